@@ -2,11 +2,35 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
 	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/archive"
+	"github.com/payment-system/dq-vault/lib/entropy"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+	"github.com/payment-system/dq-vault/lib/logging"
+	"github.com/payment-system/dq-vault/lib/queue"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/tracing"
+	"github.com/payment-system/dq-vault/lib/webhook"
 	"github.com/pkg/errors"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Factory creates a new usable instance of this secrets engine.
@@ -22,16 +46,126 @@ func Factory(ctx context.Context, c *logical.BackendConfig) (logical.Backend, er
 type Backend struct {
 	*framework.Backend
 	logger *slog.Logger
+	// logLevel is the level of logger, changed at runtime through config/logging
+	logLevel *slog.LevelVar
+
+	// userStore is the external store of the user records configured through config/storage,
+	// nil when they are kept in the Vault storage
+	userStoreMu     sync.Mutex
+	userStore       logical.Storage
+	userStoreCloser io.Closer
+	userStoreConfig *helpers.StorageConfig
+	userStoreLoaded bool
+
+	// userRecords caches the user records read by the requests when config/cache enables it
+	userCacheMu     sync.Mutex
+	userRecords     *storage.Cache
+	userCacheLoaded bool
+
+	// attestationSigner attests the responses when config/attestation enables it
+	attestationMu     sync.Mutex
+	attestationSigner ed25519.PrivateKey
+	attestationLoaded bool
+
+	// addressMu serializes the address index allocations of address/next
+	addressMu sync.Mutex
+	// userWriteMu serializes the read-check-write of the user records by the management operations,
+	// so their version checks are not raced
+	userWriteMu sync.Mutex
+	// backupMu serializes the backup verifications, so their failures are all counted
+	backupMu sync.Mutex
+	// sessionMu serializes the uses of the signing sessions of session/sign
+	sessionMu sync.Mutex
+	// approvalMu serializes the decisions and uses of the approvals of config/approvals
+	approvalMu sync.Mutex
+	// receiptMu serializes the sequence numbers of the receipts of sign
+	receiptMu sync.Mutex
+	// budgetMu serializes the debits and top-ups of the budgets of budget/top-up
+	budgetMu sync.Mutex
+	// replicationEnabled reports whether config/replication has a key, so the user record writes are
+	// journaled under journalMu; replicateMu serializes the applications of replicate/apply
+	replicationMu      sync.Mutex
+	replicationEnabled bool
+	replicationLoaded  bool
+	journalMu          sync.Mutex
+	replicateMu        sync.Mutex
+	// indexMu serializes the updates of the reverse address index of lookup/address
+	indexMu sync.Mutex
+	// dekMu serializes the changes of the encryption of the user records, by config/rotate-dek and migrate/encrypt
+	dekMu sync.Mutex
+	// eventSinkConfig is the Kafka sink of config/kafka the events are queued for, nil when none is configured
+	eventSinkMu     sync.Mutex
+	eventSinkConfig *helpers.KafkaConfig
+	eventSinkLoaded bool
+	// publishMu serializes the publications of the event queue to publisher, publishRetryAt holds
+	// back the publications of the requests after a failed one
+	publishMu      sync.Mutex
+	publisher      eventsink.Publisher
+	publishRetryAt time.Time
+	// newPublisher opens the publisher of the event queue
+	newPublisher func(eventsink.KafkaOptions) (eventsink.Publisher, error)
+	// archiveMu serializes the runs of the archiver of config/archive, newUploader opens its bucket
+	archiveMu   sync.Mutex
+	newUploader func(archive.S3Options) (archive.Uploader, error)
+	// tracingMu guards the provider of the spans of the requests, opened from config/tracing or
+	// the environment on first use
+	tracingMu       sync.Mutex
+	tracingProvider *sdktrace.TracerProvider
+	tracingLoaded   bool
+	// newTracerProvider opens the collector of the spans
+	newTracerProvider func(context.Context, tracing.Options) (*sdktrace.TracerProvider, error)
+	// jobsMu serializes the updates of the job records, jobsRunMu the runs of the jobs
+	jobsMu    sync.Mutex
+	jobsRunMu sync.Mutex
+	// activeMu guards the count of the requests and periodic runs being served: clean stops
+	// admitting them and cancels stopCtx, so the long ones checkpoint, then waits for them to end
+	activeMu sync.Mutex
+	active   int
+	stopping bool
+	drained  chan struct{}
+	stopCtx  context.Context
+	stop     context.CancelFunc
+	// inFlight counts the key operations being served, bounded by config/quotas
+	inFlight atomic.Int64
+	// requestQueue holds the key operations waiting for a slot when config/quotas queues them
+	requestQueue queue.Queue
+	// entropyMu guards the health of the entropy source of the mnemonics, checked on initialize
+	// and when config/entropy is written
+	entropyMu     sync.Mutex
+	entropyHealth *entropyHealth
+	// validationMu guards the result of the last validation of the stored configuration, run on initialize
+	validationMu sync.Mutex
+	validation   *configValidation
+	// randReader reads the entropy of crypto/rand
+	randReader io.Reader
+	// requestErrors counts the failed requests for support/bundle
+	requestErrors requestErrors
 }
 
 // NewBackend creates a new backend.
 func NewBackend(_ *logical.BackendConfig) *Backend {
 	var b Backend
 
-	b.logger = slog.With(slog.String("component", "backend"))
+	b.logLevel = new(slog.LevelVar)
+	b.newPublisher = eventsink.NewKafkaPublisher
+	b.newUploader = archive.NewS3Uploader
+	b.newTracerProvider = tracing.NewProvider
+	b.randReader = rand.Reader
+	b.requestErrors.since = time.Now()
+	b.stopCtx, b.stop = context.WithCancel(context.Background())
+	b.logger = logging.NewLogger(os.Stderr, b.logLevel).With(slog.String("component", "backend"))
 	b.Backend = &framework.Backend{
-		BackendType: logical.TypeLogical,
-		Help:        backendHelp,
+		BackendType:    logical.TypeLogical,
+		Help:           backendHelp,
+		InitializeFunc: b.initialize,
+		PeriodicFunc:   b.periodic,
+		Invalidate:     b.invalidate,
+		Clean:          b.clean,
+		PathsSpecial: &logical.Paths{
+			// unlocking a user locked by its policy violations takes the sudo capability
+			Root:            []string{"user/+/unlock"},
+			SealWrapStorage: []string{config.PassphraseStoragePath, config.ReplicationStorageKey},
+		},
 		Paths: []*framework.Path{
 
 			// api/register
@@ -42,6 +176,12 @@ func NewBackend(_ *logical.BackendConfig) *Backend {
 
 Registers new user in vault using provided UUID. Generates mnemonics if not provided and store it in vault.
 UUID must be provided by the caller. For auto-generated UUID, use register_uuid endpoint.
+Providing xpub instead registers a watch-only user, which derives addresses but cannot sign.
+With exportMnemonic, a generated mnemonic is returned once, encrypted to responsePublicKey.
+With derivationNamespace, the keys derive in a hardened namespace of the UUID, so users
+registering the same mnemonic never share a key. With expectedAddress, the user must derive it at
+expectedPath of expectedCoinType or is not registered, so migrations catch mistyped mnemonics,
+passphrases or xpubs.
 
 `,
 				Fields: map[string]*framework.FieldSchema{
@@ -65,6 +205,77 @@ UUID must be provided by the caller. For auto-generated UUID, use register_uuid
 						Description: "Passphrase of user (optional)",
 						Default:     "",
 					},
+					"xpub": {
+						Type:        framework.TypeString,
+						Description: "Extended public key of a watch-only user, registered without a mnemonic (optional)",
+						Default:     "",
+					},
+					"xpubPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of xpub, e.g. m/44'/60'/0'",
+						Default:     "",
+					},
+					"fingerprint": {
+						Type:        framework.TypeString,
+						Description: "Master key fingerprint of xpub, for the key origin of its descriptors (optional)",
+						Default:     "",
+					},
+					"allowedCoinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types the user may derive and sign for (optional, all when empty)",
+					},
+					"restrictToOwner": {
+						Type:        framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user to derive and sign (optional)",
+						Default:     false,
+					},
+					"restrictManagement": {
+						Type: framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user, or managerEntityIds, to disable, " +
+							"delete or rotate it (optional)",
+						Default: false,
+					},
+					"managerEntityIds": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Vault entities managing the user along with the registering one, implies restrictManagement (optional)",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Lifetime of the user, after which it is disabled and later purged (optional)",
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the exported mnemonic is encrypted to (optional)",
+					},
+					"exportMnemonic": {
+						Type:        framework.TypeBool,
+						Description: "Return the generated mnemonic once, encrypted to responsePublicKey (optional)",
+						Default:     false,
+					},
+					"derivationNamespace": {
+						Type:        framework.TypeBool,
+						Description: "Derive the keys in a namespace of the UUID, isolated from other users of the mnemonic (optional)",
+						Default:     false,
+					},
+					"expectedAddress": {
+						Type:        framework.TypeString,
+						Description: "Address the user must derive at expectedPath, or the registration is rejected (optional)",
+						Default:     "",
+					},
+					"expectedCoinType": {
+						Type:        framework.TypeInt,
+						Description: "Coin type of expectedAddress (required with expectedAddress)",
+					},
+					"expectedPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of expectedAddress (required with expectedAddress)",
+						Default:     "",
+					},
+					"expectedIsDev": {
+						Type:        framework.TypeBool,
+						Description: "Whether expectedAddress is a development mode address (optional)",
+						Default:     false,
+					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
 					logical.UpdateOperation: b.pathRegister,
@@ -79,6 +290,12 @@ UUID must be provided by the caller. For auto-generated UUID, use register_uuid
 
 Registers new user in vault with auto-generated UUID. Generates mnemonics if not provided and store it in vault.
 UUID will be automatically generated and returned in the response.
+Providing xpub instead registers a watch-only user, which derives addresses but cannot sign.
+With exportMnemonic, a generated mnemonic is returned once, encrypted to responsePublicKey.
+With derivationNamespace, the keys derive in a hardened namespace of the UUID, so users
+registering the same mnemonic never share a key. With expectedAddress, the user must derive it at
+expectedPath of expectedCoinType or is not registered, so migrations catch mistyped mnemonics,
+passphrases or xpubs.
 
 `,
 				Fields: map[string]*framework.FieldSchema{
@@ -97,84 +314,278 @@ UUID will be automatically generated and returned in the response.
 						Description: "Passphrase of user (optional)",
 						Default:     "",
 					},
+					"xpub": {
+						Type:        framework.TypeString,
+						Description: "Extended public key of a watch-only user, registered without a mnemonic (optional)",
+						Default:     "",
+					},
+					"xpubPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of xpub, e.g. m/44'/60'/0'",
+						Default:     "",
+					},
+					"fingerprint": {
+						Type:        framework.TypeString,
+						Description: "Master key fingerprint of xpub, for the key origin of its descriptors (optional)",
+						Default:     "",
+					},
+					"allowedCoinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types the user may derive and sign for (optional, all when empty)",
+					},
+					"restrictToOwner": {
+						Type:        framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user to derive and sign (optional)",
+						Default:     false,
+					},
+					"restrictManagement": {
+						Type: framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user, or managerEntityIds, to disable, " +
+							"delete or rotate it (optional)",
+						Default: false,
+					},
+					"managerEntityIds": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Vault entities managing the user along with the registering one, implies restrictManagement (optional)",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Lifetime of the user, after which it is disabled and later purged (optional)",
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the exported mnemonic is encrypted to (optional)",
+					},
+					"exportMnemonic": {
+						Type:        framework.TypeBool,
+						Description: "Return the generated mnemonic once, encrypted to responsePublicKey (optional)",
+						Default:     false,
+					},
+					"derivationNamespace": {
+						Type:        framework.TypeBool,
+						Description: "Derive the keys in a namespace of the UUID, isolated from other users of the mnemonic (optional)",
+						Default:     false,
+					},
+					"expectedAddress": {
+						Type:        framework.TypeString,
+						Description: "Address the user must derive at expectedPath, or the registration is rejected (optional)",
+						Default:     "",
+					},
+					"expectedCoinType": {
+						Type:        framework.TypeInt,
+						Description: "Coin type of expectedAddress (required with expectedAddress)",
+					},
+					"expectedPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of expectedAddress (required with expectedAddress)",
+						Default:     "",
+					},
+					"expectedIsDev": {
+						Type:        framework.TypeBool,
+						Description: "Whether expectedAddress is a development mode address (optional)",
+						Default:     false,
+					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
 					logical.UpdateOperation: b.pathRegisterUUID,
 				},
 			},
 
-			// api/sign
+			// api/register/init
 			{
-				Pattern:         "sign",
-				HelpSynopsis:    "Generate signature from raw transaction",
-				HelpDescription: "Generates signature from stored mnemonic and passphrase using deviation path",
+				Pattern:      "register/init",
+				HelpSynopsis: "Registers a new user pending its confirmation",
+				HelpDescription: `
+
+Registers a new user like register, but in pending status: it cannot derive or sign until
+register/confirm activates it. The response has the fingerprint of the user and its address for
+each of verifyCoinTypes, for the client to compare with the ones it derives from the mnemonic
+before confirming. A mismatch is aborted by deleting user/<uuid>; users not confirmed by
+confirmBy, 24 hours after registration, are purged. The UUID is generated when not given.
+With exportMnemonic, a generated mnemonic is returned once, encrypted to responsePublicKey.
+With derivationNamespace, the keys derive in a hardened namespace of the UUID.
+
+`,
 				Fields: map[string]*framework.FieldSchema{
 					"uuid": {
 						Type:        framework.TypeString,
-						Description: "UUID of user",
+						Description: "UUID of user (optional, generated when empty)",
+						Default:     "",
 					},
-					"path": {
+					"username": {
 						Type:        framework.TypeString,
-						Description: "Deviation path to obtain keys",
+						Description: "Username of new user (optional)",
 						Default:     "",
 					},
-					"coinType": {
+					"mnemonic": {
+						Type:        framework.TypeString,
+						Description: "Mnemonic of user (optional)",
+						Default:     "",
+					},
+					"passphrase": {
+						Type:        framework.TypeString,
+						Description: "Passphrase of user (optional)",
+						Default:     "",
+					},
+					"allowedCoinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types the user may derive and sign for (optional, all when empty)",
+					},
+					"restrictToOwner": {
+						Type:        framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user to derive and sign (optional)",
+						Default:     false,
+					},
+					"restrictManagement": {
+						Type: framework.TypeBool,
+						Description: "Only allow the Vault entity registering the user, or managerEntityIds, to confirm, " +
+							"disable, delete or rotate it (optional)",
+						Default: false,
+					},
+					"managerEntityIds": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Vault entities managing the user along with the registering one, implies restrictManagement (optional)",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Lifetime of the user, after which it is disabled and later purged (optional)",
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the exported mnemonic is encrypted to (optional)",
+					},
+					"exportMnemonic": {
+						Type:        framework.TypeBool,
+						Description: "Return the generated mnemonic once, encrypted to responsePublicKey (optional)",
+						Default:     false,
+					},
+					"derivationNamespace": {
+						Type:        framework.TypeBool,
+						Description: "Derive the keys in a namespace of the UUID, isolated from other users of the mnemonic (optional)",
+						Default:     false,
+					},
+					"expectedAddress": {
+						Type:        framework.TypeString,
+						Description: "Address the user must derive at expectedPath, or the registration is rejected (optional)",
+						Default:     "",
+					},
+					"expectedCoinType": {
 						Type:        framework.TypeInt,
-						Description: "Cointype of transaction",
+						Description: "Coin type of expectedAddress (required with expectedAddress)",
 					},
-					"payload": {
+					"expectedPath": {
 						Type:        framework.TypeString,
-						Description: "Raw transaction payload",
+						Description: "Derivation path of expectedAddress (required with expectedAddress)",
+						Default:     "",
+					},
+					"expectedIsDev": {
+						Type:        framework.TypeBool,
+						Description: "Whether expectedAddress is a development mode address (optional)",
+						Default:     false,
+					},
+					"verifyCoinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types of the addresses returned for verification (optional)",
 					},
 					"isDev": {
 						Type:        framework.TypeBool,
-						Description: "Development mode flag",
+						Description: "Development mode flag (testnet addresses)",
 						Default:     false,
 					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.pathSign,
+					logical.UpdateOperation: b.pathRegisterInit,
 				},
 			},
 
-			// api/address
+			// api/register/confirm
 			{
-				Pattern:         "address",
-				HelpSynopsis:    "Generate address of user",
-				HelpDescription: "Generates address from stored mnemonic and passphrase using deviation path",
+				Pattern:      "register/confirm",
+				HelpSynopsis: "Activates a user pending its confirmation",
+				HelpDescription: `
+
+Activates the pending user of register/init once the client verified the fingerprint and the
+addresses of the registration. The fingerprint and version must be the ones returned by
+register/init; on a fingerprint mismatch the user stays pending, and is deleted with user/<uuid>.
+
+`,
 				Fields: map[string]*framework.FieldSchema{
 					"uuid": {
 						Type:        framework.TypeString,
-						Description: "UUID of user",
+						Description: "UUID of the pending user (required)",
+						Required:    true,
 					},
-					"path": {
+					"fingerprint": {
 						Type:        framework.TypeString,
-						Description: "Deviation path to address",
-						Default:     "",
+						Description: "Master key fingerprint the client derives from the mnemonic (required)",
+						Required:    true,
 					},
-					"coinType": {
+					"version": {
 						Type:        framework.TypeInt,
-						Description: "Cointype of transaction",
+						Description: "Version of the pending user, returned by register/init (required)",
 					},
-					"isDev": {
-						Type:        framework.TypeBool,
-						Description: "Development mode flag",
-						Default:     false,
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathRegisterConfirm,
+				},
+			},
+
+			// api/gen/mnemonic
+			{
+				Pattern:      "gen/mnemonic",
+				HelpSynopsis: "Generate a mnemonic without registering a user",
+				HelpDescription: `
+
+Generates a fresh mnemonic of strength bits from the entropy source of the mount and returns it
+with the master key fingerprint it derives without passphrase. Nothing is stored: key ceremonies
+inspect and back up the mnemonic before registering it with register. With a responsePublicKey,
+the mnemonic is returned encrypted to it. Only served when config/features has mnemonicGenEnabled.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"strength": {
+						Type:        framework.TypeInt,
+						Description: "Entropy of the mnemonic in bits: 128, 160, 192, 224 or 256 (defaults to 256)",
+						Default:     config.Entropy,
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the mnemonic is encrypted to (optional)",
 					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.pathAddress,
+					logical.UpdateOperation: b.pathGenMnemonic,
 				},
 			},
 
-			// api/address/batch
+			// api/user
 			{
-				Pattern:      "address/batch",
-				HelpSynopsis: "Generate a batch of addresses for a user",
+				Pattern:      "user/?$",
+				HelpSynopsis: "List the UUIDs of the registered users",
 				HelpDescription: `
 
-Generates a batch of addresses from stored mnemonic and passphrase using a templated derivation path.
-(e.g., m/44'/60'/0'/0/%d).
+Lists the UUIDs of the users registered on the mount. Use user/<uuid> to read a user.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListUsers,
+				},
+			},
+
+			// api/user/<uuid>
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid"),
+				HelpSynopsis: "Read or delete a user record",
+				HelpDescription: `
+
+Returns the username, status, schema version, timestamps, master key fingerprint and
+allowed coin types of a user. The mnemonic and passphrase are never returned.
+Deleting purges the user with its multisig wallets, debug session, address ledger, failed
+backup verifications, escrow record, budgets and canary signature. Users registered with
+restrictManagement are only deleted by the entity that registered them or one of their
+managerEntityIds. The version counts the writes of the record: the operations changing the
+user require the version read and reject a user changed since with 409; deletions check it
+when given.
 
 `,
 				Fields: map[string]*framework.FieldSchema{
@@ -182,51 +593,3968 @@ Generates a batch of addresses from stored mnemonic and passphrase using a templ
 						Type:        framework.TypeString,
 						Description: "UUID of user",
 					},
-					"pathTemplate": {
+					"version": {
+						Type:        framework.TypeInt,
+						Description: "Version of the user read, the deletion is rejected when it changed since (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.withDebugCapture(b.pathReadUser),
+					logical.DeleteOperation: b.pathDeleteUser,
+				},
+			},
+
+			// api/user/<uuid>/disable
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/disable",
+				HelpSynopsis: "Disable a user",
+				HelpDescription: `
+
+Disables the user: its key operations are rejected with 403 until it is enabled again.
+Users registered with restrictManagement are only disabled by the entity that registered
+them or one of their managerEntityIds. The version of the user read is required; a user
+changed since is rejected with 409.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
 						Type:        framework.TypeString,
-						Description: "Templated derivation path, e.g., m/44'/60'/0'/0/%d",
+						Description: "UUID of user",
 					},
-					"coinType": {
+					"version": {
 						Type:        framework.TypeInt,
-						Description: "Cointype of transaction",
+						Description: "Version of the user read, rejected when it changed since (required)",
 					},
-					"startIndex": {
-						Type:        framework.TypeInt,
-						Description: "Start index for address generation",
-						Default:     0,
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathSetUserStatus(helpers.UserStatusDisabled),
+				},
+			},
+
+			// api/user/<uuid>/enable
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/enable",
+				HelpSynopsis: "Enable a disabled user",
+				HelpDescription: `
+
+Enables a user disabled through user/<uuid>/disable. Expired users stay expired. Users
+registered with restrictManagement are only enabled by the entity that registered them or
+one of their managerEntityIds. The version of the user read is required; a user changed
+since is rejected with 409.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
 					},
-					"count": {
+					"version": {
 						Type:        framework.TypeInt,
-						Description: "Number of addresses to generate",
+						Description: "Version of the user read, rejected when it changed since (required)",
 					},
-					"isDev": {
-						Type:        framework.TypeBool,
-						Description: "Development mode flag",
-						Default:     false,
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathSetUserStatus(helpers.UserStatusActive),
+				},
+			},
+
+			// api/user/<uuid>/violations
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/violations",
+				HelpSynopsis: "Read the policy violations noted for a user",
+				HelpDescription: `
+
+Returns the last 50 requests of the user rejected by a policy of the mount, oldest first: coin
+types not allowed, derivation paths outside config/derivation, address overrides, address book,
+contract, fee bound, budget and approval rejections, those of session/sign and of the sign/batch
+items included. Requests of other entities rejected by restrictToOwner are not noted. count is the
+number of them within the window of config/lockout, and locked whether they locked the user.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
 					},
 				},
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.UpdateOperation: b.pathAddressBatch,
+					logical.ReadOperation: b.pathReadViolations,
 				},
 			},
 
-			// api/info
+			// api/user/<uuid>/unlock
 			{
-				Pattern:      "info",
-				HelpSynopsis: "Display information about this plugin",
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/unlock",
+				HelpSynopsis: "Unlock a user locked by its policy violations",
 				HelpDescription: `
 
-Displays information about the plugin, such as the plugin version and where to
-get help.
+Activates a user locked by the maxViolations of config/lockout. It is a root path: the
+token needs the sudo capability on the path besides update, unlike user/<uuid>/enable,
+which cannot unlock users. The violations noted before the unlock no longer count. Users
+registered with restrictManagement are only unlocked by the entity that registered them or
+one of their managerEntityIds. The version of the user read is required; a user changed
+since is rejected with 409.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"version": {
+						Type:        framework.TypeInt,
+						Description: "Version of the user read, rejected when it changed since (required)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathUnlockUser,
+				},
+			},
+
+			// api/escrow
+			{
+				Pattern:      "escrow/?$",
+				HelpSynopsis: "List the UUIDs of the users with an escrow record",
+				HelpDescription: `
+
+Lists the users whose mnemonic is escrowed to the custodian key of config/escrow.
 
 `,
 				Callbacks: map[logical.Operation]framework.OperationFunc{
-					logical.ReadOperation: b.pathInfo,
+					logical.ListOperation: b.pathListEscrow,
 				},
 			},
-		},
-	}
-	return &b
+
+			// api/escrow/<uuid>
+			{
+				Pattern:      "escrow/" + framework.GenericNameRegex("uuid"),
+				HelpSynopsis: "Export or refresh the escrow record of a user",
+				HelpDescription: `
+
+Read returns the escrow record of the user: the keyId of the custodian key, the algorithm and
+the base64 ciphertext, only readable by the custodian. Update escrows the mnemonic again with
+the current key of config/escrow, for users registered before it was enabled or changed.
+Users registered with restrictManagement are only escrowed again by the entity that
+registered them or one of their managerEntityIds. Updates require the version of the user
+read; a user changed since is rejected with 409.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"version": {
+						Type:        framework.TypeInt,
+						Description: "Version of the user read, rejected when it changed since (required to update)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadEscrowRecord,
+					logical.UpdateOperation: b.pathWriteEscrowRecord,
+				},
+			},
+
+			// api/user/<uuid>/verify-backup
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/verify-backup",
+				HelpSynopsis: "Check words of the mnemonic of a user, for backup verification",
+				HelpDescription: `
+
+Reports whether the words given by their 1-based position, at least 3 of them, are the ones
+of the mnemonic of the user. The mnemonic is never returned, nor which words differ. After 5
+consecutive mismatches the path is locked for the user for an hour.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"backupWords": {
+						Type:        framework.TypeKVPairs,
+						Description: "Words of the backup by their 1-based position, e.g. 3=abandon,7=about,12=zoo",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathVerifyBackup,
+				},
+			},
+
+			// api/user/<uuid>/health
+			{
+				Pattern:      "user/" + framework.GenericNameRegex("uuid") + "/health",
+				HelpSynopsis: "Report the health issues of a user",
+				HelpDescription: `
+
+Returns healthy and the issues of the user: user_not_active, weak_passphrase when the
+passphrase does not meet config/passphrase, missing_passphrase_verifier when the policy stores
+verifiers and the user was registered without one, and passphrase_verifier_mismatch when the
+stored passphrase no longer matches its verifier. The passphrase is reported by its length and
+entropy estimate only.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathUserHealth,
+				},
+			},
+
+			// api/sign
+			{
+				Pattern:         "sign",
+				HelpSynopsis:    "Generate signature from raw transaction",
+				HelpDescription: "Generates signature from stored mnemonic and passphrase using derivation path",
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path to obtain keys",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of transaction",
+					},
+					"payload": {
+						Type:        framework.TypeString,
+						Description: "Raw transaction payload",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"complete": {
+						Type: framework.TypeBool,
+						Description: "Fetch the missing EVM nonce, gas and chainId, or a fresh Solana blockhash, " +
+							"from the config/rpc node before signing (optional)",
+						Default: false,
+					},
+					"overrideFee": {
+						Type:        framework.TypeBool,
+						Description: "Sign a payload whose fee rate is outside the bounds of config/fees (optional)",
+						Default:     false,
+					},
+					"preset": {
+						Type: framework.TypeString,
+						Description: "Named derivation path used instead of derivationPath: ethereum-default, bitcoin-segwit, " +
+							"ledger-live or trezor (optional)",
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"index": {
+						Type:        framework.TypeInt,
+						Description: "Address index of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"travelRule": {
+						Type: framework.TypeMap,
+						Description: "IVMS-101 originator, beneficiary and VASPs of the transfer, attached to the " +
+							"signature (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.signOp(false),
+				},
+			},
+
+			// api/sign/batch
+			{
+				Pattern:      "sign/batch",
+				HelpSynopsis: "Sign a batch of transactions",
+				HelpDescription: `
+
+Signs every item of items, an object with the fields of sign, with a pool of workers. The
+results are returned in the order of the items, with the status of each one (signed, failed,
+skipped or pending approval), its error and its latency in milliseconds; a failed item does
+not fail the batch.
+With failFast, the items not started when one fails are skipped. Items without an apiKey use
+the one of the batch. Every item takes a request slot of config/quotas while it is signed and
+batches larger than maxBatchCount are rejected.
+With a batchId, the result of every item is logged as it completes: a batch interrupted by a
+plugin reload, its items left reported as interrupted, is sent again with the same batchId and
+items, and the logged items are returned as resumed instead of being signed again. The logs
+are kept for 24h after their last update.
+With async, the batch is queued as a job, signed in the background and read from jobs/<id>.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"items": {
+						Type:        framework.TypeSlice,
+						Description: "Sign requests, each an object with the fields of sign",
+					},
+					"failFast": {
+						Type:        framework.TypeBool,
+						Description: "Skip the items not started yet once an item fails",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key used by the items without one (required when config/features has apiKeysRequired)",
+					},
+					"batchId": {
+						Type:        framework.TypeString,
+						Description: "Name logging the results of the batch, so it resumes when sent again (optional)",
+					},
+					"async": {
+						Type:        framework.TypeBool,
+						Description: "Queue the batch as a job instead of signing it in the request",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathSignBatch,
+				},
+			},
+
+			// api/jobs
+			{
+				Pattern:      "jobs/?$",
+				HelpSynopsis: "List the async jobs",
+				HelpDescription: `
+
+Lists the IDs of the async jobs, in the order they were queued. Finished jobs are kept for 24h,
+or as config/retention bounds them.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListJobs,
+				},
+			},
+
+			// api/jobs/<id>
+			{
+				Pattern:      "jobs/(?P<id>[0-9a-v]{20})",
+				HelpSynopsis: "Read or delete an async job",
+				HelpDescription: `
+
+Returns the status of the job (queued, running, partial, complete, failed or canceled), its
+progress and the results of the items processed so far. Jobs are run in chunks checkpointed
+in the storage, a job interrupted by a plugin reload or a failover resumes with its next items.
+Finished jobs can be deleted.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the job",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadJob,
+					logical.DeleteOperation: b.pathDeleteJob,
+				},
+			},
+
+			// api/jobs/<id>/cancel
+			{
+				Pattern:      "jobs/(?P<id>[0-9a-v]{20})/cancel",
+				HelpSynopsis: "Cancel an async job",
+				HelpDescription: `
+
+Cancels the job: a queued job is canceled at once, a running one after the chunk being
+processed. The results of the items processed are kept.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the job",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathCancelJob,
+				},
+			},
+
+			// api/session/create
+			{
+				Pattern:      "session/create",
+				HelpSynopsis: "Issue a short-lived signing session for one account",
+				HelpDescription: `
+
+Issues a session token allowing session/sign to sign, with the keys of uuid for coinType,
+at most maxOperations times under pathPrefix until the session expires (5m by default, 1h
+at most). Workers can be granted session/sign only, and receive a token scoped to the
+account they need instead of a broad policy on sign. The token is only returned here.
+With a responsePublicKey, the token is returned encrypted to it.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user whose keys the session signs with",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype the session signs for",
+					},
+					"pathPrefix": {
+						Type:        framework.TypeString,
+						Description: "Derivation path, or path prefix, the session may sign with, e.g. m/44'/60'/0'/0/7",
+					},
+					"maxOperations": {
+						Type:        framework.TypeInt,
+						Description: "Sign requests allowed to the session (optional, defaults to 1)",
+						Default:     1,
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Lifetime of the session (optional, defaults to 5m, at most 1h)",
+						Default:     int(defaultSessionTTL.Seconds()),
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the sessionToken is encrypted to (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withAPIKey(lib.OperationSign, b.pathCreateSession),
+				},
+			},
+
+			// api/session/sign
+			{
+				Pattern:      "session/sign",
+				HelpSynopsis: "Generate signature from raw transaction with a signing session",
+				HelpDescription: `
+
+Signs like sign, with the user and coin type of the session of sessionToken. The path must
+be within the pathPrefix of the session, and every such request uses one operation of the
+session. The operations left are returned in sessionRemaining.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"sessionToken": {
+						Type:        framework.TypeString,
+						Description: "Token returned by session/create",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path to obtain keys",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"payload": {
+						Type:        framework.TypeString,
+						Description: "Raw transaction payload",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"complete": {
+						Type: framework.TypeBool,
+						Description: "Fetch the missing EVM nonce, gas and chainId, or a fresh Solana blockhash, " +
+							"from the config/rpc node before signing (optional)",
+						Default: false,
+					},
+					"overrideFee": {
+						Type:        framework.TypeBool,
+						Description: "Sign a payload whose fee rate is outside the bounds of config/fees (optional)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"travelRule": {
+						Type: framework.TypeMap,
+						Description: "IVMS-101 originator, beneficiary and VASPs of the transfer, attached to the " +
+							"signature (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.signOp(true),
+				},
+			},
+
+			// api/session/<id>
+			{
+				Pattern:      "session/(?P<id>[0-9a-v]{20})",
+				HelpSynopsis: "Read or revoke a signing session",
+				HelpDescription: `
+
+Reads the scope and the operations left of a signing session, or revokes it.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the session",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadSession,
+					logical.DeleteOperation: b.pathDeleteSession,
+				},
+			},
+
+			// api/config/approvals
+			{
+				Pattern:      "config/approvals",
+				HelpSynopsis: "Configure the approval of high-value sign requests",
+				HelpDescription: `
+
+Holds the signing requests for a coin type of thresholds moving at least its amount, in base
+units, until approved; requests whose amount cannot be decoded, contract calls, user operations
+and permits included, are held too, and raw digests under any threshold. Every signing path is
+held, sign/psbt and build/btc-tx by the sum of their outputs. Held requests are posted with their decoded summary and the approve and reject
+links of linkUrl to the Slack or Teams webhookUrl of provider. The signingSecret verifies
+the callbacks of approvals/callback and is never returned, nor is the webhookUrl.
+Approvers are distinct from the requester; with approverGroups or approverClaims they must
+also be a member of one of the groups and carry the claims on an OIDC or JWT alias, and
+chat callbacks can then only reject. With format cloudevents, the webhook receives a CloudEvents
+1.0 envelope of type com.dq-vault.approval.requested instead of the chat message; with format
+template, the output of template.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"thresholds": {
+						Type:        framework.TypeKVPairs,
+						Description: "Minimum amount held for approval, in base units, by coin type",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How long a held request waits for its approval (optional, defaults to 1h)",
+						Default:     int(helpers.DefaultApprovalTTL.Seconds()),
+					},
+					"provider": {
+						Type:        framework.TypeString,
+						Description: "Chat platform of the webhook: slack or teams",
+					},
+					"webhookUrl": {
+						Type:        framework.TypeString,
+						Description: "Incoming webhook URL the held requests are posted to",
+					},
+					"signingSecret": {
+						Type:        framework.TypeString,
+						Description: "Slack signing secret, or Teams outgoing webhook security token, of the callbacks",
+					},
+					"linkUrl": {
+						Type:        framework.TypeString,
+						Description: "Base URL of the approve and reject deep links (optional)",
+					},
+					"approverGroups": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Vault identity groups the approvers must be a member of, one of them (optional)",
+					},
+					"approverClaims": {
+						Type:        framework.TypeKVPairs,
+						Description: "OIDC claims the approvers must all have on their OIDC or JWT alias (optional)",
+					},
+					"format": {
+						Type:        framework.TypeString,
+						Description: "Body of the posted notifications: native, cloudevents or template (optional, defaults to native)",
+						Default:     webhook.FormatNative,
+					},
+					"template": {
+						Type:        framework.TypeString,
+						Description: "text/template of the bodies of the template format, executed with the event",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadApprovalConfig,
+					logical.UpdateOperation: b.pathWriteApprovalConfig,
+					logical.DeleteOperation: b.pathDeleteApprovalConfig,
+				},
+			},
+
+			// api/approvals
+			{
+				Pattern:      "approvals/?$",
+				HelpSynopsis: "List the sign requests held for approval",
+				HelpDescription: `
+
+Lists the ids of the approvals not expired yet, whatever their status.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListApprovals,
+				},
+			},
+
+			// api/approvals/callback
+			{
+				Pattern:      "approvals/callback",
+				HelpSynopsis: "Take the action of a signed chat callback on an approval",
+				HelpDescription: `
+
+Approves or rejects with the callback of a Slack interaction or a Teams outgoing webhook,
+relayed as received. The signature is verified with the signingSecret of config/approvals,
+and Slack callbacks older than 5 minutes are refused. The decider is the chat user.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"body": {
+						Type:        framework.TypeString,
+						Description: "Raw body of the callback",
+					},
+					"timestamp": {
+						Type:        framework.TypeString,
+						Description: "X-Slack-Request-Timestamp header of a Slack callback",
+					},
+					"signature": {
+						Type:        framework.TypeString,
+						Description: "X-Slack-Signature header, or Authorization header of Teams",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathApprovalCallback,
+				},
+			},
+
+			// api/approvals/<id>
+			{
+				Pattern:      "approvals/(?P<id>[0-9a-v]{20})",
+				HelpSynopsis: "Read a sign request held for approval",
+				HelpDescription: `
+
+Returns the status of the approval (pending, approved, rejected or used), the decoded summary
+of its sign request, its requester and decider.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the approval",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadApproval,
+				},
+			},
+
+			// api/approvals/<id>/approve
+			{
+				Pattern:      "approvals/(?P<id>[0-9a-v]{20})/approve",
+				HelpSynopsis: "Approve a held sign request",
+				HelpDescription: `
+
+Approves the pending approval, the entity of the caller is recorded as its decider. The
+caller must not be the requester, and must hold the approver identity of config/approvals.
+The sign request is then signed when sent again with the approvalId.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the approval",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathDecideApproval(approval.ActionApprove),
+				},
+			},
+
+			// api/approvals/<id>/reject
+			{
+				Pattern:      "approvals/(?P<id>[0-9a-v]{20})/reject",
+				HelpSynopsis: "Reject a held sign request",
+				HelpDescription: `
+
+Rejects the pending approval, the entity of the caller is recorded as its decider.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the approval",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathDecideApproval(approval.ActionReject),
+				},
+			},
+
+			// api/receipts/<id>
+			{
+				Pattern:      "receipts/(?P<id>[0-9a-v]{20})",
+				HelpSynopsis: "Read the receipt of a signature",
+				HelpDescription: `
+
+Returns the receipt sign recorded with a signature: the user, coin type, derivation path and
+address of the key, the SHA-256 of the payload as signed and of the signature, the approval
+and entity of the request, and its sequence number, which orders the signatures of the mount.
+The SHA-256 of a raw transaction found on chain matches the signatureHash of its receipt.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"id": {
+						Type:        framework.TypeString,
+						Description: "ID of the receipt",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadReceipt,
+				},
+			},
+
+			// api/budget/top-up
+			{
+				Pattern:      "budget/top-up",
+				HelpSynopsis: "Replenish the signed-value budget of a user on a coin type",
+				HelpDescription: `
+
+Adds amount, in base units, to the budget of the user on the coin type, starting it on the
+first top-up. Once a user has a budget, every signature of any signing path for the coin
+type is charged the native value it sends, without its fee, and refused when the budget left
+is too low or the value cannot be decoded. Only a top-up replenishes it: grant this path separately from sign and from
+reading budgets, so the holders of the signing policies cannot raise their own ceiling.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the budget",
+					},
+					"amount": {
+						Type:        framework.TypeString,
+						Description: "Amount added to the budget, a positive integer in base units",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathTopUpBudget,
+				},
+			},
+
+			// api/budget/<uuid>
+			{
+				Pattern:      "budget/" + framework.GenericNameRegex("uuid") + "/?$",
+				HelpSynopsis: "List the coin types a user has a budget on",
+				HelpDescription: `
+
+Lists the coin types the signatures of the user are charged to a budget on.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListBudgets,
+				},
+			},
+
+			// api/budget/<uuid>/<coinType>
+			{
+				Pattern:      "budget/" + framework.GenericNameRegex("uuid") + "/(?P<coinType>\\d+)",
+				HelpSynopsis: "Read or delete the signed-value budget of a user on a coin type",
+				HelpDescription: `
+
+Returns the amount left, spent and topped up of the budget, in base units, with the number of
+top-ups and the entity of the last one. Deleting the budget lifts the ceiling: the signatures
+of the user on the coin type are no longer charged.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"coinType": {
+						Type:        framework.TypeString,
+						Description: "Cointype of the budget",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadBudget,
+					logical.DeleteOperation: b.pathDeleteBudget,
+				},
+			},
+
+			// api/sign/spl-transfer
+			{
+				Pattern:      "sign/spl-transfer",
+				HelpSynopsis: "Build and sign an SPL token transfer",
+				HelpDescription: `
+
+Builds a Solana SPL token transfer between the associated token accounts of the derived
+wallet and the recipient, and signs it. The derived wallet pays the fee. Supports both the
+SPL Token and Token-2022 programs; Token-2022 transfers require decimals (TransferChecked).
+A memo is logged by an instruction of the memo program after the transfer.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the owner wallet, e.g., m/44'/501'/0'/0'",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"mint": {
+						Type:        framework.TypeString,
+						Description: "Base58 address of the token mint",
+					},
+					"recipient": {
+						Type:        framework.TypeString,
+						Description: "Base58 wallet address of the recipient (not the token account)",
+					},
+					"amount": {
+						Type:        framework.TypeString,
+						Description: "Amount to transfer in base units",
+					},
+					"recentBlockhash": {
+						Type:        framework.TypeString,
+						Description: "Base58 recent blockhash",
+					},
+					"tokenProgram": {
+						Type:        framework.TypeString,
+						Description: "Token program: spl-token or token-2022",
+						Default:     solana.TokenProgramSPL,
+					},
+					"decimals": {
+						Type:        framework.TypeInt,
+						Description: "Mint decimals; when set the TransferChecked instruction is used",
+						Default:     -1,
+					},
+					"createRecipientAccount": {
+						Type:        framework.TypeBool,
+						Description: "Create the recipient associated token account if missing",
+						Default:     false,
+					},
+					"memo": {
+						Type:        framework.TypeString,
+						Description: "Memo of the transfer, e.g. the deposit memo of an exchange (optional)",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSPLTransfer, splTransferIntent, b.pathSignSPLTransfer),
+				},
+			},
+
+			// api/sign/psbt
+			{
+				Pattern:      "sign/psbt",
+				HelpSynopsis: "Sign the inputs of a Bitcoin PSBT",
+				HelpDescription: `
+
+Signs the inputs of a BIP-174 PSBT spending outputs of the user's wallet, without finalizing them.
+The keys are selected from the BIP-32 derivations of the inputs carrying the wallet fingerprint, and
+from the given pkh, sh(wpkh), wpkh or tr output descriptors, as returned by the xpub path.
+With uuids, the inputs of several users are signed in one call, e.g., for consolidation sweeps:
+every user is authorized on its own and must sign an input, each descriptor goes to the wallet
+of its fingerprint, and inputs attributes each signed input to its user.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"uuids": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "UUIDs of the users whose inputs are signed, instead of or along with uuid (optional)",
+					},
+					"psbt": {
+						Type:        framework.TypeString,
+						Description: "Base64 or hex encoded PSBT",
+					},
+					"descriptors": {
+						Type:        framework.TypeStringSlice,
+						Description: "Output descriptors of the wallet with key origin, e.g., wpkh([fp/84h/0h/0h]xpub.../0/*)",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag (testnet)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignPSBT, signPSBTIntent, b.pathSignPSBT),
+				},
+			},
+
+			// api/build/btc-tx
+			{
+				Pattern:      "build/btc-tx",
+				HelpSynopsis: "Build and sign a Bitcoin transaction from UTXOs of the wallet",
+				HelpDescription: `
+
+Builds the transaction spending every given UTXO of the user's wallet to the outputs, at the fee
+rate in sat/vB, signs it and returns the raw transaction. The fee is estimated from the signed
+size of the inputs, so the transaction pays at least the fee rate. The change is paid last to
+the next unused address of the internal chain (m/<changePurpose>'/<coinType>'/<account>'/1/i),
+allocated as address/next does; a change below the dust limit is left to the fee. Only segwit
+UTXOs are spent, legacy ones are signed as a PSBT with sign/psbt. The fee rate is checked
+against the bounds of config/fees.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"utxos": {
+						Type:        framework.TypeSlice,
+						Description: "UTXOs spent, each an object with the txid, vout, value in sat and path of its key",
+					},
+					"outputs": {
+						Type:        framework.TypeSlice,
+						Description: "Outputs paid, in order, each an object with the address and value in sat",
+					},
+					"feeRate": {
+						Type:        framework.TypeFloat,
+						Description: "Fee rate in sat/vB",
+					},
+					"changePurpose": {
+						Type:        framework.TypeInt,
+						Description: "Purpose of the change path, i.e., its address type (defaults to 84, p2wpkh)",
+						Default:     defaultChangePurpose,
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account of the change address (defaults to 0)",
+						Default:     0,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag (testnet)",
+						Default:     false,
+					},
+					"overrideFee": {
+						Type:        framework.TypeBool,
+						Description: "Sign a fee rate outside the bounds of config/fees (optional)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.buildBTCTxOp(),
+				},
+			},
+
+			// api/build/evm-tx
+			{
+				Pattern:      "build/evm-tx",
+				HelpSynopsis: "Build and sign an EVM transaction from its fields",
+				HelpDescription: `
+
+Builds the sign payload of the legacy (EIP-155) transaction of the fields and signs it as sign
+does: payload hooks, travel rule, approvals, budgets, fee bounds, the address book and receipts
+apply, and API keys need the sign operation. Quantities are decimal or 0x hex strings. With
+complete, the nonce, gas limit, gas price and chainId left empty are fetched from the node of
+config/rpc. Returns the fields of sign with the signed rawTx, its txHash and the payload signed.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the sender key",
+						Default:     "",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "EVM cointype of the sender key (optional, defaults to 60)",
+						Default:     60,
+					},
+					"to": {
+						Type:        framework.TypeString,
+						Description: "Recipient, or the contract called; empty deploys the contract of data",
+						Default:     "",
+					},
+					"value": {
+						Type:        framework.TypeString,
+						Description: "Value in wei (optional, defaults to 0)",
+						Default:     "",
+					},
+					"data": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded call data (optional)",
+						Default:     "",
+					},
+					"nonce": {
+						Type:        framework.TypeString,
+						Description: "Nonce of the sender (optional with complete)",
+						Default:     "",
+					},
+					"gasLimit": {
+						Type:        framework.TypeString,
+						Description: "Gas limit (optional with complete)",
+						Default:     "",
+					},
+					"gasPrice": {
+						Type:        framework.TypeString,
+						Description: "Gas price in wei (optional with complete)",
+						Default:     "",
+					},
+					"chainId": {
+						Type:        framework.TypeString,
+						Description: "Chain id of the transaction (optional with complete)",
+						Default:     "",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"complete": {
+						Type:        framework.TypeBool,
+						Description: "Fetch the nonce, gas and chainId left empty from the config/rpc node (optional)",
+						Default:     false,
+					},
+					"overrideFee": {
+						Type:        framework.TypeBool,
+						Description: "Sign a gas price outside the bounds of config/fees (optional)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"travelRule": {
+						Type: framework.TypeMap,
+						Description: "IVMS-101 originator, beneficiary and VASPs of the transfer, attached to the " +
+							"signature (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathBuildEVMTx,
+				},
+			},
+
+			// api/transfer
+			{
+				Pattern:      "transfer",
+				HelpSynopsis: "Build and sign the transaction of a transfer intent on any supported chain",
+				HelpDescription: `
+
+Builds and signs the transaction paying amount of the asset, or of the native coin when asset is
+empty, from the key of index on the account of the user to the recipient, on the chain of coinType.
+The sender path is m/44'/<coinType>'/<account>'/0/<index> (hardened throughout on ed25519 chains).
+The transaction is signed through the path signing it, whose policies and API key operation apply:
+build/evm-tx on EVM chains (an ERC-20 transfer for a token contract asset), sign on Solana (an SPL
+transfer for a mint asset) and build/btc-tx on Bitcoin, which spends the given utxos. Native amounts
+are in unit, the coin symbol by default, asset amounts in the unit of their decimals, taken from
+config/assets for the assets it registers, which can be named by their symbol. The node of
+config/rpc completes the transaction: the gas price it suggests scaled by the fee preference (100%,
+110% and 130% for low, medium and high), the nonce and gas, the Solana blockhash, and the Bitcoin fee
+rate for a confirmation within 24, 6 and 2 blocks unless feeRate is given. Returns the rawTx and
+txHash of every chain with the fields of the path signing it and the normalized intent.
+A memo, which exchanges credit deposits by, is added as an instruction of the memo program on
+Solana; the chains without memos reject it rather than send a transfer the recipient cannot credit.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the chain",
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account of the sender (defaults to 0)",
+						Default:     0,
+					},
+					"index": {
+						Type:        framework.TypeInt,
+						Description: "Address index of the sender on the account (defaults to 0)",
+						Default:     0,
+					},
+					"asset": {
+						Type: framework.TypeString,
+						Description: "Symbol or address of a config/assets token, or a contract or mint address " +
+							"(optional, empty transfers the native coin)",
+						Default: "",
+					},
+					"decimals": {
+						Type:        framework.TypeInt,
+						Description: "Decimals of the asset (required with an asset missing from config/assets)",
+						Default:     -1,
+					},
+					"recipient": {
+						Type:        framework.TypeString,
+						Description: "Address of the recipient wallet",
+					},
+					"amount": {
+						Type:        framework.TypeString,
+						Description: "Decimal amount transferred, e.g., 1.5",
+					},
+					"unit": {
+						Type:        framework.TypeString,
+						Description: "Unit of a native amount, e.g., wei or sat (optional, defaults to the coin symbol)",
+						Default:     "",
+					},
+					"feePreference": {
+						Type:        framework.TypeString,
+						Description: "low, medium or high (optional, defaults to medium)",
+						Default:     "medium",
+					},
+					"utxos": {
+						Type:        framework.TypeSlice,
+						Description: "Bitcoin UTXOs spent, each an object with the txid, vout, value in sat and path of its key",
+					},
+					"feeRate": {
+						Type:        framework.TypeFloat,
+						Description: "Bitcoin fee rate in sat/vB, instead of the estimate of the fee preference (optional)",
+					},
+					"tokenProgram": {
+						Type:        framework.TypeString,
+						Description: "Solana token program of the asset: spl-token or token-2022",
+						Default:     solana.TokenProgramSPL,
+					},
+					"createRecipientAccount": {
+						Type:        framework.TypeBool,
+						Description: "Create the Solana associated token account of the recipient if missing",
+						Default:     false,
+					},
+					"memo": {
+						Type:        framework.TypeString,
+						Description: "Memo the recipient credits the transfer by, e.g. the deposit memo of an exchange (Solana)",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"overrideFee": {
+						Type:        framework.TypeBool,
+						Description: "Sign a fee outside the bounds of config/fees (optional)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"travelRule": {
+						Type: framework.TypeMap,
+						Description: "IVMS-101 originator, beneficiary and VASPs of the transfer, attached to the " +
+							"signature (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathTransfer,
+				},
+			},
+
+			// api/sign/safe-tx
+			{
+				Pattern:      "sign/safe-tx",
+				HelpSynopsis: "Sign the hash of a Safe multisig transaction",
+				HelpDescription: `
+
+Computes the EIP-712 SafeTx hash of a Safe (formerly Gnosis Safe) transaction from its
+parameters and returns the signature of the owner key of the path, as expected by the
+signatures of execTransaction (r, s, v with v 27 or 28). Safe versions before 1.3.0 hash
+without the chainId in the domain. Amounts are decimal or 0x hex strings.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the owner key",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "EVM cointype of the owner key (optional, defaults to 60)",
+						Default:     60,
+					},
+					"chainId": {
+						Type:        framework.TypeString,
+						Description: "Chain id of the Safe",
+					},
+					"safe": {
+						Type:        framework.TypeString,
+						Description: "Address of the Safe",
+					},
+					"to": {
+						Type:        framework.TypeString,
+						Description: "Destination of the transaction",
+					},
+					"value": {
+						Type:        framework.TypeString,
+						Description: "Value in wei (optional, defaults to 0)",
+						Default:     "0",
+					},
+					"data": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded call data (optional)",
+						Default:     "",
+					},
+					"operation": {
+						Type:        framework.TypeInt,
+						Description: "0 for call, 1 for delegatecall (optional, defaults to 0)",
+						Default:     0,
+					},
+					"safeTxGas": {
+						Type:        framework.TypeString,
+						Description: "Gas of the Safe transaction (optional, defaults to 0)",
+						Default:     "0",
+					},
+					"baseGas": {
+						Type:        framework.TypeString,
+						Description: "Gas costs independent of the transaction execution (optional, defaults to 0)",
+						Default:     "0",
+					},
+					"gasPrice": {
+						Type:        framework.TypeString,
+						Description: "Gas price of the refund (optional, defaults to 0)",
+						Default:     "0",
+					},
+					"gasToken": {
+						Type:        framework.TypeString,
+						Description: "Token of the refund, zero address for ether (optional)",
+						Default:     "",
+					},
+					"refundReceiver": {
+						Type:        framework.TypeString,
+						Description: "Receiver of the refund, zero address for tx.origin (optional)",
+						Default:     "",
+					},
+					"nonce": {
+						Type:        framework.TypeString,
+						Description: "Nonce of the Safe",
+					},
+					"safeVersion": {
+						Type:        framework.TypeString,
+						Description: "Version of the Safe contract (optional, defaults to 1.3.0)",
+						Default:     evm.DefaultSafeVersion,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignSafeTx, safeTxIntent, b.pathSignSafeTx),
+				},
+			},
+
+			// api/sign/userop
+			{
+				Pattern:      "sign/userop",
+				HelpSynopsis: "Sign an ERC-4337 user operation",
+				HelpDescription: `
+
+Computes the userOpHash of an ERC-4337 user operation for the EntryPoint and chain id and
+returns the signature of the owner key of the path (r, s, v with v 27 or 28). The EIP-191
+message of the hash is signed, as SimpleAccount verifies it, unless rawHash is set. For
+EntryPoint 0.7, initCode and paymasterAndData are the packed fields of the
+PackedUserOperation. Amounts are decimal or 0x hex strings.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the owner key",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "EVM cointype of the owner key (optional, defaults to 60)",
+						Default:     60,
+					},
+					"chainId": {
+						Type:        framework.TypeString,
+						Description: "Chain id of the EntryPoint",
+					},
+					"entryPoint": {
+						Type:        framework.TypeString,
+						Description: "Address of the EntryPoint",
+					},
+					"entryPointVersion": {
+						Type:        framework.TypeString,
+						Description: "Version of the EntryPoint, 0.6 or 0.7 (optional, defaults to 0.7)",
+						Default:     evm.DefaultEntryPointVersion,
+					},
+					"sender": {
+						Type:        framework.TypeString,
+						Description: "Address of the smart account",
+					},
+					"nonce": {
+						Type:        framework.TypeString,
+						Description: "Nonce of the account in the EntryPoint",
+					},
+					"initCode": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded factory and factory data deploying the account (optional)",
+						Default:     "",
+					},
+					"callData": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded call data of the account (optional)",
+						Default:     "",
+					},
+					"callGasLimit": {
+						Type:        framework.TypeString,
+						Description: "Gas of the account call",
+					},
+					"verificationGasLimit": {
+						Type:        framework.TypeString,
+						Description: "Gas of the verification step",
+					},
+					"preVerificationGas": {
+						Type:        framework.TypeString,
+						Description: "Gas paid to the bundler for the calldata and overhead",
+					},
+					"maxFeePerGas": {
+						Type:        framework.TypeString,
+						Description: "Maximum fee per gas, as in EIP-1559",
+					},
+					"maxPriorityFeePerGas": {
+						Type:        framework.TypeString,
+						Description: "Maximum priority fee per gas, as in EIP-1559",
+					},
+					"paymasterAndData": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded paymaster and its data (optional)",
+						Default:     "",
+					},
+					"rawHash": {
+						Type:        framework.TypeBool,
+						Description: "Sign the userOpHash itself instead of its EIP-191 message (optional)",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignUserOp, userOpIntent, b.pathSignUserOp),
+				},
+			},
+
+			// api/sign/permit
+			{
+				Pattern:      "sign/permit",
+				HelpSynopsis: "Sign an EIP-2612 or Permit2 token permit",
+				HelpDescription: `
+
+Builds the EIP-712 typed data of a token permit and returns the signature of the owner key
+of the path with its v, r and s components. The type is eip2612 for the permit function of
+the token, with its EIP-712 domain name and version, permit2 for a Permit2 PermitSingle
+allowance or permit2-transfer for a Permit2 PermitTransferFrom. The domain separator is
+returned to compare with the DOMAIN_SEPARATOR of the verifying contract. Amounts are decimal
+or 0x hex strings.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the owner key",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "EVM cointype of the owner key (optional, defaults to 60)",
+						Default:     60,
+					},
+					"type": {
+						Type:        framework.TypeString,
+						Description: "eip2612, permit2 or permit2-transfer (optional, defaults to eip2612)",
+						Default:     evm.PermitEIP2612,
+					},
+					"chainId": {
+						Type:        framework.TypeString,
+						Description: "Chain id of the token",
+					},
+					"token": {
+						Type:        framework.TypeString,
+						Description: "Address of the token",
+					},
+					"spender": {
+						Type:        framework.TypeString,
+						Description: "Address allowed to spend the tokens",
+					},
+					"amount": {
+						Type:        framework.TypeString,
+						Description: "Amount in the token base unit",
+					},
+					"nonce": {
+						Type:        framework.TypeString,
+						Description: "Nonce of the owner in the token or Permit2",
+					},
+					"deadline": {
+						Type:        framework.TypeString,
+						Description: "Unix time the signature is valid until",
+					},
+					"expiration": {
+						Type:        framework.TypeString,
+						Description: "Unix time the permit2 allowance ends (optional, defaults to the deadline)",
+						Default:     "",
+					},
+					"tokenName": {
+						Type:        framework.TypeString,
+						Description: "EIP-712 domain name of the eip2612 token",
+						Default:     "",
+					},
+					"tokenVersion": {
+						Type:        framework.TypeString,
+						Description: "EIP-712 domain version of the eip2612 token (optional, defaults to 1)",
+						Default:     "1",
+					},
+					"permit2": {
+						Type:        framework.TypeString,
+						Description: "Address of Permit2 (optional, defaults to the canonical deployment)",
+						Default:     evm.Permit2Address,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignPermit, permitIntent, b.pathSignPermit),
+				},
+			},
+
+			// api/sign/digest
+			{
+				Pattern:      "sign/digest",
+				HelpSynopsis: "Sign a raw 32 byte digest",
+				HelpDescription: `
+
+Signs a caller provided 32 byte digest with the key derived on the chosen curve
+(secp256k1 or ed25519), for chains without a native adapter. Disabled unless
+config/features has signDigestEnabled set; grant this path separately in policies.
+A message can be given instead, hashed by the engine with hash. With
+digestPreimageRequired only messages are signed, and the messages recognized as
+the pre-image of an EVM, Bitcoin or Solana transaction are refused.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the signing key",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"digest": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded 32 byte digest",
+					},
+					"message": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded pre-image, signed as its hash instead of digest",
+					},
+					"hash": {
+						Type:        framework.TypeString,
+						Description: "Hash of the message: keccak256, sha256 or sha256d",
+					},
+					"curve": {
+						Type:        framework.TypeString,
+						Description: "Signing curve: secp256k1 or ed25519",
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignDigest, digestIntent("sign/digest"), b.pathSignDigest),
+				},
+			},
+
+			// api/sign/digest/override
+			{
+				Pattern:      "sign/digest/override",
+				HelpSynopsis: "Sign a raw digest without the digest policy",
+				HelpDescription: `
+
+Signs as sign/digest does, without the digestPreimageRequired policy of config/features:
+raw digests and recognized transaction pre-images are signed. Meant for admins, grant
+it only in their policies. Requires signDigestEnabled.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the signing key",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"digest": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded 32 byte digest",
+					},
+					"message": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded pre-image, signed as its hash instead of digest",
+					},
+					"hash": {
+						Type:        framework.TypeString,
+						Description: "Hash of the message: keccak256, sha256 or sha256d",
+					},
+					"curve": {
+						Type:        framework.TypeString,
+						Description: "Signing curve: secp256k1 or ed25519",
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationSignDigest, digestIntent("sign/digest/override"),
+						b.pathSignDigestOverride),
+				},
+			},
+
+			// api/broadcast
+			{
+				Pattern:      "broadcast",
+				HelpSynopsis: "Relay a signed transaction to the node of its coin",
+				HelpDescription: `
+
+Relays a hex encoded signed transaction to the node configured in config/rpc for its coin
+type (EVM, Solana or finalized Bitcoin transactions) and returns the transaction hash.
+Transport errors and 429 or 5xx responses are retried. Disabled unless config/features has
+broadcastEnabled set.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the transaction",
+					},
+					"signedTx": {
+						Type:        framework.TypeString,
+						Description: "Hex encoded signed transaction",
+					},
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user who signed the transaction, for the audit log (optional)",
+						Default:     "",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withAPIKey(lib.OperationBroadcast, b.pathBroadcast),
+				},
+			},
+
+			// api/address
+			{
+				Pattern:         "address",
+				HelpSynopsis:    "Generate address of user",
+				HelpDescription: "Generates address from stored mnemonic and passphrase using derivation path",
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the address",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of transaction",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"preset": {
+						Type: framework.TypeString,
+						Description: "Named derivation path used instead of derivationPath: ethereum-default, bitcoin-segwit, " +
+							"ledger-live or trezor (optional)",
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"index": {
+						Type:        framework.TypeInt,
+						Description: "Address index of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"hrp": {
+						Type:        framework.TypeString,
+						Description: "Human readable part of the bech32 address of a Bitcoin fork, e.g., ltc (optional)",
+					},
+					"pubKeyHashVersion": {
+						Type:        framework.TypeInt,
+						Description: "Version byte of the p2pkh address of a Bitcoin fork, from 0 to 255 (optional)",
+					},
+					"scriptHashVersion": {
+						Type:        framework.TypeInt,
+						Description: "Version byte of the p2sh address of a Bitcoin fork, from 0 to 255 (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddress, b.pathAddress)),
+				},
+			},
+
+			// api/address/prove
+			{
+				Pattern:      "address/prove",
+				HelpSynopsis: "Prove the ownership of an address by signing a challenge with its key",
+				HelpDescription: `
+
+Returns the address of the derivation path and the signature, with its key, of the proof message
+"dq-vault address proof\naddress: <address>\nchallenge: <challenge>", so an exchange whitelisting
+the address can check its ownership without a transfer. The scheme of the signature follows the
+coin: bip137 for Bitcoin (base64, not for taproot addresses), eip191 for the other secp256k1 coins
+(personal_sign, 0x r || s || v) and ed25519 for the ed25519 coins (hex). The challenge is chosen by
+the verifier, up to 256 printable characters; the prefix of the message keeps the proofs from being
+transactions. API keys need the address operation.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the address",
+						Default:     "",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the address",
+					},
+					"challenge": {
+						Type:        framework.TypeString,
+						Description: "Challenge of the verifier, signed in the proof message",
+						Required:    true,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddress, b.pathAddressProve)),
+				},
+			},
+
+			// api/xpub
+			{
+				Pattern:      "xpub",
+				HelpSynopsis: "Return the extended public key of a derivation path",
+				HelpDescription: `
+
+Returns the extended public key of a secp256k1 derivation path with its key origin. For Bitcoin
+the receive and change output descriptors of the account are returned too, their address type
+following the purpose of the path (44' pkh, 49' sh(wpkh), 84' wpkh, 86' tr).
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the account, e.g., m/84'/0'/0'",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the account",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag (tpub for Bitcoin)",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddress, b.pathXpub)),
+				},
+			},
+
+			// api/address/batch
+			{
+				Pattern:      "address/batch",
+				HelpSynopsis: "Generate a batch of addresses for a user",
+				HelpDescription: `
+
+Generates a batch of addresses from stored mnemonic and passphrase using a templated derivation path.
+(e.g., m/44'/60'/0'/0/%d).
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"pathTemplate": {
+						Type:        framework.TypeString,
+						Description: "Templated derivation path, e.g., m/44'/60'/0'/0/%d",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of transaction",
+					},
+					"startIndex": {
+						Type:        framework.TypeInt,
+						Description: "Start index for address generation",
+						Default:     0,
+					},
+					"count": {
+						Type:        framework.TypeInt,
+						Description: "Number of addresses to generate",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddressBatch, b.pathAddressBatch)),
+				},
+			},
+
+			// api/address/vanity
+			{
+				Pattern:      "address/vanity",
+				HelpSynopsis: "Search the derivation indexes of a user for an address with a prefix",
+				HelpDescription: `
+
+Derives the addresses of pathTemplate from startIndex, at most maxIterations of them, up to
+100000, and returns the first one starting with prefix with its path and index, for memorable
+test fixtures. The prefix is 1 to 16 letters and digits matched, unless caseSensitive, in any case,
+e.g., 0xdead. A search finding none fails with a 404 naming the indexes searched, to resume from
+the next one. Only the development mode addresses are searched and the addresses found are not
+indexed. Only served when config/features has vanityAddressEnabled; never fund these addresses on
+mainnets. API keys need the address/batch operation.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"pathTemplate": {
+						Type:        framework.TypeString,
+						Description: "Templated derivation path, e.g., m/44'/60'/0'/0/%d",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of transaction",
+					},
+					"prefix": {
+						Type:        framework.TypeString,
+						Description: "Prefix of the address searched, e.g., 0xdead",
+					},
+					"caseSensitive": {
+						Type:        framework.TypeBool,
+						Description: "Match the case of the prefix (optional, defaults to false)",
+						Default:     false,
+					},
+					"startIndex": {
+						Type:        framework.TypeInt,
+						Description: "First index searched",
+						Default:     0,
+					},
+					"maxIterations": {
+						Type:        framework.TypeInt,
+						Description: "Max number of indexes searched, up to 100000 (optional, defaults to 10000)",
+						Default:     defaultVanityIterations,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag, must be true (defaults to true)",
+						Default:     true,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddressBatch, b.pathAddressVanity)),
+				},
+			},
+
+			// api/address/next
+			{
+				Pattern:      "address/next",
+				HelpSynopsis: "Allocate the next unused address of an account",
+				HelpDescription: `
+
+Allocates the next unused index of the (account, change) chain of the user coin, records it in
+the address ledger of the mount and returns its address and path, m/<purpose>'/<coinType>'/<account>'/<change>/<index>
+(hardened throughout for ed25519 coins). Indexes are never handed out twice, so several
+application instances can assign deposit addresses concurrently.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of user",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the address",
+					},
+					"purpose": {
+						Type:        framework.TypeInt,
+						Description: "BIP-43 purpose of the path, e.g., 84 for Bitcoin p2wpkh (optional, defaults to 44)",
+						Default:     defaultAddressPurpose,
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account index (optional, defaults to 0)",
+						Default:     0,
+					},
+					"change": {
+						Type:        framework.TypeInt,
+						Description: "Chain of the account, 0 for receive and 1 for change addresses (optional, defaults to 0)",
+						Default:     0,
+					},
+					"reference": {
+						Type:        framework.TypeString,
+						Description: "Reference of the assignee recorded with the index, e.g., a customer id (optional)",
+						Default:     "",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationAddressNext, b.pathAddressNext)),
+				},
+			},
+
+			// api/lookup/address
+			{
+				Pattern:      "lookup/address",
+				HelpSynopsis: "Find the user and path of a derived address",
+				HelpDescription: `
+
+Returns the users, coin types and paths an address was derived from. Only addresses returned by
+the address, address/batch and address/next endpoints while config/features has
+addressIndexEnabled are indexed; unknown addresses return 404.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"address": {
+						Type:        framework.TypeString,
+						Description: "Address to look up",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathLookupAddress,
+					logical.UpdateOperation: b.pathLookupAddress,
+				},
+			},
+
+			// api/compat/verify
+			{
+				Pattern:      "compat/verify",
+				HelpSynopsis: "Check that a hardware wallet address matches the derivation of a mnemonic",
+				HelpDescription: `
+
+Derives the address of a test mnemonic at derivationPath, or at a preset with its account and
+index, and reports whether it matches the address shown by a Ledger or Trezor for the same mnemonic.
+On a mismatch, the first accounts and indexes of every preset of the coin are searched and
+the preset path deriving the address is returned in matchedPath. Nothing is stored. Only
+served when config/features has compatVerifyEnabled; never send production mnemonics.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"mnemonic": {
+						Type:        framework.TypeString,
+						Description: "Test mnemonic loaded on the hardware wallet",
+					},
+					"passphrase": {
+						Type:        framework.TypeString,
+						Description: "BIP-39 passphrase of the mnemonic (optional)",
+						Default:     "",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the address",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the address",
+						Default:     "",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+					"preset": {
+						Type: framework.TypeString,
+						Description: "Named derivation path used instead of path: ethereum-default, bitcoin-segwit, " +
+							"ledger-live or trezor (optional)",
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"index": {
+						Type:        framework.TypeInt,
+						Description: "Address index of the preset path (optional, defaults to 0)",
+						Default:     0,
+					},
+					"address": {
+						Type:        framework.TypeString,
+						Description: "Address shown by the hardware wallet",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathCompatVerify,
+				},
+			},
+
+			// api/dev/vectors
+			{
+				Pattern:      "dev/vectors",
+				HelpSynopsis: "Return the deterministic test users and their expected addresses",
+				HelpDescription: `
+
+Returns fixed test users, the public BIP-39 test mnemonics, with the address of each at the
+usual path of every supported coin type, the testnet addresses unless isDev is false. QA
+environments share identical fixtures from it without copying secrets around, and can register
+the mnemonics to sign with them. Only served when config/features has devVectorsEnabled; never
+fund these addresses on mainnets.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types of the addresses (optional, all when empty)",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Development mode flag (testnet addresses, defaults to true)",
+						Default:     true,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathDevVectors,
+				},
+			},
+
+			// api/config/features
+			{
+				Pattern:      "config/features",
+				HelpSynopsis: "Read or update the feature flags of this mount",
+				HelpDescription: `
+
+Feature flags enable or disable whole subsystems of the mount at runtime. Risky subsystems
+(sign/digest, broadcast, compat/verify) are disabled by default and only the watch-only
+export is enabled; flags omitted from an update keep their current value and deleting
+restores the defaults. The flags are also reported by info.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"signDigestEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the sign/digest endpoint",
+					},
+					"apiKeysRequired": {
+						Type:        framework.TypeBool,
+						Description: "Reject address and sign requests without a valid apiKey",
+					},
+					"broadcastEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the broadcast endpoint",
+					},
+					"exportEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the export/watch-only endpoint",
+					},
+					"addressIndexEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Record the addresses derived by the address endpoints for lookup/address",
+					},
+					"compatVerifyEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the compat/verify endpoint, on development mounts only",
+					},
+					"digestPreimageRequired": {
+						Type:        framework.TypeBool,
+						Description: "Make sign/digest hash the message itself and refuse recognized transaction pre-images",
+					},
+					"encryptedResponsesRequired": {
+						Type:        framework.TypeBool,
+						Description: "Refuse session/create, apikeys/<name> and gen/mnemonic requests without a responsePublicKey",
+					},
+					"devVectorsEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the dev/vectors endpoint, on development mounts only",
+					},
+					"vanityAddressEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the address/vanity endpoint, on development mounts only",
+					},
+					"mnemonicGenEnabled": {
+						Type:        framework.TypeBool,
+						Description: "Enable the gen/mnemonic endpoint generating mnemonics without registering a user",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadFeatures,
+					logical.UpdateOperation: b.pathWriteFeatures,
+					logical.DeleteOperation: b.pathDeleteFeatures,
+				},
+			},
+
+			// api/config/quotas
+			{
+				Pattern:      "config/quotas",
+				HelpSynopsis: "Read or update the request quotas of this mount",
+				HelpDescription: `
+
+Quotas protect the Vault node from misbehaving clients. Batches larger than maxBatchCount are
+rejected with 413 and key operations beyond maxConcurrentRequests in flight with 429, so
+clients can back off and retry. Quotas omitted from an update keep their current value and
+deleting restores the defaults.
+
+Requests, and items of sign/batch, with a mnemonic, passphrase, username, payload or
+pathTemplate longer than its maxFieldLengths are rejected with 413 before the fields are
+decoded. The lengths are in bytes; the fields omitted from an update keep their length.
+
+With queue, the key operations beyond maxConcurrentRequests wait for a slot instead, up to
+maxQueueDepth waiting for at most maxQueueWait, and are rejected with 429 beyond them. The
+items of sign/batch and of async jobs wait in the batch class, the other requests in the
+interactive one served first; a batch item is served every 4 interactive requests while
+both wait, and within a class the users waiting take turns. stats/queue reports the depth.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"maxBatchCount": {
+						Type:        framework.TypeInt,
+						Description: "Maximum count of address/batch and items of sign/batch (defaults to 1000)",
+					},
+					"maxConcurrentRequests": {
+						Type:        framework.TypeInt,
+						Description: "Maximum key operations served at once by the node, 0 for unlimited (defaults to 0)",
+					},
+					"queue": {
+						Type:        framework.TypeBool,
+						Description: "Queue the key operations beyond maxConcurrentRequests by priority (defaults to false)",
+					},
+					"maxQueueDepth": {
+						Type:        framework.TypeInt,
+						Description: "Maximum key operations waiting in the queue (defaults to 1000)",
+					},
+					"maxQueueWait": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Longest wait of a key operation in the queue (defaults to 30s)",
+					},
+					"maxFieldLengths": {
+						Type: framework.TypeKVPairs,
+						Description: "Maximum lengths in bytes by field, e.g. payload=2097152 (defaults to mnemonic=1024, " +
+							"passphrase=1024, username=256, payload=1048576, pathTemplate=256)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadQuotas,
+					logical.UpdateOperation: b.pathWriteQuotas,
+					logical.DeleteOperation: b.pathDeleteQuotas,
+				},
+			},
+
+			// api/config/cache
+			{
+				Pattern:      "config/cache",
+				HelpSynopsis: "Read or update the in-memory cache of the user records",
+				HelpDescription: `
+
+Caches the decrypted user records read by the requests in the memory of the node, so repeated
+requests for the same user skip the storage read. Entries expire after ttl, the least recently
+used are evicted beyond maxEntries, and records updated, disabled or deleted are dropped. The
+cache is disabled by default, emptied on every update and disabled again on delete. Reading
+reports the counters of the cache of the node serving the request.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"enabled": {
+						Type:        framework.TypeBool,
+						Description: "Cache the user records (defaults to false)",
+					},
+					"maxEntries": {
+						Type:        framework.TypeInt,
+						Description: "Maximum user records cached (defaults to 1024)",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Time after which a cached record is read again from the storage (defaults to 5m)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadCache,
+					logical.UpdateOperation: b.pathWriteCache,
+					logical.DeleteOperation: b.pathDeleteCache,
+				},
+			},
+
+			// api/config/entropy
+			{
+				Pattern:      "config/entropy",
+				HelpSynopsis: "Read or update the entropy source of the generated mnemonics",
+				HelpDescription: `
+
+The mnemonics generated on register are read from crypto/rand, or with source=augmented from
+crypto/rand XORed with the entropy of the seal, on the Vault Enterprise mounts with external
+entropy access. The source is checked on startup and when written: a source failing to read or
+repeating its output refuses to generate mnemonics until it is written again and passes. Reading
+returns the source, whether augmentation is available and the result of the last check.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"source": {
+						Type:        framework.TypeString,
+						Description: "Entropy source of the mnemonics, crypto/rand or augmented",
+						Default:     entropy.SourceCryptoRand,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadEntropy,
+					logical.UpdateOperation: b.pathWriteEntropy,
+					logical.DeleteOperation: b.pathDeleteEntropy,
+				},
+			},
+
+			// api/config/derivation
+			{
+				Pattern:      "config/derivation",
+				HelpSynopsis: "Read or update the policy bounding the derivation paths of the requests",
+				HelpDescription: `
+
+An xpub exported with xpub and the private key of any of its non-hardened children give the private
+key of the xpub, and of every key below it. With hardenedAccount the purpose, coin type and account
+levels must be hardened, e.g., m/44'/60'/0'/0/0, so that no key at or above the account level can be
+recovered that way; maxDepth bounds the number of components of the paths. The requests breaking
+the policy are rejected with a 422. Every path is allowed by default.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"hardenedAccount": {
+						Type:        framework.TypeBool,
+						Description: "Require the derivation paths to be hardened down to the account level",
+					},
+					"maxDepth": {
+						Type:        framework.TypeInt,
+						Description: "Max number of components of the derivation paths, 0 for unbounded",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadDerivation,
+					logical.UpdateOperation: b.pathWriteDerivation,
+					logical.DeleteOperation: b.pathDeleteDerivation,
+				},
+			},
+
+			// api/config/address-overrides
+			{
+				Pattern:      "config/address-overrides",
+				HelpSynopsis: "Read or update the address encoding overrides the address requests may use",
+				HelpDescription: `
+
+Chains forking or rebranding Bitcoin keep its keys and scripts but encode their addresses with
+their own bech32 human readable part and p2pkh and p2sh version bytes, e.g., hrp=ltc with the
+versions 48 and 50 for Litecoin. address takes them as hrp, pubKeyHashVersion and
+scriptHashVersion on the Bitcoin coin types, and returns the overrides used in addressOverride.
+The overrides of a request must be one of the allowed objects as a whole, e.g.,
+{"hrp":"ltc","pubKeyHashVersion":48,"scriptHashVersion":50}; others are rejected with a 403. None
+is allowed by default, and writing replaces the allowed objects.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"allowed": {
+						Type:        framework.TypeSlice,
+						Description: "Allowed overrides, each an object with the hrp, pubKeyHashVersion and scriptHashVersion used",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAddressOverrides,
+					logical.UpdateOperation: b.pathWriteAddressOverrides,
+					logical.DeleteOperation: b.pathDeleteAddressOverrides,
+				},
+			},
+
+			// api/config/deprecation
+			{
+				Pattern:      "config/deprecation",
+				HelpSynopsis: "Read or update the sunset of the legacy field names of the mount",
+				HelpDescription: `
+
+Renamed fields keep their legacy names for the clients not migrated yet: on every path taking a
+derivation path at the top level, path is the legacy name of derivationPath. The paths within a
+request, as those of the utxos of build/btc-tx, keep their names. A request using a legacy name
+is served with a deprecated-field warning announcing the sunset, and logged. With
+rejectLegacyFields the legacy names are rejected, so the mount can be flipped once its clients are
+migrated. Reading returns the legacy names of each path in legacyFields.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"sunset": {
+						Type:        framework.TypeString,
+						Description: "Date after which the legacy names are rejected, announced in the warnings, e.g., 2027-06-30",
+					},
+					"rejectLegacyFields": {
+						Type:        framework.TypeBool,
+						Description: "Reject the requests using legacy field names",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadDeprecation,
+					logical.UpdateOperation: b.pathWriteDeprecation,
+					logical.DeleteOperation: b.pathDeleteDeprecation,
+				},
+			},
+
+			// api/config/canary
+			{
+				Pattern:      "config/canary",
+				HelpSynopsis: "Read or update the canary users of the mount",
+				HelpDescription: `
+
+The periodic function signs a benign canary message with each of the uuids every interval,
+with the secp256k1 key of path: the EIP-191 personal message "dq-vault canary <uuid> <time>".
+The users must exist and hold a mnemonic; their addresses are expected to be monitored
+externally and to never hold funds. The last signatures are read from canary/sign.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuids": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "UUIDs of the canary users",
+					},
+					"interval": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How often the canary users sign, at least a minute (defaults to 5m)",
+					},
+					"derivationPath": {
+						Type:        framework.TypeString,
+						Description: "Derivation path of the canary key (defaults to m/44'/60'/0'/0/0)",
+					},
+					"path": {
+						Type:        framework.TypeString,
+						Description: "Legacy name of derivationPath, see config/deprecation",
+						Deprecated:  true,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadCanaryConfig,
+					logical.UpdateOperation: b.pathWriteCanaryConfig,
+					logical.DeleteOperation: b.pathDeleteCanaryConfig,
+				},
+			},
+
+			// api/canary/sign
+			{
+				Pattern:      "canary/sign",
+				HelpSynopsis: "Read or produce the canary signatures of the canary users",
+				HelpDescription: `
+
+Reading returns the last canary signature of each user of config/canary: the message, its
+signature (r, s, v with v 27 or 28), the address it recovers to and when it was signed. A
+failed attempt keeps the last signature and reports the error and the number of failures
+since. Monitoring verifies the signature and alerts when signedAt falls behind the interval.
+Updating signs at once, with every canary user or the given uuid.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "Only sign with this canary user (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadCanary,
+					logical.UpdateOperation: b.pathSignCanary,
+				},
+			},
+
+			// api/config/retention
+			{
+				Pattern:      "config/retention",
+				HelpSynopsis: "Read or update how long the debug captures, receipts, jobs and journal deletions are kept",
+				HelpDescription: `
+
+The periodic function removes the records of each class older than its maximum age, and the
+oldest beyond its maximum count across the mount: the debug captures, on top of the removal of
+the debug sessions a day after they expire, the signing receipts, the finished async jobs and
+the deletions journaled for the replication. A zero age or count leaves the receipts, jobs and
+journal unbounded by it. Settings omitted from an update keep their current value and deleting
+restores the defaults. maintenance/prune applies them on demand.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"debugCaptureMaxAge": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Age after which a debug capture is removed (defaults to 48h)",
+					},
+					"debugCaptureMaxCount": {
+						Type:        framework.TypeInt,
+						Description: "Debug captures kept across the mount, the oldest are removed first (defaults to 10000)",
+					},
+					"receiptMaxAge": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Age after which a signing receipt is removed, 0 to keep them (defaults to 0)",
+					},
+					"receiptMaxCount": {
+						Type:        framework.TypeInt,
+						Description: "Signing receipts kept, the oldest are removed first, 0 for no bound (defaults to 0)",
+					},
+					"jobMaxAge": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Time after it finished a job is removed, 0 to keep them (defaults to 24h)",
+					},
+					"jobMaxCount": {
+						Type:        framework.TypeInt,
+						Description: "Finished jobs kept, the oldest are removed first, 0 for no bound (defaults to 0)",
+					},
+					"journalMaxAge": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Age after which a journaled deletion is removed, 0 to keep them (defaults to 0)",
+					},
+					"journalMaxCount": {
+						Type:        framework.TypeInt,
+						Description: "Journaled deletions kept, the oldest are removed first, 0 for no bound (defaults to 0)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadRetention,
+					logical.UpdateOperation: b.pathWriteRetention,
+					logical.DeleteOperation: b.pathDeleteRetention,
+				},
+			},
+
+			// api/config/lockout
+			{
+				Pattern:      "config/lockout",
+				HelpSynopsis: "Read or update the policy violations locking a user",
+				HelpDescription: `
+
+The requests of a user rejected by a policy of the mount are noted as its violations, see
+user/<uuid>/violations. A user reaching maxViolations within window is locked: its key
+operations are rejected until user/<uuid>/unlock, and a lockout event is published to
+config/kafka. maxViolations 0, the default, only notes them. Settings omitted from an update
+keep their current value and deleting restores the defaults; locked users stay locked.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"maxViolations": {
+						Type:        framework.TypeInt,
+						Description: "Policy violations within window locking a user, 0 to only note them (defaults to 0)",
+					},
+					"window": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How far back the policy violations are counted (defaults to 1h)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadLockout,
+					logical.UpdateOperation: b.pathWriteLockout,
+					logical.DeleteOperation: b.pathDeleteLockout,
+				},
+			},
+
+			// api/maintenance/prune
+			{
+				Pattern:      "maintenance/prune",
+				HelpSynopsis: "Run the pruning of the periodic function now",
+				HelpDescription: `
+
+Removes the expired debug and signing sessions and applies config/retention to the debug
+captures, receipts, finished jobs and journaled deletions, as the periodic function does, and
+returns the number of records of each class removed. With dryRun the records that would be
+removed are only counted.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"dryRun": {
+						Type:        framework.TypeBool,
+						Description: "Count the records that would be removed without removing them",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathMaintenancePrune,
+				},
+			},
+
+			// api/config/logging
+			{
+				Pattern:      "config/logging",
+				HelpSynopsis: "Read or update the logging level of the mount",
+				HelpDescription: `
+
+Sets the minimum level (DEBUG, INFO, WARN or ERROR) of the plugin logs, INFO when deleted.
+Mnemonics, passphrases, seeds and private keys are always redacted from the logs.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"level": {
+						Type:        framework.TypeString,
+						Description: "Minimum log level: DEBUG, INFO, WARN or ERROR",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadLogging,
+					logical.UpdateOperation: b.pathWriteLogging,
+					logical.DeleteOperation: b.pathDeleteLogging,
+				},
+			},
+
+			// api/config/storage
+			{
+				Pattern:      "config/storage",
+				HelpSynopsis: "Read or update where the user records of the mount are persisted",
+				HelpDescription: `
+
+User records are kept in the Vault storage by default. Set type=postgres with a
+connectionURL to persist them in a Postgres table instead, encrypted with AES-256-GCM
+under a key generated and kept by the plugin. Existing records are not copied when
+the store changes. Deleting switches back to the Vault storage; the key is never returned
+and kept, so the Postgres records stay readable if the store is configured again.
+
+With splitPassphrase the passphrase of each user record written is stored apart from
+it, under passphrases/ of the Vault storage with seal wrapping, whichever the store of
+the records: neither path alone is enough to derive the keys of a user. The records are
+reassembled in memory when read; existing ones are split when next written.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"type": {
+						Type:        framework.TypeString,
+						Description: "Store of the user records: vault or postgres",
+						Default:     "vault",
+					},
+					"connectionURL": {
+						Type:        framework.TypeString,
+						Description: "Postgres connection URL (required for postgres)",
+					},
+					"table": {
+						Type:        framework.TypeString,
+						Description: "Postgres table of the user records (optional, defaults to dq_vault_users)",
+					},
+					"splitPassphrase": {
+						Type:        framework.TypeBool,
+						Description: "Store the passphrases of the user records apart from them (optional, kept when omitted)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadStorage,
+					logical.UpdateOperation: b.pathWriteStorage,
+					logical.DeleteOperation: b.pathDeleteStorage,
+				},
+			},
+
+			// api/config/rotate-dek
+			{
+				Pattern:      "config/rotate-dek",
+				HelpSynopsis: "Rotate the data encryption key of the user records",
+				HelpDescription: `
+
+Generates a new key for the user records of the external store configured through
+config/storage. Records are written under the new key at once, and the existing ones
+are re-encrypted in batches by the periodic function; the previous key stays readable
+until all of them are. Progress is reported by config/rotate-dek/status. Updating again
+after a failed rotation retries it with the same keys.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathRotateDEK,
+				},
+			},
+
+			// api/config/rotate-dek/status
+			{
+				Pattern:      "config/rotate-dek/status",
+				HelpSynopsis: "Read the progress of the last data encryption key rotation",
+				HelpDescription: `
+
+Returns the state (running, completed or failed) of the last rotation started through
+config/rotate-dek, the id of the new key, how many records were processed out of the
+total and re-encrypted, and the uuids of the records that could not be re-encrypted.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadDEKRotation,
+				},
+			},
+
+			// api/stats/storage
+			{
+				Pattern:      "stats/storage",
+				HelpSynopsis: "Report the entries and bytes stored under each prefix",
+				HelpDescription: `
+
+Walks the storage prefixes of the mount (users, escrow, apikeys, sessions, debug, addresses,
+index/addresses, backup-verification, violations, multisig and config) and returns the number
+of entries under each one and the bytes of their values, as kept in the Vault storage, with the
+totals.
+When the user records are kept in an external store, its records are reported as userStore,
+sized once decrypted. Every entry is read, avoid polling it on large mounts.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathStatsStorage,
+				},
+			},
+
+			// api/stats/queue
+			{
+				Pattern:      "stats/queue",
+				HelpSynopsis: "Report the depth of the request queue of this node",
+				HelpDescription: `
+
+Reports the request queue of config/quotas on the node serving the request: whether it is
+enabled, the key operations in flight out of the capacity, and for the interactive and batch
+classes the requests and users waiting, with the requests served, rejected because the queue
+was full and abandoned while waiting, and their average and longest wait since the plugin
+started.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathStatsQueue,
+				},
+			},
+
+			// api/replicate/export-changes
+			{
+				Pattern:      "replicate/export-changes",
+				HelpSynopsis: "Export the changes of the user records for another cluster",
+				HelpDescription: `
+
+While config/replication has a key, every write of a user record bumps its version and is
+journaled at the next sequence number; only the last change of a record is kept. Returns the
+changes journaled after since, at most limit of them by sequence number, each with the current
+record encrypted under the replication key, or deleted. Export again from lastSeq while more
+is true; a disaster recovery cluster polls it from the last sequence number it applied.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"since": {
+						Type:        framework.TypeInt,
+						Description: "Sequence number the changes are exported after (defaults to 0, every record)",
+						Default:     0,
+					},
+					"limit": {
+						Type:        framework.TypeInt,
+						Description: "Maximum number of changes returned, up to 1000 (defaults to 100)",
+						Default:     100,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReplicateExportChanges,
+				},
+			},
+
+			// api/replicate/apply
+			{
+				Pattern:      "replicate/apply",
+				HelpSynopsis: "Apply the changes of the user records exported by another cluster",
+				HelpDescription: `
+
+Writes the user records of the changes of replicate/export-changes, decrypted with the key of
+config/replication, which must be the one of the exporting cluster. Each change is checked
+against the version of the local record: changes at or below the version already applied are
+stale and skipped, and records written on this cluster (not by a replication) are conflicts,
+left as they are unless force is set. The status of every change is returned.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"changes": {
+						Type:        framework.TypeSlice,
+						Description: "Changes returned by replicate/export-changes on the other cluster",
+						Required:    true,
+					},
+					"force": {
+						Type:        framework.TypeBool,
+						Description: "Overwrite the records written on this cluster (defaults to false)",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathReplicateApply,
+				},
+			},
+
+			// api/config/replication
+			{
+				Pattern:      "config/replication",
+				HelpSynopsis: "Read or update the key the replicated user records are encrypted with",
+				HelpDescription: `
+
+Sets the AES-256 key shared by the clusters replicating the user records, hex encoded; it is
+seal wrapped and never returned, reads report its keyId and the last sequence number of the
+journal. Setting it starts journaling the writes of the user records, the records already
+stored included. Deleting it stops the journal, which is kept.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"key": {
+						Type:        framework.TypeString,
+						Description: "AES-256 key shared with the other cluster, hex encoded (required)",
+						Required:    true,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadReplicationConfig,
+					logical.UpdateOperation: b.pathWriteReplicationConfig,
+					logical.DeleteOperation: b.pathDeleteReplicationConfig,
+				},
+			},
+
+			// api/migrate/encrypt
+			{
+				Pattern:      "migrate/encrypt",
+				HelpSynopsis: "Encrypt the user records kept in clear in the Vault storage",
+				HelpDescription: `
+
+Encrypts every user record of the Vault storage with AES-256-GCM under the data encryption
+key of config/storage, generated if needed, and checks that it decrypts back to the original.
+The status of each record (encrypted, already-encrypted or failed) is returned, with the
+error of the failed ones; they are left in clear and stay readable, and updating again
+retries them. Once every record is encrypted, records in clear are rejected. Records
+updated while the migration runs may lose the update, run it when the mount is quiet.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.pathMigrateEncrypt,
+				},
+			},
+
+			// api/config/attestation
+			{
+				Pattern:      "config/attestation",
+				HelpSynopsis: "Read or update the attestation of the responses of this mount",
+				HelpDescription: `
+
+When enabled, every response with data holds a responseAttestation field: the keyId, a
+timestamp and the base64 Ed25519 signature of the timestamp, the request path and the
+canonical JSON of the other response fields, each separated by a newline. Services consuming the responses verify it with
+the publicKey returned here, to detect responses forged or altered on the way. The key is
+generated on first enable and kept across updates; deleting the configuration discards it.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"enabled": {
+						Type:        framework.TypeBool,
+						Description: "Attest every response (defaults to false)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAttestation,
+					logical.UpdateOperation: b.pathWriteAttestation,
+					logical.DeleteOperation: b.pathDeleteAttestation,
+				},
+			},
+
+			// api/config/escrow
+			{
+				Pattern:      "config/escrow",
+				HelpSynopsis: "Read or update the custodian key the mnemonics are escrowed to",
+				HelpDescription: `
+
+When enabled, the mnemonic and passphrase of every user registered on the mount are also
+encrypted to the X25519 publicKey of a third-party custodian, as a libsodium sealed box, and
+the record is stored under escrow/<uuid>. The custodian can recover a mnemonic from its record
+with its private key, without any access to Vault. Registration fails when the record cannot
+be written. Changing the key does not escrow the existing users again, see escrow/<uuid>.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"enabled": {
+						Type:        framework.TypeBool,
+						Description: "Escrow the mnemonics of the users registered from now on (defaults to false)",
+					},
+					"publicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key of the custodian, hex encoded",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadEscrowConfig,
+					logical.UpdateOperation: b.pathWriteEscrowConfig,
+					logical.DeleteOperation: b.pathDeleteEscrowConfig,
+				},
+			},
+
+			// api/config/import
+			{
+				Pattern:      "config/import",
+				HelpSynopsis: "Read the whole configuration of the mount as one document",
+				HelpDescription: `
+
+Returns the configuration of every config path (features, quotas, cache, logging, storage,
+attestation, escrow, retention, and the rpc endpoints and hook chains by coin type) in the shape accepted by
+its update, defaults included. Declarative tools can import an existing mount from it and
+detect drift; every config path also supports delete, which restores its defaults.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadImport,
+				},
+			},
+
+			// api/config/validate
+			{
+				Pattern:      "config/validate",
+				HelpSynopsis: "Validate the stored configuration and the adapters of the mount",
+				HelpDescription: `
+
+Runs the validation done when the mount starts: the self-check of the coin adapters, and every
+stored config path (rpc endpoints, hook chains, fee bounds, webhooks, sinks and policies) checked
+as its update would. Each problem names the config path to fix or delete. The mount fails to
+initialize while any is found.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadValidate,
+				},
+			},
+
+			// api/config/rpc
+			{
+				Pattern:      "config/rpc/?$",
+				HelpSynopsis: "List the coin types with a configured node",
+				HelpDescription: `
+
+Lists the coin types whose node endpoint is configured.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListRPC,
+				},
+			},
+
+			// api/config/rpc/<coinType>
+			{
+				Pattern:      "config/rpc/(?P<coinType>\\d+)",
+				HelpSynopsis: "Configure the node endpoint of a coin type",
+				HelpDescription: `
+
+Sets the JSON-RPC endpoint the broadcast endpoint relays the transactions of the coin type
+to. The URL may hold an API key: only its host is logged.
+
+On Solana, maxBlockhashAge has sign check the recent blockhash of the payloads against the
+RecentBlockhashes sysvar of the node: a blockhash more than maxBlockhashAge blocks old, or no
+longer known to the node, is returned with a stale-blockhash warning, or rejected with
+rejectStaleBlockhash. Payloads of a durable nonce and payloads to complete are not checked.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeString,
+						Description: "Cointype relayed to the endpoint",
+					},
+					"url": {
+						Type:        framework.TypeString,
+						Description: "http or https URL of the JSON-RPC endpoint",
+					},
+					"maxRetries": {
+						Type:        framework.TypeInt,
+						Description: "Retries of calls failing with a transient error (optional, defaults to 3)",
+						Default:     rpc.DefaultMaxRetries,
+					},
+					"timeout": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Timeout of each attempt (optional, defaults to 10s)",
+						Default:     int(rpc.DefaultTimeout.Seconds()),
+					},
+					"maxBlockhashAge": {
+						Type: framework.TypeInt,
+						Description: "Age in blocks, at most 150, above which the recent blockhash of Solana payloads " +
+							"is stale (optional, 0 leaves it unchecked)",
+					},
+					"rejectStaleBlockhash": {
+						Type:        framework.TypeBool,
+						Description: "Reject the sign requests of a stale blockhash instead of warning (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadRPC,
+					logical.UpdateOperation: b.pathWriteRPC,
+					logical.DeleteOperation: b.pathDeleteRPC,
+				},
+			},
+
+			// api/config/travelrule
+			{
+				Pattern:      "config/travelrule",
+				HelpSynopsis: "Configure the sink the travel rule data of the signed transfers is forwarded to",
+				HelpDescription: `
+
+Sign requests may carry the travel rule data of their transfer in travelRule, a subset of
+IVMS-101: the originator and the beneficiary, each a naturalPerson or a legalPerson with an
+accountNumber, and the originatingVASP and beneficiaryVASP. Once signed, the data is returned
+with its travelRuleHash, so the audit log records it, and posted to the sink with the
+signature, the body signed with the hex HMAC-SHA256 of the signing secret in the
+X-Dq-Vault-Signature header. The sink URL may hold credentials: only its host is returned.
+With format cloudevents, the records are posted in CloudEvents 1.0 envelopes of type
+com.dq-vault.travelrule.signed; with format template, as the output of template.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"sinkUrl": {
+						Type:        framework.TypeString,
+						Description: "http or https URL the records are posted to",
+					},
+					"signingSecret": {
+						Type:        framework.TypeString,
+						Description: "Secret keying the HMAC of the posted bodies (optional)",
+					},
+					"format": {
+						Type:        framework.TypeString,
+						Description: "Body of the posted records: native, cloudevents or template (optional, defaults to native)",
+						Default:     webhook.FormatNative,
+					},
+					"template": {
+						Type:        framework.TypeString,
+						Description: "text/template of the bodies of the template format, executed with the event",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadTravelRule,
+					logical.UpdateOperation: b.pathWriteTravelRule,
+					logical.DeleteOperation: b.pathDeleteTravelRule,
+				},
+			},
+
+			// api/config/kafka
+			{
+				Pattern:      "config/kafka",
+				HelpSynopsis: "Configure the Kafka topic the events of the mount are published to",
+				HelpDescription: `
+
+Publishes the register, sign and policy events of the mount to topic: the users registered,
+the signatures with their receipts, and the changes of the configuration, API keys and
+address book, as JSON messages keyed by the user UUID. The events are queued in the Vault
+storage first and removed once all the in-sync replicas acknowledged them, so none is lost
+while the brokers are unreachable: the periodic function publishes what the requests could
+not. The password is never returned; reads report the events still queued.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"brokers": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "host:port addresses of the bootstrap brokers",
+					},
+					"topic": {
+						Type:        framework.TypeString,
+						Description: "Topic the events are published to",
+					},
+					"saslMechanism": {
+						Type:        framework.TypeString,
+						Description: "SASL mechanism of the brokers: plain, scram-sha-256 or scram-sha-512 (optional)",
+					},
+					"username": {
+						Type:        framework.TypeString,
+						Description: "SASL username (required with saslMechanism)",
+					},
+					"password": {
+						Type:        framework.TypeString,
+						Description: "SASL password (required with saslMechanism)",
+					},
+					"tls": {
+						Type:        framework.TypeBool,
+						Description: "Connect to the brokers over TLS",
+						Default:     false,
+					},
+					"caCert": {
+						Type:        framework.TypeString,
+						Description: "PEM certificates the brokers are verified with (optional, defaults to the system roots)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadKafka,
+					logical.UpdateOperation: b.pathWriteKafka,
+					logical.DeleteOperation: b.pathDeleteKafka,
+				},
+			},
+
+			// api/config/archive
+			{
+				Pattern:      "config/archive",
+				HelpSynopsis: "Configure the S3-compatible bucket the audit data of the mount is archived to",
+				HelpDescription: `
+
+Every interval, the periodic function uploads the receipts, debug captures and replication
+journal changes recorded since its last run to bucket, one gzip-compressed JSON Lines segment
+per class under <prefix><class>/<yyyy>/<mm>/<dd>/<first>-<last>.jsonl.gz. The uploads are
+signed with the access key, which is kept in the Vault storage and never returned. Once
+archived, the receipts and debug captures older than localRetention are removed from the Vault
+storage; the journal changes are kept for the replication. Reads report the segments uploaded
+and the last run with its error. Deleting the config stops archiving and keeps the progress.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"endpoint": {
+						Type:        framework.TypeString,
+						Description: "URL of the S3-compatible store, e.g. https://s3.eu-west-1.amazonaws.com",
+					},
+					"bucket": {
+						Type:        framework.TypeString,
+						Description: "Bucket the segments are uploaded to",
+					},
+					"region": {
+						Type:        framework.TypeString,
+						Description: "Region the uploads are signed for (defaults to us-east-1)",
+					},
+					"prefix": {
+						Type:        framework.TypeString,
+						Description: "Prefix of the object keys of the segments, e.g. prod/ (optional)",
+					},
+					"accessKeyId": {
+						Type:        framework.TypeString,
+						Description: "Access key ID signing the uploads",
+					},
+					"secretAccessKey": {
+						Type:        framework.TypeString,
+						Description: "Secret access key signing the uploads",
+					},
+					"interval": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How often the audit data is archived (defaults to 1h)",
+						Default:     int(helpers.DefaultArchiveInterval.Seconds()),
+					},
+					"localRetention": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How long archived receipts and debug captures stay in the Vault storage (defaults to 720h)",
+						Default:     int(helpers.DefaultArchiveLocalRetention.Seconds()),
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadArchive,
+					logical.UpdateOperation: b.pathWriteArchive,
+					logical.DeleteOperation: b.pathDeleteArchive,
+				},
+			},
+
+			// api/config/tracing
+			{
+				Pattern:      "config/tracing",
+				HelpSynopsis: "Configure the collector the OpenTelemetry spans of the requests are exported to",
+				HelpDescription: `
+
+Exports a span for every request, named by its operation and path pattern, with the spans of
+its storage operations and of the stages of the signatures (validation, user load, seed and
+key derivation, policy checks, signing) to the OTLP/HTTP collector of endpoint. Requests with
+a W3C traceparent header passed through by Vault continue the trace of the caller; sampleRatio
+is the share of the other traces recorded. Without config/tracing the OTEL_EXPORTER_OTLP_*
+environment variables of the plugin configure the collector. Headers are never returned.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"endpoint": {
+						Type:        framework.TypeString,
+						Description: "URL of the OTLP/HTTP traces endpoint, e.g. https://collector:4318/v1/traces",
+					},
+					"headers": {
+						Type:        framework.TypeKVPairs,
+						Description: "Headers sent to the collector, e.g. its API key (optional)",
+					},
+					"sampleRatio": {
+						Type:        framework.TypeFloat,
+						Description: "Share of the traces started by the plugin that are recorded, 0 to 1 (defaults to 1)",
+						Default:     1.0,
+					},
+					"serviceName": {
+						Type:        framework.TypeString,
+						Description: "service.name of the spans (optional, defaults to dq-vault)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadTracing,
+					logical.UpdateOperation: b.pathWriteTracing,
+					logical.DeleteOperation: b.pathDeleteTracing,
+				},
+			},
+
+			// api/config/passphrase
+			{
+				Pattern:      "config/passphrase",
+				HelpSynopsis: "Read or update the strength policy of the passphrases of new users",
+				HelpDescription: `
+
+Registration rejects passphrases shorter than minLength characters or with an entropy estimate
+below minEntropyBits, with 422. The estimate counts the distinct characters and the character
+classes of the passphrase, and rates common passwords 0. With verifier set to argon2id or
+scrypt, the passphrase of each new user is also stored stretched, and user/<uuid>/health checks
+the stored passphrase against it. Fields omitted from an update keep their current value and
+deleting accepts any passphrase again.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"minLength": {
+						Type:        framework.TypeInt,
+						Description: "Minimum passphrase length in characters, 0 for none (defaults to 0)",
+					},
+					"minEntropyBits": {
+						Type:        framework.TypeFloat,
+						Description: "Minimum entropy estimate of the passphrase in bits, 0 for none (defaults to 0)",
+					},
+					"verifier": {
+						Type:        framework.TypeString,
+						Description: "Algorithm of the verifier stored with new users: argon2id, scrypt, or empty for none",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadPassphrasePolicy,
+					logical.UpdateOperation: b.pathWritePassphrasePolicy,
+					logical.DeleteOperation: b.pathDeletePassphrasePolicy,
+				},
+			},
+
+			// api/config/fees
+			{
+				Pattern:      "config/fees/?$",
+				HelpSynopsis: "List the coin types with fee bounds",
+				HelpDescription: `
+
+Lists the coin types whose sign payloads are checked against fee rate bounds.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListFees,
+				},
+			},
+
+			// api/config/fees/<coinType>
+			{
+				Pattern:      "config/fees/(?P<coinType>\\d+)",
+				HelpSynopsis: "Configure the fee rate bounds of the sign payloads of a coin type",
+				HelpDescription: `
+
+The fee rate of the payloads of sign is decoded and checked against the bounds before
+signing: the gas price in gwei for EVM coin types, the fee of the PSBT over its estimated
+signed virtual size in sat/vB for Bitcoin. Payloads out of bounds are refused unless sign
+sets overrideFee, which is logged with the entity.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeString,
+						Description: "Cointype of the payloads",
+					},
+					"min": {
+						Type:        framework.TypeFloat,
+						Description: "Lowest fee rate signed, in gwei or sat/vB",
+					},
+					"max": {
+						Type:        framework.TypeFloat,
+						Description: "Highest fee rate signed, in gwei or sat/vB",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadFees,
+					logical.UpdateOperation: b.pathWriteFees,
+					logical.DeleteOperation: b.pathDeleteFees,
+				},
+			},
+
+			// api/config/hooks
+			{
+				Pattern:      "config/hooks/?$",
+				HelpSynopsis: "List the coin types with a payload hook chain",
+				HelpDescription: `
+
+Lists the coin types whose sign payloads go through a hook chain.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListHooks,
+				},
+			},
+
+			// api/config/hooks/<coinType>
+			{
+				Pattern:      "config/hooks/(?P<coinType>\\d+)",
+				HelpSynopsis: "Configure the payload hooks run before signing the payloads of a coin type",
+				HelpDescription: `
+
+The hooks run in order on the payloads of sign before they are validated and signed, and
+each reports its action under hooks in the response, so the audit log records it. Hooks are
+"name" or "name=argument": evm-chain-id=<id> sets the EIP-155 chainId of payloads without
+one and rejects those for another chain, evm-normalize-gas turns hex and decimal string
+quantities into numbers, and evm-strip-tx-type turns EIP-2930 and EIP-1559 payloads into
+legacy ones.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeString,
+						Description: "Cointype of the payloads",
+					},
+					"hooks": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Ordered hooks, e.g. evm-normalize-gas,evm-strip-tx-type,evm-chain-id=1",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadHooks,
+					logical.UpdateOperation: b.pathWriteHooks,
+					logical.DeleteOperation: b.pathDeleteHooks,
+				},
+			},
+
+			// api/apikeys
+			{
+				Pattern:      "apikeys/?$",
+				HelpSynopsis: "List the scoped API keys",
+				HelpDescription: `
+
+Lists the names of the API keys minted for integrations.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListAPIKeys,
+				},
+			},
+
+			// api/apikeys/<name>
+			{
+				Pattern:      "apikeys/" + framework.GenericNameRegex("name"),
+				HelpSynopsis: "Mint, read or revoke a scoped API key",
+				HelpDescription: `
+
+API keys let integrations sharing one Vault role have different blast radii. A key is
+scoped to UUID glob patterns, coin types and operations (address, address/batch, address/next,
+sign, sign/spl-transfer, sign/psbt, build/btc-tx, sign/safe-tx, sign/userop, sign/permit, sign/digest,
+multisig, broadcast), all unrestricted when empty, and optionally expires.
+The key value is returned once when minted; minting an existing name rotates the key.
+Callers send it in the apiKey field of address and sign requests.
+With a responsePublicKey, the key value is returned encrypted to it.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"name": {
+						Type:        framework.TypeString,
+						Description: "Name of the key, usually the integration",
+					},
+					"uuidPatterns": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Glob patterns of the user UUIDs the key may use (optional, all when empty)",
+					},
+					"coinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "Coin types the key may use (optional, all when empty)",
+					},
+					"operations": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Operations the key may call (optional, all when empty)",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "Lifetime of the key (optional, never expires when empty)",
+					},
+					"responsePublicKey": {
+						Type:        framework.TypeString,
+						Description: "X25519 public key, hex encoded, the minted apiKey is encrypted to (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAPIKey,
+					logical.UpdateOperation: b.pathWriteAPIKey,
+					logical.DeleteOperation: b.pathDeleteAPIKey,
+				},
+			},
+
+			// api/addressbook
+			{
+				Pattern:      "addressbook/?$",
+				HelpSynopsis: "List the address book entries",
+				HelpDescription: `
+
+Lists the names of the counterparties of the address book.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListAddressBook,
+				},
+			},
+
+			// api/addressbook/<name>
+			{
+				Pattern:      "addressbook/" + framework.GenericNameRegex("name"),
+				HelpSynopsis: "Create, read or delete a named counterparty",
+				HelpDescription: `
+
+An address book entry names an address of a coin type, e.g. binance-hot-wallet. The
+approvers of held signing requests see the names of the recipients in the entries, and the
+policy of config/addressbook restricts the recipients of the signing paths to the entries
+with a tag.
+EVM addresses are stored checksummed; writing an existing name replaces the entry.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"name": {
+						Type:        framework.TypeString,
+						Description: "Name of the counterparty",
+					},
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the address",
+					},
+					"address": {
+						Type:        framework.TypeString,
+						Description: "Address of the counterparty",
+					},
+					"tags": {
+						Type:        framework.TypeCommaStringSlice,
+						Description: "Tags selecting the entry in the address book policy, e.g. exchange (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAddressBook,
+					logical.UpdateOperation: b.pathWriteAddressBook,
+					logical.DeleteOperation: b.pathDeleteAddressBook,
+				},
+			},
+
+			// api/config/addressbook
+			{
+				Pattern:      "config/addressbook",
+				HelpSynopsis: "Restrict the recipients of the signing paths to address book entries",
+				HelpDescription: `
+
+For every coin type of requiredTags, the signing paths only sign requests whose recipients
+are all address book entries of the coin type with the tag, or any entry for '*'. Requests
+whose recipients cannot be decoded, contract calls other than token transfers, user
+operations, and digests once any coin type has requiredTags, are refused. The outputs of
+Bitcoin transactions paying the signing wallet back, by a descriptor of the request or a
+BIP-32 derivation of the PSBT, are change, not recipients.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"requiredTags": {
+						Type:        framework.TypeKVPairs,
+						Description: "Tag the recipients must carry by coin type, e.g. 60=exchange",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAddressBookPolicy,
+					logical.UpdateOperation: b.pathWriteAddressBookPolicy,
+					logical.DeleteOperation: b.pathDeleteAddressBookPolicy,
+				},
+			},
+
+			// api/config/assets
+			{
+				Pattern:      "config/assets/?$",
+				HelpSynopsis: "List the tokens of the asset registry",
+				HelpDescription: `
+
+Lists the registered assets as <coinType>/<symbol>.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListAssets,
+				},
+			},
+
+			// api/config/assets/<coinType>/<symbol>
+			{
+				Pattern:      "config/assets/(?P<coinType>\\d+)/" + framework.GenericNameRegex("symbol"),
+				HelpSynopsis: "Register, read or delete a token of a coin type",
+				HelpDescription: `
+
+An asset names the token contract, or the Solana mint, of a coin type by its symbol, e.g.,
+60/USDC, with its decimals. transfer takes the symbol or the address of a registered asset
+without its decimals, and the policy of config/contracts restricts the contracts EVM sign
+payloads call to the registered assets. Symbols compare case insensitively and an address is
+registered under a single symbol; writing an existing symbol replaces the asset.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeString,
+						Description: "Cointype of the chain of the token",
+					},
+					"symbol": {
+						Type:        framework.TypeString,
+						Description: "Symbol of the token, e.g., USDC",
+					},
+					"address": {
+						Type:        framework.TypeString,
+						Description: "Contract address, or mint address on Solana",
+					},
+					"decimals": {
+						Type:        framework.TypeInt,
+						Description: "Decimals of the token, between 0 and 255",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadAsset,
+					logical.UpdateOperation: b.pathWriteAsset,
+					logical.DeleteOperation: b.pathDeleteAsset,
+				},
+			},
+
+			// api/config/contracts
+			{
+				Pattern:      "config/contracts",
+				HelpSynopsis: "Restrict the contracts called by sign to the asset registry",
+				HelpDescription: `
+
+For every EVM coin type of restrictedCoinTypes, sign only signs payloads calling the contract
+of an asset of config/assets of the coin type. Payments without call data are not restricted,
+contract creations are refused.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"restrictedCoinTypes": {
+						Type:        framework.TypeCommaIntSlice,
+						Description: "EVM coin types whose payloads may only call registered assets, e.g., 60,966",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadContractPolicy,
+					logical.UpdateOperation: b.pathWriteContractPolicy,
+					logical.DeleteOperation: b.pathDeleteContractPolicy,
+				},
+			},
+
+			// api/config/debug/<uuid>
+			{
+				Pattern:      "config/debug/" + framework.GenericNameRegex("uuid"),
+				HelpSynopsis: "Enable, read or disable the debug capture session of a user",
+				HelpDescription: `
+
+While a debug session is enabled, the sign and address requests of the user and their
+responses are recorded under debug/<uuid> to troubleshoot integrations. Mnemonics,
+passphrases, seeds and private keys are never recorded. A session lasts ttl seconds,
+at most 24h, and its captures are removed 24h after it expired.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"ttl": {
+						Type:        framework.TypeDurationSecond,
+						Description: "How long requests are captured, at most 24h (optional, defaults to 1h)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadDebugSession,
+					logical.UpdateOperation: b.pathWriteDebugSession,
+					logical.DeleteOperation: b.pathDeleteDebugSession,
+				},
+			},
+
+			// api/debug/<uuid>
+			{
+				Pattern:      "debug/" + framework.GenericNameRegex("uuid"),
+				HelpSynopsis: "List the sanitized requests captured during the debug session of a user",
+				HelpDescription: `
+
+Lists the captured request/response pairs of the user, oldest first, with secrets redacted.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathReadDebugCaptures,
+				},
+			},
+
+			// api/export/watch-only/<uuid>
+			{
+				Pattern:      "export/watch-only/" + framework.GenericNameRegex("uuid"),
+				HelpSynopsis: "Export the watch-only descriptors and xpubs of a user",
+				HelpDescription: `
+
+Exports the public half of the user's accounts for watch-only wallets and block explorers:
+the Bitcoin p2pkh, p2sh-p2wpkh, p2wpkh and p2tr accounts (BIP-44/49/84/86) with their
+xpub, SLIP-132 ypub/zpub for Electrum and BIP-380 output descriptors with key origin,
+and the BIP-44 account xpub of every secp256k1 coin. No private material is returned.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account index (optional, defaults to 0)",
+						Default:     0,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Export Bitcoin testnet accounts",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.withDebugCapture(b.pathExportWatchOnly),
+				},
+			},
+
+			// api/multisig/<uuid>
+			{
+				Pattern:      "multisig/" + framework.GenericNameRegex("uuid") + "/?$",
+				HelpSynopsis: "List the Bitcoin multisig wallets of a user",
+				HelpDescription: `
+
+Lists the names of the multisig wallets registered for the user.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ListOperation: b.pathListMultisig,
+				},
+			},
+
+			// api/multisig/<uuid>/<name>
+			{
+				Pattern:      "multisig/" + framework.GenericNameRegex("uuid") + "/" + framework.GenericNameRegex("name"),
+				HelpSynopsis: "Register, read or delete a Bitcoin multisig wallet of a user",
+				HelpDescription: `
+
+A multisig wallet combines the BIP-48 account key of the user, m/48'/0'/<account>'/2' for
+p2wsh or m/48'/0'/<account>'/1' for p2sh-p2wsh (coin type 1' on testnet), with the account
+keys of external cosigners, given as [<fingerprint>/<origin>]<xpub> key expressions. Its
+addresses are sortedmulti (BIP-67) scripts of threshold of the keys derived at
+<key>/<change>/<index>. The receive and change descriptors of the wallet are returned for
+the coordinator. A registered wallet cannot be changed; delete it to register new cosigners.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"name": {
+						Type:        framework.TypeString,
+						Description: "Name of the wallet",
+					},
+					"threshold": {
+						Type:        framework.TypeInt,
+						Description: "Number of signatures spending an output",
+					},
+					"cosigners": {
+						Type:        framework.TypeStringSlice,
+						Description: "Account keys of the other cosigners, [<fingerprint>/<origin>]<xpub>",
+					},
+					"scriptType": {
+						Type:        framework.TypeString,
+						Description: "p2wsh or p2sh-p2wsh (optional, defaults to p2wsh)",
+						Default:     bitcoin.MultisigP2WSH,
+					},
+					"account": {
+						Type:        framework.TypeInt,
+						Description: "Account index of the user key (optional, defaults to 0)",
+						Default:     0,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Testnet wallet",
+						Default:     false,
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathReadMultisig,
+					logical.UpdateOperation: b.pathWriteMultisig,
+					logical.DeleteOperation: b.pathDeleteMultisig,
+				},
+			},
+
+			// api/multisig/<uuid>/<name>/address
+			{
+				Pattern: "multisig/" + framework.GenericNameRegex("uuid") + "/" + framework.GenericNameRegex("name") +
+					"/address",
+				HelpSynopsis: "Derive an address of a Bitcoin multisig wallet",
+				HelpDescription: `
+
+Returns the address of the multisig wallet at <change>/<index> and its witness script.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"name": {
+						Type:        framework.TypeString,
+						Description: "Name of the wallet",
+					},
+					"change": {
+						Type:        framework.TypeInt,
+						Description: "0 for receive addresses, 1 for change addresses (optional, defaults to 0)",
+						Default:     0,
+					},
+					"index": {
+						Type:        framework.TypeInt,
+						Description: "Address index (optional, defaults to 0)",
+						Default:     0,
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Testnet wallet, must match the registered wallet",
+						Default:     false,
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.withDebugCapture(b.withAPIKey(lib.OperationMultisig, b.pathMultisigAddress)),
+				},
+			},
+
+			// api/multisig/<uuid>/<name>/sign
+			{
+				Pattern: "multisig/" + framework.GenericNameRegex("uuid") + "/" + framework.GenericNameRegex("name") +
+					"/sign",
+				HelpSynopsis: "Add the partial signatures of a user to a multisig PSBT",
+				HelpDescription: `
+
+Signs the inputs of a PSBT (BIP-174, base64 or hex) spending addresses of the multisig wallet
+with the key of the user, and returns the PSBT with the partial signatures, witness and redeem
+scripts and the derivations of every key of the inputs, for the other cosigners and the finalizer.
+Addresses are found from the BIP-32 derivations of the inputs, else among the first 1000
+addresses of both chains.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"uuid": {
+						Type:        framework.TypeString,
+						Description: "UUID of the user",
+					},
+					"name": {
+						Type:        framework.TypeString,
+						Description: "Name of the wallet",
+					},
+					"psbt": {
+						Type:        framework.TypeString,
+						Description: "Base64 or hex encoded PSBT",
+					},
+					"isDev": {
+						Type:        framework.TypeBool,
+						Description: "Testnet wallet, must match the registered wallet",
+						Default:     false,
+					},
+					"approvalId": {
+						Type:        framework.TypeString,
+						Description: "Approval granting the request, when config/approvals held it (optional)",
+					},
+					"apiKey": {
+						Type:        framework.TypeString,
+						Description: "Scoped API key of the integration (required when config/features has apiKeysRequired)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.UpdateOperation: b.policyOp(lib.OperationMultisig, signMultisigIntent, b.pathSignMultisig),
+				},
+			},
+
+			// api/coins
+			{
+				Pattern:      "coins",
+				HelpSynopsis: "List the supported coin types and their capabilities",
+				HelpDescription: `
+
+Lists every coin type handled by the registered adapters with the supported operations,
+signing curve, address formats, sign payload format and whether testnet mode is available.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathCoins,
+				},
+			},
+
+			// api/utils/convert
+			{
+				Pattern:      "utils/convert",
+				HelpSynopsis: "Convert an amount between the units of a coin",
+				HelpDescription: `
+
+Converts amount, a decimal number in the unit from (the base unit of the coin by default), to
+every unit of coinType, e.g., wei, gwei and ETH, sat and BTC or lamports and SOL, returned in
+amounts with the amount in base units. With to, the amount in that unit is returned in amount.
+The conversion is exact: amounts finer than the base unit are rejected rather than rounded.
+
+`,
+				Fields: map[string]*framework.FieldSchema{
+					"coinType": {
+						Type:        framework.TypeInt,
+						Description: "Cointype of the amount",
+					},
+					"amount": {
+						Type:        framework.TypeString,
+						Description: "Decimal amount to convert, e.g., 1.5",
+					},
+					"from": {
+						Type:        framework.TypeString,
+						Description: "Unit of amount, case insensitive (optional, defaults to the base unit)",
+					},
+					"to": {
+						Type:        framework.TypeString,
+						Description: "Unit to convert amount to (optional)",
+					},
+				},
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation:   b.pathConvert,
+					logical.UpdateOperation: b.pathConvert,
+				},
+			},
+
+			// api/support/bundle
+			{
+				Pattern:      "support/bundle",
+				HelpSynopsis: "Gather a sanitized diagnostic bundle of the mount for support tickets",
+				HelpDescription: `
+
+Returns in one document the versions of the plugin, Go, the Vault SDK and the Vault server, the
+configuration of config/import, the feature flags, the coin adapters and their coin types, the last
+validation of the stored configuration, and the errors of the requests served by the node since
+the plugin started, counted by route pattern and status with the last error. Secrets are
+redacted and URLs reduced to their host, so the bundle can be attached to a ticket as is. The
+error counters are kept in memory, per node.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathSupportBundle,
+				},
+			},
+
+			// api/info
+			{
+				Pattern:      "info",
+				HelpSynopsis: "Display information about this plugin",
+				HelpDescription: `
+
+Displays information about the plugin, such as the plugin version and where to
+get help, and the feature flags of the mount.
+
+`,
+				Callbacks: map[logical.Operation]framework.OperationFunc{
+					logical.ReadOperation: b.pathInfo,
+				},
+			},
+		},
+	}
+	return &b
+}
+
+// initialize applies the stored logging configuration of the mount
+func (b *Backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	loggingConfig, err := helpers.GetLoggingConfig(ctx, req.Storage)
+	if err != nil {
+		return errors.Wrap(err, "failed to read logging configuration")
+	}
+
+	level, err := logging.ParseLevel(loggingConfig.Level)
+	if err != nil {
+		return errors.Wrap(err, "invalid stored logging configuration")
+	}
+	b.logLevel.Set(level)
+
+	// the stored configuration and the adapters are validated before the first request relies on them
+	validation, err := b.validateConfig(ctx, req.Storage)
+	if err != nil {
+		return errors.Wrap(err, "failed to validate stored configuration")
+	}
+	for _, problem := range validation.problems {
+		b.logger.Error("invalid stored configuration", "path", problem.Path, "error", problem.Err)
+	}
+	if len(validation.problems) > 0 {
+		return errInvalidConfig(validation)
+	}
+
+	// the entropy source is checked before any mnemonic is generated from it
+	if entropyConfig, err := helpers.GetEntropyConfig(ctx, req.Storage); err == nil {
+		b.checkEntropy(entropyConfig.Source)
+	}
+
+	// the work interrupted by the previous instance resumes: the DEK rotation and the event queue
+	// on the periodic runs, the sign batches when they are sent again with their batchId
+	if rotation, err := helpers.GetDEKRotation(ctx, req.Storage); err == nil && rotation != nil &&
+		rotation.State == helpers.DEKRotationRunning {
+		b.logger.Info("dek rotation resumes", "keyId", rotation.KeyID, "processed", rotation.Processed,
+			"total", rotation.Total)
+	}
+	if batches, err := req.Storage.List(ctx, config.BatchWALStoragePath); err == nil && len(batches) > 0 {
+		b.logger.Info("logged sign batches", "count", len(batches))
+	}
+	if queued, err := req.Storage.List(ctx, config.EventsStoragePath); err == nil && len(queued) > 0 {
+		b.logger.Info("queued events resume", "count", len(queued))
+	}
+	return nil
+}
+
+// cleanTimeout bounds how long clean waits for the requests being served
+const cleanTimeout = 10 * time.Second
+
+// begin admits a request or periodic run, it returns false once clean started
+func (b *Backend) begin() bool {
+	b.activeMu.Lock()
+	defer b.activeMu.Unlock()
+
+	if b.stopping {
+		return false
+	}
+	b.active++
+	return true
+}
+
+// reloading reports whether clean started: the long running work checkpoints and returns
+func (b *Backend) reloading() bool {
+	return b.stopCtx != nil && b.stopCtx.Err() != nil
+}
+
+// end records the end of a request or periodic run admitted by begin
+func (b *Backend) end() {
+	b.activeMu.Lock()
+	defer b.activeMu.Unlock()
+
+	b.active--
+	if b.active == 0 && b.drained != nil {
+		close(b.drained)
+		b.drained = nil
+	}
+}
+
+// clean runs when the plugin is reloaded or the mount unmounted. New requests are refused, the
+// ones being served are stopped at their next checkpoint and waited for, then the in-memory state
+// is dropped: the connections are closed and the cached records and keys zeroed.
+func (b *Backend) clean(_ context.Context) {
+	b.activeMu.Lock()
+	b.stopping = true
+	b.stop()
+	var drained chan struct{}
+	if b.active > 0 {
+		drained = make(chan struct{})
+		b.drained = drained
+	}
+	active := b.active
+	b.activeMu.Unlock()
+
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-time.After(cleanTimeout):
+			b.logger.Warn("clean timed out waiting for the requests", "active", active)
+		}
+	}
+
+	b.attestationMu.Lock()
+	clear(b.attestationSigner)
+	b.attestationMu.Unlock()
+	b.userStoreMu.Lock()
+	if b.userStoreConfig != nil {
+		clear(b.userStoreConfig.EncryptionKey)
+		clear(b.userStoreConfig.PreviousEncryptionKey)
+	}
+	b.userStoreMu.Unlock()
+
+	b.resetUserStorage()
+	b.resetUserCache()
+	b.resetAttestationKey()
+	b.resetEventSink()
+	b.resetTracing()
+	b.logger.Info("plugin state cleaned", "active", active)
 }
 
 const backendHelp = `
@@ -0,0 +1,167 @@
+// Package dqvaulttest runs a fully wired dq-vault backend over an in-memory storage, so services
+// integrating with the plugin can write integration tests without a Vault dev server.
+//
+//	h := dqvaulttest.New(t)
+//	uuid := h.RegisterUser(dqvaulttest.TestMnemonic)
+//	signed := h.Sign(uuid, 60, "m/44'/60'/0'/0/0", payload)
+//	dqvaulttest.RequireEVMSender(t, signed.Signature, signed.Address)
+package dqvaulttest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// TestMnemonic is the BIP-39 test vector mnemonic, whose m/44'/60'/0'/0/0 address is TestEVMAddress
+const TestMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// TestEVMAddress is the address of TestMnemonic at m/44'/60'/0'/0/0
+const TestEVMAddress = "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+
+// Harness is a dq-vault backend mounted over a logical.InmemStorage. Requests go through the
+// backend router, exactly as Vault sends them to the plugin.
+type Harness struct {
+	tb      testing.TB
+	Backend logical.Backend
+	Storage logical.Storage
+}
+
+// Signature is the response of sign
+type Signature struct {
+	Signature string
+	Address   string
+	PublicKey string
+}
+
+// New returns a harness over an empty storage, cleaned up at the end of the test
+func New(tb testing.TB) *Harness {
+	tb.Helper()
+	ctx := context.Background()
+
+	storage := &logical.InmemStorage{}
+	backendConfig := logical.TestBackendConfig()
+	backendConfig.StorageView = storage
+	backend, err := api.Factory(ctx, backendConfig)
+	if err != nil {
+		tb.Fatalf("create backend: %v", err)
+	}
+	if err := backend.Initialize(ctx, &logical.InitializationRequest{Storage: storage}); err != nil {
+		tb.Fatalf("initialize backend: %v", err)
+	}
+	tb.Cleanup(func() { backend.Cleanup(ctx) })
+
+	return &Harness{tb: tb, Backend: backend, Storage: storage}
+}
+
+// Request sends operation on path to the backend, returning its response and error unchanged
+func (h *Harness) Request(operation logical.Operation, path string,
+	data map[string]interface{}) (*logical.Response, error) {
+	return h.Backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: operation,
+		Path:      path,
+		Data:      data,
+		Storage:   h.Storage,
+	})
+}
+
+// Read reads path, failing the test on error
+func (h *Harness) Read(path string) *logical.Response {
+	h.tb.Helper()
+	return h.require(h.Request(logical.ReadOperation, path, nil))
+}
+
+// Write writes data to path, failing the test on error
+func (h *Harness) Write(path string, data map[string]interface{}) *logical.Response {
+	h.tb.Helper()
+	return h.require(h.Request(logical.UpdateOperation, path, data))
+}
+
+func (h *Harness) require(resp *logical.Response, err error) *logical.Response {
+	h.tb.Helper()
+	if err != nil {
+		h.tb.Fatalf("request: %v", err)
+	}
+	if resp.IsError() {
+		h.tb.Fatalf("request: %v", resp.Error())
+	}
+	return resp
+}
+
+// RegisterUser registers a user with mnemonic, or a generated one when empty, and returns its uuid
+func (h *Harness) RegisterUser(mnemonic string) string {
+	h.tb.Helper()
+	resp := h.Write("register_uuid", map[string]interface{}{"username": "test-user", "mnemonic": mnemonic})
+	return resp.Data["uuid"].(string)
+}
+
+// Address derives the address of the user uuid at path
+func (h *Harness) Address(uuid string, coinType uint16, path string) string {
+	h.tb.Helper()
+	resp := h.Write("address", map[string]interface{}{"uuid": uuid, "coinType": int(coinType), "derivationPath": path})
+	return resp.Data["address"].(string)
+}
+
+// Sign signs payload with the key of the user uuid at path
+func (h *Harness) Sign(uuid string, coinType uint16, path, payload string) *Signature {
+	h.tb.Helper()
+	resp := h.Write("sign", map[string]interface{}{
+		"uuid": uuid, "coinType": int(coinType), "derivationPath": path, "payload": payload,
+	})
+	return &Signature{
+		Signature: resp.Data["signature"].(string),
+		Address:   resp.Data["address"].(string),
+		PublicKey: resp.Data["publicKey"].(string),
+	}
+}
+
+// RequireEVMSender checks that the hex encoded EVM transaction signedTx is signed by address
+func RequireEVMSender(tb testing.TB, signedTx, address string) {
+	tb.Helper()
+	raw, err := hex.DecodeString(strings.TrimPrefix(signedTx, "0x"))
+	if err != nil {
+		tb.Fatalf("decode signed transaction: %v", err)
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		tb.Fatalf("decode signed transaction: %v", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+	if err != nil {
+		tb.Fatalf("recover sender: %v", err)
+	}
+	if !strings.EqualFold(sender.Hex(), address) {
+		tb.Fatalf("transaction signed by %s, want %s", sender.Hex(), address)
+	}
+}
+
+// RequireSolanaSigner checks that the first signature of the hex encoded Solana transaction
+// signedTx is the signature of its message by address
+func RequireSolanaSigner(tb testing.TB, signedTx, address string) {
+	tb.Helper()
+	raw, err := hex.DecodeString(strings.TrimPrefix(signedTx, "0x"))
+	if err != nil {
+		tb.Fatalf("decode signed transaction: %v", err)
+	}
+	// a single byte compact-u16 signature count, then the signatures and the message
+	if len(raw) < 1 || raw[0] == 0 || raw[0] >= 0x80 || len(raw) < 1+int(raw[0])*ed25519.SignatureSize {
+		tb.Fatalf("signed transaction has no signature")
+	}
+	signature := raw[1 : 1+ed25519.SignatureSize]
+	message := raw[1+int(raw[0])*ed25519.SignatureSize:]
+
+	publicKey, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		tb.Fatalf("decode address: %v", err)
+	}
+	if !ed25519.Verify(publicKey[:], message, signature) {
+		tb.Fatalf("transaction is not signed by %s", address)
+	}
+}
@@ -0,0 +1,57 @@
+package dqvaulttest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/dqvaulttest"
+)
+
+const testEVMPayload = `{"nonce":42,"value":1000000000000000000,"gasLimit":21000,"gasPrice":20000000000,` +
+	`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0x","chainId":1}`
+
+func TestHarness(t *testing.T) {
+	h := dqvaulttest.New(t)
+	uuid := h.RegisterUser(dqvaulttest.TestMnemonic)
+
+	t.Run("evm", func(t *testing.T) {
+		assert.Equal(t, dqvaulttest.TestEVMAddress, h.Address(uuid, 60, "m/44'/60'/0'/0/0"))
+
+		signed := h.Sign(uuid, 60, "m/44'/60'/0'/0/0", testEVMPayload)
+		assert.Equal(t, dqvaulttest.TestEVMAddress, signed.Address)
+		dqvaulttest.RequireEVMSender(t, signed.Signature, signed.Address)
+	})
+
+	t.Run("solana", func(t *testing.T) {
+		address := h.Address(uuid, 501, "m/44'/501'/0'/0'")
+		resp := h.Write("sign/spl-transfer", map[string]interface{}{
+			"uuid":            uuid,
+			"derivationPath":  "m/44'/501'/0'/0'",
+			"mint":            "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v",
+			"recipient":       "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+			"amount":          1_000_000,
+			"recentBlockhash": "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N",
+		})
+		dqvaulttest.RequireSolanaSigner(t, resp.Data["signature"].(string), address)
+	})
+
+	t.Run("errors are returned unchanged", func(t *testing.T) {
+		_, err := h.Request(logical.UpdateOperation, "sign/digest", map[string]interface{}{
+			"uuid": uuid, "derivationPath": "m/44'/60'/0'/0/0", "digest": "00",
+		})
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+
+	t.Run("generated mnemonic", func(t *testing.T) {
+		other := h.RegisterUser("")
+		assert.NotEqual(t, uuid, other)
+		assert.NotEqual(t, dqvaulttest.TestEVMAddress, h.Address(other, 60, "m/44'/60'/0'/0/0"))
+	})
+}
@@ -0,0 +1,157 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidAddressChain   = errors.New("account and change must be between 0 and 2^31-1")
+	ErrInvalidPurpose        = errors.New("purpose must be between 0 and 2^31-1")
+	ErrAddressIndexExhausted = errors.New("no address index left on this chain")
+	ErrFixedDerivationPath   = errors.New("coinType derives its address from a fixed path")
+	ErrMissingAddress        = errors.New("address is required")
+	ErrInvalidBTCTxRequest   = errors.New("utxos must be objects with txid, vout, value and path, outputs objects " +
+		"with address and value")
+)
+
+// AddressChain -- the next unused address index of one (account, change) chain of a user coin,
+// allocated by address/next
+type AddressChain struct {
+	Next      uint32    `json:"next"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// AddressReservation -- an address index handed out by address/next, recorded so it is never reused
+type AddressReservation struct {
+	Index      uint32    `json:"index"`
+	Path       string    `json:"path"`
+	Address    string    `json:"address"`
+	Reference  string    `json:"reference,omitempty"`
+	EntityID   string    `json:"entityId,omitempty"`
+	ReservedAt time.Time `json:"reservedAt"`
+}
+
+// addressChainKey returns the storage key of the (account, change) chain of coinType of uuid
+func addressChainKey(uuid string, coinType uint16, account, change uint32) string {
+	return fmt.Sprintf("%s%s/%d/%d/%d", config.AddressLedgerStoragePath, uuid, coinType, account, change)
+}
+
+// GetAddressChain reads the address chain, returning a chain starting at index 0 when none is stored
+func GetAddressChain(ctx context.Context, s logical.Storage, uuid string, coinType uint16,
+	account, change uint32) (*AddressChain, error) {
+	entry, err := s.Get(ctx, addressChainKey(uuid, coinType, account, change))
+	if err != nil {
+		return nil, err
+	}
+
+	var chain AddressChain
+	if entry == nil {
+		return &chain, nil
+	}
+	if err := entry.DecodeJSON(&chain); err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
+
+// PutAddressReservation records reservation and advances its chain past it. The reservation is written
+// first, so a failure cannot leave the chain pointing past an index that was never recorded.
+func PutAddressReservation(ctx context.Context, s logical.Storage, uuid string, coinType uint16,
+	account, change uint32, reservation *AddressReservation) error {
+	key := addressChainKey(uuid, coinType, account, change)
+	entry, err := logical.StorageEntryJSON(fmt.Sprintf("%s/%d", key, reservation.Index), reservation)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	entry, err = logical.StorageEntryJSON(key, &AddressChain{
+		Next:      reservation.Index + 1,
+		UpdatedAt: reservation.ReservedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteAddressLedger removes every address chain and reservation of uuid
+func DeleteAddressLedger(ctx context.Context, s logical.Storage, uuid string) error {
+	return logical.ClearView(ctx, logical.NewStorageView(s, config.AddressLedgerStoragePath+uuid+"/"))
+}
+
+// AddressOwner -- the user and path an indexed address was derived from
+type AddressOwner struct {
+	UUID      string    `json:"uuid"`
+	CoinType  uint16    `json:"coinType"`
+	Path      string    `json:"path"`
+	IsDev     bool      `json:"isDev"`
+	IndexedAt time.Time `json:"indexedAt"`
+}
+
+// AddressIndexEntry -- the owners of an address derived through the plugin. EVM addresses are shared
+// by the coin types of the EVM chains, so an address can have several owners.
+type AddressIndexEntry struct {
+	Address string         `json:"address"`
+	Owners  []AddressOwner `json:"owners"`
+}
+
+// addressIndexKey returns the storage key of address; addresses are hashed as some encodings hold "/"
+func addressIndexKey(address string) string {
+	// hex addresses are case insensitive, their checksum is in the case
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		address = strings.ToLower(address)
+	}
+	hash := sha256.Sum256([]byte(address))
+	return config.AddressIndexStoragePath + hex.EncodeToString(hash[:])
+}
+
+// LookupAddress reads the index entry of address, returning nil when it was never indexed
+func LookupAddress(ctx context.Context, s logical.Storage, address string) (*AddressIndexEntry, error) {
+	entry, err := s.Get(ctx, addressIndexKey(address))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var indexed AddressIndexEntry
+	if err := entry.DecodeJSON(&indexed); err != nil {
+		return nil, err
+	}
+	return &indexed, nil
+}
+
+// IndexAddress adds owner to the index entry of address, unless it is already recorded
+func IndexAddress(ctx context.Context, s logical.Storage, address string, owner AddressOwner) error {
+	indexed, err := LookupAddress(ctx, s, address)
+	if err != nil {
+		return err
+	}
+	if indexed == nil {
+		indexed = &AddressIndexEntry{Address: address}
+	}
+	for _, known := range indexed.Owners {
+		if known.UUID == owner.UUID && known.CoinType == owner.CoinType && known.Path == owner.Path &&
+			known.IsDev == owner.IsDev {
+			return nil
+		}
+	}
+	indexed.Owners = append(indexed.Owners, owner)
+
+	entry, err := logical.StorageEntryJSON(addressIndexKey(address), indexed)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidAddressOverride    = errors.New("the overrides are hrp, pubKeyHashVersion and scriptHashVersion from 0 to 255")
+	ErrAddressOverrideNotAllowed = errors.New("the address overrides are not allowed by config/address-overrides")
+	ErrAddressOverrideCoinType   = errors.New("coinType has no address overrides")
+)
+
+// AddressOverride -- overrides of the address encoding of the address requests, for the chains
+// forking or rebranding a supported family; nil versions are not overridden
+type AddressOverride struct {
+	HRP               string `json:"hrp,omitempty"`
+	PubKeyHashVersion *int   `json:"pubKeyHashVersion,omitempty"`
+	ScriptHashVersion *int   `json:"scriptHashVersion,omitempty"`
+}
+
+// IsZero reports whether o overrides nothing
+func (o AddressOverride) IsZero() bool {
+	return o.HRP == "" && o.PubKeyHashVersion == nil && o.ScriptHashVersion == nil
+}
+
+// Equal reports whether o and other override the same fields with the same values
+func (o AddressOverride) Equal(other AddressOverride) bool {
+	sameVersion := func(a, b *int) bool { return (a == nil) == (b == nil) && (a == nil || *a == *b) }
+	return o.HRP == other.HRP && sameVersion(o.PubKeyHashVersion, other.PubKeyHashVersion) &&
+		sameVersion(o.ScriptHashVersion, other.ScriptHashVersion)
+}
+
+// String formats o for the logs and errors, e.g. hrp=ltc,pubKeyHashVersion=48
+func (o AddressOverride) String() string {
+	var fields []string
+	if o.HRP != "" {
+		fields = append(fields, "hrp="+o.HRP)
+	}
+	if o.PubKeyHashVersion != nil {
+		fields = append(fields, "pubKeyHashVersion="+strconv.Itoa(*o.PubKeyHashVersion))
+	}
+	if o.ScriptHashVersion != nil {
+		fields = append(fields, "scriptHashVersion="+strconv.Itoa(*o.ScriptHashVersion))
+	}
+	return strings.Join(fields, ",")
+}
+
+// Validate checks the human readable part and the version bytes of o
+func (o AddressOverride) Validate() error {
+	if o.HRP != "" && !bitcoin.ValidHRP(o.HRP) {
+		return fmt.Errorf("%w: %s", bitcoin.ErrInvalidHRP, o.HRP)
+	}
+	for _, version := range []*int{o.PubKeyHashVersion, o.ScriptHashVersion} {
+		if version != nil && (*version < 0 || *version > 255) {
+			return fmt.Errorf("%w: %d", ErrInvalidAddressOverride, *version)
+		}
+	}
+	return nil
+}
+
+// Bitcoin returns o in the form of the Bitcoin adapter, once validated
+func (o AddressOverride) Bitcoin() bitcoin.AddressOverrides {
+	overrides := bitcoin.AddressOverrides{HRP: o.HRP}
+	if o.PubKeyHashVersion != nil {
+		version := byte(*o.PubKeyHashVersion)
+		overrides.PubKeyHashVersion = &version
+	}
+	if o.ScriptHashVersion != nil {
+		version := byte(*o.ScriptHashVersion)
+		overrides.ScriptHashVersion = &version
+	}
+	return overrides
+}
+
+// AddressOverridePolicy -- the address overrides the requests may use, each as a whole; none by default
+type AddressOverridePolicy struct {
+	Allowed []AddressOverride `json:"allowed"`
+}
+
+// GetAddressOverridePolicy reads the address override policy of the mount, allowing none when none is stored
+func GetAddressOverridePolicy(ctx context.Context, s logical.Storage) (*AddressOverridePolicy, error) {
+	entry, err := s.Get(ctx, config.AddressOverridesStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy AddressOverridePolicy
+	if entry == nil {
+		return &policy, nil
+	}
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Allows reports whether override is one of the allowed overrides, exactly
+func (p *AddressOverridePolicy) Allows(override AddressOverride) bool {
+	return slices.ContainsFunc(p.Allowed, override.Equal)
+}
+
+// Validate checks each of the allowed overrides
+func (p *AddressOverridePolicy) Validate() error {
+	var errs []error
+	for _, override := range p.Allowed {
+		errs = append(errs, override.Validate())
+	}
+	return errors.Join(errs...)
+}
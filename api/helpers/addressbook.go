@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// AnyAddressBookTag is the tag of the address book policy matching every entry
+const AnyAddressBookTag = "*"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidAddressBookEntry  = errors.New("tags must not be empty or contain '*'")
+	ErrInvalidCounterparty      = errors.New("address is not an address of the coin type")
+	ErrInvalidAddressBookPolicy = errors.New("requiredTags must map coin types to a tag or '*'")
+	ErrRecipientNotInBook       = errors.New("recipient is not an address book entry with the tag required by the policy")
+	ErrRecipientsNotDecoded     = errors.New("the recipients of the payload cannot be decoded for the address book policy")
+)
+
+// AddressBookEntry -- a named counterparty: an address of a coin type and the tags the address
+// book policy selects it by
+type AddressBookEntry struct {
+	Name      string    `json:"name"`
+	CoinType  uint16    `json:"coinType"`
+	Address   string    `json:"address"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HasTag reports whether the entry carries tag, every entry carries AnyAddressBookTag
+func (e *AddressBookEntry) HasTag(tag string) bool {
+	return tag == AnyAddressBookTag || slices.Contains(e.Tags, tag)
+}
+
+// AddressBookPolicy -- the tag the recipients of the sign payloads of a coin type must carry in
+// the address book; coin types without one are not restricted
+type AddressBookPolicy struct {
+	RequiredTags map[uint16]string `json:"requiredTags"`
+}
+
+// NormalizeCounterparty checks that address is an address of coinType, returning it in its
+// canonical form: EIP-55 checksummed for EVM coin types. Bitcoin addresses of the main and test
+// networks are accepted, as isDev signs on the test network. The addresses of the other coin
+// types are stored as given.
+func NormalizeCounterparty(coinType uint16, address string) (string, error) {
+	logger := slog.New(slog.DiscardHandler)
+	switch {
+	case evm.NewEthereumAdapter(logger).CanDo(coinType):
+		if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+			return "", ErrInvalidCounterparty
+		}
+		return common.HexToAddress(address).Hex(), nil
+	case bitcoin.NewBitcoinAdapter(logger).CanDo(coinType):
+		for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params} {
+			if decoded, err := btcutil.DecodeAddress(address, params); err == nil && decoded.IsForNet(params) {
+				return address, nil
+			}
+		}
+		return "", ErrInvalidCounterparty
+	}
+	if address == "" {
+		return "", ErrInvalidCounterparty
+	}
+	return address, nil
+}
+
+// CounterpartyKey is the key an address is looked up by: EVM hex addresses compare without their
+// checksum case, the other encodings exactly
+func CounterpartyKey(address string) string {
+	if strings.HasPrefix(address, "0x") {
+		return strings.ToLower(address)
+	}
+	return address
+}
+
+// AddressBookIndex -- the entries of a coin type by address
+type AddressBookIndex map[string]*AddressBookEntry
+
+// Lookup returns the entry of address, or nil when it is not in the address book
+func (i AddressBookIndex) Lookup(address string) *AddressBookEntry {
+	return i[CounterpartyKey(address)]
+}
+
+// GetAddressBookIndex reads the entries of coinType of the address book
+func GetAddressBookIndex(ctx context.Context, s logical.Storage, coinType uint16) (AddressBookIndex, error) {
+	names, err := s.List(ctx, config.AddressBookStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	index := make(AddressBookIndex)
+	for _, name := range names {
+		entry, err := GetAddressBookEntry(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil && entry.CoinType == coinType {
+			index[CounterpartyKey(entry.Address)] = entry
+		}
+	}
+	return index, nil
+}
+
+// GetAddressBookEntry reads the entry name, returning nil when it does not exist
+func GetAddressBookEntry(ctx context.Context, s logical.Storage, name string) (*AddressBookEntry, error) {
+	entry, err := s.Get(ctx, config.AddressBookStoragePath+name)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var e AddressBookEntry
+	if err := entry.DecodeJSON(&e); err != nil {
+		return nil, fmt.Errorf("decode address book entry %s: %w", name, err)
+	}
+	return &e, nil
+}
+
+// PutAddressBookEntry stores e
+func PutAddressBookEntry(ctx context.Context, s logical.Storage, e *AddressBookEntry) error {
+	entry, err := logical.StorageEntryJSON(config.AddressBookStoragePath+e.Name, e)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteAddressBookEntry removes the entry name
+func DeleteAddressBookEntry(ctx context.Context, s logical.Storage, name string) error {
+	return s.Delete(ctx, config.AddressBookStoragePath+name)
+}
+
+// GetAddressBookPolicy reads the address book policy, returning nil when none is configured
+func GetAddressBookPolicy(ctx context.Context, s logical.Storage) (*AddressBookPolicy, error) {
+	entry, err := s.Get(ctx, config.AddressBookPolicyStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var policy AddressBookPolicy
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// PutAddressBookPolicy stores policy
+func PutAddressBookPolicy(ctx context.Context, s logical.Storage, policy *AddressBookPolicy) error {
+	entry, err := logical.StorageEntryJSON(config.AddressBookPolicyStorageKey, policy)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteAddressBookPolicy removes the address book policy, the recipients are no longer restricted
+func DeleteAddressBookPolicy(ctx context.Context, s logical.Storage) error {
+	return s.Delete(ctx, config.AddressBookPolicyStorageKey)
+}
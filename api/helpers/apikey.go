@@ -0,0 +1,157 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// apiKeySecretLength is the number of random bytes of an API key secret
+const apiKeySecretLength = 32
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrAPIKeyRequired     = errors.New("apiKey is required on this mount")
+	ErrInvalidAPIKey      = errors.New("invalid apiKey")
+	ErrAPIKeyExpired      = errors.New("apiKey has expired")
+	ErrAPIKeyScope        = errors.New("apiKey does not allow this request")
+	ErrUnknownOperation   = errors.New("unknown operation")
+	ErrInvalidUUIDPattern = errors.New("invalid uuid pattern")
+)
+
+// APIKeyOperations are the operations an API key can be scoped to
+//
+//nolint:gochecknoglobals // read-only lookup table
+var APIKeyOperations = []string{
+	lib.OperationAddress,
+	lib.OperationAddressBatch,
+	lib.OperationAddressNext,
+	lib.OperationSign,
+	lib.OperationSPLTransfer,
+	lib.OperationSignPSBT,
+	lib.OperationBuildBTCTx,
+	lib.OperationSignSafeTx,
+	lib.OperationSignUserOp,
+	lib.OperationSignPermit,
+	lib.OperationMultisig,
+	lib.OperationSignDigest,
+	lib.OperationBroadcast,
+}
+
+// APIKey -- a scoped key minted for one integration. Only the hash of the secret is stored.
+// Empty scopes allow everything.
+type APIKey struct {
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"secretHash"`
+	UUIDPatterns []string  `json:"uuidPatterns,omitempty"`
+	CoinTypes    []uint16  `json:"coinTypes,omitempty"`
+	Operations   []string  `json:"operations,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt,omitempty"`
+}
+
+// NewAPIKey creates the key name with a random secret, returning the key and the
+// "<name>.<secret>" value callers send, which is not stored
+func NewAPIKey(name string, uuidPatterns []string, coinTypes []uint16, operations []string,
+	ttl time.Duration) (*APIKey, string, error) {
+	for _, pattern := range uuidPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidUUIDPattern, pattern)
+		}
+	}
+	for _, operation := range operations {
+		if !slices.Contains(APIKeyOperations, operation) {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownOperation, operation)
+		}
+	}
+
+	secret := make([]byte, apiKeySecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	secretHex := hex.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	key := &APIKey{
+		Name:         name,
+		SecretHash:   hashAPIKeySecret(secretHex),
+		UUIDPatterns: uuidPatterns,
+		CoinTypes:    coinTypes,
+		Operations:   operations,
+		CreatedAt:    now,
+	}
+	if ttl > 0 {
+		key.ExpiresAt = now.Add(ttl)
+	}
+	return key, name + "." + secretHex, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authorize checks that the key allows operation on the keys of uuid for coinType.
+// Operations that are not tied to a coin pass a nil coinType, and are denied to keys restricted to some coin types.
+func (k *APIKey) Authorize(operation, uuid string, coinType *uint16, now time.Time) error {
+	if !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	if len(k.Operations) > 0 && !slices.Contains(k.Operations, operation) {
+		return fmt.Errorf("%w: operation %s", ErrAPIKeyScope, operation)
+	}
+	if len(k.CoinTypes) > 0 && (coinType == nil || !slices.Contains(k.CoinTypes, *coinType)) {
+		return fmt.Errorf("%w: coinType", ErrAPIKeyScope)
+	}
+	if len(k.UUIDPatterns) > 0 && !slices.ContainsFunc(k.UUIDPatterns, func(pattern string) bool {
+		matched, err := path.Match(pattern, uuid)
+		return err == nil && matched
+	}) {
+		return fmt.Errorf("%w: uuid %s", ErrAPIKeyScope, uuid)
+	}
+	return nil
+}
+
+// GetAPIKey reads the API key name, returning nil when it does not exist
+func GetAPIKey(ctx context.Context, s logical.Storage, name string) (*APIKey, error) {
+	entry, err := s.Get(ctx, config.APIKeysStoragePath+name)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// LookupAPIKey returns the stored key matching the "<name>.<secret>" value sent by a caller
+func LookupAPIKey(ctx context.Context, s logical.Storage, value string) (*APIKey, error) {
+	sep := strings.LastIndex(value, ".")
+	if sep <= 0 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := GetAPIKey(ctx, s, value[:sep])
+	if err != nil {
+		return nil, err
+	}
+	if key == nil ||
+		subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKeySecret(value[sep+1:]))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	return key, nil
+}
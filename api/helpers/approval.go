@@ -0,0 +1,179 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// DefaultApprovalTTL is how long a sign request waits for its approval when the policy sets no ttl
+const DefaultApprovalTTL = time.Hour
+
+// Statuses of an approval
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	// ApprovalUsed is an approval whose sign request was signed; an approval signs once
+	ApprovalUsed = "used"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidApprovalConfig = errors.New("thresholds must map coin types to non-negative integer amounts")
+	ErrUnknownApproval       = errors.New("unknown approvalId")
+	ErrApprovalMismatch      = errors.New("approvalId was granted for another sign request")
+	ErrApprovalExpired       = errors.New("approval has expired")
+	ErrApprovalNotPending    = errors.New("approval was already decided")
+	ErrApprovalNotApproved   = errors.New("sign request was not approved")
+	ErrApprovalsDisabled     = errors.New("no approval policy is configured")
+	ErrSelfApproval          = errors.New("approvers must be distinct from the requester")
+	ErrApproverIdentity      = errors.New("approver does not have the identity required by the approval policy")
+)
+
+// ApprovalConfig -- stores the approval policy of the mount and the chat platform the approvers
+// are notified on. Sign requests for a coin type of Thresholds moving at least its amount, in base
+// units, or an amount that cannot be decoded, are held until approved. The signing secret verifies
+// the callbacks of the platform and is never returned. ApproverGroups and ApproverClaims restrict
+// the approvers to the members of one of the Vault identity groups, and to the entities with an
+// OIDC or JWT alias carrying all the claims. Format and Template select the webhook body, the chat
+// message of the provider by default.
+type ApprovalConfig struct {
+	Thresholds     map[uint16]string `json:"thresholds"`
+	TTL            time.Duration     `json:"ttl"`
+	Provider       string            `json:"provider"`
+	WebhookURL     string            `json:"webhookUrl"`
+	SigningSecret  string            `json:"signingSecret"`
+	LinkURL        string            `json:"linkUrl"`
+	ApproverGroups []string          `json:"approverGroups,omitempty"`
+	ApproverClaims map[string]string `json:"approverClaims,omitempty"`
+	Format         string            `json:"format,omitempty"`
+	Template       string            `json:"template,omitempty"`
+}
+
+// RequiresApproverIdentity reports whether the approvers must hold a Vault identity of the policy
+func (c *ApprovalConfig) RequiresApproverIdentity() bool {
+	return len(c.ApproverGroups) > 0 || len(c.ApproverClaims) > 0
+}
+
+// Approval -- a sign request held for approval. The request is identified by its fingerprint, the
+// sign request granted by the approval is the one sent again with its id and the same fields.
+type Approval struct {
+	ID          string           `json:"id"`
+	Fingerprint string           `json:"fingerprint"`
+	UUID        string           `json:"uuid"`
+	Path        string           `json:"path"`
+	Summary     approval.Summary `json:"summary"`
+	Status      string           `json:"status"`
+	Requester   string           `json:"requester"`
+	Decider     string           `json:"decider,omitempty"`
+	Notified    bool             `json:"notified"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	DecidedAt   time.Time        `json:"decidedAt,omitempty"`
+	ExpiresAt   time.Time        `json:"expiresAt"`
+}
+
+// ApprovalFingerprint returns the hash identifying a sign request by the fields of fields
+func ApprovalFingerprint(fields map[string]interface{}) (string, error) {
+	// encoding/json sorts the keys of maps
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Decide records action of decider on the approval at now
+func (a *Approval) Decide(action, decider string, now time.Time) error {
+	if !now.Before(a.ExpiresAt) {
+		return ErrApprovalExpired
+	}
+	if a.Status != ApprovalPending {
+		return fmt.Errorf("%w: %s", ErrApprovalNotPending, a.Status)
+	}
+
+	a.Status = ApprovalRejected
+	if action == approval.ActionApprove {
+		a.Status = ApprovalApproved
+	}
+	a.Decider, a.DecidedAt = decider, now.UTC()
+	return nil
+}
+
+// Use checks that the approval grants the sign request of fingerprint at now and records its use
+func (a *Approval) Use(fingerprint string, now time.Time) error {
+	if a.Fingerprint != fingerprint {
+		return ErrApprovalMismatch
+	}
+	if !now.Before(a.ExpiresAt) {
+		return ErrApprovalExpired
+	}
+	if a.Status != ApprovalApproved {
+		return fmt.Errorf("%w: %s", ErrApprovalNotApproved, a.Status)
+	}
+	a.Status = ApprovalUsed
+	return nil
+}
+
+// GetApprovalConfig reads the approval policy of the mount, returning nil when none is configured
+func GetApprovalConfig(ctx context.Context, s logical.Storage) (*ApprovalConfig, error) {
+	entry, err := s.Get(ctx, config.ApprovalsStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var approvals ApprovalConfig
+	if err := entry.DecodeJSON(&approvals); err != nil {
+		return nil, err
+	}
+	return &approvals, nil
+}
+
+// PutApprovalConfig stores the approval policy of the mount
+func PutApprovalConfig(ctx context.Context, s logical.Storage, approvals *ApprovalConfig) error {
+	entry, err := logical.StorageEntryJSON(config.ApprovalsStorageKey, approvals)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteApprovalConfig removes the approval policy of the mount, sign requests are no longer held
+func DeleteApprovalConfig(ctx context.Context, s logical.Storage) error {
+	return s.Delete(ctx, config.ApprovalsStorageKey)
+}
+
+// GetApproval reads the approval id, returning nil when it does not exist
+func GetApproval(ctx context.Context, s logical.Storage, id string) (*Approval, error) {
+	entry, err := s.Get(ctx, config.ApprovalsStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var a Approval
+	if err := entry.DecodeJSON(&a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// PutApproval stores a
+func PutApproval(ctx context.Context, s logical.Storage, a *Approval) error {
+	entry, err := logical.StorageEntryJSON(config.ApprovalsStoragePath+a.ID, a)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteApproval removes the approval id
+func DeleteApproval(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, config.ApprovalsStoragePath+id)
+}
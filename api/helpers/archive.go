@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/archive"
+)
+
+// Defaults of the archiver: how often the audit data is uploaded, and how long the archived
+// records stay in the Vault storage after they were
+const (
+	DefaultArchiveInterval       = time.Hour
+	DefaultArchiveLocalRetention = 30 * 24 * time.Hour
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidArchive = errors.New("interval and localRetention must be positive")
+)
+
+// ArchiveConfig -- the S3-compatible bucket the receipts, debug captures and replication journal
+// of the mount are archived to, every Interval, and how long the archived receipts and captures are
+// kept in the Vault storage. The secret access key is never returned.
+type ArchiveConfig struct {
+	Endpoint        string        `json:"endpoint"`
+	Bucket          string        `json:"bucket"`
+	Region          string        `json:"region,omitempty"`
+	Prefix          string        `json:"prefix,omitempty"`
+	AccessKeyID     string        `json:"accessKeyId"`
+	SecretAccessKey string        `json:"secretAccessKey"`
+	Interval        time.Duration `json:"interval"`
+	LocalRetention  time.Duration `json:"localRetention"`
+}
+
+// Options returns the uploader options of the config
+func (c *ArchiveConfig) Options() archive.S3Options {
+	return archive.S3Options{
+		Endpoint:        c.Endpoint,
+		Bucket:          c.Bucket,
+		Region:          c.Region,
+		Prefix:          c.Prefix,
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+	}
+}
+
+// Validate checks the config without connecting to the bucket
+func (c *ArchiveConfig) Validate() error {
+	if err := c.Options().Validate(); err != nil {
+		return err
+	}
+	if c.Interval <= 0 || c.LocalRetention <= 0 {
+		return ErrInvalidArchive
+	}
+	return nil
+}
+
+// ArchiveState -- how far the audit data was archived: the last receipt sequence number, debug
+// capture time and journal sequence number uploaded, the segments uploaded so far, and the last run
+// with its error
+type ArchiveState struct {
+	LastRunAt        time.Time `json:"lastRunAt,omitzero"`
+	LastError        string    `json:"lastError,omitempty"`
+	ReceiptSequence  uint64    `json:"receiptSequence"`
+	DebugCaptureTime time.Time `json:"debugCaptureTime,omitzero"`
+	JournalSequence  uint64    `json:"journalSequence"`
+	Segments         int       `json:"segments"`
+}
+
+// GetArchiveConfig reads the archiver of the mount, returning nil when none is configured
+func GetArchiveConfig(ctx context.Context, s logical.Storage) (*ArchiveConfig, error) {
+	entry, err := s.Get(ctx, config.ArchiveStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var archiveConfig ArchiveConfig
+	if err := entry.DecodeJSON(&archiveConfig); err != nil {
+		return nil, err
+	}
+	return &archiveConfig, nil
+}
+
+// PutArchiveConfig stores the archiver of the mount
+func PutArchiveConfig(ctx context.Context, s logical.Storage, archiveConfig *ArchiveConfig) error {
+	entry, err := logical.StorageEntryJSON(config.ArchiveStorageKey, archiveConfig)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// GetArchiveState reads how far the audit data was archived, nothing when it never was
+func GetArchiveState(ctx context.Context, s logical.Storage) (*ArchiveState, error) {
+	entry, err := s.Get(ctx, config.ArchiveStateStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	var state ArchiveState
+	if entry == nil {
+		return &state, nil
+	}
+	if err := entry.DecodeJSON(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// PutArchiveState stores how far the audit data was archived
+func PutArchiveState(ctx context.Context, s logical.Storage, state *ArchiveState) error {
+	entry, err := logical.StorageEntryJSON(config.ArchiveStateStorageKey, state)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,197 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidAsset          = errors.New("symbol must not be empty and decimals must be between 0 and 255")
+	ErrInvalidAssetAddress   = errors.New("address is not a contract or mint address of the coin type")
+	ErrDuplicateAsset        = errors.New("the address is already registered under another symbol")
+	ErrAssetDecimals         = errors.New("decimals differ from the decimals of the registered asset")
+	ErrInvalidContractPolicy = errors.New("restrictedCoinTypes must be EVM coin types")
+	ErrContractNotRegistered = errors.New("the payload calls a contract that is not a registered asset")
+)
+
+// Asset -- a token of the asset registry: the contract, or the Solana mint, of Symbol on a coin type
+type Asset struct {
+	Symbol    string    `json:"symbol"`
+	CoinType  uint16    `json:"coinType"`
+	Address   string    `json:"address"`
+	Decimals  int       `json:"decimals"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ContractPolicy -- the coin types whose sign payloads may only call the contracts of registered assets
+type ContractPolicy struct {
+	RestrictedCoinTypes []uint16 `json:"restrictedCoinTypes"`
+}
+
+// assetKey is the storage key of the asset symbol of coinType; symbols compare case insensitively
+func assetKey(coinType uint16, symbol string) string {
+	return config.AssetsStoragePath + strconv.Itoa(int(coinType)) + "/" + strings.ToLower(symbol)
+}
+
+// NormalizeAssetAddress checks that address is a contract or mint address of coinType, returning
+// it in its canonical form as NormalizeCounterparty does; mints of Solana must be base58 keys
+func NormalizeAssetAddress(coinType uint16, address string) (string, error) {
+	if solana.NewSolanaAdapter(slog.New(slog.DiscardHandler)).CanDo(coinType) {
+		if _, err := solana.PublicKeyFromBase58(address); err != nil {
+			return "", ErrInvalidAssetAddress
+		}
+		return address, nil
+	}
+	normalized, err := NormalizeCounterparty(coinType, address)
+	if err != nil {
+		return "", ErrInvalidAssetAddress
+	}
+	return normalized, nil
+}
+
+// ValidateAsset checks the symbol, decimals and address of a
+func ValidateAsset(a *Asset) error {
+	if a.Symbol == "" || a.Decimals < 0 || a.Decimals > math.MaxUint8 {
+		return ErrInvalidAsset
+	}
+	_, err := NormalizeAssetAddress(a.CoinType, a.Address)
+	return err
+}
+
+// GetAsset reads the asset symbol of coinType, returning nil when it is not registered
+func GetAsset(ctx context.Context, s logical.Storage, coinType uint16, symbol string) (*Asset, error) {
+	entry, err := s.Get(ctx, assetKey(coinType, symbol))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var a Asset
+	if err := entry.DecodeJSON(&a); err != nil {
+		return nil, fmt.Errorf("decode asset %d/%s: %w", coinType, symbol, err)
+	}
+	return &a, nil
+}
+
+// PutAsset stores a
+func PutAsset(ctx context.Context, s logical.Storage, a *Asset) error {
+	entry, err := logical.StorageEntryJSON(assetKey(a.CoinType, a.Symbol), a)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteAsset removes the asset symbol of coinType
+func DeleteAsset(ctx context.Context, s logical.Storage, coinType uint16, symbol string) error {
+	return s.Delete(ctx, assetKey(coinType, symbol))
+}
+
+// ListAssets reads every asset of the registry, by coin type then symbol
+func ListAssets(ctx context.Context, s logical.Storage) ([]*Asset, error) {
+	coinTypes, err := s.List(ctx, config.AssetsStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	var assets []*Asset
+	for _, coinType := range coinTypes {
+		symbols, err := s.List(ctx, config.AssetsStoragePath+coinType)
+		if err != nil {
+			return nil, err
+		}
+		for _, symbol := range symbols {
+			entry, err := s.Get(ctx, config.AssetsStoragePath+coinType+symbol)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue
+			}
+			var a Asset
+			if err := entry.DecodeJSON(&a); err != nil {
+				return nil, fmt.Errorf("decode asset %s%s: %w", coinType, symbol, err)
+			}
+			assets = append(assets, &a)
+		}
+	}
+	return assets, nil
+}
+
+// AssetIndex -- the assets of a coin type by address
+type AssetIndex map[string]*Asset
+
+// Lookup returns the asset of the contract or mint address, or nil when it is not registered
+func (i AssetIndex) Lookup(address string) *Asset {
+	return i[CounterpartyKey(address)]
+}
+
+// GetAssetIndex reads the assets of coinType of the registry
+func GetAssetIndex(ctx context.Context, s logical.Storage, coinType uint16) (AssetIndex, error) {
+	prefix := config.AssetsStoragePath + strconv.Itoa(int(coinType)) + "/"
+	symbols, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	index := make(AssetIndex, len(symbols))
+	for _, symbol := range symbols {
+		a, err := GetAsset(ctx, s, coinType, symbol)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			index[CounterpartyKey(a.Address)] = a
+		}
+	}
+	return index, nil
+}
+
+// ResolveAsset returns the registered asset of coinType named by its symbol or by its address,
+// or nil when the registry has none
+func ResolveAsset(ctx context.Context, s logical.Storage, coinType uint16, asset string) (*Asset, error) {
+	a, err := GetAsset(ctx, s, coinType, asset)
+	if err != nil || a != nil {
+		return a, err
+	}
+	index, err := GetAssetIndex(ctx, s, coinType)
+	if err != nil {
+		return nil, err
+	}
+	return index.Lookup(asset), nil
+}
+
+// GetContractPolicy reads the contract policy, returning nil when none is configured
+func GetContractPolicy(ctx context.Context, s logical.Storage) (*ContractPolicy, error) {
+	entry, err := s.Get(ctx, config.ContractPolicyStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var policy ContractPolicy
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// PutContractPolicy stores policy
+func PutContractPolicy(ctx context.Context, s logical.Storage, policy *ContractPolicy) error {
+	entry, err := logical.StorageEntryJSON(config.ContractPolicyStorageKey, policy)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteContractPolicy removes the contract policy, the contracts called are no longer restricted
+func DeleteContractPolicy(ctx context.Context, s logical.Storage) error {
+	return s.Delete(ctx, config.ContractPolicyStorageKey)
+}
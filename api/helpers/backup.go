@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// MinBackupWords is the number of words a backup verification must check, so a mismatch says
+// little about any single word
+const MinBackupWords = 3
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrTooFewBackupWords   = errors.New("backupWords must give at least 3 word positions")
+	ErrInvalidWordPosition = errors.New("word position must be between 1 and the mnemonic length")
+	ErrBackupVerifyLocked  = errors.New("too many failed backup verifications, try again later")
+	ErrNoMnemonicToVerify  = errors.New("watch-only users have no mnemonic to verify")
+)
+
+// BackupVerification -- the consecutive failed backup verifications of a user
+type BackupVerification struct {
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt"`
+}
+
+// MatchBackupWords reports whether the words, by their 1-based position, are the ones of mnemonic.
+// Words are compared case insensitively and in constant time.
+func MatchBackupWords(mnemonic string, words map[string]string) (bool, error) {
+	if len(words) < MinBackupWords {
+		return false, ErrTooFewBackupWords
+	}
+
+	mnemonicWords := strings.Fields(mnemonic)
+	match := 1
+	for position, word := range words {
+		index, err := strconv.Atoi(strings.TrimSpace(position))
+		if err != nil || index < 1 || index > len(mnemonicWords) {
+			return false, fmt.Errorf("%w: %s", ErrInvalidWordPosition, position)
+		}
+		match &= subtle.ConstantTimeCompare([]byte(strings.ToLower(strings.TrimSpace(word))),
+			[]byte(mnemonicWords[index-1]))
+	}
+	return match == 1, nil
+}
+
+// GetBackupVerification reads the failed verifications of uuid, returning none when nothing is stored
+func GetBackupVerification(ctx context.Context, s logical.Storage, uuid string) (*BackupVerification, error) {
+	entry, err := s.Get(ctx, config.BackupVerificationStoragePath+uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var verification BackupVerification
+	if entry == nil {
+		return &verification, nil
+	}
+	if err := entry.DecodeJSON(&verification); err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// PutBackupVerification stores the failed verifications of uuid
+func PutBackupVerification(ctx context.Context, s logical.Storage, uuid string,
+	verification *BackupVerification) error {
+	entry, err := logical.StorageEntryJSON(config.BackupVerificationStoragePath+uuid, verification)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteBackupVerification resets the failed verifications of uuid
+func DeleteBackupVerification(ctx context.Context, s logical.Storage, uuid string) error {
+	return s.Delete(ctx, config.BackupVerificationStoragePath+uuid)
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidBatchID    = errors.New("batchId must be 1 to 64 letters, digits, '.', '_' or '-'")
+	ErrBatchIDInUse      = errors.New("batchId belongs to a batch of another entity")
+	ErrBatchItemsChanged = errors.New("batchId was logged with other items")
+	ErrReloading         = errors.New("the plugin is reloading, retry the request")
+)
+
+//nolint:gochecknoglobals // compiled once, read-only
+var batchID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidBatchID reports whether id can name a batch
+func ValidBatchID(id string) bool {
+	return batchID.MatchString(id)
+}
+
+// BatchWAL -- the write-ahead log of a sign/batch named by a batchId: the items completed so far,
+// so the batch sent again after an interruption only signs the items left
+type BatchWAL struct {
+	ID        string         `json:"id"`
+	EntityID  string         `json:"entityId"`
+	Items     []BatchWALItem `json:"items"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// BatchWALItem -- an item of a logged batch, identified by the fingerprint of its sign fields;
+// Result is set once it was signed or held for approval
+type BatchWALItem struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Result      map[string]interface{} `json:"result,omitempty"`
+}
+
+// GetBatchWAL reads the log of the batch id, returning nil when it has none
+func GetBatchWAL(ctx context.Context, s logical.Storage, id string) (*BatchWAL, error) {
+	entry, err := s.Get(ctx, config.BatchWALStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var wal BatchWAL
+	if err := entry.DecodeJSON(&wal); err != nil {
+		return nil, err
+	}
+	return &wal, nil
+}
+
+// PutBatchWAL stores wal
+func PutBatchWAL(ctx context.Context, s logical.Storage, wal *BatchWAL) error {
+	entry, err := logical.StorageEntryJSON(config.BatchWALStoragePath+wal.ID, wal)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteBatchWAL removes the log of the batch id
+func DeleteBatchWAL(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, config.BatchWALStoragePath+id)
+}
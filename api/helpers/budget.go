@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidBudgetAmount = errors.New("amount must be a positive integer in base units")
+	ErrBudgetExceeded      = errors.New("the value of the transaction exceeds the budget left, top it up through budget/top-up")
+	ErrBudgetUnpriced      = errors.New("the value of the payload cannot be decoded, it cannot be charged to the budget")
+	ErrBudgetShared        = errors.New("a transaction signed by several users cannot be charged to the budget of one")
+)
+
+// Budget -- the signed-value allowance of a user on a coin type, in base units. Signing decrements
+// Remaining by the native value of the transaction; only a top-up replenishes it.
+type Budget struct {
+	UUID      string    `json:"uuid"`
+	CoinType  uint16    `json:"coinType"`
+	Remaining *big.Int  `json:"remaining"`
+	Spent     *big.Int  `json:"spent"`
+	ToppedUp  *big.Int  `json:"toppedUp"`
+	TopUps    int       `json:"topUps"`
+	LastTopUp string    `json:"lastTopUp,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewBudget returns the empty budget of uuid on coinType, a budget signing nothing until topped up
+func NewBudget(uuid string, coinType uint16, now time.Time) *Budget {
+	return &Budget{
+		UUID:      uuid,
+		CoinType:  coinType,
+		Remaining: new(big.Int),
+		Spent:     new(big.Int),
+		ToppedUp:  new(big.Int),
+		CreatedAt: now.UTC(),
+		UpdatedAt: now.UTC(),
+	}
+}
+
+// ParseBudgetAmount parses a positive amount in base units
+func ParseBudgetAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, ErrInvalidBudgetAmount
+	}
+	return value, nil
+}
+
+// TopUp adds amount to the budget, on behalf of entity
+func (b *Budget) TopUp(amount *big.Int, entity string, now time.Time) {
+	b.Remaining.Add(b.Remaining, amount)
+	b.ToppedUp.Add(b.ToppedUp, amount)
+	b.TopUps++
+	b.LastTopUp, b.UpdatedAt = entity, now.UTC()
+}
+
+// Debit charges value to the budget, failing when less is left
+func (b *Budget) Debit(value *big.Int, now time.Time) error {
+	if b.Remaining.Cmp(value) < 0 {
+		return ErrBudgetExceeded
+	}
+	b.Remaining.Sub(b.Remaining, value)
+	b.Spent.Add(b.Spent, value)
+	b.UpdatedAt = now.UTC()
+	return nil
+}
+
+// Refund gives back value debited for a transaction that was not signed
+func (b *Budget) Refund(value *big.Int, now time.Time) {
+	b.Remaining.Add(b.Remaining, value)
+	b.Spent.Sub(b.Spent, value)
+	b.UpdatedAt = now.UTC()
+}
+
+// GetBudget reads the budget of uuid on coinType, returning nil when it has none
+func GetBudget(ctx context.Context, s logical.Storage, uuid string, coinType uint16) (*Budget, error) {
+	entry, err := s.Get(ctx, budgetKey(uuid, coinType))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var budget Budget
+	if err := entry.DecodeJSON(&budget); err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// HasBudgets reports whether uuid has a budget on any coin type
+func HasBudgets(ctx context.Context, s logical.Storage, uuid string) (bool, error) {
+	coinTypes, err := s.List(ctx, config.BudgetsStoragePath+uuid+"/")
+	return len(coinTypes) > 0, err
+}
+
+// PutBudget stores budget
+func PutBudget(ctx context.Context, s logical.Storage, budget *Budget) error {
+	entry, err := logical.StorageEntryJSON(budgetKey(budget.UUID, budget.CoinType), budget)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteBudget removes the budget of uuid on coinType
+func DeleteBudget(ctx context.Context, s logical.Storage, uuid string, coinType uint16) error {
+	return s.Delete(ctx, budgetKey(uuid, coinType))
+}
+
+func budgetKey(uuid string, coinType uint16) string {
+	return config.BudgetsStoragePath + uuid + "/" + strconv.Itoa(int(coinType))
+}
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+const (
+	// DefaultCanaryInterval is how often the canary users sign when config/canary sets no interval
+	DefaultCanaryInterval = 5 * time.Minute
+	// MinCanaryInterval is the shortest interval, the periodic function running about every minute
+	MinCanaryInterval = time.Minute
+	// DefaultCanaryPath is the derivation path of the Ethereum address the canary messages are signed with
+	DefaultCanaryPath = "m/44'/60'/0'/0/0"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidCanaryInterval = errors.New("interval must be at least a minute")
+	ErrNoCanaryUsers         = errors.New("no canary user is configured, see config/canary")
+)
+
+// CanaryConfig -- stores the users the periodic function signs a canary message with every Interval
+type CanaryConfig struct {
+	UUIDs    []string      `json:"uuids"`
+	Interval time.Duration `json:"interval"`
+	Path     string        `json:"path"`
+}
+
+// CanaryResult -- the last canary signature of a user. A failed attempt keeps the last signature
+// and records the error; Failures counts the attempts failed since the last signature.
+type CanaryResult struct {
+	UUID        string    `json:"uuid"`
+	Path        string    `json:"path"`
+	Address     string    `json:"address"`
+	Message     string    `json:"message"`
+	Signature   string    `json:"signature"`
+	SignedAt    time.Time `json:"signedAt"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+	Error       string    `json:"error,omitempty"`
+	Failures    int       `json:"failures"`
+}
+
+// GetCanaryConfig reads the canary configuration of the mount, without users when none is stored
+func GetCanaryConfig(ctx context.Context, s logical.Storage) (*CanaryConfig, error) {
+	entry, err := s.Get(ctx, config.CanaryStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	canaryConfig := CanaryConfig{UUIDs: []string{}, Interval: DefaultCanaryInterval, Path: DefaultCanaryPath}
+	if entry == nil {
+		return &canaryConfig, nil
+	}
+	if err := entry.DecodeJSON(&canaryConfig); err != nil {
+		return nil, err
+	}
+	return &canaryConfig, nil
+}
+
+// GetCanaryResult reads the last canary signature of uuid, returning nil when it has none
+func GetCanaryResult(ctx context.Context, s logical.Storage, uuid string) (*CanaryResult, error) {
+	entry, err := s.Get(ctx, config.CanaryStoragePath+uuid)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var result CanaryResult
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PutCanaryResult stores result
+func PutCanaryResult(ctx context.Context, s logical.Storage, result *CanaryResult) error {
+	entry, err := logical.StorageEntryJSON(config.CanaryStoragePath+result.UUID, result)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteCanaryResult removes the last canary signature of uuid
+func DeleteCanaryResult(ctx context.Context, s logical.Storage, uuid string) error {
+	return s.Delete(ctx, config.CanaryStoragePath+uuid)
+}
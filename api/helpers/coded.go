@@ -0,0 +1,29 @@
+package helpers
+
+import "github.com/hashicorp/vault/sdk/logical"
+
+// codedError -- an error returned with the HTTP status code of its response, which still matches
+// the errors it wraps with errors.Is, unlike logical.CodedError keeping only the message
+type codedError struct {
+	status int
+	err    error
+}
+
+// CodedError returns err with the HTTP status code status
+func CodedError(status int, err error) logical.HTTPCodedError {
+	return &codedError{status: status, err: err}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Code returns the HTTP status code of the response
+func (e *codedError) Code() int {
+	return e.status
+}
+
+// Unwrap returns the error wrapped
+func (e *codedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// DebugSession -- a time bounded debug capture session of a user
+type DebugSession struct {
+	UUID      string    `json:"uuid"`
+	EnabledAt time.Time `json:"enabledAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Captures  int       `json:"captures"`
+}
+
+// Active reports whether requests of the user are captured at now
+func (s *DebugSession) Active(now time.Time) bool {
+	return now.Before(s.ExpiresAt)
+}
+
+// DebugCapture -- a sanitized request/response pair recorded during a debug session
+type DebugCapture struct {
+	ID        string                 `json:"id"`
+	Time      time.Time              `json:"time"`
+	Path      string                 `json:"path"`
+	Operation string                 `json:"operation"`
+	Request   map[string]interface{} `json:"request,omitempty"`
+	Response  map[string]interface{} `json:"response,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// GetDebugSession reads the debug session of uuid, returning nil when none was enabled
+func GetDebugSession(ctx context.Context, s logical.Storage, uuid string) (*DebugSession, error) {
+	entry, err := s.Get(ctx, config.DebugSessionsStoragePath+uuid)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var session DebugSession
+	if err := entry.DecodeJSON(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// PutDebugSession stores session
+func PutDebugSession(ctx context.Context, s logical.Storage, session *DebugSession) error {
+	entry, err := logical.StorageEntryJSON(config.DebugSessionsStoragePath+session.UUID, session)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteDebugSession removes the debug session of uuid and all of its captures
+func DeleteDebugSession(ctx context.Context, s logical.Storage, uuid string) error {
+	prefix := config.DebugStoragePath + uuid + "/"
+	ids, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.Delete(ctx, prefix+id); err != nil {
+			return err
+		}
+	}
+	return s.Delete(ctx, config.DebugSessionsStoragePath+uuid)
+}
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// States of a rotation of the data encryption key
+const (
+	DEKRotationRunning   = "running"
+	DEKRotationCompleted = "completed"
+	DEKRotationFailed    = "failed"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrNoEncryptionKey = errors.New(
+		"user records are not encrypted, configure an external store or run migrate/encrypt first")
+	ErrDEKRotationInProgress = errors.New("a data encryption key rotation is already running")
+	ErrStoreAlwaysEncrypted  = errors.New("records of the external store are always encrypted")
+	ErrUnreadableRecord      = errors.New("record is neither encrypted nor valid JSON")
+	ErrRoundTrip             = errors.New("encrypted record does not decrypt to the original")
+)
+
+// DEKRotation -- the progress of the re-encryption of the user records under a new data encryption key.
+// Cursor is the last record re-encrypted, the next batch resumes after it.
+type DEKRotation struct {
+	State       string    `json:"state"`
+	KeyID       string    `json:"keyId"`
+	Cursor      string    `json:"cursor,omitempty"`
+	Total       int       `json:"total"`
+	Processed   int       `json:"processed"`
+	Reencrypted int       `json:"reencrypted"`
+	Failed      []string  `json:"failed,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+}
+
+// GetDEKRotation reads the last rotation of the data encryption key, returning nil when none was started
+func GetDEKRotation(ctx context.Context, s logical.Storage) (*DEKRotation, error) {
+	entry, err := s.Get(ctx, config.DEKRotationStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var rotation DEKRotation
+	if err := entry.DecodeJSON(&rotation); err != nil {
+		return nil, err
+	}
+	return &rotation, nil
+}
+
+// PutDEKRotation stores the progress of the rotation of the data encryption key
+func PutDEKRotation(ctx context.Context, s logical.Storage, rotation *DEKRotation) error {
+	entry, err := logical.StorageEntryJSON(config.DEKRotationStorageKey, rotation)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// SunsetDateFormat is the layout of the sunset date of config/deprecation
+const SunsetDateFormat = time.DateOnly
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrLegacyField         = errors.New("legacy field is no longer accepted, see config/deprecation")
+	ErrLegacyFieldConflict = errors.New("legacy field given with the field replacing it")
+	ErrInvalidSunset       = errors.New("sunset must be a date, e.g., 2027-06-30")
+)
+
+// DeprecationConfig -- the migration of the clients of the mount off the legacy field names. Until
+// RejectLegacyFields is set, the legacy names are accepted with a warning announcing Sunset.
+type DeprecationConfig struct {
+	Sunset             time.Time `json:"sunset"`
+	RejectLegacyFields bool      `json:"rejectLegacyFields"`
+}
+
+// GetDeprecationConfig reads the deprecation configuration of the mount, accepting the legacy names
+// without a sunset when none is stored
+func GetDeprecationConfig(ctx context.Context, s logical.Storage) (*DeprecationConfig, error) {
+	entry, err := s.Get(ctx, config.DeprecationStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var deprecation DeprecationConfig
+	if entry == nil {
+		return &deprecation, nil
+	}
+	if err := entry.DecodeJSON(&deprecation); err != nil {
+		return nil, err
+	}
+	return &deprecation, nil
+}
@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// AccountDepth is the depth of the account level of BIP-44, m / purpose' / coin_type' / account'
+const AccountDepth = 3
+
+// coinTypeLevel is the index of the coin type level of BIP-44 among the components of a path
+const coinTypeLevel = 1
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrNonHardenedAboveAccount = errors.New("derivation path must be hardened down to the account level")
+	ErrDerivationTooDeep       = errors.New("derivation path is deeper than the max depth of config/derivation")
+	ErrInvalidMaxDepth         = errors.New("maxDepth must be 0, unbounded, or at least the account level")
+)
+
+// DerivationPolicy -- the bounds of the derivation paths of the requests. HardenedAccount requires the
+// purpose, coin type and account levels to be hardened, so a child private key and an exported xpub
+// never combine into the private key of the account or above; MaxDepth bounds the number of components.
+type DerivationPolicy struct {
+	HardenedAccount bool `json:"hardenedAccount"`
+	MaxDepth        int  `json:"maxDepth"`
+}
+
+// GetDerivationPolicy reads the derivation policy of the mount, allowing every path when none is stored
+func GetDerivationPolicy(ctx context.Context, s logical.Storage) (*DerivationPolicy, error) {
+	entry, err := s.Get(ctx, config.DerivationPolicyStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy DerivationPolicy
+	if entry == nil {
+		return &policy, nil
+	}
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Enforced reports whether the policy bounds any path
+func (p *DerivationPolicy) Enforced() bool {
+	return p.HardenedAccount || p.MaxDepth > 0
+}
+
+// Check returns an error when derivationPath breaks the policy. The %d of the path templates are
+// checked as index 0.
+func (p *DerivationPolicy) Check(derivationPath string) error {
+	if !p.Enforced() {
+		return nil
+	}
+	components, err := lib.ParseDerivationPath(strings.ReplaceAll(derivationPath, "%d", "0"))
+	if err != nil {
+		return err
+	}
+	if p.MaxDepth > 0 && len(components) > p.MaxDepth {
+		return fmt.Errorf("%w: %d > %d", ErrDerivationTooDeep, len(components), p.MaxDepth)
+	}
+	if p.HardenedAccount {
+		for depth, component := range components[:min(len(components), AccountDepth)] {
+			if component < lib.HardenedOffset {
+				return fmt.Errorf("%w: component %d of %s", ErrNonHardenedAboveAccount, depth+1, derivationPath)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckCoinTypeLevel returns an error wrapping ErrCoinTypeNotAllowed when the coin type level of
+// derivationPath is not the hardened coinType, the one the user was authorized for
+func CheckCoinTypeLevel(derivationPath string, coinType uint16) error {
+	components, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return err
+	}
+	if len(components) <= coinTypeLevel || components[coinTypeLevel] != lib.HardenedOffset+uint32(coinType) {
+		return fmt.Errorf("%w: %s is not a path of coinType %d", ErrCoinTypeNotAllowed, derivationPath, coinType)
+	}
+	return nil
+}
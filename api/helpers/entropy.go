@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/entropy"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrEntropyAugmentationUnavailable = errors.New(
+		"entropy augmentation is not available to the mount, it needs Vault Enterprise with external entropy access")
+	ErrEntropyUnhealthy = errors.New("entropy source failed its health check, mnemonics are not generated")
+)
+
+// EntropyConfig -- the source of the entropy of the mnemonics generated by the mount
+type EntropyConfig struct {
+	Source string `json:"source"`
+}
+
+// GetEntropyConfig reads the entropy configuration of the mount, crypto/rand when none is stored
+func GetEntropyConfig(ctx context.Context, s logical.Storage) (*EntropyConfig, error) {
+	entropyConfig := EntropyConfig{Source: entropy.SourceCryptoRand}
+	entry, err := s.Get(ctx, config.EntropyStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return &entropyConfig, nil
+	}
+	if err := entry.DecodeJSON(&entropyConfig); err != nil {
+		return nil, err
+	}
+	return &entropyConfig, nil
+}
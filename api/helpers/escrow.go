@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrEscrowKeyRequired = errors.New("publicKey is required to enable escrow")
+	ErrEscrowDisabled    = errors.New("escrow is not enabled on config/escrow")
+)
+
+// EscrowRecord -- the mnemonic and passphrase of a user encrypted to the custodian key KeyID
+type EscrowRecord struct {
+	UUID       string    `json:"uuid"`
+	KeyID      string    `json:"keyId"`
+	Algorithm  string    `json:"algorithm"`
+	Ciphertext string    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// GetEscrowRecord reads the escrow record of uuid, returning nil when it has none
+func GetEscrowRecord(ctx context.Context, s logical.Storage, uuid string) (*EscrowRecord, error) {
+	entry, err := s.Get(ctx, config.EscrowStoragePath+uuid)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var record EscrowRecord
+	if err := entry.DecodeJSON(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// PutEscrowRecord stores record, replacing the previous record of its user
+func PutEscrowRecord(ctx context.Context, s logical.Storage, record *EscrowRecord) error {
+	entry, err := logical.StorageEntryJSON(config.EscrowStoragePath+record.UUID, record)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteEscrowRecord removes the escrow record of uuid
+func DeleteEscrowRecord(ctx context.Context, s logical.Storage, uuid string) error {
+	return s.Delete(ctx, config.EscrowStoragePath+uuid)
+}
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+)
+
+// KafkaConfig -- the Kafka topic the register, sign and policy events of the mount are published
+// to. The password is never returned.
+type KafkaConfig struct {
+	Brokers       []string `json:"brokers"`
+	Topic         string   `json:"topic"`
+	SASLMechanism string   `json:"saslMechanism,omitempty"`
+	Username      string   `json:"username,omitempty"`
+	Password      string   `json:"password,omitempty"`
+	TLS           bool     `json:"tls"`
+	CACert        string   `json:"caCert,omitempty"`
+}
+
+// Options returns the producer options of the config
+func (c *KafkaConfig) Options() eventsink.KafkaOptions {
+	return eventsink.KafkaOptions{
+		Brokers:       c.Brokers,
+		Topic:         c.Topic,
+		SASLMechanism: c.SASLMechanism,
+		Username:      c.Username,
+		Password:      c.Password,
+		TLS:           c.TLS,
+		CACert:        c.CACert,
+	}
+}
+
+// GetKafkaConfig reads the Kafka sink of the mount, returning nil when none is configured
+func GetKafkaConfig(ctx context.Context, s logical.Storage) (*KafkaConfig, error) {
+	entry, err := s.Get(ctx, config.KafkaStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var kafka KafkaConfig
+	if err := entry.DecodeJSON(&kafka); err != nil {
+		return nil, err
+	}
+	return &kafka, nil
+}
+
+// PutKafkaConfig stores the Kafka sink of the mount
+func PutKafkaConfig(ctx context.Context, s logical.Storage, kafka *KafkaConfig) error {
+	entry, err := logical.StorageEntryJSON(config.KafkaStorageKey, kafka)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// GetQueuedEvent reads the queued event id, returning nil when it was published
+func GetQueuedEvent(ctx context.Context, s logical.Storage, id string) (*eventsink.Event, error) {
+	entry, err := s.Get(ctx, config.EventsStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var event eventsink.Event
+	if err := entry.DecodeJSON(&event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// QueueEvent stores event until it is published
+func QueueEvent(ctx context.Context, s logical.Storage, event *eventsink.Event) error {
+	entry, err := logical.StorageEntryJSON(config.EventsStoragePath+event.ID, event)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteQueuedEvent removes the event id from the queue, once published
+func DeleteQueuedEvent(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, config.EventsStoragePath+id)
+}
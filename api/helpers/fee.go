@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidFeeBounds = errors.New("min and max must be non-negative, with min at most max and max positive")
+	ErrFeeOutOfBounds   = errors.New("fee rate is outside the bounds of config/fees, set overrideFee to sign it")
+)
+
+// FeeBounds -- the fee rates accepted for the sign payloads of a coin type, in the unit of the
+// chain: gwei of gas price for EVM chains, sat/vB for Bitcoin
+type FeeBounds struct {
+	CoinType uint16  `json:"coinType"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+// Contains reports whether rate is within the bounds
+func (f *FeeBounds) Contains(rate float64) bool {
+	return rate >= f.Min && rate <= f.Max
+}
+
+// GetFeeBounds reads the bounds of coinType, returning nil when none are configured
+func GetFeeBounds(ctx context.Context, s logical.Storage, coinType uint16) (*FeeBounds, error) {
+	entry, err := s.Get(ctx, feeBoundsKey(coinType))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var bounds FeeBounds
+	if err := entry.DecodeJSON(&bounds); err != nil {
+		return nil, err
+	}
+	return &bounds, nil
+}
+
+// PutFeeBounds stores bounds
+func PutFeeBounds(ctx context.Context, s logical.Storage, bounds *FeeBounds) error {
+	entry, err := logical.StorageEntryJSON(feeBoundsKey(bounds.CoinType), bounds)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteFeeBounds removes the bounds of coinType
+func DeleteFeeBounds(ctx context.Context, s logical.Storage, coinType uint16) error {
+	return s.Delete(ctx, feeBoundsKey(coinType))
+}
+
+func feeBoundsKey(coinType uint16) string {
+	return config.FeeBoundsStoragePath + strconv.Itoa(int(coinType))
+}
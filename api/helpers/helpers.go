@@ -4,28 +4,265 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"time"
 
-	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/storage"
 	"github.com/payment-system/dq-vault/config"
 	"github.com/rs/xid"
 )
 
 // Static error variables to avoid dynamic error creation
 var (
-	ErrInvalidUUID      = errors.New("provide a valid UUID")
-	ErrInvalidPath      = errors.New("provide a valid path")
-	ErrUUIDDoesNotExist = errors.New("UUID does not exists")
-	ErrUnknownFields    = errors.New("unknown fields provided")
+	ErrInvalidUUID         = errors.New("provide a valid UUID")
+	ErrInvalidPath         = errors.New("provide a valid path")
+	ErrUUIDDoesNotExist    = errors.New("UUID does not exists")
+	ErrUnknownFields       = errors.New("unknown fields provided")
+	ErrUserNotFound        = errors.New("user record not found")
+	ErrFeatureDisabled     = errors.New("feature is disabled on this mount")
+	ErrUnsupportedCoinType = errors.New("unsupported coinType")
+	ErrInvalidDebugTTL     = errors.New("debug capture ttl must be positive and at most 24h")
+	ErrInvalidStorageType  = errors.New("storage type must be vault or postgres")
+	ErrInvalidAccount      = errors.New("account must be between 0 and 2147483647")
+	ErrNoExtendedKey       = errors.New("coinType has no extended public key: its curve only has hardened derivation")
+	ErrInvalidSafeField    = errors.New("invalid Safe transaction field")
+	ErrInvalidUserOpField  = errors.New("invalid user operation field")
+	ErrInvalidPermitField  = errors.New("invalid permit field")
+	ErrInvalidEVMTxField   = errors.New("invalid EVM transaction field")
+	ErrInvalidTransfer     = errors.New("invalid transfer field")
+	ErrInvalidFeePref      = errors.New("feePreference must be low, medium or high")
+	ErrMemoUnsupported     = errors.New("coinType has no memo, send to an address needing none")
+	ErrNoFeeEstimate       = errors.New("the node returned no fee estimate")
+	ErrNoRPCEndpoint       = errors.New("no rpc endpoint is configured for coinType")
+	ErrInvalidRPCEndpoint  = errors.New("maxRetries must not be negative and timeout must be positive")
+	ErrInvalidBlockhashAge = errors.New("maxBlockhashAge must be between 0 and 150 blocks, on Solana coinTypes only")
+	ErrStaleBlockhash      = errors.New("the recent blockhash of the message is older than maxBlockhashAge")
+	ErrNoBroadcast         = errors.New("coinType has no broadcast support")
+	ErrInvalidQuota        = errors.New("maxBatchCount must be positive and maxConcurrentRequests not negative")
+	ErrBatchTooLarge       = errors.New("count exceeds the maxBatchCount quota of the mount")
+	ErrInvalidCacheConfig  = errors.New("maxEntries and ttl of the cache must be positive")
+	ErrTooManyRequests     = errors.New("too many concurrent requests, retry later")
+	ErrInvalidQueue        = errors.New("maxQueueDepth and maxQueueWait must not be negative")
+	ErrInvalidFieldLimit   = errors.New("maxFieldLengths must give positive lengths of mnemonic, passphrase, " +
+		"username, payload or pathTemplate")
+	ErrQueueTimeout        = errors.New("timed out waiting in the request queue, retry later")
+	ErrInvalidSignedTx     = errors.New("invalid signed transaction")
+	ErrNoCompletion        = errors.New("coinType has no payload completion")
+	ErrCompletePayload     = errors.New("unable to complete the payload")
+	ErrPathAndPreset       = errors.New("path and preset cannot both be given")
+	ErrVanityNotDev        = errors.New("address/vanity only searches development mode addresses, isDev must be true")
+	ErrInvalidVanity       = errors.New("prefix must be 1 to 16 letters and digits and pathTemplate hold one %d")
+	ErrVanityNotFound      = errors.New("no address of the indexes searched has the prefix")
+	ErrInvalidRetention    = errors.New("debugCaptureMaxAge and debugCaptureMaxCount must be positive")
+	ErrNegativeRetention   = errors.New("the maximum ages and counts of retention must not be negative")
+	ErrInvalidStoredConfig = errors.New("stored configuration is invalid, fix or delete the config paths")
+	ErrEmptyBatch          = errors.New("items must not be empty")
+	ErrInvalidBatchItem    = errors.New("batch item must be an object of sign fields")
+	ErrDigestOrMessage     = errors.New("exactly one of digest and message must be given")
+	ErrPreimageRequired    = errors.New("the digest policy of the mount requires the message instead of the digest")
+	ErrInvalidStrength     = errors.New("strength must be 128, 160, 192, 224 or 256 bits")
 )
 
-// User -- stores data related to user
-type User struct {
-	Username   string `json:"username"`
-	UUID       string `json:"uuid"`
-	Mnemonic   string `json:"mnemonic"`
-	Passphrase string `json:"passphrase"`
+// Features -- stores the feature flags of the mount; every flag gating a risky subsystem defaults
+// to disabled, see DefaultFeatures
+type Features struct {
+	SignDigestEnabled bool `json:"signDigestEnabled"`
+	// APIKeysRequired rejects address and sign requests without a valid apiKey
+	APIKeysRequired bool `json:"apiKeysRequired"`
+	// BroadcastEnabled lets the broadcast endpoint relay signed transactions to the config/rpc nodes
+	BroadcastEnabled bool `json:"broadcastEnabled"`
+	// ExportEnabled lets the export paths return the public half of the user accounts
+	ExportEnabled bool `json:"exportEnabled"`
+	// AddressIndexEnabled records the addresses derived by the address paths for lookup/address
+	AddressIndexEnabled bool `json:"addressIndexEnabled"`
+	// CompatVerifyEnabled lets compat/verify derive from a mnemonic given in the request, on development mounts
+	CompatVerifyEnabled bool `json:"compatVerifyEnabled"`
+	// DigestPreimageRequired makes sign/digest hash the pre-image itself and refuse the recognized
+	// transaction pre-images, sign/digest/override is left unchecked
+	DigestPreimageRequired bool `json:"digestPreimageRequired"`
+	// EncryptedResponsesRequired refuses the requests returning secrets without a responsePublicKey
+	EncryptedResponsesRequired bool `json:"encryptedResponsesRequired"`
+	// DevVectorsEnabled lets dev/vectors return the test users and their addresses, on development mounts
+	DevVectorsEnabled bool `json:"devVectorsEnabled"`
+	// VanityAddressEnabled lets address/vanity search the derivation indexes, on development mounts
+	VanityAddressEnabled bool `json:"vanityAddressEnabled"`
+	// MnemonicGenEnabled lets gen/mnemonic return fresh mnemonics without registering a user
+	MnemonicGenEnabled bool `json:"mnemonicGenEnabled"`
+}
+
+// DefaultFeatures returns the feature flags of a mount that never configured them. Only the export
+// of public material is enabled.
+func DefaultFeatures() *Features {
+	return &Features{ExportEnabled: true}
+}
+
+// DefaultMaxBatchCount is the maximum count of address/batch and sign/batch on a mount that never configured its quotas
+const DefaultMaxBatchCount = 1000
+
+// Quotas -- stores the request quotas protecting the Vault node from misbehaving clients
+type Quotas struct {
+	// MaxBatchCount bounds the count of address/batch and the items of sign/batch
+	MaxBatchCount int `json:"maxBatchCount"`
+	// MaxConcurrentRequests bounds the key operations served at once by the node, 0 is unlimited
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
+	// Queue queues the key operations beyond MaxConcurrentRequests by priority class instead of
+	// rejecting them, up to MaxQueueDepth waiting for at most MaxQueueWait; 0 is the default
+	Queue         bool          `json:"queue,omitempty"`
+	MaxQueueDepth int           `json:"maxQueueDepth,omitempty"`
+	MaxQueueWait  time.Duration `json:"maxQueueWait,omitempty"`
+	// MaxFieldLengths overrides DefaultMaxFieldLengths by field name
+	MaxFieldLengths map[string]int `json:"maxFieldLengths,omitempty"`
+}
+
+// Defaults of the request queue of config/quotas
+const (
+	DefaultMaxQueueDepth = 1000
+	DefaultMaxQueueWait  = 30 * time.Second
+)
+
+// QueueDepth returns the number of key operations that may wait for a slot
+func (q *Quotas) QueueDepth() int {
+	if q.MaxQueueDepth == 0 {
+		return DefaultMaxQueueDepth
+	}
+	return q.MaxQueueDepth
+}
+
+// QueueWait returns how long a key operation waits for a slot
+func (q *Quotas) QueueWait() time.Duration {
+	if q.MaxQueueWait == 0 {
+		return DefaultMaxQueueWait
+	}
+	return q.MaxQueueWait
+}
+
+// Queued reports whether the key operations beyond MaxConcurrentRequests wait for a slot
+func (q *Quotas) Queued() bool {
+	return q.Queue && q.MaxConcurrentRequests > 0
+}
+
+// DefaultMaxFieldLengths are the maximum lengths in bytes of the free-form string fields of the
+// requests, checked before the request is decoded
+//
+//nolint:gochecknoglobals // read-only lookup table
+var DefaultMaxFieldLengths = map[string]int{
+	"mnemonic":     1024,
+	"passphrase":   1024,
+	"username":     256,
+	"payload":      1 << 20,
+	"pathTemplate": 256,
+}
+
+// FieldLengths returns the maximum length of each limited field, the defaults overridden by MaxFieldLengths
+func (q *Quotas) FieldLengths() map[string]int {
+	lengths := maps.Clone(DefaultMaxFieldLengths)
+	for name, length := range q.MaxFieldLengths {
+		lengths[name] = length
+	}
+	return lengths
+}
+
+// Defaults of the user record cache of a mount that never configured it
+const (
+	DefaultCacheMaxEntries = 1024
+	DefaultCacheTTL        = 5 * time.Minute
+)
+
+// Defaults of the retention of a mount that never configured it: the longest debug session and
+// the day its captures were always kept after it, and the day the finished jobs were always kept
+const (
+	DefaultDebugCaptureMaxAge   = 48 * time.Hour
+	DefaultDebugCaptureMaxCount = 10000
+	DefaultJobMaxAge            = 24 * time.Hour
+)
+
+// RetentionConfig -- stores how long each class of records is kept, by age and by count across the
+// mount, before the periodic function prunes them: the debug captures, the signing receipts, the
+// finished async jobs and the deletions of the replication journal. A zero age or count of the
+// last three leaves them unbounded by it.
+type RetentionConfig struct {
+	DebugCaptureMaxAge   time.Duration `json:"debugCaptureMaxAge"`
+	DebugCaptureMaxCount int           `json:"debugCaptureMaxCount"`
+	ReceiptMaxAge        time.Duration `json:"receiptMaxAge"`
+	ReceiptMaxCount      int           `json:"receiptMaxCount"`
+	JobMaxAge            time.Duration `json:"jobMaxAge"`
+	JobMaxCount          int           `json:"jobMaxCount"`
+	JournalMaxAge        time.Duration `json:"journalMaxAge"`
+	JournalMaxCount      int           `json:"journalMaxCount"`
+}
+
+// Validate checks the bounds of the retention
+func (r *RetentionConfig) Validate() error {
+	if r.DebugCaptureMaxAge <= 0 || r.DebugCaptureMaxCount <= 0 {
+		return ErrInvalidRetention
+	}
+	for _, bound := range []int64{int64(r.ReceiptMaxAge), int64(r.ReceiptMaxCount), int64(r.JobMaxAge),
+		int64(r.JobMaxCount), int64(r.JournalMaxAge), int64(r.JournalMaxCount)} {
+		if bound < 0 {
+			return ErrNegativeRetention
+		}
+	}
+	return nil
+}
+
+// CacheConfig -- stores the in-memory cache of the decrypted user records. It is disabled by
+// default, as every cached record holds a mnemonic in the plugin memory.
+type CacheConfig struct {
+	Enabled    bool          `json:"enabled"`
+	MaxEntries int           `json:"maxEntries"`
+	TTL        time.Duration `json:"ttl"`
+}
+
+// AttestationConfig -- stores the Ed25519 key the responses of the mount are attested with.
+// The key is generated by the plugin and only its public half is returned.
+type AttestationConfig struct {
+	Enabled    bool   `json:"enabled"`
+	PrivateKey []byte `json:"privateKey,omitempty"`
+}
+
+// EscrowConfig -- stores the hex X25519 public key of the custodian the mnemonics registered on the
+// mount are escrowed to
+type EscrowConfig struct {
+	Enabled   bool   `json:"enabled"`
+	PublicKey string `json:"publicKey"`
+}
+
+// LoggingConfig -- stores the logging configuration of the mount
+type LoggingConfig struct {
+	Level string `json:"level"`
+}
+
+// StorageConfig -- stores where the user records of the mount are persisted.
+// The encryption keys are generated by the plugin and never returned; the previous key is only
+// kept while a rotation re-encrypts the records written under it. Records in the Vault storage
+// are encrypted once migrate/encrypt has run, see VaultEncryption. With SplitPassphrase the
+// passphrases of the records written are kept apart from them, in the Vault storage.
+type StorageConfig struct {
+	Type                  string `json:"type"`
+	ConnectionURL         string `json:"connectionURL,omitempty"`
+	Table                 string `json:"table,omitempty"`
+	EncryptionKey         []byte `json:"encryptionKey,omitempty"`
+	PreviousEncryptionKey []byte `json:"previousEncryptionKey,omitempty"`
+	VaultEncryption       string `json:"vaultEncryption,omitempty"`
+	SplitPassphrase       bool   `json:"splitPassphrase,omitempty"`
+}
+
+// Encryption states of the user records kept in the Vault storage
+const (
+	// VaultEncryptionMigrating -- records are being encrypted, those still in clear are read as they are
+	VaultEncryptionMigrating = "migrating"
+	// VaultEncryptionEnabled -- every record is encrypted
+	VaultEncryptionEnabled = "enabled"
+)
+
+// Encrypted reports whether every user record of the configured store is encrypted under EncryptionKey
+func (c *StorageConfig) Encrypted() bool {
+	if c.Type == storage.TypeVault {
+		return c.VaultEncryption == VaultEncryptionEnabled
+	}
+	return len(c.EncryptionKey) > 0
 }
 
 // NewUUID returns a globally unique random generated guid
@@ -44,23 +281,6 @@ func ValidationErr(msg string) error {
 	return logical.CodedError(http.StatusUnprocessableEntity, msg)
 }
 
-// ValidateFields verifies that no bad arguments were given to the request.
-func ValidateFields(req *logical.Request, data *framework.FieldData) error {
-	var unknownFields []string
-	for k := range req.Data {
-		if _, ok := data.Schema[k]; !ok {
-			unknownFields = append(unknownFields, k)
-		}
-	}
-
-	// Fix SA4010: Use the unknownFields slice properly
-	if len(unknownFields) > 0 {
-		return fmt.Errorf("%w: %v", ErrUnknownFields, unknownFields)
-	}
-
-	return nil
-}
-
 // errorString is a trivial implementation of error.
 type errorString struct {
 	s string
@@ -107,3 +327,146 @@ func UUIDExists(ctx context.Context, req *logical.Request, uuid string) bool {
 	}
 	return false
 }
+
+// GetFeatures reads the feature flags of the mount, returning the defaults when none are stored
+func GetFeatures(ctx context.Context, req *logical.Request) (*Features, error) {
+	entry, err := req.Storage.Get(ctx, config.FeaturesStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// flags added after the entry was stored keep their default
+	features := DefaultFeatures()
+	if entry == nil {
+		return features, nil
+	}
+	if err := entry.DecodeJSON(features); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// GetLoggingConfig reads the logging configuration of the mount, defaulting to the INFO level.
+// It takes the storage rather than the request so it can run when the backend initializes.
+func GetLoggingConfig(ctx context.Context, s logical.Storage) (*LoggingConfig, error) {
+	entry, err := s.Get(ctx, config.LoggingStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	loggingConfig := LoggingConfig{Level: config.Info}
+	if entry == nil {
+		return &loggingConfig, nil
+	}
+	if err := entry.DecodeJSON(&loggingConfig); err != nil {
+		return nil, err
+	}
+	return &loggingConfig, nil
+}
+
+// GetQuotas reads the request quotas of the mount, returning the defaults when none are stored
+func GetQuotas(ctx context.Context, s logical.Storage) (*Quotas, error) {
+	entry, err := s.Get(ctx, config.QuotasStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	quotas := Quotas{MaxBatchCount: DefaultMaxBatchCount}
+	if entry == nil {
+		return &quotas, nil
+	}
+	if err := entry.DecodeJSON(&quotas); err != nil {
+		return nil, err
+	}
+	return &quotas, nil
+}
+
+// GetCacheConfig reads the user record cache configuration of the mount, returning the defaults
+// when none is stored
+func GetCacheConfig(ctx context.Context, s logical.Storage) (*CacheConfig, error) {
+	entry, err := s.Get(ctx, config.UserCacheStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheConfig := CacheConfig{MaxEntries: DefaultCacheMaxEntries, TTL: DefaultCacheTTL}
+	if entry == nil {
+		return &cacheConfig, nil
+	}
+	if err := entry.DecodeJSON(&cacheConfig); err != nil {
+		return nil, err
+	}
+	return &cacheConfig, nil
+}
+
+// GetRetentionConfig reads the retention configuration of the mount, returning the defaults when none is stored
+func GetRetentionConfig(ctx context.Context, s logical.Storage) (*RetentionConfig, error) {
+	entry, err := s.Get(ctx, config.RetentionStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := RetentionConfig{
+		DebugCaptureMaxAge:   DefaultDebugCaptureMaxAge,
+		DebugCaptureMaxCount: DefaultDebugCaptureMaxCount,
+		JobMaxAge:            DefaultJobMaxAge,
+	}
+	if entry == nil {
+		return &retention, nil
+	}
+	if err := entry.DecodeJSON(&retention); err != nil {
+		return nil, err
+	}
+	return &retention, nil
+}
+
+// GetAttestationConfig reads the attestation configuration of the mount, disabled when none is stored
+func GetAttestationConfig(ctx context.Context, s logical.Storage) (*AttestationConfig, error) {
+	entry, err := s.Get(ctx, config.AttestationStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var attestationConfig AttestationConfig
+	if entry == nil {
+		return &attestationConfig, nil
+	}
+	if err := entry.DecodeJSON(&attestationConfig); err != nil {
+		return nil, err
+	}
+	return &attestationConfig, nil
+}
+
+// GetEscrowConfig reads the escrow configuration of the mount, disabled when none is stored
+func GetEscrowConfig(ctx context.Context, s logical.Storage) (*EscrowConfig, error) {
+	entry, err := s.Get(ctx, config.EscrowStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var escrowConfig EscrowConfig
+	if entry == nil {
+		return &escrowConfig, nil
+	}
+	if err := entry.DecodeJSON(&escrowConfig); err != nil {
+		return nil, err
+	}
+	return &escrowConfig, nil
+}
+
+// GetStorageConfig reads the user store configuration of the mount, defaulting to the Vault storage
+func GetStorageConfig(ctx context.Context, s logical.Storage) (*StorageConfig, error) {
+	entry, err := s.Get(ctx, config.UserStoreStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	storageConfig := StorageConfig{Type: storage.TypeVault}
+	if entry == nil {
+		return &storageConfig, nil
+	}
+	if err := entry.DecodeJSON(&storageConfig); err != nil {
+		return nil, err
+	}
+	return &storageConfig, nil
+}
@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// HookChain -- the ordered payload hooks run on the sign payloads of a coin type
+type HookChain struct {
+	CoinType uint16   `json:"coinType"`
+	Hooks    []string `json:"hooks"`
+}
+
+// GetHookChain reads the hook chain of coinType, returning nil when none is configured
+func GetHookChain(ctx context.Context, s logical.Storage, coinType uint16) (*HookChain, error) {
+	entry, err := s.Get(ctx, hookChainKey(coinType))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var chain HookChain
+	if err := entry.DecodeJSON(&chain); err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
+
+// PutHookChain stores chain
+func PutHookChain(ctx context.Context, s logical.Storage, chain *HookChain) error {
+	entry, err := logical.StorageEntryJSON(hookChainKey(chain.CoinType), chain)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteHookChain removes the hook chain of coinType
+func DeleteHookChain(ctx context.Context, s logical.Storage, coinType uint16) error {
+	return s.Delete(ctx, hookChainKey(coinType))
+}
+
+func hookChainKey(coinType uint16) string {
+	return config.HooksStoragePath + strconv.Itoa(int(coinType))
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Statuses of an async job
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobPartial  = "partial"
+	JobComplete = "complete"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// JobTypeSignBatch is the type of the jobs of the async sign/batch requests
+const JobTypeSignBatch = "sign/batch"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrJobFinished  = errors.New("the job is finished")
+	ErrJobNotDone   = errors.New("the job is not finished, cancel it first")
+	ErrAsyncBatchID = errors.New("batchId does not apply to async batches, their job resumes by itself")
+)
+
+// Job -- an async job and its checkpoint: Results holds the results of the items processed so
+// far, in their order, the job resumes with the next item
+type Job struct {
+	ID              string                   `json:"id"`
+	Type            string                   `json:"type"`
+	EntityID        string                   `json:"entityId"`
+	Status          string                   `json:"status"`
+	Items           []interface{}            `json:"items"`
+	APIKey          string                   `json:"apiKey,omitempty"`
+	FailFast        bool                     `json:"failFast"`
+	Results         []map[string]interface{} `json:"results"`
+	CancelRequested bool                     `json:"cancelRequested"`
+	CreatedAt       time.Time                `json:"createdAt"`
+	UpdatedAt       time.Time                `json:"updatedAt"`
+	CompletedAt     time.Time                `json:"completedAt,omitzero"`
+}
+
+// Finished reports whether the job reached a final status
+func (j *Job) Finished() bool {
+	return j.Status != JobQueued && j.Status != JobRunning
+}
+
+// GetJob reads the job id, returning nil when it does not exist
+func GetJob(ctx context.Context, s logical.Storage, id string) (*Job, error) {
+	entry, err := s.Get(ctx, config.JobsStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var job Job
+	if err := entry.DecodeJSON(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// PutJob stores job
+func PutJob(ctx context.Context, s logical.Storage, job *Job) error {
+	entry, err := logical.StorageEntryJSON(config.JobsStoragePath+job.ID, job)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteJob removes the job id
+func DeleteJob(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, config.JobsStoragePath+id)
+}
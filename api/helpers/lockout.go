@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+const (
+	// DefaultLockoutWindow is how far back the policy violations of a user are counted by default
+	DefaultLockoutWindow = time.Hour
+	// MaxViolationNotes is the number of policy violations kept per user, the oldest are dropped
+	MaxViolationNotes = 50
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidLockout = errors.New("maxViolations must be 0, disabled, or positive with a positive window")
+)
+
+// LockoutPolicy -- the number of policy violations within the window locking a user; 0 only notes them
+type LockoutPolicy struct {
+	MaxViolations int           `json:"maxViolations"`
+	Window        time.Duration `json:"window"`
+}
+
+// ViolationNote -- a request of a user rejected by a policy of the mount
+type ViolationNote struct {
+	At       time.Time `json:"at"`
+	Kind     string    `json:"kind"`
+	Path     string    `json:"path"`
+	EntityID string    `json:"entityId,omitempty"`
+	// Error is the rejection returned, redacted
+	Error string `json:"error"`
+}
+
+// UserViolations -- the most recent policy violations of a user. Those before CountFrom, set when
+// the user is unlocked, no longer count towards the lockout.
+type UserViolations struct {
+	Notes     []ViolationNote `json:"notes"`
+	CountFrom time.Time       `json:"countFrom,omitzero"`
+}
+
+// GetLockoutPolicy reads the lockout policy of the mount, only noting the violations when none is stored
+func GetLockoutPolicy(ctx context.Context, s logical.Storage) (*LockoutPolicy, error) {
+	entry, err := s.Get(ctx, config.LockoutStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := LockoutPolicy{Window: DefaultLockoutWindow}
+	if entry == nil {
+		return &policy, nil
+	}
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks the bounds of the policy
+func (p *LockoutPolicy) Validate() error {
+	if p.MaxViolations < 0 || (p.MaxViolations > 0 && p.Window <= 0) {
+		return ErrInvalidLockout
+	}
+	return nil
+}
+
+// Add notes a violation, dropping the oldest beyond MaxViolationNotes
+func (v *UserViolations) Add(note ViolationNote) {
+	v.Notes = append(v.Notes, note)
+	if len(v.Notes) > MaxViolationNotes {
+		v.Notes = v.Notes[len(v.Notes)-MaxViolationNotes:]
+	}
+}
+
+// Count returns the number of violations counting towards the lockout at now
+func (v *UserViolations) Count(policy *LockoutPolicy, now time.Time) int {
+	since := now.Add(-policy.Window)
+	if v.CountFrom.After(since) {
+		since = v.CountFrom
+	}
+	count := 0
+	for _, note := range v.Notes {
+		if note.At.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// GetUserViolations reads the policy violations of uuid, returning none when nothing is stored
+func GetUserViolations(ctx context.Context, s logical.Storage, uuid string) (*UserViolations, error) {
+	entry, err := s.Get(ctx, config.ViolationsStoragePath+uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations UserViolations
+	if entry == nil {
+		return &violations, nil
+	}
+	if err := entry.DecodeJSON(&violations); err != nil {
+		return nil, err
+	}
+	return &violations, nil
+}
+
+// PutUserViolations stores the policy violations of uuid
+func PutUserViolations(ctx context.Context, s logical.Storage, uuid string, violations *UserViolations) error {
+	entry, err := logical.StorageEntryJSON(config.ViolationsStoragePath+uuid, violations)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteUserViolations removes the policy violations of uuid
+func DeleteUserViolations(ctx context.Context, s logical.Storage, uuid string) error {
+	return s.Delete(ctx, config.ViolationsStoragePath+uuid)
+}
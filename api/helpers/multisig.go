@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrMultisigExists   = errors.New("multisig wallet already exists, delete it to register new cosigners")
+	ErrMultisigNotFound = errors.New("multisig wallet not found")
+	ErrMultisigNetwork  = errors.New("isDev does not match the network of the multisig wallet")
+)
+
+// MultisigWallet -- a Bitcoin sortedmulti wallet of a user, combining the BIP-48 account key of the
+// user with the account keys of external cosigners. Keys are [<fingerprint>/<origin>]<xpub> expressions.
+type MultisigWallet struct {
+	Name       string    `json:"name"`
+	UUID       string    `json:"uuid"`
+	Threshold  int       `json:"threshold"`
+	ScriptType string    `json:"scriptType"`
+	Account    uint32    `json:"account"`
+	IsDev      bool      `json:"isDev"`
+	VaultKey   string    `json:"vaultKey"`
+	Cosigners  []string  `json:"cosigners"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Keys returns the key expressions of the wallet, the vault key first
+func (w *MultisigWallet) Keys() []string {
+	return append([]string{w.VaultKey}, w.Cosigners...)
+}
+
+// GetMultisigWallet reads the multisig wallet name of uuid, returning nil when it does not exist
+func GetMultisigWallet(ctx context.Context, s logical.Storage, uuid, name string) (*MultisigWallet, error) {
+	entry, err := s.Get(ctx, config.MultisigStoragePath+uuid+"/"+name)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var wallet MultisigWallet
+	if err := entry.DecodeJSON(&wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// PutMultisigWallet stores wallet
+func PutMultisigWallet(ctx context.Context, s logical.Storage, wallet *MultisigWallet) error {
+	entry, err := logical.StorageEntryJSON(config.MultisigStoragePath+wallet.UUID+"/"+wallet.Name, wallet)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteMultisigWallet removes the multisig wallet name of uuid
+func DeleteMultisigWallet(ctx context.Context, s logical.Storage, uuid, name string) error {
+	return s.Delete(ctx, config.MultisigStoragePath+uuid+"/"+name)
+}
@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/passphrase"
+)
+
+// Health issues reported by user/<uuid>/health
+const (
+	HealthWeakPassphrase   = "weak_passphrase"
+	HealthMissingVerifier  = "missing_passphrase_verifier"
+	HealthVerifierMismatch = "passphrase_verifier_mismatch"
+	HealthUserNotActive    = "user_not_active"
+)
+
+const (
+	// maxPolicyLength and maxPolicyEntropyBits bound the requirements of a passphrase policy
+	maxPolicyLength      = 1024
+	maxPolicyEntropyBits = 256
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrPassphraseTooShort      = errors.New("passphrase is shorter than the policy of the mount")
+	ErrPassphraseTooWeak       = errors.New("passphrase entropy estimate is below the policy of the mount")
+	ErrInvalidPassphrasePolicy = errors.New("minLength must be between 0 and 1024 and minEntropyBits between 0 and 256")
+)
+
+// PassphrasePolicy -- the strength the passphrases of new users must have, and the algorithm of
+// the verifier stored with them, none when empty. The zero policy accepts any passphrase.
+type PassphrasePolicy struct {
+	MinLength      int     `json:"minLength"`
+	MinEntropyBits float64 `json:"minEntropyBits"`
+	Verifier       string  `json:"verifier,omitempty"`
+}
+
+// Validate checks the bounds and the verifier algorithm of the policy
+func (p *PassphrasePolicy) Validate() error {
+	if p.MinLength < 0 || p.MinLength > maxPolicyLength ||
+		p.MinEntropyBits < 0 || p.MinEntropyBits > maxPolicyEntropyBits {
+		return ErrInvalidPassphrasePolicy
+	}
+	switch p.Verifier {
+	case "", passphrase.AlgorithmArgon2id, passphrase.AlgorithmScrypt:
+		return nil
+	default:
+		return passphrase.ErrUnsupportedAlgorithm
+	}
+}
+
+// Check rejects a passphrase shorter or weaker than the policy
+func (p *PassphrasePolicy) Check(value string) error {
+	if length := utf8.RuneCountInString(value); length < p.MinLength {
+		return fmt.Errorf("%w: %d characters, at least %d", ErrPassphraseTooShort, length, p.MinLength)
+	}
+	if bits := passphrase.EntropyBits(value); bits < p.MinEntropyBits {
+		return fmt.Errorf("%w: %g bits, at least %g", ErrPassphraseTooWeak, bits, p.MinEntropyBits)
+	}
+	return nil
+}
+
+// PassphraseHealth -- how the passphrase of a user fares against the policy of the mount and
+// against its verifier
+type PassphraseHealth struct {
+	Length      int
+	EntropyBits float64
+	MeetsPolicy bool
+	Verifier    string
+	// Verified is whether the stored passphrase matches the verifier, false without a verifier
+	Verified bool
+	Issues   []string
+}
+
+// PassphraseHealth checks the passphrase of u against policy and its verifier
+func (u *User) PassphraseHealth(policy *PassphrasePolicy) (*PassphraseHealth, error) {
+	health := &PassphraseHealth{
+		Length:      utf8.RuneCountInString(u.Passphrase),
+		EntropyBits: passphrase.EntropyBits(u.Passphrase),
+		MeetsPolicy: policy.Check(u.Passphrase) == nil,
+	}
+	if !health.MeetsPolicy {
+		health.Issues = append(health.Issues, HealthWeakPassphrase)
+	}
+
+	switch {
+	case u.PassphraseVerifier != nil:
+		verified, err := u.PassphraseVerifier.Verify(u.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		health.Verifier, health.Verified = u.PassphraseVerifier.Algorithm, verified
+		if !verified {
+			health.Issues = append(health.Issues, HealthVerifierMismatch)
+		}
+	case policy.Verifier != "":
+		health.Issues = append(health.Issues, HealthMissingVerifier)
+	}
+	return health, nil
+}
+
+// GetPassphrasePolicy reads the passphrase policy of the mount, the zero policy when none is stored
+func GetPassphrasePolicy(ctx context.Context, s logical.Storage) (*PassphrasePolicy, error) {
+	entry, err := s.Get(ctx, config.PassphrasePolicyStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	var policy PassphrasePolicy
+	if entry == nil {
+		return &policy, nil
+	}
+	if err := entry.DecodeJSON(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// PutPassphrasePolicy stores the passphrase policy of the mount
+func PutPassphrasePolicy(ctx context.Context, s logical.Storage, policy *PassphrasePolicy) error {
+	entry, err := logical.StorageEntryJSON(config.PassphrasePolicyStorageKey, policy)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
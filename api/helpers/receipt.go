@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Receipt -- the record of a signature of sign: which user key signed which payload, with which
+// memo, for which request, and the position of the signature in the sequence of the signatures of
+// the mount
+type Receipt struct {
+	ID            string    `json:"id"`
+	Sequence      uint64    `json:"sequence"`
+	UUID          string    `json:"uuid"`
+	CoinType      uint16    `json:"coinType"`
+	Path          string    `json:"path"`
+	Address       string    `json:"address"`
+	PayloadHash   string    `json:"payloadHash"`
+	SignatureHash string    `json:"signatureHash"`
+	ApprovalID    string    `json:"approvalId,omitempty"`
+	Memo          string    `json:"memo,omitempty"`
+	EntityID      string    `json:"entityId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// GetReceipt reads the receipt id, returning nil when it does not exist
+func GetReceipt(ctx context.Context, s logical.Storage, id string) (*Receipt, error) {
+	entry, err := s.Get(ctx, config.ReceiptsStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var receipt Receipt
+	if err := entry.DecodeJSON(&receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// PutReceipt stores receipt
+func PutReceipt(ctx context.Context, s logical.Storage, receipt *Receipt) error {
+	entry, err := logical.StorageEntryJSON(config.ReceiptsStoragePath+receipt.ID, receipt)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// NextReceiptSequence advances the receipt sequence of the mount and returns its new value, the
+// callers serialize it
+func NextReceiptSequence(ctx context.Context, s logical.Storage) (uint64, error) {
+	entry, err := s.Get(ctx, config.ReceiptSequenceStorageKey)
+	if err != nil {
+		return 0, err
+	}
+	var sequence uint64
+	if entry != nil {
+		if sequence, err = strconv.ParseUint(string(entry.Value), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	sequence++
+	err = s.Put(ctx, &logical.StorageEntry{
+		Key:   config.ReceiptSequenceStorageKey,
+		Value: []byte(strconv.FormatUint(sequence, 10)),
+	})
+	return sequence, err
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Statuses of the changes applied by replicate/apply
+const (
+	ReplicationApplied  = "applied"
+	ReplicationStale    = "stale"
+	ReplicationConflict = "conflict"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrReplicationDisabled     = errors.New("replication is not configured, see config/replication")
+	ErrInvalidReplicationKey   = errors.New("key must be 32 bytes hex encoded")
+	ErrInvalidReplicationLimit = errors.New("limit must be between 1 and 1000")
+	ErrInvalidReplicationSince = errors.New("since must be a sequence number")
+	ErrInvalidReplicatedChange = errors.New("changes must have a uuid, a version and, unless deleted, a record")
+	ErrReplicatedRecord        = errors.New("replicated record cannot be decrypted with the replication key")
+)
+
+// ReplicationConfig -- stores the key shared with the other cluster the user records are replicated
+// to or from. The writes of the user records are journaled while a key is set.
+type ReplicationConfig struct {
+	Key []byte `json:"key,omitempty"`
+}
+
+// ReplicatedChange -- a change of a user record carried between clusters, the record sealed under
+// the replication key
+type ReplicatedChange struct {
+	Seq     uint64 `json:"seq"`
+	UUID    string `json:"uuid"`
+	Version uint64 `json:"version"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Record  []byte `json:"record,omitempty"`
+}
+
+// GetReplicationConfig reads the replication configuration of the mount, without a key when none is stored
+func GetReplicationConfig(ctx context.Context, s logical.Storage) (*ReplicationConfig, error) {
+	entry, err := s.Get(ctx, config.ReplicationStorageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicationConfig ReplicationConfig
+	if entry == nil {
+		return &replicationConfig, nil
+	}
+	if err := entry.DecodeJSON(&replicationConfig); err != nil {
+		return nil, err
+	}
+	return &replicationConfig, nil
+}
+
+// Validate checks that the change can be applied
+func (c *ReplicatedChange) Validate() error {
+	if c.UUID == "" || strings.Contains(c.UUID, "/") || c.Version == 0 || (!c.Deleted && len(c.Record) == 0) {
+		return ErrInvalidReplicatedChange
+	}
+	return nil
+}
+
+// Seal sets the record of the change, encrypted under key. The uuid and version are authenticated
+// with it, so a record cannot be replayed as another user or version.
+func (c *ReplicatedChange) Seal(key, record []byte) error {
+	aead, err := storage.NewAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	c.Record = aead.Seal(nonce, nonce, record, c.additionalData())
+	return nil
+}
+
+// Open returns the record of the change, decrypted with key
+func (c *ReplicatedChange) Open(key []byte) ([]byte, error) {
+	aead, err := storage.NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Record) < aead.NonceSize() {
+		return nil, ErrReplicatedRecord
+	}
+	nonce, ciphertext := c.Record[:aead.NonceSize()], c.Record[aead.NonceSize():]
+	record, err := aead.Open(nil, nonce, ciphertext, c.additionalData())
+	if err != nil {
+		return nil, ErrReplicatedRecord
+	}
+	return record, nil
+}
+
+func (c *ReplicatedChange) additionalData() []byte {
+	return []byte(c.UUID + "/" + strconv.FormatUint(c.Version, 10))
+}
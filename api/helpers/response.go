@@ -0,0 +1,11 @@
+package helpers
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidResponseKey  = errors.New("responsePublicKey must be an X25519 public key, 32 bytes hex encoded")
+	ErrResponseKeyRequired = errors.New(
+		"responsePublicKey is required, the response carries secrets that are only returned encrypted")
+	ErrMnemonicNotGenerated = errors.New("only mnemonics generated by the engine can be exported")
+)
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// RPCEndpoint -- the chain node a coin type is relayed to. The URL may hold an API key and is
+// never logged, only its host.
+type RPCEndpoint struct {
+	CoinType   uint16        `json:"coinType"`
+	URL        string        `json:"url"`
+	MaxRetries int           `json:"maxRetries"`
+	Timeout    time.Duration `json:"timeout"`
+	// MaxBlockhashAge is the age in blocks above which the recent blockhash of a Solana sign
+	// payload is stale, 0 leaves it unchecked; stale blockhashes are warned of unless
+	// RejectStaleBlockhash
+	MaxBlockhashAge      int  `json:"maxBlockhashAge,omitempty"`
+	RejectStaleBlockhash bool `json:"rejectStaleBlockhash,omitempty"`
+}
+
+// MaxRecentBlockhashes is the number of blockhashes of the RecentBlockhashes sysvar of Solana, the
+// largest maxBlockhashAge; older blockhashes have expired
+const MaxRecentBlockhashes = 150
+
+// GetRPCEndpoint reads the endpoint of coinType, returning nil when none is configured
+func GetRPCEndpoint(ctx context.Context, s logical.Storage, coinType uint16) (*RPCEndpoint, error) {
+	entry, err := s.Get(ctx, rpcEndpointKey(coinType))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var endpoint RPCEndpoint
+	if err := entry.DecodeJSON(&endpoint); err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// PutRPCEndpoint stores endpoint
+func PutRPCEndpoint(ctx context.Context, s logical.Storage, endpoint *RPCEndpoint) error {
+	entry, err := logical.StorageEntryJSON(rpcEndpointKey(endpoint.CoinType), endpoint)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteRPCEndpoint removes the endpoint of coinType
+func DeleteRPCEndpoint(ctx context.Context, s logical.Storage, coinType uint16) error {
+	return s.Delete(ctx, rpcEndpointKey(coinType))
+}
+
+func rpcEndpointKey(coinType uint16) string {
+	return config.RPCStoragePath + strconv.Itoa(int(coinType))
+}
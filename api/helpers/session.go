@@ -0,0 +1,135 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidSessionToken = errors.New("invalid sessionToken")
+	ErrSessionExpired      = errors.New("signing session has expired")
+	ErrSessionExhausted    = errors.New("signing session has no operations left")
+	ErrSessionScope        = errors.New("signing session does not allow this path")
+	ErrInvalidSession      = errors.New("maxOperations and ttl of the session must be positive")
+)
+
+// SigningSession -- a short-lived capability to sign with the keys of one user under a path
+// prefix, delegated to a worker. Only the hash of the secret is stored.
+type SigningSession struct {
+	ID            string    `json:"id"`
+	SecretHash    string    `json:"secretHash"`
+	UUID          string    `json:"uuid"`
+	CoinType      uint16    `json:"coinType"`
+	PathPrefix    string    `json:"pathPrefix"`
+	MaxOperations int       `json:"maxOperations"`
+	Operations    int       `json:"operations"`
+	CreatedAt     time.Time `json:"createdAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// NewSigningSession creates a session with a random id and secret, returning the session and the
+// "<id>.<secret>" token callers send, which is not stored
+func NewSigningSession(uuid string, coinType uint16, pathPrefix string, maxOperations int,
+	ttl time.Duration) (*SigningSession, string, error) {
+	if maxOperations <= 0 || ttl <= 0 {
+		return nil, "", ErrInvalidSession
+	}
+	if pathPrefix == "" {
+		return nil, "", ErrInvalidPath
+	}
+
+	secret := make([]byte, apiKeySecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	secretHex := hex.EncodeToString(secret)
+
+	now := time.Now().UTC()
+	session := &SigningSession{
+		ID:            NewUUID(),
+		SecretHash:    hashAPIKeySecret(secretHex),
+		UUID:          uuid,
+		CoinType:      coinType,
+		PathPrefix:    strings.TrimSuffix(pathPrefix, "/"),
+		MaxOperations: maxOperations,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(ttl),
+	}
+	return session, session.ID + "." + secretHex, nil
+}
+
+// Remaining returns the operations left to the session
+func (s *SigningSession) Remaining() int {
+	return max(s.MaxOperations-s.Operations, 0)
+}
+
+// Authorize checks that the session allows one more operation with the key at derivationPath.
+// The path must be the prefix or below it, component wise.
+func (s *SigningSession) Authorize(derivationPath string, now time.Time) error {
+	if !now.Before(s.ExpiresAt) {
+		return ErrSessionExpired
+	}
+	if s.Remaining() == 0 {
+		return ErrSessionExhausted
+	}
+	if derivationPath != s.PathPrefix && !strings.HasPrefix(derivationPath, s.PathPrefix+"/") {
+		return fmt.Errorf("%w: %s", ErrSessionScope, derivationPath)
+	}
+	return nil
+}
+
+// GetSigningSession reads the session id, returning nil when it does not exist
+func GetSigningSession(ctx context.Context, s logical.Storage, id string) (*SigningSession, error) {
+	entry, err := s.Get(ctx, config.SigningSessionsStoragePath+id)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	var session SigningSession
+	if err := entry.DecodeJSON(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// PutSigningSession stores session
+func PutSigningSession(ctx context.Context, s logical.Storage, session *SigningSession) error {
+	entry, err := logical.StorageEntryJSON(config.SigningSessionsStoragePath+session.ID, session)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteSigningSession removes the session id
+func DeleteSigningSession(ctx context.Context, s logical.Storage, id string) error {
+	return s.Delete(ctx, config.SigningSessionsStoragePath+id)
+}
+
+// LookupSigningSession returns the stored session matching the "<id>.<secret>" token sent by a caller
+func LookupSigningSession(ctx context.Context, s logical.Storage, token string) (*SigningSession, error) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return nil, ErrInvalidSessionToken
+	}
+
+	session, err := GetSigningSession(ctx, s, id)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil ||
+		subtle.ConstantTimeCompare([]byte(session.SecretHash), []byte(hashAPIKeySecret(secret))) != 1 {
+		return nil, ErrInvalidSessionToken
+	}
+	return session, nil
+}
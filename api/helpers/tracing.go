@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/tracing"
+)
+
+// TracingConfig -- the OTLP/HTTP collector the spans of the requests are exported to. The headers
+// may hold credentials and only their names are returned.
+type TracingConfig struct {
+	Endpoint    string            `json:"endpoint"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	SampleRatio float64           `json:"sampleRatio"`
+	ServiceName string            `json:"serviceName,omitempty"`
+}
+
+// Options returns the exporter options of the config
+func (c *TracingConfig) Options() tracing.Options {
+	return tracing.Options{
+		Endpoint:    c.Endpoint,
+		Headers:     c.Headers,
+		SampleRatio: c.SampleRatio,
+		ServiceName: c.ServiceName,
+	}
+}
+
+// GetTracingConfig reads the collector of the mount, returning nil when none is configured
+func GetTracingConfig(ctx context.Context, s logical.Storage) (*TracingConfig, error) {
+	entry, err := s.Get(ctx, config.TracingStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var tracingConfig TracingConfig
+	if err := entry.DecodeJSON(&tracingConfig); err != nil {
+		return nil, err
+	}
+	return &tracingConfig, nil
+}
+
+// PutTracingConfig stores the collector of the mount
+func PutTracingConfig(ctx context.Context, s logical.Storage, tracingConfig *TracingConfig) error {
+	entry, err := logical.StorageEntryJSON(config.TracingStorageKey, tracingConfig)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
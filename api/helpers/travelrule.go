@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// TravelRuleConfig -- the compliance sink the travel rule data of the signed transfers is
+// forwarded to. The signing secret keys the HMAC of the forwarded bodies and is never returned.
+// Format and Template select the body, the event of the record by default.
+type TravelRuleConfig struct {
+	SinkURL       string `json:"sinkUrl"`
+	SigningSecret string `json:"signingSecret"`
+	Format        string `json:"format,omitempty"`
+	Template      string `json:"template,omitempty"`
+}
+
+// GetTravelRuleConfig reads the travel rule sink of the mount, returning nil when none is configured
+func GetTravelRuleConfig(ctx context.Context, s logical.Storage) (*TravelRuleConfig, error) {
+	entry, err := s.Get(ctx, config.TravelRuleStorageKey)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var travelRule TravelRuleConfig
+	if err := entry.DecodeJSON(&travelRule); err != nil {
+		return nil, err
+	}
+	return &travelRule, nil
+}
+
+// PutTravelRuleConfig stores the travel rule sink of the mount
+func PutTravelRuleConfig(ctx context.Context, s logical.Storage, travelRule *TravelRuleConfig) error {
+	entry, err := logical.StorageEntryJSON(config.TravelRuleStorageKey, travelRule)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// DeleteTravelRuleConfig removes the travel rule sink, the travel rule data is no longer forwarded
+func DeleteTravelRuleConfig(ctx context.Context, s logical.Storage) error {
+	return s.Delete(ctx, config.TravelRuleStorageKey)
+}
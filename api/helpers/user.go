@@ -0,0 +1,326 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/passphrase"
+)
+
+// UserSchemaVersion is the version of the user record written by this plugin.
+// Records without a version predate it and are migrated on read.
+const UserSchemaVersion = 2
+
+// namespaceDomain separates the hash of the UUID giving the index of a derivation namespace
+const namespaceDomain = "dq-vault/derivation-namespace/"
+
+// User statuses
+const (
+	UserStatusActive   = "active"
+	UserStatusDisabled = "disabled"
+	// UserStatusPending is the status of the users of register/init until register/confirm activates them
+	UserStatusPending = "pending"
+	// UserStatusLocked is the status of the users locked by the policy violations of config/lockout
+	// until user/<uuid>/unlock
+	UserStatusLocked = "locked"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUserNotActive      = errors.New("user is not active")
+	ErrCoinTypeNotAllowed = errors.New("coinType is not allowed for this user")
+	ErrNotOwnerEntity     = errors.New("user is restricted to the entity that created it")
+	ErrNoRequestEntity    = errors.New("restrictToOwner requires a token backed by a Vault entity")
+	ErrNotManagerEntity   = errors.New("user can only be disabled, deleted or rotated by the entity that created it or its managers")
+	ErrNoManagementEntity = errors.New("restrictManagement requires a token backed by a Vault entity")
+	ErrUserExpired        = errors.New("user has expired and cannot be enabled")
+	ErrInvalidTTL         = errors.New("ttl must be positive")
+	ErrWatchOnlyUser      = errors.New("watch-only user: no private key to sign with")
+	ErrPrivateXpub        = errors.New("xpub must be an extended public key")
+	ErrXpubPathDepth      = errors.New("xpubPath does not match the depth of the xpub")
+	ErrInvalidFingerprint = errors.New("fingerprint must be 4 bytes hex encoded")
+	ErrXpubWithMnemonic   = errors.New("xpub cannot be registered with a mnemonic or passphrase")
+	ErrUserPending        = errors.New("user is pending, confirm it with register/confirm or delete it")
+	ErrUserNotPending     = errors.New("user is not pending the confirmation of its registration")
+	ErrWrongFingerprint   = errors.New("fingerprint does not match the one of the pending registration")
+	ErrNamespaceWatchOnly = errors.New("derivationNamespace needs a user registered with a mnemonic")
+	ErrVersionRequired    = errors.New("version of the user read is required")
+	ErrStaleVersion       = errors.New("user was changed since it was read, read it again")
+	ErrExpectedAddress    = errors.New("expectedAddress, expectedCoinType and expectedPath must be given together")
+	ErrAddressMismatch    = errors.New("the keys registered do not derive expectedAddress at expectedPath")
+	ErrUserLocked         = errors.New("user is locked by its policy violations, unlock it with user/<uuid>/unlock")
+	ErrUserNotLocked      = errors.New("user is not locked")
+)
+
+// User -- stores data related to user
+type User struct {
+	Username   string `json:"username"`
+	UUID       string `json:"uuid"`
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase"`
+
+	SchemaVersion int       `json:"schemaVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	Status        string    `json:"status"`
+	// Version counts the writes of the record, for the management operations to reject changes
+	// made from a stale read; records written before it are version 0
+	Version uint64 `json:"version,omitempty"`
+	// Fingerprint is the BIP-32 master key fingerprint of the seed, safe to share
+	Fingerprint string `json:"fingerprint"`
+	// AllowedCoinTypes restricts the coin types the user may derive and sign for; empty allows all
+	AllowedCoinTypes []uint16 `json:"allowedCoinTypes,omitempty"`
+
+	// OwnerEntityID, OwnerDisplayName and OwnerAccessor identify the Vault entity and token that registered the user
+	OwnerEntityID    string `json:"ownerEntityId,omitempty"`
+	OwnerDisplayName string `json:"ownerDisplayName,omitempty"`
+	OwnerAccessor    string `json:"ownerAccessor,omitempty"`
+	// RestrictToOwner limits key operations to requests made by OwnerEntityID, on top of the path ACLs
+	RestrictToOwner bool `json:"restrictToOwner,omitempty"`
+	// RestrictManagement limits the destructive operations on the user, disabling, deleting and rotating
+	// its escrow, to requests made by OwnerEntityID or one of ManagerEntityIDs
+	RestrictManagement bool     `json:"restrictManagement,omitempty"`
+	ManagerEntityIDs   []string `json:"managerEntityIds,omitempty"`
+
+	// ExpiresAt is when the periodic function disables the user, zero for users that never expire
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	// LockedAt is when the policy violations of the user locked it, zero unless it is locked
+	LockedAt time.Time `json:"lockedAt,omitzero"`
+
+	// Xpub and XpubPath are the extended public key, and its derivation path, of watch-only users,
+	// registered without a mnemonic
+	Xpub     string `json:"xpub,omitempty"`
+	XpubPath string `json:"xpubPath,omitempty"`
+
+	// NamespaceIndex is the hardened index, derived from the UUID, of the derivation namespace of
+	// the user: its keys derive from the namespace seed of lib.NamespaceSeed, so users registering
+	// the same mnemonic share no key. Nil for users of the plain BIP-32 derivation.
+	NamespaceIndex *uint32 `json:"namespaceIndex,omitempty"`
+
+	// PassphraseVerifier is the passphrase stretched by the verifier algorithm of config/passphrase
+	// at registration, to check the stored passphrase in health checks
+	PassphraseVerifier *passphrase.Verifier `json:"passphraseVerifier,omitempty"`
+}
+
+// NewUser creates an active user record of the current schema version
+func NewUser(uuid, username, mnemonic, passphrase string, allowedCoinTypes []uint16) (*User, error) {
+	seed, err := lib.SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &User{
+		Username:         username,
+		UUID:             uuid,
+		Mnemonic:         mnemonic,
+		Passphrase:       passphrase,
+		SchemaVersion:    UserSchemaVersion,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Status:           UserStatusActive,
+		Fingerprint:      fingerprint,
+		AllowedCoinTypes: allowedCoinTypes,
+	}, nil
+}
+
+// NewWatchOnlyUser creates an active user holding only the extended public key xpub of xpubPath.
+// fingerprint is the optional master key fingerprint, used in the key origin of its descriptors.
+func NewWatchOnlyUser(uuid, username, xpub, xpubPath, fingerprint string,
+	allowedCoinTypes []uint16) (*User, error) {
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate() {
+		return nil, ErrPrivateXpub
+	}
+	path, err := lib.ParseDerivationPath(xpubPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) != int(key.Depth()) {
+		return nil, fmt.Errorf("%w: %d components, depth %d", ErrXpubPathDepth, len(path), key.Depth())
+	}
+	if fingerprint != "" {
+		if raw, err := hex.DecodeString(fingerprint); err != nil || len(raw) != 4 {
+			return nil, ErrInvalidFingerprint
+		}
+	}
+
+	now := time.Now().UTC()
+	return &User{
+		Username:         username,
+		UUID:             uuid,
+		SchemaVersion:    UserSchemaVersion,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Status:           UserStatusActive,
+		Fingerprint:      fingerprint,
+		AllowedCoinTypes: allowedCoinTypes,
+		Xpub:             xpub,
+		XpubPath:         xpubPath,
+	}, nil
+}
+
+// WatchOnly reports whether the user was registered with an xpub only, and cannot sign
+func (u *User) WatchOnly() bool {
+	return u.Xpub != ""
+}
+
+// Seed returns the BIP-39 seed of the user, or ErrWatchOnlyUser for watch-only users
+func (u *User) Seed() ([]byte, error) {
+	if u.WatchOnly() {
+		return nil, ErrWatchOnlyUser
+	}
+	seed, err := lib.SeedFromMnemonic(u.Mnemonic, u.Passphrase)
+	if err != nil || u.NamespaceIndex == nil {
+		return seed, err
+	}
+	return lib.NamespaceSeed(seed, *u.NamespaceIndex)
+}
+
+// SetDerivationNamespace isolates the keys of the user in the derivation namespace of its UUID,
+// the master key fingerprint becoming the one of the namespace seed
+func (u *User) SetDerivationNamespace() error {
+	if u.WatchOnly() {
+		return ErrNamespaceWatchOnly
+	}
+	digest := sha256.Sum256([]byte(namespaceDomain + u.UUID))
+	index := binary.BigEndian.Uint32(digest[:4]) &^ lib.HardenedOffset
+	u.NamespaceIndex = &index
+	seed, err := u.Seed()
+	if err != nil {
+		return err
+	}
+	u.Fingerprint, err = lib.MasterFingerprint(seed)
+	return err
+}
+
+// ExtendedPublicKeyAt derives the extended public key of the BIP-32 indices from the xpub of a
+// watch-only user. The indices must be below its xpubPath and not hardened past it.
+func (u *User) ExtendedPublicKeyAt(indices []uint32) (*hdkeychain.ExtendedKey, error) {
+	key, err := hdkeychain.NewKeyFromString(u.Xpub)
+	if err != nil {
+		return nil, err
+	}
+	xpubPath, err := lib.ParseDerivationPath(u.XpubPath)
+	if err != nil {
+		return nil, err
+	}
+	return lib.ChildPublicKeyAt(key, xpubPath, indices)
+}
+
+// PutUser stores user as its next version
+func PutUser(ctx context.Context, s logical.Storage, user *User) error {
+	user.Version++
+	entry, err := logical.StorageEntryJSON(config.StorageBasePath+user.UUID, user)
+	if err != nil {
+		user.Version--
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		user.Version--
+		return err
+	}
+	return nil
+}
+
+// migrate upgrades a record written before schema versioning. The creation time of
+// such records is unknown and stays zero; the fingerprint is computed by the user read path.
+func (u *User) migrate() {
+	if u.SchemaVersion >= UserSchemaVersion {
+		return
+	}
+	u.SchemaVersion = UserSchemaVersion
+	if u.Status == "" {
+		u.Status = UserStatusActive
+	}
+}
+
+// Expired reports whether the TTL of the user ended at now
+func (u *User) Expired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !now.Before(u.ExpiresAt)
+}
+
+// checkActive checks that the user is active and, until the periodic function disables it, not expired
+func (u *User) checkActive() error {
+	if u.Status != UserStatusActive {
+		return fmt.Errorf("%w: status %s", ErrUserNotActive, u.Status)
+	}
+	if u.Expired(time.Now()) {
+		return fmt.Errorf("%w: expired at %s", ErrUserNotActive, u.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Authorize checks that the user is active and allowed to use coinType
+func (u *User) Authorize(coinType uint16) error {
+	if err := u.checkActive(); err != nil {
+		return err
+	}
+	if len(u.AllowedCoinTypes) > 0 && !slices.Contains(u.AllowedCoinTypes, coinType) {
+		return fmt.Errorf("%w: %d", ErrCoinTypeNotAllowed, coinType)
+	}
+	return nil
+}
+
+// AuthorizeAnyCoin checks that the user is active and not restricted to some coin types,
+// for operations such as raw digest signing that cannot be attributed to a coin
+func (u *User) AuthorizeAnyCoin() error {
+	if err := u.checkActive(); err != nil {
+		return err
+	}
+	if len(u.AllowedCoinTypes) > 0 {
+		return fmt.Errorf("%w: restricted to %v", ErrCoinTypeNotAllowed, u.AllowedCoinTypes)
+	}
+	return nil
+}
+
+// AuthorizeEntity checks that a request made by entityID may use the keys of the user
+func (u *User) AuthorizeEntity(entityID string) error {
+	if u.RestrictToOwner && entityID != u.OwnerEntityID {
+		return ErrNotOwnerEntity
+	}
+	return nil
+}
+
+// AuthorizeManagement checks that a request made by entityID may disable, delete or rotate the user
+func (u *User) AuthorizeManagement(entityID string) error {
+	if !u.RestrictManagement || entityID == u.OwnerEntityID || slices.Contains(u.ManagerEntityIDs, entityID) {
+		return nil
+	}
+	return ErrNotManagerEntity
+}
+
+// GetUser reads and decodes the user record stored for uuid, migrating legacy records
+func GetUser(ctx context.Context, req *logical.Request, uuid string) (*User, error) {
+	entry, err := req.Storage.Get(ctx, config.StorageBasePath+uuid)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var user User
+	if err := entry.DecodeJSON(&user); err != nil {
+		return nil, err
+	}
+	user.migrate()
+	return &user, nil
+}
@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// maxFieldValueLength bounds the length of the values quoted in the field errors
+const maxFieldValueLength = 64
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidField = errors.New("invalid field")
+	ErrFieldTooLong = errors.New("field too long")
+)
+
+// FieldRule checks the decoded value of a field, returning what the value must be when it is
+// invalid, e.g., "must be 1..10000", and "" otherwise
+type FieldRule func(value interface{}) string
+
+// IntRange returns the rule of the integer fields between low and high inclusive
+func IntRange(low, high int) FieldRule {
+	return func(value interface{}) string {
+		if v, ok := value.(int); !ok || v < low || v > high {
+			return fmt.Sprintf("must be %d..%d", low, high)
+		}
+		return ""
+	}
+}
+
+// MinInt returns the rule of the integer fields of at least low
+func MinInt(low int) FieldRule {
+	return func(value interface{}) string {
+		if v, ok := value.(int); !ok || v < low {
+			return fmt.Sprintf("must be at least %d", low)
+		}
+		return ""
+	}
+}
+
+// CoinTypes returns the rule of the coin type fields, which must be one of coinTypes
+func CoinTypes(coinTypes []uint16) FieldRule {
+	supported := slices.Sorted(slices.Values(coinTypes))
+	names := make([]string, len(supported))
+	for i, coinType := range supported {
+		names[i] = strconv.Itoa(int(coinType))
+	}
+	reason := "must be one of the supported types: " + strings.Join(names, ", ")
+	return func(value interface{}) string {
+		if v, ok := value.(int); ok && v >= 0 && v <= math.MaxUint16 && slices.Contains(supported, uint16(v)) {
+			return ""
+		}
+		return reason
+	}
+}
+
+// ValidateFields verifies that the fields of the request are fields of its path and that their
+// values decode to the type of the field, with an error naming the field and its value.
+func ValidateFields(req *logical.Request, data *framework.FieldData) error {
+	var unknownFields []string
+	for k := range req.Data {
+		if _, ok := data.Schema[k]; !ok {
+			unknownFields = append(unknownFields, k)
+		}
+	}
+	if len(unknownFields) > 0 {
+		slices.Sort(unknownFields)
+		return fmt.Errorf("%w: %v, the fields of the path are %s", ErrUnknownFields, unknownFields,
+			strings.Join(slices.Sorted(maps.Keys(data.Schema)), ", "))
+	}
+
+	return ValidateFieldValues(data)
+}
+
+// ValidateFieldValues verifies that the values given in data decode to the type of their field and
+// are among its allowed values, if any
+func ValidateFieldValues(data *framework.FieldData) error {
+	for _, name := range slices.Sorted(maps.Keys(data.Raw)) {
+		schema, ok := data.Schema[name]
+		if !ok {
+			continue
+		}
+		value, _, err := data.GetOkErr(name)
+		if err != nil {
+			return FieldError(name, data.Raw[name], "must be "+fieldTypeDescription(schema.Type))
+		}
+		if len(schema.AllowedValues) > 0 && !slices.ContainsFunc(schema.AllowedValues, func(allowed interface{}) bool {
+			return fmt.Sprint(allowed) == fmt.Sprint(value)
+		}) {
+			allowed := make([]string, len(schema.AllowedValues))
+			for i, v := range schema.AllowedValues {
+				allowed[i] = fmt.Sprint(v)
+			}
+			return FieldError(name, data.Raw[name], "must be one of "+strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// ValidateFieldLengths checks that the string values given in data are at most the lengths of
+// limits in bytes. The values are not quoted: they are only measured, never decoded.
+func ValidateFieldLengths(data map[string]interface{}, limits map[string]int) error {
+	for _, name := range slices.Sorted(maps.Keys(limits)) {
+		value, ok := data[name].(string)
+		if ok && len(value) > limits[name] {
+			return fmt.Errorf("%w: %s is %d bytes, at most %d are accepted", ErrFieldTooLong, name, len(value),
+				limits[name])
+		}
+	}
+	return nil
+}
+
+// ValidateFieldRules checks the fields of data given in the request against their rule
+func ValidateFieldRules(data *framework.FieldData, rules map[string]FieldRule) error {
+	for _, name := range slices.Sorted(maps.Keys(rules)) {
+		if _, ok := data.Raw[name]; !ok {
+			continue
+		}
+		if _, ok := data.Schema[name]; !ok {
+			continue
+		}
+		value, _, err := data.GetOkErr(name)
+		if err != nil {
+			return FieldError(name, data.Raw[name], "must be "+fieldTypeDescription(data.Schema[name].Type))
+		}
+		if reason := rules[name](value); reason != "" {
+			return FieldError(name, data.Raw[name], reason)
+		}
+	}
+	return nil
+}
+
+// FieldError returns the error of the field name given value, saying what its value must be
+func FieldError(name string, value interface{}, reason string) error {
+	return fmt.Errorf("%w: %s %s, got %s", ErrInvalidField, name, reason, SanitizeFieldValue(name, value))
+}
+
+// SanitizeFieldValue formats value of the field name for an error: redacted for the secret fields
+// and the values looking like a mnemonic, quoted and truncated otherwise
+func SanitizeFieldValue(name string, value interface{}) string {
+	lower := strings.ToLower(name)
+	if logging.IsSensitiveKey(name) || lower == "key" || strings.Contains(lower, "secret") ||
+		strings.Contains(lower, "passphrase") || strings.Contains(lower, "token") {
+		return logging.Redacted
+	}
+
+	formatted := fmt.Sprint(value)
+	if logging.RedactString(formatted) == logging.Redacted {
+		return logging.Redacted
+	}
+	if utf8.RuneCountInString(formatted) > maxFieldValueLength {
+		formatted = string([]rune(formatted)[:maxFieldValueLength]) + "..."
+	}
+	if _, ok := value.(string); ok {
+		return strconv.Quote(formatted)
+	}
+	return formatted
+}
+
+// fieldTypeDescription describes the values of the fields of type t
+func fieldTypeDescription(t framework.FieldType) string {
+	switch t {
+	case framework.TypeBool:
+		return "a boolean"
+	case framework.TypeInt, framework.TypeInt64:
+		return "an integer"
+	case framework.TypeFloat:
+		return "a number"
+	case framework.TypeDurationSecond, framework.TypeSignedDurationSecond:
+		return "a duration, in seconds or with a unit such as 5m"
+	case framework.TypeMap:
+		return "an object"
+	case framework.TypeSlice:
+		return "a list"
+	case framework.TypeStringSlice, framework.TypeCommaStringSlice:
+		return "a list of strings, or a comma separated string"
+	case framework.TypeCommaIntSlice:
+		return "a list of integers, or a comma separated string"
+	case framework.TypeKVPairs:
+		return "key=value pairs"
+	case framework.TypeTime:
+		return "an RFC 3339 time or a Unix timestamp"
+	default:
+		return "a string"
+	}
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Codes of the warnings returned with the responses, in their warnings as "<code>: <message>".
+// The codes are stable, clients match on them; the messages may change.
+const (
+	// WarningDeprecatedField -- the request used a field marked deprecated, still honored
+	WarningDeprecatedField = "deprecated-field"
+	// WarningPathDefaulted -- the derivation path of the request was replaced by the one of its coin type
+	WarningPathDefaulted = "path-defaulted"
+	// WarningFeeNearLimit -- the fee rate is within the bounds of config/fees, close to their max
+	WarningFeeNearLimit = "fee-near-limit"
+	// WarningFeeOverridden -- the fee rate is out of the bounds of config/fees, signed with overrideFee
+	WarningFeeOverridden = "fee-overridden"
+	// WarningPolicyOverridden -- a policy of the mount was bypassed or softened for the request
+	WarningPolicyOverridden = "policy-overridden"
+	// WarningTravelRuleNotForwarded -- the transfer was signed but its travel rule data did not reach the sink
+	WarningTravelRuleNotForwarded = "travel-rule-not-forwarded"
+	// WarningStaleBlockhash -- the recent blockhash of the Solana message is older than the
+	// maxBlockhashAge of config/rpc, or its age could not be checked
+	WarningStaleBlockhash = "stale-blockhash"
+)
+
+// FeeNearLimitRatio is the share of the max of the fee bounds above which a fee rate is near the limit
+const FeeNearLimitRatio = 0.9
+
+// Warning formats the warning of code
+func Warning(code, format string, args ...any) string {
+	return code + ": " + fmt.Sprintf(format, args...)
+}
+
+// WarningCode returns the code of warning
+func WarningCode(warning string) string {
+	code, _, _ := strings.Cut(warning, ": ")
+	return code
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"math"
+	"slices"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// signIntent -- what a signing request moves, read from its fields before it is signed, for the
+// policies of the mount
+type signIntent struct {
+	// uuids are the users whose keys sign, path the derivation path of the key when there is one
+	uuids []string
+	path  string
+	// summary holds the transfers of the request; it is not decoded when the request moves what a
+	// contract or a raw digest decides
+	summary approval.Summary
+	// anyCoinType is set when the signature is valid on any chain, as a raw digest is: the policies
+	// of every coin type apply
+	anyCoinType bool
+	// walletChange is set when the transfers include the change of the signing wallets, which only
+	// the handler tells apart with their keys: it checks the recipients itself
+	walletChange bool
+	// walletOutputs returns the indexes of the transfers paying the wallet of seed back, which the
+	// budget is not charged for; it is set when the signer tells its change apart by its key
+	walletOutputs func(seed []byte) ([]int, error)
+	// fingerprint identifies the request granted by an approval
+	fingerprint string
+}
+
+// signIntentFunc returns the intent of the request of d, nil when its fields are invalid: the
+// policies then leave the request to the handler, which rejects it
+type signIntentFunc func(d *framework.FieldData) (*signIntent, error)
+
+// policyOp returns the chain of the signing path op other than sign, authorized by API keys with
+// operation: the address book, approval and budget policies of the mount apply to the intent
+// intentOf reads
+func (b *Backend) policyOp(operation string, intentOf signIntentFunc,
+	op framework.OperationFunc) framework.OperationFunc {
+	policies := b.withAddressBook(intentOf, b.withApproval(intentOf, b.withBudget(intentOf, op)))
+	return b.withDebugCapture(b.withAPIKey(operation, policies))
+}
+
+// signRequestIntent is the intent of a sign request, fingerprinted by signFingerprint so the
+// approvals granted before every signing path had one still match
+func signRequestIntent(d *framework.FieldData) (*signIntent, error) {
+	coinType, ok, err := d.GetOkErr("coinType")
+	if err != nil || !ok || coinType.(int) < 0 || coinType.(int) > math.MaxUint16 {
+		return nil, nil
+	}
+	fingerprint, err := signFingerprint(d)
+	if err != nil {
+		return nil, err
+	}
+	intent := &signIntent{
+		uuids:       []string{d.Get("uuid").(string)},
+		path:        d.Get("derivationPath").(string),
+		summary:     approval.Summarize(uint16(coinType.(int)), d.Get("payload").(string), d.Get("isDev").(bool)),
+		fingerprint: fingerprint,
+	}
+	// the change of a Bitcoin PSBT pays the signing key back, or a key its derivation shows
+	if intent.summary.CoinType == slip44.Bitcoin || intent.summary.CoinType == slip44.TestNet {
+		if packet, err := bitcoin.DecodePSBT(d.Get("payload").(string)); err == nil {
+			intent.walletOutputs = func(seed []byte) ([]int, error) {
+				return bitcoin.KeyOutputs(seed, packet, intent.path)
+			}
+		}
+	}
+	return intent, nil
+}
+
+// requestFingerprint returns the fingerprint of the request of d to pattern, by its fields but the
+// approvalId and apiKey it is sent again with, and the deprecated names renamed before d is read
+func requestFingerprint(pattern string, d *framework.FieldData) (string, error) {
+	// no field has the empty name the pattern is keyed by
+	fields := map[string]interface{}{"": pattern}
+	for name, schema := range d.Schema {
+		if name == "approvalId" || name == "apiKey" || schema.Deprecated {
+			continue
+		}
+		fields[name] = d.Get(name)
+	}
+	return helpers.ApprovalFingerprint(fields)
+}
+
+// intentOf returns the intent of a request to pattern signing for uuids with the key at path,
+// fingerprinted by requestFingerprint
+func intentOf(pattern string, d *framework.FieldData, uuids []string, path string,
+	summary approval.Summary) (*signIntent, error) {
+	fingerprint, err := requestFingerprint(pattern, d)
+	if err != nil {
+		return nil, err
+	}
+	return &signIntent{uuids: uuids, path: path, summary: summary, fingerprint: fingerprint}, nil
+}
+
+// fieldCoinType returns the coinType field of d, false when it is out of range
+func fieldCoinType(d *framework.FieldData) (uint16, bool) {
+	coinType := d.Get("coinType").(int)
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return 0, false
+	}
+	return uint16(coinType), true
+}
+
+// bitcoinCoinType is the coin type of the Bitcoin keys, testnet when isDev
+func bitcoinCoinType(isDev bool) uint16 {
+	if isDev {
+		return slip44.TestNet
+	}
+	return slip44.Bitcoin
+}
+
+// psbtIntent returns the intent of a request signing the PSBT of the field psbt for uuids, which
+// moves every output of the transaction, change included
+func psbtIntent(pattern string, d *framework.FieldData, uuids []string) (*signIntent, error) {
+	payload, isDev := d.Get("psbt").(string), d.Get("isDev").(bool)
+	if _, err := bitcoin.DecodePSBT(payload); err != nil || len(uuids) == 0 || slices.Contains(uuids, "") {
+		return nil, nil
+	}
+	intent, err := intentOf(pattern, d, uuids, "", approval.Summarize(bitcoinCoinType(isDev), payload, isDev))
+	if err != nil {
+		return nil, err
+	}
+	intent.walletChange = true
+	return intent, nil
+}
@@ -2,7 +2,10 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -11,6 +14,7 @@ import (
 	"github.com/payment-system/dq-vault/config"
 	"github.com/payment-system/dq-vault/lib"
 	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
 	"github.com/payment-system/dq-vault/lib/slip44"
 )
 
@@ -26,7 +30,7 @@ func (b *Backend) pathAddress(ctx context.Context, req *logical.Request,
 	uuid := d.Get("uuid").(string)
 
 	// derivation path
-	derivationPath := d.Get("path").(string)
+	derivationPath := d.Get("derivationPath").(string)
 
 	// coin type of transaction
 	// see supported coinTypes lib/bipp44coins
@@ -34,7 +38,15 @@ func (b *Backend) pathAddress(ctx context.Context, req *logical.Request,
 
 	isDev := d.Get("isDev").(bool)
 
+	derivationPath, err := presetDerivationPath(d, derivationPath, coinType)
+	if err != nil {
+		backendLogger.Error("preset path", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var warnings []string
 	if uint16(coinType) == slip44.Bitshares {
+		warnings = appendPathDefaulted(warnings, derivationPath, config.BitsharesDerivationPath)
 		derivationPath = config.BitsharesDerivationPath
 	}
 
@@ -43,46 +55,194 @@ func (b *Backend) pathAddress(ctx context.Context, req *logical.Request,
 	// validate data provided
 	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
 		backendLogger.Error("validate data", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
 	}
 
-	// path where user data is stored in vault
-	path := config.StorageBasePath + uuid
-	entry, err := req.Storage.Get(ctx, path)
+	// obtain mnemonic, passphrase of user
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
 	if err != nil {
-		backendLogger.Error("get", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
 	}
-
-	// obtain mnemonic and passphrase of user
-	var userInfo helpers.User
-	err = entry.DecodeJSON(&userInfo)
-	if err != nil {
-		backendLogger.Error("decode json", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
 	}
 
-	seed, err := lib.SeedFromMnemonic(userInfo.Mnemonic, userInfo.Passphrase)
-	if err != nil {
-		backendLogger.Error("seed from mnemonic", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	// watch-only users derive their addresses from their xpub
+	var seed []byte
+	if !userInfo.WatchOnly() {
+		if seed, err = userSeed(ctx, userInfo); err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
 	}
 
 	backendLogger.Info("dp", "dp", derivationPath)
 
 	// obtains blockchain adapater based on coinType
-	adapterInventory := adapter.GetInventory(backendLogger)
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+
+	override, err := b.addressOverride(ctx, req.Storage, d, uint16(coinType))
+	if err != nil {
+		backendLogger.Error("address override", "error", err)
+		return nil, err
+	}
 
-	address, err := adapterInventory.DeriveAddress(seed, uint16(coinType), derivationPath, isDev)
+	var address string
+	if override.IsZero() {
+		address, err = deriveUserAddress(adapterInventory, userInfo, seed, uint16(coinType), derivationPath, isDev)
+	} else {
+		address, err = deriveOverriddenAddress(userInfo, seed, derivationPath, isDev, override)
+	}
 	if err != nil {
 		backendLogger.Error("derive address", "error", err)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
 
-	// Returns publicKey and address as output
+	if err := b.indexAddresses(ctx, req, uuid, uint16(coinType), isDev,
+		map[string]string{derivationPath: address}); err != nil {
+		backendLogger.Error("index address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := map[string]interface{}{
+		"address": address,
+	}
+	if preset, ok := d.GetOk("preset"); ok && preset.(string) != "" {
+		data["path"] = derivationPath
+	}
+	if !override.IsZero() {
+		data["addressOverride"] = addressOverrideResponseData(override)
+	}
+
+	// coins with output descriptors also get the descriptor of the address, with its key origin;
+	// the account descriptors of watch-only users are returned by xpub
+	if !userInfo.WatchOnly() {
+		descriptor, err := adapterInventory.Descriptor(seed, uint16(coinType), derivationPath, isDev)
+		switch {
+		case err == nil:
+			data["descriptor"] = descriptor
+		case !errors.Is(err, adapter.ErrNoDescriptor):
+			backendLogger.Error("descriptor", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	// Returns address as output
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"address": address,
-		},
+		Data:     data,
+		Warnings: warnings,
 	}, nil
 }
+
+// presetDerivationPath returns the path of the preset of the request at its account and index, or
+// derivationPath when no preset is given
+func presetDerivationPath(d *framework.FieldData, derivationPath string, coinType int) (string, error) {
+	preset, ok := d.GetOk("preset")
+	if !ok || preset.(string) == "" {
+		return derivationPath, nil
+	}
+	if derivationPath != "" {
+		return "", helpers.ErrPathAndPreset
+	}
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return "", helpers.ErrUnsupportedCoinType
+	}
+	return lib.PresetPath(preset.(string), uint16(coinType), d.Get("account").(int), d.Get("index").(int))
+}
+
+// deriveUserAddress derives the address of derivationPath from seed or, for watch-only users, from
+// their xpub
+func deriveUserAddress(inventory *adapter.Inventory, userInfo *helpers.User, seed []byte, coinType uint16,
+	derivationPath string, isDev bool) (string, error) {
+	if !userInfo.WatchOnly() {
+		return inventory.DeriveAddress(seed, coinType, derivationPath, isDev)
+	}
+
+	publicKey, err := watchOnlyPublicKey(userInfo, derivationPath)
+	if err != nil {
+		return "", err
+	}
+	return inventory.AddressFromPublicKey(publicKey, coinType, derivationPath, isDev)
+}
+
+// watchOnlyPublicKey returns the compressed public key of derivationPath, derived from the xpub of
+// the watch-only user
+func watchOnlyPublicKey(userInfo *helpers.User, derivationPath string) ([]byte, error) {
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := userInfo.ExtendedPublicKeyAt(path)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return publicKey.SerializeCompressed(), nil
+}
+
+// addressOverride returns the hrp, pubKeyHashVersion and scriptHashVersion overrides of the request,
+// which must be allowed as a whole by config/address-overrides. Only the Bitcoin family has them.
+func (b *Backend) addressOverride(ctx context.Context, s logical.Storage, d *framework.FieldData,
+	coinType uint16) (helpers.AddressOverride, error) {
+	var override helpers.AddressOverride
+	if hrp, ok := d.GetOk("hrp"); ok {
+		override.HRP = hrp.(string)
+	}
+	if version, ok := d.GetOk("pubKeyHashVersion"); ok {
+		v := version.(int)
+		override.PubKeyHashVersion = &v
+	}
+	if version, ok := d.GetOk("scriptHashVersion"); ok {
+		v := version.(int)
+		override.ScriptHashVersion = &v
+	}
+	if override.IsZero() {
+		return override, nil
+	}
+
+	if !bitcoin.NewBitcoinAdapter(b.logger).CanDo(coinType) {
+		return override, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrAddressOverrideCoinType.Error())
+	}
+	if err := override.Validate(); err != nil {
+		return override, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	policy, err := helpers.GetAddressOverridePolicy(ctx, s)
+	if err != nil {
+		return override, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !policy.Allows(override) {
+		return override, helpers.CodedError(http.StatusForbidden,
+			fmt.Errorf("%w: %s", helpers.ErrAddressOverrideNotAllowed, override))
+	}
+	return override, nil
+}
+
+// deriveOverriddenAddress derives the Bitcoin address of derivationPath, from seed or the xpub of
+// watch-only users, encoded with the human readable part and version bytes of override
+func deriveOverriddenAddress(userInfo *helpers.User, seed []byte, derivationPath string, isDev bool,
+	override helpers.AddressOverride) (string, error) {
+	var publicKey []byte
+	if userInfo.WatchOnly() {
+		key, err := watchOnlyPublicKey(userInfo, derivationPath)
+		if err != nil {
+			return "", err
+		}
+		publicKey = key
+	} else {
+		privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+		if err != nil {
+			return "", err
+		}
+		publicKey = privateKey.PubKey().SerializeCompressed()
+	}
+	return bitcoin.AddressFromPublicKeyWith(publicKey, derivationPath, isDev, override.Bitcoin())
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
-	"github.com/payment-system/dq-vault/lib"
 	"github.com/payment-system/dq-vault/lib/adapter"
 	"github.com/payment-system/dq-vault/lib/slip44"
 )
@@ -31,8 +30,18 @@ func (b *Backend) pathAddressBatch(ctx context.Context, req *logical.Request,
 	startIndex := d.Get("startIndex").(int)
 	count := d.Get("count").(int)
 
-	if count <= 0 || count > 1000 {
-		return nil, logical.CodedError(http.StatusBadRequest, "count must be between 1 and 1000")
+	if count <= 0 {
+		return nil, logical.CodedError(http.StatusBadRequest, "count must be positive")
+	}
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if count > quotas.MaxBatchCount {
+		backendLogger.Warn("batch rejected", "count", count, "maxBatchCount", quotas.MaxBatchCount)
+		return nil, logical.CodedError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("%s: %d", helpers.ErrBatchTooLarge, quotas.MaxBatchCount))
 	}
 
 	if uint16(coinType) == slip44.Bitshares {
@@ -42,31 +51,34 @@ func (b *Backend) pathAddressBatch(ctx context.Context, req *logical.Request,
 	// Validate base data
 	if err := helpers.ValidateData(ctx, req, uuid, pathTemplate); err != nil {
 		backendLogger.Error("validate data", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
 	}
 
-	// Retrieve user info
-	path := config.StorageBasePath + uuid
-	entry, err := req.Storage.Get(ctx, path)
+	// obtain mnemonic, passphrase of user
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
 	if err != nil {
-		backendLogger.Error("get", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
 	}
-
-	var userInfo helpers.User
-	err = entry.DecodeJSON(&userInfo)
-	if err != nil {
-		backendLogger.Error("decode json", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
 	}
 
-	seed, err := lib.SeedFromMnemonic(userInfo.Mnemonic, userInfo.Passphrase)
-	if err != nil {
-		backendLogger.Error("seed from mnemonic", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	// watch-only users derive their addresses from their xpub
+	var seed []byte
+	if !userInfo.WatchOnly() {
+		if seed, err = userSeed(ctx, userInfo); err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
 	}
 
-	adapterInventory := adapter.GetInventory(backendLogger)
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
 
 	addresses := make(map[string]string, count)
 	for i := startIndex; i < startIndex+count; i++ {
@@ -76,7 +88,7 @@ func (b *Backend) pathAddressBatch(ctx context.Context, req *logical.Request,
 		} else {
 			derivationPath = fmt.Sprintf(pathTemplate, i)
 		}
-		address, err := adapterInventory.DeriveAddress(seed, uint16(coinType), derivationPath, isDev)
+		address, err := deriveUserAddress(adapterInventory, userInfo, seed, uint16(coinType), derivationPath, isDev)
 		if err != nil {
 			backendLogger.Error("derive address", "error", err, "index", i)
 			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
@@ -84,6 +96,11 @@ func (b *Backend) pathAddressBatch(ctx context.Context, req *logical.Request,
 		addresses[derivationPath] = address
 	}
 
+	if err := b.indexAddresses(ctx, req, uuid, uint16(coinType), isDev, addresses); err != nil {
+		backendLogger.Error("index addresses", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
 			"addresses": addresses,
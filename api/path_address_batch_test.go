@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"net/http"
 	"os"
 	"testing"
 
@@ -112,6 +113,8 @@ func TestBackend_PathAddressBatch(t *testing.T) {
 	entry := createUserStorageEntryBatch(t, testUUID, testMnemonic, testPass)
 	mockStorage.On("Get", ctx, config.StorageBasePath+testUUID).Return(entry, nil)
 	mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{testUUID}, nil)
+	mockStorage.On("Get", ctx, config.QuotasStorageKey).Return(nil, nil)
+	mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
 
 	backend := createBatchTestBackend(t)
 	fieldData := createBatchFieldData(map[string]interface{}{
@@ -146,3 +149,29 @@ func TestBackend_PathAddressBatch(t *testing.T) {
 		assert.NotEmpty(t, addr)
 	}
 }
+
+func TestBackend_PathAddressBatch_Quota(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	entry, err := logical.StorageEntryJSON(config.QuotasStorageKey, helpers.Quotas{MaxBatchCount: 2})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, entry))
+
+	batch := func(count int) (*logical.Response, error) {
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "pathTemplate": "m/44'/60'/0'/0/%d", "coinType": 60, "count": count,
+		}
+		return createBatchTestBackend(t).pathAddressBatch(ctx, &logical.Request{Storage: s, Data: data},
+			createBatchFieldData(data))
+	}
+
+	_, err = batch(2)
+	require.NoError(t, err)
+
+	_, err = batch(3)
+	require.Error(t, err)
+	codedErr, ok := err.(logical.HTTPCodedError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, codedErr.Code())
+	assert.Contains(t, err.Error(), helpers.ErrBatchTooLarge.Error())
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// defaultAddressPurpose is the BIP-44 purpose of the paths allocated by address/next
+const defaultAddressPurpose = 44
+
+// pathAddressNext corresponds to UPDATE address/next. It allocates the next unused index of the
+// (account, change) chain of the user coin, records it in the address ledger and returns its address,
+// so concurrent callers never hand out the same address twice.
+func (b *Backend) pathAddressNext(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_address_next"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	coinType := d.Get("coinType").(int)
+	purpose := d.Get("purpose").(int)
+	account := d.Get("account").(int)
+	change := d.Get("change").(int)
+	isDev := d.Get("isDev").(bool)
+	reference := d.Get("reference").(string)
+
+	if purpose < 0 || purpose > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidPurpose.Error())
+	}
+	if account < 0 || account > math.MaxInt32 || change < 0 || change > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAddressChain.Error())
+	}
+	if coinType < 0 || coinType > math.MaxUint16 || uint16(coinType) == slip44.Bitshares {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrFixedDerivationPath.Error())
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	capabilities, err := adapterInventory.CoinCapabilities(uint16(coinType))
+	if err != nil {
+		backendLogger.Error("coin capabilities", "error", err, "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if !helpers.UUIDExists(ctx, req, uuid) {
+		backendLogger.Error("validate uuid", "error", helpers.ErrUUIDDoesNotExist)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, helpers.ErrUUIDDoesNotExist)
+	}
+
+	// obtain mnemonic, passphrase of user
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	// watch-only users derive their addresses from their xpub
+	var seed []byte
+	if !userInfo.WatchOnly() {
+		if seed, err = userSeed(ctx, userInfo); err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	// the chain is read and advanced under the lock, so no two requests get the same index
+	b.addressMu.Lock()
+	defer b.addressMu.Unlock()
+
+	chain, err := helpers.GetAddressChain(ctx, req.Storage, uuid, uint16(coinType), uint32(account), uint32(change))
+	if err != nil {
+		backendLogger.Error("get address chain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if chain.Next > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrAddressIndexExhausted.Error())
+	}
+
+	derivationPath := addressNextPath(capabilities.Curve, purpose, coinType, account, change, chain.Next)
+	address, err := deriveUserAddress(adapterInventory, userInfo, seed, uint16(coinType), derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	reservation := &helpers.AddressReservation{
+		Index:      chain.Next,
+		Path:       derivationPath,
+		Address:    address,
+		Reference:  reference,
+		EntityID:   req.EntityID,
+		ReservedAt: time.Now().UTC(),
+	}
+	if err := helpers.PutAddressReservation(ctx, req.Storage, uuid, uint16(coinType), uint32(account),
+		uint32(change), reservation); err != nil {
+		backendLogger.Error("put address reservation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := b.indexAddresses(ctx, req, uuid, uint16(coinType), isDev,
+		map[string]string{derivationPath: address}); err != nil {
+		backendLogger.Error("index address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("address reserved", "uuid", uuid, "coinType", coinType, "path", derivationPath,
+		"reference", reference, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"address": address,
+			"path":    derivationPath,
+			"index":   reservation.Index,
+		},
+	}, nil
+}
+
+// addressNextPath returns the BIP-44 path of index on the (account, change) chain. Coins on ed25519
+// only derive hardened components.
+func addressNextPath(curve string, purpose, coinType, account, change int, index uint32) string {
+	if curve == lib.CurveEd25519 {
+		return fmt.Sprintf("m/%d'/%d'/%d'/%d'/%d'", purpose, coinType, account, change, index)
+	}
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", purpose, coinType, account, change, index)
+}
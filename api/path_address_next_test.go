@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Helper function to create a proper framework.FieldData for address/next endpoint
+func createAddressNextFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":      {Type: framework.TypeString},
+			"coinType":  {Type: framework.TypeInt},
+			"purpose":   {Type: framework.TypeInt, Default: defaultAddressPurpose},
+			"account":   {Type: framework.TypeInt, Default: 0},
+			"change":    {Type: framework.TypeInt, Default: 0},
+			"reference": {Type: framework.TypeString, Default: ""},
+			"isDev":     {Type: framework.TypeBool, Default: false},
+		},
+	}
+}
+
+func TestBackend_PathAddressNext(t *testing.T) {
+	ctx := context.Background()
+	b := createSignTestBackend(t)
+	next := func(s logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return b.pathAddressNext(ctx, &logical.Request{Storage: s, Data: data}, createAddressNextFieldData(data))
+	}
+
+	t.Run("indexes are allocated in order and recorded", func(t *testing.T) {
+		s := newXpubTestStorage(t)
+		data := map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "reference": "customer-42"}
+
+		got, err := next(s, data)
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", got.Data["address"])
+		assert.Equal(t, "m/44'/60'/0'/0/0", got.Data["path"])
+		assert.Equal(t, uint32(0), got.Data["index"])
+
+		got, err = next(s, data)
+		require.NoError(t, err)
+		assert.Equal(t, "m/44'/60'/0'/0/1", got.Data["path"])
+
+		entry, err := s.Get(ctx, config.AddressLedgerStoragePath+signTestUUID+"/60/0/0/1")
+		require.NoError(t, err)
+		var reservation helpers.AddressReservation
+		require.NoError(t, entry.DecodeJSON(&reservation))
+		assert.Equal(t, got.Data["address"], reservation.Address)
+		assert.Equal(t, "customer-42", reservation.Reference)
+
+		// the change chain is allocated independently
+		got, err = next(s, map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "change": 1})
+		require.NoError(t, err)
+		assert.Equal(t, "m/44'/60'/0'/1/0", got.Data["path"])
+	})
+
+	t.Run("concurrent requests never share an index", func(t *testing.T) {
+		s := newXpubTestStorage(t)
+		const requests = 16
+		paths := make(chan string, requests)
+		var wg sync.WaitGroup
+		for range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := next(s, map[string]interface{}{"uuid": signTestUUID, "coinType": 60})
+				if assert.NoError(t, err) {
+					paths <- got.Data["path"].(string)
+				}
+			}()
+		}
+		wg.Wait()
+		close(paths)
+
+		seen := map[string]bool{}
+		for path := range paths {
+			assert.False(t, seen[path], path)
+			seen[path] = true
+		}
+		assert.Len(t, seen, requests)
+	})
+
+	t.Run("ed25519 paths are hardened", func(t *testing.T) {
+		got, err := next(newXpubTestStorage(t), map[string]interface{}{"uuid": signTestUUID, "coinType": 501})
+		require.NoError(t, err)
+		assert.Equal(t, "m/44'/501'/0'/0'/0'", got.Data["path"])
+	})
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "fixed derivation path",
+			data:    map[string]interface{}{"uuid": signTestUUID, "coinType": 69},
+			wantErr: helpers.ErrFixedDerivationPath.Error(),
+		},
+		{
+			name:    "negative account",
+			data:    map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "account": -1},
+			wantErr: helpers.ErrInvalidAddressChain.Error(),
+		},
+		{
+			name:    "unknown user",
+			data:    map[string]interface{}{"uuid": "unknown", "coinType": 60},
+			wantErr: helpers.ErrUUIDDoesNotExist.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := next(newXpubTestStorage(t), tt.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathAddressProve corresponds to UPDATE address/prove. It returns the address of the derivation path
+// and a signature of the proof message of the challenge with its key, so third parties can check the
+// ownership of the address without a transfer.
+func (b *Backend) pathAddressProve(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_address_prove"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	coinType := d.Get("coinType").(int)
+	challenge := d.Get("challenge").(string)
+	isDev := d.Get("isDev").(bool)
+
+	var warnings []string
+	if uint16(coinType) == slip44.Bitshares {
+		warnings = appendPathDefaulted(warnings, derivationPath, config.BitsharesDerivationPath)
+		derivationPath = config.BitsharesDerivationPath
+	}
+
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if userInfo.WatchOnly() {
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrWatchOnlyUser.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	address, err := adapterInventory.DeriveAddress(seed, uint16(coinType), derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	message, err := lib.AddressProofMessage(address, challenge)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	capabilities, err := adapterInventory.CoinCapabilities(uint16(coinType))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	scheme, signature, publicKey, err := signAddressProof(capabilities, seed, derivationPath, message, isDev)
+	if err != nil {
+		backendLogger.Error("sign address proof", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("address proof signed", "uuid", uuid, "coinType", coinType, "path", derivationPath,
+		"address", address, "scheme", scheme)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"address":   address,
+			"message":   message,
+			"scheme":    scheme,
+			"signature": signature,
+			"publicKey": hex.EncodeToString(publicKey),
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+// signAddressProof signs message with the key of derivationPath in the scheme of the coin: BIP-137
+// for Bitcoin, EIP-191 for the other secp256k1 coins and ed25519 for the ed25519 ones. It returns
+// the scheme, the signature and the public key.
+func signAddressProof(capabilities lib.Capabilities, seed []byte, derivationPath, message string,
+	isDev bool) (string, string, []byte, error) {
+	switch {
+	case capabilities.Adapter == "bitcoin":
+		signature, publicKey, err := bitcoin.SignMessage(seed, derivationPath, message, isDev)
+		return lib.ProofSchemeBIP137, signature, publicKey, err
+	case capabilities.Curve == lib.CurveSecp256k1:
+		signature, publicKey, err := lib.SignPersonalMessage(seed, derivationPath, message)
+		return lib.ProofSchemeEIP191, "0x" + hex.EncodeToString(signature), publicKey, err
+	case capabilities.Curve == lib.CurveEd25519:
+		signature, publicKey, err := lib.SignEd25519Message(seed, derivationPath, message)
+		return lib.ProofSchemeEd25519, hex.EncodeToString(signature), publicKey, err
+	default:
+		return "", "", nil, lib.ErrProofUnsupported
+	}
+}
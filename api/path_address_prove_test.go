@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestBackend_HandleRequest_AddressProve(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	prove := func(coinType uint16, derivationPath, challenge string) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "address/prove", Storage: s,
+			Data: map[string]interface{}{
+				"uuid": signTestUUID, "coinType": int(coinType), "derivationPath": derivationPath, "challenge": challenge,
+			},
+		})
+	}
+	address := func(coinType uint16, derivationPath string) string {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "address", Storage: s,
+			Data: map[string]interface{}{"uuid": signTestUUID, "coinType": int(coinType), "derivationPath": derivationPath},
+		})
+		require.NoError(t, err)
+		return resp.Data["address"].(string)
+	}
+
+	t.Run("eip191", func(t *testing.T) {
+		resp, err := prove(slip44.Ether, signTestDerivationPath, "exchange-whitelist-42")
+		require.NoError(t, err)
+		assert.Equal(t, lib.ProofSchemeEIP191, resp.Data["scheme"])
+		assert.Equal(t, address(slip44.Ether, signTestDerivationPath), resp.Data["address"])
+		assert.Contains(t, resp.Data["message"], "challenge: exchange-whitelist-42")
+
+		signature, err := hex.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "0x"))
+		require.NoError(t, err)
+		signature[crypto.RecoveryIDOffset] -= 27
+		key, err := crypto.SigToPub(accounts.TextHash([]byte(resp.Data["message"].(string))), signature)
+		require.NoError(t, err)
+		assert.Equal(t, resp.Data["address"], crypto.PubkeyToAddress(*key).Hex())
+	})
+
+	t.Run("bip137", func(t *testing.T) {
+		derivationPath := "m/84'/0'/0'/0/0"
+		resp, err := prove(slip44.Bitcoin, derivationPath, "challenge")
+		require.NoError(t, err)
+		assert.Equal(t, lib.ProofSchemeBIP137, resp.Data["scheme"])
+		assert.Equal(t, address(slip44.Bitcoin, derivationPath), resp.Data["address"])
+
+		signature, err := base64.StdEncoding.DecodeString(resp.Data["signature"].(string))
+		require.NoError(t, err)
+		signature[0] -= 8 // p2wpkh to the header of p2pkh expected by btcec
+		key, _, err := ecdsa.RecoverCompact(signature, bitcoin.MessageHash(resp.Data["message"].(string)))
+		require.NoError(t, err)
+		assert.Equal(t, resp.Data["publicKey"], hex.EncodeToString(key.SerializeCompressed()))
+
+		_, err = prove(slip44.Bitcoin, "m/86'/0'/0'/0/0", "challenge")
+		require.ErrorContains(t, err, lib.ErrProofUnsupported.Error())
+	})
+
+	t.Run("ed25519", func(t *testing.T) {
+		derivationPath := "m/44'/501'/0'/0'"
+		resp, err := prove(slip44.Solana, derivationPath, "challenge")
+		require.NoError(t, err)
+		assert.Equal(t, lib.ProofSchemeEd25519, resp.Data["scheme"])
+
+		publicKey, err := hex.DecodeString(resp.Data["publicKey"].(string))
+		require.NoError(t, err)
+		signature, err := hex.DecodeString(resp.Data["signature"].(string))
+		require.NoError(t, err)
+		assert.True(t, ed25519.Verify(publicKey, []byte(resp.Data["message"].(string)), signature))
+	})
+
+	for _, challenge := range []string{"", "two\nlines", strings.Repeat("x", lib.MaxChallengeLength+1)} {
+		_, err := prove(slip44.Ether, signTestDerivationPath, challenge)
+		require.Error(t, err, challenge)
+	}
+	_, err := prove(slip44.Ether, signTestDerivationPath, "two\nlines")
+	require.ErrorContains(t, err, lib.ErrInvalidChallenge.Error())
+	_, err = prove(slip44.Starknet, "m/44'/9004'/0'/0/0", "challenge")
+	require.ErrorContains(t, err, lib.ErrProofUnsupported.Error())
+
+	t.Run("watch-only users cannot prove", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "xpub", Storage: s,
+			Data: map[string]interface{}{"uuid": signTestUUID, "path": "m/44'/60'/0'", "coinType": 60},
+		})
+		require.NoError(t, err)
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "register", Storage: s,
+			Data: map[string]interface{}{"uuid": "watch-only", "xpub": resp.Data["xpub"], "xpubPath": "m/44'/60'/0'"},
+		})
+		require.NoError(t, err)
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "address/prove", Storage: s,
+			Data: map[string]interface{}{
+				"uuid": "watch-only", "coinType": 60, "derivationPath": signTestDerivationPath, "challenge": "challenge",
+			},
+		})
+		require.ErrorContains(t, err, helpers.ErrWatchOnlyUser.Error())
+	})
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
 	"github.com/payment-system/dq-vault/lib/slip44"
 )
 
@@ -64,7 +65,7 @@ func createFieldData(data map[string]interface{}) *framework.FieldData {
 			Type:        framework.TypeString,
 			Description: "UUID of user",
 		},
-		"path": {
+		"derivationPath": {
 			Type:        framework.TypeString,
 			Description: "Derivation path",
 		},
@@ -125,10 +126,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "successful ethereum address derivation",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				entry := createUserStorageEntry(t, testUser)
@@ -146,10 +147,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "successful address derivation with isDev true",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    true,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          true,
 			},
 			setupStorage: func(ms *MockStorage) {
 				entry := createUserStorageEntry(t, testUser)
@@ -167,9 +168,9 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "missing uuid field",
 			fieldData: map[string]interface{}{
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(_ *MockStorage) {
 				// No storage expectations since validation should fail first
@@ -193,14 +194,14 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "missing coinType field",
 			fieldData: map[string]interface{}{
-				"uuid":  testUUID,
-				"path":  testDerivationPath,
-				"isDev": false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				// Mock List for UUID existence check since ValidateData will be called
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{testUUID}, nil)
-				// Mock Get since coinType=0 doesn't cause validation failure
+				// coinType defaults to 0, Bitcoin
 				testUser := helpers.User{
 					Mnemonic:   testMnemonic,
 					Passphrase: testPassphrase,
@@ -208,16 +209,15 @@ func TestBackend_PathAddress(t *testing.T) {
 				entry := createUserStorageEntry(t, testUser)
 				ms.On("Get", ctx, config.StorageBasePath+testUUID).Return(entry, nil)
 			},
-			wantErr:        true,
-			wantStatusCode: http.StatusUnprocessableEntity,
+			wantErr: false,
 		},
 		{
 			name: "storage get error",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				// Mock List for UUID existence check
@@ -230,10 +230,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "user not found in storage",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				// Mock List for UUID existence check
@@ -247,10 +247,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "invalid json in storage",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				// Mock List for UUID existence check
@@ -267,10 +267,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "empty mnemonic in user data",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				emptyUser := helpers.User{
@@ -288,10 +288,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "invalid derivation path",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     "invalid/path",
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": "invalid/path",
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				entry := createUserStorageEntry(t, testUser)
@@ -305,10 +305,10 @@ func TestBackend_PathAddress(t *testing.T) {
 		{
 			name: "unsupported coin type",
 			fieldData: map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": 99999, // Unsupported coin type
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       99999, // Unsupported coin type
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorage) {
 				entry := createUserStorageEntry(t, testUser)
@@ -331,6 +331,10 @@ func TestBackend_PathAddress(t *testing.T) {
 			if tt.setupStorage != nil {
 				tt.setupStorage(mockStorage)
 			}
+			if !tt.wantErr {
+				// derived addresses are indexed when config/features enables it
+				mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+			}
 
 			// Setup field data
 			fieldData := createFieldData(tt.fieldData)
@@ -418,21 +422,24 @@ func TestBackend_PathAddress_CoinTypeSpecific(t *testing.T) {
 			mockStorage.On("Get", ctx, config.StorageBasePath+testUUID).Return(entry, nil)
 			// Mock List for UUID existence check
 			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{testUUID}, nil)
+			if tt.coinType == slip44.Ether || tt.coinType == slip44.Bitcoin {
+				mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+			}
 
 			fieldData := createFieldData(map[string]interface{}{
-				"uuid":     testUUID,
-				"path":     testDerivationPath,
-				"coinType": int(tt.coinType),
-				"isDev":    false,
+				"uuid":           testUUID,
+				"derivationPath": testDerivationPath,
+				"coinType":       int(tt.coinType),
+				"isDev":          false,
 			})
 
 			req := &logical.Request{
 				Storage: mockStorage,
 				Data: map[string]interface{}{
-					"uuid":     testUUID,
-					"path":     testDerivationPath,
-					"coinType": int(tt.coinType),
-					"isDev":    false,
+					"uuid":           testUUID,
+					"derivationPath": testDerivationPath,
+					"coinType":       int(tt.coinType),
+					"isDev":          false,
 				},
 			}
 
@@ -440,13 +447,20 @@ func TestBackend_PathAddress_CoinTypeSpecific(t *testing.T) {
 			got, err := backend.pathAddress(ctx, req, fieldData)
 
 			// For unsupported coin types, we expect an error
-			// Currently only Ether is supported by the EVM adapter
-			if tt.coinType != slip44.Ether {
-				assert.Error(t, err)
-			} else {
+			// Ether is served by the EVM adapter and Bitcoin by the Bitcoin adapter
+			switch tt.coinType {
+			case slip44.Ether:
+				assert.NoError(t, err)
+				assert.NotNil(t, got)
+				assert.Contains(t, got.Data, "address")
+				assert.NotContains(t, got.Data, "descriptor")
+			case slip44.Bitcoin:
 				assert.NoError(t, err)
 				assert.NotNil(t, got)
 				assert.Contains(t, got.Data, "address")
+				assert.Contains(t, got.Data["descriptor"], "pkh([")
+			default:
+				assert.Error(t, err)
 			}
 
 			mockStorage.AssertExpectations(t)
@@ -461,10 +475,10 @@ func TestBackend_PathAddress_EdgeCases(t *testing.T) {
 	t.Run("nil context", func(t *testing.T) {
 		mockStorage := new(MockStorage)
 		data := map[string]interface{}{
-			"uuid":     testUUID,
-			"path":     testDerivationPath,
-			"coinType": int(slip44.Ether),
-			"isDev":    false,
+			"uuid":           testUUID,
+			"derivationPath": testDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"isDev":          false,
 		}
 		fieldData := createFieldData(data)
 
@@ -488,10 +502,10 @@ func TestBackend_PathAddress_EdgeCases(t *testing.T) {
 	t.Run("empty uuid", func(t *testing.T) {
 		mockStorage := new(MockStorage)
 		data := map[string]interface{}{
-			"uuid":     "",
-			"path":     testDerivationPath,
-			"coinType": int(slip44.Ether),
-			"isDev":    false,
+			"uuid":           "",
+			"derivationPath": testDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"isDev":          false,
 		}
 		fieldData := createFieldData(data)
 
@@ -507,10 +521,10 @@ func TestBackend_PathAddress_EdgeCases(t *testing.T) {
 	t.Run("large coin type value", func(t *testing.T) {
 		mockStorage := new(MockStorage)
 		data := map[string]interface{}{
-			"uuid":     testUUID,
-			"path":     testDerivationPath,
-			"coinType": 2147483647, // Max int32
-			"isDev":    false,
+			"uuid":           testUUID,
+			"derivationPath": testDerivationPath,
+			"coinType":       2147483647, // Max int32
+			"isDev":          false,
 		}
 		fieldData := createFieldData(data)
 
@@ -536,6 +550,58 @@ func TestBackend_PathAddress_EdgeCases(t *testing.T) {
 }
 
 // Benchmark test for performance
+func TestBackend_PathAddress_Preset(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	address := func(data map[string]interface{}) (*logical.Response, error) {
+		fieldData := createFieldData(data)
+		fieldData.Schema["preset"] = &framework.FieldSchema{Type: framework.TypeString}
+		fieldData.Schema["account"] = &framework.FieldSchema{Type: framework.TypeInt, Default: 0}
+		fieldData.Schema["index"] = &framework.FieldSchema{Type: framework.TypeInt, Default: 0}
+		return createSignTestBackend(t).pathAddress(ctx, &logical.Request{Storage: s, Data: data}, fieldData)
+	}
+
+	t.Run("preset path", func(t *testing.T) {
+		got, err := address(map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ethereum), "preset": "ethereum-default",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, testAddress, got.Data["address"])
+		assert.Equal(t, "m/44'/60'/0'/0/0", got.Data["path"])
+	})
+
+	t.Run("ledger live accounts", func(t *testing.T) {
+		got, err := address(map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ethereum), "preset": "ledger-live", "account": 1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "m/44'/60'/1'/0/0", got.Data["path"])
+
+		want, err := address(map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ethereum), "derivationPath": "m/44'/60'/1'/0/0",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want.Data["address"], got.Data["address"])
+		assert.NotContains(t, want.Data, "path")
+	})
+
+	t.Run("path and preset", func(t *testing.T) {
+		_, err := address(map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ethereum), "preset": "trezor", "derivationPath": testDerivationPath,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrPathAndPreset.Error())
+	})
+
+	t.Run("preset of another coin", func(t *testing.T) {
+		_, err := address(map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ethereum), "preset": "bitcoin-segwit",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), lib.ErrPresetCoinType.Error())
+	})
+}
+
 func BenchmarkBackend_PathAddress(b *testing.B) {
 	ctx := context.Background()
 	backend := createTestBackend(&testing.T{})
@@ -552,12 +618,13 @@ func BenchmarkBackend_PathAddress(b *testing.B) {
 		mockStorage.On("Get", ctx, config.StorageBasePath+testUUID).Return(entry, nil)
 		// Mock List for UUID existence check
 		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{testUUID}, nil)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
 
 		data := map[string]interface{}{
-			"uuid":     testUUID,
-			"path":     testDerivationPath,
-			"coinType": int(slip44.Ether),
-			"isDev":    false,
+			"uuid":           testUUID,
+			"derivationPath": testDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"isDev":          false,
 		}
 		fieldData := createFieldData(data)
 
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+const (
+	// defaultVanityIterations is the number of indexes searched by address/vanity by default
+	defaultVanityIterations = 10000
+	// maxVanityIterations caps maxIterations, so a search holds the node for seconds at most
+	maxVanityIterations = 100000
+	// maxVanityPrefix is the max length of the prefixes searched
+	maxVanityPrefix = 16
+)
+
+// pathAddressVanity corresponds to UPDATE address/vanity. It searches the indexes of pathTemplate
+// from startIndex, at most maxIterations of them, for the first address starting with prefix, for
+// memorable test fixtures. config/features must have vanityAddressEnabled, which is meant for
+// development mounts only, and only the development mode addresses are searched. The address
+// found is not indexed.
+func (b *Backend) pathAddressVanity(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_address_vanity"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.VanityAddressEnabled {
+		backendLogger.Warn("vanity search rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("address/vanity: %s", helpers.ErrFeatureDisabled))
+	}
+
+	uuid := d.Get("uuid").(string)
+	pathTemplate := d.Get("pathTemplate").(string)
+	coinType := d.Get("coinType").(int)
+	prefix := d.Get("prefix").(string)
+	caseSensitive := d.Get("caseSensitive").(bool)
+	startIndex := d.Get("startIndex").(int)
+	maxIterations := d.Get("maxIterations").(int)
+
+	if !d.Get("isDev").(bool) {
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrVanityNotDev.Error())
+	}
+	if !validVanityPrefix(prefix) || strings.Count(pathTemplate, "%d") != 1 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidVanity.Error())
+	}
+
+	if err := helpers.ValidateData(ctx, req, uuid, pathTemplate); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	// obtain mnemonic, passphrase of user
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	// watch-only users derive their addresses from their xpub
+	var seed []byte
+	if !userInfo.WatchOnly() {
+		if seed, err = userSeed(ctx, userInfo); err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+
+	match := strings.HasPrefix
+	if !caseSensitive {
+		match = func(address, prefix string) bool {
+			return len(address) >= len(prefix) && strings.EqualFold(address[:len(prefix)], prefix)
+		}
+	}
+	for i := startIndex; i < startIndex+maxIterations; i++ {
+		// a cancelled request stops the search
+		if err := ctx.Err(); err != nil {
+			return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+		}
+		derivationPath := fmt.Sprintf(pathTemplate, i)
+		address, err := deriveUserAddress(adapterInventory, userInfo, seed, uint16(coinType), derivationPath, true)
+		if err != nil {
+			backendLogger.Error("derive address", "error", err, "index", i)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if match(address, prefix) {
+			backendLogger.Info("vanity address found", "path", derivationPath, "iterations", i-startIndex+1)
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"address":    address,
+					"path":       derivationPath,
+					"index":      i,
+					"iterations": i - startIndex + 1,
+				},
+			}, nil
+		}
+	}
+
+	backendLogger.Info("vanity address not found", "prefix", prefix, "iterations", maxIterations)
+	return nil, logical.CodedError(http.StatusNotFound,
+		fmt.Sprintf("%s: %q, indexes %d to %d", helpers.ErrVanityNotFound, prefix, startIndex, startIndex+maxIterations-1))
+}
+
+// validVanityPrefix reports whether prefix is 1 to maxVanityPrefix letters and digits, the
+// characters of every address encoding
+func validVanityPrefix(prefix string) bool {
+	if prefix == "" || len(prefix) > maxVanityPrefix {
+		return false
+	}
+	for _, c := range prefix {
+		if c > unicode.MaxASCII || !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_AddressVanity(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	vanity := func(fields map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		data := map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "pathTemplate": "m/44'/60'/0'/0/%d"}
+		for name, value := range fields {
+			data[name] = value
+		}
+		return request(logical.UpdateOperation, "address/vanity", data)
+	}
+
+	_, err := vanity(map[string]interface{}{"prefix": "0x"})
+	require.ErrorContains(t, err, helpers.ErrFeatureDisabled.Error())
+	_, err = request(logical.UpdateOperation, "config/features", map[string]interface{}{"vanityAddressEnabled": true})
+	require.NoError(t, err)
+
+	// the address of index 3, searched from index 0
+	want, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "derivationPath": "m/44'/60'/0'/0/3",
+	})
+	require.NoError(t, err)
+	address := want.Data["address"].(string)
+
+	t.Run("first index with the prefix", func(t *testing.T) {
+		resp, err := vanity(map[string]interface{}{"prefix": strings.ToLower(address[:8])})
+		require.NoError(t, err)
+		assert.Equal(t, address, resp.Data["address"])
+		assert.Equal(t, "m/44'/60'/0'/0/3", resp.Data["path"])
+		assert.Equal(t, 3, resp.Data["index"])
+		assert.Equal(t, 4, resp.Data["iterations"])
+	})
+
+	t.Run("case sensitive prefixes", func(t *testing.T) {
+		resp, err := vanity(map[string]interface{}{"prefix": address[:8], "caseSensitive": true})
+		require.NoError(t, err)
+		assert.Equal(t, address, resp.Data["address"])
+
+		_, err = vanity(map[string]interface{}{
+			"prefix": "0X" + address[2:8], "caseSensitive": true, "maxIterations": 4,
+		})
+		require.ErrorContains(t, err, helpers.ErrVanityNotFound.Error())
+	})
+
+	t.Run("bounded searches", func(t *testing.T) {
+		_, err := vanity(map[string]interface{}{"prefix": address[:8], "startIndex": 4, "maxIterations": 10})
+		require.ErrorContains(t, err, helpers.ErrVanityNotFound.Error())
+		assert.Equal(t, http.StatusNotFound, err.(logical.HTTPCodedError).Code())
+		assert.ErrorContains(t, err, "indexes 4 to 13")
+
+		_, err = vanity(map[string]interface{}{"prefix": address[:8], "maxIterations": maxVanityIterations + 1})
+		require.ErrorContains(t, err, "maxIterations")
+	})
+
+	t.Run("development mode only", func(t *testing.T) {
+		_, err := vanity(map[string]interface{}{"prefix": address[:8], "isDev": false})
+		require.ErrorContains(t, err, helpers.ErrVanityNotDev.Error())
+		assert.Equal(t, http.StatusForbidden, err.(logical.HTTPCodedError).Code())
+	})
+
+	t.Run("invalid searches", func(t *testing.T) {
+		for _, fields := range []map[string]interface{}{
+			{"prefix": ""},
+			{"prefix": "0x-"},
+			{"prefix": "0x0123456789abcdef"},
+			{"prefix": "0x", "pathTemplate": "m/44'/60'/0'/0/0"},
+		} {
+			_, err := vanity(fields)
+			require.ErrorContains(t, err, helpers.ErrInvalidVanity.Error(), fields)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// pathListAddressBook corresponds to LIST addressbook.
+func (b *Backend) pathListAddressBook(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_addressbook"))
+
+	names, err := req.Storage.List(ctx, config.AddressBookStoragePath)
+	if err != nil {
+		backendLogger.Error("list address book", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(names), nil
+}
+
+// pathReadAddressBook corresponds to READ addressbook/<name>.
+func (b *Backend) pathReadAddressBook(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_addressbook"))
+
+	entry, err := helpers.GetAddressBookEntry(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		backendLogger.Error("get address book entry", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: addressBookResponseData(entry),
+	}, nil
+}
+
+// pathWriteAddressBook corresponds to UPDATE addressbook/<name>. The entry replaces the stored one
+// of the same name.
+func (b *Backend) pathWriteAddressBook(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_addressbook"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	name := d.Get("name").(string)
+	coinType := d.Get("coinType").(int)
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	if _, err := adapter.GetInventory(backendLogger).CoinCapabilities(uint16(coinType)); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	tags := d.Get("tags").([]string)
+	for _, tag := range tags {
+		if tag == "" || strings.Contains(tag, helpers.AnyAddressBookTag) {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAddressBookEntry.Error())
+		}
+	}
+	address, err := helpers.NormalizeCounterparty(uint16(coinType), d.Get("address").(string))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	previous, err := helpers.GetAddressBookEntry(ctx, req.Storage, name)
+	if err != nil {
+		backendLogger.Error("get address book entry", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	now := time.Now().UTC()
+	entry := &helpers.AddressBookEntry{
+		Name:      name,
+		CoinType:  uint16(coinType),
+		Address:   address,
+		Tags:      tags,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if previous != nil {
+		entry.CreatedAt = previous.CreatedAt
+	}
+
+	if err := helpers.PutAddressBookEntry(ctx, req.Storage, entry); err != nil {
+		backendLogger.Error("put address book entry", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("address book entry updated", "name", name, "coinType", coinType, "address", address,
+		"tags", tags, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: addressBookResponseData(entry),
+	}, nil
+}
+
+// pathDeleteAddressBook corresponds to DELETE addressbook/<name>.
+func (b *Backend) pathDeleteAddressBook(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_addressbook"))
+
+	name := d.Get("name").(string)
+	if err := helpers.DeleteAddressBookEntry(ctx, req.Storage, name); err != nil {
+		backendLogger.Error("delete address book entry", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("address book entry deleted", "name", name, "entity", req.EntityID)
+	return nil, nil
+}
+
+func addressBookResponseData(entry *helpers.AddressBookEntry) map[string]interface{} {
+	tags := entry.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return map[string]interface{}{
+		"name":      entry.Name,
+		"coinType":  entry.CoinType,
+		"address":   entry.Address,
+		"tags":      tags,
+		"createdAt": formatTime(entry.CreatedAt),
+		"updatedAt": formatTime(entry.UpdatedAt),
+	}
+}
+
+// pathReadAddressBookPolicy corresponds to READ config/addressbook.
+func (b *Backend) pathReadAddressBookPolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_addressbook_policy"))
+
+	policy, err := helpers.GetAddressBookPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get address book policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: addressBookPolicyResponseData(policy),
+	}, nil
+}
+
+// pathWriteAddressBookPolicy corresponds to UPDATE config/addressbook. The policy replaces the stored one.
+func (b *Backend) pathWriteAddressBookPolicy(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_addressbook_policy"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	policy := &helpers.AddressBookPolicy{RequiredTags: make(map[uint16]string)}
+	for key, tag := range d.Get("requiredTags").(map[string]string) {
+		coinType, err := strconv.ParseUint(key, 10, 16)
+		if err != nil || tag == "" {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAddressBookPolicy.Error())
+		}
+		policy.RequiredTags[uint16(coinType)] = tag
+	}
+
+	if err := helpers.PutAddressBookPolicy(ctx, req.Storage, policy); err != nil {
+		backendLogger.Error("put address book policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("address book policy updated", "requiredTags", policy.RequiredTags, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: addressBookPolicyResponseData(policy),
+	}, nil
+}
+
+// pathDeleteAddressBookPolicy corresponds to DELETE config/addressbook.
+func (b *Backend) pathDeleteAddressBookPolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_addressbook_policy"))
+
+	if err := helpers.DeleteAddressBookPolicy(ctx, req.Storage); err != nil {
+		backendLogger.Error("delete address book policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("address book policy deleted", "entity", req.EntityID)
+	return nil, nil
+}
+
+func addressBookPolicyResponseData(policy *helpers.AddressBookPolicy) map[string]interface{} {
+	requiredTags := make(map[string]string, len(policy.RequiredTags))
+	for coinType, tag := range policy.RequiredTags {
+		requiredTags[strconv.Itoa(int(coinType))] = tag
+	}
+	return map[string]interface{}{
+		"requiredTags": requiredTags,
+	}
+}
+
+// checkAddressBook checks the recipients of the payload of sign against the address book policy
+// of coinType. Outputs paying the signing address back, the change of Bitcoin transactions, are not
+// recipients.
+func checkAddressBook(ctx context.Context, s logical.Storage, coinType uint16, payload string, isDev bool,
+	signer string) error {
+	summary := approval.Summarize(coinType, payload, isDev)
+	return checkRecipients(ctx, s, summary, false, func(i int) bool {
+		return helpers.CounterpartyKey(summary.Transfers[i].To) == helpers.CounterpartyKey(signer)
+	})
+}
+
+// checkRecipients checks the transfers of summary against the address book policy of its coin
+// type: every recipient must be an entry with the required tag, and the transfers must be all the
+// request moves. The transfers own reports are the wallet paying itself back, they are not
+// recipients. Signatures valid on any chain are refused under any required tag.
+func checkRecipients(ctx context.Context, s logical.Storage, summary approval.Summary, anyCoinType bool,
+	own func(i int) bool) error {
+	policy, err := helpers.GetAddressBookPolicy(ctx, s)
+	if err != nil || policy == nil {
+		return err
+	}
+	if anyCoinType {
+		if len(policy.RequiredTags) > 0 {
+			return helpers.ErrRecipientsNotDecoded
+		}
+		return nil
+	}
+	tag, ok := policy.RequiredTags[summary.CoinType]
+	if !ok {
+		return nil
+	}
+
+	if !summary.Decoded() {
+		return helpers.ErrRecipientsNotDecoded
+	}
+	index, err := helpers.GetAddressBookIndex(ctx, s, summary.CoinType)
+	if err != nil {
+		return err
+	}
+	for i, transfer := range summary.Transfers {
+		if own != nil && own(i) {
+			continue
+		}
+		if entry := index.Lookup(transfer.To); entry == nil || !entry.HasTag(tag) {
+			return fmt.Errorf("%w %q: %s", helpers.ErrRecipientNotInBook, tag, transfer.To)
+		}
+	}
+	return nil
+}
+
+// checkPSBTRecipients checks the outputs of the PSBT payload against the address book policy of
+// coinType, but the change of the signing wallets that walletOutputs returns, computed only when
+// the policy restricts the coin type
+func checkPSBTRecipients(ctx context.Context, s logical.Storage, coinType uint16, payload string, isDev bool,
+	walletOutputs func() ([]int, error)) error {
+	policy, err := helpers.GetAddressBookPolicy(ctx, s)
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if policy == nil {
+		return nil
+	}
+	if _, ok := policy.RequiredTags[coinType]; !ok {
+		return nil
+	}
+
+	own, err := walletOutputs()
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := checkRecipients(ctx, s, approval.Summarize(coinType, payload, isDev), false, func(i int) bool {
+		return slices.Contains(own, i)
+	}); err != nil {
+		return helpers.CodedError(http.StatusForbidden, err)
+	}
+	return nil
+}
+
+// withAddressBook checks the recipients of the intents intentOf reads against the address book
+// policy before op signs them. The intents of PSBTs are left to op, which tells the change of the
+// signing wallets apart with their keys.
+func (b *Backend) withAddressBook(intentOf signIntentFunc, op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "address_book"))
+
+		intent, err := intentOf(d)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		// invalid fields are left to op to reject
+		if intent == nil || intent.walletChange {
+			return op(ctx, req, d)
+		}
+		if err := checkRecipients(ctx, req.Storage, intent.summary, intent.anyCoinType, nil); err != nil {
+			backendLogger.Warn("recipients rejected", "error", err, "uuids", intent.uuids, "path", req.Path)
+			return nil, helpers.CodedError(http.StatusForbidden, err)
+		}
+		return op(ctx, req, d)
+	}
+}
+
+// labelSummary names the recipients of summary that are in the address book
+func labelSummary(ctx context.Context, s logical.Storage, summary approval.Summary) error {
+	index, err := helpers.GetAddressBookIndex(ctx, s, summary.CoinType)
+	if err != nil {
+		return err
+	}
+	summary.Label(func(address string) string {
+		if entry := index.Lookup(address); entry != nil {
+			return entry.Name
+		}
+		return ""
+	})
+	return nil
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+func TestBackend_HandleRequest_AddressBook(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	const recipient = "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"
+	sign := func(data string) (*logical.Response, error) {
+		return request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath,
+			"payload": `{"nonce":1,"value":1000,"gasLimit":21000,"gasPrice":1,"chainId":1,"to":"` + recipient +
+				`","data":"` + data + `"}`,
+		})
+	}
+
+	t.Run("invalid entries are rejected", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{
+			{"coinType": 60, "address": "742d35Cc6634C0532925a3b8D359A5C5119e32C8"},
+			{"coinType": 0, "address": recipient},
+			{"coinType": 60, "address": recipient, "tags": "exchange,*"},
+			{"coinType": 70000, "address": recipient},
+		} {
+			_, err := request(logical.UpdateOperation, "addressbook/binance-hot-wallet", data)
+			require.Error(t, err, data)
+		}
+	})
+
+	resp, err := request(logical.UpdateOperation, "addressbook/binance-hot-wallet", map[string]interface{}{
+		"coinType": 60, "address": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "tags": "exchange,hot",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress(recipient).Hex(), resp.Data["address"], "stored checksummed")
+	assert.Equal(t, []string{"exchange", "hot"}, resp.Data["tags"])
+
+	_, err = request(logical.UpdateOperation, "addressbook/treasury", map[string]interface{}{
+		"coinType": 0, "address": "bc1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq9e75rs",
+	})
+	require.NoError(t, err)
+	resp, err = request(logical.ListOperation, "addressbook/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"binance-hot-wallet", "treasury"}, resp.Data["keys"])
+
+	t.Run("without policy the recipients are not restricted", func(t *testing.T) {
+		_, err := sign("0x12345678")
+		require.NoError(t, err)
+	})
+
+	_, err = request(logical.UpdateOperation, "config/addressbook", map[string]interface{}{
+		"requiredTags": map[string]interface{}{"60": "exchange"},
+	})
+	require.NoError(t, err)
+
+	t.Run("recipients with the tag are signed", func(t *testing.T) {
+		resp, err := sign("0x")
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+	})
+
+	t.Run("undecoded recipients are refused", func(t *testing.T) {
+		_, err := sign("0x12345678")
+		require.ErrorContains(t, err, helpers.ErrRecipientsNotDecoded.Error())
+	})
+
+	t.Run("recipients without the tag are refused", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/addressbook", map[string]interface{}{
+			"requiredTags": map[string]interface{}{"60": "cold"},
+		})
+		require.NoError(t, err)
+		_, err = sign("0x")
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+
+		_, err = request(logical.DeleteOperation, "addressbook/binance-hot-wallet", nil)
+		require.NoError(t, err)
+		_, err = request(logical.UpdateOperation, "config/addressbook", map[string]interface{}{
+			"requiredTags": map[string]interface{}{"60": helpers.AnyAddressBookTag},
+		})
+		require.NoError(t, err)
+		_, err = sign("0x")
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+	})
+
+	t.Run("every signing path checks its recipients", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/addressbook", map[string]interface{}{
+			"requiredTags": map[string]interface{}{
+				"60": helpers.AnyAddressBookTag, "0": helpers.AnyAddressBookTag, "501": helpers.AnyAddressBookTag,
+			},
+		})
+		require.NoError(t, err)
+
+		spl := map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": splTestPath, "mint": splTestMint,
+			"recipient": splTestRecipient, "amount": "2500000", "recentBlockhash": splTestBlockhash,
+		}
+		_, err = request(logical.UpdateOperation, "sign/spl-transfer", spl)
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+		_, err = request(logical.UpdateOperation, "addressbook/solana-payee", map[string]interface{}{
+			"coinType": 501, "address": splTestRecipient,
+		})
+		require.NoError(t, err)
+		_, err = request(logical.UpdateOperation, "sign/spl-transfer", spl)
+		require.NoError(t, err)
+
+		// what a user operation runs or a digest signs is not decoded
+		_, err = request(logical.UpdateOperation, "sign/userop", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "chainId": "1",
+			"entryPoint": "0x0000000071727De22E5E9d8BAf0edAc6f37da032",
+			"sender":     "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326", "nonce": "0", "callData": "0xb61d27f6",
+			"callGasLimit": "100000", "verificationGasLimit": "100000", "preVerificationGas": "21000",
+			"maxFeePerGas": "30000000000", "maxPriorityFeePerGas": "1000000000",
+		})
+		require.ErrorContains(t, err, helpers.ErrRecipientsNotDecoded.Error())
+		_, err = request(logical.UpdateOperation, "sign/digest", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath,
+			"digest": "0x" + strings.Repeat("ab", 32),
+		})
+		require.ErrorContains(t, err, helpers.ErrRecipientsNotDecoded.Error())
+
+		// the outputs paying the wallet back are its change
+		xpub, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0,
+		})
+		require.NoError(t, err)
+		descriptors := []string{xpub.Data["descriptors"].(lib.Descriptors).Receive}
+		resp, err := request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": descriptors,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, resp.Data["signedInputs"])
+		_, err = request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/1'/0/7"), "descriptors": descriptors,
+		})
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+	})
+
+	_, err = request(logical.DeleteOperation, "config/addressbook", nil)
+	require.NoError(t, err)
+	resp, err = request(logical.ReadOperation, "config/addressbook", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestBackend_HandleRequest_AddressBookApprovals(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var message map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &message))
+		mu.Lock()
+		messages = append(messages, message["text"].(string))
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	_, err := request(logical.UpdateOperation, "config/approvals", map[string]interface{}{
+		"thresholds": map[string]interface{}{"60": "1"}, "provider": approval.ProviderSlack, "webhookUrl": webhook.URL,
+	})
+	require.NoError(t, err)
+	_, err = request(logical.UpdateOperation, "addressbook/binance-hot-wallet", map[string]interface{}{
+		"coinType": 60, "address": "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8",
+	})
+	require.NoError(t, err)
+
+	held, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+	})
+	require.NoError(t, err)
+	transfers := held.Data["summary"].(map[string]interface{})["transfers"].([]map[string]interface{})
+	require.Len(t, transfers, 1)
+	assert.Equal(t, "binance-hot-wallet", transfers[0]["name"])
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "to binance-hot-wallet (0x742d35Cc6634C0532925a3b8D359A5C5119e32C8)")
+}
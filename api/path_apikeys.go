@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathListAPIKeys corresponds to LIST apikeys.
+func (b *Backend) pathListAPIKeys(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_apikeys"))
+
+	names, err := req.Storage.List(ctx, config.APIKeysStoragePath)
+	if err != nil {
+		backendLogger.Error("list apikeys", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(names), nil
+}
+
+// pathReadAPIKey corresponds to READ apikeys/<name>. The secret is never returned.
+func (b *Backend) pathReadAPIKey(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_apikey"))
+
+	key, err := helpers.GetAPIKey(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		backendLogger.Error("get apikey", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: apiKeyResponseData(key),
+	}, nil
+}
+
+// pathWriteAPIKey corresponds to UPDATE apikeys/<name>. It mints a key, replacing the
+// previous one of the same name, and returns its value once.
+func (b *Backend) pathWriteAPIKey(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_apikey"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	name := d.Get("name").(string)
+	coinTypes, err := coinTypesFromField(d, "coinTypes")
+	if err != nil {
+		backendLogger.Error("validate coin types", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var uuidPatterns, operations []string
+	if v, ok := d.GetOk("uuidPatterns"); ok {
+		uuidPatterns = v.([]string)
+	}
+	if v, ok := d.GetOk("operations"); ok {
+		operations = v.([]string)
+	}
+	var ttl time.Duration
+	if v, ok := d.GetOk("ttl"); ok {
+		ttl = time.Duration(v.(int)) * time.Second
+	}
+
+	key, value, err := helpers.NewAPIKey(name, uuidPatterns, coinTypes, operations, ttl)
+	if err != nil {
+		backendLogger.Error("create apikey", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.APIKeysStoragePath+name, key)
+	if err != nil {
+		backendLogger.Error("encode apikey", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put apikey", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("apikey minted", "name", name, "operations", operations, "coinTypes", coinTypes)
+
+	data := apiKeyResponseData(key)
+	data["apiKey"] = value
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathDeleteAPIKey corresponds to DELETE apikeys/<name>. Requests using the key are rejected immediately.
+func (b *Backend) pathDeleteAPIKey(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_apikey"))
+
+	name := d.Get("name").(string)
+	if err := req.Storage.Delete(ctx, config.APIKeysStoragePath+name); err != nil {
+		backendLogger.Error("delete apikey", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("apikey revoked", "name", name)
+
+	return nil, nil
+}
+
+func apiKeyResponseData(key *helpers.APIKey) map[string]interface{} {
+	uuidPatterns, coinTypes, operations := key.UUIDPatterns, key.CoinTypes, key.Operations
+	if uuidPatterns == nil {
+		uuidPatterns = []string{}
+	}
+	if coinTypes == nil {
+		coinTypes = []uint16{}
+	}
+	if operations == nil {
+		operations = []string{}
+	}
+	return map[string]interface{}{
+		"name":         key.Name,
+		"uuidPatterns": uuidPatterns,
+		"coinTypes":    coinTypes,
+		"operations":   operations,
+		"createdAt":    formatTime(key.CreatedAt),
+		"expiresAt":    formatTime(key.ExpiresAt),
+	}
+}
+
+// withAPIKey checks the apiKey field of requests to operation against the scope of the key.
+// The field is mandatory once config/features has apiKeysRequired set, and checked whenever provided.
+// The request also holds one of the concurrent request slots of config/quotas while it is served.
+func (b *Backend) withAPIKey(operation string, op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "apikey"), slog.String("operation", operation))
+
+		release, err := b.acquireRequestSlot(ctx, req)
+		if err != nil {
+			backendLogger.Warn("request rejected", "error", err)
+			return nil, err
+		}
+		defer release()
+
+		features, err := helpers.GetFeatures(ctx, req)
+		if err != nil {
+			backendLogger.Error("get features", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+
+		value, _ := d.GetOk("apiKey")
+		if value == nil || value.(string) == "" {
+			if features.APIKeysRequired {
+				backendLogger.Warn("request rejected, apiKey missing")
+				return nil, logical.CodedError(http.StatusForbidden, helpers.ErrAPIKeyRequired.Error())
+			}
+			return op(ctx, req, d)
+		}
+
+		key, err := helpers.LookupAPIKey(ctx, req.Storage, value.(string))
+		if err != nil {
+			backendLogger.Error("lookup apikey", "error", err)
+			return nil, logical.CodedError(http.StatusForbidden, err.Error())
+		}
+
+		// the requests acting on several users need the key to cover every one
+		uuids := requestUUIDs(d)
+		if len(uuids) == 0 {
+			uuids = []string{""}
+		}
+		for _, uuid := range uuids {
+			if err := key.Authorize(operation, uuid, requestCoinType(operation, d), time.Now()); err != nil {
+				backendLogger.Error("authorize apikey", "error", err, "name", key.Name, "uuid", uuid)
+				return nil, logical.CodedError(http.StatusForbidden, err.Error())
+			}
+		}
+		return op(ctx, req, d)
+	}
+}
+
+// requestUUIDs returns the users a request acts on: its uuid and, for the requests signing for
+// several users, its uuids, without duplicates
+func requestUUIDs(d *framework.FieldData) []string {
+	var uuids []string
+	if uuid, ok := d.GetOk("uuid"); ok && uuid.(string) != "" {
+		uuids = append(uuids, uuid.(string))
+	}
+	if more, ok := d.GetOk("uuids"); ok {
+		for _, uuid := range more.([]string) {
+			if uuid != "" && !slices.Contains(uuids, uuid) {
+				uuids = append(uuids, uuid)
+			}
+		}
+	}
+	return uuids
+}
+
+// requestCoinType returns the coin type a request to operation acts on, or nil for operations without one
+func requestCoinType(operation string, d *framework.FieldData) *uint16 {
+	if operation == lib.OperationSPLTransfer {
+		coinType := slip44.Solana
+		return &coinType
+	}
+	if operation == lib.OperationSignPSBT || operation == lib.OperationBuildBTCTx || operation == lib.OperationMultisig {
+		coinType := uint16(slip44.Bitcoin)
+		if isDev, ok := d.GetOk("isDev"); ok && isDev.(bool) {
+			coinType = slip44.TestNet
+		}
+		return &coinType
+	}
+	if operation == lib.OperationSignSafeTx || operation == lib.OperationSignUserOp ||
+		operation == lib.OperationSignPermit {
+		// the coin type of EVM typed data defaults to Ethereum
+		coinType := uint16(d.Get("coinType").(int))
+		return &coinType
+	}
+	if v, ok := d.GetOk("coinType"); ok {
+		coinType := uint16(v.(int))
+		return &coinType
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Helper function to create a proper framework.FieldData for apikeys/<name> endpoint
+func createAPIKeyFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"name":         {Type: framework.TypeString},
+			"uuidPatterns": {Type: framework.TypeCommaStringSlice},
+			"coinTypes":    {Type: framework.TypeCommaIntSlice},
+			"operations":   {Type: framework.TypeCommaStringSlice},
+			"ttl":          {Type: framework.TypeDurationSecond},
+		},
+	}
+}
+
+func mintAPIKey(t *testing.T, b *Backend, storage logical.Storage, data map[string]interface{}) string {
+	resp, err := b.pathWriteAPIKey(context.Background(), &logical.Request{Storage: storage, Data: data},
+		createAPIKeyFieldData(data))
+	require.NoError(t, err)
+	return resp.Data["apiKey"].(string)
+}
+
+// callWithAPIKey runs a stub operation behind withAPIKey, returning the error of the key check
+func callWithAPIKey(b *Backend, storage logical.Storage, operation string, data map[string]interface{}) error {
+	fieldData := &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":     {Type: framework.TypeString},
+			"uuids":    {Type: framework.TypeCommaStringSlice},
+			"coinType": {Type: framework.TypeInt},
+			"apiKey":   {Type: framework.TypeString},
+		},
+	}
+	stub := func(context.Context, *logical.Request, *framework.FieldData) (*logical.Response, error) {
+		return &logical.Response{}, nil
+	}
+	_, err := b.withAPIKey(operation, stub)(context.Background(), &logical.Request{Storage: storage, Data: data},
+		fieldData)
+	return err
+}
+
+func requireAPIKeyRequired(t *testing.T, storage logical.Storage) {
+	entry, err := logical.StorageEntryJSON(config.FeaturesStorageKey, helpers.Features{APIKeysRequired: true})
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), entry))
+}
+
+func TestBackend_PathAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mint, read, list and revoke", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := &logical.InmemStorage{}
+		value := mintAPIKey(t, b, storage, map[string]interface{}{
+			"name":       "payouts",
+			"coinTypes":  []int{int(slip44.Ether)},
+			"operations": []string{lib.OperationSign},
+		})
+		assert.Regexp(t, `^payouts\.[0-9a-f]{64}$`, value)
+
+		got, err := b.pathReadAPIKey(ctx, &logical.Request{Storage: storage},
+			createAPIKeyFieldData(map[string]interface{}{"name": "payouts"}))
+		require.NoError(t, err)
+		assert.Equal(t, []uint16{slip44.Ether}, got.Data["coinTypes"])
+		assert.Equal(t, []string{lib.OperationSign}, got.Data["operations"])
+		assert.NotContains(t, got.Data, "apiKey")
+
+		list, err := b.pathListAPIKeys(ctx, &logical.Request{Storage: storage}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"payouts"}, list.Data["keys"])
+
+		_, err = b.pathDeleteAPIKey(ctx, &logical.Request{Storage: storage},
+			createAPIKeyFieldData(map[string]interface{}{"name": "payouts"}))
+		require.NoError(t, err)
+		err = callWithAPIKey(b, storage, lib.OperationSign,
+			map[string]interface{}{"uuid": signTestUUID, "coinType": int(slip44.Ether), "apiKey": value})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidAPIKey.Error())
+	})
+
+	t.Run("unknown operation", func(t *testing.T) {
+		data := map[string]interface{}{"name": "payouts", "operations": []string{"export"}}
+		_, err := createSignTestBackend(t).pathWriteAPIKey(ctx, &logical.Request{Storage: &logical.InmemStorage{},
+			Data: data}, createAPIKeyFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUnknownOperation.Error())
+	})
+}
+
+func TestBackend_WithAPIKey(t *testing.T) {
+	b := createSignTestBackend(t)
+	storage := &logical.InmemStorage{}
+	scoped := mintAPIKey(t, b, storage, map[string]interface{}{
+		"name":         "payouts",
+		"uuidPatterns": []string{"cq*"},
+		"coinTypes":    []int{int(slip44.Ether)},
+		"operations":   []string{lib.OperationSign, lib.OperationAddress},
+	})
+	expired := mintAPIKey(t, b, storage, map[string]interface{}{"name": "expired", "ttl": 1})
+	key, err := helpers.GetAPIKey(context.Background(), storage, "expired")
+	require.NoError(t, err)
+	key.ExpiresAt = time.Now().Add(-time.Minute)
+	entry, err := logical.StorageEntryJSON(config.APIKeysStoragePath+"expired", key)
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), entry))
+
+	tests := []struct {
+		name      string
+		operation string
+		data      map[string]interface{}
+		wantErr   string
+	}{
+		{
+			name:      "optional when not required",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "other", "coinType": int(slip44.Bitcoin)},
+		},
+		{
+			name:      "in scope",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Ether), "apiKey": scoped},
+		},
+		{
+			name:      "wrong secret",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Ether), "apiKey": "payouts.00"},
+			wantErr:   helpers.ErrInvalidAPIKey.Error(),
+		},
+		{
+			name:      "operation out of scope",
+			operation: lib.OperationAddressBatch,
+			data:      map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Ether), "apiKey": scoped},
+			wantErr:   helpers.ErrAPIKeyScope.Error(),
+		},
+		{
+			name:      "coin type out of scope",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Bitcoin), "apiKey": scoped},
+			wantErr:   helpers.ErrAPIKeyScope.Error(),
+		},
+		{
+			name:      "uuid out of scope",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "other", "coinType": int(slip44.Ether), "apiKey": scoped},
+			wantErr:   helpers.ErrAPIKeyScope.Error(),
+		},
+		{
+			name:      "every uuid in scope",
+			operation: lib.OperationSign,
+			data: map[string]interface{}{
+				"uuids": []string{"cqabc", "cqdef"}, "coinType": int(slip44.Ether), "apiKey": scoped,
+			},
+		},
+		{
+			name:      "one of the uuids out of scope",
+			operation: lib.OperationSign,
+			data: map[string]interface{}{
+				"uuids": []string{"cqabc", "other"}, "coinType": int(slip44.Ether), "apiKey": scoped,
+			},
+			wantErr: helpers.ErrAPIKeyScope.Error(),
+		},
+		{
+			name:      "expired",
+			operation: lib.OperationSign,
+			data:      map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Ether), "apiKey": expired},
+			wantErr:   helpers.ErrAPIKeyExpired.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := callWithAPIKey(b, storage, tt.operation, tt.data)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			var coded logical.HTTPCodedError
+			require.ErrorAs(t, err, &coded)
+			assert.Equal(t, http.StatusForbidden, coded.Code())
+		})
+	}
+
+	t.Run("required by the mount", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		requireAPIKeyRequired(t, storage)
+
+		err := callWithAPIKey(b, storage, lib.OperationSign,
+			map[string]interface{}{"uuid": "cqabc", "coinType": int(slip44.Ether)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrAPIKeyRequired.Error())
+	})
+
+	t.Run("coin scoped keys cannot sign digests", func(t *testing.T) {
+		coinScoped := mintAPIKey(t, b, storage, map[string]interface{}{
+			"name":      "ether-only",
+			"coinTypes": []int{int(slip44.Ether)},
+		})
+		err := callWithAPIKey(b, storage, lib.OperationSignDigest,
+			map[string]interface{}{"uuid": "cqabc", "apiKey": coinScoped})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "coinType")
+	})
+}
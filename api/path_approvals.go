@@ -0,0 +1,619 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/webhook"
+)
+
+// approvalNotifyTimeout bounds the post of a notification, the sign request waits for it
+const approvalNotifyTimeout = 5 * time.Second
+
+// approvalFingerprintFields are the sign fields identifying the request granted by an approval
+//
+//nolint:gochecknoglobals // read-only lookup table
+var approvalFingerprintFields = []string{
+	"uuid", "derivationPath", "preset", "account", "index", "coinType", "payload", "isDev", "complete", "overrideFee",
+	"travelRule",
+}
+
+// signFingerprint returns the fingerprint of the sign request of d, by its approvalFingerprintFields
+func signFingerprint(d *framework.FieldData) (string, error) {
+	fields := make(map[string]interface{}, len(approvalFingerprintFields))
+	for _, field := range approvalFingerprintFields {
+		if _, ok := d.Schema[field]; ok {
+			fields[fingerprintName(field)] = d.Get(field)
+		}
+	}
+	return helpers.ApprovalFingerprint(fields)
+}
+
+// fingerprintName returns the name field is fingerprinted under: its legacy name when it was renamed,
+// so the approvals pending before the rename still match
+func fingerprintName(field string) string {
+	for legacy, current := range legacyFields["sign"] {
+		if current == field {
+			return legacy
+		}
+	}
+	return field
+}
+
+// pathReadApprovalConfig corresponds to READ config/approvals.
+func (b *Backend) pathReadApprovalConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_approval_config"))
+
+	approvals, err := helpers.GetApprovalConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get approval config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if approvals == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: approvalConfigResponseData(approvals),
+	}, nil
+}
+
+// pathWriteApprovalConfig corresponds to UPDATE config/approvals. The policy replaces the stored one.
+func (b *Backend) pathWriteApprovalConfig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_approval_config"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	approvals := &helpers.ApprovalConfig{
+		Thresholds:     make(map[uint16]string),
+		TTL:            time.Duration(d.Get("ttl").(int)) * time.Second,
+		Provider:       d.Get("provider").(string),
+		WebhookURL:     d.Get("webhookUrl").(string),
+		SigningSecret:  d.Get("signingSecret").(string),
+		LinkURL:        d.Get("linkUrl").(string),
+		ApproverGroups: d.Get("approverGroups").([]string),
+		ApproverClaims: d.Get("approverClaims").(map[string]string),
+		Format:         d.Get("format").(string),
+		Template:       d.Get("template").(string),
+	}
+	for key, value := range d.Get("thresholds").(map[string]string) {
+		coinType, err := strconv.ParseUint(key, 10, 16)
+		amount, ok := new(big.Int).SetString(value, 10)
+		if err != nil || !ok || amount.Sign() < 0 {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidApprovalConfig.Error())
+		}
+		approvals.Thresholds[uint16(coinType)] = amount.String()
+	}
+	if approvals.TTL <= 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidApprovalConfig.Error())
+	}
+	if approvals.Provider != approval.ProviderSlack && approvals.Provider != approval.ProviderTeams {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, approval.ErrUnknownProvider.Error())
+	}
+	if err := rpc.ValidateURL(approvals.WebhookURL); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if _, err := webhook.NewTransformer(approvals.Format, approvals.Template); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if approvals.LinkURL != "" {
+		if err := rpc.ValidateURL(approvals.LinkURL); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	if err := helpers.PutApprovalConfig(ctx, req.Storage, approvals); err != nil {
+		backendLogger.Error("put approval config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("approval policy updated", "thresholds", approvals.Thresholds,
+		"provider", approvals.Provider, "host", rpc.Host(approvals.WebhookURL), "approverGroups", approvals.ApproverGroups,
+		"approverClaims", approvals.ApproverClaims, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: approvalConfigResponseData(approvals),
+	}, nil
+}
+
+// pathDeleteApprovalConfig corresponds to DELETE config/approvals. The pending approvals are kept
+// until they expire, sign requests are no longer held.
+func (b *Backend) pathDeleteApprovalConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_approval_config"))
+
+	if err := helpers.DeleteApprovalConfig(ctx, req.Storage); err != nil {
+		backendLogger.Error("delete approval config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("approval policy deleted", "entity", req.EntityID)
+	return nil, nil
+}
+
+// approvalConfigResponseData reports the policy without its signing secret, and only the host of
+// the webhook URL, which holds the credentials of the webhook
+func approvalConfigResponseData(approvals *helpers.ApprovalConfig) map[string]interface{} {
+	thresholds := make(map[string]string, len(approvals.Thresholds))
+	for coinType, amount := range approvals.Thresholds {
+		thresholds[strconv.Itoa(int(coinType))] = amount
+	}
+	return map[string]interface{}{
+		"thresholds":       thresholds,
+		"ttl":              int(approvals.TTL.Seconds()),
+		"provider":         approvals.Provider,
+		"webhookHost":      rpc.Host(approvals.WebhookURL),
+		"linkUrl":          approvals.LinkURL,
+		"signingSecretSet": approvals.SigningSecret != "",
+		"approverGroups":   approvals.ApproverGroups,
+		"approverClaims":   approvals.ApproverClaims,
+		"format":           webhookFormat(approvals.Format),
+		"template":         approvals.Template,
+	}
+}
+
+// pathListApprovals corresponds to LIST approvals.
+func (b *Backend) pathListApprovals(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_approvals"))
+
+	ids, err := req.Storage.List(ctx, config.ApprovalsStoragePath)
+	if err != nil {
+		backendLogger.Error("list approvals", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(ids), nil
+}
+
+// pathReadApproval corresponds to READ approvals/<id>.
+func (b *Backend) pathReadApproval(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_approval"))
+
+	a, err := helpers.GetApproval(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		backendLogger.Error("get approval", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if a == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: approvalResponseData(a),
+	}, nil
+}
+
+// pathDecideApproval serves approvals/<id>/approve and approvals/<id>/reject, the decision is
+// recorded with the entity of the caller. Approvers must be distinct from the requester and hold the
+// approver identity of config/approvals; anyone allowed on the path can reject.
+func (b *Backend) pathDecideApproval(action string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "path_"+action+"_approval"))
+
+		approvals, err := helpers.GetApprovalConfig(ctx, req.Storage)
+		if err != nil {
+			backendLogger.Error("get approval config", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		authorize := func(a *helpers.Approval) error {
+			if action != approval.ActionApprove {
+				return nil
+			}
+			return b.authorizeApprover(approvals, a, req.EntityID)
+		}
+
+		a, err := b.decideApproval(ctx, req.Storage, d.Get("id").(string), action, req.EntityID, time.Now(), authorize)
+		if err != nil {
+			backendLogger.Error("decide approval", "error", err, "entity", req.EntityID)
+			return nil, err
+		}
+		backendLogger.Info("approval decided", "id", a.ID, "status", a.Status, "entity", req.EntityID)
+
+		return &logical.Response{
+			Data: approvalResponseData(a),
+		}, nil
+	}
+}
+
+// authorizeApprover checks that the entity may approve a: it is not the requester and, when the
+// policy names approver groups or claims, it is a member of one of the groups and has an OIDC or
+// JWT alias with all the claims
+func (b *Backend) authorizeApprover(approvals *helpers.ApprovalConfig, a *helpers.Approval, entityID string) error {
+	if entityID != "" && entityID == a.Requester {
+		return logical.CodedError(http.StatusForbidden, helpers.ErrSelfApproval.Error())
+	}
+	if approvals == nil || !approvals.RequiresApproverIdentity() {
+		return nil
+	}
+	if entityID == "" || b.System() == nil {
+		return logical.CodedError(http.StatusForbidden, helpers.ErrApproverIdentity.Error())
+	}
+
+	entity, err := b.System().EntityInfo(entityID)
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if entity == nil || entity.Disabled {
+		return logical.CodedError(http.StatusForbidden, helpers.ErrApproverIdentity.Error())
+	}
+
+	if len(approvals.ApproverGroups) > 0 {
+		groups, err := b.System().GroupsForEntity(entityID)
+		if err != nil {
+			return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if !slices.ContainsFunc(groups, func(group *logical.Group) bool {
+			return slices.Contains(approvals.ApproverGroups, group.Name)
+		}) {
+			return logical.CodedError(http.StatusForbidden, fmt.Sprintf("%s: groups", helpers.ErrApproverIdentity))
+		}
+	}
+
+	if len(approvals.ApproverClaims) > 0 && !slices.ContainsFunc(entity.Aliases, func(alias *logical.Alias) bool {
+		return hasApproverClaims(alias, approvals.ApproverClaims)
+	}) {
+		return logical.CodedError(http.StatusForbidden, fmt.Sprintf("%s: claims", helpers.ErrApproverIdentity))
+	}
+	return nil
+}
+
+// hasApproverClaims reports whether alias was issued by an OIDC or JWT auth mount with all claims,
+// the claims mapped by its role are the metadata of the alias
+func hasApproverClaims(alias *logical.Alias, claims map[string]string) bool {
+	if alias.MountType != "jwt" && alias.MountType != "oidc" {
+		return false
+	}
+	for claim, value := range claims {
+		if alias.Metadata[claim] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// pathApprovalCallback corresponds to UPDATE approvals/callback. It takes the action of a signed
+// callback of the chat platform of config/approvals, relayed as received: the raw body, the Slack
+// timestamp and the signature header.
+func (b *Backend) pathApprovalCallback(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_approval_callback"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	approvals, err := helpers.GetApprovalConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get approval config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if approvals == nil || approvals.SigningSecret == "" {
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrApprovalsDisabled.Error())
+	}
+
+	now := time.Now()
+	callback, err := approval.VerifyCallback(approvals.Provider, approvals.SigningSecret,
+		d.Get("timestamp").(string), d.Get("body").(string), d.Get("signature").(string), now)
+	if err != nil {
+		backendLogger.Warn("callback rejected", "error", err, "provider", approvals.Provider)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	// chat users have no Vault identity to check against the approver identity of the policy
+	authorize := func(*helpers.Approval) error {
+		if callback.Action == approval.ActionApprove && approvals.RequiresApproverIdentity() {
+			return logical.CodedError(http.StatusForbidden, helpers.ErrApproverIdentity.Error())
+		}
+		return nil
+	}
+	decider := approvals.Provider + ":" + callback.User
+	a, err := b.decideApproval(ctx, req.Storage, callback.ID, callback.Action, decider, now, authorize)
+	if err != nil {
+		backendLogger.Error("decide approval", "error", err)
+		return nil, err
+	}
+	backendLogger.Info("approval decided", "id", a.ID, "status", a.Status, "decider", decider)
+
+	return &logical.Response{
+		Data: approvalResponseData(a),
+	}, nil
+}
+
+// decideApproval records the action of decider on the approval id, once authorize allowed it
+func (b *Backend) decideApproval(ctx context.Context, s logical.Storage, id, action, decider string,
+	now time.Time, authorize func(*helpers.Approval) error) (*helpers.Approval, error) {
+	b.approvalMu.Lock()
+	defer b.approvalMu.Unlock()
+
+	a, err := helpers.GetApproval(ctx, s, id)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if a == nil {
+		return nil, logical.CodedError(http.StatusNotFound, helpers.ErrUnknownApproval.Error())
+	}
+	if err := authorize(a); err != nil {
+		return nil, err
+	}
+	if err := a.Decide(action, decider, now); err != nil {
+		return nil, logical.CodedError(http.StatusConflict, err.Error())
+	}
+	if err := helpers.PutApproval(ctx, s, a); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return a, nil
+}
+
+func approvalResponseData(a *helpers.Approval) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        a.ID,
+		"uuid":      a.UUID,
+		"path":      a.Path,
+		"status":    a.Status,
+		"summary":   summaryResponseData(a.Summary),
+		"requester": a.Requester,
+		"decider":   a.Decider,
+		"notified":  a.Notified,
+		"createdAt": formatTime(a.CreatedAt),
+		"decidedAt": formatTime(a.DecidedAt),
+		"expiresAt": formatTime(a.ExpiresAt),
+	}
+}
+
+func summaryResponseData(summary approval.Summary) map[string]interface{} {
+	transfers := make([]map[string]interface{}, 0, len(summary.Transfers))
+	for _, transfer := range summary.Transfers {
+		t := map[string]interface{}{"to": transfer.To, "amount": transfer.Amount}
+		if transfer.Name != "" {
+			t["name"] = transfer.Name
+		}
+		if transfer.Token != "" {
+			t["token"] = transfer.Token
+		}
+		transfers = append(transfers, t)
+	}
+	data := map[string]interface{}{
+		"coinType":  summary.CoinType,
+		"transfers": transfers,
+		"value":     "",
+	}
+	if summary.Value != nil {
+		data["value"] = summary.Value.String()
+	}
+	if summary.Memo != "" {
+		data["memo"] = summary.Memo
+	}
+	return data
+}
+
+// withApproval holds the signing requests of op needing an approval under config/approvals, by
+// the intent intentOf reads: the request is stored, the approvers are notified and an approvalId
+// is returned instead of the signature. The request sent again with the approvalId once approved
+// is signed, once.
+func (b *Backend) withApproval(intentOf signIntentFunc, op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "approval"))
+
+		approvals, err := helpers.GetApprovalConfig(ctx, req.Storage)
+		if err != nil {
+			backendLogger.Error("get approval config", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if approvals == nil {
+			return op(ctx, req, d)
+		}
+		intent, err := intentOf(d)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		// invalid fields are left to op to reject
+		if intent == nil || !approvalRequired(approvals, intent) {
+			return op(ctx, req, d)
+		}
+		// the approvers see the address book names of the recipients
+		if err := labelSummary(ctx, req.Storage, intent.summary); err != nil {
+			backendLogger.Error("label summary", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+
+		id := d.Get("approvalId").(string)
+		if id == "" {
+			return b.holdForApproval(ctx, req, approvals, intent)
+		}
+		if err := b.useApproval(ctx, req.Storage, id, intent.fingerprint, time.Now()); err != nil {
+			backendLogger.Warn("approval rejected", "error", err, "id", id)
+			return nil, err
+		}
+		backendLogger.Info("approval used", "id", id)
+
+		resp, err := op(ctx, req, d)
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		resp.Data["approvalId"] = id
+		return resp, nil
+	}
+}
+
+// approvalRequired reports whether the request of intent needs an approval: it moves at least the
+// threshold of its coin type, or an amount that cannot be decoded. Signatures valid on any chain
+// need one under any threshold.
+func approvalRequired(approvals *helpers.ApprovalConfig, intent *signIntent) bool {
+	if intent.anyCoinType {
+		return len(approvals.Thresholds) > 0
+	}
+	threshold, ok := approvals.Thresholds[intent.summary.CoinType]
+	if !ok {
+		return false
+	}
+	minimum, _ := new(big.Int).SetString(threshold, 10)
+	return intent.summary.Value == nil || minimum == nil || intent.summary.Value.Cmp(minimum) >= 0
+}
+
+// holdForApproval stores the request of intent as a pending approval and notifies the approvers. A
+// failed notification is logged and reported, the approval can still be decided.
+func (b *Backend) holdForApproval(ctx context.Context, req *logical.Request, approvals *helpers.ApprovalConfig,
+	intent *signIntent) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "approval"))
+
+	now := time.Now().UTC()
+	summary := intent.summary
+	a := &helpers.Approval{
+		ID:          helpers.NewUUID(),
+		Fingerprint: intent.fingerprint,
+		UUID:        strings.Join(intent.uuids, ","),
+		Path:        intent.path,
+		Summary:     summary,
+		Status:      helpers.ApprovalPending,
+		Requester:   req.EntityID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(approvals.TTL),
+	}
+
+	notification := approval.Notification{
+		ID:        a.ID,
+		UUID:      a.UUID,
+		Path:      a.Path,
+		Summary:   summary,
+		ExpiresAt: a.ExpiresAt,
+		LinkURL:   approvals.LinkURL,
+	}
+	body, contentType, err := notificationBody(approvals, notification, webhookSource(req))
+	if err == nil {
+		notifyCtx, cancel := context.WithTimeout(ctx, approvalNotifyTimeout)
+		err = approval.Send(notifyCtx, &http.Client{Timeout: approvalNotifyTimeout}, approvals.WebhookURL,
+			contentType, body)
+		cancel()
+	}
+	if err != nil {
+		backendLogger.Error("notify approvers", "error", err, "id", a.ID, "host", rpc.Host(approvals.WebhookURL))
+	}
+	a.Notified = err == nil
+
+	if err := helpers.PutApproval(ctx, req.Storage, a); err != nil {
+		backendLogger.Error("put approval", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("request held for approval", "id", a.ID, "request", req.Path, "uuid", a.UUID, "path", a.Path,
+		"coinType", summary.CoinType, "notified", a.Notified, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"approvalId":     a.ID,
+			"approvalStatus": a.Status,
+			"summary":        summaryResponseData(summary),
+			"notified":       a.Notified,
+			"expiresAt":      formatTime(a.ExpiresAt),
+		},
+	}, nil
+}
+
+// useApproval checks that the approval id grants the sign request of fingerprint and records its use
+func (b *Backend) useApproval(ctx context.Context, s logical.Storage, id, fingerprint string, now time.Time) error {
+	b.approvalMu.Lock()
+	defer b.approvalMu.Unlock()
+
+	a, err := helpers.GetApproval(ctx, s, id)
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if a == nil {
+		return logical.CodedError(http.StatusForbidden, helpers.ErrUnknownApproval.Error())
+	}
+	if err := a.Use(fingerprint, now); err != nil {
+		return helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := helpers.PutApproval(ctx, s, a); err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return nil
+}
+
+// pruneApprovals removes the approvals that expired before now, decided or not
+func (b *Backend) pruneApprovals(ctx context.Context, s logical.Storage, now time.Time) error {
+	ids, err := s.List(ctx, config.ApprovalsStoragePath)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		a, err := helpers.GetApproval(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		if a == nil || now.Before(a.ExpiresAt) {
+			continue
+		}
+		if err := helpers.DeleteApproval(ctx, s, id); err != nil {
+			return err
+		}
+		b.logger.Info("approval expired", "id", id, "status", a.Status)
+	}
+	return nil
+}
+
+// notificationBody returns the webhook body of notification in the format of the approval policy
+func notificationBody(approvals *helpers.ApprovalConfig, notification approval.Notification,
+	source string) ([]byte, string, error) {
+	native, err := approval.Message(approvals.Provider, notification)
+	if err != nil {
+		return nil, "", err
+	}
+	transformer, err := webhook.NewTransformer(approvals.Format, approvals.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	data := map[string]interface{}{
+		"id":        notification.ID,
+		"uuid":      notification.UUID,
+		"path":      notification.Path,
+		"summary":   summaryResponseData(notification.Summary),
+		"expiresAt": formatTime(notification.ExpiresAt),
+	}
+	if link := notification.Link(approval.ActionApprove); link != "" {
+		data["approveUrl"] = link
+		data["rejectUrl"] = notification.Link(approval.ActionReject)
+	}
+	return transformer.Transform(webhook.Event{
+		ID:      notification.ID,
+		Source:  source,
+		Type:    approval.EventType,
+		Subject: notification.UUID,
+		Time:    time.Now().UTC(),
+		Data:    data,
+	}, native)
+}
+
+// webhookSource is the CloudEvents source of the webhook events of the mount of req
+func webhookSource(req *logical.Request) string {
+	if mount := strings.Trim(req.MountPoint, "/"); mount != "" {
+		return "/" + mount
+	}
+	return "/dq-vault"
+}
+
+// webhookFormat returns the webhook format of a config, stored empty when native
+func webhookFormat(format string) string {
+	if format == "" {
+		return webhook.FormatNative
+	}
+	return format
+}
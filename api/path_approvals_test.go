@@ -0,0 +1,461 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// slackCallbackBody returns the form encoded interaction payload of a click on the button of action
+func slackCallbackBody(t *testing.T, action, id string) string {
+	t.Helper()
+	payload, err := json.Marshal(map[string]interface{}{
+		"user":    map[string]interface{}{"id": "U1", "username": "ops"},
+		"actions": []interface{}{map[string]interface{}{"value": action + ":" + id}},
+	})
+	require.NoError(t, err)
+	return url.Values{"payload": {string(payload)}}.Encode()
+}
+
+func slackSignature(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestBackend_HandleRequest_Approvals(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	var (
+		mu       sync.Mutex
+		messages []map[string]interface{}
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var message map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &message))
+		mu.Lock()
+		messages = append(messages, message)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: "requester",
+		})
+	}
+	sign := func(value int, approvalID string) (*logical.Response, error) {
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath,
+			"payload": `{"nonce":1,"value":` + strconv.Itoa(value) + `,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`,
+		}
+		if approvalID != "" {
+			data["approvalId"] = approvalID
+		}
+		return request(logical.UpdateOperation, "sign", data)
+	}
+
+	t.Run("invalid policies are rejected", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{
+			{"thresholds": map[string]interface{}{"60": "-1"}, "provider": "slack", "webhookUrl": webhook.URL},
+			{"thresholds": map[string]interface{}{"eth": "1"}, "provider": "slack", "webhookUrl": webhook.URL},
+			{"thresholds": map[string]interface{}{"60": "1"}, "provider": "email", "webhookUrl": webhook.URL},
+			{"thresholds": map[string]interface{}{"60": "1"}, "provider": "slack", "webhookUrl": "ftp://hooks"},
+		} {
+			_, err := request(logical.UpdateOperation, "config/approvals", data)
+			require.Error(t, err, data)
+		}
+	})
+
+	resp, err := request(logical.UpdateOperation, "config/approvals", map[string]interface{}{
+		"thresholds":    map[string]interface{}{"60": "1000"},
+		"provider":      approval.ProviderSlack,
+		"webhookUrl":    webhook.URL + "/services/T0/B0/secret",
+		"signingSecret": "slack-secret",
+		"linkUrl":       "https://ops.example.com/approvals",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"60": "1000"}, resp.Data["thresholds"])
+	assert.Equal(t, true, resp.Data["signingSecretSet"])
+	assert.NotContains(t, resp.Data, "signingSecret")
+	assert.NotContains(t, resp.Data, "webhookUrl")
+
+	t.Run("requests below the threshold are signed", func(t *testing.T) {
+		resp, err := sign(999, "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.NotContains(t, resp.Data, "approvalId")
+	})
+
+	held, err := sign(1000, "")
+	require.NoError(t, err)
+	assert.NotContains(t, held.Data, "signature")
+	assert.Equal(t, helpers.ApprovalPending, held.Data["approvalStatus"])
+	assert.Equal(t, true, held.Data["notified"])
+	id := held.Data["approvalId"].(string)
+	summary := held.Data["summary"].(map[string]interface{})
+	assert.Equal(t, "1000", summary["value"])
+
+	t.Run("the approvers are notified with the summary and links", func(t *testing.T) {
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, messages, 1)
+		assert.Contains(t, messages[0]["text"], id)
+		assert.Contains(t, messages[0]["text"], "1000 to 0x742d35Cc6634C0532925a3b8D359A5C5119e32C8")
+		encoded, err := json.Marshal(messages[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(encoded), "https://ops.example.com/approvals?action=approve\\u0026id="+id)
+	})
+
+	t.Run("pending approvals do not sign", func(t *testing.T) {
+		_, err := sign(1000, id)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApprovalNotApproved.Error())
+	})
+
+	t.Run("forged callbacks are rejected", func(t *testing.T) {
+		body := slackCallbackBody(t, approval.ActionApprove, id)
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		_, err := request(logical.UpdateOperation, "approvals/callback", map[string]interface{}{
+			"body": body, "timestamp": timestamp, "signature": slackSignature("other-secret", timestamp, body),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), approval.ErrInvalidSignature.Error())
+
+		stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+		_, err = request(logical.UpdateOperation, "approvals/callback", map[string]interface{}{
+			"body": body, "timestamp": stale, "signature": slackSignature("slack-secret", stale, body),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), approval.ErrStaleCallback.Error())
+	})
+
+	t.Run("a signed callback approves", func(t *testing.T) {
+		body := slackCallbackBody(t, approval.ActionApprove, id)
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		resp, err := request(logical.UpdateOperation, "approvals/callback", map[string]interface{}{
+			"body": body, "timestamp": timestamp, "signature": slackSignature("slack-secret", timestamp, body),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.ApprovalApproved, resp.Data["status"])
+		assert.Equal(t, "slack:ops", resp.Data["decider"])
+
+		_, err = request(logical.UpdateOperation, "approvals/"+id+"/reject", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApprovalNotPending.Error())
+	})
+
+	t.Run("the approval grants its own request only", func(t *testing.T) {
+		_, err := sign(2000, id)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApprovalMismatch.Error())
+	})
+
+	t.Run("the approved request is signed once", func(t *testing.T) {
+		resp, err := sign(1000, id)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Equal(t, id, resp.Data["approvalId"])
+
+		_, err = sign(1000, id)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApprovalNotApproved.Error())
+
+		resp, err = request(logical.ReadOperation, "approvals/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.ApprovalUsed, resp.Data["status"])
+		assert.Equal(t, "requester", resp.Data["requester"])
+	})
+
+	t.Run("rejected requests are not signed", func(t *testing.T) {
+		held, err := sign(5000, "")
+		require.NoError(t, err)
+		rejected := held.Data["approvalId"].(string)
+		resp, err := request(logical.UpdateOperation, "approvals/"+rejected+"/reject", nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.ApprovalRejected, resp.Data["status"])
+		assert.Equal(t, "requester", resp.Data["decider"])
+
+		_, err = sign(5000, rejected)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApprovalNotApproved.Error())
+	})
+
+	t.Run("batch items are held", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "sign/batch", map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{
+					"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath,
+					"payload": `{"nonce":2,"value":1000,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+						`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`,
+				},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, resp.Data["pending"])
+	})
+
+	t.Run("expired approvals are pruned", func(t *testing.T) {
+		list, err := request(logical.ListOperation, "approvals/", nil)
+		require.NoError(t, err)
+		require.Len(t, list.Data["keys"], 3)
+
+		require.NoError(t, b.pruneApprovals(ctx, s, time.Now().Add(2*helpers.DefaultApprovalTTL)))
+		list, err = request(logical.ListOperation, "approvals/", nil)
+		require.NoError(t, err)
+		assert.Empty(t, list.Data["keys"])
+	})
+
+	t.Run("every signing path is held with its summary", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/approvals", map[string]interface{}{
+			"thresholds": map[string]interface{}{"60": "1000", "0": "40000"},
+			"provider":   approval.ProviderSlack, "webhookUrl": webhook.URL, "signingSecret": "slack-secret",
+		})
+		require.NoError(t, err)
+		xpub, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0,
+		})
+		require.NoError(t, err)
+		psbt := map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"),
+			"descriptors": []string{xpub.Data["descriptors"].(lib.Descriptors).Receive},
+		}
+		held, err := request(logical.UpdateOperation, "sign/psbt", psbt)
+		require.NoError(t, err)
+		assert.NotContains(t, held.Data, "signedInputs")
+		summary := held.Data["summary"].(map[string]interface{})
+		assert.Equal(t, "40000", summary["value"])
+		transfers := summary["transfers"].([]map[string]interface{})
+		require.Len(t, transfers, 1)
+		assert.Equal(t, "40000", transfers[0]["amount"])
+
+		id := held.Data["approvalId"].(string)
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "approvals/" + id + "/approve", Storage: s, EntityID: "approver",
+		})
+		require.NoError(t, err)
+		psbt["approvalId"] = id
+		resp, err := request(logical.UpdateOperation, "sign/psbt", psbt)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, resp.Data["signedInputs"])
+		assert.Equal(t, id, resp.Data["approvalId"])
+
+		// what a user operation runs is not decoded, whatever it moves
+		held, err = request(logical.UpdateOperation, "sign/userop", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "chainId": "1",
+			"entryPoint": "0x0000000071727De22E5E9d8BAf0edAc6f37da032",
+			"sender":     "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326", "nonce": "0", "callData": "0xb61d27f6",
+			"callGasLimit": "100000", "verificationGasLimit": "100000", "preVerificationGas": "21000",
+			"maxFeePerGas": "30000000000", "maxPriorityFeePerGas": "1000000000",
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, held.Data, "signature")
+		assert.Equal(t, "", held.Data["summary"].(map[string]interface{})["value"])
+	})
+
+	t.Run("without a policy requests are signed", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "config/approvals", nil)
+		require.NoError(t, err)
+		resp, err := sign(5000, "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+	})
+}
+
+func TestBackend_HandleRequest_ApproverIdentity(t *testing.T) {
+	ctx := context.Background()
+	view := &logical.StaticSystemView{}
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{System: view}))
+	s := newXpubTestStorage(t)
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer webhook.Close()
+
+	request := func(entityID string, operation logical.Operation, path string,
+		data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: entityID,
+		})
+	}
+	hold := func(t *testing.T) string {
+		t.Helper()
+		resp, err := request("requester", logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath,
+			"payload": `{"nonce":1,"value":1000,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`,
+		})
+		require.NoError(t, err)
+		return resp.Data["approvalId"].(string)
+	}
+
+	resp, err := request("admin", logical.UpdateOperation, "config/approvals", map[string]interface{}{
+		"thresholds":     map[string]interface{}{"60": "1"},
+		"provider":       approval.ProviderSlack,
+		"webhookUrl":     webhook.URL,
+		"signingSecret":  "slack-secret",
+		"approverGroups": "treasury,security",
+		"approverClaims": map[string]interface{}{"department": "ops"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"treasury", "security"}, resp.Data["approverGroups"])
+	assert.Equal(t, map[string]string{"department": "ops"}, resp.Data["approverClaims"])
+
+	id := hold(t)
+	approve := func(entityID string) error {
+		_, err := request(entityID, logical.UpdateOperation, "approvals/"+id+"/approve", nil)
+		return err
+	}
+	oidcAlias := &logical.Alias{MountType: "jwt", Name: "approver@example.com",
+		Metadata: map[string]string{"department": "ops"}}
+
+	tests := []struct {
+		name     string
+		entityID string
+		entity   *logical.Entity
+		groups   []*logical.Group
+		wantErr  string
+	}{
+		{name: "requester", entityID: "requester", wantErr: helpers.ErrSelfApproval.Error()},
+		{name: "no entity", entityID: "", wantErr: helpers.ErrApproverIdentity.Error()},
+		{name: "unknown entity", entityID: "approver", wantErr: helpers.ErrApproverIdentity.Error()},
+		{
+			name:     "disabled entity",
+			entityID: "approver",
+			entity:   &logical.Entity{ID: "approver", Disabled: true, Aliases: []*logical.Alias{oidcAlias}},
+			groups:   []*logical.Group{{Name: "treasury"}},
+			wantErr:  helpers.ErrApproverIdentity.Error(),
+		},
+		{
+			name:     "not in the groups",
+			entityID: "approver",
+			entity:   &logical.Entity{ID: "approver", Aliases: []*logical.Alias{oidcAlias}},
+			groups:   []*logical.Group{{Name: "payments"}},
+			wantErr:  helpers.ErrApproverIdentity.Error() + ": groups",
+		},
+		{
+			name:     "claims of another auth method",
+			entityID: "approver",
+			entity: &logical.Entity{ID: "approver", Aliases: []*logical.Alias{
+				{MountType: "userpass", Metadata: map[string]string{"department": "ops"}},
+			}},
+			groups:  []*logical.Group{{Name: "security"}},
+			wantErr: helpers.ErrApproverIdentity.Error() + ": claims",
+		},
+		{
+			name:     "other claim value",
+			entityID: "approver",
+			entity: &logical.Entity{ID: "approver", Aliases: []*logical.Alias{
+				{MountType: "oidc", Metadata: map[string]string{"department": "sales"}},
+			}},
+			groups:  []*logical.Group{{Name: "security"}},
+			wantErr: helpers.ErrApproverIdentity.Error() + ": claims",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view.EntityVal, view.GroupsVal = tt.entity, tt.groups
+			err := approve(tt.entityID)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("chat callbacks can only reject", func(t *testing.T) {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		body := slackCallbackBody(t, approval.ActionApprove, id)
+		_, err := request("relay", logical.UpdateOperation, "approvals/callback", map[string]interface{}{
+			"body": body, "timestamp": timestamp, "signature": slackSignature("slack-secret", timestamp, body),
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrApproverIdentity.Error())
+
+		rejected := hold(t)
+		body = slackCallbackBody(t, approval.ActionReject, rejected)
+		resp, err := request("relay", logical.UpdateOperation, "approvals/callback", map[string]interface{}{
+			"body": body, "timestamp": timestamp, "signature": slackSignature("slack-secret", timestamp, body),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.ApprovalRejected, resp.Data["status"])
+	})
+
+	t.Run("an approver of the groups with the claims approves", func(t *testing.T) {
+		view.EntityVal = &logical.Entity{ID: "approver", Aliases: []*logical.Alias{oidcAlias}}
+		view.GroupsVal = []*logical.Group{{Name: "payments"}, {Name: "security"}}
+		require.NoError(t, approve("approver"))
+
+		resp, err := request("requester", logical.ReadOperation, "approvals/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.ApprovalApproved, resp.Data["status"])
+		assert.Equal(t, "approver", resp.Data["decider"])
+	})
+}
+
+func TestBackend_HandleRequest_ApprovalTemplate(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	bodies := make(chan string, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer webhook.Close()
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: "requester",
+		})
+	}
+	policy := map[string]interface{}{
+		"thresholds": map[string]interface{}{"60": "1"},
+		"provider":   approval.ProviderSlack,
+		"webhookUrl": webhook.URL,
+		"format":     "template",
+		"linkUrl":    "https://approve.example.com",
+	}
+	_, err := request(logical.UpdateOperation, "config/approvals", policy)
+	require.ErrorContains(t, err, "template is required")
+
+	policy["template"] = `{"kind":"{{.Type}}","approval":"{{.Data.id}}","value":"{{.Data.summary.value}}",` +
+		`"approve":{{json .Data.approveUrl}}}`
+	resp, err := request(logical.UpdateOperation, "config/approvals", policy)
+	require.NoError(t, err)
+	assert.Equal(t, "template", resp.Data["format"])
+
+	resp, err = request(logical.UpdateOperation, "sign", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath,
+		"payload": `{"nonce":1,"value":1000,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+			`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`,
+	})
+	require.NoError(t, err)
+	id := resp.Data["approvalId"].(string)
+	assert.Equal(t, true, resp.Data["notified"])
+	assert.JSONEq(t, `{"kind":"`+approval.EventType+`","approval":"`+id+`","value":"1000",`+
+		`"approve":"https://approve.example.com?action=approve&id=`+id+`"}`, <-bodies)
+}
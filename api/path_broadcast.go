@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/rpc"
+)
+
+// pathBroadcast corresponds to UPDATE broadcast. It relays a signed transaction to the node
+// configured in config/rpc for its coin type and returns the transaction hash. It stays disabled
+// until config/features enables it.
+func (b *Backend) pathBroadcast(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_broadcast"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.BroadcastEnabled {
+		backendLogger.Warn("broadcast rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("broadcast: %s", helpers.ErrFeatureDisabled))
+	}
+
+	uuid := d.Get("uuid").(string)
+	coinType := uint16(d.Get("coinType").(int))
+	call, err := b.broadcastCall(coinType, d.Get("signedTx").(string))
+	if err != nil {
+		backendLogger.Error("broadcast call", "error", err, "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	client, host, err := b.rpcClient(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("rpc client", "error", err, "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	auditArgs := []any{"uuid", uuid, "coinType", coinType, "host", host, "entity", req.EntityID,
+		"expectedHash", call.hash}
+	var txHash string
+	attempts, err := client.Call(ctx, call.method, call.params, &txHash)
+	if err != nil && call.hash != "" && attempts > 1 && isAlreadyKnown(err) {
+		// a retried attempt found the transaction relayed by an attempt whose response was lost
+		txHash, err = call.hash, nil
+	}
+	if err != nil {
+		backendLogger.Error("broadcast failed", append(auditArgs, "attempts", attempts, "error", err)...)
+		var rpcErr *rpc.Error
+		if errors.As(err, &rpcErr) {
+			// the node rejected the transaction
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		return nil, logical.CodedError(http.StatusBadGateway, err.Error())
+	}
+
+	backendLogger.Info("transaction broadcast", append(auditArgs, "attempts", attempts, "txHash", txHash)...)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"txHash":   txHash,
+			"attempts": attempts,
+		},
+	}, nil
+}
+
+// rpcClient returns the client of the node configured for coinType in config/rpc, and its host
+func (b *Backend) rpcClient(ctx context.Context, s logical.Storage, coinType uint16) (*rpc.Client, string, error) {
+	endpoint, err := helpers.GetRPCEndpoint(ctx, s, coinType)
+	if err != nil {
+		return nil, "", err
+	}
+	if endpoint == nil {
+		return nil, "", helpers.ErrNoRPCEndpoint
+	}
+	client, err := rpc.NewClient(b.logger, endpoint.URL, endpoint.MaxRetries, endpoint.Timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, rpc.Host(endpoint.URL), nil
+}
+
+// broadcast is the JSON-RPC call relaying a signed transaction
+type broadcast struct {
+	method string
+	params []any
+	// hash is the transaction hash computed locally, when the chain allows it
+	hash string
+}
+
+// broadcastCall returns the call relaying signedTx, hex encoded as returned by sign, on the chain of coinType
+func (b *Backend) broadcastCall(coinType uint16, signedTx string) (*broadcast, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signedTx), "0x"))
+	if err != nil || len(raw) == 0 {
+		return nil, helpers.ErrInvalidSignedTx
+	}
+
+	switch {
+	case evm.NewEthereumAdapter(b.logger).CanDo(coinType):
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, fmt.Errorf("%w: %w", helpers.ErrInvalidSignedTx, err)
+		}
+		return &broadcast{method: "eth_sendRawTransaction", params: []any{hexutil.Encode(raw)}, hash: tx.Hash().Hex()},
+			nil
+	case solana.NewSolanaAdapter(b.logger).CanDo(coinType):
+		return &broadcast{
+			method: "sendTransaction",
+			params: []any{base64.StdEncoding.EncodeToString(raw), map[string]string{"encoding": "base64"}},
+		}, nil
+	case bitcoin.NewBitcoinAdapter(b.logger).CanDo(coinType):
+		// bitcoind takes the finalized network serialization, not a PSBT
+		return &broadcast{method: "sendrawtransaction", params: []any{hex.EncodeToString(raw)}}, nil
+	default:
+		return nil, helpers.ErrNoBroadcast
+	}
+}
+
+// isAlreadyKnown reports whether the node rejected a transaction because it already has it
+func isAlreadyKnown(err error) bool {
+	var rpcErr *rpc.Error
+	return errors.As(err, &rpcErr) && strings.Contains(strings.ToLower(rpcErr.Message), "already known")
+}
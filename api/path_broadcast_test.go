@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Helper function to create a proper framework.FieldData for broadcast endpoint
+func createBroadcastFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"coinType": {Type: framework.TypeInt},
+			"signedTx": {Type: framework.TypeString},
+			"uuid":     {Type: framework.TypeString, Default: ""},
+		},
+	}
+}
+
+// newTestSignedEVMTx returns a hex encoded signed legacy transaction and its hash
+func newTestSignedEVMTx(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.NewEIP155Signer(big.NewInt(1)), &types.LegacyTx{
+		Nonce: 1, GasPrice: big.NewInt(1e9), Gas: 21000, To: &common.Address{}, Value: big.NewInt(1),
+	})
+	require.NoError(t, err)
+	raw, err := tx.MarshalBinary()
+	require.NoError(t, err)
+	return hexutil.Encode(raw), tx.Hash().Hex()
+}
+
+// newBroadcastTestStorage returns a storage with broadcast enabled and the node url configured for coinType
+func newBroadcastTestStorage(t *testing.T, coinType uint16, url string) logical.Storage {
+	t.Helper()
+	s := &logical.InmemStorage{}
+	entry, err := logical.StorageEntryJSON(config.FeaturesStorageKey, helpers.Features{BroadcastEnabled: true})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+	require.NoError(t, helpers.PutRPCEndpoint(context.Background(), s, &helpers.RPCEndpoint{
+		CoinType: coinType, URL: url, MaxRetries: 0, Timeout: time.Second,
+	}))
+	return s
+}
+
+// newTestNode returns a JSON-RPC node answering every call with response, recording the last request
+func newTestNode(t *testing.T, response string, last *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if last != nil {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(last))
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBackend_PathBroadcast(t *testing.T) {
+	ctx := context.Background()
+	signedTx, txHash := newTestSignedEVMTx(t)
+	broadcast := func(storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return createSignTestBackend(t).pathBroadcast(ctx, &logical.Request{Storage: storage, Data: data},
+			createBroadcastFieldData(data))
+	}
+
+	t.Run("evm transaction", func(t *testing.T) {
+		var last map[string]interface{}
+		node := newTestNode(t, `{"jsonrpc":"2.0","id":1,"result":"`+txHash+`"}`, &last)
+
+		got, err := broadcast(newBroadcastTestStorage(t, 60, node.URL),
+			map[string]interface{}{"coinType": 60, "signedTx": signedTx, "uuid": signTestUUID})
+		require.NoError(t, err)
+		assert.Equal(t, txHash, got.Data["txHash"])
+		assert.Equal(t, 1, got.Data["attempts"])
+		assert.Equal(t, "eth_sendRawTransaction", last["method"])
+		assert.Equal(t, []interface{}{signedTx}, last["params"])
+	})
+
+	t.Run("solana transaction is relayed base64 encoded", func(t *testing.T) {
+		var last map[string]interface{}
+		node := newTestNode(t, `{"jsonrpc":"2.0","id":1,"result":"5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnb"}`, &last)
+
+		got, err := broadcast(newBroadcastTestStorage(t, 501, node.URL),
+			map[string]interface{}{"coinType": 501, "signedTx": "01020304"})
+		require.NoError(t, err)
+		assert.Equal(t, "5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnb", got.Data["txHash"])
+		assert.Equal(t, "sendTransaction", last["method"])
+		assert.Equal(t, []interface{}{base64.StdEncoding.EncodeToString([]byte{1, 2, 3, 4}),
+			map[string]interface{}{"encoding": "base64"}}, last["params"])
+	})
+
+	t.Run("rejected transaction", func(t *testing.T) {
+		node := newTestNode(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"nonce too low"}}`, nil)
+
+		_, err := broadcast(newBroadcastTestStorage(t, 60, node.URL),
+			map[string]interface{}{"coinType": 60, "signedTx": signedTx})
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusUnprocessableEntity, codedErr.Code())
+		assert.Contains(t, err.Error(), "nonce too low")
+	})
+
+	t.Run("unreachable node", func(t *testing.T) {
+		node := newTestNode(t, "", nil)
+		node.Close()
+
+		_, err := broadcast(newBroadcastTestStorage(t, 60, node.URL),
+			map[string]interface{}{"coinType": 60, "signedTx": signedTx})
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadGateway, codedErr.Code())
+	})
+
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) logical.Storage
+		data    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "feature disabled",
+			storage: func(*testing.T) logical.Storage { return &logical.InmemStorage{} },
+			data:    map[string]interface{}{"coinType": 60, "signedTx": signedTx},
+			wantErr: helpers.ErrFeatureDisabled.Error(),
+		},
+		{
+			name:    "no endpoint",
+			storage: func(t *testing.T) logical.Storage { return newBroadcastTestStorage(t, 501, "http://node") },
+			data:    map[string]interface{}{"coinType": 60, "signedTx": signedTx},
+			wantErr: helpers.ErrNoRPCEndpoint.Error(),
+		},
+		{
+			name:    "invalid evm transaction",
+			storage: func(t *testing.T) logical.Storage { return newBroadcastTestStorage(t, 60, "http://node") },
+			data:    map[string]interface{}{"coinType": 60, "signedTx": "0x0102"},
+			wantErr: helpers.ErrInvalidSignedTx.Error(),
+		},
+		{
+			name:    "not hex",
+			storage: func(t *testing.T) logical.Storage { return newBroadcastTestStorage(t, 60, "http://node") },
+			data:    map[string]interface{}{"coinType": 60, "signedTx": "zz"},
+			wantErr: helpers.ErrInvalidSignedTx.Error(),
+		},
+		{
+			name:    "coin without broadcast",
+			storage: func(t *testing.T) logical.Storage { return newBroadcastTestStorage(t, 195, "http://node") },
+			data:    map[string]interface{}{"coinType": 195, "signedTx": "0102"},
+			wantErr: helpers.ErrNoBroadcast.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := broadcast(tt.storage(t), tt.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathListBudgets corresponds to LIST budget/<uuid>.
+func (b *Backend) pathListBudgets(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_budgets"))
+
+	coinTypes, err := req.Storage.List(ctx, config.BudgetsStoragePath+d.Get("uuid").(string)+"/")
+	if err != nil {
+		backendLogger.Error("list budgets", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(coinTypes), nil
+}
+
+// pathReadBudget corresponds to READ budget/<uuid>/<coinType>.
+func (b *Backend) pathReadBudget(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_budget"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	budget, err := helpers.GetBudget(ctx, req.Storage, d.Get("uuid").(string), coinType)
+	if err != nil {
+		backendLogger.Error("get budget", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if budget == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: budgetResponseData(budget),
+	}, nil
+}
+
+// pathDeleteBudget corresponds to DELETE budget/<uuid>/<coinType>. The user signs without a
+// budget on the coin type again.
+func (b *Backend) pathDeleteBudget(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_budget"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	uuid := d.Get("uuid").(string)
+
+	b.budgetMu.Lock()
+	err = helpers.DeleteBudget(ctx, req.Storage, uuid, coinType)
+	b.budgetMu.Unlock()
+	if err != nil {
+		backendLogger.Error("delete budget", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("budget deleted", "uuid", uuid, "coinType", coinType, "entity", req.EntityID)
+	return nil, nil
+}
+
+// pathTopUpBudget corresponds to UPDATE budget/top-up. The first top-up of a user on a coin type
+// starts its budget.
+func (b *Backend) pathTopUpBudget(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_top_up_budget"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	coinType := d.Get("coinType").(int)
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	amount, err := helpers.ParseBudgetAmount(d.Get("amount").(string))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if _, err := helpers.GetUser(ctx, req, uuid); err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	b.budgetMu.Lock()
+	defer b.budgetMu.Unlock()
+
+	now := time.Now()
+	budget, err := helpers.GetBudget(ctx, req.Storage, uuid, uint16(coinType))
+	if err != nil {
+		backendLogger.Error("get budget", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if budget == nil {
+		budget = helpers.NewBudget(uuid, uint16(coinType), now)
+	}
+	budget.TopUp(amount, req.EntityID, now)
+	if err := helpers.PutBudget(ctx, req.Storage, budget); err != nil {
+		backendLogger.Error("put budget", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("budget topped up", "uuid", uuid, "coinType", coinType, "amount", amount.String(),
+		"remaining", budget.Remaining.String(), "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: budgetResponseData(budget),
+	}, nil
+}
+
+func budgetResponseData(budget *helpers.Budget) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":      budget.UUID,
+		"coinType":  budget.CoinType,
+		"remaining": budget.Remaining.String(),
+		"spent":     budget.Spent.String(),
+		"toppedUp":  budget.ToppedUp.String(),
+		"topUps":    budget.TopUps,
+		"lastTopUp": budget.LastTopUp,
+		"createdAt": formatTime(budget.CreatedAt),
+		"updatedAt": formatTime(budget.UpdatedAt),
+	}
+}
+
+// withBudget charges the signing requests of op to the budget of their user and coin type, when it
+// has one, by the intent intentOf reads. The native value of the intent is debited before signing
+// and refunded when op does not sign. Intents whose value cannot be decoded are refused, as they
+// would escape the ceiling, and so are the signatures valid on any chain of a user with a budget.
+func (b *Backend) withBudget(intentOf signIntentFunc, op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "budget"))
+
+		intent, err := intentOf(d)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		// invalid fields are left to op to reject
+		if intent == nil {
+			return op(ctx, req, d)
+		}
+		coinType := intent.summary.CoinType
+
+		budget, err := b.debitBudget(ctx, req, intent)
+		if err != nil {
+			backendLogger.Warn("signing request refused", "error", err, "uuids", intent.uuids, "coinType", coinType,
+				"path", req.Path)
+			return nil, err
+		}
+		if budget == nil {
+			return op(ctx, req, d)
+		}
+
+		resp, err := op(ctx, req, d)
+		if !budgetSigned(resp, err) {
+			if refundErr := b.refundBudget(ctx, req.Storage, budget.UUID, coinType, intent.summary.Value); refundErr != nil {
+				backendLogger.Error("refund budget", "error", refundErr, "uuid", budget.UUID, "coinType", coinType,
+					"value", intent.summary.Value.String())
+			}
+			return resp, err
+		}
+		resp.Data["budgetRemaining"] = budget.Remaining.String()
+		return resp, nil
+	}
+}
+
+// debitBudget debits the value of intent from the budget of its user on its coin type, returning
+// a nil budget when there is none. The outputs paying the wallet of the user back are taken off the
+// value of intent first, so the refund gives back what was debited.
+func (b *Backend) debitBudget(ctx context.Context, req *logical.Request, intent *signIntent) (*helpers.Budget,
+	error) {
+	b.budgetMu.Lock()
+	defer b.budgetMu.Unlock()
+
+	var budget *helpers.Budget
+	for _, uuid := range intent.uuids {
+		if intent.anyCoinType {
+			charged, err := helpers.HasBudgets(ctx, req.Storage, uuid)
+			if err != nil {
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+			if charged {
+				return nil, logical.CodedError(http.StatusForbidden, helpers.ErrBudgetUnpriced.Error())
+			}
+			continue
+		}
+		userBudget, err := helpers.GetBudget(ctx, req.Storage, uuid, intent.summary.CoinType)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if userBudget != nil {
+			budget = userBudget
+		}
+	}
+	if budget == nil {
+		return nil, nil
+	}
+	if len(intent.uuids) > 1 {
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrBudgetShared.Error())
+	}
+	if intent.summary.Value == nil {
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrBudgetUnpriced.Error())
+	}
+	if intent.walletOutputs != nil {
+		value, err := chargedValue(ctx, req, intent)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		intent.summary.Value = value
+	}
+	if err := budget.Debit(intent.summary.Value, time.Now()); err != nil {
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := helpers.PutBudget(ctx, req.Storage, budget); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return budget, nil
+}
+
+// chargedValue returns the value of intent without its transfers paying the wallet of its user back
+func chargedValue(ctx context.Context, req *logical.Request, intent *signIntent) (*big.Int, error) {
+	user, err := helpers.GetUser(ctx, req, intent.uuids[0])
+	if err != nil {
+		return nil, err
+	}
+	seed, err := userSeed(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := intent.walletOutputs(seed)
+	if err != nil {
+		return nil, err
+	}
+	return intent.summary.ValueWithout(outputs), nil
+}
+
+// refundBudget gives value back to the budget of uuid on coinType, unless it was deleted since
+func (b *Backend) refundBudget(ctx context.Context, s logical.Storage, uuid string, coinType uint16,
+	value *big.Int) error {
+	b.budgetMu.Lock()
+	defer b.budgetMu.Unlock()
+
+	budget, err := helpers.GetBudget(ctx, s, uuid, coinType)
+	if err != nil || budget == nil {
+		return err
+	}
+	budget.Refund(value, time.Now())
+	return helpers.PutBudget(ctx, s, budget)
+}
+
+// budgetSigned reports whether the response of a signing request has a signature: the signature,
+// the raw transaction of build/btc-tx or the signed PSBT
+func budgetSigned(resp *logical.Response, err error) bool {
+	if err != nil || resp == nil || resp.Data == nil {
+		return false
+	}
+	for _, key := range []string{"signature", "rawTx", "psbt"} {
+		if _, ok := resp.Data[key].(string); ok {
+			return true
+		}
+	}
+	return false
+}
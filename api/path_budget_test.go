@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestBackend_HandleRequest_Budget(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data,
+			EntityID: "finance"})
+	}
+	sign := func(path, payload string) (*logical.Response, error) {
+		t.Helper()
+		return request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "path": path, "coinType": int(slip44.Ether), "payload": payload,
+		})
+	}
+	code := func(err error) int {
+		t.Helper()
+		require.Error(t, err)
+		return err.(logical.HTTPCodedError).Code()
+	}
+	budgetPath := "budget/" + signTestUUID + "/" + strconv.Itoa(int(slip44.Ether))
+
+	t.Run("top-ups are checked", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ether), "amount": "-1",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, code(err))
+		assert.Contains(t, err.Error(), helpers.ErrInvalidBudgetAmount.Error())
+
+		_, err = request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": "unknown", "coinType": int(slip44.Ether), "amount": "1",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, code(err))
+	})
+
+	t.Run("signing decrements the budget", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ether), "amount": "1500000000000000000",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "1500000000000000000", resp.Data["remaining"])
+		assert.Equal(t, "finance", resp.Data["lastTopUp"])
+
+		resp, err = sign(signTestDerivationPath, signTestPayload)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Equal(t, "500000000000000000", resp.Data["budgetRemaining"])
+
+		_, err = sign(signTestDerivationPath, signTestPayload)
+		assert.Equal(t, http.StatusForbidden, code(err))
+		assert.Contains(t, err.Error(), helpers.ErrBudgetExceeded.Error())
+
+		_, err = sign(signTestDerivationPath, signTestMalformedPayload)
+		assert.Equal(t, http.StatusForbidden, code(err))
+		assert.Contains(t, err.Error(), helpers.ErrBudgetUnpriced.Error())
+
+		resp, err = request(logical.ReadOperation, budgetPath, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "500000000000000000", resp.Data["remaining"])
+		assert.Equal(t, "1000000000000000000", resp.Data["spent"])
+		assert.Equal(t, 1, resp.Data["topUps"])
+	})
+
+	t.Run("failed signatures are refunded", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Ether), "amount": "500000000000000000",
+		})
+		require.NoError(t, err)
+
+		_, err = sign("not a path", signTestPayload)
+		require.Error(t, err)
+
+		resp, err := request(logical.ReadOperation, budgetPath, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "1000000000000000000", resp.Data["remaining"])
+		assert.Equal(t, "1000000000000000000", resp.Data["spent"])
+		assert.Equal(t, "2000000000000000000", resp.Data["toppedUp"])
+	})
+
+	t.Run("every signing path is charged", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "sign/safe-tx", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "chainId": "1",
+			"safe": "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326", "to": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+			"value": "1000", "nonce": "7",
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Equal(t, "999999999999999000", resp.Data["budgetRemaining"])
+
+		// the value of a permit or a user operation cannot be decoded
+		_, err = request(logical.UpdateOperation, "sign/permit", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "chainId": "1",
+			"token": "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326", "tokenName": "USD Coin", "tokenVersion": "2",
+			"spender": "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8", "amount": "1000", "nonce": "0",
+			"deadline": "1893456000",
+		})
+		assert.Equal(t, http.StatusForbidden, code(err))
+		assert.Contains(t, err.Error(), helpers.ErrBudgetUnpriced.Error())
+	})
+
+	t.Run("the change of a Bitcoin payload is not charged", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": int(slip44.Bitcoin), "amount": "30000",
+		})
+		require.NoError(t, err)
+		const path = "m/84'/0'/0'/0/7"
+		payload := func(change string) string {
+			tx := wire.NewMsgTx(2)
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+			tx.AddTxOut(wire.NewTxOut(25_000, newTestP2WPKHScript(t,
+				"legal winner thank year wave sausage worth useful legal winner thank yellow", path)))
+			tx.AddTxOut(wire.NewTxOut(20_000, newTestP2WPKHScript(t, signTestValidMnemonic, change)))
+			return newTestTxPSBT(t, tx, newTestP2WPKHScript(t, signTestValidMnemonic, path))
+		}
+		signBitcoin := func(payload string) (*logical.Response, error) {
+			t.Helper()
+			return request(logical.UpdateOperation, "sign", map[string]interface{}{
+				"uuid": signTestUUID, "derivationPath": path, "coinType": int(slip44.Bitcoin), "payload": payload,
+			})
+		}
+
+		resp, err := signBitcoin(payload(path))
+		require.NoError(t, err)
+		assert.Equal(t, "5000", resp.Data["budgetRemaining"])
+
+		// an output to another key of the wallet, without its derivation, is not told apart
+		_, err = signBitcoin(payload("m/84'/0'/0'/1/0"))
+		assert.Equal(t, http.StatusForbidden, code(err))
+		assert.Contains(t, err.Error(), helpers.ErrBudgetExceeded.Error())
+		_, err = request(logical.DeleteOperation, "budget/"+signTestUUID+"/"+strconv.Itoa(int(slip44.Bitcoin)), nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("deleting the budget lifts the ceiling", func(t *testing.T) {
+		resp, err := request(logical.ListOperation, "budget/"+signTestUUID+"/", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{strconv.Itoa(int(slip44.Ether))}, resp.Data["keys"])
+
+		_, err = request(logical.DeleteOperation, budgetPath, nil)
+		require.NoError(t, err)
+		resp, err = request(logical.ReadOperation, budgetPath, nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = sign(signTestDerivationPath, signTestPayload)
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "budgetRemaining")
+	})
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// defaultChangePurpose is the BIP-44 purpose of the change addresses of build/btc-tx, native segwit
+	defaultChangePurpose = 84
+	// changeChain is the BIP-44 change component of the internal chain
+	changeChain = 1
+)
+
+// pathBuildBTCTx corresponds to UPDATE build/btc-tx. It builds the transaction spending the given
+// UTXOs of the user wallet to the outputs at the fee rate, pays the change to the next unused
+// address of the internal chain of the account, signs it and returns the raw transaction. The change
+// index is allocated as address/next does, and recorded only when the transaction has change.
+func (b *Backend) pathBuildBTCTx(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_build_btc_tx"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	feeRate := d.Get("feeRate").(float64)
+	purpose := d.Get("changePurpose").(int)
+	account := d.Get("account").(int)
+	isDev := d.Get("isDev").(bool)
+
+	if purpose < 0 || purpose > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidPurpose.Error())
+	}
+	if account < 0 || account > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAddressChain.Error())
+	}
+	// the utxos and outputs are given as JSON lists of objects
+	var utxos []bitcoin.UTXO
+	var payments []bitcoin.Payment
+	if err := decodeBTCTxList(d.Get("utxos"), &utxos); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidBTCTxRequest.Error())
+	}
+	if err := decodeBTCTxList(d.Get("outputs"), &payments); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidBTCTxRequest.Error())
+	}
+
+	coinType := uint16(slip44.Bitcoin)
+	if isDev {
+		coinType = slip44.TestNet
+	}
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	// the keys of the UTXOs are those of the coin type the user was authorized for
+	for _, utxo := range utxos {
+		if err := helpers.CheckCoinTypeLevel(utxo.Path, coinType); err != nil {
+			backendLogger.Error("check utxo path", "error", err, "path", utxo.Path)
+			if errors.Is(err, helpers.ErrCoinTypeNotAllowed) {
+				return nil, helpers.CodedError(http.StatusForbidden, err)
+			}
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	var fees map[string]interface{}
+	var warnings []string
+	bounds, err := helpers.GetFeeBounds(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("get fee bounds", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if bounds != nil {
+		if fees, warnings, err = b.checkFeeRate(req, d, bounds, feeRate, backendLogger); err != nil {
+			backendLogger.Error("check fee bounds", "error", err)
+			return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+		}
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// the change chain is read and advanced under the lock, so no two requests get the same index
+	b.addressMu.Lock()
+	defer b.addressMu.Unlock()
+
+	chain, err := helpers.GetAddressChain(ctx, req.Storage, uuid, coinType, uint32(account), changeChain)
+	if err != nil {
+		backendLogger.Error("get address chain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if chain.Next > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrAddressIndexExhausted.Error())
+	}
+	changePath := addressNextPath(lib.CurveSecp256k1, purpose, int(coinType), account, changeChain, chain.Next)
+
+	built, err := bitcoin.BuildTransaction(seed, &bitcoin.TxRequest{
+		UTXOs:      utxos,
+		Payments:   payments,
+		FeeRate:    feeRate,
+		ChangePath: changePath,
+		IsDev:      isDev,
+	})
+	if err != nil {
+		backendLogger.Error("build transaction", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var change map[string]interface{}
+	if built.Change != nil {
+		reservation := &helpers.AddressReservation{
+			Index:      chain.Next,
+			Path:       changePath,
+			Address:    built.Change.Address,
+			Reference:  built.TxID,
+			EntityID:   req.EntityID,
+			ReservedAt: time.Now().UTC(),
+		}
+		if err := helpers.PutAddressReservation(ctx, req.Storage, uuid, coinType, uint32(account), changeChain,
+			reservation); err != nil {
+			backendLogger.Error("put address reservation", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := b.indexAddresses(ctx, req, uuid, coinType, isDev,
+			map[string]string{changePath: built.Change.Address}); err != nil {
+			backendLogger.Error("index address", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		change = map[string]interface{}{
+			"address": built.Change.Address,
+			"value":   built.Change.Value,
+			"path":    changePath,
+			"index":   reservation.Index,
+		}
+	}
+
+	backendLogger.Info("built transaction", "uuid", uuid, "txid", built.TxID, "utxos", len(utxos),
+		"fee", built.Fee, "vsize", built.VSize, "change", built.Change != nil, "entity", req.EntityID)
+
+	data := map[string]interface{}{
+		"rawTx":   built.Raw,
+		"txid":    built.TxID,
+		"fee":     built.Fee,
+		"vsize":   built.VSize,
+		"feeRate": built.FeeRate,
+		"change":  change,
+	}
+	if override, ok := fees["feeOverride"]; ok {
+		data["feeOverride"] = override
+	}
+	return &logical.Response{Data: data, Warnings: warnings}, nil
+}
+
+// decodeBTCTxList decodes the list of objects of a build/btc-tx field into list
+func decodeBTCTxList(value interface{}, list interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, list)
+}
+
+// buildBTCTxOp is the chain of build/btc-tx, with the address book, approval and budget policies of
+// its outputs; transfer builds the Bitcoin transfers through it
+func (b *Backend) buildBTCTxOp() framework.OperationFunc {
+	return b.policyOp(lib.OperationBuildBTCTx, buildBTCTxIntent, b.pathBuildBTCTx)
+}
+
+// buildBTCTxIntent is the intent of a build/btc-tx request: its outputs, the change and the fee left
+// to the build
+func buildBTCTxIntent(d *framework.FieldData) (*signIntent, error) {
+	var payments []bitcoin.Payment
+	if err := decodeBTCTxList(d.Get("outputs"), &payments); err != nil || len(payments) == 0 {
+		return nil, nil
+	}
+	summary := approval.Summary{CoinType: bitcoinCoinType(d.Get("isDev").(bool)), Value: new(big.Int)}
+	for _, payment := range payments {
+		summary.Transfers = append(summary.Transfers, approval.Transfer{
+			To: payment.Address, Amount: strconv.FormatInt(payment.Value, 10),
+		})
+		summary.Value.Add(summary.Value, big.NewInt(payment.Value))
+	}
+	return intentOf("build/btc-tx", d, []string{d.Get("uuid").(string)}, "", summary)
+}
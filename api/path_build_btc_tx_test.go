@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+)
+
+func TestBackend_HandleRequest_BuildBTCTx(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+	build := func(value, feeRate interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"uuid": signTestUUID,
+			"utxos": []interface{}{
+				map[string]interface{}{"txid": strings.Repeat("ab", 32), "vout": 1, "value": 100_000, "path": "m/84'/0'/0'/0/0"},
+			},
+			"outputs": []interface{}{
+				map[string]interface{}{"address": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "value": value},
+			},
+			"feeRate": feeRate,
+		}
+	}
+
+	t.Run("change is paid to the next address of the internal chain", func(t *testing.T) {
+		resp, err := request("build/btc-tx", build(60_000, 5))
+		require.NoError(t, err)
+		raw, err := hex.DecodeString(resp.Data["rawTx"].(string))
+		require.NoError(t, err)
+		tx := wire.NewMsgTx(2)
+		require.NoError(t, tx.Deserialize(bytes.NewReader(raw)))
+		assert.Equal(t, resp.Data["txid"], tx.TxHash().String())
+		require.Len(t, tx.TxIn[0].Witness, 2)
+
+		change := resp.Data["change"].(map[string]interface{})
+		assert.Equal(t, "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el", change["address"])
+		assert.Equal(t, "m/84'/0'/0'/1/0", change["path"])
+		assert.Equal(t, int64(40_000)-resp.Data["fee"].(int64), change["value"])
+		assert.GreaterOrEqual(t, resp.Data["feeRate"], 5.0)
+
+		entry, err := s.Get(ctx, config.AddressLedgerStoragePath+signTestUUID+"/0/0/1/0")
+		require.NoError(t, err)
+		var reservation helpers.AddressReservation
+		require.NoError(t, entry.DecodeJSON(&reservation))
+		assert.Equal(t, resp.Data["txid"], reservation.Reference)
+
+		resp, err = request("build/btc-tx", build(60_000, 5))
+		require.NoError(t, err)
+		assert.Equal(t, "m/84'/0'/0'/1/1", resp.Data["change"].(map[string]interface{})["path"])
+	})
+
+	t.Run("no change index is used without change", func(t *testing.T) {
+		resp, err := request("build/btc-tx", build(99_500, 2))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Data["change"])
+		assert.Equal(t, int64(500), resp.Data["fee"])
+
+		resp, err = request("build/btc-tx", build(60_000, 5))
+		require.NoError(t, err)
+		assert.Equal(t, "m/84'/0'/0'/1/2", resp.Data["change"].(map[string]interface{})["path"])
+	})
+
+	t.Run("build errors", func(t *testing.T) {
+		_, err := request("build/btc-tx", build(99_990, 2))
+		require.ErrorContains(t, err, bitcoin.ErrInsufficientFunds.Error())
+		_, err = request("build/btc-tx", map[string]interface{}{"uuid": signTestUUID, "utxos": []interface{}{"txid"},
+			"outputs": []interface{}{}, "feeRate": 1})
+		require.ErrorContains(t, err, helpers.ErrInvalidBTCTxRequest.Error())
+		data := build(60_000, 5)
+		data["isDev"] = true
+		data["utxos"].([]interface{})[0].(map[string]interface{})["path"] = "m/84'/1'/0'/0/0"
+		_, err = request("build/btc-tx", data)
+		require.ErrorContains(t, err, bitcoin.ErrInvalidAddress.Error())
+	})
+
+	t.Run("the fee rate is checked against config/fees", func(t *testing.T) {
+		_, err := request("config/fees/0", map[string]interface{}{"min": 1, "max": 10})
+		require.NoError(t, err)
+		_, err = request("build/btc-tx", build(60_000, 20))
+		require.ErrorContains(t, err, helpers.ErrFeeOutOfBounds.Error())
+
+		data := build(60_000, 20)
+		data["overrideFee"] = true
+		resp, err := request("build/btc-tx", data)
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["feeOverride"])
+		assert.Equal(t, helpers.WarningFeeOverridden, helpers.WarningCode(resp.Warnings[0]))
+	})
+
+	t.Run("the utxo paths are checked against config/derivation and the coin type", func(t *testing.T) {
+		spend := func(path string, isDev bool) error {
+			data := build(60_000, 5)
+			data["utxos"].([]interface{})[0].(map[string]interface{})["path"] = path
+			data["isDev"] = isDev
+			_, err := request("build/btc-tx", data)
+			return err
+		}
+		_, err := request("config/derivation", map[string]interface{}{"hardenedAccount": true})
+		require.NoError(t, err)
+		defer func() {
+			_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.DeleteOperation,
+				Path: "config/derivation", Storage: s})
+			require.NoError(t, err)
+		}()
+
+		require.ErrorIs(t, spend("m/84'/0'/0/0/0", false), helpers.ErrNonHardenedAboveAccount)
+		// the key of another coin type, or of mainnet for a testnet transaction, is not spent
+		require.ErrorIs(t, spend("m/84'/60'/0'/0/0", false), helpers.ErrCoinTypeNotAllowed)
+		require.ErrorIs(t, spend("m/84'/0'/0'/0/0", true), helpers.ErrCoinTypeNotAllowed)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.ReadOperation,
+			Path: "user/" + signTestUUID + "/violations", Storage: s})
+		require.NoError(t, err)
+		// the first note is the fee rate rejected above
+		notes := resp.Data["notes"].([]map[string]interface{})
+		require.Len(t, notes, 4)
+		assert.Equal(t, "derivation", notes[1]["kind"])
+		assert.Equal(t, "coinType", notes[2]["kind"])
+		assert.Equal(t, "coinType", notes[3]["kind"])
+		assert.Equal(t, "build/btc-tx", notes[3]["path"])
+	})
+
+	t.Run("every output is checked against the address book", func(t *testing.T) {
+		_, err := request("config/addressbook", map[string]interface{}{
+			"requiredTags": map[string]interface{}{"0": helpers.AnyAddressBookTag},
+		})
+		require.NoError(t, err)
+		_, err = request("build/btc-tx", build(60_000, 5))
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+
+		// the change paid to the internal chain is not a recipient
+		_, err = request("addressbook/cold-storage", map[string]interface{}{
+			"coinType": 0, "address": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		})
+		require.NoError(t, err)
+		resp, err := request("build/btc-tx", build(60_000, 5))
+		require.NoError(t, err)
+		assert.NotNil(t, resp.Data["change"])
+	})
+}
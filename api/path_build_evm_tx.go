@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// evmTxQuantities are the quantities of build/evm-tx, decimal or 0x hex strings signed as the JSON
+// numbers of the sign payload
+//
+//nolint:gochecknoglobals // read-only lookup table
+var evmTxQuantities = []string{"nonce", "value", "gasLimit", "gasPrice", "chainId"}
+
+// evmTxSignFields are the optional fields of build/evm-tx passed on to sign when they are set
+//
+//nolint:gochecknoglobals // read-only lookup table
+var evmTxSignFields = []string{"approvalId", "travelRule", "apiKey"}
+
+// pathBuildEVMTx corresponds to UPDATE build/evm-tx. It builds the sign payload of the EVM
+// transaction from its fields and signs it as sign does, with the policies of sign, returning
+// the signed raw transaction and its hash.
+func (b *Backend) pathBuildEVMTx(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_build_evm_tx"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if !evm.NewEthereumAdapter(b.logger).CanDo(uint16(d.Get("coinType").(int))) {
+		backendLogger.Error("not an evm coin", "coinType", d.Get("coinType"))
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	payload, err := evmTxPayload(d)
+	if err != nil {
+		backendLogger.Error("evm transaction", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// the transaction is signed as a sign request of its own, the payload built from the fields
+	raw := map[string]interface{}{
+		"uuid":           d.Get("uuid"),
+		"derivationPath": d.Get("derivationPath"),
+		"coinType":       d.Get("coinType"),
+		"payload":        payload,
+		"isDev":          d.Get("isDev"),
+		"complete":       d.Get("complete"),
+		"overrideFee":    d.Get("overrideFee"),
+	}
+	for _, name := range evmTxSignFields {
+		if value, ok := d.GetOk(name); ok {
+			raw[name] = value
+		}
+	}
+	signReq := *req
+	signReq.Path, signReq.Data = "sign", raw
+	resp, err := b.signOp(false)(ctx, &signReq, &framework.FieldData{Raw: raw, Schema: b.Route("sign").Fields})
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	resp.Data["payload"] = payload
+	if signature, ok := resp.Data["signature"].(string); ok {
+		signed, err := hexutil.Decode(signature)
+		if err != nil {
+			backendLogger.Error("decode signed transaction", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		resp.Data["rawTx"] = signature
+		resp.Data["txHash"] = crypto.Keccak256Hash(signed).Hex()
+	}
+	backendLogger.Info("built transaction", "uuid", raw["uuid"], "txHash", resp.Data["txHash"],
+		"approvalStatus", resp.Data["approvalStatus"])
+	return resp, nil
+}
+
+// evmTxPayload returns the sign payload of the transaction of the request. The quantities left
+// empty are fetched from the node of config/rpc with complete; without it they are required,
+// but for the value, which defaults to 0.
+func evmTxPayload(d *framework.FieldData) (string, error) {
+	complete := d.Get("complete").(bool)
+	fields := make(map[string]json.RawMessage, len(evmTxQuantities)+2)
+	for _, name := range evmTxQuantities {
+		value := strings.TrimSpace(d.Get(name).(string))
+		if value == "" {
+			if !complete && name != "value" {
+				return "", fmt.Errorf("%w: %s is required without complete", helpers.ErrInvalidEVMTxField, name)
+			}
+			continue
+		}
+		quantity, ok := math.ParseBig256(value)
+		if !ok || quantity.Sign() < 0 {
+			return "", fmt.Errorf("%w: %s", helpers.ErrInvalidEVMTxField, name)
+		}
+		fields[name] = json.RawMessage(quantity.String())
+	}
+	if _, ok := fields["value"]; !ok {
+		fields["value"] = json.RawMessage("0")
+	}
+
+	to := d.Get("to").(string)
+	data := d.Get("data").(string)
+	if to != "" {
+		if !common.IsHexAddress(to) {
+			return "", fmt.Errorf("%w: to", helpers.ErrInvalidEVMTxField)
+		}
+		// the sign payload expects the 0x prefix
+		fields["to"], _ = json.Marshal(common.HexToAddress(to).Hex())
+	}
+	if data != "" {
+		decoded, err := hexutil.Decode(withHexPrefix(data))
+		if err != nil {
+			return "", fmt.Errorf("%w: data: %w", helpers.ErrInvalidEVMTxField, err)
+		}
+		fields["data"], _ = json.Marshal(hexutil.Encode(decoded))
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_BuildEVMTx(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+	// the fields of signTestPayload, as hex and decimal strings
+	fields := func() map[string]interface{} {
+		return map[string]interface{}{
+			"uuid":           signTestUUID,
+			"derivationPath": signTestDerivationPath,
+			"to":             "0x742d35cc6634c0532925a3b8d359a5c5119e32c8",
+			"value":          "0xde0b6b3a7640000",
+			"nonce":          "42",
+			"gasLimit":       "21000",
+			"gasPrice":       "20000000000",
+			"chainId":        "1",
+		}
+	}
+
+	t.Run("signed as the payload of sign", func(t *testing.T) {
+		resp, err := request("build/evm-tx", fields())
+		require.NoError(t, err)
+		signed, err := request("sign", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "coinType": 60, "payload": signTestPayload,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, signed.Data["signature"], resp.Data["rawTx"])
+		assert.Equal(t, signed.Data["address"], resp.Data["address"])
+
+		raw, err := hexutil.Decode(resp.Data["rawTx"].(string))
+		require.NoError(t, err)
+		var tx types.Transaction
+		require.NoError(t, tx.UnmarshalBinary(raw))
+		assert.Equal(t, tx.Hash().Hex(), resp.Data["txHash"])
+		assert.Equal(t, uint64(42), tx.Nonce())
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", sender.Hex())
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		data := fields()
+		delete(data, "nonce")
+		_, err := request("build/evm-tx", data)
+		require.ErrorContains(t, err, helpers.ErrInvalidEVMTxField.Error())
+		require.ErrorContains(t, err, "nonce")
+
+		data = fields()
+		data["gasPrice"] = "-1"
+		_, err = request("build/evm-tx", data)
+		require.ErrorContains(t, err, helpers.ErrInvalidEVMTxField.Error())
+
+		data = fields()
+		data["coinType"] = 0
+		_, err = request("build/evm-tx", data)
+		require.ErrorContains(t, err, helpers.ErrUnsupportedCoinType.Error())
+	})
+
+	t.Run("the policies of sign apply", func(t *testing.T) {
+		_, err := request("config/fees/60", map[string]interface{}{"min": 1, "max": 10})
+		require.NoError(t, err)
+		_, err = request("build/evm-tx", fields())
+		require.ErrorContains(t, err, helpers.ErrFeeOutOfBounds.Error())
+	})
+}
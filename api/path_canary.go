@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// pathReadCanaryConfig corresponds to READ config/canary
+func (b *Backend) pathReadCanaryConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_canary_config"))
+
+	canaryConfig, err := helpers.GetCanaryConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: canaryConfigResponseData(canaryConfig),
+	}, nil
+}
+
+// pathWriteCanaryConfig corresponds to UPDATE config/canary. Settings that are not provided keep
+// their stored value; the next periodic run signs with the users added.
+func (b *Backend) pathWriteCanaryConfig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_canary_config"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	canaryConfig, err := helpers.GetCanaryConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("uuids"); ok {
+		canaryConfig.UUIDs = v.([]string)
+	}
+	if v, ok := d.GetOk("interval"); ok {
+		canaryConfig.Interval = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("derivationPath"); ok {
+		canaryConfig.Path = v.(string)
+	}
+	if canaryConfig.Interval < helpers.MinCanaryInterval {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidCanaryInterval.Error())
+	}
+	if _, err := lib.ParseDerivationPath(canaryConfig.Path); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	for _, uuid := range canaryConfig.UUIDs {
+		user, err := helpers.GetUser(ctx, req, uuid)
+		if err != nil {
+			return nil, helpers.CodedError(http.StatusUnprocessableEntity, fmt.Errorf("%s: %w", uuid, err))
+		}
+		if user.WatchOnly() {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity,
+				fmt.Sprintf("%s: %s", uuid, helpers.ErrWatchOnlyUser))
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(config.CanaryStorageKey, canaryConfig)
+	if err != nil {
+		backendLogger.Error("encode canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("canary updated", "uuids", canaryConfig.UUIDs, "interval", canaryConfig.Interval,
+		"path", canaryConfig.Path)
+
+	return &logical.Response{
+		Data: canaryConfigResponseData(canaryConfig),
+	}, nil
+}
+
+// pathDeleteCanaryConfig corresponds to DELETE config/canary. Nothing is signed until canary users
+// are configured again; the last signatures are kept.
+func (b *Backend) pathDeleteCanaryConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_canary_config", config.CanaryStorageKey)
+}
+
+func canaryConfigResponseData(canaryConfig *helpers.CanaryConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"uuids":    canaryConfig.UUIDs,
+		"interval": int64(canaryConfig.Interval.Seconds()),
+		"path":     canaryConfig.Path,
+	}
+}
+
+// pathReadCanary corresponds to READ canary/sign. It returns the last canary signature of each
+// canary user, for the monitoring to verify.
+func (b *Backend) pathReadCanary(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_canary"))
+
+	canaryConfig, err := helpers.GetCanaryConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	canaries := make([]map[string]interface{}, 0, len(canaryConfig.UUIDs))
+	for _, uuid := range canaryConfig.UUIDs {
+		result, err := helpers.GetCanaryResult(ctx, req.Storage, uuid)
+		if err != nil {
+			backendLogger.Error("get canary result", "error", err, "uuid", uuid)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if result != nil {
+			canaries = append(canaries, canaryResponseData(result))
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"canaries": canaries,
+			"interval": int64(canaryConfig.Interval.Seconds()),
+		},
+	}, nil
+}
+
+// pathSignCanary corresponds to UPDATE canary/sign. It signs a canary message at once with every
+// canary user, or the given one, instead of waiting for the periodic function.
+func (b *Backend) pathSignCanary(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_canary"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	canaryConfig, err := helpers.GetCanaryConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get canary config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	uuids := canaryConfig.UUIDs
+	if uuid := d.Get("uuid").(string); uuid != "" {
+		if !slices.Contains(uuids, uuid) {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %s", uuid, helpers.ErrNoCanaryUsers))
+		}
+		uuids = []string{uuid}
+	}
+	if len(uuids) == 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrNoCanaryUsers.Error())
+	}
+
+	canaries := make([]map[string]interface{}, 0, len(uuids))
+	for _, uuid := range uuids {
+		result, err := b.signCanary(ctx, req.Storage, canaryConfig.Path, uuid, time.Now())
+		if err != nil {
+			backendLogger.Error("put canary result", "error", err, "uuid", uuid)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		canaries = append(canaries, canaryResponseData(result))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"canaries": canaries,
+			"interval": int64(canaryConfig.Interval.Seconds()),
+		},
+	}, nil
+}
+
+func canaryResponseData(result *helpers.CanaryResult) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":        result.UUID,
+		"path":        result.Path,
+		"address":     result.Address,
+		"message":     result.Message,
+		"signature":   result.Signature,
+		"signedAt":    formatTime(result.SignedAt),
+		"attemptedAt": formatTime(result.AttemptedAt),
+		"error":       result.Error,
+		"failures":    result.Failures,
+	}
+}
+
+// signCanaries signs a canary message with the canary users whose last attempt is older than the
+// interval of config/canary
+func (b *Backend) signCanaries(ctx context.Context, s logical.Storage, now time.Time) error {
+	canaryConfig, err := helpers.GetCanaryConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, uuid := range canaryConfig.UUIDs {
+		last, err := helpers.GetCanaryResult(ctx, s, uuid)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+			continue
+		}
+		if last != nil && now.Sub(last.AttemptedAt) < canaryConfig.Interval {
+			continue
+		}
+		if _, err := b.signCanary(ctx, s, canaryConfig.Path, uuid, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// signCanary signs the canary message of uuid at now and stores the result. Signing failures are
+// recorded in the result, only the errors of the storage are returned.
+func (b *Backend) signCanary(ctx context.Context, s logical.Storage, path, uuid string,
+	now time.Time) (*helpers.CanaryResult, error) {
+	result, err := helpers.GetCanaryResult(ctx, s, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = &helpers.CanaryResult{UUID: uuid}
+	}
+
+	result.AttemptedAt = now.UTC()
+	message := fmt.Sprintf("dq-vault canary %s %s", uuid, result.AttemptedAt.Format(time.RFC3339))
+	address, signature, err := canarySignature(ctx, s, uuid, path, message)
+	if err != nil {
+		b.logger.Warn("canary signature failed", "error", err, "uuid", uuid, "failures", result.Failures+1)
+		result.Error = err.Error()
+		result.Failures++
+	} else {
+		result.Path, result.Address, result.Message, result.Signature = path, address, message, signature
+		result.SignedAt, result.Error, result.Failures = result.AttemptedAt, "", 0
+	}
+	return result, helpers.PutCanaryResult(ctx, s, result)
+}
+
+// canarySignature signs the EIP-191 personal message of message with the key of uuid at path, through
+// the same record, seed and signer as the sign requests. It returns the Ethereum address of the key
+// and the 65 byte r || s || v signature, v being 27 or 28, as personal_sign verifiers expect.
+func canarySignature(ctx context.Context, s logical.Storage, uuid, path, message string) (string, string, error) {
+	user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, uuid)
+	if err != nil {
+		return "", "", err
+	}
+	if err := user.AuthorizeAnyCoin(); err != nil {
+		return "", "", err
+	}
+	if user.WatchOnly() {
+		return "", "", helpers.ErrWatchOnlyUser
+	}
+	seed, err := userSeed(ctx, user)
+	if err != nil {
+		return "", "", err
+	}
+
+	signature, publicKey, err := lib.SignDigest(seed, lib.CurveSecp256k1, path, accounts.TextHash([]byte(message)))
+	if err != nil {
+		return "", "", err
+	}
+	key, err := crypto.DecompressPubkey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return crypto.PubkeyToAddress(*key).Hex(), "0x" + hex.EncodeToString(signature), nil
+}
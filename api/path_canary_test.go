@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_Canary(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	canary := func(resp *logical.Response) map[string]interface{} {
+		t.Helper()
+		canaries := resp.Data["canaries"].([]map[string]interface{})
+		require.Len(t, canaries, 1)
+		return canaries[0]
+	}
+
+	_, err := request(logical.UpdateOperation, "canary/sign", nil)
+	require.ErrorContains(t, err, helpers.ErrNoCanaryUsers.Error())
+	_, err = request(logical.UpdateOperation, "config/canary", map[string]interface{}{"uuids": "unknown"})
+	require.ErrorContains(t, err, helpers.ErrUserNotFound.Error())
+	_, err = request(logical.UpdateOperation, "config/canary", map[string]interface{}{"interval": 10})
+	require.ErrorContains(t, err, helpers.ErrInvalidCanaryInterval.Error())
+	_, err = request(logical.UpdateOperation, "config/canary", map[string]interface{}{"uuids": signTestUUID})
+	require.NoError(t, err)
+
+	t.Run("the periodic function signs a verifiable message", func(t *testing.T) {
+		require.NoError(t, b.periodic(ctx, &logical.Request{Storage: s}))
+		resp, err := request(logical.ReadOperation, "canary/sign", nil)
+		require.NoError(t, err)
+		result := canary(resp)
+		assert.Empty(t, result["error"])
+		assert.Contains(t, result["message"], "dq-vault canary "+signTestUUID)
+
+		signature, err := hex.DecodeString(strings.TrimPrefix(result["signature"].(string), "0x"))
+		require.NoError(t, err)
+		require.Len(t, signature, crypto.SignatureLength)
+		signature[crypto.RecoveryIDOffset] -= 27
+		key, err := crypto.SigToPub(accounts.TextHash([]byte(result["message"].(string))), signature)
+		require.NoError(t, err)
+		assert.Equal(t, result["address"], crypto.PubkeyToAddress(*key).Hex())
+
+		// the interval has not passed
+		signedAt := result["signedAt"]
+		require.NoError(t, b.signCanaries(ctx, s, time.Now()))
+		resp, err = request(logical.ReadOperation, "canary/sign", nil)
+		require.NoError(t, err)
+		assert.Equal(t, signedAt, canary(resp)["signedAt"])
+	})
+
+	t.Run("failures keep the last signature", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/disable",
+			map[string]interface{}{"version": 0})
+		require.NoError(t, err)
+		resp, err := request(logical.UpdateOperation, "canary/sign", map[string]interface{}{"uuid": signTestUUID})
+		require.NoError(t, err)
+		result := canary(resp)
+		assert.Contains(t, result["error"], helpers.ErrUserNotActive.Error())
+		assert.Equal(t, 1, result["failures"])
+		assert.NotEmpty(t, result["signature"])
+	})
+}
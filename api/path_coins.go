@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathCoins corresponds to READ coins. The list is generated from the adapter
+// registry, so it always matches what the plugin can actually do.
+func (b *Backend) pathCoins(_ context.Context, _ *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_coins"))
+
+	adapterInventory := adapter.GetInventory(backendLogger)
+
+	coins := make([]map[string]interface{}, 0)
+	for _, capabilities := range adapterInventory.Capabilities() {
+		for _, coinType := range capabilities.CoinTypes {
+			coins = append(coins, map[string]interface{}{
+				"coinType":       coinType,
+				"name":           slip44.GetCoinName(coinType),
+				"adapter":        capabilities.Adapter,
+				"curve":          capabilities.Curve,
+				"nonceScheme":    capabilities.NonceScheme(),
+				"operations":     capabilities.Operations,
+				"addressFormats": capabilities.AddressFormats,
+				"payloadFormat":  capabilities.PayloadFormat(),
+				"payloadFields":  capabilities.PayloadFields(),
+				"testnet":        capabilities.Testnet,
+			})
+		}
+	}
+
+	sort.SliceStable(coins, func(i, j int) bool {
+		return coins[i]["coinType"].(uint16) < coins[j]["coinType"].(uint16)
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"coins": coins,
+		},
+	}, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestBackend_PathCoins(t *testing.T) {
+	ctx := context.Background()
+	b := createSignTestBackend(t)
+
+	got, err := b.pathCoins(ctx, &logical.Request{Storage: new(MockStorageSign)}, &framework.FieldData{})
+	require.NoError(t, err)
+
+	coins, ok := got.Data["coins"].([]map[string]interface{})
+	require.True(t, ok)
+
+	byCoinType := make(map[uint16]map[string]interface{}, len(coins))
+	for i, coin := range coins {
+		coinType := coin["coinType"].(uint16)
+		if i > 0 {
+			assert.Less(t, coins[i-1]["coinType"].(uint16), coinType, "coins must be sorted by coinType")
+		}
+		assert.NotEmpty(t, coin["adapter"])
+		byCoinType[coinType] = coin
+	}
+
+	t.Run("ethereum", func(t *testing.T) {
+		eth := byCoinType[slip44.Ether]
+		require.NotNil(t, eth)
+		assert.Equal(t, "Ethereum", eth["name"])
+		assert.Equal(t, lib.CurveSecp256k1, eth["curve"])
+		assert.Equal(t, lib.NonceRFC6979, eth["nonceScheme"])
+		assert.Equal(t, "EthereumRawTx", eth["payloadFormat"])
+		assert.Contains(t, eth["operations"], lib.OperationSign)
+	})
+
+	t.Run("solana lists spl transfers", func(t *testing.T) {
+		sol := byCoinType[slip44.Solana]
+		require.NotNil(t, sol)
+		assert.Equal(t, lib.CurveEd25519, sol["curve"])
+		assert.Equal(t, lib.NonceRFC8032, sol["nonceScheme"])
+		assert.Contains(t, sol["operations"], lib.OperationSPLTransfer)
+	})
+
+	t.Run("ton has testnet mode", func(t *testing.T) {
+		ton := byCoinType[slip44.Ton]
+		require.NotNil(t, ton)
+		assert.Equal(t, true, ton["testnet"])
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+const (
+	// compatSearchAccounts and compatSearchIndexes bound the preset paths searched for an address
+	// that does not match the requested path
+	compatSearchAccounts = 5
+	compatSearchIndexes  = 5
+)
+
+// pathCompatVerify corresponds to UPDATE compat/verify. It derives the address of a mnemonic given in
+// the request at path, or preset, and reports whether it is the address shown by a hardware wallet.
+// On a mismatch the first accounts and indexes of the presets of the coin are searched for it, so
+// integrators see which derivation the wallet used. Nothing is stored; config/features must have
+// compatVerifyEnabled, which is meant for development mounts only.
+func (b *Backend) pathCompatVerify(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_compat_verify"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.CompatVerifyEnabled {
+		backendLogger.Warn("compat verify rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("compat/verify: %s", helpers.ErrFeatureDisabled))
+	}
+
+	coinType := d.Get("coinType").(int)
+	expected := strings.TrimSpace(d.Get("address").(string))
+	isDev := d.Get("isDev").(bool)
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	if expected == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMissingAddress.Error())
+	}
+	derivationPath, err := presetDerivationPath(d, d.Get("derivationPath").(string), coinType)
+	if err != nil {
+		backendLogger.Error("preset path", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if derivationPath == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidPath.Error())
+	}
+
+	seed, err := lib.SeedFromMnemonic(d.Get("mnemonic").(string), d.Get("passphrase").(string))
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	address, err := adapterInventory.DeriveAddress(seed, uint16(coinType), derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	match := sameAddress(address, expected)
+	data := map[string]interface{}{
+		"match":   match,
+		"address": address,
+		"path":    derivationPath,
+	}
+	if !match {
+		if preset, presetPath := searchPresetPaths(adapterInventory, seed, uint16(coinType), expected,
+			isDev); presetPath != "" {
+			data["matchedPreset"], data["matchedPath"] = preset, presetPath
+		}
+	}
+	backendLogger.Info("compat verified", "coinType", coinType, "path", derivationPath, "match", match,
+		"matchedPath", data["matchedPath"])
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// sameAddress compares addresses, the hex addresses of EVM chains in any case
+func sameAddress(address, expected string) bool {
+	if strings.HasPrefix(address, "0x") && strings.HasPrefix(strings.ToLower(expected), "0x") {
+		return strings.EqualFold(address, expected)
+	}
+	return address == expected
+}
+
+// searchPresetPaths returns the first preset path of coinType, within the first accounts and
+// indexes, deriving the expected address
+func searchPresetPaths(inventory *adapter.Inventory, seed []byte, coinType uint16, expected string,
+	isDev bool) (string, string) {
+	seen := make(map[string]struct{})
+	for _, preset := range lib.PathPresets() {
+		if _, err := lib.PresetPath(preset, coinType, 0, 0); err != nil {
+			// the preset has no path for coinType
+			continue
+		}
+		for account := range compatSearchAccounts {
+			for index := range compatSearchIndexes {
+				presetPath, err := lib.PresetPath(preset, coinType, account, index)
+				if err != nil {
+					return "", ""
+				}
+				if _, ok := seen[presetPath]; ok {
+					continue
+				}
+				seen[presetPath] = struct{}{}
+				address, err := inventory.DeriveAddress(seed, coinType, presetPath, isDev)
+				if err == nil && sameAddress(address, expected) {
+					return preset, presetPath
+				}
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+func TestBackend_HandleRequest_CompatVerify(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+
+	verify := func(data map[string]interface{}) (*logical.Response, error) {
+		data["mnemonic"] = signTestValidMnemonic
+		if _, ok := data["coinType"]; !ok {
+			data["coinType"] = 60
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "compat/verify",
+			Storage:   s,
+			Data:      data,
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		_, err := verify(map[string]interface{}{"derivationPath": testDerivationPath, "address": testAddress})
+		require.Error(t, err)
+		var codedErr logical.HTTPCodedError
+		require.ErrorAs(t, err, &codedErr)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+
+	require.NoError(t, s.Put(ctx, createFeaturesStorageEntry(helpers.Features{CompatVerifyEnabled: true})))
+
+	t.Run("matching address in any case", func(t *testing.T) {
+		got, err := verify(map[string]interface{}{"derivationPath": testDerivationPath, "address": strings.ToLower(testAddress)})
+		require.NoError(t, err)
+		assert.Equal(t, true, got.Data["match"])
+		assert.Equal(t, testAddress, got.Data["address"])
+		assert.NotContains(t, got.Data, "matchedPath")
+	})
+
+	t.Run("preset", func(t *testing.T) {
+		got, err := verify(map[string]interface{}{"preset": "trezor", "address": testAddress})
+		require.NoError(t, err)
+		assert.Equal(t, true, got.Data["match"])
+		assert.Equal(t, "m/44'/60'/0'/0/0", got.Data["path"])
+	})
+
+	t.Run("mismatch reports the preset path of the address", func(t *testing.T) {
+		ledger, err := verify(map[string]interface{}{"preset": "ledger-live", "account": 3, "address": testAddress})
+		require.NoError(t, err)
+		assert.Equal(t, false, ledger.Data["match"])
+		assert.Equal(t, "m/44'/60'/3'/0/0", ledger.Data["path"])
+
+		got, err := verify(map[string]interface{}{"derivationPath": testDerivationPath, "address": ledger.Data["address"]})
+		require.NoError(t, err)
+		assert.Equal(t, false, got.Data["match"])
+		assert.Equal(t, "ethereum-default", got.Data["matchedPreset"])
+		assert.Equal(t, "m/44'/60'/3'/0/0", got.Data["matchedPath"])
+	})
+
+	t.Run("unknown address", func(t *testing.T) {
+		got, err := verify(map[string]interface{}{
+			"derivationPath": testDerivationPath, "address": "0x0000000000000000000000000000000000000001",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, false, got.Data["match"])
+		assert.NotContains(t, got.Data, "matchedPath")
+	})
+
+	t.Run("address is required", func(t *testing.T) {
+		_, err := verify(map[string]interface{}{"derivationPath": testDerivationPath})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrMissingAddress.Error())
+	})
+
+	t.Run("invalid mnemonic", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "compat/verify",
+			Storage:   s,
+			Data: map[string]interface{}{
+				"mnemonic": "abandon", "coinType": 60, "derivationPath": testDerivationPath, "address": testAddress,
+			},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), lib.ErrInvalidMnemonic.Error())
+	})
+}
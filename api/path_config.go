@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// pathDeleteFeatures corresponds to DELETE config/features. The default flags apply again.
+func (b *Backend) pathDeleteFeatures(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_features", config.FeaturesStorageKey)
+}
+
+// pathDeleteQuotas corresponds to DELETE config/quotas. The default quotas apply again.
+func (b *Backend) pathDeleteQuotas(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_quotas", config.QuotasStorageKey)
+}
+
+// pathDeleteCache corresponds to DELETE config/cache. The cache is disabled again.
+func (b *Backend) pathDeleteCache(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_cache", config.UserCacheStorageKey)
+	if err == nil {
+		b.resetUserCache()
+	}
+	return resp, err
+}
+
+// pathDeleteLogging corresponds to DELETE config/logging. The level is back to INFO immediately.
+func (b *Backend) pathDeleteLogging(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_logging", config.LoggingStorageKey)
+	if err == nil && b.logLevel != nil {
+		level, _ := logging.ParseLevel(config.Info)
+		b.logLevel.Set(level)
+	}
+	return resp, err
+}
+
+// deleteConfig removes the configuration stored at key, so its defaults apply again
+func (b *Backend) deleteConfig(ctx context.Context, req *logical.Request, op, key string) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", op))
+
+	if err := req.Storage.Delete(ctx, key); err != nil {
+		backendLogger.Error("delete config", "error", err, "key", key)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("config reset to defaults", "key", key)
+	return nil, nil
+}
+
+// pathDeleteStorage corresponds to DELETE config/storage. The user records are kept in the Vault
+// storage again; the encryption key is kept, so records left in the external store stay readable
+// if it is configured back.
+func (b *Backend) pathDeleteStorage(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_storage"))
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if len(storageConfig.EncryptionKey) == 0 {
+		resp, err := b.deleteConfig(ctx, req, "path_delete_storage", config.UserStoreStorageKey)
+		if err == nil {
+			b.resetUserStorage()
+			b.resetUserCache()
+		}
+		return resp, err
+	}
+
+	storageConfig.Type, storageConfig.ConnectionURL, storageConfig.Table = storage.TypeVault, "", ""
+	entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, storageConfig)
+	if err != nil {
+		backendLogger.Error("encode storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.resetUserStorage()
+	b.resetUserCache()
+	backendLogger.Info("storage reset to the vault storage")
+	return nil, nil
+}
+
+// pathReadImport corresponds to READ config/import. It returns the whole configuration of the
+// mount as one document, in the shape written to each config path, so existing mounts can be
+// imported into declarative tooling.
+func (b *Backend) pathReadImport(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_import"))
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	cacheConfig, err := helpers.GetCacheConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get cache config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	loggingConfig, err := helpers.GetLoggingConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get logging config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	attestationConfig, err := helpers.GetAttestationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get attestation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	escrowConfig, err := helpers.GetEscrowConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get escrow config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	retention, err := helpers.GetRetentionConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get retention config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	passphrasePolicy, err := helpers.GetPassphrasePolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get passphrase policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinTypes, err := req.Storage.List(ctx, config.RPCStoragePath)
+	if err != nil {
+		backendLogger.Error("list rpc endpoints", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	endpoints := make(map[string]interface{}, len(coinTypes))
+	for _, name := range coinTypes {
+		coinType, err := strconv.ParseUint(name, 10, 16)
+		if err != nil {
+			continue
+		}
+		endpoint, err := helpers.GetRPCEndpoint(ctx, req.Storage, uint16(coinType))
+		if err != nil {
+			backendLogger.Error("get rpc endpoint", "error", err, "coinType", coinType)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if endpoint != nil {
+			endpoints[name] = rpcResponseData(endpoint)
+		}
+	}
+
+	chains, err := req.Storage.List(ctx, config.HooksStoragePath)
+	if err != nil {
+		backendLogger.Error("list hook chains", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	hookChains := make(map[string]interface{}, len(chains))
+	for _, name := range chains {
+		coinType, err := strconv.ParseUint(name, 10, 16)
+		if err != nil {
+			continue
+		}
+		chain, err := helpers.GetHookChain(ctx, req.Storage, uint16(coinType))
+		if err != nil {
+			backendLogger.Error("get hook chain", "error", err, "coinType", coinType)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if chain != nil {
+			hookChains[name] = hooksResponseData(chain)
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"features":    featuresResponseData(features),
+			"quotas":      quotasResponseData(quotas),
+			"cache":       cacheResponseData(cacheConfig),
+			"logging":     loggingResponseData(loggingConfig),
+			"storage":     storageResponseData(storageConfig),
+			"attestation": attestationResponseData(attestationConfig),
+			"escrow":      escrowConfigResponseData(escrowConfig),
+			"retention":   retentionResponseData(retention),
+			"passphrase":  passphrasePolicyResponseData(passphrasePolicy),
+			"rpc":         endpoints,
+			"hooks":       hookChains,
+		},
+	}, nil
+}
+
+// sortedListResponse returns keys as a list response in a stable order, whatever the order of the storage
+func sortedListResponse(keys []string) *logical.Response {
+	sort.Strings(keys)
+	return logical.ListResponse(keys)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadAddressOverrides corresponds to READ config/address-overrides
+func (b *Backend) pathReadAddressOverrides(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_address_overrides"))
+
+	policy, err := helpers.GetAddressOverridePolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get address override policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: addressOverridesResponseData(policy),
+	}, nil
+}
+
+// pathWriteAddressOverrides corresponds to UPDATE config/address-overrides. The allowed overrides
+// replace the stored ones.
+func (b *Backend) pathWriteAddressOverrides(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_address_overrides"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var allowed []helpers.AddressOverride
+	if err := decodeAddressOverrides(d.Get("allowed"), &allowed); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: %w", helpers.ErrInvalidAddressOverride, err).Error())
+	}
+	policy := &helpers.AddressOverridePolicy{Allowed: []helpers.AddressOverride{}}
+	for _, override := range allowed {
+		if override.IsZero() {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAddressOverride.Error())
+		}
+		if !policy.Allows(override) {
+			policy.Allowed = append(policy.Allowed, override)
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.AddressOverridesStorageKey, policy)
+	if err != nil {
+		backendLogger.Error("encode address override policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put address override policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("address override policy updated", "allowed", len(policy.Allowed))
+
+	return &logical.Response{
+		Data: addressOverridesResponseData(policy),
+	}, nil
+}
+
+// pathDeleteAddressOverrides corresponds to DELETE config/address-overrides. No override is allowed
+// again.
+func (b *Backend) pathDeleteAddressOverrides(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_address_overrides", config.AddressOverridesStorageKey)
+}
+
+func addressOverridesResponseData(policy *helpers.AddressOverridePolicy) map[string]interface{} {
+	allowed := make([]map[string]interface{}, 0, len(policy.Allowed))
+	for _, override := range policy.Allowed {
+		allowed = append(allowed, addressOverrideResponseData(override))
+	}
+	return map[string]interface{}{
+		"allowed": allowed,
+	}
+}
+
+// addressOverrideResponseData returns the fields override sets
+func addressOverrideResponseData(override helpers.AddressOverride) map[string]interface{} {
+	data := map[string]interface{}{}
+	if override.HRP != "" {
+		data["hrp"] = override.HRP
+	}
+	if override.PubKeyHashVersion != nil {
+		data["pubKeyHashVersion"] = *override.PubKeyHashVersion
+	}
+	if override.ScriptHashVersion != nil {
+		data["scriptHashVersion"] = *override.ScriptHashVersion
+	}
+	return data
+}
+
+// decodeAddressOverrides decodes the objects of the allowed field into overrides, rejecting the
+// unknown fields
+func decodeAddressOverrides(value interface{}, overrides *[]helpers.AddressOverride) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(overrides)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+)
+
+func TestBackend_HandleRequest_AddressOverrides(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	address := func(coinType int, derivationPath string, overrides map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		data := map[string]interface{}{"uuid": signTestUUID, "coinType": coinType, "derivationPath": derivationPath}
+		for name, value := range overrides {
+			data[name] = value
+		}
+		return request(logical.UpdateOperation, "address", data)
+	}
+	litecoin := map[string]interface{}{"hrp": "ltc", "pubKeyHashVersion": 48, "scriptHashVersion": 50}
+
+	// none is allowed by default
+	resp, err := request(logical.ReadOperation, "config/address-overrides", nil)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Data["allowed"])
+	_, err = address(0, "m/84'/0'/0'/0/0", litecoin)
+	require.ErrorContains(t, err, helpers.ErrAddressOverrideNotAllowed.Error())
+	assert.Equal(t, http.StatusForbidden, err.(logical.HTTPCodedError).Code())
+
+	_, err = request(logical.UpdateOperation, "config/address-overrides", map[string]interface{}{
+		"allowed": []interface{}{map[string]interface{}{"hrp": "LTC"}},
+	})
+	require.ErrorContains(t, err, bitcoin.ErrInvalidHRP.Error())
+	_, err = request(logical.UpdateOperation, "config/address-overrides", map[string]interface{}{
+		"allowed": []interface{}{map[string]interface{}{"pubKeyHashVersion": 256}},
+	})
+	require.ErrorContains(t, err, helpers.ErrInvalidAddressOverride.Error())
+	_, err = request(logical.UpdateOperation, "config/address-overrides", map[string]interface{}{
+		"allowed": []interface{}{map[string]interface{}{"prefix": "ltc"}},
+	})
+	require.ErrorContains(t, err, helpers.ErrInvalidAddressOverride.Error())
+
+	resp, err = request(logical.UpdateOperation, "config/address-overrides", map[string]interface{}{
+		"allowed": []interface{}{litecoin, litecoin},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{litecoin}, resp.Data["allowed"])
+
+	t.Run("bech32 addresses take the hrp", func(t *testing.T) {
+		want, err := address(0, "m/84'/0'/0'/0/0", nil)
+		require.NoError(t, err)
+		assert.NotContains(t, want.Data, "addressOverride")
+		got, err := address(0, "m/84'/0'/0'/0/0", litecoin)
+		require.NoError(t, err)
+		assert.Equal(t, litecoin, got.Data["addressOverride"])
+		// the same witness program, with the checksum of the hrp
+		standard, overridden := want.Data["address"].(string), got.Data["address"].(string)
+		assert.Equal(t, "ltc"+standard[2:len(standard)-6], overridden[:len(overridden)-6])
+	})
+
+	t.Run("base58 addresses take the version", func(t *testing.T) {
+		got, err := address(0, "m/44'/0'/0'/0/0", litecoin)
+		require.NoError(t, err)
+		// 1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA with the version of Litecoin
+		assert.Equal(t, "Lf48XedjbkDbjcUu8b58YgtF5jgpff6imo", got.Data["address"])
+	})
+
+	t.Run("overrides are allowed as a whole", func(t *testing.T) {
+		_, err := address(0, "m/84'/0'/0'/0/0", map[string]interface{}{"hrp": "ltc"})
+		require.ErrorContains(t, err, helpers.ErrAddressOverrideNotAllowed.Error())
+	})
+
+	t.Run("only bitcoin coin types", func(t *testing.T) {
+		_, err := address(60, signTestDerivationPath, litecoin)
+		require.ErrorContains(t, err, helpers.ErrAddressOverrideCoinType.Error())
+	})
+
+	_, err = request(logical.DeleteOperation, "config/address-overrides", nil)
+	require.NoError(t, err)
+	entry, err := s.Get(ctx, config.AddressOverridesStorageKey)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+	_, err = address(0, "m/84'/0'/0'/0/0", litecoin)
+	require.ErrorContains(t, err, helpers.ErrAddressOverrideNotAllowed.Error())
+}
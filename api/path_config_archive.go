@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/archive"
+)
+
+const (
+	// archiveBatch bounds the records of a class archived by a run, the next runs archive the rest
+	archiveBatch = 10000
+	// archiveTimeout bounds a run of the archiver, the records left are archived by the next one
+	archiveTimeout = 5 * time.Minute
+)
+
+// pathReadArchive corresponds to READ config/archive.
+func (b *Backend) pathReadArchive(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_archive"))
+
+	archiveConfig, err := helpers.GetArchiveConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get archive config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if archiveConfig == nil {
+		return nil, nil
+	}
+	state, err := helpers.GetArchiveState(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get archive state", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := archiveResponseData(archiveConfig)
+	data["segments"] = state.Segments
+	data["receiptSequence"] = state.ReceiptSequence
+	data["journalSequence"] = state.JournalSequence
+	if !state.LastRunAt.IsZero() {
+		data["lastRunAt"] = state.LastRunAt.Format(time.RFC3339)
+	}
+	if state.LastError != "" {
+		data["lastError"] = state.LastError
+	}
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathWriteArchive corresponds to UPDATE config/archive. The bucket replaces the stored one, the
+// archiving carries on from where it stopped.
+func (b *Backend) pathWriteArchive(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_archive"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	archiveConfig := &helpers.ArchiveConfig{
+		Endpoint:        d.Get("endpoint").(string),
+		Bucket:          d.Get("bucket").(string),
+		Region:          d.Get("region").(string),
+		Prefix:          d.Get("prefix").(string),
+		AccessKeyID:     d.Get("accessKeyId").(string),
+		SecretAccessKey: d.Get("secretAccessKey").(string),
+		Interval:        time.Duration(d.Get("interval").(int)) * time.Second,
+		LocalRetention:  time.Duration(d.Get("localRetention").(int)) * time.Second,
+	}
+	if err := archiveConfig.Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutArchiveConfig(ctx, req.Storage, archiveConfig); err != nil {
+		backendLogger.Error("put archive config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("archive updated", "endpoint", archiveConfig.Endpoint, "bucket", archiveConfig.Bucket,
+		"prefix", archiveConfig.Prefix, "interval", archiveConfig.Interval,
+		"localRetention", archiveConfig.LocalRetention, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: archiveResponseData(archiveConfig),
+	}, nil
+}
+
+// pathDeleteArchive corresponds to DELETE config/archive. Nothing is archived or pruned any more;
+// the progress is kept, so configuring a bucket again carries on from where it stopped.
+func (b *Backend) pathDeleteArchive(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_archive", config.ArchiveStorageKey)
+}
+
+func archiveResponseData(archiveConfig *helpers.ArchiveConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"endpoint":           archiveConfig.Endpoint,
+		"bucket":             archiveConfig.Bucket,
+		"region":             archiveConfig.Region,
+		"prefix":             archiveConfig.Prefix,
+		"accessKeyId":        archiveConfig.AccessKeyID,
+		"secretAccessKeySet": archiveConfig.SecretAccessKey != "",
+		"interval":           int64(archiveConfig.Interval.Seconds()),
+		"localRetention":     int64(archiveConfig.LocalRetention.Seconds()),
+	}
+}
+
+// archivedCapture -- a debug capture as archived, with the user it was recorded for
+type archivedCapture struct {
+	UUID string `json:"uuid"`
+	*helpers.DebugCapture
+}
+
+// storedCapture -- a debug capture with its storage key
+type storedCapture struct {
+	key  string
+	uuid string
+	*helpers.DebugCapture
+}
+
+// archiveAuditData uploads the receipts, debug captures and replication journal changes recorded
+// since the last run to the bucket of config/archive, one segment per class, once its interval has
+// passed since the last run. The archived receipts and captures older than the local retention are
+// then removed from the Vault storage; the journal is left to the replication reading it.
+func (b *Backend) archiveAuditData(ctx context.Context, s logical.Storage, now time.Time) error {
+	b.archiveMu.Lock()
+	defer b.archiveMu.Unlock()
+
+	archiveConfig, err := helpers.GetArchiveConfig(ctx, s)
+	if err != nil || archiveConfig == nil {
+		return err
+	}
+	state, err := helpers.GetArchiveState(ctx, s)
+	if err != nil {
+		return err
+	}
+	if now.Before(state.LastRunAt.Add(archiveConfig.Interval)) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+	runErr := b.uploadAuditData(ctx, s, archiveConfig, state, now)
+	state.LastRunAt, state.LastError = now, ""
+	if runErr != nil {
+		state.LastError = runErr.Error()
+	}
+	if err := helpers.PutArchiveState(ctx, s, state); err != nil {
+		return errors.Join(runErr, err)
+	}
+	if runErr != nil {
+		return runErr
+	}
+	return b.pruneArchived(ctx, s, state, now.Add(-archiveConfig.LocalRetention))
+}
+
+// uploadAuditData uploads the segments of the records past the cursors of state, moving each cursor
+// once its segment is stored
+func (b *Backend) uploadAuditData(ctx context.Context, s logical.Storage, archiveConfig *helpers.ArchiveConfig,
+	state *helpers.ArchiveState, now time.Time) error {
+	uploader, err := b.newUploader(archiveConfig.Options())
+	if err != nil {
+		return err
+	}
+	day := now.UTC().Format("2006/01/02")
+
+	receipts, err := receiptsSince(ctx, s, state.ReceiptSequence, archiveBatch)
+	if err != nil {
+		return err
+	}
+	if len(receipts) > 0 {
+		first, last := receipts[0].Sequence, receipts[len(receipts)-1].Sequence
+		if err := uploadSegment(ctx, uploader, fmt.Sprintf("receipts/%s/%d-%d.jsonl.gz", day, first, last),
+			receipts); err != nil {
+			return err
+		}
+		state.ReceiptSequence = last
+		state.Segments++
+	}
+
+	captures, err := debugCaptures(ctx, s)
+	if err != nil {
+		return err
+	}
+	archived := make([]archivedCapture, 0, len(captures))
+	for _, capture := range captures {
+		if capture.Time.After(state.DebugCaptureTime) {
+			archived = append(archived, archivedCapture{UUID: capture.uuid, DebugCapture: capture.DebugCapture})
+		}
+	}
+	if len(archived) > 0 {
+		first, last := archived[0].Time, archived[len(archived)-1].Time
+		if err := uploadSegment(ctx, uploader, fmt.Sprintf("debug/%s/%d-%d.jsonl.gz", day, first.UnixNano(),
+			last.UnixNano()), archived); err != nil {
+			return err
+		}
+		state.DebugCaptureTime = last
+		state.Segments++
+	}
+
+	changes, err := storage.JournalChanges(ctx, s, config.ReplicationStoragePath, state.JournalSequence,
+		archiveBatch)
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		first, last := changes[0].Seq, changes[len(changes)-1].Seq
+		if err := uploadSegment(ctx, uploader, fmt.Sprintf("journal/%s/%d-%d.jsonl.gz", day, first, last),
+			changes); err != nil {
+			return err
+		}
+		state.JournalSequence = last
+		state.Segments++
+	}
+	b.logger.Info("audit data archived", "receipts", len(receipts), "debugCaptures", len(archived),
+		"journal", len(changes), "bucket", archiveConfig.Bucket)
+	return nil
+}
+
+func uploadSegment[T any](ctx context.Context, uploader archive.Uploader, key string, records []T) error {
+	segment, err := archive.EncodeSegment(records)
+	if err != nil {
+		return err
+	}
+	return uploader.Upload(ctx, key, segment)
+}
+
+// pruneArchived removes the receipts and debug captures archived by state and recorded before
+// cutoff
+func (b *Backend) pruneArchived(ctx context.Context, s logical.Storage, state *helpers.ArchiveState,
+	cutoff time.Time) error {
+	receipts, err := receiptsSince(ctx, s, 0, math.MaxInt)
+	if err != nil {
+		return err
+	}
+	removed := 0
+	for _, receipt := range receipts {
+		if receipt.Sequence > state.ReceiptSequence || !receipt.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.Delete(ctx, config.ReceiptsStoragePath+receipt.ID); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	captures, err := debugCaptures(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, capture := range captures {
+		if capture.Time.After(state.DebugCaptureTime) || !capture.Time.Before(cutoff) {
+			continue
+		}
+		if err := s.Delete(ctx, capture.key); err != nil {
+			return err
+		}
+		removed++
+	}
+	if removed > 0 {
+		b.logger.Info("archived audit data pruned", "removed", removed)
+	}
+	return nil
+}
+
+// receiptsSince returns the first limit receipts after the sequence number since, by sequence number
+func receiptsSince(ctx context.Context, s logical.Storage, since uint64, limit int) ([]*helpers.Receipt, error) {
+	ids, err := s.List(ctx, config.ReceiptsStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	var receipts []*helpers.Receipt
+	for _, id := range ids {
+		if strings.HasSuffix(id, "/") || config.ReceiptsStoragePath+id == config.ReceiptSequenceStorageKey {
+			continue
+		}
+		receipt, err := helpers.GetReceipt(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil && receipt.Sequence > since {
+			receipts = append(receipts, receipt)
+		}
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].Sequence < receipts[j].Sequence
+	})
+	return receipts[:min(len(receipts), limit)], nil
+}
+
+// debugCaptures returns the debug captures of all the users, oldest first
+func debugCaptures(ctx context.Context, s logical.Storage) ([]storedCapture, error) {
+	uuids, err := s.List(ctx, config.DebugStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	var captures []storedCapture
+	for _, name := range uuids {
+		if !strings.HasSuffix(name, "/") {
+			continue
+		}
+		prefix := config.DebugStoragePath + name
+		ids, err := s.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			entry, err := s.Get(ctx, prefix+id)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue
+			}
+			var capture helpers.DebugCapture
+			if err := entry.DecodeJSON(&capture); err != nil {
+				return nil, err
+			}
+			captures = append(captures, storedCapture{key: prefix + id, uuid: strings.TrimSuffix(name, "/"),
+				DebugCapture: &capture})
+		}
+	}
+	sort.SliceStable(captures, func(i, j int) bool {
+		return captures[i].Time.Before(captures[j].Time)
+	})
+	return captures, nil
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/archive"
+)
+
+// fakeUploader records the segments uploaded, keyed by object key
+type fakeUploader struct {
+	mu       sync.Mutex
+	options  archive.S3Options
+	segments map[string][]byte
+	err      error
+}
+
+func (u *fakeUploader) Upload(_ context.Context, key string, body []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err != nil {
+		return u.err
+	}
+	u.segments[key] = body
+	return nil
+}
+
+// keys returns the keys uploaded, sorted
+func (u *fakeUploader) keys() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	keys := make([]string, 0, len(u.segments))
+	for key := range u.segments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lines returns the JSON documents of the segment key
+func (u *fakeUploader) lines(t *testing.T, key string) []map[string]interface{} {
+	t.Helper()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	reader, err := gzip.NewReader(bytes.NewReader(u.segments[key]))
+	require.NoError(t, err)
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestBackend_HandleRequest_Archive(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	uploader := &fakeUploader{segments: map[string][]byte{}}
+	b.newUploader = func(options archive.S3Options) (archive.Uploader, error) {
+		uploader.options = options
+		return uploader, nil
+	}
+	s := &logical.InmemStorage{}
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	now := time.Now().UTC()
+	putReceipt := func(sequence uint64, age time.Duration) {
+		require.NoError(t, helpers.PutReceipt(ctx, s, &helpers.Receipt{
+			ID: helpers.NewUUID(), Sequence: sequence, UUID: "alice", CreatedAt: now.Add(-age),
+		}))
+	}
+	putCapture := func(uuid string, age time.Duration) {
+		capture := helpers.DebugCapture{ID: helpers.NewUUID(), Time: now.Add(-age), Path: "sign"}
+		entry, err := logical.StorageEntryJSON(config.DebugStoragePath+uuid+"/"+capture.ID, capture)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, entry))
+	}
+	count := func(prefix string) int {
+		ids, err := s.List(ctx, prefix)
+		require.NoError(t, err)
+		return len(ids)
+	}
+
+	resp, err := request(logical.ReadOperation, "config/archive", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+	// nothing is archived without a bucket
+	require.NoError(t, b.archiveAuditData(ctx, s, now))
+	assert.Empty(t, uploader.keys())
+
+	t.Run("invalid config is rejected", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{
+			{"endpoint": "minio:9000", "bucket": "audit", "accessKeyId": "id", "secretAccessKey": "secret"},
+			{"endpoint": "https://minio:9000", "accessKeyId": "id", "secretAccessKey": "secret"},
+			{"endpoint": "https://minio:9000", "bucket": "audit", "accessKeyId": "id"},
+			{"endpoint": "https://minio:9000", "bucket": "audit", "accessKeyId": "id", "secretAccessKey": "secret",
+				"interval": -1},
+		} {
+			_, err := request(logical.UpdateOperation, "config/archive", data)
+			require.Error(t, err)
+		}
+	})
+
+	resp, err = request(logical.UpdateOperation, "config/archive", map[string]interface{}{
+		"endpoint": "https://minio:9000", "bucket": "audit", "prefix": "prod/", "accessKeyId": "id",
+		"secretAccessKey": "secret", "localRetention": "24h",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["secretAccessKeySet"])
+	assert.NotContains(t, resp.Data, "secretAccessKey")
+	assert.Equal(t, int64(helpers.DefaultArchiveInterval.Seconds()), resp.Data["interval"])
+	assert.Equal(t, int64(86400), resp.Data["localRetention"])
+
+	putReceipt(1, 48*time.Hour)
+	putReceipt(2, time.Hour)
+	require.NoError(t, s.Put(ctx, &logical.StorageEntry{Key: config.ReceiptSequenceStorageKey, Value: []byte("2")}))
+	putCapture("alice", 36*time.Hour)
+	putCapture("bob", time.Minute)
+	journal := storage.NewJournal(s, s, config.StorageBasePath, config.ReplicationStoragePath, &sync.Mutex{})
+	require.NoError(t, journal.Put(ctx, &logical.StorageEntry{Key: config.StorageBasePath + "alice", Value: []byte("{}")}))
+
+	t.Run("periodic uploads a segment per class and prunes the old records", func(t *testing.T) {
+		require.NoError(t, b.periodic(ctx, &logical.Request{Storage: s}))
+
+		day := time.Now().UTC().Format("2006/01/02")
+		keys := uploader.keys()
+		require.Len(t, keys, 3)
+		assert.Equal(t, "journal/"+day+"/1-1.jsonl.gz", keys[1])
+		assert.Equal(t, "receipts/"+day+"/1-2.jsonl.gz", keys[2])
+		assert.True(t, strings.HasPrefix(keys[0], "debug/"+day+"/"), keys[0])
+		assert.Equal(t, "secret", uploader.options.SecretAccessKey)
+		assert.Equal(t, "prod/", uploader.options.Prefix)
+
+		receipts := uploader.lines(t, keys[2])
+		require.Len(t, receipts, 2)
+		assert.EqualValues(t, 1, receipts[0]["sequence"])
+		assert.EqualValues(t, 2, receipts[1]["sequence"])
+		captures := uploader.lines(t, keys[0])
+		require.Len(t, captures, 2)
+		assert.Equal(t, "alice", captures[0]["uuid"])
+		assert.Equal(t, "bob", captures[1]["uuid"])
+		assert.Equal(t, []map[string]interface{}{{"seq": 1.0, "name": "alice", "version": 1.0,
+			"time": uploader.lines(t, keys[1])[0]["time"]}}, uploader.lines(t, keys[1]))
+
+		// the receipt and capture past the local retention are removed, the sequence is kept
+		assert.Equal(t, 2, count(config.ReceiptsStoragePath))
+		assert.Equal(t, 0, count(config.DebugStoragePath+"alice/"))
+		assert.Equal(t, 1, count(config.DebugStoragePath+"bob/"))
+
+		resp, err := request(logical.ReadOperation, "config/archive", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, resp.Data["segments"])
+		assert.EqualValues(t, 2, resp.Data["receiptSequence"])
+		assert.Contains(t, resp.Data, "lastRunAt")
+		assert.NotContains(t, resp.Data, "lastError")
+	})
+
+	t.Run("runs within the interval do nothing", func(t *testing.T) {
+		putReceipt(3, 0)
+		require.NoError(t, b.periodic(ctx, &logical.Request{Storage: s}))
+		assert.Len(t, uploader.keys(), 3)
+	})
+
+	t.Run("next run uploads only the new records", func(t *testing.T) {
+		require.NoError(t, b.archiveAuditData(ctx, s, time.Now().Add(2*time.Hour)))
+		keys := uploader.keys()
+		require.Len(t, keys, 4)
+		assert.Contains(t, keys, "receipts/"+time.Now().Add(2*time.Hour).UTC().Format("2006/01/02")+"/3-3.jsonl.gz")
+	})
+
+	t.Run("a failed upload is reported and retried", func(t *testing.T) {
+		uploader.err = archive.ErrUploadFailed
+		putReceipt(4, 72*time.Hour)
+		require.ErrorIs(t, b.archiveAuditData(ctx, s, time.Now().Add(4*time.Hour)), archive.ErrUploadFailed)
+		resp, err := request(logical.ReadOperation, "config/archive", nil)
+		require.NoError(t, err)
+		assert.Contains(t, resp.Data["lastError"], archive.ErrUploadFailed.Error())
+		// nothing is pruned before it is archived
+		receipts, err := receiptsSince(ctx, s, 3, 10)
+		require.NoError(t, err)
+		assert.Len(t, receipts, 1)
+
+		uploader.err = nil
+		require.NoError(t, b.archiveAuditData(ctx, s, time.Now().Add(6*time.Hour)))
+		receipts, err = receiptsSince(ctx, s, 3, 10)
+		require.NoError(t, err)
+		assert.Empty(t, receipts)
+	})
+
+	t.Run("delete keeps the progress", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "config/archive", nil)
+		require.NoError(t, err)
+		resp, err := request(logical.ReadOperation, "config/archive", nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+		state, err := helpers.GetArchiveState(ctx, s)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, state.ReceiptSequence)
+	})
+}
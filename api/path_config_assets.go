@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// pathListAssets corresponds to LIST config/assets. The keys are <coinType>/<symbol>.
+func (b *Backend) pathListAssets(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_assets"))
+
+	assets, err := helpers.ListAssets(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("list assets", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	keys := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		keys = append(keys, fmt.Sprintf("%d/%s", asset.CoinType, asset.Symbol))
+	}
+	return sortedListResponse(keys), nil
+}
+
+// pathReadAsset corresponds to READ config/assets/<coinType>/<symbol>.
+func (b *Backend) pathReadAsset(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_asset"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	asset, err := helpers.GetAsset(ctx, req.Storage, coinType, d.Get("symbol").(string))
+	if err != nil {
+		backendLogger.Error("get asset", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if asset == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: assetResponseData(asset),
+	}, nil
+}
+
+// pathWriteAsset corresponds to UPDATE config/assets/<coinType>/<symbol>. The asset replaces the
+// stored one of the same symbol; an address registers a single symbol of its coin type.
+func (b *Backend) pathWriteAsset(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_asset"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if _, err := adapter.GetInventory(backendLogger).CoinCapabilities(coinType); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	asset := &helpers.Asset{
+		Symbol:   d.Get("symbol").(string),
+		CoinType: coinType,
+		Address:  d.Get("address").(string),
+		Decimals: d.Get("decimals").(int),
+	}
+	if err := helpers.ValidateAsset(asset); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	// EVM contracts are stored checksummed
+	if asset.Address, err = helpers.NormalizeAssetAddress(coinType, asset.Address); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	index, err := helpers.GetAssetIndex(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("get asset index", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if registered := index.Lookup(asset.Address); registered != nil && !strings.EqualFold(registered.Symbol,
+		asset.Symbol) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: %s", helpers.ErrDuplicateAsset, registered.Symbol).Error())
+	}
+	previous, err := helpers.GetAsset(ctx, req.Storage, coinType, asset.Symbol)
+	if err != nil {
+		backendLogger.Error("get asset", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	now := time.Now().UTC()
+	asset.CreatedAt, asset.UpdatedAt = now, now
+	if previous != nil {
+		asset.CreatedAt = previous.CreatedAt
+	}
+
+	if err := helpers.PutAsset(ctx, req.Storage, asset); err != nil {
+		backendLogger.Error("put asset", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("asset updated", "symbol", asset.Symbol, "coinType", coinType, "address", asset.Address,
+		"decimals", asset.Decimals, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: assetResponseData(asset),
+	}, nil
+}
+
+// pathDeleteAsset corresponds to DELETE config/assets/<coinType>/<symbol>.
+func (b *Backend) pathDeleteAsset(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_asset"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	symbol := d.Get("symbol").(string)
+	if err := helpers.DeleteAsset(ctx, req.Storage, coinType, symbol); err != nil {
+		backendLogger.Error("delete asset", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("asset deleted", "symbol", symbol, "coinType", coinType, "entity", req.EntityID)
+	return nil, nil
+}
+
+func assetResponseData(asset *helpers.Asset) map[string]interface{} {
+	return map[string]interface{}{
+		"symbol":    asset.Symbol,
+		"coinType":  asset.CoinType,
+		"address":   asset.Address,
+		"decimals":  asset.Decimals,
+		"createdAt": formatTime(asset.CreatedAt),
+		"updatedAt": formatTime(asset.UpdatedAt),
+	}
+}
+
+// pathReadContractPolicy corresponds to READ config/contracts.
+func (b *Backend) pathReadContractPolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_contract_policy"))
+
+	policy, err := helpers.GetContractPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get contract policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{"restrictedCoinTypes": policy.RestrictedCoinTypes},
+	}, nil
+}
+
+// pathWriteContractPolicy corresponds to UPDATE config/contracts. The policy replaces the stored one.
+func (b *Backend) pathWriteContractPolicy(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_contract_policy"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	policy := &helpers.ContractPolicy{RestrictedCoinTypes: []uint16{}}
+	for _, coinType := range d.Get("restrictedCoinTypes").([]int) {
+		if coinType < 0 || coinType > math.MaxUint16 || !evm.NewEthereumAdapter(b.logger).CanDo(uint16(coinType)) {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidContractPolicy.Error())
+		}
+		if !slices.Contains(policy.RestrictedCoinTypes, uint16(coinType)) {
+			policy.RestrictedCoinTypes = append(policy.RestrictedCoinTypes, uint16(coinType))
+		}
+	}
+	slices.Sort(policy.RestrictedCoinTypes)
+
+	if err := helpers.PutContractPolicy(ctx, req.Storage, policy); err != nil {
+		backendLogger.Error("put contract policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("contract policy updated", "restrictedCoinTypes", policy.RestrictedCoinTypes,
+		"entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{"restrictedCoinTypes": policy.RestrictedCoinTypes},
+	}, nil
+}
+
+// pathDeleteContractPolicy corresponds to DELETE config/contracts.
+func (b *Backend) pathDeleteContractPolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_contract_policy"))
+
+	if err := helpers.DeleteContractPolicy(ctx, req.Storage); err != nil {
+		backendLogger.Error("delete contract policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("contract policy deleted", "entity", req.EntityID)
+	return nil, nil
+}
+
+// checkContracts checks the contract called by the EVM payload of sign against the contract policy
+// of coinType: a payload with call data must call the contract of a registered asset. Payments
+// without call data call no contract; contract creations are refused.
+func checkContracts(ctx context.Context, s logical.Storage, coinType uint16, payload string) error {
+	policy, err := helpers.GetContractPolicy(ctx, s)
+	if err != nil || policy == nil || !slices.Contains(policy.RestrictedCoinTypes, coinType) {
+		return err
+	}
+
+	var tx lib.EthereumRawTx
+	if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+		return helpers.ErrContractNotRegistered
+	}
+	if strings.TrimPrefix(strings.ToLower(tx.Data), "0x") == "" {
+		return nil
+	}
+	if tx.To == "" {
+		return fmt.Errorf("%w: contract creation", helpers.ErrContractNotRegistered)
+	}
+	index, err := helpers.GetAssetIndex(ctx, s, coinType)
+	if err != nil {
+		return err
+	}
+	if index.Lookup(tx.To) == nil {
+		return fmt.Errorf("%w: %s", helpers.ErrContractNotRegistered, tx.To)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_Assets(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	const usdc = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	sign := func(to, data string) (*logical.Response, error) {
+		return request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": signTestDerivationPath,
+			"payload": `{"nonce":1,"value":0,"gasLimit":60000,"gasPrice":1,"chainId":1,"to":"` + to +
+				`","data":"` + data + `"}`,
+		})
+	}
+
+	t.Run("invalid assets are rejected", func(t *testing.T) {
+		for path, data := range map[string]map[string]interface{}{
+			"config/assets/60/USDC":    {"address": "A0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "decimals": 6},
+			"config/assets/60/DAI":     {"address": usdc, "decimals": 256},
+			"config/assets/501/USDC":   {"address": usdc, "decimals": 6},
+			"config/assets/70000/USDC": {"address": usdc, "decimals": 6},
+		} {
+			_, err := request(logical.UpdateOperation, path, data)
+			require.Error(t, err, path)
+		}
+	})
+
+	resp, err := request(logical.UpdateOperation, "config/assets/60/USDC", map[string]interface{}{
+		"address": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "decimals": 6,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, usdc, resp.Data["address"], "stored checksummed")
+	_, err = request(logical.UpdateOperation, "config/assets/501/USDC", map[string]interface{}{
+		"address": "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", "decimals": 6,
+	})
+	require.NoError(t, err)
+
+	t.Run("symbols compare case insensitively", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "config/assets/60/usdc", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "USDC", resp.Data["symbol"])
+		assert.Equal(t, 6, resp.Data["decimals"])
+
+		_, err = request(logical.UpdateOperation, "config/assets/60/USDC2", map[string]interface{}{
+			"address": usdc, "decimals": 6,
+		})
+		require.ErrorContains(t, err, helpers.ErrDuplicateAsset.Error())
+
+		resp, err = request(logical.ListOperation, "config/assets/", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"501/USDC", "60/USDC"}, resp.Data["keys"])
+	})
+
+	transferData := "0xa9059cbb000000000000000000000000742d35cc6634c0532925a3b8d359a5c5119e32c8" +
+		"00000000000000000000000000000000000000000000000000000000002625a0"
+	const other = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+	t.Run("without policy the contracts are not restricted", func(t *testing.T) {
+		_, err := sign(other, transferData)
+		require.NoError(t, err)
+	})
+
+	_, err = request(logical.UpdateOperation, "config/contracts", map[string]interface{}{"restrictedCoinTypes": "0"})
+	require.ErrorContains(t, err, helpers.ErrInvalidContractPolicy.Error())
+	_, err = request(logical.UpdateOperation, "config/contracts", map[string]interface{}{"restrictedCoinTypes": "60"})
+	require.NoError(t, err)
+
+	t.Run("only registered contracts are called", func(t *testing.T) {
+		_, err := sign(usdc, transferData)
+		require.NoError(t, err)
+		_, err = sign(other, transferData)
+		require.ErrorContains(t, err, helpers.ErrContractNotRegistered.Error())
+		// payments call no contract
+		_, err = sign(other, "0x")
+		require.NoError(t, err)
+	})
+
+	_, err = request(logical.DeleteOperation, "config/assets/60/USDC", nil)
+	require.NoError(t, err)
+	_, err = sign(usdc, transferData)
+	require.ErrorContains(t, err, helpers.ErrContractNotRegistered.Error())
+	_, err = request(logical.DeleteOperation, "config/contracts", nil)
+	require.NoError(t, err)
+	resp, err = request(logical.ReadOperation, "config/contracts", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/attestation"
+)
+
+// pathReadAttestation corresponds to READ config/attestation. Only the public key is returned.
+func (b *Backend) pathReadAttestation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_attestation"))
+
+	attestationConfig, err := helpers.GetAttestationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get attestation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: attestationResponseData(attestationConfig),
+	}, nil
+}
+
+// pathWriteAttestation corresponds to UPDATE config/attestation. The key is generated when
+// attestation is first enabled and kept across updates; deleting the configuration discards it.
+func (b *Backend) pathWriteAttestation(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_attestation"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	attestationConfig, err := helpers.GetAttestationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get attestation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("enabled"); ok {
+		attestationConfig.Enabled = v.(bool)
+	}
+	if attestationConfig.Enabled && len(attestationConfig.PrivateKey) == 0 {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			backendLogger.Error("generate attestation key", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		attestationConfig.PrivateKey = key
+	}
+
+	entry, err := logical.StorageEntryJSON(config.AttestationStorageKey, attestationConfig)
+	if err != nil {
+		backendLogger.Error("encode attestation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put attestation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.resetAttestationKey()
+	data := attestationResponseData(attestationConfig)
+	backendLogger.Info("attestation updated", "enabled", attestationConfig.Enabled, "keyId", data["keyId"])
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathDeleteAttestation corresponds to DELETE config/attestation. Attestation is disabled and the
+// key discarded, so enabling it again generates a new key.
+func (b *Backend) pathDeleteAttestation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_attestation", config.AttestationStorageKey)
+	if err == nil {
+		b.resetAttestationKey()
+	}
+	return resp, err
+}
+
+func attestationResponseData(attestationConfig *helpers.AttestationConfig) map[string]interface{} {
+	data := map[string]interface{}{
+		"enabled":   attestationConfig.Enabled,
+		"publicKey": "",
+		"keyId":     "",
+	}
+	if len(attestationConfig.PrivateKey) == ed25519.PrivateKeySize {
+		publicKey := ed25519.PrivateKey(attestationConfig.PrivateKey).Public().(ed25519.PublicKey)
+		data["publicKey"] = hex.EncodeToString(publicKey)
+		data["keyId"] = attestation.KeyID(publicKey)
+	}
+	return data
+}
+
+// attest adds the attestation of the mount to the data of resp, when config/attestation enables it
+func (b *Backend) attest(ctx context.Context, req *logical.Request, resp *logical.Response) error {
+	if resp == nil || resp.Data == nil || resp.IsError() || req.Storage == nil {
+		return nil
+	}
+	key, err := b.attestationKey(ctx, req.Storage)
+	if err != nil || key == nil {
+		return err
+	}
+
+	a, err := attestation.Sign(key, time.Now(), req.Path, resp.Data)
+	if err != nil {
+		return err
+	}
+	resp.Data[attestation.Field] = map[string]interface{}{
+		"keyId":     a.KeyID,
+		"timestamp": a.Timestamp,
+		"signature": a.Signature,
+	}
+	return nil
+}
+
+// attestationKey returns the key the responses are attested with, or nil when attestation is
+// disabled. The configuration is read on first use and kept until it changes.
+func (b *Backend) attestationKey(ctx context.Context, s logical.Storage) (ed25519.PrivateKey, error) {
+	b.attestationMu.Lock()
+	defer b.attestationMu.Unlock()
+
+	if b.attestationLoaded {
+		return b.attestationSigner, nil
+	}
+
+	attestationConfig, err := helpers.GetAttestationConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	b.attestationSigner = nil
+	if attestationConfig.Enabled {
+		if len(attestationConfig.PrivateKey) != ed25519.PrivateKeySize {
+			return nil, attestation.ErrInvalidKey
+		}
+		b.attestationSigner = attestationConfig.PrivateKey
+	}
+	b.attestationLoaded = true
+	return b.attestationSigner, nil
+}
+
+// resetAttestationKey drops the key so the next response reloads it from the configuration
+func (b *Backend) resetAttestationKey() {
+	b.attestationMu.Lock()
+	defer b.attestationMu.Unlock()
+
+	b.attestationSigner, b.attestationLoaded = nil, false
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/attestation"
+)
+
+func TestBackend_HandleRequest_Attestation(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+	assert.NotContains(t, resp.Data, attestation.Field, "disabled by default")
+
+	enabled := request(logical.UpdateOperation, "config/attestation", map[string]interface{}{"enabled": true})
+	publicKey, err := hex.DecodeString(enabled.Data["publicKey"].(string))
+	require.NoError(t, err)
+	require.Len(t, publicKey, ed25519.PublicKeySize)
+
+	// verify as a client would, from the JSON of the response
+	verify := func(t *testing.T, path string, resp *logical.Response) error {
+		t.Helper()
+		raw, err := json.Marshal(resp.Data)
+		require.NoError(t, err)
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var data map[string]interface{}
+		require.NoError(t, decoder.Decode(&data))
+
+		var a attestation.Attestation
+		encoded, err := json.Marshal(data[attestation.Field])
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(encoded, &a))
+		assert.Equal(t, enabled.Data["keyId"], a.KeyID)
+		return attestation.Verify(publicKey, path, data, &a)
+	}
+
+	resp = request(logical.ReadOperation, "user/"+signTestUUID, nil)
+	require.NoError(t, verify(t, "user/"+signTestUUID, resp))
+
+	t.Run("altered responses are rejected", func(t *testing.T) {
+		resp := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		resp.Data["status"] = helpers.UserStatusDisabled
+		require.ErrorIs(t, verify(t, "user/"+signTestUUID, resp), attestation.ErrInvalidSignature)
+	})
+
+	t.Run("responses are bound to their path", func(t *testing.T) {
+		resp := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		require.ErrorIs(t, verify(t, "user/other", resp), attestation.ErrInvalidSignature)
+	})
+
+	t.Run("the key is kept across updates", func(t *testing.T) {
+		resp := request(logical.UpdateOperation, "config/attestation", map[string]interface{}{"enabled": false})
+		assert.Equal(t, enabled.Data["publicKey"], resp.Data["publicKey"])
+		assert.NotContains(t, resp.Data, attestation.Field)
+
+		resp = request(logical.UpdateOperation, "config/attestation", map[string]interface{}{"enabled": true})
+		assert.Equal(t, enabled.Data["publicKey"], resp.Data["publicKey"])
+	})
+
+	t.Run("delete discards the key", func(t *testing.T) {
+		request(logical.DeleteOperation, "config/attestation", nil)
+		resp := request(logical.ReadOperation, "config/attestation", nil)
+		assert.Equal(t, false, resp.Data["enabled"])
+		assert.Empty(t, resp.Data["publicKey"])
+		assert.NotContains(t, resp.Data, attestation.Field)
+	})
+}
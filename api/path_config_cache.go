@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadCache corresponds to READ config/cache. It reports the counters of the cache of this node.
+func (b *Backend) pathReadCache(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_cache"))
+
+	cacheConfig, err := helpers.GetCacheConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get cache config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	cache, err := b.userCache(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("user cache", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := cacheResponseData(cacheConfig)
+	var stats storage.CacheStats
+	if cache != nil {
+		stats = cache.Stats()
+	}
+	data["entries"] = stats.Entries
+	data["hits"] = stats.Hits
+	data["misses"] = stats.Misses
+	data["evictions"] = stats.Evictions
+	data["hitRate"] = stats.HitRate()
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathWriteCache corresponds to UPDATE config/cache. Settings that are not provided keep their
+// stored value; every update empties the cache.
+func (b *Backend) pathWriteCache(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_cache"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	cacheConfig, err := helpers.GetCacheConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get cache config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("enabled"); ok {
+		cacheConfig.Enabled = v.(bool)
+	}
+	if v, ok := d.GetOk("maxEntries"); ok {
+		cacheConfig.MaxEntries = v.(int)
+	}
+	if v, ok := d.GetOk("ttl"); ok {
+		cacheConfig.TTL = time.Duration(v.(int)) * time.Second
+	}
+	if cacheConfig.MaxEntries <= 0 || cacheConfig.TTL <= 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidCacheConfig.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.UserCacheStorageKey, cacheConfig)
+	if err != nil {
+		backendLogger.Error("encode cache config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put cache config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.resetUserCache()
+	backendLogger.Info("cache updated", "enabled", cacheConfig.Enabled, "maxEntries", cacheConfig.MaxEntries,
+		"ttl", cacheConfig.TTL)
+
+	return &logical.Response{
+		Data: cacheResponseData(cacheConfig),
+	}, nil
+}
+
+func cacheResponseData(cacheConfig *helpers.CacheConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":    cacheConfig.Enabled,
+		"maxEntries": cacheConfig.MaxEntries,
+		"ttl":        int64(cacheConfig.TTL.Seconds()),
+	}
+}
+
+// userCache returns the cache of the user records, or nil when config/cache disables it.
+// The cache is created on first use and kept until the configuration changes.
+func (b *Backend) userCache(ctx context.Context, s logical.Storage) (*storage.Cache, error) {
+	b.userCacheMu.Lock()
+	defer b.userCacheMu.Unlock()
+
+	if b.userCacheLoaded {
+		return b.userRecords, nil
+	}
+
+	cacheConfig, err := helpers.GetCacheConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	b.userRecords = nil
+	if cacheConfig.Enabled {
+		b.userRecords = storage.NewCache(cacheConfig.MaxEntries, cacheConfig.TTL)
+	}
+	b.userCacheLoaded = true
+	return b.userRecords, nil
+}
+
+// resetUserCache drops the cache so the next request recreates it from the configuration
+func (b *Backend) resetUserCache() {
+	b.userCacheMu.Lock()
+	defer b.userCacheMu.Unlock()
+
+	// the readers get copies of the entries, the cached ones can be zeroed
+	if b.userRecords != nil {
+		b.userRecords.Purge()
+	}
+	b.userRecords, b.userCacheLoaded = nil, false
+}
+
+// invalidateCachedUser drops the cached record of key, changed by another node of the cluster
+func (b *Backend) invalidateCachedUser(key string) {
+	b.userCacheMu.Lock()
+	cache := b.userRecords
+	b.userCacheMu.Unlock()
+
+	if cache != nil {
+		cache.Invalidate(key)
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Helper function to create a proper framework.FieldData for config/cache endpoint
+func createCacheFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"enabled":    {Type: framework.TypeBool},
+			"maxEntries": {Type: framework.TypeInt},
+			"ttl":        {Type: framework.TypeDurationSecond},
+		},
+	}
+}
+
+func TestBackend_PathCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled by default", func(t *testing.T) {
+		got, err := createSignTestBackend(t).pathReadCache(ctx, &logical.Request{Storage: &logical.InmemStorage{}},
+			createCacheFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, false, got.Data["enabled"])
+		assert.Equal(t, helpers.DefaultCacheMaxEntries, got.Data["maxEntries"])
+		assert.Equal(t, int64(helpers.DefaultCacheTTL.Seconds()), got.Data["ttl"])
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{{"maxEntries": 0}, {"ttl": "0s"}} {
+			_, err := createSignTestBackend(t).pathWriteCache(ctx,
+				&logical.Request{Storage: &logical.InmemStorage{}, Data: data}, createCacheFieldData(data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), helpers.ErrInvalidCacheConfig.Error())
+		}
+	})
+}
+
+func TestBackend_HandleRequest_UserCache(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	user.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+		require.NoError(t, err)
+		return resp
+	}
+	request(logical.UpdateOperation, "config/cache", map[string]interface{}{"enabled": true, "ttl": "1m"})
+
+	for range 3 {
+		resp := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		assert.Equal(t, helpers.UserStatusActive, resp.Data["status"])
+	}
+	stats := request(logical.ReadOperation, "config/cache", nil)
+	assert.Equal(t, 1, stats.Data["entries"])
+	assert.Equal(t, uint64(2), stats.Data["hits"])
+	assert.Equal(t, uint64(1), stats.Data["misses"])
+	assert.InDelta(t, 2.0/3, stats.Data["hitRate"], 1e-9)
+
+	t.Run("disabled users are not served from the cache", func(t *testing.T) {
+		routed, err := b.routeUserStorage(ctx, s)
+		require.NoError(t, err)
+		require.NoError(t, b.expireUsers(ctx, routed, time.Now()))
+
+		resp := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		assert.Equal(t, helpers.UserStatusDisabled, resp.Data["status"])
+	})
+
+	t.Run("records changed by another node are dropped", func(t *testing.T) {
+		request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		require.NoError(t, s.Delete(ctx, config.StorageBasePath+signTestUUID))
+		b.invalidate(ctx, config.StorageBasePath+signTestUUID)
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation, Path: "user/" + signTestUUID, Storage: s,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("disabling empties the cache", func(t *testing.T) {
+		request(logical.UpdateOperation, "config/cache", map[string]interface{}{"enabled": false})
+		stats := request(logical.ReadOperation, "config/cache", nil)
+		assert.Equal(t, false, stats.Data["enabled"])
+		assert.Equal(t, 0, stats.Data["entries"])
+		assert.Nil(t, b.userRecords)
+	})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// legacyFields maps the patterns of the paths whose fields were renamed to the legacy names of
+// their fields and the names replacing them. The legacy names stay in the schemas, marked
+// deprecated, until they are removed after the sunset.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var legacyFields = map[string]map[string]string{
+	"sign":                 {"path": "derivationPath"},
+	"session/sign":         {"path": "derivationPath"},
+	"sign/spl-transfer":    {"path": "derivationPath"},
+	"sign/safe-tx":         {"path": "derivationPath"},
+	"sign/userop":          {"path": "derivationPath"},
+	"sign/permit":          {"path": "derivationPath"},
+	"sign/digest":          {"path": "derivationPath"},
+	"sign/digest/override": {"path": "derivationPath"},
+	"address":              {"path": "derivationPath"},
+	"xpub":                 {"path": "derivationPath"},
+	"compat/verify":        {"path": "derivationPath"},
+	"config/canary":        {"path": "derivationPath"},
+}
+
+// pathReadDeprecation corresponds to READ config/deprecation
+func (b *Backend) pathReadDeprecation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_deprecation"))
+
+	deprecation, err := helpers.GetDeprecationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get deprecation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: deprecationResponseData(deprecation),
+	}, nil
+}
+
+// pathWriteDeprecation corresponds to UPDATE config/deprecation. Settings that are not provided keep
+// their stored value; an empty sunset removes it.
+func (b *Backend) pathWriteDeprecation(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_deprecation"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	deprecation, err := helpers.GetDeprecationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get deprecation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("sunset"); ok {
+		deprecation.Sunset = time.Time{}
+		if v.(string) != "" {
+			sunset, err := time.Parse(helpers.SunsetDateFormat, v.(string))
+			if err != nil {
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidSunset.Error())
+			}
+			deprecation.Sunset = sunset
+		}
+	}
+	if v, ok := d.GetOk("rejectLegacyFields"); ok {
+		deprecation.RejectLegacyFields = v.(bool)
+	}
+
+	entry, err := logical.StorageEntryJSON(config.DeprecationStorageKey, deprecation)
+	if err != nil {
+		backendLogger.Error("encode deprecation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put deprecation config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("deprecation updated", "sunset", formatSunset(deprecation.Sunset),
+		"rejectLegacyFields", deprecation.RejectLegacyFields)
+
+	return &logical.Response{
+		Data: deprecationResponseData(deprecation),
+	}, nil
+}
+
+// pathDeleteDeprecation corresponds to DELETE config/deprecation. The legacy names are accepted
+// again, without a sunset.
+func (b *Backend) pathDeleteDeprecation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_deprecation", config.DeprecationStorageKey)
+}
+
+func deprecationResponseData(deprecation *helpers.DeprecationConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"sunset":             formatSunset(deprecation.Sunset),
+		"rejectLegacyFields": deprecation.RejectLegacyFields,
+		"legacyFields":       legacyFields,
+	}
+}
+
+// formatSunset formats the sunset date, "" when it is not set
+func formatSunset(sunset time.Time) string {
+	if sunset.IsZero() {
+		return ""
+	}
+	return sunset.Format(helpers.SunsetDateFormat)
+}
+
+// migrateLegacyFields returns data with the legacy fields of the path of pattern renamed to the
+// names replacing them, so the handlers only read the current names, copied when any is renamed. It
+// returns the deprecation warnings of the legacy fields used, and an error when config/deprecation
+// rejects them.
+func (b *Backend) migrateLegacyFields(ctx context.Context, s logical.Storage, pattern string,
+	data map[string]interface{}) (map[string]interface{}, []string, error) {
+	renamed := legacyFields[pattern]
+	var used []string
+	for _, legacy := range slices.Sorted(maps.Keys(renamed)) {
+		if _, ok := data[legacy]; ok {
+			used = append(used, legacy)
+		}
+	}
+	if len(used) == 0 {
+		return data, nil, nil
+	}
+
+	deprecation, err := helpers.GetDeprecationConfig(ctx, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings := make([]string, 0, len(used))
+	for _, legacy := range used {
+		current := renamed[legacy]
+		if deprecation.RejectLegacyFields {
+			return nil, nil, fmt.Errorf("%w: %s, use %s", helpers.ErrLegacyField, legacy, current)
+		}
+		if _, ok := data[current]; ok {
+			return nil, nil, fmt.Errorf("%w: %s and %s", helpers.ErrLegacyFieldConflict, legacy, current)
+		}
+
+		message := "field %s is renamed %s, the legacy name will be rejected"
+		if !deprecation.Sunset.IsZero() {
+			message += " after " + formatSunset(deprecation.Sunset)
+		}
+		warnings = append(warnings, helpers.Warning(helpers.WarningDeprecatedField, message, legacy, current))
+		b.logger.Warn("legacy field used", "path", pattern, "field", legacy, "replacedBy", current,
+			"sunset", formatSunset(deprecation.Sunset))
+	}
+	data = maps.Clone(data)
+	renameLegacyFields(pattern, data)
+	return data, warnings, nil
+}
+
+// renameLegacyFields renames in data the legacy fields of the path of pattern to the names replacing
+// them, the current names winning when both are given
+func renameLegacyFields(pattern string, data map[string]interface{}) {
+	for legacy, current := range legacyFields[pattern] {
+		value, ok := data[legacy]
+		if !ok {
+			continue
+		}
+		if _, ok := data[current]; !ok {
+			data[current] = value
+		}
+		delete(data, legacy)
+	}
+}
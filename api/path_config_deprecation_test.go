@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_Deprecation(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	legacy := map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath}
+
+	resp, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "derivationPath": signTestDerivationPath,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Warnings)
+	address := resp.Data["address"]
+
+	t.Run("legacy names are served with a warning", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "address", legacy)
+		require.NoError(t, err)
+		assert.Equal(t, address, resp.Data["address"])
+		require.Len(t, resp.Warnings, 1)
+		assert.Equal(t, helpers.WarningDeprecatedField, helpers.WarningCode(resp.Warnings[0]))
+		assert.Contains(t, legacy, "path", "the request data is not modified")
+	})
+
+	t.Run("every path taking a derivation path renames it", func(t *testing.T) {
+		want, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": "m/44'/60'/0'",
+		})
+		require.NoError(t, err)
+		assert.Empty(t, want.Warnings)
+		got, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": "m/44'/60'/0'",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want.Data["xpub"], got.Data["xpub"])
+		require.Len(t, got.Warnings, 1)
+		assert.Equal(t, helpers.WarningDeprecatedField, helpers.WarningCode(got.Warnings[0]))
+
+		for pattern, fields := range legacyFields {
+			route := b.Route(pattern)
+			require.NotNil(t, route, pattern)
+			for legacy, current := range fields {
+				assert.True(t, route.Fields[legacy].Deprecated, pattern)
+				assert.Contains(t, route.Fields, current, pattern)
+			}
+		}
+		for pattern, fields := range derivationPathFields {
+			assert.NotContains(t, fields, "path", "%s reads the legacy name", pattern)
+		}
+	})
+
+	t.Run("the warning announces the sunset", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/deprecation", map[string]interface{}{"sunset": "June 2027"})
+		require.ErrorContains(t, err, helpers.ErrInvalidSunset.Error())
+		_, err = request(logical.UpdateOperation, "config/deprecation", map[string]interface{}{"sunset": "2027-06-30"})
+		require.NoError(t, err)
+
+		resp, err := request(logical.UpdateOperation, "address", legacy)
+		require.NoError(t, err)
+		require.Len(t, resp.Warnings, 1)
+		assert.Contains(t, resp.Warnings[0], "after 2027-06-30")
+	})
+
+	t.Run("both names are ambiguous", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "derivationPath": signTestDerivationPath,
+		})
+		require.ErrorContains(t, err, helpers.ErrLegacyFieldConflict.Error())
+	})
+
+	t.Run("rejected legacy names", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "config/deprecation", map[string]interface{}{"rejectLegacyFields": true})
+		require.NoError(t, err)
+		assert.Equal(t, "2027-06-30", resp.Data["sunset"])
+
+		_, err = request(logical.UpdateOperation, "address", legacy)
+		require.ErrorContains(t, err, helpers.ErrLegacyField.Error())
+		resp, err = request(logical.UpdateOperation, "sign/batch", map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+			}},
+		})
+		require.NoError(t, err)
+		result := resp.Data["results"].([]map[string]interface{})[0]
+		assert.Equal(t, batchItemFailed, result["status"])
+		assert.Contains(t, result["error"], helpers.ErrLegacyField.Error())
+	})
+
+	_, err = request(logical.DeleteOperation, "config/deprecation", nil)
+	require.NoError(t, err)
+	_, err = request(logical.UpdateOperation, "address", legacy)
+	require.NoError(t, err)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// derivationPathFields maps the patterns of the paths deriving keys to their fields holding a
+// derivation path, checked against config/derivation; a list[].key field holds one in the key of
+// each object of the list. The paths of the presets and of address/next are built hardened down to
+// the account level.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var derivationPathFields = map[string][]string{
+	"sign":                 {"derivationPath"},
+	"build/evm-tx":         {"derivationPath"},
+	"build/btc-tx":         {"utxos[].path"},
+	"session/create":       {"pathPrefix"},
+	"session/sign":         {"derivationPath"},
+	"sign/spl-transfer":    {"derivationPath"},
+	"sign/safe-tx":         {"derivationPath"},
+	"sign/userop":          {"derivationPath"},
+	"sign/permit":          {"derivationPath"},
+	"sign/digest":          {"derivationPath"},
+	"sign/digest/override": {"derivationPath"},
+	"address":              {"derivationPath"},
+	"address/prove":        {"derivationPath"},
+	"xpub":                 {"derivationPath"},
+	"address/batch":        {"pathTemplate"},
+	"address/vanity":       {"pathTemplate"},
+	"compat/verify":        {"derivationPath"},
+	"config/canary":        {"derivationPath"},
+}
+
+// pathReadDerivation corresponds to READ config/derivation
+func (b *Backend) pathReadDerivation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_derivation"))
+
+	policy, err := helpers.GetDerivationPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get derivation policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: derivationResponseData(policy),
+	}, nil
+}
+
+// pathWriteDerivation corresponds to UPDATE config/derivation. Settings that are not provided keep
+// their stored value.
+func (b *Backend) pathWriteDerivation(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_derivation"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	policy, err := helpers.GetDerivationPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get derivation policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("hardenedAccount"); ok {
+		policy.HardenedAccount = v.(bool)
+	}
+	if v, ok := d.GetOk("maxDepth"); ok {
+		policy.MaxDepth = v.(int)
+	}
+	if policy.MaxDepth != 0 && policy.MaxDepth < helpers.AccountDepth {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidMaxDepth.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.DerivationPolicyStorageKey, policy)
+	if err != nil {
+		backendLogger.Error("encode derivation policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put derivation policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("derivation policy updated", "hardenedAccount", policy.HardenedAccount,
+		"maxDepth", policy.MaxDepth)
+
+	return &logical.Response{
+		Data: derivationResponseData(policy),
+	}, nil
+}
+
+// pathDeleteDerivation corresponds to DELETE config/derivation. Every path is allowed again.
+func (b *Backend) pathDeleteDerivation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_derivation", config.DerivationPolicyStorageKey)
+}
+
+func derivationResponseData(policy *helpers.DerivationPolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"hardenedAccount": policy.HardenedAccount,
+		"maxDepth":        policy.MaxDepth,
+	}
+}
+
+// checkDerivationPolicy checks the derivation paths given in data for the path of pattern against
+// config/derivation
+func (b *Backend) checkDerivationPolicy(ctx context.Context, s logical.Storage, pattern string,
+	data map[string]interface{}) error {
+	fields := derivationPathFields[pattern]
+	if len(fields) == 0 {
+		return nil
+	}
+	policy, err := helpers.GetDerivationPolicy(ctx, s)
+	if err != nil || !policy.Enforced() {
+		return err
+	}
+	for _, field := range fields {
+		for _, derivationPath := range derivationPaths(data, field) {
+			if err := policy.Check(derivationPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// derivationPaths returns the derivation paths given in the field of data, of each object of the
+// list for a list[].key field
+func derivationPaths(data map[string]interface{}, field string) []string {
+	list, key, ok := strings.Cut(field, "[].")
+	if !ok {
+		if derivationPath, ok := data[field].(string); ok && derivationPath != "" {
+			return []string{derivationPath}
+		}
+		return nil
+	}
+	items, _ := data[list].([]interface{})
+	var paths []string
+	for _, item := range items {
+		if object, ok := item.(map[string]interface{}); ok {
+			if derivationPath, ok := object[key].(string); ok && derivationPath != "" {
+				paths = append(paths, derivationPath)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_Derivation(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	address := func(derivationPath string) error {
+		t.Helper()
+		_, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": derivationPath,
+		})
+		return err
+	}
+
+	// every path is allowed by default
+	require.NoError(t, address("m/44'/60'/0/0/0/1/2"))
+
+	_, err := request(logical.UpdateOperation, "config/derivation", map[string]interface{}{"maxDepth": 2})
+	require.ErrorContains(t, err, helpers.ErrInvalidMaxDepth.Error())
+	resp, err := request(logical.UpdateOperation, "config/derivation", map[string]interface{}{
+		"hardenedAccount": true, "maxDepth": 5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"hardenedAccount": true, "maxDepth": 5}, resp.Data)
+
+	require.NoError(t, address(signTestDerivationPath))
+	require.ErrorContains(t, address("m/44'/60'/0/0/0"), helpers.ErrNonHardenedAboveAccount.Error())
+	require.ErrorContains(t, address("m/44'/60'/0'/0/0/1"), helpers.ErrDerivationTooDeep.Error())
+
+	_, err = request(logical.UpdateOperation, "address/batch", map[string]interface{}{
+		"uuid": signTestUUID, "pathTemplate": "m/44'/60'/%d/0/0", "coinType": 60, "count": 2,
+	})
+	require.ErrorContains(t, err, helpers.ErrNonHardenedAboveAccount.Error())
+	_, err = request(logical.UpdateOperation, "xpub", map[string]interface{}{
+		"uuid": signTestUUID, "derivationPath": "m/44'/60", "coinType": 60,
+	})
+	require.ErrorContains(t, err, helpers.ErrNonHardenedAboveAccount.Error())
+
+	resp, err = request(logical.UpdateOperation, "sign/batch", map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": "m/44'/60'/0/0/0", "payload": signTestPayload,
+		}},
+	})
+	require.NoError(t, err)
+	result := resp.Data["results"].([]map[string]interface{})[0]
+	assert.Equal(t, batchItemFailed, result["status"])
+	assert.Contains(t, result["error"], helpers.ErrNonHardenedAboveAccount.Error())
+
+	_, err = request(logical.DeleteOperation, "config/derivation", nil)
+	require.NoError(t, err)
+	require.NoError(t, address("m/44'/60'/0/0/0"))
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/entropy"
+)
+
+// entropyHealth -- the result of the health check of an entropy source
+type entropyHealth struct {
+	source    string
+	err       error
+	checkedAt time.Time
+}
+
+// pathReadEntropy corresponds to READ config/entropy
+func (b *Backend) pathReadEntropy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_entropy"))
+
+	entropyConfig, err := helpers.GetEntropyConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get entropy config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: b.entropyResponseData(entropyConfig, b.entropyHealthOf(entropyConfig.Source)),
+	}, nil
+}
+
+// pathWriteEntropy corresponds to UPDATE config/entropy. The source is checked before it is stored;
+// writing it again checks it again, which lifts the refusal of a source that failed its check.
+func (b *Backend) pathWriteEntropy(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_entropy"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entropyConfig := &helpers.EntropyConfig{Source: d.Get("source").(string)}
+	health := b.runEntropyCheck(entropyConfig.Source)
+	if health.err != nil {
+		backendLogger.Error("check entropy source", "source", entropyConfig.Source, "error", health.err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, health.err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.EntropyStorageKey, entropyConfig)
+	if err != nil {
+		backendLogger.Error("encode entropy config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put entropy config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	b.setEntropyHealth(health)
+	backendLogger.Info("entropy source updated", "source", entropyConfig.Source)
+
+	return &logical.Response{
+		Data: b.entropyResponseData(entropyConfig, health),
+	}, nil
+}
+
+// pathDeleteEntropy corresponds to DELETE config/entropy. The mnemonics are generated from
+// crypto/rand again.
+func (b *Backend) pathDeleteEntropy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_entropy", config.EntropyStorageKey)
+}
+
+func (b *Backend) entropyResponseData(entropyConfig *helpers.EntropyConfig, health *entropyHealth) map[string]interface{} {
+	_, augmentation := b.entropySourcer()
+	data := map[string]interface{}{
+		"source":                entropyConfig.Source,
+		"augmentationAvailable": augmentation,
+		"healthy":               health.err == nil,
+		"checkedAt":             health.checkedAt.UTC().Format(time.RFC3339),
+	}
+	if health.err != nil {
+		data["error"] = health.err.Error()
+	}
+	return data
+}
+
+// entropySourcer returns the external entropy of the mount, provided by the system view of the
+// Vault Enterprise mounts with external entropy access
+func (b *Backend) entropySourcer() (entropy.Sourcer, bool) {
+	if b.Backend == nil || b.System() == nil {
+		return nil, false
+	}
+	sourcer, ok := b.System().(entropy.Sourcer)
+	return sourcer, ok
+}
+
+// entropyReader returns the reader of the entropy of source
+func (b *Backend) entropyReader(source string) (io.Reader, error) {
+	switch source {
+	case entropy.SourceCryptoRand:
+		return b.randReader, nil
+	case entropy.SourceAugmented:
+		sourcer, ok := b.entropySourcer()
+		if !ok {
+			return nil, helpers.ErrEntropyAugmentationUnavailable
+		}
+		return entropy.Augment(b.randReader, sourcer), nil
+	default:
+		return nil, entropy.ErrUnknownSource
+	}
+}
+
+// runEntropyCheck runs the health check of source
+func (b *Backend) runEntropyCheck(source string) *entropyHealth {
+	health := &entropyHealth{source: source, checkedAt: time.Now()}
+	r, err := b.entropyReader(source)
+	if err == nil {
+		err = entropy.Check(r)
+	}
+	health.err = err
+	return health
+}
+
+// checkEntropy runs the health check of source and keeps its result for the mnemonics generated
+func (b *Backend) checkEntropy(source string) *entropyHealth {
+	health := b.runEntropyCheck(source)
+	if health.err != nil {
+		b.logger.Error("entropy health check failed, mnemonics are not generated", "source", source,
+			"error", health.err)
+	}
+	b.setEntropyHealth(health)
+	return health
+}
+
+func (b *Backend) setEntropyHealth(health *entropyHealth) {
+	b.entropyMu.Lock()
+	b.entropyHealth = health
+	b.entropyMu.Unlock()
+}
+
+// entropyHealthOf returns the result of the health check of source, checking it when it was not yet
+func (b *Backend) entropyHealthOf(source string) *entropyHealth {
+	b.entropyMu.Lock()
+	health := b.entropyHealth
+	b.entropyMu.Unlock()
+	if health != nil && health.source == source {
+		return health
+	}
+	return b.checkEntropy(source)
+}
+
+// generateMnemonic generates a mnemonic of entropyLength bits from the entropy source of the mount,
+// refused when the source failed its health check
+func (b *Backend) generateMnemonic(ctx context.Context, s logical.Storage, entropyLength int) (string, error) {
+	entropyConfig, err := helpers.GetEntropyConfig(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	if health := b.entropyHealthOf(entropyConfig.Source); health.err != nil {
+		return "", helpers.ErrEntropyUnhealthy
+	}
+	r, err := b.entropyReader(entropyConfig.Source)
+	if err != nil {
+		return "", err
+	}
+	return lib.MnemonicFromReader(r, entropyLength)
+}
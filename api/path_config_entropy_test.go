@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/entropy"
+)
+
+// entropySystemView is the system view of a mount with external entropy access
+type entropySystemView struct {
+	logical.StaticSystemView
+}
+
+func (entropySystemView) GetRandom(n int) ([]byte, error) {
+	return bytes.Repeat([]byte{0x5a}, n), nil
+}
+
+func TestBackend_HandleRequest_Entropy(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{System: &logical.StaticSystemView{}}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+
+	resp, err := request(logical.ReadOperation, "config/entropy", nil)
+	require.NoError(t, err)
+	assert.Equal(t, entropy.SourceCryptoRand, resp.Data["source"])
+	assert.Equal(t, true, resp.Data["healthy"])
+	assert.Equal(t, false, resp.Data["augmentationAvailable"])
+
+	_, err = request(logical.UpdateOperation, "config/entropy", map[string]interface{}{"source": "urandom"})
+	require.ErrorContains(t, err, entropy.ErrUnknownSource.Error())
+	_, err = request(logical.UpdateOperation, "config/entropy", map[string]interface{}{"source": entropy.SourceAugmented})
+	require.ErrorContains(t, err, helpers.ErrEntropyAugmentationUnavailable.Error())
+
+	t.Run("a broken source refuses to generate mnemonics", func(t *testing.T) {
+		b.randReader = bytes.NewReader(bytes.Repeat([]byte{0x01, 0x02}, 1024))
+		require.NoError(t, b.initialize(ctx, &logical.InitializationRequest{Storage: s}))
+		resp, err := request(logical.ReadOperation, "config/entropy", nil)
+		require.NoError(t, err)
+		assert.Equal(t, false, resp.Data["healthy"])
+		assert.Equal(t, entropy.ErrRepeatedOutput.Error(), resp.Data["error"])
+
+		_, err = request(logical.UpdateOperation, "register", map[string]interface{}{"uuid": "entropy-user"})
+		require.ErrorContains(t, err, helpers.ErrEntropyUnhealthy.Error())
+		// the mnemonics given are still registered
+		_, err = request(logical.UpdateOperation, "register", map[string]interface{}{
+			"uuid": "entropy-user", "mnemonic": signTestValidMnemonic,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("writing the source checks it again", func(t *testing.T) {
+		b.randReader = bytes.NewReader(make([]byte, 1024))
+		_, err := request(logical.UpdateOperation, "config/entropy", map[string]interface{}{"source": entropy.SourceCryptoRand})
+		require.ErrorContains(t, err, entropy.ErrConstantOutput.Error())
+
+		b.randReader = rand.Reader
+		resp, err := request(logical.UpdateOperation, "config/entropy", map[string]interface{}{"source": entropy.SourceCryptoRand})
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["healthy"])
+		_, err = request(logical.UpdateOperation, "register", map[string]interface{}{"uuid": "generated-user"})
+		require.NoError(t, err)
+	})
+
+	t.Run("augmented with the entropy of the system view", func(t *testing.T) {
+		b := NewBackend(&logical.BackendConfig{})
+		require.NoError(t, b.Setup(ctx, &logical.BackendConfig{System: &entropySystemView{}}))
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "config/entropy", Storage: s,
+			Data: map[string]interface{}{"source": entropy.SourceAugmented},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["augmentationAvailable"])
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "register", Storage: s,
+			Data: map[string]interface{}{"uuid": "augmented-user"},
+		})
+		require.NoError(t, err)
+	})
+}
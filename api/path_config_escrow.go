@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/escrow"
+)
+
+// pathReadEscrowConfig corresponds to READ config/escrow.
+func (b *Backend) pathReadEscrowConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_escrow_config"))
+
+	escrowConfig, err := helpers.GetEscrowConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get escrow config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: escrowConfigResponseData(escrowConfig),
+	}, nil
+}
+
+// pathWriteEscrowConfig corresponds to UPDATE config/escrow. Changing the key only applies to the
+// users registered afterwards; existing users are escrowed again with UPDATE escrow/<uuid>.
+func (b *Backend) pathWriteEscrowConfig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_escrow_config"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	escrowConfig, err := helpers.GetEscrowConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get escrow config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("enabled"); ok {
+		escrowConfig.Enabled = v.(bool)
+	}
+	if v, ok := d.GetOk("publicKey"); ok {
+		escrowConfig.PublicKey = v.(string)
+	}
+	if escrowConfig.PublicKey != "" {
+		if _, err := escrow.ParsePublicKey(escrowConfig.PublicKey); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	if escrowConfig.Enabled && escrowConfig.PublicKey == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrEscrowKeyRequired.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.EscrowStorageKey, escrowConfig)
+	if err != nil {
+		backendLogger.Error("encode escrow config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put escrow config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := escrowConfigResponseData(escrowConfig)
+	backendLogger.Info("escrow updated", "enabled", escrowConfig.Enabled, "keyId", data["keyId"])
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathDeleteEscrowConfig corresponds to DELETE config/escrow. The escrow records are kept.
+func (b *Backend) pathDeleteEscrowConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_escrow_config", config.EscrowStorageKey)
+}
+
+func escrowConfigResponseData(escrowConfig *helpers.EscrowConfig) map[string]interface{} {
+	data := map[string]interface{}{
+		"enabled":   escrowConfig.Enabled,
+		"publicKey": escrowConfig.PublicKey,
+		"keyId":     "",
+	}
+	if publicKey, err := escrow.ParsePublicKey(escrowConfig.PublicKey); err == nil {
+		data["keyId"] = escrow.KeyID(publicKey)
+	}
+	return data
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/escrow"
+)
+
+func TestBackend_HandleRequest_Escrow(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("invalid configurations are rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/escrow", map[string]interface{}{"enabled": true})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrEscrowKeyRequired.Error())
+		_, err = request(logical.UpdateOperation, "config/escrow", map[string]interface{}{"publicKey": "00"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), escrow.ErrInvalidKey.Error())
+
+		_, err = request(logical.UpdateOperation, "escrow/"+signTestUUID, map[string]interface{}{"version": 0})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrEscrowDisabled.Error())
+	})
+
+	resp, err := request(logical.UpdateOperation, "config/escrow", map[string]interface{}{
+		"enabled": true, "publicKey": hex.EncodeToString(publicKey[:]),
+	})
+	require.NoError(t, err)
+	keyID := escrow.KeyID(publicKey)
+	assert.Equal(t, keyID, resp.Data["keyId"])
+
+	t.Run("registration escrows the mnemonic", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "register", map[string]interface{}{
+			"uuid": "escrowed", "mnemonic": regTestValidMnemonic, "passphrase": regTestPassphrase,
+		})
+		require.NoError(t, err)
+
+		resp, err := request(logical.ReadOperation, "escrow/escrowed", nil)
+		require.NoError(t, err)
+		assert.Equal(t, keyID, resp.Data["keyId"])
+		assert.Equal(t, escrow.Algorithm, resp.Data["algorithm"])
+
+		secret, err := escrow.Open(publicKey, privateKey, resp.Data["ciphertext"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, escrow.Secret{
+			UUID: "escrowed", Mnemonic: regTestValidMnemonic, Passphrase: regTestPassphrase,
+		}, secret)
+	})
+
+	t.Run("existing users are escrowed on update", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "escrow/"+signTestUUID, nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+
+		resp, err = request(logical.UpdateOperation, "escrow/"+signTestUUID, map[string]interface{}{"version": 0})
+		require.NoError(t, err)
+		secret, err := escrow.Open(publicKey, privateKey, resp.Data["ciphertext"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, signTestValidMnemonic, secret.Mnemonic)
+
+		resp, err = request(logical.ListOperation, "escrow/", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"escrowed", signTestUUID}, resp.Data["keys"])
+	})
+
+	t.Run("purge removes the record", func(t *testing.T) {
+		require.NoError(t, purgeUser(ctx, s, "escrowed"))
+		resp, err := request(logical.ReadOperation, "escrow/escrowed", nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadFeatures corresponds to READ config/features.
+func (b *Backend) pathReadFeatures(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_features"))
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: featuresResponseData(features),
+	}, nil
+}
+
+// pathWriteFeatures corresponds to UPDATE config/features. Flags that are
+// not provided keep their stored value.
+func (b *Backend) pathWriteFeatures(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_features"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("signDigestEnabled"); ok {
+		features.SignDigestEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("apiKeysRequired"); ok {
+		features.APIKeysRequired = v.(bool)
+	}
+	if v, ok := d.GetOk("broadcastEnabled"); ok {
+		features.BroadcastEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("exportEnabled"); ok {
+		features.ExportEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("addressIndexEnabled"); ok {
+		features.AddressIndexEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("compatVerifyEnabled"); ok {
+		features.CompatVerifyEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("digestPreimageRequired"); ok {
+		features.DigestPreimageRequired = v.(bool)
+	}
+	if v, ok := d.GetOk("encryptedResponsesRequired"); ok {
+		features.EncryptedResponsesRequired = v.(bool)
+	}
+	if v, ok := d.GetOk("devVectorsEnabled"); ok {
+		features.DevVectorsEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("vanityAddressEnabled"); ok {
+		features.VanityAddressEnabled = v.(bool)
+	}
+	if v, ok := d.GetOk("mnemonicGenEnabled"); ok {
+		features.MnemonicGenEnabled = v.(bool)
+	}
+
+	entry, err := logical.StorageEntryJSON(config.FeaturesStorageKey, features)
+	if err != nil {
+		backendLogger.Error("encode features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("features updated", "features", features)
+
+	return &logical.Response{
+		Data: featuresResponseData(features),
+	}, nil
+}
+
+func featuresResponseData(features *helpers.Features) map[string]interface{} {
+	return map[string]interface{}{
+		"signDigestEnabled":          features.SignDigestEnabled,
+		"apiKeysRequired":            features.APIKeysRequired,
+		"broadcastEnabled":           features.BroadcastEnabled,
+		"exportEnabled":              features.ExportEnabled,
+		"addressIndexEnabled":        features.AddressIndexEnabled,
+		"compatVerifyEnabled":        features.CompatVerifyEnabled,
+		"digestPreimageRequired":     features.DigestPreimageRequired,
+		"encryptedResponsesRequired": features.EncryptedResponsesRequired,
+		"devVectorsEnabled":          features.DevVectorsEnabled,
+		"vanityAddressEnabled":       features.VanityAddressEnabled,
+		"mnemonicGenEnabled":         features.MnemonicGenEnabled,
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/secp"
+)
+
+// Helper function to create a proper framework.FieldData for config/features endpoint
+func createFeaturesFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"signDigestEnabled": {Type: framework.TypeBool},
+		},
+	}
+}
+
+func TestBackend_PathFeatures(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults when nothing is stored", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+
+		got, err := createSignTestBackend(t).pathReadFeatures(ctx, &logical.Request{Storage: mockStorage},
+			createFeaturesFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, false, got.Data["signDigestEnabled"])
+		assert.Equal(t, false, got.Data["broadcastEnabled"])
+		assert.Equal(t, true, got.Data["exportEnabled"])
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("flags missing from the stored entry keep their default", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).
+			Return(&logical.StorageEntry{Key: config.FeaturesStorageKey, Value: []byte(`{"signDigestEnabled":true}`)}, nil)
+
+		got, err := createSignTestBackend(t).pathReadFeatures(ctx, &logical.Request{Storage: mockStorage},
+			createFeaturesFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, true, got.Data["signDigestEnabled"])
+		assert.Equal(t, true, got.Data["exportEnabled"])
+	})
+
+	t.Run("info reports the flags", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).
+			Return(createFeaturesStorageEntry(helpers.Features{BroadcastEnabled: true}), nil)
+
+		got, err := createSignTestBackend(t).pathInfo(ctx, &logical.Request{Storage: mockStorage}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"signDigestEnabled":          false,
+			"apiKeysRequired":            false,
+			"broadcastEnabled":           true,
+			"exportEnabled":              false,
+			"addressIndexEnabled":        false,
+			"compatVerifyEnabled":        false,
+			"digestPreimageRequired":     false,
+			"encryptedResponsesRequired": false,
+			"devVectorsEnabled":          false,
+			"vanityAddressEnabled":       false,
+			"mnemonicGenEnabled":         false,
+		}, got.Data["features"])
+		assert.Equal(t, secp.Implementation, got.Data["secp256k1"])
+	})
+
+	t.Run("update enables sign digest", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+		mockStorage.On("Put", ctx, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+			var stored helpers.Features
+			return entry.Key == config.FeaturesStorageKey &&
+				json.Unmarshal(entry.Value, &stored) == nil && stored.SignDigestEnabled
+		})).Return(nil)
+
+		data := map[string]interface{}{"signDigestEnabled": true}
+		got, err := createSignTestBackend(t).pathWriteFeatures(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createFeaturesFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, true, got.Data["signDigestEnabled"])
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("omitted flags keep their value", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).
+			Return(createFeaturesStorageEntry(helpers.Features{SignDigestEnabled: true}), nil)
+		mockStorage.On("Put", ctx, mock.Anything).Return(nil)
+
+		got, err := createSignTestBackend(t).pathWriteFeatures(ctx, &logical.Request{Storage: mockStorage},
+			createFeaturesFieldData(map[string]interface{}{}))
+		require.NoError(t, err)
+		assert.Equal(t, true, got.Data["signDigestEnabled"])
+	})
+
+	t.Run("unknown fields rejected", func(t *testing.T) {
+		data := map[string]interface{}{"unknown": true}
+		_, err := createSignTestBackend(t).pathWriteFeatures(ctx, &logical.Request{Storage: new(MockStorageSign), Data: data},
+			createFeaturesFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUnknownFields.Error())
+	})
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/fee"
+)
+
+// pathListFees corresponds to LIST config/fees.
+func (b *Backend) pathListFees(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_fees"))
+
+	coinTypes, err := req.Storage.List(ctx, config.FeeBoundsStoragePath)
+	if err != nil {
+		backendLogger.Error("list fee bounds", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(coinTypes), nil
+}
+
+// pathReadFees corresponds to READ config/fees/<coinType>.
+func (b *Backend) pathReadFees(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_fees"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	bounds, err := helpers.GetFeeBounds(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("get fee bounds", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if bounds == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: feeBoundsResponseData(bounds),
+	}, nil
+}
+
+// pathWriteFees corresponds to UPDATE config/fees/<coinType>.
+func (b *Backend) pathWriteFees(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_fees"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if fee.Unit(coinType) == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, fee.ErrUnsupportedCoin.Error())
+	}
+	bounds := &helpers.FeeBounds{
+		CoinType: coinType,
+		Min:      d.Get("min").(float64),
+		Max:      d.Get("max").(float64),
+	}
+	if bounds.Min < 0 || bounds.Max <= 0 || bounds.Min > bounds.Max {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidFeeBounds.Error())
+	}
+
+	if err := helpers.PutFeeBounds(ctx, req.Storage, bounds); err != nil {
+		backendLogger.Error("put fee bounds", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("fee bounds updated", "coinType", coinType, "min", bounds.Min, "max", bounds.Max)
+
+	return &logical.Response{
+		Data: feeBoundsResponseData(bounds),
+	}, nil
+}
+
+// pathDeleteFees corresponds to DELETE config/fees/<coinType>.
+func (b *Backend) pathDeleteFees(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_fees"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.DeleteFeeBounds(ctx, req.Storage, coinType); err != nil {
+		backendLogger.Error("delete fee bounds", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("fee bounds deleted", "coinType", coinType)
+	return nil, nil
+}
+
+func feeBoundsResponseData(bounds *helpers.FeeBounds) map[string]interface{} {
+	return map[string]interface{}{
+		"coinType": bounds.CoinType,
+		"min":      bounds.Min,
+		"max":      bounds.Max,
+		"unit":     fee.Unit(bounds.CoinType),
+	}
+}
+
+// checkFeeBounds checks the fee rate of the payload of sign against the bounds of config/fees of
+// coinType, returning the fields reported in the response, none when no bounds are configured,
+// and the warnings of fees near the max. Fees out of bounds are signed only with overrideFee,
+// which is logged as a warning with the entity, so the audit log records who overrode which fee.
+func (b *Backend) checkFeeBounds(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	coinType uint16, payload string, logger *slog.Logger) (map[string]interface{}, []string, error) {
+	bounds, err := helpers.GetFeeBounds(ctx, req.Storage, coinType)
+	if err != nil || bounds == nil {
+		return nil, nil, err
+	}
+	rate, err := fee.Rate(coinType, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.checkFeeRate(req, d, bounds, rate, logger)
+}
+
+// checkFeeRate checks rate against the bounds of config/fees of its coin type, as checkFeeBounds
+func (b *Backend) checkFeeRate(req *logical.Request, d *framework.FieldData, bounds *helpers.FeeBounds,
+	rate float64, logger *slog.Logger) (map[string]interface{}, []string, error) {
+	fields := map[string]interface{}{"feeRate": rate}
+	unit := fee.Unit(bounds.CoinType)
+	if bounds.Contains(rate) {
+		if rate < bounds.Max*helpers.FeeNearLimitRatio {
+			return fields, nil, nil
+		}
+		return fields, []string{helpers.Warning(helpers.WarningFeeNearLimit, "fee rate %g %s is within %g%% of the max %g",
+			rate, unit, 100*(1-helpers.FeeNearLimitRatio), bounds.Max)}, nil
+	}
+	if override, ok := d.GetOk("overrideFee"); !ok || !override.(bool) {
+		return nil, nil, fmt.Errorf("%w: %g %s not in [%g, %g]", helpers.ErrFeeOutOfBounds, rate, unit, bounds.Min, bounds.Max)
+	}
+	logger.Warn("fee bounds overridden", "feeRate", rate, "unit", unit, "min", bounds.Min, "max", bounds.Max,
+		"entity", req.EntityID)
+	fields["feeOverride"] = true
+	return fields, []string{helpers.Warning(helpers.WarningFeeOverridden, "fee rate %g %s not in [%g, %g], signed with overrideFee",
+		rate, unit, bounds.Min, bounds.Max)}, nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/fee"
+)
+
+func TestBackend_HandleRequest_FeeBounds(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+
+	t.Run("invalid bounds are rejected", func(t *testing.T) {
+		for _, bounds := range []map[string]interface{}{
+			{"min": -1, "max": 10},
+			{"min": 0, "max": 0},
+			{"min": 20, "max": 10},
+		} {
+			_, err := request(logical.UpdateOperation, "config/fees/60", bounds)
+			require.ErrorContains(t, err, helpers.ErrInvalidFeeBounds.Error(), bounds)
+		}
+		_, err := request(logical.UpdateOperation, "config/fees/501", map[string]interface{}{"min": 1, "max": 10})
+		require.ErrorContains(t, err, fee.ErrUnsupportedCoin.Error())
+	})
+
+	sign := map[string]interface{}{
+		"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "coinType": 60, "payload": signTestPayload,
+	}
+
+	t.Run("without bounds the fee is not checked", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "sign", sign)
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "feeRate")
+	})
+
+	// the payload pays 20 gwei
+	resp, err := request(logical.UpdateOperation, "config/fees/60", map[string]interface{}{"min": 1, "max": 10})
+	require.NoError(t, err)
+	assert.Equal(t, fee.UnitGwei, resp.Data["unit"])
+
+	t.Run("fees out of bounds are refused", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "sign", sign)
+		require.ErrorContains(t, err, helpers.ErrFeeOutOfBounds.Error())
+	})
+
+	t.Run("overrideFee signs them", func(t *testing.T) {
+		override := map[string]interface{}{"overrideFee": true}
+		for k, v := range sign {
+			override[k] = v
+		}
+		resp, err := request(logical.UpdateOperation, "sign", override)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Equal(t, true, resp.Data["feeOverride"])
+		assert.InDelta(t, 20.0, resp.Data["feeRate"], 1e-9)
+		require.Len(t, resp.Warnings, 1)
+		assert.Equal(t, helpers.WarningFeeOverridden, helpers.WarningCode(resp.Warnings[0]))
+	})
+
+	t.Run("fees near the max are signed with a warning", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/fees/60", map[string]interface{}{"min": 1, "max": 21})
+		require.NoError(t, err)
+		resp, err := request(logical.UpdateOperation, "sign", sign)
+		require.NoError(t, err)
+		require.Len(t, resp.Warnings, 1)
+		assert.Equal(t, helpers.WarningFeeNearLimit, helpers.WarningCode(resp.Warnings[0]))
+	})
+
+	t.Run("fees within bounds are signed", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/fees/60", map[string]interface{}{"min": 1, "max": 50})
+		require.NoError(t, err)
+		resp, err := request(logical.UpdateOperation, "sign", sign)
+		require.NoError(t, err)
+		assert.InDelta(t, 20.0, resp.Data["feeRate"], 1e-9)
+		assert.NotContains(t, resp.Data, "feeOverride")
+		assert.Empty(t, resp.Warnings)
+	})
+
+	resp, err = request(logical.ListOperation, "config/fees/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"60"}, resp.Data["keys"])
+
+	_, err = request(logical.DeleteOperation, "config/fees/60", nil)
+	require.NoError(t, err)
+	resp, err = request(logical.ReadOperation, "config/fees/60", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/hooks"
+)
+
+// pathListHooks corresponds to LIST config/hooks.
+func (b *Backend) pathListHooks(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_hooks"))
+
+	coinTypes, err := req.Storage.List(ctx, config.HooksStoragePath)
+	if err != nil {
+		backendLogger.Error("list hook chains", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(coinTypes), nil
+}
+
+// pathReadHooks corresponds to READ config/hooks/<coinType>.
+func (b *Backend) pathReadHooks(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_hooks"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	chain, err := helpers.GetHookChain(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("get hook chain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if chain == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: hooksResponseData(chain),
+	}, nil
+}
+
+// pathWriteHooks corresponds to UPDATE config/hooks/<coinType>. The chain replaces the stored one.
+func (b *Backend) pathWriteHooks(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_hooks"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	chain := &helpers.HookChain{
+		CoinType: coinType,
+		Hooks:    d.Get("hooks").([]string),
+	}
+	if err := hooks.Validate(coinType, chain.Hooks); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutHookChain(ctx, req.Storage, chain); err != nil {
+		backendLogger.Error("put hook chain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("hook chain updated", "coinType", coinType, "hooks", chain.Hooks)
+
+	return &logical.Response{
+		Data: hooksResponseData(chain),
+	}, nil
+}
+
+// pathDeleteHooks corresponds to DELETE config/hooks/<coinType>.
+func (b *Backend) pathDeleteHooks(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_hooks"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.DeleteHookChain(ctx, req.Storage, coinType); err != nil {
+		backendLogger.Error("delete hook chain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("hook chain deleted", "coinType", coinType)
+	return nil, nil
+}
+
+func hooksResponseData(chain *helpers.HookChain) map[string]interface{} {
+	return map[string]interface{}{
+		"coinType": chain.CoinType,
+		"hooks":    chain.Hooks,
+	}
+}
+
+// withPayloadHooks runs the hook chain configured for the coin type of the request on its payload
+// before op, and adds the action of every hook under hooks in the response. The actions are also
+// logged, so both the plugin logs and the audit log of Vault record them.
+func (b *Backend) withPayloadHooks(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "payload_hooks"))
+
+		// invalid fields are left to op to reject
+		coinType, ok, err := d.GetOkErr("coinType")
+		if err != nil || !ok || coinType.(int) < 0 || coinType.(int) > math.MaxUint16 {
+			return op(ctx, req, d)
+		}
+		payload, ok, err := d.GetOkErr("payload")
+		if err != nil || !ok {
+			return op(ctx, req, d)
+		}
+		chain, err := helpers.GetHookChain(ctx, req.Storage, uint16(coinType.(int)))
+		if err != nil {
+			backendLogger.Error("get hook chain", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if chain == nil || len(chain.Hooks) == 0 {
+			return op(ctx, req, d)
+		}
+
+		processed, actions, err := hooks.Apply(chain.CoinType, payload.(string), chain.Hooks)
+		if err != nil {
+			backendLogger.Error("apply payload hooks", "error", err, "coinType", chain.CoinType)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		for _, action := range actions {
+			backendLogger.Info("payload hook", "coinType", chain.CoinType, "hook", action.Hook, "action", action.Action)
+		}
+
+		// the request data is left as received
+		raw := make(map[string]interface{}, len(d.Raw))
+		for k, v := range d.Raw {
+			raw[k] = v
+		}
+		raw["payload"] = processed
+		resp, err := op(ctx, req, &framework.FieldData{Raw: raw, Schema: d.Schema})
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		resp.Data["hooks"] = actions
+		return resp, nil
+	}
+}
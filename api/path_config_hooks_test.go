@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/hooks"
+)
+
+func TestBackend_HandleRequest_PayloadHooks(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+
+	t.Run("invalid chains are rejected", func(t *testing.T) {
+		for _, chain := range []string{"evm-gas-oracle", "evm-chain-id=0", "evm-normalize-gas=1"} {
+			_, err := request(logical.UpdateOperation, "config/hooks/60", map[string]interface{}{"hooks": chain})
+			require.Error(t, err, chain)
+		}
+		_, err := request(logical.UpdateOperation, "config/hooks/501", map[string]interface{}{"hooks": "evm-chain-id=1"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), hooks.ErrUnsupportedCoin.Error())
+	})
+
+	resp, err := request(logical.UpdateOperation, "config/hooks/60", map[string]interface{}{
+		"hooks": "evm-normalize-gas,evm-strip-tx-type,evm-chain-id=1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"evm-normalize-gas", "evm-strip-tx-type", "evm-chain-id=1"}, resp.Data["hooks"])
+
+	sign := map[string]interface{}{
+		"uuid": signTestUUID, "path": "m/44'/60'/0'/0/0", "coinType": 60,
+		"payload": `{"type":"0x2","nonce":"0x2a","value":"1000","gas":"0x5208","maxFeePerGas":"0x4a817c800",` +
+			`"maxPriorityFeePerGas":"0x3b9aca00","to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0x"}`,
+	}
+	resp, err = request(logical.UpdateOperation, "sign", sign)
+	require.NoError(t, err)
+	assert.Equal(t, []hooks.Action{
+		{Hook: "evm-normalize-gas", Action: "normalized gas to gasLimit, nonce, value, maxFeePerGas, maxPriorityFeePerGas"},
+		{Hook: "evm-strip-tx-type", Action: "stripped type, maxFeePerGas, maxPriorityFeePerGas"},
+		{Hook: "evm-chain-id=1", Action: "set chainId to 1"},
+	}, resp.Data["hooks"])
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "0x"))
+	require.NoError(t, err)
+	var tx types.Transaction
+	require.NoError(t, tx.UnmarshalBinary(raw))
+	assert.Equal(t, int64(1), tx.ChainId().Int64())
+	assert.Equal(t, uint64(42), tx.Nonce())
+	assert.Equal(t, uint64(21000), tx.Gas())
+	assert.Equal(t, int64(20000000000), tx.GasPrice().Int64())
+
+	t.Run("payloads for another chain are rejected", func(t *testing.T) {
+		sign["payload"] = `{"nonce":1,"value":1,"gasLimit":21000,"gasPrice":1,"chainId":5,` +
+			`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`
+		_, err := request(logical.UpdateOperation, "sign", sign)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), hooks.ErrWrongChain.Error())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "config/hooks/60", nil)
+		require.NoError(t, err)
+		resp, err := request(logical.UpdateOperation, "sign", sign)
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "hooks")
+	})
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+)
+
+const (
+	// eventPublishTimeout bounds the publication of the queue by a request, the periodic function
+	// publishes what it left
+	eventPublishTimeout = 2 * time.Second
+	// eventPublishBackoff holds back the publications of the requests after a failed one
+	eventPublishBackoff = 30 * time.Second
+	// eventPublishBatch bounds the events published at once
+	eventPublishBatch = 500
+)
+
+// pathReadKafka corresponds to READ config/kafka.
+func (b *Backend) pathReadKafka(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_kafka"))
+
+	kafka, err := helpers.GetKafkaConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get kafka config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	queued, err := req.Storage.List(ctx, config.EventsStoragePath)
+	if err != nil {
+		backendLogger.Error("list queued events", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if kafka == nil {
+		if len(queued) == 0 {
+			return nil, nil
+		}
+		kafka = &helpers.KafkaConfig{}
+	}
+
+	data := kafkaResponseData(kafka)
+	data["queued"] = len(queued)
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathWriteKafka corresponds to UPDATE config/kafka. The sink replaces the stored one, the queued
+// events are published to it.
+func (b *Backend) pathWriteKafka(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_kafka"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	kafka := &helpers.KafkaConfig{
+		Brokers:       d.Get("brokers").([]string),
+		Topic:         d.Get("topic").(string),
+		SASLMechanism: d.Get("saslMechanism").(string),
+		Username:      d.Get("username").(string),
+		Password:      d.Get("password").(string),
+		TLS:           d.Get("tls").(bool),
+		CACert:        d.Get("caCert").(string),
+	}
+	if err := kafka.Options().Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutKafkaConfig(ctx, req.Storage, kafka); err != nil {
+		backendLogger.Error("put kafka config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	b.resetEventSink()
+
+	backendLogger.Info("kafka sink updated", "brokers", kafka.Brokers, "topic", kafka.Topic,
+		"saslMechanism", kafka.SASLMechanism, "tls", kafka.TLS, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: kafkaResponseData(kafka),
+	}, nil
+}
+
+// pathDeleteKafka corresponds to DELETE config/kafka. The events are no longer queued, the queued
+// ones are kept until a sink is configured again.
+func (b *Backend) pathDeleteKafka(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_kafka", config.KafkaStorageKey)
+	if err == nil {
+		b.resetEventSink()
+	}
+	return resp, err
+}
+
+func kafkaResponseData(kafka *helpers.KafkaConfig) map[string]interface{} {
+	brokers := kafka.Brokers
+	if brokers == nil {
+		brokers = []string{}
+	}
+	return map[string]interface{}{
+		"brokers":       brokers,
+		"topic":         kafka.Topic,
+		"saslMechanism": kafka.SASLMechanism,
+		"username":      kafka.Username,
+		"passwordSet":   kafka.Password != "",
+		"tls":           kafka.TLS,
+		"caCertSet":     kafka.CACert != "",
+	}
+}
+
+// eventSink returns the Kafka sink of the mount, or nil when none is configured. The
+// configuration is read on first use and kept until it changes.
+func (b *Backend) eventSink(ctx context.Context, s logical.Storage) (*helpers.KafkaConfig, error) {
+	b.eventSinkMu.Lock()
+	defer b.eventSinkMu.Unlock()
+
+	if b.eventSinkLoaded {
+		return b.eventSinkConfig, nil
+	}
+	kafka, err := helpers.GetKafkaConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	b.eventSinkConfig, b.eventSinkLoaded = kafka, true
+	return kafka, nil
+}
+
+// resetEventSink drops the sink so the next event reloads it from the configuration
+func (b *Backend) resetEventSink() {
+	b.eventSinkMu.Lock()
+	b.eventSinkConfig, b.eventSinkLoaded = nil, false
+	b.eventSinkMu.Unlock()
+
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+	if b.publisher != nil {
+		_ = b.publisher.Close()
+	}
+	b.publisher, b.publishRetryAt = nil, time.Time{}
+}
+
+// requestEvent returns the event of a served request, or nil when it has none: the users
+// registered and the changes of the configuration, API keys and address book of the mount. The
+// signatures of sign, sign/batch and session/sign are recorded with their receipts.
+func requestEvent(req *logical.Request, resp *logical.Response) *eventsink.Event {
+	if resp != nil && resp.IsError() {
+		return nil
+	}
+	write := req.Operation == logical.UpdateOperation || req.Operation == logical.CreateOperation
+	event := &eventsink.Event{Path: req.Path, EntityID: req.EntityID}
+	switch {
+	case write && (req.Path == "register" || req.Path == "register_uuid"):
+		event.Type = eventsink.TypeRegister
+		if resp != nil && resp.Data != nil {
+			event.UUID, _ = resp.Data["uuid"].(string)
+			event.Data = map[string]interface{}{"fingerprint": resp.Data["fingerprint"]}
+		}
+	case write && strings.HasPrefix(req.Path, "sign/") && req.Path != "sign/batch":
+		if resp == nil || resp.Data == nil {
+			return nil
+		}
+		event.Type = eventsink.TypeSign
+		event.UUID, _ = req.Data["uuid"].(string)
+		event.Data = map[string]interface{}{"address": resp.Data["address"]}
+	case (write || req.Operation == logical.DeleteOperation) && (strings.HasPrefix(req.Path, config.ConfigStoragePath) ||
+		strings.HasPrefix(req.Path, config.APIKeysStoragePath) ||
+		strings.HasPrefix(req.Path, config.AddressBookStoragePath)):
+		event.Type = eventsink.TypePolicy
+		event.Data = map[string]interface{}{"operation": string(req.Operation)}
+	default:
+		return nil
+	}
+	return event
+}
+
+// queueEvent stores event for publication when config/kafka is configured, and publishes the
+// queue. The request succeeded: a failure is logged, the events already queued are kept.
+func (b *Backend) queueEvent(ctx context.Context, s logical.Storage, event *eventsink.Event) {
+	kafka, err := b.eventSink(ctx, s)
+	if err != nil {
+		b.logger.Error("get kafka config", "error", err)
+		return
+	}
+	if kafka == nil {
+		return
+	}
+
+	event.ID, event.Time = helpers.NewUUID(), time.Now().UTC()
+	if err := helpers.QueueEvent(ctx, s, event); err != nil {
+		b.logger.Error("queue event", "error", err, "type", event.Type, "path", event.Path)
+		return
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+	defer cancel()
+	if err := b.publishEvents(publishCtx, s, false); err != nil {
+		b.logger.Error("publish events", "error", err)
+	}
+}
+
+// publishEvents publishes the queued events to the Kafka sink, in the order they were queued, and
+// removes them from the queue once the brokers acknowledged them. With retry unset, the
+// publication is skipped while another one runs or after a failure, the periodic function retries.
+func (b *Backend) publishEvents(ctx context.Context, s logical.Storage, retry bool) error {
+	if retry {
+		b.publishMu.Lock()
+	} else if !b.publishMu.TryLock() {
+		return nil
+	}
+	defer b.publishMu.Unlock()
+	if !retry && time.Now().Before(b.publishRetryAt) {
+		return nil
+	}
+
+	kafka, err := b.eventSink(ctx, s)
+	if err != nil || kafka == nil {
+		return err
+	}
+	ids, err := s.List(ctx, config.EventsStoragePath)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	// the IDs sort in the order the events were queued
+	sort.Strings(ids)
+
+	// the publisher is kept, with its connections to the brokers, until the sink changes
+	if b.publisher == nil {
+		if b.publisher, err = b.newPublisher(kafka.Options()); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(ids); start += eventPublishBatch {
+		batch := ids[start:min(start+eventPublishBatch, len(ids))]
+		events := make([]eventsink.Event, 0, len(batch))
+		for _, id := range batch {
+			event, err := helpers.GetQueuedEvent(ctx, s, id)
+			if err != nil {
+				return err
+			}
+			if event != nil {
+				events = append(events, *event)
+			}
+		}
+		if err := b.publisher.Publish(ctx, events); err != nil {
+			b.publishRetryAt = time.Now().Add(eventPublishBackoff)
+			return err
+		}
+		for _, event := range events {
+			if err := helpers.DeleteQueuedEvent(ctx, s, event.ID); err != nil {
+				return err
+			}
+		}
+		b.logger.Debug("events published", "count", len(events), "topic", kafka.Topic)
+	}
+	b.publishRetryAt = time.Time{}
+	return nil
+}
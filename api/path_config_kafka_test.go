@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+)
+
+var errBrokersDown = errors.New("brokers down")
+
+// fakePublisher records the published events, failing while down is set
+type fakePublisher struct {
+	mu     sync.Mutex
+	down   bool
+	events []eventsink.Event
+}
+
+func (p *fakePublisher) Publish(_ context.Context, events []eventsink.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errBrokersDown
+	}
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func (p *fakePublisher) published() []eventsink.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]eventsink.Event(nil), p.events...)
+}
+
+func TestBackend_HandleRequest_Kafka(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	publisher := &fakePublisher{}
+	var options eventsink.KafkaOptions
+	b.newPublisher = func(o eventsink.KafkaOptions) (eventsink.Publisher, error) {
+		options = o
+		return publisher, nil
+	}
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: "entity-1",
+		})
+	}
+	sign := func() *logical.Response {
+		resp, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("without sink no event is queued", func(t *testing.T) {
+		sign()
+		keys, err := s.List(ctx, config.EventsStoragePath)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+		resp, err := request(logical.ReadOperation, "config/kafka", nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("invalid sinks are rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/kafka", map[string]interface{}{"topic": "events"})
+		require.ErrorContains(t, err, eventsink.ErrNoBrokers.Error())
+		_, err = request(logical.UpdateOperation, "config/kafka", map[string]interface{}{
+			"brokers": "kafka-1:9092", "topic": "events", "saslMechanism": eventsink.MechanismPlain,
+		})
+		require.ErrorContains(t, err, eventsink.ErrNoCredentials.Error())
+	})
+
+	resp, err := request(logical.UpdateOperation, "config/kafka", map[string]interface{}{
+		"brokers": "kafka-1:9092,kafka-2:9092", "topic": "events",
+		"saslMechanism": eventsink.MechanismSCRAMSHA256, "username": "vault", "password": "kafka-secret", "tls": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["passwordSet"])
+	assert.NotContains(t, resp.Data, "password")
+
+	t.Run("events are published in order", func(t *testing.T) {
+		signed := sign()
+		_, err := request(logical.UpdateOperation, "register", map[string]interface{}{"uuid": "kafka-user"})
+		require.NoError(t, err)
+
+		events := publisher.published()
+		require.Len(t, events, 3)
+		assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, options.Brokers)
+		assert.Equal(t, "kafka-secret", options.Password)
+
+		assert.Equal(t, eventsink.TypePolicy, events[0].Type)
+		assert.Equal(t, "config/kafka", events[0].Path)
+		assert.Equal(t, "entity-1", events[0].EntityID)
+
+		assert.Equal(t, eventsink.TypeSign, events[1].Type)
+		assert.Equal(t, signTestUUID, events[1].UUID)
+		receipt := signed.Data["receipt"].(map[string]interface{})
+		assert.Equal(t, receipt["id"], events[1].Data["id"])
+		assert.Equal(t, receipt["payloadHash"], events[1].Data["payloadHash"])
+
+		assert.Equal(t, eventsink.TypeRegister, events[2].Type)
+		assert.Equal(t, "kafka-user", events[2].UUID)
+		assert.NotContains(t, events[2].Data, "mnemonic")
+
+		keys, err := s.List(ctx, config.EventsStoragePath)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("events are kept while the brokers are down", func(t *testing.T) {
+		publisher.mu.Lock()
+		publisher.down = true
+		publisher.mu.Unlock()
+		sign()
+		sign()
+
+		resp, err := request(logical.ReadOperation, "config/kafka", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, resp.Data["queued"])
+		assert.Len(t, publisher.published(), 3)
+
+		publisher.mu.Lock()
+		publisher.down = false
+		publisher.mu.Unlock()
+		require.NoError(t, b.publishEvents(ctx, s, true))
+		events := publisher.published()
+		require.Len(t, events, 5)
+		assert.Equal(t, eventsink.TypeSign, events[3].Type)
+		assert.Less(t, events[3].Data["sequence"], events[4].Data["sequence"])
+	})
+
+	_, err = request(logical.DeleteOperation, "config/kafka", nil)
+	require.NoError(t, err)
+	sign()
+	assert.Len(t, publisher.published(), 5)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadLockout corresponds to READ config/lockout
+func (b *Backend) pathReadLockout(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_lockout"))
+
+	policy, err := helpers.GetLockoutPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get lockout policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: lockoutResponseData(policy),
+	}, nil
+}
+
+// pathWriteLockout corresponds to UPDATE config/lockout. Settings that are not provided keep their
+// stored value; the violations already noted count with the new window.
+func (b *Backend) pathWriteLockout(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_lockout"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	policy, err := helpers.GetLockoutPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get lockout policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("maxViolations"); ok {
+		policy.MaxViolations = v.(int)
+	}
+	if v, ok := d.GetOk("window"); ok {
+		policy.Window = time.Duration(v.(int)) * time.Second
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.LockoutStorageKey, policy)
+	if err != nil {
+		backendLogger.Error("encode lockout policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put lockout policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("lockout policy updated", "maxViolations", policy.MaxViolations, "window", policy.Window)
+
+	return &logical.Response{
+		Data: lockoutResponseData(policy),
+	}, nil
+}
+
+// pathDeleteLockout corresponds to DELETE config/lockout. The violations are only noted again;
+// the users already locked stay locked.
+func (b *Backend) pathDeleteLockout(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_lockout", config.LockoutStorageKey)
+}
+
+func lockoutResponseData(policy *helpers.LockoutPolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"maxViolations": policy.MaxViolations,
+		"window":        int64(policy.Window.Seconds()),
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// pathReadLogging corresponds to READ config/logging.
+func (b *Backend) pathReadLogging(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_logging"))
+
+	loggingConfig, err := helpers.GetLoggingConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get logging config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: loggingResponseData(loggingConfig),
+	}, nil
+}
+
+// pathWriteLogging corresponds to UPDATE config/logging. The new level applies immediately.
+func (b *Backend) pathWriteLogging(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_logging"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	loggingConfig, err := helpers.GetLoggingConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get logging config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("level"); ok {
+		loggingConfig.Level = strings.ToUpper(v.(string))
+	}
+	level, err := logging.ParseLevel(loggingConfig.Level)
+	if err != nil {
+		backendLogger.Error("parse level", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.LoggingStorageKey, loggingConfig)
+	if err != nil {
+		backendLogger.Error("encode logging config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put logging config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if b.logLevel != nil {
+		b.logLevel.Set(level)
+	}
+	backendLogger.Info("logging updated", "level", loggingConfig.Level)
+
+	return &logical.Response{
+		Data: loggingResponseData(loggingConfig),
+	}, nil
+}
+
+func loggingResponseData(loggingConfig *helpers.LoggingConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"level": loggingConfig.Level,
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// Helper function to create a proper framework.FieldData for config/logging endpoint
+func createLoggingFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"level": {Type: framework.TypeString},
+		},
+	}
+}
+
+func TestBackend_PathLogging(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults to info", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.LoggingStorageKey).Return(nil, nil)
+
+		got, err := createSignTestBackend(t).pathReadLogging(ctx, &logical.Request{Storage: mockStorage},
+			createLoggingFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, config.Info, got.Data["level"])
+	})
+
+	t.Run("update applies the level immediately", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.LoggingStorageKey).Return(nil, nil)
+		mockStorage.On("Put", ctx, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+			var stored helpers.LoggingConfig
+			return entry.Key == config.LoggingStorageKey &&
+				json.Unmarshal(entry.Value, &stored) == nil && stored.Level == config.Debug
+		})).Return(nil)
+
+		b := createSignTestBackend(t)
+		b.logLevel = new(slog.LevelVar)
+
+		data := map[string]interface{}{"level": "debug"}
+		got, err := b.pathWriteLogging(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createLoggingFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, config.Debug, got.Data["level"])
+		assert.Equal(t, slog.LevelDebug, b.logLevel.Level())
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.LoggingStorageKey).Return(nil, nil)
+
+		data := map[string]interface{}{"level": "verbose"}
+		_, err := createSignTestBackend(t).pathWriteLogging(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createLoggingFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), logging.ErrInvalidLevel.Error())
+	})
+
+	t.Run("initialize applies the stored level", func(t *testing.T) {
+		storage := &logical.InmemStorage{}
+		entry, err := logical.StorageEntryJSON(config.LoggingStorageKey, helpers.LoggingConfig{Level: config.Error})
+		require.NoError(t, err)
+		require.NoError(t, storage.Put(ctx, entry))
+
+		b := NewBackend(nil)
+		require.NoError(t, b.initialize(ctx, &logical.InitializationRequest{Storage: storage}))
+		assert.Equal(t, slog.LevelError, b.logLevel.Level())
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadPassphrasePolicy corresponds to READ config/passphrase.
+func (b *Backend) pathReadPassphrasePolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_passphrase_policy"))
+
+	policy, err := helpers.GetPassphrasePolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get passphrase policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: passphrasePolicyResponseData(policy),
+	}, nil
+}
+
+// pathWritePassphrasePolicy corresponds to UPDATE config/passphrase. Fields that are not provided
+// keep their stored value; the policy applies to the users registered next.
+func (b *Backend) pathWritePassphrasePolicy(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_passphrase_policy"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	policy, err := helpers.GetPassphrasePolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get passphrase policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("minLength"); ok {
+		policy.MinLength = v.(int)
+	}
+	if v, ok := d.GetOk("minEntropyBits"); ok {
+		policy.MinEntropyBits = v.(float64)
+	}
+	if v, ok := d.GetOk("verifier"); ok {
+		policy.Verifier = v.(string)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutPassphrasePolicy(ctx, req.Storage, policy); err != nil {
+		backendLogger.Error("put passphrase policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("passphrase policy updated", "minLength", policy.MinLength,
+		"minEntropyBits", policy.MinEntropyBits, "verifier", policy.Verifier)
+
+	return &logical.Response{
+		Data: passphrasePolicyResponseData(policy),
+	}, nil
+}
+
+// pathDeletePassphrasePolicy corresponds to DELETE config/passphrase. Any passphrase is accepted
+// again and no verifier is stored.
+func (b *Backend) pathDeletePassphrasePolicy(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_passphrase_policy", config.PassphrasePolicyStorageKey)
+}
+
+func passphrasePolicyResponseData(policy *helpers.PassphrasePolicy) map[string]interface{} {
+	return map[string]interface{}{
+		"minLength":      policy.MinLength,
+		"minEntropyBits": policy.MinEntropyBits,
+		"verifier":       policy.Verifier,
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/passphrase"
+)
+
+func TestBackend_HandleRequest_PassphrasePolicy(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	register := func(uuid, passphrase string) error {
+		t.Helper()
+		_, err := request(logical.UpdateOperation, "register", map[string]interface{}{
+			"uuid": uuid, "mnemonic": signTestValidMnemonic, "passphrase": passphrase,
+		})
+		return err
+	}
+	health := func(uuid string) map[string]interface{} {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, "user/"+uuid+"/health", nil)
+		require.NoError(t, err)
+		return resp.Data
+	}
+
+	t.Run("registration without a policy accepts any passphrase", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "config/passphrase", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 0, resp.Data["minLength"])
+
+		require.NoError(t, register("legacy", "password"))
+		data := health("legacy")
+		assert.Equal(t, true, data["healthy"])
+		assert.Equal(t, false, data["passphrase"].(map[string]interface{})["verified"])
+	})
+
+	t.Run("the policy rejects weak passphrases", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/passphrase", map[string]interface{}{"verifier": "bcrypt"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(logical.HTTPCodedError).Code())
+		_, err = request(logical.UpdateOperation, "config/passphrase", map[string]interface{}{"minLength": -1})
+		require.Error(t, err)
+
+		resp, err := request(logical.UpdateOperation, "config/passphrase", map[string]interface{}{
+			"minLength": 12, "minEntropyBits": 50, "verifier": passphrase.AlgorithmArgon2id,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, passphrase.AlgorithmArgon2id, resp.Data["verifier"])
+
+		for _, weak := range []string{"", "Tr0ub4dor&3", "aaaaaaaaaaaaaaaa", "passwordpassword"} {
+			err := register("weak", weak)
+			require.Error(t, err, weak)
+			assert.Equal(t, http.StatusUnprocessableEntity, err.(logical.HTTPCodedError).Code(), weak)
+		}
+		exists, err := s.Get(ctx, config.StorageBasePath+"weak")
+		require.NoError(t, err)
+		assert.Nil(t, exists)
+
+		data := health("legacy")
+		assert.Equal(t, false, data["healthy"])
+		assert.ElementsMatch(t, []string{helpers.HealthWeakPassphrase, helpers.HealthMissingVerifier}, data["issues"])
+	})
+
+	t.Run("strong passphrases are stored with their verifier", func(t *testing.T) {
+		require.NoError(t, register("strong", "correct horse battery staple"))
+		data := health("strong")
+		assert.Equal(t, true, data["healthy"])
+		assert.Equal(t, map[string]interface{}{
+			"length": 28, "entropyBits": 61.8, "meetsPolicy": true,
+			"verifier": passphrase.AlgorithmArgon2id, "verified": true,
+		}, data["passphrase"])
+
+		resp, err := request(logical.ReadOperation, "user/strong", nil)
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "passphraseVerifier")
+
+		// a passphrase changed behind the plugin no longer matches its verifier
+		entry, err := s.Get(ctx, config.StorageBasePath+"strong")
+		require.NoError(t, err)
+		var user helpers.User
+		require.NoError(t, entry.DecodeJSON(&user))
+		user.Passphrase = "correct horse battery stapler"
+		entry, err = logical.StorageEntryJSON(config.StorageBasePath+"strong", &user)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, entry))
+		assert.Equal(t, []string{helpers.HealthVerifierMismatch}, health("strong")["issues"])
+	})
+
+	t.Run("deleting the policy accepts any passphrase again", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "config/passphrase", nil)
+		require.NoError(t, err)
+		require.NoError(t, register("weak", ""))
+		assert.Equal(t, true, health("legacy")["healthy"])
+	})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/queue"
+)
+
+// pathReadQuotas corresponds to READ config/quotas.
+func (b *Backend) pathReadQuotas(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_quotas"))
+
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: quotasResponseData(quotas),
+	}, nil
+}
+
+// pathWriteQuotas corresponds to UPDATE config/quotas. Quotas that are not provided keep their
+// stored value; new quotas apply to the next requests.
+func (b *Backend) pathWriteQuotas(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_quotas"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("maxBatchCount"); ok {
+		quotas.MaxBatchCount = v.(int)
+	}
+	if v, ok := d.GetOk("maxConcurrentRequests"); ok {
+		quotas.MaxConcurrentRequests = v.(int)
+	}
+	if v, ok := d.GetOk("queue"); ok {
+		quotas.Queue = v.(bool)
+	}
+	if v, ok := d.GetOk("maxQueueDepth"); ok {
+		quotas.MaxQueueDepth = v.(int)
+	}
+	if v, ok := d.GetOk("maxQueueWait"); ok {
+		quotas.MaxQueueWait = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("maxFieldLengths"); ok {
+		if quotas.MaxFieldLengths == nil {
+			quotas.MaxFieldLengths = map[string]int{}
+		}
+		for name, value := range v.(map[string]string) {
+			length, err := strconv.Atoi(value)
+			if _, limited := helpers.DefaultMaxFieldLengths[name]; !limited || err != nil || length <= 0 {
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidFieldLimit.Error())
+			}
+			quotas.MaxFieldLengths[name] = length
+		}
+	}
+	if quotas.MaxBatchCount <= 0 || quotas.MaxConcurrentRequests < 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidQuota.Error())
+	}
+	if quotas.MaxQueueDepth < 0 || quotas.MaxQueueWait < 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidQueue.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.QuotasStorageKey, quotas)
+	if err != nil {
+		backendLogger.Error("encode quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("quotas updated", "quotas", quotas)
+
+	return &logical.Response{
+		Data: quotasResponseData(quotas),
+	}, nil
+}
+
+func quotasResponseData(quotas *helpers.Quotas) map[string]interface{} {
+	return map[string]interface{}{
+		"maxBatchCount":         quotas.MaxBatchCount,
+		"maxConcurrentRequests": quotas.MaxConcurrentRequests,
+		"queue":                 quotas.Queue,
+		"maxQueueDepth":         quotas.QueueDepth(),
+		"maxQueueWait":          int(quotas.QueueWait().Seconds()),
+		"maxFieldLengths":       quotas.FieldLengths(),
+	}
+}
+
+// checkFieldLengths rejects with 413 the values given in data for the limited fields beyond the
+// lengths of config/quotas, before anything decodes them. The quotas are only read when data has
+// one of the fields.
+func (b *Backend) checkFieldLengths(ctx context.Context, s logical.Storage, data map[string]interface{}) error {
+	if !slices.ContainsFunc(slices.Collect(maps.Keys(data)), func(name string) bool {
+		_, limited := helpers.DefaultMaxFieldLengths[name]
+		return limited
+	}) {
+		return nil
+	}
+	quotas, err := helpers.GetQuotas(ctx, s)
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.ValidateFieldLengths(data, quotas.FieldLengths()); err != nil {
+		return logical.CodedError(http.StatusRequestEntityTooLarge, err.Error())
+	}
+	return nil
+}
+
+// acquireRequestSlot takes one of the concurrent request slots of config/quotas, returning the
+// function releasing it, or a 429 error when the node already serves maxConcurrentRequests requests
+// and does not queue them
+func (b *Backend) acquireRequestSlot(ctx context.Context, req *logical.Request) (func(), error) {
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if quotas.Queued() {
+		return b.queueRequestSlot(ctx, req, quotas)
+	}
+
+	inFlight := b.inFlight.Add(1)
+	release := func() { b.inFlight.Add(-1) }
+	if quotas.MaxConcurrentRequests > 0 && inFlight > int64(quotas.MaxConcurrentRequests) {
+		release()
+		return nil, logical.CodedError(http.StatusTooManyRequests, helpers.ErrTooManyRequests.Error())
+	}
+	return release, nil
+}
+
+// requestClassKey is the context key of the priority class of a request
+type requestClassKey struct{}
+
+// withRequestClass returns ctx with the priority class its key operations are queued with
+func withRequestClass(ctx context.Context, class queue.Class) context.Context {
+	return context.WithValue(ctx, requestClassKey{}, class)
+}
+
+// requestClass returns the priority class of ctx, interactive unless withRequestClass set another
+func requestClass(ctx context.Context) queue.Class {
+	if class, ok := ctx.Value(requestClassKey{}).(queue.Class); ok {
+		return class
+	}
+	return queue.Interactive
+}
+
+// queueRequestSlot waits for one of the request slots of quotas in the queue of the class of ctx,
+// the users of the class taking turns. Requests are rejected with 429 when the queue is full or
+// they waited maxQueueWait.
+func (b *Backend) queueRequestSlot(ctx context.Context, req *logical.Request,
+	quotas *helpers.Quotas) (func(), error) {
+	b.requestQueue.SetLimits(quotas.MaxConcurrentRequests, quotas.QueueDepth())
+
+	// requests without a user share the turn of their entity
+	key, _ := req.Data["uuid"].(string)
+	if key == "" {
+		key = req.EntityID
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, quotas.QueueWait())
+	defer cancel()
+
+	release, err := b.requestQueue.Acquire(waitCtx, requestClass(ctx), key)
+	switch {
+	case errors.Is(err, queue.ErrFull):
+		return nil, logical.CodedError(http.StatusTooManyRequests, err.Error())
+	case err != nil && ctx.Err() == nil:
+		return nil, logical.CodedError(http.StatusTooManyRequests, helpers.ErrQueueTimeout.Error())
+	case err != nil:
+		return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+	}
+
+	b.inFlight.Add(1)
+	return func() {
+		b.inFlight.Add(-1)
+		release()
+	}, nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/queue"
+)
+
+// Helper function to create a proper framework.FieldData for config/quotas endpoint
+func createQuotasFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"maxBatchCount":         {Type: framework.TypeInt},
+			"maxConcurrentRequests": {Type: framework.TypeInt},
+			"queue":                 {Type: framework.TypeBool},
+			"maxQueueDepth":         {Type: framework.TypeInt},
+			"maxQueueWait":          {Type: framework.TypeDurationSecond},
+			"maxFieldLengths":       {Type: framework.TypeKVPairs},
+			"apiKey":                {Type: framework.TypeString},
+		},
+	}
+}
+
+func TestBackend_PathQuotas(t *testing.T) {
+	ctx := context.Background()
+	b := createSignTestBackend(t)
+
+	t.Run("defaults", func(t *testing.T) {
+		got, err := b.pathReadQuotas(ctx, &logical.Request{Storage: &logical.InmemStorage{}}, createQuotasFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, helpers.DefaultMaxBatchCount, got.Data["maxBatchCount"])
+		assert.Equal(t, 0, got.Data["maxConcurrentRequests"])
+	})
+
+	t.Run("update keeps omitted quotas", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{"maxConcurrentRequests": 8}
+		_, err := b.pathWriteQuotas(ctx, &logical.Request{Storage: s, Data: data}, createQuotasFieldData(data))
+		require.NoError(t, err)
+
+		quotas, err := helpers.GetQuotas(ctx, s)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.Quotas{MaxBatchCount: helpers.DefaultMaxBatchCount, MaxConcurrentRequests: 8}, *quotas)
+	})
+
+	t.Run("invalid quotas", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{{"maxBatchCount": 0}, {"maxConcurrentRequests": -1}} {
+			_, err := b.pathWriteQuotas(ctx, &logical.Request{Storage: &logical.InmemStorage{}, Data: data},
+				createQuotasFieldData(data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), helpers.ErrInvalidQuota.Error())
+		}
+	})
+
+	t.Run("concurrent requests beyond the quota are rejected", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{"maxConcurrentRequests": 1}
+		_, err := b.pathWriteQuotas(ctx, &logical.Request{Storage: s, Data: data}, createQuotasFieldData(data))
+		require.NoError(t, err)
+
+		served := make(chan struct{})
+		proceed := make(chan struct{})
+		op := b.withAPIKey("address", func(context.Context, *logical.Request, *framework.FieldData) (*logical.Response, error) {
+			served <- struct{}{}
+			<-proceed
+			return &logical.Response{}, nil
+		})
+		done := make(chan error)
+		go func() {
+			_, err := op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+			done <- err
+		}()
+		<-served
+
+		_, err = op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusTooManyRequests, codedErr.Code())
+
+		close(proceed)
+		require.NoError(t, <-done)
+
+		// the slot is released once the request is served
+		go func() { <-served }()
+		_, err = op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+		require.NoError(t, err)
+	})
+	t.Run("queued requests wait for a slot", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{"maxConcurrentRequests": 1, "queue": true, "maxQueueWait": 1}
+		_, err := b.pathWriteQuotas(ctx, &logical.Request{Storage: s, Data: data}, createQuotasFieldData(data))
+		require.NoError(t, err)
+
+		served := make(chan struct{})
+		proceed := make(chan struct{})
+		op := b.withAPIKey("address", func(context.Context, *logical.Request, *framework.FieldData) (*logical.Response, error) {
+			served <- struct{}{}
+			<-proceed
+			return &logical.Response{}, nil
+		})
+		done := make(chan error)
+		go func() {
+			_, err := op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+			done <- err
+		}()
+		<-served
+
+		queued := make(chan error)
+		go func() {
+			_, err := op(withRequestClass(ctx, queue.Batch), &logical.Request{Storage: s,
+				Data: map[string]interface{}{"uuid": signTestUUID}}, createQuotasFieldData(nil))
+			queued <- err
+		}()
+		require.Eventually(t, func() bool { return b.requestQueue.Stats(queue.Batch).Depth == 1 },
+			time.Second, time.Millisecond)
+
+		resp, err := b.pathStatsQueue(ctx, &logical.Request{Storage: s}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["enabled"])
+		assert.Equal(t, 1, resp.Data["depth"])
+		batch := resp.Data["classes"].(map[string]interface{})["batch"].(map[string]interface{})
+		assert.Equal(t, 1, batch["waitingUsers"])
+
+		close(proceed)
+		require.NoError(t, <-done)
+		<-served
+		require.NoError(t, <-queued)
+
+		// requests waiting longer than maxQueueWait are rejected
+		proceed = make(chan struct{})
+		go func() {
+			_, err := op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+			done <- err
+		}()
+		<-served
+		_, err = op(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+		require.Error(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, err.(logical.HTTPCodedError).Code())
+		assert.Contains(t, err.Error(), helpers.ErrQueueTimeout.Error())
+		close(proceed)
+		require.NoError(t, <-done)
+	})
+}
+
+func TestBackend_HandleRequest_FieldLengths(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+	tooLarge := func(t *testing.T, err error, field string) {
+		t.Helper()
+		require.ErrorContains(t, err, helpers.ErrFieldTooLong.Error()+": "+field)
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, coded.Code())
+	}
+
+	t.Run("values beyond the default lengths are rejected before decoding", func(t *testing.T) {
+		_, err := request("sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": signTestDerivationPath,
+			"payload": strings.Repeat("a", helpers.DefaultMaxFieldLengths["payload"]+1),
+		})
+		tooLarge(t, err, "payload")
+		_, err = request("register", map[string]interface{}{"uuid": "long", "passphrase": strings.Repeat("p", 1025)})
+		tooLarge(t, err, "passphrase")
+		assert.NotContains(t, err.Error(), "ppp")
+		_, err = helpers.GetUser(ctx, &logical.Request{Storage: s}, "long")
+		require.ErrorIs(t, err, helpers.ErrUserNotFound)
+	})
+
+	t.Run("batch items are checked on their own", func(t *testing.T) {
+		resp, err := request("sign/batch", map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "derivationPath": signTestDerivationPath,
+				"payload": strings.Repeat("a", helpers.DefaultMaxFieldLengths["payload"]+1)},
+		}})
+		require.NoError(t, err)
+		result := resp.Data["results"].([]map[string]interface{})[0]
+		assert.Equal(t, batchItemFailed, result["status"])
+		assert.Contains(t, result["error"], helpers.ErrFieldTooLong.Error())
+	})
+
+	t.Run("config/quotas overrides the lengths", func(t *testing.T) {
+		resp, err := request("config/quotas", map[string]interface{}{"maxFieldLengths": "username=8"})
+		require.NoError(t, err)
+		lengths := resp.Data["maxFieldLengths"].(map[string]int)
+		assert.Equal(t, 8, lengths["username"])
+		assert.Equal(t, helpers.DefaultMaxFieldLengths["payload"], lengths["payload"])
+
+		_, err = request("register", map[string]interface{}{"uuid": "long", "username": "operator-1"})
+		tooLarge(t, err, "username")
+		_, err = request("register", map[string]interface{}{"uuid": "short", "username": "operator"})
+		require.NoError(t, err)
+
+		for _, limits := range []string{"username=0", "username=x", "memo=64"} {
+			_, err := request("config/quotas", map[string]interface{}{"maxFieldLengths": limits})
+			require.ErrorContains(t, err, helpers.ErrInvalidFieldLimit.Error())
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadReplicationConfig corresponds to READ config/replication. The key is never returned.
+func (b *Backend) pathReadReplicationConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_replication_config"))
+
+	replicationConfig, err := helpers.GetReplicationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get replication config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	sequence, err := storage.JournalSequence(ctx, req.Storage, config.ReplicationStoragePath)
+	if err != nil {
+		backendLogger.Error("journal sequence", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: replicationConfigResponseData(replicationConfig, sequence),
+	}, nil
+}
+
+// pathWriteReplicationConfig corresponds to UPDATE config/replication. The user records written
+// before the journal was enabled are journaled at once, so the first export carries them all.
+func (b *Backend) pathWriteReplicationConfig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_replication_config"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	key, err := hex.DecodeString(d.Get("key").(string))
+	if err != nil || len(key) != storage.KeyLength {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidReplicationKey.Error())
+	}
+	replicationConfig := &helpers.ReplicationConfig{Key: key}
+
+	entry, err := logical.StorageEntryJSON(config.ReplicationStorageKey, replicationConfig)
+	if err != nil {
+		backendLogger.Error("encode replication config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	entry.SealWrap = true
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put replication config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	b.resetReplication()
+
+	tracked, err := b.userJournal(req.Storage).Track(ctx)
+	if err != nil {
+		backendLogger.Error("track user records", "error", err, "tracked", tracked)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	sequence, err := storage.JournalSequence(ctx, req.Storage, config.ReplicationStoragePath)
+	if err != nil {
+		backendLogger.Error("journal sequence", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := replicationConfigResponseData(replicationConfig, sequence)
+	data["tracked"] = tracked
+	backendLogger.Info("replication updated", "keyId", data["keyId"], "tracked", tracked, "sequence", sequence)
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathDeleteReplicationConfig corresponds to DELETE config/replication. The writes are no longer
+// journaled; the journal is kept, configuring a key again journals the records written meanwhile
+// only when they have no change yet.
+func (b *Backend) pathDeleteReplicationConfig(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_replication_config", config.ReplicationStorageKey)
+	b.resetReplication()
+	return resp, err
+}
+
+func replicationConfigResponseData(replicationConfig *helpers.ReplicationConfig, sequence uint64) map[string]interface{} {
+	data := map[string]interface{}{
+		"enabled":  len(replicationConfig.Key) > 0,
+		"keyId":    "",
+		"sequence": sequence,
+	}
+	if len(replicationConfig.Key) > 0 {
+		data["keyId"] = storage.KeyID(replicationConfig.Key)
+	}
+	return data
+}
+
+// replicationJournaled reports whether the writes of the user records are journaled, loading the
+// configuration on first use
+func (b *Backend) replicationJournaled(ctx context.Context, s logical.Storage) (bool, error) {
+	b.replicationMu.Lock()
+	defer b.replicationMu.Unlock()
+
+	if !b.replicationLoaded {
+		replicationConfig, err := helpers.GetReplicationConfig(ctx, s)
+		if err != nil {
+			return false, err
+		}
+		b.replicationEnabled, b.replicationLoaded = len(replicationConfig.Key) > 0, true
+	}
+	return b.replicationEnabled, nil
+}
+
+// resetReplication drops the replication configuration, reloaded by the next request
+func (b *Backend) resetReplication() {
+	b.replicationMu.Lock()
+	defer b.replicationMu.Unlock()
+
+	b.replicationEnabled, b.replicationLoaded = false, false
+}
+
+// userJournal returns s with the writes of the user records journaled
+func (b *Backend) userJournal(s logical.Storage) *storage.Journal {
+	return storage.NewJournal(s, s, config.StorageBasePath, config.ReplicationStoragePath, &b.journalMu)
+}
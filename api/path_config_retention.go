@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadRetention corresponds to READ config/retention
+func (b *Backend) pathReadRetention(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_retention"))
+
+	retention, err := helpers.GetRetentionConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get retention config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: retentionResponseData(retention),
+	}, nil
+}
+
+// pathWriteRetention corresponds to UPDATE config/retention. Settings that are not provided keep
+// their stored value; the next periodic run applies them.
+func (b *Backend) pathWriteRetention(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_retention"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	retention, err := helpers.GetRetentionConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get retention config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if v, ok := d.GetOk("debugCaptureMaxAge"); ok {
+		retention.DebugCaptureMaxAge = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("debugCaptureMaxCount"); ok {
+		retention.DebugCaptureMaxCount = v.(int)
+	}
+	if v, ok := d.GetOk("receiptMaxAge"); ok {
+		retention.ReceiptMaxAge = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("receiptMaxCount"); ok {
+		retention.ReceiptMaxCount = v.(int)
+	}
+	if v, ok := d.GetOk("jobMaxAge"); ok {
+		retention.JobMaxAge = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("jobMaxCount"); ok {
+		retention.JobMaxCount = v.(int)
+	}
+	if v, ok := d.GetOk("journalMaxAge"); ok {
+		retention.JournalMaxAge = time.Duration(v.(int)) * time.Second
+	}
+	if v, ok := d.GetOk("journalMaxCount"); ok {
+		retention.JournalMaxCount = v.(int)
+	}
+	if err := retention.Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.RetentionStorageKey, retention)
+	if err != nil {
+		backendLogger.Error("encode retention config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put retention config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("retention updated", "debugCaptureMaxAge", retention.DebugCaptureMaxAge,
+		"debugCaptureMaxCount", retention.DebugCaptureMaxCount, "receiptMaxAge", retention.ReceiptMaxAge,
+		"receiptMaxCount", retention.ReceiptMaxCount, "jobMaxAge", retention.JobMaxAge,
+		"jobMaxCount", retention.JobMaxCount, "journalMaxAge", retention.JournalMaxAge,
+		"journalMaxCount", retention.JournalMaxCount)
+
+	return &logical.Response{
+		Data: retentionResponseData(retention),
+	}, nil
+}
+
+// pathDeleteRetention corresponds to DELETE config/retention. The defaults apply from the next run.
+func (b *Backend) pathDeleteRetention(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	return b.deleteConfig(ctx, req, "path_delete_retention", config.RetentionStorageKey)
+}
+
+func retentionResponseData(retention *helpers.RetentionConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"debugCaptureMaxAge":   int64(retention.DebugCaptureMaxAge.Seconds()),
+		"debugCaptureMaxCount": retention.DebugCaptureMaxCount,
+		"receiptMaxAge":        int64(retention.ReceiptMaxAge.Seconds()),
+		"receiptMaxCount":      retention.ReceiptMaxCount,
+		"jobMaxAge":            int64(retention.JobMaxAge.Seconds()),
+		"jobMaxCount":          retention.JobMaxCount,
+		"journalMaxAge":        int64(retention.JournalMaxAge.Seconds()),
+		"journalMaxCount":      retention.JournalMaxCount,
+	}
+}
+
+// pathMaintenancePrune corresponds to UPDATE maintenance/prune. It runs the pruning of the periodic
+// function now and reports what was removed; with dryRun nothing is removed.
+func (b *Backend) pathMaintenancePrune(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_maintenance_prune"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	dryRun := d.Get("dryRun").(bool)
+	now := time.Now()
+	if !dryRun {
+		if err := b.pruneDebugSessions(ctx, req.Storage, now); err != nil {
+			backendLogger.Error("prune debug sessions", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := b.pruneSigningSessions(ctx, req.Storage, now); err != nil {
+			backendLogger.Error("prune signing sessions", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := b.pruneApprovals(ctx, req.Storage, now); err != nil {
+			backendLogger.Error("prune approvals", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	removed, err := b.applyRetention(ctx, req.Storage, now, dryRun)
+	if err != nil {
+		backendLogger.Error("apply retention", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("pruned", "debugCaptures", removed.DebugCaptures, "receipts", removed.Receipts,
+		"jobs", removed.Jobs, "journal", removed.Journal, "dryRun", dryRun, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"dryRun":        dryRun,
+			"debugCaptures": removed.DebugCaptures,
+			"receipts":      removed.Receipts,
+			"jobs":          removed.Jobs,
+			"journal":       removed.Journal,
+		},
+	}, nil
+}
+
+// prunedRecords -- the records removed by the retention of the mount, by class
+type prunedRecords struct {
+	DebugCaptures int
+	Receipts      int
+	Jobs          int
+	Journal       int
+}
+
+// retainedRecord -- a record subject to retention, by the key it is removed with and the time its
+// age runs from
+type retainedRecord struct {
+	key  string
+	time time.Time
+}
+
+// applyRetention removes the debug captures, receipts, finished jobs and journaled deletions older
+// than the retention of the mount, and the oldest beyond its counts, returning how many were, or
+// would be with dryRun, removed
+func (b *Backend) applyRetention(ctx context.Context, s logical.Storage, now time.Time,
+	dryRun bool) (prunedRecords, error) {
+	var removed prunedRecords
+	retention, err := helpers.GetRetentionConfig(ctx, s)
+	if err != nil {
+		return removed, err
+	}
+
+	captures, err := debugCaptures(ctx, s)
+	if err != nil {
+		return removed, err
+	}
+	records := make([]retainedRecord, 0, len(captures))
+	for _, capture := range captures {
+		records = append(records, retainedRecord{key: capture.key, time: capture.Time})
+	}
+	removed.DebugCaptures, err = b.pruneRetained(ctx, "debug captures", records, now, retention.DebugCaptureMaxAge,
+		retention.DebugCaptureMaxCount, dryRun, s.Delete)
+	if err != nil {
+		return removed, err
+	}
+
+	receipts, err := receiptsSince(ctx, s, 0, math.MaxInt)
+	if err != nil {
+		return removed, err
+	}
+	records = make([]retainedRecord, 0, len(receipts))
+	for _, receipt := range receipts {
+		records = append(records, retainedRecord{key: config.ReceiptsStoragePath + receipt.ID, time: receipt.CreatedAt})
+	}
+	removed.Receipts, err = b.pruneRetained(ctx, "receipts", records, now, retention.ReceiptMaxAge,
+		retention.ReceiptMaxCount, dryRun, s.Delete)
+	if err != nil {
+		return removed, err
+	}
+
+	ids, err := s.List(ctx, config.JobsStoragePath)
+	if err != nil {
+		return removed, err
+	}
+	records = make([]retainedRecord, 0, len(ids))
+	for _, id := range ids {
+		job, err := helpers.GetJob(ctx, s, id)
+		if err != nil {
+			return removed, err
+		}
+		// the queued and running jobs are kept until they finish
+		if job != nil && job.Finished() {
+			records = append(records, retainedRecord{key: id, time: job.CompletedAt})
+		}
+	}
+	removed.Jobs, err = b.pruneRetained(ctx, "jobs", records, now, retention.JobMaxAge, retention.JobMaxCount,
+		dryRun, func(ctx context.Context, id string) error {
+			return helpers.DeleteJob(ctx, s, id)
+		})
+	if err != nil {
+		return removed, err
+	}
+
+	changes, err := storage.JournalChanges(ctx, s, config.ReplicationStoragePath, 0, math.MaxInt)
+	if err != nil {
+		return removed, err
+	}
+	records = make([]retainedRecord, 0, len(changes))
+	for _, change := range changes {
+		// the changes of the live records are what the replication copies them from
+		if change.Deleted {
+			records = append(records, retainedRecord{key: strconv.FormatUint(change.Seq, 10), time: change.Time})
+		}
+	}
+	removed.Journal, err = b.pruneRetained(ctx, "journal deletions", records, now, retention.JournalMaxAge,
+		retention.JournalMaxCount, dryRun, func(ctx context.Context, key string) error {
+			seq, err := strconv.ParseUint(key, 10, 64)
+			if err != nil {
+				return err
+			}
+			return storage.DeleteJournalChange(ctx, s, config.ReplicationStoragePath, seq)
+		})
+	return removed, err
+}
+
+// pruneRetained removes with remove the records older than maxAge at now, and the oldest beyond
+// maxCount, returning how many were, or would be with dryRun, removed. A zero bound is unlimited.
+func (b *Backend) pruneRetained(ctx context.Context, class string, records []retainedRecord, now time.Time,
+	maxAge time.Duration, maxCount int, dryRun bool, remove func(context.Context, string) error) (int, error) {
+	// newest first, so the count keeps the most recent records
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].time.After(records[j].time)
+	})
+	removed := 0
+	for i, record := range records {
+		if (maxCount == 0 || i < maxCount) && (maxAge == 0 || now.Sub(record.time) <= maxAge) {
+			continue
+		}
+		if !dryRun {
+			if err := remove(ctx, record.key); err != nil {
+				return removed, err
+			}
+		}
+		removed++
+	}
+	if removed > 0 && !dryRun {
+		b.logger.Info(class+" pruned", "removed", removed, "kept", len(records)-removed)
+	}
+	return removed, nil
+}
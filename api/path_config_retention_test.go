@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_HandleRequest_Retention(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+		require.NoError(t, err)
+		return resp
+	}
+	// putCapture stores a capture of uuid taken age ago
+	putCapture := func(uuid string, age time.Duration) {
+		capture := helpers.DebugCapture{ID: helpers.NewUUID(), Time: time.Now().Add(-age).UTC(), Path: "sign"}
+		entry, err := logical.StorageEntryJSON(config.DebugStoragePath+uuid+"/"+capture.ID, capture)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, entry))
+	}
+	countCaptures := func() int {
+		total := 0
+		for _, uuid := range []string{"alice", "bob"} {
+			ids, err := s.List(ctx, config.DebugStoragePath+uuid+"/")
+			require.NoError(t, err)
+			total += len(ids)
+		}
+		return total
+	}
+
+	resp := request(logical.ReadOperation, "config/retention", nil)
+	assert.Equal(t, map[string]interface{}{
+		"debugCaptureMaxAge":   int64(helpers.DefaultDebugCaptureMaxAge.Seconds()),
+		"debugCaptureMaxCount": helpers.DefaultDebugCaptureMaxCount,
+		"receiptMaxAge":        int64(0),
+		"receiptMaxCount":      0,
+		"jobMaxAge":            int64(helpers.DefaultJobMaxAge.Seconds()),
+		"jobMaxCount":          0,
+		"journalMaxAge":        int64(0),
+		"journalMaxCount":      0,
+	}, resp.Data)
+
+	for i := range 3 {
+		putCapture("alice", time.Duration(i)*time.Minute)
+	}
+	putCapture("bob", time.Second)
+	putCapture("bob", 72*time.Hour)
+
+	t.Run("dry run counts the captures beyond the default age", func(t *testing.T) {
+		resp := request(logical.UpdateOperation, "maintenance/prune", map[string]interface{}{"dryRun": true})
+		assert.Equal(t, 1, resp.Data["debugCaptures"])
+		assert.Equal(t, 5, countCaptures())
+	})
+
+	t.Run("count keeps the most recent captures", func(t *testing.T) {
+		resp := request(logical.UpdateOperation, "config/retention", map[string]interface{}{"debugCaptureMaxCount": 2})
+		assert.Equal(t, 2, resp.Data["debugCaptureMaxCount"])
+
+		resp = request(logical.UpdateOperation, "maintenance/prune", nil)
+		assert.Equal(t, 3, resp.Data["debugCaptures"])
+		assert.Equal(t, 2, countCaptures())
+	})
+
+	t.Run("periodic applies the age", func(t *testing.T) {
+		request(logical.UpdateOperation, "config/retention",
+			map[string]interface{}{"debugCaptureMaxAge": "5m", "debugCaptureMaxCount": 10})
+		putCapture("alice", 10*time.Minute)
+		require.NoError(t, b.periodic(ctx, &logical.Request{Storage: s}))
+		assert.Equal(t, 2, countCaptures())
+	})
+
+	t.Run("invalid retention", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/retention",
+			Storage:   s,
+			Data:      map[string]interface{}{"debugCaptureMaxCount": 0},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidRetention.Error())
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/retention",
+			Storage:   s,
+			Data:      map[string]interface{}{"receiptMaxCount": -1},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrNegativeRetention.Error())
+	})
+
+	t.Run("delete restores the defaults", func(t *testing.T) {
+		request(logical.DeleteOperation, "config/retention", nil)
+		resp := request(logical.ReadOperation, "config/retention", nil)
+		assert.Equal(t, helpers.DefaultDebugCaptureMaxCount, resp.Data["debugCaptureMaxCount"])
+		assert.Equal(t, fmt.Sprint(int64(helpers.DefaultDebugCaptureMaxAge.Seconds())),
+			fmt.Sprint(resp.Data["debugCaptureMaxAge"]))
+	})
+}
+
+func TestBackend_ApplyRetention_Classes(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	now := time.Now().UTC()
+
+	prune := func(dryRun bool) map[string]interface{} {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "maintenance/prune", Storage: s,
+			Data: map[string]interface{}{"dryRun": dryRun},
+		})
+		require.NoError(t, err)
+		return resp.Data
+	}
+	count := func(prefix string) int {
+		ids, err := s.List(ctx, prefix)
+		require.NoError(t, err)
+		return len(ids)
+	}
+	setRetention := func(data map[string]interface{}) {
+		t.Helper()
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "config/retention", Storage: s, Data: data,
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("receipts", func(t *testing.T) {
+		for i, age := range []time.Duration{72 * time.Hour, 2 * time.Hour, time.Hour, time.Minute} {
+			require.NoError(t, helpers.PutReceipt(ctx, s, &helpers.Receipt{
+				ID: helpers.NewUUID(), Sequence: uint64(i + 1), CreatedAt: now.Add(-age),
+			}))
+		}
+		require.NoError(t, s.Put(ctx, &logical.StorageEntry{Key: config.ReceiptSequenceStorageKey, Value: []byte("4")}))
+		assert.Equal(t, 0, prune(true)["receipts"], "receipts are kept by default")
+
+		setRetention(map[string]interface{}{"receiptMaxAge": "48h", "receiptMaxCount": 2})
+		assert.Equal(t, 2, prune(true)["receipts"])
+		assert.Equal(t, 5, count(config.ReceiptsStoragePath))
+		assert.Equal(t, 2, prune(false)["receipts"])
+		// the two most recent receipts and the sequence are kept
+		assert.Equal(t, 3, count(config.ReceiptsStoragePath))
+		kept, err := receiptsSince(ctx, s, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, kept, 2)
+		assert.EqualValues(t, 3, kept[0].Sequence)
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		for _, job := range []*helpers.Job{
+			{ID: "a", Status: helpers.JobComplete, CompletedAt: now.Add(-48 * time.Hour)},
+			{ID: "b", Status: helpers.JobFailed, CompletedAt: now.Add(-2 * time.Hour)},
+			{ID: "c", Status: helpers.JobComplete, CompletedAt: now.Add(-time.Hour)},
+			{ID: "d", Status: helpers.JobRunning, CreatedAt: now.Add(-72 * time.Hour)},
+		} {
+			require.NoError(t, helpers.PutJob(ctx, s, job))
+		}
+		assert.Equal(t, 1, prune(true)["jobs"], "finished jobs are kept a day by default")
+		assert.Equal(t, 4, count(config.JobsStoragePath))
+
+		setRetention(map[string]interface{}{"jobMaxCount": 1})
+		assert.Equal(t, 2, prune(false)["jobs"])
+		ids, err := s.List(ctx, config.JobsStoragePath)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"c", "d"}, ids)
+	})
+
+	t.Run("journal deletions", func(t *testing.T) {
+		journal := storage.NewJournal(s, s, config.StorageBasePath, config.ReplicationStoragePath, &b.journalMu)
+		for _, name := range []string{"alice", "bob", "carol"} {
+			require.NoError(t, journal.Put(ctx, &logical.StorageEntry{Key: config.StorageBasePath + name,
+				Value: []byte("{}")}))
+		}
+		require.NoError(t, journal.Delete(ctx, config.StorageBasePath+"alice"))
+		require.NoError(t, journal.Delete(ctx, config.StorageBasePath+"bob"))
+		assert.Equal(t, 0, prune(true)["journal"], "the journal is kept by default")
+
+		setRetention(map[string]interface{}{"journalMaxCount": 1})
+		assert.Equal(t, 1, prune(true)["journal"])
+		assert.Equal(t, 1, prune(false)["journal"])
+		changes, err := storage.JournalChanges(ctx, s, config.ReplicationStoragePath, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		// the live record and the last deletion are kept
+		assert.Equal(t, "carol", changes[0].Name)
+		assert.Equal(t, "bob", changes[1].Name)
+		assert.True(t, changes[1].Deleted)
+
+		// the version of a pruned deletion is kept, so the record is journaled past it
+		require.NoError(t, journal.Put(ctx, &logical.StorageEntry{Key: config.StorageBasePath + "alice",
+			Value: []byte("{}")}))
+		last, err := storage.JournalVersion(ctx, s, config.ReplicationStoragePath, "alice")
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, last.Version)
+	})
+
+	t.Run("periodic applies every class", func(t *testing.T) {
+		setRetention(map[string]interface{}{"receiptMaxCount": 1, "jobMaxAge": "30m"})
+		require.NoError(t, b.periodic(ctx, &logical.Request{Storage: s}))
+		assert.Equal(t, 2, count(config.ReceiptsStoragePath))
+		ids, err := s.List(ctx, config.JobsStoragePath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d"}, ids)
+	})
+}
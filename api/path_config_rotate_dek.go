@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// dekRotationBatchSize is the number of user records re-encrypted by each periodic run of a rotation
+const dekRotationBatchSize = 100
+
+// pathRotateDEK corresponds to UPDATE config/rotate-dek. It generates a new data encryption key for
+// the encrypted user records; new writes use it at once, and the periodic function re-encrypts the existing
+// records in batches. The previous key stays readable until every record has been re-encrypted; after
+// a failed rotation, the same keys are used again instead.
+func (b *Backend) pathRotateDEK(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_rotate_dek"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.dekMu.Lock()
+	defer b.dekMu.Unlock()
+
+	rotation, err := helpers.GetDEKRotation(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get dek rotation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if rotation != nil && rotation.State == helpers.DEKRotationRunning {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrDEKRotationInProgress.Error())
+	}
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !storageConfig.Encrypted() {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrNoEncryptionKey.Error())
+	}
+
+	// a failed rotation is retried with its keys, its failed records may still need the previous one
+	if len(storageConfig.PreviousEncryptionKey) == 0 {
+		key := make([]byte, storage.KeyLength)
+		if _, err := rand.Read(key); err != nil {
+			backendLogger.Error("generate encryption key", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		storageConfig.PreviousEncryptionKey, storageConfig.EncryptionKey = storageConfig.EncryptionKey, key
+	}
+
+	now := time.Now().UTC()
+	rotation = &helpers.DEKRotation{
+		State:     helpers.DEKRotationRunning,
+		KeyID:     storage.KeyID(storageConfig.EncryptionKey),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if err := helpers.PutDEKRotation(ctx, req.Storage, rotation); err != nil {
+		backendLogger.Error("put dek rotation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	// the store is reopened with both keys
+	if err := b.putStorageConfig(ctx, req.Storage, storageConfig); err != nil {
+		backendLogger.Error("put storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("dek rotation started", "keyId", rotation.KeyID)
+
+	return &logical.Response{
+		Data: dekRotationResponseData(rotation),
+	}, nil
+}
+
+// pathReadDEKRotation corresponds to READ config/rotate-dek/status
+func (b *Backend) pathReadDEKRotation(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_dek_rotation"))
+
+	rotation, err := helpers.GetDEKRotation(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get dek rotation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if rotation == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: dekRotationResponseData(rotation),
+	}, nil
+}
+
+func dekRotationResponseData(rotation *helpers.DEKRotation) map[string]interface{} {
+	failed := rotation.Failed
+	if failed == nil {
+		failed = []string{}
+	}
+	return map[string]interface{}{
+		"state":       rotation.State,
+		"keyId":       rotation.KeyID,
+		"total":       rotation.Total,
+		"processed":   rotation.Processed,
+		"reencrypted": rotation.Reencrypted,
+		"failed":      failed,
+		"error":       rotation.Error,
+		"startedAt":   formatTime(rotation.StartedAt),
+		"updatedAt":   formatTime(rotation.UpdatedAt),
+		"completedAt": formatTime(rotation.CompletedAt),
+	}
+}
+
+// rotateDEK re-encrypts the next batch of user records of a running rotation, resuming after its
+// cursor. Once every record was processed the previous key is dropped, unless some could not be
+// re-encrypted: the rotation then fails and the key is kept so they stay readable.
+func (b *Backend) rotateDEK(ctx context.Context, s logical.Storage, now time.Time) error {
+	b.dekMu.Lock()
+	defer b.dekMu.Unlock()
+
+	rotation, err := helpers.GetDEKRotation(ctx, s)
+	if err != nil || rotation == nil || rotation.State != helpers.DEKRotationRunning {
+		return err
+	}
+
+	users, err := b.userStorage(ctx, s)
+	if err != nil {
+		return err
+	}
+	encrypted, ok := users.(*storage.Encrypted)
+	if !ok {
+		// the store was switched back to the Vault storage during the rotation
+		return b.finishDEKRotation(ctx, s, rotation, helpers.ErrNoEncryptionKey.Error(), now)
+	}
+
+	names, err := encrypted.List(ctx, config.StorageBasePath)
+	if err != nil {
+		return err
+	}
+	uuids := make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasSuffix(name, "/") {
+			uuids = append(uuids, name)
+		}
+	}
+	sort.Strings(uuids)
+
+	start := sort.SearchStrings(uuids, rotation.Cursor)
+	if start < len(uuids) && uuids[start] == rotation.Cursor {
+		start++
+	}
+	end := min(start+dekRotationBatchSize, len(uuids))
+	for i, uuid := range uuids[start:end] {
+		// the plugin is reloading: the cursor is checkpointed and the next instance resumes after it
+		if b.reloading() {
+			end = start + i
+			break
+		}
+		rewritten, err := encrypted.Reencrypt(ctx, config.StorageBasePath+uuid)
+		switch {
+		case err != nil:
+			b.logger.Error("re-encrypt user", "error", err, "uuid", uuid)
+			rotation.Failed = append(rotation.Failed, uuid)
+		case rewritten:
+			rotation.Reencrypted++
+		}
+		rotation.Processed++
+		rotation.Cursor = uuid
+	}
+	// records registered during the rotation are already written under the new key
+	rotation.Total = rotation.Processed + len(uuids) - end
+	rotation.UpdatedAt = now.UTC()
+
+	if end < len(uuids) {
+		b.logger.Info("dek rotation batch", "keyId", rotation.KeyID, "processed", rotation.Processed,
+			"total", rotation.Total)
+		return helpers.PutDEKRotation(ctx, s, rotation)
+	}
+	if len(rotation.Failed) > 0 {
+		return b.finishDEKRotation(ctx, s, rotation,
+			fmt.Sprintf("%d user records could not be re-encrypted", len(rotation.Failed)), now)
+	}
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if storage.KeyID(storageConfig.EncryptionKey) != rotation.KeyID {
+		return b.finishDEKRotation(ctx, s, rotation, "the encryption key changed during the rotation", now)
+	}
+	storageConfig.PreviousEncryptionKey = nil
+	if err := b.putStorageConfig(ctx, s, storageConfig); err != nil {
+		return err
+	}
+	return b.finishDEKRotation(ctx, s, rotation, "", now)
+}
+
+// finishDEKRotation records the end of rotation, failed when reason is set
+func (b *Backend) finishDEKRotation(ctx context.Context, s logical.Storage, rotation *helpers.DEKRotation,
+	reason string, now time.Time) error {
+	rotation.State, rotation.Error = helpers.DEKRotationCompleted, reason
+	if reason != "" {
+		rotation.State = helpers.DEKRotationFailed
+	}
+	rotation.UpdatedAt, rotation.CompletedAt = now.UTC(), now.UTC()
+	if err := helpers.PutDEKRotation(ctx, s, rotation); err != nil {
+		return err
+	}
+	b.logger.Info("dek rotation finished", "keyId", rotation.KeyID, "state", rotation.State,
+		"processed", rotation.Processed, "reencrypted", rotation.Reencrypted, "error", reason)
+	return nil
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_HandleRequest_RotateDEK_VaultStorage(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+
+	_, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/rotate-dek",
+		Storage:   s,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), helpers.ErrNoEncryptionKey.Error())
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/rotate-dek/status",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestBackend_RotateDEK(t *testing.T) {
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{7}, storage.KeyLength)
+
+	// setup holds the vault storage of a mount with users in an external store encrypted under oldKey
+	setup := func(t *testing.T, users int) (*Backend, logical.Storage, logical.Storage) {
+		t.Helper()
+		b := createSignTestBackend(t)
+		vault, raw := &logical.InmemStorage{}, &logical.InmemStorage{}
+		entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, &helpers.StorageConfig{
+			Type: storage.TypePostgres, ConnectionURL: "postgres://localhost/db", Table: "users",
+			EncryptionKey: oldKey,
+		})
+		require.NoError(t, err)
+		require.NoError(t, vault.Put(ctx, entry))
+
+		old, err := storage.NewEncrypted(raw, oldKey)
+		require.NoError(t, err)
+		for i := range users {
+			require.NoError(t, old.Put(ctx, &logical.StorageEntry{
+				Key:   fmt.Sprintf("%suser%03d", config.StorageBasePath, i),
+				Value: []byte(`{"uuid":"user"}`),
+			}))
+		}
+		return b, vault, raw
+	}
+
+	// start rotates the key and opens the external store as HandleRequest would
+	start := func(t *testing.T, b *Backend, vault, raw logical.Storage) *helpers.StorageConfig {
+		t.Helper()
+		_, err := b.pathRotateDEK(ctx, &logical.Request{Storage: vault}, &framework.FieldData{})
+		require.NoError(t, err)
+		storageConfig, err := helpers.GetStorageConfig(ctx, vault)
+		require.NoError(t, err)
+		users, err := storage.NewEncrypted(raw, storageConfig.EncryptionKey, storageConfig.PreviousEncryptionKey)
+		require.NoError(t, err)
+		b.userStore, b.userStoreLoaded = users, true
+		return storageConfig
+	}
+
+	t.Run("records are re-encrypted in batches", func(t *testing.T) {
+		b, vault, raw := setup(t, dekRotationBatchSize+20)
+		storageConfig := start(t, b, vault, raw)
+		assert.Equal(t, oldKey, storageConfig.PreviousEncryptionKey)
+		assert.NotEqual(t, oldKey, storageConfig.EncryptionKey)
+
+		_, err := b.pathRotateDEK(ctx, &logical.Request{Storage: vault}, &framework.FieldData{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrDEKRotationInProgress.Error())
+
+		require.NoError(t, b.rotateDEK(ctx, vault, time.Now()))
+		resp, err := b.pathReadDEKRotation(ctx, &logical.Request{Storage: vault}, &framework.FieldData{})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.DEKRotationRunning, resp.Data["state"])
+		assert.Equal(t, dekRotationBatchSize, resp.Data["processed"])
+		assert.Equal(t, dekRotationBatchSize+20, resp.Data["total"])
+
+		require.NoError(t, b.rotateDEK(ctx, vault, time.Now()))
+		resp, err = b.pathReadDEKRotation(ctx, &logical.Request{Storage: vault}, &framework.FieldData{})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.DEKRotationCompleted, resp.Data["state"])
+		assert.Equal(t, dekRotationBatchSize+20, resp.Data["processed"])
+		assert.Equal(t, dekRotationBatchSize+20, resp.Data["reencrypted"])
+		assert.Equal(t, storage.KeyID(storageConfig.EncryptionKey), resp.Data["keyId"])
+		assert.NotEmpty(t, resp.Data["completedAt"])
+
+		rotated, err := helpers.GetStorageConfig(ctx, vault)
+		require.NoError(t, err)
+		assert.Nil(t, rotated.PreviousEncryptionKey)
+		assert.False(t, b.userStoreLoaded, "the store is reopened without the previous key")
+
+		current, err := storage.NewEncrypted(raw, rotated.EncryptionKey)
+		require.NoError(t, err)
+		entry, err := current.Get(ctx, config.StorageBasePath+"user042")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"uuid":"user"}`, string(entry.Value))
+	})
+
+	t.Run("unreadable records fail the rotation and keep the previous key", func(t *testing.T) {
+		b, vault, raw := setup(t, 3)
+		other, err := storage.NewEncrypted(raw, bytes.Repeat([]byte{8}, storage.KeyLength))
+		require.NoError(t, err)
+		require.NoError(t, other.Put(ctx, &logical.StorageEntry{
+			Key: config.StorageBasePath + "user001", Value: []byte(`{}`),
+		}))
+		storageConfig := start(t, b, vault, raw)
+
+		require.NoError(t, b.rotateDEK(ctx, vault, time.Now()))
+		resp, err := b.pathReadDEKRotation(ctx, &logical.Request{Storage: vault}, &framework.FieldData{})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.DEKRotationFailed, resp.Data["state"])
+		assert.Equal(t, []string{"user001"}, resp.Data["failed"])
+		assert.Equal(t, 2, resp.Data["reencrypted"])
+
+		kept, err := helpers.GetStorageConfig(ctx, vault)
+		require.NoError(t, err)
+		assert.Equal(t, oldKey, kept.PreviousEncryptionKey)
+
+		t.Run("retry keeps the keys", func(t *testing.T) {
+			retried := start(t, b, vault, raw)
+			assert.Equal(t, storageConfig.EncryptionKey, retried.EncryptionKey)
+			assert.Equal(t, oldKey, retried.PreviousEncryptionKey)
+		})
+	})
+
+	t.Run("store switched back to the vault storage", func(t *testing.T) {
+		b, vault, raw := setup(t, 1)
+		start(t, b, vault, raw)
+		b.userStore = nil
+
+		require.NoError(t, b.rotateDEK(ctx, vault, time.Now()))
+		rotation, err := helpers.GetDEKRotation(ctx, vault)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.DEKRotationFailed, rotation.State)
+		assert.Equal(t, helpers.ErrNoEncryptionKey.Error(), rotation.Error)
+	})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/rpc"
+)
+
+// pathListRPC corresponds to LIST config/rpc.
+func (b *Backend) pathListRPC(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_rpc"))
+
+	coinTypes, err := req.Storage.List(ctx, config.RPCStoragePath)
+	if err != nil {
+		backendLogger.Error("list rpc endpoints", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(coinTypes), nil
+}
+
+// pathReadRPC corresponds to READ config/rpc/<coinType>.
+func (b *Backend) pathReadRPC(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_rpc"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	endpoint, err := helpers.GetRPCEndpoint(ctx, req.Storage, coinType)
+	if err != nil {
+		backendLogger.Error("get rpc endpoint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if endpoint == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: rpcResponseData(endpoint),
+	}, nil
+}
+
+// pathWriteRPC corresponds to UPDATE config/rpc/<coinType>.
+func (b *Backend) pathWriteRPC(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_rpc"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	endpoint := &helpers.RPCEndpoint{
+		CoinType:   coinType,
+		URL:        d.Get("url").(string),
+		MaxRetries: d.Get("maxRetries").(int),
+		Timeout:    time.Duration(d.Get("timeout").(int)) * time.Second,
+
+		MaxBlockhashAge:      d.Get("maxBlockhashAge").(int),
+		RejectStaleBlockhash: d.Get("rejectStaleBlockhash").(bool),
+	}
+	if err := rpc.ValidateURL(endpoint.URL); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if endpoint.MaxRetries < 0 || endpoint.Timeout <= 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidRPCEndpoint.Error())
+	}
+	blockhashChecked := endpoint.MaxBlockhashAge > 0 || endpoint.RejectStaleBlockhash
+	if endpoint.MaxBlockhashAge < 0 || endpoint.MaxBlockhashAge > helpers.MaxRecentBlockhashes ||
+		blockhashChecked && !solana.NewSolanaAdapter(b.logger).CanDo(coinType) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidBlockhashAge.Error())
+	}
+
+	if err := helpers.PutRPCEndpoint(ctx, req.Storage, endpoint); err != nil {
+		backendLogger.Error("put rpc endpoint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("rpc endpoint updated", "coinType", coinType, "host", rpc.Host(endpoint.URL))
+
+	return &logical.Response{
+		Data: rpcResponseData(endpoint),
+	}, nil
+}
+
+// pathDeleteRPC corresponds to DELETE config/rpc/<coinType>.
+func (b *Backend) pathDeleteRPC(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_rpc"))
+
+	coinType, err := configCoinType(d)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.DeleteRPCEndpoint(ctx, req.Storage, coinType); err != nil {
+		backendLogger.Error("delete rpc endpoint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("rpc endpoint deleted", "coinType", coinType)
+	return nil, nil
+}
+
+// configCoinType parses the coin type of the config/rpc, config/hooks and config/fees paths
+func configCoinType(d *framework.FieldData) (uint16, error) {
+	coinType, err := strconv.ParseUint(d.Get("coinType").(string), 10, 16)
+	if err != nil {
+		return 0, helpers.ErrUnsupportedCoinType
+	}
+	return uint16(coinType), nil
+}
+
+func rpcResponseData(endpoint *helpers.RPCEndpoint) map[string]interface{} {
+	return map[string]interface{}{
+		"coinType":   endpoint.CoinType,
+		"url":        endpoint.URL,
+		"host":       rpc.Host(endpoint.URL),
+		"maxRetries": endpoint.MaxRetries,
+		"timeout":    int(endpoint.Timeout.Seconds()),
+
+		"maxBlockhashAge":      endpoint.MaxBlockhashAge,
+		"rejectStaleBlockhash": endpoint.RejectStaleBlockhash,
+	}
+}
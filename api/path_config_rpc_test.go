@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/rpc"
+)
+
+// Helper function to create a proper framework.FieldData for config/rpc/<coinType> endpoint
+func createRPCFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"coinType":   {Type: framework.TypeString},
+			"url":        {Type: framework.TypeString},
+			"maxRetries": {Type: framework.TypeInt, Default: rpc.DefaultMaxRetries},
+			"timeout":    {Type: framework.TypeDurationSecond, Default: int(rpc.DefaultTimeout.Seconds())},
+
+			"maxBlockhashAge":      {Type: framework.TypeInt},
+			"rejectStaleBlockhash": {Type: framework.TypeBool},
+		},
+	}
+}
+
+func TestBackend_PathConfigRPC(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	b := createSignTestBackend(t)
+
+	t.Run("write returns the url and its host", func(t *testing.T) {
+		data := map[string]interface{}{"coinType": "60", "url": "https://mainnet.infura.io/v3/secret", "timeout": "5s"}
+		got, err := b.pathWriteRPC(ctx, &logical.Request{Storage: s, Data: data}, createRPCFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, "mainnet.infura.io", got.Data["host"])
+		assert.Equal(t, rpc.DefaultMaxRetries, got.Data["maxRetries"])
+		assert.Equal(t, 5, got.Data["timeout"])
+		assert.Equal(t, "https://mainnet.infura.io/v3/secret", got.Data["url"])
+
+		endpoint, err := helpers.GetRPCEndpoint(ctx, s, 60)
+		require.NoError(t, err)
+		assert.Equal(t, "https://mainnet.infura.io/v3/secret", endpoint.URL)
+		assert.Equal(t, 5*time.Second, endpoint.Timeout)
+	})
+
+	t.Run("read and list", func(t *testing.T) {
+		data := map[string]interface{}{"coinType": "60"}
+		got, err := b.pathReadRPC(ctx, &logical.Request{Storage: s}, createRPCFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, uint16(60), got.Data["coinType"])
+
+		list, err := b.pathListRPC(ctx, &logical.Request{Storage: s}, createRPCFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"60"}, list.Data["keys"])
+	})
+
+	t.Run("invalid endpoints", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{
+			{"coinType": "60", "url": "node:8545"},
+			{"coinType": "60", "url": "http://node:8545", "maxRetries": -1},
+			{"coinType": "70000", "url": "http://node:8545"},
+			{"coinType": "60", "url": "http://node:8545", "maxBlockhashAge": 100},
+			{"coinType": "501", "url": "http://node:8545", "maxBlockhashAge": 151},
+		} {
+			_, err := b.pathWriteRPC(ctx, &logical.Request{Storage: s, Data: data}, createRPCFieldData(data))
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("solana blockhash age", func(t *testing.T) {
+		data := map[string]interface{}{
+			"coinType": "501", "url": "http://node:8899", "maxBlockhashAge": 100, "rejectStaleBlockhash": true,
+		}
+		got, err := b.pathWriteRPC(ctx, &logical.Request{Storage: s, Data: data}, createRPCFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, 100, got.Data["maxBlockhashAge"])
+		assert.Equal(t, true, got.Data["rejectStaleBlockhash"])
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		data := map[string]interface{}{"coinType": "60"}
+		_, err := b.pathDeleteRPC(ctx, &logical.Request{Storage: s}, createRPCFieldData(data))
+		require.NoError(t, err)
+		got, err := b.pathReadRPC(ctx, &logical.Request{Storage: s}, createRPCFieldData(data))
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// pathReadStorage corresponds to READ config/storage. The encryption key is never returned.
+func (b *Backend) pathReadStorage(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_storage"))
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: storageResponseData(storageConfig),
+	}, nil
+}
+
+// pathWriteStorage corresponds to UPDATE config/storage. The external store is connected to before
+// the configuration is saved. Records are not copied between stores.
+func (b *Backend) pathWriteStorage(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_storage"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	storageConfig.Type = d.Get("type").(string)
+	switch storageConfig.Type {
+	case storage.TypeVault:
+		storageConfig.ConnectionURL, storageConfig.Table = "", ""
+	case storage.TypePostgres:
+		if v, ok := d.GetOk("connectionURL"); ok {
+			storageConfig.ConnectionURL = v.(string)
+		}
+		if storageConfig.ConnectionURL == "" {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, "connectionURL is required")
+		}
+		storageConfig.Table = storage.DefaultPostgresTable
+		if v, ok := d.GetOk("table"); ok {
+			storageConfig.Table = v.(string)
+		}
+		// the key is kept across updates, records written with it would be unreadable otherwise
+		if len(storageConfig.EncryptionKey) == 0 {
+			storageConfig.EncryptionKey = make([]byte, storage.KeyLength)
+			if _, err := rand.Read(storageConfig.EncryptionKey); err != nil {
+				backendLogger.Error("generate encryption key", "error", err)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+		}
+	default:
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidStorageType.Error())
+	}
+	if v, ok := d.GetOk("splitPassphrase"); ok {
+		storageConfig.SplitPassphrase = v.(bool)
+	}
+
+	// check the store can be used before switching to it
+	_, closer, err := openUserStore(ctx, storageConfig)
+	if err != nil {
+		backendLogger.Error("open user store", "error", err, "type", storageConfig.Type)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if closer != nil {
+		_ = closer.Close()
+	}
+
+	entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, storageConfig)
+	if err != nil {
+		backendLogger.Error("encode storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.resetUserStorage()
+	// records are not copied between stores, the cached ones may not exist in the new store
+	b.resetUserCache()
+	backendLogger.Info("storage updated", "type", storageConfig.Type, "table", storageConfig.Table,
+		"splitPassphrase", storageConfig.SplitPassphrase)
+
+	return &logical.Response{
+		Data: storageResponseData(storageConfig),
+	}, nil
+}
+
+// storageResponseData returns everything written to config/storage. The encryption key is generated
+// by the engine and never returned.
+func storageResponseData(storageConfig *helpers.StorageConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"type":            storageConfig.Type,
+		"connectionURL":   storageConfig.ConnectionURL,
+		"table":           storageConfig.Table,
+		"splitPassphrase": storageConfig.SplitPassphrase,
+	}
+}
+
+// routeUserStorage returns s with the user records routed to the configured store. Their split
+// passphrases stay in s, above the cache so it never holds them, and their writes are journaled
+// while config/replication is set.
+func (b *Backend) routeUserStorage(ctx context.Context, s logical.Storage) (logical.Storage, error) {
+	users, err := b.userStorage(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := b.userCache(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	routed := s
+	if users != nil {
+		routed = storage.NewRouter(s, config.StorageBasePath, users)
+	}
+	if cache != nil {
+		routed = storage.NewCached(routed, config.StorageBasePath, cache)
+	}
+	split := storage.NewSplit(routed, s, config.StorageBasePath, config.PassphraseStoragePath,
+		b.splitPassphrase())
+
+	journaled, err := b.replicationJournaled(ctx, s)
+	if err != nil || !journaled {
+		return split, err
+	}
+	return storage.NewJournal(split, s, config.StorageBasePath, config.ReplicationStoragePath, &b.journalMu), nil
+}
+
+// userStorage returns the external store of the user records, or nil when they are kept in clear in the Vault
+// storage. The store is opened on first use and kept until the configuration changes; records encrypted in the
+// Vault storage are read through an encryption of s.
+func (b *Backend) userStorage(ctx context.Context, s logical.Storage) (logical.Storage, error) {
+	b.userStoreMu.Lock()
+	defer b.userStoreMu.Unlock()
+
+	if !b.userStoreLoaded {
+		storageConfig, err := helpers.GetStorageConfig(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		users, closer, err := openUserStore(ctx, storageConfig)
+		if err != nil {
+			return nil, err
+		}
+		b.userStore, b.userStoreCloser, b.userStoreConfig, b.userStoreLoaded = users, closer, storageConfig, true
+	}
+
+	if b.userStore != nil || b.userStoreConfig == nil || b.userStoreConfig.VaultEncryption == "" {
+		return b.userStore, nil
+	}
+	encrypted, err := encryptedRecords(s, b.userStoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	if b.userStoreConfig.VaultEncryption == helpers.VaultEncryptionMigrating {
+		encrypted.AcceptPlaintext()
+	}
+	return encrypted, nil
+}
+
+// splitPassphrase reports whether the passphrases of the user records written are stored apart from them
+func (b *Backend) splitPassphrase() bool {
+	b.userStoreMu.Lock()
+	defer b.userStoreMu.Unlock()
+	return b.userStoreConfig != nil && b.userStoreConfig.SplitPassphrase
+}
+
+// encryptedRecords returns next encrypted under the keys of storageConfig
+func encryptedRecords(next logical.Storage, storageConfig *helpers.StorageConfig) (*storage.Encrypted, error) {
+	var previous [][]byte
+	if len(storageConfig.PreviousEncryptionKey) > 0 {
+		previous = append(previous, storageConfig.PreviousEncryptionKey)
+	}
+	return storage.NewEncrypted(next, storageConfig.EncryptionKey, previous...)
+}
+
+// resetUserStorage closes the external store so the next request reopens it from the configuration
+func (b *Backend) resetUserStorage() {
+	b.userStoreMu.Lock()
+	defer b.userStoreMu.Unlock()
+
+	if b.userStoreCloser != nil {
+		_ = b.userStoreCloser.Close()
+	}
+	b.userStore, b.userStoreCloser, b.userStoreConfig, b.userStoreLoaded = nil, nil, nil, false
+}
+
+// invalidate drops cached state when another node of the cluster changes it
+func (b *Backend) invalidate(_ context.Context, key string) {
+	switch {
+	case key == config.UserStoreStorageKey:
+		b.resetUserStorage()
+		b.resetUserCache()
+	case key == config.UserCacheStorageKey:
+		b.resetUserCache()
+	case key == config.AttestationStorageKey:
+		b.resetAttestationKey()
+	case key == config.KafkaStorageKey:
+		b.resetEventSink()
+	case key == config.TracingStorageKey:
+		b.resetTracing()
+	case key == config.ReplicationStorageKey:
+		b.resetReplication()
+	case strings.HasPrefix(key, config.StorageBasePath):
+		b.invalidateCachedUser(key)
+	}
+}
+
+// openUserStore opens the store described by storageConfig, returning nil for the Vault storage
+func openUserStore(ctx context.Context, storageConfig *helpers.StorageConfig) (logical.Storage, io.Closer, error) {
+	switch storageConfig.Type {
+	case storage.TypeVault:
+		return nil, nil, nil
+	case storage.TypePostgres:
+		postgres, err := storage.NewPostgres(ctx, storageConfig.ConnectionURL, storageConfig.Table)
+		if err != nil {
+			return nil, nil, err
+		}
+		encrypted, err := encryptedRecords(postgres, storageConfig)
+		if err != nil {
+			_ = postgres.Close()
+			return nil, nil, err
+		}
+		return encrypted, postgres, nil
+	default:
+		return nil, nil, helpers.ErrInvalidStorageType
+	}
+}
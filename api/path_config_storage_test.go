@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Helper function to create a proper framework.FieldData for config/storage endpoint
+func createStorageFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"type":            {Type: framework.TypeString, Default: storage.TypeVault},
+			"connectionURL":   {Type: framework.TypeString},
+			"table":           {Type: framework.TypeString},
+			"splitPassphrase": {Type: framework.TypeBool},
+		},
+	}
+}
+
+func TestBackend_PathStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults to the vault storage", func(t *testing.T) {
+		got, err := createSignTestBackend(t).pathReadStorage(ctx, &logical.Request{Storage: &logical.InmemStorage{}},
+			createStorageFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, storage.TypeVault, got.Data["type"])
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		data := map[string]interface{}{"type": "s3"}
+		_, err := createSignTestBackend(t).pathWriteStorage(ctx,
+			&logical.Request{Storage: &logical.InmemStorage{}, Data: data}, createStorageFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidStorageType.Error())
+	})
+
+	t.Run("postgres requires a connection url", func(t *testing.T) {
+		data := map[string]interface{}{"type": storage.TypePostgres}
+		_, err := createSignTestBackend(t).pathWriteStorage(ctx,
+			&logical.Request{Storage: &logical.InmemStorage{}, Data: data}, createStorageFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connectionURL is required")
+	})
+
+	t.Run("invalid table is rejected before saving", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{
+			"type": storage.TypePostgres, "connectionURL": "postgres://localhost/db", "table": "Users",
+		}
+		_, err := createSignTestBackend(t).pathWriteStorage(ctx, &logical.Request{Storage: s, Data: data},
+			createStorageFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), storage.ErrInvalidTable.Error())
+
+		entry, err := s.Get(ctx, config.UserStoreStorageKey)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+}
+
+func TestBackend_HandleRequest_UserStore(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	// an external store already opened from the configuration
+	users := &logical.InmemStorage{}
+	b.userStore, b.userStoreLoaded = users, true
+
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, users.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	vault := &logical.InmemStorage{}
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "user/" + signTestUUID,
+		Storage:   vault,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, signTestUUID, resp.Data["uuid"])
+
+	t.Run("passphrases are split from the records", func(t *testing.T) {
+		b.resetUserStorage()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "config/storage", Storage: vault,
+			Data: map[string]interface{}{"splitPassphrase": true},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["splitPassphrase"])
+
+		user, err := helpers.NewUser(helpers.NewUUID(), "split-user", signTestValidMnemonic, "split passphrase 42!", nil)
+		require.NoError(t, err)
+		routed, err := b.routeUserStorage(ctx, vault)
+		require.NoError(t, err)
+		require.NoError(t, routed.Put(ctx, createUserV2StorageEntry(t, user)))
+
+		stored, err := vault.Get(ctx, config.StorageBasePath+user.UUID)
+		require.NoError(t, err)
+		assert.NotContains(t, string(stored.Value), user.Passphrase)
+		passphrase, err := vault.Get(ctx, config.PassphraseStoragePath+user.UUID)
+		require.NoError(t, err)
+		require.NotNil(t, passphrase)
+
+		got, err := helpers.GetUser(ctx, &logical.Request{Storage: routed}, user.UUID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Passphrase, got.Passphrase)
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation, Path: "config/storage", Storage: vault,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["splitPassphrase"])
+	})
+
+	t.Run("reset reloads the configuration", func(t *testing.T) {
+		b.resetUserStorage()
+		got, err := b.userStorage(ctx, vault)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_PathDeleteConfig(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delete restores the default features", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		b := createSignTestBackend(t)
+		data := map[string]interface{}{"signDigestEnabled": true}
+		_, err := b.pathWriteFeatures(ctx, &logical.Request{Storage: s, Data: data}, createFeaturesFieldData(data))
+		require.NoError(t, err)
+
+		_, err = b.pathDeleteFeatures(ctx, &logical.Request{Storage: s}, createFeaturesFieldData(nil))
+		require.NoError(t, err)
+		got, err := b.pathReadFeatures(ctx, &logical.Request{Storage: s}, createFeaturesFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, false, got.Data["signDigestEnabled"])
+		assert.Equal(t, true, got.Data["exportEnabled"])
+	})
+
+	t.Run("delete restores the info level", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		b := createSignTestBackend(t)
+		b.logLevel = new(slog.LevelVar)
+		data := map[string]interface{}{"level": "error"}
+		_, err := b.pathWriteLogging(ctx, &logical.Request{Storage: s, Data: data}, createLoggingFieldData(data))
+		require.NoError(t, err)
+
+		_, err = b.pathDeleteLogging(ctx, &logical.Request{Storage: s}, createLoggingFieldData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, slog.LevelInfo, b.logLevel.Level())
+		entry, err := s.Get(ctx, config.LoggingStorageKey)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+
+	t.Run("delete of the storage keeps the encryption key", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		key := make([]byte, storage.KeyLength)
+		entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, helpers.StorageConfig{
+			Type: storage.TypePostgres, ConnectionURL: "postgres://localhost/db", Table: "dq_vault_users",
+			EncryptionKey: key,
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, entry))
+
+		b := createSignTestBackend(t)
+		_, err = b.pathDeleteStorage(ctx, &logical.Request{Storage: s}, createStorageFieldData(nil))
+		require.NoError(t, err)
+
+		storageConfig, err := helpers.GetStorageConfig(ctx, s)
+		require.NoError(t, err)
+		assert.Equal(t, storage.TypeVault, storageConfig.Type)
+		assert.Empty(t, storageConfig.ConnectionURL)
+		assert.Equal(t, key, storageConfig.EncryptionKey)
+	})
+}
+
+func TestBackend_PathReadImport(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	b := createSignTestBackend(t)
+
+	data := map[string]interface{}{"maxBatchCount": 50}
+	_, err := b.pathWriteQuotas(ctx, &logical.Request{Storage: s, Data: data}, createQuotasFieldData(data))
+	require.NoError(t, err)
+	for _, coinType := range []string{"501", "60"} {
+		data = map[string]interface{}{"coinType": coinType, "url": "https://node.example/" + coinType}
+		_, err = b.pathWriteRPC(ctx, &logical.Request{Storage: s, Data: data}, createRPCFieldData(data))
+		require.NoError(t, err)
+	}
+
+	got, err := b.pathReadImport(ctx, &logical.Request{Storage: s}, &framework.FieldData{})
+	require.NoError(t, err)
+	assert.Equal(t, 50, got.Data["quotas"].(map[string]interface{})["maxBatchCount"])
+	assert.Equal(t, config.Info, got.Data["logging"].(map[string]interface{})["level"])
+	assert.Equal(t, storage.TypeVault, got.Data["storage"].(map[string]interface{})["type"])
+	assert.Equal(t, false, got.Data["cache"].(map[string]interface{})["enabled"])
+	assert.Equal(t, true, got.Data["features"].(map[string]interface{})["exportEnabled"])
+	assert.Equal(t, 0, got.Data["passphrase"].(map[string]interface{})["minLength"])
+
+	endpoints := got.Data["rpc"].(map[string]interface{})
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "https://node.example/60", endpoints["60"].(map[string]interface{})["url"])
+
+	// the document is what each config path returns
+	quotas, err := b.pathReadQuotas(ctx, &logical.Request{Storage: s}, createQuotasFieldData(nil))
+	require.NoError(t, err)
+	assert.Equal(t, quotas.Data, got.Data["quotas"])
+
+	list, err := b.pathListRPC(ctx, &logical.Request{Storage: s}, createRPCFieldData(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"501", "60"}, list.Data["keys"])
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracingShutdownTimeout bounds the flush of the spans left when the collector changes
+const tracingShutdownTimeout = 5 * time.Second
+
+// pathReadTracing corresponds to READ config/tracing.
+func (b *Backend) pathReadTracing(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_tracing"))
+
+	tracingConfig, err := helpers.GetTracingConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get tracing config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if tracingConfig == nil {
+		if !tracing.EnvConfigured() {
+			return nil, nil
+		}
+		// the collector of the OTEL_EXPORTER_OTLP_* environment of the plugin
+		tracingConfig = &helpers.TracingConfig{SampleRatio: 1}
+	}
+
+	return &logical.Response{
+		Data: tracingResponseData(tracingConfig),
+	}, nil
+}
+
+// pathWriteTracing corresponds to UPDATE config/tracing. The collector replaces the stored one and
+// the environment of the plugin.
+func (b *Backend) pathWriteTracing(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_tracing"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	tracingConfig := &helpers.TracingConfig{
+		Endpoint:    d.Get("endpoint").(string),
+		Headers:     d.Get("headers").(map[string]string),
+		SampleRatio: d.Get("sampleRatio").(float64),
+		ServiceName: d.Get("serviceName").(string),
+	}
+	if tracingConfig.Endpoint == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, tracing.ErrInvalidEndpoint.Error())
+	}
+	if err := tracingConfig.Options().Validate(); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutTracingConfig(ctx, req.Storage, tracingConfig); err != nil {
+		backendLogger.Error("put tracing config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	b.resetTracing()
+
+	backendLogger.Info("tracing collector updated", "endpoint", tracingConfig.Endpoint,
+		"sampleRatio", tracingConfig.SampleRatio, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: tracingResponseData(tracingConfig),
+	}, nil
+}
+
+// pathDeleteTracing corresponds to DELETE config/tracing. The spans are exported to the collector
+// of the environment of the plugin, when it has one.
+func (b *Backend) pathDeleteTracing(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	resp, err := b.deleteConfig(ctx, req, "path_delete_tracing", config.TracingStorageKey)
+	if err == nil {
+		b.resetTracing()
+	}
+	return resp, err
+}
+
+// tracingResponseData reports the collector with the names of its headers only
+func tracingResponseData(tracingConfig *helpers.TracingConfig) map[string]interface{} {
+	headers := make([]string, 0, len(tracingConfig.Headers))
+	for name := range tracingConfig.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	serviceName := tracingConfig.ServiceName
+	if serviceName == "" {
+		serviceName = tracing.DefaultServiceName
+	}
+	return map[string]interface{}{
+		"endpoint":    tracingConfig.Endpoint,
+		"headerNames": headers,
+		"sampleRatio": tracingConfig.SampleRatio,
+		"serviceName": serviceName,
+		"fromEnv":     tracingConfig.Endpoint == "",
+	}
+}
+
+// tracerProvider returns the provider the spans of the requests are started with: the collector of
+// config/tracing, else the one of the environment, else none. It is opened on first use and kept
+// until the configuration changes.
+func (b *Backend) tracerProvider(ctx context.Context, s logical.Storage) trace.TracerProvider {
+	b.tracingMu.Lock()
+	defer b.tracingMu.Unlock()
+
+	if !b.tracingLoaded {
+		tracingConfig, err := helpers.GetTracingConfig(ctx, s)
+		if err != nil {
+			b.logger.Error("get tracing config", "error", err)
+			return noop.NewTracerProvider()
+		}
+		if tracingConfig == nil && tracing.EnvConfigured() {
+			tracingConfig = &helpers.TracingConfig{SampleRatio: 1}
+		}
+		if tracingConfig != nil {
+			provider, err := b.newTracerProvider(ctx, tracingConfig.Options())
+			if err != nil {
+				b.logger.Error("open tracing collector", "error", err)
+			}
+			b.tracingProvider = provider
+		}
+		b.tracingLoaded = true
+	}
+
+	if b.tracingProvider == nil {
+		return noop.NewTracerProvider()
+	}
+	return b.tracingProvider
+}
+
+// resetTracing flushes the spans left to the collector and drops it, the next request reopens it
+// from the configuration
+func (b *Backend) resetTracing() {
+	b.tracingMu.Lock()
+	provider := b.tracingProvider
+	b.tracingProvider, b.tracingLoaded = nil, false
+	b.tracingMu.Unlock()
+
+	if provider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			b.logger.Error("shutdown tracing collector", "error", err)
+		}
+	}
+}
+
+// startRequestSpan starts the server span of req, a child of the traceparent passed through by
+// Vault. The span is named by the path pattern, the request path holds the UUIDs.
+func (b *Backend) startRequestSpan(ctx context.Context, req *logical.Request) (context.Context, trace.Span) {
+	provider := trace.TracerProvider(noop.NewTracerProvider())
+	if req.Storage != nil {
+		provider = b.tracerProvider(ctx, req.Storage)
+	}
+
+	name := string(req.Operation) + " unknown"
+	if route := b.Route(req.Path); route != nil {
+		name = string(req.Operation) + " " + strings.TrimSuffix(strings.TrimPrefix(route.Pattern, "^"), "$")
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("vault.operation", string(req.Operation)),
+		attribute.String("vault.path", req.Path),
+		attribute.String("vault.mount_point", req.MountPoint),
+		attribute.String("vault.entity_id", req.EntityID),
+	}
+	if uuid, ok := req.Data["uuid"].(string); ok {
+		attributes = append(attributes, attribute.String("dq_vault.uuid", uuid))
+	}
+	return provider.Tracer(tracing.TracerName).Start(tracing.Extract(ctx, req.Headers), name,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// endRequestSpan ends the span of a request with its status code
+func endRequestSpan(span trace.Span, resp *logical.Response, err error) {
+	var coded logical.HTTPCodedError
+	switch {
+	case errors.As(err, &coded):
+		span.SetAttributes(attribute.Int("http.response.status_code", coded.Code()))
+	case err == nil && resp != nil && resp.IsError():
+		span.SetStatus(codes.Error, resp.Error().Error())
+	}
+	tracing.End(span, err)
+}
+
+// userSeed derives the seed of user, PBKDF2 makes it the slowest stage of most requests
+func userSeed(ctx context.Context, user *helpers.User) ([]byte, error) {
+	_, span := tracing.Start(ctx, "derive_seed")
+	seed, err := user.Seed()
+	tracing.End(span, err)
+	return seed, err
+}
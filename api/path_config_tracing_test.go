@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/payment-system/dq-vault/lib/slip44"
+	"github.com/payment-system/dq-vault/lib/tracing"
+)
+
+func TestBackend_HandleRequest_Tracing(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	var opened []tracing.Options
+	b.newTracerProvider = func(_ context.Context, options tracing.Options) (*sdktrace.TracerProvider, error) {
+		opened = append(opened, options)
+		return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), nil
+	}
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+
+	t.Run("the collector is configured", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "config/tracing", nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+
+		_, err = request(logical.UpdateOperation, "config/tracing", map[string]interface{}{"endpoint": "collector:4318"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(logical.HTTPCodedError).Code())
+
+		resp, err = request(logical.UpdateOperation, "config/tracing", map[string]interface{}{
+			"endpoint":    "https://collector:4318/v1/traces",
+			"headers":     map[string]interface{}{"Authorization": "Bearer secret"},
+			"sampleRatio": 0.5,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Authorization"}, resp.Data["headerNames"])
+		assert.Equal(t, 0.5, resp.Data["sampleRatio"])
+		assert.Equal(t, tracing.DefaultServiceName, resp.Data["serviceName"])
+		assert.NotContains(t, resp.Data, "headers")
+	})
+
+	t.Run("sign records the spans of its stages", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign", Storage: s,
+			Headers: map[string][]string{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			Data: map[string]interface{}{"uuid": signTestUUID, "path": signTestDerivationPath,
+				"coinType": int(slip44.Ether), "payload": signTestPayload},
+		})
+		require.NoError(t, err)
+		require.Len(t, opened, 1)
+		assert.Equal(t, "Bearer secret", opened[0].Headers["Authorization"])
+
+		names := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			names[span.Name()] = span
+		}
+		server, ok := names["update sign"]
+		require.True(t, ok, "spans: %v", names)
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		for _, stage := range []string{"sign.validate", "sign.load_user", "derive_seed", "adapter.derive_address",
+			"sign.check_fee_bounds", "sign.check_address_book", "adapter.create_signed_transaction", "storage.get"} {
+			span, ok := names[stage]
+			if assert.True(t, ok, stage) {
+				assert.Equal(t, server.SpanContext().TraceID(), span.SpanContext().TraceID(), stage)
+			}
+		}
+	})
+
+	t.Run("deleting the config drops the collector", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "config/tracing", nil)
+		require.NoError(t, err)
+
+		ended := len(recorder.Ended())
+		_, err = request(logical.ReadOperation, "config/tracing", nil)
+		require.NoError(t, err)
+		assert.Len(t, recorder.Ended(), ended)
+	})
+}
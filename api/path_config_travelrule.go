@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/travelrule"
+	"github.com/payment-system/dq-vault/lib/webhook"
+)
+
+// travelRuleForwardTimeout bounds the post of a record to the sink, the signature waits for it
+const travelRuleForwardTimeout = 5 * time.Second
+
+// pathReadTravelRule corresponds to READ config/travelrule.
+func (b *Backend) pathReadTravelRule(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_travelrule"))
+
+	travelRule, err := helpers.GetTravelRuleConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get travel rule config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if travelRule == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: travelRuleResponseData(travelRule),
+	}, nil
+}
+
+// pathWriteTravelRule corresponds to UPDATE config/travelrule.
+func (b *Backend) pathWriteTravelRule(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_travelrule"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	travelRule := &helpers.TravelRuleConfig{
+		SinkURL:       d.Get("sinkUrl").(string),
+		SigningSecret: d.Get("signingSecret").(string),
+		Format:        d.Get("format").(string),
+		Template:      d.Get("template").(string),
+	}
+	if err := rpc.ValidateURL(travelRule.SinkURL); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if _, err := webhook.NewTransformer(travelRule.Format, travelRule.Template); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if err := helpers.PutTravelRuleConfig(ctx, req.Storage, travelRule); err != nil {
+		backendLogger.Error("put travel rule config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("travel rule sink updated", "host", rpc.Host(travelRule.SinkURL), "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: travelRuleResponseData(travelRule),
+	}, nil
+}
+
+// pathDeleteTravelRule corresponds to DELETE config/travelrule.
+func (b *Backend) pathDeleteTravelRule(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_travelrule"))
+
+	if err := helpers.DeleteTravelRuleConfig(ctx, req.Storage); err != nil {
+		backendLogger.Error("delete travel rule config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("travel rule sink deleted", "entity", req.EntityID)
+	return nil, nil
+}
+
+// travelRuleResponseData reports the sink without its signing secret, and only the host of its
+// URL, which may hold credentials
+func travelRuleResponseData(travelRule *helpers.TravelRuleConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"sinkHost":         rpc.Host(travelRule.SinkURL),
+		"signingSecretSet": travelRule.SigningSecret != "",
+		"format":           webhookFormat(travelRule.Format),
+		"template":         travelRule.Template,
+	}
+}
+
+// withTravelRule validates the travelRule data of sign requests and attaches it to their
+// signature: the response returns it with its hash, so the Vault audit log records both, and it is
+// forwarded to the sink of config/travelrule with the signature. The plugin logs only record the
+// hash. Requests held for approval are returned as they are, the data is attached once signed.
+func (b *Backend) withTravelRule(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "travel_rule"))
+
+		value, ok := d.GetOk("travelRule")
+		if !ok || len(value.(map[string]interface{})) == 0 {
+			return op(ctx, req, d)
+		}
+		record, err := travelrule.Parse(value.(map[string]interface{}))
+		if err != nil {
+			backendLogger.Error("parse travel rule", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+
+		resp, err := op(ctx, req, d)
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		signature, ok := resp.Data["signature"].(string)
+		if !ok {
+			return resp, nil
+		}
+
+		event := travelrule.Event{
+			Record:    record,
+			Hash:      record.Hash(),
+			UUID:      d.Get("uuid").(string),
+			CoinType:  uint16(d.Get("coinType").(int)),
+			Signature: signature,
+			SignedAt:  time.Now().UTC(),
+		}
+		event.Address, _ = resp.Data["address"].(string)
+		backendLogger.Info("travel rule attached", "travelRuleHash", event.Hash, "uuid", event.UUID,
+			"coinType", event.CoinType, "address", event.Address, "entity", req.EntityID)
+		resp.Data["travelRule"] = record
+		resp.Data["travelRuleHash"] = event.Hash
+
+		sink, err := helpers.GetTravelRuleConfig(ctx, req.Storage)
+		if err != nil {
+			backendLogger.Error("get travel rule config", "error", err)
+		}
+		if sink == nil {
+			return resp, nil
+		}
+		body, contentType, err := travelRuleBody(sink, event, webhookSource(req))
+		if err == nil {
+			forwardCtx, cancel := context.WithTimeout(ctx, travelRuleForwardTimeout)
+			err = travelrule.Forward(forwardCtx, &http.Client{Timeout: travelRuleForwardTimeout}, sink.SinkURL,
+				sink.SigningSecret, contentType, body)
+			cancel()
+		}
+		// the transfer is signed: a failed forward is reported, compliance replays it from the audit log
+		if err != nil {
+			backendLogger.Error("forward travel rule", "error", err, "travelRuleHash", event.Hash,
+				"host", rpc.Host(sink.SinkURL))
+			resp.AddWarning(helpers.Warning(helpers.WarningTravelRuleNotForwarded,
+				"travel rule data %s not forwarded to %s, replay it from the audit log", event.Hash, rpc.Host(sink.SinkURL)))
+		}
+		resp.Data["travelRuleForwarded"] = err == nil
+		return resp, nil
+	}
+}
+
+// travelRuleBody returns the body of event in the format of the sink
+func travelRuleBody(sink *helpers.TravelRuleConfig, event travelrule.Event, source string) ([]byte, string, error) {
+	native, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	transformer, err := webhook.NewTransformer(sink.Format, sink.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	return transformer.Transform(webhook.Event{
+		ID:      helpers.NewUUID(),
+		Source:  source,
+		Type:    travelrule.EventType,
+		Subject: event.UUID,
+		Time:    event.SignedAt,
+		Data:    event,
+	}, native)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/travelrule"
+	"github.com/payment-system/dq-vault/lib/webhook"
+)
+
+func TestBackend_HandleRequest_TravelRule(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	var (
+		mu     sync.Mutex
+		events []travelrule.Event
+		status = http.StatusOK
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event travelrule.Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		assert.NotEmpty(t, r.Header.Get(travelrule.SignatureHeader))
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+		w.WriteHeader(status)
+	}))
+	defer sink.Close()
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	travelRule := map[string]interface{}{
+		"originator": map[string]interface{}{
+			"naturalPerson": map[string]interface{}{"primaryIdentifier": "Lovelace", "secondaryIdentifier": "Ada"},
+		},
+		"beneficiary": map[string]interface{}{
+			"legalPerson":   map[string]interface{}{"legalName": "Example Exchange Ltd"},
+			"accountNumber": "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8",
+		},
+	}
+	sign := func(travelRule map[string]interface{}) (*logical.Response, error) {
+		return request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+			"travelRule": travelRule,
+		})
+	}
+
+	t.Run("invalid travel rule data is rejected", func(t *testing.T) {
+		_, err := sign(map[string]interface{}{"originator": map[string]interface{}{}})
+		require.ErrorContains(t, err, travelrule.ErrInvalidRecord.Error())
+	})
+
+	t.Run("without sink the data is returned", func(t *testing.T) {
+		resp, err := sign(travelRule)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Len(t, resp.Data["travelRuleHash"], 64)
+		assert.NotNil(t, resp.Data["travelRule"])
+		assert.NotContains(t, resp.Data, "travelRuleForwarded")
+	})
+
+	_, err := request(logical.UpdateOperation, "config/travelrule", map[string]interface{}{"sinkUrl": "ftp://sink"})
+	require.ErrorContains(t, err, rpc.ErrInvalidURL.Error())
+	resp, err := request(logical.UpdateOperation, "config/travelrule", map[string]interface{}{
+		"sinkUrl": sink.URL + "/records", "signingSecret": "sink-secret",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["signingSecretSet"])
+	assert.NotContains(t, resp.Data, "signingSecret")
+
+	t.Run("the data is forwarded with the signature", func(t *testing.T) {
+		resp, err := sign(travelRule)
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["travelRuleForwarded"])
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, events, 1)
+		assert.Equal(t, resp.Data["signature"], events[0].Signature)
+		assert.Equal(t, resp.Data["address"], events[0].Address)
+		assert.Equal(t, resp.Data["travelRuleHash"], events[0].Hash)
+		assert.Equal(t, "Example Exchange Ltd", events[0].Record.Beneficiary.LegalPerson.LegalName)
+	})
+
+	t.Run("a failed forward is reported", func(t *testing.T) {
+		mu.Lock()
+		status = http.StatusServiceUnavailable
+		mu.Unlock()
+		resp, err := sign(travelRule)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["signature"])
+		assert.Equal(t, false, resp.Data["travelRuleForwarded"])
+	})
+
+	t.Run("requests without data are not forwarded", func(t *testing.T) {
+		resp, err := sign(nil)
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "travelRuleHash")
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Len(t, events, 2)
+	})
+
+	_, err = request(logical.DeleteOperation, "config/travelrule", nil)
+	require.NoError(t, err)
+	resp, err = request(logical.ReadOperation, "config/travelrule", nil)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestBackend_HandleRequest_TravelRuleCloudEvents(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	bodies := make(chan []byte, 1)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, webhook.ContentTypeCloudEvents, r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer sink.Close()
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, MountPoint: "dq/",
+		})
+	}
+	_, err := request(logical.UpdateOperation, "config/travelrule", map[string]interface{}{
+		"sinkUrl": sink.URL, "format": "xml",
+	})
+	require.ErrorContains(t, err, webhook.ErrUnknownFormat.Error())
+	resp, err := request(logical.UpdateOperation, "config/travelrule", map[string]interface{}{
+		"sinkUrl": sink.URL, "format": webhook.FormatCloudEvents,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, webhook.FormatCloudEvents, resp.Data["format"])
+
+	resp, err = request(logical.UpdateOperation, "sign", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+		"travelRule": map[string]interface{}{
+			"originator":  map[string]interface{}{"legalPerson": map[string]interface{}{"legalName": "Payer Ltd"}},
+			"beneficiary": map[string]interface{}{"legalPerson": map[string]interface{}{"legalName": "Payee Ltd"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["travelRuleForwarded"])
+
+	var envelope struct {
+		SpecVersion string           `json:"specversion"`
+		Source      string           `json:"source"`
+		Type        string           `json:"type"`
+		Subject     string           `json:"subject"`
+		Data        travelrule.Event `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(<-bodies, &envelope))
+	assert.Equal(t, "1.0", envelope.SpecVersion)
+	assert.Equal(t, "/dq", envelope.Source)
+	assert.Equal(t, travelrule.EventType, envelope.Type)
+	assert.Equal(t, signTestUUID, envelope.Subject)
+	assert.Equal(t, resp.Data["travelRuleHash"], envelope.Data.Hash)
+	assert.Equal(t, resp.Data["signature"], envelope.Data.Signature)
+}
@@ -0,0 +1,370 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/escrow"
+	"github.com/payment-system/dq-vault/lib/fee"
+	"github.com/payment-system/dq-vault/lib/hooks"
+	"github.com/payment-system/dq-vault/lib/logging"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/tracing"
+	"github.com/payment-system/dq-vault/lib/webhook"
+)
+
+// configProblem -- a stored configuration, or the adapters, failing the startup validation
+type configProblem struct {
+	// Path is the config path to fix, "adapters" for the self-check of the adapters
+	Path string
+	Err  error
+}
+
+// configValidation -- the result of the last validation of the stored configuration
+type configValidation struct {
+	problems  []configProblem
+	checkedAt time.Time
+}
+
+// pathReadValidate corresponds to READ config/validate. It validates the stored configuration and
+// the adapters again, as done when the mount starts.
+func (b *Backend) pathReadValidate(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_validate"))
+
+	validation, err := b.validateConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("validate config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return &logical.Response{
+		Data: validationResponseData(validation),
+	}, nil
+}
+
+func validationResponseData(validation *configValidation) map[string]interface{} {
+	problems := make([]map[string]interface{}, 0, len(validation.problems))
+	for _, problem := range validation.problems {
+		problems = append(problems, map[string]interface{}{"path": problem.Path, "error": problem.Err.Error()})
+	}
+	return map[string]interface{}{
+		"healthy":   len(validation.problems) == 0,
+		"problems":  problems,
+		"checkedAt": formatTime(validation.checkedAt),
+	}
+}
+
+// validateConfig runs the self-check of the adapters and validates every stored configuration as
+// its write would, so a configuration stored by an older version, or edited in the storage, is
+// reported with the path to fix instead of failing the first request using it. The result is kept
+// for info. It only errors when the storage cannot be read.
+func (b *Backend) validateConfig(ctx context.Context, s logical.Storage) (*configValidation, error) {
+	var problems []configProblem
+	report := func(path string, err error) {
+		if err == nil {
+			return
+		}
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, err := range joined.Unwrap() {
+				problems = append(problems, configProblem{Path: path, Err: err})
+			}
+			return
+		}
+		problems = append(problems, configProblem{Path: path, Err: err})
+	}
+
+	inventory := adapter.GetInventory(b.logger)
+	report("adapters", inventory.SelfCheck())
+	report(config.LoggingStorageKey, validateLoggingConfig(ctx, s))
+	report(config.EscrowStorageKey, validateEscrowConfig(ctx, s))
+	report(config.TravelRuleStorageKey, validateTravelRuleConfig(ctx, s))
+	report(config.ApprovalsStorageKey, validateApprovalConfig(ctx, s))
+	report(config.KafkaStorageKey, validateKafkaConfig(ctx, s))
+	report(config.TracingStorageKey, validateTracingConfig(ctx, s))
+	report(config.ArchiveStorageKey, validateArchiveConfig(ctx, s))
+	report(config.DerivationPolicyStorageKey, validateDerivationPolicy(ctx, s))
+	report(config.AddressOverridesStorageKey, validateAddressOverridePolicy(ctx, s))
+	report(config.QuotasStorageKey, validateQuotas(ctx, s))
+	report(config.UserCacheStorageKey, validateCacheConfig(ctx, s))
+	report(config.RetentionStorageKey, validateRetentionConfig(ctx, s))
+	report(config.LockoutStorageKey, validateLockoutPolicy(ctx, s))
+	// the other configurations have no constraint beyond their encoding
+	for key, get := range map[string]func() error{
+		config.FeaturesStorageKey: func() error {
+			_, err := helpers.GetFeatures(ctx, &logical.Request{Storage: s})
+			return err
+		},
+		config.AttestationStorageKey:      func() error { _, err := helpers.GetAttestationConfig(ctx, s); return err },
+		config.PassphrasePolicyStorageKey: func() error { _, err := helpers.GetPassphrasePolicy(ctx, s); return err },
+		config.EntropyStorageKey:          func() error { _, err := helpers.GetEntropyConfig(ctx, s); return err },
+		config.DeprecationStorageKey:      func() error { _, err := helpers.GetDeprecationConfig(ctx, s); return err },
+		config.CanaryStorageKey:           func() error { _, err := helpers.GetCanaryConfig(ctx, s); return err },
+		config.AddressBookPolicyStorageKey: func() error {
+			_, err := helpers.GetAddressBookPolicy(ctx, s)
+			return err
+		},
+		config.ContractPolicyStorageKey: func() error { _, err := helpers.GetContractPolicy(ctx, s); return err },
+	} {
+		report(key, get())
+	}
+
+	assets, err := helpers.ListAssets(ctx, s)
+	report(config.AssetsStoragePath, err)
+	for _, asset := range assets {
+		report(fmt.Sprintf("%s%d/%s", config.AssetsStoragePath, asset.CoinType, asset.Symbol), helpers.ValidateAsset(asset))
+	}
+
+	for _, coinTypeConfig := range []struct {
+		path     string
+		validate func(coinType uint16) error
+	}{
+		{config.RPCStoragePath, func(coinType uint16) error { return validateRPCEndpoint(ctx, s, inventory, coinType) }},
+		{config.HooksStoragePath, func(coinType uint16) error { return validateHookChain(ctx, s, coinType) }},
+		{config.FeeBoundsStoragePath, func(coinType uint16) error { return validateFeeBounds(ctx, s, coinType) }},
+	} {
+		names, err := s.List(ctx, coinTypeConfig.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			coinType, err := strconv.ParseUint(name, 10, 16)
+			if err != nil {
+				report(coinTypeConfig.path+name, helpers.ErrUnsupportedCoinType)
+				continue
+			}
+			report(coinTypeConfig.path+name, coinTypeConfig.validate(uint16(coinType)))
+		}
+	}
+
+	slices.SortStableFunc(problems, func(a, b configProblem) int { return strings.Compare(a.Path, b.Path) })
+	validation := &configValidation{problems: problems, checkedAt: time.Now().UTC()}
+	b.validationMu.Lock()
+	b.validation = validation
+	b.validationMu.Unlock()
+	return validation, nil
+}
+
+// lastValidation returns the result of the last validation of the stored configuration, nil before the first
+func (b *Backend) lastValidation() *configValidation {
+	b.validationMu.Lock()
+	defer b.validationMu.Unlock()
+	return b.validation
+}
+
+// revalidateConfig validates the stored configuration again after the request wrote or deleted a
+// config path while the last validation found problems, so info stops reporting the fixed ones
+func (b *Backend) revalidateConfig(ctx context.Context, req *logical.Request) {
+	validation := b.lastValidation()
+	if validation == nil || len(validation.problems) == 0 || req.Storage == nil ||
+		!strings.HasPrefix(req.Path, config.ConfigStoragePath) || req.Operation == logical.ReadOperation ||
+		req.Operation == logical.ListOperation {
+		return
+	}
+	if _, err := b.validateConfig(ctx, req.Storage); err != nil {
+		b.logger.Error("validate config", "error", err)
+	}
+}
+
+func validateLoggingConfig(ctx context.Context, s logical.Storage) error {
+	loggingConfig, err := helpers.GetLoggingConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	_, err = logging.ParseLevel(loggingConfig.Level)
+	return err
+}
+
+func validateQuotas(ctx context.Context, s logical.Storage) error {
+	quotas, err := helpers.GetQuotas(ctx, s)
+	if err != nil {
+		return err
+	}
+	if quotas.MaxBatchCount <= 0 || quotas.MaxConcurrentRequests < 0 {
+		return helpers.ErrInvalidQuota
+	}
+	if quotas.MaxQueueDepth < 0 || quotas.MaxQueueWait < 0 {
+		return helpers.ErrInvalidQueue
+	}
+	return nil
+}
+
+func validateCacheConfig(ctx context.Context, s logical.Storage) error {
+	cacheConfig, err := helpers.GetCacheConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	if cacheConfig.MaxEntries <= 0 || cacheConfig.TTL <= 0 {
+		return helpers.ErrInvalidCacheConfig
+	}
+	return nil
+}
+
+func validateRetentionConfig(ctx context.Context, s logical.Storage) error {
+	retention, err := helpers.GetRetentionConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+	return retention.Validate()
+}
+
+func validateEscrowConfig(ctx context.Context, s logical.Storage) error {
+	escrowConfig, err := helpers.GetEscrowConfig(ctx, s)
+	if err != nil || !escrowConfig.Enabled {
+		return err
+	}
+	_, err = escrow.ParsePublicKey(escrowConfig.PublicKey)
+	return err
+}
+
+func validateTravelRuleConfig(ctx context.Context, s logical.Storage) error {
+	travelRule, err := helpers.GetTravelRuleConfig(ctx, s)
+	if err != nil || travelRule == nil {
+		return err
+	}
+	if err := rpc.ValidateURL(travelRule.SinkURL); err != nil {
+		return err
+	}
+	_, err = webhook.NewTransformer(travelRule.Format, travelRule.Template)
+	return err
+}
+
+func validateApprovalConfig(ctx context.Context, s logical.Storage) error {
+	approvals, err := helpers.GetApprovalConfig(ctx, s)
+	if err != nil || approvals == nil {
+		return err
+	}
+	if approvals.TTL <= 0 {
+		return helpers.ErrInvalidApprovalConfig
+	}
+	if approvals.Provider != approval.ProviderSlack && approvals.Provider != approval.ProviderTeams {
+		return approval.ErrUnknownProvider
+	}
+	if err := rpc.ValidateURL(approvals.WebhookURL); err != nil {
+		return err
+	}
+	if approvals.LinkURL != "" {
+		if err := rpc.ValidateURL(approvals.LinkURL); err != nil {
+			return err
+		}
+	}
+	_, err = webhook.NewTransformer(approvals.Format, approvals.Template)
+	return err
+}
+
+func validateKafkaConfig(ctx context.Context, s logical.Storage) error {
+	kafka, err := helpers.GetKafkaConfig(ctx, s)
+	if err != nil || kafka == nil {
+		return err
+	}
+	return kafka.Options().Validate()
+}
+
+func validateArchiveConfig(ctx context.Context, s logical.Storage) error {
+	archiveConfig, err := helpers.GetArchiveConfig(ctx, s)
+	if err != nil || archiveConfig == nil {
+		return err
+	}
+	return archiveConfig.Validate()
+}
+
+func validateTracingConfig(ctx context.Context, s logical.Storage) error {
+	tracingConfig, err := helpers.GetTracingConfig(ctx, s)
+	if err != nil || tracingConfig == nil {
+		return err
+	}
+	if tracingConfig.Endpoint == "" {
+		return tracing.ErrInvalidEndpoint
+	}
+	return tracingConfig.Options().Validate()
+}
+
+func validateDerivationPolicy(ctx context.Context, s logical.Storage) error {
+	policy, err := helpers.GetDerivationPolicy(ctx, s)
+	if err != nil {
+		return err
+	}
+	if policy.MaxDepth != 0 && policy.MaxDepth < helpers.AccountDepth {
+		return helpers.ErrInvalidMaxDepth
+	}
+	return nil
+}
+
+func validateAddressOverridePolicy(ctx context.Context, s logical.Storage) error {
+	policy, err := helpers.GetAddressOverridePolicy(ctx, s)
+	if err != nil {
+		return err
+	}
+	return policy.Validate()
+}
+
+func validateLockoutPolicy(ctx context.Context, s logical.Storage) error {
+	policy, err := helpers.GetLockoutPolicy(ctx, s)
+	if err != nil {
+		return err
+	}
+	return policy.Validate()
+}
+
+// validateRPCEndpoint validates the node of coinType, which must be handled by an adapter
+func validateRPCEndpoint(ctx context.Context, s logical.Storage, inventory *adapter.Inventory, coinType uint16) error {
+	endpoint, err := helpers.GetRPCEndpoint(ctx, s, coinType)
+	if err != nil || endpoint == nil {
+		return err
+	}
+	if _, err := inventory.CoinCapabilities(coinType); err != nil {
+		return err
+	}
+	if err := rpc.ValidateURL(endpoint.URL); err != nil {
+		return err
+	}
+	if endpoint.MaxRetries < 0 || endpoint.Timeout <= 0 {
+		return helpers.ErrInvalidRPCEndpoint
+	}
+	return nil
+}
+
+func validateHookChain(ctx context.Context, s logical.Storage, coinType uint16) error {
+	chain, err := helpers.GetHookChain(ctx, s, coinType)
+	if err != nil || chain == nil {
+		return err
+	}
+	return hooks.Validate(coinType, chain.Hooks)
+}
+
+func validateFeeBounds(ctx context.Context, s logical.Storage, coinType uint16) error {
+	bounds, err := helpers.GetFeeBounds(ctx, s, coinType)
+	if err != nil || bounds == nil {
+		return err
+	}
+	if fee.Unit(coinType) == "" {
+		return fee.ErrUnsupportedCoin
+	}
+	if bounds.Min < 0 || bounds.Max <= 0 || bounds.Min > bounds.Max {
+		return helpers.ErrInvalidFeeBounds
+	}
+	return nil
+}
+
+// errInvalidConfig formats the problems of validation as the error of the startup
+func errInvalidConfig(validation *configValidation) error {
+	errs := make([]error, 0, len(validation.problems))
+	for _, problem := range validation.problems {
+		errs = append(errs, fmt.Errorf("%s: %w", problem.Path, problem.Err))
+	}
+	return fmt.Errorf("%w: %w", helpers.ErrInvalidStoredConfig, errors.Join(errs...))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/hooks"
+	"github.com/payment-system/dq-vault/lib/rpc"
+)
+
+func TestBackend_HandleRequest_ValidateConfig(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(operation logical.Operation, path string) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s})
+	}
+	require.NoError(t, b.initialize(ctx, &logical.InitializationRequest{Storage: s}))
+
+	resp, err := request(logical.ReadOperation, "config/validate")
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["healthy"])
+	assert.Empty(t, resp.Data["problems"])
+
+	// stored by an older version, or edited in the storage, past the checks of their writes
+	require.NoError(t, helpers.PutRPCEndpoint(ctx, s, &helpers.RPCEndpoint{
+		CoinType: 60, URL: "localhost:8545", Timeout: time.Second,
+	}))
+	require.NoError(t, helpers.PutRPCEndpoint(ctx, s, &helpers.RPCEndpoint{
+		CoinType: 5, URL: "https://node.example", Timeout: time.Second,
+	}))
+	require.NoError(t, helpers.PutHookChain(ctx, s, &helpers.HookChain{CoinType: 60, Hooks: []string{"unknown"}}))
+
+	t.Run("the mount fails to initialize with the paths to fix", func(t *testing.T) {
+		err := b.initialize(ctx, &logical.InitializationRequest{Storage: s})
+		require.ErrorIs(t, err, helpers.ErrInvalidStoredConfig)
+		require.ErrorIs(t, err, rpc.ErrInvalidURL)
+		require.ErrorIs(t, err, hooks.ErrUnknownHook)
+		require.ErrorContains(t, err, "config/rpc/5")
+
+		resp, err := request(logical.ReadOperation, "config/validate")
+		require.NoError(t, err)
+		assert.Equal(t, false, resp.Data["healthy"])
+		var paths []string
+		for _, problem := range resp.Data["problems"].([]map[string]interface{}) {
+			paths = append(paths, problem["path"].(string))
+		}
+		assert.Equal(t, []string{"config/hooks/60", "config/rpc/5", "config/rpc/60"}, paths)
+	})
+
+	t.Run("fixing the config clears the problems of info", func(t *testing.T) {
+		for _, path := range []string{"config/rpc/5", "config/rpc/60"} {
+			_, err := request(logical.DeleteOperation, path)
+			require.NoError(t, err)
+		}
+		resp, err := request(logical.ReadOperation, "info")
+		require.NoError(t, err)
+		assert.Len(t, resp.Data["validation"].(map[string]interface{})["problems"], 1)
+
+		_, err = request(logical.DeleteOperation, "config/hooks/60")
+		require.NoError(t, err)
+		resp, err = request(logical.ReadOperation, "info")
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["validation"].(map[string]interface{})["healthy"])
+		require.NoError(t, b.initialize(ctx, &logical.InitializationRequest{Storage: s}))
+	})
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+const (
+	// defaultDebugCaptureTTL is how long a debug session lasts when no ttl is given
+	defaultDebugCaptureTTL = time.Hour
+	// maxDebugCaptureTTL bounds debug sessions so they cannot be left enabled by mistake
+	maxDebugCaptureTTL = 24 * time.Hour
+	// debugCaptureRetention is how long captures are kept once their session expired
+	debugCaptureRetention = 24 * time.Hour
+	// maxDebugCaptures is the number of requests recorded per session
+	maxDebugCaptures = 100
+)
+
+// pathReadDebugSession corresponds to READ config/debug/<uuid>.
+func (b *Backend) pathReadDebugSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_debug_session"))
+
+	uuid := d.Get("uuid").(string)
+	session, err := helpers.GetDebugSession(ctx, req.Storage, uuid)
+	if err != nil {
+		backendLogger.Error("get debug session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if session == nil {
+		session = &helpers.DebugSession{UUID: uuid}
+	}
+
+	return &logical.Response{
+		Data: debugSessionResponseData(session),
+	}, nil
+}
+
+// pathWriteDebugSession corresponds to UPDATE config/debug/<uuid>. It starts a new debug session,
+// replacing a running one, during which the requests of the user are captured.
+func (b *Backend) pathWriteDebugSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_debug_session"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	if !helpers.UUIDExists(ctx, req, uuid) {
+		backendLogger.Error("uuid does not exist", "uuid", uuid)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, helpers.ErrUUIDDoesNotExist)
+	}
+
+	ttl := defaultDebugCaptureTTL
+	if v, ok := d.GetOk("ttl"); ok {
+		ttl = time.Duration(v.(int)) * time.Second
+	}
+	if ttl <= 0 || ttl > maxDebugCaptureTTL {
+		backendLogger.Error("invalid ttl", "ttl", ttl)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidDebugTTL.Error())
+	}
+
+	// captures of a previous session are dropped so a listing only shows the current one
+	if err := helpers.DeleteDebugSession(ctx, req.Storage, uuid); err != nil {
+		backendLogger.Error("delete debug session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	now := time.Now().UTC()
+	session := &helpers.DebugSession{UUID: uuid, EnabledAt: now, ExpiresAt: now.Add(ttl)}
+	if err := helpers.PutDebugSession(ctx, req.Storage, session); err != nil {
+		backendLogger.Error("put debug session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("debug session enabled", "uuid", uuid, "expiresAt", session.ExpiresAt)
+
+	return &logical.Response{
+		Data: debugSessionResponseData(session),
+	}, nil
+}
+
+// pathDeleteDebugSession corresponds to DELETE config/debug/<uuid>. It ends the session and removes its captures.
+func (b *Backend) pathDeleteDebugSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_debug_session"))
+
+	uuid := d.Get("uuid").(string)
+	if err := helpers.DeleteDebugSession(ctx, req.Storage, uuid); err != nil {
+		backendLogger.Error("delete debug session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("debug session disabled", "uuid", uuid)
+
+	return nil, nil
+}
+
+// pathReadDebugCaptures corresponds to READ debug/<uuid>. Captures are listed oldest first.
+func (b *Backend) pathReadDebugCaptures(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_debug_captures"))
+
+	uuid := d.Get("uuid").(string)
+	prefix := config.DebugStoragePath + uuid + "/"
+	ids, err := req.Storage.List(ctx, prefix)
+	if err != nil {
+		backendLogger.Error("list debug captures", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	captures := make([]*helpers.DebugCapture, 0, len(ids))
+	for _, id := range ids {
+		entry, err := req.Storage.Get(ctx, prefix+id)
+		if err != nil {
+			backendLogger.Error("get debug capture", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if entry == nil {
+			continue
+		}
+		var capture helpers.DebugCapture
+		if err := entry.DecodeJSON(&capture); err != nil {
+			backendLogger.Error("decode debug capture", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		captures = append(captures, &capture)
+	}
+	sort.SliceStable(captures, func(i, j int) bool {
+		return captures[i].Time.Before(captures[j].Time)
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"uuid":     uuid,
+			"captures": captures,
+		},
+	}, nil
+}
+
+func debugSessionResponseData(session *helpers.DebugSession) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":        session.UUID,
+		"enabled":     session.Active(time.Now()),
+		"enabledAt":   formatTime(session.EnabledAt),
+		"expiresAt":   formatTime(session.ExpiresAt),
+		"captures":    session.Captures,
+		"maxCaptures": maxDebugCaptures,
+	}
+}
+
+// withDebugCapture records a sanitized copy of the request and response of op while a debug
+// session is active for a user of the request. A failed capture never fails the request.
+func (b *Backend) withDebugCapture(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		resp, err := op(ctx, req, d)
+		for _, uuid := range requestUUIDs(d) {
+			b.captureDebug(ctx, req, uuid, resp, err)
+		}
+		return resp, err
+	}
+}
+
+func (b *Backend) captureDebug(ctx context.Context, req *logical.Request, uuid string,
+	resp *logical.Response, opErr error) {
+	backendLogger := b.logger.With(slog.String("op", "debug_capture"))
+
+	session, err := helpers.GetDebugSession(ctx, req.Storage, uuid)
+	if err != nil {
+		backendLogger.Error("get debug session", "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	if session == nil || !session.Active(now) || session.Captures >= maxDebugCaptures {
+		return
+	}
+
+	capture := helpers.DebugCapture{
+		ID:        helpers.NewUUID(),
+		Time:      now,
+		Path:      req.Path,
+		Operation: string(req.Operation),
+		Request:   sanitizeDebugData(req.Data),
+	}
+	if resp != nil {
+		capture.Response = sanitizeDebugData(resp.Data)
+	}
+	if opErr != nil {
+		capture.Error = logging.RedactString(opErr.Error())
+	}
+
+	entry, err := logical.StorageEntryJSON(config.DebugStoragePath+uuid+"/"+capture.ID, capture)
+	if err != nil {
+		backendLogger.Error("encode debug capture", "error", err)
+		return
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		backendLogger.Error("put debug capture", "error", err)
+		return
+	}
+
+	session.Captures++
+	if err := helpers.PutDebugSession(ctx, req.Storage, session); err != nil {
+		backendLogger.Error("put debug session", "error", err)
+	}
+}
+
+// sanitizeDebugData converts data to its JSON form and redacts every secret from it
+func sanitizeDebugData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil
+	}
+	return logging.Sanitize(decoded)
+}
+
+// pruneDebugSessions removes the debug sessions, and their captures, whose retention ended before now
+func (b *Backend) pruneDebugSessions(ctx context.Context, s logical.Storage, now time.Time) error {
+	uuids, err := s.List(ctx, config.DebugSessionsStoragePath)
+	if err != nil {
+		return err
+	}
+
+	for _, uuid := range uuids {
+		session, err := helpers.GetDebugSession(ctx, s, uuid)
+		if err != nil {
+			return err
+		}
+		if session == nil || now.Before(session.ExpiresAt.Add(debugCaptureRetention)) {
+			continue
+		}
+		if err := helpers.DeleteDebugSession(ctx, s, uuid); err != nil {
+			return err
+		}
+		b.logger.Info("debug session expired", "uuid", uuid)
+	}
+	return nil
+}
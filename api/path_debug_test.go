@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// Helper function to create a proper framework.FieldData for config/debug/<uuid> endpoint
+func createDebugFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid": {Type: framework.TypeString},
+			"ttl":  {Type: framework.TypeDurationSecond},
+		},
+	}
+}
+
+// createDebugTestStorage returns a storage holding the user signTestUUID
+func createDebugTestStorage(t *testing.T) *logical.InmemStorage {
+	storage := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase, nil)
+	require.NoError(t, err)
+	require.NoError(t, storage.Put(context.Background(), createUserV2StorageEntry(t, user)))
+	return storage
+}
+
+func enableDebugSession(t *testing.T, b *Backend, storage logical.Storage, ttl interface{}) *logical.Response {
+	data := map[string]interface{}{"uuid": signTestUUID}
+	if ttl != nil {
+		data["ttl"] = ttl
+	}
+	resp, err := b.pathWriteDebugSession(context.Background(),
+		&logical.Request{Storage: storage, Data: data}, createDebugFieldData(data))
+	require.NoError(t, err)
+	return resp
+}
+
+// debugTestOp echoes a secret in its response, as the real handlers never do, to check it is not captured
+func debugTestOp(_ context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature": "0xabcdef",
+			"user":      map[string]string{"mnemonic": signTestValidMnemonic},
+		},
+	}, nil
+}
+
+func runCapturedOp(t *testing.T, b *Backend, storage logical.Storage) {
+	data := map[string]interface{}{"uuid": signTestUUID, "passphrase": signTestPassphrase, "payload": signTestPayload}
+	fieldData := &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":       {Type: framework.TypeString},
+			"passphrase": {Type: framework.TypeString},
+			"payload":    {Type: framework.TypeString},
+		},
+	}
+	_, err := b.withDebugCapture(debugTestOp)(context.Background(),
+		&logical.Request{Storage: storage, Path: "sign", Operation: logical.UpdateOperation, Data: data}, fieldData)
+	require.NoError(t, err)
+}
+
+func readDebugCaptures(t *testing.T, b *Backend, storage logical.Storage) []*helpers.DebugCapture {
+	resp, err := b.pathReadDebugCaptures(context.Background(), &logical.Request{Storage: storage},
+		createDebugFieldData(map[string]interface{}{"uuid": signTestUUID}))
+	require.NoError(t, err)
+	return resp.Data["captures"].([]*helpers.DebugCapture)
+}
+
+func TestBackend_PathDebugSession(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("enable defaults to one hour", func(t *testing.T) {
+		storage := createDebugTestStorage(t)
+		resp := enableDebugSession(t, createSignTestBackend(t), storage, nil)
+		assert.Equal(t, true, resp.Data["enabled"])
+
+		session, err := helpers.GetDebugSession(ctx, storage, signTestUUID)
+		require.NoError(t, err)
+		assert.Equal(t, defaultDebugCaptureTTL, session.ExpiresAt.Sub(session.EnabledAt))
+	})
+
+	t.Run("ttl is bounded", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": signTestUUID, "ttl": "48h"}
+		_, err := createSignTestBackend(t).pathWriteDebugSession(ctx,
+			&logical.Request{Storage: createDebugTestStorage(t), Data: data}, createDebugFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidDebugTTL.Error())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": "unknown"}
+		_, err := createSignTestBackend(t).pathWriteDebugSession(ctx,
+			&logical.Request{Storage: createDebugTestStorage(t), Data: data}, createDebugFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUUIDDoesNotExist.Error())
+	})
+
+	t.Run("read without session", func(t *testing.T) {
+		resp, err := createSignTestBackend(t).pathReadDebugSession(ctx,
+			&logical.Request{Storage: createDebugTestStorage(t)},
+			createDebugFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.NoError(t, err)
+		assert.Equal(t, false, resp.Data["enabled"])
+		assert.Equal(t, 0, resp.Data["captures"])
+	})
+}
+
+func TestBackend_DebugCapture(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not captured without session", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		runCapturedOp(t, b, storage)
+		assert.Empty(t, readDebugCaptures(t, b, storage))
+	})
+
+	t.Run("captured with secrets redacted", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		enableDebugSession(t, b, storage, 600)
+		runCapturedOp(t, b, storage)
+
+		captures := readDebugCaptures(t, b, storage)
+		require.Len(t, captures, 1)
+		assert.Equal(t, "sign", captures[0].Path)
+		assert.Equal(t, string(logical.UpdateOperation), captures[0].Operation)
+		assert.Equal(t, signTestPayload, captures[0].Request["payload"])
+		assert.Equal(t, logging.Redacted, captures[0].Request["passphrase"])
+		assert.Equal(t, "0xabcdef", captures[0].Response["signature"])
+		assert.Equal(t, map[string]interface{}{"mnemonic": logging.Redacted}, captures[0].Response["user"])
+
+		session, err := helpers.GetDebugSession(ctx, storage, signTestUUID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, session.Captures)
+	})
+
+	t.Run("not captured after expiry", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		past := time.Now().UTC().Add(-time.Hour)
+		require.NoError(t, helpers.PutDebugSession(ctx, storage,
+			&helpers.DebugSession{UUID: signTestUUID, EnabledAt: past, ExpiresAt: past.Add(time.Minute)}))
+
+		runCapturedOp(t, b, storage)
+		assert.Empty(t, readDebugCaptures(t, b, storage))
+	})
+
+	t.Run("capture limit", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		now := time.Now().UTC()
+		require.NoError(t, helpers.PutDebugSession(ctx, storage, &helpers.DebugSession{
+			UUID: signTestUUID, EnabledAt: now, ExpiresAt: now.Add(time.Hour), Captures: maxDebugCaptures,
+		}))
+
+		runCapturedOp(t, b, storage)
+		assert.Empty(t, readDebugCaptures(t, b, storage))
+	})
+
+	t.Run("delete removes captures", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		enableDebugSession(t, b, storage, nil)
+		runCapturedOp(t, b, storage)
+
+		_, err := b.pathDeleteDebugSession(ctx, &logical.Request{Storage: storage},
+			createDebugFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.NoError(t, err)
+
+		assert.Empty(t, readDebugCaptures(t, b, storage))
+		session, err := helpers.GetDebugSession(ctx, storage, signTestUUID)
+		require.NoError(t, err)
+		assert.Nil(t, session)
+	})
+
+	t.Run("expired sessions are pruned after retention", func(t *testing.T) {
+		b := createSignTestBackend(t)
+		storage := createDebugTestStorage(t)
+		enableDebugSession(t, b, storage, nil)
+		runCapturedOp(t, b, storage)
+
+		require.NoError(t, b.pruneDebugSessions(ctx, storage, time.Now()))
+		assert.Len(t, readDebugCaptures(t, b, storage), 1)
+
+		require.NoError(t, b.pruneDebugSessions(ctx, storage,
+			time.Now().Add(defaultDebugCaptureTTL+debugCaptureRetention+time.Minute)))
+		assert.Empty(t, readDebugCaptures(t, b, storage))
+		keys, err := storage.List(ctx, config.DebugSessionsStoragePath)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+}
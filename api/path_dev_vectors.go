@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+// devVector -- a test user of dev/vectors
+type devVector struct {
+	name     string
+	mnemonic string
+}
+
+// devVectors are the test users of dev/vectors, the public English vectors of BIP-39 without passphrase
+//
+//nolint:gochecknoglobals // read-only lookup table
+var devVectors = []devVector{
+	{"abandon", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+	{"legal", "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+	{"letter", "letter advice cage absurd amount doctor acoustic avoid letter advice cage above"},
+	{"zoo", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong"},
+}
+
+// devVectorPaths maps the adapters to the derivation path of their addresses in dev/vectors, %d
+// being the coin type. StarkNet keys are bound to the Ethereum account.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var devVectorPaths = map[string]string{
+	"evm":      "m/44'/%d'/0'/0/0",
+	"bitcoin":  "m/84'/%d'/0'/0/0",
+	"solana":   "m/44'/%d'/0'/0'",
+	"aptos":    "m/44'/%d'/0'/0'/0'",
+	"sui":      "m/44'/%d'/0'/0'/0'",
+	"ton":      "m/44'/%d'/0'",
+	"hedera":   "m/44'/%d'/0'/0'/0'",
+	"algorand": "m/44'/%d'/0'/0'/0'",
+	"starknet": "m/44'/60'/0'/0/0",
+}
+
+// devVectorPath returns the derivation path of the address of coinType in dev/vectors, the
+// first BIP-44 account of its curve for the adapters without one
+func devVectorPath(capabilities lib.Capabilities, coinType uint16) string {
+	template, ok := devVectorPaths[capabilities.Adapter]
+	switch {
+	case !ok && capabilities.Curve == lib.CurveEd25519:
+		template = "m/44'/%d'/0'/0'/0'"
+	case !ok:
+		template = "m/44'/%d'/0'/0/0"
+	}
+	if !strings.Contains(template, "%d") {
+		return template
+	}
+	return fmt.Sprintf(template, coinType)
+}
+
+// pathDevVectors corresponds to READ dev/vectors. It returns the test users of devVectors with
+// their address for every supported coin type, or coinTypes, so QA environments share identical
+// fixtures. config/features must have devVectorsEnabled, which is meant for development mounts only.
+func (b *Backend) pathDevVectors(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_dev_vectors"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.DevVectorsEnabled {
+		backendLogger.Warn("dev vectors rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("dev/vectors: %s", helpers.ErrFeatureDisabled))
+	}
+
+	coinTypes, err := coinTypesFromField(d, "coinTypes")
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	isDev := d.Get("isDev").(bool)
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	if len(coinTypes) == 0 {
+		for _, capabilities := range adapterInventory.Capabilities() {
+			coinTypes = append(coinTypes, capabilities.CoinTypes...)
+		}
+	}
+	slices.Sort(coinTypes)
+	coinTypes = slices.Compact(coinTypes)
+
+	vectors := make([]map[string]interface{}, 0, len(devVectors))
+	for _, vector := range devVectors {
+		seed, err := lib.SeedFromMnemonic(vector.mnemonic, "")
+		if err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err, "vector", vector.name)
+			return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+		}
+		fingerprint, err := lib.MasterFingerprint(seed)
+		if err != nil {
+			backendLogger.Error("master fingerprint", "error", err, "vector", vector.name)
+			return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+		}
+
+		addresses := make([]map[string]interface{}, 0, len(coinTypes))
+		for _, coinType := range coinTypes {
+			capabilities, err := adapterInventory.CoinCapabilities(coinType)
+			if err != nil {
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %d", err, coinType))
+			}
+			path := devVectorPath(capabilities, coinType)
+			address, err := adapterInventory.DeriveAddress(seed, coinType, path, isDev)
+			if err != nil {
+				backendLogger.Error("derive address", "error", err, "vector", vector.name, "coinType", coinType)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+			addresses = append(addresses, map[string]interface{}{
+				"coinType": int(coinType),
+				"adapter":  capabilities.Adapter,
+				"path":     path,
+				"address":  address,
+			})
+		}
+		vectors = append(vectors, map[string]interface{}{
+			"name":        vector.name,
+			"mnemonic":    vector.mnemonic,
+			"fingerprint": fingerprint,
+			"addresses":   addresses,
+		})
+	}
+	backendLogger.Info("dev vectors returned", "coinTypes", coinTypes, "isDev", isDev)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"isDev":   isDev,
+			"vectors": vectors,
+		},
+	}, nil
+}
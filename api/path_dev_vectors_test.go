@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+func TestBackend_HandleRequest_DevVectors(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+
+	_, err := request(logical.ReadOperation, "dev/vectors", nil)
+	require.ErrorContains(t, err, helpers.ErrFeatureDisabled.Error())
+	_, err = request(logical.UpdateOperation, "config/features", map[string]interface{}{"devVectorsEnabled": true})
+	require.NoError(t, err)
+
+	t.Run("every vector derives every coin type", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "dev/vectors", nil)
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["isDev"])
+		vectors := resp.Data["vectors"].([]map[string]interface{})
+		require.Len(t, vectors, len(devVectors))
+		for _, vector := range vectors {
+			assert.True(t, lib.IsMnemonicValid(vector["mnemonic"].(string)), vector["name"])
+			for _, address := range vector["addresses"].([]map[string]interface{}) {
+				assert.NotEmpty(t, address["address"], address)
+			}
+		}
+	})
+
+	t.Run("the addresses are the ones of the wallets", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "dev/vectors", map[string]interface{}{
+			"coinTypes": "60,0", "isDev": false,
+		})
+		require.NoError(t, err)
+		vector := resp.Data["vectors"].([]map[string]interface{})[0]
+		assert.Equal(t, "abandon", vector["name"])
+		assert.Equal(t, "73c5da0a", vector["fingerprint"])
+		assert.Equal(t, []map[string]interface{}{
+			{"coinType": 0, "adapter": "bitcoin", "path": "m/84'/0'/0'/0/0",
+				"address": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+			{"coinType": 60, "adapter": "evm", "path": "m/44'/60'/0'/0/0",
+				"address": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		}, vector["addresses"])
+	})
+
+	_, err = request(logical.ReadOperation, "dev/vectors", map[string]interface{}{"coinTypes": "70000"})
+	require.ErrorContains(t, err, helpers.ErrUnsupportedCoinType.Error())
+}
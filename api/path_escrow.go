@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/escrow"
+)
+
+// pathListEscrow corresponds to LIST escrow/.
+func (b *Backend) pathListEscrow(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_escrow"))
+
+	uuids, err := req.Storage.List(ctx, config.EscrowStoragePath)
+	if err != nil {
+		backendLogger.Error("list escrow records", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(uuids), nil
+}
+
+// pathReadEscrowRecord corresponds to READ escrow/<uuid>. The ciphertext is only readable by the custodian.
+func (b *Backend) pathReadEscrowRecord(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_escrow_record"))
+
+	record, err := helpers.GetEscrowRecord(ctx, req.Storage, d.Get("uuid").(string))
+	if err != nil {
+		backendLogger.Error("get escrow record", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: escrowResponseData(record),
+	}, nil
+}
+
+// pathWriteEscrowRecord corresponds to UPDATE escrow/<uuid>. It escrows the mnemonic of a user
+// registered before escrow was enabled, or again after the custodian key changed.
+func (b *Backend) pathWriteEscrowRecord(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_escrow_record"))
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	user, err := helpers.GetUser(ctx, req, d.Get("uuid").(string))
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeManagement(req.EntityID); err != nil {
+		backendLogger.Warn("escrow rotation rejected", "error", err, "uuid", user.UUID, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if err := checkUserVersion(d, user); err != nil {
+		backendLogger.Warn("escrow rotation rejected", "error", err, "uuid", user.UUID, "entity", req.EntityID)
+		return nil, err
+	}
+	if user.WatchOnly() {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrWatchOnlyUser.Error())
+	}
+
+	record, err := b.escrowMnemonic(ctx, req.Storage, user)
+	if err != nil {
+		backendLogger.Error("escrow mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if record == nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrEscrowDisabled.Error())
+	}
+
+	return &logical.Response{
+		Data: escrowResponseData(record),
+	}, nil
+}
+
+func escrowResponseData(record *helpers.EscrowRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":       record.UUID,
+		"keyId":      record.KeyID,
+		"algorithm":  record.Algorithm,
+		"ciphertext": record.Ciphertext,
+		"createdAt":  formatTime(record.CreatedAt),
+	}
+}
+
+// escrowMnemonic encrypts the mnemonic and passphrase of user to the custodian key of config/escrow
+// and stores the record. It returns nil when escrow is disabled or the user is watch-only.
+func (b *Backend) escrowMnemonic(ctx context.Context, s logical.Storage,
+	user *helpers.User) (*helpers.EscrowRecord, error) {
+	escrowConfig, err := helpers.GetEscrowConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if !escrowConfig.Enabled || user.WatchOnly() {
+		return nil, nil
+	}
+	publicKey, err := escrow.ParsePublicKey(escrowConfig.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := escrow.Seal(publicKey, escrow.Secret{
+		UUID:           user.UUID,
+		Mnemonic:       user.Mnemonic,
+		Passphrase:     user.Passphrase,
+		NamespaceIndex: user.NamespaceIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	record := &helpers.EscrowRecord{
+		UUID:       user.UUID,
+		KeyID:      escrow.KeyID(publicKey),
+		Algorithm:  escrow.Algorithm,
+		Ciphertext: ciphertext,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := helpers.PutEscrowRecord(ctx, s, record); err != nil {
+		return nil, err
+	}
+	b.logger.Info("mnemonic escrowed", "uuid", user.UUID, "keyId", record.KeyID)
+	return record, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathExportWatchOnly corresponds to READ export/watch-only/<uuid>. It returns the public
+// half of the user's accounts, so wallets and explorers can reconcile without private material.
+func (b *Backend) pathExportWatchOnly(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_export_watch_only"))
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.ExportEnabled {
+		backendLogger.Warn("export rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("export: %s", helpers.ErrFeatureDisabled))
+	}
+
+	uuid := d.Get("uuid").(string)
+	account := d.Get("account").(int)
+	isDev := d.Get("isDev").(bool)
+	if account < 0 || account > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAccount.Error())
+	}
+
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if user.Status != helpers.UserStatusActive || user.Expired(time.Now()) {
+		backendLogger.Error("authorize user", "status", user.Status, "expiresAt", user.ExpiresAt)
+		return nil, logical.CodedError(http.StatusForbidden, helpers.ErrUserNotActive.Error())
+	}
+
+	seed, err := userSeed(ctx, user)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		backendLogger.Error("master fingerprint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	bitcoin := []lib.WatchOnlyAccount{}
+	bitcoinCoinType := slip44.Bitcoin
+	if isDev {
+		bitcoinCoinType = slip44.TestNet
+	}
+	// coin restricted users only get the coins they are allowed to use
+	if user.Authorize(bitcoinCoinType) == nil {
+		if bitcoin, err = lib.BitcoinWatchOnlyAccounts(seed, uint32(account), isDev); err != nil {
+			backendLogger.Error("bitcoin watch-only accounts", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	xpubs, err := accountXpubs(backendLogger, user, seed, uint32(account))
+	if err != nil {
+		backendLogger.Error("account xpubs", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	network := "mainnet"
+	if isDev {
+		network = "testnet"
+	}
+	backendLogger.Info("watch-only export", "uuid", uuid, "account", account, "network", network)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"uuid":        uuid,
+			"fingerprint": fingerprint,
+			"account":     account,
+			"network":     network,
+			"bitcoin":     bitcoin,
+			"xpubs":       xpubs,
+		},
+	}, nil
+}
+
+// accountXpubs returns the BIP-44 account xpub of every secp256k1 coin served by an adapter,
+// from which the m/44'/<coinType>'/<account>'/<change>/<index> addresses can be derived.
+// Ed25519 and STARK coins only have hardened derivation, so they have no xpub.
+func accountXpubs(logger *slog.Logger, user *helpers.User, seed []byte,
+	account uint32) ([]map[string]interface{}, error) {
+	xpubs := make([]map[string]interface{}, 0)
+	for _, capabilities := range adapter.GetInventory(logger).Capabilities() {
+		if capabilities.Curve != lib.CurveSecp256k1 {
+			continue
+		}
+		for _, coinType := range capabilities.CoinTypes {
+			if user.Authorize(coinType) != nil {
+				continue
+			}
+			path := []uint32{lib.BIP44Purpose, uint32(coinType), account}
+			xpub, err := lib.ExtendedPublicKey(seed, path, &chaincfg.MainNetParams)
+			if err != nil {
+				return nil, err
+			}
+			xpubs = append(xpubs, map[string]interface{}{
+				"coinType": coinType,
+				"name":     slip44.GetCoinName(coinType),
+				"path":     lib.FormatAccountPath(lib.BIP44Purpose, uint32(coinType), account),
+				"xpub":     xpub.String(),
+			})
+		}
+	}
+
+	sort.SliceStable(xpubs, func(i, j int) bool {
+		return xpubs[i]["coinType"].(uint16) < xpubs[j]["coinType"].(uint16)
+	})
+	return xpubs, nil
+}
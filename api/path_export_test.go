@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// BIP-49, BIP-84 and BIP-86 test vectors for the "abandon ... about" mnemonic
+const (
+	exportTestYpub   = "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP"
+	exportTestZpub   = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+	exportTestTrXpub = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+)
+
+// Helper function to create a proper framework.FieldData for export/watch-only/<uuid> endpoint
+func createExportFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":    {Type: framework.TypeString},
+			"account": {Type: framework.TypeInt, Default: 0},
+			"isDev":   {Type: framework.TypeBool, Default: false},
+		},
+	}
+}
+
+func TestBackend_PathExportWatchOnly(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bitcoin accounts match the BIP test vectors", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).
+			Return(createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, ""), nil)
+
+		got, err := createSignTestBackend(t).pathExportWatchOnly(ctx, &logical.Request{Storage: mockStorage},
+			createExportFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.NoError(t, err)
+		assert.Equal(t, userTestFingerprint, got.Data["fingerprint"])
+		assert.Equal(t, "mainnet", got.Data["network"])
+
+		accounts := got.Data["bitcoin"].([]lib.WatchOnlyAccount)
+		require.Len(t, accounts, 4)
+		byType := map[string]lib.WatchOnlyAccount{}
+		for _, account := range accounts {
+			byType[account.AddressType] = account
+		}
+
+		assert.Equal(t, "m/49'/0'/0'", byType[lib.AddressTypeP2SHP2WPKH].Path)
+		assert.Equal(t, exportTestYpub, byType[lib.AddressTypeP2SHP2WPKH].Slip132)
+		assert.Equal(t, exportTestZpub, byType[lib.AddressTypeP2WPKH].Slip132)
+		assert.Equal(t, exportTestTrXpub, byType[lib.AddressTypeP2TR].Xpub)
+
+		receive := "tr([73c5da0a/86h/0h/0h]" + exportTestTrXpub + "/0/*)"
+		assert.Equal(t, receive+"#"+mustDescriptorChecksum(t, receive), byType[lib.AddressTypeP2TR].Descriptors.Receive)
+		assert.Contains(t, byType[lib.AddressTypeP2SHP2WPKH].Descriptors.Change, "sh(wpkh([73c5da0a/49h/0h/0h]")
+		assert.Contains(t, byType[lib.AddressTypeP2SHP2WPKH].Descriptors.Change, "/1/*))#")
+
+		xpubs := got.Data["xpubs"].([]map[string]interface{})
+		require.NotEmpty(t, xpubs)
+		for _, xpub := range xpubs {
+			assert.NotEqual(t, slip44.Solana, xpub["coinType"], "ed25519 coins have no xpub")
+		}
+	})
+
+	t.Run("testnet", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).
+			Return(createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, ""), nil)
+
+		got, err := createSignTestBackend(t).pathExportWatchOnly(ctx, &logical.Request{Storage: mockStorage},
+			createExportFieldData(map[string]interface{}{"uuid": signTestUUID, "isDev": true}))
+		require.NoError(t, err)
+		accounts := got.Data["bitcoin"].([]lib.WatchOnlyAccount)
+		assert.Equal(t, "m/84'/1'/0'", accounts[2].Path)
+		assert.Regexp(t, `^tpub`, accounts[2].Xpub)
+		assert.Regexp(t, `^vpub`, accounts[2].Slip132)
+	})
+
+	t.Run("coin restricted user", func(t *testing.T) {
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{slip44.Ether})
+		require.NoError(t, err)
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(createUserV2StorageEntry(t, user), nil)
+
+		got, err := createSignTestBackend(t).pathExportWatchOnly(ctx, &logical.Request{Storage: mockStorage},
+			createExportFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.NoError(t, err)
+		assert.Empty(t, got.Data["bitcoin"])
+		xpubs := got.Data["xpubs"].([]map[string]interface{})
+		require.Len(t, xpubs, 1)
+		assert.Equal(t, slip44.Ether, xpubs[0]["coinType"])
+		assert.Equal(t, "m/44'/60'/0'", xpubs[0]["path"])
+	})
+
+	t.Run("disabled user", func(t *testing.T) {
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+		require.NoError(t, err)
+		user.Status = helpers.UserStatusDisabled
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(createUserV2StorageEntry(t, user), nil)
+
+		_, err = createSignTestBackend(t).pathExportWatchOnly(ctx, &logical.Request{Storage: mockStorage},
+			createExportFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUserNotActive.Error())
+	})
+
+	t.Run("feature disabled", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.FeaturesStorageKey).
+			Return(createFeaturesStorageEntry(helpers.Features{ExportEnabled: false}), nil)
+
+		_, err := createSignTestBackend(t).pathExportWatchOnly(ctx, &logical.Request{Storage: mockStorage},
+			createExportFieldData(map[string]interface{}{"uuid": signTestUUID}))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+		mockStorage.AssertExpectations(t)
+	})
+}
+
+func TestDescriptorChecksum(t *testing.T) {
+	// vectors from BIP-380 and the Bitcoin Core descriptor documentation
+	assert.Equal(t, "89f8spxm", mustDescriptorChecksum(t, "raw(deadbeef)"))
+	assert.Equal(t, "ml40v0wf", mustDescriptorChecksum(t, "pkh([d34db33f/44'/0'/0']"+
+		"xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/1/*)"))
+
+	_, err := lib.DescriptorChecksum("raw(deadbeef)é")
+	require.ErrorIs(t, err, lib.ErrInvalidDescriptorCharacter)
+}
+
+func mustDescriptorChecksum(t *testing.T, descriptor string) string {
+	checksum, err := lib.DescriptorChecksum(descriptor)
+	require.NoError(t, err)
+	return checksum
+}
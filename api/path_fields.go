@@ -0,0 +1,56 @@
+package api
+
+import (
+	"math"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+// pathFieldRules are the rules of the fields of the paths, by the pattern of the path, on top of
+// the fieldRules of every path declaring the field
+//
+//nolint:gochecknoglobals // read-only lookup table
+var pathFieldRules = map[string]map[string]helpers.FieldRule{
+	"address/batch":     {"count": helpers.MinInt(1)},
+	"address/vanity":    {"maxIterations": helpers.IntRange(1, maxVanityIterations)},
+	"config/derivation": {"maxDepth": helpers.MinInt(0)},
+}
+
+// fieldRules are the rules of the fields meaning the same on every path declaring them. The coin
+// types are checked against the registered adapters, see validateRequestFields.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var fieldRules = map[string]helpers.FieldRule{
+	"account":    helpers.IntRange(0, math.MaxInt32),
+	"index":      helpers.IntRange(0, math.MaxInt32),
+	"startIndex": helpers.IntRange(0, math.MaxInt32),
+}
+
+// validateRequestFields checks the values given in data for the fields of the path of pattern,
+// before the framework decodes them, so the errors name the field, what its value must be and the
+// value given
+func (b *Backend) validateRequestFields(pattern string, fields map[string]*framework.FieldSchema,
+	data map[string]interface{}) error {
+	fd := &framework.FieldData{Raw: data, Schema: fields}
+	if err := helpers.ValidateFieldValues(fd); err != nil {
+		return err
+	}
+
+	rules := make(map[string]helpers.FieldRule, len(fieldRules)+1)
+	for name, rule := range fieldRules {
+		rules[name] = rule
+	}
+	if _, ok := data["coinType"]; ok {
+		var coinTypes []uint16
+		for _, capabilities := range adapter.GetInventory(b.logger).Capabilities() {
+			coinTypes = append(coinTypes, capabilities.CoinTypes...)
+		}
+		rules["coinType"] = helpers.CoinTypes(coinTypes)
+	}
+	for name, rule := range pathFieldRules[pattern] {
+		rules[name] = rule
+	}
+	return helpers.ValidateFieldRules(fd, rules)
+}
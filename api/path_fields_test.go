@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+func TestBackend_HandleRequest_FieldErrors(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(path string, data map[string]interface{}) error {
+		t.Helper()
+		_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+		return err
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		data map[string]interface{}
+		want string
+	}{
+		{
+			name: "type",
+			path: "address",
+			data: map[string]interface{}{"uuid": signTestUUID, "coinType": "ether"},
+			want: `invalid field: coinType must be an integer, got "ether"`,
+		},
+		{
+			name: "unsupported coin type",
+			path: "address",
+			data: map[string]interface{}{"uuid": signTestUUID, "coinType": 99999},
+			want: "invalid field: coinType must be one of the supported types: 0, ",
+		},
+		{
+			name: "path rule",
+			path: "address/batch",
+			data: map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "count": 0},
+			want: "invalid field: count must be at least 1, got 0",
+		},
+		{
+			name: "hardened index",
+			path: "address",
+			data: map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "preset": "trezor", "index": -1},
+			want: "invalid field: index must be 0..2147483647, got -1",
+		},
+		{
+			name: "unknown field",
+			path: "address",
+			data: map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "cointype": 60},
+			want: "unknown fields provided: [cointype], the fields of the path are account, apiKey, coinType,",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := request(tc.path, tc.data)
+			require.ErrorContains(t, err, tc.want)
+		})
+	}
+
+	t.Run("batch items", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s,
+			Data: map[string]interface{}{"items": []interface{}{map[string]interface{}{
+				"uuid": signTestUUID, "coinType": "ether", "payload": signTestPayload,
+			}}},
+		})
+		require.NoError(t, err)
+		result := resp.Data["results"].([]map[string]interface{})[0]
+		assert.Equal(t, batchItemFailed, result["status"])
+		assert.Contains(t, result["error"], "coinType must be an integer")
+	})
+}
+
+func TestSanitizeFieldValue(t *testing.T) {
+	assert.Equal(t, `"abc"`, helpers.SanitizeFieldValue("payload", "abc"))
+	assert.Equal(t, "42", helpers.SanitizeFieldValue("count", 42))
+	assert.Equal(t, logging.Redacted, helpers.SanitizeFieldValue("passphrase", "hunter2"))
+	assert.Equal(t, logging.Redacted, helpers.SanitizeFieldValue("signingSecret", "hunter2"))
+	assert.Equal(t, logging.Redacted, helpers.SanitizeFieldValue("payload", signTestValidMnemonic))
+
+	long := helpers.SanitizeFieldValue("payload", strings.Repeat("a", 100)+"\n")
+	assert.Equal(t, `"`+strings.Repeat("a", 64)+`..."`, long)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// pathGenMnemonic corresponds to POST gen/mnemonic. It returns a fresh mnemonic of the entropy
+// source of the mount without registering a user, for key ceremonies backing it up first; nothing
+// is stored. config/features must have mnemonicGenEnabled.
+func (b *Backend) pathGenMnemonic(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_gen_mnemonic"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.MnemonicGenEnabled {
+		backendLogger.Warn("mnemonic generation rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("gen/mnemonic: %s", helpers.ErrFeatureDisabled))
+	}
+
+	strength := d.Get("strength").(int)
+	if strength%32 != 0 || strength < 128 || strength > 256 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidStrength.Error())
+	}
+	mnemonic, err := b.generateMnemonic(ctx, req.Storage, strength)
+	if err != nil {
+		backendLogger.Error("generate mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+	}
+	seed, err := lib.SeedFromMnemonic(mnemonic, "")
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		backendLogger.Error("master fingerprint", "error", err)
+		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+	}
+
+	backendLogger.Info("mnemonic generated", "strength", strength, "fingerprint", fingerprint, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"mnemonic":    mnemonic,
+			"strength":    strength,
+			"words":       len(strings.Fields(mnemonic)),
+			"fingerprint": fingerprint,
+		},
+	}, nil
+}
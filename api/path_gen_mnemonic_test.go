@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/sealedbox"
+)
+
+func TestBackend_HandleRequest_GenMnemonic(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+
+	_, err := request("gen/mnemonic", map[string]interface{}{})
+	require.ErrorContains(t, err, helpers.ErrFeatureDisabled.Error())
+	_, err = request("config/features", map[string]interface{}{"mnemonicGenEnabled": true})
+	require.NoError(t, err)
+
+	t.Run("fresh mnemonics of the strength", func(t *testing.T) {
+		resp, err := request("gen/mnemonic", map[string]interface{}{})
+		require.NoError(t, err)
+		mnemonic := resp.Data["mnemonic"].(string)
+		assert.True(t, lib.IsMnemonicValid(mnemonic))
+		assert.Equal(t, 24, resp.Data["words"])
+		assert.Equal(t, 256, resp.Data["strength"])
+
+		again, err := request("gen/mnemonic", map[string]interface{}{"strength": 128})
+		require.NoError(t, err)
+		assert.Len(t, strings.Fields(again.Data["mnemonic"].(string)), 12)
+		assert.NotEqual(t, mnemonic, again.Data["mnemonic"])
+
+		// nothing is stored
+		keys, err := s.List(ctx, "users/")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+
+		// registering the mnemonic gives a user of the fingerprint
+		registered, err := request("register", map[string]interface{}{"uuid": "ceremony-uuid", "mnemonic": mnemonic})
+		require.NoError(t, err)
+		assert.Equal(t, resp.Data["fingerprint"], registered.Data["fingerprint"])
+	})
+
+	for _, strength := range []int{0, 100, 288} {
+		_, err := request("gen/mnemonic", map[string]interface{}{"strength": strength})
+		require.ErrorContains(t, err, helpers.ErrInvalidStrength.Error())
+	}
+
+	t.Run("the mnemonic is encrypted to the response key", func(t *testing.T) {
+		publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		resp, err := request("gen/mnemonic", map[string]interface{}{
+			"responsePublicKey": hex.EncodeToString(publicKey[:]),
+		})
+		require.NoError(t, err)
+		plaintext, err := sealedbox.Open(publicKey, privateKey, resp.Data["mnemonic"].(string))
+		require.NoError(t, err)
+		assert.True(t, lib.IsMnemonicValid(string(plaintext)))
+
+		_, err = request("config/features", map[string]interface{}{"encryptedResponsesRequired": true})
+		require.NoError(t, err)
+		_, err = request("gen/mnemonic", map[string]interface{}{})
+		require.ErrorContains(t, err, helpers.ErrResponseKeyRequired.Error())
+	})
+}
@@ -2,17 +2,36 @@ package api
 
 import (
 	"context"
+	"log/slog"
+	"net/http"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/secp"
 )
 
-// pathInfo corresponds to READ gen/info.
-func (b *Backend) pathInfo(_ context.Context, _ *logical.Request,
+// pathInfo corresponds to READ gen/info. It also reports the feature flags of the mount, the
+// secp256k1 implementation the plugin was built with and the last validation of its configuration.
+func (b *Backend) pathInfo(ctx context.Context, req *logical.Request,
 	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_info"))
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := map[string]interface{}{
+		"Info":      backendHelp,
+		"features":  featuresResponseData(features),
+		"secp256k1": secp.Implementation,
+	}
+	if validation := b.lastValidation(); validation != nil {
+		data["validation"] = validationResponseData(validation)
+	}
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"Info": backendHelp,
-		},
+		Data: data,
 	}, nil
 }
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// jobChunkSize bounds the items of a job processed between two checkpoints
+const jobChunkSize = 10
+
+// enqueueSignBatch queues the items of an async sign/batch as a job and starts running it
+func (b *Backend) enqueueSignBatch(ctx context.Context, req *logical.Request, items []interface{}, failFast bool,
+	apiKey string) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_batch"))
+
+	now := time.Now().UTC()
+	job := &helpers.Job{
+		ID:        helpers.NewUUID(),
+		Type:      helpers.JobTypeSignBatch,
+		EntityID:  req.EntityID,
+		Status:    helpers.JobQueued,
+		Items:     items,
+		APIKey:    apiKey,
+		FailFast:  failFast,
+		Results:   []map[string]interface{}{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := helpers.PutJob(ctx, req.Storage, job); err != nil {
+		backendLogger.Error("put job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("batch queued", "jobId", job.ID, "items", len(items), "entity", req.EntityID)
+
+	b.startJobs(req.Storage)
+	return &logical.Response{
+		Data: jobResponseData(job),
+	}, nil
+}
+
+// pathListJobs corresponds to LIST jobs.
+func (b *Backend) pathListJobs(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_jobs"))
+
+	ids, err := req.Storage.List(ctx, config.JobsStoragePath)
+	if err != nil {
+		backendLogger.Error("list jobs", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(ids), nil
+}
+
+// pathReadJob corresponds to READ jobs/<id>.
+func (b *Backend) pathReadJob(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_job"))
+
+	job, err := helpers.GetJob(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		backendLogger.Error("get job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if job == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: jobResponseData(job),
+	}, nil
+}
+
+// pathDeleteJob corresponds to DELETE jobs/<id>, only finished jobs are deleted.
+func (b *Backend) pathDeleteJob(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_job"))
+
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+
+	id := d.Get("id").(string)
+	job, err := helpers.GetJob(ctx, req.Storage, id)
+	if err != nil {
+		backendLogger.Error("get job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if job == nil {
+		return nil, nil
+	}
+	if !job.Finished() {
+		return nil, logical.CodedError(http.StatusConflict, helpers.ErrJobNotDone.Error())
+	}
+
+	if err := helpers.DeleteJob(ctx, req.Storage, id); err != nil {
+		backendLogger.Error("delete job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("job deleted", "jobId", id, "entity", req.EntityID)
+	return nil, nil
+}
+
+// pathCancelJob corresponds to UPDATE jobs/<id>/cancel. A queued job is canceled at once, a running
+// one is flagged and canceled by its run at the next checkpoint.
+func (b *Backend) pathCancelJob(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_cancel_job"))
+
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+
+	job, err := helpers.GetJob(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		backendLogger.Error("get job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if job == nil {
+		return nil, nil
+	}
+	if job.Finished() {
+		return nil, logical.CodedError(http.StatusConflict, helpers.ErrJobFinished.Error())
+	}
+
+	now := time.Now().UTC()
+	job.CancelRequested, job.UpdatedAt = true, now
+	if job.Status == helpers.JobQueued {
+		job.Status, job.CompletedAt = helpers.JobCanceled, now
+	}
+	if err := helpers.PutJob(ctx, req.Storage, job); err != nil {
+		backendLogger.Error("put job", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("job cancel requested", "jobId", job.ID, "status", job.Status, "entity", req.EntityID)
+	return &logical.Response{
+		Data: jobResponseData(job),
+	}, nil
+}
+
+// jobResponseData reports the progress and results of job, without its items and their apiKey
+func jobResponseData(job *helpers.Job) map[string]interface{} {
+	counts := map[string]int{batchItemSigned: 0, batchItemFailed: 0, batchItemSkipped: 0, batchItemPending: 0}
+	for _, result := range job.Results {
+		status, _ := result["status"].(string)
+		counts[status]++
+	}
+	return map[string]interface{}{
+		"jobId":           job.ID,
+		"type":            job.Type,
+		"status":          job.Status,
+		"total":           len(job.Items),
+		"processed":       len(job.Results),
+		"signed":          counts[batchItemSigned],
+		"failed":          counts[batchItemFailed],
+		"skipped":         counts[batchItemSkipped],
+		"pending":         counts[batchItemPending],
+		"results":         job.Results,
+		"cancelRequested": job.CancelRequested,
+		"entityId":        job.EntityID,
+		"createdAt":       formatTime(job.CreatedAt),
+		"updatedAt":       formatTime(job.UpdatedAt),
+		"completedAt":     formatTime(job.CompletedAt),
+	}
+}
+
+// startJobs runs the jobs of s in the background, unless the plugin is reloading
+func (b *Backend) startJobs(s logical.Storage) {
+	if !b.begin() {
+		return
+	}
+	go func() {
+		defer b.end()
+		if err := b.runJobs(context.Background(), s); err != nil {
+			b.logger.Error("run jobs", "error", err)
+		}
+	}()
+}
+
+// runJobs runs the queued and running jobs of s, oldest first, until none is left. A single run
+// goes at once: the jobs queued during a run are picked up by it. A job interrupted by a reload or
+// a failover is resumed from its checkpoint by the next periodic run.
+func (b *Backend) runJobs(ctx context.Context, s logical.Storage) error {
+	if !b.jobsRunMu.TryLock() {
+		return nil
+	}
+	defer b.jobsRunMu.Unlock()
+
+	for {
+		ids, err := s.List(ctx, config.JobsStoragePath)
+		if err != nil {
+			return err
+		}
+		// the IDs sort in the order the jobs were queued
+		sort.Strings(ids)
+
+		ran := false
+		for _, id := range ids {
+			if b.reloading() {
+				return nil
+			}
+			job, err := helpers.GetJob(ctx, s, id)
+			if err != nil {
+				return err
+			}
+			if job == nil || job.Finished() {
+				continue
+			}
+			if err := b.runJob(ctx, s, job); err != nil {
+				return err
+			}
+			ran = true
+		}
+		if !ran {
+			return nil
+		}
+	}
+}
+
+// runJob signs the items left of job, checkpointing its results after every chunk, until it
+// finishes or the plugin reloads
+func (b *Backend) runJob(ctx context.Context, s logical.Storage, job *helpers.Job) error {
+	backendLogger := b.logger.With(slog.String("op", "run_job"), slog.String("jobId", job.ID))
+	if job.Status == helpers.JobRunning {
+		backendLogger.Info("job resumed", "processed", len(job.Results), "total", len(job.Items))
+	}
+
+	sign := b.signOp(false)
+	schema := b.Route("sign").Fields
+	req := &logical.Request{Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s,
+		EntityID: job.EntityID}
+	failed := false
+	for _, result := range job.Results {
+		failed = failed || result["status"] == batchItemFailed
+	}
+
+	for !job.Finished() {
+		if b.reloading() {
+			backendLogger.Info("job checkpointed", "processed", len(job.Results), "total", len(job.Items))
+			return nil
+		}
+
+		start := len(job.Results)
+		end := min(start+jobChunkSize, len(job.Items))
+		if job.CancelRequested {
+			end = start
+		}
+		results := make([]map[string]interface{}, 0, end-start)
+		for i := start; i < end; i++ {
+			if job.FailFast && failed {
+				results = append(results, map[string]interface{}{"index": i, "status": batchItemSkipped})
+				continue
+			}
+			result := b.signBatchItem(ctx, req, sign, schema, i, job.Items[i], job.APIKey)
+			failed = failed || result["status"] == batchItemFailed
+			results = append(results, result)
+		}
+
+		if err := b.checkpointJob(ctx, s, job, results, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	data := jobResponseData(job)
+	backendLogger.Info("job finished", "status", job.Status, "total", data["total"], "signed", data["signed"],
+		"failed", data["failed"], "skipped", data["skipped"], "pending", data["pending"])
+	return nil
+}
+
+// checkpointJob stores the results of the chunk processed by job, with the cancellation requested
+// meanwhile. The job finishes once every item was processed, or when it was canceled: complete
+// when no item failed or was skipped, failed when none was signed, partial otherwise.
+func (b *Backend) checkpointJob(ctx context.Context, s logical.Storage, job *helpers.Job,
+	results []map[string]interface{}, now time.Time) error {
+	b.jobsMu.Lock()
+	defer b.jobsMu.Unlock()
+
+	stored, err := helpers.GetJob(ctx, s, job.ID)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		job.CancelRequested = job.CancelRequested || stored.CancelRequested
+	}
+
+	job.Results = append(job.Results, results...)
+	job.Status, job.UpdatedAt = helpers.JobRunning, now.UTC()
+	switch {
+	case len(job.Results) == len(job.Items):
+		data := jobResponseData(job)
+		switch {
+		case data["failed"] == 0 && data["skipped"] == 0:
+			job.Status = helpers.JobComplete
+		case data["signed"] == 0 && data["pending"] == 0:
+			job.Status = helpers.JobFailed
+		default:
+			job.Status = helpers.JobPartial
+		}
+		job.CompletedAt = now.UTC()
+	case job.CancelRequested:
+		job.Status, job.CompletedAt = helpers.JobCanceled, now.UTC()
+	}
+	return helpers.PutJob(ctx, s, job)
+}
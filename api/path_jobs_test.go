@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestBackend_HandleRequest_Jobs(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	backend := func(t *testing.T) *Backend {
+		t.Helper()
+		b := NewBackend(&logical.BackendConfig{})
+		require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+		return b
+	}
+	request := func(b *Backend, operation logical.Operation, path string,
+		data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	item := func(index int) map[string]interface{} {
+		return map[string]interface{}{
+			"uuid":     signTestUUID,
+			"path":     fmt.Sprintf("m/44'/60'/0'/0/%d", index),
+			"coinType": int(slip44.Ether),
+			"payload":  signTestPayload,
+		}
+	}
+	failing := map[string]interface{}{"uuid": signTestUUID, "path": signTestDerivationPath,
+		"coinType": int(slip44.Ether), "payload": signTestMalformedPayload}
+	// putJob stores a job as a previous instance of the plugin left it
+	putJob := func(t *testing.T, status string, items []interface{}, results []map[string]interface{}) string {
+		t.Helper()
+		now := time.Now().UTC()
+		job := &helpers.Job{ID: helpers.NewUUID(), Type: helpers.JobTypeSignBatch, Status: status, Items: items,
+			Results: results, CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, helpers.PutJob(ctx, s, job))
+		return job.ID
+	}
+
+	t.Run("async batches run as jobs", func(t *testing.T) {
+		b := backend(t)
+		items := make([]interface{}, jobChunkSize+3)
+		for i := range items {
+			items[i] = item(i)
+		}
+		resp, err := request(b, logical.UpdateOperation, "sign/batch", map[string]interface{}{
+			"items": items, "async": true,
+		})
+		require.NoError(t, err)
+		id := resp.Data["jobId"].(string)
+		assert.Equal(t, helpers.JobQueued, resp.Data["status"])
+		assert.NotContains(t, resp.Data, "items")
+
+		require.Eventually(t, func() bool {
+			resp, err = request(b, logical.ReadOperation, "jobs/"+id, nil)
+			return err == nil && resp.Data["status"] == helpers.JobComplete
+		}, 10*time.Second, 20*time.Millisecond)
+		assert.Equal(t, len(items), resp.Data["processed"])
+		assert.Equal(t, len(items), resp.Data["signed"])
+
+		single, err := request(b, logical.UpdateOperation, "sign", item(jobChunkSize))
+		require.NoError(t, err)
+		result := resp.Data["results"].([]map[string]interface{})[jobChunkSize]
+		assert.Equal(t, single.Data["signature"], result["signature"])
+
+		resp, err = request(b, logical.ListOperation, "jobs/", nil)
+		require.NoError(t, err)
+		assert.Contains(t, resp.Data["keys"], id)
+
+		_, err = request(b, logical.UpdateOperation, "sign/batch", map[string]interface{}{
+			"items": items, "async": true, "batchId": "payout-1",
+		})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(logical.HTTPCodedError).Code())
+	})
+
+	t.Run("interrupted jobs resume from their checkpoint", func(t *testing.T) {
+		checkpointed := map[string]interface{}{"index": 0, "status": batchItemSigned, "signature": "0xcheckpointed"}
+		id := putJob(t, helpers.JobRunning, []interface{}{item(0), item(1), failing},
+			[]map[string]interface{}{checkpointed})
+
+		b := backend(t)
+		require.NoError(t, b.runJobs(ctx, s))
+		resp, err := request(b, logical.ReadOperation, "jobs/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobPartial, resp.Data["status"])
+		results := resp.Data["results"].([]map[string]interface{})
+		require.Len(t, results, 3)
+		assert.Equal(t, "0xcheckpointed", results[0]["signature"])
+		assert.Equal(t, batchItemSigned, results[1]["status"])
+		assert.Equal(t, batchItemFailed, results[2]["status"])
+
+		// a reloading instance leaves the job running for the next one
+		id = putJob(t, helpers.JobQueued, []interface{}{item(0)}, nil)
+		b.stop()
+		require.NoError(t, b.runJobs(ctx, s))
+		job, err := helpers.GetJob(ctx, s, id)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobQueued, job.Status)
+
+		require.NoError(t, backend(t).runJobs(ctx, s))
+		job, err = helpers.GetJob(ctx, s, id)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobComplete, job.Status)
+	})
+
+	t.Run("jobs without a signed item fail", func(t *testing.T) {
+		id := putJob(t, helpers.JobQueued, []interface{}{failing, "not an object"}, nil)
+		b := backend(t)
+		require.NoError(t, b.runJobs(ctx, s))
+		resp, err := request(b, logical.ReadOperation, "jobs/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobFailed, resp.Data["status"])
+		assert.Equal(t, 2, resp.Data["failed"])
+	})
+
+	t.Run("jobs are canceled", func(t *testing.T) {
+		b := backend(t)
+		id := putJob(t, helpers.JobQueued, []interface{}{item(0)}, nil)
+		resp, err := request(b, logical.UpdateOperation, "jobs/"+id+"/cancel", nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobCanceled, resp.Data["status"])
+
+		_, err = request(b, logical.UpdateOperation, "jobs/"+id+"/cancel", nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(logical.HTTPCodedError).Code())
+
+		id = putJob(t, helpers.JobRunning, []interface{}{item(0), item(1)}, []map[string]interface{}{})
+		_, err = request(b, logical.DeleteOperation, "jobs/"+id, nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(logical.HTTPCodedError).Code())
+
+		resp, err = request(b, logical.UpdateOperation, "jobs/"+id+"/cancel", nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobRunning, resp.Data["status"])
+		assert.Equal(t, true, resp.Data["cancelRequested"])
+
+		require.NoError(t, b.runJobs(ctx, s))
+		resp, err = request(b, logical.ReadOperation, "jobs/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.JobCanceled, resp.Data["status"])
+		assert.Equal(t, 0, resp.Data["processed"])
+
+		_, err = request(b, logical.DeleteOperation, "jobs/"+id, nil)
+		require.NoError(t, err)
+		resp, err = request(b, logical.ReadOperation, "jobs/"+id, nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("finished jobs expire", func(t *testing.T) {
+		b := backend(t)
+		require.NoError(t, b.runJobs(ctx, s))
+		removed, err := b.applyRetention(ctx, s, time.Now().Add(helpers.DefaultJobMaxAge+time.Minute), false)
+		require.NoError(t, err)
+		assert.Positive(t, removed.Jobs)
+		ids, err := s.List(ctx, config.JobsStoragePath)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+// pathLookupAddress corresponds to READ lookup/address. It returns the users and paths an address was
+// derived from through the address paths, while config/features has addressIndexEnabled.
+func (b *Backend) pathLookupAddress(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_lookup_address"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	address := d.Get("address").(string)
+	if address == "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMissingAddress.Error())
+	}
+
+	indexed, err := helpers.LookupAddress(ctx, req.Storage, address)
+	if err != nil {
+		backendLogger.Error("lookup address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("address lookup", "address", address, "found", indexed != nil, "entity", req.EntityID)
+	if indexed == nil {
+		return nil, nil
+	}
+
+	owners := make([]map[string]interface{}, 0, len(indexed.Owners))
+	for _, owner := range indexed.Owners {
+		owners = append(owners, map[string]interface{}{
+			"uuid":      owner.UUID,
+			"coinType":  owner.CoinType,
+			"path":      owner.Path,
+			"isDev":     owner.IsDev,
+			"indexedAt": formatTime(owner.IndexedAt),
+		})
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"address": indexed.Address,
+			"owners":  owners,
+		},
+	}, nil
+}
+
+// indexAddresses records the addresses, keyed by path, derived for coinType of uuid in the reverse
+// index of lookup/address, when config/features enables it
+func (b *Backend) indexAddresses(ctx context.Context, req *logical.Request, uuid string, coinType uint16,
+	isDev bool, addresses map[string]string) error {
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil || !features.AddressIndexEnabled {
+		return err
+	}
+
+	// entries are read and rewritten under the lock, so concurrent owners of an address are all kept
+	b.indexMu.Lock()
+	defer b.indexMu.Unlock()
+
+	now := time.Now().UTC()
+	for path, address := range addresses {
+		owner := helpers.AddressOwner{UUID: uuid, CoinType: coinType, Path: path, IsDev: isDev, IndexedAt: now}
+		if err := helpers.IndexAddress(ctx, req.Storage, address, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
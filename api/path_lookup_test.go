@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Helper function to create a proper framework.FieldData for lookup/address endpoint
+func createLookupFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"address": {Type: framework.TypeString},
+		},
+	}
+}
+
+// newLookupTestStorage returns the signing user storage with the address index enabled
+func newLookupTestStorage(t *testing.T) logical.Storage {
+	t.Helper()
+	s := newXpubTestStorage(t)
+	entry, err := logical.StorageEntryJSON(config.FeaturesStorageKey, helpers.Features{AddressIndexEnabled: true})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+	return s
+}
+
+func TestBackend_PathLookupAddress(t *testing.T) {
+	ctx := context.Background()
+	b := createSignTestBackend(t)
+	lookup := func(s logical.Storage, address string) (*logical.Response, error) {
+		data := map[string]interface{}{"address": address}
+		return b.pathLookupAddress(ctx, &logical.Request{Storage: s, Data: data}, createLookupFieldData(data))
+	}
+
+	t.Run("derived addresses are indexed", func(t *testing.T) {
+		s := newLookupTestStorage(t)
+		for range 2 {
+			data := map[string]interface{}{"uuid": signTestUUID, "coinType": 60}
+			_, err := b.pathAddressNext(ctx, &logical.Request{Storage: s, Data: data}, createAddressNextFieldData(data))
+			require.NoError(t, err)
+		}
+
+		// hex addresses match in any case
+		got, err := lookup(s, "0x9858effd232b4033e47d90003d41ec34ecaeda94")
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", got.Data["address"])
+		owners := got.Data["owners"].([]map[string]interface{})
+		require.Len(t, owners, 1)
+		assert.Equal(t, signTestUUID, owners[0]["uuid"])
+		assert.Equal(t, uint16(60), owners[0]["coinType"])
+		assert.Equal(t, "m/44'/60'/0'/0/0", owners[0]["path"])
+	})
+
+	t.Run("batches are indexed", func(t *testing.T) {
+		s := newLookupTestStorage(t)
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "pathTemplate": "m/44'/60'/0'/0/%d", "coinType": 60, "count": 2,
+		}
+		got, err := b.pathAddressBatch(ctx, &logical.Request{Storage: s, Data: data}, createBatchFieldData(data))
+		require.NoError(t, err)
+
+		for path, address := range got.Data["addresses"].(map[string]string) {
+			found, err := lookup(s, address)
+			require.NoError(t, err)
+			require.NotNil(t, found, address)
+			assert.Equal(t, path, found.Data["owners"].([]map[string]interface{})[0]["path"])
+		}
+	})
+
+	t.Run("nothing is indexed by default", func(t *testing.T) {
+		s := newXpubTestStorage(t)
+		data := map[string]interface{}{"uuid": signTestUUID, "coinType": 60}
+		_, err := b.pathAddressNext(ctx, &logical.Request{Storage: s, Data: data}, createAddressNextFieldData(data))
+		require.NoError(t, err)
+
+		got, err := lookup(s, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("address is required", func(t *testing.T) {
+		_, err := lookup(newLookupTestStorage(t), "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrMissingAddress.Error())
+	})
+}
+
+func TestIndexAddress(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	owner := helpers.AddressOwner{UUID: signTestUUID, CoinType: 60, Path: "m/44'/60'/0'/0/0"}
+
+	require.NoError(t, helpers.IndexAddress(ctx, s, "0xAb", owner))
+	require.NoError(t, helpers.IndexAddress(ctx, s, "0xab", owner))
+	owner.CoinType = 61
+	require.NoError(t, helpers.IndexAddress(ctx, s, "0xab", owner))
+
+	indexed, err := helpers.LookupAddress(ctx, s, "0XAB")
+	require.NoError(t, err)
+	assert.Equal(t, "0xAb", indexed.Address)
+	assert.Len(t, indexed.Owners, 2)
+
+	// other encodings are case sensitive
+	require.NoError(t, helpers.IndexAddress(ctx, s, "Base58Address", owner))
+	indexed, err = helpers.LookupAddress(ctx, s, "base58address")
+	require.NoError(t, err)
+	assert.Nil(t, indexed)
+}
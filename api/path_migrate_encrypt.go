@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// Statuses of the user records reported by migrate/encrypt
+const (
+	recordEncrypted        = "encrypted"
+	recordAlreadyEncrypted = "already-encrypted"
+	recordFailed           = "failed"
+)
+
+// pathMigrateEncrypt corresponds to UPDATE migrate/encrypt. It encrypts the user records kept in clear
+// in the Vault storage under the data encryption key, generated if needed, and checks that each one
+// decrypts back to its plaintext. While records are migrated those still in clear stay readable; once
+// every record is encrypted, plaintext records are rejected. Running it again retries the failed records.
+func (b *Backend) pathMigrateEncrypt(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_migrate_encrypt"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.dekMu.Lock()
+	defer b.dekMu.Unlock()
+
+	rotation, err := helpers.GetDEKRotation(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get dek rotation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if rotation != nil && rotation.State == helpers.DEKRotationRunning {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrDEKRotationInProgress.Error())
+	}
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if storageConfig.Type != storage.TypeVault {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrStoreAlwaysEncrypted.Error())
+	}
+
+	if storageConfig.VaultEncryption == "" {
+		// a key kept from an external store is reused, its records stay readable if it is configured back
+		if len(storageConfig.EncryptionKey) == 0 {
+			storageConfig.EncryptionKey = make([]byte, storage.KeyLength)
+			if _, err := rand.Read(storageConfig.EncryptionKey); err != nil {
+				backendLogger.Error("generate encryption key", "error", err)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+		}
+		storageConfig.VaultEncryption = helpers.VaultEncryptionMigrating
+		if err := b.putStorageConfig(ctx, req.Storage, storageConfig); err != nil {
+			backendLogger.Error("put storage config", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	encrypted, err := encryptedRecords(req.Storage, storageConfig)
+	if err != nil {
+		backendLogger.Error("open encrypted records", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	names, err := req.Storage.List(ctx, config.StorageBasePath)
+	if err != nil {
+		backendLogger.Error("list users", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	records := make(map[string]interface{}, len(names))
+	failures := make(map[string]interface{})
+	counts := map[string]int{recordEncrypted: 0, recordAlreadyEncrypted: 0, recordFailed: 0}
+	for _, uuid := range names {
+		if strings.HasSuffix(uuid, "/") {
+			continue
+		}
+		status, err := encryptRecord(ctx, req.Storage, encrypted, config.StorageBasePath+uuid)
+		if err != nil {
+			backendLogger.Error("encrypt user", "error", err, "uuid", uuid)
+			status, failures[uuid] = recordFailed, err.Error()
+		}
+		if status == "" {
+			continue
+		}
+		records[uuid] = status
+		counts[status]++
+	}
+
+	if counts[recordFailed] == 0 && storageConfig.VaultEncryption != helpers.VaultEncryptionEnabled {
+		storageConfig.VaultEncryption = helpers.VaultEncryptionEnabled
+		if err := b.putStorageConfig(ctx, req.Storage, storageConfig); err != nil {
+			backendLogger.Error("put storage config", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	backendLogger.Info("user records migrated", "encrypted", counts[recordEncrypted],
+		"alreadyEncrypted", counts[recordAlreadyEncrypted], "failed", counts[recordFailed],
+		"state", storageConfig.VaultEncryption)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"state":            storageConfig.VaultEncryption,
+			"keyId":            storage.KeyID(storageConfig.EncryptionKey),
+			"records":          records,
+			"errors":           failures,
+			"encrypted":        counts[recordEncrypted],
+			"alreadyEncrypted": counts[recordAlreadyEncrypted],
+			"failed":           counts[recordFailed],
+		},
+	}, nil
+}
+
+// putStorageConfig stores storageConfig and reopens the user store from it
+func (b *Backend) putStorageConfig(ctx context.Context, s logical.Storage, storageConfig *helpers.StorageConfig) error {
+	entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, storageConfig)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+	b.resetUserStorage()
+	return nil
+}
+
+// encryptRecord encrypts the user record at key of the Vault storage s and reads it back through
+// encrypted, restoring the plaintext if it does not decrypt to it. The status is empty for records
+// deleted in the meantime.
+func encryptRecord(ctx context.Context, s logical.Storage, encrypted *storage.Encrypted, key string) (string, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil || entry == nil {
+		return "", err
+	}
+	if _, err := encrypted.Get(ctx, key); err == nil {
+		return recordAlreadyEncrypted, nil
+	}
+	if !json.Valid(entry.Value) {
+		return "", helpers.ErrUnreadableRecord
+	}
+
+	if err := encrypted.Put(ctx, &logical.StorageEntry{Key: key, Value: entry.Value}); err != nil {
+		return "", err
+	}
+	decrypted, err := encrypted.Get(ctx, key)
+	if err == nil && decrypted != nil && bytes.Equal(decrypted.Value, entry.Value) {
+		return recordEncrypted, nil
+	}
+	return "", errors.Join(helpers.ErrRoundTrip, err, s.Put(ctx, entry))
+}
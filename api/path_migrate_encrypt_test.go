@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_HandleRequest_MigrateEncrypt(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+	require.NoError(t, s.Put(ctx, &logical.StorageEntry{Key: config.StorageBasePath + "broken", Value: []byte("{")}))
+
+	migrate := func(t *testing.T) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "migrate/encrypt",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+	readUser := func() (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "user/" + signTestUUID,
+			Storage:   s,
+		})
+	}
+
+	resp := migrate(t)
+	assert.Equal(t, helpers.VaultEncryptionMigrating, resp.Data["state"])
+	assert.Equal(t, map[string]interface{}{signTestUUID: recordEncrypted, "broken": recordFailed}, resp.Data["records"])
+	assert.Contains(t, resp.Data["errors"].(map[string]interface{})["broken"], helpers.ErrUnreadableRecord.Error())
+	assert.Equal(t, 1, resp.Data["encrypted"])
+	assert.Equal(t, 1, resp.Data["failed"])
+
+	raw, err := s.Get(ctx, config.StorageBasePath+signTestUUID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw.Value), "abandon")
+
+	t.Run("records in clear stay readable while migrating", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+		got, err := readUser()
+		require.NoError(t, err)
+		assert.Equal(t, signTestUUID, got.Data["uuid"])
+	})
+
+	require.NoError(t, s.Delete(ctx, config.StorageBasePath+"broken"))
+	resp = migrate(t)
+	assert.Equal(t, helpers.VaultEncryptionEnabled, resp.Data["state"])
+	assert.Equal(t, map[string]interface{}{signTestUUID: recordEncrypted}, resp.Data["records"])
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, s)
+	require.NoError(t, err)
+	assert.Equal(t, storage.KeyID(storageConfig.EncryptionKey), resp.Data["keyId"])
+
+	got, err := readUser()
+	require.NoError(t, err)
+	assert.Equal(t, signTestUUID, got.Data["uuid"])
+
+	t.Run("already encrypted records are skipped", func(t *testing.T) {
+		resp := migrate(t)
+		assert.Equal(t, map[string]interface{}{signTestUUID: recordAlreadyEncrypted}, resp.Data["records"])
+		assert.Equal(t, 0, resp.Data["encrypted"])
+	})
+
+	t.Run("records in clear are rejected once encrypted", func(t *testing.T) {
+		require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+		_, err := readUser()
+		require.Error(t, err)
+	})
+
+	t.Run("external store", func(t *testing.T) {
+		vault := &logical.InmemStorage{}
+		entry, err := logical.StorageEntryJSON(config.UserStoreStorageKey, &helpers.StorageConfig{
+			Type: storage.TypePostgres, ConnectionURL: "postgres://localhost/db", Table: "users",
+		})
+		require.NoError(t, err)
+		require.NoError(t, vault.Put(ctx, entry))
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "migrate/encrypt",
+			Storage:   vault,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrStoreAlwaysEncrypted.Error())
+	})
+}
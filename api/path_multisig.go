@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathListMultisig corresponds to LIST multisig/<uuid>.
+func (b *Backend) pathListMultisig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_multisig"))
+
+	names, err := req.Storage.List(ctx, config.MultisigStoragePath+d.Get("uuid").(string)+"/")
+	if err != nil {
+		backendLogger.Error("list multisig wallets", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(names), nil
+}
+
+// pathReadMultisig corresponds to READ multisig/<uuid>/<name>.
+func (b *Backend) pathReadMultisig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_multisig"))
+
+	wallet, err := helpers.GetMultisigWallet(ctx, req.Storage, d.Get("uuid").(string), d.Get("name").(string))
+	if err != nil {
+		backendLogger.Error("get multisig wallet", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if wallet == nil {
+		return nil, nil
+	}
+
+	data, err := multisigResponseData(wallet)
+	if err != nil {
+		backendLogger.Error("multisig descriptors", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return &logical.Response{Data: data}, nil
+}
+
+// pathWriteMultisig corresponds to UPDATE multisig/<uuid>/<name>. It registers a sortedmulti wallet
+// of the BIP-48 account key of the user and the cosigner keys. Registered wallets cannot be changed,
+// as funds may already be sent to their addresses.
+func (b *Backend) pathWriteMultisig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_write_multisig"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	name := d.Get("name").(string)
+	threshold := d.Get("threshold").(int)
+	scriptType := d.Get("scriptType").(string)
+	account := d.Get("account").(int)
+	isDev := d.Get("isDev").(bool)
+	cosigners := d.Get("cosigners").([]string)
+	if account < 0 || account > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidAccount.Error())
+	}
+
+	existing, err := helpers.GetMultisigWallet(ctx, req.Storage, uuid, name)
+	if err != nil {
+		backendLogger.Error("get multisig wallet", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if existing != nil {
+		backendLogger.Error("multisig wallet exists", "uuid", uuid, "name", name)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMultisigExists.Error())
+	}
+
+	userInfo, err := authorizeMultisigUser(ctx, req, uuid, isDev)
+	if err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, err
+	}
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	path, err := bitcoin.MultisigAccountPath(scriptType, uint32(account), isDev)
+	if err != nil {
+		backendLogger.Error("account path", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		backendLogger.Error("master fingerprint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	xpub, err := lib.ExtendedPublicKeyAt(seed, path, multisigNet(isDev))
+	if err != nil {
+		backendLogger.Error("extended public key", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	wallet := &helpers.MultisigWallet{
+		Name:       name,
+		UUID:       uuid,
+		Threshold:  threshold,
+		ScriptType: scriptType,
+		Account:    uint32(account),
+		IsDev:      isDev,
+		VaultKey:   "[" + fingerprint + "/" + lib.FormatOriginPath(path) + "]" + xpub.String(),
+		Cosigners:  cosigners,
+		CreatedAt:  time.Now().UTC(),
+	}
+	// the cosigner keys are validated with the wallet
+	data, err := multisigResponseData(wallet)
+	if err != nil {
+		backendLogger.Error("create multisig wallet", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.PutMultisigWallet(ctx, req.Storage, wallet); err != nil {
+		backendLogger.Error("put multisig wallet", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("multisig wallet registered", "uuid", uuid, "name", name,
+		"threshold", threshold, "keys", len(cosigners)+1, "scriptType", scriptType)
+
+	return &logical.Response{Data: data}, nil
+}
+
+// pathDeleteMultisig corresponds to DELETE multisig/<uuid>/<name>.
+func (b *Backend) pathDeleteMultisig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_multisig"))
+
+	uuid, name := d.Get("uuid").(string), d.Get("name").(string)
+	if err := helpers.DeleteMultisigWallet(ctx, req.Storage, uuid, name); err != nil {
+		backendLogger.Error("delete multisig wallet", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("multisig wallet deleted", "uuid", uuid, "name", name)
+
+	return nil, nil
+}
+
+// pathMultisigAddress corresponds to UPDATE multisig/<uuid>/<name>/address. It returns the address
+// of the multisig wallet at change/index with its witness script.
+func (b *Backend) pathMultisigAddress(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_multisig_address"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	change := d.Get("change").(int)
+	index := d.Get("index").(int)
+	if (change != 0 && change != 1) || index < 0 || index > math.MaxInt32 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidPath.Error())
+	}
+
+	wallet, multisig, err := b.getMultisig(ctx, req, d, backendLogger)
+	if err != nil {
+		return nil, err
+	}
+	address, witnessScript, err := multisig.Address(uint32(change), uint32(index), multisigNet(wallet.IsDev))
+	if err != nil {
+		backendLogger.Error("multisig address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("multisig address derived", "uuid", wallet.UUID, "name", wallet.Name,
+		"change", change, "index", index, "address", address)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"address":       address,
+			"witnessScript": hex.EncodeToString(witnessScript),
+			"change":        change,
+			"index":         index,
+		},
+	}, nil
+}
+
+// pathSignMultisig corresponds to UPDATE multisig/<uuid>/<name>/sign. It adds the partial signature
+// of the user to the PSBT inputs spending addresses of the multisig wallet.
+func (b *Backend) pathSignMultisig(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_multisig"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	packet, err := bitcoin.DecodePSBT(d.Get("psbt").(string))
+	if err != nil {
+		backendLogger.Error("decode psbt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	wallet, multisig, err := b.getMultisig(ctx, req, d, backendLogger)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := authorizeMultisigUser(ctx, req, wallet.UUID, wallet.IsDev)
+	if err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, err
+	}
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// the outputs paying an address of the wallet back are its change, not recipients
+	if err := checkPSBTRecipients(ctx, req.Storage, bitcoinCoinType(wallet.IsDev), d.Get("psbt").(string),
+		wallet.IsDev, func() ([]int, error) { return multisig.Outputs(packet) }); err != nil {
+		backendLogger.Error("check address book", "error", err)
+		return nil, err
+	}
+
+	signed, err := bitcoin.SignMultisig(seed, packet, multisig)
+	if err != nil {
+		backendLogger.Error("sign multisig psbt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	encoded, err := packet.Base64()
+	if err != nil {
+		backendLogger.Error("encode psbt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("signed multisig psbt", "uuid", wallet.UUID, "name", wallet.Name, "signedInputs", signed)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"psbt":         encoded,
+			"signedInputs": signed,
+		},
+	}, nil
+}
+
+// getMultisig reads the multisig wallet of the request, whose network must be the one of isDev
+func (b *Backend) getMultisig(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	backendLogger *slog.Logger) (*helpers.MultisigWallet, *bitcoin.Multisig, error) {
+	uuid, name := d.Get("uuid").(string), d.Get("name").(string)
+	wallet, err := helpers.GetMultisigWallet(ctx, req.Storage, uuid, name)
+	if err != nil {
+		backendLogger.Error("get multisig wallet", "error", err)
+		return nil, nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if wallet == nil {
+		backendLogger.Error("multisig wallet not found", "uuid", uuid, "name", name)
+		return nil, nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMultisigNotFound.Error())
+	}
+	if wallet.IsDev != d.Get("isDev").(bool) {
+		backendLogger.Error("multisig network", "isDev", wallet.IsDev)
+		return nil, nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMultisigNetwork.Error())
+	}
+
+	multisig, err := bitcoin.NewMultisig(wallet.Threshold, wallet.ScriptType, wallet.Keys())
+	if err != nil {
+		backendLogger.Error("multisig wallet", "error", err)
+		return nil, nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return wallet, multisig, nil
+}
+
+// authorizeMultisigUser returns the user uuid when allowed to use Bitcoin, or testnet when isDev
+func authorizeMultisigUser(ctx context.Context, req *logical.Request, uuid string, isDev bool) (*helpers.User, error) {
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	coinType := uint16(slip44.Bitcoin)
+	if isDev {
+		coinType = slip44.TestNet
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	return userInfo, nil
+}
+
+// multisigResponseData describes wallet with the receive and change descriptors of its addresses
+func multisigResponseData(wallet *helpers.MultisigWallet) (map[string]interface{}, error) {
+	multisig, err := bitcoin.NewMultisig(wallet.Threshold, wallet.ScriptType, wallet.Keys())
+	if err != nil {
+		return nil, err
+	}
+	receive, err := multisig.Descriptor(0)
+	if err != nil {
+		return nil, err
+	}
+	change, err := multisig.Descriptor(1)
+	if err != nil {
+		return nil, err
+	}
+
+	cosigners := wallet.Cosigners
+	if cosigners == nil {
+		cosigners = []string{}
+	}
+	return map[string]interface{}{
+		"name":        wallet.Name,
+		"threshold":   wallet.Threshold,
+		"scriptType":  wallet.ScriptType,
+		"account":     wallet.Account,
+		"isDev":       wallet.IsDev,
+		"vaultKey":    wallet.VaultKey,
+		"cosigners":   cosigners,
+		"descriptors": lib.Descriptors{Receive: receive, Change: change},
+		"createdAt":   formatTime(wallet.CreatedAt),
+	}, nil
+}
+
+// multisigNet returns the network of the multisig wallets of isDev
+func multisigNet(isDev bool) *chaincfg.Params {
+	if isDev {
+		return &chaincfg.TestNet3Params
+	}
+	return &chaincfg.MainNetParams
+}
+
+// signMultisigIntent is the intent of a multisig/<uuid>/<name>/sign request, the outputs of the PSBT
+func signMultisigIntent(d *framework.FieldData) (*signIntent, error) {
+	return psbtIntent("multisig/sign", d, []string{d.Get("uuid").(string)})
+}
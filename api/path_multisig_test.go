@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+)
+
+// Helper function to create a proper framework.FieldData for the multisig/<uuid>/<name> endpoints
+func createMultisigFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":       {Type: framework.TypeString},
+			"name":       {Type: framework.TypeString},
+			"threshold":  {Type: framework.TypeInt},
+			"cosigners":  {Type: framework.TypeStringSlice},
+			"scriptType": {Type: framework.TypeString, Default: bitcoin.MultisigP2WSH},
+			"account":    {Type: framework.TypeInt, Default: 0},
+			"isDev":      {Type: framework.TypeBool, Default: false},
+			"change":     {Type: framework.TypeInt, Default: 0},
+			"index":      {Type: framework.TypeInt, Default: 0},
+			"psbt":       {Type: framework.TypeString},
+		},
+	}
+}
+
+// cosignerKey returns the BIP-48 p2wsh account key expression of the test mnemonic with passphrase
+func cosignerKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	seed, err := lib.SeedFromMnemonic(signTestValidMnemonic, passphrase)
+	require.NoError(t, err)
+	path, err := bitcoin.MultisigAccountPath(bitcoin.MultisigP2WSH, 0, false)
+	require.NoError(t, err)
+	xpub, err := lib.ExtendedPublicKeyAt(seed, path, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	fingerprint, err := lib.MasterFingerprint(seed)
+	require.NoError(t, err)
+	return "[" + fingerprint + "/" + lib.FormatOriginPath(path) + "]" + xpub.String()
+}
+
+func TestBackend_PathMultisig(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	b := createSignTestBackend(t)
+	call := func(op framework.OperationFunc, data map[string]interface{}) (*logical.Response, error) {
+		return op(ctx, &logical.Request{Storage: s, Data: data}, createMultisigFieldData(data))
+	}
+	cosigners := []string{cosignerKey(t, "b"), cosignerKey(t, "c")}
+
+	t.Run("register", func(t *testing.T) {
+		got, err := call(b.pathWriteMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "threshold": 2, "cosigners": cosigners,
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(got.Data["vaultKey"].(string), "["+userTestFingerprint+"/48h/0h/0h/2h]xpub"))
+		descriptors := got.Data["descriptors"].(lib.Descriptors)
+		assert.True(t, strings.HasPrefix(descriptors.Receive, "wsh(sortedmulti(2,"), descriptors.Receive)
+		assert.Contains(t, descriptors.Change, cosigners[1]+"/1/*")
+	})
+
+	t.Run("registered wallets cannot be changed", func(t *testing.T) {
+		_, err := call(b.pathWriteMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "threshold": 1, "cosigners": cosigners,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrMultisigExists.Error())
+	})
+
+	t.Run("invalid wallets", func(t *testing.T) {
+		for name, data := range map[string]map[string]interface{}{
+			bitcoin.ErrMultisigThreshold.Error(): {"threshold": 4, "cosigners": cosigners},
+			bitcoin.ErrMultisigKey.Error():       {"threshold": 2, "cosigners": []string{cosigners[0] + "/0/*"}},
+			bitcoin.ErrUnsupportedScriptType.Error(): {
+				"threshold": 2, "cosigners": cosigners, "scriptType": "p2tr",
+			},
+		} {
+			data["uuid"], data["name"] = signTestUUID, "invalid"
+			_, err := call(b.pathWriteMultisig, data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+		}
+		stored, err := helpers.GetMultisigWallet(ctx, s, signTestUUID, "invalid")
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("read and list", func(t *testing.T) {
+		got, err := call(b.pathReadMultisig, map[string]interface{}{"uuid": signTestUUID, "name": "treasury"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, got.Data["threshold"])
+		assert.Equal(t, cosigners, got.Data["cosigners"])
+
+		list, err := call(b.pathListMultisig, map[string]interface{}{"uuid": signTestUUID})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"treasury"}, list.Data["keys"])
+	})
+
+	var address string
+	t.Run("address", func(t *testing.T) {
+		got, err := call(b.pathMultisigAddress, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "index": 5,
+		})
+		require.NoError(t, err)
+		address = got.Data["address"].(string)
+		assert.True(t, strings.HasPrefix(address, "bc1q"))
+		assert.NotEmpty(t, got.Data["witnessScript"])
+
+		_, err = call(b.pathMultisigAddress, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "isDev": true,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrMultisigNetwork.Error())
+
+		_, err = call(b.pathMultisigAddress, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "change": 2,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("sign", func(t *testing.T) {
+		decoded, err := btcutil.DecodeAddress(address, &chaincfg.MainNetParams)
+		require.NoError(t, err)
+		script, err := txscript.PayToAddrScript(decoded)
+		require.NoError(t, err)
+
+		got, err := call(b.pathSignMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "psbt": newTestScriptPSBT(t, script),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, got.Data["signedInputs"])
+
+		// the outputs paying the wallet back are its change, the others must be in the address book
+		require.NoError(t, helpers.PutAddressBookPolicy(ctx, s, &helpers.AddressBookPolicy{
+			RequiredTags: map[uint16]string{0: helpers.AnyAddressBookTag},
+		}))
+		got, err = call(b.pathSignMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury", "psbt": newTestScriptPSBT(t, script),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, got.Data["signedInputs"])
+		_, err = call(b.pathSignMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "treasury",
+			"psbt": newTestScriptPSBT(t, newTestP2WPKHScript(t, signTestValidMnemonic, "m/84'/0'/0'/0/0"), script),
+		})
+		require.ErrorContains(t, err, helpers.ErrRecipientNotInBook.Error())
+		require.NoError(t, helpers.DeleteAddressBookPolicy(ctx, s))
+
+		_, err = call(b.pathSignMultisig, map[string]interface{}{
+			"uuid": signTestUUID, "name": "unknown", "psbt": newTestScriptPSBT(t, script),
+		})
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusUnprocessableEntity, codedErr.Code())
+		assert.Contains(t, err.Error(), helpers.ErrMultisigNotFound.Error())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := call(b.pathDeleteMultisig, map[string]interface{}{"uuid": signTestUUID, "name": "treasury"})
+		require.NoError(t, err)
+		got, err := call(b.pathReadMultisig, map[string]interface{}{"uuid": signTestUUID, "name": "treasury"})
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
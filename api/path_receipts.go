@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+)
+
+// pathReadReceipt corresponds to READ receipts/<id>.
+func (b *Backend) pathReadReceipt(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_receipt"))
+
+	receipt, err := helpers.GetReceipt(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		backendLogger.Error("get receipt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if receipt == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: receiptResponseData(receipt),
+	}, nil
+}
+
+func receiptResponseData(receipt *helpers.Receipt) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":            receipt.ID,
+		"sequence":      receipt.Sequence,
+		"uuid":          receipt.UUID,
+		"coinType":      receipt.CoinType,
+		"path":          receipt.Path,
+		"address":       receipt.Address,
+		"payloadHash":   receipt.PayloadHash,
+		"signatureHash": receipt.SignatureHash,
+		"entityId":      receipt.EntityID,
+		"createdAt":     formatTime(receipt.CreatedAt),
+	}
+	if receipt.ApprovalID != "" {
+		data["approvalId"] = receipt.ApprovalID
+	}
+	if receipt.Memo != "" {
+		data["memo"] = receipt.Memo
+	}
+	return data
+}
+
+// withReceipt records a receipt of every signature of sign and returns it with the signature. The
+// receipt is stored before the signature is returned: a signature whose receipt cannot be stored
+// is not returned.
+func (b *Backend) withReceipt(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "receipt"))
+
+		resp, err := op(ctx, req, d)
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		signature, ok := resp.Data["signature"].(string)
+		if !ok {
+			return resp, nil
+		}
+
+		receipt := &helpers.Receipt{
+			ID:            helpers.NewUUID(),
+			UUID:          d.Get("uuid").(string),
+			CoinType:      uint16(d.Get("coinType").(int)),
+			Path:          d.Get("derivationPath").(string),
+			SignatureHash: sha256Hex(signature),
+			EntityID:      req.EntityID,
+			CreatedAt:     time.Now().UTC(),
+		}
+		if path, ok := resp.Data["path"].(string); ok {
+			receipt.Path = path
+		}
+		receipt.Address, _ = resp.Data["address"].(string)
+		receipt.PayloadHash, _ = resp.Data["payloadHash"].(string)
+		if approvalID, ok := d.GetOk("approvalId"); ok {
+			receipt.ApprovalID = approvalID.(string)
+		}
+		receipt.Memo = approval.Memo(receipt.CoinType, d.Get("payload").(string))
+
+		// the sequence is advanced and the receipt stored under the lock, so the order of the
+		// sequence numbers is the order of the stored receipts
+		b.receiptMu.Lock()
+		receipt.Sequence, err = helpers.NextReceiptSequence(ctx, req.Storage)
+		if err == nil {
+			err = helpers.PutReceipt(ctx, req.Storage, receipt)
+		}
+		b.receiptMu.Unlock()
+		if err != nil {
+			backendLogger.Error("put receipt", "error", err, "uuid", receipt.UUID)
+			return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+		}
+
+		backendLogger.Info("receipt", "receiptId", receipt.ID, "sequence", receipt.Sequence, "uuid", receipt.UUID,
+			"coinType", receipt.CoinType, "address", receipt.Address, "payloadHash", receipt.PayloadHash,
+			"entity", req.EntityID)
+		receiptData := receiptResponseData(receipt)
+		b.queueEvent(ctx, req.Storage, &eventsink.Event{
+			Type:     eventsink.TypeSign,
+			Path:     req.Path,
+			UUID:     receipt.UUID,
+			EntityID: req.EntityID,
+			Data:     receiptData,
+		})
+		resp.Data["receipt"] = receiptData
+		return resp, nil
+	}
+}
+
+// sha256Hex returns the hex SHA-256 of value
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend_HandleRequest_Receipts(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: "entity-1",
+		})
+	}
+	sign := func() *logical.Response {
+		resp, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": signTestPayload,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	first := sign()
+	receipt, ok := first.Data["receipt"].(map[string]interface{})
+	require.True(t, ok)
+	signature := first.Data["signature"].(string)
+	assert.Equal(t, uint64(1), receipt["sequence"])
+	assert.Equal(t, signTestUUID, receipt["uuid"])
+	assert.Equal(t, uint16(60), receipt["coinType"])
+	assert.Equal(t, signTestDerivationPath, receipt["path"])
+	assert.Equal(t, first.Data["address"], receipt["address"])
+	assert.Equal(t, sha256Hex(signTestPayload), receipt["payloadHash"])
+	assert.Equal(t, sha256Hex(signature), receipt["signatureHash"])
+	assert.Equal(t, "entity-1", receipt["entityId"])
+	assert.NotContains(t, receipt, "approvalId")
+
+	t.Run("receipts are numbered in signing order", func(t *testing.T) {
+		second := sign()
+		assert.Equal(t, uint64(2), second.Data["receipt"].(map[string]interface{})["sequence"])
+		assert.NotEqual(t, receipt["id"], second.Data["receipt"].(map[string]interface{})["id"])
+	})
+
+	t.Run("read returns the stored receipt", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "receipts/"+receipt["id"].(string), nil)
+		require.NoError(t, err)
+		assert.Equal(t, receipt, resp.Data)
+	})
+
+	t.Run("unknown receipts are not found", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "receipts/00000000000000000000", nil)
+		require.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("failed signatures have no receipt", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": signTestDerivationPath, "payload": "{}",
+		})
+		require.Error(t, err)
+		resp := sign()
+		assert.Equal(t, uint64(3), resp.Data["receipt"].(map[string]interface{})["sequence"])
+	})
+}
@@ -2,22 +2,27 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
 	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/passphrase"
+	"github.com/payment-system/dq-vault/lib/slip44"
 )
 
 // pathPassphrase corresponds to POST gen/passphrase.
 func (b *Backend) pathRegister(ctx context.Context, req *logical.Request,
 	d *framework.FieldData) (*logical.Response, error) {
-	var err error
 	backendLogger := b.logger.With(slog.String("op", "path_register"))
-	if err = helpers.ValidateFields(req, d); err != nil {
+	if err := helpers.ValidateFields(req, d); err != nil {
 		backendLogger.Error("validate fields", "error", err)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
@@ -25,13 +30,6 @@ func (b *Backend) pathRegister(ctx context.Context, req *logical.Request,
 	// obtain username
 	username := d.Get("username").(string)
 
-	// obtain mnemonic and passphrase of user
-	mnemonic := d.Get("mnemonic").(string)
-	passphrase := d.Get("passphrase").(string)
-
-	// default entropy length
-	entropyLength := config.Entropy
-
 	// obtain UUID (required)
 	uuid := d.Get("uuid").(string)
 	if uuid == "" {
@@ -45,61 +43,30 @@ func (b *Backend) pathRegister(ctx context.Context, req *logical.Request,
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, "UUID already exists")
 	}
 
-	// generated storage path to store user info
-	storagePath := config.StorageBasePath + uuid
-
-	if mnemonic == "" {
-		// generate new mnemonics if not provided by user
-		// obtain mnemonics from entropy
-		mnemonic, err = lib.MnemonicFromEntropy(entropyLength)
-		if err != nil {
-			backendLogger.Error("generate mnemonic", "error", err)
-			return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
-		}
-	}
-
-	// check if mnemonic is valid or not
-	if !lib.IsMnemonicValid(mnemonic) {
-		backendLogger.Error("invalid mnemonic", "mnemonic", mnemonic)
-		return nil, logical.CodedError(http.StatusExpectationFailed, "Invalid Mnemonic")
-	}
-
-	// create object to store user information
-	user := &helpers.User{
-		Username:   username,
-		UUID:       uuid,
-		Mnemonic:   mnemonic,
-		Passphrase: passphrase,
+	if xpub := d.Get("xpub").(string); xpub != "" {
+		return b.registerWatchOnly(ctx, req, d, backendLogger, uuid, xpub)
 	}
 
-	// creates strorage entry with user JSON encoded value
-	store, err := logical.StorageEntryJSON(storagePath, user)
+	user, mnemonic, err := b.newMnemonicUser(ctx, req, d, backendLogger, uuid)
 	if err != nil {
-		backendLogger.Error("create storage entry", "error", err)
-		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+		return nil, err
 	}
-
-	// put user information in store
-	if err = req.Storage.Put(ctx, store); err != nil {
-		backendLogger.Error("put user information", "error", err)
-		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+	if err := b.putNewUser(ctx, req, backendLogger, user); err != nil {
+		return nil, err
 	}
 
 	backendLogger.Info("user registered", "username", username)
 
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"uuid": uuid,
-		},
+		Data: exportMnemonic(registerResponseData(user), d, mnemonic),
 	}, nil
 }
 
 // pathRegisterUUID corresponds to POST register_uuid with auto-generated UUID.
 func (b *Backend) pathRegisterUUID(ctx context.Context, req *logical.Request,
 	d *framework.FieldData) (*logical.Response, error) {
-	var err error
 	backendLogger := b.logger.With(slog.String("op", "path_register_uuid"))
-	if err = helpers.ValidateFields(req, d); err != nil {
+	if err := helpers.ValidateFields(req, d); err != nil {
 		backendLogger.Error("validate fields", "error", err)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
@@ -107,64 +74,270 @@ func (b *Backend) pathRegisterUUID(ctx context.Context, req *logical.Request,
 	// obtain username
 	username := d.Get("username").(string)
 
-	// obtain mnemonic and passphrase of user
-	mnemonic := d.Get("mnemonic").(string)
-	passphrase := d.Get("passphrase").(string)
-
-	// default entropy length
-	entropyLength := config.Entropy
-
 	// Auto-generate UUID and ensure it's unique
 	uuid := helpers.NewUUID()
 	for helpers.UUIDExists(ctx, req, uuid) {
 		uuid = helpers.NewUUID()
 	}
 
-	// generated storage path to store user info
-	storagePath := config.StorageBasePath + uuid
+	if xpub := d.Get("xpub").(string); xpub != "" {
+		return b.registerWatchOnly(ctx, req, d, backendLogger, uuid, xpub)
+	}
+
+	user, mnemonic, err := b.newMnemonicUser(ctx, req, d, backendLogger, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.putNewUser(ctx, req, backendLogger, user); err != nil {
+		return nil, err
+	}
 
+	backendLogger.Info("user registered with auto-generated UUID", "username", username, "uuid", uuid)
+
+	return &logical.Response{
+		Data: exportMnemonic(registerResponseData(user), d, mnemonic),
+	}, nil
+}
+
+// newMnemonicUser creates the user uuid of the mnemonic of the request, generating one when none
+// is given, with its owner, expiry and passphrase verifier. It returns the mnemonic for its export;
+// errors are coded.
+func (b *Backend) newMnemonicUser(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	backendLogger *slog.Logger, uuid string) (*helpers.User, string, error) {
+	mnemonic := d.Get("mnemonic").(string)
+	if err := checkMnemonicExport(d, mnemonic); err != nil {
+		backendLogger.Error("validate mnemonic export", "error", err)
+		return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
 	if mnemonic == "" {
 		// generate new mnemonics if not provided by user
 		// obtain mnemonics from entropy
-		mnemonic, err = lib.MnemonicFromEntropy(entropyLength)
-		if err != nil {
+		var err error
+		if mnemonic, err = b.generateMnemonic(ctx, req.Storage, config.Entropy); err != nil {
 			backendLogger.Error("generate mnemonic", "error", err)
-			return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+			return nil, "", logical.CodedError(http.StatusExpectationFailed, err.Error())
 		}
 	}
 
 	// check if mnemonic is valid or not
 	if !lib.IsMnemonicValid(mnemonic) {
-		backendLogger.Error("invalid mnemonic", "mnemonic", mnemonic)
-		return nil, logical.CodedError(http.StatusExpectationFailed, "Invalid Mnemonic")
+		backendLogger.Error("invalid mnemonic", "words", len(strings.Fields(mnemonic)))
+		return nil, "", logical.CodedError(http.StatusExpectationFailed, "Invalid Mnemonic")
+	}
+
+	allowedCoinTypes, err := coinTypesFromField(d, "allowedCoinTypes")
+	if err != nil {
+		backendLogger.Error("validate allowed coin types", "error", err)
+		return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
 
 	// create object to store user information
-	user := &helpers.User{
-		Username:   username,
-		UUID:       uuid,
-		Mnemonic:   mnemonic,
-		Passphrase: passphrase,
+	user, err := helpers.NewUser(uuid, d.Get("username").(string), mnemonic, d.Get("passphrase").(string),
+		allowedCoinTypes)
+	if err != nil {
+		backendLogger.Error("create user", "error", err)
+		return nil, "", logical.CodedError(http.StatusExpectationFailed, err.Error())
 	}
 
-	// creates strorage entry with user JSON encoded value
-	store, err := logical.StorageEntryJSON(storagePath, user)
+	if namespace, ok := d.GetOk("derivationNamespace"); ok && namespace.(bool) {
+		if err := user.SetDerivationNamespace(); err != nil {
+			backendLogger.Error("set derivation namespace", "error", err)
+			return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	if err := setUserOwner(user, req, d); err != nil {
+		backendLogger.Error("set user owner", "error", err)
+		return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := setUserExpiry(user, d); err != nil {
+		backendLogger.Error("set user expiry", "error", err)
+		return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := applyPassphrasePolicy(ctx, req.Storage, user); err != nil {
+		backendLogger.Error("apply passphrase policy", "error", err)
+		return nil, "", logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := checkExpectedAddress(ctx, backendLogger, d, user); err != nil {
+		return nil, "", err
+	}
+	return user, mnemonic, nil
+}
+
+// putNewUser escrows the mnemonic of the new user and stores it; errors are coded
+func (b *Backend) putNewUser(ctx context.Context, req *logical.Request, backendLogger *slog.Logger,
+	user *helpers.User) error {
+	// the escrow record is written first, so no user is stored without one while escrow is enabled
+	if _, err := b.escrowMnemonic(ctx, req.Storage, user); err != nil {
+		backendLogger.Error("escrow mnemonic", "error", err)
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// put user information in store, as its first version
+	if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
+		backendLogger.Error("put user information", "error", err)
+		return logical.CodedError(http.StatusExpectationFailed, err.Error())
+	}
+	return nil
+}
+
+// registerWatchOnly stores the watch-only user of xpub, for pathRegister and pathRegisterUUID
+func (b *Backend) registerWatchOnly(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	backendLogger *slog.Logger, uuid, xpub string) (*logical.Response, error) {
+	if d.Get("mnemonic").(string) != "" || d.Get("passphrase").(string) != "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrXpubWithMnemonic.Error())
+	}
+	if namespace, ok := d.GetOk("derivationNamespace"); ok && namespace.(bool) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrNamespaceWatchOnly.Error())
+	}
+	allowedCoinTypes, err := coinTypesFromField(d, "allowedCoinTypes")
 	if err != nil {
-		backendLogger.Error("create storage entry", "error", err)
-		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
+		backendLogger.Error("validate allowed coin types", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	username := d.Get("username").(string)
+	user, err := helpers.NewWatchOnlyUser(uuid, username, xpub, d.Get("xpubPath").(string),
+		d.Get("fingerprint").(string), allowedCoinTypes)
+	if err != nil {
+		backendLogger.Error("create watch-only user", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := setUserOwner(user, req, d); err != nil {
+		backendLogger.Error("set user owner", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := setUserExpiry(user, d); err != nil {
+		backendLogger.Error("set user expiry", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := checkExpectedAddress(ctx, backendLogger, d, user); err != nil {
+		return nil, err
 	}
 
-	// put user information in store
-	if err = req.Storage.Put(ctx, store); err != nil {
+	if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
 		backendLogger.Error("put user information", "error", err)
 		return nil, logical.CodedError(http.StatusExpectationFailed, err.Error())
 	}
 
-	backendLogger.Info("user registered with auto-generated UUID", "username", username, "uuid", uuid)
+	backendLogger.Info("watch-only user registered", "username", username, "uuid", uuid, "xpubPath", user.XpubPath)
 
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"uuid": uuid,
-		},
+		Data: registerResponseData(user),
 	}, nil
 }
+
+// coinTypesFromField reads an optional list of supported coin types such as allowedCoinTypes
+func coinTypesFromField(d *framework.FieldData, field string) ([]uint16, error) {
+	raw, ok := d.GetOk(field)
+	if !ok {
+		return nil, nil
+	}
+
+	coinTypes := make([]uint16, 0, len(raw.([]int)))
+	for _, coinType := range raw.([]int) {
+		if coinType < 0 || coinType > math.MaxUint16 || !slip44.IsSupportedCoinType(uint16(coinType)) {
+			return nil, fmt.Errorf("%w: %d", helpers.ErrUnsupportedCoinType, coinType)
+		}
+		coinTypes = append(coinTypes, uint16(coinType))
+	}
+	return coinTypes, nil
+}
+
+// setUserOwner records the entity and token registering user and the optional restrictToOwner,
+// restrictManagement and managerEntityIds fields; managers imply restrictManagement
+func setUserOwner(user *helpers.User, req *logical.Request, d *framework.FieldData) error {
+	user.OwnerEntityID = req.EntityID
+	user.OwnerDisplayName = req.DisplayName
+	user.OwnerAccessor = req.ClientTokenAccessor
+
+	if restrict, ok := d.GetOk("restrictToOwner"); ok && restrict.(bool) {
+		if req.EntityID == "" {
+			return helpers.ErrNoRequestEntity
+		}
+		user.RestrictToOwner = true
+	}
+
+	if managers, ok := d.GetOk("managerEntityIds"); ok {
+		user.ManagerEntityIDs = managers.([]string)
+	}
+	if restrict, ok := d.GetOk("restrictManagement"); (ok && restrict.(bool)) || len(user.ManagerEntityIDs) > 0 {
+		if req.EntityID == "" {
+			return helpers.ErrNoManagementEntity
+		}
+		user.RestrictManagement = true
+	}
+	return nil
+}
+
+// applyPassphrasePolicy rejects the passphrase of a new user weaker than config/passphrase, and
+// stores its verifier when the policy asks for one
+func applyPassphrasePolicy(ctx context.Context, s logical.Storage, user *helpers.User) error {
+	policy, err := helpers.GetPassphrasePolicy(ctx, s)
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(user.Passphrase); err != nil {
+		return err
+	}
+	if policy.Verifier != "" {
+		if user.PassphraseVerifier, err = passphrase.NewVerifier(policy.Verifier, user.Passphrase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUserExpiry sets when the periodic function disables the user from the optional ttl field
+func setUserExpiry(user *helpers.User, d *framework.FieldData) error {
+	ttl, ok := d.GetOk("ttl")
+	if !ok {
+		return nil
+	}
+	if ttl.(int) <= 0 {
+		return helpers.ErrInvalidTTL
+	}
+	user.ExpiresAt = user.CreatedAt.Add(time.Duration(ttl.(int)) * time.Second)
+	return nil
+}
+
+// checkMnemonicExport checks the exportMnemonic of the request: only the mnemonics generated by the
+// engine are exported, and only encrypted, so the request must carry a responsePublicKey
+func checkMnemonicExport(d *framework.FieldData, mnemonic string) error {
+	if export, ok := d.GetOk("exportMnemonic"); !ok || !export.(bool) {
+		return nil
+	}
+	if mnemonic != "" {
+		return helpers.ErrMnemonicNotGenerated
+	}
+	if key, ok := d.GetOk("responsePublicKey"); !ok || key.(string) == "" {
+		return helpers.ErrResponseKeyRequired
+	}
+	return nil
+}
+
+// exportMnemonic adds the mnemonic to the response data when the request asked for its export, for
+// handleRequest to encrypt it to the responsePublicKey of the request
+func exportMnemonic(data map[string]interface{}, d *framework.FieldData, mnemonic string) map[string]interface{} {
+	if export, ok := d.GetOk("exportMnemonic"); ok && export.(bool) {
+		data["mnemonic"] = mnemonic
+	}
+	return data
+}
+
+func registerResponseData(user *helpers.User) map[string]interface{} {
+	data := map[string]interface{}{
+		"uuid":        user.UUID,
+		"fingerprint": user.Fingerprint,
+		"version":     user.Version,
+	}
+	if !user.ExpiresAt.IsZero() {
+		data["expiresAt"] = formatTime(user.ExpiresAt)
+	}
+	if user.WatchOnly() {
+		data["watchOnly"] = true
+	}
+	if user.NamespaceIndex != nil {
+		data["namespaceIndex"] = *user.NamespaceIndex
+	}
+	return data
+}
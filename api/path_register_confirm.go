@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+// pathRegisterInit corresponds to POST register/init. The user is stored pending, so it cannot
+// derive or sign until register/confirm activates it once the client checked its fingerprint and
+// the addresses of verifyCoinTypes; unconfirmed users are purged after pendingRegistrationTTL.
+func (b *Backend) pathRegisterInit(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_register_init"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	switch {
+	case uuid == "":
+		uuid = helpers.NewUUID()
+		for helpers.UUIDExists(ctx, req, uuid) {
+			uuid = helpers.NewUUID()
+		}
+	case helpers.UUIDExists(ctx, req, uuid):
+		backendLogger.Error("validate uuid", "error", "UUID already exists")
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, "UUID already exists")
+	}
+	verifyCoinTypes, err := coinTypesFromField(d, "verifyCoinTypes")
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	user, mnemonic, err := b.newMnemonicUser(ctx, req, d, backendLogger, uuid)
+	if err != nil {
+		return nil, err
+	}
+	user.Status = helpers.UserStatusPending
+	addresses, err := registrationAddresses(ctx, backendLogger, user, verifyCoinTypes, d.Get("isDev").(bool))
+	if err != nil {
+		return nil, err
+	}
+	if err := b.putNewUser(ctx, req, backendLogger, user); err != nil {
+		return nil, err
+	}
+
+	backendLogger.Info("pending user registered", "uuid", uuid, "fingerprint", user.Fingerprint)
+
+	data := exportMnemonic(registerResponseData(user), d, mnemonic)
+	data["status"] = user.Status
+	data["confirmBy"] = formatTime(user.CreatedAt.Add(pendingRegistrationTTL))
+	data["addresses"] = addresses
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// registrationAddresses derives the address of user for each of coinTypes at the path of
+// dev/vectors, for the client to compare with the addresses it derives from the mnemonic
+func registrationAddresses(ctx context.Context, backendLogger *slog.Logger, user *helpers.User,
+	coinTypes []uint16, isDev bool) ([]map[string]interface{}, error) {
+	addresses := make([]map[string]interface{}, 0, len(coinTypes))
+	if len(coinTypes) == 0 {
+		return addresses, nil
+	}
+	seed, err := userSeed(ctx, user)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	for _, coinType := range coinTypes {
+		if len(user.AllowedCoinTypes) > 0 && !slices.Contains(user.AllowedCoinTypes, coinType) {
+			return nil, helpers.CodedError(http.StatusUnprocessableEntity,
+				fmt.Errorf("%w: %d", helpers.ErrCoinTypeNotAllowed, coinType))
+		}
+		capabilities, err := adapterInventory.CoinCapabilities(coinType)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %d", err, coinType))
+		}
+		path := devVectorPath(capabilities, coinType)
+		address, err := adapterInventory.DeriveAddress(seed, coinType, path, isDev)
+		if err != nil {
+			backendLogger.Error("derive address", "error", err, "coinType", coinType)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		addresses = append(addresses, map[string]interface{}{
+			"coinType": int(coinType),
+			"path":     path,
+			"address":  address,
+		})
+	}
+	return addresses, nil
+}
+
+// pathRegisterConfirm corresponds to POST register/confirm. It activates the pending user uuid
+// when fingerprint is the one of its registration; on a mismatch the user stays pending, to be
+// deleted with user/<uuid>.
+func (b *Backend) pathRegisterConfirm(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_register_confirm"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeManagement(req.EntityID); err != nil {
+		backendLogger.Warn("registration confirmation rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if err := user.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Warn("registration confirmation rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if err := checkUserVersion(d, user); err != nil {
+		backendLogger.Warn("registration confirmation rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, err
+	}
+	now := time.Now()
+	if user.Status != helpers.UserStatusPending || !now.Before(user.CreatedAt.Add(pendingRegistrationTTL)) {
+		return nil, logical.CodedError(http.StatusConflict, helpers.ErrUserNotPending.Error())
+	}
+	if !strings.EqualFold(d.Get("fingerprint").(string), user.Fingerprint) {
+		backendLogger.Warn("registration fingerprint mismatch", "uuid", uuid)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrWrongFingerprint.Error())
+	}
+
+	user.Status, user.UpdatedAt = helpers.UserStatusActive, now.UTC()
+	if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
+		backendLogger.Error("put user", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("user registration confirmed", "uuid", uuid, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: userResponseData(user),
+	}, nil
+}
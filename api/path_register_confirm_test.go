@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_RegisterConfirm(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+	const mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	resp, err := request("register/init", map[string]interface{}{
+		"uuid": "ceremony-uuid", "mnemonic": mnemonic, "verifyCoinTypes": "60,0",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "73c5da0a", resp.Data["fingerprint"])
+	assert.Equal(t, helpers.UserStatusPending, resp.Data["status"])
+	assert.NotEmpty(t, resp.Data["confirmBy"])
+	assert.Equal(t, uint64(1), resp.Data["version"])
+	assert.Equal(t, []map[string]interface{}{
+		{"coinType": 60, "path": "m/44'/60'/0'/0/0", "address": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+		{"coinType": 0, "path": "m/84'/0'/0'/0/0", "address": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+	}, resp.Data["addresses"])
+
+	t.Run("pending users cannot derive or be enabled", func(t *testing.T) {
+		_, err := request("address", map[string]interface{}{
+			"uuid": "ceremony-uuid", "coinType": 60, "derivationPath": signTestDerivationPath,
+		})
+		require.ErrorContains(t, err, helpers.ErrUserNotActive.Error())
+		_, err = request("user/ceremony-uuid/enable", map[string]interface{}{"version": 1})
+		require.ErrorContains(t, err, helpers.ErrUserPending.Error())
+	})
+
+	t.Run("a wrong fingerprint leaves the user pending", func(t *testing.T) {
+		_, err := request("register/confirm", map[string]interface{}{
+			"uuid": "ceremony-uuid", "fingerprint": "00000000", "version": 1,
+		})
+		require.ErrorContains(t, err, helpers.ErrWrongFingerprint.Error())
+		user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, "ceremony-uuid")
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusPending, user.Status)
+	})
+
+	t.Run("the confirmation activates the user", func(t *testing.T) {
+		resp, err := request("register/confirm", map[string]interface{}{
+			"uuid": "ceremony-uuid", "fingerprint": "73C5DA0A", "version": 1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusActive, resp.Data["status"])
+		assert.Equal(t, uint64(2), resp.Data["version"])
+		resp, err = request("address", map[string]interface{}{
+			"uuid": "ceremony-uuid", "coinType": 60, "derivationPath": signTestDerivationPath,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", resp.Data["address"])
+
+		_, err = request("register/confirm", map[string]interface{}{
+			"uuid": "ceremony-uuid", "fingerprint": "73c5da0a", "version": 2,
+		})
+		require.ErrorContains(t, err, helpers.ErrUserNotPending.Error())
+	})
+
+	t.Run("unconfirmed registrations are purged", func(t *testing.T) {
+		resp, err := request("register/init", map[string]interface{}{})
+		require.NoError(t, err)
+		uuid := resp.Data["uuid"].(string)
+		require.NotEmpty(t, uuid)
+
+		require.NoError(t, b.expireUsers(ctx, s, time.Now()))
+		_, err = helpers.GetUser(ctx, &logical.Request{Storage: s}, uuid)
+		require.NoError(t, err)
+		require.NoError(t, b.expireUsers(ctx, s, time.Now().Add(pendingRegistrationTTL)))
+		_, err = helpers.GetUser(ctx, &logical.Request{Storage: s}, uuid)
+		require.ErrorIs(t, err, helpers.ErrUserNotFound)
+		_, err = helpers.GetUser(ctx, &logical.Request{Storage: s}, "ceremony-uuid")
+		require.NoError(t, err)
+	})
+
+	_, err = request("register/init", map[string]interface{}{
+		"mnemonic": mnemonic, "allowedCoinTypes": "60", "verifyCoinTypes": "0",
+	})
+	require.ErrorContains(t, err, helpers.ErrCoinTypeNotAllowed.Error())
+}
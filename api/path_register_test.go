@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"log/slog"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
 )
 
 // Test constants for register tests
@@ -75,6 +78,38 @@ func createRegisterFieldData(data map[string]interface{}) *framework.FieldData {
 			Type:        framework.TypeString,
 			Description: "Passphrase for mnemonic",
 		},
+		"xpub": {
+			Type:        framework.TypeString,
+			Description: "Extended public key of a watch-only user",
+		},
+		"xpubPath": {
+			Type:        framework.TypeString,
+			Description: "Derivation path of xpub",
+		},
+		"fingerprint": {
+			Type:        framework.TypeString,
+			Description: "Master key fingerprint of xpub",
+		},
+		"allowedCoinTypes": {
+			Type:        framework.TypeCommaIntSlice,
+			Description: "Coin types the user may use",
+		},
+		"restrictToOwner": {
+			Type:        framework.TypeBool,
+			Description: "Restrict the user to the registering entity",
+		},
+		"restrictManagement": {
+			Type:        framework.TypeBool,
+			Description: "Restrict destructive operations to the registering entity",
+		},
+		"managerEntityIds": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "Entities also allowed to manage the user",
+		},
+		"ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Lifetime of the user",
+		},
 	}
 
 	return &framework.FieldData{
@@ -98,6 +133,18 @@ func createRegisterAutoFieldData(data map[string]interface{}) *framework.FieldDa
 			Type:        framework.TypeString,
 			Description: "Passphrase for mnemonic",
 		},
+		"xpub": {
+			Type:        framework.TypeString,
+			Description: "Extended public key of a watch-only user",
+		},
+		"xpubPath": {
+			Type:        framework.TypeString,
+			Description: "Derivation path of xpub",
+		},
+		"fingerprint": {
+			Type:        framework.TypeString,
+			Description: "Master key fingerprint of xpub",
+		},
 	}
 
 	return &framework.FieldData{
@@ -110,7 +157,8 @@ func createRegisterAutoFieldData(data map[string]interface{}) *framework.FieldDa
 func createRegisterTestBackend(_ *testing.T) *Backend {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	return &Backend{
-		logger: logger,
+		logger:     logger,
+		randReader: rand.Reader,
 	}
 }
 
@@ -137,6 +185,8 @@ func TestBackend_PathRegister(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(nil)
 			},
 			want: &logical.Response{
@@ -156,6 +206,9 @@ func TestBackend_PathRegister(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(nil)
 			},
 			want: &logical.Response{
@@ -230,6 +283,8 @@ func TestBackend_PathRegister(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(assert.AnError)
 			},
 			wantErr:        true,
@@ -298,6 +353,8 @@ func TestBackend_PathRegisterUUID(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(nil)
 			},
 			wantErr: false,
@@ -311,6 +368,9 @@ func TestBackend_PathRegisterUUID(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(nil)
 			},
 			wantErr: false,
@@ -323,6 +383,8 @@ func TestBackend_PathRegisterUUID(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(nil)
 			},
 			wantErr: false,
@@ -350,6 +412,8 @@ func TestBackend_PathRegisterUUID(t *testing.T) {
 			},
 			setupStorage: func(ms *MockStorageRegister) {
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+				ms.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+				ms.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 				ms.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Return(assert.AnError)
 			},
 			wantErr:        true,
@@ -407,6 +471,8 @@ func TestBackend_PathRegisterUUID_StorageContent(t *testing.T) {
 	// Capture the storage entry to verify its content
 	var capturedEntry *logical.StorageEntry
 	mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+	mockStorage.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+	mockStorage.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
 	mockStorage.On("Put", ctx, mock.AnythingOfType("*logical.StorageEntry")).Run(func(args mock.Arguments) {
 		capturedEntry = args.Get(1).(*logical.StorageEntry)
 	}).Return(nil)
@@ -452,3 +518,222 @@ func TestBackend_PathRegisterUUID_StorageContent(t *testing.T) {
 
 	mockStorage.AssertExpectations(t)
 }
+
+func TestBackend_PathRegister_TTL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ttl sets the expiry", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "mnemonic": regTestValidMnemonic, "ttl": "24h"}
+		got, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: s, Data: data},
+			createRegisterFieldData(data))
+		require.NoError(t, err)
+
+		user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, regTestGeneratedUUID)
+		require.NoError(t, err)
+		assert.Equal(t, user.CreatedAt.Add(24*time.Hour), user.ExpiresAt)
+		assert.Equal(t, user.ExpiresAt.Format(time.RFC3339), got.Data["expiresAt"])
+	})
+
+	t.Run("users without ttl never expire", func(t *testing.T) {
+		s := &logical.InmemStorage{}
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "mnemonic": regTestValidMnemonic}
+		got, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: s, Data: data},
+			createRegisterFieldData(data))
+		require.NoError(t, err)
+		assert.NotContains(t, got.Data, "expiresAt")
+
+		entry, err := s.Get(ctx, config.StorageBasePath+regTestGeneratedUUID)
+		require.NoError(t, err)
+		assert.NotContains(t, string(entry.Value), "expiresAt")
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "mnemonic": regTestValidMnemonic, "ttl": 0}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx,
+			&logical.Request{Storage: &logical.InmemStorage{}, Data: data}, createRegisterFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidTTL.Error())
+	})
+}
+
+func TestBackend_HandleRequest_DerivationNamespace(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+	address := func(uuid string) string {
+		t.Helper()
+		resp, err := request("address", map[string]interface{}{
+			"uuid": uuid, "coinType": 60, "derivationPath": "m/44'/60'/0'/0/0",
+		})
+		require.NoError(t, err)
+		return resp.Data["address"].(string)
+	}
+
+	for _, uuid := range []string{"plain", "namespaced-1", "namespaced-2"} {
+		resp, err := request("register", map[string]interface{}{
+			"uuid": uuid, "mnemonic": regTestValidMnemonic, "derivationNamespace": uuid != "plain",
+		})
+		require.NoError(t, err)
+		if uuid == "plain" {
+			assert.NotContains(t, resp.Data, "namespaceIndex")
+		}
+	}
+
+	t.Run("users of one mnemonic share no key", func(t *testing.T) {
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", address("plain"))
+		assert.NotEqual(t, address("plain"), address("namespaced-1"))
+		assert.NotEqual(t, address("namespaced-1"), address("namespaced-2"))
+	})
+
+	t.Run("the namespace is recorded in the user", func(t *testing.T) {
+		user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, "namespaced-1")
+		require.NoError(t, err)
+		require.NotNil(t, user.NamespaceIndex)
+		assert.Less(t, *user.NamespaceIndex, uint32(lib.HardenedOffset))
+		assert.NotEqual(t, "73c5da0a", user.Fingerprint)
+
+		seed, err := lib.SeedFromMnemonic(regTestValidMnemonic, "")
+		require.NoError(t, err)
+		namespaced, err := lib.NamespaceSeed(seed, *user.NamespaceIndex)
+		require.NoError(t, err)
+		fingerprint, err := lib.MasterFingerprint(namespaced)
+		require.NoError(t, err)
+		assert.Equal(t, fingerprint, user.Fingerprint)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.ReadOperation, Path: "user/namespaced-1",
+			Storage: s})
+		require.NoError(t, err)
+		assert.Equal(t, *user.NamespaceIndex, resp.Data["namespaceIndex"])
+	})
+
+	resp, err := request("xpub", map[string]interface{}{"uuid": "plain", "path": "m/44'/60'/0'", "coinType": 60})
+	require.NoError(t, err)
+	_, err = request("register", map[string]interface{}{
+		"uuid": "watch-only", "xpub": resp.Data["xpub"], "xpubPath": "m/44'/60'/0'", "derivationNamespace": true,
+	})
+	require.ErrorContains(t, err, helpers.ErrNamespaceWatchOnly.Error())
+}
+
+func TestBackend_HandleRequest_WatchOnly(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	xpubOf := func(uuid, path string, coinType int) string {
+		resp, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": uuid, "derivationPath": path, "coinType": coinType,
+		})
+		require.NoError(t, err)
+		return resp.Data["xpub"].(string)
+	}
+
+	ethXpub := xpubOf(signTestUUID, "m/44'/60'/0'", 60)
+	btcXpub := xpubOf(signTestUUID, "m/84'/0'/0'", 0)
+
+	t.Run("invalid registrations are rejected", func(t *testing.T) {
+		for name, data := range map[string]map[string]interface{}{
+			"mnemonic": {"mnemonic": regTestValidMnemonic},
+			"depth":    {"xpubPath": "m/44'/60'"},
+			"extended": {"xpub": "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVp" +
+				"xR86QEC8w35uxmGoggxtQTPvfUu"},
+			"key":         {"xpub": "xpub-invalid"},
+			"fingerprint": {"fingerprint": "73c5"},
+		} {
+			fields := map[string]interface{}{"uuid": "watch-" + name, "xpub": ethXpub, "xpubPath": "m/44'/60'/0'"}
+			for k, v := range data {
+				fields[k] = v
+			}
+			_, err := request(logical.UpdateOperation, "register", fields)
+			require.Error(t, err, name)
+		}
+	})
+
+	resp, err := request(logical.UpdateOperation, "register", map[string]interface{}{
+		"uuid": "watch-eth", "xpub": ethXpub, "xpubPath": "m/44'/60'/0'", "allowedCoinTypes": "60",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Data["watchOnly"])
+	_, err = request(logical.UpdateOperation, "register", map[string]interface{}{
+		"uuid": "watch-btc", "xpub": btcXpub, "xpubPath": "m/84'/0'/0'", "fingerprint": userTestFingerprint,
+	})
+	require.NoError(t, err)
+
+	t.Run("addresses match the ones of the seed", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+			"uuid": "watch-eth", "path": "m/44'/60'/0'/0/0", "coinType": 60,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", resp.Data["address"])
+
+		for _, path := range []string{"m/84'/0'/0'/0/0", "m/84'/0'/0'/1/3"} {
+			want, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+				"uuid": signTestUUID, "path": path, "coinType": 0,
+			})
+			require.NoError(t, err)
+			got, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+				"uuid": "watch-btc", "path": path, "coinType": 0,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, want.Data["address"], got.Data["address"], path)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		want, err := request(logical.UpdateOperation, "address/batch", map[string]interface{}{
+			"uuid": signTestUUID, "pathTemplate": "m/44'/60'/0'/0/%d", "coinType": 60, "count": 3,
+		})
+		require.NoError(t, err)
+		got, err := request(logical.UpdateOperation, "address/batch", map[string]interface{}{
+			"uuid": "watch-eth", "pathTemplate": "m/44'/60'/0'/0/%d", "coinType": 60, "count": 3,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want.Data["addresses"], got.Data["addresses"])
+	})
+
+	t.Run("xpub", func(t *testing.T) {
+		assert.Equal(t, ethXpub, xpubOf("watch-eth", "m/44'/60'/0'", 60))
+
+		want, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0,
+		})
+		require.NoError(t, err)
+		got, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+			"uuid": "watch-btc", "derivationPath": "m/84'/0'/0'", "coinType": 0,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want.Data["descriptors"], got.Data["descriptors"])
+	})
+
+	t.Run("paths outside the xpub are rejected", func(t *testing.T) {
+		for _, path := range []string{"m/44'/60'/1'/0/0", "m/44'/60'/0'/0'/0", "m/44'/60'"} {
+			_, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+				"uuid": "watch-eth", "path": path, "coinType": 60,
+			})
+			require.Error(t, err, path)
+		}
+	})
+
+	t.Run("sign is rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": "watch-eth", "path": "m/44'/60'/0'/0/0", "coinType": 60,
+			"payload": `{"nonce":1,"value":1,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrWatchOnlyUser.Error())
+	})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter"
+)
+
+// checkExpectedAddress derives the address of the new user at the expectedPath of expectedCoinType
+// and compares it with the expectedAddress of the request, before the user is stored, so a
+// migration from another wallet system catches a mistyped mnemonic, passphrase or xpub instead of
+// registering keys nobody funded. Requests without expectedAddress are not checked; errors are
+// coded.
+func checkExpectedAddress(ctx context.Context, backendLogger *slog.Logger, d *framework.FieldData,
+	user *helpers.User) error {
+	// the fields are read with GetOk, as register/init and the tests may not declare them
+	expected, _ := d.GetOk("expectedAddress")
+	derivationPath, _ := d.GetOk("expectedPath")
+	isDev, _ := d.GetOk("expectedIsDev")
+	raw, hasCoinType := d.GetOk("expectedCoinType")
+	address, _ := expected.(string)
+	path, _ := derivationPath.(string)
+	dev, _ := isDev.(bool)
+	if address == "" && path == "" && !hasCoinType {
+		return nil
+	}
+	if address == "" || path == "" || !hasCoinType {
+		return logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrExpectedAddress.Error())
+	}
+	if raw.(int) < 0 || raw.(int) > math.MaxUint16 {
+		return logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: %d", helpers.ErrUnsupportedCoinType, raw).Error())
+	}
+	coinType := uint16(raw.(int))
+	if err := user.Authorize(coinType); err != nil {
+		return helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	var seed []byte
+	if !user.WatchOnly() {
+		var err error
+		if seed, err = userSeed(ctx, user); err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	derived, err := deriveUserAddress(adapterInventory, user, seed, coinType, path, dev)
+	if err != nil {
+		backendLogger.Error("derive expected address", "error", err, "coinType", coinType)
+		return logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !sameAddress(derived, address) {
+		backendLogger.Warn("registration rejected, expected address mismatch", "uuid", user.UUID,
+			"coinType", coinType, "path", path)
+		return logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Sprintf("%s: %s derives %s", helpers.ErrAddressMismatch, path, derived))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_RegisterExpectedAddress(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data,
+		})
+	}
+	register := func(uuid, passphrase string, expected map[string]interface{}) error {
+		t.Helper()
+		data := map[string]interface{}{"uuid": uuid, "mnemonic": regTestValidMnemonic, "passphrase": passphrase}
+		for name, value := range expected {
+			data[name] = value
+		}
+		_, err := request("register", data)
+		return err
+	}
+	expected := map[string]interface{}{
+		"expectedAddress": testAddress, "expectedCoinType": 60, "expectedPath": testDerivationPath,
+	}
+
+	t.Run("the expected address is derived", func(t *testing.T) {
+		require.NoError(t, register("migrated", "", expected))
+		assert.True(t, helpers.UUIDExists(ctx, &logical.Request{Storage: s}, "migrated"))
+
+		// EVM addresses match in any case
+		require.NoError(t, register("migrated-lower", "", map[string]interface{}{
+			"expectedAddress": strings.ToLower(testAddress), "expectedCoinType": 60, "expectedPath": testDerivationPath,
+		}))
+	})
+
+	t.Run("a mistyped passphrase is not registered", func(t *testing.T) {
+		err := register("mistyped", "test-passphrase", expected)
+		require.ErrorContains(t, err, helpers.ErrAddressMismatch.Error())
+		assert.ErrorContains(t, err, testDerivationPath+" derives 0x")
+		assert.False(t, helpers.UUIDExists(ctx, &logical.Request{Storage: s}, "mistyped"))
+	})
+
+	t.Run("incomplete expectations", func(t *testing.T) {
+		err := register("incomplete", "", map[string]interface{}{"expectedAddress": testAddress, "expectedCoinType": 60})
+		require.ErrorContains(t, err, helpers.ErrExpectedAddress.Error())
+		err = register("incomplete", "", map[string]interface{}{"expectedPath": testDerivationPath})
+		require.ErrorContains(t, err, helpers.ErrExpectedAddress.Error())
+	})
+
+	t.Run("coin types the user may not derive", func(t *testing.T) {
+		err := register("restricted", "", map[string]interface{}{
+			"expectedAddress": testAddress, "expectedCoinType": 60, "expectedPath": testDerivationPath,
+			"allowedCoinTypes": "0",
+		})
+		require.ErrorContains(t, err, helpers.ErrCoinTypeNotAllowed.Error())
+	})
+
+	t.Run("watch-only users and generated uuids", func(t *testing.T) {
+		resp, err := request("xpub", map[string]interface{}{"uuid": "migrated", "path": "m/44'/60'/0'", "coinType": 60})
+		require.NoError(t, err)
+		watchOnly := map[string]interface{}{"xpub": resp.Data["xpub"], "xpubPath": "m/44'/60'/0'"}
+		for name, value := range expected {
+			watchOnly[name] = value
+		}
+		resp, err = request("register_uuid", watchOnly)
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["watchOnly"])
+
+		watchOnly["expectedPath"] = "m/44'/60'/0'/0/1"
+		_, err = request("register_uuid", watchOnly)
+		require.ErrorContains(t, err, helpers.ErrAddressMismatch.Error())
+	})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+)
+
+// maxReplicationChanges bounds the changes of an export-changes page
+const maxReplicationChanges = 1000
+
+// pathReplicateExportChanges corresponds to READ replicate/export-changes. It returns the changes of
+// the user records journaled after since, each with the current record sealed under the replication
+// key, and the sequence number to export the next page from.
+func (b *Backend) pathReplicateExportChanges(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_replicate_export_changes"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	since, limit := d.Get("since").(int), d.Get("limit").(int)
+	if since < 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidReplicationSince.Error())
+	}
+	if limit < 1 || limit > maxReplicationChanges {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidReplicationLimit.Error())
+	}
+	replicationConfig, err := helpers.GetReplicationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get replication config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if len(replicationConfig.Key) == 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrReplicationDisabled.Error())
+	}
+
+	journaled, err := storage.JournalChanges(ctx, req.Storage, config.ReplicationStoragePath, uint64(since), limit)
+	if err != nil {
+		backendLogger.Error("journal changes", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	sequence, err := storage.JournalSequence(ctx, req.Storage, config.ReplicationStoragePath)
+	if err != nil {
+		backendLogger.Error("journal sequence", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	changes := make([]helpers.ReplicatedChange, 0, len(journaled))
+	lastSeq := uint64(since)
+	for _, journal := range journaled {
+		change := helpers.ReplicatedChange{Seq: journal.Seq, UUID: journal.Name, Version: journal.Version,
+			Deleted: journal.Deleted}
+		lastSeq = journal.Seq
+		if !change.Deleted {
+			entry, err := req.Storage.Get(ctx, config.StorageBasePath+journal.Name)
+			if err != nil {
+				backendLogger.Error("get user record", "error", err, "uuid", journal.Name)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+			// a record deleted since the list has its deletion journaled later on
+			if entry == nil {
+				continue
+			}
+			if err := change.Seal(replicationConfig.Key, entry.Value); err != nil {
+				backendLogger.Error("seal user record", "error", err, "uuid", journal.Name)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	backendLogger.Info("changes exported", "since", since, "changes", len(changes), "lastSeq", lastSeq,
+		"sequence", sequence, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"changes":  changes,
+			"lastSeq":  lastSeq,
+			"sequence": sequence,
+			"more":     lastSeq < sequence,
+		},
+	}, nil
+}
+
+// pathReplicateApply corresponds to UPDATE replicate/apply. It writes the user records of changes
+// exported by another cluster, checking their version against the last change of the local record:
+// changes already applied are stale, and records written on this cluster since are conflicts, only
+// overwritten with force. Applied changes keep their version in the local journal.
+func (b *Backend) pathReplicateApply(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_replicate_apply"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	replicationConfig, err := helpers.GetReplicationConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get replication config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if len(replicationConfig.Key) == 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrReplicationDisabled.Error())
+	}
+
+	// the changes are given as the JSON of export-changes
+	var changes []helpers.ReplicatedChange
+	raw, err := json.Marshal(d.Get("changes"))
+	if err == nil {
+		err = json.Unmarshal(raw, &changes)
+	}
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidReplicatedChange.Error())
+	}
+	records := make([][]byte, len(changes))
+	for i := range changes {
+		if err := changes[i].Validate(); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if changes[i].Deleted {
+			continue
+		}
+		if records[i], err = changes[i].Open(replicationConfig.Key); err != nil {
+			backendLogger.Error("open user record", "error", err, "uuid", changes[i].UUID)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	force := d.Get("force").(bool)
+
+	b.replicateMu.Lock()
+	defer b.replicateMu.Unlock()
+
+	results := make([]map[string]interface{}, 0, len(changes))
+	counts := map[string]int{helpers.ReplicationApplied: 0, helpers.ReplicationStale: 0, helpers.ReplicationConflict: 0}
+	for i, change := range changes {
+		status, localVersion, err := b.applyReplicatedChange(ctx, req.Storage, &change, records[i], force)
+		if err != nil {
+			backendLogger.Error("apply change", "error", err, "uuid", change.UUID, "version", change.Version)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		counts[status]++
+		results = append(results, map[string]interface{}{
+			"uuid":         change.UUID,
+			"version":      change.Version,
+			"localVersion": localVersion,
+			"status":       status,
+		})
+	}
+
+	backendLogger.Info("changes applied", "applied", counts[helpers.ReplicationApplied],
+		"stale", counts[helpers.ReplicationStale], "conflicts", counts[helpers.ReplicationConflict],
+		"force", force, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"changes":   results,
+			"applied":   counts[helpers.ReplicationApplied],
+			"stale":     counts[helpers.ReplicationStale],
+			"conflicts": counts[helpers.ReplicationConflict],
+		},
+	}, nil
+}
+
+// applyReplicatedChange writes the record of change unless it is stale or, without force, a
+// conflict, returning its status and the version of the local record it was checked against
+func (b *Backend) applyReplicatedChange(ctx context.Context, s logical.Storage, change *helpers.ReplicatedChange,
+	record []byte, force bool) (string, uint64, error) {
+	key := config.StorageBasePath + change.UUID
+	local, err := storage.JournalVersion(ctx, s, config.ReplicationStoragePath, change.UUID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var localVersion uint64
+	written := false
+	if local != nil {
+		localVersion, written = local.Version, !local.Replicated
+		if local.Replicated && change.Version <= local.Version {
+			return helpers.ReplicationStale, localVersion, nil
+		}
+	} else {
+		// a record without a change was written before the journal
+		entry, err := s.Get(ctx, key)
+		if err != nil {
+			return "", 0, err
+		}
+		written = entry != nil
+	}
+	if written && !force {
+		return helpers.ReplicationConflict, localVersion, nil
+	}
+
+	ctx = storage.WithReplicatedVersion(ctx, change.Version)
+	if change.Deleted {
+		err = s.Delete(ctx, key)
+	} else {
+		err = s.Put(ctx, &logical.StorageEntry{Key: key, Value: record})
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return helpers.ReplicationApplied, localVersion, nil
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_Replication(t *testing.T) {
+	ctx := context.Background()
+	key := strings.Repeat("ab", 32)
+	cluster := func(s logical.Storage) func(logical.Operation, string, map[string]interface{}) (*logical.Response, error) {
+		b := NewBackend(&logical.BackendConfig{})
+		require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+		return func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+			t.Helper()
+			return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+		}
+	}
+	primary, dr := cluster(newXpubTestStorage(t)), cluster(&logical.InmemStorage{})
+	// export returns the changes after since as apply takes them, through their JSON
+	export := func(since uint64) ([]interface{}, uint64) {
+		t.Helper()
+		resp, err := primary(logical.ReadOperation, "replicate/export-changes", map[string]interface{}{"since": since})
+		require.NoError(t, err)
+		raw, err := json.Marshal(resp.Data["changes"])
+		require.NoError(t, err)
+		var changes []interface{}
+		require.NoError(t, json.Unmarshal(raw, &changes))
+		return changes, resp.Data["lastSeq"].(uint64)
+	}
+	apply := func(changes []interface{}, force bool) *logical.Response {
+		t.Helper()
+		resp, err := dr(logical.UpdateOperation, "replicate/apply", map[string]interface{}{"changes": changes, "force": force})
+		require.NoError(t, err)
+		return resp
+	}
+	status := func() string {
+		t.Helper()
+		resp, err := dr(logical.ReadOperation, "user/"+signTestUUID, nil)
+		require.NoError(t, err)
+		return resp.Data["status"].(string)
+	}
+
+	_, err := primary(logical.ReadOperation, "replicate/export-changes", nil)
+	require.ErrorContains(t, err, helpers.ErrReplicationDisabled.Error())
+
+	resp, err := primary(logical.UpdateOperation, "config/replication", map[string]interface{}{"key": key})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Data["tracked"], "the records already stored are journaled")
+	_, err = dr(logical.UpdateOperation, "config/replication", map[string]interface{}{"key": key})
+	require.NoError(t, err)
+
+	changes, lastSeq := export(0)
+	require.Len(t, changes, 1)
+	resp = apply(changes, false)
+	assert.Equal(t, 1, resp.Data["applied"])
+	assert.Equal(t, helpers.UserStatusActive, status())
+
+	t.Run("changes already applied are stale", func(t *testing.T) {
+		resp := apply(changes, false)
+		assert.Equal(t, 1, resp.Data["stale"])
+	})
+
+	t.Run("later changes are applied", func(t *testing.T) {
+		_, err := primary(logical.UpdateOperation, "user/"+signTestUUID+"/disable",
+			map[string]interface{}{"version": 0})
+		require.NoError(t, err)
+		changes, lastSeq = export(lastSeq)
+		require.Len(t, changes, 1)
+		assert.InDelta(t, 2, changes[0].(map[string]interface{})["version"], 0)
+
+		resp := apply(changes, false)
+		assert.Equal(t, 1, resp.Data["applied"])
+		assert.Equal(t, helpers.UserStatusDisabled, status())
+	})
+
+	t.Run("local writes conflict", func(t *testing.T) {
+		_, err := dr(logical.UpdateOperation, "user/"+signTestUUID+"/enable", map[string]interface{}{"version": 1})
+		require.NoError(t, err)
+		_, err = primary(logical.DeleteOperation, "user/"+signTestUUID, nil)
+		require.NoError(t, err)
+		changes, _ := export(lastSeq)
+		require.Len(t, changes, 1)
+
+		resp := apply(changes, false)
+		assert.Equal(t, 1, resp.Data["conflicts"])
+		assert.Equal(t, helpers.UserStatusActive, status())
+
+		resp = apply(changes, true)
+		assert.Equal(t, 1, resp.Data["applied"])
+		resp, err = dr(logical.ReadOperation, "user/"+signTestUUID, nil)
+		require.ErrorContains(t, err, helpers.ErrUserNotFound.Error())
+		assert.Nil(t, resp)
+	})
+
+	t.Run("records sealed under another key are rejected", func(t *testing.T) {
+		_, err := dr(logical.UpdateOperation, "config/replication", map[string]interface{}{"key": strings.Repeat("cd", 32)})
+		require.NoError(t, err)
+		_, err = dr(logical.UpdateOperation, "replicate/apply", map[string]interface{}{"changes": changes})
+		require.ErrorContains(t, err, helpers.ErrReplicatedRecord.Error())
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded)
+		assert.Equal(t, http.StatusUnprocessableEntity, coded.Code())
+	})
+}
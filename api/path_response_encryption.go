@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"slices"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/sealedbox"
+)
+
+// encryptedResponse -- the sensitive fields of the responses of a path, encrypted to the
+// responsePublicKey of the request
+type encryptedResponse struct {
+	fields []string
+	// optIn paths only return their fields when the request asks for them, encrypted, so
+	// encryptedResponsesRequired does not require a key from all of their requests
+	optIn bool
+}
+
+// encryptedResponses maps the patterns of the paths returning secrets to their sensitive fields
+//
+//nolint:gochecknoglobals // read-only lookup table
+var encryptedResponses = map[string]encryptedResponse{
+	"register":       {fields: []string{"mnemonic"}, optIn: true},
+	"register_uuid":  {fields: []string{"mnemonic"}, optIn: true},
+	"register/init":  {fields: []string{"mnemonic"}, optIn: true},
+	"session/create": {fields: []string{"sessionToken"}},
+	"gen/mnemonic":   {fields: []string{"mnemonic"}},
+	"apikeys/" + framework.GenericNameRegex("name"): {fields: []string{"apiKey"}},
+}
+
+// responseEncryptionKey returns the X25519 key the sensitive fields of the response to the request
+// of the path of pattern are encrypted to, nil when they are returned in plaintext. It errors on an
+// invalid key, and on a missing one when config/features has encryptedResponsesRequired.
+func (b *Backend) responseEncryptionKey(ctx context.Context, req *logical.Request,
+	pattern string) (*[sealedbox.KeySize]byte, error) {
+	encrypted, ok := encryptedResponses[pattern]
+	if !ok || (req.Operation != logical.UpdateOperation && req.Operation != logical.CreateOperation) {
+		return nil, nil
+	}
+
+	if value, _ := req.Data["responsePublicKey"].(string); value != "" {
+		key, err := sealedbox.ParsePublicKey(value)
+		if err != nil {
+			return nil, helpers.ErrInvalidResponseKey
+		}
+		return key, nil
+	}
+	if encrypted.optIn {
+		return nil, nil
+	}
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if features.EncryptedResponsesRequired {
+		return nil, helpers.ErrResponseKeyRequired
+	}
+	return nil, nil
+}
+
+// encryptResponse replaces the sensitive fields of the path of pattern present in resp by their
+// sealed box to key, and describes the encryption in its encryption field
+func encryptResponse(resp *logical.Response, pattern string, key *[sealedbox.KeySize]byte) error {
+	if resp == nil || resp.Data == nil || key == nil {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range encryptedResponses[pattern].fields {
+		value, ok := resp.Data[field].(string)
+		if !ok {
+			continue
+		}
+		sealed, err := sealedbox.Seal(key, []byte(value))
+		if err != nil {
+			return err
+		}
+		resp.Data[field] = sealed
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	slices.Sort(fields)
+	resp.Data["encryption"] = map[string]interface{}{
+		"algorithm": sealedbox.Algorithm,
+		"keyId":     sealedbox.KeyID(key),
+		"fields":    fields,
+	}
+	return nil
+}
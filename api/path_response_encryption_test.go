@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/sealedbox"
+)
+
+func TestBackend_HandleRequest_EncryptedResponses(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data})
+	}
+
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	responsePublicKey := hex.EncodeToString(publicKey[:])
+	open := func(resp *logical.Response, field string) string {
+		t.Helper()
+		assert.Equal(t, map[string]interface{}{
+			"algorithm": sealedbox.Algorithm,
+			"keyId":     sealedbox.KeyID(publicKey),
+			"fields":    []string{field},
+		}, resp.Data["encryption"])
+		plaintext, err := sealedbox.Open(publicKey, privateKey, resp.Data[field].(string))
+		require.NoError(t, err)
+		return string(plaintext)
+	}
+	session := map[string]interface{}{"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0/0"}
+
+	t.Run("the session token is encrypted", func(t *testing.T) {
+		resp, err := request("session/create", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0/0", "responsePublicKey": responsePublicKey,
+		})
+		require.NoError(t, err)
+		token := open(resp, "sessionToken")
+		assert.True(t, strings.HasPrefix(token, resp.Data["id"].(string)+"."))
+
+		// the decrypted token signs
+		_, err = request("session/sign", map[string]interface{}{
+			"sessionToken": token, "derivationPath": signTestDerivationPath, "payload": signTestPayload,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("the minted api key is encrypted", func(t *testing.T) {
+		resp, err := request("apikeys/payouts", map[string]interface{}{"responsePublicKey": responsePublicKey})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(open(resp, "apiKey"), "payouts."))
+	})
+
+	t.Run("the generated mnemonic is exported encrypted once", func(t *testing.T) {
+		resp, err := request("register", map[string]interface{}{
+			"uuid": "exported", "exportMnemonic": true, "responsePublicKey": responsePublicKey,
+		})
+		require.NoError(t, err)
+		assert.True(t, lib.IsMnemonicValid(open(resp, "mnemonic")))
+
+		resp, err = request("register", map[string]interface{}{"uuid": "not-exported"})
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "mnemonic")
+		assert.NotContains(t, resp.Data, "encryption")
+	})
+
+	t.Run("mnemonics are only exported encrypted, when generated", func(t *testing.T) {
+		_, err := request("register", map[string]interface{}{"uuid": "no-key", "exportMnemonic": true})
+		require.ErrorContains(t, err, helpers.ErrResponseKeyRequired.Error())
+		_, err = request("register_uuid", map[string]interface{}{
+			"mnemonic": signTestValidMnemonic, "exportMnemonic": true, "responsePublicKey": responsePublicKey,
+		})
+		require.ErrorContains(t, err, helpers.ErrMnemonicNotGenerated.Error())
+	})
+
+	t.Run("invalid keys are rejected", func(t *testing.T) {
+		_, err := request("session/create", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0/0", "responsePublicKey": "abcd",
+		})
+		require.ErrorContains(t, err, helpers.ErrInvalidResponseKey.Error())
+	})
+
+	t.Run("encryptedResponsesRequired refuses plaintext secrets", func(t *testing.T) {
+		_, err := request("config/features", map[string]interface{}{"encryptedResponsesRequired": true})
+		require.NoError(t, err)
+
+		_, err = request("session/create", session)
+		require.ErrorContains(t, err, helpers.ErrResponseKeyRequired.Error())
+		_, err = request("apikeys/payouts", nil)
+		require.ErrorContains(t, err, helpers.ErrResponseKeyRequired.Error())
+		// registrations without an export return no secret
+		_, err = request("register", map[string]interface{}{"uuid": "required"})
+		require.NoError(t, err)
+		// reads of the key return no secret
+		_, err = b.HandleRequest(ctx, &logical.Request{Operation: logical.ReadOperation, Path: "apikeys/payouts", Storage: s})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+const (
+	// defaultSessionTTL is the lifetime of the signing sessions created without ttl
+	defaultSessionTTL = 5 * time.Minute
+	// maxSessionTTL bounds the lifetime of a signing session, longer delegations use an API key
+	maxSessionTTL = time.Hour
+)
+
+// pathCreateSession corresponds to UPDATE session/create. It issues a signing session bound to
+// one user, coin type and path prefix, and returns its token once.
+func (b *Backend) pathCreateSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_create_session"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	coinType := d.Get("coinType").(int)
+	pathPrefix := d.Get("pathPrefix").(string)
+	maxOperations := d.Get("maxOperations").(int)
+	ttl := time.Duration(d.Get("ttl").(int)) * time.Second
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	if ttl > maxSessionTTL {
+		ttl = maxSessionTTL
+	}
+
+	if err := helpers.ValidateData(ctx, req, uuid, pathPrefix); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	session, token, err := helpers.NewSigningSession(uuid, uint16(coinType), pathPrefix, maxOperations, ttl)
+	if err != nil {
+		backendLogger.Error("create session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := helpers.PutSigningSession(ctx, req.Storage, session); err != nil {
+		backendLogger.Error("put session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("signing session created", "id", session.ID, "uuid", uuid, "coinType", coinType,
+		"pathPrefix", session.PathPrefix, "maxOperations", maxOperations, "expiresAt", session.ExpiresAt,
+		"entity", req.EntityID)
+
+	data := sessionResponseData(session)
+	data["sessionToken"] = token
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// pathReadSession corresponds to READ session/<id>. The token is never returned.
+func (b *Backend) pathReadSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_session"))
+
+	session, err := helpers.GetSigningSession(ctx, req.Storage, d.Get("id").(string))
+	if err != nil {
+		backendLogger.Error("get session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if session == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: sessionResponseData(session),
+	}, nil
+}
+
+// pathDeleteSession corresponds to DELETE session/<id>. Requests using the session are rejected immediately.
+func (b *Backend) pathDeleteSession(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_session"))
+
+	id := d.Get("id").(string)
+	if err := helpers.DeleteSigningSession(ctx, req.Storage, id); err != nil {
+		backendLogger.Error("delete session", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("signing session revoked", "id", id)
+
+	return nil, nil
+}
+
+func sessionResponseData(session *helpers.SigningSession) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            session.ID,
+		"uuid":          session.UUID,
+		"coinType":      session.CoinType,
+		"pathPrefix":    session.PathPrefix,
+		"maxOperations": session.MaxOperations,
+		"remaining":     session.Remaining(),
+		"createdAt":     formatTime(session.CreatedAt),
+		"expiresAt":     formatTime(session.ExpiresAt),
+	}
+}
+
+// withSigningSession serves session/sign: the sessionToken is checked against the scope of its
+// session, one operation of the session is used, and op is called with the uuid and coinType of
+// the session. Requests within the scope use an operation even when signing then fails. The
+// request also holds one of the concurrent request slots of config/quotas while it is served.
+func (b *Backend) withSigningSession(op framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "signing_session"))
+
+		release, err := b.acquireRequestSlot(ctx, req)
+		if err != nil {
+			backendLogger.Warn("request rejected", "error", err)
+			return nil, err
+		}
+		defer release()
+
+		session, err := b.useSigningSession(ctx, req.Storage, d.Get("sessionToken").(string),
+			d.Get("derivationPath").(string), time.Now())
+		if err != nil {
+			backendLogger.Error("use session", "error", err)
+			return nil, logical.CodedError(http.StatusForbidden, err.Error())
+		}
+		backendLogger.Info("signing session used", "id", session.ID, "uuid", session.UUID,
+			"remaining", session.Remaining())
+
+		raw := make(map[string]interface{}, len(d.Raw)+2)
+		for k, v := range d.Raw {
+			raw[k] = v
+		}
+		raw["uuid"], raw["coinType"] = session.UUID, int(session.CoinType)
+		schema := make(map[string]*framework.FieldSchema, len(d.Schema)+2)
+		for k, v := range d.Schema {
+			schema[k] = v
+		}
+		schema["uuid"] = &framework.FieldSchema{Type: framework.TypeString}
+		schema["coinType"] = &framework.FieldSchema{Type: framework.TypeInt}
+
+		resp, err := op(ctx, req, &framework.FieldData{Raw: raw, Schema: schema})
+		if err != nil || resp == nil || resp.Data == nil {
+			return resp, err
+		}
+		resp.Data["sessionRemaining"] = session.Remaining()
+		return resp, nil
+	}
+}
+
+// useSigningSession looks up the session of token, checks that it allows signing with the key at
+// derivationPath and records the operation
+func (b *Backend) useSigningSession(ctx context.Context, s logical.Storage, token, derivationPath string,
+	now time.Time) (*helpers.SigningSession, error) {
+	b.sessionMu.Lock()
+	defer b.sessionMu.Unlock()
+
+	session, err := helpers.LookupSigningSession(ctx, s, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Authorize(derivationPath, now); err != nil {
+		return nil, err
+	}
+	session.Operations++
+	if err := helpers.PutSigningSession(ctx, s, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// pruneSigningSessions removes the sessions that expired before now
+func (b *Backend) pruneSigningSessions(ctx context.Context, s logical.Storage, now time.Time) error {
+	ids, err := s.List(ctx, config.SigningSessionsStoragePath)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		session, err := helpers.GetSigningSession(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		if session == nil || now.Before(session.ExpiresAt) {
+			continue
+		}
+		if err := helpers.DeleteSigningSession(ctx, s, id); err != nil {
+			return err
+		}
+		b.logger.Info("signing session expired", "id", id)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestBackend_HandleRequest_SigningSession(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	payload := `{"nonce":1,"value":1,"gasLimit":21000,"gasPrice":1,"chainId":1,` +
+		`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"}`
+
+	t.Run("invalid sessions are rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "session/create", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0", "maxOperations": 0,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidSession.Error())
+
+		_, err = request(logical.UpdateOperation, "session/create", map[string]interface{}{
+			"uuid": "unknown", "coinType": 60, "pathPrefix": "m/44'/60'/0'/0",
+		})
+		require.Error(t, err)
+	})
+
+	resp, err := request(logical.UpdateOperation, "session/create", map[string]interface{}{
+		"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0/", "maxOperations": 2, "ttl": "10m",
+	})
+	require.NoError(t, err)
+	token := resp.Data["sessionToken"].(string)
+	id := resp.Data["id"].(string)
+	assert.True(t, strings.HasPrefix(token, id+"."))
+	assert.Equal(t, "m/44'/60'/0'/0", resp.Data["pathPrefix"])
+	assert.Equal(t, 2, resp.Data["remaining"])
+
+	sign := map[string]interface{}{"sessionToken": token, "path": "m/44'/60'/0'/0/0", "payload": payload}
+
+	t.Run("paths outside the prefix are rejected", func(t *testing.T) {
+		for _, path := range []string{"m/44'/60'/0'/1/0", "m/44'/60'/0'/00"} {
+			_, err := request(logical.UpdateOperation, "session/sign", map[string]interface{}{
+				"sessionToken": token, "path": path, "payload": payload,
+			})
+			require.Error(t, err, path)
+			assert.Contains(t, err.Error(), helpers.ErrSessionScope.Error())
+		}
+	})
+
+	t.Run("invalid tokens are rejected", func(t *testing.T) {
+		for _, sessionToken := range []string{"", id, id + ".00", "unknown.00"} {
+			_, err := request(logical.UpdateOperation, "session/sign", map[string]interface{}{
+				"sessionToken": sessionToken, "path": "m/44'/60'/0'/0/0", "payload": payload,
+			})
+			require.Error(t, err, sessionToken)
+			assert.Contains(t, err.Error(), helpers.ErrInvalidSessionToken.Error())
+		}
+	})
+
+	t.Run("the session signs with the keys of its user", func(t *testing.T) {
+		direct, err := request(logical.UpdateOperation, "sign", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "path": "m/44'/60'/0'/0/0", "payload": payload,
+		})
+		require.NoError(t, err)
+
+		resp, err := request(logical.UpdateOperation, "session/sign", sign)
+		require.NoError(t, err)
+		assert.Equal(t, direct.Data["signature"], resp.Data["signature"])
+		assert.Equal(t, 1, resp.Data["sessionRemaining"])
+
+		resp, err = request(logical.UpdateOperation, "session/sign", sign)
+		require.NoError(t, err)
+		assert.Equal(t, 0, resp.Data["sessionRemaining"])
+	})
+
+	t.Run("exhausted sessions are rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "session/sign", sign)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrSessionExhausted.Error())
+
+		resp, err := request(logical.ReadOperation, "session/"+id, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 0, resp.Data["remaining"])
+		assert.NotContains(t, resp.Data, "sessionToken")
+	})
+
+	t.Run("revoked sessions are rejected", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "session/create", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'/0",
+		})
+		require.NoError(t, err)
+		_, err = request(logical.DeleteOperation, "session/"+resp.Data["id"].(string), nil)
+		require.NoError(t, err)
+
+		sign["sessionToken"] = resp.Data["sessionToken"]
+		_, err = request(logical.UpdateOperation, "session/sign", sign)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrInvalidSessionToken.Error())
+	})
+
+	t.Run("expired sessions are pruned", func(t *testing.T) {
+		require.NoError(t, b.pruneSigningSessions(ctx, s, time.Now().Add(maxSessionTTL)))
+		ids, err := s.List(ctx, "sessions/")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
@@ -9,9 +9,9 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
-	"github.com/payment-system/dq-vault/lib"
 	"github.com/payment-system/dq-vault/lib/adapter"
 	"github.com/payment-system/dq-vault/lib/slip44"
+	"github.com/payment-system/dq-vault/lib/tracing"
 )
 
 func (b *Backend) pathSign(ctx context.Context, req *logical.Request,
@@ -26,7 +26,7 @@ func (b *Backend) pathSign(ctx context.Context, req *logical.Request,
 	uuid := d.Get("uuid").(string)
 
 	// derivation path
-	derivationPath := d.Get("path").(string)
+	derivationPath := d.Get("derivationPath").(string)
 
 	// coin type of transaction
 	// see supported coinTypes lib/bipp44coins
@@ -38,57 +38,224 @@ func (b *Backend) pathSign(ctx context.Context, req *logical.Request,
 
 	isDev := d.Get("isDev").(bool)
 
+	// complete fetches the missing payload fields from the node of config/rpc before signing
+	complete := d.Get("complete").(bool)
+
+	derivationPath, err := presetDerivationPath(d, derivationPath, coinType)
+	if err != nil {
+		backendLogger.Error("preset path", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var warnings []string
 	if uint16(coinType) == slip44.Bitshares {
+		warnings = appendPathDefaulted(warnings, derivationPath, config.BitsharesDerivationPath)
 		derivationPath = config.BitsharesDerivationPath
 	}
 
 	backendLogger.Info("request", "path", derivationPath, "cointype", coinType, "payload", payload)
 
 	// validate data provided
-	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+	validateCtx, span := tracing.Start(ctx, "sign.validate")
+	err = helpers.ValidateData(validateCtx, req, uuid, derivationPath)
+	tracing.End(span, err)
+	if err != nil {
 		backendLogger.Error("validate data", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
 	}
 
-	// path where user data is stored in vault
-	path := config.StorageBasePath + uuid
-	entry, err := req.Storage.Get(ctx, path)
+	// obtains blockchain adapater based on coinType
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+	capabilities, err := adapterInventory.CoinCapabilities(uint16(coinType))
 	if err != nil {
-		backendLogger.Error("get", "error", err)
+		backendLogger.Error("coin capabilities", "error", err, "coinType", coinType)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
 
+	// reject malformed payloads with field level errors before touching the keys; payloads to
+	// complete are validated once completed, the node needs the address of the key
+	if !complete {
+		if err := adapterInventory.ValidatePayload(uint16(coinType), payload); err != nil {
+			backendLogger.Error("validate payload", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
 	// obtain mnemonic, passphrase of user
-	var userInfo helpers.User
-	err = entry.DecodeJSON(&userInfo)
+	userCtx, span := tracing.Start(ctx, "sign.load_user")
+	userInfo, err := helpers.GetUser(userCtx, req, uuid)
+	tracing.End(span, err)
 	if err != nil {
-		backendLogger.Error("decode json", "error", err)
-		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(uint16(coinType)); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	// the seed is derived in the background while the policies of the payload are checked, PBKDF2 is
+	// the slowest stage of the request; it is zeroed once the request returns
+	derivation := deriveSeed(ctx, userInfo)
+	defer derivation.release()
+
+	// the fee rate of a payload to complete is checked once completed, as signed
+	var fees map[string]interface{}
+	var feeWarnings []string
+	if !complete {
+		if fees, feeWarnings, err = b.signFeeBounds(ctx, req, d, uint16(coinType), payload, backendLogger); err != nil {
+			return nil, err
+		}
+		// completed payloads have the latest blockhash of the node
+		blockhashWarnings, err := b.checkBlockhashAge(ctx, req.Storage, uint16(coinType), payload, backendLogger)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, blockhashWarnings...)
 	}
 
 	// obtain seed from mnemonic and passphrase
-	seed, err := lib.SeedFromMnemonic(userInfo.Mnemonic, userInfo.Passphrase)
+	seed, err := derivation.wait(ctx)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
 
-	// obtains blockchain adapater based on coinType
-	adapterInventory := adapter.GetInventory(backendLogger)
+	// the account that produced the signature, so callers can check they signed from the expected one
+	address, err := adapterInventory.DeriveAddress(seed, uint16(coinType), derivationPath, isDev)
 	if err != nil {
-		backendLogger.Error("get adapter", "error", err)
+		backendLogger.Error("derive address", "error", err)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
 
+	var completed map[string]interface{}
+	if complete {
+		if payload, completed, err = b.completePayload(ctx, req.Storage, uint16(coinType), payload, address); err != nil {
+			backendLogger.Error("complete payload", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := adapterInventory.ValidatePayload(uint16(coinType), payload); err != nil {
+			backendLogger.Error("validate payload", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if fees, feeWarnings, err = b.signFeeBounds(ctx, req, d, uint16(coinType), payload, backendLogger); err != nil {
+			return nil, err
+		}
+	}
+
+	addressBookCtx, span := tracing.Start(ctx, "sign.check_address_book")
+	err = checkAddressBook(addressBookCtx, req.Storage, uint16(coinType), payload, isDev, address)
+	tracing.End(span, err)
+	if err != nil {
+		backendLogger.Error("check address book", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+
+	contractsCtx, span := tracing.Start(ctx, "sign.check_contracts")
+	err = checkContracts(contractsCtx, req.Storage, uint16(coinType), payload)
+	tracing.End(span, err)
+	if err != nil {
+		backendLogger.Error("check contracts", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+
 	// creates signature from raw transaction payload
 	txHex, err := adapterInventory.CreateSignedTransaction(seed, uint16(coinType), derivationPath, payload, isDev)
 	if err != nil {
 		backendLogger.Error("create signature", "error", err)
 		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
 	}
+	publicKey, err := adapterInventory.DerivePublicKey(seed, uint16(coinType), derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive public key", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
 
-	backendLogger.Info("signature", "signature", txHex)
+	backendLogger.Info("signature", "signature", txHex, "address", address, "nonceScheme", capabilities.NonceScheme())
 
-	// Returns signature as output
+	// Returns signature, with the signing address, public key, nonce scheme and the hash of the payload
+	// as signed, as output
+	data := map[string]interface{}{
+		"signature":   txHex,
+		"address":     address,
+		"publicKey":   publicKey,
+		"nonceScheme": capabilities.NonceScheme(),
+		"payloadHash": sha256Hex(payload),
+	}
+	if complete {
+		data["completed"] = completed
+	}
+	for k, v := range fees {
+		data[k] = v
+	}
+	if preset, ok := d.GetOk("preset"); ok && preset.(string) != "" {
+		data["path"] = derivationPath
+	}
 	return &logical.Response{
-		Data: map[string]interface{}{
-			"signature": txHex,
-		},
+		Data:     data,
+		Warnings: append(warnings, feeWarnings...),
 	}, nil
 }
+
+// signFeeBounds checks the fee rate of payload against config/fees, in its own span
+func (b *Backend) signFeeBounds(ctx context.Context, req *logical.Request, d *framework.FieldData, coinType uint16,
+	payload string, backendLogger *slog.Logger) (map[string]interface{}, []string, error) {
+	feesCtx, span := tracing.Start(ctx, "sign.check_fee_bounds")
+	fees, warnings, err := b.checkFeeBounds(feesCtx, req, d, coinType, payload, backendLogger)
+	tracing.End(span, err)
+	if err != nil {
+		backendLogger.Error("check fee bounds", "error", err)
+		return nil, nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	return fees, warnings, nil
+}
+
+// appendPathDefaulted appends the path-defaulted warning to warnings when the requested path is
+// replaced by the path of its coin type
+func appendPathDefaulted(warnings []string, requested, path string) []string {
+	if requested == path {
+		return warnings
+	}
+	return append(warnings, helpers.Warning(helpers.WarningPathDefaulted,
+		"path %q replaced by %s, the only path of the coin type", requested, path))
+}
+
+// seedDerivation -- the seed of a user, derived concurrently with the rest of the request
+type seedDerivation struct {
+	done chan struct{}
+	seed []byte
+	err  error
+}
+
+// deriveSeed starts deriving the seed of user
+func deriveSeed(ctx context.Context, user *helpers.User) *seedDerivation {
+	derivation := &seedDerivation{done: make(chan struct{})}
+	go func() {
+		defer close(derivation.done)
+		derivation.seed, derivation.err = userSeed(ctx, user)
+	}()
+	return derivation
+}
+
+// wait returns the seed once derived. A request whose deadline passes first fails with the error
+// of ctx instead of holding its caller past it.
+func (s *seedDerivation) wait(ctx context.Context) ([]byte, error) {
+	select {
+	case <-s.done:
+		return s.seed, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release zeroes the seed once derived, whether or not the request used it
+func (s *seedDerivation) release() {
+	go func() {
+		<-s.done
+		clear(s.seed)
+	}()
+}
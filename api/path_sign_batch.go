@@ -0,0 +1,383 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/queue"
+)
+
+// signBatchWorkers bounds the items of a sign/batch signed at once
+const signBatchWorkers = 8
+
+// batchWALRetention is how long the log of a batch is kept after its last update
+const batchWALRetention = 24 * time.Hour
+
+// Statuses of the items reported by sign/batch
+const (
+	batchItemSigned  = "signed"
+	batchItemFailed  = "failed"
+	batchItemSkipped = "skipped"
+	// batchItemPending is an item held for approval by config/approvals
+	batchItemPending = "pending"
+	// batchItemInterrupted is an item not started as the plugin is reloading
+	batchItemInterrupted = "interrupted"
+)
+
+// pathSignBatch corresponds to UPDATE sign/batch. Every item is a sign request, signed by a pool of
+// workers as sign would; the results are returned in the order of the items, each with its latency.
+// With failFast the items not started yet when one fails are skipped. With a batchId the results
+// are logged as the items complete, and the batch sent again returns the logged ones without
+// signing them a second time.
+func (b *Backend) pathSignBatch(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_batch"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	items := d.Get("items").([]interface{})
+	failFast := d.Get("failFast").(bool)
+	apiKey := d.Get("apiKey").(string)
+	batchID := d.Get("batchId").(string)
+
+	if len(items) == 0 {
+		return nil, logical.CodedError(http.StatusBadRequest, helpers.ErrEmptyBatch.Error())
+	}
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if len(items) > quotas.MaxBatchCount {
+		backendLogger.Warn("batch rejected", "count", len(items), "maxBatchCount", quotas.MaxBatchCount)
+		return nil, logical.CodedError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("%s: %d", helpers.ErrBatchTooLarge, quotas.MaxBatchCount))
+	}
+	if batchID != "" && !helpers.ValidBatchID(batchID) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidBatchID.Error())
+	}
+	if d.Get("async").(bool) {
+		if batchID != "" {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrAsyncBatchID.Error())
+		}
+		return b.enqueueSignBatch(ctx, req, items, failFast, apiKey)
+	}
+
+	// every item takes a request slot of config/quotas, the pool stays within them
+	workers := min(signBatchWorkers, len(items))
+	if quotas.MaxConcurrentRequests > 0 {
+		workers = min(workers, quotas.MaxConcurrentRequests)
+	}
+
+	sign := b.signOp(false)
+	schema := b.Route("sign").Fields
+
+	var wal *helpers.BatchWAL
+	if batchID != "" {
+		wal, err = b.openBatchWAL(ctx, req, schema, batchID, items, apiKey)
+		if err != nil {
+			backendLogger.Error("open batch log", "error", err, "batchId", batchID)
+			if errors.Is(err, helpers.ErrBatchIDInUse) || errors.Is(err, helpers.ErrBatchItemsChanged) {
+				return nil, logical.CodedError(http.StatusConflict, err.Error())
+			}
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	results := make([]map[string]interface{}, len(items))
+	indexes := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		failMu sync.Mutex
+		failed bool
+		walMu  sync.Mutex
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// the logged items were signed by the interrupted batch
+				if wal != nil && wal.Items[i].Result != nil {
+					results[i] = resumedBatchResult(i, wal.Items[i].Result)
+					continue
+				}
+				failMu.Lock()
+				skip := failFast && failed
+				failMu.Unlock()
+				if skip {
+					results[i] = map[string]interface{}{"index": i, "status": batchItemSkipped}
+					continue
+				}
+				// the items left are sent again with the batchId once the plugin is back
+				if b.reloading() {
+					results[i] = map[string]interface{}{"index": i, "status": batchItemInterrupted}
+					continue
+				}
+
+				results[i] = b.signBatchItem(ctx, req, sign, schema, i, items[i], apiKey)
+				if wal != nil && results[i]["status"] != batchItemFailed {
+					walMu.Lock()
+					b.logBatchItem(ctx, req.Storage, wal, i, results[i])
+					walMu.Unlock()
+				}
+				if results[i]["status"] == batchItemFailed {
+					failMu.Lock()
+					failed = true
+					failMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	counts := map[string]int{batchItemSigned: 0, batchItemFailed: 0, batchItemSkipped: 0, batchItemPending: 0,
+		batchItemInterrupted: 0}
+	resumed := 0
+	for _, result := range results {
+		counts[result["status"].(string)]++
+		if result["resumed"] == true {
+			resumed++
+		}
+	}
+	backendLogger.Info("batch signed", "items", len(items), "signed", counts[batchItemSigned],
+		"failed", counts[batchItemFailed], "skipped", counts[batchItemSkipped], "pending", counts[batchItemPending],
+		"interrupted", counts[batchItemInterrupted], "resumed", resumed, "batchId", batchID, "workers", workers)
+
+	data := map[string]interface{}{
+		"results":     results,
+		"signed":      counts[batchItemSigned],
+		"failed":      counts[batchItemFailed],
+		"skipped":     counts[batchItemSkipped],
+		"pending":     counts[batchItemPending],
+		"interrupted": counts[batchItemInterrupted],
+	}
+	if batchID != "" {
+		data["batchId"], data["resumed"] = batchID, resumed
+	}
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// signOp returns the chain of sign, which the items of batches and jobs and the transactions of
+// build/evm-tx are signed with. The chain of session/sign authorizes with the signing session
+// instead of the API key, its captures are then those of the user of the session.
+func (b *Backend) signOp(session bool) framework.OperationFunc {
+	charged := b.withBudget(signRequestIntent, b.withReceipt(b.pathSign))
+	policies := b.withPayloadHooks(b.withTravelRule(b.withApproval(signRequestIntent, charged)))
+	if session {
+		return b.withSigningSession(b.withDebugCapture(policies))
+	}
+	return b.withDebugCapture(b.withAPIKey(lib.OperationSign, policies))
+}
+
+// openBatchWAL returns the log of the batch batchID, created for items when it has none. A logged
+// batch is only resumed by its entity and with the same items.
+func (b *Backend) openBatchWAL(ctx context.Context, req *logical.Request, schema map[string]*framework.FieldSchema,
+	batchID string, items []interface{}, apiKey string) (*helpers.BatchWAL, error) {
+	fingerprints := make([]string, len(items))
+	for i, item := range items {
+		fingerprints[i] = batchItemFingerprint(schema, item, apiKey)
+	}
+
+	wal, err := helpers.GetBatchWAL(ctx, req.Storage, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if wal != nil {
+		if wal.EntityID != req.EntityID {
+			return nil, helpers.ErrBatchIDInUse
+		}
+		if len(wal.Items) != len(items) {
+			return nil, helpers.ErrBatchItemsChanged
+		}
+		for i, item := range wal.Items {
+			if item.Fingerprint != fingerprints[i] {
+				return nil, fmt.Errorf("%w: item %d", helpers.ErrBatchItemsChanged, i)
+			}
+		}
+		return wal, nil
+	}
+
+	now := time.Now().UTC()
+	wal = &helpers.BatchWAL{
+		ID:        batchID,
+		EntityID:  req.EntityID,
+		Items:     make([]helpers.BatchWALItem, len(items)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for i, fingerprint := range fingerprints {
+		wal.Items[i].Fingerprint = fingerprint
+	}
+	if err := helpers.PutBatchWAL(ctx, req.Storage, wal); err != nil {
+		return nil, err
+	}
+	return wal, nil
+}
+
+// logBatchItem records the result of item i in wal. A failed write is logged: the item is then
+// signed again if the batch is resumed.
+func (b *Backend) logBatchItem(ctx context.Context, s logical.Storage, wal *helpers.BatchWAL, i int,
+	result map[string]interface{}) {
+	logged := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		if k != "latencyMs" && k != "index" {
+			logged[k] = v
+		}
+	}
+	wal.Items[i].Result = logged
+	wal.UpdatedAt = time.Now().UTC()
+	if err := helpers.PutBatchWAL(ctx, s, wal); err != nil {
+		b.logger.Error("log batch item", "error", err, "batchId", wal.ID, "index", i)
+		wal.Items[i].Result = nil
+	}
+}
+
+// resumedBatchResult returns the logged result of item i
+func resumedBatchResult(i int, logged map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(logged)+2)
+	for k, v := range logged {
+		result[k] = v
+	}
+	result["index"], result["resumed"] = i, true
+	return result
+}
+
+// batchItemFingerprint returns the fingerprint of the sign fields of item, so a resumed batch is
+// checked to hold the logged items. Items that are not valid sign requests have none.
+func batchItemFingerprint(schema map[string]*framework.FieldSchema, item interface{}, apiKey string) string {
+	raw, ok := batchItemFields(item, apiKey)
+	if !ok {
+		return ""
+	}
+	// the items are signed with their legacy fields renamed, a batch resumed with the current names matches
+	renameLegacyFields("sign", raw)
+	fd := &framework.FieldData{Raw: raw, Schema: schema}
+	if err := fd.Validate(); err != nil {
+		return ""
+	}
+	fingerprint, err := signFingerprint(fd)
+	if err != nil {
+		return ""
+	}
+	return fingerprint
+}
+
+// batchItemFields returns the request data of item, its apiKey defaulting to the one of the batch
+func batchItemFields(item interface{}, apiKey string) (map[string]interface{}, bool) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	raw := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		raw[k] = v
+	}
+	if _, ok := raw["apiKey"]; !ok && apiKey != "" {
+		raw["apiKey"] = apiKey
+	}
+	return raw, true
+}
+
+// pruneBatchWALs removes the logs of the batches not updated for batchWALRetention
+func (b *Backend) pruneBatchWALs(ctx context.Context, s logical.Storage, now time.Time) error {
+	ids, err := s.List(ctx, config.BatchWALStoragePath)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		wal, err := helpers.GetBatchWAL(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		if wal == nil || now.Before(wal.UpdatedAt.Add(batchWALRetention)) {
+			continue
+		}
+		if err := helpers.DeleteBatchWAL(ctx, s, id); err != nil {
+			return err
+		}
+		b.logger.Info("batch log expired", "batchId", id)
+	}
+	return nil
+}
+
+// signBatchItem signs item i of a batch with sign and returns its result
+func (b *Backend) signBatchItem(ctx context.Context, req *logical.Request, sign framework.OperationFunc,
+	schema map[string]*framework.FieldSchema, i int, item interface{}, apiKey string) map[string]interface{} {
+	result := map[string]interface{}{"index": i}
+	start := time.Now()
+	defer func() {
+		result["latencyMs"] = time.Since(start).Milliseconds()
+	}()
+
+	raw, ok := batchItemFields(item, apiKey)
+	if !ok {
+		result["status"], result["error"] = batchItemFailed, helpers.ErrInvalidBatchItem.Error()
+		return result
+	}
+	if err := b.checkFieldLengths(ctx, req.Storage, raw); err != nil {
+		result["status"], result["error"] = batchItemFailed, err.Error()
+		return result
+	}
+	raw, warnings, err := b.migrateLegacyFields(ctx, req.Storage, "sign", raw)
+	if err != nil {
+		result["status"], result["error"] = batchItemFailed, err.Error()
+		return result
+	}
+	if err := b.validateRequestFields("sign", schema, raw); err != nil {
+		result["status"], result["error"] = batchItemFailed, err.Error()
+		return result
+	}
+	// the items rejected by a policy are violations of their users, as their sign requests would be
+	uuid, _ := raw["uuid"].(string)
+	if err := b.checkDerivationPolicy(ctx, req.Storage, "sign", raw); err != nil {
+		b.recordUserViolation(ctx, req, uuid, err)
+		result["status"], result["error"] = batchItemFailed, err.Error()
+		return result
+	}
+
+	// the item is served as a sign request of its own, its fields are the request data, queued
+	// behind the interactive requests
+	itemReq := *req
+	itemReq.Path, itemReq.Data = "sign", raw
+	resp, err := sign(withRequestClass(ctx, queue.Batch), &itemReq, &framework.FieldData{Raw: raw, Schema: schema})
+	if err != nil {
+		b.recordUserViolation(ctx, req, uuid, err)
+		result["status"], result["error"] = batchItemFailed, err.Error()
+		return result
+	}
+
+	result["status"] = batchItemSigned
+	if resp != nil && resp.Data["approvalStatus"] == helpers.ApprovalPending {
+		result["status"] = batchItemPending
+	}
+	if resp != nil {
+		for k, v := range resp.Data {
+			result[k] = v
+		}
+		warnings = append(warnings, resp.Warnings...)
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	return result
+}
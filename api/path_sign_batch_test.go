@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestBackend_HandleRequest_SignBatch(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data,
+		})
+	}
+	item := func(index int) map[string]interface{} {
+		return map[string]interface{}{
+			"uuid":     signTestUUID,
+			"path":     fmt.Sprintf("m/44'/60'/0'/0/%d", index),
+			"coinType": int(slip44.Ether),
+			"payload":  signTestPayload,
+		}
+	}
+
+	t.Run("results follow the order of the items", func(t *testing.T) {
+		items := make([]interface{}, 12)
+		for i := range items {
+			items[i] = item(i)
+		}
+		items[5] = map[string]interface{}{"uuid": signTestUUID, "path": signTestDerivationPath,
+			"coinType": int(slip44.Ether), "payload": signTestMalformedPayload}
+		items[7] = "not an object"
+
+		resp, err := request("sign/batch", map[string]interface{}{"items": items})
+		require.NoError(t, err)
+		assert.Equal(t, 10, resp.Data["signed"])
+		assert.Equal(t, 2, resp.Data["failed"])
+		assert.Equal(t, 0, resp.Data["skipped"])
+
+		results := resp.Data["results"].([]map[string]interface{})
+		require.Len(t, results, len(items))
+		for i, result := range results {
+			assert.Equal(t, i, result["index"])
+			assert.Contains(t, result, "latencyMs")
+			if i == 5 || i == 7 {
+				assert.Equal(t, batchItemFailed, result["status"])
+				assert.NotEmpty(t, result["error"])
+				continue
+			}
+
+			single, err := request("sign", item(i))
+			require.NoError(t, err)
+			assert.Equal(t, batchItemSigned, result["status"])
+			assert.Equal(t, single.Data["address"], result["address"])
+			assert.Equal(t, single.Data["signature"], result["signature"])
+		}
+	})
+
+	t.Run("fail fast skips the items after a failure", func(t *testing.T) {
+		// a single request slot signs the items one at a time
+		_, err := request("config/quotas", map[string]interface{}{"maxConcurrentRequests": 1})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.DeleteOperation, Path: "config/quotas", Storage: s,
+			})
+			require.NoError(t, err)
+		})
+
+		items := []interface{}{item(0), map[string]interface{}{"uuid": "unknown", "path": signTestDerivationPath,
+			"coinType": int(slip44.Ether), "payload": signTestPayload}, item(2), item(3)}
+		resp, err := request("sign/batch", map[string]interface{}{"items": items, "failFast": true})
+		require.NoError(t, err)
+
+		results := resp.Data["results"].([]map[string]interface{})
+		require.Len(t, results, len(items))
+		assert.Equal(t, batchItemSigned, results[0]["status"])
+		assert.Equal(t, batchItemFailed, results[1]["status"])
+		assert.Equal(t, batchItemSkipped, results[2]["status"])
+		assert.Equal(t, batchItemSkipped, results[3]["status"])
+	})
+
+	t.Run("empty and oversized batches are rejected", func(t *testing.T) {
+		_, err := request("sign/batch", map[string]interface{}{"items": []interface{}{}})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, err.(logical.HTTPCodedError).Code())
+
+		_, err = request("config/quotas", map[string]interface{}{"maxBatchCount": 2})
+		require.NoError(t, err)
+		_, err = request("sign/batch", map[string]interface{}{"items": []interface{}{item(0), item(1), item(2)}})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, err.(logical.HTTPCodedError).Code())
+	})
+
+	t.Run("a logged batch resumes after a reload", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.DeleteOperation, Path: "config/quotas", Storage: s,
+		})
+		require.NoError(t, err)
+
+		items := []interface{}{item(0), item(1), item(2)}
+		resp, err := request("sign/batch", map[string]interface{}{"items": items, "batchId": "payout-42"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, resp.Data["signed"])
+		assert.Equal(t, "payout-42", resp.Data["batchId"])
+		assert.Equal(t, 0, resp.Data["resumed"])
+		signed := resp.Data["results"].([]map[string]interface{})
+
+		b.clean(ctx)
+		_, err = request("sign/batch", map[string]interface{}{"items": items, "batchId": "payout-42"})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, err.(logical.HTTPCodedError).Code())
+
+		// the plugin is reloaded: the items left of a stopping instance are interrupted
+		reloaded := NewBackend(&logical.BackendConfig{})
+		require.NoError(t, reloaded.Setup(ctx, &logical.BackendConfig{}))
+		reloaded.stop()
+		resp, err = reloaded.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s,
+			Data: map[string]interface{}{"items": append(items[:3:3], item(3)), "batchId": "payout-43"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 4, resp.Data["interrupted"])
+
+		reloaded = NewBackend(&logical.BackendConfig{})
+		require.NoError(t, reloaded.Setup(ctx, &logical.BackendConfig{}))
+		resp, err = reloaded.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s,
+			Data: map[string]interface{}{"items": items, "batchId": "payout-42"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, resp.Data["resumed"])
+		for i, result := range resp.Data["results"].([]map[string]interface{}) {
+			assert.Equal(t, true, result["resumed"])
+			assert.Equal(t, batchItemSigned, result["status"])
+			assert.Equal(t, signed[i]["signature"], result["signature"])
+		}
+
+		_, err = reloaded.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s,
+			Data: map[string]interface{}{"items": []interface{}{item(0), item(2), item(1)}, "batchId": "payout-42"},
+		})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(logical.HTTPCodedError).Code())
+
+		_, err = reloaded.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "sign/batch", Storage: s, EntityID: "other",
+			Data: map[string]interface{}{"items": items, "batchId": "payout-42"},
+		})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusConflict, err.(logical.HTTPCodedError).Code())
+
+		require.NoError(t, reloaded.pruneBatchWALs(ctx, s, time.Now().Add(batchWALRetention+time.Minute)))
+		ids, err := s.List(ctx, config.BatchWALStoragePath)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
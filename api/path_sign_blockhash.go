@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/tracing"
+)
+
+// recentBlockhashesSysvar is the account of the Solana sysvar listing the recent blockhashes,
+// newest first
+const recentBlockhashesSysvar = "SysvarRecentB1ockHashes11111111111111111111"
+
+// recentBlockhashes is the result of the jsonParsed getAccountInfo call of the RecentBlockhashes sysvar
+type recentBlockhashes struct {
+	Value struct {
+		Data struct {
+			Parsed struct {
+				Info []struct {
+					Blockhash string `json:"blockhash"`
+				} `json:"info"`
+			} `json:"parsed"`
+		} `json:"data"`
+	} `json:"value"`
+}
+
+// checkBlockhashAge checks the recent blockhash of the Solana payload against the maxBlockhashAge
+// of the config/rpc endpoint of coinType, in its own span. A stale blockhash, or one whose age the
+// node could not tell, returns a stale-blockhash warning, or an error with rejectStaleBlockhash.
+// Other coin types, endpoints without maxBlockhashAge and durable nonce messages are not checked.
+func (b *Backend) checkBlockhashAge(ctx context.Context, s logical.Storage, coinType uint16, payload string,
+	backendLogger *slog.Logger) ([]string, error) {
+	if !solana.NewSolanaAdapter(b.logger).CanDo(coinType) {
+		return nil, nil
+	}
+	endpoint, err := helpers.GetRPCEndpoint(ctx, s, coinType)
+	if err != nil {
+		backendLogger.Error("get rpc endpoint", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if endpoint == nil || endpoint.MaxBlockhashAge == 0 {
+		return nil, nil
+	}
+	var tx lib.SolanaRawTx
+	if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, lib.ErrInvalidPayload.Error())
+	}
+	message, err := hex.DecodeString(strings.TrimPrefix(tx.RawTxHex, "0x"))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, lib.ErrInvalidPayload.Error())
+	}
+	if solana.UsesDurableNonce(message) {
+		return nil, nil
+	}
+	blockhash, err := solana.RecentBlockhash(message)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	blockhashCtx, span := tracing.Start(ctx, "sign.check_blockhash")
+	age, err := b.blockhashAge(blockhashCtx, endpoint, blockhash)
+	tracing.End(span, err)
+
+	var stale error
+	switch {
+	case err != nil:
+		backendLogger.Warn("blockhash age unknown", "error", err, "host", rpc.Host(endpoint.URL))
+		stale = fmt.Errorf("%w: %w", helpers.ErrStaleBlockhash, err)
+	case age < 0:
+		stale = fmt.Errorf("%w: %s expired", helpers.ErrStaleBlockhash, blockhash)
+	case age > endpoint.MaxBlockhashAge:
+		stale = fmt.Errorf("%w: %s is %d blocks old, at most %d are accepted", helpers.ErrStaleBlockhash,
+			blockhash, age, endpoint.MaxBlockhashAge)
+	default:
+		return nil, nil
+	}
+	if endpoint.RejectStaleBlockhash {
+		backendLogger.Error("check blockhash age", "error", stale)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, stale.Error())
+	}
+	backendLogger.Warn("check blockhash age", "error", stale)
+	return []string{helpers.Warning(helpers.WarningStaleBlockhash, "%s", stale)}, nil
+}
+
+// blockhashAge returns the number of blocks produced after blockhash, as listed by the
+// RecentBlockhashes sysvar of the node of endpoint, or -1 when it is no longer listed
+func (b *Backend) blockhashAge(ctx context.Context, endpoint *helpers.RPCEndpoint,
+	blockhash solana.PublicKey) (int, error) {
+	client, err := rpc.NewClient(b.logger, endpoint.URL, endpoint.MaxRetries, endpoint.Timeout)
+	if err != nil {
+		return 0, err
+	}
+	var recent recentBlockhashes
+	if _, err := client.Call(ctx, "getAccountInfo", []any{recentBlockhashesSysvar,
+		map[string]string{"encoding": "jsonParsed", "commitment": "confirmed"}}, &recent); err != nil {
+		return 0, fmt.Errorf("getAccountInfo: %w", err)
+	}
+	info := recent.Value.Data.Parsed.Info
+	if len(info) == 0 {
+		return 0, fmt.Errorf("getAccountInfo: %w", rpc.ErrNoResult)
+	}
+	for age, entry := range info {
+		if entry.Blockhash == blockhash.String() {
+			return age, nil
+		}
+	}
+	return -1, nil
+}
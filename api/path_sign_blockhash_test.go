@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// recentBlockhashesResult returns the getAccountInfo result of the RecentBlockhashes sysvar listing blockhashes
+func recentBlockhashesResult(blockhashes ...string) string {
+	info := ""
+	for i, blockhash := range blockhashes {
+		if i > 0 {
+			info += ","
+		}
+		info += `{"blockhash":"` + blockhash + `","feeCalculator":{"lamportsPerSignature":"5000"}}`
+	}
+	return `{"context":{"slot":1},"value":{"data":{"parsed":{"info":[` + info +
+		`],"type":"recentBlockhashes"},"program":"sysvar","space":6008}}}`
+}
+
+func TestBackend_PathSignBlockhashAge(t *testing.T) {
+	ctx := context.Background()
+	owner, err := solana.PublicKeyFromBase58(splTestOwner)
+	require.NoError(t, err)
+	recipient, err := solana.PublicKeyFromBase58(splTestRecipient)
+	require.NoError(t, err)
+	blockhash, err := solana.BlockhashFromBase58(splTestBlockhash)
+	require.NoError(t, err)
+	message, err := solana.BuildSOLTransfer(owner, recipient, 1000, blockhash, "")
+	require.NoError(t, err)
+	payload := `{"rawTxHex":"` + hex.EncodeToString(message) + `"}`
+
+	sign := func(t *testing.T, node string, maxAge int, reject bool, payload string) (*logical.Response, error) {
+		t.Helper()
+		s := newXpubTestStorage(t)
+		require.NoError(t, helpers.PutRPCEndpoint(ctx, s, &helpers.RPCEndpoint{
+			CoinType: 501, URL: node, Timeout: time.Second, MaxBlockhashAge: maxAge, RejectStaleBlockhash: reject,
+		}))
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": splTestPath, "coinType": 501, "payload": payload,
+		}
+		return createSignTestBackend(t).pathSign(ctx, &logical.Request{Storage: s, Data: data},
+			createSignFieldData(data))
+	}
+
+	t.Run("recent blockhash", func(t *testing.T) {
+		calls := map[string][]interface{}{}
+		node := newCompleteTestNode(t, map[string]string{
+			"getAccountInfo": recentBlockhashesResult(splTestMint, splTestBlockhash),
+		}, calls)
+		got, err := sign(t, node.URL, 10, true, payload)
+		require.NoError(t, err)
+		assert.NotEmpty(t, got.Data["signature"])
+		assert.Empty(t, got.Warnings)
+		assert.Equal(t, "SysvarRecentB1ockHashes11111111111111111111", calls["getAccountInfo"][0])
+	})
+
+	t.Run("unchecked without maxBlockhashAge", func(t *testing.T) {
+		calls := map[string][]interface{}{}
+		node := newCompleteTestNode(t, map[string]string{}, calls)
+		_, err := sign(t, node.URL, 0, true, payload)
+		require.NoError(t, err)
+		assert.Empty(t, calls)
+	})
+
+	t.Run("stale blockhashes are warned of", func(t *testing.T) {
+		node := newCompleteTestNode(t, map[string]string{
+			"getAccountInfo": recentBlockhashesResult(splTestMint, splTestRecipient, splTestBlockhash),
+		}, map[string][]interface{}{})
+		got, err := sign(t, node.URL, 1, false, payload)
+		require.NoError(t, err)
+		assert.NotEmpty(t, got.Data["signature"])
+		require.Len(t, got.Warnings, 1)
+		assert.Equal(t, helpers.WarningStaleBlockhash, helpers.WarningCode(got.Warnings[0]))
+		assert.Contains(t, got.Warnings[0], "is 2 blocks old, at most 1 are accepted")
+	})
+
+	t.Run("stale blockhashes are rejected", func(t *testing.T) {
+		node := newCompleteTestNode(t, map[string]string{
+			"getAccountInfo": recentBlockhashesResult(splTestMint),
+		}, map[string][]interface{}{})
+		_, err := sign(t, node.URL, 10, true, payload)
+		require.ErrorContains(t, err, helpers.ErrStaleBlockhash.Error())
+		assert.ErrorContains(t, err, "expired")
+
+		// a node that cannot tell the age is stale too
+		node = newCompleteTestNode(t, map[string]string{"getAccountInfo": `{"value":null}`}, map[string][]interface{}{})
+		_, err = sign(t, node.URL, 10, true, payload)
+		require.ErrorContains(t, err, helpers.ErrStaleBlockhash.Error())
+	})
+
+	t.Run("durable nonces are not checked", func(t *testing.T) {
+		systemProgram, err := solana.PublicKeyFromBase58(solana.SystemProgramID)
+		require.NoError(t, err)
+		nonce, err := solana.CompileMessage(owner, blockhash, []solana.Instruction{{
+			ProgramID: systemProgram,
+			Accounts: []solana.AccountMeta{
+				{PublicKey: recipient, IsWritable: true},
+				{PublicKey: owner, IsSigner: true},
+			},
+			// AdvanceNonceAccount
+			Data: binary.LittleEndian.AppendUint32(nil, 4),
+		}})
+		require.NoError(t, err)
+		calls := map[string][]interface{}{}
+		node := newCompleteTestNode(t, map[string]string{}, calls)
+		_, err = sign(t, node.URL, 10, true, `{"rawTxHex":"`+hex.EncodeToString(nonce)+`"}`)
+		require.NoError(t, err)
+		assert.Empty(t, calls)
+	})
+}
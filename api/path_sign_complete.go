@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/rpc"
+)
+
+// completePayload fills the fields of the sign payload of coinType that the node configured in
+// config/rpc knows, for the account address: the missing chainId, nonce, gasPrice and gasLimit of
+// EVM transactions, and a fresh recent blockhash for Solana messages. It returns the completed
+// payload and the values fetched.
+func (b *Backend) completePayload(ctx context.Context, s logical.Storage, coinType uint16,
+	payload, address string) (string, map[string]interface{}, error) {
+	client, host, err := b.rpcClient(ctx, s, coinType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil || fields == nil {
+		return "", nil, fmt.Errorf("%w: %w", helpers.ErrCompletePayload, lib.ErrInvalidPayload)
+	}
+
+	var completed map[string]interface{}
+	switch {
+	case evm.NewEthereumAdapter(b.logger).CanDo(coinType):
+		completed, err = completeEVMPayload(ctx, client, fields, address)
+	case solana.NewSolanaAdapter(b.logger).CanDo(coinType):
+		completed, err = completeSolanaPayload(ctx, client, fields)
+	default:
+		return "", nil, helpers.ErrNoCompletion
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", helpers.ErrCompletePayload, err)
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return "", nil, err
+	}
+	b.logger.Info("payload completed", "coinType", coinType, "host", host, "address", address,
+		"completed", completed)
+	return string(encoded), completed, nil
+}
+
+// completeEVMPayload fetches the fields missing from the EVM transaction fields sent by address
+func completeEVMPayload(ctx context.Context, client *rpc.Client, fields map[string]json.RawMessage,
+	address string) (map[string]interface{}, error) {
+	completed := map[string]interface{}{}
+	for _, f := range []struct {
+		name   string
+		method string
+		params []any
+	}{
+		{"chainId", "eth_chainId", nil},
+		{"nonce", "eth_getTransactionCount", []any{address, "pending"}},
+		{"gasPrice", "eth_gasPrice", nil},
+		{"gasLimit", "eth_estimateGas", nil},
+	} {
+		if _, ok := fields[f.name]; ok {
+			continue
+		}
+		params := f.params
+		if f.name == "gasLimit" {
+			var err error
+			if params, err = estimateGasParams(fields, address); err != nil {
+				return nil, err
+			}
+		}
+		var quantity hexutil.Big
+		if _, err := client.Call(ctx, f.method, params, &quantity); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.method, err)
+		}
+		// payload amounts are JSON numbers
+		value := quantity.ToInt().String()
+		fields[f.name] = json.RawMessage(value)
+		completed[f.name] = value
+	}
+	return completed, nil
+}
+
+// estimateGasParams returns the eth_estimateGas call of the transaction fields sent by address
+func estimateGasParams(fields map[string]json.RawMessage, address string) ([]any, error) {
+	call := map[string]string{"from": address}
+	if raw, ok := fields["to"]; ok {
+		var to string
+		if err := json.Unmarshal(raw, &to); err != nil {
+			return nil, fmt.Errorf("to: %w", err)
+		}
+		call["to"] = to
+	}
+	if raw, ok := fields["value"]; ok {
+		value := new(big.Int)
+		if err := json.Unmarshal(raw, value); err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		call["value"] = hexutil.EncodeBig(value)
+	}
+	if raw, ok := fields["data"]; ok {
+		var data string
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("data: %w", err)
+		}
+		if data != "" {
+			call["data"] = withHexPrefix(data)
+		}
+	}
+	return []any{call}, nil
+}
+
+// latestBlockhash is the result of the Solana getLatestBlockhash call
+type latestBlockhash struct {
+	Value struct {
+		Blockhash            string `json:"blockhash"`
+		LastValidBlockHeight uint64 `json:"lastValidBlockHeight"`
+	} `json:"value"`
+}
+
+// completeSolanaPayload replaces the recent blockhash of the message in fields by the latest one
+func completeSolanaPayload(ctx context.Context, client *rpc.Client,
+	fields map[string]json.RawMessage) (map[string]interface{}, error) {
+	var rawTxHex string
+	if err := json.Unmarshal(fields["rawTxHex"], &rawTxHex); err != nil {
+		return nil, fmt.Errorf("rawTxHex: %w", err)
+	}
+	message, err := hex.DecodeString(strings.TrimPrefix(rawTxHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("rawTxHex: %w", err)
+	}
+
+	var latest latestBlockhash
+	if _, err := client.Call(ctx, "getLatestBlockhash", []any{map[string]string{"commitment": "finalized"}},
+		&latest); err != nil {
+		return nil, fmt.Errorf("getLatestBlockhash: %w", err)
+	}
+	blockhash, err := solana.BlockhashFromBase58(latest.Value.Blockhash)
+	if err != nil {
+		return nil, err
+	}
+	message, err = solana.SetRecentBlockhash(message, blockhash)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(hex.EncodeToString(message))
+	if err != nil {
+		return nil, err
+	}
+	fields["rawTxHex"] = encoded
+	return map[string]interface{}{
+		"recentBlockhash":      latest.Value.Blockhash,
+		"lastValidBlockHeight": latest.Value.LastValidBlockHeight,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// newCompleteTestNode returns a JSON-RPC node answering each method with its result, recording the calls
+func newCompleteTestNode(t *testing.T, results map[string]string, calls map[string][]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		calls[req.Method] = req.Params
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + results[req.Method] + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newCompleteTestStorage returns the signing user storage with the node url configured for coinType
+func newCompleteTestStorage(t *testing.T, coinType uint16, url string) logical.Storage {
+	t.Helper()
+	s := newXpubTestStorage(t)
+	if url != "" {
+		require.NoError(t, helpers.PutRPCEndpoint(context.Background(), s, &helpers.RPCEndpoint{
+			CoinType: coinType, URL: url, Timeout: time.Second,
+		}))
+	}
+	return s
+}
+
+func TestBackend_PathSignComplete(t *testing.T) {
+	ctx := context.Background()
+	sign := func(storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return createSignTestBackend(t).pathSign(ctx, &logical.Request{Storage: storage, Data: data},
+			createSignFieldData(data))
+	}
+
+	t.Run("evm nonce and gas are fetched", func(t *testing.T) {
+		calls := map[string][]interface{}{}
+		node := newCompleteTestNode(t, map[string]string{
+			"eth_getTransactionCount": `"0x7"`,
+			"eth_gasPrice":            `"0x3b9aca00"`,
+			"eth_estimateGas":         `"0x5208"`,
+		}, calls)
+
+		got, err := sign(newCompleteTestStorage(t, 60, node.URL), map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/44'/60'/0'/0/0", "coinType": 60, "complete": true,
+			"payload": `{"chainId":1,"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","value":1000,"data":""}`,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"nonce": "7", "gasPrice": "1000000000", "gasLimit": "21000"},
+			got.Data["completed"])
+
+		var tx types.Transaction
+		require.NoError(t, tx.UnmarshalBinary(common.FromHex(got.Data["signature"].(string))))
+		assert.Equal(t, uint64(7), tx.Nonce())
+		assert.Equal(t, uint64(21000), tx.Gas())
+		assert.Equal(t, int64(1000000000), tx.GasPrice().Int64())
+
+		// the chainId of the payload is kept
+		assert.NotContains(t, calls, "eth_chainId")
+		assert.Equal(t, []interface{}{"0x9858EfFD232B4033E47d90003D41EC34EcaEda94", "pending"},
+			calls["eth_getTransactionCount"])
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"from":  "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+			"to":    "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8",
+			"value": "0x3e8",
+		}}, calls["eth_estimateGas"])
+	})
+
+	t.Run("solana blockhash is replaced", func(t *testing.T) {
+		stale, err := solana.BlockhashFromBase58("EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N")
+		require.NoError(t, err)
+		fresh, err := solana.BlockhashFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+		require.NoError(t, err)
+		feePayer, err := solana.PublicKeyFromBase58("4Nd1mBQtrMJVYVfKf2PJy9NZUZdTAsp7D4xWLs4gDB4T")
+		require.NoError(t, err)
+		message, err := solana.CompileMessage(feePayer, stale, nil)
+		require.NoError(t, err)
+		node := newCompleteTestNode(t, map[string]string{
+			"getLatestBlockhash": `{"context":{"slot":1},"value":{"blockhash":"` + fresh.String() +
+				`","lastValidBlockHeight":300}}`,
+		}, map[string][]interface{}{})
+
+		payload, completed, err := createSignTestBackend(t).completePayload(ctx,
+			newCompleteTestStorage(t, 501, node.URL), 501, `{"rawTxHex":"`+hex.EncodeToString(message)+`"}`, "")
+		require.NoError(t, err)
+		assert.Equal(t, fresh.String(), completed["recentBlockhash"])
+		assert.Equal(t, uint64(300), completed["lastValidBlockHeight"])
+
+		expected, err := solana.CompileMessage(feePayer, fresh, nil)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"rawTxHex":"`+hex.EncodeToString(expected)+`"}`, payload)
+	})
+
+	t.Run("no endpoint", func(t *testing.T) {
+		_, err := sign(newCompleteTestStorage(t, 60, ""), map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/44'/60'/0'/0/0", "coinType": 60, "complete": true,
+			"payload": `{"chainId":1,"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","value":1}`,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrNoRPCEndpoint.Error())
+	})
+
+	t.Run("coin without completion", func(t *testing.T) {
+		_, _, err := createSignTestBackend(t).completePayload(ctx,
+			newCompleteTestStorage(t, 0, "http://node"), 0, `{}`, "")
+		require.ErrorIs(t, err, helpers.ErrNoCompletion)
+	})
+}
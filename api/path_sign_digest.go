@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/preimage"
+)
+
+// pathSignDigest signs a raw 32 byte digest. It is an escape hatch for chains
+// without an adapter, so it stays disabled until config/features enables it.
+func (b *Backend) pathSignDigest(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	return b.signDigest(ctx, req, d, false)
+}
+
+// pathSignDigestOverride corresponds to UPDATE sign/digest/override: sign/digest without the digest
+// policy of config/features, for the admins granted this path
+func (b *Backend) pathSignDigestOverride(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	return b.signDigest(ctx, req, d, true)
+}
+
+// signDigest signs the digest, or the hash of the message, of a request to sign/digest. With
+// digestPreimageRequired only messages are accepted, and those recognized as the pre-image of a
+// transaction are refused unless override is set.
+func (b *Backend) signDigest(ctx context.Context, req *logical.Request,
+	d *framework.FieldData, override bool) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_digest"), slog.Bool("override", override))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	features, err := helpers.GetFeatures(ctx, req)
+	if err != nil {
+		backendLogger.Error("get features", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !features.SignDigestEnabled {
+		backendLogger.Warn("sign digest rejected, feature disabled")
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("sign/digest: %s", helpers.ErrFeatureDisabled))
+	}
+	enforced := features.DigestPreimageRequired && !override
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	curve := d.Get("curve").(string)
+	digestHex := d.Get("digest").(string)
+	messageHex := d.Get("message").(string)
+
+	if (digestHex == "") == (messageHex == "") {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrDigestOrMessage.Error())
+	}
+
+	var digest []byte
+	var kind string
+	if messageHex != "" {
+		message, err := hex.DecodeString(strings.TrimPrefix(messageHex, "0x"))
+		if err != nil {
+			backendLogger.Error("invalid message", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, lib.ErrInvalidPayload.Error())
+		}
+		if kind = preimage.Recognize(message); kind != "" && enforced {
+			backendLogger.Warn("sign digest rejected, recognized pre-image", "kind", kind)
+			return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("%s: %s", preimage.ErrRecognized, kind))
+		}
+		if digest, err = preimage.Digest(d.Get("hash").(string), message); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	} else {
+		if enforced {
+			backendLogger.Warn("sign digest rejected, pre-image required")
+			return nil, logical.CodedError(http.StatusForbidden, helpers.ErrPreimageRequired.Error())
+		}
+		digest, err = hex.DecodeString(strings.TrimPrefix(digestHex, "0x"))
+		if err != nil || len(digest) != lib.DigestLength {
+			backendLogger.Error("invalid digest", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, lib.ErrInvalidDigestLength.Error())
+		}
+	}
+
+	// validate data provided
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.AuthorizeAnyCoin(); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	signature, publicKey, err := lib.SignDigest(seed, curve, derivationPath, digest)
+	if err != nil {
+		backendLogger.Error("sign digest", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// every use of the escape hatch is worth a trace in the logs
+	backendLogger.Warn("raw digest signed", "uuid", uuid, "path", derivationPath, "curve", curve,
+		"digest", hex.EncodeToString(digest), "nonceScheme", lib.NonceScheme(curve), "preimage", kind)
+
+	data := map[string]interface{}{
+		"signature":   hex.EncodeToString(signature),
+		"publicKey":   hex.EncodeToString(publicKey),
+		"nonceScheme": lib.NonceScheme(curve),
+	}
+	if messageHex != "" {
+		data["digest"] = hex.EncodeToString(digest)
+	}
+	if kind != "" {
+		data["preimage"] = kind
+	}
+	resp := &logical.Response{
+		Data: data,
+	}
+	if override {
+		resp.AddWarning(helpers.Warning(helpers.WarningPolicyOverridden,
+			"signed through sign/digest/override, the pre-image policy of config/features was not applied"))
+	}
+	return resp, nil
+}
+
+// digestIntent returns the intent of the requests to pattern, a digest of what the key signs on any
+// chain: it is not decoded
+func digestIntent(pattern string) signIntentFunc {
+	return func(d *framework.FieldData) (*signIntent, error) {
+		intent, err := intentOf(pattern, d, []string{d.Get("uuid").(string)}, d.Get("derivationPath").(string),
+			approval.Summary{})
+		if err != nil {
+			return nil, err
+		}
+		intent.anyCoinType = true
+		return intent, nil
+	}
+}
@@ -0,0 +1,393 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/preimage"
+)
+
+const signDigestTestDigest = "0x9c22ff5f21f0b81b113e63f7db6da94fedef11b2119b4088b89664fb9a3cb658"
+
+// Helper function to create a proper framework.FieldData for sign/digest endpoint
+func createSignDigestFieldData(data map[string]interface{}) *framework.FieldData {
+	schema := map[string]*framework.FieldSchema{
+		"uuid":           {Type: framework.TypeString},
+		"derivationPath": {Type: framework.TypeString},
+		"digest":         {Type: framework.TypeString},
+		"message":        {Type: framework.TypeString},
+		"hash":           {Type: framework.TypeString},
+		"curve":          {Type: framework.TypeString},
+	}
+
+	return &framework.FieldData{
+		Raw:    data,
+		Schema: schema,
+	}
+}
+
+func createFeaturesStorageEntry(features helpers.Features) *logical.StorageEntry {
+	value, _ := json.Marshal(features)
+	return &logical.StorageEntry{
+		Key:   config.FeaturesStorageKey,
+		Value: value,
+	}
+}
+
+// signDigestTestLegacyTx returns the hex EIP-155 signing pre-image of a legacy transaction
+func signDigestTestLegacyTx(t *testing.T) string {
+	t.Helper()
+	message, err := rlp.EncodeToBytes([]interface{}{
+		uint64(0), big.NewInt(1), uint64(21000), common.Address{}, big.NewInt(0), []byte{},
+		big.NewInt(1), uint(0), uint(0),
+	})
+	require.NoError(t, err)
+	return hex.EncodeToString(message)
+}
+
+func TestBackend_PathSignDigest(t *testing.T) {
+	ctx := context.Background()
+	digest, err := hex.DecodeString(signDigestTestDigest[2:])
+	require.NoError(t, err)
+	legacyTx := signDigestTestLegacyTx(t)
+	policy := &helpers.Features{SignDigestEnabled: true, DigestPreimageRequired: true}
+
+	tests := []struct {
+		name         string
+		features     *helpers.Features
+		fieldData    map[string]interface{}
+		override     bool
+		wantData     map[string]interface{}
+		wantErr      bool
+		wantErrCode  int
+		wantErrMsg   string
+		verifyResult func(t *testing.T, signature, publicKey []byte)
+	}{
+		{
+			name:     "secp256k1 digest",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveSecp256k1,
+			},
+			verifyResult: func(t *testing.T, signature, publicKey []byte) {
+				require.Len(t, signature, 65)
+				recovered, err := crypto.SigToPub(digest, signature)
+				require.NoError(t, err)
+				assert.Equal(t, publicKey, crypto.CompressPubkey(recovered))
+				assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", crypto.PubkeyToAddress(*recovered).Hex())
+			},
+		},
+		{
+			name:     "ed25519 digest",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": "m/44'/501'/0'/0'",
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveEd25519,
+			},
+			verifyResult: func(t *testing.T, signature, publicKey []byte) {
+				assert.True(t, ed25519.Verify(publicKey, digest, signature))
+			},
+		},
+		{
+			name:     "disabled by default",
+			features: nil,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusForbidden,
+			wantErrMsg:  helpers.ErrFeatureDisabled.Error(),
+		},
+		{
+			name:     "explicitly disabled",
+			features: &helpers.Features{SignDigestEnabled: false},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusForbidden,
+			wantErrMsg:  helpers.ErrFeatureDisabled.Error(),
+		},
+		{
+			name:     "short digest",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         "0xdeadbeef",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusUnprocessableEntity,
+			wantErrMsg:  lib.ErrInvalidDigestLength.Error(),
+		},
+		{
+			name:     "unsupported curve",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          "p256",
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusUnprocessableEntity,
+			wantErrMsg:  lib.ErrUnsupportedCurve.Error(),
+		},
+		{
+			name:     "ed25519 rejects non hardened path",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveEd25519,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusUnprocessableEntity,
+			wantErrMsg:  lib.ErrNonHardenedComponent.Error(),
+		},
+		{
+			name:     "message hashed with keccak256",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        "0x" + hex.EncodeToString([]byte("hello")),
+				"hash":           "keccak256",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantData: map[string]interface{}{
+				"digest": hex.EncodeToString(crypto.Keccak256([]byte("hello"))),
+			},
+			verifyResult: func(t *testing.T, signature, publicKey []byte) {
+				recovered, err := crypto.SigToPub(crypto.Keccak256([]byte("hello")), signature)
+				require.NoError(t, err)
+				assert.Equal(t, publicKey, crypto.CompressPubkey(recovered))
+			},
+		},
+		{
+			name:     "digest and message",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"message":        "68656c6c6f",
+				"hash":           "sha256",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusUnprocessableEntity,
+			wantErrMsg:  helpers.ErrDigestOrMessage.Error(),
+		},
+		{
+			name:     "message without hash",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        "68656c6c6f",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusUnprocessableEntity,
+			wantErrMsg:  "hash must be keccak256, sha256 or sha256d",
+		},
+		{
+			name:     "recognized pre-image signed without the policy",
+			features: &helpers.Features{SignDigestEnabled: true},
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        legacyTx,
+				"hash":           "keccak256",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantData: map[string]interface{}{
+				"preimage": preimage.KindEVMLegacyTransaction,
+			},
+			verifyResult: func(t *testing.T, signature, _ []byte) {
+				require.Len(t, signature, 65)
+			},
+		},
+		{
+			name:     "policy refuses recognized pre-image",
+			features: policy,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        legacyTx,
+				"hash":           "keccak256",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusForbidden,
+			wantErrMsg:  preimage.ErrRecognized.Error() + ": " + preimage.KindEVMLegacyTransaction,
+		},
+		{
+			name:     "policy refuses raw digest",
+			features: policy,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantErr:     true,
+			wantErrCode: http.StatusForbidden,
+			wantErrMsg:  helpers.ErrPreimageRequired.Error(),
+		},
+		{
+			name:     "policy signs unrecognized message",
+			features: policy,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        "68656c6c6f",
+				"hash":           "sha256d",
+				"curve":          lib.CurveSecp256k1,
+			},
+			verifyResult: func(t *testing.T, signature, _ []byte) {
+				require.Len(t, signature, 65)
+			},
+		},
+		{
+			name:     "override signs recognized pre-image",
+			features: policy,
+			override: true,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"message":        legacyTx,
+				"hash":           "keccak256",
+				"curve":          lib.CurveSecp256k1,
+			},
+			wantData: map[string]interface{}{
+				"preimage": preimage.KindEVMLegacyTransaction,
+			},
+			verifyResult: func(t *testing.T, signature, _ []byte) {
+				require.Len(t, signature, 65)
+			},
+		},
+		{
+			name:     "override signs raw digest",
+			features: policy,
+			override: true,
+			fieldData: map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"digest":         signDigestTestDigest,
+				"curve":          lib.CurveSecp256k1,
+			},
+			verifyResult: func(t *testing.T, signature, _ []byte) {
+				require.Len(t, signature, 65)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorageSign)
+			backend := createSignTestBackend(t)
+
+			if tt.features != nil {
+				mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(createFeaturesStorageEntry(*tt.features), nil)
+			} else {
+				mockStorage.On("Get", ctx, config.FeaturesStorageKey).Return(nil, nil)
+			}
+			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil).Maybe()
+			userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, "")
+			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil).Maybe()
+
+			req := &logical.Request{
+				Storage: mockStorage,
+				Data:    tt.fieldData,
+			}
+
+			sign := backend.pathSignDigest
+			if tt.override {
+				sign = backend.pathSignDigestOverride
+			}
+			got, err := sign(ctx, req, createSignDigestFieldData(tt.fieldData))
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Nil(t, got)
+				var codedErr logical.HTTPCodedError
+				require.ErrorAs(t, err, &codedErr)
+				assert.Equal(t, tt.wantErrCode, codedErr.Code())
+				assert.Contains(t, err.Error(), tt.wantErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			signature, err := hex.DecodeString(got.Data["signature"].(string))
+			require.NoError(t, err)
+			publicKey, err := hex.DecodeString(got.Data["publicKey"].(string))
+			require.NoError(t, err)
+			tt.verifyResult(t, signature, publicKey)
+			for k, v := range tt.wantData {
+				assert.Equal(t, v, got.Data[k], k)
+			}
+
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBackend_PathSignDigest_NotesCoinTypeViolation(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{60})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+	require.NoError(t, s.Put(ctx, createFeaturesStorageEntry(helpers.Features{SignDigestEnabled: true})))
+
+	_, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation, Path: "sign/digest", Storage: s,
+		Data: map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": signTestDerivationPath, "digest": signDigestTestDigest,
+			"curve": lib.CurveSecp256k1,
+		},
+	})
+	require.ErrorIs(t, err, helpers.ErrCoinTypeNotAllowed)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation, Path: "user/" + signTestUUID + "/violations", Storage: s,
+	})
+	require.NoError(t, err)
+	notes := resp.Data["notes"].([]map[string]interface{})
+	require.Len(t, notes, 1)
+	assert.Equal(t, "coinType", notes[0]["kind"])
+	assert.Equal(t, "sign/digest", notes[0]["path"])
+}
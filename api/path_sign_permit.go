@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// pathSignPermit corresponds to UPDATE sign/permit. It builds the EIP-712 typed data of an
+// EIP-2612 or Permit2 permit and returns the signature of the owner key with its components.
+func (b *Backend) pathSignPermit(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_permit"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	coinType := uint16(d.Get("coinType").(int))
+
+	permit, err := permitFromFields(d)
+	if err != nil {
+		backendLogger.Error("permit", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	domainSeparator, err := permit.DomainSeparator()
+	if err != nil {
+		backendLogger.Error("permit domain", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !evm.NewEthereumAdapter(b.logger).CanDo(coinType) {
+		backendLogger.Error("not an evm coin", "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+
+	// validate data provided
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	signature, owner, hash, err := evm.SignPermit(seed, derivationPath, permit)
+	if err != nil {
+		backendLogger.Error("sign permit", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("permit signed", "uuid", uuid, "path", derivationPath, "type", permit.Kind,
+		"chainId", permit.ChainID, "token", permit.Token.Hex(), "spender", permit.Spender.Hex(),
+		"amount", permit.Amount, "nonce", permit.Nonce, "deadline", permit.Deadline, "hash", hexutil.Encode(hash))
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature":       hexutil.Encode(signature),
+			"v":               int(signature[crypto.RecoveryIDOffset]),
+			"r":               hexutil.Encode(signature[:32]),
+			"s":               hexutil.Encode(signature[32:64]),
+			"owner":           owner.Hex(),
+			"hash":            hexutil.Encode(hash),
+			"domainSeparator": hexutil.Encode(domainSeparator),
+		},
+	}, nil
+}
+
+// permitFromFields reads the permit of the request; amounts are decimal or 0x hex strings
+func permitFromFields(d *framework.FieldData) (*evm.Permit, error) {
+	permit := &evm.Permit{
+		Kind:         d.Get("type").(string),
+		TokenName:    d.Get("tokenName").(string),
+		TokenVersion: d.Get("tokenVersion").(string),
+	}
+
+	for _, f := range []struct {
+		name    string
+		address *common.Address
+	}{
+		{"token", &permit.Token},
+		{"spender", &permit.Spender},
+		{"permit2", &permit.Permit2},
+	} {
+		value := d.Get(f.name).(string)
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("%w: %s", helpers.ErrInvalidPermitField, f.name)
+		}
+		*f.address = common.HexToAddress(value)
+	}
+
+	for _, f := range []struct {
+		name   string
+		amount **big.Int
+	}{
+		{"chainId", &permit.ChainID},
+		{"amount", &permit.Amount},
+		{"nonce", &permit.Nonce},
+		{"deadline", &permit.Deadline},
+	} {
+		// none of them has a default, an empty value would be read as 0
+		raw := strings.TrimSpace(d.Get(f.name).(string))
+		value, ok := math.ParseBig256(raw)
+		if !ok || raw == "" {
+			return nil, fmt.Errorf("%w: %s", helpers.ErrInvalidPermitField, f.name)
+		}
+		*f.amount = value
+	}
+
+	permit.Expiration = permit.Deadline
+	if raw := strings.TrimSpace(d.Get("expiration").(string)); raw != "" {
+		value, ok := math.ParseBig256(raw)
+		if !ok {
+			return nil, fmt.Errorf("%w: expiration", helpers.ErrInvalidPermitField)
+		}
+		permit.Expiration = value
+	}
+	return permit, nil
+}
+
+// permitIntent is the intent of a sign/permit request, the allowance of the token to the spender
+func permitIntent(d *framework.FieldData) (*signIntent, error) {
+	coinType, ok := fieldCoinType(d)
+	permit, err := permitFromFields(d)
+	if !ok || err != nil {
+		return nil, nil
+	}
+	return intentOf("sign/permit", d, []string{d.Get("uuid").(string)}, d.Get("derivationPath").(string),
+		approval.Summary{CoinType: coinType, Transfers: []approval.Transfer{{
+			To: permit.Spender.Hex(), Amount: permit.Amount.String(), Token: permit.Token.Hex(),
+		}}})
+}
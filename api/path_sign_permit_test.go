@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// Helper function to create a proper framework.FieldData for sign/permit endpoint
+func createSignPermitFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":           {Type: framework.TypeString},
+			"derivationPath": {Type: framework.TypeString, Default: ""},
+			"coinType":       {Type: framework.TypeInt, Default: 60},
+			"type":           {Type: framework.TypeString, Default: evm.PermitEIP2612},
+			"chainId":        {Type: framework.TypeString},
+			"token":          {Type: framework.TypeString},
+			"spender":        {Type: framework.TypeString},
+			"amount":         {Type: framework.TypeString},
+			"nonce":          {Type: framework.TypeString},
+			"deadline":       {Type: framework.TypeString},
+			"expiration":     {Type: framework.TypeString, Default: ""},
+			"tokenName":      {Type: framework.TypeString, Default: ""},
+			"tokenVersion":   {Type: framework.TypeString, Default: "1"},
+			"permit2":        {Type: framework.TypeString, Default: evm.Permit2Address},
+		},
+	}
+}
+
+func TestBackend_PathSignPermit(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	permitData := func(overrides map[string]interface{}) map[string]interface{} {
+		data := map[string]interface{}{
+			"uuid":           signTestUUID,
+			"derivationPath": "m/44'/60'/0'/0/0",
+			"chainId":        "1",
+			"token":          "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+			"spender":        "0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD",
+			"amount":         "1000000",
+			"nonce":          "0",
+			"deadline":       "1767225600",
+			"tokenName":      "USD Coin",
+			"tokenVersion":   "2",
+		}
+		for k, v := range overrides {
+			data[k] = v
+		}
+		return data
+	}
+	sign := func(storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return createSignTestBackend(t).pathSignPermit(ctx, &logical.Request{Storage: storage, Data: data},
+			createSignPermitFieldData(data))
+	}
+
+	t.Run("eip2612 permit", func(t *testing.T) {
+		got, err := sign(s, permitData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", got.Data["owner"])
+
+		permit := &evm.Permit{
+			Kind: evm.PermitEIP2612, ChainID: big.NewInt(1),
+			Token:   common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+			Spender: common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+			Amount:  big.NewInt(1000000), Nonce: big.NewInt(0), Deadline: big.NewInt(1767225600),
+			TokenName: "USD Coin", TokenVersion: "2",
+		}
+		hash, err := permit.Hash(common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"))
+		require.NoError(t, err)
+		assert.Equal(t, hexutil.Encode(hash), got.Data["hash"])
+
+		signature := got.Data["signature"].(string)
+		assert.Len(t, signature, 2+2*65)
+		assert.Equal(t, signature[:66], got.Data["r"])
+		assert.Equal(t, "0x"+signature[66:130], got.Data["s"])
+		assert.Contains(t, []int{27, 28}, got.Data["v"])
+	})
+
+	t.Run("permit2 allowance", func(t *testing.T) {
+		got, err := sign(s, permitData(map[string]interface{}{"type": evm.PermitPermit2}))
+		require.NoError(t, err)
+		assert.Equal(t, "0x866a5aba21966af95d6c7ab78eb2b2fc913915c28be3b9aa07cc04ff903e3f28", got.Data["domainSeparator"])
+
+		// the expiration defaults to the deadline
+		withExpiration, err := sign(s, permitData(map[string]interface{}{
+			"type": evm.PermitPermit2, "expiration": "1767225600",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, got.Data["hash"], withExpiration.Data["hash"])
+	})
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantErr   string
+	}{
+		{"missing nonce", map[string]interface{}{"nonce": ""}, helpers.ErrInvalidPermitField.Error() + ": nonce"},
+		{"missing deadline", map[string]interface{}{"deadline": ""}, helpers.ErrInvalidPermitField.Error() + ": deadline"},
+		{"invalid spender", map[string]interface{}{"spender": "0x1234"}, helpers.ErrInvalidPermitField.Error() + ": spender"},
+		{"missing token name", map[string]interface{}{"tokenName": ""}, evm.ErrMissingPermitTokenName.Error()},
+		{"unsupported type", map[string]interface{}{"type": "dai"}, evm.ErrUnsupportedPermitKind.Error()},
+		{"permit2 nonce", map[string]interface{}{"type": evm.PermitPermit2, "nonce": "0x1000000000000"},
+			evm.ErrInvalidPermitAmount.Error()},
+		{"not an evm coin", map[string]interface{}{"coinType": 0}, helpers.ErrUnsupportedCoinType.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sign(s, permitData(tt.overrides))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("coin restricted user", func(t *testing.T) {
+		restricted := &logical.InmemStorage{}
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{0})
+		require.NoError(t, err)
+		require.NoError(t, restricted.Put(ctx, createUserV2StorageEntry(t, user)))
+
+		_, err = sign(restricted, permitData(nil))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// psbtSigner -- a user signing the inputs of a PSBT, with the descriptors of its wallet
+type psbtSigner struct {
+	uuid        string
+	fingerprint string
+	seed        []byte
+	descriptors []*bitcoin.Descriptor
+}
+
+// pathSignPSBT signs the inputs of a Bitcoin PSBT spending outputs of the wallets of the users,
+// e.g., a consolidation sweep of several users. The keys are selected from the BIP-32 derivations
+// of the inputs and from the given output descriptors, each handed to the user of its fingerprint.
+// Every user is authorized on its own, and the response attributes each signed input to its user.
+func (b *Backend) pathSignPSBT(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_psbt"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuids := requestUUIDs(d)
+	isDev := d.Get("isDev").(bool)
+	if len(uuids) == 0 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidUUID.Error())
+	}
+
+	packet, err := bitcoin.DecodePSBT(d.Get("psbt").(string))
+	if err != nil {
+		backendLogger.Error("decode psbt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	descriptorStrings := d.Get("descriptors").([]string)
+	descriptors := make([]*bitcoin.Descriptor, 0, len(descriptorStrings))
+	for _, s := range descriptorStrings {
+		descriptor, err := bitcoin.ParseDescriptor(s)
+		if err != nil {
+			backendLogger.Error("parse descriptor", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+
+	coinType := uint16(slip44.Bitcoin)
+	if isDev {
+		coinType = slip44.TestNet
+	}
+	// every user is checked before any input is signed
+	signers := make([]*psbtSigner, 0, len(uuids))
+	for _, uuid := range uuids {
+		signer, err := b.psbtSigner(ctx, req, uuid, coinType)
+		if err != nil {
+			backendLogger.Error("authorize psbt signer", "error", err, "uuid", uuid)
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+	if err := assignDescriptors(signers, descriptors); err != nil {
+		backendLogger.Error("assign descriptors", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	policy, err := helpers.GetDerivationPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("read derivation policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	for _, signer := range signers {
+		if err := signer.checkPaths(packet, coinType, policy); err != nil {
+			backendLogger.Error("check signing paths", "error", err, "uuid", signer.uuid)
+			return nil, err
+		}
+	}
+	// the outputs paying a wallet of the signers back are its change, not recipients
+	if err := checkPSBTRecipients(ctx, req.Storage, coinType, d.Get("psbt").(string), isDev, func() ([]int, error) {
+		var own []int
+		for _, signer := range signers {
+			outputs, err := bitcoin.WalletOutputs(signer.seed, packet, signer.descriptors)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", signer.uuid, err)
+			}
+			own = append(own, outputs...)
+		}
+		return own, nil
+	}); err != nil {
+		backendLogger.Error("check address book", "error", err)
+		return nil, err
+	}
+
+	signedBy := make(map[int]string)
+	for _, signer := range signers {
+		signed, err := bitcoin.SignPSBT(signer.seed, packet, signer.descriptors)
+		if err != nil {
+			backendLogger.Error("sign psbt", "error", err, "uuid", signer.uuid)
+			if len(signers) > 1 {
+				err = fmt.Errorf("%s: %w", signer.uuid, err)
+			}
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		for _, input := range signed {
+			if _, ok := signedBy[input]; !ok {
+				signedBy[input] = signer.uuid
+			}
+		}
+	}
+	encoded, err := packet.Base64()
+	if err != nil {
+		backendLogger.Error("encode psbt", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	signedInputs := slices.Sorted(maps.Keys(signedBy))
+	inputs := make([]map[string]interface{}, 0, len(signedInputs))
+	for _, input := range signedInputs {
+		inputs = append(inputs, map[string]interface{}{"index": input, "uuid": signedBy[input]})
+	}
+	backendLogger.Info("signed psbt", "uuids", uuids, "signedInputs", signedInputs)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"psbt":         encoded,
+			"signedInputs": signedInputs,
+			"inputs":       inputs,
+		},
+	}, nil
+}
+
+// psbtSigner authorizes the user of uuid to sign the PSBT inputs of coinType and returns its seed
+func (b *Backend) psbtSigner(ctx context.Context, req *logical.Request, uuid string,
+	coinType uint16) (*psbtSigner, error) {
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, fmt.Errorf("%s: %w", uuid, err))
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		return nil, helpers.CodedError(http.StatusForbidden, fmt.Errorf("%s: %w", uuid, err))
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		b.logger.Warn("entity not authorized", "uuid", uuid, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, fmt.Sprintf("%s: %s", uuid, err))
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %s", uuid, err))
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %s", uuid, err))
+	}
+	return &psbtSigner{uuid: uuid, fingerprint: fingerprint, seed: seed}, nil
+}
+
+// checkPaths checks the paths of the keys the signer selects for the inputs of packet against the
+// derivation policy, and against coinType, the coin type the signer was authorized for
+func (signer *psbtSigner) checkPaths(packet *bitcoin.Packet, coinType uint16, policy *helpers.DerivationPolicy) error {
+	paths, err := bitcoin.SigningPaths(signer.seed, packet, signer.descriptors)
+	if err != nil {
+		return logical.CodedError(http.StatusUnprocessableEntity, fmt.Sprintf("%s: %s", signer.uuid, err))
+	}
+	for _, path := range paths {
+		if err := policy.Check(path); err != nil {
+			return helpers.CodedError(http.StatusUnprocessableEntity, fmt.Errorf("%s: %w", signer.uuid, err))
+		}
+		if err := helpers.CheckCoinTypeLevel(path, coinType); err != nil {
+			return helpers.CodedError(http.StatusForbidden, fmt.Errorf("%s: %w", signer.uuid, err))
+		}
+	}
+	return nil
+}
+
+// assignDescriptors hands each descriptor to the signer of its key origin fingerprint. A
+// descriptor of none of the wallets is refused.
+func assignDescriptors(signers []*psbtSigner, descriptors []*bitcoin.Descriptor) error {
+	for _, descriptor := range descriptors {
+		fingerprint := hex.EncodeToString(descriptor.Fingerprint)
+		i := slices.IndexFunc(signers, func(signer *psbtSigner) bool { return signer.fingerprint == fingerprint })
+		if i < 0 {
+			return fmt.Errorf("%w: %s", bitcoin.ErrDescriptorFingerprint, fingerprint)
+		}
+		signers[i].descriptors = append(signers[i].descriptors, descriptor)
+	}
+	return nil
+}
+
+// signPSBTIntent is the intent of a sign/psbt request, the outputs of the PSBT; the change of the
+// wallet is told apart by the BIP-32 derivations of the outputs and the descriptors
+func signPSBTIntent(d *framework.FieldData) (*signIntent, error) {
+	intent, err := psbtIntent("sign/psbt", d, requestUUIDs(d))
+	if intent == nil || err != nil {
+		return intent, err
+	}
+	packet, err := bitcoin.DecodePSBT(d.Get("psbt").(string))
+	if err != nil {
+		return nil, nil
+	}
+	var descriptors []*bitcoin.Descriptor
+	for _, s := range d.Get("descriptors").([]string) {
+		// the handler rejects the descriptors that are invalid
+		descriptor, err := bitcoin.ParseDescriptor(s)
+		if err != nil {
+			return nil, nil
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	intent.walletOutputs = func(seed []byte) ([]int, error) {
+		return bitcoin.WalletOutputs(seed, packet, descriptors)
+	}
+	return intent, nil
+}
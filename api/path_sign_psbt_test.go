@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+)
+
+// Helper function to create a proper framework.FieldData for sign/psbt endpoint
+func createSignPSBTFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":        {Type: framework.TypeString},
+			"uuids":       {Type: framework.TypeCommaStringSlice},
+			"psbt":        {Type: framework.TypeString},
+			"descriptors": {Type: framework.TypeStringSlice},
+			"isDev":       {Type: framework.TypeBool, Default: false},
+		},
+	}
+}
+
+// newTestPSBT returns a base64 PSBT spending a P2WPKH output of the key at path
+func newTestPSBT(t *testing.T, path string) string {
+	t.Helper()
+	return newTestScriptPSBT(t, newTestP2WPKHScript(t, signTestValidMnemonic, path))
+}
+
+// newTestP2WPKHScript returns the P2WPKH output script of the key of mnemonic at path
+func newTestP2WPKHScript(t *testing.T, mnemonic, path string) []byte {
+	t.Helper()
+	seed, err := lib.SeedFromMnemonic(mnemonic, "")
+	require.NoError(t, err)
+	privateKey, err := lib.DerivePrivateKey(seed, path, false)
+	require.NoError(t, err)
+	script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(privateKey.PubKey().SerializeCompressed())).Script()
+	require.NoError(t, err)
+	return script
+}
+
+// newTestScriptPSBT returns a base64 PSBT with one input spending a segwit output paying to each script
+func newTestScriptPSBT(t *testing.T, scripts ...[]byte) string {
+	t.Helper()
+	tx := wire.NewMsgTx(2)
+	for i := range scripts {
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, uint32(i)), nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(40_000, scripts[0]))
+	return newTestTxPSBT(t, tx, scripts...)
+}
+
+// newTestTxPSBT returns tx as a base64 PSBT whose inputs spend segwit outputs of 50_000 paying to
+// each script
+func newTestTxPSBT(t *testing.T, tx *wire.MsgTx, scripts ...[]byte) string {
+	t.Helper()
+	var unsigned bytes.Buffer
+	require.NoError(t, tx.SerializeNoWitness(&unsigned))
+
+	var psbt bytes.Buffer
+	psbt.Write([]byte("psbt\xff"))
+	entry := func(key, value []byte) {
+		require.NoError(t, wire.WriteVarBytes(&psbt, 0, key))
+		require.NoError(t, wire.WriteVarBytes(&psbt, 0, value))
+	}
+	// global map with the unsigned transaction, input maps with the witness utxos, empty output maps
+	entry([]byte{0x00}, unsigned.Bytes())
+	psbt.WriteByte(0x00)
+	for _, script := range scripts {
+		var utxo bytes.Buffer
+		require.NoError(t, binary.Write(&utxo, binary.LittleEndian, int64(50_000)))
+		require.NoError(t, wire.WriteVarBytes(&utxo, 0, script))
+		entry([]byte{0x01}, utxo.Bytes())
+		psbt.WriteByte(0x00)
+	}
+	for range tx.TxOut {
+		psbt.WriteByte(0x00)
+	}
+	return base64.StdEncoding.EncodeToString(psbt.Bytes())
+}
+
+func TestBackend_PathSignPSBT(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+
+	// the receive descriptor returned by the xpub path
+	xpubData := map[string]interface{}{"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0}
+	xpub, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: xpubData},
+		createXpubFieldData(xpubData))
+	require.NoError(t, err)
+	receive := xpub.Data["descriptors"].(lib.Descriptors).Receive
+
+	t.Run("keys selected from the descriptor", func(t *testing.T) {
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": []string{receive},
+		}
+		got, err := createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, got.Data["signedInputs"])
+
+		signed, err := bitcoin.DecodePSBT(got.Data["psbt"].(string))
+		require.NoError(t, err)
+		assert.NotNil(t, signed.Tx)
+		assert.NotEqual(t, data["psbt"], got.Data["psbt"])
+	})
+
+	t.Run("no input of the wallet", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7")}
+		_, err := createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), bitcoin.ErrNothingToSign.Error())
+	})
+
+	t.Run("invalid descriptor", func(t *testing.T) {
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": []string{receive + "x"},
+		}
+		_, err := createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), bitcoin.ErrDescriptorChecksum.Error())
+	})
+
+	t.Run("coin restricted user", func(t *testing.T) {
+		restricted := &logical.InmemStorage{}
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{60})
+		require.NoError(t, err)
+		require.NoError(t, restricted.Put(ctx, createUserV2StorageEntry(t, user)))
+
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": []string{receive},
+		}
+		_, err = createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: restricted, Data: data},
+			createSignPSBTFieldData(data))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+	t.Run("inputs of several users", func(t *testing.T) {
+		const sweepUUID, sweepMnemonic = "sweep-user", "legal winner thank year wave sausage worth useful legal winner thank yellow"
+		sweep, err := helpers.NewUser(sweepUUID, "sweep-user", sweepMnemonic, "", nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, sweep)))
+		sweepData := map[string]interface{}{"uuid": sweepUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0}
+		sweepXpub, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: sweepData},
+			createXpubFieldData(sweepData))
+		require.NoError(t, err)
+
+		psbt := newTestScriptPSBT(t, newTestP2WPKHScript(t, sweepMnemonic, "m/84'/0'/0'/0/3"),
+			newTestP2WPKHScript(t, signTestValidMnemonic, "m/84'/0'/0'/0/7"))
+		data := map[string]interface{}{
+			"uuids": []string{signTestUUID, sweepUUID}, "psbt": psbt,
+			"descriptors": []string{receive, sweepXpub.Data["descriptors"].(lib.Descriptors).Receive},
+		}
+		got, err := createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, got.Data["signedInputs"])
+		assert.Equal(t, []map[string]interface{}{
+			{"index": 0, "uuid": sweepUUID},
+			{"index": 1, "uuid": signTestUUID},
+		}, got.Data["inputs"])
+
+		// a descriptor of no user of the request
+		data["uuids"] = []string{signTestUUID}
+		_, err = createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.ErrorContains(t, err, bitcoin.ErrDescriptorFingerprint.Error())
+
+		// every user must sign an input
+		data = map[string]interface{}{"uuids": []string{signTestUUID, sweepUUID}, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"),
+			"descriptors": []string{receive}}
+		_, err = createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: s, Data: data},
+			createSignPSBTFieldData(data))
+		require.ErrorContains(t, err, sweepUUID+": "+bitcoin.ErrNothingToSign.Error())
+	})
+
+	t.Run("users are authorized individually", func(t *testing.T) {
+		restricted := &logical.InmemStorage{}
+		for uuid, coinTypes := range map[string][]uint16{signTestUUID: nil, "restricted-user": {60}} {
+			user, err := helpers.NewUser(uuid, uuid, signTestValidMnemonic, "", coinTypes)
+			require.NoError(t, err)
+			require.NoError(t, restricted.Put(ctx, createUserV2StorageEntry(t, user)))
+		}
+
+		data := map[string]interface{}{
+			"uuids": []string{signTestUUID, "restricted-user"}, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"),
+		}
+		_, err := createSignTestBackend(t).pathSignPSBT(ctx, &logical.Request{Storage: restricted, Data: data},
+			createSignPSBTFieldData(data))
+		require.ErrorContains(t, err, "restricted-user")
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+}
+
+func TestBackend_HandleRequest_SignPSBT(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	xpub, err := request(logical.UpdateOperation, "xpub", map[string]interface{}{
+		"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": 0,
+	})
+	require.NoError(t, err)
+	receive := xpub.Data["descriptors"].(lib.Descriptors).Receive
+	notes := func() []map[string]interface{} {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, "user/"+signTestUUID+"/violations", nil)
+		require.NoError(t, err)
+		return resp.Data["notes"].([]map[string]interface{})
+	}
+
+	t.Run("the paths of the inputs are checked", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/derivation", map[string]interface{}{"maxDepth": 4})
+		require.NoError(t, err)
+		_, err = request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": []string{receive},
+		})
+		require.ErrorIs(t, err, helpers.ErrDerivationTooDeep)
+		assert.Equal(t, http.StatusUnprocessableEntity, err.(logical.HTTPCodedError).Code())
+		_, err = request(logical.DeleteOperation, "config/derivation", nil)
+		require.NoError(t, err)
+
+		// the testnet keys are those of coin type 1
+		_, err = request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": newTestPSBT(t, "m/84'/0'/0'/0/7"), "descriptors": []string{receive},
+			"isDev": true,
+		})
+		require.ErrorIs(t, err, helpers.ErrCoinTypeNotAllowed)
+		assert.Equal(t, http.StatusForbidden, err.(logical.HTTPCodedError).Code())
+
+		got := notes()
+		require.Len(t, got, 2)
+		assert.Equal(t, "derivation", got[0]["kind"])
+		assert.Equal(t, "coinType", got[1]["kind"])
+		assert.Equal(t, "sign/psbt", got[1]["path"])
+	})
+
+	t.Run("the change is not charged to the budget", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "budget/top-up", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 0, "amount": "30000",
+		})
+		require.NoError(t, err)
+
+		// 25_000 to another wallet and 20_000 of change to a receive address of the wallet
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(25_000, newTestP2WPKHScript(t,
+			"legal winner thank year wave sausage worth useful legal winner thank yellow", "m/84'/0'/0'/0/3")))
+		tx.AddTxOut(wire.NewTxOut(20_000, newTestP2WPKHScript(t, signTestValidMnemonic, "m/84'/0'/0'/0/8")))
+		psbt := newTestTxPSBT(t, tx, newTestP2WPKHScript(t, signTestValidMnemonic, "m/84'/0'/0'/0/7"))
+
+		resp, err := request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": psbt, "descriptors": []string{receive},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, resp.Data["signedInputs"])
+		assert.Equal(t, "5000", resp.Data["budgetRemaining"])
+
+		// without the descriptor the change is not told apart, and every output is charged
+		_, err = request(logical.UpdateOperation, "sign/psbt", map[string]interface{}{
+			"uuid": signTestUUID, "psbt": psbt, "descriptors": []string{},
+		})
+		require.ErrorIs(t, err, helpers.ErrBudgetExceeded)
+		resp, err = request(logical.ReadOperation, "budget/"+signTestUUID+"/0", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "5000", resp.Data["remaining"])
+	})
+}
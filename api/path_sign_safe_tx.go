@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// pathSignSafeTx corresponds to UPDATE sign/safe-tx. It computes the EIP-712 hash of a Safe
+// transaction and returns the signature of the vault held owner key, for the ops tooling
+// collecting owner approvals.
+func (b *Backend) pathSignSafeTx(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_safe_tx"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	coinType := uint16(d.Get("coinType").(int))
+
+	tx, err := safeTxFromFields(d)
+	if err != nil {
+		backendLogger.Error("safe transaction", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !evm.NewEthereumAdapter(b.logger).CanDo(coinType) {
+		backendLogger.Error("not an evm coin", "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+
+	// validate data provided
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	signature, owner, hash, err := evm.SignSafeTx(seed, derivationPath, tx)
+	if err != nil {
+		backendLogger.Error("sign safe transaction", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	logArgs := []any{"uuid", uuid, "path", derivationPath, "safe", tx.Safe.Hex(), "chainId", tx.ChainID,
+		"to", tx.To.Hex(), "nonce", tx.Nonce, "safeTxHash", hexutil.Encode(hash)}
+	var warnings []string
+	if tx.Operation == evm.SafeOperationDelegateCall {
+		// a delegatecall runs the target code with the Safe storage and funds
+		backendLogger.Warn("safe delegatecall transaction signed", logArgs...)
+		warnings = append(warnings, helpers.Warning(helpers.WarningPolicyOverridden,
+			"delegatecall to %s signed, it runs with the storage and funds of the Safe", tx.To.Hex()))
+	} else {
+		backendLogger.Info("safe transaction signed", logArgs...)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature":  hexutil.Encode(signature),
+			"owner":      owner.Hex(),
+			"safeTxHash": hexutil.Encode(hash),
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+// safeTxFromFields reads the Safe transaction of the request; amounts are decimal or 0x hex strings
+func safeTxFromFields(d *framework.FieldData) (*evm.SafeTx, error) {
+	operation := d.Get("operation").(int)
+	if operation != int(evm.SafeOperationCall) && operation != int(evm.SafeOperationDelegateCall) {
+		return nil, evm.ErrInvalidSafeOperation
+	}
+	tx := &evm.SafeTx{
+		Operation: uint8(operation),
+		Version:   d.Get("safeVersion").(string),
+	}
+
+	for _, f := range []struct {
+		name     string
+		address  *common.Address
+		optional bool
+	}{
+		{"safe", &tx.Safe, false},
+		{"to", &tx.To, false},
+		{"gasToken", &tx.GasToken, true},
+		{"refundReceiver", &tx.RefundReceiver, true},
+	} {
+		value := d.Get(f.name).(string)
+		if value == "" && f.optional {
+			continue
+		}
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("%w: %s", helpers.ErrInvalidSafeField, f.name)
+		}
+		*f.address = common.HexToAddress(value)
+	}
+
+	for _, f := range []struct {
+		name   string
+		amount **big.Int
+	}{
+		{"chainId", &tx.ChainID},
+		{"value", &tx.Value},
+		{"safeTxGas", &tx.SafeTxGas},
+		{"baseGas", &tx.BaseGas},
+		{"gasPrice", &tx.GasPrice},
+		{"nonce", &tx.Nonce},
+	} {
+		raw := strings.TrimSpace(d.Get(f.name).(string))
+		value, ok := math.ParseBig256(raw)
+		// the nonce has no default, approving a defaulted nonce could sign an unintended transaction
+		if !ok || (raw == "" && f.name == "nonce") {
+			return nil, fmt.Errorf("%w: %s", helpers.ErrInvalidSafeField, f.name)
+		}
+		*f.amount = value
+	}
+
+	data, err := hexutil.Decode(withHexPrefix(d.Get("data").(string)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: data: %w", helpers.ErrInvalidSafeField, err)
+	}
+	tx.Data = data
+	return tx, nil
+}
+
+// withHexPrefix adds the 0x prefix hexutil expects
+func withHexPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return s
+	}
+	return "0x" + s
+}
+
+// safeTxIntent is the intent of a sign/safe-tx request, the call of the Safe; a delegatecall runs
+// code deciding what it moves
+func safeTxIntent(d *framework.FieldData) (*signIntent, error) {
+	coinType, ok := fieldCoinType(d)
+	tx, err := safeTxFromFields(d)
+	if !ok || err != nil {
+		return nil, nil
+	}
+	summary := approval.Summary{CoinType: coinType}
+	if tx.Operation == evm.SafeOperationCall {
+		summary = approval.SummarizeCall(coinType, tx.To.Hex(), tx.Value, tx.Data)
+	}
+	return intentOf("sign/safe-tx", d, []string{d.Get("uuid").(string)}, d.Get("derivationPath").(string), summary)
+}
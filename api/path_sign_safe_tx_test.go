@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// Helper function to create a proper framework.FieldData for sign/safe-tx endpoint
+func createSignSafeTxFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":           {Type: framework.TypeString},
+			"derivationPath": {Type: framework.TypeString, Default: ""},
+			"coinType":       {Type: framework.TypeInt, Default: 60},
+			"chainId":        {Type: framework.TypeString},
+			"safe":           {Type: framework.TypeString},
+			"to":             {Type: framework.TypeString},
+			"value":          {Type: framework.TypeString, Default: "0"},
+			"data":           {Type: framework.TypeString, Default: ""},
+			"operation":      {Type: framework.TypeInt, Default: 0},
+			"safeTxGas":      {Type: framework.TypeString, Default: "0"},
+			"baseGas":        {Type: framework.TypeString, Default: "0"},
+			"gasPrice":       {Type: framework.TypeString, Default: "0"},
+			"gasToken":       {Type: framework.TypeString, Default: ""},
+			"refundReceiver": {Type: framework.TypeString, Default: ""},
+			"nonce":          {Type: framework.TypeString},
+			"safeVersion":    {Type: framework.TypeString, Default: evm.DefaultSafeVersion},
+		},
+	}
+}
+
+func TestBackend_PathSignSafeTx(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	safeTxData := func(overrides map[string]interface{}) map[string]interface{} {
+		data := map[string]interface{}{
+			"uuid":           signTestUUID,
+			"derivationPath": "m/44'/60'/0'/0/0",
+			"chainId":        "1",
+			"safe":           "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326",
+			"to":             "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+			"value":          "1000000000000000000",
+			"nonce":          "7",
+		}
+		for k, v := range overrides {
+			data[k] = v
+		}
+		return data
+	}
+	sign := func(storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return createSignTestBackend(t).pathSignSafeTx(ctx, &logical.Request{Storage: storage, Data: data},
+			createSignSafeTxFieldData(data))
+	}
+
+	t.Run("owner signature", func(t *testing.T) {
+		got, err := sign(s, safeTxData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", got.Data["owner"])
+
+		tx := &evm.SafeTx{
+			ChainID: big.NewInt(1), Safe: common.HexToAddress("0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326"),
+			To: common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"), Value: big.NewInt(1e18),
+			SafeTxGas: big.NewInt(0), BaseGas: big.NewInt(0), GasPrice: big.NewInt(0), Nonce: big.NewInt(7),
+		}
+		hash, err := tx.Hash()
+		require.NoError(t, err)
+		assert.Equal(t, hexutil.Encode(hash), got.Data["safeTxHash"])
+		assert.Len(t, got.Data["signature"], 2+2*65)
+	})
+
+	t.Run("hex amounts and call data", func(t *testing.T) {
+		got, err := sign(s, safeTxData(map[string]interface{}{"value": "0xde0b6b3a7640000", "data": "a9059cbb"}))
+		require.NoError(t, err)
+		withoutData, err := sign(s, safeTxData(nil))
+		require.NoError(t, err)
+		assert.NotEqual(t, withoutData.Data["safeTxHash"], got.Data["safeTxHash"])
+	})
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantErr   string
+	}{
+		{"missing nonce", map[string]interface{}{"nonce": ""}, helpers.ErrInvalidSafeField.Error() + ": nonce"},
+		{"invalid safe", map[string]interface{}{"safe": "0x1234"}, helpers.ErrInvalidSafeField.Error() + ": safe"},
+		{"invalid data", map[string]interface{}{"data": "0xzz"}, helpers.ErrInvalidSafeField.Error() + ": data"},
+		{"invalid operation", map[string]interface{}{"operation": 2}, evm.ErrInvalidSafeOperation.Error()},
+		{"missing chainId", map[string]interface{}{"chainId": ""}, evm.ErrInvalidSafeChainID.Error()},
+		{"not an evm coin", map[string]interface{}{"coinType": 0}, helpers.ErrUnsupportedCoinType.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sign(s, safeTxData(tt.overrides))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("coin restricted user", func(t *testing.T) {
+		restricted := &logical.InmemStorage{}
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{0})
+		require.NoError(t, err)
+		require.NoError(t, restricted.Put(ctx, createUserV2StorageEntry(t, user)))
+
+		_, err = sign(restricted, safeTxData(nil))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/approval"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathSignSPLTransfer builds an SPL token transfer from the user's associated
+// token account and signs it with the key at the given derivation path.
+func (b *Backend) pathSignSPLTransfer(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_spl_transfer"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	isDev := d.Get("isDev").(bool)
+
+	backendLogger.Info("request", "path", derivationPath, "mint", d.Get("mint").(string))
+
+	// validate data provided
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	transfer, err := splTransferFromFields(d)
+	if err != nil {
+		backendLogger.Error("validate transfer", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(slip44.Solana); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	adapterInventory := adapter.GetInventory(backendLogger).WithContext(ctx)
+
+	// the owner of the source token account is the derived wallet, which also pays the fee
+	owner, err := adapterInventory.DeriveAddress(seed, slip44.Solana, derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if transfer.Owner, err = solana.PublicKeyFromBase58(owner); err != nil {
+		backendLogger.Error("decode owner", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	built, err := solana.BuildSPLTransfer(transfer)
+	if err != nil {
+		backendLogger.Error("build transfer", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(built.Message)})
+	if err != nil {
+		backendLogger.Error("encode payload", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	txHex, err := adapterInventory.CreateSignedTransaction(seed, slip44.Solana, derivationPath, string(payload), isDev)
+	if err != nil {
+		backendLogger.Error("create signature", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("signature", "signature", txHex)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature":          txHex,
+			"sourceAccount":      built.SourceAccount.String(),
+			"destinationAccount": built.DestinationAccount.String(),
+		},
+	}, nil
+}
+
+// splTransferFromFields decodes the transfer parameters of a sign/spl-transfer request
+func splTransferFromFields(d *framework.FieldData) (solana.SPLTransfer, error) {
+	var (
+		transfer solana.SPLTransfer
+		err      error
+	)
+
+	if transfer.Mint, err = solana.PublicKeyFromBase58(d.Get("mint").(string)); err != nil {
+		return transfer, helpers.New("provide a valid mint address")
+	}
+	if transfer.Recipient, err = solana.PublicKeyFromBase58(d.Get("recipient").(string)); err != nil {
+		return transfer, helpers.New("provide a valid recipient address")
+	}
+	if transfer.RecentBlockhash, err = solana.BlockhashFromBase58(d.Get("recentBlockhash").(string)); err != nil {
+		return transfer, err
+	}
+	if transfer.Amount, err = strconv.ParseUint(d.Get("amount").(string), 10, 64); err != nil {
+		return transfer, helpers.New("amount must be a base unit integer")
+	}
+
+	transfer.TokenProgram = d.Get("tokenProgram").(string)
+	transfer.Decimals = d.Get("decimals").(int)
+	transfer.CreateRecipientAccount = d.Get("createRecipientAccount").(bool)
+	transfer.Memo = d.Get("memo").(string)
+
+	return transfer, nil
+}
+
+// splTransferIntent is the intent of a sign/spl-transfer request, a transfer of the token of its
+// mint to the recipient wallet
+func splTransferIntent(d *framework.FieldData) (*signIntent, error) {
+	transfer, err := splTransferFromFields(d)
+	if err != nil {
+		return nil, nil
+	}
+	return intentOf("sign/spl-transfer", d, []string{d.Get("uuid").(string)}, d.Get("derivationPath").(string),
+		approval.Summary{
+			CoinType: slip44.Solana,
+			Transfers: []approval.Transfer{{
+				To: transfer.Recipient.String(), Amount: strconv.FormatUint(transfer.Amount, 10),
+				Token: transfer.Mint.String(),
+			}},
+			Memo: transfer.Memo,
+		})
+}
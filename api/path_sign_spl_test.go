@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+const (
+	splTestPath      = "m/44'/501'/0'/0'"
+	splTestOwner     = "HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk"
+	splTestMint      = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	splTestRecipient = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	splTestBlockhash = "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N"
+)
+
+// Helper function to create a proper framework.FieldData for sign/spl-transfer endpoint
+func createSPLFieldData(data map[string]interface{}) *framework.FieldData {
+	schema := map[string]*framework.FieldSchema{
+		"uuid":                   {Type: framework.TypeString},
+		"derivationPath":         {Type: framework.TypeString},
+		"mint":                   {Type: framework.TypeString},
+		"recipient":              {Type: framework.TypeString},
+		"amount":                 {Type: framework.TypeString},
+		"recentBlockhash":        {Type: framework.TypeString},
+		"tokenProgram":           {Type: framework.TypeString, Default: solana.TokenProgramSPL},
+		"decimals":               {Type: framework.TypeInt, Default: -1},
+		"createRecipientAccount": {Type: framework.TypeBool, Default: false},
+		"isDev":                  {Type: framework.TypeBool, Default: false},
+		"memo":                   {Type: framework.TypeString},
+	}
+
+	return &framework.FieldData{
+		Raw:    data,
+		Schema: schema,
+	}
+}
+
+func TestBackend_PathSignSPLTransfer(t *testing.T) {
+	ctx := context.Background()
+
+	baseData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"uuid":            signTestUUID,
+			"derivationPath":  splTestPath,
+			"mint":            splTestMint,
+			"recipient":       splTestRecipient,
+			"amount":          "2500000",
+			"recentBlockhash": splTestBlockhash,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(map[string]interface{})
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:   "spl token transfer",
+			mutate: func(map[string]interface{}) {},
+		},
+		{
+			name: "token-2022 transfer checked with account creation",
+			mutate: func(data map[string]interface{}) {
+				data["tokenProgram"] = solana.TokenProgramToken2022
+				data["decimals"] = 6
+				data["createRecipientAccount"] = true
+			},
+		},
+		{
+			name: "transfer with a memo",
+			mutate: func(data map[string]interface{}) {
+				data["memo"] = "deposit 104528"
+			},
+		},
+		{
+			name: "invalid memo",
+			mutate: func(data map[string]interface{}) {
+				data["memo"] = "\xff"
+			},
+			wantErr:    true,
+			wantErrMsg: solana.ErrInvalidMemo.Error(),
+		},
+		{
+			name: "token-2022 without decimals",
+			mutate: func(data map[string]interface{}) {
+				data["tokenProgram"] = solana.TokenProgramToken2022
+			},
+			wantErr:    true,
+			wantErrMsg: solana.ErrDecimalsRequired.Error(),
+		},
+		{
+			name: "invalid mint",
+			mutate: func(data map[string]interface{}) {
+				data["mint"] = "not-a-mint"
+			},
+			wantErr:    true,
+			wantErrMsg: "provide a valid mint address",
+		},
+		{
+			name: "invalid amount",
+			mutate: func(data map[string]interface{}) {
+				data["amount"] = "-5"
+			},
+			wantErr:    true,
+			wantErrMsg: "amount must be a base unit integer",
+		},
+		{
+			name: "invalid blockhash",
+			mutate: func(data map[string]interface{}) {
+				data["recentBlockhash"] = "abc"
+			},
+			wantErr:    true,
+			wantErrMsg: solana.ErrInvalidBlockhash.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := new(MockStorageSign)
+			backend := createSignTestBackend(t)
+
+			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
+			userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, "")
+			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil).Maybe()
+
+			data := baseData()
+			tt.mutate(data)
+
+			req := &logical.Request{
+				Storage: mockStorage,
+				Data:    data,
+			}
+
+			got, err := backend.pathSignSPLTransfer(ctx, req, createSPLFieldData(data))
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.NotEmpty(t, got.Data["sourceAccount"])
+			assert.NotEmpty(t, got.Data["destinationAccount"])
+
+			txHex, ok := got.Data["signature"].(string)
+			require.True(t, ok)
+			tx, err := hex.DecodeString(txHex)
+			require.NoError(t, err)
+
+			owner, err := solana.PublicKeyFromBase58(splTestOwner)
+			require.NoError(t, err)
+			signature, message := tx[1:1+ed25519.SignatureSize], tx[1+ed25519.SignatureSize:]
+			assert.True(t, ed25519.Verify(owner[:], message, signature))
+
+			mockStorage.AssertExpectations(t)
+		})
+	}
+}
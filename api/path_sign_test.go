@@ -1,17 +1,24 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/payment-system/dq-vault/api/helpers"
 	"github.com/payment-system/dq-vault/config"
@@ -57,6 +64,13 @@ func (m *MockStorageSign) Delete(ctx context.Context, key string) error {
 	return args.Error(0)
 }
 
+// isSignPolicyKey matches the reads of config/fees, config/addressbook and config/contracts, the
+// tests sign without fee bounds, address book or contract policy
+func isSignPolicyKey(key string) bool {
+	return strings.HasPrefix(key, config.FeeBoundsStoragePath) || key == config.AddressBookPolicyStorageKey ||
+		key == config.ContractPolicyStorageKey
+}
+
 // Helper function to create a proper framework.FieldData for sign endpoint
 func createSignFieldData(data map[string]interface{}) *framework.FieldData {
 	schema := map[string]*framework.FieldSchema{
@@ -64,7 +78,7 @@ func createSignFieldData(data map[string]interface{}) *framework.FieldData {
 			Type:        framework.TypeString,
 			Description: "User UUID",
 		},
-		"path": {
+		"derivationPath": {
 			Type:        framework.TypeString,
 			Description: "Derivation path",
 		},
@@ -80,6 +94,10 @@ func createSignFieldData(data map[string]interface{}) *framework.FieldData {
 			Type:        framework.TypeBool,
 			Description: "Development mode flag",
 		},
+		"complete": {
+			Type:        framework.TypeBool,
+			Description: "Complete the payload from the config/rpc node",
+		},
 	}
 
 	return &framework.FieldData{
@@ -127,11 +145,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "successful ethereum transaction signing",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
@@ -139,6 +157,7 @@ func TestBackend_PathSign(t *testing.T) {
 				// Mock Get for retrieving user data
 				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
 				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				ms.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 			},
 			want: &logical.Response{
 				Data: map[string]interface{}{
@@ -150,11 +169,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "successful signing with development mode",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    true,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          true,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
@@ -162,6 +181,7 @@ func TestBackend_PathSign(t *testing.T) {
 				// Mock Get for retrieving user data
 				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
 				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				ms.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 			},
 			want: &logical.Response{
 				Data: map[string]interface{}{
@@ -173,18 +193,16 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "bitshares coin type with path override",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Bitshares),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Bitshares),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
-				// Mock Get for retrieving user data
-				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				// The payload is rejected before the user record is read
 			},
 			wantErr:        true, // Bitshares doesn't have adapter
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -192,10 +210,10 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "missing uuid field",
 			fieldData: map[string]interface{}{
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(_ *MockStorageSign) {
 				// No storage expectations since validation should fail first
@@ -220,17 +238,15 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "missing coinType field",
 			fieldData: map[string]interface{}{
-				"uuid":    signTestUUID,
-				"path":    signTestDerivationPath,
-				"payload": signTestPayload,
-				"isDev":   false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check since coinType defaults to 0
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
-				// Mock Get for retrieving user data
-				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				// The payload is rejected before the user record is read
 			},
 			wantErr:        true, // Bitcoin (coinType 0) doesn't have adapter
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -238,17 +254,15 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "missing payload field",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
-				// Mock Get for retrieving user data
-				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				// The payload is rejected before the user record is read
 			},
 			wantErr:        true, // Empty payload will cause adapter error
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -256,11 +270,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "empty uuid",
 			fieldData: map[string]interface{}{
-				"uuid":     "",
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           "",
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(_ *MockStorageSign) {
 				// No storage expectations since validation should fail first
@@ -272,11 +286,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "empty derivation path",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     "",
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": "",
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(_ *MockStorageSign) {
 				// No storage expectations since validation should fail first
@@ -288,11 +302,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "uuid does not exist",
 			fieldData: map[string]interface{}{
-				"uuid":     "nonexistent-uuid",
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           "nonexistent-uuid",
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List to return empty list (UUID doesn't exist)
@@ -305,11 +319,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "storage get error",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
@@ -323,11 +337,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "invalid json in storage",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
@@ -345,11 +359,11 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "empty mnemonic in user data",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
@@ -357,6 +371,7 @@ func TestBackend_PathSign(t *testing.T) {
 				// Mock Get with empty mnemonic
 				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", "", signTestPassphrase)
 				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				ms.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 			},
 			wantErr:        true,
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -364,18 +379,16 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "unsupported coin type",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": 99999, // Unsupported coin type
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       99999, // Unsupported coin type
+				"payload":        signTestPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
-				// Mock Get for retrieving user data
-				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				// The payload is rejected before the user record is read
 			},
 			wantErr:        true,
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -383,18 +396,16 @@ func TestBackend_PathSign(t *testing.T) {
 		{
 			name: "invalid transaction payload",
 			fieldData: map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  signTestMalformedPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestMalformedPayload,
+				"isDev":          false,
 			},
 			setupStorage: func(ms *MockStorageSign) {
 				// Mock List for UUID existence check
 				ms.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
-				// Mock Get for retrieving user data
-				userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-				ms.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+				// The payload is rejected before the user record is read
 			},
 			wantErr:        true,
 			wantStatusCode: http.StatusUnprocessableEntity,
@@ -448,6 +459,16 @@ func TestBackend_PathSign(t *testing.T) {
 					signature, ok := got.Data["signature"].(string)
 					assert.True(t, ok)
 					assert.NotEmpty(t, signature)
+
+					// the returned address and public key are the ones that signed
+					var tx types.Transaction
+					require.NoError(t, tx.UnmarshalBinary(common.FromHex(signature)))
+					sender, err := types.Sender(types.NewEIP155Signer(tx.ChainId()), &tx)
+					require.NoError(t, err)
+					assert.Equal(t, sender.Hex(), got.Data["address"])
+					publicKey, err := crypto.DecompressPubkey(common.FromHex(got.Data["publicKey"].(string)))
+					require.NoError(t, err)
+					assert.Equal(t, sender, crypto.PubkeyToAddress(*publicKey))
 				}
 			}
 
@@ -490,24 +511,26 @@ func TestBackend_PathSign_CoinTypeSpecific(t *testing.T) {
 			// Setup storage expectations
 			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
 			userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+			// Unsupported coin types and invalid payloads are rejected before the user record is read
+			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil).Maybe()
+			mockStorage.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 
 			fieldData := createSignFieldData(map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": tt.coinType,
-				"payload":  signTestPayload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       tt.coinType,
+				"payload":        signTestPayload,
+				"isDev":          false,
 			})
 
 			req := &logical.Request{
 				Storage: mockStorage,
 				Data: map[string]interface{}{
-					"uuid":     signTestUUID,
-					"path":     signTestDerivationPath,
-					"coinType": tt.coinType,
-					"payload":  signTestPayload,
-					"isDev":    false,
+					"uuid":           signTestUUID,
+					"derivationPath": signTestDerivationPath,
+					"coinType":       tt.coinType,
+					"payload":        signTestPayload,
+					"isDev":          false,
 				},
 			}
 
@@ -566,24 +589,26 @@ func TestBackend_PathSign_PayloadValidation(t *testing.T) {
 			// Setup storage expectations
 			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
 			userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+			// Unsupported coin types and invalid payloads are rejected before the user record is read
+			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil).Maybe()
+			mockStorage.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 
 			fieldData := createSignFieldData(map[string]interface{}{
-				"uuid":     signTestUUID,
-				"path":     signTestDerivationPath,
-				"coinType": int(slip44.Ether),
-				"payload":  tt.payload,
-				"isDev":    false,
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        tt.payload,
+				"isDev":          false,
 			})
 
 			req := &logical.Request{
 				Storage: mockStorage,
 				Data: map[string]interface{}{
-					"uuid":     signTestUUID,
-					"path":     signTestDerivationPath,
-					"coinType": int(slip44.Ether),
-					"payload":  tt.payload,
-					"isDev":    false,
+					"uuid":           signTestUUID,
+					"derivationPath": signTestDerivationPath,
+					"coinType":       int(slip44.Ether),
+					"payload":        tt.payload,
+					"isDev":          false,
 				},
 			}
 
@@ -602,6 +627,27 @@ func TestBackend_PathSign_PayloadValidation(t *testing.T) {
 	}
 }
 
+func TestBackend_PathSign_FieldErrors(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := new(MockStorageSign)
+	// The payload is rejected before the user record is read
+	mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
+
+	data := map[string]interface{}{
+		"uuid":           signTestUUID,
+		"derivationPath": signTestDerivationPath,
+		"coinType":       int(slip44.Ether),
+		"payload":        `{"value":1,"gasLimit":21000,"gasPrice":1,"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0xzz","chainId":1}`,
+		"isDev":          false,
+	}
+
+	_, err := createSignTestBackend(t).pathSign(ctx, &logical.Request{Storage: mockStorage, Data: data},
+		createSignFieldData(data))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid payload: nonce: missing; data: not hex")
+	mockStorage.AssertExpectations(t)
+}
+
 func TestBackend_PathSign_EdgeCases(t *testing.T) {
 	ctx := context.Background()
 	backend := createSignTestBackend(t)
@@ -609,11 +655,11 @@ func TestBackend_PathSign_EdgeCases(t *testing.T) {
 	t.Run("nil_context", func(t *testing.T) {
 		mockStorage := new(MockStorageSign)
 		data := map[string]interface{}{
-			"uuid":     signTestUUID,
-			"path":     signTestDerivationPath,
-			"coinType": int(slip44.Ether),
-			"payload":  signTestPayload,
-			"isDev":    false,
+			"uuid":           signTestUUID,
+			"derivationPath": signTestDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"payload":        signTestPayload,
+			"isDev":          false,
 		}
 		fieldData := createSignFieldData(data)
 
@@ -639,17 +685,18 @@ func TestBackend_PathSign_EdgeCases(t *testing.T) {
 		}
 
 		data := map[string]interface{}{
-			"uuid":     signTestUUID,
-			"path":     longPath,
-			"coinType": int(slip44.Ether),
-			"payload":  signTestPayload,
-			"isDev":    false,
+			"uuid":           signTestUUID,
+			"derivationPath": longPath,
+			"coinType":       int(slip44.Ether),
+			"payload":        signTestPayload,
+			"isDev":          false,
 		}
 		fieldData := createSignFieldData(data)
 
 		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
 		userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
 		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+		mockStorage.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 
 		req := &logical.Request{
 			Storage: mockStorage,
@@ -667,17 +714,18 @@ func TestBackend_PathSign_EdgeCases(t *testing.T) {
 		largePayload := `{"nonce":42,"value":1000000000000000000,"gasLimit":21000,"gasPrice":20000000000,"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0x` + string(make([]byte, 10000)) + `","chainId":1}`
 
 		data := map[string]interface{}{
-			"uuid":     signTestUUID,
-			"path":     signTestDerivationPath,
-			"coinType": int(slip44.Ether),
-			"payload":  largePayload,
-			"isDev":    false,
+			"uuid":           signTestUUID,
+			"derivationPath": signTestDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"payload":        largePayload,
+			"isDev":          false,
 		}
 		fieldData := createSignFieldData(data)
 
 		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
 		userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
-		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil).Maybe()
+		mockStorage.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 
 		req := &logical.Request{
 			Storage: mockStorage,
@@ -694,6 +742,33 @@ func TestBackend_PathSign_EdgeCases(t *testing.T) {
 	})
 }
 
+func TestSeedDerivation(t *testing.T) {
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	want, err := user.Seed()
+	require.NoError(t, err)
+
+	t.Run("derived concurrently", func(t *testing.T) {
+		derivation := deriveSeed(context.Background(), user)
+		seed, err := derivation.wait(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, want, seed)
+
+		derivation.release()
+		assert.Eventually(t, func() bool {
+			return bytes.Count(seed, []byte{0}) == len(seed)
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("the deadline of the request passes first", func(t *testing.T) {
+		derivation := &seedDerivation{done: make(chan struct{})}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := derivation.wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
 // Benchmark test for performance
 func BenchmarkBackend_PathSign(b *testing.B) {
 	ctx := context.Background()
@@ -704,13 +779,14 @@ func BenchmarkBackend_PathSign(b *testing.B) {
 		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
 		userEntry := createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, signTestPassphrase)
 		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(userEntry, nil)
+		mockStorage.On("Get", ctx, mock.MatchedBy(isSignPolicyKey)).Return(nil, nil).Maybe()
 
 		data := map[string]interface{}{
-			"uuid":     signTestUUID,
-			"path":     signTestDerivationPath,
-			"coinType": int(slip44.Ether),
-			"payload":  signTestPayload,
-			"isDev":    false,
+			"uuid":           signTestUUID,
+			"derivationPath": signTestDerivationPath,
+			"coinType":       int(slip44.Ether),
+			"payload":        signTestPayload,
+			"isDev":          false,
 		}
 		fieldData := createSignFieldData(data)
 
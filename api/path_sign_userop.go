@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+// pathSignUserOp corresponds to UPDATE sign/userop. It computes the userOpHash of an ERC-4337
+// user operation and returns the signature of the vault held owner key of the smart account.
+func (b *Backend) pathSignUserOp(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_sign_userop"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	coinType := uint16(d.Get("coinType").(int))
+	version := d.Get("entryPointVersion").(string)
+	rawHash := d.Get("rawHash").(bool)
+
+	op, entryPoint, chainID, err := userOpFromFields(d)
+	if err != nil {
+		backendLogger.Error("user operation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if !evm.NewEthereumAdapter(b.logger).CanDo(coinType) {
+		backendLogger.Error("not an evm coin", "coinType", coinType)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+
+	// validate data provided
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	signature, owner, hash, err := evm.SignUserOperation(seed, derivationPath, op, entryPoint, chainID, version,
+		!rawHash)
+	if err != nil {
+		backendLogger.Error("sign user operation", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	backendLogger.Info("user operation signed", "uuid", uuid, "path", derivationPath, "sender", op.Sender.Hex(),
+		"entryPoint", entryPoint.Hex(), "entryPointVersion", version, "chainId", chainID, "nonce", op.Nonce,
+		"userOpHash", hexutil.Encode(hash))
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"signature":  hexutil.Encode(signature),
+			"owner":      owner.Hex(),
+			"userOpHash": hexutil.Encode(hash),
+		},
+	}, nil
+}
+
+// userOpFromFields reads the user operation, EntryPoint and chain id of the request;
+// amounts are decimal or 0x hex strings
+func userOpFromFields(d *framework.FieldData) (*evm.UserOperation, common.Address, *big.Int, error) {
+	op := &evm.UserOperation{}
+	var entryPoint common.Address
+	var chainID *big.Int
+
+	for _, f := range []struct {
+		name    string
+		address *common.Address
+	}{
+		{"entryPoint", &entryPoint},
+		{"sender", &op.Sender},
+	} {
+		if value := d.Get(f.name).(string); common.IsHexAddress(value) {
+			*f.address = common.HexToAddress(value)
+		} else {
+			return nil, common.Address{}, nil, fmt.Errorf("%w: %s", helpers.ErrInvalidUserOpField, f.name)
+		}
+	}
+
+	for _, f := range []struct {
+		name   string
+		amount **big.Int
+	}{
+		{"chainId", &chainID},
+		{"nonce", &op.Nonce},
+		{"callGasLimit", &op.CallGasLimit},
+		{"verificationGasLimit", &op.VerificationGasLimit},
+		{"preVerificationGas", &op.PreVerificationGas},
+		{"maxFeePerGas", &op.MaxFeePerGas},
+		{"maxPriorityFeePerGas", &op.MaxPriorityFeePerGas},
+	} {
+		// none of them has a default, an empty value would be read as 0
+		raw := strings.TrimSpace(d.Get(f.name).(string))
+		value, ok := math.ParseBig256(raw)
+		if !ok || raw == "" {
+			return nil, common.Address{}, nil, fmt.Errorf("%w: %s", helpers.ErrInvalidUserOpField, f.name)
+		}
+		*f.amount = value
+	}
+
+	for _, f := range []struct {
+		name  string
+		bytes *[]byte
+	}{
+		{"initCode", &op.InitCode},
+		{"callData", &op.CallData},
+		{"paymasterAndData", &op.PaymasterAndData},
+	} {
+		value, err := hexutil.Decode(withHexPrefix(d.Get(f.name).(string)))
+		if err != nil {
+			return nil, common.Address{}, nil, fmt.Errorf("%w: %s: %w", helpers.ErrInvalidUserOpField, f.name, err)
+		}
+		*f.bytes = value
+	}
+	return op, entryPoint, chainID, nil
+}
+
+// userOpIntent is the intent of a sign/userop request, whose call data the account decides how to
+// run: it is not decoded
+func userOpIntent(d *framework.FieldData) (*signIntent, error) {
+	coinType, ok := fieldCoinType(d)
+	if _, _, _, err := userOpFromFields(d); !ok || err != nil {
+		return nil, nil
+	}
+	return intentOf("sign/userop", d, []string{d.Get("uuid").(string)}, d.Get("derivationPath").(string),
+		approval.Summary{CoinType: coinType})
+}
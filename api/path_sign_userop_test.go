@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// Helper function to create a proper framework.FieldData for sign/userop endpoint
+func createSignUserOpFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":                 {Type: framework.TypeString},
+			"derivationPath":       {Type: framework.TypeString, Default: ""},
+			"coinType":             {Type: framework.TypeInt, Default: 60},
+			"chainId":              {Type: framework.TypeString},
+			"entryPoint":           {Type: framework.TypeString},
+			"entryPointVersion":    {Type: framework.TypeString, Default: evm.DefaultEntryPointVersion},
+			"sender":               {Type: framework.TypeString},
+			"nonce":                {Type: framework.TypeString},
+			"initCode":             {Type: framework.TypeString, Default: ""},
+			"callData":             {Type: framework.TypeString, Default: ""},
+			"callGasLimit":         {Type: framework.TypeString},
+			"verificationGasLimit": {Type: framework.TypeString},
+			"preVerificationGas":   {Type: framework.TypeString},
+			"maxFeePerGas":         {Type: framework.TypeString},
+			"maxPriorityFeePerGas": {Type: framework.TypeString},
+			"paymasterAndData":     {Type: framework.TypeString, Default: ""},
+			"rawHash":              {Type: framework.TypeBool, Default: false},
+		},
+	}
+}
+
+func TestBackend_PathSignUserOp(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+	userOpData := func(overrides map[string]interface{}) map[string]interface{} {
+		data := map[string]interface{}{
+			"uuid":                 signTestUUID,
+			"derivationPath":       "m/44'/60'/0'/0/0",
+			"chainId":              "1",
+			"entryPoint":           "0x0000000071727De22E5E9d8BAf0edAc6f37da032",
+			"sender":               "0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326",
+			"nonce":                "0",
+			"callData":             "0xb61d27f6",
+			"callGasLimit":         "100000",
+			"verificationGasLimit": "0x249f0",
+			"preVerificationGas":   "21000",
+			"maxFeePerGas":         "30000000000",
+			"maxPriorityFeePerGas": "1000000000",
+		}
+		for k, v := range overrides {
+			data[k] = v
+		}
+		return data
+	}
+	sign := func(storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+		return createSignTestBackend(t).pathSignUserOp(ctx, &logical.Request{Storage: storage, Data: data},
+			createSignUserOpFieldData(data))
+	}
+
+	t.Run("owner signature", func(t *testing.T) {
+		got, err := sign(s, userOpData(nil))
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", got.Data["owner"])
+
+		op := &evm.UserOperation{
+			Sender: common.HexToAddress("0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326"), Nonce: big.NewInt(0),
+			CallData: common.FromHex("0xb61d27f6"), CallGasLimit: big.NewInt(100000),
+			VerificationGasLimit: big.NewInt(150000), PreVerificationGas: big.NewInt(21000),
+			MaxFeePerGas: big.NewInt(30000000000), MaxPriorityFeePerGas: big.NewInt(1000000000),
+		}
+		hash, err := op.Hash(common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032"), big.NewInt(1),
+			evm.EntryPointV07)
+		require.NoError(t, err)
+		assert.Equal(t, hexutil.Encode(hash), got.Data["userOpHash"])
+		assert.Len(t, got.Data["signature"], 2+2*65)
+	})
+
+	t.Run("raw hash and entryPoint version", func(t *testing.T) {
+		got, err := sign(s, userOpData(nil))
+		require.NoError(t, err)
+		raw, err := sign(s, userOpData(map[string]interface{}{"rawHash": true}))
+		require.NoError(t, err)
+		assert.Equal(t, got.Data["userOpHash"], raw.Data["userOpHash"])
+		assert.NotEqual(t, got.Data["signature"], raw.Data["signature"])
+
+		v06, err := sign(s, userOpData(map[string]interface{}{"entryPointVersion": evm.EntryPointV06}))
+		require.NoError(t, err)
+		assert.NotEqual(t, got.Data["userOpHash"], v06.Data["userOpHash"])
+	})
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantErr   string
+	}{
+		{"missing nonce", map[string]interface{}{"nonce": ""}, helpers.ErrInvalidUserOpField.Error() + ": nonce"},
+		{"invalid sender", map[string]interface{}{"sender": "0x1234"}, helpers.ErrInvalidUserOpField.Error() + ": sender"},
+		{"invalid callData", map[string]interface{}{"callData": "0xzz"}, helpers.ErrInvalidUserOpField.Error() + ": callData"},
+		{"missing chainId", map[string]interface{}{"chainId": ""}, helpers.ErrInvalidUserOpField.Error() + ": chainId"},
+		{"unsupported version", map[string]interface{}{"entryPointVersion": "0.5"}, evm.ErrUnsupportedEntryPoint.Error()},
+		{"not an evm coin", map[string]interface{}{"coinType": 0}, helpers.ErrUnsupportedCoinType.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sign(s, userOpData(tt.overrides))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("coin restricted user", func(t *testing.T) {
+		restricted := &logical.InmemStorage{}
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{0})
+		require.NoError(t, err)
+		require.NoError(t, restricted.Put(ctx, createUserV2StorageEntry(t, user)))
+
+		_, err = sign(restricted, userOpData(nil))
+		require.Error(t, err)
+		codedErr, ok := err.(logical.HTTPCodedError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, codedErr.Code())
+	})
+}
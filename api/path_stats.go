@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/queue"
+)
+
+// statsPrefixes are the storage prefixes reported by stats/storage
+//
+//nolint:gochecknoglobals // read-only lookup table
+var statsPrefixes = []string{
+	config.StorageBasePath,
+	config.EscrowStoragePath,
+	config.APIKeysStoragePath,
+	config.SigningSessionsStoragePath,
+	config.DebugStoragePath,
+	config.AddressLedgerStoragePath,
+	config.AddressIndexStoragePath,
+	config.BackupVerificationStoragePath,
+	config.ViolationsStoragePath,
+	config.MultisigStoragePath,
+	config.ApprovalsStoragePath,
+	config.AddressBookStoragePath,
+	config.ReceiptsStoragePath,
+	config.EventsStoragePath,
+	config.BatchWALStoragePath,
+	config.JobsStoragePath,
+	config.BudgetsStoragePath,
+	config.CanaryStoragePath,
+	config.PassphraseStoragePath,
+	config.ReplicationStoragePath,
+	config.ConfigStoragePath,
+}
+
+// storageUsage counts the entries under a prefix and the bytes of their values
+type storageUsage struct {
+	Keys  int
+	Bytes int
+}
+
+// pathStatsStorage corresponds to READ stats/storage. It walks every prefix of the Vault storage
+// and reports its entries and the bytes of their values, as stored. When the user records are kept
+// in an external store, its records are counted too, with the size of the decrypted records.
+func (b *Backend) pathStatsStorage(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_stats_storage"))
+
+	prefixes := make(map[string]interface{}, len(statsPrefixes))
+	var total storageUsage
+	for _, prefix := range statsPrefixes {
+		usage, err := walkStorageUsage(ctx, req.Storage, prefix)
+		if err != nil {
+			backendLogger.Error("walk storage", "error", err, "prefix", prefix)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		prefixes[prefix] = usageResponseData(usage)
+		total.Keys += usage.Keys
+		total.Bytes += usage.Bytes
+	}
+
+	storageConfig, err := helpers.GetStorageConfig(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get storage config", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	data := map[string]interface{}{
+		"store":      storageConfig.Type,
+		"prefixes":   prefixes,
+		"totalKeys":  total.Keys,
+		"totalBytes": total.Bytes,
+	}
+
+	users, err := b.userStorage(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("open user store", "error", err)
+		return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+	}
+	// the records of the Vault storage were counted with the other prefixes
+	if users != nil && storageConfig.Type != storage.TypeVault {
+		usage, err := walkStorageUsage(ctx, users, config.StorageBasePath)
+		if err != nil {
+			backendLogger.Error("walk user store", "error", err)
+			return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+		}
+		data["userStore"] = usageResponseData(usage)
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func usageResponseData(usage storageUsage) map[string]interface{} {
+	return map[string]interface{}{
+		"keys":  usage.Keys,
+		"bytes": usage.Bytes,
+	}
+}
+
+// walkStorageUsage counts the entries under prefix, recursively, and the bytes of their values
+func walkStorageUsage(ctx context.Context, s logical.Storage, prefix string) (storageUsage, error) {
+	var usage storageUsage
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return usage, err
+	}
+
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			child, err := walkStorageUsage(ctx, s, prefix+key)
+			if err != nil {
+				return usage, err
+			}
+			usage.Keys += child.Keys
+			usage.Bytes += child.Bytes
+			continue
+		}
+		entry, err := s.Get(ctx, prefix+key)
+		if err != nil {
+			return usage, err
+		}
+		if entry == nil {
+			continue
+		}
+		usage.Keys++
+		usage.Bytes += len(entry.Value)
+	}
+	return usage, nil
+}
+
+// pathStatsQueue corresponds to READ stats/queue. It reports the request queue of config/quotas on
+// the node serving the request: the slots taken and, for each priority class, the requests and users
+// waiting with the counters since the plugin started.
+func (b *Backend) pathStatsQueue(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_stats_queue"))
+
+	quotas, err := helpers.GetQuotas(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get quotas", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	classes := make(map[string]interface{}, len(queue.Classes))
+	depth := 0
+	for _, class := range queue.Classes {
+		stats := b.requestQueue.Stats(class)
+		depth += stats.Depth
+		averageWait := time.Duration(0)
+		if stats.Served > 0 {
+			averageWait = stats.Waited / time.Duration(stats.Served)
+		}
+		classes[class.String()] = map[string]interface{}{
+			"depth":         stats.Depth,
+			"waitingUsers":  stats.Keys,
+			"served":        stats.Served,
+			"rejected":      stats.Rejected,
+			"abandoned":     stats.Abandoned,
+			"averageWaitMs": averageWait.Milliseconds(),
+			"maxWaitMs":     stats.MaxWaited.Milliseconds(),
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":  quotas.Queued(),
+			"capacity": quotas.MaxConcurrentRequests,
+			"inFlight": b.inFlight.Load(),
+			"depth":    depth,
+			"maxDepth": quotas.QueueDepth(),
+			"classes":  classes,
+		},
+	}, nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_HandleRequest_StatsStorage(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+
+	put := func(key, value string) {
+		t.Helper()
+		require.NoError(t, s.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte(value)}))
+	}
+	stats := func() map[string]interface{} {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation, Path: "stats/storage", Storage: s,
+		})
+		require.NoError(t, err)
+		return resp.Data
+	}
+
+	t.Run("empty mount", func(t *testing.T) {
+		data := stats()
+		assert.Equal(t, "vault", data["store"])
+		assert.Equal(t, 0, data["totalKeys"])
+		assert.Equal(t, 0, data["totalBytes"])
+		assert.Len(t, data["prefixes"], len(statsPrefixes))
+		assert.NotContains(t, data, "userStore")
+	})
+
+	put(config.StorageBasePath+"alice", "0123456789")
+	put(config.StorageBasePath+"bob", "01234")
+	put(config.DebugStoragePath+"alice/1", "abc")
+	put(config.DebugStoragePath+"alice/2", "abcd")
+	put(config.AddressIndexStoragePath+"0xabc", "xy")
+	put(config.DebugSessionsStoragePath+"alice", "{}")
+
+	t.Run("counts the entries under each prefix", func(t *testing.T) {
+		data := stats()
+		prefixes := data["prefixes"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"keys": 2, "bytes": 15}, prefixes[config.StorageBasePath])
+		assert.Equal(t, map[string]interface{}{"keys": 2, "bytes": 7}, prefixes[config.DebugStoragePath])
+		assert.Equal(t, map[string]interface{}{"keys": 1, "bytes": 2}, prefixes[config.AddressIndexStoragePath])
+		assert.Equal(t, map[string]interface{}{"keys": 0, "bytes": 0}, prefixes[config.AddressLedgerStoragePath])
+		assert.Equal(t, map[string]interface{}{"keys": 1, "bytes": 2}, prefixes[config.ConfigStoragePath])
+		assert.Equal(t, 6, data["totalKeys"])
+		assert.Equal(t, 26, data["totalBytes"])
+	})
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/logging"
+	"github.com/payment-system/dq-vault/lib/rpc"
+	"github.com/payment-system/dq-vault/lib/secp"
+)
+
+// vaultSDKModule is the module of the Vault SDK the plugin is built with, reported in the bundle
+const vaultSDKModule = "github.com/hashicorp/vault/sdk"
+
+// requestErrors -- counts the failed requests served by the node by route pattern and status, since
+// the plugin started; the paths are never kept, as they hold uuids
+type requestErrors struct {
+	mu     sync.Mutex
+	since  time.Time
+	counts map[requestErrorKey]*requestErrorCount
+}
+
+type requestErrorKey struct {
+	pattern string
+	status  int
+}
+
+type requestErrorCount struct {
+	count     int
+	lastAt    time.Time
+	lastError string
+}
+
+// record counts err, the failure of a request of route, nil when it matched none
+func (e *requestErrors) record(route *framework.Path, err error) {
+	if err == nil {
+		return
+	}
+	key := requestErrorKey{pattern: "unrouted", status: http.StatusInternalServerError}
+	if route != nil {
+		key.pattern = strings.TrimSuffix(strings.TrimPrefix(route.Pattern, "^"), "$")
+	}
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		key.status = coded.Code()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counts == nil {
+		e.counts = make(map[requestErrorKey]*requestErrorCount)
+	}
+	count, ok := e.counts[key]
+	if !ok {
+		count = &requestErrorCount{}
+		e.counts[key] = count
+	}
+	count.count++
+	count.lastAt = time.Now().UTC()
+	count.lastError = logging.RedactString(err.Error())
+}
+
+// responseData returns the counts, the most frequent first
+func (e *requestErrors) responseData() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts := make([]map[string]interface{}, 0, len(e.counts))
+	for key, count := range e.counts {
+		counts = append(counts, map[string]interface{}{
+			"pattern":   key.pattern,
+			"status":    key.status,
+			"count":     count.count,
+			"lastAt":    formatTime(count.lastAt),
+			"lastError": count.lastError,
+		})
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i]["count"] != counts[j]["count"] {
+			return counts[i]["count"].(int) > counts[j]["count"].(int)
+		}
+		if counts[i]["pattern"] != counts[j]["pattern"] {
+			return counts[i]["pattern"].(string) < counts[j]["pattern"].(string)
+		}
+		return counts[i]["status"].(int) < counts[j]["status"].(int)
+	})
+	return map[string]interface{}{
+		"since":  formatTime(e.since),
+		"counts": counts,
+	}
+}
+
+// pathSupportBundle corresponds to READ support/bundle. It gathers what support needs to triage an
+// incident in one document: the versions, the configuration of config/import and the feature flags,
+// the adapters, the last validation and the errors served by the node. Secrets are redacted and the
+// URLs reduced to their host, so the bundle can be attached to a ticket as is.
+func (b *Backend) pathSupportBundle(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_support_bundle"))
+
+	configResp, err := b.pathReadImport(ctx, req, d)
+	if err != nil {
+		return nil, err
+	}
+	adapters := make([]map[string]interface{}, 0)
+	for _, capabilities := range adapter.GetInventory(backendLogger).Capabilities() {
+		adapters = append(adapters, map[string]interface{}{
+			"adapter":    capabilities.Adapter,
+			"coinTypes":  capabilities.CoinTypes,
+			"curve":      capabilities.Curve,
+			"operations": capabilities.Operations,
+		})
+	}
+
+	// the feature flags are reported on their own
+	features := configResp.Data["features"]
+	delete(configResp.Data, "features")
+	data := map[string]interface{}{
+		"generatedAt": formatTime(time.Now()),
+		"versions":    b.supportVersions(ctx),
+		"config":      redactURLs(sanitizeDebugData(configResp.Data)),
+		"features":    features,
+		"adapters":    adapters,
+		"errors":      b.requestErrors.responseData(),
+	}
+	if validation := b.lastValidation(); validation != nil {
+		data["validation"] = validationResponseData(validation)
+	}
+
+	backendLogger.Info("support bundle generated", "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+// supportVersions returns the versions of the plugin, of what it is built with and of the Vault
+// server running it, when the server tells
+func (b *Backend) supportVersions(ctx context.Context) map[string]interface{} {
+	versions := map[string]interface{}{
+		"plugin":     "",
+		"revision":   "",
+		"go":         runtime.Version(),
+		"vaultSDK":   "",
+		"vault":      "",
+		"secp256k1":  secp.Implementation,
+		"userSchema": helpers.UserSchemaVersion,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		versions["plugin"] = info.Main.Version
+		for _, dep := range info.Deps {
+			if dep.Path == vaultSDKModule {
+				versions["vaultSDK"] = dep.Version
+			}
+		}
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				versions["revision"] = setting.Value
+			}
+		}
+	}
+	if b.Backend != nil && b.System() != nil {
+		if env, err := b.System().PluginEnv(ctx); err == nil && env != nil {
+			versions["vault"] = env.VaultVersion
+		}
+	}
+	return versions
+}
+
+// redactURLs returns data with the values of the keys ending in url reduced to their host, as the
+// URLs of the nodes, sinks and stores may hold credentials
+func redactURLs(data map[string]interface{}) map[string]interface{} {
+	for key, value := range data {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			data[key] = redactURLs(v)
+		case string:
+			if strings.HasSuffix(strings.ToLower(key), "url") && v != "" {
+				data[key] = rpc.Host(v)
+			}
+		}
+	}
+	return data
+}
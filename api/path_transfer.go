@@ -0,0 +1,528 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/denom"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// transferPurpose is the BIP-44 purpose of the sender paths of transfer on EVM and Solana
+	transferPurpose = 44
+	// erc20TransferSelector is the selector of transfer(address,uint256)
+	erc20TransferSelector = "a9059cbb"
+	// satPerVBytePerBTCPerKvB converts the BTC/kvB fee rates of estimatesmartfee to sat/vB
+	satPerVBytePerBTCPerKvB = 1e8 / 1000
+)
+
+// bitcoinPurposes are the BIP-44 purposes of the Bitcoin address types: legacy, nested segwit,
+// native segwit and taproot
+//
+//nolint:gochecknoglobals // read-only lookup table
+var bitcoinPurposes = []uint32{44, 49, 84, 86}
+
+// transferFee -- what a fee preference of transfer pays: the percentage of the gas price of the
+// node paid on EVM chains, and the confirmation target, in blocks, of the Bitcoin fee estimate
+type transferFee struct {
+	gasPricePercent int64
+	confTarget      int
+}
+
+// transferFees maps the fee preferences of transfer to their fee
+//
+//nolint:gochecknoglobals // read-only lookup table
+var transferFees = map[string]transferFee{
+	"low":    {gasPricePercent: 100, confTarget: 24},
+	"medium": {gasPricePercent: 110, confTarget: 6},
+	"high":   {gasPricePercent: 130, confTarget: 2},
+}
+
+// transferSignFields are the optional fields of transfer passed on to the path signing the
+// transaction when they are set and the path takes them
+//
+//nolint:gochecknoglobals // read-only lookup table
+var transferSignFields = []string{"approvalId", "travelRule", "apiKey"}
+
+// transferIntent -- the normalized intent of a transfer request, amount in base units
+type transferIntent struct {
+	uuid     string
+	coinType uint16
+	account  int
+	index    int
+	asset    string
+	// symbol and decimals are those of the asset, symbol set when it is registered in config/assets
+	symbol    string
+	decimals  int
+	recipient string
+	amount    *big.Int
+	fee       transferFee
+	// memo is the memo the recipient credits the transfer by, on the chains having memos
+	memo string
+}
+
+// pathTransfer corresponds to UPDATE transfer. It builds the transaction of the chain of coinType
+// paying amount of the asset from the account of the user to the recipient, and signs it through
+// the path signing such transactions, so their policies apply: build/evm-tx on EVM chains, sign on
+// Solana and build/btc-tx on Bitcoin. The fields the chain needs are fetched from the node of
+// config/rpc at the fee preference.
+func (b *Backend) pathTransfer(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_transfer"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	intent, err := transferIntentOf(ctx, req.Storage, d)
+	if err != nil {
+		backendLogger.Error("transfer intent", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var resp *logical.Response
+	switch {
+	case evm.NewEthereumAdapter(b.logger).CanDo(intent.coinType):
+		resp, err = b.transferEVM(ctx, req, d, intent)
+	case solana.NewSolanaAdapter(b.logger).CanDo(intent.coinType):
+		resp, err = b.transferSolana(ctx, req, d, intent)
+	case bitcoin.NewBitcoinAdapter(b.logger).CanDo(intent.coinType):
+		resp, err = b.transferBitcoin(ctx, req, d, intent)
+	default:
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	resp.Data["intent"] = map[string]interface{}{
+		"coinType":      intent.coinType,
+		"asset":         intent.asset,
+		"symbol":        intent.symbol,
+		"recipient":     intent.recipient,
+		"amount":        intent.amount.String(),
+		"feePreference": d.Get("feePreference"),
+		"memo":          intent.memo,
+	}
+	backendLogger.Info("transfer", "uuid", intent.uuid, "coinType", intent.coinType, "asset", intent.asset,
+		"memo", intent.memo, "txHash", resp.Data["txHash"], "approvalStatus", resp.Data["approvalStatus"])
+	return resp, nil
+}
+
+// transferIntentOf decodes the intent of a transfer request. Native amounts are in unit, the unit
+// of the coin symbol by default; asset amounts are in the unit of their decimals. An asset of
+// config/assets, named by its symbol or address, takes the address and decimals registered.
+func transferIntentOf(ctx context.Context, s logical.Storage, d *framework.FieldData) (*transferIntent, error) {
+	intent := &transferIntent{
+		uuid:      d.Get("uuid").(string),
+		coinType:  uint16(d.Get("coinType").(int)),
+		account:   d.Get("account").(int),
+		index:     d.Get("index").(int),
+		asset:     d.Get("asset").(string),
+		decimals:  d.Get("decimals").(int),
+		recipient: d.Get("recipient").(string),
+		memo:      d.Get("memo").(string),
+	}
+	fee, ok := transferFees[d.Get("feePreference").(string)]
+	if !ok {
+		return nil, helpers.ErrInvalidFeePref
+	}
+	intent.fee = fee
+	if intent.account < 0 || intent.account > math.MaxInt32 {
+		return nil, helpers.ErrInvalidAccount
+	}
+	if intent.index < 0 || intent.index > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: index", helpers.ErrInvalidTransfer)
+	}
+	if intent.recipient == "" {
+		return nil, fmt.Errorf("%w: recipient is required", helpers.ErrInvalidTransfer)
+	}
+
+	var unit denom.Unit
+	if intent.asset == "" {
+		denomination, err := denom.Of(intent.coinType)
+		if err != nil {
+			return nil, err
+		}
+		name := d.Get("unit").(string)
+		if name == "" {
+			name = denomination.Symbol
+		}
+		if unit, err = denomination.Unit(name); err != nil {
+			return nil, err
+		}
+	} else {
+		registered, err := helpers.ResolveAsset(ctx, s, intent.coinType, intent.asset)
+		if err != nil {
+			return nil, err
+		}
+		if registered != nil {
+			if intent.decimals >= 0 && intent.decimals != registered.Decimals {
+				return nil, fmt.Errorf("%w: %s has %d", helpers.ErrAssetDecimals, registered.Symbol, registered.Decimals)
+			}
+			intent.asset, intent.symbol, intent.decimals = registered.Address, registered.Symbol, registered.Decimals
+		}
+		if intent.decimals < 0 || intent.decimals > math.MaxUint8 {
+			return nil, fmt.Errorf("%w: decimals of an unregistered asset are required", helpers.ErrInvalidTransfer)
+		}
+		unit = denom.Unit{Name: intent.asset, Decimals: intent.decimals}
+	}
+	amount, err := denom.Parse(d.Get("amount").(string), unit)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() == 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", helpers.ErrInvalidTransfer)
+	}
+	intent.amount = amount
+	return intent, nil
+}
+
+// transferEVM signs the EVM transaction of intent through build/evm-tx: a call of transfer on the
+// contract of the asset, or a payment of the native coin. The gas price of the node is scaled by
+// the fee preference, the nonce, gas limit and chainId are fetched by complete.
+func (b *Backend) transferEVM(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	intent *transferIntent) (*logical.Response, error) {
+	if intent.memo != "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMemoUnsupported.Error())
+	}
+	if !common.IsHexAddress(intent.recipient) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: recipient", helpers.ErrInvalidTransfer).Error())
+	}
+	if intent.amount.BitLen() > 256 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: amount", helpers.ErrInvalidTransfer).Error())
+	}
+
+	derivationPath := addressNextPath(lib.CurveSecp256k1, transferPurpose, int(intent.coinType), intent.account, 0,
+		uint32(intent.index))
+	raw := map[string]interface{}{
+		"uuid":           intent.uuid,
+		"derivationPath": derivationPath,
+		"coinType":       int(intent.coinType),
+		"to":             common.HexToAddress(intent.recipient).Hex(),
+		"value":          intent.amount.String(),
+		"isDev":          d.Get("isDev"),
+		"complete":       true,
+		"overrideFee":    d.Get("overrideFee"),
+	}
+	if intent.asset != "" {
+		if !common.IsHexAddress(intent.asset) {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity,
+				fmt.Errorf("%w: asset must be the address of the token contract", helpers.ErrInvalidTransfer).Error())
+		}
+		raw["to"], raw["value"] = common.HexToAddress(intent.asset).Hex(), "0"
+		raw["data"] = "0x" + erc20TransferSelector +
+			hex.EncodeToString(common.LeftPadBytes(common.HexToAddress(intent.recipient).Bytes(), common.HashLength)) +
+			hex.EncodeToString(common.LeftPadBytes(intent.amount.Bytes(), common.HashLength))
+	}
+	for _, name := range transferSignFields {
+		if value, ok := d.GetOk(name); ok {
+			raw[name] = value
+		}
+	}
+
+	gasPrice, err := b.transferGasPrice(ctx, req.Storage, intent)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	raw["gasPrice"] = gasPrice.String()
+
+	if err := b.checkDerivationPolicy(ctx, req.Storage, "build/evm-tx", raw); err != nil {
+		return nil, err
+	}
+	buildReq := *req
+	buildReq.Path, buildReq.Data = "build/evm-tx", raw
+	return b.pathBuildEVMTx(ctx, &buildReq, &framework.FieldData{Raw: raw, Schema: b.Route("build/evm-tx").Fields})
+}
+
+// transferGasPrice returns the gas price of the node of config/rpc scaled by the fee preference
+func (b *Backend) transferGasPrice(ctx context.Context, s logical.Storage, intent *transferIntent) (*big.Int,
+	error) {
+	client, _, err := b.rpcClient(ctx, s, intent.coinType)
+	if err != nil {
+		return nil, err
+	}
+	var gasPrice hexutil.Big
+	if _, err := client.Call(ctx, "eth_gasPrice", nil, &gasPrice); err != nil {
+		return nil, fmt.Errorf("eth_gasPrice: %w", err)
+	}
+	scaled := new(big.Int).Mul(gasPrice.ToInt(), big.NewInt(intent.fee.gasPricePercent))
+	return scaled.Div(scaled, big.NewInt(100)), nil
+}
+
+// transferSolana signs the Solana transaction of intent through sign: an SPL transfer of the mint
+// of the asset between the associated token accounts, or a system transfer of lamports, on the
+// latest blockhash of the node. Solana fees are fixed per signature, the fee preference is unused.
+func (b *Backend) transferSolana(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	intent *transferIntent) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_transfer"))
+	if !intent.amount.IsUint64() {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: amount", helpers.ErrInvalidTransfer).Error())
+	}
+	recipient, err := solana.PublicKeyFromBase58(intent.recipient)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: recipient: %w", helpers.ErrInvalidTransfer, err).Error())
+	}
+	derivationPath := addressNextPath(lib.CurveEd25519, transferPurpose, int(intent.coinType), intent.account, 0,
+		uint32(intent.index))
+	isDev := d.Get("isDev").(bool)
+
+	userInfo, err := helpers.GetUser(ctx, req, intent.uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(intent.coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	seed, err := userSeed(ctx, userInfo)
+	if err != nil {
+		backendLogger.Error("seed from mnemonic", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	address, err := adapter.GetInventory(backendLogger).WithContext(ctx).DeriveAddress(seed, intent.coinType,
+		derivationPath, isDev)
+	if err != nil {
+		backendLogger.Error("derive address", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	owner, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	client, _, err := b.rpcClient(ctx, req.Storage, intent.coinType)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	var latest latestBlockhash
+	if _, err := client.Call(ctx, "getLatestBlockhash", []any{map[string]string{"commitment": "finalized"}},
+		&latest); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, fmt.Errorf("getLatestBlockhash: %w", err).Error())
+	}
+	blockhash, err := solana.BlockhashFromBase58(latest.Value.Blockhash)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var message []byte
+	details := map[string]interface{}{}
+	if intent.asset == "" {
+		message, err = solana.BuildSOLTransfer(owner, recipient, intent.amount.Uint64(), blockhash, intent.memo)
+	} else {
+		var mint solana.PublicKey
+		if mint, err = solana.PublicKeyFromBase58(intent.asset); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity,
+				fmt.Errorf("%w: asset must be the address of the token mint", helpers.ErrInvalidTransfer).Error())
+		}
+		var built *solana.SPLTransferMessage
+		built, err = solana.BuildSPLTransfer(solana.SPLTransfer{
+			Owner:                  owner,
+			Mint:                   mint,
+			Recipient:              recipient,
+			Amount:                 intent.amount.Uint64(),
+			RecentBlockhash:        blockhash,
+			TokenProgram:           d.Get("tokenProgram").(string),
+			Decimals:               intent.decimals,
+			CreateRecipientAccount: d.Get("createRecipientAccount").(bool),
+			Memo:                   intent.memo,
+		})
+		if built != nil {
+			message = built.Message
+			details["sourceAccount"] = built.SourceAccount.String()
+			details["destinationAccount"] = built.DestinationAccount.String()
+		}
+	}
+	if err != nil {
+		backendLogger.Error("build transfer", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(message)})
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// the message is signed as a sign request of its own
+	raw := map[string]interface{}{
+		"uuid":           intent.uuid,
+		"derivationPath": derivationPath,
+		"coinType":       int(intent.coinType),
+		"payload":        string(payload),
+		"isDev":          isDev,
+		"overrideFee":    d.Get("overrideFee"),
+	}
+	for _, name := range transferSignFields {
+		if value, ok := d.GetOk(name); ok {
+			raw[name] = value
+		}
+	}
+	if err := b.checkDerivationPolicy(ctx, req.Storage, "sign", raw); err != nil {
+		return nil, err
+	}
+	signReq := *req
+	signReq.Path, signReq.Data = "sign", raw
+	resp, err := b.signOp(false)(ctx, &signReq, &framework.FieldData{Raw: raw, Schema: b.Route("sign").Fields})
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	for name, value := range details {
+		resp.Data[name] = value
+	}
+	resp.Data["recentBlockhash"] = latest.Value.Blockhash
+	resp.Data["lastValidBlockHeight"] = latest.Value.LastValidBlockHeight
+	if signature, ok := resp.Data["signature"].(string); ok {
+		signed, err := hex.DecodeString(signature)
+		if err != nil || len(signed) < 1+64 {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidSignedTx.Error())
+		}
+		// the transaction id is the first signature, after its compact-u16 count
+		resp.Data["rawTx"] = signature
+		resp.Data["txHash"] = base58.Encode(signed[1 : 1+64])
+	}
+	return resp, nil
+}
+
+// transferBitcoin signs the Bitcoin transaction of intent through build/btc-tx, spending the given
+// UTXOs with the change to the internal chain of the account. Without a feeRate, the fee rate is
+// the estimate of the node for the confirmation target of the fee preference.
+func (b *Backend) transferBitcoin(ctx context.Context, req *logical.Request, d *framework.FieldData,
+	intent *transferIntent) (*logical.Response, error) {
+	if intent.asset != "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: bitcoin has no assets", helpers.ErrInvalidTransfer).Error())
+	}
+	if intent.memo != "" {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrMemoUnsupported.Error())
+	}
+	if !intent.amount.IsInt64() {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: amount", helpers.ErrInvalidTransfer).Error())
+	}
+
+	feeRate := d.Get("feeRate").(float64)
+	if feeRate == 0 {
+		var err error
+		if feeRate, err = b.transferFeeRate(ctx, req.Storage, intent); err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+	// the test network coin type signs testnet transactions
+	isDev := d.Get("isDev").(bool) || intent.coinType == slip44.TestNet
+	var utxos []bitcoin.UTXO
+	if err := decodeBTCTxList(d.Get("utxos"), &utxos); err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrInvalidBTCTxRequest.Error())
+	}
+	if err := checkTransferUTXOs(utxos, bitcoinCoinType(isDev), intent.account); err != nil {
+		if errors.Is(err, helpers.ErrCoinTypeNotAllowed) {
+			return nil, helpers.CodedError(http.StatusForbidden, err)
+		}
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	raw := map[string]interface{}{
+		"uuid":        intent.uuid,
+		"utxos":       d.Get("utxos"),
+		"outputs":     []interface{}{map[string]interface{}{"address": intent.recipient, "value": intent.amount.Int64()}},
+		"feeRate":     feeRate,
+		"account":     intent.account,
+		"isDev":       isDev,
+		"overrideFee": d.Get("overrideFee"),
+	}
+	schema := b.Route("build/btc-tx").Fields
+	for _, name := range transferSignFields {
+		if _, ok := schema[name]; !ok {
+			continue
+		}
+		if value, ok := d.GetOk(name); ok {
+			raw[name] = value
+		}
+	}
+	if err := b.checkDerivationPolicy(ctx, req.Storage, "build/btc-tx", raw); err != nil {
+		return nil, err
+	}
+	buildReq := *req
+	buildReq.Path, buildReq.Data = "build/btc-tx", raw
+	resp, err := b.buildBTCTxOp()(ctx, &buildReq, &framework.FieldData{Raw: raw, Schema: schema})
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	// a request held for approval has no transaction yet
+	if txid, ok := resp.Data["txid"]; ok {
+		resp.Data["txHash"] = txid
+	}
+	return resp, nil
+}
+
+// checkTransferUTXOs checks that the key of every UTXO of a Bitcoin transfer is of the account of the
+// transfer, under a Bitcoin purpose and coinType
+func checkTransferUTXOs(utxos []bitcoin.UTXO, coinType uint16, account int) error {
+	for _, utxo := range utxos {
+		if err := helpers.CheckCoinTypeLevel(utxo.Path, coinType); err != nil {
+			return err
+		}
+		components, err := lib.ParseDerivationPath(utxo.Path)
+		if err != nil {
+			return err
+		}
+		if len(components) < helpers.AccountDepth || components[0] < lib.HardenedOffset ||
+			!slices.Contains(bitcoinPurposes, components[0]-lib.HardenedOffset) ||
+			components[helpers.AccountDepth-1] != lib.HardenedOffset+uint32(account) {
+			return fmt.Errorf("%w: utxo path %s is not of account %d", helpers.ErrInvalidTransfer, utxo.Path, account)
+		}
+	}
+	return nil
+}
+
+// estimateSmartFee is the result of the bitcoind estimatesmartfee call, the fee rate in BTC/kvB
+type estimateSmartFee struct {
+	FeeRate float64  `json:"feerate"`
+	Errors  []string `json:"errors"`
+}
+
+// transferFeeRate returns the fee rate in sat/vB the node of config/rpc estimates for the
+// confirmation target of the fee preference
+func (b *Backend) transferFeeRate(ctx context.Context, s logical.Storage, intent *transferIntent) (float64,
+	error) {
+	client, _, err := b.rpcClient(ctx, s, intent.coinType)
+	if err != nil {
+		return 0, err
+	}
+	var estimate estimateSmartFee
+	if _, err := client.Call(ctx, "estimatesmartfee", []any{intent.fee.confTarget}, &estimate); err != nil {
+		return 0, fmt.Errorf("estimatesmartfee: %w", err)
+	}
+	if !(estimate.FeeRate > 0) {
+		return 0, fmt.Errorf("%w: %v", helpers.ErrNoFeeEstimate, estimate.Errors)
+	}
+	return estimate.FeeRate * satPerVBytePerBTCPerKvB, nil
+}
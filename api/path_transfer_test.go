@@ -0,0 +1,262 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/approval"
+)
+
+func TestBackend_HandleRequest_Transfer(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	calls := map[string][]interface{}{}
+	node := newCompleteTestNode(t, map[string]string{
+		"eth_chainId":             `"0x1"`,
+		"eth_getTransactionCount": `"0x7"`,
+		"eth_gasPrice":            `"0x3b9aca00"`,
+		"eth_estimateGas":         `"0xea60"`,
+		"getLatestBlockhash": `{"value":{"blockhash":"EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N",` +
+			`"lastValidBlockHeight":100}}`,
+		"estimatesmartfee": `{"feerate":0.00005,"blocks":6}`,
+	}, calls)
+	s := newCompleteTestStorage(t, 60, node.URL)
+	for _, coinType := range []uint16{501, 0} {
+		require.NoError(t, helpers.PutRPCEndpoint(ctx, s, &helpers.RPCEndpoint{
+			CoinType: coinType, URL: node.URL, Timeout: time.Second,
+		}))
+	}
+	transfer := func(data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		data["uuid"] = signTestUUID
+		return b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: "transfer", Storage: s,
+			Data: data})
+	}
+	evmTx := func(t *testing.T, resp *logical.Response) *types.Transaction {
+		t.Helper()
+		var tx types.Transaction
+		require.NoError(t, tx.UnmarshalBinary(common.FromHex(resp.Data["rawTx"].(string))))
+		assert.Equal(t, tx.Hash().Hex(), resp.Data["txHash"])
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+		require.NoError(t, err)
+		assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", sender.Hex())
+		return &tx
+	}
+
+	t.Run("evm native coin at the fee preference", func(t *testing.T) {
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "0.001",
+			"feePreference": "high",
+		})
+		require.NoError(t, err)
+		tx := evmTx(t, resp)
+		assert.Equal(t, "1000000000000000", tx.Value().String())
+		assert.Equal(t, int64(1_300_000_000), tx.GasPrice().Int64())
+		assert.Equal(t, uint64(7), tx.Nonce())
+		assert.Equal(t, common.HexToAddress("0x742d35cc6634c0532925a3b8d359a5c5119e32c8"), *tx.To())
+		assert.Equal(t, map[string]interface{}{
+			"coinType": uint16(60), "asset": "", "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8",
+			"symbol": "", "amount": "1000000000000000", "feePreference": "high", "memo": "",
+		}, resp.Data["intent"])
+	})
+
+	t.Run("erc-20 asset", func(t *testing.T) {
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "2.5",
+			"asset": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "decimals": 6, "index": 1,
+		})
+		require.NoError(t, err)
+		var tx types.Transaction
+		require.NoError(t, tx.UnmarshalBinary(common.FromHex(resp.Data["rawTx"].(string))))
+		assert.Equal(t, "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", tx.To().Hex())
+		assert.Equal(t, int64(0), tx.Value().Int64())
+		assert.Equal(t, int64(1_100_000_000), tx.GasPrice().Int64())
+		assert.Equal(t, "a9059cbb000000000000000000000000742d35cc6634c0532925a3b8d359a5c5119e32c8"+
+			"00000000000000000000000000000000000000000000000000000000002625a0", hex.EncodeToString(tx.Data()))
+		// the second address of the account signs
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), &tx)
+		require.NoError(t, err)
+		assert.NotEqual(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", sender.Hex())
+	})
+
+	t.Run("asset of config/assets named by its symbol", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: "config/assets/60/USDC",
+			Storage: s, Data: map[string]interface{}{"address": "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", "decimals": 6}})
+		require.NoError(t, err)
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "2.5", "asset": "usdc",
+		})
+		require.NoError(t, err)
+		var tx types.Transaction
+		require.NoError(t, tx.UnmarshalBinary(common.FromHex(resp.Data["rawTx"].(string))))
+		assert.Equal(t, "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", tx.To().Hex())
+		assert.True(t, strings.HasSuffix(hex.EncodeToString(tx.Data()), "2625a0"))
+		intent := resp.Data["intent"].(map[string]interface{})
+		assert.Equal(t, "USDC", intent["symbol"])
+		assert.Equal(t, "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", intent["asset"])
+
+		_, err = transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "2.5",
+			"asset": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "decimals": 18,
+		})
+		require.ErrorContains(t, err, helpers.ErrAssetDecimals.Error())
+	})
+
+	t.Run("solana native coin on the latest blockhash", func(t *testing.T) {
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 501, "recipient": "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM", "amount": "0.0015",
+		})
+		require.NoError(t, err)
+		signed, err := hex.DecodeString(resp.Data["rawTx"].(string))
+		require.NoError(t, err)
+		signature, message := signed[1:1+ed25519.SignatureSize], signed[1+ed25519.SignatureSize:]
+		assert.Equal(t, base58.Encode(signature), resp.Data["txHash"])
+		assert.Equal(t, "0200000060e3160000000000", hex.EncodeToString(message[len(message)-12:]))
+		assert.Equal(t, "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N", resp.Data["recentBlockhash"])
+	})
+
+	t.Run("solana memo of the recipient", func(t *testing.T) {
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 501, "recipient": "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM", "amount": "0.0015",
+			"memo": "deposit 104528",
+		})
+		require.NoError(t, err)
+		signed, err := hex.DecodeString(resp.Data["rawTx"].(string))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"deposit 104528"}, solana.Memos(signed[1+ed25519.SignatureSize:]))
+		assert.Equal(t, "deposit 104528", resp.Data["intent"].(map[string]interface{})["memo"])
+		assert.Equal(t, "deposit 104528", resp.Data["receipt"].(map[string]interface{})["memo"])
+
+		_, err = transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "1", "memo": "104528",
+		})
+		require.ErrorContains(t, err, helpers.ErrMemoUnsupported.Error())
+	})
+
+	t.Run("bitcoin at the estimate of the fee preference", func(t *testing.T) {
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 0, "recipient": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "amount": "60000",
+			"unit": "sat", "utxos": []interface{}{map[string]interface{}{
+				"txid": strings.Repeat("ab", 32), "vout": 1, "value": 100_000, "path": "m/84'/0'/0'/0/0",
+			}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{float64(6)}, calls["estimatesmartfee"])
+		assert.Equal(t, resp.Data["txid"], resp.Data["txHash"])
+		assert.GreaterOrEqual(t, resp.Data["feeRate"], 5.0)
+		raw, err := hex.DecodeString(resp.Data["rawTx"].(string))
+		require.NoError(t, err)
+		tx := wire.NewMsgTx(2)
+		require.NoError(t, tx.Deserialize(bytes.NewReader(raw)))
+		assert.Equal(t, int64(60_000), tx.TxOut[0].Value)
+	})
+
+	t.Run("bitcoin transfers over the approval threshold are held", func(t *testing.T) {
+		webhook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		defer webhook.Close()
+		_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: "config/approvals",
+			Storage: s, Data: map[string]interface{}{
+				"thresholds": map[string]interface{}{"0": "50000"}, "provider": approval.ProviderSlack,
+				"webhookUrl": webhook.URL,
+			}})
+		require.NoError(t, err)
+		defer func() {
+			_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.DeleteOperation, Path: "config/approvals",
+				Storage: s})
+			require.NoError(t, err)
+		}()
+
+		resp, err := transfer(map[string]interface{}{
+			"coinType": 0, "recipient": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "amount": "60000",
+			"unit": "sat", "feeRate": 5, "utxos": []interface{}{map[string]interface{}{
+				"txid": strings.Repeat("ab", 32), "vout": 1, "value": 100_000, "path": "m/84'/0'/0'/0/0",
+			}},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Data["approvalId"])
+		assert.Equal(t, "60000", resp.Data["summary"].(map[string]interface{})["value"])
+		assert.NotContains(t, resp.Data, "rawTx")
+		assert.NotContains(t, resp.Data, "txHash")
+	})
+
+	t.Run("bitcoin utxos are spent from the account of the transfer only", func(t *testing.T) {
+		spend := func(path string, data map[string]interface{}) error {
+			data["coinType"], data["recipient"] = 0, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"
+			data["amount"], data["unit"], data["feeRate"] = "60000", "sat", 5
+			data["utxos"] = []interface{}{map[string]interface{}{
+				"txid": strings.Repeat("ab", 32), "vout": 1, "value": 100_000, "path": path,
+			}}
+			_, err := transfer(data)
+			return err
+		}
+		require.ErrorContains(t, spend("m/84'/0'/1'/0/0", map[string]interface{}{}), helpers.ErrInvalidTransfer.Error())
+		require.ErrorContains(t, spend("m/84'/0'/0'/0/0", map[string]interface{}{"account": 1}),
+			helpers.ErrInvalidTransfer.Error())
+		require.ErrorContains(t, spend("m/45'/0'/0'/0/0", map[string]interface{}{}), helpers.ErrInvalidTransfer.Error())
+		require.ErrorIs(t, spend("m/84'/60'/0'/0/0", map[string]interface{}{}), helpers.ErrCoinTypeNotAllowed)
+		require.ErrorIs(t, spend("m/84'/0'/0'/0/0", map[string]interface{}{"isDev": true}), helpers.ErrCoinTypeNotAllowed)
+
+		_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: "config/derivation",
+			Storage: s, Data: map[string]interface{}{"maxDepth": 4}})
+		require.NoError(t, err)
+		defer func() {
+			_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.DeleteOperation, Path: "config/derivation",
+				Storage: s})
+			require.NoError(t, err)
+		}()
+		require.ErrorIs(t, spend("m/84'/0'/0'/0/0", map[string]interface{}{}), helpers.ErrDerivationTooDeep)
+	})
+
+	t.Run("invalid intents", func(t *testing.T) {
+		_, err := transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "1",
+			"feePreference": "urgent",
+		})
+		require.ErrorContains(t, err, helpers.ErrInvalidFeePref.Error())
+		_, err = transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "1",
+			"asset": "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		})
+		require.ErrorContains(t, err, helpers.ErrInvalidTransfer.Error())
+		_, err = transfer(map[string]interface{}{"coinType": 60, "recipient": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+			"amount": "1"})
+		require.ErrorContains(t, err, helpers.ErrInvalidTransfer.Error())
+		_, err = transfer(map[string]interface{}{"coinType": 966, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8",
+			"amount": "1"})
+		require.ErrorContains(t, err, helpers.ErrNoRPCEndpoint.Error())
+	})
+
+	t.Run("the policies of the signing path apply", func(t *testing.T) {
+		_, err := b.HandleRequest(ctx, &logical.Request{Operation: logical.UpdateOperation, Path: "config/fees/60",
+			Storage: s, Data: map[string]interface{}{"min": 1, "max": 1}})
+		require.NoError(t, err)
+		// the gas price of the node is 1 gwei, 1.1 gwei at the medium preference
+		_, err = transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "1",
+		})
+		require.ErrorContains(t, err, helpers.ErrFeeOutOfBounds.Error())
+		_, err = transfer(map[string]interface{}{
+			"coinType": 60, "recipient": "0x742d35cc6634c0532925a3b8d359a5c5119e32c8", "amount": "1",
+			"feePreference": "low",
+		})
+		require.NoError(t, err)
+	})
+}
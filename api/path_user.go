@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+const (
+	// expiredUserRetention is how long users are kept disabled once their ttl ended, before being purged
+	expiredUserRetention = 30 * 24 * time.Hour
+	// pendingRegistrationTTL is how long register/confirm may activate a user of register/init, after
+	// which the pending user is purged
+	pendingRegistrationTTL = 24 * time.Hour
+)
+
+// pathListUsers corresponds to LIST user/.
+func (b *Backend) pathListUsers(ctx context.Context, req *logical.Request,
+	_ *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_list_users"))
+
+	uuids, err := req.Storage.List(ctx, config.StorageBasePath)
+	if err != nil {
+		backendLogger.Error("list users", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return sortedListResponse(uuids), nil
+}
+
+// pathReadUser corresponds to READ user/<uuid>. Only non-sensitive fields are returned.
+func (b *Backend) pathReadUser(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_user"))
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+
+	// records migrated from the legacy schema have no stored fingerprint yet
+	if user.Fingerprint == "" && !user.WatchOnly() {
+		seed, err := userSeed(ctx, user)
+		if err != nil {
+			backendLogger.Error("seed from mnemonic", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if user.Fingerprint, err = lib.MasterFingerprint(seed); err != nil {
+			backendLogger.Error("master fingerprint", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	return &logical.Response{
+		Data: userResponseData(user),
+	}, nil
+}
+
+// pathDeleteUser corresponds to DELETE user/<uuid>. The user is purged with the records bound to it.
+func (b *Backend) pathDeleteUser(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_delete_user"))
+
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if errors.Is(err, helpers.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if err := user.AuthorizeManagement(req.EntityID); err != nil {
+		backendLogger.Warn("user deletion rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if _, ok := d.GetOk("version"); ok {
+		if err := checkUserVersion(d, user); err != nil {
+			backendLogger.Warn("user deletion rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+			return nil, err
+		}
+	}
+
+	if err := purgeUser(ctx, req.Storage, uuid); err != nil {
+		backendLogger.Error("purge user", "error", err, "uuid", uuid)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("user deleted", "uuid", uuid, "entity", req.EntityID)
+	return nil, nil
+}
+
+// pathSetUserStatus returns the handler of UPDATE user/<uuid>/disable and user/<uuid>/enable,
+// setting the status of the user to status
+func (b *Backend) pathSetUserStatus(status string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		backendLogger := b.logger.With(slog.String("op", "path_set_user_status"), slog.String("status", status))
+
+		b.userWriteMu.Lock()
+		defer b.userWriteMu.Unlock()
+
+		uuid := d.Get("uuid").(string)
+		user, err := helpers.GetUser(ctx, req, uuid)
+		if err != nil {
+			backendLogger.Error("get user", "error", err)
+			return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+		}
+		if err := user.AuthorizeManagement(req.EntityID); err != nil {
+			backendLogger.Warn("user status change rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+			return nil, logical.CodedError(http.StatusForbidden, err.Error())
+		}
+		if err := checkUserVersion(d, user); err != nil {
+			backendLogger.Warn("user status change rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+			return nil, err
+		}
+
+		if user.Status == helpers.UserStatusPending {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUserPending.Error())
+		}
+		if user.Status == helpers.UserStatusLocked {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUserLocked.Error())
+		}
+		now := time.Now()
+		if status == helpers.UserStatusActive && user.Expired(now) {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUserExpired.Error())
+		}
+		if user.Status != status {
+			user.Status, user.UpdatedAt = status, now.UTC()
+			if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
+				backendLogger.Error("put user", "error", err)
+				return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+			}
+			backendLogger.Info("user status changed", "uuid", uuid, "entity", req.EntityID)
+		}
+
+		return &logical.Response{
+			Data: userResponseData(user),
+		}, nil
+	}
+}
+
+// checkUserVersion checks the version field of a request changing user against the version of the
+// record, so changes made from a stale read of the user are rejected with 409; errors are coded
+func checkUserVersion(d *framework.FieldData, user *helpers.User) error {
+	version, ok := d.GetOk("version")
+	if !ok {
+		return logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrVersionRequired.Error())
+	}
+	if version.(int) < 0 || uint64(version.(int)) != user.Version {
+		return logical.CodedError(http.StatusConflict,
+			fmt.Errorf("%w: the current version is %d", helpers.ErrStaleVersion, user.Version).Error())
+	}
+	return nil
+}
+
+func userResponseData(user *helpers.User) map[string]interface{} {
+	allowedCoinTypes := user.AllowedCoinTypes
+	if allowedCoinTypes == nil {
+		allowedCoinTypes = []uint16{}
+	}
+	managers := user.ManagerEntityIDs
+	if managers == nil {
+		managers = []string{}
+	}
+	data := map[string]interface{}{
+		"uuid":               user.UUID,
+		"username":           user.Username,
+		"schemaVersion":      user.SchemaVersion,
+		"version":            user.Version,
+		"status":             user.Status,
+		"fingerprint":        user.Fingerprint,
+		"allowedCoinTypes":   allowedCoinTypes,
+		"ownerEntityId":      user.OwnerEntityID,
+		"ownerDisplayName":   user.OwnerDisplayName,
+		"restrictToOwner":    user.RestrictToOwner,
+		"ownerAccessor":      user.OwnerAccessor,
+		"restrictManagement": user.RestrictManagement,
+		"managerEntityIds":   managers,
+		"createdAt":          formatTime(user.CreatedAt),
+		"updatedAt":          formatTime(user.UpdatedAt),
+		"expiresAt":          formatTime(user.ExpiresAt),
+		"watchOnly":          user.WatchOnly(),
+		"xpubPath":           user.XpubPath,
+	}
+	if user.NamespaceIndex != nil {
+		data["namespaceIndex"] = *user.NamespaceIndex
+	}
+	if !user.LockedAt.IsZero() {
+		data["lockedAt"] = formatTime(user.LockedAt)
+	}
+	return data
+}
+
+// formatTime formats t as RFC 3339, or returns an empty string when t is unknown
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// expireUsers disables the users whose ttl ended before now, and purges them with their multisig
+// wallets and debug session once expiredUserRetention passed. Pending users not confirmed within
+// pendingRegistrationTTL are purged.
+func (b *Backend) expireUsers(ctx context.Context, s logical.Storage, now time.Time) error {
+	uuids, err := s.List(ctx, config.StorageBasePath)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, uuid := range uuids {
+		if err := b.expireUser(ctx, s, uuid, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", uuid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expireUser disables or purges the user uuid for expireUsers, under userWriteMu
+func (b *Backend) expireUser(ctx context.Context, s logical.Storage, uuid string, now time.Time) error {
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, uuid)
+	if err != nil {
+		return err
+	}
+	if user.Status == helpers.UserStatusPending && !now.Before(user.CreatedAt.Add(pendingRegistrationTTL)) {
+		if err := purgeUser(ctx, s, uuid); err != nil {
+			return err
+		}
+		b.logger.Info("unconfirmed registration purged", "uuid", uuid, "createdAt", user.CreatedAt)
+		return nil
+	}
+	if !user.Expired(now) {
+		return nil
+	}
+
+	if !now.Before(user.ExpiresAt.Add(expiredUserRetention)) {
+		if err := purgeUser(ctx, s, uuid); err != nil {
+			return err
+		}
+		b.logger.Info("expired user purged", "uuid", uuid, "expiresAt", user.ExpiresAt)
+		return nil
+	}
+	if user.Status != helpers.UserStatusActive {
+		return nil
+	}
+
+	user.Status = helpers.UserStatusDisabled
+	user.UpdatedAt = now.UTC()
+	if err := helpers.PutUser(ctx, s, user); err != nil {
+		return err
+	}
+	b.logger.Info("user expired", "uuid", uuid, "expiresAt", user.ExpiresAt)
+	return nil
+}
+
+// purgeUser removes the record of the user uuid with its multisig wallets, debug session, address ledger,
+// failed backup verifications, policy violations, escrow record and budgets
+func purgeUser(ctx context.Context, s logical.Storage, uuid string) error {
+	names, err := s.List(ctx, config.MultisigStoragePath+uuid+"/")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := helpers.DeleteMultisigWallet(ctx, s, uuid, name); err != nil {
+			return err
+		}
+	}
+	if err := helpers.DeleteDebugSession(ctx, s, uuid); err != nil {
+		return err
+	}
+	if err := helpers.DeleteAddressLedger(ctx, s, uuid); err != nil {
+		return err
+	}
+	if err := helpers.DeleteBackupVerification(ctx, s, uuid); err != nil {
+		return err
+	}
+	if err := helpers.DeleteUserViolations(ctx, s, uuid); err != nil {
+		return err
+	}
+	if err := helpers.DeleteEscrowRecord(ctx, s, uuid); err != nil {
+		return err
+	}
+	if err := helpers.DeleteCanaryResult(ctx, s, uuid); err != nil {
+		return err
+	}
+	budgets, err := s.List(ctx, config.BudgetsStoragePath+uuid+"/")
+	if err != nil {
+		return err
+	}
+	for _, coinType := range budgets {
+		if err := s.Delete(ctx, config.BudgetsStoragePath+uuid+"/"+coinType); err != nil {
+			return err
+		}
+	}
+	return s.Delete(ctx, config.StorageBasePath+uuid)
+}
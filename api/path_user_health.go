@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+// pathUserHealth corresponds to READ user/<uuid>/health. It reports the issues of a user: its
+// status, and a passphrase weaker than config/passphrase or not matching its verifier. The
+// passphrase itself is never returned.
+func (b *Backend) pathUserHealth(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_user_health"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	issues := []string{}
+	if user.Status != helpers.UserStatusActive {
+		issues = append(issues, helpers.HealthUserNotActive)
+	}
+	data := map[string]interface{}{
+		"uuid":   uuid,
+		"status": user.Status,
+	}
+
+	// watch-only users have no passphrase to check
+	if !user.WatchOnly() {
+		policy, err := helpers.GetPassphrasePolicy(ctx, req.Storage)
+		if err != nil {
+			backendLogger.Error("get passphrase policy", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		health, err := user.PassphraseHealth(policy)
+		if err != nil {
+			backendLogger.Error("check passphrase", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		issues = append(issues, health.Issues...)
+		data["passphrase"] = map[string]interface{}{
+			"length":      health.Length,
+			"entropyBits": health.EntropyBits,
+			"meetsPolicy": health.MeetsPolicy,
+			"verifier":    health.Verifier,
+			"verified":    health.Verified,
+		}
+	}
+
+	if len(issues) > 0 {
+		backendLogger.Warn("user health issues", "uuid", uuid, "issues", issues)
+	}
+	data["healthy"] = len(issues) == 0
+	data["issues"] = issues
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+	"github.com/payment-system/dq-vault/lib/logging"
+)
+
+// policyViolations are the rejections by the policies of the mount noted as violations of the user
+// of the request, with their kind; the first the error wraps is noted. The entity restrictions of a
+// user are not among them: the entity is not the user's to answer for.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var policyViolations = []struct {
+	kind string
+	err  error
+}{
+	{"coinType", helpers.ErrCoinTypeNotAllowed},
+	{"derivation", helpers.ErrNonHardenedAboveAccount},
+	{"derivation", helpers.ErrDerivationTooDeep},
+	{"addressOverride", helpers.ErrAddressOverrideNotAllowed},
+	{"addressBook", helpers.ErrRecipientNotInBook},
+	{"contract", helpers.ErrContractNotRegistered},
+	{"fee", helpers.ErrFeeOutOfBounds},
+	{"budget", helpers.ErrBudgetExceeded},
+	{"approval", helpers.ErrApprovalMismatch},
+}
+
+// violationKind returns the kind of policy violation err is, or an empty string for other errors
+func violationKind(err error) string {
+	for _, violation := range policyViolations {
+		if errors.Is(err, violation.err) {
+			return violation.kind
+		}
+	}
+	return ""
+}
+
+// recordViolation notes the failure of req with err as a violation of its user, the user of the
+// session of its sessionToken for session/sign, as recordUserViolation does
+func (b *Backend) recordViolation(ctx context.Context, req *logical.Request, err error) {
+	if err == nil || req.Storage == nil {
+		return
+	}
+	uuid, _ := req.Data["uuid"].(string)
+	if token, ok := req.Data["sessionToken"].(string); ok && uuid == "" {
+		if session, err := helpers.LookupSigningSession(ctx, req.Storage, token); err == nil {
+			uuid = session.UUID
+		}
+	}
+	b.recordUserViolation(ctx, req, uuid, err)
+}
+
+// recordUserViolation notes the failure of req with err as a violation of the user uuid when a
+// policy rejected it, and locks the user reaching the maxViolations of config/lockout within the
+// window. The request failed anyway: errors are logged, and a standby leaves the noting to the
+// active node.
+func (b *Backend) recordUserViolation(ctx context.Context, req *logical.Request, uuid string, err error) {
+	if err == nil || req.Storage == nil || uuid == "" {
+		return
+	}
+	kind := violationKind(err)
+	if kind == "" {
+		return
+	}
+
+	event, err := b.noteViolation(ctx, req, uuid, helpers.ViolationNote{
+		At:       time.Now().UTC(),
+		Kind:     kind,
+		Path:     req.Path,
+		EntityID: req.EntityID,
+		Error:    logging.RedactString(err.Error()),
+	})
+	if err != nil {
+		b.logger.Error("record policy violation", "error", err, "uuid", uuid, "kind", kind)
+		return
+	}
+	if event != nil {
+		b.queueEvent(ctx, req.Storage, event)
+	}
+}
+
+// noteViolation stores note for the user uuid under userWriteMu, returning the lockout event when
+// it locked the user. Unknown users have nothing to note.
+func (b *Backend) noteViolation(ctx context.Context, req *logical.Request, uuid string,
+	note helpers.ViolationNote) (*eventsink.Event, error) {
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if errors.Is(err, helpers.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	violations, err := helpers.GetUserViolations(ctx, req.Storage, uuid)
+	if err != nil {
+		return nil, err
+	}
+	violations.Add(note)
+	if err := helpers.PutUserViolations(ctx, req.Storage, uuid, violations); err != nil {
+		return nil, err
+	}
+	b.logger.Warn("policy violation", "uuid", uuid, "kind", note.Kind, "path", note.Path, "entity", note.EntityID)
+
+	policy, err := helpers.GetLockoutPolicy(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	count := violations.Count(policy, note.At)
+	if policy.MaxViolations == 0 || count < policy.MaxViolations || user.Status != helpers.UserStatusActive {
+		return nil, nil
+	}
+
+	user.Status, user.LockedAt, user.UpdatedAt = helpers.UserStatusLocked, note.At, note.At
+	if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
+		return nil, err
+	}
+	b.logger.Warn("user locked", "uuid", uuid, "violations", count, "window", policy.Window)
+
+	return &eventsink.Event{
+		Type:     eventsink.TypeLockout,
+		Path:     note.Path,
+		UUID:     uuid,
+		EntityID: note.EntityID,
+		Data:     map[string]interface{}{"violations": count, "kind": note.Kind},
+	}, nil
+}
+
+// pathReadViolations corresponds to READ user/<uuid>/violations. The notes are returned oldest
+// first, with the number counting towards the lockout of config/lockout.
+func (b *Backend) pathReadViolations(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_read_violations"))
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	violations, err := helpers.GetUserViolations(ctx, req.Storage, uuid)
+	if err != nil {
+		backendLogger.Error("get user violations", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	policy, err := helpers.GetLockoutPolicy(ctx, req.Storage)
+	if err != nil {
+		backendLogger.Error("get lockout policy", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	notes := make([]map[string]interface{}, 0, len(violations.Notes))
+	for _, note := range violations.Notes {
+		notes = append(notes, map[string]interface{}{
+			"at":       formatTime(note.At),
+			"kind":     note.Kind,
+			"path":     note.Path,
+			"entityId": note.EntityID,
+			"error":    note.Error,
+		})
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"notes":         notes,
+			"count":         violations.Count(policy, time.Now()),
+			"maxViolations": policy.MaxViolations,
+			"locked":        user.Status == helpers.UserStatusLocked,
+			"lockedAt":      formatTime(user.LockedAt),
+		},
+	}, nil
+}
+
+// pathUnlockUser corresponds to UPDATE user/<uuid>/unlock, a root path requiring sudo on top of
+// update. The user is active again and its violations noted so far no longer count.
+func (b *Backend) pathUnlockUser(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_unlock_user"))
+
+	b.userWriteMu.Lock()
+	defer b.userWriteMu.Unlock()
+
+	uuid := d.Get("uuid").(string)
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeManagement(req.EntityID); err != nil {
+		backendLogger.Warn("user unlock rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if err := checkUserVersion(d, user); err != nil {
+		backendLogger.Warn("user unlock rejected", "error", err, "uuid", uuid, "entity", req.EntityID)
+		return nil, err
+	}
+
+	if user.Status != helpers.UserStatusLocked {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUserNotLocked.Error())
+	}
+	now := time.Now()
+	if user.Expired(now) {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUserExpired.Error())
+	}
+
+	violations, err := helpers.GetUserViolations(ctx, req.Storage, uuid)
+	if err != nil {
+		backendLogger.Error("get user violations", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	violations.CountFrom = now.UTC()
+	if err := helpers.PutUserViolations(ctx, req.Storage, uuid, violations); err != nil {
+		backendLogger.Error("put user violations", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	user.Status, user.LockedAt, user.UpdatedAt = helpers.UserStatusActive, time.Time{}, now.UTC()
+	if err := helpers.PutUser(ctx, req.Storage, user); err != nil {
+		backendLogger.Error("put user", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("user unlocked", "uuid", uuid, "entity", req.EntityID)
+
+	return &logical.Response{
+		Data: userResponseData(user),
+	}, nil
+}
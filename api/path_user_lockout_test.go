@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/eventsink"
+)
+
+func TestBackend_HandleRequest_Lockout(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := newXpubTestStorage(t)
+	publisher := &fakePublisher{}
+	b.newPublisher = func(eventsink.KafkaOptions) (eventsink.Publisher, error) { return publisher, nil }
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation, Path: path, Storage: s, Data: data, EntityID: "entity-1",
+		})
+	}
+	address := func(derivationPath string) error {
+		t.Helper()
+		_, err := request(logical.UpdateOperation, "address", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "derivationPath": derivationPath,
+		})
+		return err
+	}
+	violations := func() map[string]interface{} {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, "user/"+signTestUUID+"/violations", nil)
+		require.NoError(t, err)
+		return resp.Data
+	}
+	user := func() map[string]interface{} {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, "user/"+signTestUUID, nil)
+		require.NoError(t, err)
+		return resp.Data
+	}
+
+	_, err := request(logical.UpdateOperation, "config/kafka", map[string]interface{}{
+		"brokers": "kafka-1:9092", "topic": "events",
+	})
+	require.NoError(t, err)
+	_, err = request(logical.UpdateOperation, "config/derivation", map[string]interface{}{"hardenedAccount": true})
+	require.NoError(t, err)
+
+	t.Run("invalid policies are rejected", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "config/lockout", map[string]interface{}{"maxViolations": -1})
+		require.ErrorContains(t, err, helpers.ErrInvalidLockout.Error())
+		_, err = request(logical.UpdateOperation, "config/lockout", map[string]interface{}{
+			"maxViolations": 2, "window": 0,
+		})
+		require.ErrorContains(t, err, helpers.ErrInvalidLockout.Error())
+	})
+
+	t.Run("violations are only noted by default", func(t *testing.T) {
+		resp, err := request(logical.ReadOperation, "config/lockout", nil)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"maxViolations": 0, "window": int64(3600)}, resp.Data)
+
+		require.ErrorContains(t, address("m/44'/60'/0/0/0"), helpers.ErrNonHardenedAboveAccount.Error())
+		// other errors are not violations
+		require.Error(t, address("m/44'/60'/0'/0/x"))
+
+		data := violations()
+		notes := data["notes"].([]map[string]interface{})
+		require.Len(t, notes, 1)
+		assert.Equal(t, "derivation", notes[0]["kind"])
+		assert.Equal(t, "address", notes[0]["path"])
+		assert.Equal(t, "entity-1", notes[0]["entityId"])
+		assert.Contains(t, notes[0]["error"], helpers.ErrNonHardenedAboveAccount.Error())
+		assert.Equal(t, 1, data["count"])
+		assert.Equal(t, false, data["locked"])
+		assert.Equal(t, helpers.UserStatusActive, user()["status"])
+	})
+
+	t.Run("users reaching maxViolations are locked", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "config/lockout", map[string]interface{}{
+			"maxViolations": 3, "window": "10m",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"maxViolations": 3, "window": int64(600)}, resp.Data)
+
+		require.ErrorContains(t, address("m/44'/60'/0/0/1"), helpers.ErrNonHardenedAboveAccount.Error())
+		assert.Equal(t, helpers.UserStatusActive, user()["status"])
+		require.ErrorContains(t, address("m/44'/60'/0/0/2"), helpers.ErrNonHardenedAboveAccount.Error())
+
+		data := user()
+		assert.Equal(t, helpers.UserStatusLocked, data["status"])
+		assert.NotEmpty(t, data["lockedAt"])
+		assert.Equal(t, true, violations()["locked"])
+		require.ErrorContains(t, address(signTestDerivationPath), helpers.ErrUserNotActive.Error())
+
+		var lockouts []eventsink.Event
+		for _, event := range publisher.published() {
+			if event.Type == eventsink.TypeLockout {
+				lockouts = append(lockouts, event)
+			}
+		}
+		require.Len(t, lockouts, 1)
+		assert.Equal(t, signTestUUID, lockouts[0].UUID)
+		assert.Equal(t, "address", lockouts[0].Path)
+		assert.Equal(t, "entity-1", lockouts[0].EntityID)
+		// the queued events are decoded back from the storage
+		assert.Equal(t, map[string]interface{}{"violations": json.Number("3"), "kind": "derivation"}, lockouts[0].Data)
+	})
+
+	t.Run("enable does not unlock", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/enable",
+			map[string]interface{}{"version": user()["version"]})
+		require.ErrorContains(t, err, helpers.ErrUserLocked.Error())
+	})
+
+	t.Run("unlock is a root path", func(t *testing.T) {
+		assert.Equal(t, []string{"user/+/unlock"}, b.SpecialPaths().Root)
+	})
+
+	t.Run("unlock activates the user", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/unlock", map[string]interface{}{"version": 0})
+		require.ErrorContains(t, err, helpers.ErrStaleVersion.Error())
+
+		resp, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/unlock",
+			map[string]interface{}{"version": user()["version"]})
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusActive, resp.Data["status"])
+		assert.NotContains(t, resp.Data, "lockedAt")
+		require.NoError(t, address(signTestDerivationPath))
+
+		// the violations before the unlock no longer count
+		data := violations()
+		assert.Len(t, data["notes"], 3)
+		assert.Equal(t, 0, data["count"])
+		require.ErrorContains(t, address("m/44'/60'/0/0/3"), helpers.ErrNonHardenedAboveAccount.Error())
+		assert.Equal(t, helpers.UserStatusActive, user()["status"])
+
+		_, err = request(logical.UpdateOperation, "user/"+signTestUUID+"/unlock",
+			map[string]interface{}{"version": user()["version"]})
+		require.ErrorContains(t, err, helpers.ErrUserNotLocked.Error())
+	})
+
+	t.Run("violations are matched by the errors they wrap", func(t *testing.T) {
+		assert.Equal(t, "fee", violationKind(helpers.CodedError(http.StatusUnprocessableEntity,
+			fmt.Errorf("%w: 20 sat/vB", helpers.ErrFeeOutOfBounds))))
+		assert.Empty(t, violationKind(errors.New(helpers.ErrFeeOutOfBounds.Error())))
+		// the entity is not the user answering for the request
+		assert.Empty(t, violationKind(helpers.ErrNotOwnerEntity))
+	})
+
+	t.Run("session and batch signatures note the violations of their users", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "session/create", map[string]interface{}{
+			"uuid": signTestUUID, "coinType": 60, "pathPrefix": "m/44'/60'/0'", "maxOperations": 2, "ttl": "10m",
+		})
+		require.NoError(t, err)
+		_, err = request(logical.UpdateOperation, "session/sign", map[string]interface{}{
+			"sessionToken": resp.Data["sessionToken"], "derivationPath": "m/44'/60'/0/0/4", "payload": signTestPayload,
+		})
+		require.ErrorContains(t, err, helpers.ErrNonHardenedAboveAccount.Error())
+		resp, err = request(logical.UpdateOperation, "sign/batch", map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{
+				"uuid": signTestUUID, "coinType": 60, "derivationPath": "m/44'/60'/0/0/5", "payload": signTestPayload,
+			}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, batchItemFailed, resp.Data["results"].([]map[string]interface{})[0]["status"])
+
+		notes := violations()["notes"].([]map[string]interface{})
+		require.Len(t, notes, 6)
+		assert.Equal(t, "session/sign", notes[4]["path"])
+		assert.Equal(t, "sign/batch", notes[5]["path"])
+		assert.Equal(t, "derivation", notes[5]["kind"])
+		assert.Equal(t, helpers.UserStatusLocked, user()["status"])
+	})
+
+	t.Run("deleting the user purges its violations", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "user/"+signTestUUID, nil)
+		require.NoError(t, err)
+		entry, err := s.Get(ctx, config.ViolationsStoragePath+signTestUUID)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+}
@@ -0,0 +1,440 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// fingerprint of the "abandon ... about" mnemonic without passphrase (BIP-84 test vector)
+const userTestFingerprint = "73c5da0a"
+
+// Helper function to create a proper framework.FieldData for user/<uuid> endpoint
+func createUserFieldData(uuid string) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: map[string]interface{}{"uuid": uuid},
+		Schema: map[string]*framework.FieldSchema{
+			"uuid": {Type: framework.TypeString},
+		},
+	}
+}
+
+func createUserV2StorageEntry(t *testing.T, user *helpers.User) *logical.StorageEntry {
+	entry, err := logical.StorageEntryJSON(config.StorageBasePath+user.UUID, user)
+	require.NoError(t, err)
+	return entry
+}
+
+func TestBackend_PathReadUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("legacy record is migrated on read", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).
+			Return(createUserStorageEntrySign(signTestUUID, "test-user", signTestValidMnemonic, ""), nil)
+
+		got, err := createSignTestBackend(t).pathReadUser(ctx, &logical.Request{Storage: mockStorage},
+			createUserFieldData(signTestUUID))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"uuid":               signTestUUID,
+			"username":           "test-user",
+			"schemaVersion":      helpers.UserSchemaVersion,
+			"status":             helpers.UserStatusActive,
+			"fingerprint":        userTestFingerprint,
+			"allowedCoinTypes":   []uint16{},
+			"ownerEntityId":      "",
+			"ownerDisplayName":   "",
+			"ownerAccessor":      "",
+			"restrictToOwner":    false,
+			"restrictManagement": false,
+			"managerEntityIds":   []string{},
+			"createdAt":          "",
+			"updatedAt":          "",
+			"expiresAt":          "",
+			"watchOnly":          false,
+			"xpubPath":           "",
+			"version":            uint64(0),
+		}, got.Data)
+		mockStorage.AssertExpectations(t)
+	})
+
+	t.Run("v2 record", func(t *testing.T) {
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", []uint16{slip44.Ether})
+		require.NoError(t, err)
+
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(createUserV2StorageEntry(t, user), nil)
+
+		got, err := createSignTestBackend(t).pathReadUser(ctx, &logical.Request{Storage: mockStorage},
+			createUserFieldData(signTestUUID))
+		require.NoError(t, err)
+		assert.Equal(t, userTestFingerprint, got.Data["fingerprint"])
+		assert.Equal(t, []uint16{slip44.Ether}, got.Data["allowedCoinTypes"])
+		assert.Equal(t, user.CreatedAt.Format(time.RFC3339), got.Data["createdAt"])
+		assert.NotContains(t, got.Data, "mnemonic")
+		assert.NotContains(t, got.Data, "passphrase")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockStorage := new(MockStorageSign)
+		mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(nil, nil)
+
+		_, err := createSignTestBackend(t).pathReadUser(ctx, &logical.Request{Storage: mockStorage},
+			createUserFieldData(signTestUUID))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUserNotFound.Error())
+	})
+}
+
+func TestBackend_PathListUsers(t *testing.T) {
+	ctx := context.Background()
+	s := &logical.InmemStorage{}
+	for _, uuid := range []string{"a", "b"} {
+		user, err := helpers.NewUser(uuid, "test-user", signTestValidMnemonic, "", nil)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+	}
+
+	got, err := createSignTestBackend(t).pathListUsers(ctx, &logical.Request{Storage: s}, createUserFieldData(""))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, got.Data["keys"])
+}
+
+func TestBackend_PathRegister_StoresUserV2(t *testing.T) {
+	ctx := context.Background()
+
+	var stored helpers.User
+	mockStorage := new(MockStorageRegister)
+	mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+	mockStorage.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+	mockStorage.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
+	mockStorage.On("Put", ctx, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+		return json.Unmarshal(entry.Value, &stored) == nil
+	})).Return(nil)
+
+	data := map[string]interface{}{
+		"uuid":             regTestGeneratedUUID,
+		"mnemonic":         regTestValidMnemonic,
+		"allowedCoinTypes": []int{int(slip44.Ether), int(slip44.Solana)},
+	}
+	got, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: mockStorage, Data: data},
+		createRegisterFieldData(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, userTestFingerprint, got.Data["fingerprint"])
+	assert.Equal(t, helpers.UserSchemaVersion, stored.SchemaVersion)
+	assert.Equal(t, helpers.UserStatusActive, stored.Status)
+	assert.Equal(t, []uint16{slip44.Ether, slip44.Solana}, stored.AllowedCoinTypes)
+	assert.False(t, stored.CreatedAt.IsZero())
+	assert.Equal(t, stored.CreatedAt, stored.UpdatedAt)
+
+	t.Run("unsupported allowed coin type", func(t *testing.T) {
+		mockStorage := new(MockStorageRegister)
+		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+		mockStorage.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "allowedCoinTypes": []int{70000}}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createRegisterFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrUnsupportedCoinType.Error())
+	})
+}
+
+func TestBackend_PathSign_UserAuthorization(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		mutate  func(*helpers.User)
+		wantErr string
+	}{
+		{
+			name:    "disabled user",
+			mutate:  func(u *helpers.User) { u.Status = helpers.UserStatusDisabled },
+			wantErr: helpers.ErrUserNotActive.Error(),
+		},
+		{
+			name:    "coin type not allowed",
+			mutate:  func(u *helpers.User) { u.AllowedCoinTypes = []uint16{slip44.Solana} },
+			wantErr: helpers.ErrCoinTypeNotAllowed.Error(),
+		},
+		{
+			name: "other entity",
+			mutate: func(u *helpers.User) {
+				u.OwnerEntityID = "entity-owner"
+				u.RestrictToOwner = true
+			},
+			wantErr: helpers.ErrNotOwnerEntity.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+			require.NoError(t, err)
+			tt.mutate(user)
+
+			mockStorage := new(MockStorageSign)
+			mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{signTestUUID}, nil)
+			mockStorage.On("Get", ctx, config.StorageBasePath+signTestUUID).Return(createUserV2StorageEntry(t, user), nil)
+
+			data := map[string]interface{}{
+				"uuid":           signTestUUID,
+				"derivationPath": signTestDerivationPath,
+				"coinType":       int(slip44.Ether),
+				"payload":        signTestPayload,
+				"isDev":          false,
+			}
+			_, err = createSignTestBackend(t).pathSign(ctx, &logical.Request{Storage: mockStorage, Data: data},
+				createSignFieldData(data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			var coded logical.HTTPCodedError
+			require.ErrorAs(t, err, &coded)
+			assert.Equal(t, http.StatusForbidden, coded.Code())
+		})
+	}
+}
+
+func TestBackend_PathRegister_Owner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("records the registering entity", func(t *testing.T) {
+		var stored helpers.User
+		mockStorage := new(MockStorageRegister)
+		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+		mockStorage.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
+		mockStorage.On("Put", ctx, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+			return json.Unmarshal(entry.Value, &stored) == nil
+		})).Return(nil)
+
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "restrictToOwner": true}
+		req := &logical.Request{Storage: mockStorage, Data: data, EntityID: "entity-owner", DisplayName: "approle-payments"}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx, req, createRegisterFieldData(data))
+		require.NoError(t, err)
+
+		assert.Equal(t, "entity-owner", stored.OwnerEntityID)
+		assert.Equal(t, "approle-payments", stored.OwnerDisplayName)
+		assert.True(t, stored.RestrictToOwner)
+		require.NoError(t, stored.AuthorizeEntity("entity-owner"))
+		require.ErrorIs(t, stored.AuthorizeEntity("entity-other"), helpers.ErrNotOwnerEntity)
+	})
+
+	t.Run("restriction requires an entity", func(t *testing.T) {
+		mockStorage := new(MockStorageRegister)
+		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+		mockStorage.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "restrictToOwner": true}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createRegisterFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrNoRequestEntity.Error())
+	})
+
+	t.Run("managers restrict management", func(t *testing.T) {
+		var stored helpers.User
+		mockStorage := new(MockStorageRegister)
+		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+		mockStorage.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.PassphrasePolicyStorageKey).Return(nil, nil)
+		mockStorage.On("Get", ctx, config.EscrowStorageKey).Return(nil, nil)
+		mockStorage.On("Put", ctx, mock.MatchedBy(func(entry *logical.StorageEntry) bool {
+			return json.Unmarshal(entry.Value, &stored) == nil
+		})).Return(nil)
+
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "managerEntityIds": "entity-ops"}
+		req := &logical.Request{Storage: mockStorage, Data: data, EntityID: "entity-owner", ClientTokenAccessor: "accessor-1"}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx, req, createRegisterFieldData(data))
+		require.NoError(t, err)
+
+		assert.Equal(t, "accessor-1", stored.OwnerAccessor)
+		assert.True(t, stored.RestrictManagement)
+		require.NoError(t, stored.AuthorizeManagement("entity-owner"))
+		require.NoError(t, stored.AuthorizeManagement("entity-ops"))
+		require.ErrorIs(t, stored.AuthorizeManagement("entity-other"), helpers.ErrNotManagerEntity)
+	})
+
+	t.Run("management restriction requires an entity", func(t *testing.T) {
+		mockStorage := new(MockStorageRegister)
+		mockStorage.On("List", ctx, config.StorageBasePath).Return([]string{}, nil)
+		mockStorage.On("Get", ctx, config.EntropyStorageKey).Return(nil, nil)
+
+		data := map[string]interface{}{"uuid": regTestGeneratedUUID, "restrictManagement": true}
+		_, err := createRegisterTestBackend(t).pathRegister(ctx, &logical.Request{Storage: mockStorage, Data: data},
+			createRegisterFieldData(data))
+		require.ErrorContains(t, err, helpers.ErrNoManagementEntity.Error())
+	})
+
+	t.Run("unrestricted users accept any entity", func(t *testing.T) {
+		user := &helpers.User{OwnerEntityID: "entity-owner"}
+		require.NoError(t, user.AuthorizeEntity("entity-other"))
+	})
+}
+
+func TestBackend_HandleRequest_UserManagement(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	user.OwnerEntityID, user.RestrictManagement, user.ManagerEntityIDs = "entity-owner", true, []string{"entity-ops"}
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	request := func(operation logical.Operation, path, entityID string, version int) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, EntityID: entityID,
+			Data: map[string]interface{}{"version": version}})
+	}
+	forbidden := func(t *testing.T, err error) {
+		t.Helper()
+		require.ErrorContains(t, err, helpers.ErrNotManagerEntity.Error())
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded)
+		assert.Equal(t, http.StatusForbidden, coded.Code())
+	}
+
+	t.Run("other entities cannot manage the user", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/disable", "entity-other", 0)
+		forbidden(t, err)
+		_, err = request(logical.DeleteOperation, "user/"+signTestUUID, "entity-other", 0)
+		forbidden(t, err)
+		_, err = request(logical.UpdateOperation, "escrow/"+signTestUUID, "", 0)
+		forbidden(t, err)
+	})
+
+	t.Run("the owner and managers can", func(t *testing.T) {
+		resp, err := request(logical.UpdateOperation, "user/"+signTestUUID+"/disable", "entity-ops", 0)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusDisabled, resp.Data["status"])
+		resp, err = request(logical.UpdateOperation, "user/"+signTestUUID+"/enable", "entity-owner", 1)
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusActive, resp.Data["status"])
+
+		_, err = request(logical.DeleteOperation, "user/"+signTestUUID, "entity-owner", 2)
+		require.NoError(t, err)
+		_, err = helpers.GetUser(ctx, &logical.Request{Storage: s}, signTestUUID)
+		require.ErrorIs(t, err, helpers.ErrUserNotFound)
+	})
+}
+
+func TestBackend_HandleRequest_UserVersion(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	s := &logical.InmemStorage{}
+	request := func(operation logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{Operation: operation, Path: path, Storage: s, Data: data})
+	}
+	coded := func(t *testing.T, err error, code int) {
+		t.Helper()
+		var codedErr logical.HTTPCodedError
+		require.ErrorAs(t, err, &codedErr)
+		assert.Equal(t, code, codedErr.Code())
+	}
+
+	resp, err := request(logical.UpdateOperation, "register", map[string]interface{}{
+		"uuid": "versioned", "mnemonic": signTestValidMnemonic,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), resp.Data["version"])
+
+	t.Run("updates require the version read", func(t *testing.T) {
+		_, err := request(logical.UpdateOperation, "user/versioned/disable", nil)
+		require.ErrorContains(t, err, helpers.ErrVersionRequired.Error())
+		coded(t, err, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("writes based on stale reads are rejected", func(t *testing.T) {
+		read, err := request(logical.ReadOperation, "user/versioned", nil)
+		require.NoError(t, err)
+		version := read.Data["version"]
+		resp, err := request(logical.UpdateOperation, "user/versioned/disable", map[string]interface{}{"version": version})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), resp.Data["version"])
+
+		// a second operator acting on the same read
+		_, err = request(logical.UpdateOperation, "user/versioned/enable", map[string]interface{}{"version": version})
+		require.ErrorContains(t, err, helpers.ErrStaleVersion.Error())
+		coded(t, err, http.StatusConflict)
+		_, err = request(logical.DeleteOperation, "user/versioned", map[string]interface{}{"version": version})
+		require.ErrorContains(t, err, helpers.ErrStaleVersion.Error())
+
+		user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, "versioned")
+		require.NoError(t, err)
+		assert.Equal(t, helpers.UserStatusDisabled, user.Status)
+		resp, err = request(logical.UpdateOperation, "user/versioned/enable", map[string]interface{}{"version": 2})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), resp.Data["version"])
+	})
+
+	t.Run("deletions without a version are not checked", func(t *testing.T) {
+		_, err := request(logical.DeleteOperation, "user/versioned", nil)
+		require.NoError(t, err)
+	})
+}
+
+func TestBackend_ExpireUsers(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := &logical.InmemStorage{}
+	putUser := func(uuid string, expiresAt time.Time) {
+		user, err := helpers.NewUser(uuid, "test-user", signTestValidMnemonic, "", nil)
+		require.NoError(t, err)
+		user.ExpiresAt = expiresAt
+		require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+	}
+	putUser("permanent", time.Time{})
+	putUser("campaign", now.Add(time.Hour))
+	putUser("expired", now.Add(-time.Hour))
+	putUser("stale", now.Add(-expiredUserRetention-time.Hour))
+	require.NoError(t, helpers.PutMultisigWallet(ctx, s, &helpers.MultisigWallet{Name: "treasury", UUID: "stale"}))
+	require.NoError(t, helpers.PutAddressReservation(ctx, s, "stale", 60, 0, 0, &helpers.AddressReservation{}))
+
+	b := createSignTestBackend(t)
+	require.NoError(t, b.expireUsers(ctx, s, now))
+
+	status := func(uuid string) string {
+		user, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, uuid)
+		require.NoError(t, err)
+		return user.Status
+	}
+	assert.Equal(t, helpers.UserStatusActive, status("permanent"))
+	assert.Equal(t, helpers.UserStatusActive, status("campaign"))
+	assert.Equal(t, helpers.UserStatusDisabled, status("expired"))
+
+	_, err := helpers.GetUser(ctx, &logical.Request{Storage: s}, "stale")
+	require.ErrorIs(t, err, helpers.ErrUserNotFound)
+	wallets, err := s.List(ctx, config.MultisigStoragePath+"stale/")
+	require.NoError(t, err)
+	assert.Empty(t, wallets)
+	ledger, err := s.List(ctx, config.AddressLedgerStoragePath+"stale/")
+	require.NoError(t, err)
+	assert.Empty(t, ledger)
+
+	t.Run("expired users are rejected before the periodic function runs", func(t *testing.T) {
+		user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+		require.NoError(t, err)
+		user.ExpiresAt = now.Add(-time.Minute)
+		require.ErrorIs(t, user.Authorize(60), helpers.ErrUserNotActive)
+		require.ErrorIs(t, user.AuthorizeAnyCoin(), helpers.ErrUserNotActive)
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+const (
+	// maxBackupFailures is the number of consecutive mismatches after which verify-backup is locked
+	maxBackupFailures = 5
+	// backupLockout is how long verify-backup stays locked after the last mismatch
+	backupLockout = time.Hour
+)
+
+// pathVerifyBackup corresponds to UPDATE user/<uuid>/verify-backup. It reports whether the words
+// given by position are the ones of the mnemonic of the user, without revealing which ones differ.
+func (b *Backend) pathVerifyBackup(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_verify_backup"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	words := d.Get("backupWords").(map[string]string)
+
+	user, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := user.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+	if user.WatchOnly() {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrNoMnemonicToVerify.Error())
+	}
+
+	b.backupMu.Lock()
+	defer b.backupMu.Unlock()
+
+	now := time.Now().UTC()
+	verification, err := helpers.GetBackupVerification(ctx, req.Storage, uuid)
+	if err != nil {
+		backendLogger.Error("get backup verification", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if verification.Failures >= maxBackupFailures && now.Before(verification.LastFailureAt.Add(backupLockout)) {
+		backendLogger.Warn("backup verification locked", "uuid", uuid, "failures", verification.Failures)
+		return nil, logical.CodedError(http.StatusTooManyRequests, helpers.ErrBackupVerifyLocked.Error())
+	}
+
+	match, err := helpers.MatchBackupWords(user.Mnemonic, words)
+	if err != nil {
+		backendLogger.Error("match backup words", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if match {
+		err = helpers.DeleteBackupVerification(ctx, req.Storage, uuid)
+	} else {
+		if verification.Failures >= maxBackupFailures {
+			// the lockout ended, the next mismatches are counted again
+			verification.Failures = 0
+		}
+		verification.Failures++
+		verification.LastFailureAt = now
+		err = helpers.PutBackupVerification(ctx, req.Storage, uuid, verification)
+	}
+	if err != nil {
+		backendLogger.Error("record backup verification", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	backendLogger.Info("backup verified", "uuid", uuid, "words", len(words), "match", match,
+		"entity", req.EntityID)
+
+	data := map[string]interface{}{
+		"match": match,
+	}
+	if !match {
+		data["attemptsLeft"] = maxBackupFailures - verification.Failures
+	}
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
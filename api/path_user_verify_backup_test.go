@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/config"
+)
+
+func TestBackend_HandleRequest_VerifyBackup(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, createUserV2StorageEntry(t, user)))
+
+	verify := func(words map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation, Path: "user/" + signTestUUID + "/verify-backup", Storage: s,
+			Data: map[string]interface{}{"backupWords": words},
+		})
+	}
+
+	t.Run("invalid challenges are rejected", func(t *testing.T) {
+		_, err := verify(map[string]interface{}{"1": "abandon", "12": "about"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrTooFewBackupWords.Error())
+
+		for _, position := range []string{"0", "13", "first"} {
+			_, err := verify(map[string]interface{}{"1": "abandon", "12": "about", position: "abandon"})
+			require.Error(t, err, position)
+			assert.Contains(t, err.Error(), helpers.ErrInvalidWordPosition.Error())
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		resp, err := verify(map[string]interface{}{"3": "abandon", "7": " Abandon", "12": "about"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"match": true}, resp.Data)
+	})
+
+	t.Run("mismatches lock the verification", func(t *testing.T) {
+		words := map[string]interface{}{"3": "abandon", "7": "abandon", "12": "zoo"}
+		for attemptsLeft := maxBackupFailures - 1; attemptsLeft >= 0; attemptsLeft-- {
+			resp, err := verify(words)
+			require.NoError(t, err)
+			assert.Equal(t, false, resp.Data["match"])
+			assert.Equal(t, attemptsLeft, resp.Data["attemptsLeft"])
+		}
+
+		_, err := verify(map[string]interface{}{"3": "abandon", "7": "abandon", "12": "about"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrBackupVerifyLocked.Error())
+
+		require.NoError(t, purgeUser(ctx, s, signTestUUID))
+		entry, err := s.Get(ctx, config.BackupVerificationStoragePath+signTestUUID)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib/denom"
+)
+
+// pathConvert corresponds to READ and UPDATE utils/convert. It converts amount of coinType from the
+// unit from, its base unit by default, to every unit of the coin, or only to, without touching a key.
+func (b *Backend) pathConvert(_ context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_convert"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	coinType := d.Get("coinType").(int)
+	if coinType < 0 || coinType > math.MaxUint16 {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrUnsupportedCoinType.Error())
+	}
+	denomination, err := denom.Of(uint16(coinType))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	from, err := denomination.Unit(d.Get("from").(string))
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	base, err := denom.Parse(d.Get("amount").(string), from)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	amounts := make(map[string]interface{}, len(denomination.Units))
+	for _, unit := range denomination.Units {
+		amounts[unit.Name] = denom.Format(base, unit)
+	}
+	data := map[string]interface{}{
+		"coinType":   coinType,
+		"symbol":     denomination.Symbol,
+		"baseUnit":   denomination.Base().Name,
+		"baseAmount": base.String(),
+		"amounts":    amounts,
+	}
+	if name := d.Get("to").(string); name != "" {
+		to, err := denomination.Unit(name)
+		if err != nil {
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		data["amount"], data["unit"] = denom.Format(base, to), to.Name
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib/denom"
+)
+
+func TestBackend_HandleRequest_Convert(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackend(&logical.BackendConfig{})
+	require.NoError(t, b.Setup(ctx, &logical.BackendConfig{}))
+	convert := func(data map[string]interface{}) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation, Path: "utils/convert", Storage: &logical.InmemStorage{}, Data: data,
+		})
+	}
+
+	resp, err := convert(map[string]interface{}{"coinType": 60, "amount": "1.5", "from": "eth", "to": "gwei"})
+	require.NoError(t, err)
+	assert.Equal(t, "1500000000", resp.Data["amount"])
+	assert.Equal(t, "gwei", resp.Data["unit"])
+	assert.Equal(t, "1500000000000000000", resp.Data["baseAmount"])
+	assert.Equal(t, map[string]interface{}{"wei": "1500000000000000000", "gwei": "1500000000", "ETH": "1.5"},
+		resp.Data["amounts"])
+
+	resp, err = convert(map[string]interface{}{"coinType": 0, "amount": "12345"})
+	require.NoError(t, err)
+	assert.Equal(t, "sat", resp.Data["baseUnit"])
+	assert.Equal(t, "0.00012345", resp.Data["amounts"].(map[string]interface{})["BTC"])
+	assert.NotContains(t, resp.Data, "amount")
+
+	_, err = convert(map[string]interface{}{"coinType": 501, "amount": "0.0000000001", "from": "SOL"})
+	require.ErrorContains(t, err, denom.ErrBelowBaseUnit.Error())
+	_, err = convert(map[string]interface{}{"coinType": 501, "amount": "1", "to": "wei"})
+	require.ErrorContains(t, err, denom.ErrUnknownUnit.Error())
+}
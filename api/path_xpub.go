@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// pathXpub corresponds to UPDATE xpub. It returns the extended public key of the path with its
+// key origin and, for Bitcoin, the receive and change descriptors of the account.
+func (b *Backend) pathXpub(ctx context.Context, req *logical.Request,
+	d *framework.FieldData) (*logical.Response, error) {
+	backendLogger := b.logger.With(slog.String("op", "path_xpub"))
+	if err := helpers.ValidateFields(req, d); err != nil {
+		backendLogger.Error("validate fields", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	uuid := d.Get("uuid").(string)
+	derivationPath := d.Get("derivationPath").(string)
+	coinType := uint16(d.Get("coinType").(int))
+	isDev := d.Get("isDev").(bool)
+
+	if err := helpers.ValidateData(ctx, req, uuid, derivationPath); err != nil {
+		backendLogger.Error("validate data", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		backendLogger.Error("parse path", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+	if curve := coinCurve(backendLogger, coinType); curve != lib.CurveSecp256k1 {
+		backendLogger.Error("coin curve", "coinType", coinType, "curve", curve)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, helpers.ErrNoExtendedKey.Error())
+	}
+
+	userInfo, err := helpers.GetUser(ctx, req, uuid)
+	if err != nil {
+		backendLogger.Error("get user", "error", err)
+		return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+	}
+	if err := userInfo.Authorize(coinType); err != nil {
+		backendLogger.Error("authorize user", "error", err)
+		return nil, helpers.CodedError(http.StatusForbidden, err)
+	}
+	if err := userInfo.AuthorizeEntity(req.EntityID); err != nil {
+		backendLogger.Error("authorize entity", "error", err, "entity", req.EntityID)
+		return nil, logical.CodedError(http.StatusForbidden, err.Error())
+	}
+
+	bitcoin := coinType == slip44.Bitcoin || coinType == slip44.TestNet
+	net := &chaincfg.MainNetParams
+	if bitcoin && isDev {
+		net = &chaincfg.TestNet3Params
+	}
+	xpub, fingerprint, err := userExtendedPublicKey(userInfo, path, net)
+	if err != nil {
+		backendLogger.Error("extended public key", "error", err)
+		return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	// watch-only users registered without a fingerprint have no key origin
+	key := xpub.String()
+	if fingerprint != "" {
+		key = "[" + fingerprint + "/" + lib.FormatOriginPath(path) + "]" + key
+	}
+	data := map[string]interface{}{
+		"xpub":        xpub.String(),
+		"fingerprint": fingerprint,
+		"path":        derivationPath,
+		"key":         key,
+	}
+	if bitcoin {
+		descriptors, err := accountDescriptors(path, key)
+		if err != nil {
+			backendLogger.Error("descriptors", "error", err)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		data["descriptors"] = descriptors
+	}
+
+	backendLogger.Info("xpub", "uuid", uuid, "path", derivationPath, "coinType", coinType)
+	return &logical.Response{Data: data}, nil
+}
+
+// userExtendedPublicKey derives the extended public key of path for the network net, from the seed
+// of userInfo or, for watch-only users, from their xpub, and returns it with the master key
+// fingerprint. The fingerprint of watch-only users is the one they were registered with, if any.
+func userExtendedPublicKey(userInfo *helpers.User, path []uint32,
+	net *chaincfg.Params) (*hdkeychain.ExtendedKey, string, error) {
+	if userInfo.WatchOnly() {
+		key, err := userInfo.ExtendedPublicKeyAt(path)
+		if err != nil {
+			return nil, "", err
+		}
+		key, err = key.CloneWithVersion(net.HDPublicKeyID[:])
+		if err != nil {
+			return nil, "", err
+		}
+		return key, userInfo.Fingerprint, nil
+	}
+
+	seed, err := userInfo.Seed()
+	if err != nil {
+		return nil, "", err
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := lib.ExtendedPublicKeyAt(seed, path, net)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, fingerprint, nil
+}
+
+// accountDescriptors returns the receive and change descriptors of the account key expression key,
+// for the address type of the purpose of path
+func accountDescriptors(path []uint32, key string) (lib.Descriptors, error) {
+	purpose := path[0]
+	if purpose >= hdkeychain.HardenedKeyStart {
+		purpose -= hdkeychain.HardenedKeyStart
+	}
+	addressType := lib.BitcoinAddressType(purpose)
+
+	receive, err := lib.OutputDescriptor(addressType, key+"/0/*")
+	if err != nil {
+		return lib.Descriptors{}, err
+	}
+	change, err := lib.OutputDescriptor(addressType, key+"/1/*")
+	if err != nil {
+		return lib.Descriptors{}, err
+	}
+	return lib.Descriptors{Receive: receive, Change: change}, nil
+}
+
+// coinCurve returns the curve of the adapter of coinType, or "" for unsupported coins
+func coinCurve(logger *slog.Logger, coinType uint16) string {
+	for _, capabilities := range adapter.GetInventory(logger).Capabilities() {
+		for _, c := range capabilities.CoinTypes {
+			if c == coinType {
+				return capabilities.Curve
+			}
+		}
+	}
+	return ""
+}
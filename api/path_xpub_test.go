@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// BIP-84 account xpub of the "abandon ... about" mnemonic
+const xpubTestBIP84 = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+
+// Helper function to create a proper framework.FieldData for xpub endpoint
+func createXpubFieldData(data map[string]interface{}) *framework.FieldData {
+	return &framework.FieldData{
+		Raw: data,
+		Schema: map[string]*framework.FieldSchema{
+			"uuid":           {Type: framework.TypeString},
+			"derivationPath": {Type: framework.TypeString, Default: ""},
+			"coinType":       {Type: framework.TypeInt},
+			"isDev":          {Type: framework.TypeBool, Default: false},
+		},
+	}
+}
+
+// newXpubTestStorage returns a storage holding the "abandon ... about" user
+func newXpubTestStorage(t *testing.T) logical.Storage {
+	t.Helper()
+	s := &logical.InmemStorage{}
+	user, err := helpers.NewUser(signTestUUID, "test-user", signTestValidMnemonic, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), createUserV2StorageEntry(t, user)))
+	return s
+}
+
+func TestBackend_PathXpub(t *testing.T) {
+	ctx := context.Background()
+	s := newXpubTestStorage(t)
+
+	t.Run("bitcoin account with descriptors", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": signTestUUID, "derivationPath": "m/84'/0'/0'", "coinType": int(slip44.Bitcoin)}
+		got, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: data},
+			createXpubFieldData(data))
+		require.NoError(t, err)
+		assert.Equal(t, xpubTestBIP84, got.Data["xpub"])
+		assert.Equal(t, "[73c5da0a/84h/0h/0h]"+xpubTestBIP84, got.Data["key"])
+
+		receive := "wpkh([73c5da0a/84h/0h/0h]" + xpubTestBIP84 + "/0/*)"
+		descriptors := got.Data["descriptors"].(lib.Descriptors)
+		assert.Equal(t, receive+"#"+mustDescriptorChecksum(t, receive), descriptors.Receive)
+		assert.Contains(t, descriptors.Change, "/1/*)#")
+	})
+
+	t.Run("testnet bitcoin account is a tpub", func(t *testing.T) {
+		data := map[string]interface{}{
+			"uuid": signTestUUID, "derivationPath": "m/86'/1'/0'", "coinType": int(slip44.TestNet), "isDev": true,
+		}
+		got, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: data},
+			createXpubFieldData(data))
+		require.NoError(t, err)
+		assert.Contains(t, got.Data["xpub"], "tpub")
+		assert.Contains(t, got.Data["descriptors"].(lib.Descriptors).Receive, "tr([73c5da0a/86h/1h/0h]tpub")
+	})
+
+	t.Run("other secp256k1 coins have no descriptors", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": signTestUUID, "derivationPath": "m/44'/60'/0'", "coinType": int(slip44.Ether)}
+		got, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: data},
+			createXpubFieldData(data))
+		require.NoError(t, err)
+		assert.Contains(t, got.Data["xpub"], "xpub")
+		assert.NotContains(t, got.Data, "descriptors")
+	})
+
+	t.Run("ed25519 coins are rejected", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": signTestUUID, "derivationPath": "m/44'/501'/0'", "coinType": int(slip44.Solana)}
+		_, err := createSignTestBackend(t).pathXpub(ctx, &logical.Request{Storage: s, Data: data},
+			createXpubFieldData(data))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), helpers.ErrNoExtendedKey.Error())
+	})
+}
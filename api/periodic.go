@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// periodic runs the housekeeping of the mount
+func (b *Backend) periodic(ctx context.Context, req *logical.Request) error {
+	if !b.begin() {
+		return nil
+	}
+	defer b.end()
+
+	now := time.Now()
+	// periodic requests do not go through HandleRequest
+	users, err := b.routeUserStorage(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	_, retentionErr := b.applyRetention(ctx, req.Storage, now, false)
+	return errors.Join(b.pruneDebugSessions(ctx, req.Storage, now), b.pruneSigningSessions(ctx, req.Storage, now),
+		b.pruneApprovals(ctx, req.Storage, now), b.expireUsers(ctx, users, now), b.rotateDEK(ctx, req.Storage, now),
+		b.publishEvents(ctx, req.Storage, true), b.pruneBatchWALs(ctx, req.Storage, now), b.runJobs(ctx, users),
+		b.signCanaries(ctx, users, now), retentionErr,
+		b.archiveAuditData(ctx, req.Storage, now))
+}
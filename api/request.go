@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/api/helpers"
+	"github.com/payment-system/dq-vault/api/storage"
+	"github.com/payment-system/dq-vault/lib/sealedbox"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unroutedPaths are served with the Vault storage of the request, without the routing of the user records
+//
+//nolint:gochecknoglobals // read-only lookup table
+var unroutedPaths = map[string]struct{}{
+	"migrate/encrypt": {},
+	"stats/storage":   {},
+}
+
+// HandleRequest routes the user records of the request to the configured store and attests the response.
+// The unrouted paths work on the records as they are kept in the Vault storage.
+func (b *Backend) HandleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	if !b.begin() {
+		return nil, logical.CodedError(http.StatusServiceUnavailable, helpers.ErrReloading.Error())
+	}
+	defer b.end()
+
+	ctx, span := b.startRequestSpan(ctx, req)
+	resp, err := b.handleRequest(ctx, req)
+	endRequestSpan(span, resp, err)
+	b.requestErrors.record(b.Backend.Route(req.Path), err)
+	b.recordViolation(ctx, req, err)
+	return resp, err
+}
+
+// handleRequest serves req within its span, the operations on the storage record spans of their own
+func (b *Backend) handleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	if _, unrouted := unroutedPaths[req.Path]; req.Storage != nil && !unrouted {
+		routed, err := b.routeUserStorage(ctx, req.Storage)
+		if err != nil {
+			b.logger.Error("open user store", "error", err)
+			return nil, logical.CodedError(http.StatusServiceUnavailable, err.Error())
+		}
+		req.Storage = routed
+	}
+	if req.Storage != nil && trace.SpanFromContext(ctx).IsRecording() {
+		req.Storage = storage.NewTraced(req.Storage)
+	}
+
+	// the legacy fields are renamed before the framework decodes them against the schema, by the
+	// pattern of the path as declared, without the anchors added by the framework
+	route := b.Backend.Route(req.Path)
+	var warnings []string
+	var pattern string
+	var responseKey *[sealedbox.KeySize]byte
+	if route != nil && req.Storage != nil {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(route.Pattern, "^"), "$")
+		if err := b.checkFieldLengths(ctx, req.Storage, req.Data); err != nil {
+			b.logger.Warn("field too long", "error", err, "path", req.Path)
+			return nil, err
+		}
+		var err error
+		if req.Data, warnings, err = b.migrateLegacyFields(ctx, req.Storage, pattern, req.Data); err != nil {
+			b.logger.Warn("legacy fields rejected", "error", err, "path", req.Path)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := b.validateRequestFields(pattern, route.Fields, req.Data); err != nil {
+			b.logger.Warn("invalid field", "error", err, "path", req.Path)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err := b.checkDerivationPolicy(ctx, req.Storage, pattern, req.Data); err != nil {
+			b.logger.Warn("derivation path rejected", "error", err, "path", req.Path)
+			return nil, helpers.CodedError(http.StatusUnprocessableEntity, err)
+		}
+		if responseKey, err = b.responseEncryptionKey(ctx, req, pattern); err != nil {
+			b.logger.Warn("response key rejected", "error", err, "path", req.Path)
+			return nil, logical.CodedError(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	resp, err := b.Backend.HandleRequest(ctx, req)
+	if err != nil {
+		if b.forwardToActive(err) {
+			b.logger.Info("user record not replicated yet, forwarding to the active node", "path", req.Path)
+			return nil, logical.ErrPerfStandbyPleaseForward
+		}
+		return resp, err
+	}
+	recordWriteState(ctx, req)
+	// the secrets are encrypted before anything else sees the response, the attestation included
+	if err := encryptResponse(resp, pattern, responseKey); err != nil {
+		b.logger.Error("encrypt response", "error", err, "path", req.Path)
+		return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+	}
+	if warnings = append(warnings, deprecatedFieldWarnings(route, req.Data)...); len(warnings) > 0 {
+		if resp == nil {
+			resp = &logical.Response{}
+		}
+		resp.Warnings = append(resp.Warnings, warnings...)
+	}
+	b.revalidateConfig(ctx, req)
+	if event := requestEvent(req, resp); event != nil && req.Storage != nil {
+		b.queueEvent(ctx, req.Storage, event)
+	}
+	if err := b.attest(ctx, req, resp); err != nil {
+		b.logger.Error("attest response", "error", err, "path", req.Path)
+		return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+	}
+	return resp, nil
+}
+
+// forwardToActive reports whether the request failing with err is to be served by the active node
+// instead: a performance standby may not have replicated the user written by a request the active
+// node just served, as the address call following a register
+func (b *Backend) forwardToActive(err error) bool {
+	if b.Backend == nil || b.System() == nil ||
+		!b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return false
+	}
+	return errors.Is(err, helpers.ErrUserNotFound) || errors.Is(err, helpers.ErrUUIDDoesNotExist)
+}
+
+// recordWriteState has Vault return the index state of the writes of req in the X-Vault-Index
+// header of its response, even with no response data, so clients can require it on the performance
+// standbys they read from next
+func recordWriteState(ctx context.Context, req *logical.Request) {
+	if req.Operation != logical.CreateOperation && req.Operation != logical.UpdateOperation {
+		return
+	}
+	if state := logical.IndexStateFromContext(ctx); state != nil && req.ResponseState() == nil {
+		req.SetResponseState(state)
+	}
+}
+
+// deprecatedFieldWarnings returns the deprecated-field warnings of the fields of data that path
+// marks deprecated, in the order of their names
+func deprecatedFieldWarnings(path *framework.Path, data map[string]interface{}) []string {
+	if path == nil {
+		return nil
+	}
+	var warnings []string
+	for _, name := range slices.Sorted(maps.Keys(data)) {
+		if field, ok := path.Fields[name]; ok && field.Deprecated {
+			warnings = append(warnings, helpers.Warning(helpers.WarningDeprecatedField,
+				"field %s is deprecated and will be removed, see the help of the path", name))
+		}
+	}
+	return warnings
+}
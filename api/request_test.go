@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/helpers"
+)
+
+func TestDeprecatedFieldWarnings(t *testing.T) {
+	path := &framework.Path{Fields: map[string]*framework.FieldSchema{
+		"current": {Type: framework.TypeString},
+		"old":     {Type: framework.TypeString, Deprecated: true},
+	}}
+
+	assert.Empty(t, deprecatedFieldWarnings(path, map[string]interface{}{"current": "x"}))
+	assert.Empty(t, deprecatedFieldWarnings(nil, map[string]interface{}{"old": "x"}))
+	warnings := deprecatedFieldWarnings(path, map[string]interface{}{"current": "x", "old": "y"})
+	require.Len(t, warnings, 1)
+	assert.Equal(t, helpers.WarningDeprecatedField, helpers.WarningCode(warnings[0]))
+	assert.Contains(t, warnings[0], "old")
+}
+
+func TestBackend_HandleRequest_ReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	request := func(b *Backend, ctx context.Context, s logical.Storage, path string,
+		data map[string]interface{}) (*logical.Request, error) {
+		t.Helper()
+		req := &logical.Request{Operation: logical.UpdateOperation, Path: path, Storage: s, Data: data}
+		_, err := b.HandleRequest(ctx, req)
+		return req, err
+	}
+	newBackend := func(t *testing.T, state consts.ReplicationState) *Backend {
+		t.Helper()
+		b := NewBackend(&logical.BackendConfig{})
+		require.NoError(t, b.Setup(ctx, &logical.BackendConfig{
+			System: &logical.StaticSystemView{ReplicationStateVal: state},
+		}))
+		return b
+	}
+
+	t.Run("the index state of the writes is returned", func(t *testing.T) {
+		state := &logical.WALState{ClusterID: "cluster", LocalIndex: 7, ReplicatedIndex: 3}
+		req, err := request(newBackend(t, 0), logical.IndexStateContext(ctx, state), &logical.InmemStorage{},
+			"register", map[string]interface{}{"uuid": "ryw-uuid"})
+		require.NoError(t, err)
+		assert.Same(t, state, req.ResponseState())
+	})
+
+	t.Run("performance standbys forward unknown users", func(t *testing.T) {
+		data := map[string]interface{}{"uuid": "ryw-uuid", "coinType": 60, "derivationPath": signTestDerivationPath}
+		_, err := request(newBackend(t, consts.ReplicationPerformanceStandby), ctx, &logical.InmemStorage{},
+			"address", data)
+		require.ErrorIs(t, err, logical.ErrPerfStandbyPleaseForward)
+
+		_, err = request(newBackend(t, 0), ctx, &logical.InmemStorage{}, "address", data)
+		require.ErrorContains(t, err, helpers.ErrUUIDDoesNotExist.Error())
+	})
+}
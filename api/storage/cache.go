@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Cache is a bounded LRU of storage entries expiring ttl after they are read. It only lives in
+// memory: entries are never persisted, and are dropped when written or deleted through Cached.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+	items      map[string]*list.Element
+	order      *list.List
+	stats      CacheStats
+}
+
+type cacheItem struct {
+	entry   *logical.StorageEntry
+	expires time.Time
+}
+
+// CacheStats counts the lookups of a Cache
+type CacheStats struct {
+	Entries   int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate returns the share of the lookups served from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewCache returns an empty cache of at most maxEntries entries
+func NewCache(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns a copy of the cached entry of key
+func (c *Cache) get(key string) (*logical.StorageEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if ok && c.now().After(element.Value.(*cacheItem).expires) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return copyEntry(element.Value.(*cacheItem).entry), true
+}
+
+// add caches a copy of entry, evicting the least recently used entry when the cache is full
+func (c *Cache) add(entry *logical.StorageEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := &cacheItem{entry: copyEntry(entry), expires: c.now().Add(c.ttl)}
+	if element, ok := c.items[entry.Key]; ok {
+		clear(element.Value.(*cacheItem).entry.Value)
+		element.Value = item
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[entry.Key] = c.order.PushFront(item)
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops element, zeroing its value: the cached user records hold their mnemonic
+func (c *Cache) remove(element *list.Element) {
+	entry := element.Value.(*cacheItem).entry
+	clear(entry.Value)
+	delete(c.items, entry.Key)
+	c.order.Remove(element)
+}
+
+// Invalidate drops the cached entry of key
+func (c *Cache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Purge drops every cached entry, zeroing their values
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+// Stats returns the counters of the cache
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+func copyEntry(entry *logical.StorageEntry) *logical.StorageEntry {
+	copied := *entry
+	copied.Value = append([]byte(nil), entry.Value...)
+	return &copied
+}
+
+// Cached is a logical.Storage reading the keys under prefix through cache. Writes and deletes go
+// to next and invalidate the cached entry; missing keys are not cached.
+type Cached struct {
+	next   logical.Storage
+	prefix string
+	cache  *Cache
+}
+
+// NewCached caches the entries of next under prefix in cache
+func NewCached(next logical.Storage, prefix string, cache *Cache) *Cached {
+	return &Cached{next: next, prefix: prefix, cache: cache}
+}
+
+// List lists the keys under prefix
+func (c *Cached) List(ctx context.Context, prefix string) ([]string, error) {
+	return c.next.List(ctx, prefix)
+}
+
+// Get reads key, from the cache when it holds it
+func (c *Cached) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	if !strings.HasPrefix(key, c.prefix) {
+		return c.next.Get(ctx, key)
+	}
+	if entry, ok := c.cache.get(key); ok {
+		return entry, nil
+	}
+
+	entry, err := c.next.Get(ctx, key)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	c.cache.add(entry)
+	return entry, nil
+}
+
+// Put writes entry
+func (c *Cached) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	c.cache.Invalidate(entry.Key)
+	err := c.next.Put(ctx, entry)
+	// a read racing the write may have cached the previous value
+	c.cache.Invalidate(entry.Key)
+	return err
+}
+
+// Delete removes key
+func (c *Cached) Delete(ctx context.Context, key string) error {
+	c.cache.Invalidate(key)
+	err := c.next.Delete(ctx, key)
+	c.cache.Invalidate(key)
+	return err
+}
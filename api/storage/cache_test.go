@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage counts the reads of the storage it wraps
+type countingStorage struct {
+	logical.Storage
+	gets int
+}
+
+func (c *countingStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	c.gets++
+	return c.Storage.Get(ctx, key)
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	next := &countingStorage{Storage: &logical.InmemStorage{}}
+	cache := NewCache(2, time.Minute)
+	cached := NewCached(next, "users/", cache)
+
+	require.NoError(t, cached.Put(ctx, &logical.StorageEntry{Key: "users/a", Value: []byte("a")}))
+	require.NoError(t, cached.Put(ctx, &logical.StorageEntry{Key: "config/features", Value: []byte("{}")}))
+
+	for range 3 {
+		entry, err := cached.Get(ctx, "users/a")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), entry.Value)
+		entry.Value[0] = 'x'
+	}
+	assert.Equal(t, 1, next.gets, "repeated reads are served from the cache")
+
+	_, err := cached.Get(ctx, "config/features")
+	require.NoError(t, err)
+	_, err = cached.Get(ctx, "config/features")
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.gets, "keys outside of the prefix are not cached")
+
+	t.Run("writes and deletes invalidate", func(t *testing.T) {
+		require.NoError(t, cached.Put(ctx, &logical.StorageEntry{Key: "users/a", Value: []byte("b")}))
+		entry, err := cached.Get(ctx, "users/a")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("b"), entry.Value)
+
+		require.NoError(t, cached.Delete(ctx, "users/a"))
+		entry, err = cached.Get(ctx, "users/a")
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		for _, key := range []string{"users/1", "users/2", "users/3"} {
+			require.NoError(t, cached.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte(key)}))
+			_, err := cached.Get(ctx, key)
+			require.NoError(t, err)
+		}
+		stats := cache.Stats()
+		assert.Equal(t, 2, stats.Entries)
+		assert.Equal(t, uint64(1), stats.Evictions)
+
+		gets := next.gets
+		_, err := cached.Get(ctx, "users/3")
+		require.NoError(t, err)
+		_, err = cached.Get(ctx, "users/1")
+		require.NoError(t, err)
+		assert.Equal(t, gets+1, next.gets)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		now := time.Now()
+		cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+		gets := next.gets
+		_, err := cached.Get(ctx, "users/3")
+		require.NoError(t, err)
+		assert.Equal(t, gets+1, next.gets)
+	})
+
+	t.Run("purged entries are zeroed", func(t *testing.T) {
+		cache.add(&logical.StorageEntry{Key: "users/p", Value: []byte("mnemonic")})
+		cached := cache.items["users/p"].Value.(*cacheItem).entry
+
+		cache.Purge()
+		assert.Equal(t, 0, cache.Stats().Entries)
+		assert.Equal(t, make([]byte, len("mnemonic")), cached.Value)
+	})
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.InDelta(t, float64(stats.Hits)/float64(stats.Hits+stats.Misses), stats.HitRate(), 1e-9)
+}
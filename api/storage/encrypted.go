@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// KeyLength is the length of the AES-256 key encrypting the values of an external store
+const KeyLength = 32
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidKeyLength = errors.New("encryption key must be 32 bytes")
+	ErrCiphertextLength = errors.New("stored value is too short to be decrypted")
+)
+
+// Encrypted is a logical.Storage encrypting the values of another storage with AES-256-GCM.
+// The key of an entry is authenticated with its value, so values cannot be swapped between keys.
+// Keys themselves are stored in clear, as Vault does.
+type Encrypted struct {
+	next logical.Storage
+	aead cipher.AEAD
+	// previous are the keys of a rotation in progress, values are only decrypted with them
+	previous []cipher.AEAD
+	// plaintext accepts the JSON values written in clear before the storage was encrypted
+	plaintext bool
+}
+
+// NewEncrypted wraps next with encryption under key. Values still encrypted under one of the
+// previous keys can be read, and are encrypted under key when written again.
+func NewEncrypted(next logical.Storage, key []byte, previous ...[]byte) (*Encrypted, error) {
+	aead, err := NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	e := &Encrypted{next: next, aead: aead}
+	for _, k := range previous {
+		p, err := NewAEAD(k)
+		if err != nil {
+			return nil, err
+		}
+		e.previous = append(e.previous, p)
+	}
+	return e, nil
+}
+
+// KeyID identifies an encryption key without revealing it: the first 8 bytes of its SHA-256, in hex
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// AcceptPlaintext makes e read the JSON values written in clear before the storage was encrypted,
+// as values to encrypt again. It is only meant for the migration of such values.
+func (e *Encrypted) AcceptPlaintext() {
+	e.plaintext = true
+}
+
+// NewAEAD returns the AES-256-GCM cipher of key
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeyLength {
+		return nil, ErrInvalidKeyLength
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// List lists the keys under prefix
+func (e *Encrypted) List(ctx context.Context, prefix string) ([]string, error) {
+	return e.next.List(ctx, prefix)
+}
+
+// Get reads and decrypts key
+func (e *Encrypted) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	entry, err := e.next.Get(ctx, key)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+
+	value, _, err := e.open(key, entry.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &logical.StorageEntry{Key: key, Value: value}, nil
+}
+
+// open decrypts the sealed value of key, reporting whether it was encrypted under a previous key
+// or is a plaintext value to encrypt
+func (e *Encrypted) open(key string, sealed []byte) ([]byte, bool, error) {
+	value, stale, err := e.decrypt(key, sealed)
+	if err != nil && e.plaintext && json.Valid(sealed) {
+		return sealed, true, nil
+	}
+	return value, stale, err
+}
+
+func (e *Encrypted) decrypt(key string, sealed []byte) ([]byte, bool, error) {
+	nonceSize := e.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, false, ErrCiphertextLength
+	}
+	nonce, ciphertext := sealed[:nonceSize], sealed[nonceSize:]
+	value, err := e.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err == nil {
+		return value, false, nil
+	}
+	for _, previous := range e.previous {
+		if value, perr := previous.Open(nil, nonce, ciphertext, []byte(key)); perr == nil {
+			return value, true, nil
+		}
+	}
+	return nil, false, err
+}
+
+// Reencrypt writes key again under the current key when it is encrypted under a previous one, or
+// in clear with AcceptPlaintext, reporting whether it was rewritten. Missing keys are skipped.
+func (e *Encrypted) Reencrypt(ctx context.Context, key string) (bool, error) {
+	entry, err := e.next.Get(ctx, key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	value, stale, err := e.open(key, entry.Value)
+	if err != nil || !stale {
+		return false, err
+	}
+	if err := e.Put(ctx, &logical.StorageEntry{Key: key, Value: value}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Put encrypts and writes entry
+func (e *Encrypted) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := e.aead.Seal(nonce, nonce, entry.Value, []byte(entry.Key))
+	return e.next.Put(ctx, &logical.StorageEntry{Key: entry.Key, Value: sealed})
+}
+
+// Delete removes key
+func (e *Encrypted) Delete(ctx context.Context, key string) error {
+	return e.next.Delete(ctx, key)
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Layout of the journal under its prefix
+const (
+	journalSequenceKey  = "sequence"
+	journalChangesPath  = "changes/"
+	journalVersionsPath = "versions/"
+)
+
+// Change -- the last write of a record journaled, as the sequence number of the journal it took and
+// the version of the record it produced. Replicated changes were applied from another cluster.
+type Change struct {
+	Seq        uint64    `json:"seq"`
+	Name       string    `json:"name"`
+	Version    uint64    `json:"version"`
+	Deleted    bool      `json:"deleted,omitempty"`
+	Replicated bool      `json:"replicated,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Journal is a logical.Storage numbering the writes of the records under prefix, so they can be
+// replicated to another cluster. Each write bumps the version of the record and moves its change to
+// the next sequence number of the journal, kept under journalPrefix of vault. Only the last change
+// of a record is kept, so the journal holds one change per record, deleted ones included.
+//
+// The change is journaled after the record is written: a write whose change fails to be journaled
+// is only replicated with the next write of the record.
+type Journal struct {
+	next          logical.Storage
+	vault         logical.Storage
+	prefix        string
+	journalPrefix string
+	// mu serializes the sequence numbers across the journals of the requests
+	mu *sync.Mutex
+}
+
+// NewJournal journals the writes of the records of next under prefix in journalPrefix of vault
+func NewJournal(next, vault logical.Storage, prefix, journalPrefix string, mu *sync.Mutex) *Journal {
+	return &Journal{next: next, vault: vault, prefix: prefix, journalPrefix: journalPrefix, mu: mu}
+}
+
+// journalSequence -- the last sequence number taken by a change
+type journalSequence struct {
+	Seq uint64 `json:"seq"`
+}
+
+type replicatedVersionKey struct{}
+
+// WithReplicatedVersion makes the writes of ctx journaled as replicated changes producing version,
+// instead of bumping the version of the record
+func WithReplicatedVersion(ctx context.Context, version uint64) context.Context {
+	return context.WithValue(ctx, replicatedVersionKey{}, version)
+}
+
+// name returns the name of the record key, false for keys not under prefix
+func (j *Journal) name(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, j.prefix)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// List lists the keys under prefix
+func (j *Journal) List(ctx context.Context, prefix string) ([]string, error) {
+	return j.next.List(ctx, prefix)
+}
+
+// Get reads key
+func (j *Journal) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	return j.next.Get(ctx, key)
+}
+
+// Put writes entry, then journals its change
+func (j *Journal) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	if err := j.next.Put(ctx, entry); err != nil {
+		return err
+	}
+	if name, ok := j.name(entry.Key); ok {
+		return j.record(ctx, name, false)
+	}
+	return nil
+}
+
+// Delete removes key, then journals its change
+func (j *Journal) Delete(ctx context.Context, key string) error {
+	if err := j.next.Delete(ctx, key); err != nil {
+		return err
+	}
+	if name, ok := j.name(key); ok {
+		return j.record(ctx, name, true)
+	}
+	return nil
+}
+
+// Track journals the records under prefix without a change yet, such as those written before the
+// journal was enabled, returning the number of changes journaled
+func (j *Journal) Track(ctx context.Context) (int, error) {
+	names, err := j.next.List(ctx, j.prefix)
+	if err != nil {
+		return 0, err
+	}
+	tracked := 0
+	for _, name := range names {
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		last, err := JournalVersion(ctx, j.vault, j.journalPrefix, name)
+		if err != nil {
+			return tracked, err
+		}
+		if last != nil {
+			continue
+		}
+		if err := j.record(ctx, name, false); err != nil {
+			return tracked, err
+		}
+		tracked++
+	}
+	return tracked, nil
+}
+
+// record journals a change of name at the next sequence number, in place of its previous one
+func (j *Journal) record(ctx context.Context, name string, deleted bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	seq, err := JournalSequence(ctx, j.vault, j.journalPrefix)
+	if err != nil {
+		return err
+	}
+	last, err := JournalVersion(ctx, j.vault, j.journalPrefix, name)
+	if err != nil {
+		return err
+	}
+
+	change := Change{Seq: seq + 1, Name: name, Deleted: deleted, Time: time.Now().UTC()}
+	if version, ok := ctx.Value(replicatedVersionKey{}).(uint64); ok {
+		change.Version, change.Replicated = version, true
+	} else {
+		change.Version = 1
+		if last != nil {
+			change.Version = last.Version + 1
+		}
+	}
+
+	for _, key := range []string{j.journalPrefix + journalChangesPath + changeKey(change.Seq),
+		j.journalPrefix + journalVersionsPath + name} {
+		entry, err := logical.StorageEntryJSON(key, change)
+		if err != nil {
+			return err
+		}
+		if err := j.vault.Put(ctx, entry); err != nil {
+			return err
+		}
+	}
+	if last != nil {
+		if err := j.vault.Delete(ctx, j.journalPrefix+journalChangesPath+changeKey(last.Seq)); err != nil {
+			return err
+		}
+	}
+	entry, err := logical.StorageEntryJSON(j.journalPrefix+journalSequenceKey, journalSequence{Seq: change.Seq})
+	if err != nil {
+		return err
+	}
+	return j.vault.Put(ctx, entry)
+}
+
+// JournalSequence returns the last sequence number of the journal under journalPrefix of s
+func JournalSequence(ctx context.Context, s logical.Storage, journalPrefix string) (uint64, error) {
+	entry, err := s.Get(ctx, journalPrefix+journalSequenceKey)
+	if err != nil || entry == nil {
+		return 0, err
+	}
+	var sequence journalSequence
+	if err := entry.DecodeJSON(&sequence); err != nil {
+		return 0, err
+	}
+	return sequence.Seq, nil
+}
+
+// JournalVersion returns the last change of the record name journaled under journalPrefix of s,
+// nil when it has none
+func JournalVersion(ctx context.Context, s logical.Storage, journalPrefix, name string) (*Change, error) {
+	entry, err := s.Get(ctx, journalPrefix+journalVersionsPath+name)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	var change Change
+	if err := entry.DecodeJSON(&change); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// JournalChanges returns the first limit changes journaled under journalPrefix of s after since, by
+// sequence number
+func JournalChanges(ctx context.Context, s logical.Storage, journalPrefix string, since uint64,
+	limit int) ([]Change, error) {
+	keys, err := s.List(ctx, journalPrefix+journalChangesPath)
+	if err != nil {
+		return nil, err
+	}
+	// the zero-padded keys sort by sequence number
+	slices.Sort(keys)
+	first, _ := slices.BinarySearch(keys, changeKey(since+1))
+
+	changes := make([]Change, 0, min(limit, len(keys)-first))
+	for _, key := range keys[first:] {
+		if len(changes) == limit {
+			break
+		}
+		entry, err := s.Get(ctx, journalPrefix+journalChangesPath+key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			// moved by a write since the list
+			continue
+		}
+		var change Change
+		if err := entry.DecodeJSON(&change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// DeleteJournalChange removes the change journaled at seq under journalPrefix of s, keeping the last
+// version of its record, so a later write of the record still bumps it
+func DeleteJournalChange(ctx context.Context, s logical.Storage, journalPrefix string, seq uint64) error {
+	return s.Delete(ctx, journalPrefix+journalChangesPath+changeKey(seq))
+}
+
+func changeKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	// registers the postgres database/sql driver
+	_ "github.com/lib/pq"
+)
+
+// DefaultPostgresTable is the table holding the user records when none is configured
+const DefaultPostgresTable = "dq_vault_users"
+
+// ErrInvalidTable is returned for table names that are not plain SQL identifiers
+var ErrInvalidTable = errors.New("table must be a lower case SQL identifier")
+
+//nolint:gochecknoglobals // compiled once, read-only
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// Postgres is a logical.Storage persisting entries in a Postgres table of (key, value) rows
+type Postgres struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgres connects to connectionURL and creates table when it does not exist
+func NewPostgres(ctx context.Context, connectionURL, table string) (*Postgres, error) {
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+
+	db, err := sql.Open("postgres", connectionURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	// the table name is validated above, identifiers cannot be bound as parameters
+	query := "CREATE TABLE IF NOT EXISTS " + table + " (key TEXT PRIMARY KEY, value BYTEA NOT NULL)"
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Postgres{db: db, table: table}, nil
+}
+
+// Close releases the connections of the store
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+// List lists the keys under prefix
+func (p *Postgres) List(ctx context.Context, prefix string) ([]string, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+	rows, err := p.db.QueryContext(ctx, "SELECT key FROM "+p.table+" WHERE key LIKE $1 ORDER BY key", pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return children(prefix, keys), nil
+}
+
+// Get reads key, returning nil when it does not exist
+func (p *Postgres) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	var value []byte
+	err := p.db.QueryRowContext(ctx, "SELECT value FROM "+p.table+" WHERE key = $1", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &logical.StorageEntry{Key: key, Value: value}, nil
+}
+
+// Put writes entry, replacing the stored value
+func (p *Postgres) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	_, err := p.db.ExecContext(ctx, "INSERT INTO "+p.table+" (key, value) VALUES ($1, $2) "+
+		"ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value", entry.Key, entry.Value)
+	return err
+}
+
+// Delete removes key
+func (p *Postgres) Delete(ctx context.Context, key string) error {
+	_, err := p.db.ExecContext(ctx, "DELETE FROM "+p.table+" WHERE key = $1", key)
+	return err
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// splitPassphraseField is the passphrase field of the user records
+	splitPassphraseField = "passphrase"
+	// splitMarkerField marks the records whose passphrase is stored apart
+	splitMarkerField = "passphraseSplit"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrSplitPassphraseMissing = errors.New("passphrase of the user record is missing from its storage")
+)
+
+// Split is a logical.Storage keeping the passphrase of the user records under prefix apart from
+// them, under passphrasePrefix of the Vault storage, seal wrapped. Neither storage holds enough
+// to derive the keys of a user: the record is only reassembled in memory, when read.
+//
+// Records are split when written while enabled; without it they are written whole and their
+// passphrase entry removed. Split records are reassembled whether or not the split is enabled.
+type Split struct {
+	next             logical.Storage
+	vault            logical.Storage
+	prefix           string
+	passphrasePrefix string
+	enabled          bool
+}
+
+// NewSplit keeps the passphrases of the records of next under prefix in passphrasePrefix of vault
+func NewSplit(next, vault logical.Storage, prefix, passphrasePrefix string, enabled bool) *Split {
+	return &Split{next: next, vault: vault, prefix: prefix, passphrasePrefix: passphrasePrefix, enabled: enabled}
+}
+
+// passphraseKey returns the key of the passphrase of the record key, false for keys not under prefix
+func (s *Split) passphraseKey(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, s.prefix)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return s.passphrasePrefix + name, true
+}
+
+// List lists the keys under prefix
+func (s *Split) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.next.List(ctx, prefix)
+}
+
+// Get reads key, with the passphrase of split records put back
+func (s *Split) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	entry, err := s.next.Get(ctx, key)
+	passphraseKey, ok := s.passphraseKey(key)
+	if err != nil || entry == nil || !ok {
+		return entry, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Value, &fields); err != nil {
+		return nil, err
+	}
+	if _, split := fields[splitMarkerField]; !split {
+		return entry, nil
+	}
+
+	passphraseEntry, err := s.vault.Get(ctx, passphraseKey)
+	if err != nil {
+		return nil, err
+	}
+	if passphraseEntry == nil {
+		return nil, ErrSplitPassphraseMissing
+	}
+	fields[splitPassphraseField] = passphraseEntry.Value
+	delete(fields, splitMarkerField)
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &logical.StorageEntry{Key: entry.Key, Value: value, SealWrap: entry.SealWrap}, nil
+}
+
+// Put writes entry, its passphrase first when the split is enabled
+func (s *Split) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	passphraseKey, ok := s.passphraseKey(entry.Key)
+	if !ok {
+		return s.next.Put(ctx, entry)
+	}
+	if !s.enabled {
+		if err := s.next.Put(ctx, entry); err != nil {
+			return err
+		}
+		return s.vault.Delete(ctx, passphraseKey)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Value, &fields); err != nil {
+		return err
+	}
+	passphrase, ok := fields[splitPassphraseField]
+	if !ok {
+		return s.next.Put(ctx, entry)
+	}
+
+	// the passphrase is written first, so no stored record points to a missing one
+	if err := s.vault.Put(ctx, &logical.StorageEntry{Key: passphraseKey, Value: passphrase, SealWrap: true}); err != nil {
+		return err
+	}
+	fields[splitPassphraseField] = json.RawMessage(`""`)
+	fields[splitMarkerField] = json.RawMessage(`true`)
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return s.next.Put(ctx, &logical.StorageEntry{Key: entry.Key, Value: value, SealWrap: entry.SealWrap})
+}
+
+// Delete removes key, and its passphrase
+func (s *Split) Delete(ctx context.Context, key string) error {
+	if err := s.next.Delete(ctx, key); err != nil {
+		return err
+	}
+	if passphraseKey, ok := s.passphraseKey(key); ok {
+		return s.vault.Delete(ctx, passphraseKey)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package storage lets user records live outside of the Vault logical storage.
+// Stores implement logical.Storage, so the request handlers are unaware of where
+// a record is persisted; the Vault storage stays the default.
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Store types accepted by config/storage
+const (
+	TypeVault    = "vault"
+	TypePostgres = "postgres"
+)
+
+// Router is a logical.Storage sending the keys under prefix to routed and every other key to
+// the Vault storage. Listing a parent of prefix only returns the keys of the Vault storage.
+type Router struct {
+	vault  logical.Storage
+	prefix string
+	routed logical.Storage
+}
+
+// NewRouter routes the keys under prefix to routed
+func NewRouter(vault logical.Storage, prefix string, routed logical.Storage) *Router {
+	return &Router{vault: vault, prefix: prefix, routed: routed}
+}
+
+func (r *Router) storage(key string) logical.Storage {
+	if strings.HasPrefix(key, r.prefix) {
+		return r.routed
+	}
+	return r.vault
+}
+
+// List lists the keys under prefix
+func (r *Router) List(ctx context.Context, prefix string) ([]string, error) {
+	return r.storage(prefix).List(ctx, prefix)
+}
+
+// Get reads key
+func (r *Router) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	return r.storage(key).Get(ctx, key)
+}
+
+// Put writes entry
+func (r *Router) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	return r.storage(entry.Key).Put(ctx, entry)
+}
+
+// Delete removes key
+func (r *Router) Delete(ctx context.Context, key string) error {
+	return r.storage(key).Delete(ctx, key)
+}
+
+// children returns the names directly under prefix, with a trailing "/" for nested keys,
+// following the List semantics of logical.Storage
+func children(prefix string, keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[:i+1]
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
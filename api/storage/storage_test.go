@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	ctx := context.Background()
+	vault, users := &logical.InmemStorage{}, &logical.InmemStorage{}
+	router := NewRouter(vault, "users/", users)
+
+	require.NoError(t, router.Put(ctx, &logical.StorageEntry{Key: "users/abc", Value: []byte("user")}))
+	require.NoError(t, router.Put(ctx, &logical.StorageEntry{Key: "config/features", Value: []byte("{}")}))
+
+	entry, err := users.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	entry, err = vault.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	keys, err := router.List(ctx, "users/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, keys)
+
+	entry, err = router.Get(ctx, "config/features")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), entry.Value)
+
+	require.NoError(t, router.Delete(ctx, "users/abc"))
+	keys, err = users.List(ctx, "users/")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestEncrypted(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{7}, KeyLength)
+	next := &logical.InmemStorage{}
+	encrypted, err := NewEncrypted(next, key)
+	require.NoError(t, err)
+
+	value := []byte(`{"mnemonic":"secret words"}`)
+	require.NoError(t, encrypted.Put(ctx, &logical.StorageEntry{Key: "users/abc", Value: value}))
+
+	raw, err := next.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw.Value), "secret words")
+
+	entry, err := encrypted.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.Equal(t, value, entry.Value)
+
+	t.Run("value moved to another key", func(t *testing.T) {
+		require.NoError(t, next.Put(ctx, &logical.StorageEntry{Key: "users/other", Value: raw.Value}))
+		_, err := encrypted.Get(ctx, "users/other")
+		require.Error(t, err)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, err := NewEncrypted(next, bytes.Repeat([]byte{8}, KeyLength))
+		require.NoError(t, err)
+		_, err = other.Get(ctx, "users/abc")
+		require.Error(t, err)
+	})
+
+	t.Run("missing entry", func(t *testing.T) {
+		entry, err := encrypted.Get(ctx, "users/missing")
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+
+	t.Run("invalid key length", func(t *testing.T) {
+		_, err := NewEncrypted(next, []byte("short"))
+		require.ErrorIs(t, err, ErrInvalidKeyLength)
+	})
+}
+
+func TestEncrypted_Reencrypt(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := bytes.Repeat([]byte{7}, KeyLength), bytes.Repeat([]byte{9}, KeyLength)
+	next := &logical.InmemStorage{}
+	old, err := NewEncrypted(next, oldKey)
+	require.NoError(t, err)
+	value := []byte(`{"mnemonic":"secret words"}`)
+	require.NoError(t, old.Put(ctx, &logical.StorageEntry{Key: "users/abc", Value: value}))
+
+	rotated, err := NewEncrypted(next, newKey, oldKey)
+	require.NoError(t, err)
+	entry, err := rotated.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.Equal(t, value, entry.Value, "values under the previous key stay readable")
+
+	rewritten, err := rotated.Reencrypt(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.True(t, rewritten)
+
+	current, err := NewEncrypted(next, newKey)
+	require.NoError(t, err)
+	entry, err = current.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.Equal(t, value, entry.Value)
+	_, err = old.Get(ctx, "users/abc")
+	require.Error(t, err, "the previous key no longer decrypts the value")
+
+	t.Run("already under the current key", func(t *testing.T) {
+		rewritten, err := rotated.Reencrypt(ctx, "users/abc")
+		require.NoError(t, err)
+		assert.False(t, rewritten)
+	})
+
+	t.Run("missing entry", func(t *testing.T) {
+		rewritten, err := rotated.Reencrypt(ctx, "users/missing")
+		require.NoError(t, err)
+		assert.False(t, rewritten)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		other, err := NewEncrypted(next, bytes.Repeat([]byte{8}, KeyLength))
+		require.NoError(t, err)
+		require.NoError(t, other.Put(ctx, &logical.StorageEntry{Key: "users/other", Value: value}))
+		_, err = rotated.Reencrypt(ctx, "users/other")
+		require.Error(t, err)
+	})
+
+	t.Run("invalid previous key length", func(t *testing.T) {
+		_, err := NewEncrypted(next, newKey, []byte("short"))
+		require.ErrorIs(t, err, ErrInvalidKeyLength)
+	})
+}
+
+func TestEncrypted_AcceptPlaintext(t *testing.T) {
+	ctx := context.Background()
+	next := &logical.InmemStorage{}
+	value := []byte(`{}`)
+	require.NoError(t, next.Put(ctx, &logical.StorageEntry{Key: "users/abc", Value: value}))
+	require.NoError(t, next.Put(ctx, &logical.StorageEntry{Key: "users/garbage", Value: []byte("not json")}))
+
+	encrypted, err := NewEncrypted(next, bytes.Repeat([]byte{7}, KeyLength))
+	require.NoError(t, err)
+	_, err = encrypted.Get(ctx, "users/abc")
+	require.Error(t, err, "plaintext values are rejected by default")
+
+	encrypted.AcceptPlaintext()
+	entry, err := encrypted.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.Equal(t, value, entry.Value)
+	_, err = encrypted.Get(ctx, "users/garbage")
+	require.Error(t, err)
+
+	rewritten, err := encrypted.Reencrypt(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.True(t, rewritten)
+	raw, err := next.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.NotEqual(t, value, raw.Value)
+}
+
+func TestChildren(t *testing.T) {
+	keys := []string{"users/a", "users/b/1", "users/b/2", "users/c", "config/x"}
+	assert.Equal(t, []string{"a", "b/", "c"}, children("users/", keys))
+}
+
+func TestNewPostgres_InvalidTable(t *testing.T) {
+	_, err := NewPostgres(context.Background(), "postgres://localhost/db", "users; DROP TABLE x")
+	require.ErrorIs(t, err, ErrInvalidTable)
+}
+
+func TestSplit(t *testing.T) {
+	ctx := context.Background()
+	vault := &logical.InmemStorage{}
+	record := &logical.StorageEntry{Key: "users/abc", Value: []byte(`{"mnemonic":"words","passphrase":"secret"}`)}
+
+	split := NewSplit(vault, vault, "users/", "passphrases/", true)
+	require.NoError(t, split.Put(ctx, record))
+
+	stored, err := vault.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored.Value), "secret")
+	passphrase, err := vault.Get(ctx, "passphrases/abc")
+	require.NoError(t, err)
+	assert.JSONEq(t, `"secret"`, string(passphrase.Value))
+
+	entry, err := split.Get(ctx, "users/abc")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(record.Value), string(entry.Value))
+
+	t.Run("split records are read once disabled", func(t *testing.T) {
+		whole := NewSplit(vault, vault, "users/", "passphrases/", false)
+		entry, err := whole.Get(ctx, "users/abc")
+		require.NoError(t, err)
+		assert.JSONEq(t, string(record.Value), string(entry.Value))
+
+		require.NoError(t, whole.Put(ctx, entry))
+		stored, err := vault.Get(ctx, "users/abc")
+		require.NoError(t, err)
+		assert.JSONEq(t, string(record.Value), string(stored.Value))
+		passphrase, err := vault.Get(ctx, "passphrases/abc")
+		require.NoError(t, err)
+		assert.Nil(t, passphrase)
+	})
+
+	t.Run("a missing passphrase fails the read", func(t *testing.T) {
+		require.NoError(t, split.Put(ctx, record))
+		require.NoError(t, vault.Delete(ctx, "passphrases/abc"))
+		_, err := split.Get(ctx, "users/abc")
+		assert.ErrorIs(t, err, ErrSplitPassphraseMissing)
+	})
+
+	t.Run("delete removes both", func(t *testing.T) {
+		require.NoError(t, split.Put(ctx, record))
+		require.NoError(t, split.Delete(ctx, "users/abc"))
+		keys, err := vault.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("other keys pass through", func(t *testing.T) {
+		other := &logical.StorageEntry{Key: "config/features", Value: []byte(`{"passphrase":"kept"}`)}
+		require.NoError(t, split.Put(ctx, other))
+		entry, err := vault.Get(ctx, "config/features")
+		require.NoError(t, err)
+		assert.Equal(t, other.Value, entry.Value)
+	})
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	vault := &logical.InmemStorage{}
+	var mu sync.Mutex
+	journal := NewJournal(vault, vault, "users/", "replication/", &mu)
+	put := func(ctx context.Context, key string) {
+		t.Helper()
+		require.NoError(t, journal.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte("{}")}))
+	}
+
+	require.NoError(t, vault.Put(ctx, &logical.StorageEntry{Key: "users/before", Value: []byte("{}")}))
+	tracked, err := journal.Track(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tracked)
+
+	put(ctx, "users/abc")
+	put(ctx, "users/abc")
+	put(ctx, "config/features")
+	require.NoError(t, journal.Delete(ctx, "users/before"))
+
+	changes, err := JournalChanges(ctx, vault, "replication/", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 2, "only the last change of a record is kept")
+	assert.Equal(t, Change{Seq: 3, Name: "abc", Version: 2, Time: changes[0].Time}, changes[0])
+	assert.Equal(t, Change{Seq: 4, Name: "before", Version: 2, Deleted: true, Time: changes[1].Time}, changes[1])
+	seq, err := JournalSequence(ctx, vault, "replication/")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), seq)
+
+	t.Run("pages", func(t *testing.T) {
+		changes, err := JournalChanges(ctx, vault, "replication/", 3, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "before", changes[0].Name)
+
+		changes, err = JournalChanges(ctx, vault, "replication/", 0, 1)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "abc", changes[0].Name)
+	})
+
+	t.Run("replicated writes keep their version", func(t *testing.T) {
+		put(WithReplicatedVersion(ctx, 7), "users/abc")
+		last, err := JournalVersion(ctx, vault, "replication/", "abc")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(7), last.Version)
+		assert.True(t, last.Replicated)
+
+		put(ctx, "users/abc")
+		last, err = JournalVersion(ctx, vault, "replication/", "abc")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(8), last.Version)
+		assert.False(t, last.Replicated)
+	})
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/payment-system/dq-vault/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Traced is a logical.Storage recording a span for every operation on next, as a child of the
+// span of the request
+type Traced struct {
+	next logical.Storage
+}
+
+// NewTraced traces the operations on next
+func NewTraced(next logical.Storage) *Traced {
+	return &Traced{next: next}
+}
+
+// List lists the keys under prefix
+func (t *Traced) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "storage.list", attribute.String("vault.storage.key", prefix))
+	keys, err := t.next.List(ctx, prefix)
+	span.SetAttributes(attribute.Int("vault.storage.keys", len(keys)))
+	tracing.End(span, err)
+	return keys, err
+}
+
+// Get reads key
+func (t *Traced) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	ctx, span := tracing.Start(ctx, "storage.get", attribute.String("vault.storage.key", key))
+	entry, err := t.next.Get(ctx, key)
+	span.SetAttributes(attribute.Bool("vault.storage.found", entry != nil))
+	tracing.End(span, err)
+	return entry, err
+}
+
+// Put writes entry
+func (t *Traced) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	ctx, span := tracing.Start(ctx, "storage.put", attribute.String("vault.storage.key", entry.Key))
+	err := t.next.Put(ctx, entry)
+	tracing.End(span, err)
+	return err
+}
+
+// Delete removes key
+func (t *Traced) Delete(ctx context.Context, key string) error {
+	ctx, span := tracing.Start(ctx, "storage.delete", attribute.String("vault.storage.key", key))
+	err := t.next.Delete(ctx, key)
+	tracing.End(span, err)
+	return err
+}
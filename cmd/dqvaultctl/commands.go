@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// defaultBatchSize stays below the default maxBatchCount quota of the engine
+const defaultBatchSize = 500
+
+// ctl runs the commands against the engine mounted at mount
+type ctl struct {
+	logical *vaultapi.Logical
+	mount   string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func (c *ctl) path(path string) string {
+	return c.mount + "/" + path
+}
+
+// write writes data to path, failing on responses without data
+func (c *ctl) write(path string, data map[string]interface{}) (map[string]interface{}, error) {
+	secret, err := c.logical.Write(c.path(path), data)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrEmptyResponse, path)
+	}
+	return secret.Data, nil
+}
+
+func (c *ctl) flagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: dqvaultctl %s [flags]\n\n%s\n\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// required fails when one of the named flags of fs is empty
+func required(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if fs.Lookup(name).Value.String() == "" {
+			fs.Usage()
+			return fmt.Errorf("%w: -%s", ErrMissingFlag, name)
+		}
+	}
+	return nil
+}
+
+// create opens the output file name, or returns stdout when name is empty or "-"
+func (c *ctl) create(name string) (io.Writer, func() error, error) {
+	if name == "" || name == "-" {
+		return c.stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// register registers a user. The mnemonic and passphrase are read from files, so they stay out
+// of the shell history.
+func (c *ctl) register(args []string) error {
+	fs := c.flagSet("register", "Registers a user and prints its UUID and master key fingerprint.")
+	uuid := fs.String("uuid", "", "UUID of the user (generated when empty)")
+	username := fs.String("username", "", "username of the user")
+	mnemonicFile := fs.String("mnemonic-file", "", "file holding the mnemonic to import (generated when empty)")
+	passphraseFile := fs.String("passphrase-file", "", "file holding the BIP-39 passphrase")
+	allowedCoinTypes := fs.String("allowed-coin-types", "", "comma separated coin types the user may use")
+	ttl := fs.Duration("ttl", 0, "lifetime of the user, after which it is disabled")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"username": *username}
+	for key, name := range map[string]string{"mnemonic": *mnemonicFile, "passphrase": *passphraseFile} {
+		if name == "" {
+			continue
+		}
+		secret, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		data[key] = strings.TrimSpace(string(secret))
+	}
+	if *allowedCoinTypes != "" {
+		data["allowedCoinTypes"] = *allowedCoinTypes
+	}
+	if *ttl > 0 {
+		data["ttl"] = ttl.String()
+	}
+
+	path := "register_uuid"
+	if *uuid != "" {
+		path, data["uuid"] = "register", *uuid
+	}
+	resp, err := c.write(path, data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.stdout, "%v\t%v\n", resp["uuid"], resp["fingerprint"])
+	return err
+}
+
+// list prints the UUIDs of the registered users, one per line
+func (c *ctl) list(args []string) error {
+	fs := c.flagSet("list", "Lists the UUIDs of the registered users.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	secret, err := c.logical.List(c.path("user"))
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return nil
+	}
+	keys, _ := secret.Data["keys"].([]interface{})
+	for _, key := range keys {
+		if _, err := fmt.Fprintln(c.stdout, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addresses derives count addresses from start with address/batch, in requests of at most
+// batch addresses, and writes them as index,path,address CSV rows
+func (c *ctl) addresses(args []string) error {
+	fs := c.flagSet("addresses", "Derives a batch of addresses of a user to CSV (index,path,address).")
+	uuid := fs.String("uuid", "", "UUID of the user")
+	coinType := fs.Int("coin-type", -1, "SLIP-44 coin type")
+	pathTemplate := fs.String("path-template", "", "derivation path with %d for the index, e.g. m/44'/60'/0'/0/%d")
+	start := fs.Int("start", 0, "first address index")
+	count := fs.Int("count", 1, "number of addresses")
+	batch := fs.Int("batch", defaultBatchSize, "addresses per request, at most the maxBatchCount quota")
+	isDev := fs.Bool("dev", false, "derive testnet addresses")
+	apiKey := fs.String("api-key", os.Getenv("DQVAULT_API_KEY"), "scoped API key (DQVAULT_API_KEY)")
+	out := fs.String("o", "", "output file (stdout when empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "uuid", "path-template"); err != nil {
+		return err
+	}
+	if *coinType < 0 || *count <= 0 || *batch <= 0 || !strings.Contains(*pathTemplate, "%d") {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	w, closeOutput, err := c.create(*out)
+	if err != nil {
+		return err
+	}
+	rows := csv.NewWriter(w)
+	if err := rows.Write([]string{"index", "path", "address"}); err != nil {
+		return err
+	}
+
+	for first := *start; first < *start+*count; first += *batch {
+		n := min(*batch, *start+*count-first)
+		data := map[string]interface{}{
+			"uuid": *uuid, "coinType": *coinType, "pathTemplate": *pathTemplate,
+			"startIndex": first, "count": n, "isDev": *isDev,
+		}
+		if *apiKey != "" {
+			data["apiKey"] = *apiKey
+		}
+		resp, err := c.write("address/batch", data)
+		if err != nil {
+			_ = closeOutput()
+			return err
+		}
+		addresses, _ := resp["addresses"].(map[string]interface{})
+		for i := first; i < first+n; i++ {
+			path := fmt.Sprintf(*pathTemplate, i)
+			if err := rows.Write([]string{strconv.Itoa(i), path, fmt.Sprint(addresses[path])}); err != nil {
+				_ = closeOutput()
+				return err
+			}
+		}
+	}
+
+	rows.Flush()
+	if err := rows.Error(); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	return closeOutput()
+}
+
+// signLine is a line of the payload file of sign
+type signLine struct {
+	// Path overrides the -path flag for this payload
+	Path    string          `json:"path"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// signResult is a line of the output of sign
+type signResult struct {
+	Line      int    `json:"line"`
+	Path      string `json:"path"`
+	Address   string `json:"address,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// sign signs every payload of a JSON Lines file. Each line is {"path": ..., "payload": ...}, the
+// payload being the JSON document of the coin or a string for raw payloads such as PSBTs. Every
+// line gets a result line, failed payloads do not stop the others.
+func (c *ctl) sign(args []string) error {
+	fs := c.flagSet("sign", `Signs a file of payloads, one {"path": ..., "payload": ...} document per line,
+and writes one {"line", "path", "address", "signature"} or {"line", "error"} result per line.`)
+	uuid := fs.String("uuid", "", "UUID of the user")
+	coinType := fs.Int("coin-type", -1, "SLIP-44 coin type")
+	defaultPath := fs.String("path", "", "derivation path of the payloads without one")
+	payloads := fs.String("payloads", "-", "payload file (stdin when -)")
+	isDev := fs.Bool("dev", false, "sign for testnet")
+	apiKey := fs.String("api-key", os.Getenv("DQVAULT_API_KEY"), "scoped API key (DQVAULT_API_KEY)")
+	out := fs.String("o", "", "output file (stdout when empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "uuid"); err != nil {
+		return err
+	}
+	if *coinType < 0 {
+		fs.Usage()
+		return ErrUsage
+	}
+
+	in := c.stdin
+	if *payloads != "-" {
+		f, err := os.Open(*payloads)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	w, closeOutput, err := c.create(*out)
+	if err != nil {
+		return err
+	}
+	results := json.NewEncoder(w)
+
+	failed := 0
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		result := c.signLine(line, scanner.Bytes(), *defaultPath, map[string]interface{}{
+			"uuid": *uuid, "coinType": *coinType, "isDev": *isDev,
+		}, *apiKey)
+		if result.Error != "" {
+			failed++
+		}
+		if err := results.Encode(result); err != nil {
+			_ = closeOutput()
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	if err := closeOutput(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d", ErrFailedPayloads, failed)
+	}
+	return nil
+}
+
+func (c *ctl) signLine(line int, raw []byte, defaultPath string, data map[string]interface{},
+	apiKey string) signResult {
+	result := signResult{Line: line, Path: defaultPath}
+	var parsed signLine
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if parsed.Path != "" {
+		result.Path = parsed.Path
+	}
+
+	// raw payloads are JSON strings, coin payloads are sent as their JSON document
+	payload := string(parsed.Payload)
+	var s string
+	if json.Unmarshal(parsed.Payload, &s) == nil {
+		payload = s
+	}
+	data["derivationPath"], data["payload"] = result.Path, payload
+	if apiKey != "" {
+		data["apiKey"] = apiKey
+	}
+
+	resp, err := c.write("sign", data)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Address, _ = resp["address"].(string)
+	result.Signature, _ = resp["signature"].(string)
+	return result
+}
+
+// export writes the watch-only bundle of a user as indented JSON
+func (c *ctl) export(args []string) error {
+	fs := c.flagSet("export", "Exports the watch-only descriptors and xpubs of a user as JSON.")
+	uuid := fs.String("uuid", "", "UUID of the user")
+	account := fs.Int("account", 0, "account index")
+	isDev := fs.Bool("dev", false, "export the Bitcoin testnet accounts")
+	out := fs.String("o", "", "output file (stdout when empty)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := required(fs, "uuid"); err != nil {
+		return err
+	}
+
+	secret, err := c.logical.ReadWithData(c.path("export/watch-only/"+*uuid), map[string][]string{
+		"account": {strconv.Itoa(*account)},
+		"isDev":   {strconv.FormatBool(*isDev)},
+	})
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Data == nil {
+		return fmt.Errorf("%w: export/watch-only", ErrEmptyResponse)
+	}
+
+	w, closeOutput, err := c.create(*out)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(secret.Data); err != nil {
+		_ = closeOutput()
+		return err
+	}
+	return closeOutput()
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/api/dqvaulttest"
+)
+
+const testEVMPayload = `{"nonce":42,"value":1000000000000000000,"gasLimit":21000,"gasPrice":20000000000,` +
+	`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0x","chainId":1}`
+
+// newTestVault serves the HTTP API of a Vault server with the engine mounted at dq/, and points
+// the Vault client of the commands at it
+func newTestVault(t *testing.T) *dqvaulttest.Harness {
+	t.Helper()
+	h := dqvaulttest.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/dq/")
+		var operation logical.Operation = logical.ReadOperation
+		data := map[string]interface{}{}
+		switch {
+		case r.URL.Query().Get("list") == "true":
+			operation = logical.ListOperation
+			path = strings.TrimSuffix(path, "/") + "/"
+		case r.Method == http.MethodPut || r.Method == http.MethodPost:
+			operation = logical.UpdateOperation
+			decoder := json.NewDecoder(r.Body)
+			decoder.UseNumber()
+			require.NoError(t, decoder.Decode(&data))
+		default:
+			for key, values := range r.URL.Query() {
+				data[key] = values[0]
+			}
+		}
+
+		resp, err := h.Request(operation, path, data)
+		if err != nil {
+			code := http.StatusInternalServerError
+			var codedErr logical.HTTPCodedError
+			if errors.As(err, &codedErr) {
+				code = codedErr.Code()
+			}
+			w.WriteHeader(code)
+			_ = json.NewEncoder(w).Encode(map[string][]string{"errors": {err.Error()}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": resp.Data})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test")
+	return h
+}
+
+// runCommand runs dqvaultctl with args and returns its output
+func runCommand(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	newTestVault(t)
+	dir := t.TempDir()
+	mnemonicFile := filepath.Join(dir, "mnemonic")
+	require.NoError(t, os.WriteFile(mnemonicFile, []byte(dqvaulttest.TestMnemonic+"\n"), 0o600))
+
+	out, err := runCommand(t, "", "register", "-uuid", "treasury", "-mnemonic-file", mnemonicFile)
+	require.NoError(t, err)
+	assert.Equal(t, "treasury\t73c5da0a\n", out)
+	_, err = runCommand(t, "", "register", "-username", "generated")
+	require.NoError(t, err)
+
+	t.Run("list", func(t *testing.T) {
+		out, err := runCommand(t, "", "list")
+		require.NoError(t, err)
+		uuids := strings.Fields(out)
+		assert.Len(t, uuids, 2)
+		assert.Contains(t, uuids, "treasury")
+	})
+
+	t.Run("addresses are written to csv in batches", func(t *testing.T) {
+		output := filepath.Join(dir, "addresses.csv")
+		_, err := runCommand(t, "", "addresses", "-uuid", "treasury", "-coin-type", "60",
+			"-path-template", "m/44'/60'/0'/0/%d", "-count", "3", "-batch", "2", "-o", output)
+		require.NoError(t, err)
+
+		f, err := os.Open(output)
+		require.NoError(t, err)
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, rows, 4)
+		assert.Equal(t, []string{"index", "path", "address"}, rows[0])
+		assert.Equal(t, []string{"0", "m/44'/60'/0'/0/0", dqvaulttest.TestEVMAddress}, rows[1])
+		assert.Equal(t, "m/44'/60'/0'/0/2", rows[3][1])
+	})
+
+	t.Run("sign reports every payload", func(t *testing.T) {
+		payloads := `{"payload":` + testEVMPayload + `}` + "\n\n" +
+			`{"path":"m/44'/60'/0'/0/1","payload":{"nonce":1}}` + "\n"
+		out, err := runCommand(t, payloads, "sign", "-uuid", "treasury", "-coin-type", "60",
+			"-path", "m/44'/60'/0'/0/0")
+		require.ErrorIs(t, err, ErrFailedPayloads)
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 2)
+		var signed, failed signResult
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &signed))
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &failed))
+
+		assert.Equal(t, 1, signed.Line)
+		assert.Equal(t, dqvaulttest.TestEVMAddress, signed.Address)
+		dqvaulttest.RequireEVMSender(t, signed.Signature, signed.Address)
+		assert.Equal(t, 3, failed.Line)
+		assert.Equal(t, "m/44'/60'/0'/0/1", failed.Path)
+		assert.NotEmpty(t, failed.Error)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		out, err := runCommand(t, "", "export", "-uuid", "treasury")
+		require.NoError(t, err)
+		var bundle map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(out), &bundle))
+		assert.Equal(t, "73c5da0a", bundle["fingerprint"])
+	})
+
+	t.Run("usage errors", func(t *testing.T) {
+		_, err := runCommand(t, "")
+		require.ErrorIs(t, err, ErrUsage)
+		_, err = runCommand(t, "", "rotate")
+		require.ErrorIs(t, err, ErrUnknownCommand)
+		_, err = runCommand(t, "", "addresses", "-coin-type", "60")
+		require.ErrorIs(t, err, ErrMissingFlag)
+	})
+}
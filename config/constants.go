@@ -5,6 +5,185 @@ const (
 	// Example: <StorageBasePath>/<user-uuid>
 	StorageBasePath = "users/"
 
+	// ConfigStoragePath base path where mount level configuration is stored in vault
+	// Example: <ConfigStoragePath><subsystem>
+	ConfigStoragePath = "config/"
+
+	// FeaturesStorageKey stores the feature flags of the mount
+	FeaturesStorageKey = ConfigStoragePath + "features"
+
+	// QuotasStorageKey stores the request quotas of the mount
+	QuotasStorageKey = ConfigStoragePath + "quotas"
+
+	// LoggingStorageKey stores the logging configuration of the mount
+	LoggingStorageKey = ConfigStoragePath + "logging"
+
+	// DebugSessionsStoragePath stores the debug capture session of a user
+	// Example: <DebugSessionsStoragePath><user-uuid>
+	DebugSessionsStoragePath = ConfigStoragePath + "debug/"
+
+	// RPCStoragePath stores the chain node endpoints of the mount
+	// Example: <RPCStoragePath><coin-type>
+	RPCStoragePath = ConfigStoragePath + "rpc/"
+
+	// HooksStoragePath stores the payload hook chains of the mount
+	// Example: <HooksStoragePath><coin-type>
+	HooksStoragePath = ConfigStoragePath + "hooks/"
+
+	// UserCacheStorageKey stores the configuration of the in-memory cache of the user records
+	UserCacheStorageKey = ConfigStoragePath + "cache"
+
+	// RetentionStorageKey stores how long the debug captures of the mount are kept
+	RetentionStorageKey = ConfigStoragePath + "retention"
+
+	// UserStoreStorageKey stores where the user records of the mount are persisted
+	UserStoreStorageKey = ConfigStoragePath + "storage"
+
+	// DEKRotationStorageKey stores the progress of the rotation of the encryption key of the user records
+	DEKRotationStorageKey = ConfigStoragePath + "rotate-dek"
+
+	// ReplicationStorageKey stores the key the user records replicated to another cluster are encrypted with
+	ReplicationStorageKey = ConfigStoragePath + "replication"
+
+	// ReplicationStoragePath base path of the journal of the user record writes to replicate
+	// Example: <ReplicationStoragePath>changes/<sequence number>, <ReplicationStoragePath>versions/<user-uuid>
+	ReplicationStoragePath = "replication/"
+
+	// AttestationStorageKey stores the key the responses of the mount are attested with
+	AttestationStorageKey = ConfigStoragePath + "attestation"
+
+	// EscrowStorageKey stores the custodian public key the mnemonics are escrowed to
+	EscrowStorageKey = ConfigStoragePath + "escrow"
+
+	// EscrowStoragePath base path where the escrow records of the mnemonics are stored
+	// Example: <EscrowStoragePath><user-uuid>
+	EscrowStoragePath = "escrow/"
+
+	// APIKeysStoragePath base path where the scoped API keys are stored
+	// Example: <APIKeysStoragePath><key-name>
+	APIKeysStoragePath = "apikeys/"
+
+	// SigningSessionsStoragePath base path where the delegated signing sessions are stored
+	// Example: <SigningSessionsStoragePath><session-id>
+	SigningSessionsStoragePath = "sessions/"
+
+	// DebugStoragePath base path where the sanitized captures of debug sessions are stored
+	// Example: <DebugStoragePath><user-uuid>/<capture-id>
+	DebugStoragePath = "debug/"
+
+	// AddressLedgerStoragePath base path where the address indexes allocated by address/next are recorded
+	// Example: <AddressLedgerStoragePath><user-uuid>/<coin-type>/<account>/<change>
+	AddressLedgerStoragePath = "addresses/"
+
+	// AddressIndexStoragePath base path of the reverse index of the derived addresses
+	// Example: <AddressIndexStoragePath><sha256 of the address>
+	AddressIndexStoragePath = "index/addresses/"
+
+	// BackupVerificationStoragePath base path where the failed backup verifications of the users are counted
+	// Example: <BackupVerificationStoragePath><user-uuid>
+	BackupVerificationStoragePath = "backup-verification/"
+
+	// ViolationsStoragePath base path where the policy violations of the users are noted
+	// Example: <ViolationsStoragePath><user-uuid>
+	ViolationsStoragePath = "violations/"
+
+	// MultisigStoragePath base path where the Bitcoin multisig wallets of the users are stored
+	// Example: <MultisigStoragePath><user-uuid>/<wallet-name>
+	MultisigStoragePath = "multisig/"
+
+	// ApprovalsStorageKey stores the approval policy and the chat webhook of the mount
+	ApprovalsStorageKey = ConfigStoragePath + "approvals"
+
+	// FeeBoundsStoragePath stores the fee rate bounds of the sign payloads of the mount
+	// Example: <FeeBoundsStoragePath><coin-type>
+	FeeBoundsStoragePath = ConfigStoragePath + "fees/"
+
+	// ApprovalsStoragePath base path where the sign requests held for approval are stored
+	// Example: <ApprovalsStoragePath><approval-id>
+	ApprovalsStoragePath = "approvals/"
+
+	// AddressBookStoragePath base path where the named counterparties of the address book are stored
+	// Example: <AddressBookStoragePath><entry-name>
+	AddressBookStoragePath = "addressbook/"
+
+	// AddressBookPolicyStorageKey stores the address book tags the recipients of the signing paths must carry
+	AddressBookPolicyStorageKey = ConfigStoragePath + "addressbook"
+
+	// AssetsStoragePath base path where the tokens of the asset registry are stored
+	// Example: <AssetsStoragePath><coin-type>/<symbol>
+	AssetsStoragePath = ConfigStoragePath + "assets/"
+
+	// ContractPolicyStorageKey stores the coin types whose sign payloads may only call registered assets
+	ContractPolicyStorageKey = ConfigStoragePath + "contracts"
+
+	// TravelRuleStorageKey stores the sink the travel rule data of the signed transfers is forwarded to
+	TravelRuleStorageKey = ConfigStoragePath + "travelrule"
+
+	// ReceiptsStoragePath base path where the receipts of the signatures of sign are stored
+	// Example: <ReceiptsStoragePath><receipt-id>
+	ReceiptsStoragePath = "receipts/"
+
+	// ReceiptSequenceStorageKey stores the sequence number of the last receipt of the mount
+	ReceiptSequenceStorageKey = ReceiptsStoragePath + "sequence"
+
+	// ArchiveStorageKey stores the S3-compatible bucket the audit data of the mount is archived to
+	ArchiveStorageKey = ConfigStoragePath + "archive"
+
+	// ArchiveStateStorageKey stores how far the audit data of the mount was archived
+	ArchiveStateStorageKey = "archive/state"
+
+	// KafkaStorageKey stores the Kafka topic the events of the mount are published to
+	KafkaStorageKey = ConfigStoragePath + "kafka"
+
+	// TracingStorageKey stores the collector the OpenTelemetry spans of the requests are exported to
+	TracingStorageKey = ConfigStoragePath + "tracing"
+
+	// PassphrasePolicyStorageKey stores the strength policy of the passphrases of new users
+	PassphrasePolicyStorageKey = ConfigStoragePath + "passphrase"
+
+	// EventsStoragePath base path where the events waiting to be published to Kafka are queued
+	// Example: <EventsStoragePath><event-id>
+	EventsStoragePath = "events/"
+
+	// BatchWALStoragePath base path where the progress of the sign/batch requests named by a batchId is logged
+	// Example: <BatchWALStoragePath><batch-id>
+	BatchWALStoragePath = "wal/batches/"
+
+	// JobsStoragePath base path where the async jobs are persisted with their checkpointed results
+	// Example: <JobsStoragePath><job-id>
+	JobsStoragePath = "jobs/"
+
+	// CanaryStorageKey stores the canary users whose signatures are produced by the periodic function
+	CanaryStorageKey = ConfigStoragePath + "canary"
+
+	// DeprecationStorageKey stores the sunset of the legacy field names of the mount and whether they are rejected
+	DeprecationStorageKey = ConfigStoragePath + "deprecation"
+
+	// EntropyStorageKey stores the entropy source of the mnemonics generated by the mount
+	EntropyStorageKey = ConfigStoragePath + "entropy"
+
+	// DerivationPolicyStorageKey stores the policy bounding the derivation paths of the requests
+	DerivationPolicyStorageKey = ConfigStoragePath + "derivation"
+
+	// AddressOverridesStorageKey stores the address encoding overrides the address requests may use
+	AddressOverridesStorageKey = ConfigStoragePath + "address-overrides"
+
+	// LockoutStorageKey stores the number of policy violations within a window locking a user
+	LockoutStorageKey = ConfigStoragePath + "lockout"
+
+	// CanaryStoragePath base path where the last canary signature of each canary user is stored
+	// Example: <CanaryStoragePath><user-uuid>
+	CanaryStoragePath = "canary/"
+
+	// BudgetsStoragePath base path where the signed-value budgets of the users are stored
+	// Example: <BudgetsStoragePath><user-uuid>/<coin-type>
+	BudgetsStoragePath = "budgets/"
+
+	// PassphraseStoragePath base path where the passphrases of the user records are kept apart from them,
+	// seal wrapped, when config/storage splits them
+	// Example: <PassphraseStoragePath><user-uuid>
+	PassphraseStoragePath = "passphrases/"
+
 	// Entropy is default  length of the bits in the entropy
 	Entropy = 256
 
@@ -15,6 +194,7 @@ const (
 // supported log levels
 const (
 	Info  = "INFO"
+	Warn  = "WARN"
 	Error = "ERROR"
 	Debug = "DEBUG"
 	Fatal = "FATAL"
@@ -0,0 +1,271 @@
+package algorand
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// checksumLength is the number of trailing hash bytes appended to an address
+	checksumLength = 4
+	// maxGroupSize is the largest atomic transaction group accepted by the network
+	maxGroupSize = 16
+	// transactionPrefix is the domain separator for transaction ids and signatures
+	transactionPrefix = "TX"
+	// groupPrefix is the domain separator for transaction group ids
+	groupPrefix = "TG"
+)
+
+// addressEncoding is the unpadded base32 alphabet used by Algorand addresses
+var addressEncoding = base32.StdEncoding.WithPadding(base32.NoPadding) //nolint:gochecknoglobals
+
+// Adapter represents an Algorand blockchain adapter
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewAlgorandAdapter creates a new Algorand adapter instance
+func NewAlgorandAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "algorand")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (a *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Algorand
+}
+
+// Capabilities describes the coins and formats handled by the Algorand adapter
+func (a *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "algorand",
+		CoinTypes:      []uint16{slip44.Algorand},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"base32 public key with checksum"},
+		Payload:        lib.AlgorandRawTx{},
+		Operations:     lib.DefaultOperations(),
+	}
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (a *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives a public key from the given seed and derivation path
+func (a *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the base32 account address (public key followed by a 4 byte checksum)
+func (a *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	address := EncodeAddress(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Address derived successfully", "address", address)
+
+	return address, nil
+}
+
+// ValidatePayload reports the field errors of an Algorand sign payload
+func (a *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	txns, ok := v.Strings("txns", true)
+	if ok && len(txns) > maxGroupSize {
+		v.Fail("txns", ErrInvalidGroupSize.Error())
+	}
+	for i, txn := range txns {
+		if _, err := parseTransaction(txn); err != nil {
+			v.Fail(fmt.Sprintf("txns[%d]", i), err.Error())
+		}
+	}
+	return v.Err()
+}
+
+// CreateSignedTransaction signs every transaction of the group sent by the derived
+// account and returns the hex encoded concatenation of the signed transactions, in
+// group order. Transactions sent by other accounts are left to their owners.
+func (a *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := a.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.AlgorandRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	txns, err := parseGroup(rawTx.Txns)
+	if err != nil {
+		logger.Error("Invalid transaction group", "error", err)
+		return "", err
+	}
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	var signed []byte
+	for _, txn := range txns {
+		if !bytes.Equal(txn.sender, publicKey) {
+			continue
+		}
+		signature := ed25519.Sign(privateKey, append([]byte(transactionPrefix), txn.raw...))
+		signed = append(signed, encodeMap([]mapEntry{
+			{key: "sig", value: appendBin(nil, signature)},
+			{key: "txn", value: txn.raw},
+		})...)
+	}
+	if len(signed) == 0 {
+		return "", ErrNoOwnedTransactions
+	}
+
+	txHex := hex.EncodeToString(signed)
+	logger.Info("Signed transaction created successfully", "tx", txHex)
+
+	return txHex, nil
+}
+
+// EncodeAddress returns the checksummed base32 address of an ed25519 public key
+func EncodeAddress(publicKey ed25519.PublicKey) string {
+	checksum := sha512.Sum512_256(publicKey)
+	return addressEncoding.EncodeToString(append(bytes.Clone(publicKey), checksum[len(checksum)-checksumLength:]...))
+}
+
+// transaction is a msgpack transaction with the fields the vault inspects
+type transaction struct {
+	raw     []byte
+	entries []mapEntry
+	sender  []byte
+	group   []byte
+}
+
+func parseTransaction(txHex string) (transaction, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(txHex, "0x"))
+	if err != nil {
+		return transaction{}, ErrInvalidTransaction
+	}
+	entries, err := decodeMap(raw)
+	if err != nil {
+		return transaction{}, err
+	}
+
+	txn := transaction{raw: raw, entries: entries}
+	hasType := false
+	for _, e := range entries {
+		switch e.key {
+		case "type":
+			hasType = true
+		case "snd":
+			txn.sender, err = decodeBin(e.value)
+		case "grp":
+			txn.group, err = decodeBin(e.value)
+		}
+		if err != nil {
+			return transaction{}, err
+		}
+	}
+	if !hasType || len(txn.sender) != ed25519.PublicKeySize {
+		return transaction{}, ErrInvalidTransaction
+	}
+	return txn, nil
+}
+
+// idWithoutGroup returns the transaction id with the group field removed, as hashed into the group id
+func (t transaction) idWithoutGroup() [32]byte {
+	entries := make([]mapEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		if e.key != "grp" {
+			entries = append(entries, e)
+		}
+	}
+	return sha512.Sum512_256(append([]byte(transactionPrefix), encodeMap(entries)...))
+}
+
+// parseGroup decodes the transactions and checks that grouped transactions
+// carry the group id derived from the whole group
+func parseGroup(txHexes []string) ([]transaction, error) {
+	if len(txHexes) == 0 || len(txHexes) > maxGroupSize {
+		return nil, ErrInvalidGroupSize
+	}
+
+	txns := make([]transaction, 0, len(txHexes))
+	for _, txHex := range txHexes {
+		txn, err := parseTransaction(txHex)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+
+	if len(txns) == 1 && txns[0].group == nil {
+		return txns, nil
+	}
+
+	groupID := computeGroupID(txns)
+	for _, txn := range txns {
+		if !bytes.Equal(txn.group, groupID[:]) {
+			return nil, ErrGroupMismatch
+		}
+	}
+	return txns, nil
+}
+
+// computeGroupID hashes the ids of the transactions as the network does for atomic groups
+func computeGroupID(txns []transaction) [32]byte {
+	txList := appendArrayHeader(nil, len(txns))
+	for _, txn := range txns {
+		id := txn.idWithoutGroup()
+		txList = appendBin(txList, id[:])
+	}
+	group := encodeMap([]mapEntry{{key: "txlist", value: txList}})
+	return sha512.Sum512_256(append([]byte(groupPrefix), group...))
+}
@@ -0,0 +1,193 @@
+package algorand
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// SLIP-0010 ed25519 test vector 1 seed and its m/0' child
+	testSeedHex        = "000102030405060708090a0b0c0d0e0f"
+	testDerivationPath = "m/0'"
+	testPublicKeyHex   = "8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"
+	// zeroAddress is the well known address of the all zero public key
+	zeroAddress = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAY5HFKQ"
+)
+
+func newTestAdapter() *Adapter {
+	return NewAlgorandAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+	return seed
+}
+
+func testPublicKey(t *testing.T) ed25519.PublicKey {
+	publicKey, err := hex.DecodeString(testPublicKeyHex)
+	require.NoError(t, err)
+	return publicKey
+}
+
+// newPayment returns the canonical (sorted keys) entries of a payment transaction
+func newPayment(sender, receiver []byte, amount uint64) []mapEntry {
+	genesisHash := make([]byte, 32)
+	return []mapEntry{
+		{key: "amt", value: appendUint(nil, amount)},
+		{key: "fee", value: appendUint(nil, 1000)},
+		{key: "fv", value: appendUint(nil, 1000)},
+		{key: "gen", value: appendStr(nil, "testnet-v1.0")},
+		{key: "gh", value: appendBin(nil, genesisHash)},
+		{key: "lv", value: appendUint(nil, 2000)},
+		{key: "rcv", value: appendBin(nil, receiver)},
+		{key: "snd", value: appendBin(nil, sender)},
+		{key: "type", value: appendStr(nil, "pay")},
+	}
+}
+
+// withGroup inserts the group id after "gh", keeping canonical key order
+func withGroup(entries []mapEntry, groupID [32]byte) []mapEntry {
+	out := make([]mapEntry, 0, len(entries)+1)
+	for _, e := range entries {
+		out = append(out, e)
+		if e.key == "gh" {
+			out = append(out, mapEntry{key: "grp", value: appendBin(nil, groupID[:])})
+		}
+	}
+	return out
+}
+
+func groupHexes(t *testing.T, payments ...[]mapEntry) []string {
+	txns := make([]transaction, 0, len(payments))
+	for _, p := range payments {
+		txn, err := parseTransaction(hex.EncodeToString(encodeMap(p)))
+		require.NoError(t, err)
+		txns = append(txns, txn)
+	}
+	groupID := computeGroupID(txns)
+
+	out := make([]string, 0, len(payments))
+	for _, p := range payments {
+		out = append(out, hex.EncodeToString(encodeMap(withGroup(p, groupID))))
+	}
+	return out
+}
+
+func sign(t *testing.T, txns []string) (string, error) {
+	payload, err := json.Marshal(lib.AlgorandRawTx{Txns: txns})
+	require.NoError(t, err)
+	return newTestAdapter().CreateSignedTransaction(testSeed(t), testDerivationPath, string(payload))
+}
+
+func TestAlgorandAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Algorand))
+	assert.False(t, adapter.CanDo(slip44.Solana))
+}
+
+func TestAlgorandAdapter_DeriveAddress(t *testing.T) {
+	assert.Equal(t, zeroAddress, EncodeAddress(make(ed25519.PublicKey, ed25519.PublicKeySize)))
+
+	address, err := newTestAdapter().DeriveAddress(testSeed(t), testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, EncodeAddress(testPublicKey(t)), address)
+	assert.Len(t, address, len(zeroAddress))
+}
+
+func TestAlgorandAdapter_CreateSignedTransaction(t *testing.T) {
+	own := testPublicKey(t)
+	other := make([]byte, ed25519.PublicKeySize)
+	other[0] = 1
+
+	t.Run("single transaction", func(t *testing.T) {
+		raw := encodeMap(newPayment(own, other, 100000))
+
+		txHex, err := sign(t, []string{hex.EncodeToString(raw)})
+		require.NoError(t, err)
+		signed, err := hex.DecodeString(txHex)
+		require.NoError(t, err)
+
+		entries, err := decodeMap(signed)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "sig", entries[0].key)
+		assert.Equal(t, "txn", entries[1].key)
+		assert.Equal(t, raw, entries[1].value)
+
+		signature, err := decodeBin(entries[0].value)
+		require.NoError(t, err)
+		assert.True(t, ed25519.Verify(own, append([]byte(transactionPrefix), raw...), signature))
+	})
+
+	t.Run("group signs only owned transactions", func(t *testing.T) {
+		group := groupHexes(t, newPayment(own, other, 100000), newPayment(other, own, 5))
+
+		txHex, err := sign(t, group)
+		require.NoError(t, err)
+		signed, err := hex.DecodeString(txHex)
+		require.NoError(t, err)
+
+		entries, err := decodeMap(signed)
+		require.NoError(t, err)
+		assert.Equal(t, group[0], hex.EncodeToString(entries[1].value))
+	})
+
+	t.Run("group id must match", func(t *testing.T) {
+		group := groupHexes(t, newPayment(own, other, 100000), newPayment(other, own, 5))
+		tampered := groupHexes(t, newPayment(own, other, 100000), newPayment(other, own, 6))
+
+		_, err := sign(t, []string{group[0], tampered[1]})
+		assert.ErrorIs(t, err, ErrGroupMismatch)
+
+		_, err = sign(t, []string{
+			hex.EncodeToString(encodeMap(newPayment(own, other, 1))),
+			hex.EncodeToString(encodeMap(newPayment(own, other, 2))),
+		})
+		assert.ErrorIs(t, err, ErrGroupMismatch)
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		_, err := sign(t, []string{hex.EncodeToString(encodeMap(newPayment(other, own, 5)))})
+		assert.ErrorIs(t, err, ErrNoOwnedTransactions)
+
+		_, err = sign(t, nil)
+		assert.ErrorIs(t, err, ErrInvalidGroupSize)
+
+		_, err = sign(t, []string{"82a3736967"})
+		assert.ErrorIs(t, err, ErrInvalidTransaction)
+
+		_, err = newTestAdapter().CreateSignedTransaction(testSeed(t), testDerivationPath, `{"txns":"x"}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+	})
+}
+
+func TestAlgorandAdapter_ValidatePayload(t *testing.T) {
+	adapter := newTestAdapter()
+	own := testPublicKey(t)
+
+	valid := `{"txns":["` + hex.EncodeToString(encodeMap(newPayment(own, own, 1))) + `"]}`
+	require.NoError(t, adapter.ValidatePayload(valid))
+
+	err := adapter.ValidatePayload(`{"txns":["zz","82a3736967"]}`)
+	var fieldErrs lib.PayloadErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, lib.PayloadErrors{
+		{Field: "txns[0]", Message: ErrInvalidTransaction.Error()},
+		{Field: "txns[1]", Message: ErrInvalidTransaction.Error()},
+	}, fieldErrs)
+
+	err = adapter.ValidatePayload(`{"txns":[]}`)
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, lib.PayloadErrors{{Field: "txns", Message: "empty"}}, fieldErrs)
+}
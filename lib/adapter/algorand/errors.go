@@ -0,0 +1,12 @@
+package algorand
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData  = errors.New("invalid payload data")
+	ErrInvalidTransaction  = errors.New("invalid msgpack transaction")
+	ErrInvalidGroupSize    = errors.New("a transaction group holds between 1 and 16 transactions")
+	ErrGroupMismatch       = errors.New("transaction group id does not match the transactions")
+	ErrNoOwnedTransactions = errors.New("no transaction in the group is sent by the derived account")
+)
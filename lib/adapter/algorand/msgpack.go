@@ -0,0 +1,265 @@
+package algorand
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// msgpack format bytes used by canonical Algorand encoding
+const (
+	mpFixMapMask   = 0x80
+	mpFixArrayMask = 0x90
+	mpFixStrMask   = 0xa0
+	mpNil          = 0xc0
+	mpFalse        = 0xc2
+	mpTrue         = 0xc3
+	mpBin8         = 0xc4
+	mpBin16        = 0xc5
+	mpBin32        = 0xc6
+	mpExt8         = 0xc7
+	mpExt16        = 0xc8
+	mpExt32        = 0xc9
+	mpFloat32      = 0xca
+	mpFloat64      = 0xcb
+	mpUint8        = 0xcc
+	mpUint16       = 0xcd
+	mpUint32       = 0xce
+	mpUint64       = 0xcf
+	mpInt8         = 0xd0
+	mpInt16        = 0xd1
+	mpInt32        = 0xd2
+	mpInt64        = 0xd3
+	mpFixExt1      = 0xd4
+	mpFixExt16     = 0xd8
+	mpStr8         = 0xd9
+	mpStr16        = 0xda
+	mpStr32        = 0xdb
+	mpArray16      = 0xdc
+	mpArray32      = 0xdd
+	mpMap16        = 0xde
+	mpMap32        = 0xdf
+	mpNegFixInt    = 0xe0
+	mpFixMax       = 0x0f
+	mpFixStrMax    = 0x1f
+	mpPosFixIntMax = 0x7f
+)
+
+// mapEntry is a decoded map key with its still encoded value
+type mapEntry struct {
+	key   string
+	value []byte
+}
+
+// decodeMap splits a msgpack map with string keys into its entries, keeping
+// every value in its original encoding
+func decodeMap(b []byte) ([]mapEntry, error) {
+	r := &mpReader{data: b}
+	count := r.mapHeader()
+	entries := make([]mapEntry, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		key := r.str()
+		start := r.pos
+		r.skipValue()
+		if r.err == nil {
+			entries = append(entries, mapEntry{key: key, value: b[start:r.pos]})
+		}
+	}
+	if r.err != nil || r.pos != len(b) {
+		return nil, ErrInvalidTransaction
+	}
+	return entries, nil
+}
+
+// decodeBin decodes a whole msgpack bin value
+func decodeBin(b []byte) ([]byte, error) {
+	r := &mpReader{data: b}
+	out := r.bin()
+	if r.err != nil || r.pos != len(b) {
+		return nil, ErrInvalidTransaction
+	}
+	return out, nil
+}
+
+// encodeMap writes the entries back as a msgpack map, in the given order
+func encodeMap(entries []mapEntry) []byte {
+	out := appendMapHeader(nil, len(entries))
+	for _, e := range entries {
+		out = appendStr(out, e.key)
+		out = append(out, e.value...)
+	}
+	return out
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	if n <= mpFixMax {
+		return append(b, mpFixMapMask|byte(n))
+	}
+	return binary.BigEndian.AppendUint16(append(b, mpMap16), uint16(n))
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	if n <= mpFixMax {
+		return append(b, mpFixArrayMask|byte(n))
+	}
+	return binary.BigEndian.AppendUint16(append(b, mpArray16), uint16(n))
+}
+
+func appendStr(b []byte, s string) []byte {
+	if len(s) <= mpFixStrMax {
+		return append(append(b, mpFixStrMask|byte(len(s))), s...)
+	}
+	return append(append(b, mpStr8, byte(len(s))), s...)
+}
+
+func appendBin(b []byte, data []byte) []byte {
+	return append(append(b, mpBin8, byte(len(data))), data...)
+}
+
+func appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= mpPosFixIntMax:
+		return append(b, byte(v))
+	case v <= 0xff:
+		return append(b, mpUint8, byte(v))
+	case v <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, mpUint16), uint16(v))
+	case v <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, mpUint32), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, mpUint64), v)
+	}
+}
+
+type mpReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *mpReader) next(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrInvalidTransaction
+		return nil
+	}
+	out := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *mpReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *mpReader) uint(n int) int {
+	var v uint64
+	for _, b := range r.next(n) {
+		v = v<<8 | uint64(b)
+	}
+	if v > uint64(len(r.data)) {
+		// no length or count can exceed the input size
+		r.err = ErrInvalidTransaction
+		return 0
+	}
+	return int(v)
+}
+
+func (r *mpReader) mapHeader() int {
+	switch t := r.byte(); {
+	case t&0xf0 == mpFixMapMask:
+		return int(t & mpFixMax)
+	case t == mpMap16:
+		return r.uint(2)
+	case t == mpMap32:
+		return r.uint(4)
+	default:
+		r.err = ErrInvalidTransaction
+		return 0
+	}
+}
+
+func (r *mpReader) str() string {
+	switch t := r.byte(); {
+	case t&0xe0 == mpFixStrMask:
+		return string(r.next(int(t & mpFixStrMax)))
+	case t == mpStr8:
+		return string(r.next(r.uint(1)))
+	case t == mpStr16:
+		return string(r.next(r.uint(2)))
+	default:
+		r.err = ErrInvalidTransaction
+		return ""
+	}
+}
+
+func (r *mpReader) bin() []byte {
+	switch r.byte() {
+	case mpBin8:
+		return bytes.Clone(r.next(r.uint(1)))
+	case mpBin16:
+		return bytes.Clone(r.next(r.uint(2)))
+	case mpBin32:
+		return bytes.Clone(r.next(r.uint(4)))
+	default:
+		r.err = ErrInvalidTransaction
+		return nil
+	}
+}
+
+// skipValue advances past one complete msgpack value
+func (r *mpReader) skipValue() {
+	t := r.byte()
+	if r.err != nil {
+		return
+	}
+	switch {
+	case t <= mpPosFixIntMax, t >= mpNegFixInt, t == mpNil, t == mpFalse, t == mpTrue:
+	case t&0xf0 == mpFixMapMask:
+		r.skipValues(2 * int(t&mpFixMax))
+	case t&0xf0 == mpFixArrayMask:
+		r.skipValues(int(t & mpFixMax))
+	case t&0xe0 == mpFixStrMask:
+		r.next(int(t & mpFixStrMax))
+	case t == mpBin8, t == mpStr8:
+		r.next(r.uint(1))
+	case t == mpBin16, t == mpStr16:
+		r.next(r.uint(2))
+	case t == mpBin32, t == mpStr32:
+		r.next(r.uint(4))
+	case t == mpExt8:
+		r.next(r.uint(1) + 1)
+	case t == mpExt16:
+		r.next(r.uint(2) + 1)
+	case t == mpExt32:
+		r.next(r.uint(4) + 1)
+	case t == mpFloat32, t == mpUint32, t == mpInt32:
+		r.next(4)
+	case t == mpFloat64, t == mpUint64, t == mpInt64:
+		r.next(8)
+	case t == mpUint8, t == mpInt8:
+		r.next(1)
+	case t == mpUint16, t == mpInt16:
+		r.next(2)
+	case t >= mpFixExt1 && t <= mpFixExt16:
+		r.next(1<<(t-mpFixExt1) + 1)
+	case t == mpArray16:
+		r.skipValues(r.uint(2))
+	case t == mpArray32:
+		r.skipValues(r.uint(4))
+	case t == mpMap16:
+		r.skipValues(2 * r.uint(2))
+	case t == mpMap32:
+		r.skipValues(2 * r.uint(4))
+	default:
+		r.err = ErrInvalidTransaction
+	}
+}
+
+func (r *mpReader) skipValues(n int) {
+	for i := 0; i < n && r.err == nil; i++ {
+		r.skipValue()
+	}
+}
@@ -0,0 +1,178 @@
+package aptos
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// ed25519Scheme is the authentication key scheme identifier for single ed25519 keys
+	ed25519Scheme = 0x00
+	// addressLength is the length of an account address in bytes
+	addressLength = 32
+	// rawTransactionSalt is hashed to build the signing message prefix
+	rawTransactionSalt = "APTOS::RawTransaction"
+	// authenticatorEd25519 is the TransactionAuthenticator variant for ed25519
+	authenticatorEd25519 = 0x00
+)
+
+// Adapter represents an Aptos blockchain adapter
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewAptosAdapter creates a new Aptos adapter instance
+func NewAptosAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "aptos")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (a *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Aptos
+}
+
+// Capabilities describes the coins and formats handled by the Aptos adapter
+func (a *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "aptos",
+		CoinTypes:      []uint16{slip44.Aptos},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"0x prefixed 32 byte hex authentication key"},
+		Payload:        lib.BCSRawTx{},
+		Operations:     lib.DefaultOperations(),
+	}
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (a *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives a public key from the given seed and derivation path
+func (a *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := "0x" + hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the account address (authentication key) from the given seed and derivation path
+func (a *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	address := "0x" + hex.EncodeToString(accountAddress(privateKey.Public().(ed25519.PublicKey)))
+	logger.Info("Address derived successfully", "address", address)
+
+	return address, nil
+}
+
+// ValidatePayload reports the field errors of an Aptos sign payload
+func (a *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	if txBytes, ok := v.Hex("txBytes", true); ok && len(txBytes) <= addressLength {
+		v.Fail("txBytes", "too short for a RawTransaction")
+	}
+	return v.Err()
+}
+
+// CreateSignedTransaction signs the BCS serialized RawTransaction in the payload and
+// returns the hex encoded BCS SignedTransaction ready for submission.
+func (a *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := a.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.BCSRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil || rawTx.TxBytes == "" {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	txBytes, err := hex.DecodeString(strings.TrimPrefix(rawTx.TxBytes, "0x"))
+	if err != nil || len(txBytes) <= addressLength {
+		return "", ErrInvalidRawData
+	}
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	// RawTransaction starts with the sender address
+	if !bytes.Equal(txBytes[:addressLength], accountAddress(publicKey)) {
+		return "", ErrUnexpectedSender
+	}
+
+	signature := ed25519.Sign(privateKey, signingMessage(txBytes))
+
+	// SignedTransaction = RawTransaction || TransactionAuthenticator::Ed25519 { public_key, signature }
+	signed := make([]byte, 0, len(txBytes)+3+len(publicKey)+len(signature))
+	signed = append(signed, txBytes...)
+	signed = append(signed, authenticatorEd25519)
+	signed = append(signed, byte(len(publicKey)))
+	signed = append(signed, publicKey...)
+	signed = append(signed, byte(len(signature)))
+	signed = append(signed, signature...)
+
+	txHex := "0x" + hex.EncodeToString(signed)
+	logger.Info("Signed transaction created successfully", "tx", txHex)
+
+	return txHex, nil
+}
+
+// accountAddress returns sha3-256(public key || scheme), the default account address
+func accountAddress(publicKey ed25519.PublicKey) []byte {
+	h := sha3.New256()
+	h.Write(publicKey)
+	h.Write([]byte{ed25519Scheme})
+	return h.Sum(nil)
+}
+
+// signingMessage prefixes the raw transaction with the hashed domain separator
+func signingMessage(txBytes []byte) []byte {
+	prefix := sha3.Sum256([]byte(rawTransactionSalt))
+	return append(prefix[:], txBytes...)
+}
@@ -0,0 +1,98 @@
+package aptos
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Test vector from the Aptos TypeScript SDK ed25519 key derivation tests
+const (
+	testMnemonic       = "shoot island position soft burden budget tooth cruel issue economy destroy above"
+	testDerivationPath = "m/44'/637'/0'/0'/0'"
+	expectedAddress    = "0x07968dab936c1bad187c60ce4082f307d030d780e91e694ae03aef16aba73f30"
+)
+
+func newTestAdapter() *Adapter {
+	return NewAptosAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := lib.SeedFromMnemonic(testMnemonic, "")
+	require.NoError(t, err)
+	return seed
+}
+
+func TestAptosAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Aptos))
+	assert.False(t, adapter.CanDo(slip44.Sui))
+	assert.False(t, adapter.CanDo(slip44.Ether))
+}
+
+func TestAptosAdapter_DeriveAddress(t *testing.T) {
+	adapter := newTestAdapter()
+
+	address, err := adapter.DeriveAddress(testSeed(t), testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, address)
+
+	_, err = adapter.DeriveAddress(testSeed(t), "m/44'/637'/0'/0/0", false)
+	assert.ErrorIs(t, err, lib.ErrNonHardenedComponent)
+}
+
+func TestAptosAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+
+	sender, err := hex.DecodeString(strings.TrimPrefix(expectedAddress, "0x"))
+	require.NoError(t, err)
+	// sender followed by an opaque remainder of the RawTransaction
+	rawTx := append(sender, 0x01, 0x02, 0x03, 0x04)
+
+	t.Run("signs raw transaction from derived sender", func(t *testing.T) {
+		payload, err := json.Marshal(lib.BCSRawTx{TxBytes: hex.EncodeToString(rawTx)})
+		require.NoError(t, err)
+
+		txHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		require.NoError(t, err)
+
+		signed, err := hex.DecodeString(strings.TrimPrefix(txHex, "0x"))
+		require.NoError(t, err)
+		require.Len(t, signed, len(rawTx)+3+ed25519.PublicKeySize+ed25519.SignatureSize)
+		assert.Equal(t, rawTx, signed[:len(rawTx)])
+
+		authenticator := signed[len(rawTx):]
+		assert.Equal(t, byte(authenticatorEd25519), authenticator[0])
+		publicKey := ed25519.PublicKey(authenticator[2 : 2+ed25519.PublicKeySize])
+		signature := authenticator[3+ed25519.PublicKeySize:]
+		assert.True(t, ed25519.Verify(publicKey, signingMessage(rawTx), signature))
+	})
+
+	t.Run("rejects foreign sender", func(t *testing.T) {
+		foreign := append(make([]byte, addressLength), 0x01)
+		payload, err := json.Marshal(lib.BCSRawTx{TxBytes: hex.EncodeToString(foreign)})
+		require.NoError(t, err)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		assert.ErrorIs(t, err, ErrUnexpectedSender)
+	})
+
+	t.Run("rejects invalid payload", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, testDerivationPath, `{"invalid":"json"}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"txBytes":"0x01"}`)
+		assert.ErrorIs(t, err, ErrInvalidRawData)
+	})
+}
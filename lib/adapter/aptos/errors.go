@@ -0,0 +1,10 @@
+package aptos
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData = errors.New("invalid payload data")
+	ErrInvalidRawData     = errors.New("invalid BCS raw transaction")
+	ErrUnexpectedSender   = errors.New("sender of raw transaction does not match derived address")
+)
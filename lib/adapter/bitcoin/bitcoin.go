@@ -0,0 +1,625 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	btcecv1 "github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// DescriptorScanLimit is the number of addresses of a ranged descriptor searched for the inputs
+	DescriptorScanLimit = 1000
+)
+
+// Adapter represents a Bitcoin adapter. The address type of a derivation path follows its
+// purpose: m/44' legacy, m/49' nested segwit, m/86' taproot and native segwit otherwise.
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewBitcoinAdapter creates a new Bitcoin adapter instance
+func NewBitcoinAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "bitcoin")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (a *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Bitcoin || coinType == slip44.TestNet
+}
+
+// Capabilities describes the coins and formats handled by the Bitcoin adapter
+func (a *Adapter) Capabilities() lib.Capabilities {
+	operations := append(lib.DefaultOperations(), lib.OperationSignPSBT, lib.OperationBuildBTCTx,
+		lib.OperationMultisig, lib.OperationBroadcast)
+	return lib.Capabilities{
+		Adapter:   "bitcoin",
+		CoinTypes: []uint16{slip44.Bitcoin, slip44.TestNet},
+		Curve:     lib.CurveSecp256k1,
+		AddressFormats: []string{
+			"base58 p2pkh (m/44')", "base58 p2sh-p2wpkh (m/49')", "bech32 p2wpkh (m/84')", "bech32m p2tr (m/86')",
+		},
+		RawPayloadFormat: "base64 or hex encoded PSBT (BIP-174)",
+		Operations:       operations,
+		Testnet:          true,
+	}
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (a *Adapter) DerivePrivateKey(seed []byte, derivationPath string, isDev bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Serialize())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives the compressed public key from the given seed and derivation path
+func (a *Adapter) DerivePublicKey(seed []byte, derivationPath string, isDev bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the address of the derivation path, on testnet in dev mode
+func (a *Adapter) DeriveAddress(seed []byte, derivationPath string, isDev bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		logger.Error("Failed to parse derivation path", "error", err)
+		return "", err
+	}
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	address, err := encodeAddress(addressType, privateKey.PubKey().SerializeCompressed(), netParams(isDev))
+	if err != nil {
+		logger.Error("Failed to encode address", "error", err)
+		return "", err
+	}
+	logger.Info("Address derived successfully", "address", address, "addressType", addressType)
+
+	return address, nil
+}
+
+// AddressFromPublicKey encodes the compressed publicKey as the address type of the purpose of the
+// derivation path, for watch-only users
+func (a *Adapter) AddressFromPublicKey(publicKey []byte, derivationPath string, isDev bool) (string, error) {
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		return "", err
+	}
+	return encodeAddress(addressType, publicKey, netParams(isDev))
+}
+
+// AddressOverrides -- the address encoding of a fork or rebrand of Bitcoin, overriding the one of
+// the network; the unset fields keep it
+type AddressOverrides struct {
+	// HRP is the human readable part of the bech32 and bech32m addresses
+	HRP string
+	// PubKeyHashVersion and ScriptHashVersion are the version bytes of the base58 p2pkh and p2sh addresses
+	PubKeyHashVersion *byte
+	ScriptHashVersion *byte
+}
+
+// AddressFromPublicKeyWith encodes the compressed publicKey as AddressFromPublicKey does, with the
+// human readable part and version bytes of overrides
+func AddressFromPublicKeyWith(publicKey []byte, derivationPath string, isDev bool,
+	overrides AddressOverrides) (string, error) {
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		return "", err
+	}
+	net := *netParams(isDev)
+	if overrides.HRP != "" {
+		if !ValidHRP(overrides.HRP) {
+			return "", ErrInvalidHRP
+		}
+		net.Bech32HRPSegwit = overrides.HRP
+	}
+	if overrides.PubKeyHashVersion != nil {
+		net.PubKeyHashAddrID = *overrides.PubKeyHashVersion
+	}
+	if overrides.ScriptHashVersion != nil {
+		net.ScriptHashAddrID = *overrides.ScriptHashVersion
+	}
+	return encodeAddress(addressType, publicKey, &net)
+}
+
+// ValidHRP reports whether hrp is a lowercase bech32 human readable part (BIP-173)
+func ValidHRP(hrp string) bool {
+	if len(hrp) == 0 || len(hrp) > 83 {
+		return false
+	}
+	for _, c := range hrp {
+		if c < 33 || c > 126 || (c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Descriptor returns the single key output descriptor of the address of the derivation path,
+// with its key origin, e.g. wpkh([73c5da0a/84h/0h/0h/0/0]03...)#checksum
+func (a *Adapter) Descriptor(seed []byte, derivationPath string, isDev bool) (string, error) {
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		return "", err
+	}
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, err := lib.MasterFingerprint(seed)
+	if err != nil {
+		return "", err
+	}
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey := privateKey.PubKey().SerializeCompressed()
+	if addressType == lib.AddressTypeP2TR {
+		// taproot keys are x-only
+		publicKey = publicKey[1:]
+	}
+	key := "[" + fingerprint + "/" + lib.FormatOriginPath(path) + "]" + hex.EncodeToString(publicKey)
+	return lib.OutputDescriptor(addressType, key)
+}
+
+// ValidatePayload checks the payload is a PSBT with its unsigned transaction
+func (a *Adapter) ValidatePayload(payload string) error {
+	if _, err := DecodePSBT(payload); err != nil {
+		return lib.PayloadErrors{{Field: "payload", Message: err.Error()}}
+	}
+	return nil
+}
+
+// CreateSignedTransaction signs every input of the PSBT spending an output of the key of the
+// derivation path, and returns the base64 encoded PSBT. Inputs are not finalized.
+func (a *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := a.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	packet, err := DecodePSBT(payload)
+	if err != nil {
+		return "", err
+	}
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := newSigner(seed, packet).sign(func(int) [][]uint32 { return [][]uint32{path} })
+	if err != nil {
+		logger.Error("Failed to sign PSBT", "error", err)
+		return "", err
+	}
+
+	encoded, err := packet.Base64()
+	if err != nil {
+		return "", err
+	}
+	logger.Info("Signed transaction created successfully", "signedInputs", signed)
+
+	return encoded, nil
+}
+
+// SignPSBT signs every input of packet spending an output of the wallet of seed. The keys are
+// selected from the BIP-32 derivations of the inputs carrying the wallet fingerprint, and from the
+// first DescriptorScanLimit addresses of descriptors, which must be keys of the wallet.
+// It returns the indexes of the signed inputs.
+func SignPSBT(seed []byte, packet *Packet, descriptors []*Descriptor) ([]int, error) {
+	candidates, err := psbtCandidates(seed, packet, descriptors)
+	if err != nil {
+		return nil, err
+	}
+	return newSigner(seed, packet).sign(candidates)
+}
+
+// SigningPaths returns the derivation paths SignPSBT selects the keys of the unfinalized inputs of
+// packet from for the wallet of seed, as m/84'/0'/0'/0/7, that the policies on the keys check
+// before it signs
+func SigningPaths(seed []byte, packet *Packet, descriptors []*Descriptor) ([]string, error) {
+	candidates, err := psbtCandidates(seed, packet, descriptors)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i := range packet.Tx.TxIn {
+		if packet.finalized(i) {
+			continue
+		}
+		for _, path := range candidates(i) {
+			paths = append(paths, formatPath(path))
+		}
+	}
+	return paths, nil
+}
+
+// psbtCandidates returns the candidate paths of the inputs of packet for the wallet of seed: those
+// of the BIP-32 derivations carrying the wallet fingerprint, and the address of descriptors the
+// utxo pays
+func psbtCandidates(seed []byte, packet *Packet, descriptors []*Descriptor) (func(i int) [][]uint32, error) {
+	fingerprint, err := newSigner(seed, packet).fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := descriptorScripts(seed, fingerprint, descriptors)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(i int) [][]uint32 {
+		paths := packet.bip32Derivations(i, fingerprint)
+		if utxo, err := packet.utxo(i); err == nil {
+			if path, ok := scripts[string(utxo.PkScript)]; ok {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}, nil
+}
+
+// WalletOutputs returns the indexes of the outputs of packet paying the wallet of seed back, its
+// change: those paying a key of their BIP-32 derivations carrying the wallet fingerprint, and those
+// paying one of the first DescriptorScanLimit addresses of descriptors, which must be keys of the
+// wallet.
+func WalletOutputs(seed []byte, packet *Packet, descriptors []*Descriptor) ([]int, error) {
+	fingerprint, err := newSigner(seed, packet).fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := descriptorScripts(seed, fingerprint, descriptors)
+	if err != nil {
+		return nil, err
+	}
+
+	own := []int{}
+	for i, out := range packet.Tx.TxOut {
+		if _, ok := scripts[string(out.PkScript)]; ok {
+			own = append(own, i)
+			continue
+		}
+		paid, err := paysKeyOf(seed, packet.outputBip32Derivations(i, fingerprint), out.PkScript)
+		if err != nil {
+			return nil, err
+		}
+		if paid {
+			own = append(own, i)
+		}
+	}
+	return own, nil
+}
+
+// KeyOutputs returns the indexes of the outputs of packet paying the wallet of seed back when the
+// key of derivationPath signs it: those paying that key, whatever its address type, and those
+// paying a key of their BIP-32 derivations carrying the wallet fingerprint
+func KeyOutputs(seed []byte, packet *Packet, derivationPath string) ([]int, error) {
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := newSigner(seed, packet).fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	own := []int{}
+	for i, out := range packet.Tx.TxOut {
+		paths := append([][]uint32{path}, packet.outputBip32Derivations(i, fingerprint)...)
+		paid, err := paysKeyOf(seed, paths, out.PkScript)
+		if err != nil {
+			return nil, err
+		}
+		if paid {
+			own = append(own, i)
+		}
+	}
+	return own, nil
+}
+
+// paysKeyOf reports whether script pays the key of seed at one of paths, whatever its address type
+func paysKeyOf(seed []byte, paths [][]uint32, script []byte) (bool, error) {
+	for _, path := range paths {
+		publicKey, err := publicKeyAt(seed, path)
+		if err != nil {
+			return false, err
+		}
+		for _, addressType := range []string{
+			lib.AddressTypeP2WPKH, lib.AddressTypeP2TR, lib.AddressTypeP2SHP2WPKH, lib.AddressTypeP2PKH,
+		} {
+			candidate, err := outputScript(addressType, publicKey)
+			if err != nil {
+				return false, err
+			}
+			if string(candidate) == string(script) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// descriptorScripts maps the output scripts of the first DescriptorScanLimit addresses of
+// descriptors, checked to be keys of the wallet of seed, to their derivation paths
+func descriptorScripts(seed, fingerprint []byte, descriptors []*Descriptor) (map[string][]uint32, error) {
+	scripts := make(map[string][]uint32)
+	for _, descriptor := range descriptors {
+		if err := descriptor.checkOwner(seed, fingerprint); err != nil {
+			return nil, err
+		}
+		count := uint32(1)
+		if descriptor.Wildcard {
+			count = DescriptorScanLimit
+		}
+		for index := range count {
+			path, publicKey, err := descriptor.key(index)
+			if err != nil {
+				return nil, err
+			}
+			script, err := outputScript(descriptor.AddressType, publicKey)
+			if err != nil {
+				return nil, err
+			}
+			scripts[string(script)] = path
+		}
+	}
+	return scripts, nil
+}
+
+// signer adds the signatures of a wallet to a PSBT
+type signer struct {
+	seed      []byte
+	packet    *Packet
+	sigHashes *txscript.TxSigHashes
+}
+
+func newSigner(seed []byte, packet *Packet) *signer {
+	return &signer{seed: seed, packet: packet, sigHashes: txscript.NewTxSigHashes(packet.Tx)}
+}
+
+// fingerprint returns the master key fingerprint of the wallet
+func (s *signer) fingerprint() ([]byte, error) {
+	fingerprint, err := lib.MasterFingerprint(s.seed)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(fingerprint)
+}
+
+// sign signs the unfinalized inputs whose utxo pays to a key of the candidate paths of the input
+func (s *signer) sign(candidates func(i int) [][]uint32) ([]int, error) {
+	fingerprint, err := s.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	signed := []int{}
+	for i := range s.packet.Tx.TxIn {
+		paths := candidates(i)
+		if s.packet.finalized(i) || len(paths) == 0 {
+			continue
+		}
+		utxo, err := s.packet.utxo(i)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := s.signInput(i, utxo, paths, fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		if ok {
+			signed = append(signed, i)
+		}
+	}
+	if len(signed) == 0 {
+		return nil, ErrNothingToSign
+	}
+	return signed, nil
+}
+
+// signInput signs input i with the first key of paths whose output script is the utxo script
+func (s *signer) signInput(i int, utxo *wire.TxOut, paths [][]uint32, fingerprint []byte) (bool, error) {
+	for _, path := range paths {
+		privateKey, err := lib.DerivePrivateKey(s.seed, formatPath(path), false)
+		if err != nil {
+			return false, err
+		}
+		publicKey := privateKey.PubKey().SerializeCompressed()
+
+		for _, addressType := range []string{
+			lib.AddressTypeP2WPKH, lib.AddressTypeP2TR, lib.AddressTypeP2SHP2WPKH, lib.AddressTypeP2PKH,
+		} {
+			script, err := outputScript(addressType, publicKey)
+			if err != nil {
+				return false, err
+			}
+			if string(script) != string(utxo.PkScript) {
+				continue
+			}
+			if err := s.signWith(i, utxo, addressType, privateKey); err != nil {
+				return false, err
+			}
+			s.addDerivation(i, addressType, publicKey, fingerprint, path)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// signWith adds the signature of privateKey for the output script of addressType to input i
+func (s *signer) signWith(i int, utxo *wire.TxOut, addressType string, privateKey *btcecv1.PrivateKey) error {
+	input := &s.packet.inputs[i]
+	publicKey := privateKey.PubKey().SerializeCompressed()
+
+	switch addressType {
+	case lib.AddressTypeP2TR:
+		hashType, err := s.packet.sighashType(i, sigHashDefault)
+		if err != nil {
+			return err
+		}
+		prevouts, err := s.packet.prevouts()
+		if err != nil {
+			return err
+		}
+		hash, err := taprootSigHash(s.packet.Tx, i, prevouts, hashType)
+		if err != nil {
+			return err
+		}
+		key, _ := btcec.PrivKeyFromBytes(privateKey.Serialize())
+		tweaked, err := taprootPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		sig, err := signSchnorr(tweaked, hash)
+		if err != nil {
+			return err
+		}
+		if hashType != sigHashDefault {
+			sig = append(sig, byte(hashType))
+		}
+		input.set([]byte{inputTapKeySig}, sig)
+		return nil
+
+	case lib.AddressTypeP2PKH:
+		if _, ok := input.get(inputNonWitnessUtxo); !ok {
+			return fmt.Errorf("%w: legacy inputs need the non-witness utxo", ErrMissingUtxo)
+		}
+		hashType, err := s.packet.sighashType(i, txscript.SigHashAll)
+		if err != nil {
+			return err
+		}
+		sig, err := txscript.RawTxInSignature(s.packet.Tx, i, utxo.PkScript, hashType, privateKey)
+		if err != nil {
+			return err
+		}
+		if err := checkSignature(sig); err != nil {
+			return err
+		}
+		input.set(append([]byte{inputPartialSig}, publicKey...), sig)
+		return nil
+
+	default:
+		hashType, err := s.packet.sighashType(i, txscript.SigHashAll)
+		if err != nil {
+			return err
+		}
+		program, err := witnessProgram(publicKey)
+		if err != nil {
+			return err
+		}
+		sig, err := txscript.RawTxInWitnessSignature(s.packet.Tx, s.sigHashes, i, utxo.Value,
+			program, hashType, privateKey)
+		if err != nil {
+			return err
+		}
+		if err := checkSignature(sig); err != nil {
+			return err
+		}
+		input.set(append([]byte{inputPartialSig}, publicKey...), sig)
+		if addressType == lib.AddressTypeP2SHP2WPKH {
+			input.set([]byte{inputRedeemScript}, program)
+		}
+		return nil
+	}
+}
+
+// checkSignature checks that the ECDSA signature of an input, with its trailing sighash byte, is
+// strict DER with a low S, as the standardness rules of the nodes require
+func checkSignature(sig []byte) error {
+	if len(sig) == 0 {
+		return lib.ErrNonCanonicalSignature
+	}
+	return lib.CheckDERSignature(sig[:len(sig)-1])
+}
+
+// addDerivation records the key origin of the signing key, for finalizers and coordinators
+func (s *signer) addDerivation(i int, addressType string, publicKey, fingerprint []byte, path []uint32) {
+	origin := encodeKeyOrigin(fingerprint, path)
+	if addressType == lib.AddressTypeP2TR {
+		// no leaf hashes for the key path
+		s.packet.inputs[i].set(append([]byte{inputTapBip32Derivation}, publicKey[1:]...), append([]byte{0x00}, origin...))
+		return
+	}
+	s.packet.inputs[i].set(append([]byte{inputBip32Derivation}, publicKey...), origin)
+}
+
+// encodeKeyOrigin encodes the <fingerprint><path> key origin of the BIP-32 derivation entries
+func encodeKeyOrigin(fingerprint []byte, path []uint32) []byte {
+	origin := append([]byte{}, fingerprint...)
+	for _, index := range path {
+		origin = binary.LittleEndian.AppendUint32(origin, index)
+	}
+	return origin
+}
+
+// formatPath formats BIP-32 indices as an absolute derivation path
+func formatPath(path []uint32) string {
+	var sb strings.Builder
+	sb.WriteString("m")
+	for _, index := range path {
+		if index >= hardenedKeyStart {
+			fmt.Fprintf(&sb, "/%d'", index-hardenedKeyStart)
+			continue
+		}
+		fmt.Fprintf(&sb, "/%d", index)
+	}
+	return sb.String()
+}
+
+// publicKeyAt derives the compressed public key of the BIP-32 indices
+func publicKeyAt(seed []byte, path []uint32) ([]byte, error) {
+	privateKey, err := lib.DerivePrivateKey(seed, formatPath(path), false)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.PubKey().SerializeCompressed(), nil
+}
+
+// netParams returns the testnet parameters in dev mode, the mainnet ones otherwise
+func netParams(isDev bool) *chaincfg.Params {
+	if isDev {
+		return &chaincfg.TestNet3Params
+	}
+	return &chaincfg.MainNetParams
+}
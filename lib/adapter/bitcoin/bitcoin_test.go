@@ -0,0 +1,654 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func newTestAdapter() *Adapter {
+	return NewBitcoinAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	t.Helper()
+	seed, err := lib.SeedFromMnemonic(testMnemonic, "")
+	require.NoError(t, err)
+	return seed
+}
+
+func TestDeriveAddress(t *testing.T) {
+	seed := testSeed(t)
+	adapter := newTestAdapter()
+
+	// first receive addresses of the BIP-44, BIP-49, BIP-84 and BIP-86 test vectors
+	for path, expected := range map[string]string{
+		"m/44'/0'/0'/0/0": "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+		"m/49'/0'/0'/0/0": "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf",
+		"m/84'/0'/0'/0/0": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		"m/86'/0'/0'/0/0": "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+	} {
+		t.Run(path, func(t *testing.T) {
+			address, err := adapter.DeriveAddress(seed, path, false)
+			require.NoError(t, err)
+			assert.Equal(t, expected, address)
+		})
+	}
+
+	address, err := adapter.DeriveAddress(seed, "m/84'/1'/0'/0/0", true)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(address, "tb1q"))
+}
+
+func TestAddressFromPublicKeyWith(t *testing.T) {
+	seed := testSeed(t)
+	publicKeyAt := func(derivationPath string) []byte {
+		privateKey, err := lib.DerivePrivateKey(seed, derivationPath, false)
+		require.NoError(t, err)
+		return privateKey.PubKey().SerializeCompressed()
+	}
+	litecoinP2PKH, litecoinP2SH := byte(0x30), byte(0x32)
+	overrides := AddressOverrides{HRP: "ltc", PubKeyHashVersion: &litecoinP2PKH, ScriptHashVersion: &litecoinP2SH}
+
+	// the same keys, only the human readable part or the version byte differs
+	for path, bitcoinAddress := range map[string]string{
+		"m/84'/0'/0'/0/0": "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu",
+		"m/86'/0'/0'/0/0": "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+	} {
+		address, err := AddressFromPublicKeyWith(publicKeyAt(path), path, false, overrides)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(address, "ltc1"), address)
+		assert.Equal(t, bitcoinAddress[3:len(bitcoinAddress)-6], address[4:len(address)-6])
+	}
+	for path, version := range map[string]byte{"m/44'/0'/0'/0/0": litecoinP2PKH, "m/49'/0'/0'/0/0": litecoinP2SH} {
+		address, err := AddressFromPublicKeyWith(publicKeyAt(path), path, false, overrides)
+		require.NoError(t, err)
+		bitcoinAddress, err := newTestAdapter().AddressFromPublicKey(publicKeyAt(path), path, false)
+		require.NoError(t, err)
+		decoded, decodedVersion, err := base58.CheckDecode(address)
+		require.NoError(t, err)
+		expected, _, err := base58.CheckDecode(bitcoinAddress)
+		require.NoError(t, err)
+		assert.Equal(t, version, decodedVersion)
+		assert.Equal(t, expected, decoded)
+	}
+
+	// no override is the address of the network
+	address, err := AddressFromPublicKeyWith(publicKeyAt("m/84'/0'/0'/0/0"), "m/84'/0'/0'/0/0", false, AddressOverrides{})
+	require.NoError(t, err)
+	assert.Equal(t, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", address)
+
+	for _, hrp := range []string{"LTC", "l tc", strings.Repeat("a", 84)} {
+		_, err := AddressFromPublicKeyWith(publicKeyAt("m/84'/0'/0'/0/0"), "m/84'/0'/0'/0/0", false,
+			AddressOverrides{HRP: hrp})
+		require.ErrorIs(t, err, ErrInvalidHRP, hrp)
+	}
+}
+
+// the signed messages recover to the key of the address, with the header of its type
+func TestSignMessage(t *testing.T) {
+	seed := testSeed(t)
+	adapter := newTestAdapter()
+
+	for path, header := range map[string]byte{"m/44'/0'/0'/0/0": 31, "m/49'/0'/0'/0/0": 35, "m/84'/0'/0'/0/0": 39} {
+		signature, key, err := SignMessage(seed, path, "challenge", false)
+		require.NoError(t, err, path)
+		compact, err := base64.StdEncoding.DecodeString(signature)
+		require.NoError(t, err)
+		require.Len(t, compact, 65)
+		assert.LessOrEqual(t, header, compact[0], path)
+		assert.Less(t, compact[0], header+4, path)
+
+		// btcec expects the header of compressed P2PKH keys
+		compact[0] -= header - 31
+		publicKey, compressed, err := ecdsa.RecoverCompact(compact, MessageHash("challenge"))
+		require.NoError(t, err)
+		assert.True(t, compressed)
+		assert.Equal(t, key, publicKey.SerializeCompressed())
+		address, err := adapter.AddressFromPublicKey(publicKey.SerializeCompressed(), path, false)
+		require.NoError(t, err)
+		derived, err := adapter.DeriveAddress(seed, path, false)
+		require.NoError(t, err)
+		assert.Equal(t, derived, address, path)
+	}
+
+	_, _, err := SignMessage(seed, "m/86'/0'/0'/0/0", "challenge", false)
+	require.ErrorIs(t, err, lib.ErrProofUnsupported)
+}
+
+func TestDescriptor(t *testing.T) {
+	seed := testSeed(t)
+
+	descriptor, err := newTestAdapter().Descriptor(seed, "m/84'/0'/0'/0/0", false)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(descriptor,
+		"wpkh([73c5da0a/84h/0h/0h/0/0]0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c)#"))
+
+	parsed, err := ParseDescriptor(descriptor)
+	require.NoError(t, err)
+	assert.Equal(t, lib.AddressTypeP2WPKH, parsed.AddressType)
+	fingerprint, _ := hex.DecodeString("73c5da0a")
+	require.NoError(t, parsed.checkOwner(seed, fingerprint))
+
+	// taproot descriptors carry the x-only key
+	descriptor, err = newTestAdapter().Descriptor(seed, "m/86'/0'/0'/0/0", false)
+	require.NoError(t, err)
+	parsed, err = ParseDescriptor(descriptor)
+	require.NoError(t, err)
+	require.NoError(t, parsed.checkOwner(seed, fingerprint))
+	script, err := outputScript(parsed.AddressType, parsed.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c",
+		hex.EncodeToString(script))
+}
+
+func TestParseDescriptor(t *testing.T) {
+	const xpub = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+	account := "[73c5da0a/84h/0h/0h]" + xpub
+
+	parsed, err := ParseDescriptor("wpkh(" + account + "/0/*)")
+	require.NoError(t, err)
+	assert.True(t, parsed.Wildcard)
+	assert.Equal(t, []uint32{0}, parsed.Derivation)
+	assert.Equal(t, []uint32{hardenedKeyStart + 84, hardenedKeyStart, hardenedKeyStart}, parsed.Origin)
+
+	path, publicKey, err := parsed.key(0)
+	require.NoError(t, err)
+	assert.Equal(t, "m/84'/0'/0'/0/0", formatPath(path))
+	assert.Equal(t, "0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c", hex.EncodeToString(publicKey))
+
+	for descriptor, expected := range map[string]error{
+		"wpkh(" + account + "/0/*)#00000000":              ErrDescriptorChecksum,
+		"wpkh(" + xpub + "/0/*)":                          ErrDescriptorOriginMissing,
+		"wpkh(" + account + "/0h/*)":                      ErrHardenedDerivation,
+		"wpkh(" + account + "/0/*h)":                      ErrHardenedDerivation,
+		"wsh(multi(1," + account + "/0/*))":               ErrUnsupportedDescriptor,
+		"tr(" + account + "/0/*,pk(" + account + "/1/*))": ErrUnsupportedDescriptor,
+	} {
+		_, err := ParseDescriptor(descriptor)
+		require.ErrorIs(t, err, expected, descriptor)
+	}
+}
+
+func TestSignSchnorr(t *testing.T) {
+	// BIP-340 test vectors 0 and 1
+	for _, vector := range []struct{ secretKey, aux, message, signature string }{
+		{
+			secretKey: "0000000000000000000000000000000000000000000000000000000000000003",
+			aux:       "0000000000000000000000000000000000000000000000000000000000000000",
+			message:   "0000000000000000000000000000000000000000000000000000000000000000",
+			signature: "e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca8215" +
+				"25f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+		},
+		{
+			secretKey: "b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+			aux:       "0000000000000000000000000000000000000000000000000000000000000001",
+			message:   "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			signature: "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de3341" +
+				"8906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+		},
+	} {
+		secretKey, _ := hex.DecodeString(vector.secretKey)
+		message, _ := hex.DecodeString(vector.message)
+		var aux [32]byte
+		auxBytes, _ := hex.DecodeString(vector.aux)
+		copy(aux[:], auxBytes)
+
+		privateKey, _ := btcec.PrivKeyFromBytes(secretKey)
+		signature, err := signSchnorrWithAux(privateKey, message, aux)
+		require.NoError(t, err)
+		assert.Equal(t, vector.signature, hex.EncodeToString(signature))
+	}
+}
+
+// testInput is an output of the test wallet spent by the test transaction
+type testInput struct {
+	path        string
+	addressType string
+	value       int64
+}
+
+// newTestPSBT returns a PSBT spending one output per input, with their witness utxo, or the
+// non-witness utxo for legacy inputs
+func newTestPSBT(t *testing.T, seed []byte, inputs []testInput) *Packet {
+	t.Helper()
+
+	tx := wire.NewMsgTx(2)
+	maps := make([]psbtMap, 0, len(inputs))
+	for i, input := range inputs {
+		privateKey, err := lib.DerivePrivateKey(seed, input.path, false)
+		require.NoError(t, err)
+		script, err := outputScript(input.addressType, privateKey.PubKey().SerializeCompressed())
+		require.NoError(t, err)
+
+		prev := wire.NewMsgTx(2)
+		prev.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{byte(i + 1)}, 0), nil, nil))
+		prev.AddTxOut(wire.NewTxOut(input.value, script))
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil, nil))
+		tx.TxIn[i].PreviousOutPoint.Hash = prev.TxHash()
+
+		var m psbtMap
+		if input.addressType == lib.AddressTypeP2PKH {
+			var buf bytes.Buffer
+			require.NoError(t, prev.Serialize(&buf))
+			m.set([]byte{inputNonWitnessUtxo}, buf.Bytes())
+		} else {
+			var buf bytes.Buffer
+			require.NoError(t, writeTxOut(&buf, prev.TxOut[0]))
+			m.set([]byte{inputWitnessUtxo}, buf.Bytes())
+		}
+		maps = append(maps, m)
+	}
+	tx.AddTxOut(wire.NewTxOut(10_000, []byte{txscript.OP_RETURN}))
+
+	var unsigned bytes.Buffer
+	require.NoError(t, tx.SerializeNoWitness(&unsigned))
+	packet := &Packet{
+		Tx:      tx,
+		global:  psbtMap{{key: []byte{globalUnsignedTx}, value: unsigned.Bytes()}},
+		inputs:  maps,
+		outputs: []psbtMap{nil},
+	}
+
+	// serialization round trip
+	raw, err := packet.Serialize()
+	require.NoError(t, err)
+	parsed, err := ParsePSBT(raw)
+	require.NoError(t, err)
+	return parsed
+}
+
+// verifyInput finalizes input i from its partial signature and runs it through the script engine
+func verifyInput(t *testing.T, packet *Packet, i int, addressType string) {
+	t.Helper()
+
+	utxo, err := packet.utxo(i)
+	require.NoError(t, err)
+	tx := packet.Tx.Copy()
+
+	switch addressType {
+	case lib.AddressTypeP2TR:
+		sig, ok := packet.inputs[i].get(inputTapKeySig)
+		require.True(t, ok)
+		require.Len(t, sig, schnorrSignatureLength)
+		hash, err := taprootSigHash(packet.Tx, i, mustPrevouts(t, packet), sigHashDefault)
+		require.NoError(t, err)
+		assert.True(t, verifySchnorr(t, utxo.PkScript[2:], hash, sig), "taproot signature")
+		return
+	default:
+		entries := packet.inputs[i].ofType(inputPartialSig)
+		require.Len(t, entries, 1)
+		publicKey, sig := entries[0].key[1:], entries[0].value
+		switch addressType {
+		case lib.AddressTypeP2PKH:
+			tx.TxIn[i].SignatureScript, err = txscript.NewScriptBuilder().AddData(sig).AddData(publicKey).Script()
+			require.NoError(t, err)
+		case lib.AddressTypeP2SHP2WPKH:
+			redeemScript, ok := packet.inputs[i].get(inputRedeemScript)
+			require.True(t, ok)
+			tx.TxIn[i].SignatureScript, err = txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			require.NoError(t, err)
+			tx.TxIn[i].Witness = wire.TxWitness{sig, publicKey}
+		default:
+			tx.TxIn[i].Witness = wire.TxWitness{sig, publicKey}
+		}
+	}
+
+	engine, err := txscript.NewEngine(utxo.PkScript, tx, i, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx), utxo.Value)
+	require.NoError(t, err)
+	require.NoError(t, engine.Execute())
+}
+
+func mustPrevouts(t *testing.T, packet *Packet) []*wire.TxOut {
+	t.Helper()
+	prevouts, err := packet.prevouts()
+	require.NoError(t, err)
+	return prevouts
+}
+
+// verifySchnorr checks a BIP-340 signature of the x-only key: s·G = R + e·P
+func verifySchnorr(t *testing.T, xOnlyKey, hash, sig []byte) bool {
+	t.Helper()
+	publicKey, err := btcec.ParsePubKey(append([]byte{0x02}, xOnlyKey...))
+	require.NoError(t, err)
+
+	var s, e btcec.ModNScalar
+	require.False(t, s.SetByteSlice(sig[32:]))
+	e.SetByteSlice(taggedHash("BIP0340/challenge", sig[:32], xOnlyKey, hash))
+	e.Negate()
+
+	// R = s·G - e·P must have an even y and the x of the signature
+	var sG, eP, r, p btcec.JacobianPoint
+	publicKey.AsJacobian(&p)
+	btcec.ScalarBaseMultNonConst(&s, &sG)
+	btcec.ScalarMultNonConst(&e, &p, &eP)
+	btcec.AddNonConst(&sG, &eP, &r)
+	r.ToAffine()
+	x := r.X.Bytes()
+	return !r.Y.IsOdd() && bytes.Equal(x[:], sig[:32])
+}
+
+func TestSignPSBT(t *testing.T) {
+	seed := testSeed(t)
+	inputs := []testInput{
+		{"m/84'/0'/0'/0/3", lib.AddressTypeP2WPKH, 50_000},
+		{"m/86'/0'/0'/1/2", lib.AddressTypeP2TR, 60_000},
+		{"m/49'/0'/0'/0/1", lib.AddressTypeP2SHP2WPKH, 70_000},
+		{"m/44'/0'/0'/0/0", lib.AddressTypeP2PKH, 80_000},
+	}
+
+	t.Run("keys selected from descriptors", func(t *testing.T) {
+		packet := newTestPSBT(t, seed, inputs)
+		accounts, err := lib.BitcoinWatchOnlyAccounts(seed, 0, false)
+		require.NoError(t, err)
+		var descriptors []*Descriptor
+		for _, account := range accounts {
+			for _, d := range []string{account.Descriptors.Receive, account.Descriptors.Change} {
+				parsed, err := ParseDescriptor(d)
+				require.NoError(t, err)
+				descriptors = append(descriptors, parsed)
+			}
+		}
+
+		paths, err := SigningPaths(seed, packet, descriptors)
+		require.NoError(t, err)
+		assert.Equal(t, []string{inputs[0].path, inputs[1].path, inputs[2].path, inputs[3].path}, paths)
+
+		signed, err := SignPSBT(seed, packet, descriptors)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 2, 3}, signed)
+		for i, input := range inputs {
+			verifyInput(t, packet, i, input.addressType)
+		}
+
+		// the key origins are recorded for the finalizer
+		assert.Len(t, packet.inputs[0].ofType(inputBip32Derivation), 1)
+		assert.Len(t, packet.inputs[1].ofType(inputTapBip32Derivation), 1)
+	})
+
+	t.Run("keys selected from the bip32 derivations", func(t *testing.T) {
+		packet := newTestPSBT(t, seed, inputs[:1])
+		privateKey, err := lib.DerivePrivateKey(seed, inputs[0].path, false)
+		require.NoError(t, err)
+		origin, _ := hex.DecodeString("73c5da0a")
+		for _, index := range []uint32{hardenedKeyStart + 84, hardenedKeyStart, hardenedKeyStart, 0, 3} {
+			origin = binary.LittleEndian.AppendUint32(origin, index)
+		}
+		packet.inputs[0].set(append([]byte{inputBip32Derivation}, privateKey.PubKey().SerializeCompressed()...), origin)
+		paths, err := SigningPaths(seed, packet, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{inputs[0].path}, paths)
+
+		signed, err := SignPSBT(seed, packet, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, signed)
+		verifyInput(t, packet, 0, inputs[0].addressType)
+	})
+
+	t.Run("nothing to sign", func(t *testing.T) {
+		packet := newTestPSBT(t, seed, inputs[:1])
+		_, err := SignPSBT(seed, packet, nil)
+		require.ErrorIs(t, err, ErrNothingToSign)
+	})
+
+	t.Run("descriptor of another wallet", func(t *testing.T) {
+		other, err := lib.SeedFromMnemonic("legal winner thank year wave sausage worth useful legal winner thank yellow", "")
+		require.NoError(t, err)
+		accounts, err := lib.BitcoinWatchOnlyAccounts(other, 0, false)
+		require.NoError(t, err)
+		parsed, err := ParseDescriptor(accounts[2].Descriptors.Receive)
+		require.NoError(t, err)
+
+		_, err = SignPSBT(seed, newTestPSBT(t, seed, inputs[:1]), []*Descriptor{parsed})
+		require.ErrorIs(t, err, ErrDescriptorFingerprint)
+	})
+}
+
+func TestWalletOutputs(t *testing.T) {
+	seed := testSeed(t)
+	other, err := lib.SeedFromMnemonic("legal winner thank year wave sausage worth useful legal winner thank yellow", "")
+	require.NoError(t, err)
+	key := func(seed []byte, path string) []byte {
+		t.Helper()
+		privateKey, err := lib.DerivePrivateKey(seed, path, false)
+		require.NoError(t, err)
+		return privateKey.PubKey().SerializeCompressed()
+	}
+	script := func(addressType string, publicKey []byte) []byte {
+		t.Helper()
+		script, err := outputScript(addressType, publicKey)
+		require.NoError(t, err)
+		return script
+	}
+	origin := func(indexes ...uint32) []byte {
+		value, _ := hex.DecodeString("73c5da0a")
+		for _, index := range indexes {
+			value = binary.LittleEndian.AppendUint32(value, index)
+		}
+		return value
+	}
+	accounts, err := lib.BitcoinWatchOnlyAccounts(seed, 0, false)
+	require.NoError(t, err)
+	var descriptors []*Descriptor
+	for _, account := range accounts {
+		parsed, err := ParseDescriptor(account.Descriptors.Change)
+		require.NoError(t, err)
+		if parsed.AddressType == lib.AddressTypeP2WPKH {
+			descriptors = append(descriptors, parsed)
+		}
+	}
+	require.Len(t, descriptors, 1)
+
+	// output 0 is the OP_RETURN of newTestPSBT; 1 pays the change descriptor, 2 the key of its
+	// derivation, and 3 another wallet whatever its derivation claims
+	packet := newTestPSBT(t, seed, []testInput{{"m/84'/0'/0'/0/3", lib.AddressTypeP2WPKH, 50_000}})
+	packet.Tx.AddTxOut(wire.NewTxOut(1000, script(lib.AddressTypeP2WPKH, key(seed, "m/84'/0'/0'/1/5"))))
+	packet.Tx.AddTxOut(wire.NewTxOut(1000, script(lib.AddressTypeP2SHP2WPKH, key(seed, "m/49'/0'/0'/1/0"))))
+	packet.Tx.AddTxOut(wire.NewTxOut(1000, script(lib.AddressTypeP2WPKH, key(other, "m/84'/0'/0'/1/9"))))
+	packet.outputs = append(packet.outputs, nil,
+		psbtMap{{key: append([]byte{outputBip32Derivation}, key(seed, "m/49'/0'/0'/1/0")...),
+			value: origin(hardenedKeyStart+49, hardenedKeyStart, hardenedKeyStart, 1, 0)}},
+		psbtMap{{key: append([]byte{outputBip32Derivation}, key(other, "m/84'/0'/0'/1/9")...),
+			value: origin(hardenedKeyStart+84, hardenedKeyStart, hardenedKeyStart, 1, 9)}})
+
+	own, err := WalletOutputs(seed, packet, descriptors)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, own)
+
+	own, err = WalletOutputs(other, packet, nil)
+	require.NoError(t, err)
+	assert.Empty(t, own, "the derivation carries the fingerprint of seed")
+
+	// the key signing with CreateSignedTransaction is the wallet without descriptors
+	own, err = KeyOutputs(seed, packet, "m/84'/0'/0'/1/5")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, own)
+	own, err = KeyOutputs(seed, packet, "m/84'/0'/0'/0/3")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, own)
+	_, err = KeyOutputs(seed, packet, "not a path")
+	require.Error(t, err)
+}
+
+func TestCreateSignedTransaction(t *testing.T) {
+	seed := testSeed(t)
+	packet := newTestPSBT(t, seed, []testInput{
+		{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000},
+		{"m/84'/0'/0'/0/1", lib.AddressTypeP2WPKH, 50_000},
+	})
+	encoded, err := packet.Base64()
+	require.NoError(t, err)
+
+	adapter := newTestAdapter()
+	require.NoError(t, adapter.ValidatePayload(encoded))
+	require.ErrorIs(t, adapter.ValidatePayload("cHNidP8="), lib.ErrInvalidPayload)
+
+	signed, err := adapter.CreateSignedTransaction(seed, "m/84'/0'/0'/0/1", encoded)
+	require.NoError(t, err)
+	result, err := DecodePSBT(signed)
+	require.NoError(t, err)
+	assert.Empty(t, result.inputs[0].ofType(inputPartialSig))
+	verifyInput(t, result, 1, lib.AddressTypeP2WPKH)
+
+	_, err = adapter.CreateSignedTransaction(seed, "m/84'/0'/0'/0/2", encoded)
+	require.ErrorIs(t, err, ErrNothingToSign)
+}
+
+func TestLegacyInputNeedsNonWitnessUtxo(t *testing.T) {
+	seed := testSeed(t)
+	packet := newTestPSBT(t, seed, []testInput{{"m/44'/0'/0'/0/0", lib.AddressTypeP2PKH, 80_000}})
+
+	// replace the non-witness utxo by the witness utxo of the same output
+	utxo, err := packet.utxo(0)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writeTxOut(&buf, utxo))
+	packet.inputs[0] = psbtMap{{key: []byte{inputWitnessUtxo}, value: buf.Bytes()}}
+
+	_, err = newTestAdapter().CreateSignedTransaction(seed, "m/44'/0'/0'/0/0", mustBase64(t, packet))
+	require.ErrorIs(t, err, ErrMissingUtxo)
+}
+
+func mustBase64(t *testing.T, packet *Packet) string {
+	t.Helper()
+	encoded, err := packet.Base64()
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestFeeRate(t *testing.T) {
+	seed := testSeed(t)
+	tests := []struct {
+		name    string
+		input   testInput
+		wantFee int64
+		// the transaction without witnesses is 61 bytes, the weight of the signatures is added
+		wantVSize int64
+	}{
+		{"p2wpkh", testInput{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000}, 40_000, 89},
+		{"p2pkh", testInput{"m/44'/0'/0'/0/0", lib.AddressTypeP2PKH, 50_000}, 40_000, 168},
+		{"p2tr", testInput{"m/86'/0'/0'/0/0", lib.AddressTypeP2TR, 20_000}, 10_000, 78},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, rate, err := newTestPSBT(t, seed, []testInput{tt.input}).FeeRate()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFee, fee)
+			assert.InDelta(t, float64(tt.wantFee)/float64(tt.wantVSize), rate, 1e-9)
+		})
+	}
+
+	_, _, err := newTestPSBT(t, seed, []testInput{{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 5_000}}).FeeRate()
+	require.ErrorIs(t, err, ErrNegativeFee)
+}
+
+func TestBuildTransaction(t *testing.T) {
+	seed := testSeed(t)
+	utxos := []UTXO{
+		{TxID: strings.Repeat("11", 32), Vout: 0, Value: 50_000, Path: "m/84'/0'/0'/0/0"},
+		{TxID: strings.Repeat("22", 32), Vout: 3, Value: 60_000, Path: "m/49'/0'/0'/0/1"},
+		{TxID: strings.Repeat("33", 32), Vout: 1, Value: 70_000, Path: "m/86'/0'/0'/0/0"},
+	}
+	payments := []Payment{
+		{Address: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", Value: 100_000},
+		{Address: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", Value: 20_000},
+	}
+	request := &TxRequest{UTXOs: utxos, Payments: payments, FeeRate: 12.5, ChangePath: "m/84'/0'/0'/1/0"}
+
+	t.Run("signed with change", func(t *testing.T) {
+		built, err := BuildTransaction(seed, request)
+		require.NoError(t, err)
+		raw, err := hex.DecodeString(built.Raw)
+		require.NoError(t, err)
+		tx := wire.NewMsgTx(2)
+		require.NoError(t, tx.Deserialize(bytes.NewReader(raw)))
+		assert.Equal(t, built.TxID, tx.TxHash().String())
+
+		require.Len(t, tx.TxOut, 3)
+		change, err := newTestAdapter().DeriveAddress(seed, "m/84'/0'/0'/1/0", false)
+		require.NoError(t, err)
+		require.NotNil(t, built.Change)
+		assert.Equal(t, change, built.Change.Address)
+		assert.Equal(t, built.Change.Value, tx.TxOut[2].Value)
+		assert.Equal(t, int64(180_000-120_000)-built.Fee, built.Change.Value)
+		// the signatures are at most as large as estimated
+		assert.GreaterOrEqual(t, built.FeeRate, request.FeeRate)
+		assert.Less(t, built.FeeRate, request.FeeRate*1.05)
+
+		prevouts := make([]*wire.TxOut, 0, len(utxos))
+		for _, utxo := range utxos {
+			publicKey, addressType, err := walletKey(seed, utxo.Path)
+			require.NoError(t, err)
+			script, err := outputScript(addressType, publicKey)
+			require.NoError(t, err)
+			prevouts = append(prevouts, wire.NewTxOut(utxo.Value, script))
+		}
+		for i, prevout := range prevouts {
+			assert.Equal(t, uint32(rbfSequence), tx.TxIn[i].Sequence)
+			if isTaprootScript(prevout.PkScript) {
+				require.Len(t, tx.TxIn[i].Witness, 1)
+				hash, err := taprootSigHash(tx, i, prevouts, sigHashDefault)
+				require.NoError(t, err)
+				assert.True(t, verifySchnorr(t, prevout.PkScript[2:], hash, tx.TxIn[i].Witness[0]), "taproot signature")
+				continue
+			}
+			engine, err := txscript.NewEngine(prevout.PkScript, tx, i, txscript.StandardVerifyFlags, nil,
+				txscript.NewTxSigHashes(tx), prevout.Value)
+			require.NoError(t, err)
+			require.NoError(t, engine.Execute(), i)
+		}
+	})
+
+	t.Run("change below the dust limit goes to the fee", func(t *testing.T) {
+		built, err := BuildTransaction(seed, &TxRequest{UTXOs: utxos[:1], Payments: []Payment{
+			{Address: payments[0].Address, Value: 48_500},
+		}, FeeRate: 10, ChangePath: "m/84'/0'/0'/1/0"})
+		require.NoError(t, err)
+		assert.Nil(t, built.Change)
+		assert.Equal(t, int64(1_500), built.Fee)
+	})
+
+	for name, tt := range map[string]struct {
+		request *TxRequest
+		err     error
+	}{
+		"insufficient funds": {&TxRequest{UTXOs: utxos[:1], Payments: []Payment{
+			{Address: payments[0].Address, Value: 49_500},
+		}, FeeRate: 10, ChangePath: request.ChangePath}, ErrInsufficientFunds},
+		"legacy utxo": {&TxRequest{UTXOs: []UTXO{{TxID: utxos[0].TxID, Value: 50_000, Path: "m/44'/0'/0'/0/0"}},
+			Payments: payments[:1], FeeRate: 1, ChangePath: request.ChangePath}, ErrLegacyUTXO},
+		"duplicate utxo": {&TxRequest{UTXOs: []UTXO{utxos[0], utxos[0]}, Payments: payments[:1], FeeRate: 1,
+			ChangePath: request.ChangePath}, ErrDuplicateUTXO},
+		"dust output": {&TxRequest{UTXOs: utxos, Payments: []Payment{{Address: payments[0].Address, Value: 545}},
+			FeeRate: 1, ChangePath: request.ChangePath}, ErrDustOutput},
+		"address of the test network": {&TxRequest{UTXOs: utxos, Payments: []Payment{
+			{Address: "tb1q6rz28mcfaxtmd6v789l9rrlrusdprr9pqcpvkl", Value: 1_000},
+		}, FeeRate: 1, ChangePath: request.ChangePath}, ErrInvalidAddress},
+		"no fee rate": {&TxRequest{UTXOs: utxos, Payments: payments, ChangePath: request.ChangePath}, ErrInvalidFeeRate},
+		"no utxo":     {&TxRequest{Payments: payments, FeeRate: 1, ChangePath: request.ChangePath}, ErrNoUTXOs},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := BuildTransaction(seed, tt.request)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
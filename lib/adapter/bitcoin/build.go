@@ -0,0 +1,247 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+const (
+	// DustLimit is the smallest output value built, in satoshis, the dust threshold of p2pkh outputs
+	DustLimit = 546
+	// builtTxVersion is the version of the built transactions, allowing relative lock times
+	builtTxVersion = 2
+	// rbfSequence signals the inputs as replaceable (BIP-125), so an underpaying transaction can be bumped
+	rbfSequence = wire.MaxTxInSequenceNum - 2
+)
+
+// UTXO -- an output of the wallet spent by a built transaction, paying to the key of Path
+type UTXO struct {
+	TxID  string `json:"txid"`
+	Vout  uint32 `json:"vout"`
+	Value int64  `json:"value"`
+	Path  string `json:"path"`
+}
+
+// Payment -- an output of a built transaction, in satoshis
+type Payment struct {
+	Address string `json:"address"`
+	Value   int64  `json:"value"`
+}
+
+// TxRequest -- a transaction to build, spending every UTXO, paying the payments in order and the
+// change, if any is left above the dust limit, last to the key of ChangePath
+type TxRequest struct {
+	UTXOs      []UTXO
+	Payments   []Payment
+	FeeRate    float64
+	ChangePath string
+	IsDev      bool
+}
+
+// BuiltTx -- a signed transaction, Raw hex encoded with its witnesses
+type BuiltTx struct {
+	Raw     string
+	TxID    string
+	Fee     int64
+	VSize   int64
+	FeeRate float64
+	// Change is the change output, nil when the change was below the dust limit and left to the fee
+	Change *Payment
+}
+
+// BuildTransaction builds and signs the transaction of request with the keys of seed. The fee is
+// the fee rate, in sat/vB, of the virtual size estimated with signatures of their maximum size, so
+// the signed transaction pays at least the fee rate. Only segwit UTXOs are spent: their signatures
+// commit to the value spent, so a wrong value gives an invalid transaction rather than a wrong fee.
+func BuildTransaction(seed []byte, request *TxRequest) (*BuiltTx, error) {
+	if len(request.UTXOs) == 0 {
+		return nil, ErrNoUTXOs
+	}
+	if len(request.Payments) == 0 {
+		return nil, ErrNoOutputs
+	}
+	if !(request.FeeRate > 0) || math.IsInf(request.FeeRate, 0) {
+		return nil, ErrInvalidFeeRate
+	}
+	net := netParams(request.IsDev)
+
+	tx := wire.NewMsgTx(builtTxVersion)
+	packet := &Packet{Tx: tx}
+	paths := make([][]uint32, 0, len(request.UTXOs))
+	spent := make(map[wire.OutPoint]bool, len(request.UTXOs))
+	var in, out int64
+	for i, utxo := range request.UTXOs {
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil || utxo.Value <= 0 || utxo.Value > btcutil.MaxSatoshi {
+			return nil, fmt.Errorf("%w: utxo %d", ErrInvalidUTXO, i)
+		}
+		outpoint := wire.OutPoint{Hash: *hash, Index: utxo.Vout}
+		if spent[outpoint] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateUTXO, outpoint)
+		}
+		spent[outpoint] = true
+
+		path, err := lib.ParseDerivationPath(utxo.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: utxo %d: %w", ErrInvalidUTXO, i, err)
+		}
+		publicKey, addressType, err := walletKey(seed, utxo.Path)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %d: %w", i, err)
+		}
+		if addressType == lib.AddressTypeP2PKH {
+			return nil, fmt.Errorf("%w: utxo %d", ErrLegacyUTXO, i)
+		}
+		script, err := outputScript(addressType, publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		var witnessUtxo bytes.Buffer
+		if err := writeTxOut(&witnessUtxo, wire.NewTxOut(utxo.Value, script)); err != nil {
+			return nil, err
+		}
+		txIn := wire.NewTxIn(&outpoint, nil, nil)
+		txIn.Sequence = rbfSequence
+		tx.AddTxIn(txIn)
+		packet.inputs = append(packet.inputs, psbtMap{{key: []byte{inputWitnessUtxo}, value: witnessUtxo.Bytes()}})
+		paths = append(paths, path)
+		in += utxo.Value
+	}
+
+	for i, payment := range request.Payments {
+		script, err := addressScript(payment.Address, net)
+		if err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		if payment.Value < DustLimit || payment.Value > btcutil.MaxSatoshi {
+			return nil, fmt.Errorf("%w: output %d of %d sat", ErrDustOutput, i, payment.Value)
+		}
+		tx.AddTxOut(wire.NewTxOut(payment.Value, script))
+		packet.outputs = append(packet.outputs, psbtMap{})
+		out += payment.Value
+	}
+
+	change, err := addChange(seed, packet, request, in-out)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := newSigner(seed, packet).sign(func(i int) [][]uint32 { return [][]uint32{paths[i]} }); err != nil {
+		return nil, err
+	}
+	if err := finalizeInputs(packet); err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	if err := tx.Serialize(&raw); err != nil {
+		return nil, err
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	vsize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	fee := in - out
+	if change != nil {
+		fee -= change.Value
+	}
+	return &BuiltTx{
+		Raw:     hex.EncodeToString(raw.Bytes()),
+		TxID:    tx.TxHash().String(),
+		Fee:     fee,
+		VSize:   vsize,
+		FeeRate: float64(fee) / float64(vsize),
+		Change:  change,
+	}, nil
+}
+
+// addChange pays the change of the surplus, the value of the inputs above the payments, to the
+// key of the change path when it is left above the dust limit once the fee of the change output is
+// paid, and returns it. Otherwise the surplus goes to the fee, which it must cover.
+func addChange(seed []byte, packet *Packet, request *TxRequest, surplus int64) (*Payment, error) {
+	publicKey, addressType, err := walletKey(seed, request.ChangePath)
+	if err != nil {
+		return nil, fmt.Errorf("change: %w", err)
+	}
+	script, err := outputScript(addressType, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	_, vsize, err := packet.signedVSize()
+	if err != nil {
+		return nil, err
+	}
+
+	packet.Tx.AddTxOut(wire.NewTxOut(0, script))
+	packet.outputs = append(packet.outputs, psbtMap{})
+	_, changeVSize, err := packet.signedVSize()
+	if err != nil {
+		return nil, err
+	}
+	if value := surplus - feeAt(request.FeeRate, changeVSize); value >= DustLimit {
+		packet.Tx.TxOut[len(packet.Tx.TxOut)-1].Value = value
+		address, err := encodeAddress(addressType, publicKey, netParams(request.IsDev))
+		if err != nil {
+			return nil, err
+		}
+		return &Payment{Address: address, Value: value}, nil
+	}
+
+	packet.Tx.TxOut = packet.Tx.TxOut[:len(packet.Tx.TxOut)-1]
+	packet.outputs = packet.outputs[:len(packet.outputs)-1]
+	if missing := feeAt(request.FeeRate, vsize) - surplus; missing > 0 {
+		return nil, fmt.Errorf("%w: %d sat short", ErrInsufficientFunds, missing)
+	}
+	return nil, nil
+}
+
+// feeAt returns the fee of vsize virtual bytes at rate sat/vB, rounded up
+func feeAt(rate float64, vsize int64) int64 {
+	return int64(math.Ceil(rate * float64(vsize)))
+}
+
+// walletKey returns the compressed public key of the derivation path and its address type
+func walletKey(seed []byte, derivationPath string) ([]byte, string, error) {
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		return nil, "", err
+	}
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return privateKey.PubKey().SerializeCompressed(), addressType, nil
+}
+
+// finalizeInputs moves the signature of every input of packet to the scriptSig and witness of its
+// transaction input
+func finalizeInputs(packet *Packet) error {
+	for i, input := range packet.inputs {
+		txIn := packet.Tx.TxIn[i]
+		if sig, ok := input.get(inputTapKeySig); ok {
+			txIn.Witness = wire.TxWitness{sig}
+			continue
+		}
+		sigs := input.ofType(inputPartialSig)
+		if len(sigs) != 1 {
+			return fmt.Errorf("%w: input %d", ErrNothingToSign, i)
+		}
+		txIn.Witness = wire.TxWitness{sigs[0].value, sigs[0].key[1:]}
+		if redeemScript, ok := input.get(inputRedeemScript); ok {
+			scriptSig, err := txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			if err != nil {
+				return err
+			}
+			txIn.SignatureScript = scriptSig
+		}
+	}
+	return nil
+}
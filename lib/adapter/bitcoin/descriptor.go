@@ -0,0 +1,231 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+const (
+	hardenedKeyStart = hdkeychain.HardenedKeyStart
+	// xOnlyKeyLength and compressedKeyLength are the hex lengths of single keys in descriptors
+	xOnlyKeyLength      = 64
+	compressedKeyLength = 66
+)
+
+// Descriptor is a parsed single key output descriptor (BIP-380): pkh, sh(wpkh), wpkh or tr
+// without script path, whose key carries its origin
+type Descriptor struct {
+	AddressType string
+	Fingerprint []byte
+	// Origin is the derivation path of the key from the master key
+	Origin []uint32
+	// ExtendedKey is the extended public key, nil for single public keys
+	ExtendedKey *hdkeychain.ExtendedKey
+	// PublicKey is the compressed single public key
+	PublicKey []byte
+	// Derivation is the unhardened path from the extended key, followed by the index when Wildcard
+	Derivation []uint32
+	Wildcard   bool
+}
+
+// descriptorWrapper is the output script expression of an address type
+type descriptorWrapper struct {
+	prefix, suffix, addressType string
+}
+
+func descriptorWrappers() []descriptorWrapper {
+	return []descriptorWrapper{
+		{"sh(wpkh(", "))", lib.AddressTypeP2SHP2WPKH},
+		{"wpkh(", ")", lib.AddressTypeP2WPKH},
+		{"pkh(", ")", lib.AddressTypeP2PKH},
+		{"tr(", ")", lib.AddressTypeP2TR},
+	}
+}
+
+// ParseDescriptor parses descriptor, checking its checksum when present
+func ParseDescriptor(descriptor string) (*Descriptor, error) {
+	body, checksum, hasChecksum := strings.Cut(strings.TrimSpace(descriptor), "#")
+	if hasChecksum {
+		expected, err := lib.DescriptorChecksum(body)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != expected {
+			return nil, fmt.Errorf("%w: %s", ErrDescriptorChecksum, descriptor)
+		}
+	}
+
+	for _, wrapper := range descriptorWrappers() {
+		if !strings.HasPrefix(body, wrapper.prefix) || !strings.HasSuffix(body, wrapper.suffix) {
+			continue
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(body, wrapper.prefix), wrapper.suffix)
+		if strings.ContainsAny(key, "(),") {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedDescriptor, descriptor)
+		}
+		parsed, err := parseKeyExpression(key, wrapper.addressType == lib.AddressTypeP2TR)
+		if err != nil {
+			return nil, err
+		}
+		parsed.AddressType = wrapper.addressType
+		return parsed, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedDescriptor, descriptor)
+}
+
+// parseKeyExpression parses [<fingerprint>/<origin>]<key>/<derivation>; x-only keys are only valid in tr
+func parseKeyExpression(expression string, xOnly bool) (*Descriptor, error) {
+	if !strings.HasPrefix(expression, "[") {
+		return nil, ErrDescriptorOriginMissing
+	}
+	origin, key, found := strings.Cut(expression[1:], "]")
+	if !found {
+		return nil, fmt.Errorf("%w: unterminated key origin", ErrInvalidDescriptor)
+	}
+
+	d := &Descriptor{}
+	originParts := strings.Split(origin, "/")
+	fingerprint, err := hex.DecodeString(originParts[0])
+	if err != nil || len(fingerprint) != fingerprintLength {
+		return nil, fmt.Errorf("%w: key origin fingerprint %q", ErrInvalidDescriptor, originParts[0])
+	}
+	d.Fingerprint = fingerprint
+	for _, component := range originParts[1:] {
+		index, err := parsePathComponent(component)
+		if err != nil {
+			return nil, err
+		}
+		d.Origin = append(d.Origin, index)
+	}
+
+	keyParts := strings.Split(key, "/")
+	switch {
+	case len(keyParts[0]) == compressedKeyLength || (xOnly && len(keyParts[0]) == xOnlyKeyLength):
+		if len(keyParts) > 1 {
+			return nil, fmt.Errorf("%w: derivation from a single key", ErrInvalidDescriptor)
+		}
+		publicKey, err := hex.DecodeString(keyParts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: public key: %w", ErrInvalidDescriptor, err)
+		}
+		if len(publicKey) == xOnlyKeyLength/2 {
+			// the even key, taproot only commits to the x coordinate
+			publicKey = append([]byte{0x02}, publicKey...)
+		}
+		d.PublicKey = publicKey
+		return d, nil
+	default:
+		if d.ExtendedKey, err = hdkeychain.NewKeyFromString(keyParts[0]); err != nil {
+			return nil, fmt.Errorf("%w: extended key: %w", ErrInvalidDescriptor, err)
+		}
+		if d.ExtendedKey.IsPrivate() {
+			return nil, fmt.Errorf("%w: private keys are not accepted", ErrInvalidDescriptor)
+		}
+	}
+
+	for i, component := range keyParts[1:] {
+		last := i == len(keyParts)-2
+		if last && component == "*" {
+			d.Wildcard = true
+			break
+		}
+		if last && strings.HasPrefix(component, "*") {
+			return nil, ErrHardenedDerivation
+		}
+		index, err := parsePathComponent(component)
+		if err != nil {
+			return nil, err
+		}
+		if index >= hardenedKeyStart {
+			return nil, ErrHardenedDerivation
+		}
+		d.Derivation = append(d.Derivation, index)
+	}
+	return d, nil
+}
+
+// parsePathComponent parses a path index, hardened with h, H or '
+func parsePathComponent(component string) (uint32, error) {
+	var offset uint32
+	if trimmed := strings.TrimRight(component, "hH'"); len(component)-len(trimmed) == 1 {
+		offset, component = hardenedKeyStart, trimmed
+	}
+	index, err := strconv.ParseUint(component, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("%w: path component %q", ErrInvalidDescriptor, component)
+	}
+	return uint32(index) + offset, nil
+}
+
+// key returns the full derivation path and the compressed public key of the descriptor at index,
+// index being ignored without wildcard
+func (d *Descriptor) key(index uint32) ([]uint32, []byte, error) {
+	path := append(append([]uint32{}, d.Origin...), d.Derivation...)
+	if d.ExtendedKey == nil {
+		return path, d.PublicKey, nil
+	}
+
+	key := d.ExtendedKey
+	derivation := d.Derivation
+	if d.Wildcard {
+		derivation = append(append([]uint32{}, derivation...), index)
+		path = append(path, index)
+	}
+	for _, i := range derivation {
+		var err error
+		if key, err = key.Derive(i); err != nil {
+			return nil, nil, err
+		}
+	}
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return path, publicKey.SerializeCompressed(), nil
+}
+
+// checkOwner checks the descriptor key is the key of seed at the key origin
+func (d *Descriptor) checkOwner(seed, fingerprint []byte) error {
+	if !bytes.Equal(d.Fingerprint, fingerprint) {
+		return fmt.Errorf("%w: %x", ErrDescriptorFingerprint, d.Fingerprint)
+	}
+
+	if d.ExtendedKey == nil {
+		publicKey, err := publicKeyAt(seed, d.Origin)
+		if err != nil {
+			return err
+		}
+		// x-only taproot keys were lifted to the even key, only the x coordinate has to match
+		if d.AddressType == lib.AddressTypeP2TR && bytes.Equal(publicKey[1:], d.PublicKey[1:]) {
+			d.PublicKey = publicKey
+		}
+		if !bytes.Equal(publicKey, d.PublicKey) {
+			return ErrDescriptorKeyMismatch
+		}
+		return nil
+	}
+
+	// the version bytes as xpub, tpub or SLIP-132 do not matter
+	expected, err := lib.ExtendedPublicKeyAt(seed, d.Origin, netParams(false))
+	if err != nil {
+		return err
+	}
+	expectedKey, err := expected.ECPubKey()
+	if err != nil {
+		return err
+	}
+	actualKey, err := d.ExtendedKey.ECPubKey()
+	if err != nil {
+		return err
+	}
+	if !expectedKey.IsEqual(actualKey) || !bytes.Equal(expected.ChainCode(), d.ExtendedKey.ChainCode()) {
+		return ErrDescriptorKeyMismatch
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package bitcoin
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPSBT             = errors.New("invalid PSBT")
+	ErrUnsupportedPSBTVersion  = errors.New("only version 0 PSBTs are supported")
+	ErrDuplicatePSBTKey        = errors.New("duplicate PSBT key")
+	ErrMissingUnsignedTx       = errors.New("PSBT has no unsigned transaction")
+	ErrSignedUnsignedTx        = errors.New("PSBT unsigned transaction has signatures")
+	ErrUtxoMismatch            = errors.New("non-witness utxo does not match the input outpoint")
+	ErrMissingUtxo             = errors.New("input has no utxo")
+	ErrMissingPrevouts         = errors.New("taproot signing needs the utxo of every input")
+	ErrUnsupportedSighash      = errors.New("unsupported sighash type")
+	ErrNothingToSign           = errors.New("no input of the PSBT can be signed by this wallet")
+	ErrInvalidDescriptor       = errors.New("invalid descriptor")
+	ErrUnsupportedDescriptor   = errors.New("unsupported descriptor: expected pkh, sh(wpkh), wpkh or tr with one key")
+	ErrDescriptorChecksum      = errors.New("descriptor checksum mismatch")
+	ErrDescriptorFingerprint   = errors.New("descriptor key origin is not this wallet")
+	ErrDescriptorKeyMismatch   = errors.New("descriptor key does not match the wallet key at its origin path")
+	ErrHardenedDerivation      = errors.New("hardened derivation after an extended public key")
+	ErrInvalidTaprootTweak     = errors.New("invalid taproot tweak")
+	ErrDescriptorOriginMissing = errors.New("descriptor key has no key origin")
+	ErrUnsupportedScriptType   = errors.New("unsupported multisig script type: expected p2wsh or p2sh-p2wsh")
+	ErrMultisigThreshold       = errors.New("multisig threshold must be between 1 and the number of keys")
+	ErrMultisigKeyCount        = errors.New("multisig wallets have between 1 and 20 keys")
+	ErrMultisigKey             = errors.New("multisig keys are account xpubs with key origin and no derivation")
+	ErrDuplicateMultisigKey    = errors.New("duplicate multisig key")
+	ErrNotCosigner             = errors.New("the wallet is not a cosigner of the multisig wallet")
+	ErrNegativeFee             = errors.New("PSBT outputs exceed its inputs")
+	ErrInvalidAddress          = errors.New("invalid bitcoin address for the network")
+	ErrInvalidHRP              = errors.New("hrp must be 1 to 83 lowercase printable ASCII characters")
+	ErrNoUTXOs                 = errors.New("the transaction spends no utxo")
+	ErrNoOutputs               = errors.New("the transaction pays no output")
+	ErrInvalidUTXO             = errors.New("utxo must have a txid, a positive value and the derivation path of its key")
+	ErrDuplicateUTXO           = errors.New("utxo spent twice")
+	ErrLegacyUTXO              = errors.New("legacy (m/44') utxos commit to no value in their signature, sign them as a PSBT")
+	ErrDustOutput              = errors.New("output value is below the dust limit")
+	ErrInvalidFeeRate          = errors.New("fee rate must be a positive number of sat/vB")
+	ErrInsufficientFunds       = errors.New("utxos do not cover the outputs and the fee")
+)
@@ -0,0 +1,92 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Weight the signatures add to an input spending a single key output, beyond the stripped size
+// of the input with an empty scriptSig. ECDSA signatures are counted at their 72 byte maximum.
+const (
+	// scriptSig of <sig> <pubkey>
+	p2pkhSignedWeight = (1 + 72 + 1 + 33) * blockchain.WitnessScaleFactor
+	// witness of <sig> <pubkey>
+	p2wpkhSignedWeight = 1 + 1 + 72 + 1 + 33
+	// scriptSig pushing the P2WPKH redeem script, and its witness
+	p2shP2wpkhSignedWeight = (1+22)*blockchain.WitnessScaleFactor + p2wpkhSignedWeight
+	// witness of the 64 byte schnorr signature
+	p2trSignedWeight = 1 + 1 + 64
+	// segwitMarkerWeight is the marker and flag of transactions with witnesses
+	segwitMarkerWeight = 2
+)
+
+// FeeRate returns the fee of the transaction, in satoshis, and its rate in sat/vB once signed.
+// The virtual size is estimated from the outputs spent by the inputs, as single key outputs;
+// inputs already finalized are counted with their final scripts.
+func (p *Packet) FeeRate() (int64, float64, error) {
+	in, vsize, err := p.signedVSize()
+	if err != nil {
+		return 0, 0, err
+	}
+	var out int64
+	for _, txOut := range p.Tx.TxOut {
+		out += txOut.Value
+	}
+
+	fee := in - out
+	if fee < 0 {
+		return 0, 0, fmt.Errorf("%w: outputs exceed inputs by %d sat", ErrNegativeFee, -fee)
+	}
+	return fee, float64(fee) / float64(vsize), nil
+}
+
+// signedVSize returns the value of the outputs spent by the inputs and the estimated virtual size
+// of the transaction once signed
+func (p *Packet) signedVSize() (int64, int64, error) {
+	var in int64
+	weight := int64(p.Tx.SerializeSizeStripped()) * blockchain.WitnessScaleFactor
+	witness := false
+	for i := range p.Tx.TxIn {
+		utxo, err := p.utxo(i)
+		if err != nil {
+			return 0, 0, err
+		}
+		in += utxo.Value
+
+		if scriptSig, ok := p.inputs[i].get(inputFinalScriptSig); ok {
+			weight += int64(len(scriptSig)) * blockchain.WitnessScaleFactor
+		}
+		if final, ok := p.inputs[i].get(inputFinalScriptWitness); ok {
+			weight += int64(len(final))
+			witness = true
+		}
+		if p.finalized(i) {
+			continue
+		}
+
+		switch {
+		case isTaprootScript(utxo.PkScript):
+			weight += p2trSignedWeight
+			witness = true
+		case txscript.GetScriptClass(utxo.PkScript) == txscript.PubKeyHashTy:
+			weight += p2pkhSignedWeight
+		case txscript.GetScriptClass(utxo.PkScript) == txscript.ScriptHashTy:
+			weight += p2shP2wpkhSignedWeight
+			witness = true
+		default:
+			weight += p2wpkhSignedWeight
+			witness = true
+		}
+	}
+	if witness {
+		weight += segwitMarkerWeight
+	}
+	return in, (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor, nil
+}
+
+// isTaprootScript reports whether script is a witness version 1 program of a 32 byte output key
+func isTaprootScript(script []byte) bool {
+	return len(script) == 34 && script[0] == txscript.OP_1 && script[1] == txscript.OP_DATA_32
+}
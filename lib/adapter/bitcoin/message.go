@@ -0,0 +1,57 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/secp"
+)
+
+// messageMagic prefixes the Bitcoin signed messages
+const messageMagic = "Bitcoin Signed Message:\n"
+
+// BIP-137 header bytes of the signatures of compressed keys, by address type, plus the recovery id
+//
+//nolint:gochecknoglobals // read-only lookup table
+var messageHeaders = map[string]byte{
+	lib.AddressTypeP2PKH:      31,
+	lib.AddressTypeP2SHP2WPKH: 35,
+	lib.AddressTypeP2WPKH:     39,
+}
+
+// MessageHash returns the double SHA-256 of the Bitcoin signed message of message
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarString(&buf, 0, messageMagic)
+	_ = wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
+// SignMessage signs the Bitcoin signed message of message with the key of derivationPath, following
+// BIP-137 for the address type of the purpose of the path. It returns the base64 signature and the
+// compressed public key; taproot addresses, which need BIP-322, return lib.ErrProofUnsupported.
+func SignMessage(seed []byte, derivationPath, message string, isDev bool) (string, []byte, error) {
+	addressType, err := addressTypeOf(derivationPath)
+	if err != nil {
+		return "", nil, err
+	}
+	header, ok := messageHeaders[addressType]
+	if !ok {
+		return "", nil, lib.ErrProofUnsupported
+	}
+	privateKey, err := lib.DerivePrivateKey(seed, derivationPath, isDev)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// [R || S || V] to [header + V || R || S]
+	signature, err := secp.Sign(MessageHash(message), privateKey.Serialize())
+	if err != nil {
+		return "", nil, err
+	}
+	compact := append([]byte{header + signature[64]}, signature[:64]...)
+	return base64.StdEncoding.EncodeToString(compact), privateKey.PubKey().SerializeCompressed(), nil
+}
@@ -0,0 +1,389 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Script types of multisig wallets
+const (
+	MultisigP2WSH     = "p2wsh"
+	MultisigP2SHP2WSH = "p2sh-p2wsh"
+)
+
+// bip48Purpose is the purpose of the multisig account paths m/48'/<coinType>'/<account>'/<scriptType>'
+const bip48Purpose = 48
+
+// bip48ScriptType returns the BIP-48 script type index of scriptType
+func bip48ScriptType(scriptType string) (uint32, error) {
+	switch scriptType {
+	case MultisigP2SHP2WSH:
+		return 1, nil
+	case MultisigP2WSH:
+		return 2, nil //nolint:mnd // BIP-48 script type of native segwit
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedScriptType, scriptType)
+	}
+}
+
+// MultisigAccountPath returns the BIP-48 account path m/48'/<coinType>'/<account>'/<scriptType>'
+// of scriptType, the coin type being 1' on testnet
+func MultisigAccountPath(scriptType string, account uint32, testnet bool) ([]uint32, error) {
+	scriptIndex, err := bip48ScriptType(scriptType)
+	if err != nil {
+		return nil, err
+	}
+	coinType := uint32(slip44.Bitcoin)
+	if testnet {
+		coinType = uint32(slip44.TestNet)
+	}
+	return []uint32{
+		hardenedKeyStart + bip48Purpose, hardenedKeyStart + coinType,
+		hardenedKeyStart + account, hardenedKeyStart + scriptIndex,
+	}, nil
+}
+
+// Multisig is a sortedmulti wallet spent by Threshold of its account keys. The address at
+// <change>/<index> pays to the keys derived at <key>/<change>/<index>, sorted (BIP-67).
+type Multisig struct {
+	Threshold  int
+	ScriptType string
+	// Keys are the account keys, with their key origin
+	Keys []*Descriptor
+}
+
+// multisigKey is a key of a multisig address with its key origin
+type multisigKey struct {
+	publicKey   []byte
+	fingerprint []byte
+	path        []uint32
+}
+
+// NewMultisig creates the threshold of keys wallet of scriptType. Keys are key expressions
+// [<fingerprint>/<origin>]<xpub> of account keys, without derivation.
+func NewMultisig(threshold int, scriptType string, keys []string) (*Multisig, error) {
+	if _, err := bip48ScriptType(scriptType); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 || len(keys) > txscript.MaxPubKeysPerMultiSig {
+		return nil, ErrMultisigKeyCount
+	}
+	if threshold < 1 || threshold > len(keys) {
+		return nil, ErrMultisigThreshold
+	}
+
+	m := &Multisig{Threshold: threshold, ScriptType: scriptType}
+	seen := make(map[string]bool, len(keys))
+	for _, expression := range keys {
+		key, err := parseKeyExpression(strings.TrimSpace(expression), false)
+		if err != nil {
+			return nil, err
+		}
+		if key.ExtendedKey == nil || len(key.Derivation) > 0 || key.Wildcard {
+			return nil, fmt.Errorf("%w: %s", ErrMultisigKey, expression)
+		}
+		publicKey, err := key.ExtendedKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		id := string(publicKey.SerializeCompressed())
+		if seen[id] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMultisigKey, expression)
+		}
+		seen[id] = true
+		m.Keys = append(m.Keys, key)
+	}
+	return m, nil
+}
+
+// Descriptor returns the sortedmulti output descriptor of the receive (0) or change (1) chain,
+// e.g. wsh(sortedmulti(2,[73c5da0a/48h/0h/0h/2h]xpub.../0/*,...))#checksum
+func (m *Multisig) Descriptor(change uint32) (string, error) {
+	keys := make([]string, 0, len(m.Keys))
+	for _, key := range m.Keys {
+		keys = append(keys, key.keyExpression()+"/"+strconv.FormatUint(uint64(change), 10)+"/*")
+	}
+	descriptor := "wsh(sortedmulti(" + strconv.Itoa(m.Threshold) + "," + strings.Join(keys, ",") + "))"
+	if m.ScriptType == MultisigP2SHP2WSH {
+		descriptor = "sh(" + descriptor + ")"
+	}
+	return lib.DescriptorWithChecksum(descriptor)
+}
+
+// Address returns the address of change/index on net and its witness script
+func (m *Multisig) Address(change, index uint32, net *chaincfg.Params) (string, []byte, error) {
+	witnessScript, _, err := m.witnessScript(change, index)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var address btcutil.Address
+	if m.ScriptType == MultisigP2WSH {
+		hash := sha256.Sum256(witnessScript)
+		address, err = btcutil.NewAddressWitnessScriptHash(hash[:], net)
+	} else {
+		var redeemScript []byte
+		if _, redeemScript, err = m.outputScript(witnessScript); err != nil {
+			return "", nil, err
+		}
+		address, err = btcutil.NewAddressScriptHash(redeemScript, net)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return address.EncodeAddress(), witnessScript, nil
+}
+
+// chainKeys derives the account keys at change
+func (m *Multisig) chainKeys(change uint32) ([]*hdkeychain.ExtendedKey, error) {
+	chain := make([]*hdkeychain.ExtendedKey, 0, len(m.Keys))
+	for _, key := range m.Keys {
+		child, err := key.ExtendedKey.Derive(change)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, child)
+	}
+	return chain, nil
+}
+
+// witnessScript returns the witness script of change/index and its keys, sorted
+func (m *Multisig) witnessScript(change, index uint32) ([]byte, []multisigKey, error) {
+	chain, err := m.chainKeys(change)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.scriptAt(chain, change, index)
+}
+
+// scriptAt returns the witness script of index from the chain keys of change, and its keys
+func (m *Multisig) scriptAt(chain []*hdkeychain.ExtendedKey, change, index uint32) ([]byte, []multisigKey, error) {
+	keys := make([]multisigKey, 0, len(chain))
+	for k, chainKey := range chain {
+		child, err := chainKey.Derive(index)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, multisigKey{
+			publicKey:   publicKey.SerializeCompressed(),
+			fingerprint: m.Keys[k].Fingerprint,
+			path:        append(append([]uint32{}, m.Keys[k].Origin...), change, index),
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i].publicKey, keys[j].publicKey) < 0 })
+
+	builder := txscript.NewScriptBuilder().AddInt64(int64(m.Threshold))
+	for _, key := range keys {
+		builder.AddData(key.publicKey)
+	}
+	script, err := builder.AddInt64(int64(len(keys))).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	return script, keys, err
+}
+
+// outputScript returns the output script paying to witnessScript, and the redeem script of p2sh-p2wsh
+func (m *Multisig) outputScript(witnessScript []byte) ([]byte, []byte, error) {
+	hash := sha256.Sum256(witnessScript)
+	program, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(hash[:]).Script()
+	if err != nil || m.ScriptType == MultisigP2WSH {
+		return program, nil, err
+	}
+	script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(program)).AddOp(txscript.OP_EQUAL).Script()
+	return script, program, err
+}
+
+// scan maps the output scripts of the first DescriptorScanLimit addresses of both chains to their change/index
+func (m *Multisig) scan() (map[string][2]uint32, error) {
+	scripts := make(map[string][2]uint32, 2*DescriptorScanLimit)
+	for change := range uint32(2) {
+		chain, err := m.chainKeys(change)
+		if err != nil {
+			return nil, err
+		}
+		for index := range uint32(DescriptorScanLimit) {
+			witnessScript, _, err := m.scriptAt(chain, change, index)
+			if err != nil {
+				return nil, err
+			}
+			script, _, err := m.outputScript(witnessScript)
+			if err != nil {
+				return nil, err
+			}
+			scripts[string(script)] = [2]uint32{change, index}
+		}
+	}
+	return scripts, nil
+}
+
+// Outputs returns the indexes of the outputs of packet paying one of the first DescriptorScanLimit
+// addresses of both chains of m, its change
+func (m *Multisig) Outputs(packet *Packet) ([]int, error) {
+	scripts, err := m.scan()
+	if err != nil {
+		return nil, err
+	}
+	own := []int{}
+	for i, out := range packet.Tx.TxOut {
+		if _, ok := scripts[string(out.PkScript)]; ok {
+			own = append(own, i)
+		}
+	}
+	return own, nil
+}
+
+// ownKey returns the account key of the wallet of seed
+func (m *Multisig) ownKey(seed, fingerprint []byte) (*Descriptor, error) {
+	for _, key := range m.Keys {
+		if key.checkOwner(seed, fingerprint) == nil {
+			return key, nil
+		}
+	}
+	return nil, ErrNotCosigner
+}
+
+// addressOf returns the change/index of input i from its BIP-32 derivations below the account key own
+func (m *Multisig) addressOf(packet *Packet, i int, own *Descriptor) (uint32, uint32, bool) {
+	for _, path := range packet.bip32Derivations(i, own.Fingerprint) {
+		if len(path) != len(own.Origin)+2 || !slices.Equal(path[:len(own.Origin)], own.Origin) {
+			continue
+		}
+		change, index := path[len(path)-2], path[len(path)-1]
+		if change < 2 && index < hardenedKeyStart {
+			return change, index, true
+		}
+	}
+	return 0, 0, false
+}
+
+// SignMultisig adds the partial signature of the wallet of seed, one of the keys of m, to every
+// input of packet spending an address of m. The address of an input is found from its BIP-32
+// derivations of the wallet key, else among the first DescriptorScanLimit addresses of both chains.
+// The witness script, the redeem script and the derivations of all keys are added for the
+// cosigners and the finalizer. It returns the indexes of the signed inputs.
+func SignMultisig(seed []byte, packet *Packet, m *Multisig) ([]int, error) {
+	s := newSigner(seed, packet)
+	fingerprint, err := s.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	own, err := m.ownKey(seed, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts map[string][2]uint32
+	signed := []int{}
+	for i := range packet.Tx.TxIn {
+		if packet.finalized(i) {
+			continue
+		}
+		change, index, found := m.addressOf(packet, i, own)
+		utxo, err := packet.utxo(i)
+		if err != nil {
+			if found {
+				return nil, fmt.Errorf("input %d: %w", i, err)
+			}
+			continue
+		}
+		if !found {
+			if scripts == nil {
+				if scripts, err = m.scan(); err != nil {
+					return nil, err
+				}
+			}
+			position, ok := scripts[string(utxo.PkScript)]
+			if !ok {
+				continue
+			}
+			change, index = position[0], position[1]
+		}
+
+		ok, err := s.signMultisigInput(i, utxo, m, own, change, index)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		if ok {
+			signed = append(signed, i)
+		}
+	}
+	if len(signed) == 0 {
+		return nil, ErrNothingToSign
+	}
+	return signed, nil
+}
+
+// signMultisigInput adds the partial signature of the key own/change/index to input i, when the
+// utxo pays to the address change/index of m
+func (s *signer) signMultisigInput(i int, utxo *wire.TxOut, m *Multisig, own *Descriptor,
+	change, index uint32) (bool, error) {
+	witnessScript, keys, err := m.witnessScript(change, index)
+	if err != nil {
+		return false, err
+	}
+	script, redeemScript, err := m.outputScript(witnessScript)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(script, utxo.PkScript) {
+		return false, nil
+	}
+
+	hashType, err := s.packet.sighashType(i, txscript.SigHashAll)
+	if err != nil {
+		return false, err
+	}
+	path := append(append([]uint32{}, own.Origin...), change, index)
+	privateKey, err := lib.DerivePrivateKey(s.seed, formatPath(path), false)
+	if err != nil {
+		return false, err
+	}
+	sig, err := txscript.RawTxInWitnessSignature(s.packet.Tx, s.sigHashes, i, utxo.Value,
+		witnessScript, hashType, privateKey)
+	if err != nil {
+		return false, err
+	}
+	if err := checkSignature(sig); err != nil {
+		return false, err
+	}
+
+	input := &s.packet.inputs[i]
+	input.set(append([]byte{inputPartialSig}, privateKey.PubKey().SerializeCompressed()...), sig)
+	input.set([]byte{inputWitnessScript}, witnessScript)
+	if redeemScript != nil {
+		input.set([]byte{inputRedeemScript}, redeemScript)
+	}
+	for _, key := range keys {
+		input.set(append([]byte{inputBip32Derivation}, key.publicKey...), encodeKeyOrigin(key.fingerprint, key.path))
+	}
+	return true, nil
+}
+
+// keyExpression formats the key with its origin, [<fingerprint>/<origin>]<key>
+func (d *Descriptor) keyExpression() string {
+	origin := hex.EncodeToString(d.Fingerprint)
+	if len(d.Origin) > 0 {
+		origin += "/" + lib.FormatOriginPath(d.Origin)
+	}
+	if d.ExtendedKey == nil {
+		return "[" + origin + "]" + hex.EncodeToString(d.PublicKey)
+	}
+	return "[" + origin + "]" + d.ExtendedKey.String()
+}
@@ -0,0 +1,230 @@
+package bitcoin
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// cosignerSeed returns the seed of another wallet of the test mnemonic
+func cosignerSeed(t *testing.T, passphrase string) []byte {
+	t.Helper()
+	seed, err := lib.SeedFromMnemonic(testMnemonic, passphrase)
+	require.NoError(t, err)
+	return seed
+}
+
+// accountKey returns the key expression of the BIP-48 account 0 of seed
+func accountKey(t *testing.T, seed []byte, scriptType string) string {
+	t.Helper()
+	path, err := MultisigAccountPath(scriptType, 0, false)
+	require.NoError(t, err)
+	xpub, err := lib.ExtendedPublicKeyAt(seed, path, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	fingerprint, err := lib.MasterFingerprint(seed)
+	require.NoError(t, err)
+	return "[" + fingerprint + "/" + lib.FormatOriginPath(path) + "]" + xpub.String()
+}
+
+// newTestMultisig returns the 2-of-3 wallet of the test seed and the cosigner seeds b and c
+func newTestMultisig(t *testing.T, scriptType string) *Multisig {
+	t.Helper()
+	m, err := NewMultisig(2, scriptType, []string{
+		accountKey(t, testSeed(t), scriptType),
+		accountKey(t, cosignerSeed(t, "b"), scriptType),
+		accountKey(t, cosignerSeed(t, "c"), scriptType),
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestNewMultisig(t *testing.T) {
+	key := accountKey(t, testSeed(t), MultisigP2WSH)
+	cosigner := accountKey(t, cosignerSeed(t, "b"), MultisigP2WSH)
+
+	tests := []struct {
+		name       string
+		threshold  int
+		scriptType string
+		keys       []string
+		wantErr    error
+	}{
+		{"2 of 2", 2, MultisigP2WSH, []string{key, cosigner}, nil},
+		{"zero threshold", 0, MultisigP2WSH, []string{key, cosigner}, ErrMultisigThreshold},
+		{"threshold above keys", 3, MultisigP2WSH, []string{key, cosigner}, ErrMultisigThreshold},
+		{"no keys", 1, MultisigP2WSH, nil, ErrMultisigKeyCount},
+		{"unknown script type", 1, "p2tr", []string{key}, ErrUnsupportedScriptType},
+		{"ranged key", 1, MultisigP2WSH, []string{key + "/0/*"}, ErrMultisigKey},
+		{"key without origin", 1, MultisigP2WSH, []string{key[strings.Index(key, "]")+1:]}, ErrDescriptorOriginMissing},
+		{"duplicate key", 1, MultisigP2WSH, []string{key, key}, ErrDuplicateMultisigKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMultisig(tt.threshold, tt.scriptType, tt.keys)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMultisigDescriptor(t *testing.T) {
+	m := newTestMultisig(t, MultisigP2WSH)
+	descriptor, err := m.Descriptor(0)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(descriptor, "wsh(sortedmulti(2,[73c5da0a/48h/0h/0h/2h]xpub"), descriptor)
+	body, checksum, _ := strings.Cut(descriptor, "#")
+	expected, err := lib.DescriptorChecksum(body)
+	require.NoError(t, err)
+	assert.Equal(t, expected, checksum)
+	assert.Equal(t, 3, strings.Count(body, "/0/*"))
+
+	nested := newTestMultisig(t, MultisigP2SHP2WSH)
+	descriptor, err = nested.Descriptor(1)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(descriptor, "sh(wsh(sortedmulti(2,[73c5da0a/48h/0h/0h/1h]xpub"), descriptor)
+	assert.Equal(t, 3, strings.Count(descriptor, "/1/*"))
+}
+
+func TestMultisigAddress(t *testing.T) {
+	address, witnessScript, err := newTestMultisig(t, MultisigP2WSH).Address(0, 0, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(address, "bc1q"))
+	assert.Len(t, address, 62)
+
+	// 2 <key> <key> <key> 3 CHECKMULTISIG with sorted keys
+	pushes, err := txscript.PushedData(witnessScript)
+	require.NoError(t, err)
+	require.Len(t, pushes, 3)
+	assert.True(t, sort.SliceIsSorted(pushes, func(i, j int) bool { return bytes.Compare(pushes[i], pushes[j]) < 0 }))
+	assert.Equal(t, byte(txscript.OP_2), witnessScript[0])
+
+	address, _, err = newTestMultisig(t, MultisigP2SHP2WSH).Address(0, 0, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(address, "3"))
+
+	address, _, err = newTestMultisig(t, MultisigP2WSH).Address(0, 0, &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(address, "tb1q"))
+}
+
+// setMultisigUtxo makes input i of packet spend the address change/index of m
+func setMultisigUtxo(t *testing.T, packet *Packet, i int, m *Multisig, change, index uint32) {
+	t.Helper()
+	witnessScript, _, err := m.witnessScript(change, index)
+	require.NoError(t, err)
+	script, _, err := m.outputScript(witnessScript)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writeTxOut(&buf, wire.NewTxOut(70_000, script)))
+	packet.inputs[i] = psbtMap{{key: []byte{inputWitnessUtxo}, value: buf.Bytes()}}
+}
+
+// verifyMultisigInput finalizes input i from its partial signatures and runs it through the script engine
+func verifyMultisigInput(t *testing.T, packet *Packet, i int) {
+	t.Helper()
+
+	utxo, err := packet.utxo(i)
+	require.NoError(t, err)
+	witnessScript, ok := packet.inputs[i].get(inputWitnessScript)
+	require.True(t, ok)
+	publicKeys, err := txscript.PushedData(witnessScript)
+	require.NoError(t, err)
+
+	// the signatures follow the order of the keys of the script
+	witness := wire.TxWitness{nil}
+	for _, publicKey := range publicKeys {
+		if sig, ok := packet.inputs[i].get(append([]byte{inputPartialSig}, publicKey...)...); ok {
+			witness = append(witness, sig)
+		}
+	}
+	witness = append(witness, witnessScript)
+
+	tx := packet.Tx.Copy()
+	tx.TxIn[i].Witness = witness
+	if redeemScript, ok := packet.inputs[i].get(inputRedeemScript); ok {
+		tx.TxIn[i].SignatureScript, err = txscript.NewScriptBuilder().AddData(redeemScript).Script()
+		require.NoError(t, err)
+	}
+
+	engine, err := txscript.NewEngine(utxo.PkScript, tx, i, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx), utxo.Value)
+	require.NoError(t, err)
+	require.NoError(t, engine.Execute())
+}
+
+func TestSignMultisig(t *testing.T) {
+	for _, scriptType := range []string{MultisigP2WSH, MultisigP2SHP2WSH} {
+		t.Run(scriptType, func(t *testing.T) {
+			seed, cosigner := testSeed(t), cosignerSeed(t, "b")
+			m := newTestMultisig(t, scriptType)
+
+			// inputs 0 and 1 spend multisig addresses of both chains, input 2 the single key wallet
+			packet := newTestPSBT(t, seed, []testInput{
+				{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000},
+				{"m/84'/0'/0'/0/1", lib.AddressTypeP2WPKH, 50_000},
+				{"m/84'/0'/0'/0/2", lib.AddressTypeP2WPKH, 50_000},
+			})
+			setMultisigUtxo(t, packet, 0, m, 0, 3)
+			setMultisigUtxo(t, packet, 1, m, 1, 7)
+
+			signed, err := SignMultisig(cosigner, packet, m)
+			require.NoError(t, err)
+			assert.Equal(t, []int{0, 1}, signed)
+			_, keys, err := m.witnessScript(1, 7)
+			require.NoError(t, err)
+			for _, key := range keys {
+				_, ok := packet.inputs[1].get(append([]byte{inputBip32Derivation}, key.publicKey...)...)
+				assert.True(t, ok, "derivation of every key")
+			}
+
+			// the next signer finds the addresses from the derivations added by the first one
+			packet, err = DecodePSBT(mustBase64(t, packet))
+			require.NoError(t, err)
+			signed, err = SignMultisig(seed, packet, m)
+			require.NoError(t, err)
+			assert.Equal(t, []int{0, 1}, signed)
+			assert.Empty(t, packet.inputs[2].ofType(inputPartialSig))
+
+			verifyMultisigInput(t, packet, 0)
+			verifyMultisigInput(t, packet, 1)
+		})
+	}
+
+	t.Run("not a cosigner", func(t *testing.T) {
+		packet := newTestPSBT(t, testSeed(t), []testInput{{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000}})
+		_, err := SignMultisig(cosignerSeed(t, "d"), packet, newTestMultisig(t, MultisigP2WSH))
+		require.ErrorIs(t, err, ErrNotCosigner)
+	})
+
+	t.Run("nothing to sign", func(t *testing.T) {
+		packet := newTestPSBT(t, testSeed(t), []testInput{{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000}})
+		_, err := SignMultisig(testSeed(t), packet, newTestMultisig(t, MultisigP2WSH))
+		require.ErrorIs(t, err, ErrNothingToSign)
+	})
+}
+
+func TestMultisigOutputs(t *testing.T) {
+	m := newTestMultisig(t, MultisigP2WSH)
+	witnessScript, _, err := m.witnessScript(1, 4)
+	require.NoError(t, err)
+	change, _, err := m.outputScript(witnessScript)
+	require.NoError(t, err)
+
+	packet := newTestPSBT(t, testSeed(t), []testInput{{"m/84'/0'/0'/0/0", lib.AddressTypeP2WPKH, 50_000}})
+	packet.Tx.AddTxOut(wire.NewTxOut(20_000, change))
+	own, err := m.Outputs(packet)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, own)
+}
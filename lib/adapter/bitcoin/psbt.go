@@ -0,0 +1,327 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP-174 key types used by the signer; other entries are kept as they are
+const (
+	globalUnsignedTx = 0x00
+	globalVersion    = 0xfb
+
+	inputNonWitnessUtxo     = 0x00
+	inputWitnessUtxo        = 0x01
+	inputPartialSig         = 0x02
+	inputSighashType        = 0x03
+	inputRedeemScript       = 0x04
+	inputWitnessScript      = 0x05
+	inputBip32Derivation    = 0x06
+	inputFinalScriptSig     = 0x07
+	inputFinalScriptWitness = 0x08
+	inputTapKeySig          = 0x13
+	inputTapBip32Derivation = 0x16
+
+	outputBip32Derivation    = 0x02
+	outputTapBip32Derivation = 0x07
+)
+
+const (
+	// maxPSBTSize bounds keys, values and the number of entries read from a PSBT
+	maxPSBTSize = 4_000_000
+	// fingerprintLength is the length of the master key fingerprint of the BIP-32 derivation entries
+	fingerprintLength = 4
+)
+
+// psbtMagic starts every serialized PSBT
+func psbtMagic() []byte {
+	return []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+}
+
+// keyValue is one entry of a PSBT map; the first byte of key is its type
+type keyValue struct {
+	key   []byte
+	value []byte
+}
+
+// psbtMap is a PSBT map in serialization order, so unknown entries round-trip untouched
+type psbtMap []keyValue
+
+// get returns the value of the entry whose key is exactly key
+func (m psbtMap) get(key ...byte) ([]byte, bool) {
+	for _, kv := range m {
+		if bytes.Equal(kv.key, key) {
+			return kv.value, true
+		}
+	}
+	return nil, false
+}
+
+// ofType returns the entries of keyType
+func (m psbtMap) ofType(keyType byte) []keyValue {
+	var entries []keyValue
+	for _, kv := range m {
+		if kv.key[0] == keyType {
+			entries = append(entries, kv)
+		}
+	}
+	return entries
+}
+
+// set replaces the value of key, or appends the entry
+func (m *psbtMap) set(key, value []byte) {
+	for i, kv := range *m {
+		if bytes.Equal(kv.key, key) {
+			(*m)[i].value = value
+			return
+		}
+	}
+	*m = append(*m, keyValue{key: key, value: value})
+}
+
+// Packet is a version 0 PSBT (BIP-174)
+type Packet struct {
+	Tx      *wire.MsgTx
+	global  psbtMap
+	inputs  []psbtMap
+	outputs []psbtMap
+}
+
+// DecodePSBT decodes a base64 or hex encoded PSBT
+func DecodePSBT(encoded string) (*Packet, error) {
+	encoded = strings.TrimSpace(encoded)
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if raw, err = hex.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("%w: neither base64 nor hex", ErrInvalidPSBT)
+		}
+	}
+	return ParsePSBT(raw)
+}
+
+// ParsePSBT parses a serialized PSBT
+func ParsePSBT(raw []byte) (*Packet, error) {
+	if !bytes.HasPrefix(raw, psbtMagic()) {
+		return nil, fmt.Errorf("%w: missing magic bytes", ErrInvalidPSBT)
+	}
+	r := bytes.NewReader(raw[len(psbtMagic()):])
+
+	global, err := readMap(r)
+	if err != nil {
+		return nil, err
+	}
+	if version, ok := global.get(globalVersion); ok && (len(version) != 4 || binary.LittleEndian.Uint32(version) != 0) {
+		return nil, ErrUnsupportedPSBTVersion
+	}
+	unsignedTx, ok := global.get(globalUnsignedTx)
+	if !ok {
+		return nil, ErrMissingUnsignedTx
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.DeserializeNoWitness(bytes.NewReader(unsignedTx)); err != nil {
+		return nil, fmt.Errorf("%w: unsigned transaction: %w", ErrInvalidPSBT, err)
+	}
+	for _, in := range tx.TxIn {
+		if len(in.SignatureScript) > 0 || len(in.Witness) > 0 {
+			return nil, ErrSignedUnsignedTx
+		}
+	}
+
+	packet := &Packet{Tx: tx, global: global}
+	for range tx.TxIn {
+		m, err := readMap(r)
+		if err != nil {
+			return nil, err
+		}
+		packet.inputs = append(packet.inputs, m)
+	}
+	for range tx.TxOut {
+		m, err := readMap(r)
+		if err != nil {
+			return nil, err
+		}
+		packet.outputs = append(packet.outputs, m)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidPSBT)
+	}
+	return packet, nil
+}
+
+// readMap reads the entries of a map up to its 0x00 separator
+func readMap(r *bytes.Reader) (psbtMap, error) {
+	var m psbtMap
+	for {
+		key, err := wire.ReadVarBytes(r, 0, maxPSBTSize, "key")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPSBT, err)
+		}
+		if len(key) == 0 {
+			return m, nil
+		}
+		value, err := wire.ReadVarBytes(r, 0, maxPSBTSize, "value")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPSBT, err)
+		}
+		if _, ok := m.get(key...); ok {
+			return nil, fmt.Errorf("%w: %x", ErrDuplicatePSBTKey, key)
+		}
+		m = append(m, keyValue{key: key, value: value})
+	}
+}
+
+// Serialize returns the binary PSBT
+func (p *Packet) Serialize() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(psbtMagic())
+	maps := append(append([]psbtMap{p.global}, p.inputs...), p.outputs...)
+	for _, m := range maps {
+		for _, kv := range m {
+			if err := wire.WriteVarBytes(&buf, 0, kv.key); err != nil {
+				return nil, err
+			}
+			if err := wire.WriteVarBytes(&buf, 0, kv.value); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte(0x00)
+	}
+	return buf.Bytes(), nil
+}
+
+// Base64 returns the base64 encoded PSBT
+func (p *Packet) Base64() (string, error) {
+	raw, err := p.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// finalized reports whether input i already has its final scriptSig or witness
+func (p *Packet) finalized(i int) bool {
+	_, scriptSig := p.inputs[i].get(inputFinalScriptSig)
+	_, witness := p.inputs[i].get(inputFinalScriptWitness)
+	return scriptSig || witness
+}
+
+// utxo returns the output spent by input i, checking the non-witness utxo against the outpoint
+func (p *Packet) utxo(i int) (*wire.TxOut, error) {
+	outpoint := p.Tx.TxIn[i].PreviousOutPoint
+	if raw, ok := p.inputs[i].get(inputNonWitnessUtxo); ok {
+		prev := wire.NewMsgTx(wire.TxVersion)
+		if err := prev.Deserialize(bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("%w: input %d non-witness utxo: %w", ErrInvalidPSBT, i, err)
+		}
+		if prev.TxHash() != outpoint.Hash || int(outpoint.Index) >= len(prev.TxOut) {
+			return nil, fmt.Errorf("%w: input %d", ErrUtxoMismatch, i)
+		}
+		return prev.TxOut[outpoint.Index], nil
+	}
+	if raw, ok := p.inputs[i].get(inputWitnessUtxo); ok {
+		return parseTxOut(raw)
+	}
+	return nil, fmt.Errorf("%w: input %d", ErrMissingUtxo, i)
+}
+
+// prevouts returns the outputs spent by every input, as needed by the taproot sighash
+func (p *Packet) prevouts() ([]*wire.TxOut, error) {
+	prevouts := make([]*wire.TxOut, 0, len(p.inputs))
+	for i := range p.inputs {
+		utxo, err := p.utxo(i)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMissingPrevouts, err)
+		}
+		prevouts = append(prevouts, utxo)
+	}
+	return prevouts, nil
+}
+
+// sighashType returns the sighash type requested for input i, or fallback when none is set
+func (p *Packet) sighashType(i int, fallback txscript.SigHashType) (txscript.SigHashType, error) {
+	value, ok := p.inputs[i].get(inputSighashType)
+	if !ok {
+		return fallback, nil
+	}
+	if len(value) != 4 {
+		return 0, fmt.Errorf("%w: input %d sighash type", ErrInvalidPSBT, i)
+	}
+	return txscript.SigHashType(binary.LittleEndian.Uint32(value)), nil
+}
+
+// bip32Derivations returns the derivation paths of input i whose key origin is fingerprint,
+// from both the ECDSA and the taproot entries
+func (p *Packet) bip32Derivations(i int, fingerprint []byte) [][]uint32 {
+	return keyOrigins(p.inputs[i], inputBip32Derivation, inputTapBip32Derivation, fingerprint)
+}
+
+// outputBip32Derivations returns the derivation paths of output i whose key origin is fingerprint
+func (p *Packet) outputBip32Derivations(i int, fingerprint []byte) [][]uint32 {
+	return keyOrigins(p.outputs[i], outputBip32Derivation, outputTapBip32Derivation, fingerprint)
+}
+
+// keyOrigins returns the paths of the ECDSA and taproot derivation entries of m whose key origin
+// is fingerprint
+func keyOrigins(m psbtMap, keyType, tapKeyType byte, fingerprint []byte) [][]uint32 {
+	var paths [][]uint32
+	for _, kv := range m.ofType(keyType) {
+		if path, ok := parseKeyOrigin(kv.value, fingerprint); ok {
+			paths = append(paths, path)
+		}
+	}
+	for _, kv := range m.ofType(tapKeyType) {
+		// the origin follows the leaf hashes of the key
+		r := bytes.NewReader(kv.value)
+		hashes, err := wire.ReadVarInt(r, 0)
+		if err != nil || hashes > uint64(r.Len())/32 {
+			continue
+		}
+		if path, ok := parseKeyOrigin(kv.value[len(kv.value)-r.Len()+int(hashes)*32:], fingerprint); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// parseKeyOrigin decodes a <fingerprint><path> key origin, if it belongs to fingerprint
+func parseKeyOrigin(value, fingerprint []byte) ([]uint32, bool) {
+	if len(value) < fingerprintLength || (len(value)-fingerprintLength)%4 != 0 ||
+		!bytes.Equal(value[:fingerprintLength], fingerprint) {
+		return nil, false
+	}
+	path := make([]uint32, 0, (len(value)-fingerprintLength)/4)
+	for j := fingerprintLength; j < len(value); j += 4 {
+		path = append(path, binary.LittleEndian.Uint32(value[j:]))
+	}
+	return path, true
+}
+
+// parseTxOut decodes a serialized transaction output
+func parseTxOut(raw []byte) (*wire.TxOut, error) {
+	r := bytes.NewReader(raw)
+	var value int64
+	if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+		return nil, fmt.Errorf("%w: witness utxo: %w", ErrInvalidPSBT, err)
+	}
+	script, err := wire.ReadVarBytes(r, 0, maxPSBTSize, "pkScript")
+	if err != nil || r.Len() != 0 {
+		return nil, fmt.Errorf("%w: witness utxo script", ErrInvalidPSBT)
+	}
+	return wire.NewTxOut(value, script), nil
+}
+
+// writeTxOut serializes a transaction output as in transactions and witness utxos
+func writeTxOut(w io.Writer, out *wire.TxOut) error {
+	if err := binary.Write(w, binary.LittleEndian, out.Value); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, out.PkScript)
+}
@@ -0,0 +1,70 @@
+package bitcoin
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// schnorrSignatureLength is the length of BIP-340 signatures
+const schnorrSignatureLength = 64
+
+// ErrSchnorrNonce is returned in the negligible case of a zero nonce
+var ErrSchnorrNonce = errors.New("schnorr nonce is zero")
+
+// xOnly returns the 32 byte x-only encoding of the public key (BIP-340)
+func xOnly(publicKey *btcec.PublicKey) []byte {
+	return publicKey.SerializeCompressed()[1:]
+}
+
+// signSchnorr signs the 32 byte hash with BIP-340, using random auxiliary data.
+// The btcec schnorr package needs a newer chainhash than the one of the btcd release in use.
+func signSchnorr(privateKey *btcec.PrivateKey, hash []byte) ([]byte, error) {
+	var aux [32]byte
+	if _, err := rand.Read(aux[:]); err != nil {
+		return nil, err
+	}
+	return signSchnorrWithAux(privateKey, hash, aux)
+}
+
+// signSchnorrWithAux is the BIP-340 signing algorithm with the auxiliary random data aux
+func signSchnorrWithAux(privateKey *btcec.PrivateKey, hash []byte, aux [32]byte) ([]byte, error) {
+	// d is negated when its public key has an odd y, so that P is the even key of its x
+	d := privateKey.Key
+	publicKey := privateKey.PubKey()
+	if publicKey.SerializeCompressed()[0] == 0x03 {
+		d.Negate()
+	}
+	dBytes := d.Bytes()
+
+	t := taggedHash("BIP0340/aux", aux[:])
+	for i := range t {
+		t[i] ^= dBytes[i]
+	}
+
+	var k btcec.ModNScalar
+	k.SetByteSlice(taggedHash("BIP0340/nonce", t, xOnly(publicKey), hash))
+	if k.IsZero() {
+		return nil, ErrSchnorrNonce
+	}
+
+	var r btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(&k, &r)
+	r.ToAffine()
+	if r.Y.IsOdd() {
+		k.Negate()
+	}
+	rBytes := r.X.Bytes()
+
+	var e btcec.ModNScalar
+	e.SetByteSlice(taggedHash("BIP0340/challenge", rBytes[:], xOnly(publicKey), hash))
+
+	// s = k + e·d
+	s := e.Mul(&d).Add(&k)
+	sBytes := s.Bytes()
+
+	signature := make([]byte, 0, schnorrSignatureLength)
+	signature = append(signature, rBytes[:]...)
+	return append(signature, sBytes[:]...), nil
+}
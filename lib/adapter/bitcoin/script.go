@@ -0,0 +1,252 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+	"github.com/payment-system/dq-vault/lib"
+)
+
+const (
+	// bech32mConstant is the checksum constant of segwit v1+ addresses (BIP-350)
+	bech32mConstant = 0x2bc830a3
+	bech32Charset   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32Checksum  = 6
+	// taprootVersion is the witness version of taproot outputs
+	taprootVersion = 1
+)
+
+// addressTypeOf returns the address type of the derivation path, chosen from its purpose
+func addressTypeOf(derivationPath string) (string, error) {
+	path, err := lib.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return "", err
+	}
+	purpose := path[0]
+	if purpose >= hardenedKeyStart {
+		purpose -= hardenedKeyStart
+	}
+	return lib.BitcoinAddressType(purpose), nil
+}
+
+// outputScript returns the script paying to the compressed public key for addressType
+func outputScript(addressType string, publicKey []byte) ([]byte, error) {
+	switch addressType {
+	case lib.AddressTypeP2PKH:
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(publicKey)).AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	case lib.AddressTypeP2WPKH:
+		return witnessProgram(publicKey)
+	case lib.AddressTypeP2SHP2WPKH:
+		redeemScript, err := witnessProgram(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(redeemScript)).AddOp(txscript.OP_EQUAL).Script()
+	case lib.AddressTypeP2TR:
+		outputKey, err := taprootOutputKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_1).AddData(outputKey).Script()
+	default:
+		return nil, fmt.Errorf("%w: %s", lib.ErrUnknownAddressType, addressType)
+	}
+}
+
+// witnessProgram returns the P2WPKH script of the public key, also the redeem script of P2SH-P2WPKH
+func witnessProgram(publicKey []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(btcutil.Hash160(publicKey)).Script()
+}
+
+// encodeAddress returns the address of the compressed public key for addressType on net
+func encodeAddress(addressType string, publicKey []byte, net *chaincfg.Params) (string, error) {
+	switch addressType {
+	case lib.AddressTypeP2PKH:
+		address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(publicKey), net)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	case lib.AddressTypeP2WPKH:
+		address, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(publicKey), net)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	case lib.AddressTypeP2SHP2WPKH:
+		redeemScript, err := witnessProgram(publicKey)
+		if err != nil {
+			return "", err
+		}
+		address, err := btcutil.NewAddressScriptHash(redeemScript, net)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	case lib.AddressTypeP2TR:
+		outputKey, err := taprootOutputKey(publicKey)
+		if err != nil {
+			return "", err
+		}
+		return encodeBech32m(net.Bech32HRPSegwit, taprootVersion, outputKey)
+	default:
+		return "", fmt.Errorf("%w: %s", lib.ErrUnknownAddressType, addressType)
+	}
+}
+
+// taprootTweak returns the BIP-86 tweak of the internal key, committing to no script path
+func taprootTweak(internalKey *btcec.PublicKey) (*btcec.ModNScalar, error) {
+	var tweak btcec.ModNScalar
+	if overflow := tweak.SetByteSlice(taggedHash("TapTweak", xOnly(internalKey))); overflow {
+		return nil, ErrInvalidTaprootTweak
+	}
+	return &tweak, nil
+}
+
+// taprootOutputKey returns the x-only output key of the BIP-86 key path spend for the compressed public key
+func taprootOutputKey(publicKey []byte) ([]byte, error) {
+	internalKey, err := btcec.ParsePubKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	tweak, err := taprootTweak(internalKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the even y lift of the internal key, plus tweak·G
+	even, err := btcec.ParsePubKey(append([]byte{0x02}, xOnly(internalKey)...))
+	if err != nil {
+		return nil, err
+	}
+	var point, tweakPoint, outputPoint btcec.JacobianPoint
+	even.AsJacobian(&point)
+	btcec.ScalarBaseMultNonConst(tweak, &tweakPoint)
+	btcec.AddNonConst(&point, &tweakPoint, &outputPoint)
+	outputPoint.ToAffine()
+	return xOnly(btcec.NewPublicKey(&outputPoint.X, &outputPoint.Y)), nil
+}
+
+// taprootPrivateKey tweaks the private key so it signs for the BIP-86 output key
+func taprootPrivateKey(privateKey *btcec.PrivateKey) (*btcec.PrivateKey, error) {
+	tweak, err := taprootTweak(privateKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	key := privateKey.Key
+	if privateKey.PubKey().SerializeCompressed()[0] == 0x03 {
+		key.Negate()
+	}
+	key.Add(tweak)
+	return btcec.PrivKeyFromScalar(&key), nil
+}
+
+// taggedHash is the BIP-340 tagged hash of msg
+func taggedHash(tag string, msg ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+	return h.Sum(nil)
+}
+
+// encodeBech32m encodes a segwit v1+ address (BIP-350), which btcutil predates
+func encodeBech32m(hrp string, version byte, program []byte) (string, error) {
+	converted, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data := append([]byte{version}, converted...)
+
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, make([]byte, bech32Checksum)...)) ^ bech32mConstant
+
+	var sb strings.Builder
+	sb.WriteString(hrp + "1")
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	for i := range bech32Checksum {
+		sb.WriteByte(bech32Charset[(polymod>>(5*(bech32Checksum-1-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// bech32HRPExpand expands the human readable part for the checksum computation
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := range len(hrp) {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := range len(hrp) {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+//nolint:mnd // generator constants of the BIP-173 checksum
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+// addressScript returns the output script paying to address on net. Taproot addresses are decoded
+// here, btcutil predating bech32m.
+func addressScript(address string, net *chaincfg.Params) ([]byte, error) {
+	if program, ok := decodeTaprootAddress(address, net); ok {
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_1).AddData(program).Script()
+	}
+	decoded, err := btcutil.DecodeAddress(address, net)
+	if err != nil || !decoded.IsForNet(net) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+	}
+	return txscript.PayToAddrScript(decoded)
+}
+
+// decodeTaprootAddress returns the 32 byte witness program of a segwit v1 address of net (BIP-350)
+func decodeTaprootAddress(address string, net *chaincfg.Params) ([]byte, bool) {
+	address = strings.ToLower(address)
+	separator := strings.LastIndexByte(address, '1')
+	if separator < 1 || address[:separator] != net.Bech32HRPSegwit || len(address)-separator-1 <= bech32Checksum {
+		return nil, false
+	}
+	data := make([]byte, 0, len(address)-separator-1)
+	for _, c := range address[separator+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return nil, false
+		}
+		data = append(data, byte(i))
+	}
+	if bech32Polymod(append(bech32HRPExpand(address[:separator]), data...)) != bech32mConstant ||
+		data[0] != taprootVersion {
+		return nil, false
+	}
+	program, err := bech32.ConvertBits(data[1:len(data)-bech32Checksum], 5, 8, false)
+	if err != nil || len(program) != 32 {
+		return nil, false
+	}
+	return program, true
+}
@@ -0,0 +1,53 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// sigHashDefault is the taproot sighash type committing to the whole transaction, BIP-341
+const sigHashDefault txscript.SigHashType = 0x00
+
+// taprootSigHash returns the BIP-341 signature hash of a key path spend of input idx.
+// Only SIGHASH_DEFAULT and SIGHASH_ALL are supported, which commit to every input and output.
+func taprootSigHash(tx *wire.MsgTx, idx int, prevouts []*wire.TxOut,
+	hashType txscript.SigHashType) ([]byte, error) {
+	if hashType != sigHashDefault && hashType != txscript.SigHashAll {
+		return nil, fmt.Errorf("%w: %#x for taproot", ErrUnsupportedSighash, uint32(hashType))
+	}
+
+	var outpoints, amounts, scripts, sequences, outputs bytes.Buffer
+	for i, in := range tx.TxIn {
+		outpoints.Write(in.PreviousOutPoint.Hash[:])
+		_ = binary.Write(&outpoints, binary.LittleEndian, in.PreviousOutPoint.Index)
+		_ = binary.Write(&amounts, binary.LittleEndian, prevouts[i].Value)
+		if err := wire.WriteVarBytes(&scripts, 0, prevouts[i].PkScript); err != nil {
+			return nil, err
+		}
+		_ = binary.Write(&sequences, binary.LittleEndian, in.Sequence)
+	}
+	for _, out := range tx.TxOut {
+		if err := writeTxOut(&outputs, out); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg bytes.Buffer
+	msg.WriteByte(0x00) // sighash epoch
+	msg.WriteByte(byte(hashType))
+	_ = binary.Write(&msg, binary.LittleEndian, tx.Version)
+	_ = binary.Write(&msg, binary.LittleEndian, tx.LockTime)
+	for _, b := range [][]byte{outpoints.Bytes(), amounts.Bytes(), scripts.Bytes(), sequences.Bytes(), outputs.Bytes()} {
+		digest := sha256.Sum256(b)
+		msg.Write(digest[:])
+	}
+	msg.WriteByte(0x00) // key path spend without annex
+	_ = binary.Write(&msg, binary.LittleEndian, uint32(idx))
+
+	return taggedHash("TapSighash", msg.Bytes()), nil
+}
@@ -1,7 +1,14 @@
 package adapter
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/payment-system/dq-vault/lib"
+)
 
 var (
-	ErrNoAdapterFound = errors.New("no adapter found")
+	ErrNoAdapterFound     = fmt.Errorf("%w: no adapter found", lib.ErrUnsupportedCoin)
+	ErrNoDescriptor       = errors.New("coin has no output descriptors")
+	ErrNoPublicKeyAddress = errors.New("coin addresses cannot be derived from an extended public key")
 )
@@ -3,6 +3,17 @@ package evm
 import "errors"
 
 var (
-	ErrInvalidECDSAPublicKey = errors.New("invalid ECDSA public key")
-	ErrInvalidPayloadData    = errors.New("invalid payload data")
+	ErrInvalidECDSAPublicKey  = errors.New("invalid ECDSA public key")
+	ErrInvalidPayloadData     = errors.New("invalid payload data")
+	ErrInvalidSafeVersion     = errors.New("invalid Safe version: expected major.minor.patch")
+	ErrInvalidSafeOperation   = errors.New("invalid Safe operation: expected 0 (call) or 1 (delegatecall)")
+	ErrInvalidSafeChainID     = errors.New("invalid Safe chainId")
+	ErrInvalidSafeAmount      = errors.New("amounts of the Safe transaction must be uint256 values")
+	ErrUnsupportedEntryPoint  = errors.New("unsupported EntryPoint version: expected 0.6 or 0.7")
+	ErrInvalidUserOpChainID   = errors.New("invalid user operation chainId")
+	ErrInvalidUserOpAmount    = errors.New("invalid user operation amount: gas limits and fees of v0.7 are uint128 values")
+	ErrUnsupportedPermitKind  = errors.New("unsupported permit type: expected eip2612, permit2 or permit2-transfer")
+	ErrInvalidPermitChainID   = errors.New("invalid permit chainId")
+	ErrInvalidPermitAmount    = errors.New("invalid permit amount: Permit2 allowances are uint160, nonces and expirations uint48")
+	ErrMissingPermitTokenName = errors.New("missing token name: EIP-2612 permits are signed for the EIP-712 domain of the token")
 )
@@ -49,6 +49,21 @@ func (e *EthereumAdapter) CanDo(coinType uint16) bool {
 	return slices.Contains(e.availableCoinTypes, coinType)
 }
 
+// Capabilities describes the coins and formats handled by the EVM adapter
+func (e *EthereumAdapter) Capabilities() lib.Capabilities {
+	// typed data operations are signed with the same keys as transactions
+	operations := append(lib.DefaultOperations(), lib.OperationSignSafeTx, lib.OperationSignUserOp,
+		lib.OperationSignPermit, lib.OperationBroadcast)
+	return lib.Capabilities{
+		Adapter:        "evm",
+		CoinTypes:      slices.Clone(e.availableCoinTypes),
+		Curve:          lib.CurveSecp256k1,
+		AddressFormats: []string{"EIP-55 checksummed hex (0x...)"},
+		Payload:        lib.EthereumRawTx{},
+		Operations:     operations,
+	}
+}
+
 func (e *EthereumAdapter) DerivePrivateKey(seed []byte, derivationPath string, isDev bool) (string, error) {
 	logger := e.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
 	logger.Info("Deriving private key")
@@ -122,6 +137,15 @@ func (e *EthereumAdapter) DeriveAddress(seed []byte, derivationPath string, isDe
 	return address, nil
 }
 
+// AddressFromPublicKey returns the address of the compressed publicKey, for watch-only users
+func (e *EthereumAdapter) AddressFromPublicKey(publicKey []byte, _ string, _ bool) (string, error) {
+	publicKeyECDSA, err := crypto.DecompressPubkey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return crypto.PubkeyToAddress(*publicKeyECDSA).Hex(), nil
+}
+
 func validatePayload(payload lib.EthereumRawTx, zeroAddress string) (isValid bool, txType string) {
 	// Value, chainId, GasPrice should not be negative
 	if payload.ChainID.Cmp(big.NewInt(0)) == -1 ||
@@ -150,6 +174,28 @@ func validatePayload(payload lib.EthereumRawTx, zeroAddress string) (isValid boo
 	return false, ""
 }
 
+// ValidatePayload reports the field errors of an Ethereum sign payload
+func (e *EthereumAdapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	v.Uint("nonce", 64, true)
+	v.BigInt("value", true)
+	v.Uint("gasLimit", 64, true)
+	v.BigInt("gasPrice", true)
+	v.BigInt("chainId", true)
+	_, hasData := v.Hex("data", false)
+
+	to, hasTo := v.String("to", false)
+	switch {
+	case hasTo && (!strings.HasPrefix(to, "0x") || !common.IsHexAddress(to) || len(to) != len(e.zeroAddress)):
+		v.Fail("to", "not a 0x prefixed hex address")
+	case hasTo && to == e.zeroAddress:
+		v.Fail("to", "zero address")
+	case !hasTo && !hasData && v.IsObject():
+		v.Fail("to", "missing (required unless data deploys a contract)")
+	}
+	return v.Err()
+}
+
 func (e *EthereumAdapter) createRawTransaction(payloadString string) (*types.Transaction, *big.Int, error) {
 	logger := e.logger.With(slog.String("op", "create_raw_transaction"))
 	logger.Info("Creating raw transaction")
@@ -204,6 +250,11 @@ func (e *EthereumAdapter) CreateSignedTransaction(seed []byte, derivationPath, p
 	if err != nil {
 		return "", err
 	}
+	_, r, s := signedTx.RawSignatureValues()
+	if err := lib.CheckSignatureValues(r, s); err != nil {
+		logger.Error("Non canonical signature", "error", err)
+		return "", err
+	}
 	// obtains signed transaction hex
 	var signedTxBuff bytes.Buffer
 	_ = signedTx.EncodeRLP(&signedTxBuff)
@@ -2,6 +2,7 @@ package evm
 
 import (
 	"encoding/hex"
+	"io"
 	"log/slog"
 	"math/big"
 	"os"
@@ -587,10 +588,73 @@ func TestValidatePayload(t *testing.T) {
 	}
 }
 
+func TestEthereumAdapter_ValidatePayload(t *testing.T) {
+	adapter := NewEthereumAdapter(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	tests := []struct {
+		name       string
+		payload    string
+		wantFields []lib.FieldError
+	}{
+		{
+			name: "valid ether transfer",
+			payload: `{"nonce":42,"value":1000000000000000000,"gasLimit":21000,"gasPrice":20000000000,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","chainId":1}`,
+		},
+		{
+			name:    "contract creation without to",
+			payload: `{"nonce":0,"value":0,"gasLimit":500000,"gasPrice":1,"data":"0x6080","chainId":1}`,
+		},
+		{
+			name:       "not json",
+			payload:    `{invalid`,
+			wantFields: []lib.FieldError{{Field: "payload", Message: "not a JSON object"}},
+		},
+		{
+			name: "missing nonce and value not a number",
+			payload: `{"value":"0x10","gasLimit":21000,"gasPrice":1,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","chainId":1}`,
+			wantFields: []lib.FieldError{
+				{Field: "nonce", Message: "missing"},
+				{Field: "value", Message: "not an integer"},
+			},
+		},
+		{
+			name:    "data not hex and zero address",
+			payload: `{"nonce":1,"value":0,"gasLimit":21000,"gasPrice":1,"to":"` + adapter.zeroAddress + `","data":"zz","chainId":1}`,
+			wantFields: []lib.FieldError{
+				{Field: "data", Message: "not hex"},
+				{Field: "to", Message: "zero address"},
+			},
+		},
+		{
+			name:    "negative gas price",
+			payload: `{"nonce":1,"value":0,"gasLimit":21000,"gasPrice":-1,"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","chainId":1}`,
+			wantFields: []lib.FieldError{
+				{Field: "gasPrice", Message: "negative"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := adapter.ValidatePayload(tt.payload)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, lib.ErrInvalidPayload)
+			var fieldErrs lib.PayloadErrors
+			require.ErrorAs(t, err, &fieldErrs)
+			assert.Equal(t, tt.wantFields, []lib.FieldError(fieldErrs))
+		})
+	}
+}
+
 // Benchmark tests
 func BenchmarkEthereumAdapter_DerivePrivateKey(b *testing.B) {
 	testSeed, _ := hex.DecodeString(testSeedHex)
-	adapter := NewEthereumAdapter(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	adapter := NewEthereumAdapter(slog.New(slog.NewTextHandler(io.Discard, nil)))
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -600,7 +664,7 @@ func BenchmarkEthereumAdapter_DerivePrivateKey(b *testing.B) {
 
 func BenchmarkEthereumAdapter_DeriveAddress(b *testing.B) {
 	testSeed, _ := hex.DecodeString(testSeedHex)
-	adapter := NewEthereumAdapter(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	adapter := NewEthereumAdapter(slog.New(slog.NewTextHandler(io.Discard, nil)))
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,60 @@
+package evm
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/secp"
+)
+
+// signatureVOffset turns the recovery id into the v of the signatures checked with ecrecover
+const signatureVOffset = 27
+
+// signHash signs the 32 byte hash with the key of the derivation path, returning the 65 byte
+// r || s || v signature, v being 27 or 28, and the address of the key
+func signHash(seed []byte, derivationPath string, hash []byte) ([]byte, common.Address, error) {
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	signature, err := signDigest(privateKey, hash)
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	return signature, crypto.PubkeyToAddress(privateKey.PublicKey), nil
+}
+
+// derivePrivateKey returns the secp256k1 key of the derivation path
+func derivePrivateKey(seed []byte, derivationPath string) (*ecdsa.PrivateKey, error) {
+	btcecPrivateKey, err := lib.DerivePrivateKey(seed, derivationPath, false)
+	if err != nil {
+		return nil, err
+	}
+	return btcecPrivateKey.ToECDSA(), nil
+}
+
+// signDigest signs the 32 byte hash with privateKey, returning the 65 byte r || s || v signature
+func signDigest(privateKey *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	signature, err := secp.Sign(hash, crypto.FromECDSA(privateKey))
+	if err != nil {
+		return nil, err
+	}
+	if err := lib.CheckCompactSignature(signature); err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += signatureVOffset
+	return signature, nil
+}
+
+// word returns v as a 32 byte ABI word
+func word(v *big.Int) []byte {
+	return common.LeftPadBytes(v.Bytes(), common.HashLength)
+}
+
+// addressWord returns the address as a 32 byte ABI word
+func addressWord(address common.Address) []byte {
+	return common.LeftPadBytes(address.Bytes(), common.HashLength)
+}
@@ -0,0 +1,169 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Kinds of permits
+const (
+	// PermitEIP2612 is the permit function of EIP-2612 tokens
+	PermitEIP2612 = "eip2612"
+	// PermitPermit2 is a PermitSingle allowance of the Permit2 AllowanceTransfer
+	PermitPermit2 = "permit2"
+	// PermitPermit2Transfer is a one time PermitTransferFrom of the Permit2 SignatureTransfer
+	PermitPermit2Transfer = "permit2-transfer"
+)
+
+// Permit2Address is the address Permit2 is deployed at on every chain
+const Permit2Address = "0x000000000022D473030F116dDEE9F6B43aC78BA3"
+
+// Bit sizes of the Permit2 allowance fields
+const (
+	permit2AmountBits = 160
+	permit2UintBits   = 48
+)
+
+// Permit is an EIP-712 token approval signed by the owner for the spender
+type Permit struct {
+	Kind    string
+	ChainID *big.Int
+	Token   common.Address
+	Spender common.Address
+	Amount  *big.Int
+	Nonce   *big.Int
+	// Deadline is the deadline of the permit, the sigDeadline of Permit2 allowances
+	Deadline *big.Int
+	// Expiration is the end of Permit2 allowances
+	Expiration *big.Int
+	// TokenName and TokenVersion are the EIP-712 domain of EIP-2612 tokens
+	TokenName    string
+	TokenVersion string
+	// Permit2 is the verifying contract of the Permit2 kinds
+	Permit2 common.Address
+}
+
+// DomainSeparator returns the EIP-712 domain separator of the permit, to compare with the
+// DOMAIN_SEPARATOR of the verifying contract
+func (p *Permit) DomainSeparator() ([]byte, error) {
+	if p.ChainID == nil || p.ChainID.Sign() <= 0 || p.ChainID.BitLen() > 256 {
+		return nil, ErrInvalidPermitChainID
+	}
+	switch p.Kind {
+	case PermitEIP2612:
+		if p.TokenName == "" {
+			return nil, ErrMissingPermitTokenName
+		}
+		return crypto.Keccak256(
+			crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)")),
+			crypto.Keccak256([]byte(p.TokenName)),
+			crypto.Keccak256([]byte(p.TokenVersion)),
+			word(p.ChainID),
+			addressWord(p.Token),
+		), nil
+	case PermitPermit2, PermitPermit2Transfer:
+		// Permit2 has no version in its domain
+		return crypto.Keccak256(
+			crypto.Keccak256([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)")),
+			crypto.Keccak256([]byte("Permit2")),
+			word(p.ChainID),
+			addressWord(p.Permit2),
+		), nil
+	default:
+		return nil, ErrUnsupportedPermitKind
+	}
+}
+
+// Hash returns the EIP-712 hash of the permit of owner, keccak256(0x19 0x01 domainSeparator structHash)
+func (p *Permit) Hash(owner common.Address) ([]byte, error) {
+	domainSeparator, err := p.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePermit(p); err != nil {
+		return nil, err
+	}
+
+	var structHash []byte
+	switch p.Kind {
+	case PermitEIP2612:
+		structHash = crypto.Keccak256(
+			crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)")),
+			addressWord(owner),
+			addressWord(p.Spender),
+			word(p.Amount),
+			word(p.Nonce),
+			word(p.Deadline),
+		)
+	case PermitPermit2:
+		details := crypto.Keccak256(
+			crypto.Keccak256([]byte("PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)")),
+			addressWord(p.Token),
+			word(p.Amount),
+			word(p.Expiration),
+			word(p.Nonce),
+		)
+		structHash = crypto.Keccak256(
+			crypto.Keccak256([]byte("PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)"+
+				"PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)")),
+			details,
+			addressWord(p.Spender),
+			word(p.Deadline),
+		)
+	case PermitPermit2Transfer:
+		permitted := crypto.Keccak256(
+			crypto.Keccak256([]byte("TokenPermissions(address token,uint256 amount)")),
+			addressWord(p.Token),
+			word(p.Amount),
+		)
+		structHash = crypto.Keccak256(
+			crypto.Keccak256([]byte("PermitTransferFrom(TokenPermissions permitted,address spender,uint256 nonce,"+
+				"uint256 deadline)TokenPermissions(address token,uint256 amount)")),
+			permitted,
+			addressWord(p.Spender),
+			word(p.Nonce),
+			word(p.Deadline),
+		)
+	}
+
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash), nil
+}
+
+// SignPermit signs the permit with the key of the derivation path, the permit owner. It returns
+// the 65 byte r || s || v signature, v being 27 or 28, the owner address and the hash.
+func SignPermit(seed []byte, derivationPath string, p *Permit) ([]byte, common.Address, []byte, error) {
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	owner := crypto.PubkeyToAddress(privateKey.PublicKey)
+	hash, err := p.Hash(owner)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	signature, err := signDigest(privateKey, hash)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	return signature, owner, hash, nil
+}
+
+// validatePermit checks the amounts of p fit the types of its kind
+func validatePermit(p *Permit) error {
+	amounts := []*big.Int{p.Amount, p.Nonce, p.Deadline}
+	if p.Kind == PermitPermit2 {
+		amounts = append(amounts, p.Expiration)
+	}
+	for _, v := range amounts {
+		if v == nil || v.Sign() < 0 || v.BitLen() > 256 {
+			return ErrInvalidPermitAmount
+		}
+	}
+	if p.Kind == PermitPermit2 && (p.Amount.BitLen() > permit2AmountBits ||
+		p.Expiration.BitLen() > permit2UintBits || p.Nonce.BitLen() > permit2UintBits) {
+		return ErrInvalidPermitAmount
+	}
+	return nil
+}
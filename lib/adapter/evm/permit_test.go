@@ -0,0 +1,206 @@
+package evm
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPermit(kind string) *Permit {
+	return &Permit{
+		Kind:         kind,
+		ChainID:      big.NewInt(1),
+		Token:        common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		Spender:      common.HexToAddress("0x3fC91A3afd70395Cd496C647d5a6CC9D4B2b7FAD"),
+		Amount:       big.NewInt(1000000),
+		Nonce:        big.NewInt(5),
+		Deadline:     big.NewInt(1767225600),
+		Expiration:   big.NewInt(1769904000),
+		TokenName:    "USD Coin",
+		TokenVersion: "2",
+		Permit2:      common.HexToAddress(Permit2Address),
+	}
+}
+
+// permitTypedDataHash hashes p with the go-ethereum EIP-712 implementation
+func permitTypedDataHash(t *testing.T, p *Permit, owner common.Address) []byte {
+	t.Helper()
+	var typedData apitypes.TypedData
+	switch p.Kind {
+	case PermitEIP2612:
+		typedData = apitypes.TypedData{
+			Types: apitypes.Types{
+				"EIP712Domain": {
+					{Name: "name", Type: "string"},
+					{Name: "version", Type: "string"},
+					{Name: "chainId", Type: "uint256"},
+					{Name: "verifyingContract", Type: "address"},
+				},
+				"Permit": {
+					{Name: "owner", Type: "address"},
+					{Name: "spender", Type: "address"},
+					{Name: "value", Type: "uint256"},
+					{Name: "nonce", Type: "uint256"},
+					{Name: "deadline", Type: "uint256"},
+				},
+			},
+			PrimaryType: "Permit",
+			Domain: apitypes.TypedDataDomain{
+				Name: p.TokenName, Version: p.TokenVersion, ChainId: (*math.HexOrDecimal256)(p.ChainID),
+				VerifyingContract: p.Token.Hex(),
+			},
+			Message: apitypes.TypedDataMessage{
+				"owner":    owner.Hex(),
+				"spender":  p.Spender.Hex(),
+				"value":    p.Amount.String(),
+				"nonce":    p.Nonce.String(),
+				"deadline": p.Deadline.String(),
+			},
+		}
+	case PermitPermit2:
+		typedData = apitypes.TypedData{
+			Types: apitypes.Types{
+				"EIP712Domain": permit2DomainType(),
+				"PermitSingle": {
+					{Name: "details", Type: "PermitDetails"},
+					{Name: "spender", Type: "address"},
+					{Name: "sigDeadline", Type: "uint256"},
+				},
+				"PermitDetails": {
+					{Name: "token", Type: "address"},
+					{Name: "amount", Type: "uint160"},
+					{Name: "expiration", Type: "uint48"},
+					{Name: "nonce", Type: "uint48"},
+				},
+			},
+			PrimaryType: "PermitSingle",
+			Domain:      permit2Domain(p),
+			Message: apitypes.TypedDataMessage{
+				"details": map[string]interface{}{
+					"token":      p.Token.Hex(),
+					"amount":     p.Amount.String(),
+					"expiration": p.Expiration.String(),
+					"nonce":      p.Nonce.String(),
+				},
+				"spender":     p.Spender.Hex(),
+				"sigDeadline": p.Deadline.String(),
+			},
+		}
+	case PermitPermit2Transfer:
+		typedData = apitypes.TypedData{
+			Types: apitypes.Types{
+				"EIP712Domain": permit2DomainType(),
+				"PermitTransferFrom": {
+					{Name: "permitted", Type: "TokenPermissions"},
+					{Name: "spender", Type: "address"},
+					{Name: "nonce", Type: "uint256"},
+					{Name: "deadline", Type: "uint256"},
+				},
+				"TokenPermissions": {
+					{Name: "token", Type: "address"},
+					{Name: "amount", Type: "uint256"},
+				},
+			},
+			PrimaryType: "PermitTransferFrom",
+			Domain:      permit2Domain(p),
+			Message: apitypes.TypedDataMessage{
+				"permitted": map[string]interface{}{
+					"token":  p.Token.Hex(),
+					"amount": p.Amount.String(),
+				},
+				"spender":  p.Spender.Hex(),
+				"nonce":    p.Nonce.String(),
+				"deadline": p.Deadline.String(),
+			},
+		}
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	return hash
+}
+
+func permit2DomainType() []apitypes.Type {
+	return []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	}
+}
+
+func permit2Domain(p *Permit) apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name: "Permit2", ChainId: (*math.HexOrDecimal256)(p.ChainID), VerifyingContract: p.Permit2.Hex(),
+	}
+}
+
+func TestPermitHash(t *testing.T) {
+	owner := common.HexToAddress(expectedAddress)
+	for _, kind := range []string{PermitEIP2612, PermitPermit2, PermitPermit2Transfer} {
+		t.Run(kind, func(t *testing.T) {
+			p := newTestPermit(kind)
+			hash, err := p.Hash(owner)
+			require.NoError(t, err)
+			assert.Equal(t, hex.EncodeToString(permitTypedDataHash(t, p, owner)), hex.EncodeToString(hash))
+		})
+	}
+
+	t.Run("permit2 domain separator", func(t *testing.T) {
+		// DOMAIN_SEPARATOR() of Permit2 on Ethereum mainnet
+		domainSeparator, err := newTestPermit(PermitPermit2).DomainSeparator()
+		require.NoError(t, err)
+		assert.Equal(t, "866a5aba21966af95d6c7ab78eb2b2fc913915c28be3b9aa07cc04ff903e3f28",
+			hex.EncodeToString(domainSeparator))
+	})
+
+	t.Run("invalid permits", func(t *testing.T) {
+		p := newTestPermit("permit")
+		_, err := p.Hash(owner)
+		require.ErrorIs(t, err, ErrUnsupportedPermitKind)
+
+		p = newTestPermit(PermitEIP2612)
+		p.TokenName = ""
+		_, err = p.Hash(owner)
+		require.ErrorIs(t, err, ErrMissingPermitTokenName)
+
+		p = newTestPermit(PermitPermit2)
+		p.ChainID = big.NewInt(0)
+		_, err = p.Hash(owner)
+		require.ErrorIs(t, err, ErrInvalidPermitChainID)
+
+		// allowances are uint160, the one time transfers uint256
+		p = newTestPermit(PermitPermit2)
+		p.Amount = new(big.Int).Lsh(big.NewInt(1), 160)
+		_, err = p.Hash(owner)
+		require.ErrorIs(t, err, ErrInvalidPermitAmount)
+		p.Kind = PermitPermit2Transfer
+		_, err = p.Hash(owner)
+		require.NoError(t, err)
+	})
+}
+
+func TestSignPermit(t *testing.T) {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+
+	signature, owner, hash, err := SignPermit(seed, testDerivationPath, newTestPermit(PermitEIP2612))
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, owner.Hex())
+	require.Len(t, signature, crypto.SignatureLength)
+
+	expected, err := newTestPermit(PermitEIP2612).Hash(owner)
+	require.NoError(t, err)
+	assert.Equal(t, expected, hash)
+
+	recovered := append([]byte{}, signature...)
+	recovered[crypto.RecoveryIDOffset] -= 27
+	publicKey, err := crypto.SigToPub(hash, recovered)
+	require.NoError(t, err)
+	assert.Equal(t, owner, crypto.PubkeyToAddress(*publicKey))
+}
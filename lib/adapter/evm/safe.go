@@ -0,0 +1,145 @@
+package evm
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Operations of Safe transactions
+const (
+	SafeOperationCall         uint8 = 0
+	SafeOperationDelegateCall uint8 = 1
+)
+
+// DefaultSafeVersion is the Safe contract version assumed when none is given
+const DefaultSafeVersion = "1.3.0"
+
+// safeVersionParts is the number of components of Safe versions, major.minor.patch
+const safeVersionParts = 3
+
+// SafeTx is a transaction of a Safe (formerly Gnosis Safe) multisig, approved by its owners
+// by signing its EIP-712 hash
+type SafeTx struct {
+	ChainID        *big.Int
+	Safe           common.Address
+	To             common.Address
+	Value          *big.Int
+	Data           []byte
+	Operation      uint8
+	SafeTxGas      *big.Int
+	BaseGas        *big.Int
+	GasPrice       *big.Int
+	GasToken       common.Address
+	RefundReceiver common.Address
+	Nonce          *big.Int
+	// Version is the Safe contract version: the domain has no chainId before 1.3.0, and
+	// baseGas was named dataGas before 1.0.0
+	Version string
+}
+
+// Hash returns the EIP-712 hash of the SafeTx, keccak256(0x19 0x01 domainSeparator safeTxHash)
+func (tx *SafeTx) Hash() ([]byte, error) {
+	version := tx.Version
+	if version == "" {
+		version = DefaultSafeVersion
+	}
+	withChainID, err := safeVersionAtLeast(version, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	withBaseGas, err := safeVersionAtLeast(version, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSafeTx(tx, withChainID); err != nil {
+		return nil, err
+	}
+
+	var domainSeparator []byte
+	if withChainID {
+		domainSeparator = crypto.Keccak256(
+			crypto.Keccak256([]byte("EIP712Domain(uint256 chainId,address verifyingContract)")),
+			word(tx.ChainID), addressWord(tx.Safe),
+		)
+	} else {
+		domainSeparator = crypto.Keccak256(
+			crypto.Keccak256([]byte("EIP712Domain(address verifyingContract)")),
+			addressWord(tx.Safe),
+		)
+	}
+
+	gasField := "baseGas"
+	if !withBaseGas {
+		gasField = "dataGas"
+	}
+	typeHash := crypto.Keccak256([]byte("SafeTx(address to,uint256 value,bytes data,uint8 operation," +
+		"uint256 safeTxGas,uint256 " + gasField + ",uint256 gasPrice,address gasToken,address refundReceiver," +
+		"uint256 nonce)"))
+	safeTxHash := crypto.Keccak256(
+		typeHash,
+		addressWord(tx.To),
+		word(tx.Value),
+		crypto.Keccak256(tx.Data),
+		word(new(big.Int).SetUint64(uint64(tx.Operation))),
+		word(tx.SafeTxGas),
+		word(tx.BaseGas),
+		word(tx.GasPrice),
+		addressWord(tx.GasToken),
+		addressWord(tx.RefundReceiver),
+		word(tx.Nonce),
+	)
+
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, safeTxHash), nil
+}
+
+// SignSafeTx signs the hash of tx with the key of the derivation path. It returns the 65 byte
+// r || s || v owner signature accepted by execTransaction, v being 27 or 28, the owner address
+// and the hash.
+func SignSafeTx(seed []byte, derivationPath string, tx *SafeTx) ([]byte, common.Address, []byte, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	signature, owner, err := signHash(seed, derivationPath, hash)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	return signature, owner, hash, nil
+}
+
+// validateSafeTx checks the operation of tx, and that its amounts are uint256 values
+func validateSafeTx(tx *SafeTx, withChainID bool) error {
+	if tx.Operation > SafeOperationDelegateCall {
+		return ErrInvalidSafeOperation
+	}
+	if withChainID && (tx.ChainID == nil || tx.ChainID.Sign() <= 0 || tx.ChainID.BitLen() > 256) {
+		return ErrInvalidSafeChainID
+	}
+	for _, v := range []*big.Int{tx.Value, tx.SafeTxGas, tx.BaseGas, tx.GasPrice, tx.Nonce} {
+		if v == nil || v.Sign() < 0 || v.BitLen() > 256 {
+			return ErrInvalidSafeAmount
+		}
+	}
+	return nil
+}
+
+// safeVersionAtLeast reports whether the major.minor.patch version is at least major.minor
+func safeVersionAtLeast(version string, major, minor int) (bool, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != safeVersionParts {
+		return false, ErrInvalidSafeVersion
+	}
+	numbers := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return false, ErrInvalidSafeVersion
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers[0] > major || (numbers[0] == major && numbers[1] >= minor), nil
+}
@@ -0,0 +1,139 @@
+package evm
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSafeTx() *SafeTx {
+	return &SafeTx{
+		ChainID:        big.NewInt(1),
+		Safe:           common.HexToAddress("0x1f9090aaE28b8a3dCeaDf281B0F12828e676c326"),
+		To:             common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"),
+		Value:          big.NewInt(0),
+		Data:           common.FromHex("0xa9059cbb0000000000000000000000009858effd232b4033e47d90003d41ec34ecaeda9400000000000000000000000000000000000000000000000000000000000f4240"),
+		Operation:      SafeOperationCall,
+		SafeTxGas:      big.NewInt(0),
+		BaseGas:        big.NewInt(0),
+		GasPrice:       big.NewInt(0),
+		GasToken:       common.Address{},
+		RefundReceiver: common.Address{},
+		Nonce:          big.NewInt(42),
+	}
+}
+
+// typedDataHash hashes tx with the go-ethereum EIP-712 implementation
+func typedDataHash(t *testing.T, tx *SafeTx, withChainID bool, gasField string) []byte {
+	t.Helper()
+	domainFields := []apitypes.Type{{Name: "verifyingContract", Type: "address"}}
+	domain := apitypes.TypedDataDomain{VerifyingContract: tx.Safe.Hex()}
+	if withChainID {
+		domainFields = append([]apitypes.Type{{Name: "chainId", Type: "uint256"}}, domainFields...)
+		domain.ChainId = (*math.HexOrDecimal256)(tx.ChainID)
+	}
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainFields,
+			"SafeTx": {
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "data", Type: "bytes"},
+				{Name: "operation", Type: "uint8"},
+				{Name: "safeTxGas", Type: "uint256"},
+				{Name: gasField, Type: "uint256"},
+				{Name: "gasPrice", Type: "uint256"},
+				{Name: "gasToken", Type: "address"},
+				{Name: "refundReceiver", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+			},
+		},
+		PrimaryType: "SafeTx",
+		Domain:      domain,
+		Message: apitypes.TypedDataMessage{
+			"to":             tx.To.Hex(),
+			"value":          tx.Value.String(),
+			"data":           tx.Data,
+			"operation":      big.NewInt(int64(tx.Operation)).String(),
+			"safeTxGas":      tx.SafeTxGas.String(),
+			gasField:         tx.BaseGas.String(),
+			"gasPrice":       tx.GasPrice.String(),
+			"gasToken":       tx.GasToken.Hex(),
+			"refundReceiver": tx.RefundReceiver.Hex(),
+			"nonce":          tx.Nonce.String(),
+		},
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	require.NoError(t, err)
+	return hash
+}
+
+func TestSafeTxHash(t *testing.T) {
+	tests := []struct {
+		version     string
+		withChainID bool
+		gasField    string
+	}{
+		{"", true, "baseGas"},
+		{"1.4.1", true, "baseGas"},
+		{"1.1.1", false, "baseGas"},
+		{"0.1.0", false, "dataGas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			tx := newTestSafeTx()
+			tx.Version = tt.version
+			hash, err := tx.Hash()
+			require.NoError(t, err)
+			assert.Equal(t, hex.EncodeToString(typedDataHash(t, tx, tt.withChainID, tt.gasField)), hex.EncodeToString(hash))
+		})
+	}
+
+	t.Run("invalid transactions", func(t *testing.T) {
+		tx := newTestSafeTx()
+		tx.Version = "1.3"
+		_, err := tx.Hash()
+		require.ErrorIs(t, err, ErrInvalidSafeVersion)
+
+		tx = newTestSafeTx()
+		tx.Operation = 2
+		_, err = tx.Hash()
+		require.ErrorIs(t, err, ErrInvalidSafeOperation)
+
+		tx = newTestSafeTx()
+		tx.ChainID = nil
+		_, err = tx.Hash()
+		require.ErrorIs(t, err, ErrInvalidSafeChainID)
+
+		tx = newTestSafeTx()
+		tx.Value = big.NewInt(-1)
+		_, err = tx.Hash()
+		require.ErrorIs(t, err, ErrInvalidSafeAmount)
+	})
+}
+
+func TestSignSafeTx(t *testing.T) {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+
+	tx := newTestSafeTx()
+	signature, owner, hash, err := SignSafeTx(seed, testDerivationPath, tx)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, owner.Hex())
+	require.Len(t, signature, crypto.SignatureLength)
+	assert.Contains(t, []byte{27, 28}, signature[crypto.RecoveryIDOffset])
+
+	// Safe recovers the owner with v - 27
+	recovered := append([]byte{}, signature...)
+	recovered[crypto.RecoveryIDOffset] -= 27
+	publicKey, err := crypto.SigToPub(hash, recovered)
+	require.NoError(t, err)
+	assert.Equal(t, owner, crypto.PubkeyToAddress(*publicKey))
+}
@@ -0,0 +1,123 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EntryPoint versions of ERC-4337 user operations
+const (
+	EntryPointV06 = "0.6"
+	EntryPointV07 = "0.7"
+)
+
+// DefaultEntryPointVersion is the EntryPoint version assumed when none is given
+const DefaultEntryPointVersion = EntryPointV07
+
+// packedGasBits is the width of each gas value packed in the bytes32 fields of v0.7 user operations
+const packedGasBits = 128
+
+// UserOperation is an ERC-4337 user operation. For EntryPoint v0.7, InitCode is the packed
+// factory || factoryData and PaymasterAndData the packed paymaster || paymasterVerificationGasLimit ||
+// paymasterPostOpGasLimit || paymasterData, as in the PackedUserOperation the EntryPoint hashes.
+type UserOperation struct {
+	Sender               common.Address
+	Nonce                *big.Int
+	InitCode             []byte
+	CallData             []byte
+	CallGasLimit         *big.Int
+	VerificationGasLimit *big.Int
+	PreVerificationGas   *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	PaymasterAndData     []byte
+}
+
+// Hash returns the userOpHash of the EntryPoint version deployed at entryPoint on chainID,
+// keccak256(abi.encode(keccak256(pack(userOp)), entryPoint, chainId))
+func (op *UserOperation) Hash(entryPoint common.Address, chainID *big.Int, version string) ([]byte, error) {
+	if chainID == nil || chainID.Sign() <= 0 || chainID.BitLen() > 256 {
+		return nil, ErrInvalidUserOpChainID
+	}
+	for _, v := range []*big.Int{
+		op.Nonce, op.CallGasLimit, op.VerificationGasLimit, op.PreVerificationGas, op.MaxFeePerGas,
+		op.MaxPriorityFeePerGas,
+	} {
+		if v == nil || v.Sign() < 0 || v.BitLen() > 256 {
+			return nil, ErrInvalidUserOpAmount
+		}
+	}
+
+	var packed []byte
+	switch version {
+	case EntryPointV06:
+		packed = crypto.Keccak256(
+			addressWord(op.Sender),
+			word(op.Nonce),
+			crypto.Keccak256(op.InitCode),
+			crypto.Keccak256(op.CallData),
+			word(op.CallGasLimit),
+			word(op.VerificationGasLimit),
+			word(op.PreVerificationGas),
+			word(op.MaxFeePerGas),
+			word(op.MaxPriorityFeePerGas),
+			crypto.Keccak256(op.PaymasterAndData),
+		)
+	case EntryPointV07:
+		accountGasLimits, err := packGas(op.VerificationGasLimit, op.CallGasLimit)
+		if err != nil {
+			return nil, err
+		}
+		gasFees, err := packGas(op.MaxPriorityFeePerGas, op.MaxFeePerGas)
+		if err != nil {
+			return nil, err
+		}
+		packed = crypto.Keccak256(
+			addressWord(op.Sender),
+			word(op.Nonce),
+			crypto.Keccak256(op.InitCode),
+			crypto.Keccak256(op.CallData),
+			accountGasLimits,
+			word(op.PreVerificationGas),
+			gasFees,
+			crypto.Keccak256(op.PaymasterAndData),
+		)
+	default:
+		return nil, ErrUnsupportedEntryPoint
+	}
+
+	return crypto.Keccak256(packed, addressWord(entryPoint), word(chainID)), nil
+}
+
+// SignUserOperation signs the userOpHash of op with the key of the derivation path. With ethSign
+// the EIP-191 message of the hash is signed, as SimpleAccount and most ECDSA owned accounts
+// verify it, the hash itself otherwise. It returns the 65 byte r || s || v signature, v being
+// 27 or 28, the owner address and the userOpHash.
+func SignUserOperation(seed []byte, derivationPath string, op *UserOperation, entryPoint common.Address,
+	chainID *big.Int, version string, ethSign bool) ([]byte, common.Address, []byte, error) {
+	hash, err := op.Hash(entryPoint, chainID, version)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	digest := hash
+	if ethSign {
+		digest = accounts.TextHash(hash)
+	}
+	signature, owner, err := signHash(seed, derivationPath, digest)
+	if err != nil {
+		return nil, common.Address{}, nil, err
+	}
+	return signature, owner, hash, nil
+}
+
+// packGas packs two 128 bit gas values in a bytes32, high first
+func packGas(high, low *big.Int) ([]byte, error) {
+	if high.BitLen() > packedGasBits || low.BitLen() > packedGasBits {
+		return nil, ErrInvalidUserOpAmount
+	}
+	packed := new(big.Int).Lsh(high, packedGasBits)
+	return word(packed.Or(packed, low)), nil
+}
@@ -0,0 +1,127 @@
+package evm
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUserOperation() *UserOperation {
+	return &UserOperation{
+		Sender:               common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"),
+		Nonce:                big.NewInt(3),
+		InitCode:             common.FromHex("0x9406cc6185a346906296840746125a0e449764545fbfb9cf"),
+		CallData:             common.FromHex("0xb61d27f6"),
+		CallGasLimit:         big.NewInt(100000),
+		VerificationGasLimit: big.NewInt(150000),
+		PreVerificationGas:   big.NewInt(21000),
+		MaxFeePerGas:         big.NewInt(30000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		PaymasterAndData:     nil,
+	}
+}
+
+// abiEncode encodes values with the go-ethereum ABI implementation
+func abiEncode(t *testing.T, types []string, values ...interface{}) []byte {
+	t.Helper()
+	arguments := make(abi.Arguments, 0, len(types))
+	for _, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		require.NoError(t, err)
+		arguments = append(arguments, abi.Argument{Type: typ})
+	}
+	encoded, err := arguments.Pack(values...)
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestUserOperationHash(t *testing.T) {
+	entryPoint := common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+	chainID := big.NewInt(11155111)
+	op := newTestUserOperation()
+	outer := func(packed []byte) []byte {
+		return crypto.Keccak256(abiEncode(t, []string{"bytes32", "address", "uint256"},
+			common.BytesToHash(crypto.Keccak256(packed)), entryPoint, chainID))
+	}
+
+	t.Run("v0.6", func(t *testing.T) {
+		packed := abiEncode(t,
+			[]string{"address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256",
+				"uint256", "bytes32"},
+			op.Sender, op.Nonce, common.BytesToHash(crypto.Keccak256(op.InitCode)),
+			common.BytesToHash(crypto.Keccak256(op.CallData)), op.CallGasLimit, op.VerificationGasLimit,
+			op.PreVerificationGas, op.MaxFeePerGas, op.MaxPriorityFeePerGas,
+			common.BytesToHash(crypto.Keccak256(op.PaymasterAndData)))
+		hash, err := op.Hash(entryPoint, chainID, EntryPointV06)
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(outer(packed)), hex.EncodeToString(hash))
+	})
+
+	t.Run("v0.7", func(t *testing.T) {
+		var accountGasLimits, gasFees [32]byte
+		copy(accountGasLimits[:16], common.LeftPadBytes(op.VerificationGasLimit.Bytes(), 16))
+		copy(accountGasLimits[16:], common.LeftPadBytes(op.CallGasLimit.Bytes(), 16))
+		copy(gasFees[:16], common.LeftPadBytes(op.MaxPriorityFeePerGas.Bytes(), 16))
+		copy(gasFees[16:], common.LeftPadBytes(op.MaxFeePerGas.Bytes(), 16))
+		packed := abiEncode(t,
+			[]string{"address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "bytes32", "bytes32"},
+			op.Sender, op.Nonce, common.BytesToHash(crypto.Keccak256(op.InitCode)),
+			common.BytesToHash(crypto.Keccak256(op.CallData)), accountGasLimits, op.PreVerificationGas, gasFees,
+			common.BytesToHash(crypto.Keccak256(op.PaymasterAndData)))
+		hash, err := op.Hash(entryPoint, chainID, EntryPointV07)
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(outer(packed)), hex.EncodeToString(hash))
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		_, err := op.Hash(entryPoint, chainID, "0.8")
+		require.ErrorIs(t, err, ErrUnsupportedEntryPoint)
+
+		_, err = op.Hash(entryPoint, nil, EntryPointV07)
+		require.ErrorIs(t, err, ErrInvalidUserOpChainID)
+
+		invalid := newTestUserOperation()
+		invalid.Nonce = nil
+		_, err = invalid.Hash(entryPoint, chainID, EntryPointV07)
+		require.ErrorIs(t, err, ErrInvalidUserOpAmount)
+
+		// gas values of v0.7 are packed in 128 bits
+		invalid = newTestUserOperation()
+		invalid.MaxFeePerGas = new(big.Int).Lsh(big.NewInt(1), 128)
+		_, err = invalid.Hash(entryPoint, chainID, EntryPointV07)
+		require.ErrorIs(t, err, ErrInvalidUserOpAmount)
+		_, err = invalid.Hash(entryPoint, chainID, EntryPointV06)
+		require.NoError(t, err)
+	})
+}
+
+func TestSignUserOperation(t *testing.T) {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+	entryPoint := common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+
+	for _, ethSign := range []bool{true, false} {
+		signature, owner, hash, err := SignUserOperation(seed, testDerivationPath, newTestUserOperation(), entryPoint,
+			big.NewInt(1), EntryPointV07, ethSign)
+		require.NoError(t, err)
+		assert.Equal(t, expectedAddress, owner.Hex())
+		require.Len(t, signature, crypto.SignatureLength)
+
+		digest := hash
+		if ethSign {
+			digest = accounts.TextHash(hash)
+		}
+		recovered := append([]byte{}, signature...)
+		recovered[crypto.RecoveryIDOffset] -= 27
+		publicKey, err := crypto.SigToPub(digest, recovered)
+		require.NoError(t, err)
+		assert.Equal(t, owner, crypto.PubkeyToAddress(*publicKey))
+	}
+}
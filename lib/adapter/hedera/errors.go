@@ -0,0 +1,9 @@
+package hedera
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData = errors.New("invalid payload data")
+	ErrInvalidBodyBytes   = errors.New("invalid transaction body bytes")
+)
@@ -0,0 +1,197 @@
+package hedera
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// ed25519PublicKeyDERPrefix is the SubjectPublicKeyInfo prefix of an ed25519 public key
+	ed25519PublicKeyDERPrefix = "302a300506032b6570032100"
+	// ed25519PrivateKeyDERPrefix is the PKCS#8 prefix of an ed25519 private key
+	ed25519PrivateKeyDERPrefix = "302e020100300506032b657004220420"
+	// aliasShardRealm is the shard and realm of a public key account alias
+	aliasShardRealm = "0.0."
+)
+
+// protobuf field numbers of the Hedera transaction envelope
+const (
+	signaturePairPubKeyPrefix         protowire.Number = 1
+	signaturePairEd25519              protowire.Number = 3
+	signatureMapSigPair               protowire.Number = 1
+	signedTransactionBodyBytes        protowire.Number = 1
+	signedTransactionSigMap           protowire.Number = 2
+	transactionSignedTransactionBytes protowire.Number = 5
+)
+
+// Adapter represents a Hedera adapter for ed25519 accounts
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewHederaAdapter creates a new Hedera adapter instance
+func NewHederaAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "hedera")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (h *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Hedera
+}
+
+// Capabilities describes the coins and formats handled by the Hedera adapter
+func (h *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "hedera",
+		CoinTypes:      []uint16{slip44.Hedera},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"public key alias 0.0.<DER public key hex>"},
+		Payload:        lib.HederaRawTx{},
+		Operations:     lib.DefaultOperations(),
+	}
+}
+
+// DerivePrivateKey derives a DER encoded private key from the given seed and derivation path
+func (h *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := h.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := ed25519PrivateKeyDERPrefix + hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives the DER encoded public key Hedera expects in account keys
+func (h *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := h.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := publicKeyDER(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the public key account alias (0.0.<DER public key>).
+// Hedera account numbers are assigned by the network; the alias can be used
+// as the recipient of a transfer to auto-create the account.
+func (h *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := h.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	address := aliasShardRealm + publicKeyDER(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Address derived successfully", "address", address)
+
+	return address, nil
+}
+
+// ValidatePayload reports the field errors of a Hedera sign payload
+func (h *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	if bodyBytes, ok := v.Hex("bodyBytes", true); ok && !isProtobufMessage(bodyBytes) {
+		v.Fail("bodyBytes", "not a protobuf TransactionBody")
+	}
+	return v.Err()
+}
+
+// CreateSignedTransaction signs the TransactionBody bytes in the payload and returns
+// the hex encoded protobuf Transaction wrapping the SignedTransaction.
+func (h *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := h.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.HederaRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil || rawTx.BodyBytes == "" {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	bodyBytes, err := hex.DecodeString(strings.TrimPrefix(rawTx.BodyBytes, "0x"))
+	if err != nil || !isProtobufMessage(bodyBytes) {
+		return "", ErrInvalidBodyBytes
+	}
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	signature := ed25519.Sign(privateKey, bodyBytes)
+
+	var sigPair []byte
+	sigPair = protowire.AppendTag(sigPair, signaturePairPubKeyPrefix, protowire.BytesType)
+	sigPair = protowire.AppendBytes(sigPair, publicKey)
+	sigPair = protowire.AppendTag(sigPair, signaturePairEd25519, protowire.BytesType)
+	sigPair = protowire.AppendBytes(sigPair, signature)
+
+	var sigMap []byte
+	sigMap = protowire.AppendTag(sigMap, signatureMapSigPair, protowire.BytesType)
+	sigMap = protowire.AppendBytes(sigMap, sigPair)
+
+	var signedTx []byte
+	signedTx = protowire.AppendTag(signedTx, signedTransactionBodyBytes, protowire.BytesType)
+	signedTx = protowire.AppendBytes(signedTx, bodyBytes)
+	signedTx = protowire.AppendTag(signedTx, signedTransactionSigMap, protowire.BytesType)
+	signedTx = protowire.AppendBytes(signedTx, sigMap)
+
+	var tx []byte
+	tx = protowire.AppendTag(tx, transactionSignedTransactionBytes, protowire.BytesType)
+	tx = protowire.AppendBytes(tx, signedTx)
+
+	txHex := hex.EncodeToString(tx)
+	logger.Info("Signed transaction created successfully", "tx", txHex)
+
+	return txHex, nil
+}
+
+func publicKeyDER(publicKey ed25519.PublicKey) string {
+	return ed25519PublicKeyDERPrefix + hex.EncodeToString(publicKey)
+}
+
+// isProtobufMessage reports whether b is a well formed sequence of protobuf fields
+func isProtobufMessage(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for len(b) > 0 {
+		_, _, n := protowire.ConsumeField(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package hedera
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// SLIP-0010 ed25519 test vector 1 seed and its m/0' child
+	testSeedHex        = "000102030405060708090a0b0c0d0e0f"
+	testDerivationPath = "m/0'"
+	expectedPublicKey  = "302a300506032b65700321008c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"
+	expectedPrivateKey = "302e020100300506032b65700422042068e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"
+	// CryptoTransfer body: transactionID, nodeAccountID 0.0.3, fee, validDuration, memo
+	testBodyBytesHex = "0a0c0a0608b0bcc6a90612021802120218031880c2d72f22020878320474657374"
+)
+
+func newTestAdapter() *Adapter {
+	return NewHederaAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+	return seed
+}
+
+func TestHederaAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Hedera))
+	assert.False(t, adapter.CanDo(slip44.Solana))
+}
+
+func TestHederaAdapter_DeriveKeys(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+
+	privateKey, err := adapter.DerivePrivateKey(seed, testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedPrivateKey, privateKey)
+
+	publicKey, err := adapter.DerivePublicKey(seed, testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedPublicKey, publicKey)
+
+	address, err := adapter.DeriveAddress(seed, testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, "0.0."+expectedPublicKey, address)
+
+	_, err = adapter.DerivePublicKey(seed, "m/44'/3030'/0'/0", false)
+	assert.ErrorIs(t, err, lib.ErrNonHardenedComponent)
+}
+
+func TestHederaAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+
+	t.Run("wraps body bytes in a signed transaction", func(t *testing.T) {
+		payload, err := json.Marshal(lib.HederaRawTx{BodyBytes: testBodyBytesHex})
+		require.NoError(t, err)
+
+		txHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		require.NoError(t, err)
+		tx, err := hex.DecodeString(txHex)
+		require.NoError(t, err)
+
+		signedTx := bytesFields(t, tx)[transactionSignedTransactionBytes]
+		body := bytesFields(t, signedTx)[signedTransactionBodyBytes]
+		assert.Equal(t, testBodyBytesHex, hex.EncodeToString(body))
+
+		sigMap := bytesFields(t, signedTx)[signedTransactionSigMap]
+		sigPair := bytesFields(t, bytesFields(t, sigMap)[signatureMapSigPair])
+		publicKey, signature := sigPair[signaturePairPubKeyPrefix], sigPair[signaturePairEd25519]
+
+		assert.Equal(t, expectedPublicKey[len(ed25519PublicKeyDERPrefix):], hex.EncodeToString(publicKey))
+		assert.True(t, ed25519.Verify(publicKey, body, signature))
+	})
+
+	t.Run("rejects invalid payload", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, testDerivationPath, `{"invalid":"json"}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"bodyBytes":"zz"}`)
+		assert.ErrorIs(t, err, ErrInvalidBodyBytes)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"bodyBytes":"0aff"}`)
+		assert.ErrorIs(t, err, ErrInvalidBodyBytes)
+	})
+}
+
+// bytesFields decodes a message made only of length delimited fields
+func bytesFields(t *testing.T, b []byte) map[protowire.Number][]byte {
+	fields := make(map[protowire.Number][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.Positive(t, n)
+		require.Equal(t, protowire.BytesType, typ)
+		value, m := protowire.ConsumeBytes(b[n:])
+		require.Positive(t, m)
+		fields[num] = value
+		b = b[n+m:]
+	}
+	return fields
+}
@@ -1,18 +1,41 @@
 package adapter
 
-import "log/slog"
+import (
+	"context"
+	"log/slog"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type adapter interface {
 	CanDo(coinType uint16) bool
+	Capabilities() lib.Capabilities
 	DerivePrivateKey(seed []byte, derivationPath string, isDev bool) (string, error)
 	DerivePublicKey(seed []byte, derivationPath string, isDev bool) (string, error)
 	DeriveAddress(seed []byte, derivationPath string, isDev bool) (string, error)
+	ValidatePayload(payload string) error
 	CreateSignedTransaction(seed []byte, derivationPath string, payload string) (string, error)
 }
 
+// publicKeyAdapter is implemented by adapters of coins whose addresses can be derived from the
+// public key alone, as needed for watch-only users
+type publicKeyAdapter interface {
+	AddressFromPublicKey(publicKey []byte, derivationPath string, isDev bool) (string, error)
+}
+
+// descriptorAdapter is implemented by adapters of coins with output descriptors (BIP-380)
+type descriptorAdapter interface {
+	Descriptor(seed []byte, derivationPath string, isDev bool) (string, error)
+}
+
 type Inventory struct {
 	logger   *slog.Logger
 	adapters []adapter
+	// ctx is the context the spans of the derivations and signatures are children of
+	ctx context.Context
 }
 
 func NewAdapterInventory(logger *slog.Logger, adapters ...adapter) *Inventory {
@@ -22,6 +45,24 @@ func NewAdapterInventory(logger *slog.Logger, adapters ...adapter) *Inventory {
 	}
 }
 
+// WithContext returns the inventory recording the spans of its derivations and signatures as
+// children of the span of ctx
+func (i *Inventory) WithContext(ctx context.Context) *Inventory {
+	traced := *i
+	traced.ctx = ctx
+	return &traced
+}
+
+// startSpan starts the span of the stage name for coinType
+func (i *Inventory) startSpan(name string, coinType uint16) trace.Span {
+	ctx := i.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Start(ctx, "adapter."+name, attribute.Int("dq_vault.coin_type", int(coinType)))
+	return span
+}
+
 func (i *Inventory) getProvider(coinType uint16) adapter {
 	for _, adapter := range i.adapters {
 		if adapter.CanDo(coinType) {
@@ -31,6 +72,24 @@ func (i *Inventory) getProvider(coinType uint16) adapter {
 	return nil
 }
 
+// Capabilities returns the capabilities of every registered adapter, in registration order
+func (i *Inventory) Capabilities() []lib.Capabilities {
+	capabilities := make([]lib.Capabilities, 0, len(i.adapters))
+	for _, adapter := range i.adapters {
+		capabilities = append(capabilities, adapter.Capabilities())
+	}
+	return capabilities
+}
+
+// CoinCapabilities returns the capabilities of the adapter handling coinType
+func (i *Inventory) CoinCapabilities(coinType uint16) (lib.Capabilities, error) {
+	adapter := i.getProvider(coinType)
+	if adapter == nil {
+		return lib.Capabilities{}, ErrNoAdapterFound
+	}
+	return adapter.Capabilities(), nil
+}
+
 func (i *Inventory) DerivePublicKey(seed []byte, coinType uint16,
 	derivationPath string, isDev bool) (string, error) {
 	logger := i.logger.With(slog.String("op", "derive_public_key"), slog.Uint64("coinType", uint64(coinType)))
@@ -42,7 +101,9 @@ func (i *Inventory) DerivePublicKey(seed []byte, coinType uint16,
 		return "", ErrNoAdapterFound
 	}
 
+	span := i.startSpan("derive_public_key", coinType)
 	pubKey, err := adapter.DerivePublicKey(seed, derivationPath, isDev)
+	tracing.End(span, err)
 	if err != nil {
 		logger.Error("Failed to derive public key", "error", err)
 		return "", err
@@ -64,7 +125,9 @@ func (i *Inventory) DeriveAddress(seed []byte, coinType uint16,
 		return "", ErrNoAdapterFound
 	}
 
+	span := i.startSpan("derive_address", coinType)
 	address, err := adapter.DeriveAddress(seed, derivationPath, isDev)
+	tracing.End(span, err)
 	if err != nil {
 		logger.Error("Failed to derive address", "error", err)
 		return "", err
@@ -75,6 +138,73 @@ func (i *Inventory) DeriveAddress(seed []byte, coinType uint16,
 	return address, nil
 }
 
+// AddressFromPublicKey derives the address of the compressed secp256k1 publicKey of derivationPath,
+// or returns ErrNoPublicKeyAddress when the coin needs the seed
+func (i *Inventory) AddressFromPublicKey(publicKey []byte, coinType uint16,
+	derivationPath string, isDev bool) (string, error) {
+	logger := i.logger.With(slog.String("op", "address_from_public_key"), slog.Uint64("coinType", uint64(coinType)))
+
+	adapter := i.getProvider(coinType)
+	if adapter == nil {
+		logger.Error("No adapter found for coin type", "coinType", coinType)
+		return "", ErrNoAdapterFound
+	}
+	publicKeyAdapter, ok := adapter.(publicKeyAdapter)
+	if !ok {
+		return "", ErrNoPublicKeyAddress
+	}
+
+	span := i.startSpan("address_from_public_key", coinType)
+	address, err := publicKeyAdapter.AddressFromPublicKey(publicKey, derivationPath, isDev)
+	tracing.End(span, err)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+	return address, nil
+}
+
+// Descriptor returns the output descriptor of the address of derivationPath, or ErrNoDescriptor
+// when the coin has none
+func (i *Inventory) Descriptor(seed []byte, coinType uint16, derivationPath string, isDev bool) (string, error) {
+	logger := i.logger.With(slog.String("op", "descriptor"), slog.Uint64("coinType", uint64(coinType)))
+
+	adapter, ok := i.getProvider(coinType).(descriptorAdapter)
+	if !ok {
+		return "", ErrNoDescriptor
+	}
+
+	span := i.startSpan("descriptor", coinType)
+	descriptor, err := adapter.Descriptor(seed, derivationPath, isDev)
+	tracing.End(span, err)
+	if err != nil {
+		logger.Error("Failed to derive descriptor", "error", err)
+		return "", err
+	}
+	return descriptor, nil
+}
+
+// ValidatePayload checks the sign payload of coinType before any key is derived.
+// Invalid payloads return lib.PayloadErrors listing every faulty field.
+func (i *Inventory) ValidatePayload(coinType uint16, payload string) error {
+	logger := i.logger.With(slog.String("op", "validate_payload"), slog.Uint64("coinType", uint64(coinType)))
+
+	adapter := i.getProvider(coinType)
+	if adapter == nil {
+		logger.Error("No adapter found for coin type", "coinType", coinType)
+		return ErrNoAdapterFound
+	}
+
+	span := i.startSpan("validate_payload", coinType)
+	err := adapter.ValidatePayload(payload)
+	tracing.End(span, err)
+	if err != nil {
+		logger.Error("Invalid payload", "error", err)
+		return err
+	}
+	return nil
+}
+
 func (i *Inventory) CreateSignedTransaction(seed []byte, coinType uint16,
 	derivationPath string, payload string, _ bool) (string, error) {
 	logger := i.logger.With(slog.String("op", "create_signed_transaction"), slog.Uint64("coinType", uint64(coinType)))
@@ -86,7 +216,9 @@ func (i *Inventory) CreateSignedTransaction(seed []byte, coinType uint16,
 		return "", ErrNoAdapterFound
 	}
 
+	span := i.startSpan("create_signed_transaction", coinType)
 	tx, err := adapter.CreateSignedTransaction(seed, derivationPath, payload)
+	tracing.End(span, err)
 	if err != nil {
 		logger.Error("Failed to create signed transaction", "error", err)
 		return "", err
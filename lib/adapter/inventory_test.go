@@ -0,0 +1,145 @@
+package adapter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const benchMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// benchCoin is a coin of the registry benchmarked with the account at path
+type benchCoin struct {
+	name     string
+	coinType uint16
+	path     string
+	// payload returns a sign payload of the account at address, nil when signing is not benchmarked
+	payload func(b *testing.B, address string) string
+}
+
+// benchCoins lists one coin per registered adapter. The Algorand and Bitcoin payloads (msgpack
+// transactions and PSBTs spending the account) are only built by their package tests, so these
+// coins are benchmarked for address derivation only.
+func benchCoins() []benchCoin {
+	return []benchCoin{
+		{"evm", slip44.Ether, "m/44'/60'/0'/0/0", func(*testing.B, string) string {
+			return `{"nonce":42,"value":1000000000000000000,"gasLimit":21000,"gasPrice":20000000000,` +
+				`"to":"0x742d35Cc6634C0532925a3b8D359A5C5119e32C8","data":"0x","chainId":1}`
+		}},
+		{"solana", slip44.Solana, "m/44'/501'/0'/0'", benchSolanaPayload},
+		{"aptos", slip44.Aptos, "m/44'/637'/0'/0'/0'", benchAptosPayload},
+		{"sui", slip44.Sui, "m/44'/784'/0'/0'/0'", func(*testing.B, string) string {
+			return `{"txBytes":"0000020100"}`
+		}},
+		{"ton", slip44.Ton, "m/44'/607'/0'", func(*testing.B, string) string {
+			return `{"seqno":5,"validUntil":1900000000,"messages":[{"address":` +
+				`"EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N","amount":"1000000000","bounce":true}]}`
+		}},
+		{"hedera", slip44.Hedera, "m/0'", func(*testing.B, string) string {
+			return `{"bodyBytes":"0a0c0a0608b0bcc6a90612021802120218031880c2d72f22020878320474657374"}`
+		}},
+		{"algorand", slip44.Algorand, "m/0'", nil},
+		{"starknet", slip44.Starknet, "m/44'/60'/0'/0/0", func(_ *testing.B, address string) string {
+			return `{"type":"invoke","senderAddress":"` + address + `","nonce":"0x5","chainId":"SN_SEPOLIA",` +
+				`"calls":[{"to":"0x049d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7",` +
+				`"entrypoint":"transfer","calldata":["0x1234","0x64","0x0"]}],"resourceBounds":{` +
+				`"l1Gas":{"maxAmount":"0x100","maxPricePerUnit":"0x1234567890"},` +
+				`"l2Gas":{"maxAmount":"1000000","maxPricePerUnit":"0x5"},` +
+				`"l1DataGas":{"maxAmount":"0x80","maxPricePerUnit":"0x9"}}}`
+		}},
+		{"bitcoin", slip44.Bitcoin, "m/84'/0'/0'/0/0", nil},
+	}
+}
+
+// benchSolanaPayload returns an SPL transfer message paid by address
+func benchSolanaPayload(b *testing.B, address string) string {
+	owner, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		b.Fatal(err)
+	}
+	mint, err := solana.PublicKeyFromBase58("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+	if err != nil {
+		b.Fatal(err)
+	}
+	recipient, err := solana.PublicKeyFromBase58("9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM")
+	if err != nil {
+		b.Fatal(err)
+	}
+	built, err := solana.BuildSPLTransfer(solana.SPLTransfer{
+		Owner: owner, Mint: mint, Recipient: recipient, Amount: 1_000_000, RecentBlockhash: mint,
+		TokenProgram: solana.TokenProgramSPL, Decimals: -1,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return benchJSON(b, lib.SolanaRawTx{RawTxHex: hex.EncodeToString(built.Message)})
+}
+
+// benchAptosPayload returns a raw transaction sent by address
+func benchAptosPayload(b *testing.B, address string) string {
+	sender, err := hex.DecodeString(strings.TrimPrefix(address, "0x"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return benchJSON(b, lib.BCSRawTx{TxBytes: hex.EncodeToString(append(sender, 0x01, 0x02, 0x03, 0x04))})
+}
+
+func benchJSON(b *testing.B, v any) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return string(encoded)
+}
+
+func newBenchInventory(b *testing.B) (*Inventory, []byte) {
+	b.Helper()
+	seed, err := lib.SeedFromMnemonic(benchMnemonic, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return GetInventory(slog.New(slog.NewTextHandler(io.Discard, nil))), seed
+}
+
+func BenchmarkInventory_DeriveAddress(b *testing.B) {
+	inventory, seed := newBenchInventory(b)
+	for _, coin := range benchCoins() {
+		b.Run(coin.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := inventory.DeriveAddress(seed, coin.coinType, coin.path, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkInventory_CreateSignedTransaction(b *testing.B) {
+	inventory, seed := newBenchInventory(b)
+	for _, coin := range benchCoins() {
+		if coin.payload == nil {
+			continue
+		}
+		b.Run(coin.name, func(b *testing.B) {
+			address, err := inventory.DeriveAddress(seed, coin.coinType, coin.path, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			payload := coin.payload(b, address)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := inventory.CreateSignedTransaction(seed, coin.coinType, coin.path, payload, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,15 @@ import (
 	"log/slog"
 	"sync"
 
+	"github.com/payment-system/dq-vault/lib/adapter/algorand"
+	"github.com/payment-system/dq-vault/lib/adapter/aptos"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
 	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/hedera"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/adapter/starknet"
+	"github.com/payment-system/dq-vault/lib/adapter/sui"
+	"github.com/payment-system/dq-vault/lib/adapter/ton"
 )
 
 // Package-level variables for singleton pattern
@@ -19,6 +27,14 @@ func GetInventory(logger *slog.Logger) *Inventory {
 		inventory = NewAdapterInventory(
 			logger,
 			evm.NewEthereumAdapter(logger),
+			solana.NewSolanaAdapter(logger),
+			aptos.NewAptosAdapter(logger),
+			sui.NewSuiAdapter(logger),
+			ton.NewTonAdapter(logger),
+			hedera.NewHederaAdapter(logger),
+			algorand.NewAlgorandAdapter(logger),
+			starknet.NewStarknetAdapter(logger),
+			bitcoin.NewBitcoinAdapter(logger),
 		)
 	})
 	return inventory
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/payment-system/dq-vault/lib"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidCapabilities = errors.New("adapter declares invalid capabilities")
+	ErrDuplicateCoinType   = errors.New("coin type is claimed by two adapters, the second is never used")
+	ErrSelfCheckFailed     = errors.New("adapter fails its self-check derivation")
+)
+
+// selfCheckSeed is the seed of the self-check derivations, the one of the BIP-39 test vector
+// "abandon abandon ... about" without passphrase. No key derived from it is ever used.
+//
+//nolint:gochecknoglobals // read-only test vector
+var selfCheckSeed = []byte{
+	0x5e, 0xb0, 0x0b, 0xbd, 0xdc, 0xf0, 0x69, 0x08, 0x48, 0x89, 0xa8, 0xab, 0x91, 0x55, 0x56, 0x81,
+	0x65, 0xf5, 0xc4, 0x53, 0xcc, 0xb8, 0x5e, 0x70, 0x81, 0x1a, 0xae, 0xd6, 0xf6, 0xda, 0x5f, 0xc1,
+	0x9a, 0x5a, 0xc4, 0x0b, 0x38, 0x9c, 0xd3, 0x70, 0xd0, 0x86, 0x20, 0x6d, 0xec, 0x8a, 0xa6, 0xc4,
+	0x3d, 0xae, 0xa6, 0x69, 0x0f, 0x20, 0xad, 0x3d, 0x8d, 0x48, 0xb2, 0xd2, 0xce, 0x9e, 0x38, 0xe4,
+}
+
+// SelfCheck checks the registration of the adapters: each declares a name, a known curve and coin
+// types it handles, no coin type is claimed twice, and each coin type derives an address at the
+// first hardened BIP-44 account. It returns every failure, so a broken build is caught at startup
+// instead of at its first request.
+func (i *Inventory) SelfCheck() error {
+	var errs []error
+	claimed := make(map[uint16]string)
+	for _, adapter := range i.adapters {
+		capabilities := adapter.Capabilities()
+		if capabilities.Adapter == "" || len(capabilities.CoinTypes) == 0 ||
+			!slices.Contains([]string{lib.CurveSecp256k1, lib.CurveEd25519, lib.CurveStark}, capabilities.Curve) {
+			errs = append(errs, fmt.Errorf("%w: %q, curve %q, coin types %v", ErrInvalidCapabilities,
+				capabilities.Adapter, capabilities.Curve, capabilities.CoinTypes))
+			continue
+		}
+
+		for _, coinType := range capabilities.CoinTypes {
+			if owner, ok := claimed[coinType]; ok {
+				errs = append(errs, fmt.Errorf("%w: coin type %d by %s and %s", ErrDuplicateCoinType, coinType, owner,
+					capabilities.Adapter))
+				continue
+			}
+			claimed[coinType] = capabilities.Adapter
+			if !adapter.CanDo(coinType) {
+				errs = append(errs, fmt.Errorf("%w: %s does not handle its coin type %d", ErrInvalidCapabilities,
+					capabilities.Adapter, coinType))
+				continue
+			}
+
+			path := fmt.Sprintf("m/44'/%d'/0'/0'/0'", coinType)
+			address, err := adapter.DeriveAddress(selfCheckSeed, path, false)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("%w: %s, coin type %d: %w", ErrSelfCheckFailed, capabilities.Adapter,
+					coinType, err))
+			case address == "":
+				errs = append(errs, fmt.Errorf("%w: %s, coin type %d: empty address", ErrSelfCheckFailed,
+					capabilities.Adapter, coinType))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,22 @@
+package adapter
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+func TestInventory_SelfCheck(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	require.NoError(t, GetInventory(logger).SelfCheck())
+
+	// the second EVM adapter is shadowed by the first on every coin type
+	err := NewAdapterInventory(logger, evm.NewEthereumAdapter(logger), solana.NewSolanaAdapter(logger),
+		evm.NewEthereumAdapter(logger)).SelfCheck()
+	require.ErrorIs(t, err, ErrDuplicateCoinType)
+	require.ErrorContains(t, err, "coin type 60")
+}
@@ -0,0 +1,22 @@
+package solana
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData  = errors.New("invalid payload data")
+	ErrInvalidMessage      = errors.New("invalid transaction message")
+	ErrUnexpectedFeePayer  = errors.New("fee payer of message does not match derived address")
+	ErrUnsupportedSigners  = errors.New("message requires signatures other than the fee payer")
+	ErrInvalidPublicKey    = errors.New("invalid public key")
+	ErrInvalidBlockhash    = errors.New("invalid recent blockhash")
+	ErrInvalidAmount       = errors.New("amount must be greater than zero")
+	ErrUnknownTokenProgram = errors.New("unknown token program")
+	ErrInvalidDecimals     = errors.New("decimals out of range")
+	ErrDecimalsRequired    = errors.New("decimals are required for token-2022 transfers")
+	ErrNoViableProgramBump = errors.New("unable to find a viable program address bump seed")
+	ErrTooManyAccountKeys  = errors.New("too many account keys in message")
+	ErrCompactU16Overflow  = errors.New("value does not fit in compact-u16")
+	ErrMalformedCompactU16 = errors.New("malformed compact-u16")
+	ErrInvalidMemo         = errors.New("memo must be valid UTF-8 of 1 to 566 bytes")
+)
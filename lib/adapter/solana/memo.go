@@ -0,0 +1,44 @@
+package solana
+
+import (
+	"unicode/utf8"
+)
+
+// MemoProgramID is the address of the SPL memo program, version 2
+const MemoProgramID = "MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr"
+
+// MaxMemoLength is the largest memo, in bytes, fitting in a transaction of a single signer
+const MaxMemoLength = 566
+
+// MemoInstruction returns the instruction of the memo program logging memo. It requires no
+// signature, so the memo does not change the signers of the message.
+func MemoInstruction(memo string) (Instruction, error) {
+	if memo == "" || len(memo) > MaxMemoLength || !utf8.ValidString(memo) {
+		return Instruction{}, ErrInvalidMemo
+	}
+	memoProgram, err := PublicKeyFromBase58(MemoProgramID)
+	if err != nil {
+		return Instruction{}, err
+	}
+	return Instruction{ProgramID: memoProgram, Data: []byte(memo)}, nil
+}
+
+// Memos returns the memos of the memo program instructions of the legacy or v0 message msg, in
+// the order of the instructions; messages that cannot be decoded have none
+func Memos(msg []byte) []string {
+	memoProgram, err := PublicKeyFromBase58(MemoProgramID)
+	if err != nil {
+		return nil
+	}
+	instructions, ok := programInstructions(msg)
+	if !ok {
+		return nil
+	}
+	var memos []string
+	for _, instruction := range instructions {
+		if instruction.programID == memoProgram {
+			memos = append(memos, string(instruction.data))
+		}
+	}
+	return memos
+}
@@ -0,0 +1,409 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+
+	"filippo.io/edwards25519"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	// maxSeedLength is the maximum length of a single program address seed
+	maxSeedLength = 32
+	// maxAccountKeys is the number of accounts a legacy message can reference
+	maxAccountKeys = 256
+	// compactU16Max is the largest value encodable as compact-u16
+	compactU16Max = 0xffff
+	// pdaMarker is appended to program address seeds before hashing
+	pdaMarker = "ProgramDerivedAddress"
+)
+
+// PublicKey is a 32 byte Solana account address
+type PublicKey [ed25519.PublicKeySize]byte
+
+// PublicKeyFromBase58 decodes a base58 encoded Solana address
+func PublicKeyFromBase58(s string) (PublicKey, error) {
+	var pk PublicKey
+	decoded := base58.Decode(s)
+	if len(decoded) != len(pk) {
+		return pk, ErrInvalidPublicKey
+	}
+	copy(pk[:], decoded)
+	return pk, nil
+}
+
+// BlockhashFromBase58 decodes a base58 encoded recent blockhash
+func BlockhashFromBase58(s string) (PublicKey, error) {
+	blockhash, err := PublicKeyFromBase58(s)
+	if err != nil {
+		return blockhash, ErrInvalidBlockhash
+	}
+	return blockhash, nil
+}
+
+// String returns the base58 representation of the public key
+func (p PublicKey) String() string {
+	return base58.Encode(p[:])
+}
+
+// isOnCurve reports whether the key is a valid ed25519 point.
+// Program derived addresses are required to be off the curve.
+func (p PublicKey) isOnCurve() bool {
+	_, err := new(edwards25519.Point).SetBytes(p[:])
+	return err == nil
+}
+
+// FindProgramAddress returns the first off-curve program derived address for
+// the seeds, searching bump seeds from 255 down as the Solana runtime does.
+func FindProgramAddress(seeds [][]byte, programID PublicKey) (PublicKey, uint8, error) {
+	for _, seed := range seeds {
+		if len(seed) > maxSeedLength {
+			return PublicKey{}, 0, ErrInvalidPublicKey
+		}
+	}
+
+	for bump := 255; bump >= 0; bump-- {
+		h := sha256.New()
+		for _, seed := range seeds {
+			h.Write(seed)
+		}
+		h.Write([]byte{uint8(bump)})
+		h.Write(programID[:])
+		h.Write([]byte(pdaMarker))
+
+		var candidate PublicKey
+		copy(candidate[:], h.Sum(nil))
+		if !candidate.isOnCurve() {
+			return candidate, uint8(bump), nil
+		}
+	}
+	return PublicKey{}, 0, ErrNoViableProgramBump
+}
+
+// AccountMeta describes how an instruction uses an account
+type AccountMeta struct {
+	PublicKey  PublicKey
+	IsSigner   bool
+	IsWritable bool
+}
+
+// Instruction is a single program invocation inside a message
+type Instruction struct {
+	ProgramID PublicKey
+	Accounts  []AccountMeta
+	Data      []byte
+}
+
+// CompileMessage serializes instructions into a legacy Solana message with the
+// fee payer as the first (writable, signing) account.
+func CompileMessage(feePayer PublicKey, recentBlockhash PublicKey, instructions []Instruction) ([]byte, error) {
+	metas := []AccountMeta{{PublicKey: feePayer, IsSigner: true, IsWritable: true}}
+	index := map[PublicKey]int{feePayer: 0}
+
+	add := func(meta AccountMeta) {
+		if i, ok := index[meta.PublicKey]; ok {
+			metas[i].IsSigner = metas[i].IsSigner || meta.IsSigner
+			metas[i].IsWritable = metas[i].IsWritable || meta.IsWritable
+			return
+		}
+		index[meta.PublicKey] = len(metas)
+		metas = append(metas, meta)
+	}
+
+	for _, ix := range instructions {
+		for _, meta := range ix.Accounts {
+			add(meta)
+		}
+		add(AccountMeta{PublicKey: ix.ProgramID})
+	}
+
+	if len(metas) > maxAccountKeys {
+		return nil, ErrTooManyAccountKeys
+	}
+
+	// order: writable signers, readonly signers, writable non-signers, readonly non-signers
+	ordered := make([]AccountMeta, 0, len(metas))
+	for _, group := range []struct{ signer, writable bool }{
+		{true, true}, {true, false}, {false, true}, {false, false},
+	} {
+		for _, meta := range metas {
+			if meta.IsSigner == group.signer && meta.IsWritable == group.writable {
+				ordered = append(ordered, meta)
+			}
+		}
+	}
+
+	var numSigners, numReadonlySigned, numReadonlyUnsigned uint8
+	position := make(map[PublicKey]uint8, len(ordered))
+	for i, meta := range ordered {
+		position[meta.PublicKey] = uint8(i)
+		switch {
+		case meta.IsSigner && !meta.IsWritable:
+			numSigners++
+			numReadonlySigned++
+		case meta.IsSigner:
+			numSigners++
+		case !meta.IsWritable:
+			numReadonlyUnsigned++
+		}
+	}
+
+	msg := []byte{numSigners, numReadonlySigned, numReadonlyUnsigned}
+	msg = appendCompactU16(msg, len(ordered))
+	for _, meta := range ordered {
+		msg = append(msg, meta.PublicKey[:]...)
+	}
+	msg = append(msg, recentBlockhash[:]...)
+
+	msg = appendCompactU16(msg, len(instructions))
+	for _, ix := range instructions {
+		msg = append(msg, position[ix.ProgramID])
+		msg = appendCompactU16(msg, len(ix.Accounts))
+		for _, meta := range ix.Accounts {
+			msg = append(msg, position[meta.PublicKey])
+		}
+		if len(ix.Data) > compactU16Max {
+			return nil, ErrCompactU16Overflow
+		}
+		msg = appendCompactU16(msg, len(ix.Data))
+		msg = append(msg, ix.Data...)
+	}
+
+	return msg, nil
+}
+
+// messageHeader is the decoded prefix of a serialized message
+type messageHeader struct {
+	numRequiredSignatures uint8
+	feePayer              PublicKey
+}
+
+// parseMessageHeader decodes the header and fee payer of a legacy or v0 message
+func parseMessageHeader(msg []byte) (messageHeader, error) {
+	var header messageHeader
+
+	// versioned messages are prefixed with 0x80 | version
+	if len(msg) > 0 && msg[0]&0x80 != 0 {
+		msg = msg[1:]
+	}
+	if len(msg) < 3 {
+		return header, ErrInvalidMessage
+	}
+	header.numRequiredSignatures = msg[0]
+
+	numKeys, n, err := readCompactU16(msg[3:])
+	if err != nil {
+		return header, err
+	}
+	keys := msg[3+n:]
+	if numKeys == 0 || len(keys) < numKeys*len(header.feePayer) {
+		return header, ErrInvalidMessage
+	}
+	copy(header.feePayer[:], keys[:len(header.feePayer)])
+	return header, nil
+}
+
+// IsMessage reports whether msg is exactly one serialized legacy or v0 message: header, account
+// keys, recent blockhash, instructions and, for v0, address table lookups
+func IsMessage(msg []byte) bool {
+	var key PublicKey
+	versioned := len(msg) > 0 && msg[0]&0x80 != 0
+	if versioned {
+		if msg[0] != 0x80 {
+			return false
+		}
+		msg = msg[1:]
+	}
+	if len(msg) < 3 || msg[0] == 0 || msg[1] >= msg[0] {
+		return false
+	}
+	rest := msg[3:]
+
+	// next reads a compact-u16 length followed by size bytes per element
+	next := func(size int) (int, bool) {
+		count, n, err := readCompactU16(rest)
+		if err != nil || len(rest) < n+count*size {
+			return 0, false
+		}
+		rest = rest[n+count*size:]
+		return count, true
+	}
+
+	numKeys, ok := next(len(key))
+	if !ok || numKeys < int(msg[0]) || len(rest) < len(key) {
+		return false
+	}
+	rest = rest[len(key):]
+
+	numInstructions, n, err := readCompactU16(rest)
+	if err != nil {
+		return false
+	}
+	rest = rest[n:]
+	for range numInstructions {
+		if len(rest) == 0 {
+			return false
+		}
+		rest = rest[1:]
+		if _, ok := next(1); !ok {
+			return false
+		}
+		if _, ok := next(1); !ok {
+			return false
+		}
+	}
+
+	if versioned {
+		numLookups, n, err := readCompactU16(rest)
+		if err != nil {
+			return false
+		}
+		rest = rest[n:]
+		for range numLookups {
+			if len(rest) < len(key) {
+				return false
+			}
+			rest = rest[len(key):]
+			if _, ok := next(1); !ok {
+				return false
+			}
+			if _, ok := next(1); !ok {
+				return false
+			}
+		}
+	}
+	return len(rest) == 0
+}
+
+// RecentBlockhash returns the recent blockhash of the legacy or v0 message msg. Messages of a
+// durable nonce hold the nonce there instead.
+func RecentBlockhash(msg []byte) (PublicKey, error) {
+	var blockhash PublicKey
+	offset, err := recentBlockhashOffset(msg)
+	if err != nil {
+		return blockhash, err
+	}
+	copy(blockhash[:], msg[offset:])
+	return blockhash, nil
+}
+
+// SetRecentBlockhash returns a copy of the legacy or v0 message msg with its recent blockhash
+// replaced by blockhash
+func SetRecentBlockhash(msg []byte, blockhash PublicKey) ([]byte, error) {
+	offset, err := recentBlockhashOffset(msg)
+	if err != nil {
+		return nil, err
+	}
+	updated := append([]byte{}, msg...)
+	copy(updated[offset:], blockhash[:])
+	return updated, nil
+}
+
+// recentBlockhashOffset returns the offset of the recent blockhash in msg, following the header
+// and the account keys
+func recentBlockhashOffset(msg []byte) (int, error) {
+	var key PublicKey
+	offset := 0
+	// versioned messages are prefixed with 0x80 | version
+	if len(msg) > 0 && msg[0]&0x80 != 0 {
+		offset = 1
+	}
+	if len(msg) < offset+3 {
+		return 0, ErrInvalidMessage
+	}
+	offset += 3
+
+	numKeys, n, err := readCompactU16(msg[offset:])
+	if err != nil {
+		return 0, err
+	}
+	offset += n + numKeys*len(key)
+	if numKeys == 0 || len(msg) < offset+len(key) {
+		return 0, ErrInvalidMessage
+	}
+	return offset, nil
+}
+
+// programInstruction is an instruction of a compiled message, its accounts left out
+type programInstruction struct {
+	programID PublicKey
+	data      []byte
+}
+
+// programInstructions decodes the instructions of the legacy or v0 message msg. Programs are
+// always static account keys, so the address table lookups of v0 messages are not needed.
+func programInstructions(msg []byte) ([]programInstruction, bool) {
+	var key PublicKey
+	// versioned messages are prefixed with 0x80 | version
+	if len(msg) > 0 && msg[0]&0x80 != 0 {
+		msg = msg[1:]
+	}
+	if len(msg) < 3 {
+		return nil, false
+	}
+	rest := msg[3:]
+
+	numKeys, n, err := readCompactU16(rest)
+	if err != nil || len(rest) < n+(numKeys+1)*len(key) {
+		return nil, false
+	}
+	keys := rest[n : n+numKeys*len(key)]
+	// the recent blockhash follows the account keys
+	rest = rest[n+(numKeys+1)*len(key):]
+
+	numInstructions, n, err := readCompactU16(rest)
+	if err != nil {
+		return nil, false
+	}
+	rest = rest[n:]
+	instructions := make([]programInstruction, 0, numInstructions)
+	for range numInstructions {
+		if len(rest) == 0 {
+			return nil, false
+		}
+		programIndex := int(rest[0])
+		rest = rest[1:]
+		numAccounts, n, err := readCompactU16(rest)
+		if err != nil || len(rest) < n+numAccounts {
+			return nil, false
+		}
+		rest = rest[n+numAccounts:]
+		size, n, err := readCompactU16(rest)
+		if err != nil || len(rest) < n+size || programIndex >= numKeys {
+			return nil, false
+		}
+		instructions = append(instructions, programInstruction{
+			programID: PublicKey(keys[programIndex*len(key) : (programIndex+1)*len(key)]),
+			data:      rest[n : n+size],
+		})
+		rest = rest[n+size:]
+	}
+	return instructions, true
+}
+
+func appendCompactU16(b []byte, v int) []byte {
+	for {
+		elem := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, elem)
+		}
+		b = append(b, elem|0x80)
+	}
+}
+
+func readCompactU16(b []byte) (value, size int, err error) {
+	for size < 3 {
+		if size >= len(b) {
+			return 0, 0, ErrMalformedCompactU16
+		}
+		elem := int(b[size])
+		value |= (elem & 0x7f) << (size * 7)
+		size++
+		if elem&0x80 == 0 {
+			return value, size, nil
+		}
+	}
+	return 0, 0, ErrMalformedCompactU16
+}
@@ -0,0 +1,170 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+)
+
+// Adapter represents a Solana blockchain adapter
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewSolanaAdapter creates a new Solana adapter instance
+func NewSolanaAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "solana")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (s *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Solana
+}
+
+// Capabilities describes the coins and formats handled by the Solana adapter
+func (s *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "solana",
+		CoinTypes:      []uint16{slip44.Solana},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"base58 public key"},
+		Payload:        lib.SolanaRawTx{},
+		Operations:     append(lib.DefaultOperations(), lib.OperationSPLTransfer, lib.OperationBroadcast),
+	}
+}
+
+func (s *Adapter) deriveKey(seed []byte, derivationPath string) (ed25519.PrivateKey, error) {
+	return lib.DeriveEd25519PrivateKey(seed, derivationPath)
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (s *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := s.deriveKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives a public key from the given seed and derivation path
+func (s *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := s.deriveKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the base58 account address from the given seed and derivation path
+func (s *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := s.deriveKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	var address PublicKey
+	copy(address[:], privateKey.Public().(ed25519.PublicKey))
+
+	logger.Info("Address derived successfully", "address", address.String())
+
+	return address.String(), nil
+}
+
+// ValidatePayload reports the field errors of a Solana sign payload
+func (s *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	v.Hex("rawTxHex", true)
+	return v.Err()
+}
+
+// CreateSignedTransaction signs the serialized message in the payload and returns
+// the hex encoded wire transaction (signature count, signature, message).
+func (s *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := s.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.SolanaRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil || rawTx.RawTxHex == "" {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	message, err := hex.DecodeString(strings.TrimPrefix(rawTx.RawTxHex, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+
+	privateKey, err := s.deriveKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	txHex, err := signMessage(privateKey, message)
+	if err != nil {
+		logger.Error("Failed to sign message", "error", err)
+		return "", err
+	}
+
+	logger.Info("Signed transaction created successfully", "tx", txHex)
+
+	return txHex, nil
+}
+
+// signMessage checks that the derived key is the only required signer of the
+// message and returns the serialized, signed transaction.
+func signMessage(privateKey ed25519.PrivateKey, message []byte) (string, error) {
+	header, err := parseMessageHeader(message)
+	if err != nil {
+		return "", err
+	}
+	if header.numRequiredSignatures != 1 {
+		return "", ErrUnsupportedSigners
+	}
+	if !ed25519.PublicKey(header.feePayer[:]).Equal(privateKey.Public()) {
+		return "", ErrUnexpectedFeePayer
+	}
+
+	signature := ed25519.Sign(privateKey, message)
+
+	tx := make([]byte, 0, 1+len(signature)+len(message))
+	tx = appendCompactU16(tx, 1)
+	tx = append(tx, signature...)
+	tx = append(tx, message...)
+
+	return hex.EncodeToString(tx), nil
+}
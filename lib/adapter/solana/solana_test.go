@@ -0,0 +1,351 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// seed derived from the "abandon ... about" mnemonic with an empty passphrase
+	testSeedHex        = "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4"
+	testDerivationPath = "m/44'/501'/0'/0'"
+	expectedAddress    = "HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk"
+	testMint           = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	testRecipient      = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	testBlockhash      = "EkSnNWid2cvwEVnVx9aBqawnmiCNiDgp3gUdkDPTKN1N"
+)
+
+func newTestAdapter() *Adapter {
+	return NewSolanaAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := hex.DecodeString(testSeedHex)
+	require.NoError(t, err)
+	return seed
+}
+
+func TestDeriveEd25519PrivateKey_SLIP10Vectors(t *testing.T) {
+	// https://github.com/satoshilabs/slips/blob/master/slip-0010.md test vector 1 for ed25519
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path       string
+		privateKey string
+		publicKey  string
+	}{
+		{
+			path:       "m/0'",
+			privateKey: "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+			publicKey:  "8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c",
+		},
+		{
+			path:       "m/0'/1'",
+			privateKey: "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+			publicKey:  "1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			key, err := lib.DeriveEd25519PrivateKey(seed, tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.privateKey, hex.EncodeToString(key.Seed()))
+			assert.Equal(t, tt.publicKey, hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+		})
+	}
+
+	t.Run("non hardened component rejected", func(t *testing.T) {
+		_, err := lib.DeriveEd25519PrivateKey(seed, "m/44'/501'/0'/0")
+		assert.ErrorIs(t, err, lib.ErrNonHardenedComponent)
+	})
+}
+
+func TestSolanaAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Solana))
+	assert.False(t, adapter.CanDo(slip44.Ether))
+	assert.False(t, adapter.CanDo(slip44.Tron))
+}
+
+func TestSolanaAdapter_DeriveAddress(t *testing.T) {
+	adapter := newTestAdapter()
+
+	address, err := adapter.DeriveAddress(testSeed(t), testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, address)
+
+	_, err = adapter.DeriveAddress(testSeed(t), "m/44'/501'/0'/0", false)
+	assert.Error(t, err)
+}
+
+func TestSolanaAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+
+	owner, err := PublicKeyFromBase58(expectedAddress)
+	require.NoError(t, err)
+	transfer := newTestTransfer(t)
+	transfer.Owner = owner
+
+	built, err := BuildSPLTransfer(transfer)
+	require.NoError(t, err)
+
+	t.Run("signs message with fee payer key", func(t *testing.T) {
+		payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(built.Message)})
+		require.NoError(t, err)
+
+		txHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		require.NoError(t, err)
+
+		tx, err := hex.DecodeString(txHex)
+		require.NoError(t, err)
+		require.Equal(t, byte(1), tx[0])
+		signature, message := tx[1:1+ed25519.SignatureSize], tx[1+ed25519.SignatureSize:]
+		assert.Equal(t, built.Message, message)
+		assert.True(t, ed25519.Verify(owner[:], message, signature))
+	})
+
+	t.Run("rejects message paid by another account", func(t *testing.T) {
+		other := newTestTransfer(t)
+		other.Owner, err = PublicKeyFromBase58(testRecipient)
+		require.NoError(t, err)
+		foreign, err := BuildSPLTransfer(other)
+		require.NoError(t, err)
+
+		payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(foreign.Message)})
+		require.NoError(t, err)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		assert.ErrorIs(t, err, ErrUnexpectedFeePayer)
+	})
+
+	t.Run("rejects invalid payload", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, testDerivationPath, `{"invalid":"json"}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"rawTxHex":"zz"}`)
+		assert.ErrorIs(t, err, ErrInvalidMessage)
+	})
+}
+
+func newTestTransfer(t *testing.T) SPLTransfer {
+	mint, err := PublicKeyFromBase58(testMint)
+	require.NoError(t, err)
+	recipient, err := PublicKeyFromBase58(testRecipient)
+	require.NoError(t, err)
+	blockhash, err := BlockhashFromBase58(testBlockhash)
+	require.NoError(t, err)
+
+	return SPLTransfer{
+		Mint:            mint,
+		Recipient:       recipient,
+		Amount:          1_000_000,
+		RecentBlockhash: blockhash,
+		TokenProgram:    TokenProgramSPL,
+		Decimals:        -1,
+	}
+}
+
+func TestBuildSPLTransfer(t *testing.T) {
+	owner, err := PublicKeyFromBase58(expectedAddress)
+	require.NoError(t, err)
+
+	t.Run("associated token accounts are off curve and deterministic", func(t *testing.T) {
+		transfer := newTestTransfer(t)
+		transfer.Owner = owner
+
+		first, err := BuildSPLTransfer(transfer)
+		require.NoError(t, err)
+		second, err := BuildSPLTransfer(transfer)
+		require.NoError(t, err)
+
+		assert.Equal(t, first.Message, second.Message)
+		assert.False(t, first.SourceAccount.isOnCurve())
+		assert.False(t, first.DestinationAccount.isOnCurve())
+		assert.NotEqual(t, first.SourceAccount, first.DestinationAccount)
+	})
+
+	t.Run("token-2022 uses a different token account", func(t *testing.T) {
+		transfer := newTestTransfer(t)
+		transfer.Owner = owner
+		legacy, err := BuildSPLTransfer(transfer)
+		require.NoError(t, err)
+
+		transfer.TokenProgram = TokenProgramToken2022
+		_, err = BuildSPLTransfer(transfer)
+		assert.ErrorIs(t, err, ErrDecimalsRequired)
+
+		transfer.Decimals = 6
+		token2022, err := BuildSPLTransfer(transfer)
+		require.NoError(t, err)
+		assert.NotEqual(t, legacy.SourceAccount, token2022.SourceAccount)
+	})
+
+	t.Run("header reflects single signer", func(t *testing.T) {
+		transfer := newTestTransfer(t)
+		transfer.Owner = owner
+		transfer.CreateRecipientAccount = true
+
+		built, err := BuildSPLTransfer(transfer)
+		require.NoError(t, err)
+
+		header, err := parseMessageHeader(built.Message)
+		require.NoError(t, err)
+		assert.Equal(t, uint8(1), header.numRequiredSignatures)
+		assert.Equal(t, owner, header.feePayer)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		transfer := newTestTransfer(t)
+		transfer.Owner = owner
+
+		transfer.Amount = 0
+		_, err := BuildSPLTransfer(transfer)
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+
+		transfer.Amount = 1
+		transfer.TokenProgram = "unknown"
+		_, err = BuildSPLTransfer(transfer)
+		assert.ErrorIs(t, err, ErrUnknownTokenProgram)
+	})
+}
+
+func TestSetRecentBlockhash(t *testing.T) {
+	transfer := newTestTransfer(t)
+	built, err := BuildSPLTransfer(transfer)
+	require.NoError(t, err)
+
+	fresh, err := BlockhashFromBase58(testMint)
+	require.NoError(t, err)
+	updated, err := SetRecentBlockhash(built.Message, fresh)
+	require.NoError(t, err)
+
+	transfer.RecentBlockhash = fresh
+	expected, err := BuildSPLTransfer(transfer)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Message, updated)
+	assert.NotEqual(t, expected.Message, built.Message, "the message must be copied")
+
+	// v0 messages are prefixed with their version
+	versioned, err := SetRecentBlockhash(append([]byte{0x80}, built.Message...), fresh)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Message, versioned[1:])
+
+	blockhash, err := RecentBlockhash(versioned)
+	require.NoError(t, err)
+	assert.Equal(t, fresh, blockhash)
+	blockhash, err = RecentBlockhash(built.Message)
+	require.NoError(t, err)
+	assert.NotEqual(t, fresh, blockhash)
+
+	_, err = SetRecentBlockhash(built.Message[:40], fresh)
+	require.ErrorIs(t, err, ErrInvalidMessage)
+	_, err = RecentBlockhash(built.Message[:40])
+	require.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestUsesDurableNonce(t *testing.T) {
+	owner, err := PublicKeyFromBase58(expectedAddress)
+	require.NoError(t, err)
+	nonceAccount, err := PublicKeyFromBase58(testRecipient)
+	require.NoError(t, err)
+	systemProgram, err := PublicKeyFromBase58(SystemProgramID)
+	require.NoError(t, err)
+	var nonce PublicKey
+
+	transfer, err := BuildSOLTransfer(owner, nonceAccount, 1, nonce, "")
+	require.NoError(t, err)
+	assert.False(t, UsesDurableNonce(transfer))
+
+	advance := Instruction{
+		ProgramID: systemProgram,
+		Accounts: []AccountMeta{
+			{PublicKey: nonceAccount, IsWritable: true},
+			{PublicKey: owner, IsSigner: true},
+		},
+		Data: binary.LittleEndian.AppendUint32(nil, systemInstructionAdvanceNonceAccount),
+	}
+	message, err := CompileMessage(owner, nonce, []Instruction{advance})
+	require.NoError(t, err)
+	assert.True(t, UsesDurableNonce(message))
+	assert.True(t, UsesDurableNonce(append([]byte{0x80}, message...)))
+	assert.False(t, UsesDurableNonce(message[:40]))
+}
+
+func TestBuildSOLTransfer(t *testing.T) {
+	owner, err := PublicKeyFromBase58(expectedAddress)
+	require.NoError(t, err)
+	recipient, err := PublicKeyFromBase58(testRecipient)
+	require.NoError(t, err)
+	blockhash, err := BlockhashFromBase58(testBlockhash)
+	require.NoError(t, err)
+
+	message, err := BuildSOLTransfer(owner, recipient, 1_500_000, blockhash, "")
+	require.NoError(t, err)
+	header, err := parseMessageHeader(message)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), header.numRequiredSignatures)
+	assert.Equal(t, owner, header.feePayer)
+	// the instruction data closes the message: the transfer discriminator and the lamports
+	assert.Equal(t, "0200000060e3160000000000", hex.EncodeToString(message[len(message)-12:]))
+
+	seed := testSeed(t)
+	payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(message)})
+	require.NoError(t, err)
+	_, err = newTestAdapter().CreateSignedTransaction(seed, testDerivationPath, string(payload))
+	require.NoError(t, err)
+
+	_, err = BuildSOLTransfer(owner, recipient, 0, blockhash, "")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	assert.Empty(t, Memos(message))
+}
+
+func TestMemos(t *testing.T) {
+	owner, err := PublicKeyFromBase58(expectedAddress)
+	require.NoError(t, err)
+	recipient, err := PublicKeyFromBase58(testRecipient)
+	require.NoError(t, err)
+	mint, err := PublicKeyFromBase58(testMint)
+	require.NoError(t, err)
+	blockhash, err := BlockhashFromBase58(testBlockhash)
+	require.NoError(t, err)
+
+	message, err := BuildSOLTransfer(owner, recipient, 1_500_000, blockhash, "deposit 104528")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deposit 104528"}, Memos(message))
+	// the memo needs no signature of its own
+	header, err := parseMessageHeader(message)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), header.numRequiredSignatures)
+	payload, err := json.Marshal(lib.SolanaRawTx{RawTxHex: hex.EncodeToString(message)})
+	require.NoError(t, err)
+	_, err = newTestAdapter().CreateSignedTransaction(testSeed(t), testDerivationPath, string(payload))
+	require.NoError(t, err)
+
+	built, err := BuildSPLTransfer(SPLTransfer{
+		Owner: owner, Mint: mint, Recipient: recipient, Amount: 10, RecentBlockhash: blockhash, Decimals: 6,
+		CreateRecipientAccount: true, Memo: "DX7Q9",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DX7Q9"}, Memos(built.Message))
+
+	for _, memo := range []string{strings.Repeat("m", MaxMemoLength+1), "\xff"} {
+		_, err := BuildSOLTransfer(owner, recipient, 1, blockhash, memo)
+		require.ErrorIs(t, err, ErrInvalidMemo)
+	}
+	assert.Empty(t, Memos([]byte{1, 0}))
+}
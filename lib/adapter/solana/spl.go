@@ -0,0 +1,176 @@
+package solana
+
+import (
+	"encoding/binary"
+)
+
+// Well known program addresses
+const (
+	SystemProgramID                 = "11111111111111111111111111111111"
+	TokenProgramID                  = "TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA"
+	Token2022ProgramID              = "TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb"
+	AssociatedTokenAccountProgramID = "ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL"
+)
+
+// Token program names accepted by BuildSPLTransfer
+const (
+	TokenProgramSPL       = "spl-token"
+	TokenProgramToken2022 = "token-2022"
+)
+
+// token program instruction discriminators
+const (
+	instructionTransfer        = 3
+	instructionTransferChecked = 12
+	// associated token account program CreateIdempotent instruction
+	instructionCreateIdempotent = 1
+	// maxDecimals is the largest decimals value a mint can declare
+	maxDecimals = 255
+)
+
+// SPLTransfer describes an SPL token transfer from the signer's associated token account
+type SPLTransfer struct {
+	Owner           PublicKey
+	Mint            PublicKey
+	Recipient       PublicKey
+	Amount          uint64
+	RecentBlockhash PublicKey
+	// TokenProgram is either TokenProgramSPL or TokenProgramToken2022
+	TokenProgram string
+	// Decimals selects TransferChecked when non-negative; required for token-2022
+	Decimals int
+	// CreateRecipientAccount prepends an idempotent associated token account creation
+	CreateRecipientAccount bool
+	// Memo appends an instruction of the memo program, for the recipients crediting deposits by memo
+	Memo string
+}
+
+// SPLTransferMessage is the compiled message and the token accounts it touches
+type SPLTransferMessage struct {
+	Message            []byte
+	SourceAccount      PublicKey
+	DestinationAccount PublicKey
+}
+
+// TokenProgramAddress resolves a token program name to its program address
+func TokenProgramAddress(name string) (PublicKey, error) {
+	switch name {
+	case "", TokenProgramSPL:
+		return PublicKeyFromBase58(TokenProgramID)
+	case TokenProgramToken2022:
+		return PublicKeyFromBase58(Token2022ProgramID)
+	default:
+		return PublicKey{}, ErrUnknownTokenProgram
+	}
+}
+
+// FindAssociatedTokenAddress derives the associated token account of wallet for mint
+func FindAssociatedTokenAddress(wallet, mint, tokenProgram PublicKey) (PublicKey, error) {
+	ataProgram, err := PublicKeyFromBase58(AssociatedTokenAccountProgramID)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	address, _, err := FindProgramAddress([][]byte{wallet[:], tokenProgram[:], mint[:]}, ataProgram)
+	return address, err
+}
+
+// BuildSPLTransfer compiles a message transferring tokens between the associated
+// token accounts of the owner and recipient. The owner pays the fee.
+func BuildSPLTransfer(t SPLTransfer) (*SPLTransferMessage, error) {
+	if t.Amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	tokenProgram, err := TokenProgramAddress(t.TokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	if t.TokenProgram == TokenProgramToken2022 && t.Decimals < 0 {
+		return nil, ErrDecimalsRequired
+	}
+	if t.Decimals > maxDecimals {
+		return nil, ErrInvalidDecimals
+	}
+
+	source, err := FindAssociatedTokenAddress(t.Owner, t.Mint, tokenProgram)
+	if err != nil {
+		return nil, err
+	}
+	destination, err := FindAssociatedTokenAddress(t.Recipient, t.Mint, tokenProgram)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := make([]Instruction, 0, 3)
+
+	if t.CreateRecipientAccount {
+		ataProgram, err := PublicKeyFromBase58(AssociatedTokenAccountProgramID)
+		if err != nil {
+			return nil, err
+		}
+		systemProgram, err := PublicKeyFromBase58(SystemProgramID)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, Instruction{
+			ProgramID: ataProgram,
+			Accounts: []AccountMeta{
+				{PublicKey: t.Owner, IsSigner: true, IsWritable: true},
+				{PublicKey: destination, IsWritable: true},
+				{PublicKey: t.Recipient},
+				{PublicKey: t.Mint},
+				{PublicKey: systemProgram},
+				{PublicKey: tokenProgram},
+			},
+			Data: []byte{instructionCreateIdempotent},
+		})
+	}
+
+	var transfer Instruction
+	if t.Decimals >= 0 {
+		data := []byte{instructionTransferChecked}
+		data = binary.LittleEndian.AppendUint64(data, t.Amount)
+		data = append(data, uint8(t.Decimals))
+		transfer = Instruction{
+			ProgramID: tokenProgram,
+			Accounts: []AccountMeta{
+				{PublicKey: source, IsWritable: true},
+				{PublicKey: t.Mint},
+				{PublicKey: destination, IsWritable: true},
+				{PublicKey: t.Owner, IsSigner: true},
+			},
+			Data: data,
+		}
+	} else {
+		data := []byte{instructionTransfer}
+		data = binary.LittleEndian.AppendUint64(data, t.Amount)
+		transfer = Instruction{
+			ProgramID: tokenProgram,
+			Accounts: []AccountMeta{
+				{PublicKey: source, IsWritable: true},
+				{PublicKey: destination, IsWritable: true},
+				{PublicKey: t.Owner, IsSigner: true},
+			},
+			Data: data,
+		}
+	}
+	instructions = append(instructions, transfer)
+	if t.Memo != "" {
+		memo, err := MemoInstruction(t.Memo)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, memo)
+	}
+
+	msg, err := CompileMessage(t.Owner, t.RecentBlockhash, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SPLTransferMessage{
+		Message:            msg,
+		SourceAccount:      source,
+		DestinationAccount: destination,
+	}, nil
+}
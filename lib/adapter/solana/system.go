@@ -0,0 +1,61 @@
+package solana
+
+import (
+	"encoding/binary"
+)
+
+// system program instruction discriminators, little endian u32
+const (
+	systemInstructionAdvanceNonceAccount = 4
+	systemInstructionTransfer            = 2
+)
+
+// BuildSOLTransfer compiles a message transferring lamports from the owner to the recipient
+// with the system program, followed by an instruction of the memo program when memo is set. The
+// owner pays the fee.
+func BuildSOLTransfer(owner, recipient PublicKey, lamports uint64, recentBlockhash PublicKey,
+	memo string) ([]byte, error) {
+	if lamports == 0 {
+		return nil, ErrInvalidAmount
+	}
+	systemProgram, err := PublicKeyFromBase58(SystemProgramID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, systemInstructionTransfer)
+	data = binary.LittleEndian.AppendUint64(data, lamports)
+	instructions := []Instruction{{
+		ProgramID: systemProgram,
+		Accounts: []AccountMeta{
+			{PublicKey: owner, IsSigner: true, IsWritable: true},
+			{PublicKey: recipient, IsWritable: true},
+		},
+		Data: data,
+	}}
+	if memo != "" {
+		instruction, err := MemoInstruction(memo)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, instruction)
+	}
+	return CompileMessage(owner, recentBlockhash, instructions)
+}
+
+// UsesDurableNonce reports whether the legacy or v0 message msg starts with the AdvanceNonceAccount
+// instruction of the system program, so its recent blockhash is the value of a nonce account that
+// does not expire
+func UsesDurableNonce(msg []byte) bool {
+	systemProgram, err := PublicKeyFromBase58(SystemProgramID)
+	if err != nil {
+		return false
+	}
+	instructions, ok := programInstructions(msg)
+	if !ok || len(instructions) == 0 {
+		return false
+	}
+	first := instructions[0]
+	return first.programID == systemProgram && len(first.data) >= 4 &&
+		binary.LittleEndian.Uint32(first.data) == systemInstructionAdvanceNonceAccount
+}
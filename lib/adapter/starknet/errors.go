@@ -0,0 +1,15 @@
+package starknet
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData     = errors.New("invalid payload data")
+	ErrInvalidFelt            = errors.New("value is not a valid field element")
+	ErrInvalidShortString     = errors.New("short string must be at most 31 ASCII characters")
+	ErrUnsupportedTxType      = errors.New("unsupported transaction type, expected invoke or deploy_account")
+	ErrMissingCalls           = errors.New("invoke transaction requires at least one call")
+	ErrResourceBoundRange     = errors.New("resource bound out of range")
+	ErrInvalidDataAvailablity = errors.New("data availability mode must be 0 (L1) or 1 (L2)")
+	ErrMessageHashTooLarge    = errors.New("message hash must be lower than 2^251")
+)
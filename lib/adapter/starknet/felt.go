@@ -0,0 +1,77 @@
+package starknet
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+)
+
+const (
+	// maxShortStringLength is the number of ASCII characters a felt can hold
+	maxShortStringLength = 31
+	// hexPrefix marks hexadecimal felts
+	hexPrefix = "0x"
+)
+
+// ParseFelt parses a 0x prefixed hexadecimal or a decimal field element and
+// rejects values outside of the field instead of reducing them
+func ParseFelt(s string) (*fp.Element, error) {
+	s = strings.TrimSpace(s)
+	v, ok := new(big.Int), false
+	if strings.HasPrefix(s, hexPrefix) {
+		v, ok = v.SetString(s[len(hexPrefix):], 16)
+	} else {
+		v, ok = v.SetString(s, 10)
+	}
+	if !ok || v.Sign() < 0 || v.Cmp(fp.Modulus()) >= 0 {
+		return nil, ErrInvalidFelt
+	}
+	return new(fp.Element).SetBigInt(v), nil
+}
+
+// parseFelts parses a list of field elements
+func parseFelts(values []string) ([]*fp.Element, error) {
+	out := make([]*fp.Element, 0, len(values))
+	for _, v := range values {
+		felt, err := ParseFelt(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, felt)
+	}
+	return out, nil
+}
+
+// ShortString encodes up to 31 ASCII characters as a felt (Cairo short string)
+func ShortString(s string) (*fp.Element, error) {
+	if len(s) > maxShortStringLength {
+		return nil, ErrInvalidShortString
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] > 0x7f {
+			return nil, ErrInvalidShortString
+		}
+	}
+	return new(fp.Element).SetBytes([]byte(s)), nil
+}
+
+// mustShortString encodes a constant short string
+func mustShortString(s string) *fp.Element {
+	felt, err := ShortString(s)
+	if err != nil {
+		panic(err)
+	}
+	return felt
+}
+
+// FeltHex returns the 0x prefixed, 64 digit hexadecimal form of a felt
+func FeltHex(felt *fp.Element) string {
+	b := felt.Bytes()
+	return hexPrefix + hex.EncodeToString(b[:])
+}
+
+func uintFelt(v uint64) *fp.Element {
+	return new(fp.Element).SetUint64(v)
+}
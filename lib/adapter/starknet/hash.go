@@ -0,0 +1,153 @@
+package starknet
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+	pedersenhash "github.com/consensys/gnark-crypto/ecc/stark-curve/pedersen-hash"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// selectorBits is the width of a starknet_keccak entry point selector
+	selectorBits = 250
+	// transactionVersion3 is the only transaction version accepted by the sequencer
+	transactionVersion3 = 3
+	// resourceNameShift is the bit offset of the resource name in a resource bound
+	resourceNameShift = 192
+	// resourceAmountShift is the bit offset of the max amount in a resource bound
+	resourceAmountShift = 128
+	// resourcePriceBits is the width of the max price per unit in a resource bound
+	resourcePriceBits = 128
+	// dataAvailabilityModeBits is the offset of the nonce data availability mode
+	dataAvailabilityModeBits = 32
+	// addressBoundOffset is subtracted from 2^251 to get the contract address bound
+	addressBoundOffset = 256
+	// addressBoundBits is the bit length of the contract address bound
+	addressBoundBits = 251
+)
+
+// Call is one contract invocation of a multicall
+type Call struct {
+	To       *fp.Element
+	Selector *fp.Element
+	Calldata []*fp.Element
+}
+
+// ResourceBounds caps the amount and the unit price of one fee resource
+type ResourceBounds struct {
+	MaxAmount       uint64
+	MaxPricePerUnit *big.Int
+}
+
+// TransactionV3 holds the fields shared by v3 transactions
+type TransactionV3 struct {
+	ChainID                   *fp.Element
+	Nonce                     *fp.Element
+	Tip                       uint64
+	L1Gas                     ResourceBounds
+	L2Gas                     ResourceBounds
+	L1DataGas                 ResourceBounds
+	PaymasterData             []*fp.Element
+	NonceDataAvailabilityMode uint32
+	FeeDataAvailabilityMode   uint32
+}
+
+// SelectorFromName returns the entry point selector, starknet_keccak(name)
+func SelectorFromName(name string) *fp.Element {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(name))
+	v := new(big.Int).SetBytes(h.Sum(nil))
+	v.And(v, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), selectorBits), big.NewInt(1)))
+	return new(fp.Element).SetBigInt(v)
+}
+
+// PedersenArray hashes a sequence of felts followed by its length
+func PedersenArray(elems ...*fp.Element) *fp.Element {
+	h := pedersenhash.PedersenArray(elems...)
+	return &h
+}
+
+// ExecuteCalldata encodes calls as the __execute__ calldata of Cairo 1 accounts
+func ExecuteCalldata(calls []Call) []*fp.Element {
+	out := []*fp.Element{uintFelt(uint64(len(calls)))}
+	for _, call := range calls {
+		out = append(out, call.To, call.Selector, uintFelt(uint64(len(call.Calldata))))
+		out = append(out, call.Calldata...)
+	}
+	return out
+}
+
+// ContractAddress computes the address of a contract deployed by deployer with
+// the given salt, class hash and constructor calldata
+func ContractAddress(deployer, salt, classHash *fp.Element, constructorCalldata []*fp.Element) *fp.Element {
+	h := PedersenArray(
+		mustShortString("STARKNET_CONTRACT_ADDRESS"),
+		deployer,
+		salt,
+		classHash,
+		PedersenArray(constructorCalldata...),
+	)
+
+	bound := new(big.Int).Lsh(big.NewInt(1), addressBoundBits)
+	bound.Sub(bound, big.NewInt(addressBoundOffset))
+	v := h.BigInt(new(big.Int))
+	return new(fp.Element).SetBigInt(v.Mod(v, bound))
+}
+
+// InvokeHash returns the hash of an INVOKE v3 transaction
+func (t TransactionV3) InvokeHash(sender *fp.Element, calldata, accountDeploymentData []*fp.Element) *fp.Element {
+	return PoseidonArray(
+		mustShortString("invoke"),
+		uintFelt(transactionVersion3),
+		sender,
+		t.tipAndResourcesHash(),
+		PoseidonArray(t.PaymasterData...),
+		t.ChainID,
+		t.Nonce,
+		t.dataAvailabilityModes(),
+		PoseidonArray(accountDeploymentData...),
+		PoseidonArray(calldata...),
+	)
+}
+
+// DeployAccountHash returns the hash of a DEPLOY_ACCOUNT v3 transaction
+func (t TransactionV3) DeployAccountHash(address, classHash, salt *fp.Element, constructorCalldata []*fp.Element) *fp.Element {
+	return PoseidonArray(
+		mustShortString("deploy_account"),
+		uintFelt(transactionVersion3),
+		address,
+		t.tipAndResourcesHash(),
+		PoseidonArray(t.PaymasterData...),
+		t.ChainID,
+		t.Nonce,
+		t.dataAvailabilityModes(),
+		PoseidonArray(constructorCalldata...),
+		classHash,
+		salt,
+	)
+}
+
+func (t TransactionV3) tipAndResourcesHash() *fp.Element {
+	return PoseidonArray(
+		uintFelt(t.Tip),
+		t.L1Gas.encode("L1_GAS"),
+		t.L2Gas.encode("L2_GAS"),
+		t.L1DataGas.encode("L1_DATA"),
+	)
+}
+
+func (t TransactionV3) dataAvailabilityModes() *fp.Element {
+	return uintFelt(uint64(t.NonceDataAvailabilityMode)<<dataAvailabilityModeBits | uint64(t.FeeDataAvailabilityMode))
+}
+
+// encode packs the resource name, max amount and max price per unit into one felt
+func (r ResourceBounds) encode(resource string) *fp.Element {
+	name := mustShortString(resource).BigInt(new(big.Int))
+	v := new(big.Int).Lsh(name, resourceNameShift)
+	v.Or(v, new(big.Int).Lsh(new(big.Int).SetUint64(r.MaxAmount), resourceAmountShift))
+	if r.MaxPricePerUnit != nil {
+		v.Or(v, r.MaxPricePerUnit)
+	}
+	return new(fp.Element).SetBigInt(v)
+}
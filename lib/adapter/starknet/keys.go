@@ -0,0 +1,157 @@
+package starknet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	starkcurve "github.com/consensys/gnark-crypto/ecc/stark-curve"
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fr"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// eip2645Purpose is the purpose component of EIP-2645 paths
+	eip2645Purpose = 2645
+	// eip2645Layer is sha256("starkex") masked to 31 bits
+	eip2645Layer = 579218131
+	// eip2645Application is sha256("starkdeployement") masked to 31 bits
+	eip2645Application = 891216374
+	// eip2645ComponentBits is the width of each Ethereum address component
+	eip2645ComponentBits = 31
+	// ecdsaElementBits bounds r, s^-1 and the signed message hash
+	ecdsaElementBits = 251
+	// keyLength is the length of private keys and hashes in bytes
+	keyLength = 32
+)
+
+// EIP2645Path returns the EIP-2645 derivation path bound to an Ethereum address:
+// m/2645'/layer'/application'/eth_address_1'/eth_address_2'/index
+func EIP2645Path(address common.Address, index uint32) string {
+	a := new(big.Int).SetBytes(address.Bytes())
+	mask := big.NewInt(1<<eip2645ComponentBits - 1)
+	low := new(big.Int).And(a, mask)
+	high := new(big.Int).And(new(big.Int).Rsh(a, eip2645ComponentBits), mask)
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d'/%d'/%d",
+		eip2645Purpose, eip2645Layer, eip2645Application, low.Uint64(), high.Uint64(), index)
+}
+
+// GrindKey turns a secp256k1 private key into a stark curve private key without
+// modulo bias, hashing the key with an increasing index until the digest falls
+// below the largest multiple of the curve order
+func GrindKey(keySeed []byte) *big.Int {
+	order := fr.Modulus()
+	limit := new(big.Int).Lsh(big.NewInt(1), keyLength*8)
+	limit.Sub(limit, new(big.Int).Mod(limit, order))
+
+	for index := int64(0); ; index++ {
+		indexBytes := big.NewInt(index).Bytes()
+		if len(indexBytes) == 0 {
+			indexBytes = []byte{0}
+		}
+		digest := sha256.Sum256(append(append([]byte{}, keySeed...), indexBytes...))
+		key := new(big.Int).SetBytes(digest[:])
+		if key.Cmp(limit) < 0 {
+			return key.Mod(key, order)
+		}
+	}
+}
+
+// PublicKey returns the x coordinate of the public key, the form StarkNet accounts store
+func PublicKey(privateKey *big.Int) *fp.Element {
+	var point starkcurve.G1Affine
+	point.ScalarMultiplicationBase(privateKey)
+	return new(fp.Element).Set(&point.X)
+}
+
+// Sign produces a stark curve ECDSA signature (r, s) of a message hash with a
+// deterministic RFC 6979 nonce
+func Sign(privateKey *big.Int, messageHash *fp.Element) (r, s *big.Int, err error) {
+	m := messageHash.BigInt(new(big.Int))
+	bound := new(big.Int).Lsh(big.NewInt(1), ecdsaElementBits)
+	if m.Cmp(bound) >= 0 {
+		return nil, nil, ErrMessageHashTooLarge
+	}
+
+	order := fr.Modulus()
+	nonces := newNonceGenerator(privateKey, m, order)
+	for {
+		k := nonces.next()
+
+		var point starkcurve.G1Affine
+		point.ScalarMultiplicationBase(k)
+		r = point.X.BigInt(new(big.Int))
+		if r.Sign() == 0 || r.Cmp(bound) >= 0 {
+			continue
+		}
+
+		// s = k^-1 * (m + r * d) mod n
+		s = new(big.Int).Mul(r, privateKey)
+		s.Add(s, m).Mod(s, order)
+		if s.Sign() == 0 {
+			continue
+		}
+		s.Mul(s, new(big.Int).ModInverse(k, order)).Mod(s, order)
+
+		// the verifier works with w = s^-1, which must be a valid ECDSA element
+		w := new(big.Int).ModInverse(s, order)
+		if w == nil || w.Cmp(bound) >= 0 {
+			continue
+		}
+		return r, s, nil
+	}
+}
+
+// nonceGenerator is the HMAC-SHA256 DRBG of RFC 6979 section 3.2
+type nonceGenerator struct {
+	k, v  []byte
+	order *big.Int
+}
+
+func newNonceGenerator(privateKey, hash, order *big.Int) *nonceGenerator {
+	x := privateKey.FillBytes(make([]byte, keyLength))
+	h := new(big.Int).Mod(bits2int(hash.FillBytes(make([]byte, keyLength)), order), order).
+		FillBytes(make([]byte, keyLength))
+
+	g := &nonceGenerator{k: make([]byte, sha256.Size), v: make([]byte, sha256.Size), order: order}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.k, g.v, []byte{0x00}, x, h)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, x, h)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+// next returns the next candidate nonce in [1, n-1]
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		g.v = g.mac(g.k, g.v)
+		k := bits2int(g.v, g.order)
+		g.k = g.mac(g.k, g.v, []byte{0x00})
+		g.v = g.mac(g.k, g.v)
+		if k.Sign() > 0 && k.Cmp(g.order) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *nonceGenerator) mac(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// bits2int keeps the leftmost qlen bits of b
+func bits2int(b []byte, order *big.Int) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - order.BitLen(); excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+	return v
+}
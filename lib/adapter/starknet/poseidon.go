@@ -0,0 +1,89 @@
+package starknet
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+)
+
+const (
+	// poseidonFullRounds is the number of full rounds of the Hades permutation
+	poseidonFullRounds = 8
+	// poseidonPartialRounds is the number of partial rounds of the Hades permutation
+	poseidonPartialRounds = 83
+	// poseidonRounds is the total number of rounds of the Hades permutation
+	poseidonRounds = poseidonFullRounds + poseidonPartialRounds
+	// poseidonWidth is the state width of the StarkNet Poseidon instance
+	poseidonWidth = 3
+	// roundKeySeed prefixes the index hashed into each round key
+	roundKeySeed = "Hades"
+)
+
+// Round keys are generated on first use
+var (
+	roundKeysOnce sync.Once                                 //nolint:gochecknoglobals // lazily generated constants
+	roundKeys     [poseidonRounds][poseidonWidth]fp.Element //nolint:gochecknoglobals // lazily generated constants
+)
+
+// setRoundKeys generates the round keys as sha256("Hades" || index) reduced modulo p,
+// the procedure used by the StarkWare reference implementation
+func setRoundKeys() {
+	for i := range roundKeys {
+		for j := range roundKeys[i] {
+			digest := sha256.Sum256([]byte(roundKeySeed + strconv.Itoa(i*poseidonWidth+j)))
+			roundKeys[i][j].SetBytes(digest[:])
+		}
+	}
+}
+
+// hadesPermutation applies the Hades permutation to the state in place
+func hadesPermutation(state *[poseidonWidth]fp.Element) {
+	roundKeysOnce.Do(setRoundKeys)
+
+	for i := 0; i < poseidonRounds; i++ {
+		full := i < poseidonFullRounds/2 || poseidonRounds-i <= poseidonFullRounds/2
+
+		for j := range state {
+			state[j].Add(&state[j], &roundKeys[i][j])
+		}
+
+		// x^3 s-box, applied to the whole state in full rounds and to the last word otherwise
+		var squared fp.Element
+		for j := range state {
+			if full || j == poseidonWidth-1 {
+				squared.Square(&state[j])
+				state[j].Mul(&state[j], &squared)
+			}
+		}
+
+		// MDS matrix ((3,1,1), (1,-1,1), (1,1,-2))
+		var sum, tmp fp.Element
+		sum.Add(&state[0], &state[1]).Add(&sum, &state[2])
+		state[0].Double(&state[0]).Add(&state[0], &sum)
+		state[1].Sub(&sum, tmp.Double(&state[1]))
+		state[2].Sub(&sum, tmp.Double(&state[2]).Add(&tmp, &state[2]))
+	}
+}
+
+// PoseidonArray hashes a sequence of felts, padding with 1 (odd length) or 1, 0 (even length)
+func PoseidonArray(elems ...*fp.Element) *fp.Element {
+	var state [poseidonWidth]fp.Element
+
+	for i := 0; i+1 < len(elems); i += 2 {
+		state[0].Add(&state[0], elems[i])
+		state[1].Add(&state[1], elems[i+1])
+		hadesPermutation(&state)
+	}
+
+	rem := len(elems) % 2
+	if rem == 1 {
+		state[0].Add(&state[0], elems[len(elems)-1])
+	}
+	one := fp.One()
+	state[rem].Add(&state[rem], &one)
+	hadesPermutation(&state)
+
+	return new(fp.Element).Set(&state[0])
+}
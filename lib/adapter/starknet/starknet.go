@@ -0,0 +1,361 @@
+package starknet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// OpenZeppelinAccountClassHash is the class hash of the OpenZeppelin account v0.8.1,
+	// whose constructor takes the stark public key
+	OpenZeppelinAccountClassHash = "0x061dac032f228abef9c6626f995015233097ae253a7f72d68552db02f2971b8f"
+	// TxTypeInvoke is an account INVOKE v3 transaction
+	TxTypeInvoke = "invoke"
+	// TxTypeDeployAccount deploys the OpenZeppelin account of the derived key
+	TxTypeDeployAccount = "deploy_account"
+	// eip2645Index is the address index used under the Ethereum bound EIP-2645 path
+	eip2645Index = 0
+)
+
+// Adapter represents a StarkNet adapter. The derivation path given to every
+// method is the Ethereum path; the stark key is derived from the EIP-2645 path
+// bound to the Ethereum address at that path.
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewStarknetAdapter creates a new StarkNet adapter instance
+func NewStarknetAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "starknet")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (a *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Starknet
+}
+
+// Capabilities describes the coins and formats handled by the StarkNet adapter
+func (a *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "starknet",
+		CoinTypes:      []uint16{slip44.Starknet},
+		Curve:          lib.CurveStark,
+		AddressFormats: []string{"0x felt, OpenZeppelin account counterfactual address"},
+		Payload:        lib.StarknetRawTx{},
+		Operations:     lib.DefaultOperations(),
+	}
+}
+
+// DerivePrivateKey derives the stark curve private key
+func (a *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := FeltHex(new(fp.Element).SetBigInt(privateKey))
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives the stark public key (x coordinate)
+func (a *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := FeltHex(PublicKey(privateKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the counterfactual address of the OpenZeppelin account
+// of the stark key, deployed with the public key as salt
+func (a *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := a.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	address := FeltHex(accountAddress(PublicKey(privateKey)))
+	logger.Info("Address derived successfully", "address", address)
+
+	return address, nil
+}
+
+// ValidatePayload reports the field errors of a StarkNet sign payload
+func (a *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	feltField(v, "nonce", true)
+	if chainID, ok := v.String("chainId", true); ok {
+		if strings.HasPrefix(chainID, hexPrefix) {
+			feltField(v, "chainId", true)
+		} else if _, err := ShortString(chainID); err != nil {
+			v.Fail("chainId", err.Error())
+		}
+	}
+	v.Uint("tip", 64, false)
+	feltsField(v, "paymasterData")
+	for _, field := range []string{"nonceDataAvailabilityMode", "feeDataAvailabilityMode"} {
+		if mode, ok := v.Uint(field, 32, false); ok && mode > 1 {
+			v.Fail(field, ErrInvalidDataAvailablity.Error())
+		}
+	}
+
+	bounds := v.Object("resourceBounds", false)
+	for _, resource := range []string{"l1Gas", "l2Gas", "l1DataGas"} {
+		bound := bounds.Object(resource, false)
+		boundedField(bound, "maxAmount", 64)
+		boundedField(bound, "maxPricePerUnit", resourcePriceBits)
+	}
+
+	txType, _ := v.String("type", true)
+	switch txType {
+	case TxTypeInvoke:
+		feltField(v, "senderAddress", true)
+		feltsField(v, "accountDeploymentData")
+		for _, call := range v.Objects("calls", true) {
+			feltField(call, "to", true)
+			call.String("entrypoint", true)
+			feltsField(call, "calldata")
+		}
+	case TxTypeDeployAccount, "":
+	default:
+		v.Fail("type", ErrUnsupportedTxType.Error())
+	}
+	return v.Err()
+}
+
+// feltField checks that field holds a field element
+func feltField(v *lib.PayloadValidator, field string, required bool) {
+	if s, ok := v.String(field, required); ok {
+		if _, err := ParseFelt(s); err != nil {
+			v.Fail(field, err.Error())
+		}
+	}
+}
+
+// boundedField checks that field holds an optional felt fitting in bits bits
+func boundedField(v *lib.PayloadValidator, field string, bits int) {
+	if s, ok := v.String(field, false); ok {
+		if _, err := parseBoundedInt(s, bits); err != nil {
+			v.Fail(field, err.Error())
+		}
+	}
+}
+
+// feltsField checks that field holds a list of field elements
+func feltsField(v *lib.PayloadValidator, field string) {
+	values, _ := v.Strings(field, false)
+	for i, s := range values {
+		if _, err := ParseFelt(s); err != nil {
+			v.Fail(fmt.Sprintf("%s[%d]", field, i), err.Error())
+		}
+	}
+}
+
+// CreateSignedTransaction computes the v3 transaction hash of the payload and
+// returns the hex encoded signature r || s (two 32 byte felts)
+func (a *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := a.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.StarknetRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	privateKey, err := derivePrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	txHash, err := TransactionHash(rawTx, PublicKey(privateKey))
+	if err != nil {
+		logger.Error("Failed to compute transaction hash", "error", err)
+		return "", err
+	}
+
+	r, s, err := Sign(privateKey, txHash)
+	if err != nil {
+		logger.Error("Failed to sign transaction hash", "error", err)
+		return "", err
+	}
+
+	signature := append(r.FillBytes(make([]byte, keyLength)), s.FillBytes(make([]byte, keyLength))...)
+	signatureHex := hex.EncodeToString(signature)
+	logger.Info("Signed transaction created successfully", "txHash", FeltHex(txHash), "signature", signatureHex)
+
+	return signatureHex, nil
+}
+
+// TransactionHash computes the hash of an invoke or deploy_account v3 transaction.
+// publicKey is the stark key that deploys its account in deploy_account transactions.
+func TransactionHash(rawTx lib.StarknetRawTx, publicKey *fp.Element) (*fp.Element, error) {
+	tx, err := parseTransactionV3(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rawTx.Type {
+	case TxTypeInvoke:
+		if len(rawTx.Calls) == 0 {
+			return nil, ErrMissingCalls
+		}
+		sender, err := ParseFelt(rawTx.SenderAddress)
+		if err != nil {
+			return nil, fmt.Errorf("senderAddress: %w", err)
+		}
+		calls := make([]Call, 0, len(rawTx.Calls))
+		for _, c := range rawTx.Calls {
+			to, err := ParseFelt(c.To)
+			if err != nil {
+				return nil, fmt.Errorf("call to: %w", err)
+			}
+			calldata, err := parseFelts(c.Calldata)
+			if err != nil {
+				return nil, fmt.Errorf("call calldata: %w", err)
+			}
+			calls = append(calls, Call{To: to, Selector: SelectorFromName(c.Entrypoint), Calldata: calldata})
+		}
+		accountDeploymentData, err := parseFelts(rawTx.AccountDeploymentData)
+		if err != nil {
+			return nil, fmt.Errorf("accountDeploymentData: %w", err)
+		}
+		return tx.InvokeHash(sender, ExecuteCalldata(calls), accountDeploymentData), nil
+
+	case TxTypeDeployAccount:
+		classHash, _ := ParseFelt(OpenZeppelinAccountClassHash)
+		calldata := []*fp.Element{publicKey}
+		return tx.DeployAccountHash(accountAddress(publicKey), classHash, publicKey, calldata), nil
+
+	default:
+		return nil, ErrUnsupportedTxType
+	}
+}
+
+func parseTransactionV3(rawTx lib.StarknetRawTx) (TransactionV3, error) {
+	var tx TransactionV3
+	var err error
+
+	if strings.HasPrefix(rawTx.ChainID, hexPrefix) {
+		tx.ChainID, err = ParseFelt(rawTx.ChainID)
+	} else {
+		tx.ChainID, err = ShortString(rawTx.ChainID)
+	}
+	if err != nil || rawTx.ChainID == "" {
+		return tx, fmt.Errorf("chainId: %w", ErrInvalidFelt)
+	}
+	if tx.Nonce, err = ParseFelt(rawTx.Nonce); err != nil {
+		return tx, fmt.Errorf("nonce: %w", err)
+	}
+	if tx.PaymasterData, err = parseFelts(rawTx.PaymasterData); err != nil {
+		return tx, fmt.Errorf("paymasterData: %w", err)
+	}
+	if rawTx.NonceDataAvailabilityMode > 1 || rawTx.FeeDataAvailabilityMode > 1 {
+		return tx, ErrInvalidDataAvailablity
+	}
+	tx.NonceDataAvailabilityMode = rawTx.NonceDataAvailabilityMode
+	tx.FeeDataAvailabilityMode = rawTx.FeeDataAvailabilityMode
+	tx.Tip = rawTx.Tip
+
+	bounds := []struct {
+		raw lib.StarknetResourceBounds
+		out *ResourceBounds
+	}{
+		{rawTx.ResourceBounds.L1Gas, &tx.L1Gas},
+		{rawTx.ResourceBounds.L2Gas, &tx.L2Gas},
+		{rawTx.ResourceBounds.L1DataGas, &tx.L1DataGas},
+	}
+	for _, b := range bounds {
+		if *b.out, err = parseResourceBounds(b.raw); err != nil {
+			return tx, err
+		}
+	}
+	return tx, nil
+}
+
+func parseResourceBounds(raw lib.StarknetResourceBounds) (ResourceBounds, error) {
+	var bounds ResourceBounds
+	amount, err := parseBoundedInt(raw.MaxAmount, 64)
+	if err != nil {
+		return bounds, err
+	}
+	price, err := parseBoundedInt(raw.MaxPricePerUnit, resourcePriceBits)
+	if err != nil {
+		return bounds, err
+	}
+	bounds.MaxAmount = amount.Uint64()
+	bounds.MaxPricePerUnit = price
+	return bounds, nil
+}
+
+// parseBoundedInt parses an optional felt that must fit in bits bits
+func parseBoundedInt(s string, bits int) (*big.Int, error) {
+	if s == "" {
+		return new(big.Int), nil
+	}
+	felt, err := ParseFelt(s)
+	if err != nil {
+		return nil, ErrResourceBoundRange
+	}
+	v := felt.BigInt(new(big.Int))
+	if v.BitLen() > bits {
+		return nil, ErrResourceBoundRange
+	}
+	return v, nil
+}
+
+// accountAddress returns the OpenZeppelin account address of a stark public key
+func accountAddress(publicKey *fp.Element) *fp.Element {
+	classHash, _ := ParseFelt(OpenZeppelinAccountClassHash)
+	return ContractAddress(new(fp.Element), publicKey, classHash, []*fp.Element{publicKey})
+}
+
+// derivePrivateKey derives the Ethereum address at the given path, then grinds the
+// secp256k1 key at the EIP-2645 path bound to that address into a stark key
+func derivePrivateKey(seed []byte, derivationPath string) (*big.Int, error) {
+	ethKey, err := lib.DerivePrivateKey(seed, derivationPath, false)
+	if err != nil {
+		return nil, err
+	}
+	address := crypto.PubkeyToAddress(ethKey.ToECDSA().PublicKey)
+
+	starkPathKey, err := lib.DerivePrivateKey(seed, EIP2645Path(address, eip2645Index), false)
+	if err != nil {
+		return nil, err
+	}
+	return GrindKey(starkPathKey.Serialize()), nil
+}
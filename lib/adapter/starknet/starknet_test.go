@@ -0,0 +1,209 @@
+package starknet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"testing"
+
+	starkcurve "github.com/consensys/gnark-crypto/ecc/stark-curve"
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fp"
+	"github.com/consensys/gnark-crypto/ecc/stark-curve/fr"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	testMnemonic       = "shoot island position soft burden budget tooth cruel issue economy destroy above"
+	testDerivationPath = "m/44'/60'/0'/0/0"
+	// expected values computed with starknet.go
+	expectedPublicKey  = "0x0052f94450ccadb8ba0a35e721846974e55627affd4ea6a7a6af69f20cd3a0ed"
+	expectedAddress    = "0x01258dab1a219af32b4b4f64c77d427313014857d69b647f29aa66fc2919fedd"
+	expectedInvokeHash = "0x03ea5716b28ec2aac6e4962f4f3581ed16497d0a37cf84a383932cc3f1b48d7d"
+	expectedDeployHash = "0x078e712ca94c58c5bf04577b70398bba084b8e93517240c12c68317d5bca53b0"
+	testResourceBounds = `"resourceBounds":{"l1Gas":{"maxAmount":"0x100","maxPricePerUnit":"0x1234567890"},` +
+		`"l2Gas":{"maxAmount":"1000000","maxPricePerUnit":"0x5"},"l1DataGas":{"maxAmount":"0x80","maxPricePerUnit":"0x9"}}`
+)
+
+func newTestAdapter() *Adapter {
+	return NewStarknetAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := lib.SeedFromMnemonic(testMnemonic, "")
+	require.NoError(t, err)
+	return seed
+}
+
+func invokePayload(sender string) string {
+	return `{"type":"invoke","senderAddress":"` + sender + `","nonce":"0x5","chainId":"SN_SEPOLIA","tip":7,
+	"calls":[
+		{"to":"0x049d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7","entrypoint":"transfer","calldata":["0x1234","0x64","0x0"]},
+		{"to":"0x04718f5a0fc34cc1af16a1cdee98ffb20c31f5cd61d6ab07201858f4287c938d","entrypoint":"approve","calldata":["0x99","1000","0"]}
+	],` + testResourceBounds + `,"nonceDataAvailabilityMode":0,"feeDataAvailabilityMode":1}`
+}
+
+func TestStarknetAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Starknet))
+	assert.False(t, adapter.CanDo(slip44.Ether))
+}
+
+func TestGrindKey_StarkExVector(t *testing.T) {
+	// key derivation vector of the StarkEx crypto utils
+	mnemonic := "range mountain blast problem vibrant void vivid doctor cluster enough melody salt layer language " +
+		"laptop boat major space monkey unit glimpse pause change vibrant"
+	seed, err := lib.SeedFromMnemonic(mnemonic, "")
+	require.NoError(t, err)
+
+	path := EIP2645Path(common.HexToAddress("0xa4864d977b944315389d1765ffa7e66F74ee8cd7"), 0)
+	assert.Equal(t, "m/2645'/579218131'/891216374'/1961790679'/2135936222'/0", path)
+
+	key, err := lib.DerivePrivateKey(seed, path, false)
+	require.NoError(t, err)
+	assert.Equal(t, "6cf0a8bf113352eb863157a45c5e5567abb34f8d32cddafd2c22aa803f4892c",
+		fmt.Sprintf("%x", GrindKey(key.Serialize())))
+}
+
+func TestHashes(t *testing.T) {
+	selector := SelectorFromName("transfer")
+	assert.Equal(t, "0x0083afd3f4caedc6eebf44246fe54e38c95e3179a5ec9ea81740eca5b482d12e", FeltHex(selector))
+
+	poseidon := PoseidonArray(uintFelt(1), uintFelt(2), uintFelt(3))
+	assert.Equal(t, "0x02f0d8840bcf3bc629598d8a6cc80cb7c0d9e52d93dab244bbf9cd0dca0ad082", FeltHex(poseidon))
+
+	chainID, err := ShortString("SN_MAIN")
+	require.NoError(t, err)
+	assert.Equal(t, "0x00000000000000000000000000000000000000000000000000534e5f4d41494e", FeltHex(chainID))
+
+	_, err = ParseFelt("0x0800000000000011000000000000000000000000000000000000000000000001")
+	assert.ErrorIs(t, err, ErrInvalidFelt)
+}
+
+func TestStarknetAdapter_DeriveAddress(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+
+	publicKey, err := adapter.DerivePublicKey(seed, testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedPublicKey, publicKey)
+
+	address, err := adapter.DeriveAddress(seed, testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, address)
+}
+
+func TestStarknetAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+	publicKey, err := ParseFelt(expectedPublicKey)
+	require.NoError(t, err)
+
+	t.Run("invoke v3", func(t *testing.T) {
+		payload := invokePayload(expectedAddress)
+
+		var rawTx lib.StarknetRawTx
+		require.NoError(t, json.Unmarshal([]byte(payload), &rawTx))
+		txHash, err := TransactionHash(rawTx, publicKey)
+		require.NoError(t, err)
+		assert.Equal(t, expectedInvokeHash, FeltHex(txHash))
+
+		signatureHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, payload)
+		require.NoError(t, err)
+		assert.True(t, verify(t, txHash, signatureHex, seed))
+
+		again, err := adapter.CreateSignedTransaction(seed, testDerivationPath, payload)
+		require.NoError(t, err)
+		assert.Equal(t, signatureHex, again, "signatures use deterministic nonces")
+	})
+
+	t.Run("deploy account v3", func(t *testing.T) {
+		payload := `{"type":"deploy_account","nonce":"0","chainId":"SN_MAIN",` +
+			`"resourceBounds":{"l1Gas":{"maxAmount":"0x100","maxPricePerUnit":"0x1234567890"},` +
+			`"l1DataGas":{"maxAmount":"0x80","maxPricePerUnit":"0x9"}}}`
+
+		var rawTx lib.StarknetRawTx
+		require.NoError(t, json.Unmarshal([]byte(payload), &rawTx))
+		txHash, err := TransactionHash(rawTx, publicKey)
+		require.NoError(t, err)
+		assert.Equal(t, expectedDeployHash, FeltHex(txHash))
+
+		signatureHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, payload)
+		require.NoError(t, err)
+		assert.True(t, verify(t, txHash, signatureHex, seed))
+	})
+
+	t.Run("rejects invalid payload", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, testDerivationPath, `{"type":`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"type":"declare","nonce":"1","chainId":"SN_MAIN"}`)
+		assert.ErrorIs(t, err, ErrUnsupportedTxType)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"type":"invoke","nonce":"1","chainId":"SN_MAIN"}`)
+		assert.ErrorIs(t, err, ErrMissingCalls)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath,
+			`{"type":"invoke","nonce":"1","chainId":"SN_MAIN","resourceBounds":{"l1Gas":{"maxAmount":"0x10000000000000000"}}}`)
+		assert.ErrorIs(t, err, ErrResourceBoundRange)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, invokePayload("0xzz"))
+		assert.ErrorIs(t, err, ErrInvalidFelt)
+	})
+}
+
+// verify checks an r || s signature against the full public key point
+func verify(t *testing.T, messageHash *fp.Element, signatureHex string, seed []byte) bool {
+	signature, err := hex.DecodeString(signatureHex)
+	require.NoError(t, err)
+	require.Len(t, signature, 2*keyLength)
+	r, s := new(big.Int).SetBytes(signature[:keyLength]), new(big.Int).SetBytes(signature[keyLength:])
+
+	privateKey, err := derivePrivateKey(seed, testDerivationPath)
+	require.NoError(t, err)
+	var publicKey starkcurve.G1Affine
+	publicKey.ScalarMultiplicationBase(privateKey)
+
+	order := fr.Modulus()
+	w := new(big.Int).ModInverse(s, order)
+	u1 := new(big.Int).Mul(messageHash.BigInt(new(big.Int)), w)
+	u2 := new(big.Int).Mul(r, w)
+
+	var p1, p2 starkcurve.G1Affine
+	p1.ScalarMultiplicationBase(u1.Mod(u1, order))
+	p2.ScalarMultiplication(&publicKey, u2.Mod(u2, order))
+	p1.Add(&p1, &p2)
+	return p1.X.BigInt(new(big.Int)).Cmp(r) == 0
+}
+
+func TestStarknetAdapter_ValidatePayload(t *testing.T) {
+	adapter := newTestAdapter()
+
+	require.NoError(t, adapter.ValidatePayload(invokePayload("0x1")))
+	require.NoError(t, adapter.ValidatePayload(`{"type":"deploy_account","nonce":"0","chainId":"SN_MAIN"}`))
+
+	err := adapter.ValidatePayload(`{"type":"invoke","nonce":"0xzz","chainId":"SN_SEPOLIA",` +
+		`"calls":[{"to":"0x1","calldata":["0x1","bad"]}],` +
+		`"resourceBounds":{"l2Gas":{"maxAmount":"0x10000000000000000"}},"feeDataAvailabilityMode":2}`)
+	var fieldErrs lib.PayloadErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, lib.PayloadErrors{
+		{Field: "nonce", Message: ErrInvalidFelt.Error()},
+		{Field: "feeDataAvailabilityMode", Message: ErrInvalidDataAvailablity.Error()},
+		{Field: "resourceBounds.l2Gas.maxAmount", Message: ErrResourceBoundRange.Error()},
+		{Field: "senderAddress", Message: "missing"},
+		{Field: "calls[0].entrypoint", Message: "missing"},
+		{Field: "calls[0].calldata[1]", Message: ErrInvalidFelt.Error()},
+	}, fieldErrs)
+
+	err = adapter.ValidatePayload(`{"type":"declare","nonce":"0","chainId":"SN_MAIN"}`)
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, lib.PayloadErrors{{Field: "type", Message: ErrUnsupportedTxType.Error()}}, fieldErrs)
+}
@@ -0,0 +1,9 @@
+package sui
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData = errors.New("invalid payload data")
+	ErrInvalidRawData     = errors.New("invalid BCS transaction data")
+)
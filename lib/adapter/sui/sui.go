@@ -0,0 +1,156 @@
+package sui
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+	// ed25519Flag is the signature scheme flag for ed25519 keys
+	ed25519Flag = 0x00
+)
+
+// transactionIntent is the intent prefix (scope TransactionData, version V0, app Sui)
+func transactionIntent() []byte {
+	return []byte{0x00, 0x00, 0x00}
+}
+
+// Adapter represents a Sui blockchain adapter
+type Adapter struct {
+	logger *slog.Logger
+}
+
+// NewSuiAdapter creates a new Sui adapter instance
+func NewSuiAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger: logger.With(slog.String("adapter", "sui")),
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (s *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Sui
+}
+
+// Capabilities describes the coins and formats handled by the Sui adapter
+func (s *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "sui",
+		CoinTypes:      []uint16{slip44.Sui},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"0x prefixed 32 byte hex"},
+		Payload:        lib.BCSRawTx{},
+		Operations:     lib.DefaultOperations(),
+	}
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (s *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives a public key from the given seed and derivation path
+func (s *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the address, blake2b-256(flag || public key), from the given seed and derivation path
+func (s *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := s.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	address := blake2b.Sum256(append([]byte{ed25519Flag}, publicKey...))
+
+	addressHex := "0x" + hex.EncodeToString(address[:])
+	logger.Info("Address derived successfully", "address", addressHex)
+
+	return addressHex, nil
+}
+
+// ValidatePayload reports the field errors of a Sui sign payload
+func (s *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	if txBytes, ok := v.Hex("txBytes", true); ok && len(txBytes) == 0 {
+		v.Fail("txBytes", "empty")
+	}
+	return v.Err()
+}
+
+// CreateSignedTransaction signs the intent message of the BCS serialized TransactionData in the
+// payload and returns the hex encoded serialized signature (flag || signature || public key).
+func (s *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := s.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.BCSRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil || rawTx.TxBytes == "" {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	txBytes, err := hex.DecodeString(strings.TrimPrefix(rawTx.TxBytes, "0x"))
+	if err != nil || len(txBytes) == 0 {
+		return "", ErrInvalidRawData
+	}
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	digest := blake2b.Sum256(append(transactionIntent(), txBytes...))
+	signature := ed25519.Sign(privateKey, digest[:])
+
+	serialized := make([]byte, 0, 1+ed25519.SignatureSize+ed25519.PublicKeySize)
+	serialized = append(serialized, ed25519Flag)
+	serialized = append(serialized, signature...)
+	serialized = append(serialized, privateKey.Public().(ed25519.PublicKey)...)
+
+	signatureHex := hex.EncodeToString(serialized)
+	logger.Info("Signed transaction created successfully", "signature", signatureHex)
+
+	return signatureHex, nil
+}
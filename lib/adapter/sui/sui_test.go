@@ -0,0 +1,82 @@
+package sui
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Test vector from the Sui TypeScript SDK ed25519 keypair tests
+const (
+	testMnemonic = "film crazy soon outside stand loop subway crumble thrive popular green nuclear " +
+		"struggle pistol arm wife phrase warfare march wheat nephew ask sunny firm"
+	testDerivationPath = "m/44'/784'/0'/0'/0'"
+	expectedAddress    = "0xa2d14fad60c56049ecf75246a481934691214ce413e6a8ae2fe6834c173a6133"
+)
+
+func newTestAdapter() *Adapter {
+	return NewSuiAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func testSeed(t *testing.T) []byte {
+	seed, err := lib.SeedFromMnemonic(testMnemonic, "")
+	require.NoError(t, err)
+	return seed
+}
+
+func TestSuiAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Sui))
+	assert.False(t, adapter.CanDo(slip44.Aptos))
+	assert.False(t, adapter.CanDo(slip44.Solana))
+}
+
+func TestSuiAdapter_DeriveAddress(t *testing.T) {
+	adapter := newTestAdapter()
+
+	address, err := adapter.DeriveAddress(testSeed(t), testDerivationPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedAddress, address)
+}
+
+func TestSuiAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed := testSeed(t)
+	txBytes := []byte{0x00, 0x00, 0x02, 0x01, 0x00}
+
+	t.Run("signs intent message", func(t *testing.T) {
+		payload, err := json.Marshal(lib.BCSRawTx{TxBytes: hex.EncodeToString(txBytes)})
+		require.NoError(t, err)
+
+		sigHex, err := adapter.CreateSignedTransaction(seed, testDerivationPath, string(payload))
+		require.NoError(t, err)
+
+		serialized, err := hex.DecodeString(sigHex)
+		require.NoError(t, err)
+		require.Len(t, serialized, 1+ed25519.SignatureSize+ed25519.PublicKeySize)
+		assert.Equal(t, byte(ed25519Flag), serialized[0])
+
+		signature := serialized[1 : 1+ed25519.SignatureSize]
+		publicKey := ed25519.PublicKey(serialized[1+ed25519.SignatureSize:])
+		digest := blake2b.Sum256(append(transactionIntent(), txBytes...))
+		assert.True(t, ed25519.Verify(publicKey, digest[:], signature))
+	})
+
+	t.Run("rejects invalid payload", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, testDerivationPath, `{"invalid":"json"}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, testDerivationPath, `{"txBytes":"xyz"}`)
+		assert.ErrorIs(t, err, ErrInvalidRawData)
+	})
+}
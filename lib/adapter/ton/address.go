@@ -0,0 +1,95 @@
+package ton
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+const (
+	// friendlyAddressLength is the decoded length of a user-friendly address
+	friendlyAddressLength = 36
+	// tagBounceable marks a user-friendly address as bounceable
+	tagBounceable = 0x11
+	// tagNonBounceable marks a user-friendly address as non-bounceable
+	tagNonBounceable = 0x51
+	// tagTestnet is or-ed into the tag for testnet-only addresses
+	tagTestnet = 0x80
+	// crc16Polynomial is the CRC-16/XMODEM polynomial
+	crc16Polynomial = 0x1021
+)
+
+// Address is a standard internal TON address
+type Address struct {
+	Workchain int8
+	Hash      [32]byte
+}
+
+// ParseAddress accepts raw (0:<hex>) and user-friendly (base64 / base64url) addresses
+func ParseAddress(s string) (Address, error) {
+	var addr Address
+
+	if workchain, hash, ok := strings.Cut(s, ":"); ok {
+		wc, err := strconv.ParseInt(workchain, 10, 8)
+		if err != nil {
+			return addr, ErrInvalidAddress
+		}
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != len(addr.Hash) {
+			return addr, ErrInvalidAddress
+		}
+		addr.Workchain = int8(wc)
+		copy(addr.Hash[:], decoded)
+		return addr, nil
+	}
+
+	decoded, err := base64.URLEncoding.DecodeString(strings.NewReplacer("+", "-", "/", "_").Replace(s))
+	if err != nil || len(decoded) != friendlyAddressLength {
+		return addr, ErrInvalidAddress
+	}
+	if binary.BigEndian.Uint16(decoded[34:]) != crc16(decoded[:34]) {
+		return addr, ErrInvalidAddress
+	}
+	addr.Workchain = int8(decoded[1])
+	copy(addr.Hash[:], decoded[2:34])
+	return addr, nil
+}
+
+// Raw returns the workchain:hex representation
+func (a Address) Raw() string {
+	return strconv.Itoa(int(a.Workchain)) + ":" + hex.EncodeToString(a.Hash[:])
+}
+
+// Friendly returns the base64url user-friendly representation
+func (a Address) Friendly(bounceable, testnet bool) string {
+	tag := byte(tagNonBounceable)
+	if bounceable {
+		tag = tagBounceable
+	}
+	if testnet {
+		tag |= tagTestnet
+	}
+
+	out := make([]byte, 0, friendlyAddressLength)
+	out = append(out, tag, byte(a.Workchain))
+	out = append(out, a.Hash[:]...)
+	out = binary.BigEndian.AppendUint16(out, crc16(out))
+	return base64.URLEncoding.EncodeToString(out)
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ crc16Polynomial
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,374 @@
+package ton
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+	"math/big"
+)
+
+const (
+	// maxCellBits is the data capacity of an ordinary cell
+	maxCellBits = 1023
+	// maxCellRefs is the number of references an ordinary cell can hold
+	maxCellRefs = 4
+	// bocMagic prefixes a serialized bag of cells
+	bocMagic = 0xb5ee9c72
+	// bocFlagCRC32C marks a bag of cells followed by a crc32c checksum
+	bocFlagCRC32C = 0x40
+	// bocFlagIndex marks a bag of cells carrying an offset index
+	bocFlagIndex = 0x80
+	// bocSizeMask extracts the reference size from the flags byte
+	bocSizeMask = 0x07
+	// coinsLengthBits is the length prefix width of VarUInteger 16
+	coinsLengthBits = 4
+	// maxCoinsBytes is the largest byte length VarUInteger 16 can prefix
+	maxCoinsBytes = 15
+)
+
+// Cell is an ordinary TVM cell: up to 1023 bits of data and 4 references
+type Cell struct {
+	data []byte
+	bits int
+	refs []*Cell
+}
+
+// Hash returns the representation hash of the cell
+func (c *Cell) Hash() []byte {
+	h := sha256.New()
+	h.Write(c.descriptors())
+	h.Write(c.paddedData())
+	for _, ref := range c.refs {
+		h.Write(binary.BigEndian.AppendUint16(nil, ref.Depth()))
+	}
+	for _, ref := range c.refs {
+		h.Write(ref.Hash())
+	}
+	return h.Sum(nil)
+}
+
+// Depth returns the maximum depth of the cell tree
+func (c *Cell) Depth() uint16 {
+	var depth uint16
+	for _, ref := range c.refs {
+		if d := ref.Depth() + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+func (c *Cell) descriptors() []byte {
+	d1 := byte(len(c.refs))
+	d2 := byte(c.bits/8 + (c.bits+7)/8)
+	return []byte{d1, d2}
+}
+
+// paddedData returns the cell data with the completion tag appended when the
+// bit length is not a multiple of eight
+func (c *Cell) paddedData() []byte {
+	out := make([]byte, (c.bits+7)/8)
+	copy(out, c.data)
+	if c.bits%8 != 0 {
+		out[c.bits/8] |= 0x80 >> (c.bits % 8)
+	}
+	return out
+}
+
+// Builder assembles a Cell bit by bit
+type Builder struct {
+	cell Cell
+	err  error
+}
+
+// NewBuilder returns an empty cell builder
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// StoreBit appends a single bit
+func (b *Builder) StoreBit(bit bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if b.cell.bits+1 > maxCellBits {
+		b.err = ErrCellOverflow
+		return b
+	}
+	if b.cell.bits%8 == 0 {
+		b.cell.data = append(b.cell.data, 0)
+	}
+	if bit {
+		b.cell.data[b.cell.bits/8] |= 0x80 >> (b.cell.bits % 8)
+	}
+	b.cell.bits++
+	return b
+}
+
+// StoreUint appends the n low bits of v, most significant first
+func (b *Builder) StoreUint(v uint64, n int) *Builder {
+	for i := n - 1; i >= 0; i-- {
+		b.StoreBit(v>>uint(i)&1 == 1)
+	}
+	return b
+}
+
+// StoreBigUint appends v as an n bit unsigned integer
+func (b *Builder) StoreBigUint(v *big.Int, n int) *Builder {
+	for i := n - 1; i >= 0; i-- {
+		b.StoreBit(v.Bit(i) == 1)
+	}
+	return b
+}
+
+// StoreBytes appends whole bytes
+func (b *Builder) StoreBytes(data []byte) *Builder {
+	for _, octet := range data {
+		b.StoreUint(uint64(octet), 8)
+	}
+	return b
+}
+
+// StoreCoins appends an amount as VarUInteger 16
+func (b *Builder) StoreCoins(amount *big.Int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if amount.Sign() < 0 {
+		b.err = ErrInvalidAmount
+		return b
+	}
+	length := (amount.BitLen() + 7) / 8
+	if length > maxCoinsBytes {
+		b.err = ErrInvalidAmount
+		return b
+	}
+	b.StoreUint(uint64(length), coinsLengthBits)
+	return b.StoreBigUint(amount, length*8)
+}
+
+// StoreAddress appends an addr_std without anycast
+func (b *Builder) StoreAddress(addr Address) *Builder {
+	b.StoreUint(0b10, 2)
+	b.StoreBit(false)
+	b.StoreUint(uint64(uint8(addr.Workchain)), 8)
+	return b.StoreBytes(addr.Hash[:])
+}
+
+// StoreRef appends a reference to another cell
+func (b *Builder) StoreRef(ref *Cell) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(b.cell.refs) >= maxCellRefs {
+		b.err = ErrTooManyRefs
+		return b
+	}
+	b.cell.refs = append(b.cell.refs, ref)
+	return b
+}
+
+// StoreBuilder appends the bits and references of another builder
+func (b *Builder) StoreBuilder(other *Builder) *Builder {
+	cell, err := other.EndCell()
+	if err != nil {
+		b.err = err
+		return b
+	}
+	for i := 0; i < cell.bits; i++ {
+		b.StoreBit(cell.data[i/8]&(0x80>>(i%8)) != 0)
+	}
+	for _, ref := range cell.refs {
+		b.StoreRef(ref)
+	}
+	return b
+}
+
+// EndCell finalizes the cell
+func (b *Builder) EndCell() (*Cell, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	cell := b.cell
+	return &cell, nil
+}
+
+// ToBOC serializes the cell tree as a bag of cells with a crc32c checksum
+func (c *Cell) ToBOC() []byte {
+	order := make([]*Cell, 0)
+	index := make(map[string]int)
+
+	// reverse post-order guarantees every reference points to a later cell
+	var visit func(cell *Cell)
+	visit = func(cell *Cell) {
+		key := string(cell.Hash())
+		if _, ok := index[key]; ok {
+			return
+		}
+		index[key] = -1
+		for _, ref := range cell.refs {
+			visit(ref)
+		}
+		order = append(order, cell)
+	}
+	visit(c)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	for i, cell := range order {
+		index[string(cell.Hash())] = i
+	}
+
+	refSize := byteLength(uint64(len(order)))
+	var payload bytes.Buffer
+	for _, cell := range order {
+		payload.Write(cell.descriptors())
+		payload.Write(cell.paddedData())
+		for _, ref := range cell.refs {
+			payload.Write(uintBytes(uint64(index[string(ref.Hash())]), refSize))
+		}
+	}
+	offSize := byteLength(uint64(payload.Len()))
+
+	var boc bytes.Buffer
+	boc.Write(binary.BigEndian.AppendUint32(nil, bocMagic))
+	boc.WriteByte(bocFlagCRC32C | byte(refSize))
+	boc.WriteByte(byte(offSize))
+	boc.Write(uintBytes(uint64(len(order)), refSize))
+	boc.Write(uintBytes(1, refSize))
+	boc.Write(uintBytes(0, refSize))
+	boc.Write(uintBytes(uint64(payload.Len()), offSize))
+	boc.Write(uintBytes(0, refSize))
+	boc.Write(payload.Bytes())
+
+	checksum := crc32.Checksum(boc.Bytes(), crc32.MakeTable(crc32.Castagnoli))
+	boc.Write(binary.LittleEndian.AppendUint32(nil, checksum))
+
+	return boc.Bytes()
+}
+
+// FromBOC parses a single root bag of cells
+func FromBOC(boc []byte) (*Cell, error) {
+	r := &bocReader{data: boc}
+
+	if r.uint(4) != bocMagic {
+		return nil, ErrInvalidBOC
+	}
+	flags := r.byte()
+	refSize := int(flags & bocSizeMask)
+	offSize := int(r.byte())
+	if r.err != nil || refSize == 0 || refSize > 4 || offSize == 0 || offSize > 8 {
+		return nil, ErrInvalidBOC
+	}
+
+	cellCount := int(r.uint(refSize))
+	rootCount := int(r.uint(refSize))
+	r.uint(refSize) // absent cells
+	r.uint(offSize) // total cells size
+	if r.err != nil || rootCount != 1 || cellCount == 0 || cellCount > len(boc) {
+		return nil, ErrInvalidBOC
+	}
+	root := int(r.uint(refSize))
+	if flags&bocFlagIndex != 0 {
+		r.skip(cellCount * offSize)
+	}
+
+	cells := make([]*Cell, cellCount)
+	refIndexes := make([][]int, cellCount)
+	for i := 0; i < cellCount; i++ {
+		d1, d2 := r.byte(), r.byte()
+		refCount := int(d1 & bocSizeMask)
+		if refCount > maxCellRefs {
+			return nil, ErrInvalidBOC
+		}
+		data := r.bytes((int(d2) + 1) / 2)
+		bits := len(data) * 8
+		if d2%2 == 1 && len(data) > 0 {
+			// strip the completion tag
+			last := data[len(data)-1]
+			trailing := 0
+			for trailing < 8 && last&(1<<trailing) == 0 {
+				trailing++
+			}
+			bits -= trailing + 1
+			data[len(data)-1] &^= 1 << trailing
+		}
+		for j := 0; j < refCount; j++ {
+			refIndexes[i] = append(refIndexes[i], int(r.uint(refSize)))
+		}
+		if r.err != nil {
+			return nil, ErrInvalidBOC
+		}
+		cells[i] = &Cell{data: data, bits: bits}
+	}
+
+	// references always point forward, so resolve from the last cell backwards
+	for i := cellCount - 1; i >= 0; i-- {
+		for _, ref := range refIndexes[i] {
+			if ref <= i || ref >= cellCount {
+				return nil, ErrInvalidBOC
+			}
+			cells[i].refs = append(cells[i].refs, cells[ref])
+		}
+	}
+
+	if root >= cellCount {
+		return nil, ErrInvalidBOC
+	}
+	return cells[root], nil
+}
+
+type bocReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bocReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		r.err = ErrInvalidBOC
+		return nil
+	}
+	out := make([]byte, n)
+	copy(out, r.data[r.pos:r.pos+n])
+	r.pos += n
+	return out
+}
+
+func (r *bocReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *bocReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *bocReader) uint(n int) uint64 {
+	var v uint64
+	for _, b := range r.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func byteLength(v uint64) int {
+	n := 1
+	for v >= 1<<(8*n) && n < 8 {
+		n++
+	}
+	return n
+}
+
+func uintBytes(v uint64, n int) []byte {
+	out := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+	return out
+}
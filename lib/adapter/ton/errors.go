@@ -0,0 +1,15 @@
+package ton
+
+import "errors"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidPayloadData = errors.New("invalid payload data")
+	ErrInvalidAddress     = errors.New("invalid TON address")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrTooManyMessages    = errors.New("wallet v4 supports at most 4 messages")
+	ErrNoMessages         = errors.New("at least one message is required")
+	ErrCellOverflow       = errors.New("cell overflow")
+	ErrTooManyRefs        = errors.New("cell can reference at most 4 cells")
+	ErrInvalidBOC         = errors.New("invalid bag of cells")
+)
@@ -0,0 +1,193 @@
+package ton
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// maskingLength is the number of characters to show at the end of masked keys
+	maskingLength = 4
+)
+
+// Adapter represents a TON blockchain adapter for wallet v4r2 accounts
+type Adapter struct {
+	logger      *slog.Logger
+	subwalletID uint32
+}
+
+// NewTonAdapter creates a new TON adapter instance
+func NewTonAdapter(logger *slog.Logger) *Adapter {
+	return &Adapter{
+		logger:      logger.With(slog.String("adapter", "ton")),
+		subwalletID: DefaultSubwalletID,
+	}
+}
+
+// CanDo checks if this adapter can handle the given coin type
+func (t *Adapter) CanDo(coinType uint16) bool {
+	return coinType == slip44.Ton
+}
+
+// Capabilities describes the coins and formats handled by the TON adapter
+func (t *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:        "ton",
+		CoinTypes:      []uint16{slip44.Ton},
+		Curve:          lib.CurveEd25519,
+		AddressFormats: []string{"user-friendly base64url, non-bounceable wallet v4r2"},
+		Payload:        lib.TonRawTx{},
+		Operations:     lib.DefaultOperations(),
+		Testnet:        true,
+	}
+}
+
+// DerivePrivateKey derives a private key from the given seed and derivation path
+func (t *Adapter) DerivePrivateKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := t.logger.With(slog.String("op", "derive_private_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving private key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	privateKeyHex := hex.EncodeToString(privateKey.Seed())
+
+	maskedKey := strings.Repeat("*", len(privateKeyHex)-maskingLength) + privateKeyHex[len(privateKeyHex)-maskingLength:]
+	logger.Info("Private key derived successfully", "privateKey", maskedKey)
+
+	return privateKeyHex, nil
+}
+
+// DerivePublicKey derives a public key from the given seed and derivation path
+func (t *Adapter) DerivePublicKey(seed []byte, derivationPath string, _ bool) (string, error) {
+	logger := t.logger.With(slog.String("op", "derive_public_key"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving public key")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive public key", "error", err)
+		return "", err
+	}
+
+	publicKeyHex := hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+	logger.Info("Public key derived successfully", "publicKey", publicKeyHex)
+
+	return publicKeyHex, nil
+}
+
+// DeriveAddress derives the non-bounceable user-friendly wallet v4r2 address.
+// In dev mode the testnet flag is set on the address.
+func (t *Adapter) DeriveAddress(seed []byte, derivationPath string, isDev bool) (string, error) {
+	logger := t.logger.With(slog.String("op", "derive_address"), slog.String("derivationPath", derivationPath))
+	logger.Info("Deriving address")
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive address", "error", err)
+		return "", err
+	}
+
+	wallet, err := WalletAddress(privateKey.Public().(ed25519.PublicKey), t.subwalletID)
+	if err != nil {
+		logger.Error("Failed to compute wallet address", "error", err)
+		return "", err
+	}
+
+	address := wallet.Friendly(false, isDev)
+	logger.Info("Address derived successfully", "address", address, "raw", wallet.Raw())
+
+	return address, nil
+}
+
+// ValidatePayload reports the field errors of a TON sign payload
+func (t *Adapter) ValidatePayload(payload string) error {
+	v := lib.NewPayloadValidator(payload)
+	v.Uint("seqno", 32, false)
+	if validUntil, ok := v.Uint("validUntil", 32, true); ok && validUntil == 0 {
+		v.Fail("validUntil", "missing")
+	}
+
+	messages := v.Objects("messages", true)
+	if len(messages) > maxWalletMessages {
+		v.Fail("messages", ErrTooManyMessages.Error())
+	}
+	for _, m := range messages {
+		if address, ok := m.String("address", true); ok {
+			if _, err := ParseAddress(address); err != nil {
+				m.Fail("address", "not a TON address")
+			}
+		}
+		if amount, ok := m.String("amount", true); ok {
+			if n, ok := new(big.Int).SetString(amount, 10); !ok || n.Sign() <= 0 {
+				m.Fail("amount", "not a positive decimal amount")
+			}
+		}
+		m.Bool("bounce")
+		m.String("comment", false)
+		m.Uint("mode", 8, false)
+	}
+	return v.Err()
+}
+
+// CreateSignedTransaction signs a wallet v4 transfer and returns the hex encoded
+// bag of cells of the external message, ready to be sent to the network.
+func (t *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
+	logger := t.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
+	logger.Info("Creating signed transaction")
+
+	var rawTx lib.TonRawTx
+	if err := json.Unmarshal([]byte(payload), &rawTx); err != nil || rawTx.ValidUntil == 0 {
+		return "", fmt.Errorf("unable to decode payload=[%v]: %w", payload, ErrInvalidPayloadData)
+	}
+
+	messages := make([]WalletMessage, 0, len(rawTx.Messages))
+	for _, m := range rawTx.Messages {
+		destination, err := ParseAddress(m.Address)
+		if err != nil {
+			return "", err
+		}
+		amount, ok := new(big.Int).SetString(m.Amount, 10)
+		if !ok || amount.Sign() <= 0 {
+			return "", ErrInvalidAmount
+		}
+		mode := uint8(defaultSendMode)
+		if m.Mode != nil {
+			mode = *m.Mode
+		}
+		messages = append(messages, WalletMessage{
+			Destination: destination,
+			Amount:      amount,
+			Bounce:      m.Bounce,
+			Comment:     m.Comment,
+			Mode:        mode,
+		})
+	}
+
+	privateKey, err := lib.DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		logger.Error("Failed to derive private key", "error", err)
+		return "", err
+	}
+
+	external, err := SignedExternalMessage(privateKey, t.subwalletID, rawTx.ValidUntil, rawTx.Seqno, messages)
+	if err != nil {
+		logger.Error("Failed to build external message", "error", err)
+		return "", err
+	}
+
+	txHex := hex.EncodeToString(external.ToBOC())
+	logger.Info("Signed transaction created successfully", "tx", txHex)
+
+	return txHex, nil
+}
@@ -0,0 +1,177 @@
+package ton
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+const (
+	// private key seed of SLIP-0010 ed25519 test vector 1 at m/0'
+	testPrivateKeySeed = "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3"
+	// wallet v4r2 address of testPrivateKeySeed, cross-checked with tonutils-go
+	expectedWalletAddress = "UQCC3D2vsJbdbb0oFjDh2vnXyVcP0wk2TQaoY4nqnvJG1uzj"
+	walletV4R2CodeHash    = "feb5ff6820e2ff0d9483e7e0d62c817d846789fb4ae580c878866d959dabd5c0"
+	testDestination       = "EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N"
+)
+
+func newTestAdapter() *Adapter {
+	return NewTonAdapter(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+}
+
+func TestWalletV4R2Code(t *testing.T) {
+	code, err := WalletV4R2Code()
+	require.NoError(t, err)
+	assert.Equal(t, walletV4R2CodeHash, hex.EncodeToString(code.Hash()))
+	assert.Equal(t, uint16(7), code.Depth())
+
+	// serialization round trip keeps the representation hash
+	parsed, err := FromBOC(code.ToBOC())
+	require.NoError(t, err)
+	assert.Equal(t, code.Hash(), parsed.Hash())
+}
+
+func TestWalletAddress(t *testing.T) {
+	seed, err := hex.DecodeString(testPrivateKeySeed)
+	require.NoError(t, err)
+	publicKey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+	addr, err := WalletAddress(publicKey, DefaultSubwalletID)
+	require.NoError(t, err)
+	assert.Equal(t, expectedWalletAddress, addr.Friendly(false, false))
+
+	parsed, err := ParseAddress(addr.Friendly(true, true))
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+
+	parsed, err = ParseAddress(addr.Raw())
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+
+	_, err = ParseAddress("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2M")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
+
+func TestTonAdapter_CanDo(t *testing.T) {
+	adapter := newTestAdapter()
+	assert.True(t, adapter.CanDo(slip44.Ton))
+	assert.False(t, adapter.CanDo(slip44.Solana))
+}
+
+func TestTonAdapter_DeriveAddress(t *testing.T) {
+	adapter := newTestAdapter()
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	address, err := adapter.DeriveAddress(seed, "m/0'", false)
+	require.NoError(t, err)
+	assert.Equal(t, expectedWalletAddress, address)
+
+	testnet, err := adapter.DeriveAddress(seed, "m/0'", true)
+	require.NoError(t, err)
+	assert.NotEqual(t, address, testnet)
+}
+
+func TestTonAdapter_CreateSignedTransaction(t *testing.T) {
+	adapter := newTestAdapter()
+	seed, err := lib.SeedFromMnemonic(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	require.NoError(t, err)
+	path := "m/44'/607'/0'"
+
+	privateKeyHex, err := adapter.DerivePrivateKey(seed, path, false)
+	require.NoError(t, err)
+	privateKeyBytes, err := hex.DecodeString(privateKeyHex)
+	require.NoError(t, err)
+	privateKey := ed25519.NewKeyFromSeed(privateKeyBytes)
+
+	tests := []struct {
+		name          string
+		seqno         uint32
+		withStateInit bool
+	}{
+		{name: "deployed wallet", seqno: 5, withStateInit: false},
+		{name: "undeployed wallet attaches state init", seqno: 0, withStateInit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := fmt.Sprintf(`{"seqno":%d,"validUntil":1900000000,"messages":[`+
+				`{"address":"%s","amount":"1000000000","bounce":true,"comment":"hello"}]}`,
+				tt.seqno, testDestination)
+
+			txHex, err := adapter.CreateSignedTransaction(seed, path, payload)
+			require.NoError(t, err)
+
+			boc, err := hex.DecodeString(txHex)
+			require.NoError(t, err)
+			external, err := FromBOC(boc)
+			require.NoError(t, err)
+
+			if tt.withStateInit {
+				require.Len(t, external.refs, 2)
+			} else {
+				require.Len(t, external.refs, 1)
+			}
+
+			// body = signature || unsigned body; the signature covers the unsigned body hash
+			body := external.refs[len(external.refs)-1]
+			signature := body.data[:ed25519.SignatureSize]
+			unsigned := &Cell{data: shiftBits(body.data, body.bits, 512), bits: body.bits - 512, refs: body.refs}
+			assert.True(t, ed25519.Verify(privateKey.Public().(ed25519.PublicKey), unsigned.Hash(), signature))
+		})
+	}
+
+	t.Run("invalid payloads", func(t *testing.T) {
+		_, err := adapter.CreateSignedTransaction(seed, path, `{"seqno":1}`)
+		assert.ErrorIs(t, err, ErrInvalidPayloadData)
+
+		_, err = adapter.CreateSignedTransaction(seed, path, `{"seqno":1,"validUntil":1,"messages":[]}`)
+		assert.ErrorIs(t, err, ErrNoMessages)
+
+		_, err = adapter.CreateSignedTransaction(seed, path,
+			`{"seqno":1,"validUntil":1,"messages":[{"address":"bad","amount":"1"}]}`)
+		assert.ErrorIs(t, err, ErrInvalidAddress)
+
+		_, err = adapter.CreateSignedTransaction(seed, path, fmt.Sprintf(
+			`{"seqno":1,"validUntil":1,"messages":[{"address":"%s","amount":"-1"}]}`, testDestination))
+		assert.ErrorIs(t, err, ErrInvalidAmount)
+	})
+}
+
+// shiftBits drops the first n bits of a bit string of the given length
+func TestTonAdapter_ValidatePayload(t *testing.T) {
+	adapter := newTestAdapter()
+
+	valid := fmt.Sprintf(`{"seqno":1,"validUntil":1900000000,"messages":[{"address":"%s","amount":"1"}]}`, testDestination)
+	require.NoError(t, adapter.ValidatePayload(valid))
+
+	err := adapter.ValidatePayload(`{"seqno":-1,"messages":[{"address":"bad","amount":"-1","mode":300}]}`)
+	var fieldErrs lib.PayloadErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, lib.PayloadErrors{
+		{Field: "seqno", Message: "not an unsigned 32 bit integer"},
+		{Field: "validUntil", Message: "missing"},
+		{Field: "messages[0].address", Message: "not a TON address"},
+		{Field: "messages[0].amount", Message: "not a positive decimal amount"},
+		{Field: "messages[0].mode", Message: "not an unsigned 8 bit integer"},
+	}, fieldErrs)
+}
+
+func shiftBits(data []byte, bits, n int) []byte {
+	b := NewBuilder()
+	for i := n; i < bits; i++ {
+		b.StoreBit(data[i/8]&(0x80>>(i%8)) != 0)
+	}
+	cell, _ := b.EndCell()
+	return cell.data
+}
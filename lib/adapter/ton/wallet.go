@@ -0,0 +1,225 @@
+package ton
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"math/big"
+)
+
+const (
+	// DefaultSubwalletID is the wallet id used by wallet v3/v4 on workchain 0
+	DefaultSubwalletID = 698983191
+	// maxWalletMessages is the number of messages a wallet v4 transfer can carry
+	maxWalletMessages = 4
+	// defaultSendMode pays forward fees separately and ignores action errors
+	defaultSendMode = 3
+	// opSimpleSend is the wallet v4 op for a plain transfer
+	opSimpleSend = 0
+	// commentOp prefixes a text comment in a message body
+	commentOp = 0
+	// maxCommentBytes is the number of comment bytes that fit in the first body cell
+	maxCommentBytes = 123
+)
+
+// walletV4R2CodeHex is the wallet v4r2 contract code as a bag of cells
+const walletV4R2CodeHex = "B5EE9C72410214010002D4000114FF00F4A413F4BCF2C80B010201200203020148040504F8F28308D71820D31FD31FD31F02" +
+	"F823BBF264ED44D0D31FD31FD3FFF404D15143BAF2A15151BAF2A205F901541064F910F2A3F80024A4C8CB1F5240CB1F5230" +
+	"CBFF5210F400C9ED54F80F01D30721C0009F6C519320D74A96D307D402FB00E830E021C001E30021C002E30001C0039130E3" +
+	"0D03A4C8CB1F12CB1FCBFF1011121302E6D001D0D3032171B0925F04E022D749C120925F04E002D31F218210706C7567BD22" +
+	"821064737472BDB0925F05E003FA403020FA4401C8CA07CBFFC9D0ED44D0810140D721F404305C810108F40A6FA131B3925F" +
+	"07E005D33FC8258210706C7567BA923830E30D03821064737472BA925F06E30D06070201200809007801FA00F40430F8276F" +
+	"2230500AA121BEF2E0508210706C7567831EB17080185004CB0526CF1658FA0219F400CB6917CB1F5260CB3F20C98040FB00" +
+	"06008A5004810108F45930ED44D0810140D720C801CF16F400C9ED540172B08E23821064737472831EB17080185005CB0550" +
+	"03CF1623FA0213CB6ACB1FCB3FC98040FB00925F03E20201200A0B0059BD242B6F6A2684080A06B90FA0218470D4080847A4" +
+	"937D29910CE6903E9FF9837812801B7810148987159F31840201580C0D0011B8C97ED44D0D70B1F8003DB29DFB5134204050" +
+	"35C87D010C00B23281F2FFF274006040423D029BE84C600201200E0F0019ADCE76A26840206B90EB85FFC00019AF1DF6A268" +
+	"40106B90EB858FC0006ED207FA00D4D422F90005C8CA0715CBFFC9D077748018C8CB05CB0222CF165005FA0214CB6B12CCCC" +
+	"C973FB00C84014810108F451F2A7020070810108D718FA00D33FC8542047810108F451F2A782106E6F746570748018C8CB05" +
+	"CB025006CF165004FA0214CB6A12CB1FCB3FC973FB0002006C810108D718FA00D33F305224810108F459F2A7821064737472" +
+	"70748018C8CB05CB025005CF165003FA0213CB6ACB1F12CB3FC973FB00000AF400C9ED54696225E5" //nolint:lll // contract code is necessarily long
+
+// WalletV4R2Code returns the parsed wallet v4r2 code cell
+func WalletV4R2Code() (*Cell, error) {
+	boc, err := hex.DecodeString(walletV4R2CodeHex)
+	if err != nil {
+		return nil, err
+	}
+	return FromBOC(boc)
+}
+
+// WalletStateInit builds the wallet v4r2 StateInit for the public key
+func WalletStateInit(publicKey ed25519.PublicKey, subwalletID uint32) (*Cell, error) {
+	code, err := WalletV4R2Code()
+	if err != nil {
+		return nil, err
+	}
+
+	// seqno, subwallet id, public key, empty plugins dictionary
+	data, err := NewBuilder().
+		StoreUint(0, 32).
+		StoreUint(uint64(subwalletID), 32).
+		StoreBytes(publicKey).
+		StoreBit(false).
+		EndCell()
+	if err != nil {
+		return nil, err
+	}
+
+	// no split_depth, no special, code, data, no library
+	return NewBuilder().
+		StoreBit(false).
+		StoreBit(false).
+		StoreBit(true).StoreRef(code).
+		StoreBit(true).StoreRef(data).
+		StoreBit(false).
+		EndCell()
+}
+
+// WalletAddress computes the workchain 0 address of the wallet v4r2 for the public key
+func WalletAddress(publicKey ed25519.PublicKey, subwalletID uint32) (Address, error) {
+	var addr Address
+
+	stateInit, err := WalletStateInit(publicKey, subwalletID)
+	if err != nil {
+		return addr, err
+	}
+	copy(addr.Hash[:], stateInit.Hash())
+	return addr, nil
+}
+
+// WalletMessage is a single outgoing transfer of a wallet v4 request
+type WalletMessage struct {
+	Destination Address
+	Amount      *big.Int
+	Bounce      bool
+	Comment     string
+	Mode        uint8
+}
+
+// internalMessage builds the int_msg_info message cell for a transfer
+func internalMessage(msg WalletMessage) (*Cell, error) {
+	b := NewBuilder().
+		StoreBit(false).      // int_msg_info$0
+		StoreBit(true).       // ihr_disabled
+		StoreBit(msg.Bounce). // bounce
+		StoreBit(false).      // bounced
+		StoreUint(0, 2).      // src: addr_none
+		StoreAddress(msg.Destination).
+		StoreCoins(msg.Amount).
+		StoreBit(false).           // no extra currencies
+		StoreCoins(big.NewInt(0)). // ihr_fee
+		StoreCoins(big.NewInt(0)). // fwd_fee
+		StoreUint(0, 64).          // created_lt
+		StoreUint(0, 32).          // created_at
+		StoreBit(false)            // no state init
+
+	if msg.Comment == "" {
+		return b.StoreBit(false).EndCell()
+	}
+
+	body, err := commentCell(msg.Comment)
+	if err != nil {
+		return nil, err
+	}
+	return b.StoreBit(true).StoreRef(body).EndCell()
+}
+
+// commentCell encodes a text comment, chaining cells as a snake string
+func commentCell(comment string) (*Cell, error) {
+	text := []byte(comment)
+	head := min(len(text), maxCommentBytes)
+
+	b := NewBuilder().StoreUint(commentOp, 32).StoreBytes(text[:head])
+	tail, err := snakeTail(text[head:])
+	if err != nil {
+		return nil, err
+	}
+	if tail != nil {
+		b.StoreRef(tail)
+	}
+	return b.EndCell()
+}
+
+func snakeTail(text []byte) (*Cell, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+	chunk := min(len(text), maxCellBits/8)
+	b := NewBuilder().StoreBytes(text[:chunk])
+	tail, err := snakeTail(text[chunk:])
+	if err != nil {
+		return nil, err
+	}
+	if tail != nil {
+		b.StoreRef(tail)
+	}
+	return b.EndCell()
+}
+
+// unsignedTransferBody builds the wallet v4 body the owner signs
+func unsignedTransferBody(subwalletID, validUntil, seqno uint32, messages []WalletMessage) (*Builder, error) {
+	if len(messages) == 0 {
+		return nil, ErrNoMessages
+	}
+	if len(messages) > maxWalletMessages {
+		return nil, ErrTooManyMessages
+	}
+
+	b := NewBuilder().
+		StoreUint(uint64(subwalletID), 32).
+		StoreUint(uint64(validUntil), 32).
+		StoreUint(uint64(seqno), 32).
+		StoreUint(opSimpleSend, 8)
+
+	for _, msg := range messages {
+		cell, err := internalMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		b.StoreUint(uint64(msg.Mode), 8).StoreRef(cell)
+	}
+	return b, nil
+}
+
+// SignedExternalMessage signs a wallet v4 transfer and wraps it in an inbound
+// external message to the wallet, attaching the StateInit when seqno is 0.
+func SignedExternalMessage(privateKey ed25519.PrivateKey, subwalletID, validUntil, seqno uint32,
+	messages []WalletMessage) (*Cell, error) {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	unsigned, err := unsignedTransferBody(subwalletID, validUntil, seqno, messages)
+	if err != nil {
+		return nil, err
+	}
+	unsignedCell, err := unsigned.EndCell()
+	if err != nil {
+		return nil, err
+	}
+	signature := ed25519.Sign(privateKey, unsignedCell.Hash())
+
+	body, err := NewBuilder().StoreBytes(signature).StoreBuilder(unsigned).EndCell()
+	if err != nil {
+		return nil, err
+	}
+
+	stateInit, err := WalletStateInit(publicKey, subwalletID)
+	if err != nil {
+		return nil, err
+	}
+	var wallet Address
+	copy(wallet.Hash[:], stateInit.Hash())
+
+	b := NewBuilder().
+		StoreUint(0b10, 2). // ext_in_msg_info$10
+		StoreUint(0, 2).    // src: addr_none
+		StoreAddress(wallet).
+		StoreCoins(big.NewInt(0)) // import_fee
+
+	if seqno == 0 {
+		b.StoreBit(true).StoreBit(true).StoreRef(stateInit)
+	} else {
+		b.StoreBit(false)
+	}
+
+	return b.StoreBit(true).StoreRef(body).EndCell()
+}
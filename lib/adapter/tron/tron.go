@@ -16,6 +16,8 @@ import (
 	"github.com/fbsobreira/gotron-sdk/pkg/common"
 	"github.com/fbsobreira/gotron-sdk/pkg/keys/hd"
 	"github.com/fbsobreira/gotron-sdk/pkg/proto/core"
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/secp"
 	"github.com/payment-system/dq-vault/lib/slip44"
 	"google.golang.org/protobuf/proto"
 )
@@ -48,6 +50,18 @@ func (t *Adapter) CanDo(coinType uint16) bool {
 	return coinType == slip44.Tron
 }
 
+// Capabilities describes the coins and formats handled by the Tron adapter
+func (t *Adapter) Capabilities() lib.Capabilities {
+	return lib.Capabilities{
+		Adapter:          "tron",
+		CoinTypes:        []uint16{slip44.Tron},
+		Curve:            lib.CurveSecp256k1,
+		AddressFormats:   []string{"base58check (T...)"},
+		RawPayloadFormat: "hex encoded protobuf TransactionRaw",
+		Operations:       lib.DefaultOperations(),
+	}
+}
+
 func (t *Adapter) parseDerivationPath(path string) (string, error) {
 	logger := t.logger.With(slog.String("op", "parse_derivation_path"), slog.String("path", path))
 	logger.Info("Parsing derivation path")
@@ -161,6 +175,29 @@ func (t *Adapter) DeriveAddress(seed []byte, derivationPath string, _ bool) (str
 	return tronAddress.String(), nil
 }
 
+// ValidatePayload reports the errors of a Tron sign payload, a hex encoded protobuf TransactionRaw
+func (t *Adapter) ValidatePayload(payload string) error {
+	decodedHex, err := hex.DecodeString(payload)
+	if err != nil {
+		return lib.PayloadErrors{{Field: "payload", Message: "not hex"}}
+	}
+
+	raw := &core.TransactionRaw{}
+	if err := proto.Unmarshal(decodedHex, raw); err != nil {
+		return lib.PayloadErrors{{Field: "payload", Message: "not a protobuf TransactionRaw"}}
+	}
+	if len(raw.GetContract()) == 0 {
+		return lib.PayloadErrors{{Field: "contract", Message: "missing"}}
+	}
+
+	switch raw.GetContract()[0].GetType() {
+	case core.Transaction_Contract_TransferContract, core.Transaction_Contract_TriggerSmartContract:
+		return nil
+	default:
+		return lib.PayloadErrors{{Field: "contract[0].type", Message: ErrUnsupportedTransactionType.Error()}}
+	}
+}
+
 // CreateSignedTransaction creates a signed transaction from the given parameters
 func (t *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload string) (string, error) {
 	logger := t.logger.With(slog.String("op", "create_signed_transaction"), slog.String("derivationPath", derivationPath))
@@ -234,11 +271,6 @@ func (t *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload s
 		return "", err
 	}
 
-	ecdsaPrivateKey, err := crypto.ToECDSA(privateBytes)
-	if err != nil {
-		return "", err
-	}
-
 	rawDataJSON, err := proto.Marshal(raw)
 	if err != nil {
 		return "", err
@@ -248,10 +280,13 @@ func (t *Adapter) CreateSignedTransaction(seed []byte, derivationPath, payload s
 	h.Write(rawDataJSON)
 	txIDHash := h.Sum(nil)
 
-	sig, err := crypto.Sign(txIDHash, ecdsaPrivateKey)
+	sig, err := secp.Sign(txIDHash, privateBytes)
 	if err != nil {
 		return "", err
 	}
+	if err := lib.CheckCompactSignature(sig); err != nil {
+		return "", err
+	}
 
 	return hex.EncodeToString(sig), nil
 }
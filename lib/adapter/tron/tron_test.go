@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/payment-system/dq-vault/lib"
 	"github.com/payment-system/dq-vault/lib/slip44"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -485,6 +486,29 @@ func TestTronAdapter_CreateSignedTransaction(t *testing.T) {
 	}
 }
 
+func TestTronAdapter_ValidatePayload(t *testing.T) {
+	adapter := NewTronAdapter(logger)
+
+	tests := []struct {
+		name    string
+		payload string
+		want    lib.PayloadErrors
+	}{
+		{name: "not hex", payload: "invalid_hex", want: lib.PayloadErrors{{Field: "payload", Message: "not hex"}}},
+		{name: "not protobuf", payload: "0a", want: lib.PayloadErrors{{Field: "payload", Message: "not a protobuf TransactionRaw"}}},
+		{name: "no contract", payload: "0a020102", want: lib.PayloadErrors{{Field: "contract", Message: "missing"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := adapter.ValidatePayload(tt.payload)
+			var fieldErrs lib.PayloadErrors
+			require.ErrorAs(t, err, &fieldErrs)
+			assert.Equal(t, tt.want, fieldErrs)
+		})
+	}
+}
+
 func TestTronAdapter_decodeContractData(t *testing.T) {
 	adapter := NewTronAdapter(logger)
 
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Schemes of the address proofs, how their message is signed and verified
+const (
+	// ProofSchemeEIP191 -- the EIP-191 personal message of the proof, a 65 byte r || s || v
+	// signature, v being 27 or 28, as personal_sign verifiers expect
+	ProofSchemeEIP191 = "eip191"
+	// ProofSchemeBIP137 -- the Bitcoin signed message of the proof, a base64 65 byte signature whose
+	// header encodes the address type, as Bitcoin wallets verify it
+	ProofSchemeBIP137 = "bip137"
+	// ProofSchemeEd25519 -- the ed25519 signature of the bytes of the proof message
+	ProofSchemeEd25519 = "ed25519"
+
+	// MaxChallengeLength bounds the length of the challenges of the address proofs, in bytes
+	MaxChallengeLength = 256
+	// addressProofPrefix starts the messages of the address proofs, which are then neither
+	// transactions nor the messages of another protocol
+	addressProofPrefix = "dq-vault address proof"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidChallenge = errors.New("challenge must be 1 to 256 bytes of printable characters, on one line")
+	ErrProofUnsupported = errors.New("address proofs are not supported for the address type")
+)
+
+// AddressProofMessage returns the message signed by the proof of the ownership of address for
+// challenge, or ErrInvalidChallenge
+func AddressProofMessage(address, challenge string) (string, error) {
+	if challenge == "" || len(challenge) > MaxChallengeLength {
+		return "", ErrInvalidChallenge
+	}
+	for _, r := range challenge {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidChallenge
+		}
+	}
+	return addressProofPrefix + "\naddress: " + address + "\nchallenge: " + challenge, nil
+}
+
+// SignPersonalMessage signs the EIP-191 personal message of message with the secp256k1 key of
+// derivationPath. It returns the 65 byte r || s || v signature, v being 27 or 28, and the
+// compressed public key.
+func SignPersonalMessage(seed []byte, derivationPath, message string) (signature, publicKey []byte, err error) {
+	signature, publicKey, err = SignDigest(seed, CurveSecp256k1, derivationPath, accounts.TextHash([]byte(message)))
+	if err != nil {
+		return nil, nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, publicKey, nil
+}
+
+// SignEd25519Message signs the bytes of message with the ed25519 key of derivationPath. It returns
+// the signature and the public key.
+func SignEd25519Message(seed []byte, derivationPath, message string) (signature, publicKey []byte, err error) {
+	privateKey, err := DeriveEd25519PrivateKey(seed, derivationPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ed25519.Sign(privateKey, []byte(message)), privateKey.Public().(ed25519.PublicKey), nil
+}
@@ -0,0 +1,288 @@
+package approval
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// testPSBT returns the base64 PSBT of a transaction paying 7000 sat to a P2WPKH output and 3000 to
+// an OP_RETURN one
+func testPSBT(t *testing.T) string {
+	t.Helper()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(7000, append([]byte{0x00, 0x14}, make([]byte, 20)...)))
+	tx.AddTxOut(wire.NewTxOut(3000, []byte{0x6a}))
+	var unsigned bytes.Buffer
+	require.NoError(t, tx.SerializeNoWitness(&unsigned))
+
+	psbt := []byte("psbt\xff")
+	psbt = append(psbt, 0x01, 0x00, byte(unsigned.Len()))
+	psbt = append(psbt, unsigned.Bytes()...)
+	psbt = append(psbt, 0x00, 0x00, 0x00, 0x00)
+	return base64.StdEncoding.EncodeToString(psbt)
+}
+
+// testSolanaMemoMessage returns a Solana message transferring 1 lamport with the memo "deposit 104528"
+func testSolanaMemoMessage(t *testing.T) []byte {
+	t.Helper()
+	owner, err := solana.PublicKeyFromBase58("HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk")
+	require.NoError(t, err)
+	message, err := solana.BuildSOLTransfer(owner, owner, 1, owner, "deposit 104528")
+	require.NoError(t, err)
+	return message
+}
+
+func TestSummarize(t *testing.T) {
+	const to = "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8"
+	// transfer(0x9858EfFD232B4033E47d90003D41EC34EcaEda94, 5000)
+	transfer := "0xa9059cbb0000000000000000000000009858effd232b4033e47d90003d41ec34ecaeda94" +
+		"0000000000000000000000000000000000000000000000000000000000001388"
+
+	tests := []struct {
+		name     string
+		coinType uint16
+		payload  string
+		want     Summary
+		// wantDecoded is whether want holds all the payload moves
+		wantDecoded bool
+	}{
+		{
+			name:        "ether transfer",
+			coinType:    60,
+			payload:     `{"nonce":1,"value":1000,"gasLimit":21000,"gasPrice":1,"chainId":1,"to":"` + to + `"}`,
+			want:        Summary{CoinType: 60, Transfers: []Transfer{{To: to, Amount: "1000"}}, Value: big.NewInt(1000)},
+			wantDecoded: true,
+		},
+		{
+			name:     "erc20 transfer",
+			coinType: 60,
+			payload: `{"nonce":1,"value":0,"gasLimit":60000,"gasPrice":1,"chainId":1,"to":"` + to +
+				`","data":"` + transfer + `"}`,
+			want: Summary{CoinType: 60, Transfers: []Transfer{
+				{To: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", Amount: "5000", Token: to},
+			}},
+			wantDecoded: true,
+		},
+		{
+			name:     "contract call",
+			coinType: 60,
+			payload:  `{"nonce":1,"value":0,"gasLimit":60000,"gasPrice":1,"chainId":1,"to":"` + to + `","data":"0x01"}`,
+			want:     Summary{CoinType: 60},
+		},
+		{
+			name:     "bitcoin psbt",
+			coinType: 0,
+			payload:  testPSBT(t),
+			want: Summary{CoinType: 0, Transfers: []Transfer{
+				{To: "bc1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqq9e75rs", Amount: "7000"},
+				{To: "6a", Amount: "3000"},
+			}, Value: big.NewInt(10_000)},
+			wantDecoded: true,
+		},
+		{name: "malformed payload", coinType: 60, payload: "{", want: Summary{CoinType: 60}},
+		{name: "other chain", coinType: 501, payload: `{"rawTxHex":"00"}`, want: Summary{CoinType: 501}},
+		{
+			name:     "solana memo",
+			coinType: 501,
+			payload:  `{"rawTxHex":"` + hex.EncodeToString(testSolanaMemoMessage(t)) + `"}`,
+			want:     Summary{CoinType: 501, Memo: "deposit 104528"},
+		},
+		{
+			name:     "ton comments",
+			coinType: 607,
+			payload: `{"seqno":1,"validUntil":1,"messages":[{"address":"a","amount":"1","comment":"104528"},` +
+				`{"address":"b","amount":"1"}]}`,
+			want: Summary{CoinType: 607, Memo: "104528"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := Summarize(tt.coinType, tt.payload, false)
+			assert.Equal(t, tt.want, summary)
+			assert.Equal(t, tt.wantDecoded, summary.Decoded())
+		})
+	}
+
+	// Safe transactions are summarized from their call
+	assert.Equal(t, Summary{CoinType: 60, Transfers: []Transfer{{To: to, Amount: "1000"}}, Value: big.NewInt(1000)},
+		SummarizeCall(60, to, big.NewInt(1000), nil))
+	assert.False(t, SummarizeCall(60, to, nil, []byte{0x01}).Decoded())
+
+	// the change told apart by the signer is taken off the outputs of a PSBT
+	psbt := Summarize(0, testPSBT(t), false)
+	assert.Equal(t, big.NewInt(3000), psbt.ValueWithout([]int{0}))
+	assert.Equal(t, big.NewInt(10_000), psbt.ValueWithout(nil))
+	assert.Equal(t, big.NewInt(10_000), psbt.Value, "the value of the summary is kept")
+	assert.Nil(t, Summary{CoinType: 0}.ValueWithout([]int{0}))
+
+	assert.Equal(t, "coinType 501\npayload not decoded\nvalue unknown", Summary{CoinType: 501}.Text())
+	assert.Equal(t, "coinType 501\npayload not decoded\nvalue unknown\nmemo \"104528\"",
+		Summary{CoinType: 501, Memo: "104528"}.Text())
+
+	labeled := Summary{CoinType: 60, Transfers: []Transfer{{To: to, Amount: "1000"}, {To: "0x01", Amount: "1"}},
+		Value: big.NewInt(1001)}
+	labeled.Label(func(address string) string {
+		if address == to {
+			return "binance-hot-wallet"
+		}
+		return ""
+	})
+	assert.Equal(t, "coinType 60\n1000 to binance-hot-wallet ("+to+")\n1 to 0x01", labeled.Text())
+}
+
+func TestMessage(t *testing.T) {
+	n := Notification{
+		ID:        "cs1ab7r2a3ohq8hm58r0",
+		UUID:      "user",
+		Path:      "m/44'/60'/0'/0/0",
+		Summary:   Summary{CoinType: 60, Transfers: []Transfer{{To: "0xabc", Amount: "1"}}, Value: big.NewInt(1)},
+		ExpiresAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		LinkURL:   "https://ops.example.com/approvals?team=payments",
+	}
+	assert.Equal(t, "https://ops.example.com/approvals?action=reject&id=cs1ab7r2a3ohq8hm58r0&team=payments",
+		n.Link(ActionReject))
+
+	t.Run("slack", func(t *testing.T) {
+		body, err := Message(ProviderSlack, n)
+		require.NoError(t, err)
+		var message struct {
+			Text   string `json:"text"`
+			Blocks []struct {
+				Type     string `json:"type"`
+				Elements []struct {
+					Value string `json:"value"`
+					URL   string `json:"url"`
+				} `json:"elements"`
+			} `json:"blocks"`
+		}
+		require.NoError(t, json.Unmarshal(body, &message))
+		assert.Contains(t, message.Text, "1 to 0xabc")
+		assert.Contains(t, message.Text, "expires 2026-01-02T03:04:05Z")
+		require.Len(t, message.Blocks, 2)
+		require.Len(t, message.Blocks[1].Elements, 2)
+		assert.Equal(t, "approve:"+n.ID, message.Blocks[1].Elements[0].Value)
+		assert.Equal(t, n.Link(ActionApprove), message.Blocks[1].Elements[0].URL)
+		assert.Equal(t, "reject:"+n.ID, message.Blocks[1].Elements[1].Value)
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		body, err := Message(ProviderTeams, n)
+		require.NoError(t, err)
+		var message struct {
+			Attachments []struct {
+				Content struct {
+					Actions []struct {
+						Title string `json:"title"`
+						URL   string `json:"url"`
+					} `json:"actions"`
+				} `json:"content"`
+			} `json:"attachments"`
+		}
+		require.NoError(t, json.Unmarshal(body, &message))
+		require.Len(t, message.Attachments, 1)
+		actions := message.Attachments[0].Content.Actions
+		require.Len(t, actions, 2)
+		assert.Equal(t, "Approve", actions[0].Title)
+		assert.Equal(t, n.Link(ActionApprove), actions[0].URL)
+
+		n.LinkURL = ""
+		body, err = Message(ProviderTeams, n)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &message))
+		assert.Empty(t, message.Attachments[0].Content.Actions)
+	})
+
+	_, err := Message("email", n)
+	require.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestSend(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	require.NoError(t, Send(t.Context(), server.Client(), server.URL+"/hook/secret", "application/json", []byte("{}")))
+
+	status = http.StatusForbidden
+	err := Send(t.Context(), server.Client(), server.URL+"/hook/secret", "application/json", []byte("{}"))
+	require.ErrorIs(t, err, ErrWebhookStatus)
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+func TestVerifyCallback_Slack(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	payload := `{"user":{"id":"U1","username":"ops"},"actions":[{"value":"approve:cs1ab7r2a3ohq8hm58r0"}]}`
+	body := url.Values{"payload": {payload}}.Encode()
+	sign := func(secret, timestamp, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + timestamp + ":" + body))
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	callback, err := VerifyCallback(ProviderSlack, "secret", timestamp, body, sign("secret", timestamp, body), now)
+	require.NoError(t, err)
+	assert.Equal(t, Callback{Action: ActionApprove, ID: "cs1ab7r2a3ohq8hm58r0", User: "ops"}, callback)
+
+	_, err = VerifyCallback(ProviderSlack, "secret", timestamp, body, sign("other", timestamp, body), now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = VerifyCallback(ProviderSlack, "secret", timestamp, body+"x", sign("secret", timestamp, body), now)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = VerifyCallback(ProviderSlack, "secret", timestamp, body, sign("secret", timestamp, body),
+		now.Add(MaxCallbackSkew+time.Second))
+	require.ErrorIs(t, err, ErrStaleCallback)
+	_, err = VerifyCallback(ProviderSlack, "secret", "", body, sign("secret", "", body), now)
+	require.ErrorIs(t, err, ErrStaleCallback)
+
+	other := url.Values{"payload": {`{"actions":[{"value":"delete:cs1ab7r2a3ohq8hm58r0"}]}`}}.Encode()
+	_, err = VerifyCallback(ProviderSlack, "secret", timestamp, other, sign("secret", timestamp, other), now)
+	require.ErrorIs(t, err, ErrInvalidCallback)
+}
+
+func TestVerifyCallback_Teams(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("teams-token"))
+	body := `{"type":"message","text":"<at>Vault</at> Approve CS1AB7R2A3OHQ8HM58R0","from":{"id":"29:1","name":"Ops"}}`
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte("teams-token"))
+		mac.Write([]byte(body))
+		return "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	callback, err := VerifyCallback(ProviderTeams, secret, "", body, sign(body), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, Callback{Action: ActionApprove, ID: "cs1ab7r2a3ohq8hm58r0", User: "Ops"}, callback)
+
+	_, err = VerifyCallback(ProviderTeams, secret, "", body, sign(body+" "), time.Now())
+	require.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = VerifyCallback(ProviderTeams, "not base64!", "", body, sign(body), time.Now())
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	hello := `{"type":"message","text":"hello"}`
+	_, err = VerifyCallback(ProviderTeams, secret, "", hello, sign(hello), time.Now())
+	require.ErrorIs(t, err, ErrInvalidCallback)
+
+	_, err = VerifyCallback("email", secret, "", body, sign(body), time.Now())
+	require.ErrorIs(t, err, ErrUnknownProvider)
+}
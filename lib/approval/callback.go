@@ -0,0 +1,145 @@
+package approval
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidSignature = errors.New("callback signature does not match")
+	ErrStaleCallback    = errors.New("callback timestamp is too old or in the future")
+	ErrInvalidCallback  = errors.New("callback does not name an action on an approval")
+)
+
+// MaxCallbackSkew bounds the age of the Slack callbacks, as Slack recommends, against replays
+const MaxCallbackSkew = 5 * time.Minute
+
+// Callback is an action taken on an approval from a chat platform
+type Callback struct {
+	Action string
+	ID     string
+	// User is the chat user who took the action
+	User string
+}
+
+// teamsCommand is an action typed to the Teams outgoing webhook, after the mention of the bot
+//
+//nolint:gochecknoglobals // compiled once, read-only
+var teamsCommand = regexp.MustCompile(`(?i)\b(approve|reject)\s+([0-9a-v]{20})\b`)
+
+// VerifyCallback checks the signature of a callback body posted by provider and returns the action
+// it takes. Slack signs "v0:<timestamp>:<body>" with the signing secret of the app, hex encoded in
+// X-Slack-Signature; Teams signs the body with the base64 security token of the outgoing webhook,
+// base64 encoded in the Authorization header after "HMAC ".
+func VerifyCallback(provider, secret, timestamp, body, signature string, now time.Time) (Callback, error) {
+	switch provider {
+	case ProviderSlack:
+		if err := verifySlack(secret, timestamp, body, signature, now); err != nil {
+			return Callback{}, err
+		}
+		return parseSlack(body)
+	case ProviderTeams:
+		if err := verifyTeams(secret, body, signature); err != nil {
+			return Callback{}, err
+		}
+		return parseTeams(body)
+	}
+	return Callback{}, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+}
+
+func verifySlack(secret, timestamp, body, signature string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleCallback
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > MaxCallbackSkew || skew < -MaxCallbackSkew {
+		return ErrStaleCallback
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func verifyTeams(secret, body, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(strings.TrimPrefix(signature, "HMAC "))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// parseSlack reads the button of an interaction payload, posted form encoded in payload
+func parseSlack(body string) (Callback, error) {
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return Callback{}, ErrInvalidCallback
+	}
+	var payload struct {
+		User struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Actions []struct {
+			Value string `json:"value"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil || len(payload.Actions) != 1 {
+		return Callback{}, ErrInvalidCallback
+	}
+
+	action, id, ok := strings.Cut(payload.Actions[0].Value, ":")
+	if !ok || (action != ActionApprove && action != ActionReject) || id == "" {
+		return Callback{}, ErrInvalidCallback
+	}
+	user := payload.User.Username
+	if user == "" {
+		user = payload.User.ID
+	}
+	return Callback{Action: action, ID: id, User: user}, nil
+}
+
+// parseTeams reads the "approve <id>" or "reject <id>" message of an outgoing webhook activity
+func parseTeams(body string) (Callback, error) {
+	var activity struct {
+		Text string `json:"text"`
+		From struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"from"`
+	}
+	if err := json.Unmarshal([]byte(body), &activity); err != nil {
+		return Callback{}, ErrInvalidCallback
+	}
+	match := teamsCommand.FindStringSubmatch(activity.Text)
+	if match == nil {
+		return Callback{}, ErrInvalidCallback
+	}
+
+	user := activity.From.Name
+	if user == "" {
+		user = activity.From.ID
+	}
+	return Callback{Action: strings.ToLower(match[1]), ID: strings.ToLower(match[2]), User: user}, nil
+}
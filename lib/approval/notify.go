@@ -0,0 +1,175 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownProvider = errors.New("provider must be slack or teams")
+	ErrWebhookStatus   = errors.New("webhook rejected the notification")
+)
+
+// Chat platforms the notifications are posted to
+const (
+	ProviderSlack = "slack"
+	ProviderTeams = "teams"
+)
+
+// EventType is the webhook event type of the notifications
+const EventType = "com.dq-vault.approval.requested"
+
+// Actions of the approvers on an approval
+const (
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+)
+
+// Notification is the message posted for an approval
+type Notification struct {
+	ID        string
+	UUID      string
+	Path      string
+	Summary   Summary
+	ExpiresAt time.Time
+	// LinkURL is the base URL of the approve and reject deep links, none are sent when it is empty
+	LinkURL string
+}
+
+// Link returns the deep link of action on the approval, or "" without a LinkURL
+func (n Notification) Link(action string) string {
+	if n.LinkURL == "" {
+		return ""
+	}
+	u, err := url.Parse(n.LinkURL)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Set("id", n.ID)
+	query.Set("action", action)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (n Notification) text() string {
+	return fmt.Sprintf("Sign request %s awaits approval\nuser %s, path %s\n%s\nexpires %s",
+		n.ID, n.UUID, n.Path, n.Summary.Text(), n.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// Message returns the webhook body of the notification for provider: a Slack message with
+// approve and reject buttons, or a Microsoft Teams adaptive card with the deep links
+func Message(provider string, n Notification) ([]byte, error) {
+	switch provider {
+	case ProviderSlack:
+		return json.Marshal(slackMessage(n))
+	case ProviderTeams:
+		return json.Marshal(teamsMessage(n))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+}
+
+func slackMessage(n Notification) map[string]interface{} {
+	button := func(action, title, style string) map[string]interface{} {
+		b := map[string]interface{}{
+			"type":      "button",
+			"action_id": action,
+			"text":      map[string]interface{}{"type": "plain_text", "text": title},
+			"style":     style,
+			// the value of the button is what the interaction callback reports
+			"value": action + ":" + n.ID,
+		}
+		if link := n.Link(action); link != "" {
+			b["url"] = link
+		}
+		return b
+	}
+
+	text := n.text()
+	return map[string]interface{}{
+		"text": text,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]interface{}{"type": "plain_text", "text": text},
+			},
+			map[string]interface{}{
+				"type":     "actions",
+				"block_id": n.ID,
+				"elements": []interface{}{
+					button(ActionApprove, "Approve", "primary"),
+					button(ActionReject, "Reject", "danger"),
+				},
+			},
+		},
+	}
+}
+
+func teamsMessage(n Notification) map[string]interface{} {
+	// incoming webhook cards only open links, the approvers answer by a message to the outgoing
+	// webhook otherwise
+	actions := []interface{}{}
+	for _, action := range []struct{ name, title string }{
+		{ActionApprove, "Approve"},
+		{ActionReject, "Reject"},
+	} {
+		if link := n.Link(action.name); link != "" {
+			actions = append(actions, map[string]interface{}{
+				"type":  "Action.OpenUrl",
+				"title": action.title,
+				"url":   link,
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []interface{}{
+						map[string]interface{}{"type": "TextBlock", "text": n.text(), "wrap": true},
+					},
+					"actions": actions,
+				},
+			},
+		},
+	}
+}
+
+// Send posts body, of contentType, to the webhook URL. The URL holds the credentials of the
+// webhook and is not part of the errors.
+func Send(ctx context.Context, client *http.Client, webhookURL, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return ErrWebhookStatus
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: status %d", ErrWebhookStatus, resp.StatusCode)
+	}
+	return nil
+}
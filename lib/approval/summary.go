@@ -0,0 +1,223 @@
+// Package approval builds the summaries and chat notifications of the sign requests held for
+// approval, and verifies the signed callbacks of the chat platforms acting on them.
+package approval
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+	"github.com/payment-system/dq-vault/lib/adapter/ton"
+)
+
+// erc20TransferSelector is the selector of transfer(address,uint256)
+const erc20TransferSelector = "a9059cbb"
+
+// Transfer is an amount moved to an address by a transaction, in base units; Token is the
+// contract of a token transfer, empty for the native coin. Name is the address book name of the
+// recipient, when it has one.
+type Transfer struct {
+	To     string `json:"to"`
+	Name   string `json:"name,omitempty"`
+	Amount string `json:"amount"`
+	Token  string `json:"token,omitempty"`
+}
+
+// Summary is the decoded content of a sign payload shown to the approvers
+type Summary struct {
+	CoinType  uint16     `json:"coinType"`
+	Transfers []Transfer `json:"transfers"`
+	// Value is the native amount the transaction moves, in base units. It is nil when the payload
+	// was not decoded or calls a contract, as the native amount then says nothing of its value.
+	Value *big.Int `json:"value"`
+	// Memo is the memo of the payload, see Memo
+	Memo string `json:"memo,omitempty"`
+}
+
+// Summarize decodes the transfers of an EVM or Bitcoin sign payload. The payloads of the other
+// chains are summarized without transfers or value, with their memo.
+func Summarize(coinType uint16, payload string, isDev bool) Summary {
+	summary := Summary{CoinType: coinType, Memo: Memo(coinType, payload)}
+	logger := slog.New(slog.DiscardHandler)
+	switch {
+	case evm.NewEthereumAdapter(logger).CanDo(coinType):
+		summarizeEVM(&summary, payload)
+	case bitcoin.NewBitcoinAdapter(logger).CanDo(coinType):
+		summarizeBitcoin(&summary, payload, isDev)
+	}
+	return summary
+}
+
+// Memo returns the memo a sign payload carries for its recipient, which exchanges credit deposits
+// by: the memo program instructions of a Solana message or the comments of a TON transfer, one per
+// line. The payloads of the other chains, and those that cannot be decoded, have none.
+func Memo(coinType uint16, payload string) string {
+	logger := slog.New(slog.DiscardHandler)
+	var memos []string
+	switch {
+	case solana.NewSolanaAdapter(logger).CanDo(coinType):
+		var tx lib.SolanaRawTx
+		if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+			return ""
+		}
+		message, err := hex.DecodeString(tx.RawTxHex)
+		if err != nil {
+			return ""
+		}
+		memos = solana.Memos(message)
+	case ton.NewTonAdapter(logger).CanDo(coinType):
+		var tx lib.TonRawTx
+		if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+			return ""
+		}
+		for _, message := range tx.Messages {
+			if message.Comment != "" {
+				memos = append(memos, message.Comment)
+			}
+		}
+	}
+	return strings.Join(memos, "\n")
+}
+
+// SummarizeCall decodes the transfers of an EVM call of data to to, sending value: a native
+// transfer without data, or an ERC-20 transfer call. Safe transactions are summarized by it.
+func SummarizeCall(coinType uint16, to string, value *big.Int, data []byte) Summary {
+	summary := Summary{CoinType: coinType}
+	summarizeCall(&summary, to, value, data)
+	return summary
+}
+
+func summarizeEVM(summary *Summary, payload string) {
+	var tx lib.EthereumRawTx
+	if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+		return
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(tx.Data), "0x"))
+	if err != nil {
+		return
+	}
+	summarizeCall(summary, tx.To, tx.Value, data)
+}
+
+func summarizeCall(summary *Summary, to string, value *big.Int, data []byte) {
+	if value == nil {
+		value = new(big.Int)
+	}
+	if len(data) == 0 {
+		summary.Transfers = []Transfer{{To: to, Amount: value.String()}}
+		summary.Value = value
+		return
+	}
+
+	if value.Sign() > 0 {
+		summary.Transfers = append(summary.Transfers, Transfer{To: to, Amount: value.String()})
+	}
+	// transfer(address,uint256): the recipient and the amount are the two words of the arguments
+	if len(data) == 4+2*32 && hex.EncodeToString(data[:4]) == erc20TransferSelector {
+		summary.Transfers = append(summary.Transfers, Transfer{
+			To:     common.BytesToAddress(data[4:36]).Hex(),
+			Amount: new(big.Int).SetBytes(data[36:]).String(),
+			Token:  to,
+		})
+	}
+}
+
+func summarizeBitcoin(summary *Summary, payload string, isDev bool) {
+	packet, err := bitcoin.DecodePSBT(payload)
+	if err != nil {
+		return
+	}
+	params := &chaincfg.MainNetParams
+	if isDev {
+		params = &chaincfg.TestNet3Params
+	}
+
+	// the change outputs are counted too, only the keys of the signer tell them apart; the budgets
+	// take them off with ValueWithout
+	value := new(big.Int)
+	for _, out := range packet.Tx.TxOut {
+		to := hex.EncodeToString(out.PkScript)
+		_, addresses, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, params)
+		if err == nil && len(addresses) == 1 {
+			to = addresses[0].EncodeAddress()
+		}
+		summary.Transfers = append(summary.Transfers, Transfer{To: to, Amount: fmt.Sprint(out.Value)})
+		value.Add(value, big.NewInt(out.Value))
+	}
+	summary.Value = value
+}
+
+// Decoded reports whether the transfers are all the payload moves: a native transfer, or a token
+// transfer call. The payloads calling other contracts move what the contract decides.
+func (s Summary) Decoded() bool {
+	if len(s.Transfers) == 0 {
+		return false
+	}
+	return s.Value != nil || slices.ContainsFunc(s.Transfers, func(t Transfer) bool { return t.Token != "" })
+}
+
+// ValueWithout returns the value of the summary of a Bitcoin payload without the outputs of the
+// indexes, the change of the signing wallet the signer tells apart: its transfers are the outputs
+// of the transaction, in order. It is nil when the value is.
+func (s Summary) ValueWithout(outputs []int) *big.Int {
+	if s.Value == nil {
+		return nil
+	}
+	value := new(big.Int).Set(s.Value)
+	for _, i := range outputs {
+		if i < 0 || i >= len(s.Transfers) {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(s.Transfers[i].Amount, 10)
+		if ok {
+			value.Sub(value, amount)
+		}
+	}
+	return value
+}
+
+// Label sets the names of the recipients, name returning "" for the addresses without one
+func (s Summary) Label(name func(address string) string) {
+	for i := range s.Transfers {
+		s.Transfers[i].Name = name(s.Transfers[i].To)
+	}
+}
+
+// Text returns the summary as lines of text, for the notifications
+func (s Summary) Text() string {
+	lines := []string{fmt.Sprintf("coinType %d", s.CoinType)}
+	if len(s.Transfers) == 0 {
+		lines = append(lines, "payload not decoded")
+	}
+	for _, transfer := range s.Transfers {
+		to := transfer.To
+		if transfer.Name != "" {
+			to = fmt.Sprintf("%s (%s)", transfer.Name, transfer.To)
+		}
+		line := fmt.Sprintf("%s to %s", transfer.Amount, to)
+		if transfer.Token != "" {
+			line += " of token " + transfer.Token
+		}
+		lines = append(lines, line)
+	}
+	if s.Value == nil {
+		lines = append(lines, "value unknown")
+	}
+	if s.Memo != "" {
+		lines = append(lines, "memo "+strconv.Quote(s.Memo))
+	}
+	return strings.Join(lines, "\n")
+}
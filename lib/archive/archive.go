@@ -0,0 +1,196 @@
+// Package archive uploads the audit data of the mount to S3-compatible object storage, as segments
+// of gzip-compressed JSON Lines. The uploads are signed with AWS Signature Version 4 and address
+// the bucket by path, as AWS S3, MinIO, Ceph and the other S3-compatible stores accept.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is the region the uploads are signed for when none is given
+	DefaultRegion = "us-east-1"
+	// ContentType is the content type of the segments
+	ContentType = "application/gzip"
+	// uploadTimeout bounds an upload, the records stay in the Vault storage when it times out
+	uploadTimeout = 30 * time.Second
+	// signingAlgorithm is the algorithm of AWS Signature Version 4
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	// errorBodyLimit bounds the error response of the store quoted in the errors
+	errorBodyLimit = 512
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidEndpoint = errors.New("endpoint must be an http or https URL without query")
+	ErrNoBucket        = errors.New("bucket is required")
+	ErrInvalidPrefix   = errors.New("prefix may only hold letters, digits and . _ - /")
+	ErrNoCredentials   = errors.New("accessKeyId and secretAccessKey are required")
+	ErrUploadFailed    = errors.New("segment upload failed")
+)
+
+// keyPattern are the characters of the object keys, which need no escaping in their URL
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]*$`)
+
+// S3Options -- the bucket of an S3-compatible store the segments are uploaded to, under Prefix,
+// and the access key signing the uploads
+type S3Options struct {
+	Endpoint        string
+	Bucket          string
+	Region          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Validate checks the options without connecting to the store
+func (o S3Options) Validate() error {
+	endpoint, err := url.Parse(o.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" ||
+		endpoint.RawQuery != "" || endpoint.Fragment != "" {
+		return ErrInvalidEndpoint
+	}
+	if o.Bucket == "" || !keyPattern.MatchString(o.Bucket) || strings.Contains(o.Bucket, "/") {
+		return ErrNoBucket
+	}
+	if !keyPattern.MatchString(o.Prefix) {
+		return ErrInvalidPrefix
+	}
+	if o.AccessKeyID == "" || o.SecretAccessKey == "" {
+		return ErrNoCredentials
+	}
+	return nil
+}
+
+// Uploader stores the segments
+type Uploader interface {
+	// Upload stores body as the object key, under the prefix of the uploader, and returns once the
+	// store acknowledged it
+	Upload(ctx context.Context, key string, body []byte) error
+}
+
+// s3Uploader puts the segments to the bucket with signed requests
+type s3Uploader struct {
+	options  S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Uploader returns an uploader to the bucket of options
+func NewS3Uploader(options S3Options) (Uploader, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if options.Region == "" {
+		options.Region = DefaultRegion
+	}
+	endpoint, _ := url.Parse(options.Endpoint)
+	return &s3Uploader{
+		options:  options,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: uploadTimeout},
+		now:      time.Now,
+	}, nil
+}
+
+func (u *s3Uploader) Upload(ctx context.Context, key string, body []byte) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("%w: invalid key %q", ErrUploadFailed, key)
+	}
+	object := *u.endpoint
+	object.Path = strings.TrimSuffix(object.Path, "/") + "/" + u.options.Bucket + "/" + u.options.Prefix + key
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, object.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	u.sign(req, body, u.now())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUploadFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+		return fmt.Errorf("%w: %s: %s", ErrUploadFailed, resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 authorization of req with body at now, over its host, date and
+// payload hash
+func (u *s3Uploader) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := amzDate[:8] + "/" + u.options.Region + "/s3/aws4_request"
+	stringToSign := signingAlgorithm + "\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hmacSHA256(signingKey(u.options.SecretAccessKey, amzDate[:8], u.options.Region, "s3"),
+		stringToSign)
+
+	req.Header.Set("Authorization", signingAlgorithm+" Credential="+u.options.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(signature))
+}
+
+// signingKey derives the Signature Version 4 key of secret for the date, region and service
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EncodeSegment returns records as a segment: one JSON document per line, gzip-compressed
+func EncodeSegment[T any](records []T) ([]byte, error) {
+	var segment bytes.Buffer
+	writer := gzip.NewWriter(&segment)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return segment.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Options_Validate(t *testing.T) {
+	valid := S3Options{
+		Endpoint: "https://s3.eu-west-1.amazonaws.com", Bucket: "dq-vault-audit", Prefix: "prod/",
+		AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+	}
+	require.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name    string
+		modify  func(o *S3Options)
+		wantErr error
+	}{
+		{"no endpoint", func(o *S3Options) { o.Endpoint = "" }, ErrInvalidEndpoint},
+		{"endpoint without scheme", func(o *S3Options) { o.Endpoint = "s3.amazonaws.com" }, ErrInvalidEndpoint},
+		{"endpoint with query", func(o *S3Options) { o.Endpoint = "https://minio:9000/?x=1" }, ErrInvalidEndpoint},
+		{"no bucket", func(o *S3Options) { o.Bucket = "" }, ErrNoBucket},
+		{"bucket with a path", func(o *S3Options) { o.Bucket = "audit/prod" }, ErrNoBucket},
+		{"prefix to escape", func(o *S3Options) { o.Prefix = "prod audit/" }, ErrInvalidPrefix},
+		{"no secret", func(o *S3Options) { o.SecretAccessKey = "" }, ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := valid
+			tt.modify(&options)
+			require.ErrorIs(t, options.Validate(), tt.wantErr)
+			_, err := NewS3Uploader(options)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	// the key derivation example of the AWS Signature Version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestS3Uploader_Upload(t *testing.T) {
+	type record struct {
+		Sequence int `json:"sequence"`
+	}
+	segment, err := EncodeSegment([]record{{1}, {2}})
+	require.NoError(t, err)
+
+	var got *http.Request
+	var body []byte
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "denied.jsonl.gz") {
+			http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		}
+	}))
+	defer store.Close()
+
+	uploader, err := NewS3Uploader(S3Options{
+		Endpoint: store.URL, Bucket: "audit", Prefix: "prod/", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	uploader.(*s3Uploader).now = func() time.Time { return time.Date(2026, 10, 14, 8, 30, 0, 0, time.UTC) }
+	require.NoError(t, uploader.Upload(context.Background(), "receipts/2026/10/14/1-2.jsonl.gz", segment))
+
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "/audit/prod/receipts/2026/10/14/1-2.jsonl.gz", got.URL.Path)
+	assert.Equal(t, ContentType, got.Header.Get("Content-Type"))
+	assert.Equal(t, "20261014T083000Z", got.Header.Get("X-Amz-Date"))
+	assert.Equal(t, sha256Hex(segment), got.Header.Get("X-Amz-Content-Sha256"))
+	assert.True(t, strings.HasPrefix(got.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "+
+		"Credential=AKIDEXAMPLE/20261014/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, "+
+		"Signature="), got.Header.Get("Authorization"))
+
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	var lines []record
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var line record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []record{{1}, {2}}, lines)
+
+	err = uploader.Upload(context.Background(), "denied.jsonl.gz", segment)
+	require.ErrorIs(t, err, ErrUploadFailed)
+	assert.Contains(t, err.Error(), "AccessDenied")
+	require.ErrorIs(t, uploader.Upload(context.Background(), "a b.jsonl.gz", segment), ErrUploadFailed)
+}
@@ -0,0 +1,122 @@
+// Package attestation signs the responses of the plugin with the Ed25519 attestation key of the
+// mount, so the services consuming them can check they were produced by the plugin and were not
+// altered on the way.
+package attestation
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Field is the key of the attestation in the response data
+const Field = "responseAttestation"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidKey       = errors.New("invalid Ed25519 attestation key")
+	ErrInvalidSignature = errors.New("invalid attestation signature")
+)
+
+// Attestation is the detached signature of a response
+type Attestation struct {
+	// KeyID identifies the public key, see KeyID
+	KeyID string `json:"keyId"`
+	// Timestamp is the signing time, in RFC 3339 with nanoseconds
+	Timestamp string `json:"timestamp"`
+	// Signature is the base64 Ed25519 signature of Message
+	Signature string `json:"signature"`
+}
+
+// KeyID returns the first 8 bytes of the SHA-256 of publicKey, in hex
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Payload returns the canonical JSON of the response data: compact, object keys sorted at every
+// level, numbers as written, no HTML escaping, and without the attestation itself. Verifiers
+// decoding the response must keep the numbers as written (json.Decoder.UseNumber in Go).
+func Payload(data map[string]interface{}) ([]byte, error) {
+	unsigned := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if k != Field {
+			unsigned[k] = v
+		}
+	}
+
+	// the data may hold structs, encoded in field order: decoding them to maps sorts their keys
+	raw, err := encode(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var canonical interface{}
+	if err := decoder.Decode(&canonical); err != nil {
+		return nil, err
+	}
+	return encode(canonical)
+}
+
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Message returns the signed bytes: the timestamp, the request path relative to the mount and the
+// payload, separated by newlines. The path binds a response to the request it answers.
+func Message(timestamp, path string, payload []byte) []byte {
+	msg := make([]byte, 0, len(timestamp)+len(path)+len(payload)+2)
+	msg = append(msg, timestamp...)
+	msg = append(msg, '\n')
+	msg = append(msg, path...)
+	msg = append(msg, '\n')
+	return append(msg, payload...)
+}
+
+// Sign attests the response data of the request to path
+func Sign(key ed25519.PrivateKey, now time.Time, path string, data map[string]interface{}) (*Attestation, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKey
+	}
+	payload, err := Payload(data)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := now.UTC().Format(time.RFC3339Nano)
+	return &Attestation{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Timestamp: timestamp,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, Message(timestamp, path, payload))),
+	}, nil
+}
+
+// Verify checks the attestation of the response data of the request to path
+func Verify(publicKey ed25519.PublicKey, path string, data map[string]interface{}, a *Attestation) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidKey
+	}
+	signature, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	payload, err := Payload(data)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, Message(a.Timestamp, path, payload), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
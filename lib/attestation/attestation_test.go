@@ -0,0 +1,59 @@
+package attestation
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	type signed struct {
+		Signature string `json:"signature"`
+		Address   string `json:"address"`
+	}
+	data := map[string]interface{}{
+		"result": signed{Signature: "0x01", Address: "0x9858"},
+		"value":  uint64(1000000000000000000),
+		"note":   "<a&b>",
+	}
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	a, err := Sign(key, now, "sign", data)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(publicKey), a.KeyID)
+	assert.Equal(t, "2026-10-14T12:00:00Z", a.Timestamp)
+	require.NoError(t, Verify(publicKey, "sign", data, a))
+
+	t.Run("payload is canonical", func(t *testing.T) {
+		payload, err := Payload(data)
+		require.NoError(t, err)
+		assert.Equal(t, `{"note":"<a&b>","result":{"address":"0x9858","signature":"0x01"},"value":1000000000000000000}`,
+			string(payload))
+	})
+
+	t.Run("decoded responses verify", func(t *testing.T) {
+		data[Field] = a
+		raw, err := json.Marshal(data)
+		require.NoError(t, err)
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw, &decoded))
+		require.NoError(t, Verify(publicKey, "sign", decoded, a))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.ErrorIs(t, Verify(publicKey, "sign/digest", data, a), ErrInvalidSignature)
+		other, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		require.ErrorIs(t, Verify(other, "sign", data, a), ErrInvalidSignature)
+		require.ErrorIs(t, Verify(publicKey[:8], "sign", data, a), ErrInvalidKey)
+		_, err = Sign(key[:8], now, "sign", data)
+		require.ErrorIs(t, err, ErrInvalidKey)
+	})
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrNonCanonicalSignature = errors.New("signature is not canonical")
+	ErrHighS                 = errors.New("s is above half the curve order")
+)
+
+// compactSignatureLength is the length of the [R || S || V] signatures of crypto.Sign
+const compactSignatureLength = 65
+
+//nolint:gochecknoglobals // read-only lookup table
+var secp256k1HalfOrder = new(big.Int).Rsh(secp256k1.S256().N, 1)
+
+// CheckSignatureValues checks that (r, s) is a canonical secp256k1 ECDSA signature: r in [1, n-1]
+// and s in [1, n/2], the low-S form required by BIP-62 and EIP-2. The high-S twin of a signature
+// is valid too, but nodes enforcing the low-S rule reject the transactions carrying it.
+func CheckSignatureValues(r, s *big.Int) error {
+	if r == nil || s == nil || r.Sign() <= 0 || r.Cmp(secp256k1.S256().N) >= 0 || s.Sign() <= 0 {
+		return fmt.Errorf("%w: r or s out of range", ErrNonCanonicalSignature)
+	}
+	if s.Cmp(secp256k1HalfOrder) > 0 {
+		return fmt.Errorf("%w: %w", ErrNonCanonicalSignature, ErrHighS)
+	}
+	return nil
+}
+
+// CheckCompactSignature checks a 65 byte [R || S || V] signature, as produced by crypto.Sign, before
+// any offset is added to V
+func CheckCompactSignature(signature []byte) error {
+	if len(signature) != compactSignatureLength || signature[crypto.RecoveryIDOffset] > 1 {
+		return fmt.Errorf("%w: expected 65 bytes with a recovery id of 0 or 1", ErrNonCanonicalSignature)
+	}
+	return CheckSignatureValues(new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:64]))
+}
+
+// CheckDERSignature checks a strict DER encoded signature, without the sighash byte of Bitcoin
+// signatures
+func CheckDERSignature(der []byte) error {
+	signature, err := ecdsa.ParseDERSignature(der)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNonCanonicalSignature, err)
+	}
+	if s := signature.S(); s.IsOverHalfOrder() {
+		return fmt.Errorf("%w: %w", ErrNonCanonicalSignature, ErrHighS)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcecdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSignatureValues(t *testing.T) {
+	n := btcec.S256().N
+	half := new(big.Int).Rsh(n, 1)
+	tests := []struct {
+		name    string
+		r, s    *big.Int
+		wantErr error
+	}{
+		{"low s", big.NewInt(1), half, nil},
+		{"high s", big.NewInt(1), new(big.Int).Add(half, big.NewInt(1)), ErrHighS},
+		{"zero r", big.NewInt(0), big.NewInt(1), ErrNonCanonicalSignature},
+		{"r of the order", new(big.Int).Set(n), big.NewInt(1), ErrNonCanonicalSignature},
+		{"zero s", big.NewInt(1), big.NewInt(0), ErrNonCanonicalSignature},
+		{"missing s", big.NewInt(1), nil, ErrNonCanonicalSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSignatureValues(tt.r, tt.s)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ErrorIs(t, err, ErrNonCanonicalSignature)
+		})
+	}
+}
+
+func TestCheckCompactSignature(t *testing.T) {
+	privateKey, err := crypto.ToECDSA(big.NewInt(1).FillBytes(make([]byte, 32)))
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	signature, err := crypto.Sign(hash[:], privateKey)
+	require.NoError(t, err)
+	require.NoError(t, CheckCompactSignature(signature))
+
+	t.Run("high s twin", func(t *testing.T) {
+		twin := append([]byte{}, signature...)
+		s := new(big.Int).Sub(btcec.S256().N, new(big.Int).SetBytes(signature[32:64]))
+		s.FillBytes(twin[32:64])
+		twin[crypto.RecoveryIDOffset] ^= 1
+		assert.ErrorIs(t, CheckCompactSignature(twin), ErrHighS)
+	})
+
+	t.Run("recovery id with an offset", func(t *testing.T) {
+		offset := append([]byte{}, signature...)
+		offset[crypto.RecoveryIDOffset] += 27
+		assert.ErrorIs(t, CheckCompactSignature(offset), ErrNonCanonicalSignature)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		assert.ErrorIs(t, CheckCompactSignature(signature[:64]), ErrNonCanonicalSignature)
+	})
+}
+
+func TestCheckDERSignature(t *testing.T) {
+	privateKey, _ := btcec.PrivKeyFromBytes(big.NewInt(1).FillBytes(make([]byte, 32)))
+	hash := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	der := btcecdsa.Sign(privateKey, hash[:]).Serialize()
+	require.NoError(t, CheckDERSignature(der))
+
+	parsed, err := btcecdsa.ParseDERSignature(der)
+	require.NoError(t, err)
+	r, s := parsed.R(), parsed.S()
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+
+	t.Run("high s twin", func(t *testing.T) {
+		high := new(big.Int).Sub(btcec.S256().N, new(big.Int).SetBytes(sBytes[:]))
+		twin, err := hex.DecodeString(derSignature(new(big.Int).SetBytes(rBytes[:]), high))
+		require.NoError(t, err)
+		assert.ErrorIs(t, CheckDERSignature(twin), ErrHighS)
+	})
+
+	t.Run("not strict DER", func(t *testing.T) {
+		// r gets a second leading zero byte
+		require.Equal(t, "3045022100", hex.EncodeToString(der[:5]))
+		padded, err := hex.DecodeString("30460222" + "00" + hex.EncodeToString(der[4:]))
+		require.NoError(t, err)
+		assert.ErrorIs(t, CheckDERSignature(padded), ErrNonCanonicalSignature)
+	})
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Operations exposed by the API for a coin
+const (
+	OperationAddress      = "address"
+	OperationAddressBatch = "address/batch"
+	// OperationAddressNext allocates the next unused address index of an account
+	OperationAddressNext = "address/next"
+	OperationSign        = "sign"
+	OperationSPLTransfer = "sign/spl-transfer"
+	OperationSignPSBT    = "sign/psbt"
+	OperationSignSafeTx  = "sign/safe-tx"
+	OperationSignUserOp  = "sign/userop"
+	OperationSignPermit  = "sign/permit"
+	// OperationBuildBTCTx builds and signs Bitcoin transactions from the UTXOs of the wallet
+	OperationBuildBTCTx = "build/btc-tx"
+	// OperationBroadcast relays signed transactions to the node configured for the coin
+	OperationBroadcast = "broadcast"
+	// OperationMultisig derives the addresses of and signs for the Bitcoin multisig wallets of a user
+	OperationMultisig = "multisig"
+	// OperationSignDigest signs raw digests and is not tied to a coin
+	OperationSignDigest = "sign/digest"
+)
+
+// CurveStark is the STARK-friendly curve used by StarkNet accounts
+const CurveStark = "stark"
+
+// Capabilities describes what an adapter supports, so the API can advertise
+// coins straight from the adapter registry
+type Capabilities struct {
+	// Adapter is the short adapter name used in logs
+	Adapter string
+	// CoinTypes are the SLIP-44 coin types handled by the adapter
+	CoinTypes []uint16
+	// Curve is the signing curve (CurveSecp256k1, CurveEd25519 or CurveStark)
+	Curve string
+	// AddressFormats lists the address encodings returned by DeriveAddress
+	AddressFormats []string
+	// Payload is a zero value of the JSON sign payload struct
+	Payload IRawTx
+	// RawPayloadFormat describes the payload of adapters that do not take JSON
+	RawPayloadFormat string
+	// Operations lists the API operations available for the coin types
+	Operations []string
+	// Testnet reports whether isDev changes the derived addresses (testnet mode)
+	Testnet bool
+}
+
+// DefaultOperations returns the operations every adapter supports
+func DefaultOperations() []string {
+	return []string{OperationAddress, OperationAddressBatch, OperationAddressNext, OperationSign}
+}
+
+// PayloadFormat returns the name of the payload struct, or the raw payload format
+func (c Capabilities) PayloadFormat() string {
+	if c.Payload == nil {
+		return c.RawPayloadFormat
+	}
+	return reflect.TypeOf(c.Payload).Name()
+}
+
+// NonceScheme returns the nonce scheme of the signatures of the adapter
+func (c Capabilities) NonceScheme() string {
+	return NonceScheme(c.Curve)
+}
+
+// PayloadFields returns the top level JSON fields of the payload struct
+func (c Capabilities) PayloadFields() []string {
+	if c.Payload == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(c.Payload)
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
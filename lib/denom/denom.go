@@ -0,0 +1,168 @@
+// Package denom converts the amounts of the supported coins between their base units and their
+// human denominations, e.g., wei and ETH, sat and BTC or lamports and SOL. Amounts are decimal
+// strings converted with big integers, never through floats, so no precision is lost.
+package denom
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// maxAmountLength bounds the length of the amounts parsed, well above 2^256 in the smallest unit
+const maxAmountLength = 128
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnsupportedCoin = fmt.Errorf("%w: coinType has no known denomination", lib.ErrUnsupportedCoin)
+	ErrUnknownUnit     = errors.New("unknown unit of the coin type")
+	ErrInvalidAmount   = errors.New("amount must be a non-negative decimal number, e.g., 1.5")
+	ErrBelowBaseUnit   = errors.New("amount has more decimals than the base unit of the coin allows")
+)
+
+// Unit -- a denomination of a coin, worth 10^Decimals base units
+type Unit struct {
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+// Denomination -- the units of a coin, from its base unit to the unit of its Symbol
+type Denomination struct {
+	Symbol string `json:"symbol"`
+	Units  []Unit `json:"units"`
+}
+
+// evm returns the denomination of an EVM chain whose native coin is symbol
+func evm(symbol string) Denomination {
+	return Denomination{Symbol: symbol, Units: []Unit{{"wei", 0}, {"gwei", 9}, {symbol, 18}}}
+}
+
+// denominations maps the coin types of the adapters to their denomination
+//
+//nolint:gochecknoglobals // read-only lookup table
+var denominations = map[uint16]Denomination{
+	slip44.Bitcoin:   {Symbol: "BTC", Units: []Unit{{"sat", 0}, {"BTC", 8}}},
+	slip44.TestNet:   {Symbol: "tBTC", Units: []Unit{{"sat", 0}, {"tBTC", 8}}},
+	slip44.Ether:     evm("ETH"),
+	slip44.Binance:   evm("BNB"),
+	slip44.Polygon:   evm("POL"),
+	slip44.Avalanche: evm("AVAX"),
+	slip44.Fantom:    evm("FTM"),
+	slip44.Harmony:   evm("ONE"),
+	slip44.Solana:    {Symbol: "SOL", Units: []Unit{{"lamports", 0}, {"SOL", 9}}},
+	slip44.Aptos:     {Symbol: "APT", Units: []Unit{{"octas", 0}, {"APT", 8}}},
+	slip44.Sui:       {Symbol: "SUI", Units: []Unit{{"mist", 0}, {"SUI", 9}}},
+	slip44.Ton:       {Symbol: "TON", Units: []Unit{{"nanoton", 0}, {"TON", 9}}},
+	slip44.Hedera:    {Symbol: "HBAR", Units: []Unit{{"tinybar", 0}, {"HBAR", 8}}},
+	slip44.Algorand:  {Symbol: "ALGO", Units: []Unit{{"microalgo", 0}, {"ALGO", 6}}},
+	slip44.Tron:      {Symbol: "TRX", Units: []Unit{{"sun", 0}, {"TRX", 6}}},
+	slip44.Starknet:  {Symbol: "STRK", Units: []Unit{{"fri", 0}, {"STRK", 18}}},
+}
+
+// Of returns the denomination of coinType
+func Of(coinType uint16) (Denomination, error) {
+	denomination, ok := denominations[coinType]
+	if !ok {
+		return Denomination{}, ErrUnsupportedCoin
+	}
+	return denomination, nil
+}
+
+// Base returns the base unit of the coin
+func (d Denomination) Base() Unit {
+	return d.Units[0]
+}
+
+// Unit returns the unit of the coin named name, compared case insensitively. An empty name is the base unit.
+func (d Denomination) Unit(name string) (Unit, error) {
+	if name == "" {
+		return d.Base(), nil
+	}
+	for _, unit := range d.Units {
+		if strings.EqualFold(unit.Name, name) {
+			return unit, nil
+		}
+	}
+	return Unit{}, ErrUnknownUnit
+}
+
+// Parse returns the amount in unit as base units. Amounts finer than the base unit are rejected
+// rather than rounded.
+func Parse(amount string, unit Unit) (*big.Int, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" || len(amount) > maxAmountLength {
+		return nil, ErrInvalidAmount
+	}
+	whole, fraction, _ := strings.Cut(amount, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || (fraction != "" && !isDigits(fraction)) {
+		return nil, ErrInvalidAmount
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > unit.Decimals {
+		return nil, ErrBelowBaseUnit
+	}
+	base, ok := new(big.Int).SetString(whole+fraction+strings.Repeat("0", unit.Decimals-len(fraction)), 10)
+	if !ok {
+		return nil, ErrInvalidAmount
+	}
+	return base, nil
+}
+
+// Format returns the amount of base units in unit, without trailing zeros
+func Format(base *big.Int, unit Unit) string {
+	digits := new(big.Int).Abs(base).String()
+	sign := ""
+	if base.Sign() < 0 {
+		sign = "-"
+	}
+	if unit.Decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= unit.Decimals {
+		digits = strings.Repeat("0", unit.Decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-unit.Decimals], strings.TrimRight(digits[len(digits)-unit.Decimals:], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// Convert converts amount of coinType from the unit named from to the unit named to
+func Convert(coinType uint16, amount, from, to string) (string, error) {
+	denomination, err := Of(coinType)
+	if err != nil {
+		return "", err
+	}
+	fromUnit, err := denomination.Unit(from)
+	if err != nil {
+		return "", err
+	}
+	toUnit, err := denomination.Unit(to)
+	if err != nil {
+		return "", err
+	}
+	base, err := Parse(amount, fromUnit)
+	if err != nil {
+		return "", err
+	}
+	return Format(base, toUnit), nil
+}
+
+// isDigits reports whether s is made of decimal digits only
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,72 @@
+package denom
+
+import (
+	"log/slog"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		coinType      uint16
+		amount, from  string
+		to, converted string
+	}{
+		{slip44.Ether, "1.5", "ETH", "wei", "1500000000000000000"},
+		{slip44.Ether, "1500000000000000000", "wei", "eth", "1.5"},
+		{slip44.Ether, "21", "gwei", "ETH", "0.000000021"},
+		{slip44.Bitcoin, "0.00000001", "BTC", "", "1"},
+		{slip44.Bitcoin, "100000000", "sat", "BTC", "1"},
+		{slip44.Solana, ".25", "SOL", "lamports", "250000000"},
+		{slip44.Solana, "0", "lamports", "SOL", "0"},
+		// above the 2^256 of the EVM words, without loss
+		{slip44.Ether, strings.Repeat("9", 80), "ETH", "ETH", strings.Repeat("9", 80)},
+	} {
+		converted, err := Convert(tc.coinType, tc.amount, tc.from, tc.to)
+		require.NoError(t, err, tc)
+		assert.Equal(t, tc.converted, converted, tc)
+	}
+
+	for amount, want := range map[string]error{
+		"1e18":                   ErrInvalidAmount,
+		"-1":                     ErrInvalidAmount,
+		"1.2.3":                  ErrInvalidAmount,
+		"":                       ErrInvalidAmount,
+		"0.0000000000000000001":  ErrBelowBaseUnit,
+		strings.Repeat("1", 200): ErrInvalidAmount,
+	} {
+		_, err := Convert(slip44.Ether, amount, "ETH", "wei")
+		require.ErrorIs(t, err, want, amount)
+	}
+	_, err := Convert(slip44.Ether, "1", "sat", "wei")
+	require.ErrorIs(t, err, ErrUnknownUnit)
+	_, err = Convert(slip44.Cardano, "1", "", "")
+	require.ErrorIs(t, err, ErrUnsupportedCoin)
+	require.ErrorIs(t, err, lib.ErrUnsupportedCoin)
+}
+
+func TestFormat(t *testing.T) {
+	unit := Unit{Name: "ETH", Decimals: 18}
+	assert.Equal(t, "-0.5", Format(big.NewInt(-5e17), unit))
+	assert.Equal(t, "0.000000000000000001", Format(big.NewInt(1), unit))
+	assert.Equal(t, "5", Format(big.NewInt(5e18), unit))
+}
+
+// every coin of the adapters has a denomination
+func TestOf(t *testing.T) {
+	for _, capabilities := range adapter.GetInventory(slog.New(slog.DiscardHandler)).Capabilities() {
+		for _, coinType := range capabilities.CoinTypes {
+			denomination, err := Of(coinType)
+			require.NoError(t, err, coinType)
+			assert.Equal(t, 0, denomination.Base().Decimals, coinType)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Bitcoin address types of the watch-only accounts, named after their output scripts
+const (
+	AddressTypeP2PKH      = "p2pkh"
+	AddressTypeP2SHP2WPKH = "p2sh-p2wpkh"
+	AddressTypeP2WPKH     = "p2wpkh"
+	AddressTypeP2TR       = "p2tr"
+)
+
+// BIP44Purpose is the purpose of the BIP-44 account paths m/44'/<coinType>'/<account>'
+const BIP44Purpose uint32 = 44
+
+const (
+	// descriptorInputCharset and descriptorChecksumCharset are defined by BIP-380
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	descriptorChecksumLength  = 8
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	// ErrInvalidDescriptorCharacter is returned for descriptors with characters outside the BIP-380 charset
+	ErrInvalidDescriptorCharacter = errors.New("invalid descriptor character")
+	ErrUnknownAddressType         = errors.New("unknown address type")
+	ErrNotBelowExtendedKey        = errors.New("path is not below the path of the extended public key")
+	ErrHardenedFromPublic         = errors.New("hardened components cannot be derived from an extended public key")
+)
+
+// bitcoinAddressType describes the BIP-44 style account of an address type
+type bitcoinAddressType struct {
+	name    string
+	purpose uint32
+	// descriptor wraps the key expression in the output script expression
+	descriptor func(key string) string
+	// slip132 are the SLIP-132 version bytes for mainnet and testnet, used by Electrum; nil for xpub/tpub
+	slip132 [2][]byte
+}
+
+// bitcoinAddressTypes lists the exported address types, legacy first
+func bitcoinAddressTypes() []bitcoinAddressType {
+	return []bitcoinAddressType{
+		{
+			name:       AddressTypeP2PKH,
+			purpose:    BIP44Purpose,
+			descriptor: func(key string) string { return "pkh(" + key + ")" },
+		},
+		{
+			name:       AddressTypeP2SHP2WPKH,
+			purpose:    49,
+			descriptor: func(key string) string { return "sh(wpkh(" + key + "))" },
+			slip132:    [2][]byte{{0x04, 0x9d, 0x7c, 0xb2}, {0x04, 0x4a, 0x52, 0x62}},
+		},
+		{
+			name:       AddressTypeP2WPKH,
+			purpose:    84,
+			descriptor: func(key string) string { return "wpkh(" + key + ")" },
+			slip132:    [2][]byte{{0x04, 0xb2, 0x47, 0x46}, {0x04, 0x5f, 0x1c, 0xf6}},
+		},
+		{
+			name:       AddressTypeP2TR,
+			purpose:    86,
+			descriptor: func(key string) string { return "tr(" + key + ")" },
+		},
+	}
+}
+
+// Descriptors are the output descriptors of the receive and change chains of an account
+type Descriptors struct {
+	Receive string `json:"receive"`
+	Change  string `json:"change"`
+}
+
+// WatchOnlyAccount is the public part of a Bitcoin account, enough to derive and watch its addresses
+type WatchOnlyAccount struct {
+	AddressType string      `json:"addressType"`
+	Path        string      `json:"path"`
+	Xpub        string      `json:"xpub"`
+	Slip132     string      `json:"slip132,omitempty"`
+	Descriptors Descriptors `json:"descriptors"`
+}
+
+// BitcoinWatchOnlyAccounts returns the accounts of every address type at the given account index,
+// on testnet when testnet is set. Descriptors carry the key origin and a BIP-380 checksum.
+func BitcoinWatchOnlyAccounts(seed []byte, account uint32, testnet bool) ([]WatchOnlyAccount, error) {
+	net, coinType, netIndex := &chaincfg.MainNetParams, uint32(slip44.Bitcoin), 0
+	if testnet {
+		net, coinType, netIndex = &chaincfg.TestNet3Params, uint32(slip44.TestNet), 1
+	}
+	fingerprint, err := MasterFingerprint(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	addressTypes := bitcoinAddressTypes()
+	accounts := make([]WatchOnlyAccount, 0, len(addressTypes))
+	for _, addressType := range addressTypes {
+		path := []uint32{addressType.purpose, coinType, account}
+		xpub, err := ExtendedPublicKey(seed, path, net)
+		if err != nil {
+			return nil, err
+		}
+
+		key := "[" + fingerprint + "/" + formatHardenedPath(path, "h") + "]" + xpub.String()
+		receive, err := DescriptorWithChecksum(addressType.descriptor(key + "/0/*"))
+		if err != nil {
+			return nil, err
+		}
+		change, err := DescriptorWithChecksum(addressType.descriptor(key + "/1/*"))
+		if err != nil {
+			return nil, err
+		}
+
+		watchOnly := WatchOnlyAccount{
+			AddressType: addressType.name,
+			Path:        FormatAccountPath(path[0], path[1], path[2]),
+			Xpub:        xpub.String(),
+			Descriptors: Descriptors{Receive: receive, Change: change},
+		}
+		if version := addressType.slip132[netIndex]; version != nil {
+			slip132, err := xpub.CloneWithVersion(version)
+			if err != nil {
+				return nil, err
+			}
+			watchOnly.Slip132 = slip132.String()
+		}
+		accounts = append(accounts, watchOnly)
+	}
+	return accounts, nil
+}
+
+// ExtendedPublicKey derives the extended public key of the hardened path, given without the m/ prefix
+// nor the hardened offset, for the network net
+func ExtendedPublicKey(seed []byte, path []uint32, net *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	indices := make([]uint32, 0, len(path))
+	for _, index := range path {
+		indices = append(indices, hdkeychain.HardenedKeyStart+index)
+	}
+	return ExtendedPublicKeyAt(seed, indices, net)
+}
+
+// ExtendedPublicKeyAt derives the extended public key of the BIP-32 indices, hardened ones
+// including the hardened offset, for the network net
+func ExtendedPublicKeyAt(seed []byte, indices []uint32, net *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	key, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indices {
+		if key, err = key.Derive(index); err != nil {
+			return nil, err
+		}
+	}
+	return key.Neuter()
+}
+
+// ChildPublicKeyAt derives the extended public key of the BIP-32 indices from the extended public
+// key parent of parentIndices. indices must extend parentIndices with non hardened components.
+func ChildPublicKeyAt(parent *hdkeychain.ExtendedKey, parentIndices, indices []uint32) (*hdkeychain.ExtendedKey,
+	error) {
+	if len(indices) < len(parentIndices) {
+		return nil, ErrNotBelowExtendedKey
+	}
+	for i, index := range parentIndices {
+		if indices[i] != index {
+			return nil, ErrNotBelowExtendedKey
+		}
+	}
+
+	key := parent
+	for _, index := range indices[len(parentIndices):] {
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, ErrHardenedFromPublic
+		}
+		var err error
+		if key, err = key.Derive(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// BitcoinAddressType returns the address type of the accounts of purpose: legacy for 44',
+// nested segwit for 49', taproot for 86' and native segwit otherwise
+func BitcoinAddressType(purpose uint32) string {
+	for _, addressType := range bitcoinAddressTypes() {
+		if addressType.purpose == purpose {
+			return addressType.name
+		}
+	}
+	return AddressTypeP2WPKH
+}
+
+// OutputDescriptor wraps the key expression key in the output script of addressType and
+// appends the BIP-380 checksum
+func OutputDescriptor(addressType, key string) (string, error) {
+	for _, t := range bitcoinAddressTypes() {
+		if t.name == addressType {
+			return DescriptorWithChecksum(t.descriptor(key))
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownAddressType, addressType)
+}
+
+// FormatOriginPath formats the BIP-32 indices as the key origin path of a descriptor, e.g. 84h/0h/0h/0/1
+func FormatOriginPath(indices []uint32) string {
+	components := make([]string, 0, len(indices))
+	for _, index := range indices {
+		if index >= hdkeychain.HardenedKeyStart {
+			components = append(components, strconv.FormatUint(uint64(index-hdkeychain.HardenedKeyStart), 10)+"h")
+			continue
+		}
+		components = append(components, strconv.FormatUint(uint64(index), 10))
+	}
+	return strings.Join(components, "/")
+}
+
+// formatHardenedPath formats path with every component hardened with marker
+func formatHardenedPath(path []uint32, marker string) string {
+	components := make([]string, 0, len(path))
+	for _, index := range path {
+		components = append(components, strconv.FormatUint(uint64(index), 10)+marker)
+	}
+	return strings.Join(components, "/")
+}
+
+// DescriptorWithChecksum appends the BIP-380 checksum to descriptor
+func DescriptorWithChecksum(descriptor string) (string, error) {
+	checksum, err := DescriptorChecksum(descriptor)
+	if err != nil {
+		return "", err
+	}
+	return descriptor + "#" + checksum, nil
+}
+
+// DescriptorChecksum computes the BIP-380 checksum of descriptor, given without checksum
+func DescriptorChecksum(descriptor string) (string, error) {
+	c := uint64(1)
+	class, classCount := 0, 0
+	for _, ch := range descriptor {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDescriptorCharacter, ch)
+		}
+		c = descriptorPolymod(c, uint64(pos&31))
+		class = class*3 + pos>>5
+		if classCount++; classCount == 3 {
+			c = descriptorPolymod(c, uint64(class))
+			class, classCount = 0, 0
+		}
+	}
+	if classCount > 0 {
+		c = descriptorPolymod(c, uint64(class))
+	}
+	for range descriptorChecksumLength {
+		c = descriptorPolymod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, descriptorChecksumLength)
+	for j := range checksum {
+		checksum[j] = descriptorChecksumCharset[(c>>(5*(descriptorChecksumLength-1-j)))&31]
+	}
+	return string(checksum), nil
+}
+
+//nolint:mnd // generator constants of the BIP-380 checksum
+func descriptorPolymod(c, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// FormatAccountPath formats the hardened account path m/<purpose>'/<coinType>'/<account>'
+func FormatAccountPath(purpose, coinType, account uint32) string {
+	return "m/" + formatHardenedPath([]uint32{purpose, coinType, account}, "'")
+}
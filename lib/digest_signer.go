@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/payment-system/dq-vault/lib/secp"
+)
+
+const (
+	// CurveSecp256k1 signs digests with BIP-32 derived secp256k1 keys
+	CurveSecp256k1 = "secp256k1"
+	// CurveEd25519 signs digests with SLIP-0010 derived ed25519 keys
+	CurveEd25519 = "ed25519"
+	// DigestLength is the length of the digests accepted for signing
+	DigestLength = 32
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnsupportedCurve    = errors.New("unsupported curve, expected secp256k1 or ed25519")
+	ErrInvalidDigestLength = errors.New("digest must be exactly 32 bytes")
+)
+
+// SignDigest signs a 32 byte digest with the key derived at derivationPath on the given curve.
+// secp256k1 signatures are 65 bytes [R || S || V] with a low S value, and the public key is
+// compressed; ed25519 signs the digest bytes as the message.
+func SignDigest(seed []byte, curve, derivationPath string, digest []byte) (signature, publicKey []byte, err error) {
+	if len(digest) != DigestLength {
+		return nil, nil, ErrInvalidDigestLength
+	}
+
+	switch curve {
+	case CurveSecp256k1:
+		key, err := DerivePrivateKey(seed, derivationPath, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey := key.ToECDSA()
+		signature, err := secp.Sign(digest, key.Serialize())
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := CheckCompactSignature(signature); err != nil {
+			return nil, nil, err
+		}
+		return signature, crypto.CompressPubkey(&privateKey.PublicKey), nil
+
+	case CurveEd25519:
+		privateKey, err := DeriveEd25519PrivateKey(seed, derivationPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ed25519.Sign(privateKey, digest), privateKey.Public().(ed25519.PublicKey), nil
+
+	default:
+		return nil, nil, ErrUnsupportedCurve
+	}
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// ed25519SeedModifier is the HMAC key used by SLIP-0010 for the ed25519 master key
+	ed25519SeedModifier = "ed25519 seed"
+	// HardenedOffset is the first hardened child index, the offset of the hardened components
+	HardenedOffset = 0x80000000
+	// keyLength is the length of a SLIP-0010 private key and chain code
+	keyLength = 32
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrNonHardenedComponent = errors.New("ed25519 derivation supports hardened components only")
+)
+
+// DeriveEd25519PrivateKey derives an ed25519 private key from the seed following
+// SLIP-0010 (https://github.com/satoshilabs/slips/blob/master/slip-0010.md).
+//
+// ed25519 does not support public (non-hardened) derivation, so every component
+// of the derivation path must be hardened, e.g. m/44'/501'/0'/0'.
+func DeriveEd25519PrivateKey(seed []byte, path string) (ed25519.PrivateKey, error) {
+	components, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte(ed25519SeedModifier))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:keyLength], sum[keyLength:]
+
+	for _, index := range components {
+		if index < HardenedOffset {
+			return nil, &PathError{Path: path, Err: ErrNonHardenedComponent}
+		}
+
+		data := make([]byte, 0, 1+keyLength+4)
+		data = append(data, 0x00)
+		data = append(data, key...)
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac = hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum = mac.Sum(nil)
+		key, chainCode = sum[:keyLength], sum[keyLength:]
+	}
+
+	return ed25519.NewKeyFromSeed(key), nil
+}
@@ -0,0 +1,88 @@
+// Package entropy provides the randomness of the generated mnemonics: crypto/rand, optionally
+// augmented with an external source, and a health check detecting broken sources before any
+// mnemonic is generated from them.
+package entropy
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sources of the entropy of the mnemonics
+const (
+	// SourceCryptoRand -- the entropy of crypto/rand, the default
+	SourceCryptoRand = "crypto/rand"
+	// SourceAugmented -- the entropy of crypto/rand XORed with the one of an external Sourcer,
+	// e.g., the seal of a Vault Enterprise mount with entropy augmentation
+	SourceAugmented = "augmented"
+)
+
+const (
+	// sampleSize is the size of the samples read by Check, the entropy of a 24 words mnemonic
+	sampleSize = 32
+	// samples is the number of samples read by Check
+	samples = 16
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownSource  = errors.New("entropy source must be crypto/rand or augmented")
+	ErrReadFailed     = errors.New("entropy source could not be read")
+	ErrShortOutput    = errors.New("entropy source returned fewer bytes than requested")
+	ErrRepeatedOutput = errors.New("entropy source repeated its output")
+	ErrConstantOutput = errors.New("entropy source returned constant bytes")
+)
+
+// Sourcer -- an external source of entropy
+type Sourcer interface {
+	GetRandom(bytes int) ([]byte, error)
+}
+
+// Augment returns a reader of the bytes of r XORed with the ones of sourcer, at least as random as
+// the better of both. Reads fail when either fails.
+func Augment(r io.Reader, sourcer Sourcer) io.Reader {
+	return &augmented{r: r, sourcer: sourcer}
+}
+
+type augmented struct {
+	r       io.Reader
+	sourcer Sourcer
+}
+
+func (a *augmented) Read(p []byte) (int, error) {
+	if _, err := io.ReadFull(a.r, p); err != nil {
+		return 0, err
+	}
+	external, err := a.sourcer.GetRandom(len(p))
+	if err != nil {
+		return 0, err
+	}
+	if len(external) != len(p) {
+		return 0, ErrShortOutput
+	}
+	subtle.XORBytes(p, p, external)
+	return len(p), nil
+}
+
+// Check reads samples of r and returns an error when r is broken: a read failing, a sample of
+// constant bytes or a sample repeating a previous one.
+func Check(r io.Reader) error {
+	seen := make(map[string]bool, samples)
+	sample := make([]byte, sampleSize)
+	for range samples {
+		if _, err := io.ReadFull(r, sample); err != nil {
+			return fmt.Errorf("%w: %w", ErrReadFailed, err)
+		}
+		if bytes.Count(sample, sample[:1]) == sampleSize {
+			return ErrConstantOutput
+		}
+		if seen[string(sample)] {
+			return ErrRepeatedOutput
+		}
+		seen[string(sample)] = true
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package entropy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errSourcer = errors.New("seal unavailable")
+
+// sourcer returns the bytes of random, or err
+type sourcer struct {
+	random []byte
+	err    error
+}
+
+func (s sourcer) GetRandom(n int) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return bytes.Repeat(s.random, n/len(s.random)+1)[:n], nil
+}
+
+// repeating returns the same sample of random bytes again and again
+type repeating struct{ sample []byte }
+
+func (r repeating) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.sample[i%len(r.sample)]
+	}
+	return len(p), nil
+}
+
+func TestCheck(t *testing.T) {
+	require.NoError(t, Check(rand.Reader))
+	require.NoError(t, Check(Augment(rand.Reader, sourcer{random: []byte{0x5a}})))
+
+	sample := make([]byte, sampleSize)
+	_, err := rand.Read(sample)
+	require.NoError(t, err)
+	require.ErrorIs(t, Check(repeating{sample: sample}), ErrRepeatedOutput)
+	require.ErrorIs(t, Check(bytes.NewReader(make([]byte, sampleSize*samples))), ErrConstantOutput)
+	require.ErrorIs(t, Check(bytes.NewReader(sample)), ErrReadFailed)
+	require.ErrorIs(t, Check(Augment(rand.Reader, sourcer{err: errSourcer})), errSourcer)
+}
+
+func TestAugment(t *testing.T) {
+	p := make([]byte, 4)
+	_, err := io.ReadFull(Augment(bytes.NewReader([]byte{1, 2, 3, 4}), sourcer{random: []byte{0xff}}), p)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xfe, 0xfd, 0xfc, 0xfb}, p)
+}
@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"errors"
+)
+
+// Sentinel errors of the categories of failures of lib and of its subpackages, for the programs
+// embedding them. The specific errors wrap them, so errors.Is matches either, e.g.,
+// adapter.ErrNoAdapterFound is also ErrUnsupportedCoin.
+var (
+	ErrUnsupportedCoin = errors.New("unsupported coin type")
+	ErrInvalidPath     = errors.New("invalid derivation path")
+)
+
+// PathError -- the derivation path Path could not be parsed; Err is the cause, e.g.,
+// ErrInvalidComponent. It matches ErrInvalidPath and its cause with errors.Is.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return ErrInvalidPath.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns ErrInvalidPath and the cause of the error
+func (e *PathError) Unwrap() []error {
+	return []error{ErrInvalidPath, e.Err}
+}
@@ -0,0 +1,69 @@
+// Package escrow encrypts the mnemonics of the users to the public key of a third-party
+// custodian, so the custodian can recover them from the escrow records without any access to
+// the plugin. Records are libsodium sealed boxes (X25519, XSalsa20-Poly1305).
+package escrow
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/payment-system/dq-vault/lib/sealedbox"
+)
+
+// Algorithm names the encryption of the escrow records
+const Algorithm = sealedbox.Algorithm
+
+// KeySize is the size of the X25519 custodian keys
+const KeySize = sealedbox.KeySize
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidKey        = errors.New("custodian public key must be 32 bytes hex encoded")
+	ErrInvalidCiphertext = errors.New("escrow ciphertext cannot be decrypted with this key")
+)
+
+// Secret is the plaintext of an escrow record. The UUID binds the record to its user.
+type Secret struct {
+	UUID       string `json:"uuid"`
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase"`
+	// NamespaceIndex is the index of the derivation namespace of the user, see lib.NamespaceSeed
+	NamespaceIndex *uint32 `json:"namespaceIndex,omitempty"`
+}
+
+// ParsePublicKey decodes a hex encoded X25519 custodian public key
+func ParsePublicKey(s string) (*[KeySize]byte, error) {
+	key, err := sealedbox.ParsePublicKey(s)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// KeyID returns the first 8 bytes of the SHA-256 of publicKey, in hex
+func KeyID(publicKey *[KeySize]byte) string {
+	return sealedbox.KeyID(publicKey)
+}
+
+// Seal encrypts secret to publicKey and returns the base64 ciphertext
+func Seal(publicKey *[KeySize]byte, secret Secret) (string, error) {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return "", err
+	}
+	return sealedbox.Seal(publicKey, plaintext)
+}
+
+// Open decrypts the base64 ciphertext of Seal with the key pair of the custodian
+func Open(publicKey, privateKey *[KeySize]byte, ciphertext string) (Secret, error) {
+	plaintext, err := sealedbox.Open(publicKey, privateKey, ciphertext)
+	if err != nil {
+		return Secret{}, ErrInvalidCiphertext
+	}
+
+	var secret Secret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return Secret{}, err
+	}
+	return secret, nil
+}
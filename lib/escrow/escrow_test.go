@@ -0,0 +1,44 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestSealOpen(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	parsed, err := ParsePublicKey(hex.EncodeToString(publicKey[:]))
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, parsed)
+
+	secret := Secret{UUID: "user-1", Mnemonic: "abandon abandon about", Passphrase: "pass"}
+	ciphertext, err := Seal(parsed, secret)
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "abandon")
+
+	opened, err := Open(publicKey, privateKey, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, secret, opened)
+
+	t.Run("other keys cannot open the record", func(t *testing.T) {
+		otherPublicKey, otherPrivateKey, err := box.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		_, err = Open(otherPublicKey, otherPrivateKey, ciphertext)
+		require.ErrorIs(t, err, ErrInvalidCiphertext)
+	})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, key := range []string{"", "00", "zz" + hex.EncodeToString(make([]byte, 31))} {
+		_, err := ParsePublicKey(key)
+		require.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+	assert.Len(t, KeyID(&[KeySize]byte{}), 16)
+}
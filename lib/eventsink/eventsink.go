@@ -0,0 +1,202 @@
+// Package eventsink publishes the register, sign and policy events of the mount to a Kafka topic.
+// The events are queued in the Vault storage before they are published, so none is lost while
+// the brokers are unreachable; the publisher only reports which were written.
+package eventsink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Types of the events
+const (
+	// TypeRegister is a user registered by register or register_uuid
+	TypeRegister = "register"
+	// TypeSign is a signature returned by a sign path
+	TypeSign = "sign"
+	// TypePolicy is a change of the configuration of the mount
+	TypePolicy = "policy"
+	// TypeLockout is a user locked by its policy violations
+	TypeLockout = "lockout"
+)
+
+// SASL mechanisms of the brokers
+const (
+	MechanismPlain       = "plain"
+	MechanismSCRAMSHA256 = "scram-sha-256"
+	MechanismSCRAMSHA512 = "scram-sha-512"
+)
+
+// writeTimeout bounds a publish, the events stay queued when it times out
+const writeTimeout = 10 * time.Second
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrNoBrokers        = errors.New("brokers must list host:port addresses")
+	ErrNoTopic          = errors.New("topic is required")
+	ErrUnknownMechanism = errors.New("saslMechanism must be plain, scram-sha-256 or scram-sha-512")
+	ErrNoCredentials    = errors.New("username and password are required with saslMechanism")
+	ErrInvalidCACert    = errors.New("caCert must be PEM encoded certificates")
+)
+
+// Event -- an event of the mount, as published. Events of a user share its UUID as message key,
+// so they are kept in order on one partition.
+type Event struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Path     string                 `json:"path"`
+	UUID     string                 `json:"uuid,omitempty"`
+	EntityID string                 `json:"entityId,omitempty"`
+	Time     time.Time              `json:"time"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// KafkaOptions -- the brokers and topic the events are published to, and how the producer
+// authenticates: SASL with one of the mechanisms, over TLS when TLS is set. CACert holds the PEM
+// certificates the brokers are verified with, the system roots without it.
+type KafkaOptions struct {
+	Brokers       []string
+	Topic         string
+	SASLMechanism string
+	Username      string
+	Password      string
+	TLS           bool
+	CACert        string
+}
+
+// Validate checks the options without connecting to the brokers
+func (o KafkaOptions) Validate() error {
+	if len(o.Brokers) == 0 {
+		return ErrNoBrokers
+	}
+	for _, broker := range o.Brokers {
+		host, port, err := net.SplitHostPort(broker)
+		if err != nil || host == "" {
+			return fmt.Errorf("%w: %s", ErrNoBrokers, broker)
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("%w: %s", ErrNoBrokers, broker)
+		}
+	}
+	if o.Topic == "" {
+		return ErrNoTopic
+	}
+	if _, err := o.mechanism(); err != nil {
+		return err
+	}
+	_, err := o.tlsConfig()
+	return err
+}
+
+func (o KafkaOptions) mechanism() (sasl.Mechanism, error) {
+	if o.SASLMechanism == "" {
+		return nil, nil
+	}
+	if o.Username == "" || o.Password == "" {
+		return nil, ErrNoCredentials
+	}
+	switch o.SASLMechanism {
+	case MechanismPlain:
+		return plain.Mechanism{Username: o.Username, Password: o.Password}, nil
+	case MechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, o.Username, o.Password)
+	case MechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, o.Username, o.Password)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownMechanism, o.SASLMechanism)
+}
+
+func (o KafkaOptions) tlsConfig() (*tls.Config, error) {
+	if !o.TLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(o.CACert)) {
+			return nil, ErrInvalidCACert
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// Publisher writes events to the sink
+type Publisher interface {
+	// Publish writes events, in order, and returns once the sink acknowledged all of them
+	Publish(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// kafkaPublisher writes the events to a topic, acknowledged by all the in-sync replicas
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher returns a publisher to the topic of options
+func NewKafkaPublisher(options KafkaOptions) (Publisher, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	mechanism, err := options.mechanism()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(options.Brokers...),
+		Topic:        options.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// the queue is published at once, the batches do not wait for more events
+		BatchTimeout: time.Millisecond,
+		WriteTimeout: writeTimeout,
+		Transport: &kafka.Transport{
+			ClientID: "dq-vault",
+			SASL:     mechanism,
+			TLS:      tlsConfig,
+		},
+	}}, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, events []Event) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(event.UUID),
+			Value: value,
+			Time:  event.Time,
+			Headers: []kafka.Header{
+				{Key: "type", Value: []byte(event.Type)},
+				{Key: "id", Value: []byte(event.ID)},
+			},
+		})
+	}
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
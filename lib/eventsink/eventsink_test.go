@@ -0,0 +1,55 @@
+package eventsink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaOptions_Validate(t *testing.T) {
+	valid := KafkaOptions{Brokers: []string{"kafka-1:9092", "10.0.0.2:9093"}, Topic: "dq-vault.events"}
+	require.NoError(t, valid.Validate())
+
+	withSASL := valid
+	withSASL.SASLMechanism, withSASL.Username, withSASL.Password = MechanismSCRAMSHA512, "vault", "secret"
+	withSASL.TLS = true
+	require.NoError(t, withSASL.Validate())
+
+	tests := []struct {
+		name    string
+		modify  func(o *KafkaOptions)
+		wantErr error
+	}{
+		{"no brokers", func(o *KafkaOptions) { o.Brokers = nil }, ErrNoBrokers},
+		{"broker without port", func(o *KafkaOptions) { o.Brokers = []string{"kafka-1"} }, ErrNoBrokers},
+		{"broker with invalid port", func(o *KafkaOptions) { o.Brokers = []string{"kafka-1:http"} }, ErrNoBrokers},
+		{"no topic", func(o *KafkaOptions) { o.Topic = "" }, ErrNoTopic},
+		{"unknown mechanism", func(o *KafkaOptions) {
+			o.SASLMechanism, o.Username, o.Password = "gssapi", "vault", "secret"
+		}, ErrUnknownMechanism},
+		{"mechanism without credentials", func(o *KafkaOptions) { o.SASLMechanism = MechanismPlain }, ErrNoCredentials},
+		{"invalid ca cert", func(o *KafkaOptions) { o.TLS, o.CACert = true, "not a certificate" }, ErrInvalidCACert},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := valid
+			tt.modify(&options)
+			require.ErrorIs(t, options.Validate(), tt.wantErr)
+			_, err := NewKafkaPublisher(options)
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewKafkaPublisher(t *testing.T) {
+	publisher, err := NewKafkaPublisher(KafkaOptions{
+		Brokers: []string{"kafka-1:9092"}, Topic: "dq-vault.events",
+		SASLMechanism: MechanismPlain, Username: "vault", Password: "secret", TLS: true,
+	})
+	require.NoError(t, err)
+	writer := publisher.(*kafkaPublisher).writer
+	assert.Equal(t, "dq-vault.events", writer.Topic)
+	assert.NotNil(t, writer.Transport)
+	require.NoError(t, publisher.Close())
+}
@@ -0,0 +1,66 @@
+// Package fee decodes the fee rate of the sign payloads, checked against the fee bounds of the
+// chain before signing: the gas price in gwei for EVM chains, sat/vB for Bitcoin.
+package fee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnsupportedCoin = fmt.Errorf("%w: fee bounds are supported for EVM and Bitcoin coin types only", lib.ErrUnsupportedCoin)
+	ErrNoFee           = errors.New("payload has no gasPrice")
+)
+
+// Units of the fee rates
+const (
+	UnitGwei        = "gwei"
+	UnitSatPerVByte = "sat/vB"
+)
+
+// weiPerGwei converts the EVM gas prices to gwei
+const weiPerGwei = 1e9
+
+// Unit returns the unit of the fee rates of coinType, or "" when they are not decoded
+func Unit(coinType uint16) string {
+	logger := slog.New(slog.DiscardHandler)
+	switch {
+	case evm.NewEthereumAdapter(logger).CanDo(coinType):
+		return UnitGwei
+	case bitcoin.NewBitcoinAdapter(logger).CanDo(coinType):
+		return UnitSatPerVByte
+	}
+	return ""
+}
+
+// Rate returns the fee rate of the payload of coinType, in its Unit
+func Rate(coinType uint16, payload string) (float64, error) {
+	switch Unit(coinType) {
+	case UnitGwei:
+		var tx lib.EthereumRawTx
+		if err := json.Unmarshal([]byte(payload), &tx); err != nil {
+			return 0, err
+		}
+		if tx.GasPrice == nil {
+			return 0, ErrNoFee
+		}
+		gwei, _ := new(big.Rat).SetFrac(tx.GasPrice, big.NewInt(weiPerGwei)).Float64()
+		return gwei, nil
+	case UnitSatPerVByte:
+		packet, err := bitcoin.DecodePSBT(payload)
+		if err != nil {
+			return 0, err
+		}
+		_, rate, err := packet.FeeRate()
+		return rate, err
+	}
+	return 0, ErrUnsupportedCoin
+}
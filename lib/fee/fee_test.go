@@ -0,0 +1,29 @@
+package fee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit(t *testing.T) {
+	assert.Equal(t, UnitGwei, Unit(60))
+	assert.Equal(t, UnitSatPerVByte, Unit(0))
+	assert.Empty(t, Unit(501))
+}
+
+func TestRate(t *testing.T) {
+	rate, err := Rate(60, `{"nonce":0,"value":1,"gasLimit":21000,"gasPrice":1500000000,"to":"0x0","chainId":1}`)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, rate, 1e-9)
+
+	_, err = Rate(60, `{"nonce":0,"value":1,"gasLimit":21000,"to":"0x0","chainId":1}`)
+	require.ErrorIs(t, err, ErrNoFee)
+
+	_, err = Rate(0, "not a psbt")
+	require.Error(t, err)
+
+	_, err = Rate(501, "")
+	require.ErrorIs(t, err, ErrUnsupportedCoin)
+}
@@ -0,0 +1,265 @@
+// Package hooks provides the payload pre-processors run on sign payloads before they are
+// validated and signed. A hook chain is an ordered list of hook specs, "name" or "name=argument",
+// configured per coin type; every hook reports the action it took, for the audit record.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/adapter/evm"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownHook     = errors.New("unknown payload hook")
+	ErrInvalidArgument = errors.New("invalid payload hook argument")
+	ErrUnsupportedCoin = fmt.Errorf("%w: payload hook does not support coinType", lib.ErrUnsupportedCoin)
+	ErrRejected        = errors.New("payload rejected by hook")
+	ErrInvalidChainID  = errors.New("chainId must be a positive decimal integer")
+	ErrWrongChain      = errors.New("chainId is not the chain of the mount")
+	ErrNoLegacyForm    = errors.New("transaction type has no legacy form")
+)
+
+// Unchanged is the action of hooks that left the payload as it was
+const Unchanged = "unchanged"
+
+// Action records what a hook of the chain did to the payload
+type Action struct {
+	Hook   string `json:"hook"`
+	Action string `json:"action"`
+}
+
+// hook is a built-in pre-processor of the decoded payload fields
+type hook struct {
+	// supports reports whether the hook handles the payloads of coinType
+	supports func(coinType uint16) bool
+	// argument reports whether the hook requires an argument, and checks it
+	argument func(arg string) error
+	apply    func(fields map[string]json.RawMessage, arg string) (string, error)
+}
+
+// isEVM reports whether coinType is signed by the EVM adapter
+func isEVM(coinType uint16) bool {
+	return evm.NewEthereumAdapter(slog.New(slog.DiscardHandler)).CanDo(coinType)
+}
+
+// builtins are the hooks a chain can name
+//
+//nolint:gochecknoglobals // read-only lookup table
+var builtins = map[string]hook{
+	"evm-chain-id":      {supports: isEVM, argument: parseChainID, apply: applyChainID},
+	"evm-normalize-gas": {supports: isEVM, apply: applyNormalizeGas},
+	"evm-strip-tx-type": {supports: isEVM, apply: applyStripTxType},
+}
+
+// Names returns the names of the built-in hooks, sorted
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parse splits a hook spec into its hook and argument
+func parse(spec string) (string, hook, string, error) {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(spec), "=")
+	h, ok := builtins[name]
+	if !ok {
+		return "", hook{}, "", fmt.Errorf("%w: %s", ErrUnknownHook, name)
+	}
+	switch {
+	case h.argument == nil && hasArg:
+		return "", hook{}, "", fmt.Errorf("%w: %s takes no argument", ErrInvalidArgument, name)
+	case h.argument != nil:
+		if err := h.argument(arg); err != nil {
+			return "", hook{}, "", fmt.Errorf("%w: %s: %w", ErrInvalidArgument, name, err)
+		}
+	}
+	return name, h, arg, nil
+}
+
+// Validate checks that every hook of chain exists, supports coinType and has a valid argument
+func Validate(coinType uint16, chain []string) error {
+	for _, spec := range chain {
+		name, h, _, err := parse(spec)
+		if err != nil {
+			return err
+		}
+		if !h.supports(coinType) {
+			return fmt.Errorf("%w: %s: %d", ErrUnsupportedCoin, name, coinType)
+		}
+	}
+	return nil
+}
+
+// Apply runs chain, in order, on the JSON payload of coinType and returns the processed payload
+// with the action of every hook. Payloads that are not JSON objects are rejected.
+func Apply(coinType uint16, payload string, chain []string) (string, []Action, error) {
+	if len(chain) == 0 {
+		return payload, nil, nil
+	}
+	if err := Validate(coinType, chain); err != nil {
+		return "", nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil || fields == nil {
+		return "", nil, lib.ErrInvalidPayload
+	}
+
+	actions := make([]Action, 0, len(chain))
+	for _, spec := range chain {
+		_, h, arg, err := parse(spec)
+		if err != nil {
+			return "", nil, err
+		}
+		action, err := h.apply(fields, arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s: %w", ErrRejected, spec, err)
+		}
+		actions = append(actions, Action{Hook: spec, Action: action})
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(encoded), actions, nil
+}
+
+func parseChainID(arg string) error {
+	_, err := chainIDArgument(arg)
+	return err
+}
+
+func chainIDArgument(arg string) (*big.Int, error) {
+	chainID, ok := new(big.Int).SetString(arg, 10)
+	if !ok || chainID.Sign() <= 0 {
+		return nil, ErrInvalidChainID
+	}
+	return chainID, nil
+}
+
+// applyChainID sets the EIP-155 chainId of transactions without one, and rejects transactions
+// for another chain
+func applyChainID(fields map[string]json.RawMessage, arg string) (string, error) {
+	want, err := chainIDArgument(arg)
+	if err != nil {
+		return "", err
+	}
+	raw, ok := fields["chainId"]
+	if !ok || bytes.Equal(raw, []byte("null")) {
+		fields["chainId"] = json.RawMessage(want.String())
+		return "set chainId to " + want.String(), nil
+	}
+	chainID, err := quantity(raw)
+	if err != nil {
+		return "", fmt.Errorf("chainId: %w", err)
+	}
+	if chainID.Cmp(want) != 0 {
+		return "", fmt.Errorf("%w: %s, expected %s", ErrWrongChain, chainID, want)
+	}
+	return Unchanged, nil
+}
+
+// gasFields are the quantities of EVM payloads, signed as JSON numbers
+//
+//nolint:gochecknoglobals // read-only lookup table
+var gasFields = []string{"nonce", "value", "gasLimit", "gasPrice", "chainId", "maxFeePerGas", "maxPriorityFeePerGas"}
+
+// applyNormalizeGas turns the quantities given as hex or decimal strings into JSON numbers,
+// and renames the gas field of JSON-RPC transactions to gasLimit
+func applyNormalizeGas(fields map[string]json.RawMessage, _ string) (string, error) {
+	var normalized []string
+	renamed := false
+	if raw, ok := fields["gas"]; ok {
+		if _, hasLimit := fields["gasLimit"]; !hasLimit {
+			fields["gasLimit"], renamed = raw, true
+			normalized = append(normalized, "gas to gasLimit")
+		}
+		delete(fields, "gas")
+	}
+	for _, name := range gasFields {
+		raw, ok := fields[name]
+		if !ok || len(raw) == 0 || raw[0] != '"' {
+			continue
+		}
+		n, err := quantity(raw)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		fields[name] = json.RawMessage(n.String())
+		if name != "gasLimit" || !renamed {
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) == 0 {
+		return Unchanged, nil
+	}
+	return "normalized " + strings.Join(normalized, ", "), nil
+}
+
+// typedTxFields are the fields of EIP-2930 and EIP-1559 transactions, which the EVM adapter
+// signs as legacy transactions
+//
+//nolint:gochecknoglobals // read-only lookup table
+var typedTxFields = []string{"type", "accessList", "maxFeePerGas", "maxPriorityFeePerGas"}
+
+// applyStripTxType turns EIP-2930 and EIP-1559 transactions into legacy ones, paying the max fee
+// per gas when no gasPrice is set, and rejects the types that cannot be turned into one
+func applyStripTxType(fields map[string]json.RawMessage, _ string) (string, error) {
+	if raw, ok := fields["type"]; ok {
+		txType, err := quantity(raw)
+		if err != nil {
+			return "", fmt.Errorf("type: %w", err)
+		}
+		if !txType.IsUint64() || txType.Uint64() > 2 {
+			return "", fmt.Errorf("%w: %s", ErrNoLegacyForm, txType)
+		}
+	}
+
+	if _, ok := fields["gasPrice"]; !ok {
+		if maxFee, ok := fields["maxFeePerGas"]; ok {
+			fields["gasPrice"] = maxFee
+		}
+	}
+	var stripped []string
+	for _, name := range typedTxFields {
+		if _, ok := fields[name]; ok {
+			delete(fields, name)
+			stripped = append(stripped, name)
+		}
+	}
+	if len(stripped) == 0 {
+		return Unchanged, nil
+	}
+	return "stripped " + strings.Join(stripped, ", "), nil
+}
+
+// quantity decodes a JSON number, or a hex or decimal string, as a non negative integer
+func quantity(raw json.RawMessage) (*big.Int, error) {
+	s := string(raw)
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		s = str
+	}
+	base := 10
+	if rest, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		s, base = rest, 16
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("%w: not a quantity", lib.ErrInvalidPayload)
+	}
+	return n, nil
+}
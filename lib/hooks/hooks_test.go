@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib"
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		chain   []string
+		payload string
+		want    map[string]interface{}
+		actions []string
+		wantErr error
+	}{
+		{
+			name:    "chain id is set when missing",
+			chain:   []string{"evm-chain-id=137"},
+			payload: `{"nonce":1}`,
+			want:    map[string]interface{}{"nonce": 1.0, "chainId": 137.0},
+			actions: []string{"set chainId to 137"},
+		},
+		{
+			name:    "matching chain id is kept",
+			chain:   []string{"evm-chain-id=1"},
+			payload: `{"chainId":"0x1"}`,
+			want:    map[string]interface{}{"chainId": "0x1"},
+			actions: []string{Unchanged},
+		},
+		{
+			name:    "other chain is rejected",
+			chain:   []string{"evm-chain-id=1"},
+			payload: `{"chainId":56}`,
+			wantErr: ErrWrongChain,
+		},
+		{
+			name:    "gas fields are normalized",
+			chain:   []string{"evm-normalize-gas"},
+			payload: `{"gas":"0x5208","gasPrice":"20000000000","nonce":3}`,
+			want:    map[string]interface{}{"gasLimit": 21000.0, "gasPrice": 2e10, "nonce": 3.0},
+			actions: []string{"normalized gas to gasLimit, gasPrice"},
+		},
+		{
+			name:    "invalid quantity",
+			chain:   []string{"evm-normalize-gas"},
+			payload: `{"value":"ten"}`,
+			wantErr: lib.ErrInvalidPayload,
+		},
+		{
+			name:    "eip-1559 transactions become legacy",
+			chain:   []string{"evm-strip-tx-type"},
+			payload: `{"type":2,"maxFeePerGas":30,"maxPriorityFeePerGas":2,"accessList":[]}`,
+			want:    map[string]interface{}{"gasPrice": 30.0},
+			actions: []string{"stripped type, accessList, maxFeePerGas, maxPriorityFeePerGas"},
+		},
+		{
+			name:    "blob transactions are rejected",
+			chain:   []string{"evm-strip-tx-type"},
+			payload: `{"type":"0x3"}`,
+			wantErr: ErrNoLegacyForm,
+		},
+		{
+			name:    "unknown hook",
+			chain:   []string{"evm-gas-oracle"},
+			payload: `{}`,
+			wantErr: ErrUnknownHook,
+		},
+		{
+			name:    "not an object",
+			chain:   []string{"evm-normalize-gas"},
+			payload: `[1]`,
+			wantErr: lib.ErrInvalidPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, actions, err := Apply(slip44.Ether, tt.payload, tt.chain)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var fields map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(got), &fields))
+			assert.Equal(t, tt.want, fields)
+			require.Len(t, actions, len(tt.actions))
+			for i, action := range actions {
+				assert.Equal(t, tt.chain[i], action.Hook)
+				assert.Equal(t, tt.actions[i], action.Action)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(slip44.Polygon, []string{"evm-normalize-gas", "evm-chain-id=137"}))
+	require.ErrorIs(t, Validate(slip44.Solana, []string{"evm-normalize-gas"}), ErrUnsupportedCoin)
+	require.ErrorIs(t, Validate(slip44.Ether, []string{"evm-chain-id"}), ErrInvalidArgument)
+	require.ErrorIs(t, Validate(slip44.Ether, []string{"evm-strip-tx-type=2"}), ErrInvalidArgument)
+	assert.Equal(t, []string{"evm-chain-id", "evm-normalize-gas", "evm-strip-tx-type"}, Names())
+}
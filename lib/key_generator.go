@@ -1,6 +1,10 @@
 package lib
 
 import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
@@ -8,13 +12,20 @@ import (
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec"
-	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcutil"
+	"github.com/payment-system/dq-vault/lib/secp"
 	bip32 "github.com/tyler-smith/go-bip32"
 )
 
 const (
 	// DerivationPathCapacity is the initial capacity for derivation path slices
 	DerivationPathCapacity = 8
+
+	// fingerprintLength is the number of bytes of a BIP-32 key fingerprint
+	fingerprintLength = 4
+
+	// secp256k1SeedModifier is the HMAC key used by BIP-32 for the secp256k1 master key
+	secp256k1SeedModifier = "Bitcoin seed"
 )
 
 // Static error variables to avoid dynamic error creation
@@ -52,33 +63,121 @@ func DerivePrivateKey(seed []byte, path string, _ bool) (*btcec.PrivateKey, erro
 		return nil, err
 	}
 
-	key, err := bip32.NewMasterKey(seed)
+	key, chainCode, err := masterPrivateKey(seed)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, n := range deriavtionPath {
-		key, err = key.NewChildKey(n)
+		child, childChainCode, err := childPrivateKey(key, chainCode, n)
+		clear(key)
 		if err != nil {
 			return nil, err
 		}
+		key, chainCode = child, childChainCode
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), key)
+	clear(key)
+	return privKey, nil
+}
+
+// masterPrivateKey returns the BIP-32 master key of seed and its chain code
+func masterPrivateKey(seed []byte) (key, chainCode []byte, err error) {
+	mac := hmac.New(sha512.New, []byte(secp256k1SeedModifier))
+	mac.Write(seed)
+	intermediary := mac.Sum(nil)
+	if !validPrivateKey(intermediary[:keyLength]) {
+		return nil, nil, bip32.ErrInvalidPrivateKey
+	}
+	return intermediary[:keyLength], intermediary[keyLength:], nil
+}
+
+// childPrivateKey returns the BIP-32 child key of index (CKDpriv) and its chain code. The public
+// keys of the non-hardened steps are derived by lib/secp, the slowest part of the derivation.
+func childPrivateKey(key, chainCode []byte, index uint32) (child, childChainCode []byte, err error) {
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, key...)
+	} else if data, err = secp.PublicKey(key); err != nil {
+		return nil, nil, err
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	intermediary := mac.Sum(nil)
+	clear(data)
+
+	sum := new(big.Int).Add(new(big.Int).SetBytes(intermediary[:keyLength]), new(big.Int).SetBytes(key))
+	child = sum.Mod(sum, btcec.S256().N).FillBytes(make([]byte, keyLength))
+	if !validPrivateKey(child) {
+		return nil, nil, bip32.ErrInvalidPrivateKey
 	}
+	return child, intermediary[keyLength:], nil
+}
 
-	privKey, err := hdkeychain.NewKeyFromString(key.B58Serialize())
+// NamespaceSeed returns the seed isolating the keys of a derivation namespace of seed: the BIP-32
+// key and chain code of its master key at the hardened index. Every curve derives from it as from
+// a seed, so two namespaces of one seed share no key whatever their paths.
+func NamespaceSeed(seed []byte, index uint32) ([]byte, error) {
+	if index >= HardenedOffset {
+		return nil, fmt.Errorf("%w [0, %d]: %d", ErrComponentOutOfHardenedRange, HardenedOffset-1, index)
+	}
+	key, chainCode, err := masterPrivateKey(seed)
 	if err != nil {
 		return nil, err
 	}
+	child, childChainCode, err := childPrivateKey(key, chainCode, HardenedOffset+index)
+	clear(key)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := append(child, childChainCode...)
+	clear(child)
+	return namespaced, nil
+}
+
+// validPrivateKey reports whether key is a non-zero scalar below the order of the curve
+func validPrivateKey(key []byte) bool {
+	scalar := new(big.Int).SetBytes(key)
+	return scalar.Sign() > 0 && scalar.Cmp(btcec.S256().N) < 0
+}
 
-	return privKey.ECPrivKey()
+// MasterFingerprint returns the BIP-32 fingerprint of the master key of seed: the
+// first 4 bytes of the hash160 of its compressed public key, hex encoded.
+// It identifies a wallet without revealing any key material.
+func MasterFingerprint(seed []byte) (string, error) {
+	key, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(btcutil.Hash160(key.PublicKey().Key)[:fingerprintLength]), nil
 }
 
-// ParseDerivationPath converts a user specified derivation path string to the
+// ParseDerivationPath returns the BIP-32 indices of path, hardened ones including the
+// hardened offset. Relative paths are appended to the default root path.
+// Its errors are a *PathError.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	return parseDerivationPath(path)
+}
+
+// parseDerivationPath converts a user specified derivation path string to the
 // internal binary representation.
 //
 // Full derivation paths need to start with the `m/` prefix, relative derivation
 // paths (which will get appended to the default root path) must not have prefixes
-// in front of the first element. Whitespace is ignored.
+// in front of the first element. Whitespace is ignored. Its errors are a *PathError.
 func parseDerivationPath(path string) (derivationPath, error) {
+	result, err := parseComponents(path)
+	if err != nil {
+		return nil, &PathError{Path: path, Err: err}
+	}
+	return result, nil
+}
+
+// parseComponents parses the components of path for parseDerivationPath
+func parseComponents(path string) (derivationPath, error) {
 	// Pre-allocate result slice with estimated capacity
 	result := make(derivationPath, 0, DerivationPathCapacity)
 
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bip32 "github.com/tyler-smith/go-bip32"
+)
+
+func TestDerivePrivateKey(t *testing.T) {
+	// test vector 1 of BIP-32
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	key, err := DerivePrivateKey(seed, "m/0'/1/2'/2/1000000000", false)
+	require.NoError(t, err)
+	assert.Equal(t, "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+		hex.EncodeToString(key.Serialize()))
+
+	_, err = DerivePrivateKey(seed, "m/x", false)
+	assert.ErrorIs(t, err, ErrInvalidComponent)
+}
+
+func TestNamespaceSeed(t *testing.T) {
+	// the key and chain code of m/0' of test vector 1 of BIP-32
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	namespaced, err := NamespaceSeed(seed, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"+
+		"47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", hex.EncodeToString(namespaced))
+
+	other, err := NamespaceSeed(seed, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, namespaced, other)
+	_, err = NamespaceSeed(seed, HardenedOffset)
+	require.ErrorIs(t, err, ErrComponentOutOfHardenedRange)
+}
+
+// the errors of the derivation paths match ErrInvalidPath and their cause
+func TestParseDerivationPath_Errors(t *testing.T) {
+	for path, cause := range map[string]error{
+		"/0":            ErrAmbiguousPath,
+		"m":             ErrEmptyDerivationPath,
+		"m/x":           ErrInvalidComponent,
+		"m/4294967296":  ErrComponentOutOfRange,
+		"m/2147483648'": ErrComponentOutOfHardenedRange,
+	} {
+		_, err := ParseDerivationPath(path)
+		require.ErrorIs(t, err, ErrInvalidPath, path)
+		require.ErrorIs(t, err, cause, path)
+		var pathErr *PathError
+		require.ErrorAs(t, err, &pathErr, path)
+		assert.Equal(t, path, pathErr.Path)
+	}
+
+	_, err := DeriveEd25519PrivateKey(make([]byte, 64), "m/44'/501'/0")
+	require.ErrorIs(t, err, ErrInvalidPath)
+	require.ErrorIs(t, err, ErrNonHardenedComponent)
+}
+
+// TestDerivePrivateKey_GoBIP32 checks the derivation against go-bip32, which derived the keys of
+// the plugin before
+func TestDerivePrivateKey_GoBIP32(t *testing.T) {
+	paths := []string{"m/44'/60'/0'/0/0", "m/84'/0'/0'/1/7", "m/0/1/2/3", "m/44'/195'/3'/0/2147483647", "0/5"}
+	for i := 0; i < 32; i++ {
+		seed := sha512.Sum512([]byte{byte(i)})
+		for _, path := range paths {
+			key, err := DerivePrivateKey(seed[:], path, false)
+			require.NoError(t, err)
+			assert.Equal(t, goBIP32PrivateKey(t, seed[:], path), key.Serialize(), fmt.Sprintf("seed %d %s", i, path))
+		}
+	}
+}
+
+func goBIP32PrivateKey(t *testing.T, seed []byte, path string) []byte {
+	t.Helper()
+	indices, err := parseDerivationPath(path)
+	require.NoError(t, err)
+	key, err := bip32.NewMasterKey(seed)
+	require.NoError(t, err)
+	for _, n := range indices {
+		key, err = key.NewChildKey(n)
+		require.NoError(t, err)
+	}
+	extended, err := hdkeychain.NewKeyFromString(key.B58Serialize())
+	require.NoError(t, err)
+	privateKey, err := extended.ECPrivKey()
+	require.NoError(t, err)
+	return privateKey.Serialize()
+}
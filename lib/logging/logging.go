@@ -0,0 +1,191 @@
+// Package logging provides the slog handler used by the plugin: a runtime
+// adjustable level and redaction of secret material in every record.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// ErrInvalidLevel is returned for log levels other than DEBUG, INFO, WARN and ERROR
+var ErrInvalidLevel = errors.New("invalid log level, expected DEBUG, INFO, WARN or ERROR")
+
+// sensitiveKeys are the attribute keys, lower cased without separators, whose values are never logged
+//
+//nolint:gochecknoglobals // read-only lookup table
+var sensitiveKeys = map[string]struct{}{
+	"apikey":       {},
+	"backupwords":  {},
+	"mnemonic":     {},
+	"passphrase":   {},
+	"password":     {},
+	"privatekey":   {},
+	"seed":         {},
+	"secret":       {},
+	"sessiontoken": {},
+	// the personal data of the travel rule is kept out of the plugin logs
+	"travelrule": {},
+	"xprv":       {},
+}
+
+// mnemonicLengths are the BIP-39 mnemonic word counts
+//
+//nolint:gochecknoglobals // read-only lookup table
+var mnemonicLengths = map[int]struct{}{12: {}, 15: {}, 18: {}, 21: {}, 24: {}}
+
+// NewLogger returns a logger writing text records to w at the level of level,
+// with sensitive attributes redacted
+func NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewRedactingHandler(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// ParseLevel parses DEBUG, INFO, WARN or ERROR, case insensitively
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToUpper(s) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+		if err := level.UnmarshalText([]byte(s)); err != nil {
+			return level, fmt.Errorf("%w: %s", ErrInvalidLevel, s)
+		}
+		return level, nil
+	default:
+		return level, fmt.Errorf("%w: %s", ErrInvalidLevel, s)
+	}
+}
+
+// IsSensitiveKey reports whether values logged under key must be redacted.
+// Keys are compared case insensitively, ignoring '_' and '-'.
+func IsSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	_, ok := sensitiveKeys[normalized]
+	return ok
+}
+
+// looksLikeMnemonic reports whether s is a BIP-39 mnemonic, whatever key it is logged under
+func looksLikeMnemonic(s string) bool {
+	words := strings.Fields(s)
+	if _, ok := mnemonicLengths[len(words)]; !ok {
+		return false
+	}
+	for _, word := range words {
+		if _, ok := bip39.GetWordIndex(word); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// RedactingHandler wraps a slog.Handler and redacts sensitive attributes,
+// including attributes added with Logger.With and inside groups
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler wraps next
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+// Enabled reports whether next handles records at level
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the attributes of r before passing it to next
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs redacts attrs before passing them to next
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, redactAttr(a))
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+// WithGroup returns a handler nesting the following attributes in group name
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, member := range group {
+			redacted = append(redacted, redactAttr(member))
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindString:
+		if looksLikeMnemonic(value.String()) {
+			return slog.String(a.Key, Redacted)
+		}
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok && looksLikeMnemonic(err.Error()) {
+			return slog.String(a.Key, Redacted)
+		}
+	default:
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// Sanitize returns a copy of data, as decoded from JSON, with sensitive keys and mnemonic looking
+// values replaced by Redacted. It is used for request and response data persisted outside the logs.
+func Sanitize(data map[string]interface{}) map[string]interface{} {
+	sanitized := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if IsSensitiveKey(key) {
+			sanitized[key] = Redacted
+			continue
+		}
+		sanitized[key] = sanitizeValue(value)
+	}
+	return sanitized
+}
+
+func sanitizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return Sanitize(v)
+	case []interface{}:
+		sanitized := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			sanitized = append(sanitized, sanitizeValue(element))
+		}
+		return sanitized
+	case string:
+		return RedactString(v)
+	default:
+		return v
+	}
+}
+
+// RedactString returns Redacted when s looks like a mnemonic, and s otherwise
+func RedactString(s string) string {
+	if looksLikeMnemonic(s) {
+		return Redacted
+	}
+	return s
+}
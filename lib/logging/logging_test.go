@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, slog.LevelDebug).With(slog.String("passphrase", "hunter2"))
+
+	logger.Info("register",
+		"mnemonic", "some words",
+		"private_key", "1ab42cc4",
+		slog.Group("user", slog.String("Seed", "5eb00bbd"), slog.String("uuid", "abc")),
+		"note", testMnemonic,
+		"error", errors.New(testMnemonic),
+		"address", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "some words", "1ab42cc4", "5eb00bbd", "abandon"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, "passphrase="+Redacted)
+	assert.Contains(t, out, "user.uuid=abc")
+	assert.Contains(t, out, "address=0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	logger := NewLogger(&buf, level)
+
+	level.Set(slog.LevelWarn)
+	logger.Info("hidden")
+	assert.Empty(t, buf.String())
+
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"DEBUG": slog.LevelDebug, "info": slog.LevelInfo, "Warn": slog.LevelWarn, "ERROR": slog.LevelError,
+	} {
+		got, err := ParseLevel(s)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	for _, s := range []string{"", "FATAL", "INFO+2"} {
+		_, err := ParseLevel(s)
+		assert.ErrorIs(t, err, ErrInvalidLevel)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	data := map[string]interface{}{
+		"uuid":       "abc",
+		"passphrase": "hunter2",
+		"payload":    `{"nonce":1}`,
+		"user": map[string]interface{}{
+			"mnemonic": "some words",
+			"note":     testMnemonic,
+		},
+		"items":      []interface{}{testMnemonic, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", float64(7)},
+		"travelRule": map[string]interface{}{"originator": map[string]interface{}{"accountNumber": "customer-42"}},
+	}
+
+	got := Sanitize(data)
+
+	assert.Equal(t, map[string]interface{}{
+		"uuid":       "abc",
+		"passphrase": Redacted,
+		"payload":    `{"nonce":1}`,
+		"user": map[string]interface{}{
+			"mnemonic": Redacted,
+			"note":     Redacted,
+		},
+		"items":      []interface{}{Redacted, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", float64(7)},
+		"travelRule": Redacted,
+	}, got)
+	assert.Equal(t, "hunter2", data["passphrase"], "input must not be modified")
+}
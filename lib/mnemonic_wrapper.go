@@ -1,7 +1,9 @@
 package lib
 
 import (
+	"crypto/rand"
 	"errors"
+	"io"
 
 	"github.com/tyler-smith/go-bip39"
 )
@@ -9,6 +11,9 @@ import (
 const (
 	// DefaultEntropyLength is the default entropy length for mnemonic generation
 	DefaultEntropyLength = 256
+	// minEntropyLength and maxEntropyLength bound the entropy lengths of BIP-39, multiples of 32
+	minEntropyLength = 128
+	maxEntropyLength = 256
 )
 
 // Static error variables to avoid dynamic error creation
@@ -26,8 +31,16 @@ func GenerateMnemonic() (string, error) {
 // MnemonicFromEntropy will return a string consisting of the mnemonic words for
 // the given entropy.
 func MnemonicFromEntropy(entropyLength int) (string, error) {
-	entropy, err := bip39.NewEntropy(entropyLength)
-	if err != nil {
+	return MnemonicFromReader(rand.Reader, entropyLength)
+}
+
+// MnemonicFromReader returns the mnemonic of entropyLength bits of entropy read from r
+func MnemonicFromReader(r io.Reader, entropyLength int) (string, error) {
+	if entropyLength%32 != 0 || entropyLength < minEntropyLength || entropyLength > maxEntropyLength {
+		return "", bip39.ErrEntropyLengthInvalid
+	}
+	entropy := make([]byte, entropyLength/8)
+	if _, err := io.ReadFull(r, entropy); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const benchMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestMnemonicFromReader(t *testing.T) {
+	mnemonic, err := MnemonicFromReader(bytes.NewReader(make([]byte, 16)), 128)
+	require.NoError(t, err)
+	assert.Equal(t, benchMnemonic, mnemonic)
+
+	_, err = MnemonicFromReader(bytes.NewReader(make([]byte, 32)), 160+8)
+	require.ErrorIs(t, err, bip39.ErrEntropyLengthInvalid)
+	_, err = MnemonicFromReader(bytes.NewReader(make([]byte, 16)), 256)
+	require.Error(t, err, "the reader is too short")
+}
+
+func BenchmarkGenerateMnemonic(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := GenerateMnemonic(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSeedFromMnemonic measures the PBKDF2 stretching paid by every request deriving a key
+func BenchmarkSeedFromMnemonic(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := SeedFromMnemonic(benchMnemonic, "passphrase"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDerivePrivateKey(b *testing.B) {
+	seed, err := SeedFromMnemonic(benchMnemonic, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DerivePrivateKey(seed, "m/44'/60'/0'/0/0", false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
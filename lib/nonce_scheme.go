@@ -0,0 +1,21 @@
+package lib
+
+// Nonce schemes of the signatures produced by the adapters, see NonceScheme
+const (
+	// NonceRFC6979 derives the ECDSA nonce from the private key and the message hash (RFC 6979)
+	NonceRFC6979 = "rfc6979"
+	// NonceRFC8032 derives the EdDSA nonce from the private key and the message (RFC 8032)
+	NonceRFC8032 = "rfc8032"
+)
+
+// NonceScheme returns how the signatures on curve get their nonce. Every ECDSA and EdDSA signer of
+// the plugin derives it from the key and the message, so no signature depends on a random number
+// generator and signing the same message twice gives the same signature. The taproot inputs of
+// PSBTs are BIP-340 Schnorr signatures, whose nonce is derived the same way mixed with auxiliary
+// randomness.
+func NonceScheme(curve string) string {
+	if curve == CurveEd25519 {
+		return NonceRFC8032
+	}
+	return NonceRFC6979
+}
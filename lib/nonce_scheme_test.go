@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcecdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6979Nonce is the nonce generation of RFC 6979 section 3.2 with HMAC-SHA256, written from the
+// RFC so that the signers are checked against an implementation they do not share
+func rfc6979Nonce(curve elliptic.Curve, x *big.Int, hash []byte) *big.Int {
+	q := curve.Params().N
+	rolen := (q.BitLen() + 7) / 8
+	bx := x.FillBytes(make([]byte, rolen))
+	bh := new(big.Int).Mod(bits2intRef(hash, q.BitLen()), q).FillBytes(make([]byte, rolen))
+	mac := func(key []byte, data ...[]byte) []byte {
+		h := hmac.New(sha256.New, key)
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+
+	v := make([]byte, sha256.Size)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, sha256.Size)
+	k = mac(k, v, []byte{0x00}, bx, bh)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, bx, bh)
+	v = mac(k, v)
+	for {
+		var t []byte
+		for len(t) < rolen {
+			v = mac(k, v)
+			t = append(t, v...)
+		}
+		nonce := bits2intRef(t, q.BitLen())
+		if nonce.Sign() > 0 && nonce.Cmp(q) < 0 {
+			return nonce
+		}
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
+
+func bits2intRef(b []byte, qlen int) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - qlen; excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+	return v
+}
+
+// rfc6979Sign signs hash with the nonce of rfc6979Nonce; lowS normalizes s as the secp256k1 signers do
+func rfc6979Sign(curve elliptic.Curve, x *big.Int, hash []byte, lowS bool) (r, s *big.Int) {
+	q := curve.Params().N
+	k := rfc6979Nonce(curve, x, hash)
+	r, _ = curve.ScalarBaseMult(k.FillBytes(make([]byte, (q.BitLen()+7)/8)))
+	r.Mod(r, q)
+	s = new(big.Int).Mul(r, x)
+	s.Add(s, bits2intRef(hash, q.BitLen())).Mul(s, new(big.Int).ModInverse(k, q)).Mod(s, q)
+	if lowS && s.Cmp(new(big.Int).Rsh(q, 1)) > 0 {
+		s.Sub(q, s)
+	}
+	return r, s
+}
+
+func mustHexInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, ok := new(big.Int).SetString(s, 16)
+	require.True(t, ok, s)
+	return v
+}
+
+// TestRFC6979Reference checks the reference implementation against the P-256 SHA-256 vectors of
+// RFC 6979 appendix A.2.5
+func TestRFC6979Reference(t *testing.T) {
+	x := mustHexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")
+	tests := []struct {
+		message string
+		k, r, s string
+	}{
+		{
+			message: "sample",
+			k:       "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			message: "test",
+			k:       "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			hash := sha256.Sum256([]byte(tt.message))
+			assert.Equal(t, mustHexInt(t, tt.k), rfc6979Nonce(elliptic.P256(), x, hash[:]))
+			r, s := rfc6979Sign(elliptic.P256(), x, hash[:], false)
+			assert.Equal(t, mustHexInt(t, tt.r), r)
+			assert.Equal(t, mustHexInt(t, tt.s), s)
+		})
+	}
+}
+
+// TestNonceScheme_Secp256k1 checks that the secp256k1 signers use RFC 6979 nonces: go-ethereum signs
+// the EVM and Tron transactions and the secp256k1 digests, btcec the Bitcoin ECDSA inputs
+func TestNonceScheme_Secp256k1(t *testing.T) {
+	n := btcec.S256().N
+	keys := map[string]*big.Int{
+		"one":         big.NewInt(1),
+		"order minus": new(big.Int).Sub(n, big.NewInt(1)),
+		"arbitrary":   mustHexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"),
+	}
+	messages := []string{
+		"Satoshi Nakamoto",
+		"All those moments will be lost in time, like tears in rain. Time to die...",
+		"sample",
+	}
+	// published secp256k1 vectors, key 1, low S
+	published := map[string]string{
+		"Satoshi Nakamoto": "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8" +
+			"2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+		"All those moments will be lost in time, like tears in rain. Time to die...": "" +
+			"8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b" +
+			"547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+	}
+
+	for name, x := range keys {
+		for _, message := range messages {
+			t.Run(name+"/"+message, func(t *testing.T) {
+				hash := sha256.Sum256([]byte(message))
+				r, s := rfc6979Sign(btcec.S256(), x, hash[:], true)
+				want := hex.EncodeToString(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+				if name == "one" && published[message] != "" {
+					require.Equal(t, published[message], want)
+				}
+
+				privateKey, err := crypto.ToECDSA(x.FillBytes(make([]byte, 32)))
+				require.NoError(t, err)
+				signature, err := crypto.Sign(hash[:], privateKey)
+				require.NoError(t, err)
+				assert.Equal(t, want, hex.EncodeToString(signature[:64]), "go-ethereum")
+
+				btcKey, _ := btcec.PrivKeyFromBytes(x.FillBytes(make([]byte, 32)))
+				der, err := hex.DecodeString(derSignature(r, s))
+				require.NoError(t, err)
+				assert.Equal(t, der, btcecdsa.Sign(btcKey, hash[:]).Serialize(), "btcec")
+			})
+		}
+	}
+}
+
+// derSignature returns the hex DER encoding of (r, s)
+func derSignature(r, s *big.Int) string {
+	integer := func(v *big.Int) []byte {
+		b := v.Bytes()
+		if b[0]&0x80 != 0 {
+			b = append([]byte{0x00}, b...)
+		}
+		return append([]byte{0x02, byte(len(b))}, b...)
+	}
+	body := append(integer(r), integer(s)...)
+	return hex.EncodeToString(append([]byte{0x30, byte(len(body))}, body...))
+}
+
+// TestNonceScheme_Ed25519 checks the ed25519 signer against the first vector of RFC 8032 section 7.1
+func TestNonceScheme_Ed25519(t *testing.T) {
+	seed, err := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	require.NoError(t, err)
+	signature := ed25519.Sign(ed25519.NewKeyFromSeed(seed), nil)
+	assert.Equal(t, "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e06522490155"+
+		"5fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b", hex.EncodeToString(signature))
+}
+
+func TestSignDigest_Deterministic(t *testing.T) {
+	seed := make([]byte, 64)
+	digest := sha256.Sum256([]byte("sample"))
+	for _, curve := range []string{CurveSecp256k1, CurveEd25519} {
+		t.Run(curve, func(t *testing.T) {
+			path := "m/44'/60'/0'/0/0"
+			if curve == CurveEd25519 {
+				path = "m/44'/501'/0'/0'"
+			}
+			first, _, err := SignDigest(seed, curve, path, digest[:])
+			require.NoError(t, err)
+			second, _, err := SignDigest(seed, curve, path, digest[:])
+			require.NoError(t, err)
+			assert.Equal(t, first, second)
+		})
+	}
+}
+
+func TestNonceScheme(t *testing.T) {
+	assert.Equal(t, NonceRFC6979, NonceScheme(CurveSecp256k1))
+	assert.Equal(t, NonceRFC6979, NonceScheme(CurveStark))
+	assert.Equal(t, NonceRFC8032, NonceScheme(CurveEd25519))
+}
@@ -0,0 +1,158 @@
+// Package passphrase estimates the strength of BIP-39 passphrases and stretches them into
+// verifiers with argon2id or scrypt, to check a passphrase later without storing it again.
+package passphrase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Verifier algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+)
+
+const (
+	// saltLength and keyLength are the lengths of the salt and of the stretched key of a verifier
+	saltLength = 16
+	keyLength  = 32
+
+	// argon2id parameters, the second recommended option of RFC 9106
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+
+	// scrypt parameters, the interactive login ones of the scrypt paper
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnsupportedAlgorithm = errors.New("verifier algorithm must be argon2id or scrypt")
+)
+
+// commonPassphrases are rejected whatever their length, their estimate is 0 bits
+//
+//nolint:gochecknoglobals // read-only list of the most common passwords
+var commonPassphrases = map[string]struct{}{
+	"password": {}, "password1": {}, "passw0rd": {}, "123456": {}, "12345678": {}, "123456789": {},
+	"1234567890": {}, "qwerty": {}, "qwertyuiop": {}, "abc123": {}, "letmein": {}, "welcome": {},
+	"iloveyou": {}, "admin": {}, "monkey": {}, "dragon": {}, "sunshine": {}, "princess": {},
+	"football": {}, "baseball": {}, "trustno1": {}, "bitcoin": {}, "ethereum": {}, "satoshi": {},
+	"changeme": {}, "secret": {}, "passphrase": {},
+}
+
+// EntropyBits estimates the entropy of passphrase in bits: the distinct characters it holds
+// times the bits of the character classes it draws from, so repeating characters adds nothing.
+// It is a rough lower bound for policies, not a measure of the guessability of a passphrase.
+func EntropyBits(passphrase string) float64 {
+	if _, ok := commonPassphrases[strings.ToLower(passphrase)]; ok {
+		return 0
+	}
+
+	var lower, upper, digit, space, symbol, other bool
+	distinct := make(map[rune]struct{})
+	for _, r := range passphrase {
+		distinct[r] = struct{}{}
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r == ' ':
+			space = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {space, 1}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if len(distinct) == 0 {
+		return 0
+	}
+	return math.Round(float64(len(distinct))*math.Log2(float64(pool))*10) / 10
+}
+
+// Verifier -- a passphrase stretched by Algorithm with its salt and cost parameters. It checks a
+// passphrase without holding it; the parameters are kept so they can change for new verifiers.
+type Verifier struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Key       []byte `json:"key"`
+
+	// Time, Memory (KiB) and Threads of argon2id; N, R and P of scrypt
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+}
+
+// NewVerifier stretches passphrase with algorithm and a random salt
+func NewVerifier(algorithm, passphrase string) (*Verifier, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	verifier := &Verifier{Algorithm: algorithm, Salt: salt}
+	switch algorithm {
+	case AlgorithmArgon2id:
+		verifier.Time, verifier.Memory, verifier.Threads = argon2Time, argon2Memory, argon2Threads
+	case AlgorithmScrypt:
+		verifier.N, verifier.R, verifier.P = scryptN, scryptR, scryptP
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	key, err := verifier.stretch(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	verifier.Key = key
+	return verifier, nil
+}
+
+// Verify reports whether passphrase is the one the verifier was created from
+func (v *Verifier) Verify(passphrase string) (bool, error) {
+	key, err := v.stretch(passphrase)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, v.Key) == 1, nil
+}
+
+// stretch derives the key of passphrase with the algorithm and parameters of v
+func (v *Verifier) stretch(passphrase string) ([]byte, error) {
+	switch v.Algorithm {
+	case AlgorithmArgon2id:
+		return argon2.IDKey([]byte(passphrase), v.Salt, v.Time, v.Memory, v.Threads, keyLength), nil
+	case AlgorithmScrypt:
+		return scrypt.Key([]byte(passphrase), v.Salt, v.N, v.R, v.P, keyLength)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
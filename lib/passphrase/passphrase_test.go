@@ -0,0 +1,49 @@
+package passphrase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntropyBits(t *testing.T) {
+	for passphrase, want := range map[string]float64{
+		"":                             0,
+		"Password":                     0,
+		"aaaaaaaaaaaa":                 4.7,
+		"abcdefgh":                     37.6,
+		"correct horse battery staple": 61.8,
+		"Tr0ub4dor&3":                  65.7,
+	} {
+		assert.Equal(t, want, EntropyBits(passphrase), passphrase)
+	}
+	assert.Greater(t, EntropyBits("ümlaut"), EntropyBits("umlaut"))
+}
+
+func TestVerifier(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmArgon2id, AlgorithmScrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			verifier, err := NewVerifier(algorithm, "correct horse battery staple")
+			require.NoError(t, err)
+			assert.Len(t, verifier.Key, keyLength)
+			assert.NotContains(t, string(verifier.Key), "horse")
+
+			ok, err := verifier.Verify("correct horse battery staple")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = verifier.Verify("correct horse battery stapler")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			other, err := NewVerifier(algorithm, "correct horse battery staple")
+			require.NoError(t, err)
+			assert.NotEqual(t, verifier.Key, other.Key, "salted")
+		})
+	}
+
+	_, err := NewVerifier("bcrypt", "passphrase")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	_, err = (&Verifier{Algorithm: "bcrypt"}).Verify("passphrase")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
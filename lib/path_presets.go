@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownPathPreset   = errors.New("unknown path preset")
+	ErrPresetCoinType      = fmt.Errorf("%w: path preset does not support coinType", ErrUnsupportedCoin)
+	ErrInvalidPresetNumber = errors.New("account and index of a path preset must be between 0 and 2^31-1")
+)
+
+// pathPresets are the derivation paths of the wallets the keys must stay compatible with, by coin
+// type. {account} and {index} are replaced by the account and index of the request.
+//
+//nolint:gochecknoglobals // read-only lookup table
+var pathPresets = map[string]map[uint16]string{
+	// BIP-44 paths of MetaMask and most Ethereum tooling, the index is the address
+	"ethereum-default": {
+		slip44.Ethereum: "m/44'/60'/{account}'/0/{index}",
+	},
+	// BIP-84 native segwit receive addresses
+	"bitcoin-segwit": {
+		slip44.Bitcoin: "m/84'/0'/{account}'/0/{index}",
+	},
+	// Ledger Live numbers the Ethereum accounts on the account component, each has one address
+	"ledger-live": {
+		slip44.Ethereum: "m/44'/60'/{account}'/0/0",
+		slip44.Bitcoin:  "m/84'/0'/{account}'/0/{index}",
+	},
+	// Trezor Suite numbers the Ethereum accounts on the address index of the first account
+	"trezor": {
+		slip44.Ethereum: "m/44'/60'/0'/0/{account}",
+		slip44.Bitcoin:  "m/84'/0'/{account}'/0/{index}",
+	},
+}
+
+// PathPresets returns the names of the path presets, sorted
+func PathPresets() []string {
+	names := make([]string, 0, len(pathPresets))
+	for name := range pathPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetPath returns the derivation path of the preset name for coinType, at account and index
+func PresetPath(name string, coinType uint16, account, index int) (string, error) {
+	templates, ok := pathPresets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPathPreset, name)
+	}
+	template, ok := templates[coinType]
+	if !ok {
+		return "", fmt.Errorf("%w: %s: %d", ErrPresetCoinType, name, coinType)
+	}
+	if account < 0 || account > math.MaxInt32 || index < 0 || index > math.MaxInt32 {
+		return "", ErrInvalidPresetNumber
+	}
+	return strings.NewReplacer("{account}", strconv.Itoa(account), "{index}", strconv.Itoa(index)).
+		Replace(template), nil
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/payment-system/dq-vault/lib/slip44"
+)
+
+func TestPresetPath(t *testing.T) {
+	tests := []struct {
+		preset   string
+		coinType uint16
+		account  int
+		index    int
+		want     string
+	}{
+		{"ethereum-default", slip44.Ethereum, 0, 0, "m/44'/60'/0'/0/0"},
+		{"ethereum-default", slip44.Ethereum, 0, 7, "m/44'/60'/0'/0/7"},
+		{"bitcoin-segwit", slip44.Bitcoin, 1, 3, "m/84'/0'/1'/0/3"},
+		{"ledger-live", slip44.Ethereum, 2, 5, "m/44'/60'/2'/0/0"},
+		{"ledger-live", slip44.Bitcoin, 0, 4, "m/84'/0'/0'/0/4"},
+		{"trezor", slip44.Ethereum, 2, 0, "m/44'/60'/0'/0/2"},
+		{"trezor", slip44.Bitcoin, 1, 0, "m/84'/0'/1'/0/0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset+"/"+tt.want, func(t *testing.T) {
+			got, err := PresetPath(tt.preset, tt.coinType, tt.account, tt.index)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("every preset has a path", func(t *testing.T) {
+		for _, name := range PathPresets() {
+			assert.NotEmpty(t, pathPresets[name], name)
+		}
+		assert.Equal(t, []string{"bitcoin-segwit", "ethereum-default", "ledger-live", "trezor"}, PathPresets())
+	})
+
+	t.Run("unknown preset", func(t *testing.T) {
+		_, err := PresetPath("metamask", slip44.Ethereum, 0, 0)
+		require.ErrorIs(t, err, ErrUnknownPathPreset)
+	})
+
+	t.Run("unsupported coin type", func(t *testing.T) {
+		_, err := PresetPath("bitcoin-segwit", slip44.Ethereum, 0, 0)
+		require.ErrorIs(t, err, ErrPresetCoinType)
+		require.ErrorIs(t, err, ErrUnsupportedCoin)
+	})
+
+	t.Run("negative index", func(t *testing.T) {
+		_, err := PresetPath("ethereum-default", slip44.Ethereum, 0, -1)
+		require.ErrorIs(t, err, ErrInvalidPresetNumber)
+	})
+}
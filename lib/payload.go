@@ -41,3 +41,83 @@ type BitsharesRawTx struct {
 	TransactionDigest string `json:"transactionDigest"`
 	IRawTx
 }
+
+// SolanaRawTx stores a serialized Solana transaction message
+// implements IRawTx
+// RawTxHex is the hex encoded message that the fee payer signs.
+type SolanaRawTx struct {
+	RawTxHex string `json:"rawTxHex"`
+	IRawTx
+}
+
+// BCSRawTx stores a BCS serialized transaction
+// (Aptos RawTransaction or Sui TransactionData)
+// implements IRawTx
+type BCSRawTx struct {
+	TxBytes string `json:"txBytes"`
+	IRawTx
+}
+
+// TonRawTx stores a TON wallet v4 transfer request
+// implements IRawTx
+// The wallet state init is attached automatically while seqno is 0 (undeployed wallet).
+type TonRawTx struct {
+	Seqno      uint32 `json:"seqno"`
+	ValidUntil uint32 `json:"validUntil"`
+	Messages   []struct {
+		Address string `json:"address"`
+		Amount  string `json:"amount"`
+		Bounce  bool   `json:"bounce"`
+		Comment string `json:"comment"`
+		Mode    *uint8 `json:"mode"`
+	} `json:"messages"`
+	IRawTx
+}
+
+// HederaRawTx stores a serialized Hedera TransactionBody
+// implements IRawTx
+type HederaRawTx struct {
+	BodyBytes string `json:"bodyBytes"`
+	IRawTx
+}
+
+// AlgorandRawTx stores msgpack encoded Algorand transactions
+// implements IRawTx
+// Several transactions form an atomic group and must carry the same group id.
+type AlgorandRawTx struct {
+	Txns []string `json:"txns"`
+	IRawTx
+}
+
+// StarknetRawTx stores a StarkNet v3 transaction to hash and sign
+// implements IRawTx
+// Type is invoke or deploy_account. Felts are 0x prefixed hex or decimal strings,
+// chainId also accepts a short string such as SN_MAIN or SN_SEPOLIA.
+type StarknetRawTx struct {
+	Type          string `json:"type"`
+	SenderAddress string `json:"senderAddress"`
+	Calls         []struct {
+		To         string   `json:"to"`
+		Entrypoint string   `json:"entrypoint"`
+		Calldata   []string `json:"calldata"`
+	} `json:"calls"`
+	Nonce          string `json:"nonce"`
+	ChainID        string `json:"chainId"`
+	Tip            uint64 `json:"tip"`
+	ResourceBounds struct {
+		L1Gas     StarknetResourceBounds `json:"l1Gas"`
+		L2Gas     StarknetResourceBounds `json:"l2Gas"`
+		L1DataGas StarknetResourceBounds `json:"l1DataGas"`
+	} `json:"resourceBounds"`
+	PaymasterData             []string `json:"paymasterData"`
+	AccountDeploymentData     []string `json:"accountDeploymentData"`
+	NonceDataAvailabilityMode uint32   `json:"nonceDataAvailabilityMode"`
+	FeeDataAvailabilityMode   uint32   `json:"feeDataAvailabilityMode"`
+	IRawTx
+}
+
+// StarknetResourceBounds caps the amount (u64) and unit price (u128) of one fee resource
+type StarknetResourceBounds struct {
+	MaxAmount       string `json:"maxAmount"`
+	MaxPricePerUnit string `json:"maxPricePerUnit"`
+}
@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPayload is matched by every PayloadErrors value
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// FieldError describes the problem found in one field of a sign payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// PayloadErrors collects the field errors found while validating a sign payload
+type PayloadErrors []FieldError
+
+func (e PayloadErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		parts = append(parts, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return ErrInvalidPayload.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Is makes errors.Is(err, ErrInvalidPayload) hold for PayloadErrors
+func (e PayloadErrors) Is(target error) bool {
+	return target == ErrInvalidPayload
+}
+
+// PayloadValidator checks the fields of a JSON sign payload before any key is derived,
+// collecting an error per field rather than stopping at the first one
+type PayloadValidator struct {
+	prefix string
+	fields map[string]json.RawMessage
+	errs   *PayloadErrors
+}
+
+// NewPayloadValidator decodes payload as a JSON object
+func NewPayloadValidator(payload string) *PayloadValidator {
+	v := &PayloadValidator{errs: &PayloadErrors{}}
+	if err := json.Unmarshal([]byte(payload), &v.fields); err != nil || v.fields == nil {
+		v.Fail("payload", "not a JSON object")
+	}
+	return v
+}
+
+// Err returns the collected PayloadErrors, or nil when the payload is valid
+func (v *PayloadValidator) Err() error {
+	if len(*v.errs) == 0 {
+		return nil
+	}
+	return *v.errs
+}
+
+// Fail records an error for field
+func (v *PayloadValidator) Fail(field, message string) {
+	*v.errs = append(*v.errs, FieldError{Field: v.prefix + field, Message: message})
+}
+
+// IsObject reports whether the validated value is a JSON object, so cross field checks can be skipped otherwise
+func (v *PayloadValidator) IsObject() bool {
+	return v.fields != nil
+}
+
+// Has reports whether field is present and not null
+func (v *PayloadValidator) Has(field string) bool {
+	raw, ok := v.fields[field]
+	return ok && !bytes.Equal(raw, []byte("null"))
+}
+
+// String returns the string value of field, recording an error when it is not a string
+func (v *PayloadValidator) String(field string, required bool) (string, bool) {
+	if !v.present(field, required) {
+		return "", false
+	}
+	var s string
+	if err := json.Unmarshal(v.fields[field], &s); err != nil {
+		v.Fail(field, "not a string")
+		return "", false
+	}
+	if s == "" && required {
+		v.Fail(field, "missing")
+		return "", false
+	}
+	return s, s != ""
+}
+
+// Hex checks that field is a hex string, with or without 0x prefix, and returns the decoded bytes
+func (v *PayloadValidator) Hex(field string, required bool) ([]byte, bool) {
+	s, ok := v.String(field, required)
+	if !ok {
+		return nil, false
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		v.Fail(field, "not hex")
+		return nil, false
+	}
+	return decoded, true
+}
+
+// Uint checks that field is a JSON number fitting in bitSize unsigned bits
+func (v *PayloadValidator) Uint(field string, bitSize int, required bool) (uint64, bool) {
+	if !v.present(field, required) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(v.fields[field]), 10, bitSize)
+	if err != nil {
+		v.Fail(field, fmt.Sprintf("not an unsigned %d bit integer", bitSize))
+		return 0, false
+	}
+	return n, true
+}
+
+// BigInt checks that field is a non negative JSON number of any size
+func (v *PayloadValidator) BigInt(field string, required bool) (*big.Int, bool) {
+	if !v.present(field, required) {
+		return nil, false
+	}
+	n, ok := new(big.Int).SetString(string(v.fields[field]), 10)
+	if !ok {
+		v.Fail(field, "not an integer")
+		return nil, false
+	}
+	if n.Sign() < 0 {
+		v.Fail(field, "negative")
+		return nil, false
+	}
+	return n, true
+}
+
+// Bool checks that field is a JSON boolean
+func (v *PayloadValidator) Bool(field string) {
+	if !v.present(field, false) {
+		return
+	}
+	var b bool
+	if err := json.Unmarshal(v.fields[field], &b); err != nil {
+		v.Fail(field, "not a boolean")
+	}
+}
+
+// Strings returns the elements of a JSON array of strings
+func (v *PayloadValidator) Strings(field string, required bool) ([]string, bool) {
+	if !v.present(field, required) {
+		return nil, false
+	}
+	var values []string
+	if err := json.Unmarshal(v.fields[field], &values); err != nil {
+		v.Fail(field, "not an array of strings")
+		return nil, false
+	}
+	if len(values) == 0 && required {
+		v.Fail(field, "empty")
+		return nil, false
+	}
+	return values, true
+}
+
+// Objects returns a validator for every element of a JSON array of objects.
+// Errors found by the element validators are reported as field[i].name.
+func (v *PayloadValidator) Objects(field string, required bool) []*PayloadValidator {
+	if !v.present(field, required) {
+		return nil
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal(v.fields[field], &elements); err != nil {
+		v.Fail(field, "not an array")
+		return nil
+	}
+	if len(elements) == 0 && required {
+		v.Fail(field, "empty")
+		return nil
+	}
+
+	validators := make([]*PayloadValidator, 0, len(elements))
+	for i, element := range elements {
+		validators = append(validators, v.nested(fmt.Sprintf("%s[%d]", field, i), element))
+	}
+	return validators
+}
+
+// Object returns a validator for the JSON object stored in field
+func (v *PayloadValidator) Object(field string, required bool) *PayloadValidator {
+	if !v.present(field, required) {
+		// nothing to check inside a missing object
+		return &PayloadValidator{prefix: v.prefix + field + ".", errs: v.errs}
+	}
+	return v.nested(field, v.fields[field])
+}
+
+func (v *PayloadValidator) nested(field string, raw json.RawMessage) *PayloadValidator {
+	child := &PayloadValidator{prefix: v.prefix + field + ".", errs: v.errs}
+	if err := json.Unmarshal(raw, &child.fields); err != nil || child.fields == nil {
+		v.Fail(field, "not a JSON object")
+		child.fields = map[string]json.RawMessage{}
+	}
+	return child
+}
+
+func (v *PayloadValidator) present(field string, required bool) bool {
+	if v.Has(field) {
+		return true
+	}
+	// a payload that is not a JSON object has already been reported
+	if required && v.fields != nil {
+		v.Fail(field, "missing")
+	}
+	return false
+}
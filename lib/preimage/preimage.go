@@ -0,0 +1,130 @@
+// Package preimage recognizes the messages of sign/digest that are the signing pre-image of a
+// transaction of a chain with an adapter. Signing them with the generic digest path would let a
+// caller obtain a transaction signature without the checks of the adapter, or replay one for another
+// chain, so the digest policy refuses them.
+package preimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/payment-system/dq-vault/lib/adapter/bitcoin"
+	"github.com/payment-system/dq-vault/lib/adapter/solana"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownHash = errors.New("hash must be keccak256, sha256 or sha256d")
+	ErrRecognized  = errors.New("message is the pre-image of a recognized transaction type")
+)
+
+// Kinds of the recognized pre-images
+const (
+	KindEVMLegacyTransaction = "evm-legacy-transaction"
+	KindEVMTypedTransaction  = "evm-typed-transaction"
+	KindEIP191Message        = "eip191-message"
+	KindEIP712TypedData      = "eip712-typed-data"
+	KindBitcoinTransaction   = "bitcoin-transaction"
+	KindBitcoinPSBT          = "bitcoin-psbt"
+	KindSolanaMessage        = "solana-message"
+)
+
+// maxTxType is the highest EIP-2718 transaction type in use (EIP-7702 set code transactions)
+const maxTxType = 0x04
+
+// sighashLength is the length of the sighash type appended to legacy Bitcoin signing pre-images
+const sighashLength = 4
+
+// hashes are the hash functions turning a message into the digest signed
+//
+//nolint:gochecknoglobals // read-only lookup table
+var hashes = map[string]func([]byte) []byte{
+	"keccak256": func(message []byte) []byte {
+		return crypto.Keccak256(message)
+	},
+	"sha256": func(message []byte) []byte {
+		digest := sha256.Sum256(message)
+		return digest[:]
+	},
+	"sha256d": func(message []byte) []byte {
+		first := sha256.Sum256(message)
+		digest := sha256.Sum256(first[:])
+		return digest[:]
+	},
+}
+
+// Digest returns the digest of message with the named hash function
+func Digest(hash string, message []byte) ([]byte, error) {
+	h, ok := hashes[hash]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHash, hash)
+	}
+	return h(message), nil
+}
+
+// Recognize returns the kind of transaction pre-image message is, or "" when it is none. The checks
+// are structural heuristics: they recognize well-formed pre-images, not every message that a chain
+// would accept.
+func Recognize(message []byte) string {
+	switch {
+	case isEIP191(message):
+		return KindEIP191Message
+	case len(message) == 66 && message[0] == 0x19 && message[1] == 0x01:
+		return KindEIP712TypedData
+	case bytes.HasPrefix(message, []byte("psbt\xff")):
+		if _, err := bitcoin.ParsePSBT(message); err == nil {
+			return KindBitcoinPSBT
+		}
+	case len(message) > 1 && message[0] >= 0x01 && message[0] <= maxTxType && isRLPList(message[1:]):
+		// EIP-2718 envelopes: a transaction type followed by the RLP list of its fields
+		return KindEVMTypedTransaction
+	case isEVMLegacy(message):
+		return KindEVMLegacyTransaction
+	}
+	if isBitcoinTransaction(message) ||
+		(len(message) > sighashLength && isBitcoinTransaction(message[:len(message)-sighashLength])) {
+		return KindBitcoinTransaction
+	}
+	if solana.IsMessage(message) {
+		return KindSolanaMessage
+	}
+	return ""
+}
+
+func isEIP191(message []byte) bool {
+	return bytes.HasPrefix(message, []byte("\x19Ethereum Signed Message:\n"))
+}
+
+// isRLPList reports whether b is exactly one RLP list
+func isRLPList(b []byte) bool {
+	kind, _, rest, err := rlp.Split(b)
+	return err == nil && kind == rlp.List && len(rest) == 0
+}
+
+// isEVMLegacy reports whether message is the RLP list of the fields of a legacy transaction, with
+// (EIP-155) or without the chain id
+func isEVMLegacy(message []byte) bool {
+	content, rest, err := rlp.SplitList(message)
+	if err != nil || len(rest) != 0 {
+		return false
+	}
+	count, err := rlp.CountValues(content)
+	return err == nil && (count == 6 || count == 9)
+}
+
+// isBitcoinTransaction reports whether message is exactly one serialized transaction with inputs
+// and outputs
+func isBitcoinTransaction(message []byte) bool {
+	var tx wire.MsgTx
+	r := bytes.NewReader(message)
+	if err := tx.Deserialize(r); err != nil || r.Len() != 0 {
+		return false
+	}
+	return len(tx.TxIn) > 0 && len(tx.TxOut) > 0
+}
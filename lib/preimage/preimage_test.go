@@ -0,0 +1,144 @@
+package preimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBitcoinTx returns a serialized transaction with one input and one output
+func testBitcoinTx(t *testing.T) []byte {
+	t.Helper()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(10_000, []byte{0x6a}))
+	var buf bytes.Buffer
+	require.NoError(t, tx.SerializeNoWitness(&buf))
+	return buf.Bytes()
+}
+
+// testPSBT returns a PSBT of testBitcoinTx, without any input or output data
+func testPSBT(t *testing.T) []byte {
+	t.Helper()
+	unsigned := testBitcoinTx(t)
+	psbt := []byte("psbt\xff")
+	psbt = append(psbt, 0x01, 0x00, byte(len(unsigned)))
+	psbt = append(psbt, unsigned...)
+	return append(psbt, 0x00, 0x00, 0x00)
+}
+
+// testSolanaMessage returns a legacy or v0 message with a fee payer and one instruction of a program
+func testSolanaMessage(versioned bool) []byte {
+	var msg []byte
+	if versioned {
+		msg = append(msg, 0x80)
+	}
+	msg = append(msg, 0x01, 0x00, 0x01, 0x02)
+	msg = append(msg, bytes.Repeat([]byte{0x11}, 32)...)
+	msg = append(msg, bytes.Repeat([]byte{0x22}, 32)...)
+	msg = append(msg, bytes.Repeat([]byte{0x33}, 32)...)
+	msg = append(msg, 0x01, 0x01, 0x01, 0x00, 0x02, 0xaa, 0xbb)
+	if versioned {
+		msg = append(msg, 0x00)
+	}
+	return msg
+}
+
+func TestRecognize(t *testing.T) {
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	to := common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(1), Gas: 21000, To: &to, Value: big.NewInt(1)})
+	legacyPreimage, err := rlp.EncodeToBytes([]interface{}{
+		legacy.Nonce(), legacy.GasPrice(), legacy.Gas(), legacy.To(), legacy.Value(), legacy.Data(),
+		big.NewInt(1), uint(0), uint(0),
+	})
+	require.NoError(t, err)
+	// the signing hash of a legacy transaction is the keccak256 of this pre-image
+	assert.Equal(t, signer.Hash(legacy).Bytes(), crypto.Keccak256(legacyPreimage))
+
+	dynamic := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Nonce: 1, Gas: 21000, To: &to,
+		GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Value: big.NewInt(1)})
+	dynamicPreimage, err := rlp.EncodeToBytes([]interface{}{
+		dynamic.ChainId(), dynamic.Nonce(), dynamic.GasTipCap(), dynamic.GasFeeCap(), dynamic.Gas(),
+		dynamic.To(), dynamic.Value(), dynamic.Data(), dynamic.AccessList(),
+	})
+	require.NoError(t, err)
+	dynamicPreimage = append([]byte{types.DynamicFeeTxType}, dynamicPreimage...)
+	assert.Equal(t, signer.Hash(dynamic).Bytes(), crypto.Keccak256(dynamicPreimage))
+
+	bitcoinTx := testBitcoinTx(t)
+	tests := []struct {
+		name    string
+		message []byte
+		want    string
+	}{
+		{name: "evm legacy transaction", message: legacyPreimage, want: KindEVMLegacyTransaction},
+		{name: "evm dynamic fee transaction", message: dynamicPreimage, want: KindEVMTypedTransaction},
+		{name: "eip191 message", message: []byte("\x19Ethereum Signed Message:\n5hello"), want: KindEIP191Message},
+		{
+			name:    "eip712 typed data",
+			message: append([]byte{0x19, 0x01}, make([]byte, 64)...),
+			want:    KindEIP712TypedData,
+		},
+		{name: "bitcoin transaction", message: bitcoinTx, want: KindBitcoinTransaction},
+		{
+			name:    "bitcoin legacy sighash pre-image",
+			message: append(append([]byte{}, bitcoinTx...), 0x01, 0x00, 0x00, 0x00),
+			want:    KindBitcoinTransaction,
+		},
+		{name: "bitcoin psbt", message: testPSBT(t), want: KindBitcoinPSBT},
+		{name: "solana legacy message", message: testSolanaMessage(false), want: KindSolanaMessage},
+		{name: "solana v0 message", message: testSolanaMessage(true), want: KindSolanaMessage},
+		{name: "text", message: []byte("hello"), want: ""},
+		{name: "empty", message: nil, want: ""},
+		{name: "digest", message: crypto.Keccak256([]byte("hello")), want: ""},
+		{name: "rlp list of other fields", message: []byte{0xc3, 0x01, 0x02, 0x03}, want: ""},
+		{name: "typed transaction of unknown type", message: append([]byte{0x05}, dynamicPreimage[1:]...), want: ""},
+		{name: "truncated bitcoin transaction", message: bitcoinTx[:len(bitcoinTx)-1], want: ""},
+		{name: "truncated solana message", message: testSolanaMessage(false)[:40], want: ""},
+		{name: "psbt magic only", message: []byte("psbt\xff"), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Recognize(tt.message))
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	message := []byte("hello")
+	first := sha256.Sum256(message)
+	double := sha256.Sum256(first[:])
+
+	tests := []struct {
+		hash string
+		want []byte
+	}{
+		{hash: "keccak256", want: crypto.Keccak256(message)},
+		{hash: "sha256", want: first[:]},
+		{hash: "sha256d", want: double[:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hash, func(t *testing.T) {
+			digest, err := Digest(tt.hash, message)
+			require.NoError(t, err)
+			assert.Equal(t, hex.EncodeToString(tt.want), hex.EncodeToString(digest))
+		})
+	}
+
+	_, err := Digest("md5", message)
+	require.ErrorIs(t, err, ErrUnknownHash)
+	_, err = Digest("", message)
+	require.ErrorIs(t, err, ErrUnknownHash)
+}
@@ -0,0 +1,238 @@
+// Package queue bounds the key operations served at once, queuing the requests beyond the limit
+// by priority class instead of refusing them. Interactive requests are served before batch ones,
+// a batch request every InteractiveWeight interactive ones so batches still progress, and within
+// a class the waiting keys, the UUIDs of the users, take turns.
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Class is the priority class of a request
+type Class int
+
+// Priority classes, Interactive first
+const (
+	Interactive Class = iota
+	Batch
+)
+
+// String returns the name of the class
+func (c Class) String() string {
+	if c == Batch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// Classes lists the priority classes in priority order
+//
+//nolint:gochecknoglobals // read-only lookup table
+var Classes = []Class{Interactive, Batch}
+
+// InteractiveWeight is the number of interactive requests served for every batch one while both wait
+const InteractiveWeight = 4
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrFull = errors.New("the request queue is full, retry later")
+)
+
+// Stats -- the counters of a class since the queue was created
+type Stats struct {
+	Depth     int
+	Keys      int
+	Served    uint64
+	Rejected  uint64
+	Abandoned uint64
+	Waited    time.Duration
+	MaxWaited time.Duration
+}
+
+// Queue -- the slots of the operations served at once and the requests waiting for one. The zero
+// Queue has no slot until SetLimits.
+type Queue struct {
+	mu         sync.Mutex
+	capacity   int
+	maxDepth   int
+	running    int
+	sinceBatch int
+	classes    [2]classQueue
+	stats      [2]Stats
+}
+
+// classQueue -- the waiters of a class by key, the keys in the order they take turns
+type classQueue struct {
+	keys    []string
+	waiters map[string][]*waiter
+	depth   int
+}
+
+type waiter struct {
+	ready    chan struct{}
+	granted  bool
+	enqueued time.Time
+}
+
+// New returns a queue serving capacity operations at once, with at most maxDepth waiting
+func New(capacity, maxDepth int) *Queue {
+	q := &Queue{}
+	q.SetLimits(capacity, maxDepth)
+	return q
+}
+
+// SetLimits changes the operations served at once and the requests waiting, the waiting ones
+// are served at once when the capacity grows
+func (q *Queue) SetLimits(capacity, maxDepth int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.capacity, q.maxDepth = capacity, maxDepth
+	q.dispatch()
+}
+
+// Acquire takes a slot for a request of class and key, waiting for one until ctx is done. It
+// returns the function releasing the slot, ErrFull when maxDepth requests already wait, or the
+// error of ctx.
+func (q *Queue) Acquire(ctx context.Context, class Class, key string) (func(), error) {
+	q.mu.Lock()
+	if q.running < q.capacity && q.depth() == 0 {
+		q.running++
+		q.stats[class].Served++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	if q.depth() >= q.maxDepth {
+		q.stats[class].Rejected++
+		q.mu.Unlock()
+		return nil, ErrFull
+	}
+	w := &waiter{ready: make(chan struct{}), enqueued: time.Now()}
+	q.classes[class].push(key, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// the slot may have been granted while ctx was done
+	if w.granted {
+		q.stats[class].Served--
+		q.running--
+		q.dispatch()
+	} else {
+		q.classes[class].remove(key, w)
+	}
+	q.stats[class].Abandoned++
+	return nil, ctx.Err()
+}
+
+// Stats returns the counters of class
+func (q *Queue) Stats(class Class) Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats[class]
+	stats.Depth, stats.Keys = q.classes[class].depth, len(q.classes[class].keys)
+	return stats
+}
+
+// Running returns the number of operations holding a slot
+func (q *Queue) Running() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running
+}
+
+func (q *Queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running--
+	q.dispatch()
+}
+
+func (q *Queue) depth() int {
+	return q.classes[Interactive].depth + q.classes[Batch].depth
+}
+
+// dispatch grants the free slots to the waiters, by class then by key
+func (q *Queue) dispatch() {
+	for q.running < q.capacity && q.depth() > 0 {
+		class := Interactive
+		if q.classes[Interactive].depth == 0 ||
+			(q.classes[Batch].depth > 0 && q.sinceBatch >= InteractiveWeight) {
+			class = Batch
+		}
+		if class == Batch {
+			q.sinceBatch = 0
+		} else {
+			q.sinceBatch++
+		}
+
+		w := q.classes[class].pop()
+		waited := time.Since(w.enqueued)
+		stats := &q.stats[class]
+		stats.Served++
+		stats.Waited += waited
+		stats.MaxWaited = max(stats.MaxWaited, waited)
+		w.granted = true
+		q.running++
+		close(w.ready)
+	}
+}
+
+// push queues w behind the waiters of key, key taking its turn after the keys already waiting
+func (c *classQueue) push(key string, w *waiter) {
+	if c.waiters == nil {
+		c.waiters = make(map[string][]*waiter)
+	}
+	if len(c.waiters[key]) == 0 {
+		c.keys = append(c.keys, key)
+	}
+	c.waiters[key] = append(c.waiters[key], w)
+	c.depth++
+}
+
+// pop returns the first waiter of the key whose turn it is, the key waiting again behind the others
+func (c *classQueue) pop() *waiter {
+	key := c.keys[0]
+	c.keys = c.keys[1:]
+	waiters := c.waiters[key]
+	w := waiters[0]
+	if len(waiters) == 1 {
+		delete(c.waiters, key)
+	} else {
+		c.waiters[key] = waiters[1:]
+		c.keys = append(c.keys, key)
+	}
+	c.depth--
+	return w
+}
+
+// remove drops w from the waiters of key
+func (c *classQueue) remove(key string, w *waiter) {
+	waiters := c.waiters[key]
+	for i, queued := range waiters {
+		if queued != w {
+			continue
+		}
+		c.waiters[key] = append(waiters[:i:i], waiters[i+1:]...)
+		c.depth--
+		break
+	}
+	if len(c.waiters[key]) > 0 {
+		return
+	}
+	delete(c.waiters, key)
+	for i, queued := range c.keys {
+		if queued == key {
+			c.keys = append(c.keys[:i:i], c.keys[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// served records the order the waiters of a queue are granted their slot in
+type served struct {
+	mu    sync.Mutex
+	order []string
+	wg    sync.WaitGroup
+}
+
+// enqueue queues the request name of class and key, released as soon as it is served, once the
+// queue has it waiting
+func (s *served) enqueue(t *testing.T, q *Queue, class Class, key, name string) {
+	t.Helper()
+	depth := q.Stats(class).Depth
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		release, err := q.Acquire(context.Background(), class, key)
+		if !assert.NoError(t, err) {
+			return
+		}
+		s.mu.Lock()
+		s.order = append(s.order, name)
+		s.mu.Unlock()
+		release()
+	}()
+	require.Eventually(t, func() bool { return q.Stats(class).Depth == depth+1 }, time.Second, time.Millisecond)
+}
+
+func TestQueue_Order(t *testing.T) {
+	t.Run("interactive first, users taking turns", func(t *testing.T) {
+		q := New(1, 10)
+		release, err := q.Acquire(context.Background(), Interactive, "holder")
+		require.NoError(t, err)
+
+		var s served
+		s.enqueue(t, q, Batch, "u1", "batch-u1-1")
+		s.enqueue(t, q, Batch, "u1", "batch-u1-2")
+		s.enqueue(t, q, Batch, "u2", "batch-u2")
+		s.enqueue(t, q, Interactive, "u1", "interactive-u1-1")
+		s.enqueue(t, q, Interactive, "u1", "interactive-u1-2")
+		s.enqueue(t, q, Interactive, "u2", "interactive-u2")
+		assert.Equal(t, 2, q.Stats(Interactive).Keys)
+
+		release()
+		s.wg.Wait()
+		assert.Equal(t, []string{"interactive-u1-1", "interactive-u2", "interactive-u1-2",
+			"batch-u1-1", "batch-u2", "batch-u1-2"}, s.order)
+		assert.Equal(t, uint64(4), q.Stats(Interactive).Served)
+		assert.Equal(t, 0, q.Running())
+	})
+
+	t.Run("batches are not starved", func(t *testing.T) {
+		q := New(1, 10)
+		release, err := q.Acquire(context.Background(), Interactive, "holder")
+		require.NoError(t, err)
+
+		var s served
+		s.enqueue(t, q, Batch, "job", "batch")
+		for range InteractiveWeight + 2 {
+			s.enqueue(t, q, Interactive, "u1", "interactive")
+		}
+
+		release()
+		s.wg.Wait()
+		assert.Equal(t, []string{"interactive", "interactive", "interactive", "interactive", "batch",
+			"interactive", "interactive"}, s.order)
+	})
+}
+
+func TestQueue_Limits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("full queues reject", func(t *testing.T) {
+		q := New(1, 1)
+		release, err := q.Acquire(ctx, Interactive, "u1")
+		require.NoError(t, err)
+		var s served
+		s.enqueue(t, q, Batch, "u1", "batch")
+
+		_, err = q.Acquire(ctx, Interactive, "u2")
+		assert.ErrorIs(t, err, ErrFull)
+		assert.Equal(t, uint64(1), q.Stats(Interactive).Rejected)
+
+		release()
+		s.wg.Wait()
+	})
+
+	t.Run("waiters leave when their context is done", func(t *testing.T) {
+		q := New(1, 10)
+		release, err := q.Acquire(ctx, Interactive, "u1")
+		require.NoError(t, err)
+
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = q.Acquire(waitCtx, Interactive, "u2")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		stats := q.Stats(Interactive)
+		assert.Equal(t, 0, stats.Depth)
+		assert.Equal(t, 0, stats.Keys)
+		assert.Equal(t, uint64(1), stats.Abandoned)
+
+		release()
+		assert.Equal(t, 0, q.Running())
+	})
+
+	t.Run("growing the capacity serves the waiters", func(t *testing.T) {
+		q := New(1, 10)
+		release, err := q.Acquire(ctx, Interactive, "u1")
+		require.NoError(t, err)
+		var s served
+		s.enqueue(t, q, Interactive, "u2", "interactive")
+
+		q.SetLimits(2, 10)
+		s.wg.Wait()
+		assert.Equal(t, []string{"interactive"}, s.order)
+		release()
+		assert.Equal(t, 0, q.Running())
+	})
+}
@@ -0,0 +1,159 @@
+// Package rpc is a minimal JSON-RPC 2.0 client for the chain nodes the plugin relays to
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxRetries is the number of retries of a call failing with a transient error
+const DefaultMaxRetries = 3
+
+// DefaultTimeout bounds each attempt of a call
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize bounds the response bodies read from nodes
+const maxResponseSize = 1 << 20
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidURL = errors.New("rpc url must be an http or https url")
+	ErrStatus     = errors.New("rpc endpoint returned an unexpected status")
+	ErrNoResult   = errors.New("rpc response has neither result nor error")
+)
+
+// Error is an error returned by the node, such as a rejected transaction. It is not retried.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Client calls the JSON-RPC endpoint url, retrying transport errors, 429 and 5xx responses
+// with an exponential backoff
+type Client struct {
+	logger     *slog.Logger
+	url        string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewClient returns a client of the endpoint url, each attempt bounded by timeout
+func NewClient(logger *slog.Logger, endpoint string, maxRetries int, timeout time.Duration) (*Client, error) {
+	if err := ValidateURL(endpoint); err != nil {
+		return nil, err
+	}
+	return &Client{
+		logger:     logger.With(slog.String("component", "rpc"), slog.String("host", Host(endpoint))),
+		url:        endpoint,
+		httpClient: &http.Client{Timeout: timeout},
+		maxRetries: maxRetries,
+		backoff:    time.Second,
+	}, nil
+}
+
+// ValidateURL checks endpoint is an absolute http or https url
+func ValidateURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Host returns the host of endpoint, to log and report endpoints whose path or query holds an API key
+func Host(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Call calls method with params and decodes its result into result. It returns the number of
+// attempts made.
+func (c *Client) Call(ctx context.Context, method string, params []any, result any) (int, error) {
+	body, err := json.Marshal(request{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return 0, err
+	}
+
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		raw, retry, err := c.post(ctx, body)
+		if err == nil {
+			return attempt, json.Unmarshal(raw, result)
+		}
+		if !retry || attempt > c.maxRetries || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		c.logger.Warn("rpc call failed, retrying", "method", method, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one attempt of the call, returning the raw result or whether the error is transient
+func (c *Client) post(ctx context.Context, body []byte) (json.RawMessage, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// the url is dropped from the error, it may hold an API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, true, urlErr.Err
+		}
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, true, fmt.Errorf("%w: %d", ErrStatus, resp.StatusCode)
+	}
+	var decoded response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&decoded); err != nil {
+		return nil, false, fmt.Errorf("%w: %d", ErrStatus, resp.StatusCode)
+	}
+	if decoded.Error != nil {
+		return nil, false, decoded.Error
+	}
+	if len(decoded.Result) == 0 {
+		return nil, false, ErrNoResult
+	}
+	return decoded.Result, false, nil
+}
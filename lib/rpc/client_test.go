@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, url string, maxRetries int) *Client {
+	t.Helper()
+	client, err := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), url, maxRetries, time.Second)
+	require.NoError(t, err)
+	client.backoff = time.Millisecond
+	return client
+}
+
+func TestClientCall(t *testing.T) {
+	t.Run("result", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req request
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "2.0", req.JSONRPC)
+			assert.Equal(t, "eth_sendRawTransaction", req.Method)
+			assert.Equal(t, []any{"0x02"}, req.Params)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0xabc"}`))
+		}))
+		defer server.Close()
+
+		var result string
+		attempts, err := newTestClient(t, server.URL, 3).Call(context.Background(), "eth_sendRawTransaction",
+			[]any{"0x02"}, &result)
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, "0xabc", result)
+	})
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0xabc"}`))
+		}))
+		defer server.Close()
+
+		var result string
+		attempts, err := newTestClient(t, server.URL, 3).Call(context.Background(), "m", nil, &result)
+		require.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, "0xabc", result)
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		var result string
+		attempts, err := newTestClient(t, server.URL, 2).Call(context.Background(), "m", nil, &result)
+		require.ErrorIs(t, err, ErrStatus)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("node errors are not retried", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"nonce too low"}}`))
+		}))
+		defer server.Close()
+
+		var result string
+		_, err := newTestClient(t, server.URL, 3).Call(context.Background(), "m", nil, &result)
+		var rpcErr *Error
+		require.ErrorAs(t, err, &rpcErr)
+		assert.Equal(t, -32000, rpcErr.Code)
+		assert.Equal(t, "nonce too low", rpcErr.Message)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestValidateURL(t *testing.T) {
+	require.NoError(t, ValidateURL("https://mainnet.infura.io/v3/key"))
+	require.ErrorIs(t, ValidateURL("ftp://node"), ErrInvalidURL)
+	require.ErrorIs(t, ValidateURL("node:8545"), ErrInvalidURL)
+	assert.Equal(t, "mainnet.infura.io", Host("https://mainnet.infura.io/v3/key"))
+}
@@ -0,0 +1,65 @@
+// Package sealedbox encrypts to X25519 public keys as libsodium sealed boxes (crypto_box_seal,
+// X25519 and XSalsa20-Poly1305): each box is encrypted with a fresh ephemeral key pair, so only
+// the holder of the private key of the recipient can open it.
+package sealedbox
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Algorithm names the encryption of the sealed boxes
+const Algorithm = "x25519-xsalsa20-poly1305-sealedbox"
+
+// KeySize is the size of the X25519 keys
+const KeySize = 32
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidKey        = errors.New("public key must be 32 bytes hex encoded")
+	ErrInvalidCiphertext = errors.New("ciphertext cannot be decrypted with this key")
+)
+
+// ParsePublicKey decodes a hex encoded X25519 public key
+func ParsePublicKey(s string) (*[KeySize]byte, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	var key [KeySize]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// KeyID returns the first 8 bytes of the SHA-256 of publicKey, in hex
+func KeyID(publicKey *[KeySize]byte) string {
+	sum := sha256.Sum256(publicKey[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// Seal encrypts plaintext to publicKey and returns the base64 ciphertext
+func Seal(publicKey *[KeySize]byte, plaintext []byte) (string, error) {
+	sealed, err := box.SealAnonymous(nil, plaintext, publicKey, rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts the base64 ciphertext of Seal with the key pair of the recipient
+func Open(publicKey, privateKey *[KeySize]byte, ciphertext string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, ok := box.OpenAnonymous(nil, sealed, publicKey, privateKey)
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
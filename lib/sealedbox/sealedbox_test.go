@@ -0,0 +1,44 @@
+package sealedbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestSeal(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	parsed, err := ParsePublicKey(hex.EncodeToString(publicKey[:]))
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, parsed)
+	assert.Len(t, KeyID(publicKey), 16)
+
+	sealed, err := Seal(publicKey, []byte("secret"))
+	require.NoError(t, err)
+	again, err := Seal(publicKey, []byte("secret"))
+	require.NoError(t, err)
+	// every box has its own ephemeral key
+	assert.NotEqual(t, sealed, again)
+
+	plaintext, err := Open(publicKey, privateKey, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), plaintext)
+
+	otherPublic, otherPrivate, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = Open(otherPublic, otherPrivate, sealed)
+	require.ErrorIs(t, err, ErrInvalidCiphertext)
+	_, err = Open(publicKey, privateKey, "not base64!")
+	require.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	for _, key := range []string{"", "zz", hex.EncodeToString(publicKey[:31])} {
+		_, err := ParsePublicKey(key)
+		require.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
@@ -0,0 +1,55 @@
+// Package secp signs digests and derives public keys on secp256k1. Built with the libsecp256k1
+// tag and cgo, Sign and PublicKey call the C library bundled with go-ethereum; other builds use
+// the pure Go implementation of decred. Both produce the same deterministic RFC 6979 signatures
+// and the same public keys.
+package secp
+
+import (
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+const (
+	// DigestLength is the length of the digests signed
+	DigestLength = 32
+
+	// PrivateKeyLength is the length of a serialized private key
+	PrivateKeyLength = 32
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidDigest     = errors.New("digest must be 32 bytes")
+	ErrInvalidPrivateKey = errors.New("invalid secp256k1 private key")
+	ErrScalarMult        = errors.New("libsecp256k1 scalar multiplication failed")
+)
+
+// checkPrivateKey rejects the scalars that are not a private key: zero, or not below the order
+func checkPrivateKey(privateKey []byte) error {
+	if len(privateKey) != PrivateKeyLength {
+		return ErrInvalidPrivateKey
+	}
+	var scalar secp256k1.ModNScalar
+	defer scalar.Zero()
+	if overflow := scalar.SetByteSlice(privateKey); overflow || scalar.IsZero() {
+		return ErrInvalidPrivateKey
+	}
+	return nil
+}
+
+// checkSign checks the arguments of Sign
+func checkSign(digest, privateKey []byte) error {
+	if len(digest) != DigestLength {
+		return ErrInvalidDigest
+	}
+	return checkPrivateKey(privateKey)
+}
+
+// PublicKey returns the 33 byte compressed public key of privateKey
+func PublicKey(privateKey []byte) ([]byte, error) {
+	if err := checkPrivateKey(privateKey); err != nil {
+		return nil, err
+	}
+	return publicKey(privateKey)
+}
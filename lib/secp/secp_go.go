@@ -0,0 +1,39 @@
+//go:build !libsecp256k1 || !cgo
+
+package secp
+
+import (
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Implementation names the secp256k1 implementation of the build
+const Implementation = "go"
+
+// compactRecoveryOffset is added to the recovery id in the first byte of the compact
+// signatures of decred
+const compactRecoveryOffset = 27
+
+// Sign signs the 32 byte digest with privateKey, returning the 65 byte [R || S || V] signature
+// with a low S value, V being the recovery id 0 or 1
+func Sign(digest, privateKey []byte) ([]byte, error) {
+	if err := checkSign(digest, privateKey); err != nil {
+		return nil, err
+	}
+	key := secp256k1.PrivKeyFromBytes(privateKey)
+	defer key.Zero()
+
+	// [V + 27 || R || S] to [R || S || V]
+	signature := ecdsa.SignCompact(key, digest, false)
+	recoveryID := signature[0] - compactRecoveryOffset
+	copy(signature, signature[1:])
+	signature[len(signature)-1] = recoveryID
+	return signature, nil
+}
+
+// publicKey returns the compressed public key of the checked privateKey
+func publicKey(privateKey []byte) ([]byte, error) {
+	key := secp256k1.PrivKeyFromBytes(privateKey)
+	defer key.Zero()
+	return key.PubKey().SerializeCompressed(), nil
+}
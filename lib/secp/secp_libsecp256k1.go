@@ -0,0 +1,28 @@
+//go:build libsecp256k1 && cgo
+
+package secp
+
+import (
+	"github.com/ethereum/go-ethereum/crypto/secp256k1"
+)
+
+// Implementation names the secp256k1 implementation of the build
+const Implementation = "libsecp256k1"
+
+// Sign signs the 32 byte digest with privateKey, returning the 65 byte [R || S || V] signature
+// with a low S value, V being the recovery id 0 or 1
+func Sign(digest, privateKey []byte) ([]byte, error) {
+	if err := checkSign(digest, privateKey); err != nil {
+		return nil, err
+	}
+	return secp256k1.Sign(digest, privateKey)
+}
+
+// publicKey returns the compressed public key of the checked privateKey
+func publicKey(privateKey []byte) ([]byte, error) {
+	x, y := secp256k1.S256().ScalarBaseMult(privateKey)
+	if x == nil {
+		return nil, ErrScalarMult
+	}
+	return secp256k1.CompressPubkey(x, y), nil
+}
@@ -0,0 +1,103 @@
+package secp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	for i := byte(1); i <= 16; i++ {
+		privateKey := sha256.Sum256([]byte{i})
+		digest := sha256.Sum256([]byte{i, i})
+
+		signature, err := Sign(digest[:], privateKey[:])
+		require.NoError(t, err)
+		require.Len(t, signature, crypto.SignatureLength)
+
+		// the same deterministic signature as go-ethereum, whatever the implementation
+		ecdsaKey, err := crypto.ToECDSA(privateKey[:])
+		require.NoError(t, err)
+		want, err := crypto.Sign(digest[:], ecdsaKey)
+		require.NoError(t, err)
+		assert.Equal(t, want, signature, Implementation)
+
+		publicKey, err := PublicKey(privateKey[:])
+		require.NoError(t, err)
+		assert.Equal(t, crypto.CompressPubkey(&ecdsaKey.PublicKey), publicKey)
+		recovered, err := crypto.SigToPub(digest[:], signature)
+		require.NoError(t, err)
+		assert.Equal(t, publicKey, crypto.CompressPubkey(recovered))
+	}
+}
+
+func TestImplementations(t *testing.T) {
+	order := crypto.S256().Params().N
+	vectors := [][]byte{
+		new(big.Int).SetInt64(1).FillBytes(make([]byte, PrivateKeyLength)),
+		new(big.Int).Sub(order, big.NewInt(1)).FillBytes(make([]byte, PrivateKeyLength)),
+	}
+	for i := byte(1); i <= 16; i++ {
+		privateKey := sha256.Sum256([]byte{'k', i})
+		vectors = append(vectors, privateKey[:])
+	}
+	digest := sha256.Sum256([]byte("digest"))
+
+	// the build matches the pure Go implementation of decred, the one of the builds without the tag
+	for _, privateKey := range vectors {
+		key := secp256k1.PrivKeyFromBytes(privateKey)
+		publicKey, err := PublicKey(privateKey)
+		require.NoError(t, err)
+		assert.Equal(t, key.PubKey().SerializeCompressed(), publicKey, Implementation)
+
+		// [V + 27 || R || S] to [R || S || V]
+		compact := ecdsa.SignCompact(key, digest[:], false)
+		signature, err := Sign(digest[:], privateKey)
+		require.NoError(t, err)
+		assert.Equal(t, append(compact[1:], compact[0]-27), signature, Implementation)
+	}
+}
+
+func TestSign_Invalid(t *testing.T) {
+	digest := sha256.Sum256([]byte("digest"))
+	privateKey := sha256.Sum256([]byte("key"))
+	order := crypto.S256().Params().N.FillBytes(make([]byte, PrivateKeyLength))
+
+	_, err := Sign(digest[:31], privateKey[:])
+	assert.ErrorIs(t, err, ErrInvalidDigest)
+	for _, invalid := range [][]byte{nil, privateKey[:31], make([]byte, PrivateKeyLength), order,
+		bytes.Repeat([]byte{0xff}, PrivateKeyLength)} {
+		_, err = Sign(digest[:], invalid)
+		assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+		_, err = PublicKey(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPrivateKey)
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	privateKey := sha256.Sum256([]byte("key"))
+	digest := sha256.Sum256([]byte("digest"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Sign(digest[:], privateKey[:]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPublicKey(b *testing.B) {
+	privateKey := sha256.Sum256([]byte("key"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := PublicKey(privateKey[:]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -144,6 +144,10 @@ const (
 	Qtum            uint16 = 2301
 	Icon            uint16 = 74
 	Tezos           uint16 = 1729
+	Aptos           uint16 = 637
+	Sui             uint16 = 784
+	Ton             uint16 = 607
+	Starknet        uint16 = 9004
 	Chainlink       uint16 = 60 // Uses Ethereum's coin type
 	Uniswap         uint16 = 60 // Uses Ethereum's coin type
 	Compound        uint16 = 60 // Uses Ethereum's coin type
@@ -264,6 +268,14 @@ func GetCoinName(coinType uint16) string {
 		return "Grin"
 	case Beam:
 		return "Beam"
+	case Aptos:
+		return "Aptos"
+	case Sui:
+		return "Sui"
+	case Ton:
+		return "TON"
+	case Starknet:
+		return "StarkNet"
 	default:
 		return "Unknown"
 	}
@@ -275,7 +287,8 @@ func IsSupportedCoinType(coinType uint16) bool {
 	case Bitcoin, TestNet, Ethereum, EthereumClassic, Bitshares, Litecoin, Dogecoin, Zcash, Monero,
 		Stellar, Ripple, Cardano, Cosmos, Binance, Polkadot, Solana, Avalanche, Polygon, Fantom,
 		Harmony, Near, Algorand, Filecoin, Tezos, Qtum, Icon, Waves, Nano, Iota, Ontology, Zilliqa,
-		Vechain, Theta, Hedera, Elrond, Tron, Kusama, Grin, Beam:
+		Vechain, Theta, Hedera, Elrond, Tron, Kusama, Grin, Beam, Aptos, Sui, Ton,
+		Starknet:
 		return true
 	default:
 		return false
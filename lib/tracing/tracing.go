@@ -0,0 +1,124 @@
+// Package tracing records the OpenTelemetry spans of the requests of the plugin and exports them to
+// an OTLP/HTTP collector. Spans are started from the span of their context, with its provider: code
+// called outside of a traced request records nothing.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the spans of the plugin
+const TracerName = "github.com/payment-system/dq-vault"
+
+// DefaultServiceName is the service.name of the spans when Options has none
+const DefaultServiceName = "dq-vault"
+
+// Environment variables of the OTLP exporter, the collector is configured by them when they are set
+const (
+	EnvEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidEndpoint    = errors.New("endpoint must be an http or https URL")
+	ErrInvalidSampleRatio = errors.New("sampleRatio must be between 0 and 1")
+)
+
+// Options -- the collector the spans are exported to, over OTLP/HTTP. Without an Endpoint the
+// exporter reads the OTEL_EXPORTER_OTLP_* environment variables. SampleRatio is the share of the
+// traces started by the plugin that are recorded, the traces of the callers keep their decision.
+type Options struct {
+	Endpoint    string
+	Headers     map[string]string
+	SampleRatio float64
+	ServiceName string
+}
+
+// Validate checks the options without connecting to the collector
+func (o Options) Validate() error {
+	if o.Endpoint != "" {
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidEndpoint
+		}
+	}
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return ErrInvalidSampleRatio
+	}
+	return nil
+}
+
+// EnvConfigured reports whether the environment of the plugin configures a collector
+func EnvConfigured() bool {
+	return os.Getenv(EnvEndpoint) != "" || os.Getenv(EnvTracesEndpoint) != ""
+}
+
+// NewProvider returns a provider exporting the spans to the collector of o in batches. It does not
+// connect until the first batch; Shutdown flushes the spans left.
+func NewProvider(ctx context.Context, o Options) (*sdktrace.TracerProvider, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	var options []otlptracehttp.Option
+	if o.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(o.Endpoint))
+	}
+	if len(o.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(o.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := o.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+	), nil
+}
+
+// Extract returns ctx with the remote span of the W3C traceparent of headers, the request headers
+// passed through by Vault
+func Extract(ctx context.Context, headers map[string][]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(headers))
+}
+
+// Start starts the span name as a child of the span of ctx, with the provider of that span. Outside
+// of a recorded span ctx is returned as is, with a span recording nothing.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.IsRecording() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return parent.TracerProvider().Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends span, with an error status when err is set
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	for _, valid := range []Options{
+		{},
+		{Endpoint: "https://collector:4318/v1/traces", SampleRatio: 0.25},
+		{Endpoint: "http://localhost:4318", SampleRatio: 1},
+	} {
+		assert.NoError(t, valid.Validate(), valid)
+	}
+
+	assert.ErrorIs(t, Options{Endpoint: "collector:4318"}.Validate(), ErrInvalidEndpoint)
+	assert.ErrorIs(t, Options{Endpoint: "grpc://collector:4317"}.Validate(), ErrInvalidEndpoint)
+	assert.ErrorIs(t, Options{SampleRatio: 1.5}.Validate(), ErrInvalidSampleRatio)
+	assert.ErrorIs(t, Options{SampleRatio: -1}.Validate(), ErrInvalidSampleRatio)
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("outside of a recorded span", func(t *testing.T) {
+		started, span := Start(ctx, "stage")
+		assert.Equal(t, ctx, started)
+		assert.False(t, span.IsRecording())
+		End(span, errors.New("ignored"))
+	})
+
+	t.Run("children share the provider of their parent", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, parent := provider.Tracer(TracerName).Start(ctx, "request")
+
+		_, child := Start(ctx, "stage")
+		End(child, errors.New("failed"))
+		End(parent, nil)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		assert.Equal(t, "stage", spans[0].Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	})
+}
+
+func TestExtract(t *testing.T) {
+	ctx := Extract(context.Background(), map[string][]string{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+	remote := trace.SpanContextFromContext(ctx)
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", remote.TraceID().String())
+
+	assert.False(t, trace.SpanContextFromContext(Extract(context.Background(), nil)).IsValid())
+}
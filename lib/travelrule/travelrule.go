@@ -0,0 +1,222 @@
+// Package travelrule validates the travel rule data attached to sign requests and forwards it to
+// the compliance sink. The data is a subset of the IVMS-101 data model: the natural or legal
+// persons originating and receiving the transfer, their accounts, and their VASPs.
+package travelrule
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of the forwarded records, keyed with the
+// signing secret of the sink
+const SignatureHeader = "X-Dq-Vault-Signature"
+
+// EventType is the webhook event type of the records of the signed transfers
+const EventType = "com.dq-vault.travelrule.signed"
+
+// maxFieldLength bounds every text field of a record
+const maxFieldLength = 256
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrInvalidRecord = errors.New("invalid travelRule")
+	ErrSinkStatus    = errors.New("travel rule sink rejected the record")
+)
+
+//nolint:gochecknoglobals // compiled once, read-only
+var (
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+	// lei is an ISO 17442 legal entity identifier
+	lei = regexp.MustCompile(`^[A-Z0-9]{18}[0-9]{2}$`)
+)
+
+// NaturalPerson -- an individual, named by their family name (primaryIdentifier) and given names
+type NaturalPerson struct {
+	PrimaryIdentifier   string `json:"primaryIdentifier"`
+	SecondaryIdentifier string `json:"secondaryIdentifier,omitempty"`
+	DateOfBirth         string `json:"dateOfBirth,omitempty"`
+	CountryOfResidence  string `json:"countryOfResidence,omitempty"`
+	NationalIdentifier  string `json:"nationalIdentifier,omitempty"`
+}
+
+// LegalPerson -- an organization, optionally identified by its LEI
+type LegalPerson struct {
+	LegalName             string `json:"legalName"`
+	LEI                   string `json:"lei,omitempty"`
+	CountryOfRegistration string `json:"countryOfRegistration,omitempty"`
+}
+
+// Party -- the originator or the beneficiary of a transfer: exactly one natural or legal person,
+// and the account it sends from or receives on
+type Party struct {
+	NaturalPerson *NaturalPerson `json:"naturalPerson,omitempty"`
+	LegalPerson   *LegalPerson   `json:"legalPerson,omitempty"`
+	AccountNumber string         `json:"accountNumber,omitempty"`
+}
+
+// VASP -- the virtual asset service provider of a party
+type VASP struct {
+	LegalPerson LegalPerson `json:"legalPerson"`
+}
+
+// Record -- the travel rule data of a transfer
+type Record struct {
+	Originator      Party `json:"originator"`
+	Beneficiary     Party `json:"beneficiary"`
+	OriginatingVASP *VASP `json:"originatingVASP,omitempty"`
+	BeneficiaryVASP *VASP `json:"beneficiaryVASP,omitempty"`
+}
+
+// Parse decodes and validates the travel rule data of a sign request, as received in its JSON form
+func Parse(data map[string]interface{}) (*Record, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	var record Record
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	if err := record.Originator.validate("originator"); err != nil {
+		return nil, err
+	}
+	if err := record.Beneficiary.validate("beneficiary"); err != nil {
+		return nil, err
+	}
+	if record.OriginatingVASP != nil {
+		if err := record.OriginatingVASP.LegalPerson.validate("originatingVASP.legalPerson"); err != nil {
+			return nil, err
+		}
+	}
+	if record.BeneficiaryVASP != nil {
+		if err := record.BeneficiaryVASP.LegalPerson.validate("beneficiaryVASP.legalPerson"); err != nil {
+			return nil, err
+		}
+	}
+	return &record, nil
+}
+
+func (p *Party) validate(name string) error {
+	if (p.NaturalPerson == nil) == (p.LegalPerson == nil) {
+		return fmt.Errorf("%w: %s must have exactly one of naturalPerson and legalPerson", ErrInvalidRecord, name)
+	}
+	if err := checkLength(name+".accountNumber", p.AccountNumber); err != nil {
+		return err
+	}
+	if p.LegalPerson != nil {
+		return p.LegalPerson.validate(name + ".legalPerson")
+	}
+
+	person := p.NaturalPerson
+	name += ".naturalPerson"
+	if person.PrimaryIdentifier == "" {
+		return fmt.Errorf("%w: %s.primaryIdentifier is required", ErrInvalidRecord, name)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"primaryIdentifier", person.PrimaryIdentifier},
+		{"secondaryIdentifier", person.SecondaryIdentifier},
+		{"nationalIdentifier", person.NationalIdentifier},
+	} {
+		if err := checkLength(name+"."+field.name, field.value); err != nil {
+			return err
+		}
+	}
+	if person.DateOfBirth != "" {
+		if _, err := time.Parse(time.DateOnly, person.DateOfBirth); err != nil {
+			return fmt.Errorf("%w: %s.dateOfBirth must be YYYY-MM-DD", ErrInvalidRecord, name)
+		}
+	}
+	if person.CountryOfResidence != "" && !countryCode.MatchString(person.CountryOfResidence) {
+		return fmt.Errorf("%w: %s.countryOfResidence must be an ISO 3166 alpha-2 code", ErrInvalidRecord, name)
+	}
+	return nil
+}
+
+func (p *LegalPerson) validate(name string) error {
+	if p.LegalName == "" {
+		return fmt.Errorf("%w: %s.legalName is required", ErrInvalidRecord, name)
+	}
+	if err := checkLength(name+".legalName", p.LegalName); err != nil {
+		return err
+	}
+	if p.LEI != "" && !lei.MatchString(p.LEI) {
+		return fmt.Errorf("%w: %s.lei must be a 20 character LEI", ErrInvalidRecord, name)
+	}
+	if p.CountryOfRegistration != "" && !countryCode.MatchString(p.CountryOfRegistration) {
+		return fmt.Errorf("%w: %s.countryOfRegistration must be an ISO 3166 alpha-2 code", ErrInvalidRecord, name)
+	}
+	return nil
+}
+
+func checkLength(name, value string) error {
+	if len(value) > maxFieldLength {
+		return fmt.Errorf("%w: %s is longer than %d bytes", ErrInvalidRecord, name, maxFieldLength)
+	}
+	return nil
+}
+
+// Hash returns the hex SHA-256 of the JSON form of the record, which ties the logs of a signature
+// to its travel rule data without logging the personal data
+func (r *Record) Hash() string {
+	// the fields are encoded in the order of the structs
+	encoded, _ := json.Marshal(r)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Event -- a signed transfer and its travel rule data, as forwarded to the sink
+type Event struct {
+	Record    *Record   `json:"travelRule"`
+	Hash      string    `json:"travelRuleHash"`
+	UUID      string    `json:"uuid"`
+	CoinType  uint16    `json:"coinType"`
+	Address   string    `json:"address"`
+	Signature string    `json:"signature"`
+	SignedAt  time.Time `json:"signedAt"`
+}
+
+// Forward posts body, the event of a record as transformed for the sink, to the sink URL, signing
+// it with secret when it is set. The URL may hold credentials and is not part of the errors.
+func Forward(ctx context.Context, client *http.Client, sinkURL, secret, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sinkURL, bytes.NewReader(body))
+	if err != nil {
+		return ErrSinkStatus
+	}
+	req.Header.Set("Content-Type", contentType)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: status %d", ErrSinkStatus, resp.StatusCode)
+	}
+	return nil
+}
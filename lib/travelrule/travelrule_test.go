@@ -0,0 +1,117 @@
+package travelrule
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"originator": map[string]interface{}{
+			"naturalPerson": map[string]interface{}{
+				"primaryIdentifier": "Lovelace", "secondaryIdentifier": "Ada",
+				"dateOfBirth": "1815-12-10", "countryOfResidence": "GB",
+			},
+			"accountNumber": "customer-42",
+		},
+		"beneficiary": map[string]interface{}{
+			"legalPerson":   map[string]interface{}{"legalName": "Example Exchange Ltd"},
+			"accountNumber": "0x742d35Cc6634C0532925a3b8D359A5C5119e32C8",
+		},
+		"beneficiaryVASP": map[string]interface{}{
+			"legalPerson": map[string]interface{}{"legalName": "Example Exchange Ltd", "lei": "5493001KJTIIGC8Y1R12"},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	record, err := Parse(testRecord())
+	require.NoError(t, err)
+	assert.Equal(t, "Lovelace", record.Originator.NaturalPerson.PrimaryIdentifier)
+	assert.Equal(t, "5493001KJTIIGC8Y1R12", record.BeneficiaryVASP.LegalPerson.LEI)
+	assert.Nil(t, record.OriginatingVASP)
+
+	again, err := Parse(testRecord())
+	require.NoError(t, err)
+	assert.Equal(t, record.Hash(), again.Hash())
+	assert.Len(t, record.Hash(), 64)
+
+	tests := []struct {
+		name   string
+		modify func(map[string]interface{})
+		want   string
+	}{
+		{"unknown field", func(r map[string]interface{}) { r["amount"] = 1 }, "unknown field"},
+		{"missing party", func(r map[string]interface{}) { delete(r, "beneficiary") }, "beneficiary must have"},
+		{"both persons", func(r map[string]interface{}) {
+			r["originator"].(map[string]interface{})["legalPerson"] = map[string]interface{}{"legalName": "Acme"}
+		}, "originator must have"},
+		{"missing name", func(r map[string]interface{}) {
+			r["beneficiary"].(map[string]interface{})["legalPerson"] = map[string]interface{}{}
+		}, "beneficiary.legalPerson.legalName is required"},
+		{"date of birth", func(r map[string]interface{}) {
+			r["originator"].(map[string]interface{})["naturalPerson"].(map[string]interface{})["dateOfBirth"] = "10/12/1815"
+		}, "dateOfBirth"},
+		{"country", func(r map[string]interface{}) {
+			r["originator"].(map[string]interface{})["naturalPerson"].(map[string]interface{})["countryOfResidence"] = "GBR"
+		}, "countryOfResidence"},
+		{"lei", func(r map[string]interface{}) {
+			r["beneficiaryVASP"].(map[string]interface{})["legalPerson"].(map[string]interface{})["lei"] = "LEI"
+		}, "lei"},
+		{"length", func(r map[string]interface{}) {
+			r["originator"].(map[string]interface{})["accountNumber"] = strings.Repeat("a", maxFieldLength+1)
+		}, "accountNumber is longer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testRecord()
+			tt.modify(data)
+			_, err := Parse(data)
+			require.ErrorIs(t, err, ErrInvalidRecord)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	record, err := Parse(testRecord())
+	require.NoError(t, err)
+	event := Event{Record: record, Hash: record.Hash(), UUID: "uuid", CoinType: 60, Signature: "0x01",
+		SignedAt: time.Unix(0, 0).UTC()}
+	encoded, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	var body []byte
+	var signature string
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer sink.Close()
+
+	require.NoError(t, Forward(context.Background(), sink.Client(), sink.URL+"/records?token=secret", "key", "application/json", encoded))
+	assert.Contains(t, string(body), `"travelRuleHash":"`+record.Hash()+`"`)
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(body)
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), signature)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	err = Forward(context.Background(), failing.Client(), failing.URL+"/records?token=secret", "", "application/json", encoded)
+	require.ErrorIs(t, err, ErrSinkStatus)
+	assert.NotContains(t, err.Error(), "token=secret")
+}
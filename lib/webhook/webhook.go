@@ -0,0 +1,164 @@
+// Package webhook transforms the events posted to the outgoing webhooks of the mount, the approval
+// notifications and the travel rule records, into the format their receiver expects: its native
+// body, a CloudEvents 1.0 envelope, or the output of a custom template.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Formats of the webhook bodies
+const (
+	// FormatNative posts the body of the receiver, a chat message or a travel rule record
+	FormatNative = "native"
+	// FormatCloudEvents posts a CloudEvents 1.0 envelope in structured JSON mode
+	FormatCloudEvents = "cloudevents"
+	// FormatTemplate posts the output of a text/template executed with the event
+	FormatTemplate = "template"
+)
+
+// Content types of the webhook bodies
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeCloudEvents = "application/cloudevents+json"
+)
+
+// CloudEventsSpecVersion is the version of the CloudEvents specification of the envelopes
+const CloudEventsSpecVersion = "1.0"
+
+// Static error variables to avoid dynamic error creation
+var (
+	ErrUnknownFormat   = errors.New("format must be native, cloudevents or template")
+	ErrInvalidTemplate = errors.New("invalid webhook template")
+	ErrTemplateUnused  = errors.New("template is only used by the template format")
+)
+
+// Event is an event posted to a webhook
+type Event struct {
+	// ID identifies the event within its Source
+	ID string
+	// Source is the URI reference of the mount that produced the event
+	Source string
+	// Type is the reverse-DNS type of the event, e.g. com.dq-vault.approval.requested
+	Type string
+	// Subject is the user the event is about
+	Subject string
+	Time    time.Time
+	// Data is the payload of the event, encoded as JSON by the envelopes and the templates
+	Data interface{}
+}
+
+// Transformer turns an event into the body posted to a webhook and its content type, native is
+// the body of the event in the format of the receiver
+type Transformer interface {
+	Transform(event Event, native []byte) ([]byte, string, error)
+}
+
+// NewTransformer returns the transformer of format, text is the template of FormatTemplate. The
+// empty format is FormatNative.
+func NewTransformer(format, text string) (Transformer, error) {
+	if format != FormatTemplate && text != "" {
+		return nil, ErrTemplateUnused
+	}
+	switch format {
+	case "", FormatNative:
+		return nativeTransformer{}, nil
+	case FormatCloudEvents:
+		return cloudEventsTransformer{}, nil
+	case FormatTemplate:
+		return newTemplateTransformer(text)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+type nativeTransformer struct{}
+
+func (nativeTransformer) Transform(_ Event, native []byte) ([]byte, string, error) {
+	return native, ContentTypeJSON, nil
+}
+
+type cloudEventsTransformer struct{}
+
+// cloudEvent is the structured mode JSON form of a CloudEvents 1.0 event
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            string      `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+func (cloudEventsTransformer) Transform(event Event, _ []byte) ([]byte, string, error) {
+	body, err := json.Marshal(cloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          event.Source,
+		Type:            event.Type,
+		Subject:         event.Subject,
+		Time:            event.Time.UTC().Format(time.RFC3339Nano),
+		DataContentType: ContentTypeJSON,
+		Data:            event.Data,
+	})
+	return body, ContentTypeCloudEvents, err
+}
+
+type templateTransformer struct {
+	template *template.Template
+}
+
+// templateData is what the templates are executed with, Data is the event data as decoded from
+// its JSON form, so the templates address its fields by their JSON names
+type templateData struct {
+	ID, Source, Type, Subject, Time string
+	Data                            interface{}
+}
+
+//nolint:gochecknoglobals // read-only lookup table
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+func newTemplateTransformer(text string) (Transformer, error) {
+	if text == "" {
+		return nil, fmt.Errorf("%w: template is required", ErrInvalidTemplate)
+	}
+	parsed, err := template.New("webhook").Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+	return templateTransformer{template: parsed}, nil
+}
+
+func (t templateTransformer) Transform(event Event, _ []byte) ([]byte, string, error) {
+	encoded, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	data := templateData{
+		ID:      event.ID,
+		Source:  event.Source,
+		Type:    event.Type,
+		Subject: event.Subject,
+		Time:    event.Time.UTC().Format(time.RFC3339Nano),
+	}
+	if err := json.Unmarshal(encoded, &data.Data); err != nil {
+		return nil, "", err
+	}
+
+	var body bytes.Buffer
+	if err := t.template.Execute(&body, data); err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+	return body.Bytes(), ContentTypeJSON, nil
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent() Event {
+	return Event{
+		ID:      "cs1ab7r2a3ohq8hm58r0",
+		Source:  "/dq",
+		Type:    "com.dq-vault.approval.requested",
+		Subject: "user-1",
+		Time:    time.Unix(1_700_000_000, 0),
+		Data:    map[string]interface{}{"id": "cs1ab7r2a3ohq8hm58r0", "summary": map[string]interface{}{"value": "10"}},
+	}
+}
+
+func TestNewTransformer(t *testing.T) {
+	for _, format := range []string{"", FormatNative, FormatCloudEvents} {
+		_, err := NewTransformer(format, "")
+		require.NoError(t, err, format)
+	}
+	_, err := NewTransformer("xml", "")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+	_, err = NewTransformer(FormatCloudEvents, "{{.ID}}")
+	require.ErrorIs(t, err, ErrTemplateUnused)
+	_, err = NewTransformer(FormatTemplate, "")
+	require.ErrorIs(t, err, ErrInvalidTemplate)
+	_, err = NewTransformer(FormatTemplate, "{{.ID")
+	require.ErrorIs(t, err, ErrInvalidTemplate)
+}
+
+func TestTransform_Native(t *testing.T) {
+	transformer, err := NewTransformer(FormatNative, "")
+	require.NoError(t, err)
+	body, contentType, err := transformer.Transform(testEvent(), []byte(`{"text":"hello"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"text":"hello"}`, string(body))
+	assert.Equal(t, ContentTypeJSON, contentType)
+}
+
+func TestTransform_CloudEvents(t *testing.T) {
+	transformer, err := NewTransformer(FormatCloudEvents, "")
+	require.NoError(t, err)
+	body, contentType, err := transformer.Transform(testEvent(), []byte(`{"text":"hello"}`))
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeCloudEvents, contentType)
+
+	var envelope map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	assert.Equal(t, map[string]interface{}{
+		"specversion":     "1.0",
+		"id":              "cs1ab7r2a3ohq8hm58r0",
+		"source":          "/dq",
+		"type":            "com.dq-vault.approval.requested",
+		"subject":         "user-1",
+		"time":            "2023-11-14T22:13:20Z",
+		"datacontenttype": "application/json",
+		"data":            map[string]interface{}{"id": "cs1ab7r2a3ohq8hm58r0", "summary": map[string]interface{}{"value": "10"}},
+	}, envelope)
+}
+
+func TestTransform_Template(t *testing.T) {
+	transformer, err := NewTransformer(FormatTemplate,
+		`{"event":"{{.Type}}","approval":{{json .Data.id}},"value":"{{.Data.summary.value}}","at":"{{.Time}}"}`)
+	require.NoError(t, err)
+	body, contentType, err := transformer.Transform(testEvent(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeJSON, contentType)
+	assert.JSONEq(t, `{"event":"com.dq-vault.approval.requested","approval":"cs1ab7r2a3ohq8hm58r0","value":"10",`+
+		`"at":"2023-11-14T22:13:20Z"}`, string(body))
+
+	missing, err := NewTransformer(FormatTemplate, `{{.Data.missing.value}}`)
+	require.NoError(t, err)
+	_, _, err = missing.Transform(testEvent(), nil)
+	require.ErrorIs(t, err, ErrInvalidTemplate)
+}
@@ -0,0 +1,45 @@
+#!/bin/bash
+
+# Runs the key derivation and signing benchmarks and prints them as a table.
+# With BASELINE set to the output of an earlier run, benchstat compares both runs instead:
+#   make bench                                 # writes .bench/<revision>.txt
+#   BASELINE=.bench/<revision>.txt make bench  # after the change under test
+
+set -o pipefail
+
+COUNT=${BENCH_COUNT:-6}
+PACKAGES=${BENCH_PACKAGES:-"./lib/..."}
+TAGS=${BENCH_TAGS:-""}
+OUT_DIR=.bench
+mkdir -p "$OUT_DIR"
+OUT="$OUT_DIR/$(git rev-parse --short HEAD 2>/dev/null || echo current)$(git diff --quiet 2>/dev/null || echo -dirty).txt"
+
+echo "running benchmarks (count=$COUNT) ..."
+# shellcheck disable=SC2086
+go test -tags "$TAGS" -run '^$' -bench . -benchmem -count "$COUNT" $PACKAGES | grep -v '^time=' | tee "$OUT" > /dev/null || exit 1
+echo "results written to $OUT"
+
+if [ -n "$BASELINE" ]; then
+	if command -v benchstat > /dev/null; then
+		benchstat "$BASELINE" "$OUT"
+	else
+		go run golang.org/x/perf/cmd/benchstat@latest "$BASELINE" "$OUT"
+	fi
+	exit $?
+fi
+
+# mean of every benchmark over the runs
+awk '
+	$1 ~ /^Benchmark/ {
+		name = $1; sub(/-[0-9]+$/, "", name)
+		if (!(name in runs)) order[n++] = name
+		runs[name]++; ns[name] += $3; bytes[name] += $5; allocs[name] += $7
+	}
+	END {
+		printf "%-60s %14s %12s %10s\n", "benchmark", "ns/op", "B/op", "allocs/op"
+		for (i = 0; i < n; i++) {
+			name = order[i]
+			printf "%-60s %14.0f %12.0f %10.0f\n", name, ns[name] / runs[name], bytes[name] / runs[name],
+				allocs[name] / runs[name]
+		}
+	}' "$OUT"